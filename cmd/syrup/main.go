@@ -38,19 +38,38 @@ func init() {
 	viper.SetDefault("server.maxConnections", 10)
 	viper.SetDefault("server.maxConnPerHost", 2)
 	viper.SetDefault("server.timeout", time.Duration(time.Minute*10))
+	viper.SetDefault("server.bootWindow", time.Duration(0))
 	viper.SetDefault("server.speed", 0)
 	viper.SetDefault("server.processDelay", 0)
 	viper.SetDefault("server.hostname", "spr1139")
+	viper.SetDefault("server.prompt", `\u@\h:\w\$ `)
 	viper.SetDefault("server.commandList", "commands.txt")
 	viper.SetDefault("server.sessionLogFmt", "asciinema")
 	viper.SetDefault("server.banner", "banner.txt")
 	viper.SetDefault("server.privateKey", "id_rsa")
 	viper.SetDefault("server.portRedirection", "disable")
 	viper.SetDefault("server.commandOutputDir", "cmdOutput")
+	viper.SetDefault("server.manDir", "man")
+	viper.SetDefault("server.unknownCommandList", "unknowncmd.txt")
+	viper.SetDefault("server.loginHistory", "logs/logins.json")
+	viper.SetDefault("server.allowDownload", true)
+	viper.SetDefault("server.downloadFileSizeLimit", 0)
+	viper.SetDefault("server.writeFileSizeLimit", 16<<20)
+	viper.SetDefault("server.artifactDir", "artifacts")
+	viper.SetDefault("server.mirrorGitRepos", true)
 	viper.SetDefault("virtualfs.imageFile", "filesystem.zip")
 	viper.SetDefault("virtualfs.uidMappingFile", "passwd")
 	viper.SetDefault("virtualfs.gidMappingFile", "group")
 	viper.SetDefault("virtualfs.savedFileDir", "tempdir")
+	viper.SetDefault("persona.distro", "ubuntu")
+	viper.SetDefault("persona.arch", "x86_64")
+	viper.SetDefault("persona.address", "192.168.1.34")
+	viper.SetDefault("persona.netmask", "255.255.255.0")
+	viper.SetDefault("persona.gateway", "192.168.1.1")
+	viper.SetDefault("persona.mac", "52:54:00:3a:7c:91")
+	viper.SetDefault("persona.timezone", "UTC")
+	viper.SetDefault("persona.clockOffset", 0)
+	viper.SetDefault("persona.sudo.password", "any")
 	viper.SetDefault("asciinema.apiEndpoint", "https://asciinema.org")
 }
 
@@ -73,6 +92,7 @@ func main() {
 	}
 	pathMap := lfshook.PathMap{
 		log.InfoLevel: "logs/activity.log",
+		log.WarnLevel: "logs/activity.log",
 	}
 	if _, err = os.Stat("logs"); os.IsNotExist(err) {
 		err = os.MkdirAll("logs/sessions", 0755)
@@ -95,9 +115,9 @@ func main() {
 		log.AddHook(hook)
 	}
 
-	err = honeyos.LoadGroups(path.Join(configPath, viper.GetString("virtualfs.uidMappingFile")))
+	err = honeyos.LoadGroups(path.Join(configPath, viper.GetString("virtualfs.gidMappingFile")))
 	if err != nil {
-		log.Errorf("Cannot load group mapping file %v", path.Join(configPath, viper.GetString("virtualfs.uidMappingFile")))
+		log.Errorf("Cannot load group mapping file %v", path.Join(configPath, viper.GetString("virtualfs.gidMappingFile")))
 	}
 	// Load command list
 	honeyos.RegisterFakeCommand(readFiletoArray(path.Join(configPath, viper.GetString("server.commandList"))))
@@ -113,6 +133,10 @@ func main() {
 			}
 		}
 	}
+	// Load manual pages
+	if err := honeyos.RegisterManPages(viper.GetString("server.manDir")); err != nil {
+		log.WithError(err).Warn("Cannot load manual pages")
+	}
 	// Randomize seed
 	rand.Seed(time.Now().Unix())
 
@@ -5,8 +5,10 @@ import (
 	"errors"
 	"math/rand"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 type User struct {
@@ -26,6 +28,8 @@ type Group struct {
 }
 
 var (
+	// accountMu guards the accounts, which commands like useradd change
+	accountMu       sync.RWMutex
 	users           = make(map[int]User)
 	usernameMapping = make(map[string]User)
 	groups          = make(map[int]Group)
@@ -77,9 +81,14 @@ func LoadGroups(groupFile string) error {
 		if err != nil {
 			return err
 		}
+		var members []string
+		if len(fields) > 3 && fields[3] != "" {
+			members = strings.Split(fields[3], ",")
+		}
 		groups[gid] = Group{
-			GID:  gid,
-			Name: fields[0],
+			GID:      gid,
+			Name:     fields[0],
+			Userlist: members,
 		}
 	}
 
@@ -87,6 +96,8 @@ func LoadGroups(groupFile string) error {
 }
 
 func IsUserExist(user string) (pass string, exists bool) {
+	accountMu.RLock()
+	defer accountMu.RUnlock()
 	userObj, exists := usernameMapping[user]
 	if !exists {
 		return
@@ -95,18 +106,38 @@ func IsUserExist(user string) (pass string, exists bool) {
 }
 
 func GetUser(name string) User {
+	accountMu.RLock()
+	defer accountMu.RUnlock()
 	return usernameMapping[name]
 }
 
 func GetUserByID(id int) User {
+	accountMu.RLock()
+	defer accountMu.RUnlock()
 	return users[id]
 }
 
 func GetGroupByID(id int) Group {
+	accountMu.RLock()
+	defer accountMu.RUnlock()
 	return groups[id]
 }
 
+// GetGroup finds the group by name
+func GetGroup(name string) (Group, bool) {
+	accountMu.RLock()
+	defer accountMu.RUnlock()
+	for _, g := range groups {
+		if g.Name == name {
+			return g, true
+		}
+	}
+	return Group{}, false
+}
+
 func CreateUser(name, password string) (newUser User, e error) {
+	accountMu.Lock()
+	defer accountMu.Unlock()
 	if _, exists := usernameMapping[name]; exists {
 		return newUser, errors.New("User already exists")
 	}
@@ -122,3 +153,135 @@ func CreateUser(name, password string) (newUser User, e error) {
 	users[newUser.UID] = newUser
 	return
 }
+
+// AddUser adds the account created in the session, e.g. by useradd, so the
+// user can login with it afterwards
+func AddUser(u User) error {
+	accountMu.Lock()
+	defer accountMu.Unlock()
+	if _, exists := usernameMapping[u.Name]; exists {
+		return errors.New("User already exists")
+	}
+	usernameMapping[u.Name] = u
+	if _, exists := users[u.UID]; !exists {
+		users[u.UID] = u
+	}
+	return nil
+}
+
+// RemoveUser deletes the account and its group of the same name, and
+// removes it from members of other groups
+func RemoveUser(name string) {
+	accountMu.Lock()
+	defer accountMu.Unlock()
+	u, exists := usernameMapping[name]
+	if !exists {
+		return
+	}
+	delete(usernameMapping, name)
+	if users[u.UID].Name == name {
+		delete(users, u.UID)
+	}
+	if groups[u.GID].Name == name {
+		delete(groups, u.GID)
+	}
+	for gid, g := range groups {
+		var members []string
+		for _, m := range g.Userlist {
+			if m != name {
+				members = append(members, m)
+			}
+		}
+		g.Userlist = members
+		groups[gid] = g
+	}
+}
+
+// SetPassword changes the password which the user can login with
+func SetPassword(name, password string) {
+	accountMu.Lock()
+	defer accountMu.Unlock()
+	u, exists := usernameMapping[name]
+	if !exists {
+		return
+	}
+	u.Password = password
+	usernameMapping[name] = u
+	if users[u.UID].Name == name {
+		users[u.UID] = u
+	}
+}
+
+// AddGroup adds the group created in the session
+func AddGroup(g Group) error {
+	accountMu.Lock()
+	defer accountMu.Unlock()
+	if _, exists := groups[g.GID]; exists {
+		return errors.New("Group already exists")
+	}
+	groups[g.GID] = g
+	return nil
+}
+
+// AddGroupMember adds the user to the supplementary members of the group
+func AddGroupMember(group, user string) {
+	accountMu.Lock()
+	defer accountMu.Unlock()
+	for gid, g := range groups {
+		if g.Name == group {
+			g.Userlist = append(g.Userlist, user)
+			groups[gid] = g
+			return
+		}
+	}
+}
+
+// Users returns all accounts ordered by UID, as listed in /etc/passwd
+func Users() []User {
+	accountMu.RLock()
+	defer accountMu.RUnlock()
+	list := make([]User, 0, len(usernameMapping))
+	for _, u := range usernameMapping {
+		list = append(list, u)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].UID != list[j].UID {
+			return list[i].UID < list[j].UID
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// Groups returns all groups ordered by GID, as listed in /etc/group
+func Groups() []Group {
+	accountMu.RLock()
+	defer accountMu.RUnlock()
+	list := make([]Group, 0, len(groups))
+	for _, g := range groups {
+		list = append(list, g)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].GID < list[j].GID })
+	return list
+}
+
+// UserGroups returns the groups of the user, the primary group first and
+// then the supplementary groups by GID, like id and groups list them
+func UserGroups(u User) []Group {
+	accountMu.RLock()
+	defer accountMu.RUnlock()
+	list := []Group{{GID: u.GID, Name: groups[u.GID].Name}}
+	for _, g := range groups {
+		if g.GID == u.GID {
+			continue
+		}
+		for _, m := range g.Userlist {
+			if m == u.Name {
+				list = append(list, g)
+				break
+			}
+		}
+	}
+	sort.Slice(list[1:], func(i, j int) bool { return list[i+1].GID < list[j+1].GID })
+	return list
+}
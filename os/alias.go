@@ -0,0 +1,119 @@
+package os
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// defaultAliases are those defined in .bashrc of a fresh Ubuntu installation
+var defaultAliases = map[string]string{
+	"alert": `notify-send --urgency=low -i "$([ $? = 0 ] && echo terminal || echo error)" "$(history|tail -n1|sed -e 's/^\s*[0-9]\+\s*//;s/[;&|]\s*alert$//')"`,
+	"egrep": "egrep --color=auto",
+	"fgrep": "fgrep --color=auto",
+	"grep":  "grep --color=auto",
+	"l":     "ls -CF",
+	"la":    "ls -A",
+	"ll":    "ls -alF",
+	"ls":    "ls --color=auto",
+}
+
+func newAliases() map[string]string {
+	aliases := make(map[string]string, len(defaultAliases))
+	for k, v := range defaultAliases {
+		aliases[k] = v
+	}
+	return aliases
+}
+
+// expandAliases replaces the first word of each simple command with its
+// alias. Like bash, if an alias ends with blank, the word next to it will
+// be expanded as well
+func (sh *Shell) expandAliases(tokens []token) []token {
+	var result []token
+	cmdPos := true
+	for _, tok := range tokens {
+		if tok.typ != tokWord {
+			result = append(result, tok)
+			cmdPos = tok.typ != tokRedirect
+			continue
+		}
+		if !cmdPos || isAssignment(tok.val) {
+			// Assignments before the command don't change the command position
+			result = append(result, tok)
+			continue
+		}
+		expanded, checkNext := sh.expandAlias(tok, map[string]bool{})
+		result = append(result, expanded...)
+		cmdPos = checkNext
+	}
+	return result
+}
+
+func (sh *Shell) expandAlias(tok token, seen map[string]bool) (tokens []token, checkNext bool) {
+	value, ok := sh.sys.aliases[tok.val]
+	if !ok || seen[tok.val] {
+		return []token{tok}, false
+	}
+	seen[tok.val] = true
+	aliasTokens, err := lex(value)
+	if err != nil || len(aliasTokens) == 0 {
+		return []token{tok}, false
+	}
+	// Aliases can be nested, e.g. ll='ls -alF' and ls='ls --color=auto'
+	if aliasTokens[0].typ == tokWord {
+		first, _ := sh.expandAlias(aliasTokens[0], seen)
+		aliasTokens = append(first, aliasTokens[1:]...)
+	}
+	return aliasTokens, strings.HasSuffix(value, " ") || strings.HasSuffix(value, "\t")
+}
+
+func builtinAlias(sh *Shell, args []string, proc *process) int {
+	if len(args) == 1 || len(args) == 2 && args[1] == "-p" {
+		names := make([]string, 0, len(sh.sys.aliases))
+		for name := range sh.sys.aliases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			printAlias(sh, name, proc)
+		}
+		return 0
+	}
+	res := 0
+	for _, arg := range args[1:] {
+		if i := strings.IndexByte(arg, '='); i > 0 {
+			sh.sys.aliases[arg[:i]] = arg[i+1:]
+			sh.log.WithField("alias", arg).Infof("User defined alias %v", arg)
+		} else if _, exists := sh.sys.aliases[arg]; exists {
+			printAlias(sh, arg, proc)
+		} else {
+			sh.errorf(proc, "alias: %v: not found", arg)
+			res = 1
+		}
+	}
+	return res
+}
+
+func printAlias(sh *Shell, name string, proc *process) {
+	fmt.Fprintf(proc.Out(), "alias %v='%v'\n", name, strings.Replace(sh.sys.aliases[name], "'", `'\''`, -1))
+}
+
+func builtinUnalias(sh *Shell, args []string, proc *process) int {
+	if len(args) == 1 {
+		fmt.Fprintln(proc.Err(), "unalias: usage: unalias [-a] name [name ...]")
+		return 2
+	}
+	res := 0
+	for _, arg := range args[1:] {
+		if arg == "-a" {
+			sh.sys.aliases = map[string]string{}
+		} else if _, exists := sh.sys.aliases[arg]; exists {
+			delete(sh.sys.aliases, arg)
+		} else {
+			sh.errorf(proc, "unalias: %v: not found", arg)
+			res = 1
+		}
+	}
+	return res
+}
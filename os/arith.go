@@ -0,0 +1,390 @@
+package os
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// arithError is the error of an arithmetic expression, with the part of the
+// expression where it is found
+type arithError struct {
+	expr, msg, token string
+}
+
+func (e arithError) Error() string {
+	return fmt.Sprintf("%v: %v (error token is \"%v\")", e.expr, e.msg, e.token)
+}
+
+// arithOps are the operators of arithmetic expressions, longest first
+var arithOps = []string{
+	"<<=", ">>=",
+	"**", "<<", ">>", "<=", ">=", "==", "!=", "&&", "||", "++", "--",
+	"+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=",
+	"+", "-", "*", "/", "%", "<", ">", "&", "|", "^", "!", "~", "?", ":", "=", "(", ")",
+}
+
+// arithParser evaluates the expression as it parses it, with the 64-bit
+// integers of bash. Variables not holding numbers count as 0
+type arithParser struct {
+	sh   *Shell
+	expr string
+	pos  int
+	// last is where the token read last starts, for errors
+	last int
+	// skip is set in the operands not evaluated, like the right of && when
+	// the left is false, so they don't assign or fail on division by 0
+	skip int
+}
+
+// arith evaluates the expression of $((...))
+func (sh *Shell) arith(expr string) (n int64, err error) {
+	p := &arithParser{sh: sh, expr: expr}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(arithError)
+			if !ok {
+				panic(r)
+			}
+			n, err = 0, e
+		}
+	}()
+	if strings.TrimSpace(expr) == "" {
+		return 0, nil
+	}
+	n = p.comma()
+	if p.peek() != "" || p.pos < len(p.expr) {
+		p.last = p.pos
+		p.fail("syntax error in expression")
+	}
+	return n, nil
+}
+
+// arithExpand evaluates $((expr)) after expanding parameters and command
+// substitutions in it. Errors are printed and expand to nothing
+func (sh *Shell) arithExpand(expr string) string {
+	expr = sh.expandHeredoc(expr)
+	n, err := sh.arith(expr)
+	if err != nil {
+		if sh.stderr != nil {
+			fmt.Fprintf(sh.stderr, "%v: %v\n", sh.name, err)
+		}
+		sh.status = 1
+		return ""
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+func (p *arithParser) fail(msg string) {
+	panic(arithError{strings.TrimSpace(p.expr), msg, strings.TrimSpace(p.expr[p.last:])})
+}
+
+func (p *arithParser) skipSpace() {
+	for p.pos < len(p.expr) && strings.ContainsRune(" \t\n", rune(p.expr[p.pos])) {
+		p.pos++
+	}
+}
+
+// peek returns the operator at the position, or "" if there is none
+func (p *arithParser) peek() string {
+	p.skipSpace()
+	for _, op := range arithOps {
+		if strings.HasPrefix(p.expr[p.pos:], op) {
+			return op
+		}
+	}
+	return ""
+}
+
+// accept reads the operator if it is next
+func (p *arithParser) accept(ops ...string) string {
+	next := p.peek()
+	for _, op := range ops {
+		if next == op {
+			p.last = p.pos
+			p.pos += len(op)
+			return op
+		}
+	}
+	return ""
+}
+
+func (p *arithParser) comma() int64 {
+	n := p.assign()
+	for p.peek() == "" && p.pos < len(p.expr) && p.expr[p.pos] == ',' {
+		p.pos++
+		n = p.assign()
+	}
+	return n
+}
+
+// assign is name = expr and the compound assignments like +=, or the
+// conditional expression
+func (p *arithParser) assign() int64 {
+	start := p.pos
+	p.skipSpace()
+	if name := p.name(); name != "" {
+		if op := p.accept("=", "+=", "-=", "*=", "/=", "%=", "<<=", ">>=", "&=", "|=", "^="); op != "" {
+			n := p.assign()
+			if op != "=" {
+				n = p.binary(op[:len(op)-1], p.variable(name), n)
+			}
+			p.set(name, n)
+			return n
+		}
+	}
+	p.pos = start
+	return p.ternary()
+}
+
+func (p *arithParser) ternary() int64 {
+	cond := p.logical(0)
+	if p.accept("?") == "" {
+		return cond
+	}
+	if cond == 0 {
+		p.skip++
+	}
+	a := p.assign()
+	if cond == 0 {
+		p.skip--
+	}
+	if p.accept(":") == "" {
+		p.fail("expected `:'")
+	}
+	if cond != 0 {
+		p.skip++
+	}
+	b := p.ternary()
+	if cond != 0 {
+		p.skip--
+		return a
+	}
+	return b
+}
+
+// arithLevels are the binary operators by precedence, lowest first
+var arithLevels = [][]string{
+	{"||"}, {"&&"}, {"|"}, {"^"}, {"&"}, {"==", "!="}, {"<=", ">=", "<", ">"},
+	{"<<", ">>"}, {"+", "-"}, {"*", "/", "%"},
+}
+
+// logical parses the binary operators from the level of precedence up
+func (p *arithParser) logical(level int) int64 {
+	if level == len(arithLevels) {
+		return p.power()
+	}
+	n := p.logical(level + 1)
+	for {
+		// Compound assignments and ++ are not the operators they start with
+		switch next := p.peek(); {
+		case next == "" || strings.HasSuffix(next, "=") && next != "==" && next != "!=" && next != "<=" && next != ">=":
+			return n
+		}
+		op := p.accept(arithLevels[level]...)
+		if op == "" {
+			return n
+		}
+		// The right of && and || is only evaluated if it decides
+		short := op == "&&" && n == 0 || op == "||" && n != 0
+		if short {
+			p.skip++
+		}
+		m := p.logical(level + 1)
+		if short {
+			p.skip--
+		}
+		n = p.binary(op, n, m)
+	}
+}
+
+// power is ** which is right associative
+func (p *arithParser) power() int64 {
+	n := p.unary()
+	if p.accept("**") == "" {
+		return n
+	}
+	exp := p.power()
+	if exp < 0 {
+		if p.skip == 0 {
+			p.fail("exponent less than 0")
+		}
+		return 0
+	}
+	result := int64(1)
+	for ; exp > 0; exp-- {
+		result *= n
+	}
+	return result
+}
+
+func (p *arithParser) unary() int64 {
+	switch op := p.accept("++", "--", "+", "-", "!", "~"); op {
+	case "++", "--":
+		p.skipSpace()
+		name := p.name()
+		if name == "" {
+			p.fail("syntax error: operand expected")
+		}
+		n := p.variable(name) + 1
+		if op == "--" {
+			n -= 2
+		}
+		p.set(name, n)
+		return n
+	case "+":
+		return p.unary()
+	case "-":
+		return -p.unary()
+	case "!":
+		if p.unary() == 0 {
+			return 1
+		}
+		return 0
+	case "~":
+		return ^p.unary()
+	}
+	return p.primary()
+}
+
+func (p *arithParser) primary() int64 {
+	if p.accept("(") != "" {
+		n := p.comma()
+		if p.accept(")") == "" {
+			p.fail("missing `)'")
+		}
+		return n
+	}
+	p.skipSpace()
+	if name := p.name(); name != "" {
+		n := p.variable(name)
+		if op := p.accept("++", "--"); op != "" {
+			if op == "++" {
+				p.set(name, n+1)
+			} else {
+				p.set(name, n-1)
+			}
+		}
+		return n
+	}
+	start := p.pos
+	for p.pos < len(p.expr) && (isAlnum(p.expr[p.pos]) || p.expr[p.pos] == '#') {
+		p.pos++
+	}
+	if start == p.pos {
+		p.fail("syntax error: operand expected")
+	}
+	p.last = start
+	n, ok := arithNumber(p.expr[start:p.pos])
+	if !ok {
+		p.fail("value too great for base")
+	}
+	return n
+}
+
+// name reads the variable name at the position, if any
+func (p *arithParser) name() string {
+	start := p.pos
+	if p.pos < len(p.expr) && (p.expr[p.pos] == '_' || isLetter(p.expr[p.pos])) {
+		for p.pos < len(p.expr) && (p.expr[p.pos] == '_' || isAlnum(p.expr[p.pos])) {
+			p.pos++
+		}
+	}
+	if start != p.pos {
+		p.last = start
+	}
+	return p.expr[start:p.pos]
+}
+
+// variable is the value of the variable as a number
+func (p *arithParser) variable(name string) int64 {
+	n, _ := arithNumber(strings.TrimSpace(p.sh.getVar(name)))
+	return n
+}
+
+func (p *arithParser) set(name string, n int64) {
+	if p.skip == 0 {
+		p.sh.setVar(name, strconv.FormatInt(n, 10))
+	}
+}
+
+func (p *arithParser) binary(op string, a, b int64) int64 {
+	truth := func(v bool) int64 {
+		if v {
+			return 1
+		}
+		return 0
+	}
+	switch op {
+	case "||":
+		return truth(a != 0 || b != 0)
+	case "&&":
+		return truth(a != 0 && b != 0)
+	case "|":
+		return a | b
+	case "^":
+		return a ^ b
+	case "&":
+		return a & b
+	case "==":
+		return truth(a == b)
+	case "!=":
+		return truth(a != b)
+	case "<":
+		return truth(a < b)
+	case "<=":
+		return truth(a <= b)
+	case ">":
+		return truth(a > b)
+	case ">=":
+		return truth(a >= b)
+	case "<<":
+		return a << uint64(b&63)
+	case ">>":
+		return a >> uint64(b&63)
+	case "+":
+		return a + b
+	case "-":
+		return a - b
+	case "*":
+		return a * b
+	}
+	// Division and remainder
+	if b == 0 {
+		if p.skip == 0 {
+			p.fail("division by 0")
+		}
+		return 0
+	}
+	if op == "/" {
+		return a / b
+	}
+	return a % b
+}
+
+// arithNumber parses the integer constant, decimal, octal with leading 0,
+// hex with 0x or base#digits. Empty is 0
+func arithNumber(s string) (int64, bool) {
+	base := 10
+	switch {
+	case s == "":
+		return 0, true
+	case strings.Contains(s, "#"):
+		i := strings.Index(s, "#")
+		b, err := strconv.Atoi(s[:i])
+		if err != nil || b < 2 || b > 36 {
+			return 0, false
+		}
+		base, s = b, s[i+1:]
+	case strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X"):
+		base, s = 16, s[2:]
+	case len(s) > 1 && s[0] == '0':
+		base, s = 8, s[1:]
+	}
+	n, err := strconv.ParseUint(strings.ToLower(s), base, 64)
+	return int64(n), err == nil
+}
+
+func isLetter(c byte) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }
+
+func isAlnum(c byte) bool { return isLetter(c) || c >= '0' && c <= '9' }
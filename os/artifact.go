@@ -0,0 +1,43 @@
+package os
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+var artifactLock sync.Mutex
+
+// SaveArtifact keeps a copy of the file brought into the honeypot, e.g. by
+// wget, in the directory set in server.artifactDir on host. Files are named
+// by their SHA-256 so the same payload is stored once. It returns the
+// checksum in hex
+func SaveArtifact(sys Sys, data []byte, source string) (string, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	sys.Log().WithFields(log.Fields{
+		"sha256": hash,
+		"source": source,
+		"size":   len(data),
+	}).Infof("Captured file from %v", source)
+	dir := viper.GetString("server.artifactDir")
+	if dir == "" {
+		return hash, nil
+	}
+	artifactLock.Lock()
+	defer artifactLock.Unlock()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return hash, err
+	}
+	p := filepath.Join(dir, hash)
+	if _, err := os.Stat(p); err == nil {
+		return hash, nil
+	}
+	return hash, ioutil.WriteFile(p, data, 0600)
+}
@@ -0,0 +1,499 @@
+package os
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// builtinFunc is a command implemented inside the shell, usually because it
+// changes the state of the shell itself
+type builtinFunc func(sh *Shell, args []string, proc *process) int
+
+var builtins map[string]builtinFunc
+
+func init() {
+	builtins = map[string]builtinFunc{
+		".":       builtinSource,
+		":":       builtinTrue,
+		"alias":   builtinAlias,
+		"bg":      builtinBg,
+		"cd":      builtinCd,
+		"command": builtinCommand,
+		"eval":    builtinEval,
+		"exit":    builtinExit,
+		"export":  builtinExport,
+		"false":   builtinFalse,
+		"fg":      builtinFg,
+		"history": builtinHistory,
+		"jobs":    builtinJobs,
+		"kill":    builtinKill,
+		"logout":  builtinExit,
+		"pwd":     builtinPwd,
+		"read":    builtinRead,
+		"set":     builtinSet,
+		"shift":   builtinShift,
+		"source":  builtinSource,
+		"true":    builtinTrue,
+		"type":    builtinType,
+		"umask":   builtinUmask,
+		"unalias": builtinUnalias,
+		"unset":   builtinUnset,
+		"wait":    builtinWait,
+	}
+}
+
+func builtinCd(sh *Shell, args []string, proc *process) int {
+	dir := sh.getVar("HOME")
+	if len(args) > 1 {
+		dir = args[1]
+	}
+	if dir == "-" {
+		if dir = sh.getVar("OLDPWD"); dir == "" {
+			sh.errorf(proc, "cd: OLDPWD not set")
+			return 1
+		}
+		fmt.Fprintln(proc.Out(), dir)
+	}
+	if dir == "" {
+		return 0
+	}
+	oldPwd := sh.sys.Getcwd()
+	if cdErr := sh.sys.Chdir(dir); cdErr != nil {
+		p := dir
+		if !strings.HasPrefix(p, "/") {
+			p = oldPwd + "/" + p
+		}
+		if fi, err := sh.sys.FSys().Stat(p); err == nil && !fi.IsDir() {
+			sh.errorf(proc, "cd: %v: Not a directory", dir)
+		} else if os.IsPermission(cdErr) {
+			sh.errorf(proc, "cd: %v: Permission denied", dir)
+		} else {
+			sh.errorf(proc, "cd: %v: No such file or directory", dir)
+		}
+		return 1
+	}
+	sh.setVar("OLDPWD", oldPwd)
+	return 0
+}
+
+func builtinExit(sh *Shell, args []string, proc *process) int {
+	// Without argument exit with status of the last command
+	status := sh.status
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			sh.errorf(proc, "%v: %v: numeric argument required", args[0], args[1])
+			n = 2
+		}
+		status = n & 0xff
+	}
+	sh.exited, sh.exitStatus = true, status
+	// Only the login shell logs the user out, not subshells or scripts
+	if !sh.interactive {
+		return status
+	}
+	if sh.parent != nil {
+		// Shell started by sudo or su returns to the one starting it
+		if strings.HasPrefix(sh.name, "-") {
+			fmt.Fprintln(proc.Err(), "logout")
+		} else {
+			fmt.Fprintln(proc.Err(), "exit")
+		}
+		return status
+	}
+	sh.log.Infof("User logged out")
+	sh.terminal.Write([]byte("logout\n"))
+	sh.terminal.SetPrompt("")
+	sh.termSignal <- status
+	return status
+}
+
+func builtinExport(sh *Shell, args []string, proc *process) int {
+	unexport := false
+	names := args[1:]
+	for len(names) > 0 && strings.HasPrefix(names[0], "-") {
+		switch names[0] {
+		case "-n":
+			unexport = true
+		case "-p", "-f", "--":
+		default:
+			sh.errorf(proc, "export: %v: invalid option", names[0])
+			fmt.Fprintln(proc.Err(), "export: usage: export [-fn] [name[=value] ...] or export -p")
+			return 2
+		}
+		names = names[1:]
+	}
+	if len(names) == 0 {
+		var exported []string
+		for k := range sh.sys.exports {
+			exported = append(exported, k)
+		}
+		sort.Strings(exported)
+		for _, k := range exported {
+			if v, exists := sh.sys.envVars[k]; exists {
+				fmt.Fprintf(proc.Out(), "declare -x %v=\"%v\"\n", k, dquoteEscaper.Replace(v))
+			} else {
+				fmt.Fprintf(proc.Out(), "declare -x %v\n", k)
+			}
+		}
+		return 0
+	}
+	status := 0
+	for _, arg := range names {
+		kv := strings.SplitN(arg, "=", 2)
+		if !isName(kv[0]) {
+			sh.errorf(proc, "export: `%v': not a valid identifier", arg)
+			status = 1
+			continue
+		}
+		if len(kv) > 1 {
+			sh.setVar(kv[0], kv[1])
+		}
+		if unexport {
+			delete(sh.sys.exports, kv[0])
+		} else {
+			sh.sys.exports[kv[0]] = true
+		}
+	}
+	return status
+}
+
+// dquoteEscaper escapes the value to be printed in double quotes
+var dquoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`")
+
+func builtinUnset(sh *Shell, args []string, proc *process) int {
+	status := 0
+	for _, name := range args[1:] {
+		if name == "-v" || name == "-f" {
+			continue
+		}
+		if !isName(name) {
+			sh.errorf(proc, "unset: `%v': not a valid identifier", name)
+			status = 1
+			continue
+		}
+		delete(sh.sys.envVars, name)
+		delete(sh.sys.exports, name)
+	}
+	return status
+}
+
+func builtinTrue(sh *Shell, args []string, proc *process) int {
+	return 0
+}
+
+func builtinFalse(sh *Shell, args []string, proc *process) int {
+	return 1
+}
+
+func builtinPwd(sh *Shell, args []string, proc *process) int {
+	fmt.Fprintln(proc.Out(), sh.sys.Getcwd())
+	return 0
+}
+
+func builtinUmask(sh *Shell, args []string, proc *process) int {
+	symbolic := len(args) > 1 && args[1] == "-S"
+	if symbolic {
+		args = args[1:]
+	}
+	if len(args) < 2 {
+		mask := sh.sys.umask
+		if !symbolic {
+			fmt.Fprintf(proc.Out(), "%04o\n", mask)
+			return 0
+		}
+		var perms []string
+		for i, who := range []string{"u", "g", "o"} {
+			perm := who + "="
+			bits := ^mask >> uint(6-3*i)
+			for j, c := range "rwx" {
+				if bits&(4>>uint(j)) != 0 {
+					perm += string(c)
+				}
+			}
+			perms = append(perms, perm)
+		}
+		fmt.Fprintln(proc.Out(), strings.Join(perms, ","))
+		return 0
+	}
+	n, err := strconv.ParseUint(args[1], 8, 32)
+	if err != nil || n > 0777 {
+		sh.errorf(proc, "umask: %v: octal number out of range", args[1])
+		return 1
+	}
+	sh.sys.umask = os.FileMode(n)
+	return 0
+}
+
+// lookup finds what the command name refers to, in the same order bash
+// resolves it. kind is one of alias, builtin or file, or empty if not found
+func (sh *Shell) lookup(name string) (kind, value string) {
+	if alias, exists := sh.sys.aliases[name]; exists {
+		return "alias", alias
+	}
+	if _, exists := builtins[name]; exists {
+		return "builtin", name
+	}
+	if strings.Contains(name, "/") {
+		if _, err := sh.sys.FSys().Stat(name); err == nil {
+			return "file", name
+		}
+		return "", ""
+	}
+	if found := executables(sh.sys.FSys(), sh.getVar("PATH"), name); len(found) > 0 {
+		return "file", found[0]
+	}
+	if cmd, exists := funcMap[name]; exists {
+		return "file", cmd.Where()
+	}
+	if _, exists := fakeFuncList[name]; exists {
+		return "file", "/usr/bin/" + name
+	}
+	return "", ""
+}
+
+func builtinType(sh *Shell, args []string, proc *process) int {
+	mode, all := "", false
+	names := args[1:]
+	for len(names) > 0 && strings.HasPrefix(names[0], "-") {
+		for _, c := range names[0][1:] {
+			switch c {
+			case 'a':
+				all = true
+			case 't', 'p', 'P':
+				mode = "-" + string(c)
+			case 'f':
+			default:
+				sh.errorf(proc, "type: -%c: invalid option\ntype: usage: type [-afptP] name [name ...]", c)
+				return 2
+			}
+		}
+		names = names[1:]
+	}
+	status := 0
+	for _, name := range names {
+		if all {
+			if !sh.typeAll(name, mode, proc) {
+				if mode == "" {
+					sh.errorf(proc, "type: %v: not found", name)
+				}
+				status = 1
+			}
+			continue
+		}
+		kind, value := sh.lookup(name)
+		switch {
+		case kind == "":
+			if mode == "" {
+				sh.errorf(proc, "type: %v: not found", name)
+			}
+			status = 1
+		case mode == "-t":
+			fmt.Fprintln(proc.Out(), kind)
+		case mode == "-p" || mode == "-P":
+			if kind == "file" {
+				fmt.Fprintln(proc.Out(), value)
+			}
+		case kind == "alias":
+			fmt.Fprintf(proc.Out(), "%v is aliased to `%v'\n", name, value)
+		case kind == "builtin":
+			fmt.Fprintf(proc.Out(), "%v is a shell builtin\n", name)
+		default:
+			fmt.Fprintf(proc.Out(), "%v is %v\n", name, value)
+		}
+	}
+	return status
+}
+
+// typeAll prints every definition of the name for type -a, the alias and
+// builtin before all the executables in $PATH. It is false if none is found
+func (sh *Shell) typeAll(name, mode string, proc *process) bool {
+	found := false
+	if alias, exists := sh.sys.aliases[name]; exists && mode != "-p" && mode != "-P" {
+		if mode == "-t" {
+			fmt.Fprintln(proc.Out(), "alias")
+		} else {
+			fmt.Fprintf(proc.Out(), "%v is aliased to `%v'\n", name, alias)
+		}
+		found = true
+	}
+	if _, exists := builtins[name]; exists && mode != "-p" && mode != "-P" {
+		if mode == "-t" {
+			fmt.Fprintln(proc.Out(), "builtin")
+		} else {
+			fmt.Fprintf(proc.Out(), "%v is a shell builtin\n", name)
+		}
+		found = true
+	}
+	for _, p := range executables(sh.sys.FSys(), sh.getVar("PATH"), name) {
+		switch mode {
+		case "-t":
+			fmt.Fprintln(proc.Out(), "file")
+		case "-p", "-P":
+			fmt.Fprintln(proc.Out(), p)
+		default:
+			fmt.Fprintf(proc.Out(), "%v is %v\n", name, p)
+		}
+		found = true
+	}
+	return found
+}
+
+func builtinCommand(sh *Shell, args []string, proc *process) int {
+	if len(args) < 2 {
+		return 0
+	}
+	switch args[1] {
+	case "-v":
+		status := 0
+		for _, name := range args[2:] {
+			switch kind, value := sh.lookup(name); kind {
+			case "":
+				status = 1
+			case "alias":
+				fmt.Fprintf(proc.Out(), "alias %v='%v'\n", name, strings.Replace(value, "'", `'\''`, -1))
+			default:
+				fmt.Fprintln(proc.Out(), value)
+			}
+		}
+		return status
+	case "-V":
+		return builtinType(sh, append([]string{"type"}, args[2:]...), proc)
+	case "-p":
+		args = args[1:]
+	}
+	// Run the command skipping aliases
+	return sh.run(args[1:], proc)
+}
+
+func builtinEval(sh *Shell, args []string, proc *process) int {
+	return sh.execLine(strings.Join(args[1:], " "), proc)
+}
+
+func builtinSet(sh *Shell, args []string, proc *process) int {
+	if len(args) == 1 {
+		var names []string
+		for k := range sh.sys.envVars {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		for _, k := range names {
+			v := sh.sys.envVars[k]
+			if strings.ContainsAny(v, " \t\n'\"$`\\|&;()<>*?[]") {
+				v = "'" + strings.Replace(v, "'", `'\''`, -1) + "'"
+			}
+			fmt.Fprintf(proc.Out(), "%v=%v\n", k, v)
+		}
+		return 0
+	}
+	// Options like -e and -x are accepted but have no effect
+	i := 1
+	for ; i < len(args) && (strings.HasPrefix(args[i], "-") || strings.HasPrefix(args[i], "+")); i++ {
+		if args[i] == "--" || args[i] == "-" {
+			i++
+			sh.args = append([]string{}, args[i:]...)
+			return 0
+		}
+		if args[i] == "-o" || args[i] == "+o" {
+			i++
+		}
+	}
+	if i < len(args) {
+		sh.args = append([]string{}, args[i:]...)
+	}
+	return 0
+}
+
+func builtinShift(sh *Shell, args []string, proc *process) int {
+	n := 1
+	if len(args) > 1 {
+		var err error
+		if n, err = strconv.Atoi(args[1]); err != nil || n < 0 {
+			sh.errorf(proc, "shift: %v: numeric argument required", args[1])
+			return 1
+		}
+	}
+	if n > len(sh.args) {
+		return 1
+	}
+	sh.args = sh.args[n:]
+	return 0
+}
+
+func builtinRead(sh *Shell, args []string, proc *process) int {
+	raw, silent := false, false
+	var names []string
+	for i := 1; i < len(args); i++ {
+		switch args[i] {
+		case "-r":
+			raw = true
+		case "-s":
+			silent = true
+		case "-p":
+			if i+1 < len(args) {
+				i++
+				fmt.Fprint(proc.Err(), args[i])
+			}
+		case "-t", "-n", "-N", "-d", "-u":
+			// Options with argument that we ignore
+			i++
+		default:
+			names = append(names, args[i])
+		}
+	}
+	if len(names) == 0 {
+		names = []string{"REPLY"}
+	}
+	for _, name := range names {
+		if !isName(name) {
+			sh.errorf(proc, "read: `%v': not a valid identifier", name)
+			return 1
+		}
+	}
+	if t := proc.Termios(); silent && t != nil && t.Flag("echo") {
+		// Like bash, turn off echo while reading password
+		t.SetFlag("echo", false)
+		defer t.SetFlag("echo", true)
+	}
+	// Read byte by byte so that input after the line is left for others
+	var line bytes.Buffer
+	b := make([]byte, 1)
+	var err error
+	for {
+		var n int
+		if n, err = proc.In().Read(b); n > 0 {
+			if b[0] == '\n' {
+				break
+			}
+			line.WriteByte(b[0])
+		}
+		if err != nil {
+			break
+		}
+	}
+	text := strings.TrimSuffix(line.String(), "\r")
+	if !raw {
+		text = strings.NewReplacer("\\\n", "", `\`, "").Replace(text)
+	}
+	fields := strings.Fields(text)
+	for i, name := range names {
+		switch {
+		case i >= len(fields):
+			sh.setVar(name, "")
+		case i == len(names)-1:
+			// Last variable gets rest of the line
+			sh.setVar(name, strings.Join(fields[i:], " "))
+		default:
+			sh.setVar(name, fields[i])
+		}
+	}
+	if err == io.EOF && line.Len() == 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,68 @@
+package os
+
+import (
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// clock is the virtual clock of the session, which is the real time moved
+// by persona.clockOffset and whatever date -s sets
+type clock struct {
+	mu     sync.Mutex
+	offset time.Duration
+}
+
+// Location returns the timezone of the command: TZ if set, otherwise
+// persona.timezone of the machine. Unknown zones are UTC by the name given,
+// like glibc does
+func Location(sys Sys) *time.Location {
+	name := Getenv(sys, "TZ")
+	if name == "" {
+		name = viper.GetString("persona.timezone")
+	}
+	if name == "" || name == "UTC" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.FixedZone(name, 0)
+	}
+	return loc
+}
+
+// Now returns the time of the virtual clock in the timezone of the command
+func Now(sys Sys) time.Time {
+	t := time.Now().Add(viper.GetDuration("persona.clockOffset"))
+	var c *clock
+	switch s := sys.(type) {
+	case *process:
+		c = s.clock
+	case *System:
+		c = s.clock
+	}
+	if c != nil {
+		c.mu.Lock()
+		t = t.Add(c.offset)
+		c.mu.Unlock()
+	}
+	return t.In(Location(sys))
+}
+
+// SetClock sets the virtual clock of the session to t, as settimeofday(2)
+// does. Only root can set it
+func SetClock(sys Sys, t time.Time) error {
+	proc, ok := sys.(*process)
+	if !ok || proc.clock == nil || proc.userId != 0 {
+		return syscall.EPERM
+	}
+	old := Now(sys)
+	proc.clock.mu.Lock()
+	proc.clock.offset += t.Sub(old)
+	proc.clock.mu.Unlock()
+	proc.Log().WithField("old", old.Format(time.RFC3339)).WithField("new", t.Format(time.RFC3339)).
+		Warnf("User set the clock to %v, possibly to confuse timestamps", t.Format(time.RFC3339))
+	return nil
+}
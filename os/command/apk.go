@@ -0,0 +1,274 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/afero"
+	"github.com/spf13/pflag"
+)
+
+type apk struct{}
+
+const (
+	apkMirror = "https://dl-cdn.alpinelinux.org/alpine/v3.15"
+	// apkWorld is the packages explicitly installed by user
+	apkWorld = "/etc/apk/world"
+	// apkIndexCache exists after the indexes are fetched without --no-cache
+	apkIndexCache = "/var/cache/apk/APKINDEX.5022a8a2.tar.gz"
+)
+
+func init() {
+	honeyos.RegisterCommand("apk", apk{})
+}
+
+func (apk) GetHelp() string {
+	return ""
+}
+
+func (apk) Where() string {
+	return "/sbin/apk"
+}
+
+func (a apk) Exec(args []string, sys honeyos.Sys) int {
+	if pkgFamily() != "apk" {
+		return honeyos.CommandNotFound(sys, append([]string{"apk"}, args...))
+	}
+	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	noCache := flag.Bool("no-cache", false, "Do not use any local cache path")
+	update := flag.BoolP("update-cache", "U", false, "Update the repository cache")
+	quiet := flag.BoolP("quiet", "q", false, "Print less information")
+	virtual := flag.StringP("virtual", "t", "", "Create virtual package with given dependencies")
+	flag.StringP("repository", "X", "", "Use packages from REPO")
+	flag.Bool("allow-untrusted", false, "Install packages with untrusted signature or no signature")
+	flag.Bool("no-progress", false, "Disable progress bar even for TTYs")
+	if err := flag.Parse(args); err != nil {
+		fmt.Fprintf(sys.Err(), "apk: %v\n", err)
+		return 1
+	}
+	if flag.NArg() == 0 {
+		fmt.Fprintln(sys.Out(), "apk-tools 2.12.7, compiled for x86_64.\n\nusage: apk [<OPTIONS>...] COMMAND [<ARGUMENTS>...]\n\nPackage installation and removal:\n  add        Add packages to WORLD and commit changes\n  del        Remove packages from WORLD and commit changes\n\nSystem maintenance:\n  fix        Fix, reinstall or upgrade packages without modifying WORLD\n  update     Update repository indexes\n  upgrade    Install upgrades available from repositories\n\nQuerying package information:\n  info       Give detailed information about packages or repositories\n  search     Search for packages by name or description")
+		return 1
+	}
+	out := sys.Out()
+	if *quiet {
+		out = ioutil.Discard
+	}
+	sub, names := flag.Arg(0), flag.Args()[1:]
+	switch sub {
+	case "add", "del", "update", "upgrade", "fix":
+		if !isRoot(sys) {
+			fmt.Fprintln(sys.Err(), "ERROR: Unable to lock database: Permission denied\nERROR: Failed to open apk database: Permission denied")
+			return 99
+		}
+	}
+	db := loadPkgDB(sys, "apk")
+	switch sub {
+	case "add":
+		logPkgRequest(sys, "apk", "add", names)
+		if *update || *noCache || !a.cached(sys) {
+			if !a.fetch(out, *noCache, sys) {
+				return 1
+			}
+		}
+		return a.add(names, *virtual, db, out, sys)
+	case "del":
+		logPkgRequest(sys, "apk", "del", names)
+		return a.del(names, db, out, sys)
+	case "update":
+		logPkgRequest(sys, "apk", "update", nil)
+		if !a.fetch(out, *noCache, sys) {
+			return 1
+		}
+		fmt.Fprintf(out, "v3.15.4-116-g9a9e6e0b0d [%v/main]\nv3.15.4-119-g1e0ad4f1f4 [%v/community]\nOK: 15850 distinct packages available\n", apkMirror, apkMirror)
+		return 0
+	case "upgrade", "fix":
+		if *update || *noCache || !a.cached(sys) {
+			if !a.fetch(out, *noCache, sys) {
+				return 1
+			}
+		}
+		a.ok(db, out)
+		return 0
+	case "info":
+		return a.info(names, db, sys)
+	case "search":
+		for _, p := range pkgCatalog {
+			if p.family != "" && p.family != "apk" {
+				continue
+			}
+			for _, pattern := range names {
+				if strings.Contains(p.name, pattern) {
+					fmt.Fprintf(sys.Out(), "%v-%v\n", p.name, pkgVersion(p, "apk"))
+					break
+				}
+			}
+		}
+		return 0
+	}
+	fmt.Fprintf(sys.Err(), "apk: applet '%v' not found\n", sub)
+	return 1
+}
+
+func (apk) cached(sys honeyos.Sys) bool {
+	exists, _ := afero.Exists(sys.FSys(), apkIndexCache)
+	return exists
+}
+
+// fetch prints the indexes downloaded, and caches them unless noCache
+func (apk) fetch(out io.Writer, noCache bool, sys honeyos.Sys) bool {
+	for _, repo := range []string{"main", "community"} {
+		if !pkgSleep(sys, 300*time.Millisecond) {
+			return false
+		}
+		fmt.Fprintf(out, "fetch %v/%v/x86_64/APKINDEX.tar.gz\n", apkMirror, repo)
+	}
+	if !noCache {
+		fs := sys.FSys()
+		fs.MkdirAll(path.Dir(apkIndexCache), 0755)
+		afero.WriteFile(fs, apkIndexCache, nil, 0644)
+	}
+	return true
+}
+
+// ok prints the summary of installed packages apk ends with
+func (apk) ok(db *pkgDB, out io.Writer) {
+	size := 0
+	for _, p := range db.pkgs {
+		size += p.size
+	}
+	fmt.Fprintf(out, "OK: %v MiB in %v packages\n", size/1024+1, len(db.pkgs))
+}
+
+func (a apk) add(names []string, virtual string, db *pkgDB, out io.Writer, sys honeyos.Sys) int {
+	if len(names) == 0 {
+		a.ok(db, out)
+		return 0
+	}
+	pkgs, missing := resolvePkgs("apk", names, db)
+	if len(missing) > 0 {
+		fmt.Fprintln(sys.Err(), "ERROR: unable to select packages:")
+		for _, name := range missing {
+			required := "world[" + name + "]"
+			if virtual != "" {
+				required = virtual + "-20220101.000000[" + name + "]"
+			}
+			fmt.Fprintf(sys.Err(), "  %v (no such package):\n    required by: %v\n", name, required)
+		}
+		return len(missing)
+	}
+	total := len(pkgs)
+	if virtual != "" {
+		total++
+	}
+	for i, p := range pkgs {
+		if !pkgSleep(sys, time.Duration(50+p.size/30)*time.Millisecond) {
+			return 1
+		}
+		db.install(sys, p)
+		fmt.Fprintf(out, "(%v/%v) Installing %v (%v)\n", i+1, total, p.name, pkgVersion(p, "apk"))
+	}
+	world := names
+	if virtual != "" {
+		fmt.Fprintf(out, "(%v/%v) Installing %v (20220101.000000)\n", total, total, virtual)
+		db.pkgs[virtual] = pkgInfo{name: virtual, version: "20220101.000000", deps: names, desc: "virtual meta package"}
+		world = []string{virtual}
+	}
+	if len(pkgs) > 0 {
+		fmt.Fprintln(out, "Executing busybox-1.34.1-r3.trigger")
+	}
+	db.save(sys)
+	a.saveWorld(sys, world, nil)
+	a.ok(db, out)
+	return 0
+}
+
+func (a apk) del(names []string, db *pkgDB, out io.Writer, sys honeyos.Sys) int {
+	var removed []pkgInfo
+	for _, name := range names {
+		p, ok := db.pkgs[name]
+		if !ok {
+			fmt.Fprintf(out, "WARNING: Ignoring %v: No such package\n", name)
+			continue
+		}
+		removed = append(removed, p)
+		// Removing a virtual package removes what it was created with
+		if p.desc == "virtual meta package" {
+			for _, d := range p.deps {
+				if dp, ok := db.pkgs[d]; ok {
+					removed = append(removed, dp)
+				}
+			}
+		}
+	}
+	for i, p := range removed {
+		if !pkgSleep(sys, 80*time.Millisecond) {
+			return 1
+		}
+		db.remove(sys, p.name)
+		fmt.Fprintf(out, "(%v/%v) Purging %v (%v)\n", i+1, len(removed), p.name, p.version)
+	}
+	if len(removed) > 0 {
+		fmt.Fprintln(out, "Executing busybox-1.34.1-r3.trigger")
+		db.save(sys)
+		a.saveWorld(sys, nil, names)
+	}
+	a.ok(db, out)
+	return 0
+}
+
+// saveWorld adds and removes packages in the world file
+func (apk) saveWorld(sys honeyos.Sys, add, del []string) {
+	fs := sys.FSys()
+	world := map[string]bool{}
+	if b, err := afero.ReadFile(fs, apkWorld); err == nil {
+		for _, name := range strings.Fields(string(b)) {
+			world[name] = true
+		}
+	} else {
+		for _, name := range []string{"alpine-baselayout", "alpine-keys", "apk-tools", "busybox", "libc-utils"} {
+			world[name] = true
+		}
+	}
+	for _, name := range add {
+		world[name] = true
+	}
+	for _, name := range del {
+		delete(world, name)
+	}
+	var names []string
+	for name := range world {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fs.MkdirAll(path.Dir(apkWorld), 0755)
+	afero.WriteFile(fs, apkWorld, []byte(strings.Join(names, "\n")+"\n"), 0644)
+}
+
+func (apk) info(names []string, db *pkgDB, sys honeyos.Sys) int {
+	if len(names) == 0 {
+		for _, name := range db.names() {
+			fmt.Fprintln(sys.Out(), name)
+		}
+		return 0
+	}
+	for _, name := range names {
+		p, ok := db.pkgs[name]
+		if !ok {
+			if p, ok = lookupPkg("apk", name); !ok {
+				continue
+			}
+			p.version = pkgVersion(p, "apk")
+		}
+		id := p.name + "-" + p.version
+		fmt.Fprintf(sys.Out(), "%v description:\n%v\n\n%v installed size:\n%v KiB\n\n", id, p.desc, id, p.size)
+	}
+	return 0
+}
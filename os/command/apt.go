@@ -0,0 +1,493 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/pflag"
+)
+
+// apt is apt and apt-get, which differ in a few messages and subcommands
+type apt struct {
+	name string
+}
+
+type dpkg struct{}
+
+func init() {
+	honeyos.RegisterCommand("apt", apt{"apt"})
+	honeyos.RegisterCommand("apt-get", apt{"apt-get"})
+	honeyos.RegisterCommand("dpkg", dpkg{})
+}
+
+func (a apt) GetHelp() string {
+	return ""
+}
+
+func (a apt) Where() string {
+	return "/usr/bin/" + a.name
+}
+
+// aptSource is the mirror and release of the distribution, for the lines
+// printed when fetching indexes and packages
+func aptSource() (mirror, security, release string) {
+	if honeyos.Distro() == "debian" {
+		return "http://deb.debian.org/debian", "http://security.debian.org/debian-security", "stretch"
+	}
+	return "http://archive.ubuntu.com/ubuntu", "http://security.ubuntu.com/ubuntu", "xenial"
+}
+
+func (a apt) Exec(args []string, sys honeyos.Sys) int {
+	if pkgFamily() != "deb" {
+		return honeyos.CommandNotFound(sys, append([]string{a.name}, args...))
+	}
+	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	yes := flag.BoolP("yes", "y", false, "Assume Yes to all queries and do not prompt")
+	flag.Bool("assume-yes", false, "Assume Yes to all queries and do not prompt")
+	flag.Bool("force-yes", false, "")
+	flag.Bool("allow-unauthenticated", false, "")
+	flag.Bool("no-install-recommends", false, "")
+	flag.BoolP("fix-broken", "f", false, "")
+	quiet := flag.CountP("quiet", "q", "")
+	purge := flag.Bool("purge", false, "")
+	if err := flag.Parse(args); err != nil {
+		opt := strings.TrimPrefix(err.Error(), "unknown flag: ")
+		opt = strings.TrimPrefix(opt, "unknown shorthand flag: ")
+		fmt.Fprintf(sys.Err(), "E: Command line option %v is not understood in combination with the other options\n", opt)
+		return 100
+	}
+	if v, _ := flag.GetBool("assume-yes"); v {
+		*yes = true
+	}
+	if flag.NArg() == 0 {
+		fmt.Fprintf(sys.Out(), "%v 1.2.35 (amd64)\nUsage: %v [options] command\n\n", a.name, a.name)
+		return 1
+	}
+	if a.name == "apt" && !honeyos.IsTerminal(sys.Out()) {
+		fmt.Fprint(sys.Err(), "\nWARNING: apt does not have a stable CLI interface. Use with caution in scripts.\n\n")
+	}
+	out := sys.Out()
+	if *quiet > 1 {
+		out = ioutil.Discard
+	}
+	sub, names := flag.Arg(0), flag.Args()[1:]
+	switch sub {
+	case "update":
+		return a.update(out, sys)
+	case "install", "reinstall":
+		return a.install(names, *yes, out, sys)
+	case "remove", "purge", "autoremove":
+		return a.remove(names, *yes, sub == "purge" || *purge, out, sys)
+	case "upgrade", "dist-upgrade", "full-upgrade":
+		if !isRoot(sys) {
+			return aptLockError(sys)
+		}
+		fmt.Fprintln(out, "Reading package lists... Done\nBuilding dependency tree       \nReading state information... Done\nCalculating upgrade... Done\n0 upgraded, 0 newly installed, 0 to remove and 0 not upgraded.")
+		return 0
+	case "clean", "autoclean":
+		if !isRoot(sys) {
+			return aptLockError(sys)
+		}
+		return 0
+	case "list", "search":
+		if a.name == "apt" {
+			return a.list(sub, names, out, sys)
+		}
+	}
+	fmt.Fprintf(sys.Err(), "E: Invalid operation %v\n", sub)
+	return 100
+}
+
+func aptLockError(sys honeyos.Sys) int {
+	fmt.Fprintln(sys.Err(), "E: Could not open lock file /var/lib/dpkg/lock - open (13: Permission denied)\nE: Unable to lock the administration directory (/var/lib/dpkg/), are you root?")
+	return 100
+}
+
+func (a apt) update(out io.Writer, sys honeyos.Sys) int {
+	if !isRoot(sys) {
+		fmt.Fprintln(sys.Err(), "E: Could not open lock file /var/lib/apt/lists/lock - open (13: Permission denied)\nE: Unable to lock directory /var/lib/apt/lists/")
+		return 100
+	}
+	logPkgRequest(sys, a.name, "update", nil)
+	mirror, security, release := aptSource()
+	indexes := []struct {
+		source, index string
+		size          int
+	}{
+		{mirror, release + " InRelease", 0},
+		{mirror, release + "-updates InRelease", 109},
+		{security, release + "-security InRelease", 109},
+		{mirror, release + "-backports InRelease", 107},
+		{mirror, release + "-updates/main amd64 Packages", 2049},
+		{mirror, release + "-updates/universe amd64 Packages", 1534},
+		{security, release + "-security/main amd64 Packages", 1648},
+		{security, release + "-security/universe amd64 Packages", 984},
+	}
+	start := time.Now()
+	total := 0
+	for i, idx := range indexes {
+		if !pkgSleep(sys, time.Duration(100+idx.size/5)*time.Millisecond) {
+			return 130
+		}
+		if idx.size == 0 {
+			fmt.Fprintf(out, "Hit:%v %v %v\n", i+1, idx.source, idx.index)
+			continue
+		}
+		fmt.Fprintf(out, "Get:%v %v %v [%vB]\n", i+1, idx.source, idx.index, aptSize(float64(idx.size*1000)))
+		total += idx.size * 1000
+	}
+	elapsed := time.Since(start)
+	fmt.Fprintf(out, "Fetched %vB in %.0fs (%vB/s)\n", aptSize(float64(total)), elapsed.Seconds(), aptSize(float64(total)/elapsed.Seconds()))
+	fmt.Fprintln(out, "Reading package lists... Done")
+	if a.name == "apt" {
+		fmt.Fprintln(out, "Building dependency tree       \nReading state information... Done\nAll packages are up to date.")
+	}
+	return 0
+}
+
+func (a apt) install(names []string, yes bool, out io.Writer, sys honeyos.Sys) int {
+	if !isRoot(sys) {
+		return aptLockError(sys)
+	}
+	logPkgRequest(sys, a.name, "install", names)
+	fmt.Fprintln(out, "Reading package lists... Done\nBuilding dependency tree       \nReading state information... Done")
+	db := loadPkgDB(sys, "deb")
+	pkgs, missing := resolvePkgs("deb", names, db)
+	if len(missing) > 0 {
+		for _, name := range missing {
+			fmt.Fprintf(sys.Err(), "E: Unable to locate package %v\n", name)
+		}
+		return 100
+	}
+	requested := map[string]bool{}
+	for _, name := range names {
+		requested[name] = true
+		if db.installed(name) {
+			fmt.Fprintf(out, "%v is already the newest version (%v).\n", name, db.pkgs[name].version)
+		}
+	}
+	var extra, all []string
+	size, archive := 0, 0
+	for _, p := range pkgs {
+		if !requested[p.name] {
+			extra = append(extra, p.name)
+		}
+		all = append(all, p.name)
+		size += p.size
+		archive += p.size / 3
+	}
+	if len(extra) > 0 {
+		fmt.Fprintf(out, "The following additional packages will be installed:\n%v", aptList(extra, sys))
+	}
+	if len(all) > 0 {
+		fmt.Fprintf(out, "The following NEW packages will be installed:\n%v", aptList(all, sys))
+	}
+	fmt.Fprintf(out, "0 upgraded, %v newly installed, 0 to remove and 0 not upgraded.\n", len(pkgs))
+	if len(pkgs) == 0 {
+		return 0
+	}
+	fmt.Fprintf(out, "Need to get %vB of archives.\n", aptSize(float64(archive*1000)))
+	fmt.Fprintf(out, "After this operation, %vB of additional disk space will be used.\n", aptSize(float64(size*1000)))
+	// apt-get only asks when more than the requested packages are changed
+	if len(extra) > 0 && !yes {
+		if !pkgConfirm(sys, "Do you want to continue? [Y/n] ", true) {
+			fmt.Fprintln(out, "Abort.")
+			return 1
+		}
+	}
+	mirror, _, release := aptSource()
+	start := time.Now()
+	for i, p := range pkgs {
+		if !pkgSleep(sys, time.Duration(50+p.size/30)*time.Millisecond) {
+			return 130
+		}
+		fmt.Fprintf(out, "Get:%v %v %v/main amd64 %v amd64 %v [%vB]\n", i+1, mirror, release, p.name, p.version, aptSize(float64(p.size/3*1000)))
+	}
+	elapsed := time.Since(start)
+	fmt.Fprintf(out, "Fetched %vB in %.0fs (%vB/s)\n", aptSize(float64(archive*1000)), elapsed.Seconds(), aptSize(float64(archive*1000)/elapsed.Seconds()))
+	files := 25000 + 37*len(db.pkgs)
+	for i, p := range pkgs {
+		fmt.Fprintf(out, "Selecting previously unselected package %v.\n", p.name)
+		if i == 0 {
+			fmt.Fprintf(out, "(Reading database ... %v files and directories currently installed.)\n", files)
+		}
+		fmt.Fprintf(out, "Preparing to unpack .../%v_%v_amd64.deb ...\nUnpacking %v (%v) ...\n", p.name, strings.Replace(p.version, ":", "%3a", 1), p.name, p.version)
+	}
+	lib := false
+	for _, p := range pkgs {
+		if !pkgSleep(sys, 80*time.Millisecond) {
+			return 130
+		}
+		db.install(sys, p)
+		lib = lib || strings.HasPrefix(p.name, "lib")
+		fmt.Fprintf(out, "Setting up %v (%v) ...\n", p.name, p.version)
+	}
+	if lib {
+		fmt.Fprintln(out, "Processing triggers for libc-bin (2.23-0ubuntu11) ...")
+	}
+	fmt.Fprintln(out, "Processing triggers for man-db (2.7.5-1) ...")
+	if err := db.save(sys); err != nil {
+		fmt.Fprintf(sys.Err(), "E: Sub-process /usr/bin/dpkg returned an error code (1)\n")
+		return 100
+	}
+	return 0
+}
+
+func (a apt) remove(names []string, yes, purge bool, out io.Writer, sys honeyos.Sys) int {
+	if !isRoot(sys) {
+		return aptLockError(sys)
+	}
+	logPkgRequest(sys, a.name, "remove", names)
+	fmt.Fprintln(out, "Reading package lists... Done\nBuilding dependency tree       \nReading state information... Done")
+	db := loadPkgDB(sys, "deb")
+	var removed, listed []string
+	size := 0
+	for _, name := range names {
+		if !db.installed(name) {
+			if _, ok := lookupPkg("deb", name); !ok {
+				fmt.Fprintf(sys.Err(), "E: Unable to locate package %v\n", name)
+				return 100
+			}
+			fmt.Fprintf(out, "Package '%v' is not installed, so not removed\n", name)
+			continue
+		}
+		removed = append(removed, name)
+		size += db.pkgs[name].size
+		if purge {
+			name += "*"
+		}
+		listed = append(listed, name)
+	}
+	if len(removed) > 0 {
+		fmt.Fprintf(out, "The following packages will be REMOVED:\n%v", aptList(listed, sys))
+	}
+	fmt.Fprintf(out, "0 upgraded, 0 newly installed, %v to remove and 0 not upgraded.\n", len(removed))
+	if len(removed) == 0 {
+		return 0
+	}
+	fmt.Fprintf(out, "After this operation, %vB disk space will be freed.\n", aptSize(float64(size*1000)))
+	if !yes && !pkgConfirm(sys, "Do you want to continue? [Y/n] ", true) {
+		fmt.Fprintln(out, "Abort.")
+		return 1
+	}
+	fmt.Fprintf(out, "(Reading database ... %v files and directories currently installed.)\n", 25000+37*len(db.pkgs))
+	for _, name := range removed {
+		if !pkgSleep(sys, 100*time.Millisecond) {
+			return 130
+		}
+		fmt.Fprintf(out, "Removing %v (%v) ...\n", name, db.pkgs[name].version)
+		if purge {
+			fmt.Fprintf(out, "Purging configuration files for %v (%v) ...\n", name, db.pkgs[name].version)
+		}
+		db.remove(sys, name)
+	}
+	fmt.Fprintln(out, "Processing triggers for man-db (2.7.5-1) ...")
+	db.save(sys)
+	return 0
+}
+
+func (a apt) list(sub string, patterns []string, out io.Writer, sys honeyos.Sys) int {
+	_, _, release := aptSource()
+	db := loadPkgDB(sys, "deb")
+	if sub == "search" {
+		if len(patterns) == 0 {
+			fmt.Fprintln(sys.Err(), "E: You must give at least one search pattern")
+			return 100
+		}
+		fmt.Fprintln(out, "Sorting... Done\nFull Text Search... Done")
+		for _, p := range pkgCatalog {
+			if p.family != "" && p.family != "deb" {
+				continue
+			}
+			for _, pattern := range patterns {
+				if strings.Contains(p.name, pattern) || strings.Contains(strings.ToLower(p.desc), strings.ToLower(pattern)) {
+					status := ""
+					if db.installed(p.name) {
+						status = " [installed]"
+					}
+					fmt.Fprintf(out, "%v/%v %v amd64%v\n  %v\n\n", p.name, release, p.version, status, p.desc)
+					break
+				}
+			}
+		}
+		return 0
+	}
+	fmt.Fprintln(out, "Listing... Done")
+	for _, name := range db.names() {
+		if len(patterns) > 0 {
+			matched := false
+			for _, pattern := range patterns {
+				if m, _ := path.Match(pattern, name); m {
+					matched = true
+				}
+			}
+			if !matched {
+				continue
+			}
+		}
+		fmt.Fprintf(out, "%v/%v,now %v amd64 [installed]\n", name, release, db.pkgs[name].version)
+	}
+	return 0
+}
+
+// aptList formats package names indented and wrapped to the terminal width
+func aptList(names []string, sys honeyos.Sys) string {
+	width := sys.Width()
+	if width <= 0 {
+		width = 80
+	}
+	var b strings.Builder
+	line := " "
+	for _, name := range names {
+		if len(line)+len(name)+1 > width-1 && line != " " {
+			b.WriteString(line + "\n")
+			line = " "
+		}
+		line += " " + name
+	}
+	b.WriteString(line + "\n")
+	return b.String()
+}
+
+// aptSize formats the size in SI units like apt does, e.g. 5,443 k and 23.4 M
+func aptSize(n float64) string {
+	units := []string{"", "k", "M", "G", "T"}
+	for i, unit := range units {
+		if n < 100 && i != 0 {
+			return fmt.Sprintf("%.1f %v", n, unit)
+		}
+		if n < 10000 || i == len(units)-1 {
+			s := fmt.Sprintf("%.0f", n)
+			if len(s) > 3 {
+				s = s[:len(s)-3] + "," + s[len(s)-3:]
+			}
+			return fmt.Sprintf("%v %v", s, unit)
+		}
+		n /= 1000
+	}
+	return ""
+}
+
+func (dpkg) GetHelp() string {
+	return ""
+}
+
+func (dpkg) Where() string {
+	return "/usr/bin/dpkg"
+}
+
+func (d dpkg) Exec(args []string, sys honeyos.Sys) int {
+	if pkgFamily() != "deb" {
+		return honeyos.CommandNotFound(sys, append([]string{"dpkg"}, args...))
+	}
+	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	list := flag.BoolP("list", "l", false, "List packages")
+	status := flag.BoolP("status", "s", false, "Display package status details")
+	files := flag.BoolP("listfiles", "L", false, "List files owned by package(s)")
+	install := flag.BoolP("install", "i", false, "Install the package")
+	if err := flag.Parse(args); err != nil {
+		fmt.Fprintf(sys.Err(), "dpkg: error: %v\n\nType dpkg --help for help about installing and deinstalling packages [*];\n", err)
+		return 2
+	}
+	db := loadPkgDB(sys, "deb")
+	switch {
+	case *list:
+		return d.list(flag.Args(), db, sys)
+	case *status || *files:
+		if flag.NArg() == 0 {
+			fmt.Fprintln(sys.Err(), "dpkg-query: error: --status needs at least one package name argument")
+			return 2
+		}
+		res := 0
+		for _, name := range flag.Args() {
+			p, ok := db.pkgs[name]
+			if !ok {
+				fmt.Fprintf(sys.Err(), "dpkg-query: package '%v' is not installed and no information is available\n", name)
+				res = 1
+				continue
+			}
+			if *files {
+				fmt.Fprintln(sys.Out(), "/.")
+				for _, bin := range p.bins {
+					fmt.Fprintln(sys.Out(), bin)
+				}
+				fmt.Fprintf(sys.Out(), "/usr/share/doc/%v\n/usr/share/doc/%v/copyright\n", name, name)
+				continue
+			}
+			fmt.Fprintf(sys.Out(), "Package: %v\nStatus: install ok installed\nPriority: optional\nInstalled-Size: %v\nArchitecture: amd64\nVersion: %v\n", p.name, p.size, p.version)
+			if len(p.deps) > 0 {
+				fmt.Fprintf(sys.Out(), "Depends: %v\n", strings.Join(p.deps, ", "))
+			}
+			fmt.Fprintf(sys.Out(), "Description: %v\n\n", p.desc)
+		}
+		if res != 0 {
+			fmt.Fprintln(sys.Err(), "Use dpkg --info (= dpkg-deb --info) to examine archive files,\nand dpkg --contents (= dpkg-deb --contents) to list their contents.")
+		}
+		return res
+	case *install:
+		if !isRoot(sys) {
+			fmt.Fprintln(sys.Err(), "dpkg: error: requested operation requires superuser privilege")
+			return 2
+		}
+		logPkgRequest(sys, "dpkg", "install", flag.Args())
+		for _, arg := range flag.Args() {
+			fmt.Fprintf(sys.Err(), "dpkg: error processing archive %v (--install):\n cannot access archive: No such file or directory\n", arg)
+		}
+		fmt.Fprintf(sys.Err(), "Errors were encountered while processing:\n %v\n", strings.Join(flag.Args(), "\n "))
+		return 1
+	}
+	fmt.Fprintln(sys.Err(), "dpkg: error: need an action option\n\nType dpkg --help for help about installing and deinstalling packages [*];\nUse 'apt' or 'aptitude' for user-friendly package management;\nType dpkg -Dhelp for a list of dpkg debug flag values;\nType dpkg --force-help for a list of forcing options;\nType dpkg-deb --help for help about manipulating *.deb files;\n\nOptions marked [*] produce a lot of output - pipe it through 'less' or 'more' !")
+	return 2
+}
+
+func (dpkg) list(patterns []string, db *pkgDB, sys honeyos.Sys) int {
+	var lines []string
+	matched := make([]bool, len(patterns))
+	for _, name := range db.names() {
+		if len(patterns) > 0 {
+			ok := false
+			for i, pattern := range patterns {
+				if m, _ := path.Match(pattern, name); m {
+					ok, matched[i] = true, true
+				}
+			}
+			if !ok {
+				continue
+			}
+		}
+		p := db.pkgs[name]
+		lines = append(lines, fmt.Sprintf("ii  %-20v %-20v %-12v %v", trimTo(name, 20), trimTo(p.version, 20), "amd64", p.desc))
+	}
+	res := 0
+	for i, ok := range matched {
+		if !ok {
+			fmt.Fprintf(sys.Err(), "dpkg-query: no packages found matching %v\n", patterns[i])
+			res = 1
+		}
+	}
+	if len(lines) == 0 {
+		return res
+	}
+	fmt.Fprintln(sys.Out(), "Desired=Unknown/Install/Remove/Purge/Hold\n| Status=Not/Inst/Conf-files/Unpacked/halF-conf/Half-inst/trig-aWait/Trig-pend\n|/ Err?=(none)/Reinst-required (Status,Err: uppercase=bad)")
+	fmt.Fprintf(sys.Out(), "||/ %-20v %-20v %-12v %v\n", "Name", "Version", "Architecture", "Description")
+	fmt.Fprintf(sys.Out(), "+++-%v-%v-%v-%v\n", strings.Repeat("=", 20), strings.Repeat("=", 20), strings.Repeat("=", 12), strings.Repeat("=", 20))
+	for _, line := range lines {
+		fmt.Fprintln(sys.Out(), line)
+	}
+	return res
+}
+
+// trimTo cuts s to n characters, marking the cut like dpkg-query does
+func trimTo(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-1] + "+"
+}
@@ -0,0 +1,189 @@
+package command
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/viper"
+)
+
+// arp shows the ARP cache of the machine, which has the gateway and the
+// hosts of the subnet it talks to
+type arp struct{}
+
+// netNeighbour is an entry of the ARP cache, shown by arp and ip neigh
+type netNeighbour struct {
+	ip       net.IP
+	mac      string
+	hostname string
+	// stale entries have not been used lately, the gateway is always in use
+	stale bool
+}
+
+const arpUsage = `Usage:
+  arp [-vn]  [<HW>] [-i <if>] [-a] [<hostname>]             <-Display ARP cache
+  arp [-v]          [-i <if>] -d  <host> [pub]               <-Delete ARP entry
+  arp [-vnD] [<HW>] [-i <if>] -f  [<filename>]            <-Add entry from file
+  arp [-v]   [<HW>] [-i <if>] -s  <host> <hwaddr> [temp]            <-Add entry
+  arp [-v]   [<HW>] [-i <if>] -Ds <host> <if> [netmask <nm>] pub          <-''-
+
+        -a                       display (all) hosts in alternative (BSD) style
+        -e                       display (all) hosts in default (Linux) style
+        -s, --set                set a new ARP entry
+        -d, --delete             delete a specified entry
+        -v, --verbose            be verbose
+        -n, --numeric            don't resolve names
+        -i, --device             specify network interface (e.g. eth0)
+        -D, --use-device         read <hwaddr> from given device
+        -A, -p, --protocol       specify protocol family
+        -f, --file               read new entries from file or from /etc/ethers
+
+  <HW>=Use '-H <hw>' to specify hardware address type. Default: ether
+  List of possible hardware types (which support ARP):
+    ash (Ash) ether (Ethernet) ax25 (AMPR AX.25)
+    netrom (AMPR NET/ROM) rose (AMPR ROSE) arcnet (ARCnet)
+    dlci (Frame Relay DLCI) fddi (Fiber Distributed Data Interface) hippi (HIPPI)
+    irda (IrLAP) x25 (generic X.25) eui64 (Generic EUI-64)
+`
+
+func init() {
+	honeyos.RegisterCommand("arp", arp{})
+}
+
+func (arp) GetHelp() string {
+	return arpUsage
+}
+
+func (arp) Where() string {
+	return "/usr/sbin/arp"
+}
+
+// netMAC is the made up MAC address of the host of the subnet, of the
+// virtual NIC of QEMU like the machine itself
+func netMAC(ip net.IP) string {
+	if ip.Equal(net.ParseIP(viper.GetString("persona.gateway"))) {
+		return gatewayMAC
+	}
+	h := fnvString(ip.String())
+	return fmt.Sprintf("52:54:00:%02x:%02x:%02x", byte(h>>16), byte(h>>8), byte(h))
+}
+
+// netNeighbours are the hosts of the subnet in the ARP cache: the gateway,
+// the hosts ssh lands on and the peers of the connections of the machine
+func netNeighbours(sys honeyos.Sys) []netNeighbour {
+	eth, _ := findIface("eth0")
+	network := &net.IPNet{IP: eth.addr.Mask(eth.mask), Mask: eth.mask}
+	neighbours := []netNeighbour{{ip: net.ParseIP(viper.GetString("persona.gateway")), mac: gatewayMAC}}
+	seen := map[string]bool{neighbours[0].ip.String(): true, eth.addr.String(): true}
+	add := func(ip net.IP, hostname string) {
+		if ip == nil || seen[ip.String()] || !network.Contains(ip) {
+			return
+		}
+		seen[ip.String()] = true
+		neighbours = append(neighbours, netNeighbour{ip: ip, hostname: hostname, stale: true, mac: netMAC(ip)})
+	}
+	for _, s := range sys.Sockets() {
+		host, _ := honeyos.SplitAddr(s.Remote)
+		add(net.ParseIP(host), "")
+	}
+	for _, entry := range viper.GetStringSlice("persona.ssh.hosts") {
+		if fields := strings.Fields(entry); len(fields) > 1 {
+			add(net.ParseIP(fields[0]), strings.SplitN(fields[1], ".", 2)[0])
+		}
+	}
+	return neighbours
+}
+
+func (a arp) Exec(args []string, sys honeyos.Sys) int {
+	if pkgFamily() == "rpm" && !loadPkgDB(sys, "rpm").installed("net-tools") {
+		return honeyos.CommandNotFound(sys, append([]string{"arp"}, args...))
+	}
+	bsd, numeric, device := false, false, ""
+	var hosts []string
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "-a", "--all":
+			bsd = true
+		case "-e":
+			bsd = false
+		case "-n", "--numeric":
+			numeric = true
+		case "-v", "--verbose":
+		case "-i", "--device", "-H", "--hw-type", "-A", "-p", "--protocol":
+			if i+1 >= len(args) {
+				fmt.Fprint(sys.Err(), arpUsage)
+				return 255
+			}
+			i++
+			if arg == "-i" || arg == "--device" {
+				device = args[i]
+			}
+		case "-s", "--set", "-d", "--delete", "-f", "--file", "-Ds":
+			// Changes are accepted from root, but the cache stays the same
+			sys.Log().WithField("args", args).Info("User tried to configure network")
+			if !isRoot(sys) {
+				fmt.Fprintln(sys.Err(), "SIOCSARP: Operation not permitted")
+				return 255
+			}
+			return 0
+		case "-h", "--help":
+			fmt.Fprint(sys.Out(), arpUsage)
+			return 0
+		default:
+			if strings.HasPrefix(arg, "-") {
+				fmt.Fprint(sys.Err(), arpUsage)
+				return 255
+			}
+			hosts = append(hosts, arg)
+		}
+	}
+	if device != "" && device != "eth0" {
+		if _, ok := findIface(device); !ok {
+			fmt.Fprintf(sys.Err(), "SIOCGIFFLAGS: %v: No such device\n", device)
+			return 255
+		}
+	}
+
+	neighbours := netNeighbours(sys)
+	if device == "lo" {
+		neighbours = nil
+	}
+	if len(hosts) > 0 {
+		ip := netResolve(sys, hosts[0])
+		if ip == nil {
+			fmt.Fprintf(sys.Err(), "%v: Unknown host\n", hosts[0])
+			return 255
+		}
+		var match []netNeighbour
+		for _, n := range neighbours {
+			if n.ip.Equal(ip) {
+				match = append(match, n)
+			}
+		}
+		if len(match) == 0 {
+			fmt.Fprintf(sys.Out(), "arp: in %d entries no match found.\n", len(neighbours))
+			return 1
+		}
+		neighbours = match
+	}
+	if !bsd {
+		fmt.Fprintln(sys.Out(), "Address                  HWtype  HWaddress           Flags Mask            Iface")
+	}
+	for _, n := range neighbours {
+		name := n.hostname
+		if numeric || name == "" {
+			name = n.ip.String()
+		}
+		if bsd {
+			if numeric || n.hostname == "" {
+				name = "?"
+			}
+			fmt.Fprintf(sys.Out(), "%v (%v) at %v [ether] on eth0\n", name, n.ip, n.mac)
+			continue
+		}
+		fmt.Fprintf(sys.Out(), "%-24v %-7v %-19v %-5v %-15v %v\n", name, "ether", n.mac, "C", "", "eth0")
+	}
+	return 0
+}
@@ -0,0 +1,1412 @@
+package command
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+)
+
+// awk runs the programs like mawk, the awk of Debian and Ubuntu. Recon
+// scripts use it for the fields of files like awk -F: '{print $1}' /etc/passwd
+type awk struct {
+	path string
+}
+
+const awkUsage = `usage: mawk [-W option] [-F value] [-v var=value] [--] 'program text' [file ...]
+usage: mawk [-W option] [-F value] [-v var=value] [-f program-file] [file ...]
+`
+
+func init() {
+	honeyos.RegisterCommand("awk", awk{"/usr/bin/awk"})
+	honeyos.RegisterCommand("mawk", awk{"/usr/bin/mawk"})
+}
+
+func (awk) GetHelp() string {
+	return awkUsage
+}
+
+func (a awk) Where() string {
+	return a.path
+}
+
+// Kinds of awk value. strnum is the string from input looking like number,
+// which compares as number
+const (
+	awkKindNum = iota
+	awkKindStr
+	awkKindStrNum
+)
+
+type awkValue struct {
+	s    string
+	n    float64
+	kind byte
+}
+
+func awkNumber(n float64) awkValue { return awkValue{n: n, kind: awkKindNum} }
+func awkString(s string) awkValue  { return awkValue{s: s, kind: awkKindStr} }
+
+var awkNumPrefix = regexp.MustCompile(`^[ \t\n]*[-+]?([0-9]+\.?[0-9]*|\.[0-9]+)([eE][-+]?[0-9]+)?`)
+var awkNumFull = regexp.MustCompile(`^[ \t\n]*[-+]?([0-9]+\.?[0-9]*|\.[0-9]+)([eE][-+]?[0-9]+)?[ \t\n]*$`)
+
+// awkStrNum makes the value of input, which is number if it looks like one
+func awkStrNum(s string) awkValue {
+	if awkNumFull.MatchString(s) {
+		n, _ := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		return awkValue{s: s, n: n, kind: awkKindStrNum}
+	}
+	return awkString(s)
+}
+
+// awkError is the run time error, which ends the program with status 2
+type awkError string
+
+// awkVar is the storage of variable, either scalar or array
+type awkVar struct {
+	v   awkValue
+	arr map[string]awkValue
+}
+
+// Results of executing statements
+const (
+	awkCtrlNone = iota
+	awkCtrlNext
+	awkCtrlNextFile
+	awkCtrlExit
+	awkCtrlBreak
+	awkCtrlContinue
+	awkCtrlReturn
+)
+
+// awkInput reads the records of file, command or standard input
+type awkInput struct {
+	r   *bufio.Reader
+	eof bool
+	// records are split in advance for paragraph mode and regexp RS
+	records []string
+}
+
+// awkOutput collects the output redirected to file or command, which is
+// written when closed
+type awkOutput struct {
+	name, redir string
+	buf         bytes.Buffer
+}
+
+type awkInterp struct {
+	sys      honeyos.Sys
+	prog     *awkProgram
+	globals  map[string]*awkVar
+	frames   []map[string]*awkVar
+	record   string
+	fields   []string
+	split    bool
+	inputs   map[string]*awkInput
+	outputs  map[string]*awkOutput
+	order    []string
+	main     *awkInput
+	argIndex int
+	fileUsed bool
+	retVal   awkValue
+	exitCode int
+	reCache  map[string]*regexp.Regexp
+	rand     *rand.Rand
+	seed     float64
+}
+
+func (a awk) Exec(args []string, sys honeyos.Sys) int {
+	var src string
+	var progFiles []string
+	assigns := []string{}
+	fs := ""
+	hasFS := false
+	i := 0
+	for ; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			i++
+			break
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			break
+		}
+		opt, val := arg[1], arg[2:]
+		if strings.IndexByte("Ffvw", opt) >= 0 && val == "" && opt != 'W' {
+			if i+1 >= len(args) {
+				fmt.Fprintf(sys.Err(), "awk: option requires an argument -- %c\n%v", opt, awkUsage)
+				return 2
+			}
+			i++
+			val = args[i]
+		}
+		switch opt {
+		case 'F':
+			fs, hasFS = val, true
+			if fs == "t" {
+				fs = "\t"
+			}
+		case 'f':
+			progFiles = append(progFiles, val)
+		case 'v':
+			if !strings.Contains(val, "=") {
+				fmt.Fprintf(sys.Err(), "awk: improper assignment: -v %v\n", val)
+				return 2
+			}
+			assigns = append(assigns, val)
+		case 'W':
+			if val == "" && i+1 < len(args) {
+				i++
+				val = args[i]
+			}
+			switch {
+			case strings.HasPrefix(val, "v"):
+				fmt.Fprintln(sys.Out(), "mawk 1.3.3 Nov 1996, Copyright (C) Michael D. Brennan\n\ncompiled limits:\nmax NF             32767\nsprintf buffer      2040")
+				return 0
+			case strings.HasPrefix(val, "u"):
+				fmt.Fprint(sys.Err(), awkUsage)
+				return 0
+			}
+		case '-':
+			if arg == "--version" {
+				fmt.Fprintln(sys.Out(), "mawk 1.3.3 Nov 1996, Copyright (C) Michael D. Brennan")
+				return 0
+			}
+			fmt.Fprintf(sys.Err(), "awk: not an option: %v\n", arg)
+			return 2
+		default:
+			fmt.Fprintf(sys.Err(), "awk: not an option: %v\n", arg)
+			return 2
+		}
+	}
+	args = args[i:]
+	if len(progFiles) > 0 {
+		for _, name := range progFiles {
+			var data []byte
+			var err error
+			if name == "-" || name == "/dev/stdin" {
+				data, err = ioutil.ReadAll(sys.In())
+			} else {
+				data, err = readFile(sys, absPath(sys, name))
+			}
+			if err != nil {
+				fmt.Fprintf(sys.Err(), "awk: couldn't open file %v.\n", name)
+				return 2
+			}
+			src += string(data) + "\n"
+		}
+	} else {
+		if len(args) == 0 {
+			fmt.Fprint(sys.Err(), awkUsage)
+			return 2
+		}
+		src, args = args[0], args[1:]
+	}
+	prog, err := awkParse(src)
+	if err != nil {
+		fmt.Fprintf(sys.Err(), "awk: %v\n", err)
+		return 2
+	}
+
+	in := &awkInterp{
+		sys:     sys,
+		prog:    prog,
+		globals: map[string]*awkVar{},
+		inputs:  map[string]*awkInput{},
+		outputs: map[string]*awkOutput{},
+		reCache: map[string]*regexp.Regexp{},
+		rand:    rand.New(rand.NewSource(0)),
+	}
+	for k, v := range map[string]string{"FS": " ", "OFS": " ", "ORS": "\n", "RS": "\n", "SUBSEP": "\034", "CONVFMT": "%.6g", "OFMT": "%.6g"} {
+		in.globals[k] = &awkVar{v: awkString(v)}
+	}
+	for _, k := range []string{"NR", "FNR", "RSTART", "RLENGTH"} {
+		in.globals[k] = &awkVar{v: awkNumber(0)}
+	}
+	in.globals["RLENGTH"].v = awkNumber(-1)
+	if hasFS {
+		in.globals["FS"].v = awkString(awkUnescape(fs))
+	}
+	env := map[string]awkValue{}
+	for _, e := range sys.Environ() {
+		if kv := strings.SplitN(e, "=", 2); len(kv) == 2 {
+			env[kv[0]] = awkStrNum(kv[1])
+		}
+	}
+	in.globals["ENVIRON"] = &awkVar{arr: env}
+	argv := map[string]awkValue{"0": awkString("awk")}
+	for n, arg := range args {
+		argv[strconv.Itoa(n+1)] = awkStrNum(arg)
+	}
+	in.globals["ARGV"] = &awkVar{arr: argv}
+	in.globals["ARGC"] = &awkVar{v: awkNumber(float64(len(args) + 1))}
+	for _, assign := range assigns {
+		kv := strings.SplitN(assign, "=", 2)
+		in.setVar(kv[0], awkStrNum(awkUnescape(kv[1])))
+	}
+	return in.run()
+}
+
+// awkUnescape processes escape sequences of command line assignments
+func awkUnescape(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			n, esc := awkEscape(s[i+1:])
+			b.WriteString(esc)
+			i += n
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func (in *awkInterp) run() (status int) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg, ok := r.(awkError)
+			if !ok {
+				panic(r)
+			}
+			in.closeAll()
+			if in.sys.Context().Err() != nil {
+				status = 130
+				return
+			}
+			fmt.Fprintf(in.sys.Err(), "awk: %v\n", msg)
+			status = 2
+		}
+	}()
+	ctrl := awkCtrlNone
+	for _, r := range in.prog.begin {
+		if ctrl = in.execRule(r.body); ctrl == awkCtrlExit {
+			break
+		}
+	}
+	if ctrl != awkCtrlExit && (len(in.prog.rules) > 0 || len(in.prog.end) > 0) {
+	records:
+		for {
+			rec, ok := in.nextMain()
+			if !ok {
+				break
+			}
+			in.setRecord(rec)
+			for _, r := range in.prog.rules {
+				if !in.matchRule(r) {
+					continue
+				}
+				if r.body == nil {
+					in.print(false, nil, "", nil)
+					continue
+				}
+				switch in.execRule(r.body) {
+				case awkCtrlNext:
+					continue records
+				case awkCtrlNextFile:
+					in.main = nil
+					continue records
+				case awkCtrlExit:
+					ctrl = awkCtrlExit
+					break records
+				}
+			}
+		}
+	}
+	// Exit in BEGIN and main rules still runs END, exit in END does not
+	for _, r := range in.prog.end {
+		if in.execRule(r.body) == awkCtrlExit {
+			break
+		}
+	}
+	in.closeAll()
+	return in.exitCode
+}
+
+// execRule runs the action of rule, where exit may be run by a function
+func (in *awkInterp) execRule(b *awkBlock) (ctrl int) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(awkExitFromFunc); !ok {
+				panic(r)
+			}
+			ctrl = awkCtrlExit
+		}
+	}()
+	return in.exec(b)
+}
+
+func (in *awkInterp) matchRule(r *awkRule) bool {
+	if r.pattern == nil {
+		return true
+	}
+	if r.pattern2 == nil {
+		return in.bool(in.eval(r.pattern))
+	}
+	if !r.active {
+		if !in.bool(in.eval(r.pattern)) {
+			return false
+		}
+		r.active = true
+	}
+	if in.bool(in.eval(r.pattern2)) {
+		r.active = false
+	}
+	return true
+}
+
+func (in *awkInterp) checkInterrupt() {
+	if in.sys.Context().Err() != nil {
+		panic(awkError("interrupted"))
+	}
+}
+
+// Variables
+
+func (in *awkInterp) lookup(name string) *awkVar {
+	if len(in.frames) > 0 {
+		if v, ok := in.frames[len(in.frames)-1][name]; ok {
+			return v
+		}
+	}
+	v, ok := in.globals[name]
+	if !ok {
+		v = &awkVar{v: awkValue{kind: awkKindStrNum}}
+		in.globals[name] = v
+	}
+	return v
+}
+
+func (in *awkInterp) getVar(name string) awkValue {
+	if name == "NF" {
+		in.splitRecord()
+		return awkNumber(float64(len(in.fields)))
+	}
+	v := in.lookup(name)
+	if v.arr != nil {
+		panic(awkError(fmt.Sprintf("can't use array %v in scalar context", name)))
+	}
+	return v.v
+}
+
+func (in *awkInterp) setVar(name string, val awkValue) {
+	if name == "NF" {
+		in.splitRecord()
+		n := int(in.toNum(val))
+		if n < 0 {
+			panic(awkError("NF set to negative value"))
+		}
+		for len(in.fields) < n {
+			in.fields = append(in.fields, "")
+		}
+		in.fields = in.fields[:n]
+		in.rebuild()
+		return
+	}
+	v := in.lookup(name)
+	if v.arr != nil {
+		panic(awkError(fmt.Sprintf("can't assign to %v; it's an array name.", name)))
+	}
+	v.v = val
+}
+
+func (in *awkInterp) array(name string) map[string]awkValue {
+	v := in.lookup(name)
+	if v.arr == nil {
+		if v.v.kind != awkKindStrNum || v.v.s != "" {
+			panic(awkError(fmt.Sprintf("can't use scalar %v as array", name)))
+		}
+		v.arr = map[string]awkValue{}
+	}
+	return v.arr
+}
+
+func (in *awkInterp) subscript(subs []awkExpr) string {
+	if len(subs) == 1 {
+		return in.toStr(in.eval(subs[0]))
+	}
+	var keys []string
+	for _, s := range subs {
+		keys = append(keys, in.toStr(in.eval(s)))
+	}
+	return strings.Join(keys, in.toStr(in.getVar("SUBSEP")))
+}
+
+// Record and fields
+
+func (in *awkInterp) setRecord(rec string) {
+	in.record, in.split = rec, false
+}
+
+func (in *awkInterp) splitRecord() {
+	if in.split {
+		return
+	}
+	in.fields = in.splitFS(in.record, in.toStr(in.getVar("FS")), nil)
+	in.split = true
+}
+
+// splitFS splits the string like the record is split into fields. Space
+// splits on runs of blanks, other single characters split literally and
+// longer FS is regexp
+func (in *awkInterp) splitFS(s, fs string, re *regexp.Regexp) []string {
+	if s == "" {
+		return nil
+	}
+	paragraph := in.toStr(in.getVar("RS")) == ""
+	switch {
+	case re != nil:
+	case fs == " ":
+		return strings.Fields(s)
+	case fs == "":
+		return strings.Split(s, "")
+	case len(fs) == 1 && fs != "\\":
+		if paragraph {
+			return strings.FieldsFunc(s, func(r rune) bool { return r == '\n' || r == rune(fs[0]) })
+		}
+		return strings.Split(s, fs)
+	default:
+		re = in.regexp(fs)
+	}
+	if paragraph {
+		var fields []string
+		for _, line := range strings.Split(s, "\n") {
+			fields = append(fields, re.Split(line, -1)...)
+		}
+		return fields
+	}
+	return re.Split(s, -1)
+}
+
+// rebuild joins the fields with OFS after a field is changed
+func (in *awkInterp) rebuild() {
+	in.record = strings.Join(in.fields, in.toStr(in.getVar("OFS")))
+}
+
+func (in *awkInterp) getField(i int) awkValue {
+	if i < 0 {
+		panic(awkError(fmt.Sprintf("negative field index $%v", i)))
+	}
+	if i == 0 {
+		return awkStrNum(in.record)
+	}
+	in.splitRecord()
+	if i > len(in.fields) {
+		return awkValue{kind: awkKindStrNum}
+	}
+	return awkStrNum(in.fields[i-1])
+}
+
+func (in *awkInterp) setField(i int, val string) {
+	if i < 0 {
+		panic(awkError(fmt.Sprintf("negative field index $%v", i)))
+	}
+	if i == 0 {
+		in.setRecord(val)
+		return
+	}
+	in.splitRecord()
+	for len(in.fields) < i {
+		in.fields = append(in.fields, "")
+	}
+	in.fields[i-1] = val
+	in.rebuild()
+}
+
+// Conversions
+
+func (in *awkInterp) format(n float64, conv string) string {
+	if n == math.Trunc(n) && math.Abs(n) < 1e16 {
+		return strconv.FormatInt(int64(n), 10)
+	}
+	if math.IsNaN(n) {
+		return "nan"
+	}
+	if math.IsInf(n, 0) {
+		if n > 0 {
+			return "inf"
+		}
+		return "-inf"
+	}
+	return in.sprintf(in.toStr(in.getVar(conv)), []awkValue{awkNumber(n)})
+}
+
+func (in *awkInterp) toStr(v awkValue) string {
+	if v.kind == awkKindNum {
+		return in.format(v.n, "CONVFMT")
+	}
+	return v.s
+}
+
+func (in *awkInterp) toNum(v awkValue) float64 {
+	switch v.kind {
+	case awkKindNum, awkKindStrNum:
+		return v.n
+	}
+	m := awkNumPrefix.FindString(v.s)
+	n, _ := strconv.ParseFloat(strings.TrimLeft(m, " \t\n"), 64)
+	return n
+}
+
+func (in *awkInterp) bool(v awkValue) bool {
+	switch v.kind {
+	case awkKindNum:
+		return v.n != 0
+	case awkKindStrNum:
+		if v.s == "" {
+			return false
+		}
+		return v.n != 0
+	}
+	return v.s != ""
+}
+
+func awkBool(b bool) awkValue {
+	if b {
+		return awkNumber(1)
+	}
+	return awkNumber(0)
+}
+
+func (in *awkInterp) compare(a, b awkValue) int {
+	if a.kind != awkKindStr && b.kind != awkKindStr && !(a.kind == awkKindStrNum && a.s == "" && b.kind == awkKindStrNum && b.s != "") {
+		switch x, y := in.toNum(a), in.toNum(b); {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(in.toStr(a), in.toStr(b))
+}
+
+// regexp compiles the dynamic regexp of string, which are cached as scripts
+// use the same ones for every line
+func (in *awkInterp) regexp(s string) *regexp.Regexp {
+	if re, ok := in.reCache[s]; ok {
+		return re
+	}
+	re, err := regexp.Compile(grepConvert(s, true))
+	if err != nil {
+		panic(awkError(fmt.Sprintf("regular expression compile failed (%v)", s)))
+	}
+	in.reCache[s] = re
+	return re
+}
+
+func (in *awkInterp) regexpOf(e awkExpr) *regexp.Regexp {
+	if r, ok := e.(*awkRegex); ok {
+		return r.re
+	}
+	return in.regexp(in.toStr(in.eval(e)))
+}
+
+// Statements
+
+func (in *awkInterp) exec(s awkStmt) int {
+	switch s := s.(type) {
+	case *awkBlock:
+		for _, st := range s.stmts {
+			if c := in.exec(st); c != awkCtrlNone {
+				return c
+			}
+		}
+	case *awkExprStmt:
+		in.eval(s.e)
+	case *awkPrint:
+		in.print(s.printf, s.args, s.redir, s.dest)
+	case *awkIf:
+		if in.bool(in.eval(s.c)) {
+			return in.exec(s.then)
+		} else if s.els != nil {
+			return in.exec(s.els)
+		}
+	case *awkWhile:
+		// do-while runs the body before checking the condition
+		for first := s.do; first || in.bool(in.eval(s.c)); first = false {
+			in.checkInterrupt()
+			c := in.exec(s.body)
+			if c == awkCtrlBreak {
+				break
+			}
+			if c != awkCtrlNone && c != awkCtrlContinue {
+				return c
+			}
+		}
+	case *awkFor:
+		if s.init != nil {
+			in.exec(s.init)
+		}
+		for s.c == nil || in.bool(in.eval(s.c)) {
+			in.checkInterrupt()
+			c := in.exec(s.body)
+			if c == awkCtrlBreak {
+				break
+			}
+			if c != awkCtrlNone && c != awkCtrlContinue {
+				return c
+			}
+			if s.post != nil {
+				in.exec(s.post)
+			}
+		}
+	case *awkForIn:
+		arr := in.array(s.arr)
+		for _, k := range awkKeys(arr) {
+			if _, ok := arr[k]; !ok {
+				continue
+			}
+			in.setVar(s.name, awkStrNum(k))
+			c := in.exec(s.body)
+			if c == awkCtrlBreak {
+				break
+			}
+			if c != awkCtrlNone && c != awkCtrlContinue {
+				return c
+			}
+		}
+	case *awkSimple:
+		return map[string]int{"next": awkCtrlNext, "nextfile": awkCtrlNextFile, "break": awkCtrlBreak, "continue": awkCtrlContinue}[s.keyword]
+	case *awkExit:
+		if s.keyword == "return" {
+			in.retVal = awkValue{kind: awkKindStrNum}
+			if s.e != nil {
+				in.retVal = in.eval(s.e)
+			}
+			return awkCtrlReturn
+		}
+		if s.e != nil {
+			in.exitCode = int(in.toNum(in.eval(s.e)))
+		}
+		return awkCtrlExit
+	case *awkDelete:
+		arr := in.array(s.name)
+		if s.subs == nil {
+			for k := range arr {
+				delete(arr, k)
+			}
+		} else {
+			delete(arr, in.subscript(s.subs))
+		}
+	}
+	return awkCtrlNone
+}
+
+// awkKeys orders the keys of array for for-in, numbers first
+func awkKeys(arr map[string]awkValue) []string {
+	keys := make([]string, 0, len(arr))
+	for k := range arr {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, errA := strconv.ParseFloat(keys[i], 64)
+		b, errB := strconv.ParseFloat(keys[j], 64)
+		switch {
+		case errA == nil && errB == nil:
+			return a < b
+		case errA == nil || errB == nil:
+			return errA == nil
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+func (in *awkInterp) print(printf bool, args []awkExpr, redir string, dest awkExpr) {
+	var text string
+	switch {
+	case printf:
+		var vals []awkValue
+		for _, a := range args[1:] {
+			vals = append(vals, in.eval(a))
+		}
+		text = in.sprintf(in.toStr(in.eval(args[0])), vals)
+	case len(args) == 0:
+		text = in.record + in.toStr(in.getVar("ORS"))
+	default:
+		var parts []string
+		for _, a := range args {
+			v := in.eval(a)
+			if v.kind == awkKindNum {
+				parts = append(parts, in.format(v.n, "OFMT"))
+			} else {
+				parts = append(parts, v.s)
+			}
+		}
+		text = strings.Join(parts, in.toStr(in.getVar("OFS"))) + in.toStr(in.getVar("ORS"))
+	}
+	in.output(text, redir, dest)
+}
+
+func (in *awkInterp) output(text, redir string, dest awkExpr) {
+	if redir == "" {
+		io.WriteString(in.sys.Out(), text)
+		return
+	}
+	name := in.toStr(in.eval(dest))
+	switch name {
+	case "/dev/stdout", "-":
+		io.WriteString(in.sys.Out(), text)
+		return
+	case "/dev/stderr":
+		io.WriteString(in.sys.Err(), text)
+		return
+	}
+	out, ok := in.outputs[name]
+	if !ok {
+		out = &awkOutput{name: name, redir: redir}
+		if redir != "|" {
+			// The file is truncated when opened like awk does
+			p := absPath(in.sys, name)
+			flag := os.O_WRONLY | os.O_CREATE
+			if redir == ">" {
+				flag |= os.O_TRUNC
+			} else {
+				flag |= os.O_APPEND
+			}
+			f, err := in.sys.FSys().OpenFile(p, flag, 0666&^in.sys.Umask())
+			if err != nil {
+				panic(awkError(fmt.Sprintf("cannot open \"%v\" for output", name)))
+			}
+			f.Close()
+		}
+		in.outputs[name] = out
+		in.order = append(in.order, name)
+	}
+	out.buf.WriteString(text)
+}
+
+// close writes the output to file or command, or ends reading the input
+func (in *awkInterp) close(name string) int {
+	if _, ok := in.inputs[name]; ok {
+		delete(in.inputs, name)
+		return 0
+	}
+	out, ok := in.outputs[name]
+	if !ok {
+		return -1
+	}
+	delete(in.outputs, name)
+	if out.redir == "|" {
+		n, _ := honeyos.RunAs(in.sys, honeyos.Credential{UID: in.sys.CurrentUser(), Stdin: &out.buf}, []string{"sh", "-c", name})
+		return n
+	}
+	p := absPath(in.sys, name)
+	f, err := in.sys.FSys().OpenFile(p, os.O_WRONLY|os.O_APPEND, 0666&^in.sys.Umask())
+	if err != nil {
+		return -1
+	}
+	defer f.Close()
+	data := out.buf.Bytes()
+	f.Write(data)
+	if len(data) > 0 {
+		in.sys.Log().WithField("file", p).Infof("User wrote %v with awk", p)
+		honeyos.SaveArtifact(in.sys, data, "awk "+p)
+	}
+	return 0
+}
+
+func (in *awkInterp) closeAll() {
+	for _, name := range in.order {
+		if _, ok := in.outputs[name]; ok {
+			in.close(name)
+		}
+	}
+	in.order = nil
+}
+
+// Input
+
+func (in *awkInterp) openInput(r io.Reader) *awkInput {
+	inp := &awkInput{r: bufio.NewReader(r)}
+	rs := in.toStr(in.getVar("RS"))
+	if len(rs) > 1 || rs == "" {
+		data, _ := ioutil.ReadAll(inp.r)
+		inp.eof = true
+		text := string(data)
+		if rs == "" {
+			text = strings.Trim(text, "\n")
+			if text != "" {
+				inp.records = regexp.MustCompile(`\n\n+`).Split(text, -1)
+			}
+		} else {
+			inp.records = in.regexp(rs).Split(text, -1)
+			if n := len(inp.records); n > 0 && inp.records[n-1] == "" {
+				inp.records = inp.records[:n-1]
+			}
+		}
+	}
+	return inp
+}
+
+func (in *awkInterp) readRecord(inp *awkInput) (string, bool) {
+	if inp.records != nil || inp.eof {
+		if len(inp.records) == 0 {
+			return "", false
+		}
+		rec := inp.records[0]
+		inp.records = inp.records[1:]
+		return rec, true
+	}
+	rs := in.toStr(in.getVar("RS"))
+	line, err := inp.r.ReadString(rs[0])
+	if err != nil {
+		inp.eof = true
+		if line == "" {
+			return "", false
+		}
+		return line, true
+	}
+	return line[:len(line)-1], true
+}
+
+// nextMain reads the next record of the files in ARGV, or standard input if
+// there are none. Operands like var=value are assigned when reached
+func (in *awkInterp) nextMain() (string, bool) {
+	for {
+		in.checkInterrupt()
+		if in.main != nil {
+			if rec, ok := in.readRecord(in.main); ok {
+				in.setVar("NR", awkNumber(in.toNum(in.getVar("NR"))+1))
+				in.setVar("FNR", awkNumber(in.toNum(in.getVar("FNR"))+1))
+				return rec, true
+			}
+			in.main = nil
+		}
+		argc := int(in.toNum(in.getVar("ARGC")))
+		in.argIndex++
+		if in.argIndex >= argc {
+			if in.fileUsed {
+				return "", false
+			}
+			in.fileUsed = true
+			in.main = in.openInput(in.sys.In())
+			in.setVar("FNR", awkNumber(0))
+			continue
+		}
+		argv := in.array("ARGV")
+		arg, ok := argv[strconv.Itoa(in.argIndex)]
+		name := in.toStr(arg)
+		if !ok || name == "" {
+			continue
+		}
+		if kv := strings.SplitN(name, "=", 2); len(kv) == 2 && awkNameRE.MatchString(kv[0]) {
+			in.setVar(kv[0], awkStrNum(awkUnescape(kv[1])))
+			continue
+		}
+		in.fileUsed = true
+		in.setVar("FILENAME", awkString(name))
+		in.setVar("FNR", awkNumber(0))
+		if name == "-" || name == "/dev/stdin" {
+			in.main = in.openInput(in.sys.In())
+			continue
+		}
+		p := absPath(in.sys, name)
+		if fi, err := in.sys.FSys().Stat(p); err == nil && fi.IsDir() {
+			// mawk reads nothing from the directory without complaining
+			continue
+		}
+		data, err := readFile(in.sys, p)
+		if err != nil {
+			reason := "No such file or directory"
+			if os.IsPermission(err) {
+				reason = "Permission denied"
+			}
+			panic(awkError(fmt.Sprintf("cannot open %v (%v)", name, reason)))
+		}
+		in.main = in.openInput(bytes.NewReader(data))
+	}
+}
+
+var awkNameRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func (in *awkInterp) getline(g *awkGetline) awkValue {
+	var rec string
+	var ok bool
+	switch g.op {
+	case "":
+		if rec, ok = in.nextMain(); !ok {
+			return awkNumber(0)
+		}
+	case "<", "|":
+		name := in.toStr(in.eval(g.src))
+		inp, exists := in.inputs[name]
+		if !exists {
+			if g.op == "<" {
+				var r io.Reader
+				if name == "-" || name == "/dev/stdin" {
+					r = in.sys.In()
+				} else {
+					data, err := readFile(in.sys, absPath(in.sys, name))
+					if err != nil {
+						return awkNumber(-1)
+					}
+					r = bytes.NewReader(data)
+				}
+				inp = in.openInput(r)
+			} else {
+				// Output of the command is collected before reading
+				if out, ok := in.outputs[name]; ok && out.redir == "|" {
+					in.close(name)
+				}
+				var buf bytes.Buffer
+				honeyos.RunAs(in.sys, honeyos.Credential{UID: in.sys.CurrentUser(), Stdout: &buf}, []string{"sh", "-c", name})
+				inp = in.openInput(&buf)
+			}
+			in.inputs[name] = inp
+		}
+		if rec, ok = in.readRecord(inp); !ok {
+			return awkNumber(0)
+		}
+		if g.op == "|" {
+			in.setVar("NR", awkNumber(in.toNum(in.getVar("NR"))+1))
+		}
+	}
+	if g.lv == nil {
+		in.setRecord(rec)
+	} else {
+		in.assign(g.lv, awkStrNum(rec))
+	}
+	return awkNumber(1)
+}
+
+// Expressions
+
+func (in *awkInterp) assign(lv awkExpr, v awkValue) {
+	switch lv := lv.(type) {
+	case *awkVarRef:
+		in.setVar(lv.name, v)
+	case *awkIndex:
+		in.array(lv.name)[in.subscript(lv.subs)] = v
+	case *awkField:
+		in.setField(int(in.toNum(in.eval(lv.idx))), in.toStr(v))
+	}
+}
+
+func (in *awkInterp) arith(op string, a, b float64) float64 {
+	switch op {
+	case "+":
+		return a + b
+	case "-":
+		return a - b
+	case "*":
+		return a * b
+	case "/":
+		if b == 0 {
+			panic(awkError("division by zero"))
+		}
+		return a / b
+	case "%":
+		if b == 0 {
+			panic(awkError("division by zero in %"))
+		}
+		return math.Mod(a, b)
+	case "^":
+		return math.Pow(a, b)
+	}
+	return 0
+}
+
+func (in *awkInterp) eval(e awkExpr) awkValue {
+	switch e := e.(type) {
+	case *awkNum:
+		return awkNumber(e.v)
+	case *awkStr:
+		return awkString(e.v)
+	case *awkRegex:
+		return awkBool(e.re.MatchString(in.record))
+	case *awkGroup:
+		if len(e.list) > 1 {
+			panic(awkError("syntax error: unexpected list"))
+		}
+		return in.eval(e.list[0])
+	case *awkVarRef:
+		return in.getVar(e.name)
+	case *awkIndex:
+		arr := in.array(e.name)
+		k := in.subscript(e.subs)
+		v, ok := arr[k]
+		if !ok {
+			// Referencing the element creates it
+			v = awkValue{kind: awkKindStrNum}
+			arr[k] = v
+		}
+		return v
+	case *awkField:
+		return in.getField(int(in.toNum(in.eval(e.idx))))
+	case *awkUnary:
+		v := in.eval(e.e)
+		switch e.op {
+		case "!":
+			return awkBool(!in.bool(v))
+		case "-":
+			return awkNumber(-in.toNum(v))
+		}
+		return awkNumber(in.toNum(v))
+	case *awkBinary:
+		switch e.op {
+		case "&&":
+			return awkBool(in.bool(in.eval(e.l)) && in.bool(in.eval(e.r)))
+		case "||":
+			return awkBool(in.bool(in.eval(e.l)) || in.bool(in.eval(e.r)))
+		case " ":
+			l := in.toStr(in.eval(e.l))
+			return awkString(l + in.toStr(in.eval(e.r)))
+		case "<", "<=", "!=", "==", ">", ">=":
+			c := in.compare(in.eval(e.l), in.eval(e.r))
+			return awkBool(map[string]bool{"<": c < 0, "<=": c <= 0, "!=": c != 0, "==": c == 0, ">": c > 0, ">=": c >= 0}[e.op])
+		}
+		l := in.toNum(in.eval(e.l))
+		return awkNumber(in.arith(e.op, l, in.toNum(in.eval(e.r))))
+	case *awkAssign:
+		v := in.eval(e.rv)
+		if e.op != "=" {
+			old := in.toNum(in.eval(e.lv))
+			v = awkNumber(in.arith(e.op[:1], old, in.toNum(v)))
+		}
+		in.assign(e.lv, v)
+		return v
+	case *awkCond:
+		if in.bool(in.eval(e.c)) {
+			return in.eval(e.a)
+		}
+		return in.eval(e.b)
+	case *awkIncr:
+		old := in.toNum(in.eval(e.lv))
+		n := old + 1
+		if e.op == "--" {
+			n = old - 1
+		}
+		in.assign(e.lv, awkNumber(n))
+		if e.pre {
+			return awkNumber(n)
+		}
+		return awkNumber(old)
+	case *awkIn:
+		_, ok := in.array(e.name)[in.subscript(e.subs)]
+		return awkBool(ok)
+	case *awkMatch:
+		s := in.toStr(in.eval(e.l))
+		return awkBool(in.regexpOf(e.r).MatchString(s) != e.neg)
+	case *awkGetline:
+		return in.getline(e)
+	case *awkCall:
+		if e.builtin {
+			return in.builtin(e.name, e.args)
+		}
+		return in.call(e)
+	}
+	panic(awkError(fmt.Sprintf("unexpected expression %T", e)))
+}
+
+func (in *awkInterp) call(e *awkCall) awkValue {
+	f, ok := in.prog.funcs[e.name]
+	if !ok {
+		panic(awkError(fmt.Sprintf("function %v never defined", e.name)))
+	}
+	if len(e.args) > len(f.params) {
+		panic(awkError(fmt.Sprintf("too many arguments in call to %v", e.name)))
+	}
+	if len(in.frames) > 1000 {
+		panic(awkError("function call nesting too deep"))
+	}
+	frame := map[string]*awkVar{}
+	for i, param := range f.params {
+		if i >= len(e.args) {
+			frame[param] = &awkVar{v: awkValue{kind: awkKindStrNum}}
+			continue
+		}
+		// Arrays are passed by reference
+		if ref, ok := e.args[i].(*awkVarRef); ok {
+			if v := in.lookup(ref.name); v.arr != nil || v.v.kind == awkKindStrNum && v.v.s == "" {
+				frame[param] = v
+				continue
+			}
+		}
+		frame[param] = &awkVar{v: in.eval(e.args[i])}
+	}
+	in.frames = append(in.frames, frame)
+	defer func() { in.frames = in.frames[:len(in.frames)-1] }()
+	in.retVal = awkValue{kind: awkKindStrNum}
+	if in.exec(f.body) == awkCtrlExit {
+		panic(awkExitFromFunc{})
+	}
+	return in.retVal
+}
+
+// awkExitFromFunc unwinds calls of function running exit
+type awkExitFromFunc struct{}
+
+func (in *awkInterp) builtin(name string, args []awkExpr) awkValue {
+	arg := func(i int) awkValue {
+		if i >= len(args) {
+			return awkValue{kind: awkKindStrNum}
+		}
+		return in.eval(args[i])
+	}
+	str := func(i int) string { return in.toStr(arg(i)) }
+	num := func(i int) float64 { return in.toNum(arg(i)) }
+	switch name {
+	case "length":
+		if len(args) == 0 {
+			return awkNumber(float64(len([]rune(in.record))))
+		}
+		if ref, ok := args[0].(*awkVarRef); ok {
+			if v := in.lookup(ref.name); v.arr != nil {
+				return awkNumber(float64(len(v.arr)))
+			}
+		}
+		return awkNumber(float64(len([]rune(str(0)))))
+	case "substr":
+		s := []rune(str(0))
+		start := math.Floor(num(1) + 0.5)
+		end := float64(len(s)) + 1
+		if len(args) > 2 {
+			end = start + math.Floor(num(2)+0.5)
+		}
+		start, end = math.Max(start, 1), math.Min(end, float64(len(s))+1)
+		if end <= start {
+			return awkString("")
+		}
+		return awkString(string(s[int(start)-1 : int(end)-1]))
+	case "index":
+		s, t := str(0), str(1)
+		i := strings.Index(s, t)
+		if i < 0 {
+			return awkNumber(0)
+		}
+		return awkNumber(float64(len([]rune(s[:i])) + 1))
+	case "split":
+		s := str(0)
+		ref, ok := args[1].(*awkVarRef)
+		if !ok {
+			panic(awkError("split: second argument is not an array"))
+		}
+		var parts []string
+		switch {
+		case len(args) < 3:
+			parts = in.splitFS(s, in.toStr(in.getVar("FS")), nil)
+		default:
+			if r, ok := args[2].(*awkRegex); ok {
+				parts = in.splitFS(s, "", r.re)
+			} else {
+				parts = in.splitFS(s, str(2), nil)
+			}
+		}
+		arr := in.array(ref.name)
+		for k := range arr {
+			delete(arr, k)
+		}
+		for i, part := range parts {
+			arr[strconv.Itoa(i+1)] = awkStrNum(part)
+		}
+		return awkNumber(float64(len(parts)))
+	case "sub", "gsub":
+		re := in.regexpOf(args[0])
+		repl := str(1)
+		var target awkExpr = &awkField{&awkNum{0}}
+		if len(args) > 2 {
+			target = args[2]
+		}
+		s := in.toStr(in.eval(target))
+		matches := re.FindAllStringIndex(s, -1)
+		if name == "sub" && len(matches) > 1 {
+			matches = matches[:1]
+		}
+		if len(matches) == 0 {
+			return awkNumber(0)
+		}
+		var b strings.Builder
+		last := 0
+		for _, m := range matches {
+			b.WriteString(s[last:m[0]])
+			for i := 0; i < len(repl); i++ {
+				switch {
+				case repl[i] == '\\' && i+1 < len(repl) && (repl[i+1] == '&' || repl[i+1] == '\\'):
+					i++
+					b.WriteByte(repl[i])
+				case repl[i] == '&':
+					b.WriteString(s[m[0]:m[1]])
+				default:
+					b.WriteByte(repl[i])
+				}
+			}
+			last = m[1]
+		}
+		b.WriteString(s[last:])
+		if awkIsLvalue(target) {
+			in.assign(target, awkString(b.String()))
+		}
+		return awkNumber(float64(len(matches)))
+	case "match":
+		s := str(0)
+		loc := in.regexpOf(args[1]).FindStringIndex(s)
+		if loc == nil {
+			in.setVar("RSTART", awkNumber(0))
+			in.setVar("RLENGTH", awkNumber(-1))
+			return awkNumber(0)
+		}
+		start := float64(len([]rune(s[:loc[0]])) + 1)
+		in.setVar("RSTART", awkNumber(start))
+		in.setVar("RLENGTH", awkNumber(float64(len([]rune(s[loc[0]:loc[1]])))))
+		return awkNumber(start)
+	case "sprintf":
+		if len(args) == 0 {
+			return awkString("")
+		}
+		var vals []awkValue
+		for i := range args[1:] {
+			vals = append(vals, arg(i+1))
+		}
+		return awkString(in.sprintf(str(0), vals))
+	case "sin":
+		return awkNumber(math.Sin(num(0)))
+	case "cos":
+		return awkNumber(math.Cos(num(0)))
+	case "atan2":
+		return awkNumber(math.Atan2(num(0), num(1)))
+	case "exp":
+		return awkNumber(math.Exp(num(0)))
+	case "log":
+		return awkNumber(math.Log(num(0)))
+	case "sqrt":
+		return awkNumber(math.Sqrt(num(0)))
+	case "int":
+		return awkNumber(math.Trunc(num(0)))
+	case "rand":
+		return awkNumber(in.rand.Float64())
+	case "srand":
+		prev := in.seed
+		in.seed = float64(time.Now().Unix())
+		if len(args) > 0 {
+			in.seed = num(0)
+		}
+		in.rand.Seed(int64(in.seed))
+		return awkNumber(prev)
+	case "tolower":
+		return awkString(strings.ToLower(str(0)))
+	case "toupper":
+		return awkString(strings.ToUpper(str(0)))
+	case "system":
+		// Output redirected so far goes out before the command runs
+		cmd := str(0)
+		n, _ := honeyos.RunAs(in.sys, honeyos.Credential{UID: in.sys.CurrentUser()}, []string{"sh", "-c", cmd})
+		return awkNumber(float64(n))
+	case "close":
+		return awkNumber(float64(in.close(str(0))))
+	case "fflush":
+		return awkNumber(0)
+	}
+	panic(awkError(fmt.Sprintf("function %v never defined", name)))
+}
+
+// sprintf formats the values like printf(3), with the conversions of awk
+func (in *awkInterp) sprintf(format string, vals []awkValue) string {
+	var b strings.Builder
+	next := func() (awkValue, bool) {
+		if len(vals) == 0 {
+			return awkValue{}, false
+		}
+		v := vals[0]
+		vals = vals[1:]
+		return v, true
+	}
+	for i := 0; i < len(format); i++ {
+		c, start := format[i], i
+		if c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+		j := i + 1
+		for j < len(format) && strings.IndexByte("-+ #0", format[j]) >= 0 {
+			j++
+		}
+		spec := format[i:j]
+		// Width and precision of * are taken from the arguments
+		for part := 0; part < 2; part++ {
+			if part == 1 {
+				if j >= len(format) || format[j] != '.' {
+					break
+				}
+				spec += "."
+				j++
+			}
+			if j < len(format) && format[j] == '*' {
+				v, _ := next()
+				spec += strconv.Itoa(int(in.toNum(v)))
+				j++
+				continue
+			}
+			start := j
+			for j < len(format) && format[j] >= '0' && format[j] <= '9' {
+				j++
+			}
+			spec += format[start:j]
+		}
+		for j < len(format) && strings.IndexByte("hlLqjzt", format[j]) >= 0 {
+			j++
+		}
+		if j >= len(format) {
+			b.WriteString(format[i:])
+			break
+		}
+		verb := format[j]
+		i = j
+		if verb == '%' {
+			b.WriteByte('%')
+			continue
+		}
+		v, ok := next()
+		if !ok && strings.IndexByte("cdiouxXeEfFgGs", verb) >= 0 {
+			panic(awkError("not enough arguments passed to sprintf(\"" + format + "\")"))
+		}
+		switch verb {
+		case 'd', 'i':
+			b.WriteString(fmt.Sprintf(spec+"d", int64(in.toNum(v))))
+		case 'o', 'x', 'X':
+			b.WriteString(fmt.Sprintf(spec+string(verb), uint64(int64(in.toNum(v)))))
+		case 'u':
+			b.WriteString(fmt.Sprintf(spec+"d", uint64(int64(in.toNum(v)))))
+		case 'e', 'E', 'f', 'F', 'g', 'G':
+			if verb == 'F' {
+				verb = 'f'
+			}
+			b.WriteString(fmt.Sprintf(spec+string(verb), in.toNum(v)))
+		case 'c':
+			s := ""
+			if v.kind == awkKindNum {
+				s = string(rune(int(v.n)))
+			} else if r := []rune(v.s); len(r) > 0 {
+				s = string(r[0])
+			}
+			b.WriteString(fmt.Sprintf(spec+"s", s))
+		case 's':
+			s := v.s
+			if v.kind == awkKindNum {
+				s = in.format(v.n, "CONVFMT")
+			}
+			b.WriteString(fmt.Sprintf(spec+"s", s))
+		default:
+			b.WriteString(format[start : j+1])
+			if ok {
+				vals = append([]awkValue{v}, vals...)
+			}
+		}
+	}
+	return b.String()
+}
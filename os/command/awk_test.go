@@ -0,0 +1,67 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestAwk(t *testing.T) {
+	passwd := "root:x:0:0:root:/root:/bin/bash\ndaemon:x:1:1:daemon:/usr/sbin:/usr/sbin/nologin\n" +
+		"ubuntu:x:1000:1000:Ubuntu:/home/ubuntu:/bin/bash\n"
+	tests := []struct {
+		args   []string
+		stdin  string
+		expect string
+		status int
+	}{
+		// Field splitting
+		{[]string{"{print $2}"}, "a b c\n  d   e  \n", "b\ne\n", 0},
+		{[]string{"{print $NF, NF}"}, "a b c\nd\n", "c 3\nd 1\n", 0},
+		{[]string{"{print $0}"}, "  a  b  \n", "  a  b  \n", 0},
+		{[]string{"{$2 = \"x\"; print}"}, "a b c\n", "a x c\n", 0},
+		{[]string{"{print $1 $3}"}, "a b c\n", "ac\n", 0},
+		{[]string{"{print NR \": \" $1}"}, "a\nb\n", "1: a\n2: b\n", 0},
+		// -F
+		{[]string{"-F:", "{print $1}", "/etc/passwd"}, "", "root\ndaemon\nubuntu\n", 0},
+		{[]string{"-F", ":", "$3 >= 1000 {print $1, $6}", "/etc/passwd"}, "", "ubuntu /home/ubuntu\n", 0},
+		{[]string{"-F", "[,;]", "{print $2}"}, "a,b;c\n", "b\n", 0},
+		{[]string{"-F\\t", "{print $2}"}, "a b\tc\n", "c\n", 0},
+		{[]string{"-v", "OFS=-", "{$1 = $1; print}"}, "a b c\n", "a-b-c\n", 0},
+		{[]string{"BEGIN {FS = \":\"} {print $7}", "/etc/passwd"}, "", "/bin/bash\n/usr/sbin/nologin\n/bin/bash\n", 0},
+		// BEGIN and END
+		{[]string{"BEGIN {print \"start\"} END {print \"end\"}"}, "a\n", "start\nend\n", 0},
+		{[]string{"{n += $1} END {print n, NR}"}, "1\n2\n3\n", "6 3\n", 0},
+		{[]string{"END {print $0}"}, "a\nb\n", "b\n", 0},
+		{[]string{"BEGIN {exit 3} END {print \"end\"}"}, "", "end\n", 3},
+		// printf
+		{[]string{"{printf \"%-5s|%3d|%.2f\\n\", $1, $2, $3}"}, "ab 7 3.14159\n", "ab   |  7|3.14\n", 0},
+		{[]string{"BEGIN {printf \"%x %o %c %5.1e%%\\n\", 255, 8, 65, 1234.5}"}, "", "ff 10 A 1.2e+03%\n", 0},
+		{[]string{"BEGIN {x = sprintf(\"%03d\", 7); print x}"}, "", "007\n", 0},
+		// Arrays
+		{[]string{"{c[$1]++} END {for (k in c) n++; print n, c[\"a\"]}"}, "a\nb\na\n", "2 2\n", 0},
+		{[]string{"BEGIN {n = split(\"a:b:c\", p, \":\"); print n, p[3]}"}, "", "3 c\n", 0},
+		{[]string{"BEGIN {a[1]; if (1 in a) print \"in\"; delete a[1]; if (!(1 in a)) print \"out\"}"}, "", "in\nout\n", 0},
+		{[]string{"{a[NR] = $0} END {for (i = NR; i > 0; i--) print a[i]}"}, "x\ny\nz\n", "z\ny\nx\n", 0},
+		// Regex matching
+		{[]string{"/bash$/"}, passwd, "root:x:0:0:root:/root:/bin/bash\nubuntu:x:1000:1000:Ubuntu:/home/ubuntu:/bin/bash\n", 0},
+		{[]string{"-F:", "$7 !~ /nologin/ {print $1}", "/etc/passwd"}, "", "root\nubuntu\n", 0},
+		{[]string{"$1 ~ /^[0-9]+$/ {print \"num\"; next} {print \"str\"}"}, "12\nab\n", "num\nstr\n", 0},
+		{[]string{"{gsub(/o/, \"0\"); print}"}, "foo boo\n", "f00 b00\n", 0},
+		{[]string{"{sub(/[0-9]+/, \"<&>\"); print}"}, "ab12cd34\n", "ab<12>cd34\n", 0},
+		{[]string{"match($0, /[0-9]+/) {print RSTART, RLENGTH}"}, "ab123c\n", "3 3\n", 0},
+		{[]string{"/start/,/stop/"}, "a\nstart\nb\nstop\nc\n", "start\nb\nstop\n", 0},
+		// Errors
+		{[]string{"-F:", "{print $1}", "/nonexistent"}, "", "", 2},
+		{[]string{"{print $1"}, "", "", 2},
+	}
+	for _, test := range tests {
+		sys := newTestSys(test.stdin)
+		afero.WriteFile(sys.FSys(), "/etc/passwd", []byte(passwd), 0644)
+		status := sys.run(awk{"/usr/bin/awk"}, test.args...)
+		if out := sys.out.String(); out != test.expect || status != test.status {
+			t.Errorf("awk %q, expect %q with status %v, got %q with status %v (%q)",
+				test.args, test.expect, test.status, out, status, sys.err.String())
+		}
+	}
+}
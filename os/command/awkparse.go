@@ -0,0 +1,919 @@
+package command
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Tokens of awk program
+const (
+	awkTokEOF = iota
+	awkTokNewline
+	awkTokNumber
+	awkTokString
+	awkTokERE
+	awkTokName
+	awkTokFuncName
+	awkTokBuiltin
+	awkTokKeyword
+	awkTokPunct
+)
+
+type awkToken struct {
+	kind int
+	s    string
+	n    float64
+	line int
+}
+
+var awkKeywords = map[string]bool{
+	"BEGIN": true, "END": true, "function": true, "func": true, "if": true, "else": true,
+	"while": true, "for": true, "do": true, "break": true, "continue": true, "next": true,
+	"nextfile": true, "exit": true, "return": true, "delete": true, "in": true,
+	"getline": true, "print": true, "printf": true,
+}
+
+var awkBuiltins = map[string]bool{
+	"length": true, "substr": true, "index": true, "split": true, "sub": true, "gsub": true,
+	"match": true, "sprintf": true, "sin": true, "cos": true, "atan2": true, "exp": true,
+	"log": true, "sqrt": true, "int": true, "rand": true, "srand": true, "tolower": true,
+	"toupper": true, "system": true, "close": true, "fflush": true,
+}
+
+type awkSyntaxError struct {
+	line int
+	near string
+}
+
+func (e awkSyntaxError) Error() string {
+	return fmt.Sprintf("line %v: syntax error at or near %v", e.line, e.near)
+}
+
+// awkLex splits the program into tokens. Whether / starts a regexp or
+// divides depends on the token before it
+func awkLex(src string) ([]awkToken, error) {
+	var toks []awkToken
+	line := 1
+	operand := func() bool {
+		if len(toks) == 0 {
+			return false
+		}
+		t := toks[len(toks)-1]
+		switch t.kind {
+		case awkTokNumber, awkTokString, awkTokERE, awkTokName, awkTokBuiltin:
+			return true
+		case awkTokPunct:
+			return t.s == ")" || t.s == "]" || t.s == "$" || t.s == "++" || t.s == "--"
+		}
+		return false
+	}
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '\\' && i+1 < len(src) && src[i+1] == '\n':
+			i += 2
+			line++
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == '\n':
+			toks = append(toks, awkToken{kind: awkTokNewline, s: "end of line", line: line})
+			line++
+			i++
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9':
+			m := awkNumRE.FindString(src[i:])
+			n, _ := strconv.ParseFloat(m, 64)
+			if strings.HasPrefix(m, "0x") || strings.HasPrefix(m, "0X") {
+				v, _ := strconv.ParseInt(m[2:], 16, 64)
+				n = float64(v)
+			}
+			toks = append(toks, awkToken{kind: awkTokNumber, s: m, n: n, line: line})
+			i += len(m)
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i
+			for j < len(src) && (src[j] == '_' || src[j] >= 'a' && src[j] <= 'z' || src[j] >= 'A' && src[j] <= 'Z' || src[j] >= '0' && src[j] <= '9') {
+				j++
+			}
+			word := src[i:j]
+			kind := awkTokName
+			switch {
+			case awkKeywords[word]:
+				kind = awkTokKeyword
+				if word == "func" {
+					word = "function"
+				}
+			case awkBuiltins[word]:
+				kind = awkTokBuiltin
+			case j < len(src) && src[j] == '(':
+				kind = awkTokFuncName
+			}
+			toks = append(toks, awkToken{kind: kind, s: word, line: line})
+			i = j
+		case c == '"':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(src) && src[j] != '"'; j++ {
+				if src[j] == '\n' {
+					return nil, awkSyntaxError{line, "runaway string constant \"" + b.String()}
+				}
+				if src[j] == '\\' && j+1 < len(src) {
+					j++
+					n, esc := awkEscape(src[j:])
+					b.WriteString(esc)
+					j += n - 1
+					continue
+				}
+				b.WriteByte(src[j])
+			}
+			if j >= len(src) {
+				return nil, awkSyntaxError{line, "runaway string constant \"" + b.String()}
+			}
+			toks = append(toks, awkToken{kind: awkTokString, s: b.String(), line: line})
+			i = j + 1
+		case c == '/' && !operand():
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(src) && src[j] != '/'; j++ {
+				if src[j] == '\n' {
+					return nil, awkSyntaxError{line, "runaway regular expression /" + b.String()}
+				}
+				if src[j] == '\\' && j+1 < len(src) {
+					j++
+					if src[j] != '/' {
+						b.WriteByte('\\')
+					}
+				}
+				b.WriteByte(src[j])
+			}
+			if j >= len(src) {
+				return nil, awkSyntaxError{line, "runaway regular expression /" + b.String()}
+			}
+			toks = append(toks, awkToken{kind: awkTokERE, s: b.String(), line: line})
+			i = j + 1
+		default:
+			op := string(c)
+			for _, p := range []string{"**=", "+=", "-=", "*=", "/=", "%=", "^=", "**", "==", "<=", ">=", "!=", "!~", "++", "--", "&&", "||", ">>"} {
+				if strings.HasPrefix(src[i:], p) {
+					op = p
+					break
+				}
+			}
+			if strings.IndexByte("{}()[];,+-*/%^!<>|&?:~$=", c) < 0 {
+				return nil, awkSyntaxError{line, op}
+			}
+			i += len(op)
+			// ** is the same as ^ in gawk and mawk
+			op = strings.Replace(op, "**", "^", 1)
+			toks = append(toks, awkToken{kind: awkTokPunct, s: op, line: line})
+		}
+	}
+	toks = append(toks, awkToken{kind: awkTokEOF, s: "end of file", line: line})
+	return toks, nil
+}
+
+var awkNumRE = regexp.MustCompile(`^(0[xX][0-9a-fA-F]+|([0-9]+\.?[0-9]*|\.[0-9]+)([eE][-+]?[0-9]+)?)`)
+
+// awkEscape decodes the escape sequence after backslash, returning the
+// number of bytes used
+func awkEscape(s string) (int, string) {
+	switch s[0] {
+	case 'n':
+		return 1, "\n"
+	case 't':
+		return 1, "\t"
+	case 'r':
+		return 1, "\r"
+	case 'a':
+		return 1, "\a"
+	case 'b':
+		return 1, "\b"
+	case 'f':
+		return 1, "\f"
+	case 'v':
+		return 1, "\v"
+	case '"', '\\', '/':
+		return 1, s[:1]
+	}
+	if s[0] >= '0' && s[0] <= '7' {
+		n := 1
+		for n < len(s) && n < 3 && s[n] >= '0' && s[n] <= '7' {
+			n++
+		}
+		v, _ := strconv.ParseUint(s[:n], 8, 8)
+		return n, string([]byte{byte(v)})
+	}
+	return 1, "\\" + s[:1]
+}
+
+// AST of awk program
+type (
+	awkExpr interface{}
+	awkStmt interface{}
+
+	awkNum    struct{ v float64 }
+	awkStr    struct{ v string }
+	awkRegex  struct{ re *regexp.Regexp }
+	awkVarRef struct{ name string }
+	awkIndex  struct {
+		name string
+		subs []awkExpr
+	}
+	awkField struct{ idx awkExpr }
+	awkGroup struct{ list []awkExpr }
+	awkUnary struct {
+		op string
+		e  awkExpr
+	}
+	awkBinary struct {
+		op   string
+		l, r awkExpr
+	}
+	awkAssign struct {
+		op     string
+		lv, rv awkExpr
+	}
+	awkCond struct{ c, a, b awkExpr }
+	awkIncr struct {
+		op  string
+		pre bool
+		lv  awkExpr
+	}
+	awkIn struct {
+		subs []awkExpr
+		name string
+	}
+	awkMatch struct {
+		neg  bool
+		l, r awkExpr
+	}
+	awkCall struct {
+		name    string
+		args    []awkExpr
+		builtin bool
+	}
+	// awkGetline reads the record from main input, file with < or command
+	// with |
+	awkGetline struct {
+		lv  awkExpr
+		op  string
+		src awkExpr
+	}
+
+	awkBlock struct{ stmts []awkStmt }
+	awkPrint struct {
+		printf bool
+		args   []awkExpr
+		redir  string
+		dest   awkExpr
+	}
+	awkIf struct {
+		c         awkExpr
+		then, els awkStmt
+	}
+	awkWhile struct {
+		c    awkExpr
+		body awkStmt
+		do   bool
+	}
+	awkFor struct {
+		init, post awkStmt
+		c          awkExpr
+		body       awkStmt
+	}
+	awkForIn struct {
+		name, arr string
+		body      awkStmt
+	}
+	awkExprStmt struct{ e awkExpr }
+	awkSimple   struct{ keyword string }
+	awkExit     struct {
+		keyword string
+		e       awkExpr
+	}
+	awkDelete struct {
+		name string
+		subs []awkExpr
+	}
+)
+
+type awkRule struct {
+	begin, end bool
+	pattern    awkExpr
+	pattern2   awkExpr
+	body       *awkBlock
+	active     bool
+}
+
+type awkFunc struct {
+	name   string
+	params []string
+	body   *awkBlock
+}
+
+type awkProgram struct {
+	begin, rules, end []*awkRule
+	funcs             map[string]*awkFunc
+}
+
+type awkParser struct {
+	toks []awkToken
+	pos  int
+	// noGT is set while parsing the arguments of print, where > redirects
+	// the output instead of comparing
+	noGT bool
+}
+
+func (p *awkParser) tok() awkToken { return p.toks[p.pos] }
+
+func (p *awkParser) is(s string) bool {
+	t := p.toks[p.pos]
+	return (t.kind == awkTokPunct || t.kind == awkTokKeyword) && t.s == s
+}
+
+func (p *awkParser) fail() {
+	t := p.tok()
+	near := t.s
+	switch t.kind {
+	case awkTokString:
+		near = "\"" + t.s + "\""
+	case awkTokERE:
+		near = "/" + t.s + "/"
+	case awkTokEOF:
+		near = "end of file"
+	case awkTokNewline:
+		near = "end of line"
+	}
+	panic(awkSyntaxError{t.line, near})
+}
+
+func (p *awkParser) expect(s string) {
+	if !p.is(s) {
+		p.fail()
+	}
+	p.pos++
+}
+
+func (p *awkParser) optNewlines() {
+	for p.tok().kind == awkTokNewline {
+		p.pos++
+	}
+}
+
+// awkParse compiles the program, reporting syntax errors like mawk does
+func awkParse(src string) (prog *awkProgram, err error) {
+	toks, err := awkLex(src)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(awkSyntaxError)
+			if !ok {
+				panic(r)
+			}
+			prog, err = nil, e
+		}
+	}()
+	p := &awkParser{toks: toks}
+	prog = &awkProgram{funcs: map[string]*awkFunc{}}
+	for {
+		for p.tok().kind == awkTokNewline || p.is(";") {
+			p.pos++
+		}
+		if p.tok().kind == awkTokEOF {
+			break
+		}
+		switch {
+		case p.is("BEGIN"):
+			p.pos++
+			prog.begin = append(prog.begin, &awkRule{begin: true, body: p.block()})
+		case p.is("END"):
+			p.pos++
+			prog.end = append(prog.end, &awkRule{end: true, body: p.block()})
+		case p.is("function"):
+			p.pos++
+			name := p.tok()
+			if name.kind != awkTokName && name.kind != awkTokFuncName {
+				p.fail()
+			}
+			p.pos++
+			f := &awkFunc{name: name.s}
+			p.expect("(")
+			for !p.is(")") {
+				if p.tok().kind != awkTokName {
+					p.fail()
+				}
+				f.params = append(f.params, p.tok().s)
+				p.pos++
+				if p.is(",") {
+					p.pos++
+					p.optNewlines()
+				} else if !p.is(")") {
+					p.fail()
+				}
+			}
+			p.pos++
+			p.optNewlines()
+			f.body = p.block()
+			prog.funcs[f.name] = f
+		default:
+			r := &awkRule{}
+			if !p.is("{") {
+				r.pattern = p.expr()
+				if p.is(",") {
+					p.pos++
+					p.optNewlines()
+					r.pattern2 = p.expr()
+				}
+			}
+			if p.is("{") {
+				r.body = p.block()
+			}
+			prog.rules = append(prog.rules, r)
+		}
+	}
+	return prog, nil
+}
+
+func (p *awkParser) block() *awkBlock {
+	p.expect("{")
+	b := &awkBlock{}
+	for {
+		for p.tok().kind == awkTokNewline || p.is(";") {
+			p.pos++
+		}
+		if p.is("}") {
+			p.pos++
+			return b
+		}
+		b.stmts = append(b.stmts, p.stmt())
+	}
+}
+
+// end consumes the terminator of simple statement
+func (p *awkParser) end() {
+	switch {
+	case p.is(";") || p.tok().kind == awkTokNewline:
+		p.pos++
+	case p.is("}") || p.tok().kind == awkTokEOF:
+	default:
+		p.fail()
+	}
+}
+
+func (p *awkParser) stmt() awkStmt {
+	switch {
+	case p.is("{"):
+		return p.block()
+	case p.is(";"):
+		p.pos++
+		return &awkBlock{}
+	case p.is("if"):
+		p.pos++
+		p.expect("(")
+		s := &awkIf{c: p.expr()}
+		p.expect(")")
+		p.optNewlines()
+		s.then = p.stmt()
+		save := p.pos
+		for p.tok().kind == awkTokNewline || p.is(";") {
+			p.pos++
+		}
+		if p.is("else") {
+			p.pos++
+			p.optNewlines()
+			s.els = p.stmt()
+		} else {
+			p.pos = save
+		}
+		return s
+	case p.is("while"):
+		p.pos++
+		p.expect("(")
+		s := &awkWhile{c: p.expr()}
+		p.expect(")")
+		if p.is(";") {
+			p.pos++
+			s.body = &awkBlock{}
+			return s
+		}
+		p.optNewlines()
+		s.body = p.stmt()
+		return s
+	case p.is("do"):
+		p.pos++
+		p.optNewlines()
+		s := &awkWhile{do: true, body: p.stmt()}
+		for p.tok().kind == awkTokNewline || p.is(";") {
+			p.pos++
+		}
+		p.expect("while")
+		p.expect("(")
+		s.c = p.expr()
+		p.expect(")")
+		p.end()
+		return s
+	case p.is("for"):
+		p.pos++
+		p.expect("(")
+		if p.tok().kind == awkTokName && p.toks[p.pos+1].s == "in" && p.toks[p.pos+2].kind == awkTokName && p.toks[p.pos+3].s == ")" {
+			s := &awkForIn{name: p.tok().s, arr: p.toks[p.pos+2].s}
+			p.pos += 4
+			p.optNewlines()
+			s.body = p.stmt()
+			return s
+		}
+		s := &awkFor{}
+		if !p.is(";") {
+			s.init = p.simple()
+		}
+		p.expect(";")
+		p.optNewlines()
+		if !p.is(";") {
+			s.c = p.expr()
+		}
+		p.expect(";")
+		p.optNewlines()
+		if !p.is(")") {
+			s.post = p.simple()
+		}
+		p.expect(")")
+		if p.is(";") {
+			p.pos++
+			s.body = &awkBlock{}
+			return s
+		}
+		p.optNewlines()
+		s.body = p.stmt()
+		return s
+	}
+	s := p.simple()
+	p.end()
+	return s
+}
+
+// simple parses the statements that may be terminated by ;
+func (p *awkParser) simple() awkStmt {
+	t := p.tok()
+	if t.kind == awkTokKeyword {
+		switch t.s {
+		case "print", "printf":
+			p.pos++
+			s := &awkPrint{printf: t.s == "printf"}
+			p.noGT = true
+			if !p.is(";") && !p.is("}") && !p.is(">") && !p.is(">>") && !p.is("|") && p.tok().kind != awkTokNewline && p.tok().kind != awkTokEOF {
+				s.args = p.exprList()
+			}
+			p.noGT = false
+			if len(s.args) == 1 {
+				if g, ok := s.args[0].(*awkGroup); ok {
+					s.args = g.list
+				}
+			}
+			if s.printf && len(s.args) == 0 {
+				p.fail()
+			}
+			if p.is(">") || p.is(">>") || p.is("|") {
+				s.redir = p.tok().s
+				p.pos++
+				s.dest = p.concat()
+			}
+			return s
+		case "next", "nextfile", "break", "continue":
+			p.pos++
+			return &awkSimple{keyword: t.s}
+		case "exit", "return":
+			p.pos++
+			s := &awkExit{keyword: t.s}
+			if !p.is(";") && !p.is("}") && p.tok().kind != awkTokNewline && p.tok().kind != awkTokEOF {
+				s.e = p.expr()
+			}
+			return s
+		case "delete":
+			p.pos++
+			if p.tok().kind != awkTokName {
+				p.fail()
+			}
+			s := &awkDelete{name: p.tok().s}
+			p.pos++
+			if p.is("[") {
+				p.pos++
+				s.subs = p.exprList()
+				p.expect("]")
+			}
+			return s
+		}
+	}
+	return &awkExprStmt{p.expr()}
+}
+
+func (p *awkParser) exprList() []awkExpr {
+	list := []awkExpr{p.expr()}
+	for p.is(",") {
+		p.pos++
+		p.optNewlines()
+		list = append(list, p.expr())
+	}
+	return list
+}
+
+func awkIsLvalue(e awkExpr) bool {
+	switch e.(type) {
+	case *awkVarRef, *awkIndex, *awkField:
+		return true
+	}
+	return false
+}
+
+func (p *awkParser) expr() awkExpr {
+	e := p.ternary()
+	t := p.tok()
+	if t.kind == awkTokPunct && awkIsLvalue(e) {
+		switch t.s {
+		case "=", "+=", "-=", "*=", "/=", "%=", "^=":
+			p.pos++
+			p.optNewlines()
+			return &awkAssign{op: t.s, lv: e, rv: p.expr()}
+		}
+	}
+	return e
+}
+
+func (p *awkParser) ternary() awkExpr {
+	c := p.or()
+	if !p.is("?") {
+		return c
+	}
+	p.pos++
+	p.optNewlines()
+	a := p.expr()
+	p.optNewlines()
+	p.expect(":")
+	p.optNewlines()
+	return &awkCond{c, a, p.expr()}
+}
+
+func (p *awkParser) or() awkExpr {
+	e := p.and()
+	for p.is("||") {
+		p.pos++
+		p.optNewlines()
+		e = &awkBinary{"||", e, p.and()}
+	}
+	return e
+}
+
+func (p *awkParser) and() awkExpr {
+	e := p.in()
+	for p.is("&&") {
+		p.pos++
+		p.optNewlines()
+		e = &awkBinary{"&&", e, p.in()}
+	}
+	return e
+}
+
+func (p *awkParser) in() awkExpr {
+	e := p.match()
+	for p.is("in") {
+		p.pos++
+		if p.tok().kind != awkTokName {
+			p.fail()
+		}
+		subs := []awkExpr{e}
+		if g, ok := e.(*awkGroup); ok {
+			subs = g.list
+		}
+		e = &awkIn{subs, p.tok().s}
+		p.pos++
+	}
+	return e
+}
+
+func (p *awkParser) match() awkExpr {
+	e := p.rel()
+	for p.is("~") || p.is("!~") {
+		neg := p.is("!~")
+		p.pos++
+		e = &awkMatch{neg, e, p.rel()}
+	}
+	return e
+}
+
+func (p *awkParser) rel() awkExpr {
+	e := p.pipe()
+	for _, op := range []string{"<", "<=", "!=", "==", ">", ">="} {
+		if p.is(op) && !(op == ">" && p.noGT) {
+			p.pos++
+			return &awkBinary{op, e, p.pipe()}
+		}
+	}
+	return e
+}
+
+// pipe parses "cmd" | getline, which binds looser than concatenation
+func (p *awkParser) pipe() awkExpr {
+	e := p.concat()
+	for p.is("|") && p.toks[p.pos+1].s == "getline" && p.toks[p.pos+1].kind == awkTokKeyword {
+		p.pos += 2
+		g := &awkGetline{op: "|", src: e}
+		if p.startsLvalue() {
+			g.lv = p.primary()
+		}
+		e = g
+	}
+	return e
+}
+
+func (p *awkParser) startsLvalue() bool {
+	t := p.tok()
+	return t.kind == awkTokName || t.kind == awkTokPunct && t.s == "$"
+}
+
+// startsConcat tells if the token starts an operand of concatenation. + and
+// - are taken as binary operators like other awks do
+func (p *awkParser) startsConcat() bool {
+	t := p.tok()
+	switch t.kind {
+	case awkTokNumber, awkTokString, awkTokERE, awkTokName, awkTokFuncName, awkTokBuiltin:
+		return true
+	case awkTokKeyword:
+		return t.s == "getline"
+	case awkTokPunct:
+		return t.s == "$" || t.s == "(" || t.s == "!" || t.s == "++" || t.s == "--"
+	}
+	return false
+}
+
+func (p *awkParser) concat() awkExpr {
+	e := p.additive()
+	for p.startsConcat() && !(p.is("in")) {
+		e = &awkBinary{" ", e, p.additive()}
+	}
+	return e
+}
+
+func (p *awkParser) additive() awkExpr {
+	e := p.mul()
+	for p.is("+") || p.is("-") {
+		op := p.tok().s
+		p.pos++
+		e = &awkBinary{op, e, p.mul()}
+	}
+	return e
+}
+
+func (p *awkParser) mul() awkExpr {
+	e := p.unary()
+	for p.is("*") || p.is("/") || p.is("%") {
+		op := p.tok().s
+		p.pos++
+		e = &awkBinary{op, e, p.unary()}
+	}
+	return e
+}
+
+func (p *awkParser) unary() awkExpr {
+	if p.is("!") || p.is("-") || p.is("+") {
+		op := p.tok().s
+		p.pos++
+		return &awkUnary{op, p.unary()}
+	}
+	return p.pow()
+}
+
+func (p *awkParser) pow() awkExpr {
+	e := p.postfix()
+	if p.is("^") {
+		p.pos++
+		// Exponent is right associative and takes unary minus like 2^-1
+		if p.is("-") || p.is("+") || p.is("!") {
+			op := p.tok().s
+			p.pos++
+			return &awkBinary{"^", e, &awkUnary{op, p.pow()}}
+		}
+		return &awkBinary{"^", e, p.pow()}
+	}
+	return e
+}
+
+func (p *awkParser) postfix() awkExpr {
+	e := p.primary()
+	if awkIsLvalue(e) && (p.is("++") || p.is("--")) {
+		op := p.tok().s
+		p.pos++
+		return &awkIncr{op: op, lv: e}
+	}
+	return e
+}
+
+func (p *awkParser) primary() awkExpr {
+	t := p.tok()
+	p.pos++
+	switch t.kind {
+	case awkTokNumber:
+		return &awkNum{t.n}
+	case awkTokString:
+		return &awkStr{t.s}
+	case awkTokERE:
+		re, err := regexp.Compile(grepConvert(t.s, true))
+		if err != nil {
+			p.pos--
+			panic(awkSyntaxError{t.line, "regular expression compile failed (" + t.s + ")"})
+		}
+		return &awkRegex{re}
+	case awkTokName:
+		if p.is("[") {
+			p.pos++
+			subs := p.exprList()
+			p.expect("]")
+			return &awkIndex{t.s, subs}
+		}
+		return &awkVarRef{t.s}
+	case awkTokFuncName:
+		p.expect("(")
+		return &awkCall{name: t.s, args: p.args()}
+	case awkTokBuiltin:
+		if !p.is("(") {
+			if t.s != "length" {
+				p.pos--
+				p.fail()
+			}
+			return &awkCall{name: t.s, builtin: true}
+		}
+		p.pos++
+		return &awkCall{name: t.s, args: p.args(), builtin: true}
+	case awkTokKeyword:
+		if t.s == "getline" {
+			g := &awkGetline{}
+			if p.startsLvalue() {
+				g.lv = p.primary()
+			}
+			if p.is("<") {
+				p.pos++
+				g.op, g.src = "<", p.primary()
+			}
+			return g
+		}
+	case awkTokPunct:
+		switch t.s {
+		case "$":
+			if p.is("++") || p.is("--") || p.is("-") {
+				op := p.tok().s
+				p.pos++
+				if op == "-" {
+					return &awkField{&awkUnary{op, p.primary()}}
+				}
+				return &awkField{&awkIncr{op: op, pre: true, lv: p.primary()}}
+			}
+			return &awkField{p.primary()}
+		case "++", "--":
+			lv := p.primary()
+			if !awkIsLvalue(lv) {
+				p.pos--
+				p.fail()
+			}
+			return &awkIncr{op: t.s, pre: true, lv: lv}
+		case "-", "+", "!":
+			return &awkUnary{t.s, p.unary()}
+		case "(":
+			noGT := p.noGT
+			p.noGT = false
+			p.optNewlines()
+			list := p.exprList()
+			p.optNewlines()
+			p.expect(")")
+			p.noGT = noGT
+			if len(list) == 1 {
+				return &awkGroup{list}
+			}
+			if !p.is("in") && !p.noGT {
+				p.fail()
+			}
+			return &awkGroup{list}
+		}
+	}
+	p.pos--
+	p.fail()
+	return nil
+}
+
+func (p *awkParser) args() []awkExpr {
+	p.optNewlines()
+	if p.is(")") {
+		p.pos++
+		return nil
+	}
+	noGT := p.noGT
+	p.noGT = false
+	list := p.exprList()
+	p.noGT = noGT
+	p.optNewlines()
+	p.expect(")")
+	return list
+}
@@ -0,0 +1,131 @@
+package command
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/pflag"
+)
+
+// base64Cmd encodes and decodes base64. What is decoded is logged, as
+// payloads are often passed encoded to get them past the shell
+type base64Cmd struct{}
+
+func init() {
+	honeyos.RegisterCommand("base64", base64Cmd{})
+}
+
+func (base64Cmd) GetHelp() string {
+	return "Usage: base64 [OPTION]... [FILE]\nBase64 encode or decode FILE, or standard input, to standard output.\n\n" +
+		"With no FILE, or when FILE is -, read standard input.\n\n" +
+		"Mandatory arguments to long options are mandatory for short options too.\n" +
+		"  -d, --decode          decode data\n" +
+		"  -i, --ignore-garbage  when decoding, ignore non-alphabet characters\n" +
+		"  -w, --wrap=COLS       wrap encoded lines after COLS character (default 76).\n" +
+		"                          Use 0 to disable line wrapping\n\n" +
+		"      --help     display this help and exit\n" +
+		"      --version  output version information and exit\n"
+}
+
+func (base64Cmd) Where() string {
+	return "/usr/bin/base64"
+}
+
+func (b base64Cmd) Exec(args []string, sys honeyos.Sys) int {
+	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	decode := flag.BoolP("decode", "d", false, "")
+	garbage := flag.BoolP("ignore-garbage", "i", false, "")
+	wrap := flag.StringP("wrap", "w", "76", "")
+	help := flag.Bool("help", false, "")
+	if err := flag.Parse(args); err != nil {
+		msg := strings.Replace(err.Error(), "unknown shorthand flag: ", "invalid option -- ", 1)
+		if i := strings.Index(msg, " in -"); i >= 0 {
+			msg = msg[:i]
+		}
+		fmt.Fprintf(sys.Err(), "base64: %v\nTry 'base64 --help' for more information.\n", msg)
+		return 1
+	}
+	if *help {
+		fmt.Fprint(sys.Out(), b.GetHelp())
+		return 0
+	}
+	cols, err := strconv.Atoi(*wrap)
+	if err != nil || cols < 0 {
+		fmt.Fprintf(sys.Err(), "base64: invalid wrap size: ‘%v’\n", *wrap)
+		return 1
+	}
+	files := flag.Args()
+	if len(files) > 1 {
+		fmt.Fprintf(sys.Err(), "base64: extra operand ‘%v’\nTry 'base64 --help' for more information.\n", files[1])
+		return 1
+	}
+	name := "-"
+	if len(files) == 1 {
+		name = files[0]
+	}
+	var data []byte
+	if name == "-" {
+		data, _ = ioutil.ReadAll(sys.In())
+	} else {
+		p := absPath(sys, name)
+		if fi, err := sys.FSys().Stat(p); err == nil && fi.IsDir() {
+			fmt.Fprintln(sys.Err(), "base64: read error: Is a directory")
+			return 1
+		}
+		if data, err = readFile(sys, p); err != nil {
+			reason := "No such file or directory"
+			if os.IsPermission(err) {
+				reason = "Permission denied"
+			}
+			fmt.Fprintf(sys.Err(), "base64: %v: %v\n", name, reason)
+			return 1
+		}
+	}
+	if !*decode {
+		enc := base64.StdEncoding.EncodeToString(data)
+		if cols > 0 {
+			for len(enc) > cols {
+				fmt.Fprintln(sys.Out(), enc[:cols])
+				enc = enc[cols:]
+			}
+		}
+		if enc != "" {
+			fmt.Fprintln(sys.Out(), enc)
+		}
+		return 0
+	}
+	const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/="
+	in := strings.Map(func(r rune) rune {
+		if r == '\n' || *garbage && !strings.ContainsRune(alphabet, r) {
+			return -1
+		}
+		return r
+	}, string(data))
+	out := make([]byte, base64.StdEncoding.DecodedLen(len(in)))
+	n, err := base64.StdEncoding.Decode(out, []byte(in))
+	out = out[:n]
+	if err != nil {
+		// What is decoded before the bad input is still printed
+		if len(in)%4 != 0 {
+			padded := in + strings.Repeat("=", 4-len(in)%4)
+			out = make([]byte, base64.StdEncoding.DecodedLen(len(padded)))
+			n, _ = base64.StdEncoding.Decode(out, []byte(padded))
+			out = out[:n]
+		}
+	}
+	if len(out) > 0 {
+		sys.Log().WithField("decoded", string(out)).Infof("User decoded %v bytes with base64", len(out))
+	}
+	sys.Out().Write(out)
+	if err != nil {
+		fmt.Fprintln(sys.Err(), "base64: invalid input")
+		return 1
+	}
+	return 0
+}
@@ -21,26 +21,34 @@ func (c cat) GetHelp() string {
 
 func (c cat) Exec(args []string, sys honeyos.Sys) int {
 	if len(args) == 0 {
+		io.Copy(sys.Out(), sys.In())
 		return 0
 	}
-	filePath := args[0]
-	if !path.IsAbs(filePath) {
-		filePath = path.Join(sys.Getcwd(), filePath)
-	}
-	f, err := sys.FSys().OpenFile(filePath, os.O_RDONLY, os.ModeType)
-	if err != nil {
-		if err == os.ErrNotExist {
-			fmt.Fprintf(sys.Out(), "cat: %v: No such file or directory\n", args[0])
-			return 1
-		} else if err == os.ErrPermission {
-			fmt.Fprintf(sys.Out(), "cat: %v: Permission denied\n", args[0])
-			return 1
+	res := 0
+	for _, arg := range args {
+		if arg == "-" {
+			io.Copy(sys.Out(), sys.In())
+			continue
+		}
+		filePath := arg
+		if !path.IsAbs(filePath) {
+			filePath = path.Join(sys.Getcwd(), filePath)
+		}
+		data, err := readFile(sys, filePath)
+		if err != nil {
+			if os.IsPermission(err) {
+				fmt.Fprintf(sys.Err(), "cat: %v: Permission denied\n", arg)
+			} else {
+				fmt.Fprintf(sys.Err(), "cat: %v: No such file or directory\n", arg)
+			}
+			res = 1
+			continue
 		}
+		sys.Out().Write(data)
 	}
-	io.Copy(sys.Out(), f)
-	return 0
+	return res
 }
 
 func (c cat) Where() string {
-	return "/bin/pwd"
+	return "/bin/cat"
 }
@@ -0,0 +1,277 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/afero"
+)
+
+type chmod struct{}
+
+// chmodOptions are the options of chmod, chown and chgrp
+type chmodOptions struct {
+	recursive bool
+	verbose   bool
+	changes   bool
+	silent    bool
+}
+
+func init() {
+	honeyos.RegisterCommand("chmod", chmod{})
+}
+
+func (chmod) GetHelp() string {
+	return ""
+}
+
+func (chmod) Where() string {
+	return "/bin/chmod"
+}
+
+func (c chmod) Exec(args []string, sys honeyos.Sys) int {
+	var opt chmodOptions
+	var operands []string
+	mode := ""
+	for i, arg := range args {
+		if arg == "--" {
+			operands = append(operands, args[i+1:]...)
+			break
+		}
+		switch {
+		case arg == "-R", arg == "--recursive":
+			opt.recursive = true
+		case arg == "-v", arg == "--verbose":
+			opt.verbose = true
+		case arg == "-c", arg == "--changes":
+			opt.changes = true
+		case arg == "-f", arg == "--silent", arg == "--quiet":
+			opt.silent = true
+		case strings.HasPrefix(arg, "-") && mode == "" && strings.Trim(arg[1:], "rwxXst") == "" && len(arg) > 1:
+			// Modes like -x are not options
+			mode = arg
+		case strings.HasPrefix(arg, "--"):
+			fmt.Fprintf(sys.Err(), "chmod: unrecognized option '%v'\nTry 'chmod --help' for more information.\n", arg)
+			return 1
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			ok := true
+			for _, f := range arg[1:] {
+				switch f {
+				case 'R':
+					opt.recursive = true
+				case 'v':
+					opt.verbose = true
+				case 'c':
+					opt.changes = true
+				case 'f':
+					opt.silent = true
+				default:
+					ok = false
+				}
+			}
+			if !ok {
+				fmt.Fprintf(sys.Err(), "chmod: invalid option -- '%c'\nTry 'chmod --help' for more information.\n", arg[1])
+				return 1
+			}
+		case mode == "":
+			mode = arg
+		default:
+			operands = append(operands, arg)
+		}
+	}
+	if mode == "" {
+		fmt.Fprintln(sys.Err(), "chmod: missing operand\nTry 'chmod --help' for more information.")
+		return 1
+	}
+	if len(operands) == 0 {
+		fmt.Fprintf(sys.Err(), "chmod: missing operand after ‘%v’\nTry 'chmod --help' for more information.\n", mode)
+		return 1
+	}
+	if _, ok := chmodMode(mode, 0, 0, false); !ok {
+		fmt.Fprintf(sys.Err(), "chmod: invalid mode: ‘%v’\nTry 'chmod --help' for more information.\n", mode)
+		return 1
+	}
+	sys.Log().WithField("mode", mode).WithField("files", operands).Info("User changed file mode")
+	res := 0
+	for _, name := range operands {
+		ok, err := chmodWalk(sys, name, opt.recursive, func(p, shown string, fi os.FileInfo) bool {
+			old := fi.Mode()
+			m, _ := chmodMode(mode, old, sys.Umask(), fi.IsDir())
+			if err := sys.FSys().Chmod(p, m); err != nil {
+				if !opt.silent {
+					fmt.Fprintf(sys.Err(), "chmod: changing permissions of '%v': Operation not permitted\n", shown)
+				}
+				return false
+			}
+			switch {
+			case m != old && (opt.verbose || opt.changes):
+				fmt.Fprintf(sys.Out(), "mode of '%v' changed from %04o (%v) to %04o (%v)\n", shown, unixMode(old),
+					permString(old), unixMode(m), permString(m))
+			case opt.verbose:
+				fmt.Fprintf(sys.Out(), "mode of '%v' retained as %04o (%v)\n", shown, unixMode(m), permString(m))
+			}
+			return true
+		})
+		if err != nil {
+			if !opt.silent {
+				fmt.Fprintf(sys.Err(), "chmod: cannot access '%v': No such file or directory\n", name)
+			}
+			res = 1
+		} else if !ok {
+			res = 1
+		}
+	}
+	return res
+}
+
+// chmodWalk calls f on the file, and the files under it if recursive. ok is
+// false if f fails on any of them, and the error is for the file not found
+func chmodWalk(sys honeyos.Sys, name string, recursive bool, f func(p, shown string, fi os.FileInfo) bool) (ok bool, err error) {
+	p := absPath(sys, name)
+	fi, err := sys.FSys().Stat(p)
+	if err != nil {
+		return false, err
+	}
+	if !recursive || !fi.IsDir() {
+		return f(p, name, fi), nil
+	}
+	ok = true
+	afero.Walk(sys.FSys(), p, func(sub string, fi os.FileInfo, err error) error {
+		if err == nil && !f(sub, name+strings.TrimPrefix(sub, p), fi) {
+			ok = false
+		}
+		return nil
+	})
+	return ok, nil
+}
+
+// unixMode is the mode in the bits of chmod, like 4755 for setuid
+func unixMode(m os.FileMode) uint32 {
+	u := uint32(m.Perm())
+	if m&os.ModeSetuid != 0 {
+		u |= 04000
+	}
+	if m&os.ModeSetgid != 0 {
+		u |= 02000
+	}
+	if m&os.ModeSticky != 0 {
+		u |= 01000
+	}
+	return u
+}
+
+func fileMode(u uint32) os.FileMode {
+	m := os.FileMode(u & 0777)
+	if u&04000 != 0 {
+		m |= os.ModeSetuid
+	}
+	if u&02000 != 0 {
+		m |= os.ModeSetgid
+	}
+	if u&01000 != 0 {
+		m |= os.ModeSticky
+	}
+	return m
+}
+
+// permString is the permissions like ls shows, e.g. rwsr-xr-x
+func permString(m os.FileMode) string {
+	b := []byte("rwxrwxrwx")
+	for i := range b {
+		if m&(1<<uint(8-i)) == 0 {
+			b[i] = '-'
+		}
+	}
+	special := []struct {
+		mode os.FileMode
+		pos  int
+		c    byte
+	}{{os.ModeSetuid, 2, 's'}, {os.ModeSetgid, 5, 's'}, {os.ModeSticky, 8, 't'}}
+	for _, s := range special {
+		if m&s.mode == 0 {
+			continue
+		}
+		if b[s.pos] == '-' {
+			b[s.pos] = s.c - 'a' + 'A'
+		} else {
+			b[s.pos] = s.c
+		}
+	}
+	return string(b)
+}
+
+// chmodMode applies the mode given to chmod to the old one. Symbolic modes
+// without the users affected are masked by umask, like chmod does
+func chmodMode(spec string, old, umask os.FileMode, isDir bool) (os.FileMode, bool) {
+	if spec == "" {
+		return old, false
+	}
+	if n, err := strconv.ParseUint(spec, 8, 32); err == nil && len(spec) <= 4 {
+		return old&^(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky) | fileMode(uint32(n)), true
+	}
+	cur, mask := unixMode(old), uint32(umask.Perm())
+	for _, clause := range strings.Split(spec, ",") {
+		i, who := 0, uint32(0)
+		for ; i < len(clause) && strings.IndexByte("ugoa", clause[i]) >= 0; i++ {
+			who |= map[byte]uint32{'u': 04700, 'g': 02070, 'o': 01007, 'a': 07777}[clause[i]]
+		}
+		if i == len(clause) {
+			return old, false
+		}
+		for i < len(clause) {
+			op := clause[i]
+			if op != '+' && op != '-' && op != '=' {
+				return old, false
+			}
+			i++
+			var bits uint32
+			for ; i < len(clause) && strings.IndexByte("+-=", clause[i]) < 0; i++ {
+				switch clause[i] {
+				case 'r':
+					bits |= 0444
+				case 'w':
+					bits |= 0222
+				case 'x':
+					bits |= 0111
+				case 'X':
+					if isDir || cur&0111 != 0 {
+						bits |= 0111
+					}
+				case 's':
+					bits |= 06000
+				case 't':
+					bits |= 01000
+				case 'u':
+					bits |= (cur >> 6 & 7) * 0111
+				case 'g':
+					bits |= (cur >> 3 & 7) * 0111
+				case 'o':
+					bits |= (cur & 7) * 0111
+				default:
+					return old, false
+				}
+			}
+			affected := who
+			if who == 0 {
+				affected = 07777 &^ mask
+			}
+			bits &= affected
+			switch op {
+			case '+':
+				cur |= bits
+			case '-':
+				cur &^= bits
+			case '=':
+				clear := who
+				if who == 0 {
+					clear = 07777
+				}
+				cur = cur&^clear | bits
+			}
+		}
+	}
+	return old&^(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky) | fileMode(cur), true
+}
@@ -0,0 +1,190 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/mkishere/sshsyrup/virtualfs"
+)
+
+// chown changes the owner and group of files, or only the group as chgrp
+type chown struct {
+	name string
+}
+
+func init() {
+	honeyos.RegisterCommand("chown", chown{name: "chown"})
+	honeyos.RegisterCommand("chgrp", chown{name: "chgrp"})
+}
+
+func (chown) GetHelp() string {
+	return ""
+}
+
+func (c chown) Where() string {
+	return "/bin/" + c.name
+}
+
+func (c chown) Exec(args []string, sys honeyos.Sys) int {
+	var opt chmodOptions
+	var operands []string
+	for i, arg := range args {
+		if arg == "--" {
+			operands = append(operands, args[i+1:]...)
+			break
+		}
+		switch {
+		case arg == "--recursive":
+			opt.recursive = true
+		case arg == "--verbose":
+			opt.verbose = true
+		case arg == "--changes":
+			opt.changes = true
+		case arg == "--silent", arg == "--quiet":
+			opt.silent = true
+		case strings.HasPrefix(arg, "--"):
+			fmt.Fprintf(sys.Err(), "%v: unrecognized option '%v'\nTry '%v --help' for more information.\n", c.name, arg, c.name)
+			return 1
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			for _, f := range arg[1:] {
+				switch f {
+				case 'R':
+					opt.recursive = true
+				case 'v':
+					opt.verbose = true
+				case 'c':
+					opt.changes = true
+				case 'f':
+					opt.silent = true
+				case 'h', 'H', 'L', 'P':
+				default:
+					fmt.Fprintf(sys.Err(), "%v: invalid option -- '%c'\nTry '%v --help' for more information.\n", c.name, f, c.name)
+					return 1
+				}
+			}
+		default:
+			operands = append(operands, arg)
+		}
+	}
+	switch len(operands) {
+	case 0:
+		fmt.Fprintf(sys.Err(), "%v: missing operand\nTry '%v --help' for more information.\n", c.name, c.name)
+		return 1
+	case 1:
+		fmt.Fprintf(sys.Err(), "%v: missing operand after ‘%v’\nTry '%v --help' for more information.\n", c.name, operands[0], c.name)
+		return 1
+	}
+	spec := operands[0]
+	if c.name == "chgrp" {
+		spec = ":" + spec
+	}
+	uid, gid, ok := c.parseOwner(spec, sys)
+	if !ok {
+		return 1
+	}
+	sys.Log().WithField("owner", operands[0]).WithField("files", operands[1:]).Infof("User changed file owner with %v", c.name)
+	res := 0
+	for _, name := range operands[1:] {
+		ok, err := chmodWalk(sys, name, opt.recursive, func(p, shown string, fi os.FileInfo) bool {
+			oldUID, oldGID, _, _ := virtualfs.GetExtraInfo(fi)
+			newUID, newGID := uid, gid
+			if newUID < 0 {
+				newUID = oldUID
+			}
+			if newGID < 0 {
+				newGID = oldGID
+			}
+			if err := honeyos.Chown(sys.FSys(), p, newUID, newGID); err != nil {
+				if !opt.silent {
+					what := "ownership"
+					if c.name == "chgrp" {
+						what = "group"
+					}
+					fmt.Fprintf(sys.Err(), "%v: changing %v of '%v': Operation not permitted\n", c.name, what, shown)
+				}
+				return false
+			}
+			changed := newUID != oldUID || newGID != oldGID
+			if opt.verbose || opt.changes && changed {
+				c.report(sys, shown, oldUID, oldGID, newUID, newGID, changed)
+			}
+			return true
+		})
+		if err != nil {
+			if !opt.silent {
+				fmt.Fprintf(sys.Err(), "%v: cannot access '%v': No such file or directory\n", c.name, name)
+			}
+			res = 1
+		} else if !ok {
+			res = 1
+		}
+	}
+	return res
+}
+
+// parseOwner reads the owner and group like user:group, where either can be
+// left out and -1 is returned for it. The group after user: is the login
+// group of the user
+func (c chown) parseOwner(spec string, sys honeyos.Sys) (uid, gid int, ok bool) {
+	uid, gid = -1, -1
+	user, group := spec, ""
+	sep := strings.IndexAny(spec, ":.")
+	if sep >= 0 {
+		user, group = spec[:sep], spec[sep+1:]
+	}
+	if user != "" {
+		if n, err := strconv.Atoi(user); err == nil {
+			uid = n
+		} else if u := honeyos.GetUser(user); u.Name != "" {
+			uid = u.UID
+			if sep >= 0 && group == "" {
+				gid = u.GID
+			}
+		} else {
+			fmt.Fprintf(sys.Err(), "%v: invalid user: ‘%v’\n", c.name, spec)
+			return 0, 0, false
+		}
+	}
+	if group != "" {
+		if n, err := strconv.Atoi(group); err == nil {
+			gid = n
+		} else if g, found := honeyos.GetGroup(group); found {
+			gid = g.GID
+		} else if c.name == "chgrp" {
+			fmt.Fprintf(sys.Err(), "chgrp: invalid group: ‘%v’\n", group)
+			return 0, 0, false
+		} else {
+			fmt.Fprintf(sys.Err(), "chown: invalid group: ‘%v’\n", spec)
+			return 0, 0, false
+		}
+	}
+	return uid, gid, true
+}
+
+func (c chown) report(sys honeyos.Sys, name string, oldUID, oldGID, newUID, newGID int, changed bool) {
+	owner := func(uid, gid int) string {
+		u, g := honeyos.GetUserByID(uid).Name, honeyos.GetGroupByID(gid).Name
+		if u == "" {
+			u = strconv.Itoa(uid)
+		}
+		if g == "" {
+			g = strconv.Itoa(gid)
+		}
+		if c.name == "chgrp" {
+			return g
+		}
+		return u + ":" + g
+	}
+	what := "ownership"
+	if c.name == "chgrp" {
+		what = "group"
+	}
+	if changed {
+		fmt.Fprintf(sys.Out(), "changed %v of '%v' from %v to %v\n", what, name, owner(oldUID, oldGID), owner(newUID, newGID))
+	} else {
+		fmt.Fprintf(sys.Out(), "%v of '%v' retained as %v\n", what, name, owner(newUID, newGID))
+	}
+}
@@ -0,0 +1,410 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+	pathlib "path"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/mkishere/sshsyrup/virtualfs"
+	"github.com/spf13/afero"
+)
+
+type cp struct{}
+
+// cpOptions are the options of cp, and of mv which shares overwriting
+type cpOptions struct {
+	recursive, force, interactive, noClobber bool
+	preserve, verbose, update, noTarget      bool
+	// noDeref copies symbolic links as links, which -R does unless -L
+	noDeref, deref bool
+	target         string
+}
+
+func init() {
+	honeyos.RegisterCommand("cp", cp{})
+}
+
+func (cp) GetHelp() string {
+	return `Usage: cp [OPTION]... [-T] SOURCE DEST
+  or:  cp [OPTION]... SOURCE... DIRECTORY
+  or:  cp [OPTION]... -t DIRECTORY SOURCE...
+Copy SOURCE to DEST, or multiple SOURCE(s) to DIRECTORY.
+
+Mandatory arguments to long options are mandatory for short options too.
+  -a, --archive                same as -dR --preserve=all
+  -d                           same as --no-dereference --preserve=links
+  -f, --force                  if an existing destination file cannot be
+                                 opened, remove it and try again (this option
+                                 is ignored when the -n option is also used)
+  -i, --interactive            prompt before overwrite (overrides a previous -n
+                                  option)
+  -H                           follow command-line symbolic links in SOURCE
+  -L, --dereference            always follow symbolic links in SOURCE
+  -n, --no-clobber             do not overwrite an existing file (overrides
+                                 a previous -i option)
+  -P, --no-dereference         never follow symbolic links in SOURCE
+  -p                           same as --preserve=mode,ownership,timestamps
+  -R, -r, --recursive          copy directories recursively
+  -t, --target-directory=DIRECTORY  copy all SOURCE arguments into DIRECTORY
+  -T, --no-target-directory    treat DEST as a normal file
+  -u, --update                 copy only when the SOURCE file is newer
+                                 than the destination file or when the
+                                 destination file is missing
+  -v, --verbose                explain what is being done
+      --help     display this help and exit
+      --version  output version information and exit
+
+GNU coreutils online help: <https://www.gnu.org/software/coreutils/>
+Full documentation at: <https://www.gnu.org/software/coreutils/cp>
+or available locally via: info '(coreutils) cp invocation'
+`
+}
+
+func (cp) Where() string {
+	return "/bin/cp"
+}
+
+func (c cp) Exec(args []string, sys honeyos.Sys) int {
+	var opt cpOptions
+	operands, status := fileOperands("cp", args, sys, func(f string) (bool, int) {
+		switch f {
+		case "-a", "--archive":
+			opt.recursive, opt.preserve, opt.noDeref = true, true, true
+		case "-r", "-R", "--recursive":
+			opt.recursive = true
+		case "-f", "--force":
+			opt.force = true
+		case "-i", "--interactive":
+			opt.interactive, opt.noClobber = true, false
+		case "-n", "--no-clobber":
+			opt.noClobber, opt.interactive = true, false
+		case "-p", "--preserve":
+			opt.preserve = true
+		case "-v", "--verbose":
+			opt.verbose = true
+		case "-u", "--update":
+			opt.update = true
+		case "-T", "--no-target-directory":
+			opt.noTarget = true
+		case "-t", "--target-directory":
+			return true, -1
+		case "-d", "-P", "--no-dereference":
+			opt.noDeref, opt.deref = true, false
+		case "-L", "--dereference":
+			opt.deref, opt.noDeref = true, false
+		case "-H", "--parents", "--sparse":
+		case "--help":
+			fmt.Fprint(sys.Out(), c.GetHelp())
+			return false, 0
+		case "--version":
+			fmt.Fprintln(sys.Out(), "cp (GNU coreutils) 8.32")
+			return false, 0
+		default:
+			return false, 1
+		}
+		return false, -1
+	}, &opt.target)
+	if status >= 0 {
+		return status
+	}
+	sources, dest, ok := fileTarget("cp", operands, opt, sys)
+	if !ok {
+		return 1
+	}
+	sys.Log().WithField("files", sources).WithField("dest", dest).Info("User copied files")
+	res := 0
+	for _, src := range sources {
+		dst := dest
+		if fileIntoDir(sys, dest, opt) {
+			dst = pathlib.Join(dest, pathlib.Base(strings.TrimSuffix(src, "/")))
+		}
+		if !c.copy(sys, &opt, src, dst) {
+			res = 1
+		}
+	}
+	return res
+}
+
+// copy copies the file or directory src to dst, which are the names as the
+// user gave them
+func (c cp) copy(sys honeyos.Sys, opt *cpOptions, src, dst string) bool {
+	fs := sys.FSys()
+	srcPath, dstPath := absPath(sys, src), absPath(sys, dst)
+	fi, err := honeyos.Lstat(fs, srcPath)
+	if err == nil && fi.Mode()&os.ModeSymlink != 0 && (opt.deref || !opt.noDeref && !opt.recursive) {
+		fi, err = fs.Stat(srcPath)
+	}
+	if err != nil {
+		fmt.Fprintf(sys.Err(), "cp: cannot stat '%v': %v\n", src, fileError(err))
+		return false
+	}
+	dfi, err := fs.Stat(dstPath)
+	exists := err == nil
+	target, err := honeyos.Readlink(fs, srcPath)
+	if fi.Mode()&os.ModeSymlink != 0 && err != nil {
+		// Links of the image don't keep their targets, so the file is copied
+		fi, err = fs.Stat(srcPath)
+		if err != nil {
+			fmt.Fprintf(sys.Err(), "cp: cannot stat '%v': %v\n", src, fileError(err))
+			return false
+		}
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		// The link is copied as a link
+		if _, err := honeyos.Lstat(fs, dstPath); err == nil {
+			if srcPath == dstPath {
+				fmt.Fprintf(sys.Err(), "cp: '%v' and '%v' are the same file\n", src, dst)
+				return false
+			}
+			if opt.noClobber || opt.interactive && !fileConfirm(sys, fmt.Sprintf("cp: overwrite '%v'? ", dst)) {
+				return true
+			}
+			fs.Remove(dstPath)
+		}
+		if err := honeyos.Symlink(fs, target, dstPath); err != nil {
+			fmt.Fprintf(sys.Err(), "cp: cannot create symbolic link '%v': %v\n", dst, fileError(err))
+			return false
+		}
+		if opt.verbose {
+			fmt.Fprintf(sys.Out(), "'%v' -> '%v'\n", src, dst)
+		}
+		return true
+	}
+	if fi.IsDir() {
+		if !opt.recursive {
+			fmt.Fprintf(sys.Err(), "cp: -r not specified; omitting directory '%v'\n", src)
+			return false
+		}
+		if inside(srcPath, dstPath) {
+			fmt.Fprintf(sys.Err(), "cp: cannot copy a directory, '%v', into itself, '%v'\n", src, dst)
+			return false
+		}
+		if exists && !dfi.IsDir() {
+			fmt.Fprintf(sys.Err(), "cp: cannot overwrite non-directory '%v' with directory '%v'\n", dst, src)
+			return false
+		}
+		if !exists {
+			if err := fs.Mkdir(dstPath, fi.Mode().Perm()&^sys.Umask()); err != nil {
+				fmt.Fprintf(sys.Err(), "cp: cannot create directory '%v': %v\n", dst, fileError(err))
+				return false
+			}
+			if opt.verbose {
+				fmt.Fprintf(sys.Out(), "'%v' -> '%v'\n", src, dst)
+			}
+		}
+		list, err := afero.ReadDir(fs, srcPath)
+		if err != nil {
+			fmt.Fprintf(sys.Err(), "cp: cannot access '%v': %v\n", src, fileError(err))
+			return false
+		}
+		ok := true
+		for _, child := range list {
+			if !c.copy(sys, opt, pathlib.Join(src, child.Name()), pathlib.Join(dst, child.Name())) {
+				ok = false
+			}
+		}
+		if opt.preserve {
+			fs.Chmod(dstPath, fi.Mode())
+			preserve(sys, fi, dstPath)
+		}
+		return ok
+	}
+	if exists {
+		if srcPath == dstPath {
+			fmt.Fprintf(sys.Err(), "cp: '%v' and '%v' are the same file\n", src, dst)
+			return false
+		}
+		if dfi.IsDir() {
+			fmt.Fprintf(sys.Err(), "cp: cannot overwrite directory '%v' with non-directory\n", dst)
+			return false
+		}
+		switch {
+		case opt.noClobber:
+			return true
+		case opt.update && !fi.ModTime().After(dfi.ModTime()):
+			return true
+		case opt.interactive && !fileConfirm(sys, fmt.Sprintf("cp: overwrite '%v'? ", dst)):
+			return true
+		}
+	}
+	in, err := fs.Open(srcPath)
+	if err != nil {
+		fmt.Fprintf(sys.Err(), "cp: cannot open '%v' for reading: %v\n", src, fileError(err))
+		return false
+	}
+	defer in.Close()
+	out, err := fs.OpenFile(dstPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm()&^sys.Umask())
+	if err != nil && exists && opt.force {
+		if fs.Remove(dstPath) == nil {
+			out, err = fs.OpenFile(dstPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm()&^sys.Umask())
+		}
+	}
+	if err != nil {
+		verb := "create regular file"
+		if exists {
+			verb = "open"
+		}
+		fmt.Fprintf(sys.Err(), "cp: cannot %v '%v': %v\n", verb, dst, fileError(err))
+		return false
+	}
+	_, err = io.Copy(out, in)
+	out.Close()
+	if err != nil {
+		fmt.Fprintf(sys.Err(), "cp: error writing '%v': %v\n", dst, fileError(err))
+		return false
+	}
+	if opt.preserve {
+		fs.Chmod(dstPath, fi.Mode())
+		preserve(sys, fi, dstPath)
+	}
+	if opt.verbose {
+		fmt.Fprintf(sys.Out(), "'%v' -> '%v'\n", src, dst)
+	}
+	return true
+}
+
+// preserve gives the copy the times of the file, and its owner if the user
+// is root as others can't give away files
+func preserve(sys honeyos.Sys, fi os.FileInfo, p string) {
+	uid, gid, atime, mtime := virtualfs.GetExtraInfo(fi)
+	sys.FSys().Chtimes(p, atime, mtime)
+	if isRoot(sys) {
+		honeyos.Chown(sys.FSys(), p, uid, gid)
+	}
+}
+
+// fileOperands parses the options of cp, mv and rm, where short options can
+// be combined like -rf. flag handles each option in its short or long form,
+// returning true if it takes the value, and the exit status if it ends the
+// command or -1 if not. Unknown options have status 1. A value is stored in
+// value. status is -1 if the command goes on with the operands
+func fileOperands(name string, args []string, sys honeyos.Sys, flag func(f string) (bool, int), value *string) (operands []string, status int) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return append(operands, args[i+1:]...), -1
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			operands = append(operands, arg)
+			continue
+		}
+		if strings.HasPrefix(arg, "--") {
+			// The value of --opt=value is only kept by options taking it
+			f, hasValue, old := arg, false, *value
+			if kv := strings.SplitN(arg, "=", 2); len(kv) == 2 {
+				f, hasValue = kv[0], true
+				*value = kv[1]
+			}
+			takes, s := flag(f)
+			if !takes {
+				*value = old
+			}
+			if s == 1 {
+				fmt.Fprintf(sys.Err(), "%v: unrecognized option '%v'\nTry '%v --help' for more information.\n", name, arg, name)
+				return nil, 1
+			}
+			if s >= 0 {
+				return nil, s
+			}
+			if takes && !hasValue {
+				if i+1 >= len(args) {
+					fmt.Fprintf(sys.Err(), "%v: option '%v' requires an argument\nTry '%v --help' for more information.\n", name, arg, name)
+					return nil, 1
+				}
+				i++
+				*value = args[i]
+			}
+			continue
+		}
+		for j := 1; j < len(arg); j++ {
+			takes, s := flag("-" + arg[j:j+1])
+			if s == 1 {
+				fmt.Fprintf(sys.Err(), "%v: invalid option -- '%c'\nTry '%v --help' for more information.\n", name, arg[j], name)
+				return nil, 1
+			}
+			if s >= 0 {
+				return nil, s
+			}
+			if takes {
+				// The rest of -tDIR is the value
+				if j+1 < len(arg) {
+					*value = arg[j+1:]
+				} else if i+1 < len(args) {
+					i++
+					*value = args[i]
+				} else {
+					fmt.Fprintf(sys.Err(), "%v: option requires an argument -- '%c'\nTry '%v --help' for more information.\n", name, arg[j], name)
+					return nil, 1
+				}
+				break
+			}
+		}
+	}
+	return operands, -1
+}
+
+// fileTarget splits the operands of cp and mv to the sources and where they
+// go, checking there is a directory for many of them
+func fileTarget(name string, operands []string, opt cpOptions, sys honeyos.Sys) (sources []string, dest string, ok bool) {
+	if opt.target != "" {
+		if fi, err := sys.FSys().Stat(absPath(sys, opt.target)); err != nil {
+			fmt.Fprintf(sys.Err(), "%v: failed to access '%v': %v\n", name, opt.target, fileError(err))
+			return nil, "", false
+		} else if !fi.IsDir() {
+			fmt.Fprintf(sys.Err(), "%v: target '%v' is not a directory\n", name, opt.target)
+			return nil, "", false
+		}
+		if len(operands) == 0 {
+			fmt.Fprintf(sys.Err(), "%v: missing file operand\nTry '%v --help' for more information.\n", name, name)
+			return nil, "", false
+		}
+		return operands, opt.target, true
+	}
+	switch len(operands) {
+	case 0:
+		fmt.Fprintf(sys.Err(), "%v: missing file operand\nTry '%v --help' for more information.\n", name, name)
+		return nil, "", false
+	case 1:
+		fmt.Fprintf(sys.Err(), "%v: missing destination file operand after '%v'\nTry '%v --help' for more information.\n",
+			name, operands[0], name)
+		return nil, "", false
+	}
+	dest = operands[len(operands)-1]
+	if len(operands) > 2 {
+		if opt.noTarget {
+			fmt.Fprintf(sys.Err(), "%v: extra operand '%v'\nTry '%v --help' for more information.\n", name, operands[2], name)
+			return nil, "", false
+		}
+		if !fileIntoDir(sys, dest, opt) {
+			fmt.Fprintf(sys.Err(), "%v: target '%v' is not a directory\n", name, dest)
+			return nil, "", false
+		}
+	}
+	return operands[:len(operands)-1], dest, true
+}
+
+// fileIntoDir tells if the sources go into dest as it is a directory
+func fileIntoDir(sys honeyos.Sys, dest string, opt cpOptions) bool {
+	if opt.target != "" {
+		return true
+	}
+	fi, err := sys.FSys().Stat(absPath(sys, dest))
+	return err == nil && fi.IsDir() && !opt.noTarget
+}
+
+// inside tells if p is dir or under it
+func inside(dir, p string) bool {
+	dir, p = pathlib.Clean(dir), pathlib.Clean(p)
+	return p == dir || strings.HasPrefix(p, strings.TrimSuffix(dir, "/")+"/")
+}
+
+// fileConfirm asks the question on stderr like cp -i and rm -i, taking an
+// answer starting with y as yes
+func fileConfirm(sys honeyos.Sys, question string) bool {
+	fmt.Fprint(sys.Err(), question)
+	line, _ := readLine(sys.In())
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(line)), "y")
+}
@@ -0,0 +1,332 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"path"
+	"regexp"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/afero"
+)
+
+// crontab installs, lists and removes the cron table of users, which is kept
+// in the spool directory of the virtual filesystem. Installing is logged as
+// warning since cron is how attackers persist on the machine
+type crontab struct{}
+
+// cronField checks the time fields, which can be numbers, ranges, steps,
+// lists and names of months and weekdays
+var cronField = regexp.MustCompile(`^(\*|([0-9]+|[a-zA-Z]{3})(-([0-9]+|[a-zA-Z]{3}))?)(/[0-9]+)?(,(\*|([0-9]+|[a-zA-Z]{3})(-([0-9]+|[a-zA-Z]{3}))?)(/[0-9]+)?)*$`)
+
+var cronFieldNames = []string{"minute", "hour", "day-of-month", "month", "day-of-week"}
+
+// cronEditors are the editors select-editor offers, with the commands running
+// them
+var cronEditors = []struct{ path, cmd string }{
+	{"/bin/ed", "ed"}, {"/bin/nano", "nano"}, {"/usr/bin/vim.basic", "vim"}, {"/usr/bin/vim.tiny", "vi"},
+}
+
+func init() {
+	honeyos.RegisterCommand("crontab", crontab{})
+}
+
+func (crontab) GetHelp() string {
+	return `usage:	crontab [-u user] file
+	crontab [ -u user ] [ -i ] { -e | -l | -r }
+		(default operation is replace, per 1003.2)
+	-e	(edit user's crontab)
+	-l	(list user's crontab)
+	-r	(delete user's crontab)
+	-i	(prompt before deleting user's crontab)
+`
+}
+
+func (crontab) Where() string {
+	return "/usr/bin/crontab"
+}
+
+func (c crontab) Exec(args []string, sys honeyos.Sys) int {
+	action, file := "", ""
+	user := honeyos.GetUserByID(sys.CurrentUser()).Name
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "-u":
+			if i+1 >= len(args) {
+				fmt.Fprintln(sys.Err(), "crontab: option requires an argument -- 'u'")
+				fmt.Fprint(sys.Err(), c.GetHelp())
+				return 1
+			}
+			i++
+			if !isRoot(sys) {
+				fmt.Fprintln(sys.Err(), "must be privileged to use -u")
+				return 1
+			}
+			if honeyos.GetUser(args[i]).Name == "" {
+				fmt.Fprintf(sys.Err(), "crontab: user `%v' unknown\n", args[i])
+				return 1
+			}
+			user = args[i]
+		case arg == "-e" || arg == "-l" || arg == "-r":
+			if action != "" && action != arg {
+				fmt.Fprint(sys.Err(), c.GetHelp())
+				return 1
+			}
+			action = arg
+		case arg == "-i":
+		case arg == "-" || !strings.HasPrefix(arg, "-"):
+			file = arg
+		default:
+			fmt.Fprintf(sys.Err(), "crontab: invalid option -- '%v'\n", strings.TrimLeft(arg, "-"))
+			fmt.Fprint(sys.Err(), c.GetHelp())
+			return 1
+		}
+	}
+	if action == "" && file == "" {
+		if honeyos.IsTerminal(sys.In()) {
+			fmt.Fprint(sys.Err(), c.GetHelp())
+			return 1
+		}
+		file = "-"
+	}
+	fs := honeyos.SetUIDFs(sys.FSys())
+	spool := cronPath(user)
+	switch action {
+	case "-l":
+		data, err := afero.ReadFile(fs, spool)
+		if err != nil {
+			fmt.Fprintf(sys.Err(), "no crontab for %v\n", user)
+			return 1
+		}
+		sys.Out().Write(data)
+		return 0
+	case "-r":
+		if err := fs.Remove(spool); err != nil {
+			fmt.Fprintf(sys.Err(), "no crontab for %v\n", user)
+			return 1
+		}
+		sys.Log().WithField("crontabUser", user).Infof("User removed crontab of %v", user)
+		return 0
+	case "-e":
+		return c.edit(sys, user)
+	}
+	var data []byte
+	var err error
+	if file == "-" {
+		data, err = ioutil.ReadAll(sys.In())
+	} else {
+		data, err = afero.ReadFile(sys.FSys(), absPath(sys, file))
+	}
+	if err != nil {
+		fmt.Fprintf(sys.Err(), "%v: No such file or directory\n", file)
+		return 1
+	}
+	if msg := checkCrontab(data); msg != "" {
+		fmt.Fprintf(sys.Err(), "\"%v\":%v\nerrors in crontab file, can't install.\n", file, msg)
+		return 1
+	}
+	installCrontab(sys, user, data, file)
+	return 0
+}
+
+// edit runs the editor on a copy of the crontab and installs it if changed
+func (c crontab) edit(sys honeyos.Sys, user string) int {
+	fs := honeyos.SetUIDFs(sys.FSys())
+	old, err := afero.ReadFile(fs, cronPath(user))
+	if err != nil {
+		fmt.Fprintf(sys.Err(), "no crontab for %v - using an empty one\n", user)
+		old = []byte(cronTemplate)
+	}
+	editor := c.editor(sys)
+	if editor == "" {
+		return 1
+	}
+	dir := fmt.Sprintf("/tmp/crontab.%06x", rand.Intn(1<<24))
+	tmp := dir + "/crontab"
+	sys.FSys().MkdirAll(dir, 0700)
+	defer sys.FSys().RemoveAll(dir)
+	afero.WriteFile(sys.FSys(), tmp, old, 0600)
+	for {
+		n, found := honeyos.RunAs(sys, honeyos.Credential{UID: sys.CurrentUser()}, []string{editor, tmp})
+		if !found {
+			fmt.Fprintf(sys.Err(), "/bin/sh: 1: %v: not found\n", editor)
+			n = 127
+		}
+		if n != 0 {
+			fmt.Fprintf(sys.Err(), "crontab: \"%v\" exited with status %v\n", editor, n)
+			return 1
+		}
+		data, _ := afero.ReadFile(sys.FSys(), tmp)
+		if string(data) == string(old) {
+			fmt.Fprintln(sys.Err(), "crontab: no changes made to crontab")
+			return 0
+		}
+		fmt.Fprintln(sys.Err(), "crontab: installing new crontab")
+		if msg := checkCrontab(data); msg != "" {
+			fmt.Fprintf(sys.Err(), "\"%v\":%v\nerrors in crontab file, can't install.\n", tmp, msg)
+			if pkgConfirm(sys, "Do you want to retry the same edit? (y/n) ", false) {
+				continue
+			}
+			fmt.Fprintf(sys.Err(), "edits left in %v\n", tmp)
+			return 1
+		}
+		installCrontab(sys, user, data, "crontab -e")
+		return 0
+	}
+}
+
+// editor finds the editor for crontab -e from $VISUAL and $EDITOR. Debian
+// asks to select one the first time, like sensible-editor does
+func (crontab) editor(sys honeyos.Sys) string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if e := honeyos.Getenv(sys, env); e != "" {
+			return e
+		}
+	}
+	if pkgFamily() != "deb" {
+		return "vi"
+	}
+	selected := honeyos.Getenv(sys, "HOME") + "/.selected_editor"
+	if data, err := afero.ReadFile(sys.FSys(), selected); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if strings.HasPrefix(line, "SELECTED_EDITOR=") {
+				p := strings.Trim(strings.TrimPrefix(line, "SELECTED_EDITOR="), `"`)
+				for _, e := range cronEditors {
+					if e.path == p {
+						return e.cmd
+					}
+				}
+				return p
+			}
+		}
+	}
+	fmt.Fprintln(sys.Out(), "\nSelect an editor.  To change later, run 'select-editor'.")
+	for i, e := range cronEditors {
+		fmt.Fprintf(sys.Out(), "  %v. %v", i+1, e.path)
+		if e.cmd == "nano" {
+			fmt.Fprint(sys.Out(), "        <---- easiest")
+		}
+		fmt.Fprintln(sys.Out())
+	}
+	for {
+		fmt.Fprintf(sys.Out(), "\nChoose 1-%v [2]: ", len(cronEditors))
+		line, ok := readLine(sys.In())
+		if !ok {
+			return ""
+		}
+		choice := 2
+		if line = strings.TrimSpace(line); line != "" {
+			if _, err := fmt.Sscan(line, &choice); err != nil || choice < 1 || choice > len(cronEditors) {
+				continue
+			}
+		}
+		e := cronEditors[choice-1]
+		afero.WriteFile(sys.FSys(), selected, []byte("# Generated by /usr/bin/select-editor\nSELECTED_EDITOR=\""+e.path+"\"\n"), 0644)
+		return e.cmd
+	}
+}
+
+// cronPath is where the crontab of the user is kept in the distribution
+func cronPath(user string) string {
+	switch pkgFamily() {
+	case "rpm":
+		return "/var/spool/cron/" + user
+	case "apk":
+		return "/etc/crontabs/" + user
+	}
+	return "/var/spool/cron/crontabs/" + user
+}
+
+// installCrontab saves the crontab of the user. The content is captured as
+// artifact as it usually runs the payload
+func installCrontab(sys honeyos.Sys, user string, data []byte, source string) {
+	fs := honeyos.SetUIDFs(sys.FSys())
+	p := cronPath(user)
+	fs.MkdirAll(path.Dir(p), 0755)
+	afero.WriteFile(fs, p, data, 0600)
+	fs.Chmod(p, 0600)
+	u := honeyos.GetUser(user)
+	honeyos.Chown(fs, p, u.UID, u.GID)
+	sys.Log().WithField("crontabUser", user).WithField("crontab", string(data)).
+		Warnf("User installed crontab for %v", user)
+	honeyos.SaveArtifact(sys, data, source)
+}
+
+// checkCrontab validates the entries like crontab does, returning the line
+// and error like 1: bad minute
+func checkCrontab(data []byte) string {
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if strings.HasPrefix(fields[0], "@") {
+			if len(fields) < 2 {
+				return fmt.Sprintf("%v: bad command", i+1)
+			}
+			continue
+		}
+		if eq := strings.IndexByte(line, '='); eq > 0 && !strings.ContainsAny(line[:eq], " \t*") {
+			// Environment setting like MAILTO=""
+			continue
+		}
+		for j, name := range cronFieldNames {
+			if j >= len(fields) || !cronField.MatchString(fields[j]) {
+				return fmt.Sprintf("%v: bad %v", i+1, name)
+			}
+		}
+		if len(fields) < 6 {
+			return fmt.Sprintf("%v: bad command", i+1)
+		}
+	}
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		return fmt.Sprintf("%v: premature EOF", strings.Count(string(data), "\n")+1)
+	}
+	return ""
+}
+
+// readLine reads the answer up to the end of line, leaving the rest of input
+func readLine(in io.Reader) (string, bool) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := in.Read(b)
+		if n > 0 {
+			if b[0] == '\n' {
+				return strings.TrimSuffix(string(line), "\r"), true
+			}
+			line = append(line, b[0])
+		}
+		if err != nil {
+			return string(line), len(line) > 0
+		}
+	}
+}
+
+const cronTemplate = `# Edit this file to introduce tasks to be run by cron.
+#
+# Each task to run has to be defined through a single line
+# indicating with different fields when the task will be run
+# and what command to run for the task
+#
+# To define the time you can provide concrete values for
+# minute (m), hour (h), day of month (dom), month (mon),
+# and day of week (dow) or use '*' in these fields (for 'any').#
+# Notice that tasks will be started based on the cron's system
+# daemon's notion of time and timezones.
+#
+# Output of the crontab jobs (including errors) is sent through
+# email to the user the crontab file belongs to (unless redirected).
+#
+# For example, you can run a backup of all your user accounts
+# at 5 a.m every week with:
+# 0 5 * * 1 tar -zcf /var/backups/home.tgz /home/
+#
+# For more information see the manual pages of crontab(5) and cron(8)
+#
+# m h  dom mon dow   command
+`
@@ -0,0 +1,111 @@
+package command
+
+import (
+	"crypto/sha512"
+	"math/rand"
+)
+
+const cryptAlphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// cryptPassword hashes the password with a random salt like the password
+// stored in /etc/shadow
+func cryptPassword(password string) string {
+	salt := make([]byte, 16)
+	for i := range salt {
+		salt[i] = cryptAlphabet[rand.Intn(len(cryptAlphabet))]
+	}
+	return sha512Crypt(password, string(salt))
+}
+
+// sha512Crypt is the SHA-512 based crypt(3) with the default 5000 rounds,
+// which gives the $6$ hashes
+func sha512Crypt(password, salt string) string {
+	if len(salt) > 16 {
+		salt = salt[:16]
+	}
+	p, s := []byte(password), []byte(salt)
+	h := sha512.New()
+	h.Write(p)
+	h.Write(s)
+	h.Write(p)
+	b := h.Sum(nil)
+
+	h.Reset()
+	h.Write(p)
+	h.Write(s)
+	h.Write(repeatBytes(b, len(p)))
+	for n := len(p); n > 0; n >>= 1 {
+		if n&1 != 0 {
+			h.Write(b)
+		} else {
+			h.Write(p)
+		}
+	}
+	a := h.Sum(nil)
+
+	h.Reset()
+	for range p {
+		h.Write(p)
+	}
+	dp := repeatBytes(h.Sum(nil), len(p))
+	h.Reset()
+	for i := 0; i < 16+int(a[0]); i++ {
+		h.Write(s)
+	}
+	ds := repeatBytes(h.Sum(nil), len(s))
+
+	for i := 0; i < 5000; i++ {
+		h.Reset()
+		if i&1 != 0 {
+			h.Write(dp)
+		} else {
+			h.Write(a)
+		}
+		if i%3 != 0 {
+			h.Write(ds)
+		}
+		if i%7 != 0 {
+			h.Write(dp)
+		}
+		if i&1 != 0 {
+			h.Write(a)
+		} else {
+			h.Write(dp)
+		}
+		a = h.Sum(nil)
+	}
+
+	out := []byte("$6$" + salt + "$")
+	encode := func(w uint, n int) {
+		for ; n > 0; n-- {
+			out = append(out, cryptAlphabet[w&0x3f])
+			w >>= 6
+		}
+	}
+	// The bytes are shuffled in groups of three before encoding
+	for i := 0; i < 21; i++ {
+		x, y, z := i, i+21, i+42
+		switch i % 3 {
+		case 1:
+			x, y, z = i+21, i+42, i
+		case 2:
+			x, y, z = i+42, i, i+21
+		}
+		encode(uint(a[x])<<16|uint(a[y])<<8|uint(a[z]), 4)
+	}
+	encode(uint(a[63]), 2)
+	return string(out)
+}
+
+// repeatBytes repeats b up to n bytes
+func repeatBytes(b []byte, n int) []byte {
+	out := make([]byte, 0, n)
+	for len(out) < n {
+		k := len(b)
+		if k > n-len(out) {
+			k = n - len(out)
+		}
+		out = append(out, b[:k]...)
+	}
+	return out
+}
@@ -0,0 +1,438 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	urllib "net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mkishere/sshsyrup/os"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+type curl struct{}
+
+const curlUserAgent = "curl/7.68.0"
+
+func init() {
+	os.RegisterCommand("curl", curl{})
+}
+
+func (curl) GetHelp() string {
+	return ""
+}
+
+func (curl) Where() string {
+	return "/usr/bin/curl"
+}
+
+// curlOptions are the command line options affecting each transfer
+type curlOptions struct {
+	output     string
+	remoteName bool
+	silent     bool
+	showError  bool
+	method     string
+	data       []string
+	headers    []string
+	location   bool
+	insecure   bool
+	fail       bool
+	head       bool
+	include    bool
+	verbose    bool
+	agent      string
+	user       string
+	maxTime    float64
+}
+
+func (c curl) Exec(args []string, sys os.Sys) int {
+	var opt curlOptions
+	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	flag.StringVarP(&opt.output, "output", "o", "", "Write to file instead of stdout")
+	flag.BoolVarP(&opt.remoteName, "remote-name", "O", false, "Write output to a file named as the remote file")
+	flag.BoolVarP(&opt.silent, "silent", "s", false, "Silent mode")
+	flag.BoolVarP(&opt.showError, "show-error", "S", false, "Show error even when -s is used")
+	flag.StringVarP(&opt.method, "request", "X", "", "Specify request command to use")
+	flag.StringArrayVarP(&opt.data, "data", "d", nil, "HTTP POST data")
+	dataRaw := flag.StringArray("data-raw", nil, "HTTP POST data, '@' allowed")
+	dataBinary := flag.StringArray("data-binary", nil, "HTTP POST binary data")
+	flag.StringArrayVarP(&opt.headers, "header", "H", nil, "Pass custom header(s) to server")
+	flag.BoolVarP(&opt.location, "location", "L", false, "Follow redirects")
+	flag.BoolVarP(&opt.insecure, "insecure", "k", false, "Allow insecure server connections when using SSL")
+	flag.BoolVarP(&opt.fail, "fail", "f", false, "Fail silently (no output at all) on HTTP errors")
+	flag.BoolVarP(&opt.head, "head", "I", false, "Show document info only")
+	flag.BoolVarP(&opt.include, "include", "i", false, "Include protocol response headers in the output")
+	flag.BoolVarP(&opt.verbose, "verbose", "v", false, "Make the operation more talkative")
+	flag.StringVarP(&opt.agent, "user-agent", "A", curlUserAgent, "Send User-Agent <name> to server")
+	flag.StringVarP(&opt.user, "user", "u", "", "Server user and password")
+	flag.Float64VarP(&opt.maxTime, "max-time", "m", 0, "Maximum time allowed for the transfer")
+	flag.BoolP("progress-bar", "#", false, "Display transfer progress as a bar")
+	if err := flag.Parse(args); err != nil {
+		fmt.Fprintf(sys.Err(), "curl: %v\ncurl: try 'curl --help' or 'curl --manual' for more information\n", err)
+		return 2
+	}
+	if flag.NArg() == 0 {
+		fmt.Fprintln(sys.Err(), "curl: try 'curl --help' or 'curl --manual' for more information")
+		return 2
+	}
+	opt.data = append(append(opt.data, *dataRaw...), *dataBinary...)
+	res := 0
+	for _, url := range flag.Args() {
+		if n := c.transfer(url, opt, sys); n != 0 {
+			res = n
+		}
+		if sys.Context().Err() != nil {
+			break
+		}
+	}
+	return res
+}
+
+// transfer sends the request to url and writes the response as the options
+// say. It returns the exit status of curl
+func (c curl) transfer(url string, opt curlOptions, sys os.Sys) int {
+	errorf := func(code int, format string, a ...interface{}) int {
+		if !opt.silent || opt.showError {
+			fmt.Fprintf(sys.Err(), "curl: (%v) %v\n", code, fmt.Sprintf(format, a...))
+		}
+		return code
+	}
+	if !strings.Contains(url, "://") {
+		url = "http://" + url
+	}
+	urlobj, err := urllib.Parse(url)
+	if err != nil || urlobj.Hostname() == "" {
+		return errorf(3, "URL using bad/illegal format or missing URL")
+	}
+	if urlobj.Scheme != "http" && urlobj.Scheme != "https" {
+		return errorf(1, "Protocol \"%v\" not supported or disabled in libcurl", urlobj.Scheme)
+	}
+	output := opt.output
+	if opt.remoteName {
+		if output = path.Base(urlobj.Path); output == "/" || output == "." {
+			if !opt.silent || opt.showError {
+				fmt.Fprintln(sys.Err(), "curl: Remote file name has no length!")
+			}
+			return 23
+		}
+	}
+
+	// Build the request the way curl does, so what is logged is what the
+	// server would see
+	method := opt.method
+	var data []byte
+	for i, d := range opt.data {
+		if strings.HasPrefix(d, "@") {
+			b, err := afero.ReadFile(sys.FSys(), absPath(sys, d[1:]))
+			if err != nil {
+				fmt.Fprintf(sys.Err(), "Warning: Couldn't read data from file \"%v\", this makes an empty\nWarning: POST.\n", d[1:])
+			}
+			d = strings.TrimRight(string(b), "\r\n")
+		}
+		if i > 0 {
+			data = append(data, '&')
+		}
+		data = append(data, d...)
+	}
+	switch {
+	case method != "":
+	case opt.head:
+		method = "HEAD"
+	case len(opt.data) > 0:
+		method = "POST"
+	default:
+		method = "GET"
+	}
+	var body io.Reader
+	if len(opt.data) > 0 {
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return errorf(3, "URL using bad/illegal format or missing URL")
+	}
+	req.Header.Set("User-Agent", opt.agent)
+	req.Header.Set("Accept", "*/*")
+	if len(opt.data) > 0 {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if opt.user != "" {
+		kv := strings.SplitN(opt.user, ":", 2)
+		if len(kv) == 1 {
+			kv = append(kv, "")
+		}
+		req.SetBasicAuth(kv[0], kv[1])
+	}
+	for _, h := range opt.headers {
+		kv := strings.SplitN(h, ":", 2)
+		if len(kv) == 2 {
+			req.Header.Set(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+		}
+	}
+	dump, _ := httputil.DumpRequest(req, true)
+	sys.Log().WithFields(log.Fields{
+		"url":     url,
+		"method":  method,
+		"request": string(dump),
+	}).Infof("User sending HTTP request to %v", url)
+
+	host := urlobj.Hostname()
+	port := urlobj.Port()
+	if port == "" {
+		port = "80"
+		if urlobj.Scheme == "https" {
+			port = "443"
+		}
+	}
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else if viper.GetBool("server.allowDownload") {
+		ips, _ = net.LookupIP(host)
+	}
+	if len(ips) == 0 {
+		return errorf(6, "Could not resolve host: %v", host)
+	}
+	if opt.verbose {
+		fmt.Fprintf(sys.Err(), "*   Trying %v:%v...\n", ips[0], port)
+	}
+	// The honeypot host and its network are never reached from the session,
+	// as if nothing listens there
+	for _, ip := range ips {
+		if netInternal(ip) {
+			sys.Log().WithField("url", url).Warnf("Refused HTTP request to internal address %v", ip)
+			return errorf(7, "Failed to connect to %v port %v: Connection refused", host, port)
+		}
+	}
+	if !viper.GetBool("server.allowDownload") {
+		return errorf(28, "Failed to connect to %v port %v: Connection timed out", host, port)
+	}
+	// Only downloads are sent for real. Requests sending data are logged
+	// above and time out, so the honeypot never relays them
+	if (method != "GET" && method != "HEAD") || len(opt.data) > 0 {
+		sys.Log().WithField("url", url).Warnf("Not sending HTTP %v request to %v", method, url)
+		return errorf(28, "Failed to connect to %v port %v: Connection timed out", host, port)
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext:        netDialExternal,
+			TLSClientConfig:    &tls.Config{InsecureSkipVerify: opt.insecure},
+			DisableCompression: true,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if !opt.location {
+				return http.ErrUseLastResponse
+			}
+			if len(via) >= 50 {
+				return fmt.Errorf("Maximum (50) redirects followed")
+			}
+			return nil
+		},
+	}
+	if opt.maxTime > 0 {
+		client.Timeout = time.Duration(opt.maxTime * float64(time.Second))
+	}
+	start := time.Now()
+	resp, err := client.Do(req.WithContext(sys.Context()))
+	switch {
+	case sys.Context().Err() != nil:
+		return 130
+	case err != nil && strings.Contains(err.Error(), "x509"):
+		return errorf(60, "SSL certificate problem: unable to get local issuer certificate")
+	case err != nil && strings.Contains(err.Error(), "Timeout"):
+		return errorf(28, "Operation timed out after %v milliseconds with 0 bytes received", int(time.Since(start)/time.Millisecond))
+	case err != nil:
+		return errorf(7, "Failed to connect to %v port %v: Connection refused", host, port)
+	}
+	defer resp.Body.Close()
+	if opt.verbose {
+		fmt.Fprintf(sys.Err(), "* Connected to %v (%v) port %v (#0)\n", host, ips[0], port)
+		for _, line := range strings.Split(strings.TrimRight(string(dump), "\r\n"), "\r\n") {
+			fmt.Fprintf(sys.Err(), "> %v\n", line)
+		}
+		fmt.Fprintln(sys.Err(), ">")
+		for _, line := range strings.Split(curlHeaders(resp), "\r\n") {
+			if line != "" {
+				fmt.Fprintf(sys.Err(), "< %v\n", line)
+			}
+		}
+		fmt.Fprintln(sys.Err(), "<")
+	}
+	if opt.fail && resp.StatusCode >= 400 {
+		return errorf(22, "The requested URL returned error: %v", resp.Status)
+	}
+
+	var content bytes.Buffer
+	limit := int64(viper.GetSizeInBytes("server.downloadFileSizeLimit"))
+	if limit <= 0 || limit > curlMaxBody {
+		limit = curlMaxBody
+	}
+	if _, err := io.Copy(&content, io.LimitReader(resp.Body, limit)); err != nil {
+		return errorf(56, "Failure when receiving data from the peer")
+	}
+	if content.Len() > 0 {
+		if _, err := os.SaveArtifact(sys, content.Bytes(), url); err != nil {
+			sys.Log().WithError(err).Error("Cannot save downloaded file to artifact directory")
+		}
+	}
+
+	var out bytes.Buffer
+	if opt.include || opt.head {
+		out.WriteString(curlHeaders(resp))
+	}
+	out.Write(content.Bytes())
+	// Progress meter is shown unless the output goes to the terminal
+	if !opt.silent && (output != "" || !os.IsTerminal(sys.Out())) {
+		c.progress(sys.Err(), int64(out.Len()), int64(len(data)), time.Since(start))
+	}
+	if output == "" || output == "-" {
+		sys.Out().Write(out.Bytes())
+		return 0
+	}
+	if err := afero.WriteFile(sys.FSys(), absPath(sys, output), out.Bytes(), 0666&^sys.Umask()); err != nil {
+		if !opt.silent || opt.showError {
+			fmt.Fprintf(sys.Err(), "Warning: Failed to create the file %v: Permission denied\n", output)
+		}
+		return errorf(23, "Failure writing output to destination")
+	}
+	return 0
+}
+
+// curlMaxBody is the most of the response kept, even if the download size
+// is not limited in the config
+const curlMaxBody = 100 << 20
+
+// netDialExternal connects to the address unless it is internal to the
+// honeypot host. Addresses are checked when connecting as well, for the
+// redirects and names resolving differently the second time
+func netDialExternal(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no address for %v", host)
+	}
+	for _, a := range addrs {
+		if netInternal(a.IP) {
+			return nil, fmt.Errorf("internal address %v", a.IP)
+		}
+	}
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	return dialer.DialContext(ctx, network, net.JoinHostPort(addrs[0].IP.String(), port))
+}
+
+// netInternalNets are the networks of the honeypot host rather than the
+// Internet: private, shared, loopback and link local ones, including the
+// metadata service of the cloud at 169.254.169.254
+var netInternalNets = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range []string{"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16",
+		"172.16.0.0/12", "192.168.0.0/16", "::/128", "::1/128", "fc00::/7", "fe80::/10"} {
+		_, n, _ := net.ParseCIDR(cidr)
+		nets = append(nets, n)
+	}
+	return nets
+}()
+
+// netInternal tells if the address is internal to the honeypot host,
+// which the requests of the session must never reach
+func netInternal(ip net.IP) bool {
+	if ip.IsMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, n := range netInternalNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// curlHeaders formats the status line and headers of the response
+func curlHeaders(resp *http.Response) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%v %v\r\n", resp.Proto, resp.Status)
+	var keys []string
+	for k := range resp.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range resp.Header[k] {
+			fmt.Fprintf(&buf, "%v: %v\r\n", k, v)
+		}
+	}
+	buf.WriteString("\r\n")
+	return buf.String()
+}
+
+// progress prints the progress meter of the finished transfer
+func (curl) progress(w io.Writer, received, sent int64, elapsed time.Duration) {
+	speed := func(n int64) string {
+		if elapsed <= 0 {
+			return "0"
+		}
+		return curlSize(int64(float64(n) / elapsed.Seconds()))
+	}
+	percent := func(n int64) string {
+		if n == 0 {
+			return "0"
+		}
+		return "100"
+	}
+	total := received + sent
+	fmt.Fprintln(w, "  % Total    % Received % Xferd  Average Speed   Time    Time     Time  Current")
+	fmt.Fprintln(w, "                                 Dload  Upload   Total   Spent    Left  Speed")
+	fmt.Fprintf(w, "%3v %5v  %3v %5v  %3v %5v %6v %6v --:--:-- %v --:--:-- %5v\n",
+		percent(total), curlSize(total), percent(received), curlSize(received), percent(sent), curlSize(sent),
+		speed(received), speed(sent), curlTime(elapsed), speed(received))
+}
+
+// curlSize formats the size in at most 5 characters, like 1234 and 12.3M
+func curlSize(n int64) string {
+	if n < 100000 {
+		return strconv.FormatInt(n, 10)
+	}
+	v := float64(n)
+	for _, u := range []string{"k", "M", "G", "T"} {
+		v /= 1024
+		if v < 10 {
+			return fmt.Sprintf("%.1f%v", v, u)
+		}
+		if v < 1000 {
+			return fmt.Sprintf("%.0f%v", v, u)
+		}
+	}
+	return fmt.Sprintf("%.0fP", v/1024)
+}
+
+// curlTime formats the duration as H:MM:SS
+func curlTime(d time.Duration) string {
+	s := int(d.Seconds())
+	if s == 0 {
+		return "--:--:--"
+	}
+	return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+}
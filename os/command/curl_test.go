@@ -0,0 +1,62 @@
+package command
+
+import (
+	"net"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestNetInternal(t *testing.T) {
+	tests := []struct {
+		ip       string
+		internal bool
+	}{
+		{"127.0.0.1", true},
+		{"127.8.0.1", true},
+		{"10.1.2.3", true},
+		{"172.31.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"100.64.0.1", true},
+		{"0.0.0.0", true},
+		{"::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"fd00::1", true},
+		{"fe80::1", true},
+		{"224.0.0.1", true},
+		{"8.8.8.8", false},
+		{"172.32.0.1", false},
+		{"2001:4860:4860::8888", false},
+	}
+	for _, test := range tests {
+		if internal := netInternal(net.ParseIP(test.ip)); internal != test.internal {
+			t.Errorf("%v, expect internal to be %v, got %v", test.ip, test.internal, internal)
+		}
+	}
+}
+
+func TestCurlRefused(t *testing.T) {
+	viper.Set("server.allowDownload", true)
+	defer viper.Set("server.allowDownload", nil)
+	tests := []struct {
+		args   []string
+		errMsg string
+		status int
+	}{
+		{[]string{"http://127.0.0.1:8080/"}, "curl: (7) Failed to connect to 127.0.0.1 port 8080: Connection refused\n", 7},
+		{[]string{"http://169.254.169.254/latest/meta-data/"}, "curl: (7) Failed to connect to 169.254.169.254 port 80: Connection refused\n", 7},
+		{[]string{"-s", "https://10.0.0.1/"}, "", 7},
+		{[]string{"http://[::1]/"}, "curl: (7) Failed to connect to ::1 port 80: Connection refused\n", 7},
+		// Only downloads are sent
+		{[]string{"-d", "a=1", "http://203.0.113.7/"}, "curl: (28) Failed to connect to 203.0.113.7 port 80: Connection timed out\n", 28},
+		{[]string{"-X", "DELETE", "http://203.0.113.7/x"}, "curl: (28) Failed to connect to 203.0.113.7 port 80: Connection timed out\n", 28},
+	}
+	for _, test := range tests {
+		sys := newTestSys("")
+		status := sys.run(curl{}, test.args...)
+		if errMsg := sys.err.String(); errMsg != test.errMsg || status != test.status {
+			t.Errorf("curl %q, expect %q with status %v, got %q with status %v", test.args, test.errMsg, test.status, errMsg, status)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/pflag"
+)
+
+// cut prints the selected bytes, characters or fields of the lines
+type cut struct{}
+
+// cutRange is a range of the list of cut, counting from 1. hi 0 is up to
+// the end of line
+type cutRange struct {
+	lo, hi int
+}
+
+func init() {
+	honeyos.RegisterCommand("cut", cut{})
+}
+
+func (cut) GetHelp() string {
+	return "Usage: cut OPTION... [FILE]...\nTry 'cut --help' for more information.\n"
+}
+
+func (cut) Where() string {
+	return "/usr/bin/cut"
+}
+
+// parseCutList parses the list like 1,3-5,7-
+func parseCutList(list string) ([]cutRange, bool) {
+	var ranges []cutRange
+	for _, part := range strings.Split(list, ",") {
+		r := cutRange{}
+		var err error
+		switch i := strings.IndexByte(part, '-'); {
+		case i < 0:
+			if r.lo, err = strconv.Atoi(part); err != nil || r.lo < 1 {
+				return nil, false
+			}
+			r.hi = r.lo
+		default:
+			r.lo = 1
+			if i > 0 {
+				if r.lo, err = strconv.Atoi(part[:i]); err != nil || r.lo < 1 {
+					return nil, false
+				}
+			}
+			if i < len(part)-1 {
+				if r.hi, err = strconv.Atoi(part[i+1:]); err != nil || r.hi < r.lo {
+					return nil, false
+				}
+			} else if i == 0 {
+				return nil, false
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, true
+}
+
+// selected tells if the position is in the list, or not in it for
+// --complement
+func cutSelected(ranges []cutRange, n int, complement bool) bool {
+	for _, r := range ranges {
+		if n >= r.lo && (r.hi == 0 || n <= r.hi) {
+			return !complement
+		}
+	}
+	return complement
+}
+
+func (c cut) Exec(args []string, sys honeyos.Sys) int {
+	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	byteList := flag.StringP("bytes", "b", "", "")
+	charList := flag.StringP("characters", "c", "", "")
+	fieldList := flag.StringP("fields", "f", "", "")
+	delim := flag.StringP("delimiter", "d", "\t", "")
+	onlyDelimited := flag.BoolP("only-delimited", "s", false, "")
+	complement := flag.Bool("complement", false, "")
+	outDelim := flag.String("output-delimiter", "", "")
+	zero := flag.BoolP("zero-terminated", "z", false, "")
+	// -n is ignored like in coreutils
+	flag.BoolP("no-split", "n", false, "")
+	help := flag.Bool("help", false, "")
+	if err := flag.Parse(args); err != nil {
+		msg := strings.Replace(err.Error(), "unknown shorthand flag: ", "invalid option -- ", 1)
+		if i := strings.Index(msg, " in -"); i >= 0 {
+			msg = msg[:i]
+		}
+		fmt.Fprintf(sys.Err(), "cut: %v\nTry 'cut --help' for more information.\n", msg)
+		return 1
+	}
+	if *help {
+		fmt.Fprint(sys.Out(), c.GetHelp())
+		return 0
+	}
+	list, fields := "", false
+	modes := 0
+	for _, m := range []struct {
+		name  string
+		value string
+		field bool
+	}{{"bytes", *byteList, false}, {"characters", *charList, false}, {"fields", *fieldList, true}} {
+		if flag.Changed(m.name) {
+			modes++
+			list, fields = m.value, m.field
+		}
+	}
+	switch {
+	case modes == 0:
+		fmt.Fprintf(sys.Err(), "cut: you must specify a list of bytes, characters, or fields\nTry 'cut --help' for more information.\n")
+		return 1
+	case modes > 1:
+		fmt.Fprintf(sys.Err(), "cut: only one type of list may be specified\nTry 'cut --help' for more information.\n")
+		return 1
+	case !fields && flag.Changed("delimiter"):
+		fmt.Fprintf(sys.Err(), "cut: an input delimiter may be specified only when operating on fields\nTry 'cut --help' for more information.\n")
+		return 1
+	case len(*delim) != 1:
+		fmt.Fprintf(sys.Err(), "cut: the delimiter must be a single character\nTry 'cut --help' for more information.\n")
+		return 1
+	}
+	ranges, ok := parseCutList(list)
+	if !ok {
+		fmt.Fprintf(sys.Err(), "cut: invalid byte, character or field list\nTry 'cut --help' for more information.\n")
+		return 1
+	}
+	if !flag.Changed("output-delimiter") && fields {
+		*outDelim = *delim
+	}
+
+	end := byte('\n')
+	if *zero {
+		end = 0
+	}
+	files := flag.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	status := 0
+	var buf bytes.Buffer
+	for _, name := range files {
+		data, ok := headOpen(sys, "cut", name)
+		if !ok {
+			status = 1
+			continue
+		}
+		for _, line := range headLines(data, end) {
+			line = bytes.TrimSuffix(line, []byte{end})
+			if !fields {
+				// Characters are bytes in cut of coreutils
+				last := -1
+				for i := range line {
+					if cutSelected(ranges, i+1, *complement) {
+						if last >= 0 && last != i-1 && *outDelim != "" {
+							buf.WriteString(*outDelim)
+						}
+						buf.WriteByte(line[i])
+						last = i
+					}
+				}
+				buf.WriteByte(end)
+				continue
+			}
+			parts := bytes.Split(line, []byte(*delim))
+			if len(parts) == 1 {
+				if !*onlyDelimited {
+					buf.Write(line)
+					buf.WriteByte(end)
+				}
+				continue
+			}
+			first := true
+			for i, part := range parts {
+				if !cutSelected(ranges, i+1, *complement) {
+					continue
+				}
+				if !first {
+					buf.WriteString(*outDelim)
+				}
+				buf.Write(part)
+				first = false
+			}
+			buf.WriteByte(end)
+		}
+	}
+	sys.Out().Write(buf.Bytes())
+	return status
+}
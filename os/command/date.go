@@ -0,0 +1,460 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/pflag"
+)
+
+type date struct{}
+
+// dateLayouts are the absolute dates date -d and -s understand
+var dateLayouts = []string{
+	"2006-01-02 15:04:05.999999999", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05", "2006-01-02", "2006/01/02 15:04:05", "2006/01/02", "01/02/2006 15:04:05", "01/02/2006",
+	"01/02/06", "15:04:05", "15:04", "Jan 2 2006 15:04:05", "Jan 2 2006", "Jan 2, 2006", "2 Jan 2006", "January 2 2006",
+	"Mon Jan _2 15:04:05 MST 2006", "Mon Jan _2 15:04:05 2006", "Mon, 02 Jan 2006 15:04:05 -0700", "Mon Jan 2 2006",
+	time.RFC1123, time.RFC3339Nano, "20060102", "20060102 15:04", "20060102 15:04:05",
+}
+
+// dateRelative matches the relative items like "+3 days" or "2 hours ago"
+var dateRelative = regexp.MustCompile(`^([+-]?\d*)\s*(sec|secs|second|seconds|min|mins|minute|minutes|hour|hours|day|days|` +
+	`week|weeks|fortnight|fortnights|month|months|year|years)(\s+ago)?$`)
+
+func init() {
+	honeyos.RegisterCommand("date", date{})
+}
+
+func (date) GetHelp() string {
+	return `Usage: date [OPTION]... [+FORMAT]
+  or:  date [-u|--utc|--universal] [MMDDhhmm[[CC]YY][.ss]]
+Display the current time in the given FORMAT, or set the system date.
+
+Mandatory arguments to long options are mandatory for short options too.
+  -d, --date=STRING          display time described by STRING, not 'now'
+  -f, --file=DATEFILE        like --date; once for each line of DATEFILE
+  -I[FMT], --iso-8601[=FMT]  output date/time in ISO 8601 format.
+                               FMT='date' for date only (the default),
+                               'hours', 'minutes', 'seconds', or 'ns'
+                               for date and time to the indicated precision.
+                               Example: 2006-08-14T02:34:56-06:00
+  -R, --rfc-email            output date and time in RFC 5322 format.
+                               Example: Mon, 14 Aug 2006 02:34:56 -0600
+      --rfc-3339=FMT         output date/time in RFC 3339 format.
+                               FMT='date', 'seconds', or 'ns'
+                               for date and time to the indicated precision.
+                               Example: 2006-08-14 02:34:56-06:00
+  -r, --reference=FILE       display the last modification time of FILE
+  -s, --set=STRING           set time described by STRING
+  -u, --utc, --universal     print or set Coordinated Universal Time (UTC)
+      --help     display this help and exit
+      --version  output version information and exit
+`
+}
+
+func (date) Where() string {
+	return "/bin/date"
+}
+
+func (d date) Exec(args []string, sys honeyos.Sys) int {
+	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	desc := flag.StringP("date", "d", "", "")
+	set := flag.StringP("set", "s", "", "")
+	ref := flag.StringP("reference", "r", "", "")
+	utc := flag.BoolP("utc", "u", false, "")
+	flag.BoolVar(utc, "universal", false, "")
+	rfcEmail := flag.BoolP("rfc-email", "R", false, "")
+	iso := flag.StringP("iso-8601", "I", "", "")
+	flag.Lookup("iso-8601").NoOptDefVal = "date"
+	rfc3339 := flag.String("rfc-3339", "", "")
+	help := flag.Bool("help", false, "")
+	version := flag.Bool("version", false, "")
+	// -I takes its value only when attached, like -Iseconds
+	for i, arg := range args {
+		if strings.HasPrefix(arg, "-I") && len(arg) > 2 {
+			args[i] = "--iso-8601=" + arg[2:]
+		}
+	}
+	if err := flag.Parse(args); err != nil {
+		msg := strings.Replace(err.Error(), "unknown shorthand flag: ", "invalid option -- ", 1)
+		if i := strings.Index(msg, " in -"); i >= 0 {
+			msg = msg[:i]
+		}
+		fmt.Fprintf(sys.Err(), "date: %v\nTry 'date --help' for more information.\n", msg)
+		return 1
+	}
+	switch {
+	case *help:
+		fmt.Fprint(sys.Out(), d.GetHelp())
+		return 0
+	case *version:
+		fmt.Fprintln(sys.Out(), "date (GNU coreutils) 8.32")
+		return 0
+	}
+	now := honeyos.Now(sys)
+	if *utc {
+		now = now.UTC()
+	}
+	loc := now.Location()
+	format := "%a %b %e %H:%M:%S %Z %Y"
+	var operand string
+	for _, arg := range flag.Args() {
+		switch {
+		case strings.HasPrefix(arg, "+") && format == "%a %b %e %H:%M:%S %Z %Y":
+			format = arg[1:]
+		case operand == "" && !strings.HasPrefix(arg, "+"):
+			operand = arg
+		default:
+			fmt.Fprintf(sys.Err(), "date: extra operand '%v'\nTry 'date --help' for more information.\n", arg)
+			return 1
+		}
+	}
+	switch {
+	case *rfcEmail:
+		format = "%a, %d %b %Y %H:%M:%S %z"
+	case *iso != "":
+		f, ok := map[string]string{"date": "%F", "hours": "%FT%H%:z", "minutes": "%FT%H:%M%:z",
+			"seconds": "%FT%T%:z", "ns": "%FT%T,%N%:z"}[*iso]
+		if !ok {
+			fmt.Fprintf(sys.Err(), "date: invalid argument '%v' for '--iso-8601'\n", *iso)
+			return 1
+		}
+		format = f
+	case *rfc3339 != "":
+		f, ok := map[string]string{"date": "%F", "seconds": "%F %T%:z", "ns": "%F %T.%N%:z"}[*rfc3339]
+		if !ok {
+			fmt.Fprintf(sys.Err(), "date: invalid argument '%v' for '--rfc-3339'\n", *rfc3339)
+			return 1
+		}
+		format = f
+	}
+	t := now
+	switch {
+	case flag.Changed("date") && flag.Changed("set"):
+		fmt.Fprintln(sys.Err(), "date: the options to print and set the time may not be used together")
+		return 1
+	case flag.Changed("date"):
+		var ok bool
+		if t, ok = parseDate(*desc, now, loc); !ok {
+			fmt.Fprintf(sys.Err(), "date: invalid date '%v'\n", *desc)
+			return 1
+		}
+	case flag.Changed("reference"):
+		fi, err := sys.FSys().Stat(absPath(sys, *ref))
+		if err != nil {
+			fmt.Fprintf(sys.Err(), "date: %v: No such file or directory\n", *ref)
+			return 1
+		}
+		t = fi.ModTime().In(loc)
+	}
+	status := 0
+	if flag.Changed("set") || operand != "" {
+		var ok bool
+		if operand != "" {
+			t, ok = parsePosixDate(operand, now, loc)
+			if !ok {
+				fmt.Fprintf(sys.Err(), "date: invalid date '%v'\n", operand)
+				return 1
+			}
+		} else if t, ok = parseDate(*set, now, loc); !ok {
+			fmt.Fprintf(sys.Err(), "date: invalid date '%v'\n", *set)
+			return 1
+		}
+		if err := honeyos.SetClock(sys, t); err != nil {
+			sys.Log().WithField("date", t.Format(time.RFC3339)).Warnf("User tried to set the clock to %v", t.Format(time.RFC3339))
+			fmt.Fprintln(sys.Err(), "date: cannot set date: Operation not permitted")
+			status = 1
+		}
+	}
+	fmt.Fprintln(sys.Out(), strftime(t, format))
+	return status
+}
+
+// parseDate parses the date string of date -d, which is an absolute date,
+// relative items like "2 days ago", or both
+func parseDate(s string, now time.Time, loc *time.Location) (time.Time, bool) {
+	s = strings.Join(strings.Fields(strings.ToLower(s)), " ")
+	if strings.HasPrefix(s, "@") {
+		n, err := strconv.ParseFloat(s[1:], 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return time.Unix(0, int64(n*1e9)).In(loc), true
+	}
+	t := now
+	words := strings.Fields(s)
+	// The longest absolute date at the start, then the relative items
+	abs := 0
+	for n := len(words); n > 0; n-- {
+		if parsed, ok := parseAbsDate(strings.Join(words[:n], " "), now, loc); ok {
+			t, abs = parsed, n
+			break
+		}
+	}
+	words = words[abs:]
+	for i := 0; i < len(words); i++ {
+		switch words[i] {
+		case "now", "today":
+			continue
+		case "yesterday":
+			t = t.AddDate(0, 0, -1)
+			continue
+		case "tomorrow":
+			t = t.AddDate(0, 0, 1)
+			continue
+		case "utc", "gmt", "z":
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC).In(loc)
+			continue
+		}
+		item := words[i]
+		if i+1 < len(words) && regexp.MustCompile(`^[+-]?\d+$`).MatchString(words[i]) {
+			i++
+			item += " " + words[i]
+		}
+		if i+1 < len(words) && words[i+1] == "ago" {
+			i++
+			item += " ago"
+		}
+		m := dateRelative.FindStringSubmatch(item)
+		if m == nil {
+			return time.Time{}, false
+		}
+		n := 1
+		if m[1] != "" && m[1] != "+" && m[1] != "-" {
+			n, _ = strconv.Atoi(m[1])
+		} else if m[1] == "-" {
+			n = -1
+		}
+		if m[3] != "" {
+			n = -n
+		}
+		switch unit := strings.TrimSuffix(m[2], "s"); unit {
+		case "sec", "second":
+			t = t.Add(time.Duration(n) * time.Second)
+		case "min", "minute":
+			t = t.Add(time.Duration(n) * time.Minute)
+		case "hour":
+			t = t.Add(time.Duration(n) * time.Hour)
+		case "day":
+			t = t.AddDate(0, 0, n)
+		case "week":
+			t = t.AddDate(0, 0, 7*n)
+		case "fortnight":
+			t = t.AddDate(0, 0, 14*n)
+		case "month":
+			t = t.AddDate(0, n, 0)
+		case "year":
+			t = t.AddDate(n, 0, 0)
+		}
+	}
+	return t, true
+}
+
+// parseAbsDate parses the absolute date in the timezone. Dates without day
+// are today, and those without time are at midnight
+func parseAbsDate(s string, now time.Time, loc *time.Location) (time.Time, bool) {
+	for _, layout := range dateLayouts {
+		t, err := time.ParseInLocation(layout, s, loc)
+		if err != nil {
+			// Month names are matched in any case
+			t, err = time.ParseInLocation(layout, strings.Title(s), loc)
+		}
+		if err != nil {
+			continue
+		}
+		if !strings.Contains(layout, "2006") && !strings.Contains(layout, "06") {
+			t = time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc)
+		}
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// parsePosixDate parses the MMDDhhmm[[CC]YY][.ss] operand setting the date
+func parsePosixDate(s string, now time.Time, loc *time.Location) (time.Time, bool) {
+	sec := 0
+	if i := strings.Index(s, "."); i >= 0 {
+		n, err := strconv.Atoi(s[i+1:])
+		if err != nil || len(s[i+1:]) != 2 {
+			return time.Time{}, false
+		}
+		sec, s = n, s[:i]
+	}
+	if _, err := strconv.Atoi(s); err != nil || len(s) != 8 && len(s) != 10 && len(s) != 12 {
+		return time.Time{}, false
+	}
+	num := func(from, to int) int { n, _ := strconv.Atoi(s[from:to]); return n }
+	year := now.Year()
+	switch len(s) {
+	case 10:
+		year = 1900 + num(8, 10)
+		if year < 1969 {
+			year += 100
+		}
+	case 12:
+		year = num(8, 12)
+	}
+	month, day, hour, min := num(0, 2), num(2, 4), num(4, 6), num(6, 8)
+	if month < 1 || month > 12 || day < 1 || day > 31 || hour > 23 || min > 59 || sec > 60 {
+		return time.Time{}, false
+	}
+	return time.Date(year, time.Month(month), day, hour, min, sec, 0, loc), true
+}
+
+// strftime formats the time like strftime(3), with the flags and widths of
+// GNU date like %-d and %_H
+func strftime(t time.Time, format string) string {
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			b.WriteByte(format[i])
+			continue
+		}
+		start := i
+		i++
+		pad, upper := byte(0), false
+		for ; i < len(format) && strings.IndexByte("-_0^#", format[i]) >= 0; i++ {
+			if format[i] == '^' {
+				upper = true
+			} else {
+				pad = format[i]
+			}
+		}
+		width := 0
+		for ; i < len(format) && format[i] >= '0' && format[i] <= '9'; i++ {
+			width = width*10 + int(format[i]-'0')
+		}
+		colons := 0
+		for ; i < len(format) && format[i] == ':'; i++ {
+			colons++
+		}
+		if i == len(format) {
+			b.WriteString(format[start:])
+			break
+		}
+		num := func(n, digits int, defPad byte) string {
+			if width > 0 {
+				digits = width
+			}
+			if pad != 0 {
+				defPad = pad
+			}
+			switch defPad {
+			case '-':
+				return strconv.Itoa(n)
+			case '_':
+				return fmt.Sprintf("%*d", digits, n)
+			}
+			return fmt.Sprintf("%0*d", digits, n)
+		}
+		var s string
+		switch c := format[i]; c {
+		case 'a':
+			s = t.Format("Mon")
+		case 'A':
+			s = t.Format("Monday")
+		case 'b', 'h':
+			s = t.Format("Jan")
+		case 'B':
+			s = t.Format("January")
+		case 'c':
+			s = strftime(t, "%a %d %b %Y %r %Z")
+		case 'C':
+			s = num(t.Year()/100, 2, '0')
+		case 'd':
+			s = num(t.Day(), 2, '0')
+		case 'D':
+			s = strftime(t, "%m/%d/%y")
+		case 'e':
+			s = num(t.Day(), 2, '_')
+		case 'F':
+			s = strftime(t, "%Y-%m-%d")
+		case 'g':
+			year, _ := t.ISOWeek()
+			s = num(year%100, 2, '0')
+		case 'G':
+			year, _ := t.ISOWeek()
+			s = num(year, 4, '0')
+		case 'H':
+			s = num(t.Hour(), 2, '0')
+		case 'I':
+			s = num((t.Hour()+11)%12+1, 2, '0')
+		case 'j':
+			s = num(t.YearDay(), 3, '0')
+		case 'k':
+			s = num(t.Hour(), 2, '_')
+		case 'l':
+			s = num((t.Hour()+11)%12+1, 2, '_')
+		case 'm':
+			s = num(int(t.Month()), 2, '0')
+		case 'M':
+			s = num(t.Minute(), 2, '0')
+		case 'n':
+			s = "\n"
+		case 'N':
+			s = fmt.Sprintf("%09d", t.Nanosecond())
+			if width > 0 && width < 9 {
+				s = s[:width]
+			}
+		case 'p':
+			s = t.Format("PM")
+		case 'P':
+			s = strings.ToLower(t.Format("PM"))
+		case 'r':
+			s = strftime(t, "%I:%M:%S %p")
+		case 'R':
+			s = strftime(t, "%H:%M")
+		case 's':
+			s = strconv.FormatInt(t.Unix(), 10)
+		case 'S':
+			s = num(t.Second(), 2, '0')
+		case 't':
+			s = "\t"
+		case 'T':
+			s = strftime(t, "%H:%M:%S")
+		case 'u':
+			s = strconv.Itoa((int(t.Weekday())+6)%7 + 1)
+		case 'U':
+			s = num((t.YearDay()+6-int(t.Weekday()))/7, 2, '0')
+		case 'V':
+			_, week := t.ISOWeek()
+			s = num(week, 2, '0')
+		case 'w':
+			s = strconv.Itoa(int(t.Weekday()))
+		case 'W':
+			s = num((t.YearDay()+6-(int(t.Weekday())+6)%7)/7, 2, '0')
+		case 'x':
+			s = strftime(t, "%m/%d/%y")
+		case 'X':
+			s = strftime(t, "%r")
+		case 'y':
+			s = num(t.Year()%100, 2, '0')
+		case 'Y':
+			s = num(t.Year(), 1, '0')
+		case 'z':
+			s = [...]string{"-0700", "-07:00", "-07:00:00", "-07"}[colons%4]
+			s = t.Format(s)
+		case 'Z':
+			s = t.Format("MST")
+		case '%':
+			s = "%"
+		default:
+			s = format[start : i+1]
+		}
+		if upper {
+			s = strings.ToUpper(s)
+		}
+		if width > len(s) && strings.IndexByte("aAbBhpPZ", format[i]) >= 0 {
+			s = strings.Repeat(" ", width-len(s)) + s
+		}
+		b.WriteString(s)
+	}
+	return b.String()
+}
@@ -0,0 +1,412 @@
+package command
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// dbServer is a database server of persona.databases, which takes the user
+// and password given, * for any
+type dbServer struct {
+	engine, addr, user, password string
+}
+
+// dbServers finds the servers of the engine at the address, which are there
+// even if nothing listens on the port
+func dbServers(sys honeyos.Sys, engine string, ip net.IP) []dbServer {
+	var servers []dbServer
+	for _, entry := range viper.GetStringSlice("persona.databases") {
+		fields := strings.Fields(entry)
+		if len(fields) < 2 || fields[0] != engine {
+			continue
+		}
+		s := dbServer{engine: fields[0], addr: fields[1], user: "*", password: "*"}
+		if len(fields) > 2 {
+			s.user = fields[2]
+		}
+		if len(fields) > 3 {
+			s.password = fields[3]
+		}
+		if addr := netResolve(sys, s.addr); addr != nil && addr.Equal(ip) ||
+			s.addr == "127.0.0.1" && ip.IsLoopback() {
+			servers = append(servers, s)
+		}
+	}
+	return servers
+}
+
+// dbPorts are the ports the servers of persona.databases listen on
+var dbPorts = map[string]int{"mysql": 3306, "postgres": 5432, "redis": 6379}
+
+// dbReach tells if the port of the server accepts connections, and if the
+// host answers at all
+func dbReach(sys honeyos.Sys, engine string, ip net.IP, port int) (open, reachable bool) {
+	if port == dbPorts[engine] && len(dbServers(sys, engine, ip)) > 0 {
+		return true, true
+	}
+	return netPortOpen(sys, ip, port)
+}
+
+// dbLogin tells if the server takes the credentials, which are logged either
+// way. Servers not in persona.databases deny everyone
+func dbLogin(sys honeyos.Sys, engine, host string, ip net.IP, user, password string) bool {
+	accepted := false
+	for _, s := range dbServers(sys, engine, ip) {
+		if (s.user == "*" || s.user == user) && (s.password == "*" || s.password == password) {
+			accepted = true
+		}
+	}
+	sys.Log().WithFields(log.Fields{"host": host, "user": user, "password": password, "accepted": accepted}).
+		Infof("User tried %v password for %v@%v", engine, user, host)
+	return accepted
+}
+
+// dbTable is a table of the fake databases, with the values of its rows as
+// text. numeric columns are aligned to the right
+type dbTable struct {
+	name    string
+	cols    []string
+	numeric []bool
+	rows    [][]string
+}
+
+// dbSchema is a database and its tables
+type dbSchema struct {
+	name   string
+	tables []*dbTable
+}
+
+// dbResult is what the query returns. Statements other than SELECT have no
+// columns, affected is the count of rows they changed and tag is how psql
+// reports them, like INSERT 0 1
+type dbResult struct {
+	cols     []string
+	numeric  []bool
+	rows     [][]string
+	affected int
+	tag      string
+}
+
+const (
+	dbErrSyntax = iota
+	dbErrNoTable
+	dbErrNoColumn
+	dbErrNoDB
+)
+
+// dbError is the failure of the query, which each client reports its way.
+// name is the table or column not found, or the text near the syntax error
+type dbError struct {
+	kind int
+	name string
+}
+
+func (e dbError) Error() string {
+	return fmt.Sprintf("error %v near %v", e.kind, e.name)
+}
+
+// dbSession is the connection of the client to the server, with the data of
+// the server made up for the session
+type dbSession struct {
+	sys                honeyos.Sys
+	engine, host, user string
+	db                 string
+	schemas            []*dbSchema
+	funcs              map[string]string
+	postgres           bool
+}
+
+// newDBSession connects to the database db, with the tables of the
+// application and the databases the engine comes with
+func newDBSession(sys honeyos.Sys, engine, host, user, db string, system ...*dbSchema) *dbSession {
+	s := &dbSession{sys: sys, engine: engine, host: host, user: user, db: db, postgres: engine == "postgres"}
+	s.schemas = append(system, dbAppSchema(sys))
+	return s
+}
+
+// schema returns the database of the name, nil if there is none
+func (s *dbSession) schema(name string) *dbSchema {
+	for _, d := range s.schemas {
+		if strings.EqualFold(d.name, name) {
+			return d
+		}
+	}
+	return nil
+}
+
+// table finds the table, which is in the current database unless qualified
+// as db.table
+func (s *dbSession) table(name string) (*dbTable, error) {
+	db, table := s.db, name
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		db, table = name[:i], name[i+1:]
+		if s.postgres && db == "public" {
+			db = s.db
+		}
+	} else if db == "" {
+		return nil, dbError{dbErrNoDB, ""}
+	}
+	dbs := []string{db}
+	if s.postgres {
+		// The catalog is in the search path of every database
+		dbs = append(dbs, "pg_catalog")
+	}
+	for _, name := range dbs {
+		if d := s.schema(name); d != nil {
+			for _, t := range d.tables {
+				if strings.EqualFold(t.name, table) {
+					return t, nil
+				}
+			}
+		}
+	}
+	if !s.postgres && !strings.Contains(name, ".") {
+		name = s.db + "." + name
+	}
+	return nil, dbError{dbErrNoTable, name}
+}
+
+// query runs the statement. SELECT returns the rows of the fake tables and
+// functions, the other statements succeed without changing anything
+func (s *dbSession) query(q string) (*dbResult, error) {
+	q = strings.TrimSpace(strings.TrimRight(strings.TrimSpace(q), ";"))
+	s.log(q)
+	words := strings.Fields(strings.ToLower(q))
+	if len(words) == 0 {
+		return nil, dbError{dbErrSyntax, ""}
+	}
+	switch words[0] {
+	case "select":
+		return s.selectRows(q[len("select"):])
+	case "insert":
+		return &dbResult{affected: 1, tag: "INSERT 0 1"}, nil
+	case "update", "delete":
+		return &dbResult{tag: strings.ToUpper(words[0]) + " 0"}, nil
+	case "create", "drop", "alter", "truncate":
+		tag := strings.ToUpper(words[0])
+		if len(words) > 1 {
+			tag += " " + strings.ToUpper(words[1])
+		}
+		return &dbResult{tag: tag}, nil
+	case "grant", "revoke", "set", "flush", "begin", "start", "commit", "rollback", "lock", "unlock", "load", "copy":
+		return &dbResult{tag: strings.ToUpper(words[0])}, nil
+	}
+	return nil, dbError{dbErrSyntax, dbNear(q)}
+}
+
+// log records the query, including those the client answers itself
+func (s *dbSession) log(q string) {
+	s.sys.Log().WithFields(log.Fields{"query": q, "database": s.db, "host": s.host}).
+		Infof("User queried %v database: %v", s.engine, q)
+}
+
+// dbNear is the start of the text the syntax error is near
+func dbNear(q string) string {
+	if fields := strings.Fields(q); len(fields) > 0 {
+		return fields[0]
+	}
+	return q
+}
+
+// selectRows runs SELECT, with the expressions before FROM and the table,
+// WHERE of a column equal to a value and LIMIT after it
+func (s *dbSession) selectRows(q string) (*dbResult, error) {
+	lower := strings.ToLower(q)
+	from := strings.Index(lower, " from ")
+	if from < 0 {
+		return s.selectValues(q)
+	}
+	list, rest := strings.TrimSpace(q[:from]), strings.Fields(q[from+len(" from "):])
+	if len(rest) == 0 {
+		return nil, dbError{dbErrSyntax, ""}
+	}
+	t, err := s.table(strings.Trim(rest[0], "`\""))
+	if err != nil {
+		return nil, err
+	}
+	rows := t.rows
+	limit := -1
+	for i := 1; i < len(rest); i++ {
+		switch strings.ToLower(rest[i]) {
+		case "where":
+			cond := strings.Join(rest[i+1:], " ")
+			if eq := strings.IndexByte(cond, '='); eq > 0 {
+				col := strings.Trim(strings.TrimSpace(cond[:eq]), "`\"")
+				value := strings.Fields(cond[eq+1:] + " ")
+				if len(value) > 0 {
+					filtered, err := t.where(col, strings.Trim(value[0], "'\""))
+					if err != nil {
+						return nil, err
+					}
+					rows = filtered
+				}
+			}
+		case "limit":
+			if i+1 < len(rest) {
+				n := rest[i+1]
+				if c := strings.IndexByte(n, ','); c >= 0 {
+					n = n[c+1:]
+				}
+				limit, _ = strconv.Atoi(n)
+			}
+		}
+	}
+	if limit >= 0 && limit < len(rows) {
+		rows = rows[:limit]
+	}
+	if strings.EqualFold(strings.Replace(list, " ", "", -1), "count(*)") {
+		return &dbResult{cols: []string{list}, numeric: []bool{true}, rows: [][]string{{strconv.Itoa(len(rows))}}}, nil
+	}
+	var index []int
+	for _, name := range strings.Split(list, ",") {
+		name = strings.Trim(strings.TrimSpace(name), "`\"")
+		if name == "*" {
+			for i := range t.cols {
+				index = append(index, i)
+			}
+			continue
+		}
+		i := t.column(name)
+		if i < 0 {
+			return nil, dbError{dbErrNoColumn, name}
+		}
+		index = append(index, i)
+	}
+	res := &dbResult{}
+	for _, i := range index {
+		res.cols = append(res.cols, t.cols[i])
+		res.numeric = append(res.numeric, t.numeric[i])
+	}
+	for _, row := range rows {
+		var values []string
+		for _, i := range index {
+			values = append(values, row[i])
+		}
+		res.rows = append(res.rows, values)
+	}
+	return res, nil
+}
+
+// selectValues runs SELECT without table, of the functions telling about
+// the server and literals
+func (s *dbSession) selectValues(list string) (*dbResult, error) {
+	res := &dbResult{rows: [][]string{nil}}
+	for _, expr := range strings.Split(list, ",") {
+		expr = strings.TrimSpace(expr)
+		key := strings.ToLower(strings.Replace(expr, " ", "", -1))
+		value, ok := s.funcs[key]
+		numeric := false
+		switch {
+		case ok:
+		case strings.HasPrefix(expr, "'") && strings.HasSuffix(expr, "'") && len(expr) > 1:
+			value = expr[1 : len(expr)-1]
+		case key == "now()" || key == "current_timestamp" || key == "current_timestamp()":
+			value = honeyos.Now(s.sys).Format("2006-01-02 15:04:05")
+		case key == "database()" || key == "current_database()":
+			value = s.db
+		default:
+			if _, err := strconv.ParseFloat(expr, 64); err != nil {
+				if s.postgres {
+					return nil, dbError{dbErrNoColumn, expr}
+				}
+				return nil, dbError{dbErrSyntax, expr}
+			}
+			value, numeric = expr, true
+		}
+		label := expr
+		if s.postgres {
+			label = "?column?"
+			if i := strings.IndexByte(expr, '('); i > 0 {
+				label = strings.ToLower(expr[:i])
+			}
+		}
+		res.cols = append(res.cols, label)
+		res.numeric = append(res.numeric, numeric)
+		res.rows[0] = append(res.rows[0], value)
+	}
+	return res, nil
+}
+
+// column finds the column of the name, -1 if there is none
+func (t *dbTable) column(name string) int {
+	for i, c := range t.cols {
+		if strings.EqualFold(c, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (t *dbTable) where(col, value string) ([][]string, error) {
+	i := t.column(col)
+	if i < 0 {
+		return nil, dbError{dbErrNoColumn, col}
+	}
+	var rows [][]string
+	for _, row := range t.rows {
+		if row[i] == value {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// dbHash makes up the hash of the password of the account, stored the way
+// the application would with bcrypt
+func dbHash(sys honeyos.Sys, name string) string {
+	sum := sha256.Sum256([]byte("syrup " + sys.Hostname() + " " + name))
+	s := base64.RawStdEncoding.EncodeToString(append(sum[:], sum[:]...))
+	s = strings.NewReplacer("+", ".").Replace(s)
+	return "$2y$10$" + s[:53]
+}
+
+// mysqlHash makes up the hash of MySQL native password, as in mysql.user
+func mysqlHash(sys honeyos.Sys, name string) string {
+	sum := sha1.Sum([]byte("syrup " + sys.Hostname() + " " + name))
+	return fmt.Sprintf("*%X", sum)
+}
+
+// dbAppSchema is the database of the web application on the machine, with
+// its users, orders and settings
+func dbAppSchema(sys honeyos.Sys) *dbSchema {
+	users := &dbTable{name: "users", cols: []string{"id", "username", "email", "password", "created_at"},
+		numeric: []bool{true, false, false, false, false}}
+	for i, u := range []string{"admin", "jsmith", "mgarcia", "dwilson", "support"} {
+		email := u + "@example.com"
+		if u == "jsmith" {
+			email = "john.smith@example.com"
+		}
+		created := time.Date(2019, 3, 14, 9, 21, 45, 0, time.UTC).Add(time.Duration(i*37) * 24 * time.Hour)
+		users.rows = append(users.rows, []string{strconv.Itoa(i + 1), u, email, dbHash(sys, u),
+			created.Format("2006-01-02 15:04:05")})
+	}
+	orders := &dbTable{name: "orders", cols: []string{"id", "user_id", "total", "status", "created_at"},
+		numeric: []bool{true, true, true, false, false}}
+	statuses := []string{"paid", "shipped", "paid", "refunded", "pending", "shipped"}
+	for i, status := range statuses {
+		created := time.Date(2020, 6, 2, 14, 5, 11, 0, time.UTC).Add(time.Duration(i*53) * time.Hour)
+		orders.rows = append(orders.rows, []string{strconv.Itoa(1001 + i), strconv.Itoa(2 + i%3),
+			fmt.Sprintf("%d.%02d", 20+int(fnvString(status)%180)+i*7, (i*37)%100), status,
+			created.Format("2006-01-02 15:04:05")})
+	}
+	settings := &dbTable{name: "settings", cols: []string{"name", "value"}, numeric: []bool{false, false},
+		rows: [][]string{
+			{"site_name", "Example Store"},
+			{"site_url", "https://www.example.com"},
+			{"smtp_host", "mail.example.com"},
+			{"smtp_user", "noreply@example.com"},
+			{"smtp_password", "Summer2019!"},
+			{"backup_path", "/var/backups/app"},
+		}}
+	return &dbSchema{name: "app", tables: []*dbTable{orders, settings, users}}
+}
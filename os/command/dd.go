@@ -0,0 +1,630 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// dd copies the input to the output by blocks. Special files of /dev and
+// the disks are simulated, and copies take the time they would at the speed
+// of the devices. Only so much of what is written is kept in the virtual
+// filesystem, while the summary tells the full size
+type dd struct{}
+
+// ddOperands are the operands of dd
+type ddOperands struct {
+	in, out         string
+	ibs, obs        int64
+	count, skip     int64
+	seek            int64
+	hasCount        bool
+	notrunc, noxfer bool
+	ucase, lcase    bool
+	quiet, progress bool
+	countBytes      bool
+	skipBytes       bool
+	seekBytes       bool
+	excl, nocreat   bool
+}
+
+// ddDevice matches the block devices, written by those wiping the machine
+var ddDevice = regexp.MustCompile(`^/dev/(sd[a-z]+|hd[a-z]+|vd[a-z]+|xvd[a-z]+|nvme[0-9]+n[0-9]+(p[0-9]+)?|mmcblk[0-9]+(p[0-9]+)?|mapper/.+|dm-[0-9]+|md[0-9]+)[0-9]*$`)
+
+// ddUnits are the suffixes of the numbers of the operands
+var ddUnits = map[string]int64{
+	"c": 1, "w": 2, "b": 512, "kB": 1000, "k": 1024, "K": 1024, "KiB": 1024, "MB": 1000 * 1000, "M": 1 << 20, "MiB": 1 << 20,
+	"GB": 1000 * 1000 * 1000, "G": 1 << 30, "GiB": 1 << 30, "TB": 1000 * 1000 * 1000 * 1000, "T": 1 << 40, "TiB": 1 << 40,
+}
+
+func init() {
+	honeyos.RegisterCommand("dd", dd{})
+}
+
+func (dd) GetHelp() string {
+	return "Usage: dd [OPERAND]...\n  or:  dd OPTION\nCopy a file, converting and formatting according to the operands.\n\n" +
+		"  bs=BYTES        read and write up to BYTES bytes at a time (default: 512);\n" +
+		"                  overrides ibs and obs\n" +
+		"  cbs=BYTES       convert BYTES bytes at a time\n" +
+		"  conv=CONVS      convert the file as per the comma separated symbol list\n" +
+		"  count=N         copy only N input blocks\n" +
+		"  ibs=BYTES       read up to BYTES bytes at a time (default: 512)\n" +
+		"  if=FILE         read from FILE instead of stdin\n" +
+		"  iflag=FLAGS     read as per the comma separated symbol list\n" +
+		"  obs=BYTES       write BYTES bytes at a time (default: 512)\n" +
+		"  of=FILE         write to FILE instead of stdout\n" +
+		"  oflag=FLAGS     write as per the comma separated symbol list\n" +
+		"  seek=N          skip N obs-sized blocks at start of output\n" +
+		"  skip=N          skip N ibs-sized blocks at start of input\n" +
+		"  status=LEVEL    The LEVEL of information to print to stderr;\n" +
+		"                  'none' suppresses everything but error messages,\n" +
+		"                  'noxfer' suppresses the final transfer statistics,\n" +
+		"                  'progress' shows periodic transfer statistics\n\n" +
+		"N and BYTES may be followed by the following multiplicative suffixes:\n" +
+		"c =1, w =2, b =512, kB =1000, K =1024, MB =1000*1000, M =1024*1024, xM =M\n" +
+		"GB =1000*1000*1000, G =1024*1024*1024, and so on for T, P, E, Z, Y.\n"
+}
+
+func (dd) Where() string {
+	return "/bin/dd"
+}
+
+// ddNumber parses the number of the operands with the suffixes, and the
+// products like 2x512
+func ddNumber(s string) (int64, bool) {
+	n := int64(1)
+	for _, part := range strings.Split(s, "x") {
+		mult := int64(1)
+		for suffix, m := range ddUnits {
+			if strings.HasSuffix(part, suffix) && len(part) > len(suffix) {
+				part, mult = strings.TrimSuffix(part, suffix), m
+				break
+			}
+		}
+		v, err := strconv.ParseInt(part, 10, 64)
+		if err != nil || v < 0 {
+			return 0, false
+		}
+		n *= v * mult
+	}
+	return n, true
+}
+
+// parse reads the operands, printing the error if any
+func (dd) parse(sys honeyos.Sys, args []string) (ddOperands, bool) {
+	op := ddOperands{ibs: 512, obs: 512}
+	for _, arg := range args {
+		i := strings.Index(arg, "=")
+		if i < 0 {
+			fmt.Fprintf(sys.Err(), "dd: unrecognized operand ‘%v’\nTry 'dd --help' for more information.\n", arg)
+			return op, false
+		}
+		key, val := arg[:i], arg[i+1:]
+		number := func(dst *int64) bool {
+			n, ok := ddNumber(val)
+			if !ok || n == 0 && (key == "bs" || key == "ibs" || key == "obs") {
+				fmt.Fprintf(sys.Err(), "dd: invalid number: ‘%v’\n", val)
+				return false
+			}
+			*dst = n
+			return true
+		}
+		symbols := func(f func(string) bool) bool {
+			for _, sym := range strings.Split(val, ",") {
+				if !f(sym) {
+					fmt.Fprintf(sys.Err(), "dd: invalid %v: ‘%v’\nTry 'dd --help' for more information.\n", map[string]string{
+						"conv": "conversion", "iflag": "input flag", "oflag": "output flag"}[key], sym)
+					return false
+				}
+			}
+			return true
+		}
+		flags := func(sym string) bool {
+			switch sym {
+			case "count_bytes":
+				op.countBytes = true
+			case "skip_bytes":
+				op.skipBytes = true
+			case "seek_bytes":
+				op.seekBytes = true
+			case "fullblock", "append", "direct", "directory", "dsync", "sync", "nocache", "nonblock", "noatime", "noctty", "nofollow", "binary", "text":
+			default:
+				return false
+			}
+			return true
+		}
+		ok := true
+		switch key {
+		case "if":
+			op.in = val
+		case "of":
+			op.out = val
+		case "bs":
+			if ok = number(&op.ibs); ok {
+				op.obs = op.ibs
+			}
+		case "ibs":
+			ok = number(&op.ibs)
+		case "obs":
+			ok = number(&op.obs)
+		case "cbs":
+			var cbs int64
+			ok = number(&cbs)
+		case "count":
+			ok, op.hasCount = number(&op.count), true
+		case "skip":
+			ok = number(&op.skip)
+		case "seek":
+			ok = number(&op.seek)
+		case "status":
+			switch val {
+			case "none":
+				op.quiet = true
+			case "noxfer":
+				op.noxfer = true
+			case "progress":
+				op.progress = true
+			default:
+				fmt.Fprintf(sys.Err(), "dd: invalid status level: ‘%v’\nTry 'dd --help' for more information.\n", val)
+				return op, false
+			}
+		case "conv":
+			ok = symbols(func(sym string) bool {
+				switch sym {
+				case "notrunc":
+					op.notrunc = true
+				case "ucase":
+					op.ucase = true
+				case "lcase":
+					op.lcase = true
+				case "excl":
+					op.excl = true
+				case "nocreat":
+					op.nocreat = true
+				case "fsync", "fdatasync", "sync", "noerror", "sparse", "swab", "ascii", "ebcdic", "ibm", "block", "unblock":
+				default:
+					return false
+				}
+				return true
+			})
+		case "iflag", "oflag":
+			ok = symbols(flags)
+		default:
+			fmt.Fprintf(sys.Err(), "dd: unrecognized operand ‘%v’\nTry 'dd --help' for more information.\n", arg)
+			return op, false
+		}
+		if !ok {
+			return op, false
+		}
+	}
+	if op.ucase && op.lcase {
+		fmt.Fprintln(sys.Err(), "dd: cannot combine lcase and ucase")
+		return op, false
+	}
+	return op, true
+}
+
+// ddDiskSize returns the size of the disk or partition, if the machine has
+// it. Whole disks hold the filesystems mounted from them or from LVM
+func ddDiskSize(sys honeyos.Sys, dev string) (int64, bool) {
+	var total int64
+	found := false
+	for _, m := range sys.Mounts() {
+		if !dfDisk(m.Type) {
+			continue
+		}
+		if m.Device == dev {
+			return m.Size, true
+		}
+		if strings.HasPrefix(m.Device, dev) || strings.HasPrefix(m.Device, "/dev/mapper/") && strings.TrimRight(dev, "0123456789") == dev {
+			total += m.Size
+			found = true
+		}
+	}
+	// The partition table and what is not mounted take some more
+	return total + total/50, found
+}
+
+// ddSource is the input of the copy
+type ddSource struct {
+	data []byte
+	// size is the bytes the input has, -1 for endless ones like /dev/zero
+	size   int64
+	random bool
+	// rate is the bytes read per second
+	rate float64
+}
+
+// ddOpenError prints the error of the file that cannot be opened
+func ddOpenError(sys honeyos.Sys, name string, err error) int {
+	reason := "No such file or directory"
+	if os.IsPermission(err) {
+		reason = "Permission denied"
+	}
+	fmt.Fprintf(sys.Err(), "dd: failed to open '%v': %v\n", name, reason)
+	return 1
+}
+
+// source opens the input, skipping the blocks of skip
+func (dd) source(sys honeyos.Sys, op ddOperands) (ddSource, int) {
+	skip := op.skip * op.ibs
+	if op.skipBytes {
+		skip = op.skip
+	}
+	src := ddSource{size: -1, rate: 2.5e9}
+	switch {
+	case op.in == "/dev/zero" || op.in == "/dev/full":
+	case op.in == "/dev/urandom" || op.in == "/dev/random":
+		src.random, src.rate = true, 1.6e8
+	case op.in == "/dev/null":
+		src.size = 0
+	case ddDevice.MatchString(op.in) || op.in == "/dev/mem":
+		size, ok := ddDiskSize(sys, op.in)
+		if op.in == "/dev/mem" {
+			size, ok = 1<<20, true
+		}
+		if !ok {
+			return src, ddOpenError(sys, op.in, os.ErrNotExist)
+		}
+		if !isRoot(sys) {
+			return src, ddOpenError(sys, op.in, os.ErrPermission)
+		}
+		sys.Log().WithField("device", op.in).Warnf("User reading disk %v with dd", op.in)
+		src.size, src.rate = size-skip, 1.8e8
+		if src.size < 0 {
+			src.size = 0
+		}
+	default:
+		var data []byte
+		if op.in == "" || op.in == "/dev/stdin" {
+			data, _ = ioutil.ReadAll(sys.In())
+		} else {
+			p := absPath(sys, op.in)
+			if fi, err := sys.FSys().Stat(p); err == nil && fi.IsDir() {
+				fmt.Fprintf(sys.Err(), "dd: error reading '%v': Is a directory\n", op.in)
+				return src, 1
+			}
+			var err error
+			if data, err = readFile(sys, p); err != nil {
+				return src, ddOpenError(sys, op.in, err)
+			}
+		}
+		if skip > int64(len(data)) {
+			fmt.Fprintf(sys.Err(), "dd: '%v': cannot skip to specified offset\n", ddName(op.in, "standard input"))
+			skip = int64(len(data))
+		}
+		src.data, src.size, src.rate = data[skip:], int64(len(data))-skip, 1.5e9
+	}
+	return src, 0
+}
+
+// ddName is the name of the file, or what it is shown as if not given
+func ddName(name, std string) string {
+	if name == "" {
+		return std
+	}
+	return name
+}
+
+func (d dd) Exec(args []string, sys honeyos.Sys) int {
+	if len(args) == 1 && args[0] == "--help" {
+		fmt.Fprint(sys.Out(), d.GetHelp())
+		return 0
+	}
+	op, ok := d.parse(sys, args)
+	if !ok {
+		return 1
+	}
+	wipe := ddDevice.MatchString(op.out) || op.out == "/dev/mem" || op.out == "/dev/kmem" || op.out == "/dev/port"
+	if wipe {
+		sys.Log().WithFields(log.Fields{"device": op.out, "input": ddName(op.in, "stdin")}).
+			Errorf("User writing over disk %v with dd", op.out)
+	}
+	src, status := d.source(sys, op)
+	if status != 0 {
+		return status
+	}
+
+	// total is the bytes to copy, -1 until the output is full or the copy
+	// is interrupted
+	total := src.size
+	if op.hasCount {
+		n := op.count * op.ibs
+		if op.countBytes {
+			n = op.count
+		}
+		if total < 0 || n < total {
+			total = n
+		}
+	}
+	seek := op.seek * op.obs
+	if op.seekBytes {
+		seek = op.seek
+	}
+	// capacity is the room of the output, -1 if unlimited
+	capacity, rate := int64(-1), 0.0
+	var out io.Writer
+	var keep func([]byte) error
+	switch {
+	case op.out == "" || op.out == "/dev/stdout":
+		out, rate = sys.Out(), 5e8
+		if honeyos.IsTerminal(sys.Out()) {
+			rate = 4e7
+		}
+	case op.out == "/dev/null":
+	case op.out == "/dev/full":
+		capacity = 0
+	case op.out == "/dev/stderr":
+		out, rate = sys.Err(), 4e7
+	case wipe:
+		size, ok := ddDiskSize(sys, op.out)
+		if op.out != "/dev/mem" && op.out != "/dev/kmem" && op.out != "/dev/port" && !ok {
+			// Writing to nothing creates the file under /dev
+			break
+		}
+		if !isRoot(sys) {
+			return ddOpenError(sys, op.out, os.ErrPermission)
+		}
+		if !ok {
+			fmt.Fprintf(sys.Err(), "dd: failed to open '%v': Operation not permitted\n", op.out)
+			return 1
+		}
+		capacity, rate = size, 1.4e8
+	}
+	if capacity < 0 && rate == 0 && out == nil && op.out != "/dev/null" {
+		var errCode int
+		if capacity, keep, errCode = d.target(sys, op, seek); errCode != 0 {
+			return errCode
+		}
+		rate = 4e8
+	}
+
+	// The output fills up or the copy goes on until interrupted
+	full := false
+	written := total
+	if capacity >= 0 && (total < 0 || seek+total > capacity) {
+		written, full = capacity-seek, true
+		if written < 0 {
+			written = 0
+		}
+	}
+	if rate > 0 {
+		rate = 1 / (1/rate + 1/src.rate)
+	} else {
+		rate = src.rate
+	}
+	// Speeds vary a little from run to run
+	rate *= 0.9 + rand.Float64()/5
+	elapsed, interrupted := d.wait(sys, op, written, rate)
+	if interrupted {
+		written, full = int64(elapsed.Seconds()*rate), false
+		if total >= 0 && written > total {
+			written = total
+		}
+	}
+	read := written
+	if full && total >= 0 && !interrupted {
+		// What could not be written was still read
+		read = written + op.ibs - written%op.ibs
+		if written%op.ibs == 0 {
+			read = written + op.ibs
+		}
+		if read > total {
+			read = total
+		}
+	}
+
+	if data := d.data(src, written, op); len(data) > 0 {
+		if out != nil {
+			out.Write(data)
+		} else if keep != nil {
+			if err := keep(data); err != nil {
+				sys.Log().WithError(err).Error("Cannot write output of dd")
+			}
+		}
+	}
+	status = 0
+	if full {
+		fmt.Fprintf(sys.Err(), "dd: error writing '%v': No space left on device\n", ddName(op.out, "standard output"))
+		status = 1
+	}
+	if !op.quiet {
+		d.summary(sys, read, written, op, elapsed, !op.noxfer)
+	}
+	if interrupted {
+		return 130
+	}
+	return status
+}
+
+// target opens the file of the output in the virtual filesystem. It returns
+// the room left on the filesystem and the function writing the data kept
+func (dd) target(sys honeyos.Sys, op ddOperands, seek int64) (int64, func([]byte) error, int) {
+	p := absPath(sys, op.out)
+	fi, statErr := sys.FSys().Stat(p)
+	switch {
+	case statErr == nil && fi.IsDir():
+		fmt.Fprintf(sys.Err(), "dd: failed to open '%v': Is a directory\n", op.out)
+		return 0, nil, 1
+	case statErr == nil && op.excl:
+		fmt.Fprintf(sys.Err(), "dd: failed to open '%v': File exists\n", op.out)
+		return 0, nil, 1
+	case statErr != nil && op.nocreat:
+		return 0, nil, ddOpenError(sys, op.out, statErr)
+	}
+	f, err := sys.FSys().OpenFile(p, os.O_WRONLY|os.O_CREATE, 0666&^sys.Umask())
+	if err != nil {
+		return 0, nil, ddOpenError(sys, op.out, err)
+	}
+	if !op.notrunc {
+		// The output is cut at the offset of seek
+		f.Truncate(seek)
+	}
+	f.Close()
+	capacity := int64(-1)
+	mounts := sys.Mounts()
+	if i := mountIndex(mounts, p); i >= 0 && mounts[i].Size > 0 {
+		capacity = mountUsage(sys, mounts)[i].avail + seek
+		if statErr == nil {
+			capacity += fi.Size()
+		}
+	}
+	keep := func(data []byte) error {
+		limit := int64(viper.GetSizeInBytes("server.writeFileSizeLimit"))
+		if limit > 0 && seek >= limit {
+			return nil
+		}
+		if limit > 0 && seek+int64(len(data)) > limit {
+			data = data[:limit-seek]
+		}
+		f, err := sys.FSys().OpenFile(p, os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = f.WriteAt(data, seek)
+		return err
+	}
+	return capacity, keep, 0
+}
+
+// data returns the bytes written, up to what is kept of the output
+func (dd) data(src ddSource, written int64, op ddOperands) []byte {
+	n := written
+	if limit := int64(viper.GetSizeInBytes("server.writeFileSizeLimit")); limit > 0 && n > limit {
+		n = limit
+	}
+	if src.data != nil {
+		if n > int64(len(src.data)) {
+			n = int64(len(src.data))
+		}
+		data := src.data[:n]
+		switch {
+		case op.ucase:
+			data = []byte(strings.ToUpper(string(data)))
+		case op.lcase:
+			data = []byte(strings.ToLower(string(data)))
+		}
+		return data
+	}
+	data := make([]byte, n)
+	if src.random {
+		rand.Read(data)
+	}
+	return data
+}
+
+// wait takes the time of the copy at the rate, showing the progress each
+// second for status=progress. Endless copies go on until interrupted
+func (d dd) wait(sys honeyos.Sys, op ddOperands, size int64, rate float64) (time.Duration, bool) {
+	var records int64
+	if size > 0 {
+		records = (size + op.obs - 1) / op.obs
+	}
+	// Starting takes a while, and each block read and written takes a
+	// couple of system calls
+	need := time.Duration(4e5 + float64(records)*2e3 + float64(size)/rate*1e9)
+	if size < 0 {
+		need = -1
+	}
+	start := time.Now()
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	var done <-chan time.Time
+	if need >= 0 {
+		done = time.After(need)
+	}
+	shown := false
+	for {
+		select {
+		case <-done:
+			if shown {
+				fmt.Fprintln(sys.Err())
+			}
+			return need, false
+		case <-tick.C:
+			if op.progress && !op.quiet {
+				elapsed := time.Since(start)
+				fmt.Fprintf(sys.Err(), "\r%v", d.copied(int64(elapsed.Seconds()*rate), elapsed, true, true))
+				shown = true
+			}
+		case <-sys.Context().Done():
+			if shown {
+				fmt.Fprintln(sys.Err())
+			}
+			return time.Since(start), true
+		}
+	}
+}
+
+// summary prints the records copied and the transfer statistics
+func (d dd) summary(sys honeyos.Sys, read, written int64, op ddOperands, elapsed time.Duration, xfer bool) {
+	records := func(n, bs int64) string {
+		return fmt.Sprintf("%v+%v", n/bs, map[bool]int{true: 1, false: 0}[n%bs > 0])
+	}
+	fmt.Fprintf(sys.Err(), "%v records in\n%v records out\n", records(read, op.ibs), records(written, op.obs))
+	if xfer {
+		fmt.Fprintln(sys.Err(), d.copied(written, elapsed, false, pkgFamily() == "deb"))
+	}
+}
+
+// copied is the line of the transfer statistics, in the format of the dd
+// of the distribution. coreutils before 8.24 only shows the size in SI
+// units, and busybox is terser
+func (dd) copied(n int64, elapsed time.Duration, progress, both bool) string {
+	secs := elapsed.Seconds()
+	rate := "Infinity B/s"
+	if secs > 0 {
+		rate = ddHuman(float64(n)/secs, true) + "/s"
+	}
+	if pkgFamily() == "apk" {
+		// busybox shows the powers of 1024 as kB and MB
+		short := func(n float64) string {
+			return strings.Replace(strings.Replace(ddHuman(n, false), " ", "", 1), "i", "", 1)
+		}
+		rate = "Inf/s"
+		if secs > 0 {
+			rate = short(float64(n)/secs) + "/s"
+		}
+		return fmt.Sprintf("%v bytes (%v) copied, %.6f seconds, %v", n, short(float64(n)), secs, rate)
+	}
+	t := strconv.FormatFloat(secs, 'g', 6, 64)
+	if progress {
+		t = strconv.Itoa(int(secs))
+	}
+	switch {
+	case !both:
+		return fmt.Sprintf("%v bytes (%v) copied, %v s, %v", n, ddHuman(float64(n), true), t, rate)
+	case n < 1000:
+		return fmt.Sprintf("%v bytes copied, %v s, %v", n, t, rate)
+	}
+	return fmt.Sprintf("%v bytes (%v, %v) copied, %v s, %v", n, ddHuman(float64(n), true), ddHuman(float64(n), false), t, rate)
+}
+
+// ddHuman formats the size rounded to the nearest with a decimal below 10,
+// in powers of 1000 if si is set or 1024 otherwise
+func ddHuman(n float64, si bool) string {
+	base, units := 1000.0, []string{"B", "kB", "MB", "GB", "TB", "PB", "EB"}
+	if !si {
+		base, units = 1024, []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+	}
+	i := 0
+	for n >= base && i < len(units)-1 {
+		n /= base
+		i++
+	}
+	if i > 0 && n < 9.95 {
+		return fmt.Sprintf("%.1f %v", n, units[i])
+	}
+	return fmt.Sprintf("%.0f %v", n, units[i])
+}
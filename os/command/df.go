@@ -0,0 +1,291 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/afero"
+	"github.com/spf13/pflag"
+)
+
+// df shows the filesystems of persona with their usage. Besides what the
+// system is made up to use, files in the virtual filesystem count so writes
+// of the attacker show up
+type df struct{}
+
+// dfUsage is the usage of the mounted filesystem in bytes, and inodes
+type dfUsage struct {
+	honeyos.MountInfo
+	used, avail          int64
+	inodes, iused, ifree int64
+}
+
+func init() {
+	honeyos.RegisterCommand("df", df{})
+}
+
+func (df) GetHelp() string {
+	return "Usage: df [OPTION]... [FILE]...\nTry 'df --help' for more information.\n"
+}
+
+func (df) Where() string {
+	return "/bin/df"
+}
+
+// dfDisk tells if the filesystem keeps files on disk rather than in memory,
+// where the system takes some space
+func dfDisk(fsType string) bool {
+	switch fsType {
+	case "ext2", "ext3", "ext4", "xfs", "btrfs", "zfs", "vfat":
+		return true
+	}
+	return false
+}
+
+// mountIndex finds the filesystem the path is on, the mount point of the
+// longest match. The last mounted wins if mounted on the same point
+func mountIndex(mounts []honeyos.MountInfo, p string) int {
+	best := -1
+	for i, m := range mounts {
+		if (p == m.Dir || strings.HasPrefix(p, strings.TrimSuffix(m.Dir, "/")+"/")) && (best < 0 || len(m.Dir) >= len(mounts[best].Dir)) {
+			best = i
+		}
+	}
+	return best
+}
+
+// mountUsage works out the usage of the filesystems. Files in the virtual
+// filesystem are counted to the mount point they are under
+func mountUsage(sys honeyos.Sys, mounts []honeyos.MountInfo) []dfUsage {
+	usage := make([]dfUsage, len(mounts))
+	var files []int64
+	for i, m := range mounts {
+		usage[i].MountInfo = m
+		files = append(files, 0)
+		if dfDisk(m.Type) {
+			// The system takes a share of the disk, made up by the device
+			pct := 12 + int64(fnvString(m.Device+m.Dir)%24)
+			if m.Dir == "/boot" {
+				pct = 10 + pct/2
+			}
+			usage[i].used = m.Size / 100 * pct
+			usage[i].iused = m.Size / 100 * pct / 40960
+		} else if m.Dir == "/run" {
+			usage[i].used = m.Size / 100 * 3
+			usage[i].iused = 600
+		}
+	}
+	afero.Walk(sys.FSys(), "/", func(p string, fi os.FileInfo, err error) error {
+		if err != nil || sys.Context().Err() != nil {
+			return nil
+		}
+		if i := mountIndex(mounts, p); i >= 0 {
+			if mounts[i].Size == 0 {
+				// Pseudo filesystems like /proc are not walked
+				return nil
+			}
+			usage[i].used += diskBlocks(fi)
+			files[i]++
+		}
+		return nil
+	})
+	for i := range usage {
+		u := &usage[i]
+		if u.used > u.Size {
+			u.used = u.Size
+		}
+		u.avail = u.Size - u.used
+		if strings.HasPrefix(u.Type, "ext") {
+			// ext reserves 5% of blocks for root
+			u.avail -= u.Size / 20
+		}
+		if u.avail < 0 {
+			u.avail = 0
+		}
+		if u.Size > 0 {
+			u.inodes = u.Size / 16384
+			if !dfDisk(u.Type) {
+				u.inodes = honeyos.MemTotal / 4
+			}
+			u.iused += files[i] + 1
+			u.ifree = u.inodes - u.iused
+		}
+	}
+	return usage
+}
+
+func dfPercent(used, avail int64) string {
+	if used+avail == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%v%%", (used*100+used+avail-1)/(used+avail))
+}
+
+func (df) Exec(args []string, sys honeyos.Sys) int {
+	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	all := flag.BoolP("all", "a", false, "include pseudo, duplicate, inaccessible file systems")
+	human := flag.BoolP("human-readable", "h", false, "print sizes in powers of 1024 (e.g., 1023M)")
+	si := flag.BoolP("si", "H", false, "print sizes in powers of 1000 (e.g., 1.1G)")
+	inodes := flag.BoolP("inodes", "i", false, "list inode information instead of block usage")
+	flag.BoolP("k", "k", false, "like --block-size=1K")
+	mega := flag.BoolP("m", "m", false, "like --block-size=1M")
+	flag.BoolP("local", "l", false, "limit listing to local file systems")
+	posix := flag.BoolP("portability", "P", false, "use the POSIX output format")
+	total := flag.Bool("total", false, "elide all entries insignificant to available space, and produce a grand total")
+	types := flag.StringArrayP("type", "t", nil, "limit listing to file systems of type TYPE")
+	excludes := flag.StringArrayP("exclude-type", "x", nil, "limit listing to file systems not of type TYPE")
+	printType := flag.BoolP("print-type", "T", false, "print file system type")
+	if err := flag.Parse(args); err != nil {
+		msg := strings.Replace(err.Error(), "unknown shorthand flag: ", "invalid option -- ", 1)
+		if i := strings.Index(msg, " in -"); i >= 0 {
+			msg = msg[:i]
+		}
+		fmt.Fprintf(sys.Err(), "df: %v\nTry 'df --help' for more information.\n", msg)
+		return 1
+	}
+	mounts := sys.Mounts()
+	usage := mountUsage(sys, mounts)
+	status := 0
+	var rows []dfUsage
+	if files := flag.Args(); len(files) > 0 {
+		// The file operands show the filesystems they are on
+		for _, name := range files {
+			p := absPath(sys, name)
+			if _, err := sys.FSys().Stat(p); err != nil {
+				fmt.Fprintf(sys.Err(), "df: %v: No such file or directory\n", name)
+				status = 1
+				continue
+			}
+			if best := mountIndex(mounts, p); best >= 0 {
+				rows = append(rows, usage[best])
+			}
+		}
+		if len(rows) == 0 {
+			return status
+		}
+	} else {
+	mounts:
+		for _, u := range usage {
+			if u.Size == 0 && !*all {
+				continue
+			}
+			for _, t := range *excludes {
+				if u.Type == t {
+					continue mounts
+				}
+			}
+			if len(*types) > 0 {
+				found := false
+				for _, t := range *types {
+					found = found || u.Type == t
+				}
+				if !found {
+					continue
+				}
+			}
+			rows = append(rows, u)
+		}
+		if len(rows) == 0 {
+			fmt.Fprintln(sys.Err(), "df: no file systems processed")
+			return 1
+		}
+	}
+	if *total {
+		t := dfUsage{MountInfo: honeyos.MountInfo{Device: "total", Dir: "-", Type: "-"}}
+		for _, u := range rows {
+			t.Size, t.used, t.avail = t.Size+u.Size, t.used+u.used, t.avail+u.avail
+			t.inodes, t.iused, t.ifree = t.inodes+u.inodes, t.iused+u.iused, t.ifree+u.ifree
+		}
+		rows = append(rows, t)
+	}
+
+	base := float64(0)
+	switch {
+	case *human:
+		base = 1024
+	case *si:
+		base = 1000
+	}
+	unit := int64(1024)
+	if *mega {
+		unit = 1 << 20
+	}
+	num := func(n int64, blocks bool) string {
+		switch {
+		case !blocks:
+			if base > 0 {
+				return humanSize(n, base)
+			}
+			return fmt.Sprint(n)
+		case base > 0:
+			return humanSize(n, base)
+		}
+		return fmt.Sprint((n + unit - 1) / unit)
+	}
+	header := []string{"Filesystem"}
+	if *printType {
+		header = append(header, "Type")
+	}
+	switch {
+	case *inodes:
+		header = append(header, "Inodes", "IUsed", "IFree", "IUse%")
+	case base > 0:
+		header = append(header, "Size", "Used", "Avail", "Use%")
+	case *posix:
+		header = append(header, "1024-blocks", "Used", "Available", "Capacity")
+	case *mega:
+		header = append(header, "1M-blocks", "Used", "Available", "Use%")
+	default:
+		header = append(header, "1K-blocks", "Used", "Available", "Use%")
+	}
+	header = append(header, "Mounted on")
+	table := [][]string{header}
+	for _, u := range rows {
+		row := []string{u.Device}
+		if *printType {
+			row = append(row, u.Type)
+		}
+		if *inodes {
+			row = append(row, num(u.inodes, false), num(u.iused, false), num(u.ifree, false), dfPercent(u.iused, u.ifree))
+		} else {
+			row = append(row, num(u.Size, true), num(u.used, true), num(u.avail, true), dfPercent(u.used, u.avail))
+		}
+		table = append(table, append(row, u.Dir))
+	}
+	typeCol := -1
+	if *printType {
+		typeCol = 1
+	}
+	// Columns are as wide as the widest cell, the numbers aligned right
+	widths := make([]int, len(header))
+	widths[0] = 14
+	for _, row := range table {
+		for i, cell := range row {
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+			if base > 0 && i > 0 && i != typeCol && i < len(row)-2 && widths[i] < 5 {
+				widths[i] = 5
+			}
+		}
+	}
+	for _, row := range table {
+		var b strings.Builder
+		for i, cell := range row {
+			switch {
+			case i == len(row)-1:
+				b.WriteString(cell)
+			case i == 0 || i == typeCol:
+				b.WriteString(fmt.Sprintf("%-*v ", widths[i], cell))
+			default:
+				b.WriteString(fmt.Sprintf("%*v ", widths[i], cell))
+			}
+		}
+		fmt.Fprintln(sys.Out(), b.String())
+	}
+	return status
+}
@@ -0,0 +1,291 @@
+package command
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+)
+
+// dig queries the fake DNS and prints the reply the way dig formats the
+// message, section by section
+type dig struct{}
+
+// digOptions are the sections of the reply dig prints, toggled by the +
+// options
+type digOptions struct {
+	cmd, comments, question, answer, authority, stats, short bool
+}
+
+// digQuery is the question dig asks the server
+type digQuery struct {
+	name, kind string
+}
+
+var digVersions = map[string]string{"ubuntu": "9.10.3-P4-Ubuntu", "debian": "9.10.3-P4-Debian",
+	"centos": "9.11.4-P2-RedHat-9.11.4-26.P2.el7", "alpine": "9.16.20"}
+
+// dnsTypes are the types of records the tools ask for
+var dnsTypes = []string{"A", "AAAA", "ANY", "CNAME", "MX", "NS", "PTR", "SOA", "SRV", "TXT"}
+
+func init() {
+	honeyos.RegisterCommand("dig", dig{})
+}
+
+func (dig) GetHelp() string {
+	return `Usage:  dig [@global-server] [domain] [q-type] [q-class] {q-opt}
+            {global-d-opt} host [@local-server] {local-d-opt}
+            [ host [@local-server] {local-d-opt} [...]]
+
+Use "dig -h" (or "dig -h | more") for complete list of options
+`
+}
+
+func (dig) Where() string {
+	return "/usr/bin/dig"
+}
+
+// dnsTools tells if the package of dig, nslookup and host is installed
+func dnsTools(sys honeyos.Sys) bool {
+	pkg := map[string]string{"deb": "dnsutils", "rpm": "bind-utils", "apk": "bind-tools"}[pkgFamily()]
+	return loadPkgDB(sys, pkgFamily()).installed(pkg)
+}
+
+// dnsType returns the type named, in upper case, or false if there is no
+// such type
+func dnsType(name string) (string, bool) {
+	name = strings.ToUpper(name)
+	for _, t := range dnsTypes {
+		if t == name {
+			return t, true
+		}
+	}
+	return "", false
+}
+
+func (d dig) Exec(args []string, sys honeyos.Sys) int {
+	if !dnsTools(sys) {
+		return honeyos.CommandNotFound(sys, append([]string{"dig"}, args...))
+	}
+	opt := digOptions{cmd: true, comments: true, question: true, answer: true, authority: true, stats: true}
+	server := dnsServer()
+	var queries []digQuery
+	kind := ""
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case strings.HasPrefix(arg, "@"):
+			server = arg[1:]
+		case strings.HasPrefix(arg, "+"):
+			if !opt.set(arg[1:]) {
+				fmt.Fprintf(sys.Err(), "Invalid option: %v\n", arg)
+				fmt.Fprint(sys.Err(), d.GetHelp())
+				return 1
+			}
+		case arg == "-h":
+			fmt.Fprint(sys.Out(), d.GetHelp())
+			return 0
+		case arg == "-v":
+			fmt.Fprintf(sys.Err(), "DiG %v\n", d.version())
+			return 0
+		case len(arg) == 2 && strings.IndexByte("tqxpcfbky", arg[1]) >= 0 && arg[0] == '-':
+			if i+1 >= len(args) {
+				fmt.Fprintf(sys.Err(), "dig: option requires an argument -- '%c'\n", arg[1])
+				return 1
+			}
+			i++
+			switch arg[1] {
+			case 't':
+				t, ok := dnsType(args[i])
+				if !ok {
+					fmt.Fprintf(sys.Err(), "dig: invalid type: %v\n", args[i])
+					return 1
+				}
+				kind = t
+			case 'q':
+				queries = append(queries, digQuery{name: args[i]})
+			case 'x':
+				ip := net.ParseIP(args[i])
+				if ip == nil {
+					fmt.Fprintf(sys.Err(), "dig: '%v' is not a legal IP address\n", args[i])
+					return 1
+				}
+				queries = append(queries, digQuery{name: dnsReverse(ip), kind: "PTR"})
+			}
+		case strings.HasPrefix(arg, "-"):
+		default:
+			if t, ok := dnsType(arg); ok {
+				if len(queries) > 0 && queries[len(queries)-1].kind == "" {
+					queries[len(queries)-1].kind = t
+				} else {
+					kind = t
+				}
+			} else if !strings.EqualFold(arg, "IN") {
+				queries = append(queries, digQuery{name: arg})
+			}
+		}
+	}
+	if len(queries) == 0 {
+		queries = append(queries, digQuery{name: ".", kind: "NS"})
+	}
+	if net.ParseIP(server) == nil && netResolve(sys, server) == nil {
+		fmt.Fprintf(sys.Err(), "dig: couldn't get address for '%v': not found\n", server)
+		return 10
+	}
+
+	status := 0
+	for n, q := range queries {
+		if q.kind == "" {
+			q.kind = kind
+		}
+		if q.kind == "" {
+			q.kind = "A"
+		}
+		if !d.query(sys, args, server, q, opt, n == 0) {
+			status = 9
+		}
+	}
+	return status
+}
+
+// set toggles the section or option named, without the +. It returns false
+// for options dig does not know
+func (o *digOptions) set(name string) bool {
+	on := !strings.HasPrefix(name, "no")
+	name = strings.TrimPrefix(name, "no")
+	if i := strings.IndexByte(name, '='); i >= 0 {
+		name = name[:i]
+	}
+	switch name {
+	case "all":
+		o.cmd, o.comments, o.question, o.answer, o.authority, o.stats = on, on, on, on, on, on
+	case "cmd":
+		o.cmd = on
+	case "comments":
+		o.comments = on
+	case "question":
+		o.question = on
+	case "answer":
+		o.answer = on
+	case "authority":
+		o.authority = on
+	case "stats":
+		o.stats = on
+	case "short":
+		o.short = on
+	case "additional", "edns", "recurse", "search", "trace", "tcp", "vc", "time", "tries", "retry", "dnssec",
+		"multiline", "ttlid", "identify", "cookie", "nssearch", "besteffort":
+	default:
+		return false
+	}
+	return true
+}
+
+func (dig) version() string {
+	if v, ok := digVersions[honeyos.Distro()]; ok {
+		return v
+	}
+	return digVersions["ubuntu"]
+}
+
+// query asks the server and prints the reply. It returns false if the
+// server did not answer
+func (d dig) query(sys honeyos.Sys, args []string, server string, q digQuery, opt digOptions, first bool) bool {
+	out := sys.Out()
+	answer := dnsQuery(sys, "dig", server, q.name, q.kind)
+	if opt.cmd && !opt.short {
+		fmt.Fprintf(out, "\n; <<>> DiG %v <<>> %v\n", d.version(), strings.Join(args, " "))
+		if first {
+			fmt.Fprintln(out, ";; global options: +cmd")
+		}
+	}
+	if answer.status == "timeout" {
+		if !pkgSleep(sys, 15*time.Second) {
+			return false
+		}
+		fmt.Fprintln(out, ";; connection timed out; no servers could be reached")
+		return false
+	}
+	if opt.short {
+		for _, r := range answer.records {
+			fmt.Fprintln(out, r.value)
+		}
+		return true
+	}
+
+	var authority []dnsRecord
+	if answer.authoritative && len(answer.records) == 0 {
+		zone := strings.TrimSuffix(q.name, ".")
+		if i := strings.IndexByte(zone, '.'); i >= 0 && answer.status == "NXDOMAIN" {
+			zone = zone[i+1:]
+		}
+		authority = append(authority, dnsRecord{name: zone, kind: "SOA", ttl: 300,
+			value: fmt.Sprintf("ns1.%v. hostmaster.%v. %d 3600 900 604800 300", zone, zone, 2020010100+fnvString(zone)%99)})
+	}
+	if opt.comments {
+		flags := "qr rd ra"
+		if answer.authoritative {
+			flags = "qr aa rd ra"
+		}
+		fmt.Fprintf(out, ";; Got answer:\n;; ->>HEADER<<- opcode: QUERY, status: %v, id: %d\n", answer.status, rand.Intn(65536))
+		fmt.Fprintf(out, ";; flags: %v; QUERY: 1, ANSWER: %d, AUTHORITY: %d, ADDITIONAL: 1\n\n",
+			flags, len(answer.records), len(authority))
+		fmt.Fprintln(out, ";; OPT PSEUDOSECTION:\n; EDNS: version: 0, flags:; udp: 4096")
+	}
+	if opt.question {
+		if opt.comments {
+			fmt.Fprintln(out, ";; QUESTION SECTION:")
+		}
+		fmt.Fprintf(out, "%vIN\t%v\n", digPad(";"+dnsFQDN(q.name), 32), q.kind)
+		if opt.comments {
+			fmt.Fprintln(out)
+		}
+	}
+	section := func(title string, records []dnsRecord) {
+		if len(records) == 0 {
+			return
+		}
+		if opt.comments {
+			fmt.Fprintf(out, ";; %v SECTION:\n", title)
+		}
+		for _, r := range records {
+			fmt.Fprintf(out, "%v%d\tIN\t%v\t%v\n", digPad(dnsFQDN(r.name), 24), r.ttl, r.kind, r.value)
+		}
+		if opt.comments {
+			fmt.Fprintln(out)
+		}
+	}
+	if opt.answer {
+		section("ANSWER", answer.records)
+	}
+	if opt.authority {
+		section("AUTHORITY", authority)
+	}
+	if opt.stats {
+		size := 12 + len(q.name) + 6 + 11
+		for _, r := range append(answer.records, authority...) {
+			size += 12 + len(r.name) + len(r.value)
+		}
+		ip := server
+		if addr := net.ParseIP(server); addr == nil {
+			ip = netResolve(sys, server).String()
+		}
+		fmt.Fprintf(out, ";; Query time: %d msec\n;; SERVER: %v#53(%v)\n;; WHEN: %v\n;; MSG SIZE  rcvd: %d\n\n",
+			answer.rtt/time.Millisecond, ip, ip, honeyos.Now(sys).Format("Mon Jan _2 15:04:05 MST 2006"), size)
+	}
+	return true
+}
+
+// digPad pads the name with tabs to the column, like dig lines up the
+// fields of the records
+func digPad(s string, column int) string {
+	width := (len(s)/8 + 1) * 8
+	s += "\t"
+	for ; width < column; width += 8 {
+		s += "\t"
+	}
+	return s
+}
@@ -0,0 +1,433 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/pflag"
+)
+
+// dmesg prints the kernel ring buffer, made up from the persona so that the
+// kernel, memory, disks and network card agree with uname, free and df. The
+// boot is the same every time, then ufw keeps blocking scans on Ubuntu
+type dmesg struct{}
+
+// dmesgEntry is a message in the ring buffer, at seconds since boot
+type dmesgEntry struct {
+	t        float64
+	facility int
+	level    int
+	msg      string
+}
+
+var (
+	dmesgFacilities = []string{"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news"}
+	dmesgLevels     = []string{"emerg", "alert", "crit", "err", "warn", "notice", "info", "debug"}
+)
+
+func init() {
+	honeyos.RegisterCommand("dmesg", dmesg{})
+}
+
+func (dmesg) GetHelp() string {
+	return "\nUsage:\n dmesg [options]\n\nDisplay or control the kernel ring buffer.\n\nOptions:\n" +
+		" -C, --clear                 clear the kernel ring buffer\n" +
+		" -c, --read-clear            read and clear all messages\n" +
+		" -D, --console-off           disable printing messages to console\n" +
+		" -E, --console-on            enable printing messages to console\n" +
+		" -F, --file <file>           use the file instead of the kernel log buffer\n" +
+		" -f, --facility <list>       restrict output to defined facilities\n" +
+		" -H, --human                 human readable output\n" +
+		" -k, --kernel                display kernel messages\n" +
+		" -L, --color[=<when>]        colorize messages (auto, always or never)\n" +
+		" -l, --level <list>          restrict output to defined levels\n" +
+		" -n, --console-level <level> set level of messages printed to console\n" +
+		" -P, --nopager               do not pipe output into a pager\n" +
+		" -r, --raw                   print the raw message buffer\n" +
+		" -S, --syslog                force to use syslog(2) rather than /dev/kmsg\n" +
+		" -s, --buffer-size <size>    buffer size to query the kernel ring buffer\n" +
+		" -u, --userspace             display userspace messages\n" +
+		" -w, --follow                wait for new messages\n" +
+		" -x, --decode                decode facility and level to readable string\n" +
+		" -d, --show-delta            show time delta between printed messages\n" +
+		" -e, --reltime               show local time and time delta in readable format\n" +
+		" -T, --ctime                 show human readable timestamp (may be inaccurate!)\n" +
+		" -t, --notime                don't print messages timestamp\n" +
+		"     --time-format <format>  show time stamp using format:\n" +
+		"                               [delta|reltime|ctime|notime|iso]\n" +
+		"Suspending/resume will make ctime and iso timestamps inaccurate.\n\n" +
+		" -h, --help     display this help and exit\n" +
+		" -V, --version  output version information and exit\n\n" +
+		"Supported log facilities:\n    kern - kernel messages\n    user - random user-level messages\n" +
+		"    mail - mail system\n  daemon - system daemons\n    auth - security/authorization messages\n" +
+		"  syslog - messages generated internally by syslogd\n     lpr - line printer subsystem\n" +
+		"    news - network news subsystem\n\n" +
+		"Supported log levels (priorities):\n   emerg - system is unusable\n   alert - action must be taken immediately\n" +
+		"    crit - critical conditions\n     err - error conditions\n    warn - warning conditions\n" +
+		"  notice - normal but significant condition\n    info - informational\n   debug - debug-level messages\n\n" +
+		"For more details see dmesg(1).\n"
+}
+
+func (dmesg) Where() string {
+	return "/bin/dmesg"
+}
+
+// dmesgDisk is the disk as the kernel finds it, with its partitions
+type dmesgDisk struct {
+	name       string
+	partitions []string
+	size       int64
+}
+
+// dmesgDisks works out the disks from the partitions mounted. The LVM volume
+// of CentOS is on the second partition of the disk with /boot
+func dmesgDisks(mounts []honeyos.MountInfo) []*dmesgDisk {
+	var disks []*dmesgDisk
+	find := func(name string) *dmesgDisk {
+		for _, d := range disks {
+			if d.name == name {
+				return d
+			}
+		}
+		d := &dmesgDisk{name: name, size: 1 << 20}
+		disks = append(disks, d)
+		return d
+	}
+	var lvm int64
+	for _, m := range mounts {
+		dev := strings.TrimPrefix(m.Device, "/dev/")
+		switch {
+		case strings.HasPrefix(dev, "mapper/"):
+			lvm += m.Size
+		case dev != m.Device && (strings.HasPrefix(dev, "sd") || strings.HasPrefix(dev, "vd") || strings.HasPrefix(dev, "xvd")):
+			d := find(strings.TrimRight(dev, "0123456789"))
+			d.partitions = append(d.partitions, dev)
+			d.size += m.Size
+		}
+	}
+	if lvm > 0 {
+		d := find("sda")
+		d.partitions = append(d.partitions, fmt.Sprintf("%v%v", d.name, len(d.partitions)+1))
+		d.size += lvm + swapTotal*1024
+	}
+	for _, d := range disks {
+		sort.Strings(d.partitions)
+	}
+	return disks
+}
+
+// dmesgBoot makes up the messages of the boot, and those logged since until
+// now. The randomness is seeded by the machine so it stays the same
+func dmesgBoot(sys honeyos.Sys, now time.Time) []dmesgEntry {
+	r := rand.New(rand.NewSource(int64(fnvString(sys.Hostname() + honeyos.IPAddress()))))
+	distro := honeyos.Distro()
+	var entries []dmesgEntry
+	t := 0.0
+	add := func(step float64, level int, format string, a ...interface{}) {
+		if step > 0 {
+			t += step * (0.5 + r.Float64())
+		}
+		entries = append(entries, dmesgEntry{t: t, level: level, msg: fmt.Sprintf(format, a...)})
+	}
+	version, _ := readFile(sys, "/proc/version")
+	cmdline, _ := readFile(sys, "/proc/cmdline")
+	add(0, 5, "%v", strings.TrimSpace(string(version)))
+	add(0, 6, "Command line: %v", strings.TrimSpace(string(cmdline)))
+	for _, l := range []string{"KERNEL supported cpus:", "  Intel GenuineIntel", "  AMD AuthenticAMD", "  Centaur CentaurHauls",
+		"x86/fpu: Legacy x87 FPU detected.", "e820: BIOS-provided physical RAM map:",
+		"BIOS-e820: [mem 0x0000000000000000-0x000000000009fbff] usable",
+		"BIOS-e820: [mem 0x000000000009fc00-0x000000000009ffff] reserved",
+		"BIOS-e820: [mem 0x00000000000f0000-0x00000000000fffff] reserved",
+		"BIOS-e820: [mem 0x0000000000100000-0x000000007ffdbfff] usable",
+		"BIOS-e820: [mem 0x000000007ffdc000-0x000000007fffffff] reserved",
+		"BIOS-e820: [mem 0x00000000feffc000-0x00000000feffffff] reserved",
+		"BIOS-e820: [mem 0x00000000fffc0000-0x00000000ffffffff] reserved",
+		"NX (Execute Disable) protection: active", "SMBIOS 2.8 present.",
+		"DMI: QEMU Standard PC (i440FX + PIIX, 1996), BIOS 1.10.2-1ubuntu1 04/01/2014",
+		"Hypervisor detected: KVM", "e820: last_pfn = 0x7ffdc max_arch_pfn = 0x400000000"} {
+		add(0, 6, "%v", l)
+	}
+	add(0, 6, "Memory: %vK/2096616K available (8484K kernel code, 1337K rwdata, 3980K rodata, 1480K init, 1292K bss, %vK reserved, 0K cma-reserved)",
+		honeyos.MemTotal-13480, 2096616-honeyos.MemTotal+13480)
+	for _, l := range []string{"Hierarchical RCU implementation.", "NR_IRQS:33024 nr_irqs:256 16", "Console: colour VGA+ 80x25",
+		"console [tty1] enabled", "console [ttyS0] enabled"} {
+		add(0, 6, "%v", l)
+	}
+	add(0, 6, "tsc: Detected %.3f MHz processor", cpuMHz)
+	add(0.004, 6, "Calibrating delay loop (skipped) preset value.. %.2f BogoMIPS (lpj=%v)", cpuMHz*2, int(cpuMHz*4000))
+	add(0.0002, 6, "pid_max: default: 32768 minimum: 301")
+	add(0.0004, 6, "ACPI: Core revision 20150930")
+	add(0.003, 6, "Security Framework initialized")
+	switch distro {
+	case "centos":
+		add(0.0001, 6, "SELinux:  Initializing.")
+	case "alpine":
+	default:
+		add(0.0001, 6, "AppArmor: AppArmor initialized")
+	}
+	add(0.0008, 6, "Dentry cache hash table entries: 262144 (order: 9, 2097152 bytes)")
+	add(0.0006, 6, "Mount-cache hash table entries: 4096 (order: 3, 32768 bytes)")
+	add(0.04, 6, "smpboot: CPU0: %v (family: 0x6, model: 0x2a, stepping: 0x1)", cpuModel)
+	add(0.004, 6, "x86: Booted up 1 node, 1 CPUs")
+	add(0.0001, 6, "smpboot: Total of 1 processors activated (%.2f BogoMIPS)", cpuMHz*2)
+	add(0.003, 6, "devtmpfs: initialized")
+	add(0.004, 6, "NET: Registered protocol family 16")
+	add(0.02, 6, "PCI: Using configuration type 1 for base access")
+	add(0.01, 6, "ACPI: Interpreter enabled")
+	add(0.02, 6, "pci 0000:00:01.1: legacy IDE quirk: reg 0x10: [io  0x01f0-0x01f7]")
+	add(0.03, 5, "SCSI subsystem initialized")
+	add(0.06, 6, "clocksource: Switched to clocksource kvm-clock")
+	add(0.01, 6, "NET: Registered protocol family 2")
+	add(0.001, 6, "TCP established hash table entries: 16384 (order: 5, 131072 bytes)")
+	add(0.02, 6, "Trying to unpack rootfs image as initramfs...")
+	add(0.3, 6, "Freeing initrd memory: 17384K")
+	add(0.04, 6, "audit: initializing netlink subsys (disabled)")
+	add(0.05, 6, "Serial: 8250/16550 driver, 32 ports, IRQ sharing enabled")
+	add(0.02, 6, "00:05: ttyS0 at I/O 0x3f8 (irq = 4, base_baud = 115200) is a 16550A")
+	add(0.03, 6, "i8042: PNP: PS/2 Controller [PNP0303:KBD,PNP0f13:MOU] at 0x60,0x64 irq 1,12")
+	add(0.02, 6, "rtc_cmos 00:00: setting system clock to %v UTC (%v)", honeyos.BootTime().UTC().Format("2006-01-02 15:04:05"), honeyos.BootTime().Unix())
+	add(0.2, 6, "Freeing unused kernel memory: 1480K")
+
+	mounts := sys.Mounts()
+	var mac string
+	if eth, ok := findIface("eth0"); ok {
+		mac = eth.mac
+	}
+	disks := dmesgDisks(mounts)
+	virtio := len(disks) > 0 && strings.HasPrefix(disks[0].name, "vd")
+	if !virtio {
+		add(0.1, 6, "e1000: Intel(R) PRO/1000 Network Driver - version 7.3.21-k8-NAPI")
+		add(0.001, 6, "e1000: Copyright (c) 1999-2006 Intel Corporation.")
+	}
+	for i, d := range disks {
+		sectors := d.size / 512
+		gb, gib := float64(d.size)/1e9, float64(d.size)/(1<<30)
+		if virtio {
+			add(0.02, 5, "virtio_blk virtio%v: [%v] %v 512-byte logical blocks (%.1f GB/%.1f GiB)", i+1, d.name, sectors, gb, gib)
+		} else {
+			add(0.02, 5, "scsi 2:0:%v:0: Direct-Access     QEMU     QEMU HARDDISK    2.5+ PQ: 0 ANSI: 5", i)
+			add(0.001, 5, "sd 2:0:%v:0: [%v] %v 512-byte logical blocks: (%.1f GB/%.1f GiB)", i, d.name, sectors, gb, gib)
+			add(0.0001, 5, "sd 2:0:%v:0: [%v] Write Protect is off", i, d.name)
+			add(0.0001, 5, "sd 2:0:%v:0: [%v] Write cache: enabled, read cache: enabled, doesn't support DPO or FUA", i, d.name)
+		}
+		add(0.002, 6, " %v: %v", d.name, strings.Join(d.partitions, " "))
+		if !virtio {
+			add(0.001, 5, "sd 2:0:%v:0: [%v] Attached SCSI disk", i, d.name)
+		}
+	}
+	if !virtio && mac != "" {
+		add(0.05, 6, "e1000 0000:00:03.0 eth0: (PCI:33MHz:32-bit) %v", mac)
+		add(0.001, 6, "e1000 0000:00:03.0 eth0: Intel(R) PRO/1000 Network Connection")
+	}
+	for _, m := range mounts {
+		dev := strings.TrimPrefix(m.Device, "/dev/")
+		if strings.HasPrefix(dev, "mapper/") {
+			dev = "dm-0"
+		}
+		switch {
+		case m.Dir != "/":
+		case strings.HasPrefix(m.Type, "ext"):
+			add(0.4, 6, "EXT4-fs (%v): mounted filesystem with ordered data mode. Opts: (null)", dev)
+		case m.Type == "xfs":
+			add(0.3, 5, "XFS (%v): Mounting V5 Filesystem", dev)
+			add(0.1, 5, "XFS (%v): Ending clean mount", dev)
+		}
+	}
+	if systemd := map[string]string{"ubuntu": "229", "debian": "232", "centos": "219"}[distro]; systemd != "" {
+		daemon := func(step float64, format string, a ...interface{}) {
+			add(step, 6, format, a...)
+			entries[len(entries)-1].facility = 3
+		}
+		daemon(0.5, "systemd[1]: systemd %v running in system mode. (+PAM +AUDIT +SELINUX +IMA +APPARMOR +SMACK +SYSVINIT +UTMP +LIBCRYPTSETUP +GCRYPT +GNUTLS +ACL +XZ -LZ4 +SECCOMP +BLKID +ELFUTILS +KMOD -IDN)", systemd)
+		daemon(0.001, "systemd[1]: Detected virtualization kvm.")
+		daemon(0.0001, "systemd[1]: Detected architecture x86-64.")
+		daemon(0.002, "systemd[1]: Set hostname to <%v>.", sys.Hostname())
+		daemon(0.3, "systemd[1]: Started Journal Service.")
+	}
+	for _, m := range mounts {
+		if m.Dir == "/" && strings.HasPrefix(m.Type, "ext") && distro != "alpine" {
+			add(0.1, 6, "EXT4-fs (%v): re-mounted. Opts: errors=remount-ro", strings.TrimPrefix(m.Device, "/dev/"))
+		}
+	}
+	if distro == "ubuntu" || distro == "debian" {
+		for _, name := range []string{"/sbin/dhclient", "/usr/lib/NetworkManager/nm-dhcp-client.action", "/usr/sbin/ntpd", "/usr/sbin/tcpdump"} {
+			add(0.05, 5, `audit: type=1400 audit(%v.%03d:%v): apparmor="STATUS" operation="profile_load" profile="unconfined" name="%v" pid=%v comm="apparmor_parser"`,
+				honeyos.BootTime().Unix()+int64(t), int(t*1000)%1000, len(entries), name, 300+r.Intn(100))
+		}
+	}
+	if !virtio {
+		add(0.5, 6, "e1000: eth0 NIC Link is Up 1000 Mbps Full Duplex, Flow Control: RX")
+	}
+	add(0.001, 6, "IPv6: ADDRCONF(NETDEV_UP): eth0: link is not ready")
+	add(0.001, 6, "IPv6: ADDRCONF(NETDEV_CHANGE): eth0: link becomes ready")
+
+	// The ring buffer keeps only the latest of what came after the boot
+	up := now.Sub(honeyos.BootTime()).Seconds()
+	var late []dmesgEntry
+	for t = 60 + r.Float64()*600; t < up; t += 30 + r.Float64()*900 {
+		var e dmesgEntry
+		switch {
+		case distro == "ubuntu":
+			e = dmesgEntry{t: t, level: 4, msg: logUFW(r)}
+		case r.Intn(8) == 0:
+			e = dmesgEntry{t: t, level: 6, msg: "TCP: request_sock_TCP: Possible SYN flooding on port 22. Sending cookies.  Check SNMP counters."}
+		default:
+			continue
+		}
+		if late = append(late, e); len(late) > 200 {
+			late = late[1:]
+		}
+	}
+	return append(entries, late...)
+}
+
+func (d dmesg) Exec(args []string, sys honeyos.Sys) int {
+	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	clearBuf := flag.BoolP("clear", "C", false, "clear the kernel ring buffer")
+	readClear := flag.BoolP("read-clear", "c", false, "read and clear all messages")
+	consoleOff := flag.BoolP("console-off", "D", false, "disable printing messages to console")
+	consoleOn := flag.BoolP("console-on", "E", false, "enable printing messages to console")
+	facilities := flag.StringP("facility", "f", "", "restrict output to defined facilities")
+	human := flag.BoolP("human", "H", false, "human readable output")
+	kernel := flag.BoolP("kernel", "k", false, "display kernel messages")
+	flag.StringP("color", "L", "auto", "colorize messages")
+	flag.Lookup("color").NoOptDefVal = "auto"
+	levels := flag.StringP("level", "l", "", "restrict output to defined levels")
+	consoleLevel := flag.StringP("console-level", "n", "", "set level of messages printed to console")
+	flag.BoolP("nopager", "P", false, "do not pipe output into a pager")
+	raw := flag.BoolP("raw", "r", false, "print the raw message buffer")
+	flag.BoolP("syslog", "S", false, "force to use syslog(2) rather than /dev/kmsg")
+	flag.StringP("buffer-size", "s", "", "buffer size to query the kernel ring buffer")
+	userspace := flag.BoolP("userspace", "u", false, "display userspace messages")
+	follow := flag.BoolP("follow", "w", false, "wait for new messages")
+	decode := flag.BoolP("decode", "x", false, "decode facility and level to readable string")
+	delta := flag.BoolP("show-delta", "d", false, "show time delta between printed messages")
+	flag.BoolP("reltime", "e", false, "show local time and time delta in readable format")
+	ctime := flag.BoolP("ctime", "T", false, "show human readable timestamp")
+	notime := flag.BoolP("notime", "t", false, "don't print messages timestamp")
+	timeFormat := flag.String("time-format", "", "show time stamp using format")
+	help := flag.BoolP("help", "h", false, "display this help and exit")
+	version := flag.BoolP("version", "V", false, "output version information and exit")
+	if err := flag.Parse(args); err != nil {
+		msg := strings.Replace(err.Error(), "unknown shorthand flag: ", "invalid option -- ", 1)
+		if i := strings.Index(msg, " in -"); i >= 0 {
+			msg = msg[:i]
+		}
+		fmt.Fprintf(sys.Err(), "dmesg: %v\nTry 'dmesg --help' for more information.\n", msg)
+		return 1
+	}
+	switch {
+	case *help:
+		fmt.Fprint(sys.Out(), d.GetHelp())
+		return 0
+	case *version:
+		fmt.Fprintln(sys.Out(), "dmesg from util-linux 2.27.1")
+		return 0
+	}
+	switch *timeFormat {
+	case "", "delta", "reltime", "iso":
+	case "ctime":
+		*ctime = true
+	case "notime":
+		*notime = true
+	default:
+		fmt.Fprintf(sys.Err(), "dmesg: unknown time format: %v\n", *timeFormat)
+		return 1
+	}
+	if *clearBuf || *readClear || *consoleOff || *consoleOn || *consoleLevel != "" {
+		if !isRoot(sys) {
+			fmt.Fprintln(sys.Err(), "dmesg: klogctl failed: Operation not permitted")
+			return 1
+		}
+		sys.Log().WithField("args", args).Infof("User controlling kernel ring buffer with dmesg")
+		if !*readClear {
+			return 0
+		}
+	}
+	// Levels and facilities are filtered by the names given
+	allowed := func(list, what string, names []string) (map[int]bool, bool) {
+		if list == "" {
+			return nil, true
+		}
+		set := map[int]bool{}
+		for _, name := range strings.Split(list, ",") {
+			found := false
+			for i, n := range names {
+				if n == name {
+					set[i], found = true, true
+				}
+			}
+			if !found {
+				fmt.Fprintf(sys.Err(), "dmesg: unknown %v '%v'\n", what, name)
+				return nil, false
+			}
+		}
+		return set, true
+	}
+	levelSet, ok := allowed(*levels, "level", dmesgLevels)
+	if !ok {
+		return 1
+	}
+	facilitySet, ok := allowed(*facilities, "facility", dmesgFacilities)
+	if !ok {
+		return 1
+	}
+	if *kernel && !*userspace {
+		facilitySet = map[int]bool{0: true}
+	}
+
+	var last float64
+	first := true
+	show := func(e dmesgEntry) {
+		if levelSet != nil && !levelSet[e.level] || facilitySet != nil && !facilitySet[e.facility] {
+			return
+		}
+		var b strings.Builder
+		switch {
+		case *raw:
+			fmt.Fprintf(&b, "<%v>", e.facility<<3|e.level)
+		case *decode:
+			fmt.Fprintf(&b, "%-6v:%-6v: ", dmesgFacilities[e.facility], dmesgLevels[e.level])
+		}
+		at := honeyos.BootTime().Add(time.Duration(e.t * float64(time.Second)))
+		switch {
+		case *notime:
+		case *ctime && !*raw:
+			fmt.Fprintf(&b, "[%v] ", at.Format("Mon Jan _2 15:04:05 2006"))
+		case *human && !*raw:
+			if first || int64(e.t)/60 != int64(last)/60 {
+				fmt.Fprintf(&b, "[%v] ", at.Format("Jan 2 15:04"))
+			} else {
+				fmt.Fprintf(&b, "[  %+.6f] ", e.t-last)
+			}
+		case *delta && !*raw:
+			fmt.Fprintf(&b, "[%12.6f <%12.6f>] ", e.t, e.t-last)
+		default:
+			fmt.Fprintf(&b, "[%12.6f] ", e.t)
+		}
+		first, last = false, e.t
+		b.WriteString(e.msg)
+		fmt.Fprintln(sys.Out(), b.String())
+	}
+	for _, e := range dmesgBoot(sys, time.Now()) {
+		show(e)
+	}
+	if !*follow {
+		return 0
+	}
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for {
+		if !pkgSleep(sys, time.Duration(5+r.Intn(40))*time.Second) {
+			return 130
+		}
+		if honeyos.Distro() == "ubuntu" {
+			show(dmesgEntry{t: time.Since(honeyos.BootTime()).Seconds(), level: 4, msg: logUFW(r)})
+		}
+	}
+}
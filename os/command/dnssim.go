@@ -0,0 +1,285 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// dnsRecord is the answer of the fake DNS, as configured in persona.dns
+// records or made up from the hosts ssh lands on
+type dnsRecord struct {
+	name, kind, value string
+	ttl               int
+}
+
+// dnsAnswer is the reply of the server to the query of dig, nslookup and
+// host. status is NOERROR, NXDOMAIN or SERVFAIL, or timeout if the server
+// does not answer
+type dnsAnswer struct {
+	records []dnsRecord
+	status  string
+	// authoritative is set for the names of the site, answered from the
+	// configured records
+	authoritative bool
+	rtt           time.Duration
+}
+
+// dnsServer is the resolver of the machine, the gateway unless set
+func dnsServer() string {
+	if server := viper.GetString("persona.dns.server"); server != "" {
+		return server
+	}
+	return viper.GetString("persona.gateway")
+}
+
+// dnsFQDN makes the name absolute with the trailing dot, as shown by dig
+func dnsFQDN(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// dnsReverse is the in-addr.arpa name of the address looked up for PTR
+func dnsReverse(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa", ip4[3], ip4[2], ip4[1], ip4[0])
+	}
+	var b strings.Builder
+	for i := len(ip) - 1; i >= 0; i-- {
+		fmt.Fprintf(&b, "%x.%x.", ip[i]&0xf, ip[i]>>4)
+	}
+	return b.String() + "ip6.arpa"
+}
+
+// dnsUnreverse is the address of the in-addr.arpa name, nil for other names
+func dnsUnreverse(name string) net.IP {
+	name = strings.TrimSuffix(name, ".")
+	if !strings.HasSuffix(name, ".in-addr.arpa") {
+		return nil
+	}
+	parts := strings.Split(strings.TrimSuffix(name, ".in-addr.arpa"), ".")
+	if len(parts) != 4 {
+		return nil
+	}
+	return net.ParseIP(parts[3] + "." + parts[2] + "." + parts[1] + "." + parts[0])
+}
+
+// dnsRecords reads the records of persona.dns, given as name, type and
+// value, like intranet.corp A 10.0.0.5 or corp MX 10 mail.corp
+func dnsRecords() []dnsRecord {
+	var records []dnsRecord
+	for _, entry := range viper.GetStringSlice("persona.dns.records") {
+		fields := strings.Fields(entry)
+		if len(fields) < 3 {
+			continue
+		}
+		r := dnsRecord{name: strings.ToLower(strings.TrimSuffix(fields[0], ".")), kind: strings.ToUpper(fields[1]),
+			value: strings.Join(fields[2:], " "), ttl: 300}
+		switch r.kind {
+		case "CNAME", "NS", "PTR":
+			r.value = dnsFQDN(r.value)
+		case "MX":
+			if len(fields) > 3 {
+				r.value = fields[2] + " " + dnsFQDN(fields[3])
+			}
+		case "TXT":
+			if !strings.HasPrefix(r.value, `"`) {
+				r.value = `"` + r.value + `"`
+			}
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+// dnsLocal answers the name from the records of the site: those configured,
+// the hosts ssh lands on and the machine itself. ok is false for names
+// outside the site, which the resolver asks the Internet for
+func dnsLocal(sys honeyos.Sys, name, kind string) (answer dnsAnswer, ok bool) {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	configured := dnsRecords()
+	answer.status, answer.authoritative = "NOERROR", true
+	// Follow the aliases like the resolver does, up to a few
+	for depth := 0; depth < 8; depth++ {
+		found, alias := false, ""
+		for _, r := range configured {
+			if r.name != name {
+				continue
+			}
+			found = true
+			if r.kind == kind || kind == "ANY" {
+				answer.records = append(answer.records, r)
+			} else if r.kind == "CNAME" {
+				answer.records = append(answer.records, r)
+				alias = strings.TrimSuffix(r.value, ".")
+			}
+		}
+		if !found {
+			break
+		}
+		if alias == "" {
+			return answer, true
+		}
+		name = alias
+	}
+	if len(answer.records) > 0 {
+		// The alias points outside the site
+		return answer, true
+	}
+
+	if ip := dnsUnreverse(name); ip != nil && kind == "PTR" {
+		if h, landing := sshLookup(sys, ip.String()); landing && !ip.IsLoopback() {
+			answer.records = append(answer.records, dnsRecord{name: name, kind: "PTR", value: dnsFQDN(h.hostname), ttl: 3600})
+			return answer, true
+		}
+	}
+	if name == "localhost" {
+		if kind == "A" || kind == "ANY" {
+			answer.records = append(answer.records, dnsRecord{name: name, kind: "A", value: "127.0.0.1", ttl: 86400})
+		}
+		return answer, true
+	}
+	if h, landing := sshLookup(sys, name); landing {
+		if kind == "A" || kind == "ANY" {
+			answer.records = append(answer.records, dnsRecord{name: name, kind: "A", value: h.addr, ttl: 3600})
+		}
+		return answer, true
+	}
+
+	// Names in the domains of the site do not exist if not configured
+	for _, r := range configured {
+		if zone := r.name[strings.IndexByte(r.name, '.')+1:]; name == zone || strings.HasSuffix(name, "."+zone) {
+			answer.status = "NXDOMAIN"
+			return answer, true
+		}
+	}
+	return dnsAnswer{}, false
+}
+
+// dnsQuery asks the server for the records of the name, logging the domain
+// looked up. The resolver answers the site from dnsLocal and the rest from
+// the Internet when the honeypot is online. Other servers are only reached
+// online
+func dnsQuery(sys honeyos.Sys, tool, server, name, kind string) dnsAnswer {
+	sys.Log().WithFields(log.Fields{"domain": name, "type": kind, "dnsServer": server}).
+		Infof("User looked up %v with %v", name, tool)
+	resolver := server == dnsServer()
+	if server != "" && !resolver {
+		ip := net.ParseIP(server)
+		if ip == nil {
+			ip = netResolve(sys, server)
+		}
+		if open, _ := netPortOpen(sys, ip, 53); ip == nil || !open {
+			return dnsAnswer{status: "timeout"}
+		}
+		// A resolver listening on the machine answers like the one of the site
+		resolver = ip.IsLoopback() || ip.Equal(net.ParseIP(honeyos.IPAddress()))
+	}
+	if resolver {
+		if answer, ok := dnsLocal(sys, name, kind); ok {
+			answer.rtt = time.Duration(fnvString(name)%3) * time.Millisecond
+			return answer
+		}
+	}
+	if !viper.GetBool("server.allowDownload") {
+		return dnsAnswer{status: "SERVFAIL", rtt: 4 * time.Millisecond}
+	}
+	return dnsInternet(sys, name, kind)
+}
+
+// dnsInternet looks up the name for real. The TTLs are made up, the Go
+// resolver does not tell them
+func dnsInternet(sys honeyos.Sys, name, kind string) dnsAnswer {
+	ctx, cancel := context.WithTimeout(sys.Context(), 10*time.Second)
+	defer cancel()
+	start := time.Now()
+	fqdn := strings.TrimSuffix(name, ".")
+	if fqdn == "" {
+		fqdn = "."
+	}
+	ttl := 60 + int(fnvString(fqdn)%3540)
+	answer := dnsAnswer{status: "NOERROR"}
+	add := func(kind, value string) {
+		answer.records = append(answer.records, dnsRecord{name: fqdn, kind: kind, value: value, ttl: ttl})
+	}
+	var err error
+	kinds := []string{kind}
+	if kind == "ANY" {
+		kinds = []string{"A", "AAAA", "MX", "NS", "TXT"}
+	}
+	r := net.DefaultResolver
+	for _, k := range kinds {
+		switch k {
+		case "A", "AAAA":
+			var cname string
+			if cname, err = r.LookupCNAME(ctx, fqdn); err != nil {
+				cname = fqdn
+			} else if strings.TrimSuffix(cname, ".") != fqdn && kind != "ANY" {
+				add("CNAME", cname)
+			}
+			var ips []net.IPAddr
+			if ips, err = r.LookupIPAddr(ctx, fqdn); err == nil {
+				for _, ip := range ips {
+					if (ip.IP.To4() != nil) == (k == "A") {
+						answer.records = append(answer.records, dnsRecord{name: strings.TrimSuffix(cname, "."),
+							kind: k, value: ip.IP.String(), ttl: ttl})
+					}
+				}
+			}
+		case "CNAME":
+			var cname string
+			if cname, err = r.LookupCNAME(ctx, fqdn); err == nil && strings.TrimSuffix(cname, ".") != fqdn {
+				add("CNAME", cname)
+			}
+		case "MX":
+			var mxs []*net.MX
+			if mxs, err = r.LookupMX(ctx, fqdn); err == nil {
+				for _, mx := range mxs {
+					add("MX", fmt.Sprintf("%d %v", mx.Pref, mx.Host))
+				}
+			}
+		case "NS":
+			var nss []*net.NS
+			if nss, err = r.LookupNS(ctx, fqdn); err == nil {
+				for _, ns := range nss {
+					add("NS", ns.Host)
+				}
+			}
+		case "TXT":
+			var txts []string
+			if txts, err = r.LookupTXT(ctx, fqdn); err == nil {
+				for _, txt := range txts {
+					add("TXT", fmt.Sprintf("%q", txt))
+				}
+			}
+		case "PTR":
+			var names []string
+			if ip := dnsUnreverse(fqdn); ip != nil {
+				if names, err = r.LookupAddr(ctx, ip.String()); err == nil {
+					for _, n := range names {
+						add("PTR", dnsFQDN(n))
+					}
+				}
+			}
+		}
+		// DNSError.IsNotFound needs Go 1.13, match the resolver's message instead
+		if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.Err != "no such host" {
+			answer.status = "SERVFAIL"
+		} else if ok && len(answer.records) == 0 && kind != "ANY" {
+			answer.status = "NXDOMAIN"
+		}
+	}
+	if ctx.Err() != nil && sys.Context().Err() == nil {
+		answer.status = "timeout"
+	}
+	answer.rtt = time.Since(start)
+	return answer
+}
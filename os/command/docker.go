@@ -0,0 +1,1009 @@
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/pflag"
+)
+
+// docker is the client of the docker daemon, over the containers and images
+// of the session. The shells of the containers are nested shells on the
+// honeypot with the container as hostname
+type docker struct{}
+
+func init() {
+	honeyos.RegisterCommand("docker", docker{})
+}
+
+func (docker) GetHelp() string {
+	return "\nUsage:\tdocker [OPTIONS] COMMAND\n\n" +
+		"A self-sufficient runtime for containers\n\nOptions:\n" +
+		"      --config string      Location of client config files (default \"/root/.docker\")\n" +
+		"  -D, --debug              Enable debug mode\n" +
+		"  -H, --host list          Daemon socket(s) to connect to\n" +
+		"  -l, --log-level string   Set the logging level (\"debug\"|\"info\"|\"warn\"|\"error\"|\"fatal\") (default \"info\")\n" +
+		"  -v, --version            Print version information and quit\n\n" +
+		"Management Commands:\n" +
+		"  container   Manage containers\n" +
+		"  image       Manage images\n\n" +
+		"Commands:\n" +
+		"  exec        Run a command in a running container\n" +
+		"  images      List images\n" +
+		"  info        Display system-wide information\n" +
+		"  inspect     Return low-level information on Docker objects\n" +
+		"  kill        Kill one or more running containers\n" +
+		"  logs        Fetch the logs of a container\n" +
+		"  ps          List containers\n" +
+		"  pull        Pull an image or a repository from a registry\n" +
+		"  restart     Restart one or more containers\n" +
+		"  rm          Remove one or more containers\n" +
+		"  rmi         Remove one or more images\n" +
+		"  run         Run a command in a new container\n" +
+		"  start       Start one or more stopped containers\n" +
+		"  stop        Stop one or more running containers\n" +
+		"  version     Show the Docker version information\n\n" +
+		"Run 'docker COMMAND --help' for more information on a command.\n"
+}
+
+func (docker) Where() string {
+	return "/usr/bin/docker"
+}
+
+// dockerUsage are the usage lines of the commands, shown when the arguments
+// are wrong
+var dockerUsage = map[string]string{
+	"exec":    "docker exec [OPTIONS] CONTAINER COMMAND [ARG...]\n\nRun a command in a running container",
+	"images":  "docker images [OPTIONS] [REPOSITORY[:TAG]]\n\nList images",
+	"inspect": "docker inspect [OPTIONS] NAME|ID [NAME|ID...]\n\nReturn low-level information on Docker objects",
+	"kill":    "docker kill [OPTIONS] CONTAINER [CONTAINER...]\n\nKill one or more running containers",
+	"logs":    "docker logs [OPTIONS] CONTAINER\n\nFetch the logs of a container",
+	"ps":      "docker ps [OPTIONS]\n\nList containers",
+	"pull":    "docker pull [OPTIONS] NAME[:TAG|@DIGEST]\n\nPull an image or a repository from a registry",
+	"restart": "docker restart [OPTIONS] CONTAINER [CONTAINER...]\n\nRestart one or more containers",
+	"rm":      "docker rm [OPTIONS] CONTAINER [CONTAINER...]\n\nRemove one or more containers",
+	"rmi":     "docker rmi [OPTIONS] IMAGE [IMAGE...]\n\nRemove one or more images",
+	"run":     "docker run [OPTIONS] IMAGE [COMMAND] [ARG...]\n\nRun a command in a new container",
+	"start":   "docker start [OPTIONS] CONTAINER [CONTAINER...]\n\nStart one or more stopped containers",
+	"stop":    "docker stop [OPTIONS] CONTAINER [CONTAINER...]\n\nStop one or more running containers",
+	"version": "docker version [OPTIONS]\n\nShow the Docker version information",
+	"info":    "docker info [OPTIONS]\n\nDisplay system-wide information",
+}
+
+// dockerEndpoints are the API paths the commands call first, shown in the
+// error when the socket cannot be connected
+var dockerEndpoints = map[string]string{
+	"ps": "Get http://%2Fvar%2Frun%2Fdocker.sock/v1.40/containers/json", "images": "Get http://%2Fvar%2Frun%2Fdocker.sock/v1.40/images/json",
+	"run": "Post http://%2Fvar%2Frun%2Fdocker.sock/v1.40/containers/create", "pull": "Post http://%2Fvar%2Frun%2Fdocker.sock/v1.40/images/create",
+	"info": "Get http://%2Fvar%2Frun%2Fdocker.sock/v1.40/info", "version": "Get http://%2Fvar%2Frun%2Fdocker.sock/v1.40/version",
+}
+
+// dockerShells are the commands taken as a shell of the container
+var dockerShells = map[string]bool{"sh": true, "bash": true, "ash": true, "zsh": true}
+
+// dockerNames are put together as the names of the containers run without
+// --name, like docker does
+var dockerNames = [2][]string{
+	{"admiring", "affectionate", "amazing", "angry", "awesome", "boring", "busy", "clever", "cool", "eager",
+		"ecstatic", "elegant", "epic", "festive", "focused", "gifted", "happy", "hungry", "jolly", "keen",
+		"loving", "modest", "nervous", "nifty", "pedantic", "quirky", "relaxed", "sharp", "stoic", "vibrant"},
+	{"albattani", "austin", "babbage", "bardeen", "bohr", "curie", "darwin", "dijkstra", "einstein", "euclid",
+		"feynman", "galileo", "hopper", "hypatia", "kepler", "knuth", "lamport", "lovelace", "mccarthy", "newton",
+		"noether", "pasteur", "ritchie", "shannon", "tesla", "thompson", "torvalds", "turing", "wozniak", "yonath"},
+}
+
+// dockerDuration formats the duration like docker does, as 3 weeks or About
+// an hour
+func dockerDuration(d time.Duration) string {
+	seconds, minutes, hours := int(d.Seconds()), int(d.Minutes()), int(d.Hours()+0.5)
+	switch {
+	case seconds < 1:
+		return "Less than a second"
+	case seconds == 1:
+		return "1 second"
+	case seconds < 60:
+		return fmt.Sprintf("%d seconds", seconds)
+	case minutes == 1:
+		return "About a minute"
+	case minutes < 60:
+		return fmt.Sprintf("%d minutes", minutes)
+	case hours == 1:
+		return "About an hour"
+	case hours < 48:
+		return fmt.Sprintf("%d hours", hours)
+	case hours < 24*7*2:
+		return fmt.Sprintf("%d days", hours/24)
+	case hours < 24*30*2:
+		return fmt.Sprintf("%d weeks", hours/24/7)
+	case hours < 24*365*2:
+		return fmt.Sprintf("%d months", hours/24/30)
+	}
+	return fmt.Sprintf("%d years", int(d.Hours())/24/365)
+}
+
+// dockerSize formats the size in bytes like docker does, as 133MB
+func dockerSize(n int64) string {
+	size, unit := float64(n), 0
+	units := []string{"B", "kB", "MB", "GB", "TB"}
+	for size >= 1000 && unit < len(units)-1 {
+		size /= 1000
+		unit++
+	}
+	return fmt.Sprintf("%.3g%s", size, units[unit])
+}
+
+// dockerStatus is the state of the container as docker ps shows it
+func dockerStatus(c *honeyos.Container) string {
+	switch {
+	case c.Running:
+		return "Up " + dockerDuration(time.Since(c.Started))
+	case c.Started.IsZero():
+		return "Created"
+	}
+	return fmt.Sprintf("Exited (%v) %v ago", c.ExitCode, dockerDuration(time.Since(c.Finished)))
+}
+
+// dockerRef returns the image reference in full, like nginx:latest
+func dockerRef(ref string) string {
+	return honeyos.NewImage(ref, time.Now()).Ref()
+}
+
+// findContainer finds the container by name or the start of the ID
+func findContainer(containers []*honeyos.Container, name string) *honeyos.Container {
+	for _, c := range containers {
+		if c.Name == name || strings.TrimPrefix(name, "/") == c.Name {
+			return c
+		}
+	}
+	var found *honeyos.Container
+	for _, c := range containers {
+		if name != "" && strings.HasPrefix(c.ID, name) {
+			if found != nil {
+				return nil
+			}
+			found = c
+		}
+	}
+	return found
+}
+
+// findImage finds the image by reference or the start of the ID
+func findImage(images []*honeyos.Image, ref string) *honeyos.Image {
+	for _, img := range images {
+		if img.Ref() == dockerRef(ref) {
+			return img
+		}
+	}
+	for _, img := range images {
+		if len(ref) >= 4 && strings.HasPrefix(img.ID, strings.TrimPrefix(ref, "sha256:")) {
+			return img
+		}
+	}
+	return nil
+}
+
+// dockerFormat prints the rows with the Go template, like --format does. A
+// template starting with table is printed as columns with the headers
+func dockerFormat(sys honeyos.Sys, format string, headers map[string]string, rows []map[string]string) int {
+	format = strings.NewReplacer(`\t`, "\t", `\n`, "\n").Replace(format)
+	table := strings.HasPrefix(format, "table")
+	if table {
+		format = strings.TrimSpace(strings.TrimPrefix(format, "table"))
+	}
+	tmpl, err := template.New("").Option("missingkey=zero").Parse(format)
+	if err != nil {
+		fmt.Fprintf(sys.Err(), "Template parsing error: %v\n", err)
+		return 64
+	}
+	out := tabwriter.NewWriter(sys.Out(), 20, 1, 3, ' ', 0)
+	if table {
+		rows = append([]map[string]string{headers}, rows...)
+	}
+	for _, row := range rows {
+		var b bytes.Buffer
+		if err := tmpl.Execute(&b, row); err != nil {
+			fmt.Fprintf(sys.Err(), "Template parsing error: %v\n", err)
+			return 64
+		}
+		if table {
+			fmt.Fprintln(out, b.String())
+		} else {
+			fmt.Fprintln(sys.Out(), b.String())
+		}
+	}
+	out.Flush()
+	return 0
+}
+
+// dockerFlags returns the flags of the command, the errors printed like
+// docker does
+func dockerFlags(sub string) *pflag.FlagSet {
+	flag := pflag.NewFlagSet(sub, pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	flag.Bool("help", false, "Print usage")
+	return flag
+}
+
+// dockerParse parses the arguments of the command, printing the error or the
+// help. ok is false if the command is done with the exit code returned
+func dockerParse(sys honeyos.Sys, sub string, flag *pflag.FlagSet, args []string, min int) (n int, ok bool) {
+	if err := flag.Parse(args); err != nil {
+		fmt.Fprintf(sys.Err(), "%v\nSee 'docker %v --help'.\n", err, sub)
+		return 125, false
+	}
+	if help, _ := flag.GetBool("help"); help {
+		fmt.Fprintf(sys.Out(), "\nUsage:\t%v\n", dockerUsage[sub])
+		return 0, false
+	}
+	if flag.NArg() < min {
+		plural := ""
+		if min > 1 {
+			plural = "s"
+		}
+		fmt.Fprintf(sys.Err(), "\"docker %v\" requires at least %v argument%v.\nSee 'docker %v --help'.\n\nUsage:  %v\n",
+			sub, min, plural, sub, dockerUsage[sub])
+		return 1, false
+	}
+	return 0, true
+}
+
+func (d docker) Exec(args []string, sys honeyos.Sys) int {
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		switch args[0] {
+		case "-v", "--version":
+			fmt.Fprintln(sys.Out(), "Docker version 19.03.13, build 4484c46d9d")
+			return 0
+		case "-h", "--help":
+			fmt.Fprint(sys.Out(), d.GetHelp())
+			return 0
+		case "-D", "--debug":
+		case "-H", "--host", "-l", "--log-level", "--config":
+			if len(args) > 1 {
+				args = args[1:]
+			}
+		default:
+			fmt.Fprintf(sys.Err(), "unknown flag: %v\nSee 'docker --help'.\n", args[0])
+			return 125
+		}
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		fmt.Fprint(sys.Out(), d.GetHelp())
+		return 0
+	}
+	sub, args := args[0], args[1:]
+	// The management commands are the same as the short ones
+	if (sub == "container" || sub == "image") && len(args) > 0 {
+		switch name := args[0]; {
+		case name == "ls" || name == "list":
+			sub = map[string]string{"container": "ps", "image": "images"}[sub]
+		case sub == "image" && name == "rm":
+			sub = "rmi"
+		default:
+			sub = name
+		}
+		args = args[1:]
+	}
+	if _, ok := dockerUsage[sub]; !ok {
+		fmt.Fprintf(sys.Err(), "docker: '%v' is not a docker command.\nSee 'docker --help'\n", sub)
+		return 1
+	}
+	if sub == "version" {
+		return d.version(sys)
+	}
+	if !isRoot(sys) {
+		endpoint, ok := dockerEndpoints[sub]
+		if !ok {
+			endpoint = "Get http://%2Fvar%2Frun%2Fdocker.sock/v1.40/containers/json"
+		}
+		fmt.Fprintf(sys.Err(), "Got permission denied while trying to connect to the Docker daemon socket at "+
+			"unix:///var/run/docker.sock: %v: dial unix /var/run/docker.sock: connect: permission denied\n", endpoint)
+		return 1
+	}
+	switch sub {
+	case "ps":
+		return d.ps(args, sys)
+	case "images":
+		return d.images(args, sys)
+	case "pull":
+		return d.pull(args, sys)
+	case "run":
+		return d.run(args, sys)
+	case "exec":
+		return d.exec(args, sys)
+	case "inspect":
+		return d.inspect(args, sys)
+	case "info":
+		return d.info(sys)
+	case "rmi":
+		return d.rmi(args, sys)
+	}
+	return d.control(sub, args, sys)
+}
+
+func (docker) version(sys honeyos.Sys) int {
+	fmt.Fprint(sys.Out(), "Client: Docker Engine - Community\n Version:           19.03.13\n API version:       1.40\n"+
+		" Go version:        go1.13.15\n Git commit:        4484c46d9d\n Built:             Wed Sep 16 17:02:36 2020\n"+
+		" OS/Arch:           linux/amd64\n Experimental:      false\n")
+	if !isRoot(sys) {
+		fmt.Fprintf(sys.Err(), "Got permission denied while trying to connect to the Docker daemon socket at "+
+			"unix:///var/run/docker.sock: %v: dial unix /var/run/docker.sock: connect: permission denied\n", dockerEndpoints["version"])
+		return 1
+	}
+	fmt.Fprint(sys.Out(), "\nServer: Docker Engine - Community\n Engine:\n  Version:          19.03.13\n"+
+		"  API version:      1.40 (minimum version 1.12)\n  Go version:       go1.13.15\n  Git commit:       4484c46d9d\n"+
+		"  Built:            Wed Sep 16 17:01:06 2020\n  OS/Arch:          linux/amd64\n  Experimental:     false\n"+
+		" containerd:\n  Version:          1.3.7\n  GitCommit:        8fba4e9a7d01810a393d5d25a3621dc101981175\n"+
+		" runc:\n  Version:          1.0.0-rc10\n  GitCommit:        dc9208a3303feef5b3839f4323d9beb36df0a9dd\n"+
+		" docker-init:\n  Version:          0.18.0\n  GitCommit:        fec3683\n")
+	return 0
+}
+
+func (docker) info(sys honeyos.Sys) int {
+	var total, running, stopped, images int
+	honeyos.UpdateDocker(sys, func(containers *[]*honeyos.Container, imgs *[]*honeyos.Image) {
+		for _, c := range *containers {
+			if c.Running {
+				running++
+			} else {
+				stopped++
+			}
+		}
+		total, images = len(*containers), len(*imgs)
+	})
+	_, desc, _, _ := honeyos.DistroName()
+	fmt.Fprintf(sys.Out(), "Client:\n Debug Mode: false\n\nServer:\n Containers: %v\n  Running: %v\n  Paused: 0\n  Stopped: %v\n"+
+		" Images: %v\n Server Version: 19.03.13\n Storage Driver: overlay2\n  Backing Filesystem: extfs\n"+
+		"  Supports d_type: true\n  Native Overlay Diff: true\n Logging Driver: json-file\n Cgroup Driver: cgroupfs\n"+
+		" Plugins:\n  Volume: local\n  Network: bridge host ipvlan macvlan null overlay\n"+
+		"  Log: awslogs fluentd gcplogs gelf journald json-file local logentries splunk syslog\n Swarm: inactive\n"+
+		" Runtimes: runc\n Default Runtime: runc\n Init Binary: docker-init\n Security Options:\n  apparmor\n  seccomp\n"+
+		"   Profile: default\n Kernel Version: %v\n Operating System: %v\n OSType: linux\n Architecture: %v\n"+
+		" CPUs: 1\n Total Memory: %.4gGiB\n Name: %v\n ID: %v\n Docker Root Dir: /var/lib/docker\n Debug Mode: false\n"+
+		" Registry: https://index.docker.io/v1/\n Labels:\n Experimental: false\n Insecure Registries:\n  127.0.0.0/8\n"+
+		" Live Restore Enabled: false\n\nWARNING: No swap limit support\n",
+		total, running, stopped, images, honeyos.KernelRelease(), desc, honeyos.Arch(),
+		float64(honeyos.MemTotal)/(1<<20), sys.Hostname(), dockerNodeID(sys.Hostname()))
+	return 0
+}
+
+// dockerNodeID is the ID of the daemon, like
+// 5ZXF:2YQP:MOWB:L5SN:7PTS:VQXC:6HTD:JG2D:4S3D:BUB6:BPYH:JPCS
+func dockerNodeID(seed string) string {
+	const chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567"
+	id := honeyos.DockerID("node " + seed)
+	var parts []string
+	for i := 0; i < 12; i++ {
+		var part []byte
+		for j := 0; j < 4; j++ {
+			part = append(part, chars[int(id[(i*4+j)%len(id)])*7%len(chars)])
+		}
+		parts = append(parts, string(part))
+	}
+	return strings.Join(parts, ":")
+}
+
+func (docker) ps(args []string, sys honeyos.Sys) int {
+	flag := dockerFlags("ps")
+	all := flag.BoolP("all", "a", false, "Show all containers (default shows just running)")
+	flag.StringArrayP("filter", "f", nil, "Filter output based on conditions provided")
+	format := flag.String("format", "", "Pretty-print containers using a Go template")
+	last := flag.IntP("last", "n", -1, "Show n last created containers (includes all states)")
+	latest := flag.BoolP("latest", "l", false, "Show the latest created container (includes all states)")
+	noTrunc := flag.Bool("no-trunc", false, "Don't truncate output")
+	quiet := flag.BoolP("quiet", "q", false, "Only display numeric IDs")
+	size := flag.BoolP("size", "s", false, "Display total file sizes")
+	if n, ok := dockerParse(sys, "ps", flag, args, 0); !ok {
+		return n
+	}
+	if *latest {
+		*last = 1
+	}
+	var rows []map[string]string
+	honeyos.UpdateDocker(sys, func(containers *[]*honeyos.Container, images *[]*honeyos.Image) {
+		for i := len(*containers) - 1; i >= 0 && (*last < 0 || len(rows) < *last); i-- {
+			c := (*containers)[i]
+			if !c.Running && !*all && *last < 0 {
+				continue
+			}
+			id, command := c.ID[:12], c.Command
+			if *noTrunc {
+				id = c.ID
+			} else if len([]rune(command)) > 20 {
+				command = string([]rune(command)[:19]) + "…"
+			}
+			virtual := int64(0)
+			if img := findImage(*images, c.Image); img != nil {
+				virtual = img.Size
+			}
+			rows = append(rows, map[string]string{
+				"ID": id, "Image": c.Image, "Command": strconv.Quote(command),
+				"CreatedAt": c.Created.Format("2006-01-02 15:04:05 -0700 MST"), "RunningFor": dockerDuration(time.Since(c.Created)) + " ago",
+				"Status": dockerStatus(c), "Ports": c.Ports, "Names": c.Name, "Networks": "bridge",
+				"Size": fmt.Sprintf("0B (virtual %v)", dockerSize(virtual)),
+			})
+		}
+	})
+	switch {
+	case *format != "":
+	case *quiet:
+		*format = "{{.ID}}"
+	case *size:
+		*format = "table {{.ID}}\t{{.Image}}\t{{.Command}}\t{{.RunningFor}}\t{{.Status}}\t{{.Ports}}\t{{.Names}}\t{{.Size}}"
+	default:
+		*format = "table {{.ID}}\t{{.Image}}\t{{.Command}}\t{{.RunningFor}}\t{{.Status}}\t{{.Ports}}\t{{.Names}}"
+	}
+	return dockerFormat(sys, *format, map[string]string{
+		"ID": "CONTAINER ID", "Image": "IMAGE", "Command": "COMMAND", "CreatedAt": "CREATED AT", "RunningFor": "CREATED",
+		"Status": "STATUS", "Ports": "PORTS", "Names": "NAMES", "Networks": "NETWORKS", "Size": "SIZE",
+	}, rows)
+}
+
+func (docker) images(args []string, sys honeyos.Sys) int {
+	flag := dockerFlags("images")
+	flag.BoolP("all", "a", false, "Show all images (default hides intermediate images)")
+	digests := flag.Bool("digests", false, "Show digests")
+	flag.StringArrayP("filter", "f", nil, "Filter output based on conditions provided")
+	format := flag.String("format", "", "Pretty-print images using a Go template")
+	noTrunc := flag.Bool("no-trunc", false, "Don't truncate output")
+	quiet := flag.BoolP("quiet", "q", false, "Only show numeric IDs")
+	if n, ok := dockerParse(sys, "images", flag, args, 0); !ok {
+		return n
+	}
+	var images []*honeyos.Image
+	honeyos.UpdateDocker(sys, func(_ *[]*honeyos.Container, imgs *[]*honeyos.Image) {
+		images = append(images, *imgs...)
+	})
+	sort.SliceStable(images, func(i, j int) bool { return images[i].Created.After(images[j].Created) })
+	var rows []map[string]string
+	seen := map[string]bool{}
+	for _, img := range images {
+		if flag.NArg() > 0 && img.Repository != flag.Arg(0) && img.Ref() != flag.Arg(0) {
+			continue
+		}
+		id := img.ID[:12]
+		if *noTrunc {
+			id = "sha256:" + img.ID
+		}
+		// Quiet lists every image once, even with several tags
+		if *quiet && seen[id] {
+			continue
+		}
+		seen[id] = true
+		rows = append(rows, map[string]string{
+			"Repository": img.Repository, "Tag": img.Tag, "ID": id, "Digest": "sha256:" + honeyos.DockerID("digest "+img.ID),
+			"CreatedSince": dockerDuration(time.Since(img.Created)) + " ago", "CreatedAt": img.Created.Format("2006-01-02 15:04:05 -0700 MST"),
+			"Size": dockerSize(img.Size),
+		})
+	}
+	switch {
+	case *format != "":
+	case *quiet:
+		*format = "{{.ID}}"
+	case *digests:
+		*format = "table {{.Repository}}\t{{.Tag}}\t{{.Digest}}\t{{.ID}}\t{{.CreatedSince}}\t{{.Size}}"
+	default:
+		*format = "table {{.Repository}}\t{{.Tag}}\t{{.ID}}\t{{.CreatedSince}}\t{{.Size}}"
+	}
+	return dockerFormat(sys, *format, map[string]string{
+		"Repository": "REPOSITORY", "Tag": "TAG", "ID": "IMAGE ID", "Digest": "DIGEST", "CreatedSince": "CREATED",
+		"CreatedAt": "CREATED AT", "Size": "SIZE",
+	}, rows)
+}
+
+func (d docker) pull(args []string, sys honeyos.Sys) int {
+	flag := dockerFlags("pull")
+	flag.BoolP("all-tags", "a", false, "Download all tagged images in the repository")
+	flag.BoolP("quiet", "q", false, "Suppress verbose output")
+	if n, ok := dockerParse(sys, "pull", flag, args, 1); !ok {
+		return n
+	}
+	if flag.NArg() > 1 {
+		fmt.Fprint(sys.Err(), "\"docker pull\" requires exactly 1 argument.\nSee 'docker pull --help'.\n\nUsage:  "+dockerUsage["pull"]+"\n")
+		return 1
+	}
+	if _, ok := d.fetch(sys, flag.Arg(0)); !ok {
+		return 1
+	}
+	return 0
+}
+
+// fetch pulls the image from the registry unless pulled already, printing
+// the progress like docker pull does. ok is false if interrupted
+func (docker) fetch(sys honeyos.Sys, ref string) (img *honeyos.Image, ok bool) {
+	img = honeyos.NewImage(ref, time.Now())
+	// Images on Docker Hub without user are the official ones in library
+	repo, registry := img.Repository, "docker.io"
+	if i := strings.Index(repo, "/"); i > 0 && strings.ContainsAny(repo[:i], ".:") {
+		registry, repo = repo[:i], repo[i+1:]
+	} else if !strings.Contains(repo, "/") {
+		repo = "library/" + repo
+	}
+	sys.Log().WithField("image", img.Ref()).Infof("User pulled image %v with docker", img.Ref())
+	if !strings.Contains(ref[strings.LastIndex(ref, "/")+1:], ":") {
+		fmt.Fprintln(sys.Out(), "Using default tag: latest")
+	}
+	fmt.Fprintf(sys.Out(), "%v: Pulling from %v\n", img.Tag, repo)
+	digest := "sha256:" + honeyos.DockerID("digest "+img.ID)
+	name := img.Ref()
+	if registry != "docker.io" {
+		name = registry + "/" + name
+	}
+	var existing *honeyos.Image
+	honeyos.UpdateDocker(sys, func(_ *[]*honeyos.Container, images *[]*honeyos.Image) {
+		existing = findImage(*images, img.Ref())
+	})
+	if existing != nil {
+		fmt.Fprintf(sys.Out(), "Digest: %v\nStatus: Image is up to date for %v\n%v/%v:%v\n", digest, name, registry, repo, img.Tag)
+		return existing, true
+	}
+	layers := 2 + int(img.ID[2])%5
+	for i := 0; i < layers; i++ {
+		fmt.Fprintf(sys.Out(), "%v: Pulling fs layer\n", honeyos.DockerID(img.ID + strconv.Itoa(i))[:12])
+	}
+	for i := 0; i < layers; i++ {
+		if !pkgSleep(sys, time.Duration(300+rand.Intn(900))*time.Millisecond) {
+			return nil, false
+		}
+		fmt.Fprintf(sys.Out(), "%v: Pull complete\n", honeyos.DockerID(img.ID + strconv.Itoa(i))[:12])
+	}
+	fmt.Fprintf(sys.Out(), "Digest: %v\nStatus: Downloaded newer image for %v\n%v/%v:%v\n", digest, name, registry, repo, img.Tag)
+	honeyos.UpdateDocker(sys, func(_ *[]*honeyos.Container, images *[]*honeyos.Image) {
+		*images = append(*images, img)
+	})
+	return img, true
+}
+
+// publish formats the port published with -p like docker ps shows it
+func publish(spec string, n int) string {
+	proto := "tcp"
+	if i := strings.Index(spec, "/"); i >= 0 {
+		spec, proto = spec[:i], spec[i+1:]
+	}
+	parts := strings.Split(spec, ":")
+	switch len(parts) {
+	case 1:
+		return fmt.Sprintf("0.0.0.0:%v->%v/%v", 32768+n, parts[0], proto)
+	case 2:
+		return fmt.Sprintf("0.0.0.0:%v->%v/%v", parts[0], parts[1], proto)
+	}
+	return fmt.Sprintf("%v:%v->%v/%v", parts[0], parts[1], parts[2], proto)
+}
+
+// shell runs the command in the container as a nested shell of the honeypot
+// on the container. Without command the shell reads from the input if
+// interactive, else exits at once
+func (docker) shell(sys honeyos.Sys, c *honeyos.Container, args []string, interactive bool, dir string, env []string) (int, bool) {
+	cred := honeyos.Credential{UID: 0, Hostname: c.ID[:12], Dir: "/", Env: map[string]string{"HOSTNAME": c.ID[:12]}}
+	if dir != "" {
+		cred.Dir = dir
+	}
+	for _, e := range env {
+		if i := strings.Index(e, "="); i > 0 {
+			cred.Env[e[:i]] = e[i+1:]
+		}
+	}
+	if len(args) == 1 && dockerShells[path.Base(args[0])] {
+		if !interactive {
+			return 0, true
+		}
+		return honeyos.RunAs(sys, cred, nil)
+	}
+	return honeyos.RunAs(sys, cred, args)
+}
+
+// dockerHello is the output of the hello-world image
+const dockerHello = "\nHello from Docker!\nThis message shows that your installation appears to be working correctly.\n\n" +
+	"To generate this message, Docker took the following steps:\n" +
+	" 1. The Docker client contacted the Docker daemon.\n" +
+	" 2. The Docker daemon pulled the \"hello-world\" image from the Docker Hub.\n    (amd64)\n" +
+	" 3. The Docker daemon created a new container from that image which runs the\n" +
+	"    executable that produces the output you are currently reading.\n" +
+	" 4. The Docker daemon streamed that output to the Docker client, which sent it\n    to your terminal.\n\n" +
+	"To try something more ambitious, you can run an Ubuntu container with:\n $ docker run -it ubuntu bash\n\n" +
+	"Share images, automate workflows, and more with a free Docker ID:\n https://hub.docker.com/\n\n" +
+	"For more examples and ideas, visit:\n https://docs.docker.com/get-started/\n\n"
+
+func (d docker) run(args []string, sys honeyos.Sys) int {
+	flag := dockerFlags("run")
+	flag.SetInterspersed(false)
+	detach := flag.BoolP("detach", "d", false, "Run container in background and print container ID")
+	interactive := flag.BoolP("interactive", "i", false, "Keep STDIN open even if not attached")
+	flag.BoolP("tty", "t", false, "Allocate a pseudo-TTY")
+	remove := flag.Bool("rm", false, "Automatically remove the container when it exits")
+	name := flag.String("name", "", "Assign a name to the container")
+	ports := flag.StringArrayP("publish", "p", nil, "Publish a container's port(s) to the host")
+	flag.BoolP("publish-all", "P", false, "Publish all exposed ports to random ports")
+	env := flag.StringArrayP("env", "e", nil, "Set environment variables")
+	volumes := flag.StringArrayP("volume", "v", nil, "Bind mount a volume")
+	flag.StringArray("mount", nil, "Attach a filesystem mount to the container")
+	privileged := flag.Bool("privileged", false, "Give extended privileges to this container")
+	entrypoint := flag.String("entrypoint", "", "Overwrite the default ENTRYPOINT of the image")
+	network := flag.String("network", "default", "Connect a container to a network")
+	flag.String("net", "default", "Connect a container to a network")
+	flag.StringP("user", "u", "", "Username or UID (format: <name|uid>[:<group|gid>])")
+	workdir := flag.StringP("workdir", "w", "", "Working directory inside the container")
+	flag.String("restart", "no", "Restart policy to apply when a container exits")
+	flag.StringP("hostname", "h", "", "Container host name")
+	flag.StringP("memory", "m", "", "Memory limit")
+	flag.String("cpus", "", "Number of CPUs")
+	flag.String("pid", "", "PID namespace to use")
+	flag.String("ipc", "", "IPC mode to use")
+	flag.StringArray("cap-add", nil, "Add Linux capabilities")
+	flag.StringArray("security-opt", nil, "Security Options")
+	flag.StringArray("device", nil, "Add a host device to the container")
+	flag.StringArrayP("label", "l", nil, "Set meta data on a container")
+	if n, ok := dockerParse(sys, "run", flag, args, 1); !ok {
+		return n
+	}
+	ref, cmd := flag.Arg(0), flag.Args()[1:]
+	if flag.Changed("net") {
+		*network, _ = flag.GetString("net")
+	}
+	sys.Log().WithField("image", ref).WithField("command", cmd).WithField("privileged", *privileged).
+		WithField("volumes", *volumes).WithField("network", *network).
+		Warnf("User ran container from image %v with docker", ref)
+
+	var img *honeyos.Image
+	var conflict *honeyos.Container
+	honeyos.UpdateDocker(sys, func(containers *[]*honeyos.Container, images *[]*honeyos.Image) {
+		img = findImage(*images, ref)
+		if *name != "" {
+			conflict = findContainer(*containers, *name)
+		}
+	})
+	if conflict != nil && conflict.Name == *name {
+		fmt.Fprintf(sys.Err(), "docker: Error response from daemon: Conflict. The container name \"/%v\" is already in use by "+
+			"container \"%v\". You have to remove (or rename) that container to be able to reuse that name.\n"+
+			"See 'docker run --help'.\n", *name, conflict.ID)
+		return 125
+	}
+	if img == nil {
+		fmt.Fprintf(sys.Err(), "Unable to find image '%v' locally\n", dockerRef(ref))
+		var ok bool
+		if img, ok = d.fetch(sys, ref); !ok {
+			return 130
+		}
+	}
+
+	c := &honeyos.Container{
+		ID: honeyos.DockerID(fmt.Sprint(time.Now().UnixNano(), ref)), Name: *name, Image: ref,
+		Command: img.Command, Created: time.Now(),
+	}
+	if len(cmd) > 0 {
+		c.Command = strings.Join(cmd, " ")
+	}
+	if *entrypoint != "" {
+		c.Command = strings.TrimSpace(*entrypoint + " " + strings.Join(cmd, " "))
+		cmd = append([]string{*entrypoint}, cmd...)
+	}
+	if c.Name == "" {
+		c.Name = dockerNames[0][rand.Intn(len(dockerNames[0]))] + "_" + dockerNames[1][rand.Intn(len(dockerNames[1]))]
+	}
+	var published []string
+	for i, p := range *ports {
+		published = append(published, publish(p, i))
+	}
+	c.Ports = strings.Join(published, ", ")
+	honeyos.UpdateDocker(sys, func(containers *[]*honeyos.Container, _ *[]*honeyos.Image) {
+		*containers = append(*containers, c)
+	})
+	finish := func(code int) {
+		honeyos.UpdateDocker(sys, func(containers *[]*honeyos.Container, _ *[]*honeyos.Image) {
+			c.Running, c.ExitCode, c.Finished = false, code, time.Now()
+			if !*remove {
+				return
+			}
+			for i, o := range *containers {
+				if o == c {
+					*containers = append((*containers)[:i], (*containers)[i+1:]...)
+					break
+				}
+			}
+		})
+	}
+	c.Running, c.Started = true, time.Now()
+
+	// The images of services run until stopped, shells until their input ends
+	daemon := len(cmd) == 0 && !dockerShells[path.Base(img.Command)]
+	if *detach {
+		fmt.Fprintln(sys.Out(), c.ID)
+		if !daemon && len(cmd) == 0 && !*interactive {
+			finish(0)
+		}
+		return 0
+	}
+	switch {
+	case img.Repository == "hello-world" && len(cmd) == 0:
+		fmt.Fprint(sys.Out(), dockerHello)
+		finish(0)
+		return 0
+	case daemon:
+		<-sys.Context().Done()
+		finish(0)
+		return 0
+	case len(cmd) == 0:
+		cmd = strings.Fields(img.Command)
+	}
+	n, found := d.shell(sys, c, cmd, *interactive, *workdir, *env)
+	if !found {
+		c.Started = time.Time{}
+		finish(127)
+		fmt.Fprintf(sys.Err(), "docker: Error response from daemon: OCI runtime create failed: container_linux.go:349: "+
+			"starting container process caused \"exec: \\\"%v\\\": executable file not found in $PATH\": unknown.\n", cmd[0])
+		return 127
+	}
+	finish(n)
+	return n
+}
+
+func (d docker) exec(args []string, sys honeyos.Sys) int {
+	flag := dockerFlags("exec")
+	flag.SetInterspersed(false)
+	flag.BoolP("detach", "d", false, "Detached mode: run command in the background")
+	interactive := flag.BoolP("interactive", "i", false, "Keep STDIN open even if not attached")
+	flag.BoolP("tty", "t", false, "Allocate a pseudo-TTY")
+	flag.Bool("privileged", false, "Give extended privileges to the command")
+	flag.StringP("user", "u", "", "Username or UID (format: <name|uid>[:<group|gid>])")
+	workdir := flag.StringP("workdir", "w", "", "Working directory inside the container")
+	env := flag.StringArrayP("env", "e", nil, "Set environment variables")
+	if n, ok := dockerParse(sys, "exec", flag, args, 2); !ok {
+		return n
+	}
+	var c *honeyos.Container
+	honeyos.UpdateDocker(sys, func(containers *[]*honeyos.Container, _ *[]*honeyos.Image) {
+		c = findContainer(*containers, flag.Arg(0))
+	})
+	switch {
+	case c == nil:
+		fmt.Fprintf(sys.Err(), "Error: No such container: %v\n", flag.Arg(0))
+		return 1
+	case !c.Running:
+		fmt.Fprintf(sys.Err(), "Error response from daemon: Container %v is not running\n", c.ID)
+		return 1
+	}
+	cmd := flag.Args()[1:]
+	sys.Log().WithField("container", c.Name).WithField("image", c.Image).WithField("command", cmd).
+		Infof("User executed %v in container %v with docker", strings.Join(cmd, " "), c.Name)
+	n, found := d.shell(sys, c, cmd, *interactive, *workdir, *env)
+	if !found {
+		fmt.Fprintf(sys.Out(), "OCI runtime exec failed: exec failed: container_linux.go:349: starting container process "+
+			"caused \"exec: \\\"%v\\\": executable file not found in $PATH\": unknown\n", cmd[0])
+		return 126
+	}
+	return n
+}
+
+// control starts, stops and removes the containers, printing them as given
+func (docker) control(sub string, args []string, sys honeyos.Sys) int {
+	flag := dockerFlags(sub)
+	force := flag.BoolP("force", "f", false, "Force the removal of a running container (uses SIGKILL)")
+	flag.BoolP("volumes", "v", false, "Remove the volumes associated with the container")
+	flag.IntP("time", "t", 10, "Seconds to wait for stop before killing it")
+	flag.StringP("signal", "s", "KILL", "Signal to send to the container")
+	flag.BoolP("attach", "a", false, "Attach STDOUT/STDERR and forward signals")
+	flag.Bool("follow", false, "Follow log output")
+	flag.String("tail", "all", "Number of lines to show from the end of the logs")
+	if n, ok := dockerParse(sys, sub, flag, args, 1); !ok {
+		return n
+	}
+	status := 0
+	for _, name := range flag.Args() {
+		var errMsg string
+		honeyos.UpdateDocker(sys, func(containers *[]*honeyos.Container, _ *[]*honeyos.Image) {
+			c := findContainer(*containers, name)
+			if c == nil {
+				errMsg = "Error: No such container: " + name
+				if sub != "logs" {
+					errMsg = "Error response from daemon: No such container: " + name
+				}
+				return
+			}
+			switch sub {
+			case "start", "restart":
+				if !c.Running || sub == "restart" {
+					c.Running, c.Started = true, time.Now()
+				}
+			case "stop", "kill":
+				if !c.Running && sub == "kill" {
+					errMsg = fmt.Sprintf("Error response from daemon: Cannot kill container: %v: Container %v is not running", name, c.ID)
+					return
+				}
+				if c.Running {
+					c.Running, c.Finished, c.ExitCode = false, time.Now(), 0
+					if sub == "kill" {
+						c.ExitCode = 137
+					}
+				}
+			case "rm":
+				if c.Running && !*force {
+					errMsg = fmt.Sprintf("Error response from daemon: You cannot remove a running container %v. "+
+						"Stop the container before attempting removal or force remove", c.ID)
+					return
+				}
+				for i, o := range *containers {
+					if o == c {
+						*containers = append((*containers)[:i], (*containers)[i+1:]...)
+						break
+					}
+				}
+			}
+		})
+		switch {
+		case errMsg != "":
+			fmt.Fprintln(sys.Err(), errMsg)
+			status = 1
+		case sub == "logs":
+		default:
+			// Stopping waits for the processes of the container to exit
+			if (sub == "stop" || sub == "restart") && !pkgSleep(sys, time.Second) {
+				return 130
+			}
+			fmt.Fprintln(sys.Out(), name)
+		}
+	}
+	sys.Log().WithField("containers", flag.Args()).Infof("User ran docker %v", sub)
+	return status
+}
+
+func (docker) rmi(args []string, sys honeyos.Sys) int {
+	flag := dockerFlags("rmi")
+	force := flag.BoolP("force", "f", false, "Force removal of the image")
+	flag.Bool("no-prune", false, "Do not delete untagged parents")
+	if n, ok := dockerParse(sys, "rmi", flag, args, 1); !ok {
+		return n
+	}
+	status := 0
+	for _, ref := range flag.Args() {
+		honeyos.UpdateDocker(sys, func(containers *[]*honeyos.Container, images *[]*honeyos.Image) {
+			img := findImage(*images, ref)
+			if img == nil {
+				fmt.Fprintf(sys.Err(), "Error: No such image: %v\n", ref)
+				status = 1
+				return
+			}
+			for _, c := range *containers {
+				if findImage([]*honeyos.Image{img}, c.Image) != nil && !*force {
+					fmt.Fprintf(sys.Err(), "Error response from daemon: conflict: unable to remove repository reference "+
+						"\"%v\" (must force) - container %v is using its referenced image %v\n", ref, c.ID[:12], img.ID[:12])
+					status = 1
+					return
+				}
+			}
+			for i, o := range *images {
+				if o == img {
+					*images = append((*images)[:i], (*images)[i+1:]...)
+					break
+				}
+			}
+			fmt.Fprintf(sys.Out(), "Untagged: %v\nUntagged: %v@sha256:%v\nDeleted: sha256:%v\n",
+				img.Ref(), img.Repository, honeyos.DockerID("digest "+img.ID), img.ID)
+		})
+	}
+	return status
+}
+
+func (docker) inspect(args []string, sys honeyos.Sys) int {
+	flag := dockerFlags("inspect")
+	flag.StringP("format", "f", "", "Format the output using the given Go template")
+	flag.BoolP("size", "s", false, "Display total file sizes if the type is container")
+	flag.String("type", "", "Return JSON for specified type")
+	if n, ok := dockerParse(sys, "inspect", flag, args, 1); !ok {
+		return n
+	}
+	var objects []interface{}
+	status := 0
+	honeyos.UpdateDocker(sys, func(containers *[]*honeyos.Container, images *[]*honeyos.Image) {
+		for _, name := range flag.Args() {
+			if c := findContainer(*containers, name); c != nil {
+				objects = append(objects, inspectContainer(c, *containers, *images))
+			} else if img := findImage(*images, name); img != nil {
+				objects = append(objects, inspectImage(img))
+			} else {
+				status = 1
+				defer fmt.Fprintf(sys.Err(), "Error: No such object: %v\n", name)
+			}
+		}
+	})
+	if objects == nil {
+		objects = []interface{}{}
+	}
+	out, _ := json.MarshalIndent(objects, "", "    ")
+	fmt.Fprintln(sys.Out(), string(out))
+	return status
+}
+
+// dockerTime formats the time in the JSON of docker inspect
+func dockerTime(t time.Time) string {
+	if t.IsZero() {
+		return "0001-01-01T00:00:00Z"
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+type inspectState struct {
+	Status                                 string
+	Running, Paused, Restarting, OOMKilled bool
+	Dead                                   bool
+	Pid, ExitCode                          int
+	Error                                  string
+	StartedAt, FinishedAt                  string
+}
+
+type inspectConfig struct {
+	Hostname, Domainname, User string
+	Env                        []string
+	Cmd                        []string
+	Image, WorkingDir          string
+}
+
+type inspectNetwork struct {
+	Gateway, IPAddress string
+	IPPrefixLen        int
+	MacAddress         string
+}
+
+func inspectContainer(c *honeyos.Container, containers []*honeyos.Container, images []*honeyos.Image) interface{} {
+	fields := strings.Fields(c.Command)
+	state := inspectState{Status: "exited", ExitCode: c.ExitCode, StartedAt: dockerTime(c.Started), FinishedAt: dockerTime(c.Finished)}
+	if c.Running {
+		state.Status, state.Running, state.Pid = "running", true, 1300+int(c.ID[0])*7%900
+	} else if c.Started.IsZero() {
+		state.Status = "created"
+	}
+	imageID := ""
+	if img := findImage(images, c.Image); img != nil {
+		imageID = "sha256:" + img.ID
+	}
+	var network inspectNetwork
+	for i, o := range containers {
+		if o == c && c.Running {
+			network = inspectNetwork{Gateway: "172.17.0.1", IPAddress: fmt.Sprintf("172.17.0.%v", i+2), IPPrefixLen: 16,
+				MacAddress: fmt.Sprintf("02:42:ac:11:00:%02x", i+2)}
+		}
+	}
+	return struct {
+		Id, Created, Path string
+		Args              []string
+		State             inspectState
+		Image             string
+		Name              string
+		RestartCount      int
+		Driver, Platform  string
+		Config            inspectConfig
+		NetworkSettings   inspectNetwork
+	}{
+		c.ID, dockerTime(c.Created), fields[0], fields[1:], state, imageID, "/" + c.Name, 0, "overlay2", "linux",
+		inspectConfig{Hostname: c.ID[:12], Env: []string{"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"},
+			Cmd: fields, Image: c.Image},
+		network,
+	}
+}
+
+func inspectImage(img *honeyos.Image) interface{} {
+	return struct {
+		Id                    string
+		RepoTags, RepoDigests []string
+		Created               string
+		DockerVersion         string
+		Architecture, Os      string
+		Size, VirtualSize     int64
+		Config                inspectConfig
+	}{
+		"sha256:" + img.ID, []string{img.Ref()}, []string{img.Repository + "@sha256:" + honeyos.DockerID("digest "+img.ID)},
+		dockerTime(img.Created), "19.03.12", "amd64", "linux", img.Size, img.Size,
+		inspectConfig{Env: []string{"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"},
+			Cmd: strings.Fields(img.Command)},
+	}
+}
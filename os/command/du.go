@@ -0,0 +1,196 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/pflag"
+)
+
+// du walks the virtual filesystem adding up the size of files, so the numbers
+// agree with what ls shows
+type du struct{}
+
+type duWalker struct {
+	sys                      honeyos.Sys
+	all, summarize, apparent bool
+	maxDepth                 int
+	unit                     int64
+	human                    float64
+	exclude                  []string
+	status                   int
+}
+
+func init() {
+	honeyos.RegisterCommand("du", du{})
+}
+
+func (du) GetHelp() string {
+	return "Usage: du [OPTION]... [FILE]...\nTry 'du --help' for more information.\n"
+}
+
+func (du) Where() string {
+	return "/usr/bin/du"
+}
+
+// diskBlocks is the disk usage of the file, allocated in blocks of 4K
+func diskBlocks(fi os.FileInfo) int64 {
+	if fi.IsDir() {
+		return 4096
+	}
+	return (fi.Size() + 4095) / 4096 * 4096
+}
+
+// humanSize formats the size like 4.0K or 40G, rounding up as coreutils do
+func humanSize(n int64, base float64) string {
+	v := float64(n)
+	if v < base {
+		return fmt.Sprint(n)
+	}
+	units := "KMGTPE"
+	i := -1
+	for v >= base && i < len(units)-1 {
+		v /= base
+		i++
+	}
+	if v < 10 {
+		v = math.Ceil(v*10) / 10
+		if v < 10 {
+			return fmt.Sprintf("%.1f%c", v, units[i])
+		}
+	}
+	v = math.Ceil(v)
+	if v >= base && i < len(units)-1 {
+		return fmt.Sprintf("1.0%c", units[i+1])
+	}
+	return fmt.Sprintf("%.0f%c", v, units[i])
+}
+
+func (d du) Exec(args []string, sys honeyos.Sys) int {
+	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	w := &duWalker{sys: sys, unit: 1024, maxDepth: -1}
+	flag.BoolVarP(&w.all, "all", "a", false, "write counts for all files, not just directories")
+	flag.BoolVarP(&w.summarize, "summarize", "s", false, "display only a total for each argument")
+	flag.BoolVar(&w.apparent, "apparent-size", false, "print apparent sizes, rather than disk usage")
+	bytes := flag.BoolP("bytes", "b", false, "equivalent to '--apparent-size --block-size=1'")
+	total := flag.BoolP("total", "c", false, "produce a grand total")
+	human := flag.BoolP("human-readable", "h", false, "print sizes in human readable format")
+	si := flag.Bool("si", false, "like -h, but use powers of 1000 not 1024")
+	flag.BoolP("k", "k", false, "like --block-size=1K")
+	mega := flag.BoolP("m", "m", false, "like --block-size=1M")
+	flag.IntVarP(&w.maxDepth, "max-depth", "d", -1, "print the total for a directory only if it is N or fewer levels below")
+	flag.StringArrayVar(&w.exclude, "exclude", nil, "exclude files that match PATTERN")
+	flag.BoolP("one-file-system", "x", false, "skip directories on different file systems")
+	flag.BoolP("dereference", "L", false, "dereference all symbolic links")
+	flag.BoolP("count-links", "l", false, "count sizes many times if hard linked")
+	if err := flag.Parse(args); err != nil {
+		msg := strings.Replace(err.Error(), "unknown shorthand flag: ", "invalid option -- ", 1)
+		if i := strings.Index(msg, " in -"); i >= 0 {
+			msg = msg[:i]
+		}
+		fmt.Fprintf(sys.Err(), "du: %v\nTry 'du --help' for more information.\n", msg)
+		return 1
+	}
+	switch {
+	case *bytes:
+		w.apparent, w.unit = true, 1
+	case *mega:
+		w.unit = 1 << 20
+	}
+	switch {
+	case *human:
+		w.human = 1024
+	case *si:
+		w.human = 1000
+	}
+	if w.summarize {
+		if w.all || flag.Changed("max-depth") && w.maxDepth != 0 {
+			fmt.Fprintln(sys.Err(), "du: cannot both summarize and show all entries\nTry 'du --help' for more information.")
+			return 1
+		}
+		w.maxDepth = 0
+	}
+	files := flag.Args()
+	if len(files) == 0 {
+		files = []string{"."}
+	}
+	var sum int64
+	for _, name := range files {
+		fi, err := sys.FSys().Stat(absPath(sys, name))
+		if err != nil {
+			reason := "No such file or directory"
+			if os.IsPermission(err) {
+				reason = "Permission denied"
+			}
+			fmt.Fprintf(sys.Err(), "du: cannot access '%v': %v\n", name, reason)
+			w.status = 1
+			continue
+		}
+		sum += w.walk(absPath(sys, name), name, fi, 0)
+		if sys.Context().Err() != nil {
+			return 130
+		}
+	}
+	if *total {
+		w.print(sum, "total")
+	}
+	return w.status
+}
+
+func (w *duWalker) size(fi os.FileInfo) int64 {
+	if w.apparent && !fi.IsDir() {
+		return fi.Size()
+	}
+	return diskBlocks(fi)
+}
+
+func (w *duWalker) print(n int64, name string) {
+	if w.human > 0 {
+		fmt.Fprintf(w.sys.Out(), "%v\t%v\n", humanSize(n, w.human), name)
+		return
+	}
+	fmt.Fprintf(w.sys.Out(), "%v\t%v\n", (n+w.unit-1)/w.unit, name)
+}
+
+// walk adds up the sizes under the path, printing the directories up to the
+// max depth and the files with -a
+func (w *duWalker) walk(p, display string, fi os.FileInfo, depth int) int64 {
+	sum := w.size(fi)
+	if fi.IsDir() && w.sys.Context().Err() == nil {
+		var entries []os.FileInfo
+		dir, err := w.sys.FSys().Open(p)
+		if err == nil {
+			entries, err = dir.Readdir(-1)
+			dir.Close()
+		}
+		if err != nil {
+			fmt.Fprintf(w.sys.Err(), "du: cannot read directory '%v': Permission denied\n", display)
+			w.status = 1
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	entries:
+		for _, e := range entries {
+			for _, pattern := range w.exclude {
+				if ok, _ := filepath.Match(pattern, e.Name()); ok {
+					continue entries
+				}
+			}
+			child := display + "/" + e.Name()
+			if strings.HasSuffix(display, "/") {
+				child = display + e.Name()
+			}
+			sum += w.walk(filepath.Join(p, e.Name()), child, e, depth+1)
+		}
+	}
+	if (fi.IsDir() || w.all || depth == 0) && (w.maxDepth < 0 || depth <= w.maxDepth) {
+		w.print(sum, display)
+	}
+	return sum
+}
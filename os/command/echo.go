@@ -0,0 +1,56 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mkishere/sshsyrup/os"
+)
+
+type echo struct{}
+
+func init() {
+	os.RegisterCommand("echo", echo{})
+}
+
+func (echo) GetHelp() string {
+	return ""
+}
+
+func (echo) Exec(args []string, sys os.Sys) int {
+	newLine, escape := true, false
+	// Like GNU echo, stop parsing options at the first non-option
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' && strings.Trim(args[0][1:], "neE") == "" {
+		for _, c := range args[0][1:] {
+			switch c {
+			case 'n':
+				newLine = false
+			case 'e':
+				escape = true
+			case 'E':
+				escape = false
+			}
+		}
+		args = args[1:]
+	}
+	str := strings.Join(args, " ")
+	if escape {
+		str = echoUnescape(str)
+	}
+	if newLine {
+		str += "\n"
+	}
+	fmt.Fprint(sys.Out(), str)
+	return 0
+}
+
+func (echo) Where() string {
+	return "/bin/echo"
+}
+
+var echoEscapes = strings.NewReplacer(`\\`, `\`, `\a`, "\a", `\b`, "\b", `\e`, "\x1b", `\f`, "\f",
+	`\n`, "\n", `\r`, "\r", `\t`, "\t", `\v`, "\v")
+
+func echoUnescape(s string) string {
+	return echoEscapes.Replace(s)
+}
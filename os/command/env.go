@@ -0,0 +1,260 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+)
+
+// env is env and printenv, which both print the environment
+type env struct {
+	name string
+}
+
+func init() {
+	honeyos.RegisterCommand("env", env{"env"})
+	honeyos.RegisterCommand("printenv", env{"printenv"})
+}
+
+func (e env) GetHelp() string {
+	if e.name == "printenv" {
+		return `Usage: printenv [OPTION]... [VARIABLE]...
+Print the values of the specified environment VARIABLE(s).
+If no VARIABLE is specified, print name and value pairs for them all.
+
+  -0, --null     end each output line with NUL, not newline
+      --help     display this help and exit
+      --version  output version information and exit
+`
+	}
+	return `Usage: env [OPTION]... [-] [NAME=VALUE]... [COMMAND [ARG]...]
+Set each NAME to VALUE in the environment and run COMMAND.
+
+Mandatory arguments to long options are mandatory for short options too.
+  -i, --ignore-environment  start with an empty environment
+  -0, --null           end each output line with NUL, not newline
+  -u, --unset=NAME     remove variable from the environment
+  -C, --chdir=DIR      change working directory to DIR
+  -S, --split-string=S  process and split S into separate arguments;
+                        used to pass multiple arguments on shebang lines
+  -v, --debug          print verbose information for each processing step
+      --help     display this help and exit
+      --version  output version information and exit
+
+A mere - implies -i.  If no COMMAND, print the resulting environment.
+`
+}
+
+func (e env) Where() string {
+	return "/usr/bin/" + e.name
+}
+
+func (e env) Exec(args []string, sys honeyos.Sys) int {
+	if e.name == "printenv" {
+		return e.printenv(args, sys)
+	}
+	var cred = honeyos.Credential{UID: sys.CurrentUser(), Env: map[string]string{}}
+	end := "\n"
+	i := 0
+	for ; i < len(args); i++ {
+		arg := args[i]
+		if arg == "-" {
+			cred.Clear = true
+			continue
+		}
+		if !strings.HasPrefix(arg, "-") {
+			break
+		}
+		if arg == "--" {
+			i++
+			break
+		}
+		if strings.HasPrefix(arg, "--") {
+			opt := strings.SplitN(arg[2:], "=", 2)
+			name, hasValue, value := opt[0], len(opt) == 2, ""
+			if hasValue {
+				value = opt[1]
+			}
+			switch name {
+			case "ignore-environment":
+				cred.Clear = true
+			case "null":
+				end = "\x00"
+			case "debug":
+			case "help":
+				fmt.Fprint(sys.Out(), e.GetHelp())
+				return 0
+			case "version":
+				fmt.Fprintln(sys.Out(), "env (GNU coreutils) 8.32")
+				return 0
+			case "unset", "chdir", "split-string":
+				if !hasValue {
+					if i+1 >= len(args) {
+						fmt.Fprintf(sys.Err(), "env: option '--%v' requires an argument\nTry 'env --help' for more information.\n", name)
+						return 125
+					}
+					i++
+					value = args[i]
+				}
+				if !e.option(name[0], value, &cred, &args, i) {
+					fmt.Fprintf(sys.Err(), "env: cannot unset '%v': Invalid argument\n", value)
+					return 125
+				}
+			default:
+				fmt.Fprintf(sys.Err(), "env: unrecognized option '%v'\nTry 'env --help' for more information.\n", arg)
+				return 125
+			}
+			continue
+		}
+		for j := 1; j < len(arg); j++ {
+			switch c := arg[j]; c {
+			case 'i':
+				cred.Clear = true
+			case '0':
+				end = "\x00"
+			case 'v':
+			case 'u', 'C', 'S':
+				value := arg[j+1:]
+				if value == "" {
+					if i+1 >= len(args) {
+						fmt.Fprintf(sys.Err(), "env: option requires an argument -- '%c'\nTry 'env --help' for more information.\n", c)
+						return 125
+					}
+					i++
+					value = args[i]
+				}
+				name := map[byte]byte{'u': 'u', 'C': 'c', 'S': 's'}[c]
+				if !e.option(name, value, &cred, &args, i) {
+					fmt.Fprintf(sys.Err(), "env: cannot unset '%v': Invalid argument\n", value)
+					return 125
+				}
+				j = len(arg)
+			default:
+				fmt.Fprintf(sys.Err(), "env: invalid option -- '%c'\nTry 'env --help' for more information.\n", c)
+				return 125
+			}
+		}
+	}
+	var assigned []string
+	for ; i < len(args) && strings.Contains(args[i], "="); i++ {
+		kv := strings.SplitN(args[i], "=", 2)
+		if kv[0] == "" {
+			fmt.Fprintf(sys.Err(), "env: cannot set '%v': Invalid argument\n", args[i])
+			return 125
+		}
+		if _, ok := cred.Env[kv[0]]; !ok {
+			assigned = append(assigned, kv[0])
+		}
+		cred.Env[kv[0]] = kv[1]
+	}
+	cmd := args[i:]
+	if len(cmd) == 0 {
+		// Without a command the resulting environment is printed, new
+		// variables last
+		var vars []string
+		if !cred.Clear {
+			vars = sys.Environ()
+		}
+		for _, kv := range vars {
+			k := strings.SplitN(kv, "=", 2)[0]
+			if v, ok := cred.Env[k]; ok {
+				fmt.Fprint(sys.Out(), k+"="+v+end)
+				delete(cred.Env, k)
+				continue
+			}
+			unset := false
+			for _, u := range cred.Unset {
+				unset = unset || u == k
+			}
+			if !unset {
+				fmt.Fprint(sys.Out(), kv+end)
+			}
+		}
+		for _, k := range assigned {
+			if v, ok := cred.Env[k]; ok {
+				fmt.Fprint(sys.Out(), k+"="+v+end)
+			}
+		}
+		return 0
+	}
+	if cred.Dir != "" {
+		if fi, err := sys.FSys().Stat(absPath(sys, cred.Dir)); err != nil || !fi.IsDir() {
+			fmt.Fprintf(sys.Err(), "env: cannot change directory to '%v': No such file or directory\n", cred.Dir)
+			return 125
+		}
+		cred.Dir = absPath(sys, cred.Dir)
+	}
+	if len(cred.Env) > 0 || cred.Clear || len(cred.Unset) > 0 {
+		sys.Log().WithField("env", cred.Env).WithField("clear", cred.Clear).
+			Infof("User ran %v with env", strings.Join(cmd, " "))
+	}
+	n, found := honeyos.RunAs(sys, cred, cmd)
+	if !found {
+		fmt.Fprintf(sys.Err(), "env: '%v': No such file or directory\n", cmd[0])
+		return 127
+	}
+	return n
+}
+
+// option applies the option taking a value: u for unset, c for chdir and
+// s for split-string, which puts the split words back into the arguments
+// after the current one
+func (env) option(name byte, value string, cred *honeyos.Credential, args *[]string, i int) bool {
+	switch name {
+	case 'u':
+		if value == "" || strings.Contains(value, "=") {
+			return false
+		}
+		cred.Unset = append(cred.Unset, value)
+	case 'c':
+		cred.Dir = value
+	case 's':
+		rest := append(strings.Fields(value), (*args)[i+1:]...)
+		*args = append((*args)[:i+1:i+1], rest...)
+	}
+	return true
+}
+
+func (e env) printenv(args []string, sys honeyos.Sys) int {
+	end := "\n"
+	var names []string
+	for _, arg := range args {
+		switch arg {
+		case "-0", "--null":
+			end = "\x00"
+		case "--help":
+			fmt.Fprint(sys.Out(), e.GetHelp())
+			return 0
+		case "--version":
+			fmt.Fprintln(sys.Out(), "printenv (GNU coreutils) 8.32")
+			return 0
+		default:
+			if strings.HasPrefix(arg, "-") && len(arg) > 1 {
+				fmt.Fprintf(sys.Err(), "printenv: invalid option -- '%v'\nTry 'printenv --help' for more information.\n", arg[1:2])
+				return 2
+			}
+			names = append(names, arg)
+		}
+	}
+	if len(names) == 0 {
+		for _, kv := range sys.Environ() {
+			fmt.Fprint(sys.Out(), kv+end)
+		}
+		return 0
+	}
+	status := 0
+	for _, name := range names {
+		found := false
+		for _, kv := range sys.Environ() {
+			if strings.HasPrefix(kv, name+"=") && !strings.Contains(name, "=") {
+				fmt.Fprint(sys.Out(), kv[len(name)+1:]+end)
+				found = true
+			}
+		}
+		if !found {
+			status = 1
+		}
+	}
+	return status
+}
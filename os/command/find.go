@@ -0,0 +1,781 @@
+package command
+
+import (
+	"fmt"
+	"math"
+	"os"
+	pathlib "path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/mkishere/sshsyrup/virtualfs"
+	log "github.com/sirupsen/logrus"
+)
+
+// find walks the virtual filesystem evaluating the expression on each file,
+// like GNU find. What is searched for tells what attackers are after, like
+// credentials and setuid binaries
+type find struct{}
+
+// findFile is the file the expression is evaluated on
+type findFile struct {
+	// path is as printed, starting with root which is the path given
+	path, root string
+	abs        string
+	fi         os.FileInfo
+	depth      int
+}
+
+// findExpr is the compiled expression. It returns whether the file matches,
+// which decides if the rest of -a and -o is evaluated
+type findExpr func(f *findFile) bool
+
+// finder keeps the state of the search
+type finder struct {
+	sys                honeyos.Sys
+	maxDepth, minDepth int
+	depthFirst         bool
+	hasAction          bool
+	status             int
+	prune, quit        bool
+	now                time.Time
+	// batches are the commands of -exec ... {} +, run with all the files at
+	// the end
+	batches []*findBatch
+}
+
+type findBatch struct {
+	args  []string
+	files []string
+}
+
+// findParser parses the expression, which is the arguments after the paths
+type findParser struct {
+	*finder
+	args []string
+	pos  int
+}
+
+const findUsage = "Usage: find [-H] [-L] [-P] [-Olevel] [-D debugopts] [path...] [expression]"
+
+func init() {
+	honeyos.RegisterCommand("find", find{})
+}
+
+func (find) GetHelp() string {
+	return findUsage + "\n"
+}
+
+func (find) Where() string {
+	return "/usr/bin/find"
+}
+
+func (find) Exec(args []string, sys honeyos.Sys) int {
+	for len(args) > 0 && (args[0] == "-H" || args[0] == "-L" || args[0] == "-P" || strings.HasPrefix(args[0], "-O")) {
+		args = args[1:]
+	}
+	if len(args) > 0 && (args[0] == "--help" || args[0] == "-help") {
+		fmt.Fprintln(sys.Out(), findUsage)
+		return 0
+	}
+	if len(args) > 0 && (args[0] == "--version" || args[0] == "-version") {
+		fmt.Fprintln(sys.Out(), "find (GNU findutils) 4.7.0-git")
+		return 0
+	}
+	var paths []string
+	for len(args) > 0 && !strings.HasPrefix(args[0], "-") && args[0] != "(" && args[0] != "!" {
+		paths = append(paths, args[0])
+		args = args[1:]
+	}
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	f := &finder{sys: sys, maxDepth: -1, now: time.Now()}
+	p := &findParser{finder: f, args: args}
+	expr, err := p.parse()
+	if err != nil {
+		fmt.Fprintf(sys.Err(), "find: %v\n", err)
+		return 1
+	}
+	if !f.hasAction {
+		match := expr
+		expr = func(file *findFile) bool {
+			if match(file) {
+				fmt.Fprintln(sys.Out(), file.path)
+			}
+			return true
+		}
+	}
+	sys.Log().WithFields(log.Fields{"paths": paths, "expression": strings.Join(args, " ")}).
+		Infof("User searching %v with find", strings.Join(paths, " "))
+
+	for _, root := range paths {
+		abs := absPath(sys, root)
+		fi, err := sys.FSys().Stat(abs)
+		if err != nil {
+			if os.IsPermission(err) {
+				fmt.Fprintf(sys.Err(), "find: ‘%v’: Permission denied\n", root)
+			} else {
+				fmt.Fprintf(sys.Err(), "find: ‘%v’: No such file or directory\n", root)
+			}
+			f.status = 1
+			continue
+		}
+		f.visit(expr, &findFile{path: root, root: root, abs: abs, fi: fi})
+		if f.quit {
+			break
+		}
+	}
+	for _, b := range f.batches {
+		if len(b.files) > 0 {
+			f.run(b.args, b.files, "")
+		}
+	}
+	return f.status
+}
+
+// visit evaluates the expression on the file, then descends into it if it
+// is a directory. Files are evaluated after their contents with -depth
+func (f *finder) visit(expr findExpr, file *findFile) {
+	if f.quit || f.sys.Context().Err() != nil {
+		return
+	}
+	f.prune = false
+	if !f.depthFirst && file.depth >= f.minDepth {
+		expr(file)
+	}
+	if file.fi.IsDir() && !f.prune && (f.maxDepth < 0 || file.depth < f.maxDepth) {
+		dir, err := f.sys.FSys().Open(file.abs)
+		var entries []os.FileInfo
+		if err == nil {
+			entries, err = dir.Readdir(-1)
+			dir.Close()
+		}
+		if err != nil {
+			fmt.Fprintf(f.sys.Err(), "find: ‘%v’: Permission denied\n", file.path)
+			f.status = 1
+		}
+		for _, fi := range entries {
+			name := file.path + "/" + fi.Name()
+			if strings.HasSuffix(file.path, "/") {
+				name = file.path + fi.Name()
+			}
+			f.visit(expr, &findFile{path: name, root: file.root, abs: pathlib.Join(file.abs, fi.Name()), fi: fi, depth: file.depth + 1})
+		}
+	}
+	if f.depthFirst && file.depth >= f.minDepth && !f.quit {
+		expr(file)
+	}
+}
+
+// run runs the command of -exec with {} replaced by the files. Status is
+// true if the command succeeds
+func (f *finder) run(args, files []string, dir string) bool {
+	var cmd []string
+	for _, arg := range args {
+		if arg == "{}" && len(files) > 1 {
+			cmd = append(cmd, files...)
+			continue
+		}
+		cmd = append(cmd, strings.Replace(arg, "{}", files[0], -1))
+	}
+	n, found := honeyos.RunAs(f.sys, honeyos.Credential{UID: f.sys.CurrentUser(), Dir: dir}, cmd)
+	if !found {
+		fmt.Fprintf(f.sys.Err(), "find: ‘%v’: No such file or directory\n", cmd[0])
+		f.status = 1
+		return false
+	}
+	if n != 0 && len(files) > 1 {
+		f.status = 1
+	}
+	return n == 0
+}
+
+func (p *findParser) parse() (findExpr, error) {
+	if p.pos >= len(p.args) {
+		return func(*findFile) bool { return true }, nil
+	}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.args) {
+		if p.args[p.pos] == ")" {
+			return nil, fmt.Errorf("invalid expression; you have too many ')'")
+		}
+		return nil, fmt.Errorf("paths must precede expression: `%v'\n%v", p.args[p.pos], findUsage)
+	}
+	return expr, nil
+}
+
+func (p *findParser) parseOr() (findExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.pos < len(p.args) && (p.args[p.pos] == "-o" || p.args[p.pos] == "-or" || p.args[p.pos] == ",") {
+		op := p.args[p.pos]
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		if op == "," {
+			left = func(f *findFile) bool { l(f); return right(f) }
+		} else {
+			left = func(f *findFile) bool { return l(f) || right(f) }
+		}
+	}
+	return left, nil
+}
+
+func (p *findParser) parseAnd() (findExpr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.pos < len(p.args) {
+		switch p.args[p.pos] {
+		case "-o", "-or", ",", ")":
+			return left, nil
+		case "-a", "-and":
+			p.pos++
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(f *findFile) bool { return l(f) && right(f) }
+	}
+	return left, nil
+}
+
+func (p *findParser) parseNot() (findExpr, error) {
+	if p.pos >= len(p.args) {
+		return nil, fmt.Errorf("invalid expression; you have used a binary operator '%v' with nothing after it.", p.args[p.pos-1])
+	}
+	if arg := p.args[p.pos]; arg == "!" || arg == "-not" {
+		p.pos++
+		e, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return func(f *findFile) bool { return !e(f) }, nil
+	}
+	return p.parsePrimary()
+}
+
+// value returns the argument of the test or action
+func (p *findParser) value(name string) (string, error) {
+	if p.pos >= len(p.args) {
+		return "", fmt.Errorf("missing argument to `%v'", name)
+	}
+	p.pos++
+	return p.args[p.pos-1], nil
+}
+
+// number parses the numeric argument of tests like -mtime, which is more
+// than n with +n and less than n with -n
+func (p *findParser) number(name string) (func(n int64) bool, string, error) {
+	arg, err := p.value(name)
+	if err != nil {
+		return nil, "", err
+	}
+	cmp, s := 0, arg
+	switch {
+	case strings.HasPrefix(s, "+"):
+		cmp, s = 1, s[1:]
+	case strings.HasPrefix(s, "-"):
+		cmp, s = -1, s[1:]
+	}
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	v, err := strconv.ParseInt(s[:i], 10, 64)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid argument `%v' to `%v'", arg, name)
+	}
+	return func(n int64) bool {
+		switch cmp {
+		case 1:
+			return n > v
+		case -1:
+			return n < v
+		}
+		return n == v
+	}, s[i:], nil
+}
+
+func (p *findParser) parsePrimary() (findExpr, error) {
+	arg := p.args[p.pos]
+	p.pos++
+	switch arg {
+	case "(":
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.pos >= len(p.args) || p.args[p.pos] != ")" {
+			return nil, fmt.Errorf("invalid expression; I was expecting to find a ')' somewhere but did not see one.")
+		}
+		p.pos++
+		return e, nil
+	case ")":
+		return nil, fmt.Errorf("invalid expression; you have too many ')'")
+	case "-true":
+		return func(*findFile) bool { return true }, nil
+	case "-false":
+		return func(*findFile) bool { return false }, nil
+
+	// Options, which apply to the whole search wherever they are
+	case "-maxdepth", "-mindepth":
+		v, err := p.value(arg)
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("Expected a positive decimal integer argument to %v, but got ‘%v’", arg, v)
+		}
+		if arg == "-maxdepth" {
+			p.maxDepth = n
+		} else {
+			p.minDepth = n
+		}
+		return func(*findFile) bool { return true }, nil
+	case "-depth", "-d":
+		p.depthFirst = true
+		return func(*findFile) bool { return true }, nil
+	case "-xdev", "-mount", "-follow", "-noleaf", "-daystart", "-ignore_readdir_race", "-nowarn", "-warn":
+		return func(*findFile) bool { return true }, nil
+	case "-regextype":
+		_, err := p.value(arg)
+		return func(*findFile) bool { return true }, err
+
+	// Tests
+	case "-name", "-iname", "-path", "-ipath", "-wholename", "-iwholename", "-lname", "-ilname":
+		pattern, err := p.value(arg)
+		if err != nil {
+			return nil, err
+		}
+		re := findPattern(pattern, strings.HasPrefix(arg, "-i"))
+		if strings.Contains(arg, "name") && !strings.Contains(arg, "wholename") {
+			return func(f *findFile) bool { return re.MatchString(pathlib.Base(f.path)) }, nil
+		}
+		return func(f *findFile) bool { return re.MatchString(f.path) }, nil
+	case "-regex", "-iregex":
+		expr, err := p.value(arg)
+		if err != nil {
+			return nil, err
+		}
+		if arg == "-iregex" {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("Invalid regular expression `%v'", expr)
+		}
+		return func(f *findFile) bool { return re.MatchString(f.path) }, nil
+	case "-type", "-xtype":
+		v, err := p.value(arg)
+		if err != nil {
+			return nil, err
+		}
+		types := strings.Split(v, ",")
+		for _, t := range types {
+			if len(t) != 1 || strings.IndexByte("bcdpflsD", t[0]) < 0 {
+				return nil, fmt.Errorf("Unknown argument to %v: %v", arg, t)
+			}
+		}
+		return func(f *findFile) bool {
+			for _, t := range types {
+				if findType(f.fi.Mode()) == t[0] {
+					return true
+				}
+			}
+			return false
+		}, nil
+	case "-mtime", "-atime", "-ctime", "-mmin", "-amin", "-cmin":
+		cmp, _, err := p.number(arg)
+		if err != nil {
+			return nil, err
+		}
+		unit := 24 * time.Hour
+		if strings.HasSuffix(arg, "min") {
+			unit = time.Minute
+		}
+		return func(f *findFile) bool {
+			return cmp(int64(math.Floor(float64(p.now.Sub(f.fi.ModTime())) / float64(unit))))
+		}, nil
+	case "-newer", "-anewer", "-cnewer":
+		name, err := p.value(arg)
+		if err != nil {
+			return nil, err
+		}
+		ref, err := p.sys.FSys().Stat(absPath(p.sys, name))
+		if err != nil {
+			return nil, fmt.Errorf("‘%v’: No such file or directory", name)
+		}
+		return func(f *findFile) bool { return f.fi.ModTime().After(ref.ModTime()) }, nil
+	case "-size":
+		cmp, suffix, err := p.number(arg)
+		if err != nil {
+			return nil, err
+		}
+		units := map[string]int64{"": 512, "b": 512, "c": 1, "w": 2, "k": 1024, "M": 1024 * 1024, "G": 1024 * 1024 * 1024}
+		unit, ok := units[suffix]
+		if !ok {
+			return nil, fmt.Errorf("invalid -size type `%v'", suffix)
+		}
+		return func(f *findFile) bool {
+			size := f.fi.Size()
+			if f.fi.IsDir() {
+				size = 4096
+			}
+			// Sizes are rounded up to the unit
+			return cmp((size + unit - 1) / unit)
+		}, nil
+	case "-empty":
+		return func(f *findFile) bool {
+			if !f.fi.IsDir() {
+				return f.fi.Mode().IsRegular() && f.fi.Size() == 0
+			}
+			dir, err := p.sys.FSys().Open(f.abs)
+			if err != nil {
+				return false
+			}
+			defer dir.Close()
+			names, _ := dir.Readdirnames(1)
+			return len(names) == 0
+		}, nil
+	case "-perm":
+		v, err := p.value(arg)
+		if err != nil {
+			return nil, err
+		}
+		kind, spec := byte(0), v
+		if strings.HasPrefix(spec, "-") || strings.HasPrefix(spec, "/") || strings.HasPrefix(spec, "+") {
+			kind, spec = spec[0], spec[1:]
+		}
+		m, ok := chmodMode(spec, 0, 0, false)
+		if !ok {
+			return nil, fmt.Errorf("invalid mode ‘%v’", v)
+		}
+		want := unixMode(m)
+		return func(f *findFile) bool {
+			mode := unixMode(f.fi.Mode())
+			switch kind {
+			case '-':
+				return mode&want == want
+			case '/', '+':
+				return want == 0 || mode&want != 0
+			}
+			return mode == want
+		}, nil
+	case "-user", "-uid", "-group", "-gid":
+		v, err := p.value(arg)
+		if err != nil {
+			return nil, err
+		}
+		id, err := strconv.Atoi(v)
+		switch {
+		case arg == "-user" && err != nil:
+			if _, exists := honeyos.IsUserExist(v); !exists {
+				return nil, fmt.Errorf("‘%v’ is not the name of a known user", v)
+			}
+			id = honeyos.GetUser(v).UID
+		case arg == "-group" && err != nil:
+			g, exists := honeyos.GetGroup(v)
+			if !exists {
+				return nil, fmt.Errorf("‘%v’ is not the name of an existing group", v)
+			}
+			id = g.GID
+		case err != nil:
+			return nil, fmt.Errorf("invalid argument `%v' to `%v'", v, arg)
+		}
+		byGroup := arg == "-group" || arg == "-gid"
+		return func(f *findFile) bool {
+			uid, gid, _, _ := virtualfs.GetExtraInfo(f.fi)
+			if byGroup {
+				return gid == id
+			}
+			return uid == id
+		}, nil
+	case "-nouser", "-nogroup":
+		return func(f *findFile) bool {
+			uid, gid, _, _ := virtualfs.GetExtraInfo(f.fi)
+			if arg == "-nouser" {
+				return honeyos.GetUserByID(uid).Name == ""
+			}
+			return honeyos.GetGroupByID(gid).Name == ""
+		}, nil
+	case "-readable", "-writable", "-executable":
+		want := map[string]os.FileMode{"-readable": 4, "-writable": 2, "-executable": 1}[arg]
+		return func(f *findFile) bool { return honeyos.Access(p.sys, f.abs, want) }, nil
+
+	// Actions
+	case "-print", "-print0":
+		p.hasAction = true
+		end := "\n"
+		if arg == "-print0" {
+			end = "\x00"
+		}
+		return func(f *findFile) bool {
+			fmt.Fprint(p.sys.Out(), f.path+end)
+			return true
+		}, nil
+	case "-printf":
+		p.hasAction = true
+		format, err := p.value(arg)
+		if err != nil {
+			return nil, err
+		}
+		return func(f *findFile) bool {
+			fmt.Fprint(p.sys.Out(), findPrintf(format, f))
+			return true
+		}, nil
+	case "-ls":
+		p.hasAction = true
+		return func(f *findFile) bool {
+			uid, gid, _, _ := virtualfs.GetExtraInfo(f.fi)
+			size := f.fi.Size()
+			if f.fi.IsDir() {
+				size = 4096
+			}
+			fmt.Fprintf(p.sys.Out(), "%9d %6d %v %3d %-8s %-8s %8d %v %v\n", fnvString(f.abs)%1000000+100000, (size+4095)/4096*4,
+				lsMode(f.fi.Mode()), 1, honeyos.GetUserByID(uid).Name, honeyos.GetGroupByID(gid).Name, size,
+				f.fi.ModTime().Format("Jan _2 15:04"), f.path)
+			return true
+		}, nil
+	case "-prune":
+		return func(*findFile) bool {
+			p.prune = true
+			return true
+		}, nil
+	case "-quit":
+		return func(*findFile) bool {
+			p.quit = true
+			return true
+		}, nil
+	case "-delete":
+		p.hasAction, p.depthFirst = true, true
+		return func(f *findFile) bool {
+			if f.path == "." {
+				return true
+			}
+			if err := p.sys.FSys().Remove(f.abs); err != nil {
+				reason := "Permission denied"
+				if f.fi.IsDir() && !os.IsPermission(err) {
+					reason = "Directory not empty"
+				}
+				fmt.Fprintf(p.sys.Err(), "find: cannot delete ‘%v’: %v\n", f.path, reason)
+				p.status = 1
+				return false
+			}
+			return true
+		}, nil
+	case "-exec", "-execdir", "-ok", "-okdir":
+		p.hasAction = true
+		var cmd []string
+		batch := false
+		for {
+			if p.pos >= len(p.args) {
+				return nil, fmt.Errorf("missing argument to `%v'", arg)
+			}
+			a := p.args[p.pos]
+			p.pos++
+			if a == ";" {
+				break
+			}
+			if a == "+" && len(cmd) > 0 && cmd[len(cmd)-1] == "{}" && !strings.HasPrefix(arg, "-ok") {
+				batch = true
+				break
+			}
+			cmd = append(cmd, a)
+		}
+		if len(cmd) == 0 {
+			return nil, fmt.Errorf("missing argument to `%v'", arg)
+		}
+		inDir := strings.HasSuffix(arg, "dir")
+		if batch {
+			b := &findBatch{args: cmd}
+			p.batches = append(p.batches, b)
+			return func(f *findFile) bool {
+				b.files = append(b.files, f.path)
+				return true
+			}, nil
+		}
+		return func(f *findFile) bool {
+			file, dir := f.path, ""
+			if inDir {
+				file, dir = "./"+pathlib.Base(f.abs), pathlib.Dir(f.abs)
+			}
+			if strings.HasPrefix(arg, "-ok") {
+				fmt.Fprintf(p.sys.Err(), "< %v ... %v > ? ", cmd[0], file)
+				answer, _ := readLine(p.sys.In())
+				if !strings.HasPrefix(strings.ToLower(answer), "y") {
+					return false
+				}
+			}
+			return p.run(cmd, []string{file}, dir)
+		}, nil
+	}
+	if strings.HasPrefix(arg, "-") {
+		return nil, fmt.Errorf("unknown predicate `%v'", arg)
+	}
+	return nil, fmt.Errorf("paths must precede expression: `%v'\n%v", arg, findUsage)
+}
+
+// findType is the letter of the file type for -type
+func findType(m os.FileMode) byte {
+	switch {
+	case m.IsDir():
+		return 'd'
+	case m&os.ModeSymlink != 0:
+		return 'l'
+	case m&os.ModeNamedPipe != 0:
+		return 'p'
+	case m&os.ModeSocket != 0:
+		return 's'
+	case m&os.ModeCharDevice != 0:
+		return 'c'
+	case m&os.ModeDevice != 0:
+		return 'b'
+	}
+	return 'f'
+}
+
+// findPattern converts the shell pattern to regexp. Unlike the shell, * and
+// ? match / and leading dot as well
+func findPattern(pattern string, fold bool) *regexp.Regexp {
+	var b strings.Builder
+	if fold {
+		b.WriteString("(?i)")
+	}
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			b.WriteString("(?s:.*)")
+		case '?':
+			b.WriteString("(?s:.)")
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			}
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if end == 0 {
+				// ] right after [ is part of the class
+				if next := strings.IndexByte(pattern[i+2:], ']'); next >= 0 {
+					class = pattern[i+1 : i+2+next]
+					end = next + 1
+				}
+			}
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.Replace(class, `\`, `\\`, -1) + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return regexp.MustCompile("^" + regexp.QuoteMeta(pattern) + "$")
+	}
+	return re
+}
+
+// findPrintf formats the file for -printf, with the directives commonly used
+func findPrintf(format string, f *findFile) string {
+	var b strings.Builder
+	uid, gid, _, _ := virtualfs.GetExtraInfo(f.fi)
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if (c != '%' && c != '\\') || i+1 >= len(format) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		d := format[i]
+		if c == '\\' {
+			switch d {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case '0':
+				b.WriteByte(0)
+			case '\\':
+				b.WriteByte('\\')
+			default:
+				b.WriteString("\\" + string(d))
+			}
+			continue
+		}
+		switch d {
+		case 'p':
+			b.WriteString(f.path)
+		case 'f':
+			b.WriteString(pathlib.Base(f.path))
+		case 'h':
+			b.WriteString(pathlib.Dir(f.path))
+		case 'P':
+			b.WriteString(strings.TrimPrefix(strings.TrimPrefix(f.path, f.root), "/"))
+		case 's':
+			b.WriteString(strconv.FormatInt(f.fi.Size(), 10))
+		case 'm':
+			b.WriteString(strconv.FormatUint(uint64(unixMode(f.fi.Mode())), 8))
+		case 'M':
+			b.WriteString(lsMode(f.fi.Mode()))
+		case 'u':
+			b.WriteString(honeyos.GetUserByID(uid).Name)
+		case 'g':
+			b.WriteString(honeyos.GetGroupByID(gid).Name)
+		case 'U':
+			b.WriteString(strconv.Itoa(uid))
+		case 'G':
+			b.WriteString(strconv.Itoa(gid))
+		case 'd':
+			b.WriteString(strconv.Itoa(f.depth))
+		case 'y':
+			b.WriteByte(findType(f.fi.Mode()))
+		case 't', 'a', 'c':
+			b.WriteString(f.fi.ModTime().Format("Mon Jan _2 15:04:05.0000000000 2006"))
+		case 'T', 'A', 'C':
+			// Only the common fields of the time, like %TY and %T@
+			layouts := map[byte]string{'Y': "2006", 'm': "01", 'd': "02", 'H': "15", 'M': "04", 'S': "05.0000000000",
+				'T': "15:04:05.0000000000", 'D': "01/02/06", 'F': "2006-01-02", '+': "2006-01-02+15:04:05.0000000000"}
+			if i+1 >= len(format) {
+				b.WriteString("%" + string(d))
+				continue
+			}
+			i++
+			if format[i] == '@' {
+				b.WriteString(fmt.Sprintf("%.10f", float64(f.fi.ModTime().UnixNano())/1e9))
+			} else if layout, ok := layouts[format[i]]; ok {
+				b.WriteString(f.fi.ModTime().Format(layout))
+			}
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteString("%" + string(d))
+		}
+	}
+	return b.String()
+}
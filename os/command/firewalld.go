@@ -0,0 +1,1002 @@
+package command
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/afero"
+)
+
+// firewallCmd is the client of firewalld, the firewall of CentOS. The zones
+// are kept in the files of firewalld for the permanent configuration, and
+// the runtime one is what is in the iptables tables of the session, so it
+// follows the changes made with iptables. Opening ports and trusting all
+// traffic are logged as warnings
+type firewallCmd struct{}
+
+const (
+	firewalldConf     = "/etc/firewalld/firewalld.conf"
+	firewalldZones    = "/etc/firewalld/zones/"
+	firewalldSysZones = "/usr/lib/firewalld/zones/"
+)
+
+// fwZone is the configuration of a zone. Ports are like 8080/tcp, and rich
+// rules as given to firewall-cmd
+type fwZone struct {
+	target   string
+	services []string
+	ports    []string
+	sources  []string
+	rich     []string
+}
+
+// fwService is a service of /usr/lib/firewalld/services. dest limits it to
+// the IPv6 address
+type fwService struct {
+	ports []string
+	dest  string
+}
+
+var (
+	fwServices = map[string]fwService{
+		"dhcpv6-client": {[]string{"546/udp"}, "fe80::/64"}, "dns": {[]string{"53/tcp", "53/udp"}, ""},
+		"ftp": {[]string{"21/tcp"}, ""}, "http": {[]string{"80/tcp"}, ""}, "https": {[]string{"443/tcp"}, ""},
+		"imaps": {[]string{"993/tcp"}, ""}, "mdns": {[]string{"5353/udp"}, "ff02::fb/128"},
+		"mysql": {[]string{"3306/tcp"}, ""}, "ntp": {[]string{"123/udp"}, ""},
+		"postgresql": {[]string{"5432/tcp"}, ""}, "redis": {[]string{"6379/tcp"}, ""},
+		"samba-client": {[]string{"137/udp", "138/udp"}, ""}, "smtp": {[]string{"25/tcp"}, ""},
+		"ssh": {[]string{"22/tcp"}, ""}, "telnet": {[]string{"23/tcp"}, ""},
+	}
+	// fwZones are the zones firewalld comes with
+	fwZones = map[string]fwZone{
+		"block":    {target: "%%REJECT%%"},
+		"dmz":      {target: "default", services: []string{"ssh"}},
+		"drop":     {target: "DROP"},
+		"external": {target: "default", services: []string{"ssh"}},
+		"home":     {target: "default", services: []string{"ssh", "mdns", "samba-client", "dhcpv6-client"}},
+		"internal": {target: "default", services: []string{"ssh", "mdns", "samba-client", "dhcpv6-client"}},
+		"public":   {target: "default", services: []string{"ssh", "dhcpv6-client"}},
+		"trusted":  {target: "ACCEPT"},
+		"work":     {target: "default", services: []string{"ssh", "dhcpv6-client"}},
+	}
+	fwZoneDescriptions = map[string]string{
+		"public": "For use in public areas. You do not trust the other computers on networks to not harm your computer. Only selected incoming connections are accepted.",
+	}
+)
+
+// fwZoneXML is the file of a zone
+type fwZoneXML struct {
+	XMLName     xml.Name `xml:"zone"`
+	Target      string   `xml:"target,attr,omitempty"`
+	Short       string   `xml:"short"`
+	Description string   `xml:"description"`
+	Services    []struct {
+		Name string `xml:"name,attr"`
+	} `xml:"service"`
+	Ports []struct {
+		Protocol string `xml:"protocol,attr"`
+		Port     string `xml:"port,attr"`
+	} `xml:"port"`
+	Sources []struct {
+		Address string `xml:"address,attr"`
+	} `xml:"source"`
+	Rules []fwRichXML `xml:"rule"`
+}
+
+// fwRichXML is a rich rule as saved in the file of the zone
+type fwRichXML struct {
+	Family string `xml:"family,attr,omitempty"`
+	Source *struct {
+		Address string `xml:"address,attr"`
+	} `xml:"source"`
+	Port *struct {
+		Protocol string `xml:"protocol,attr"`
+		Port     string `xml:"port,attr"`
+	} `xml:"port"`
+	Accept *struct{} `xml:"accept"`
+	Drop   *struct{} `xml:"drop"`
+	Reject *struct{} `xml:"reject"`
+}
+
+// fwRich is a rich rule, of the simple kind letting in, dropping or
+// rejecting the traffic from an address, to a port if given
+type fwRich struct {
+	family, source, port, proto, action string
+}
+
+func init() {
+	honeyos.RegisterCommand("firewall-cmd", firewallCmd{})
+	honeyos.OnFirewallLoad(func(sys honeyos.Sys, ipt map[string][]*honeyos.Chain) {
+		if !loadPkgDB(sys, pkgFamily()).installed("firewalld") {
+			return
+		}
+		name := fwDefaultZone(sys)
+		z, _ := fwLoadZone(sys, name)
+		fwApply(name, z, ipt)
+	})
+}
+
+func (firewallCmd) GetHelp() string {
+	return `Usage: firewall-cmd [OPTIONS...]
+
+General Options
+  -h, --help           Prints a short help text and exists
+  -V, --version        Print the version string of firewalld
+  -q, --quiet          Do not print status messages
+
+Status Options
+  --state              Return and print firewalld state
+  --reload             Reload firewall and keep state information
+  --complete-reload    Reload firewall and lose state information
+  --runtime-to-permanent
+                       Create permanent from runtime configuration
+
+Permanent Options
+  --permanent          Set an option permanently
+
+Zone Options
+  --get-default-zone   Print default zone for connections and interfaces
+  --set-default-zone=<zone>
+                       Set default zone
+  --get-active-zones   Print currently active zones
+  --get-zones          Print predefined zones [P]
+  --get-services       Print predefined services [P]
+  --list-all-zones     List everything added for or enabled in all zones [P]
+
+Options to Adapt and Query Zones
+  --list-all           List everything added for or enabled in a zone [P] [Z]
+  --list-services      List services added for a zone [P] [Z]
+  --add-service=<service>
+                       Add a service for a zone [P] [Z] [T]
+  --remove-service=<service>
+                       Remove a service from a zone [P] [Z]
+  --query-service=<service>
+                       Return whether service has been added for a zone [P] [Z]
+  --list-ports         List ports added for a zone [P] [Z]
+  --add-port=<portid>[-<portid>]/<protocol>
+                       Add the port for a zone [P] [Z] [T]
+  --remove-port=<portid>[-<portid>]/<protocol>
+                       Remove the port from a zone [P] [Z]
+  --query-port=<portid>[-<portid>]/<protocol>
+                       Return whether the port has been added for zone [P] [Z]
+  --list-rich-rules    List rich language rules added for a zone [P] [Z]
+  --add-rich-rule=<rule>
+                       Add rich language rule 'rule' for a zone [P] [Z] [T]
+  --remove-rich-rule=<rule>
+                       Remove rich language rule 'rule' from a zone [P] [Z]
+  --list-sources       List sources that are bound to a zone [P] [Z]
+  --add-source=<source>[/<mask>]|<MAC>|ipset:<ipset>
+                       Bind the source to a zone [P] [Z]
+  --remove-source=<source>[/<mask>]|<MAC>|ipset:<ipset>
+                       Remove the source from a zone [P] [Z]
+`
+}
+
+func (firewallCmd) Where() string {
+	return "/usr/bin/firewall-cmd"
+}
+
+// fwDefaultZone is the zone of eth0, from firewalld.conf
+func fwDefaultZone(sys honeyos.Sys) string {
+	if zone := readSettings(sys, firewalldConf)["DefaultZone"]; zone != "" {
+		return zone
+	}
+	return "public"
+}
+
+// fwLoadZone reads the permanent configuration of the zone, from /etc if
+// changed there. False if there is no such zone
+func fwLoadZone(sys honeyos.Sys, name string) (fwZone, bool) {
+	z, ok := fwZones[name]
+	for _, dir := range []string{firewalldZones, firewalldSysZones} {
+		data, err := readFile(sys, dir+name+".xml")
+		var zx fwZoneXML
+		if err != nil || len(data) == 0 || xml.Unmarshal(data, &zx) != nil {
+			continue
+		}
+		z, ok = fwZone{target: zx.Target}, true
+		if z.target == "" {
+			z.target = "default"
+		}
+		for _, s := range zx.Services {
+			z.services = append(z.services, s.Name)
+		}
+		for _, p := range zx.Ports {
+			z.ports = append(z.ports, p.Port+"/"+p.Protocol)
+		}
+		for _, s := range zx.Sources {
+			z.sources = append(z.sources, s.Address)
+		}
+		for _, r := range zx.Rules {
+			rich := fwRich{family: r.Family, action: "accept"}
+			if r.Source != nil {
+				rich.source = r.Source.Address
+			}
+			if r.Port != nil {
+				rich.port, rich.proto = r.Port.Port, r.Port.Protocol
+			}
+			if r.Drop != nil {
+				rich.action = "drop"
+			} else if r.Reject != nil {
+				rich.action = "reject"
+			}
+			z.rich = append(z.rich, rich.String())
+		}
+		break
+	}
+	return z, ok
+}
+
+// fwSaveZone writes the permanent configuration of the zone to /etc
+func fwSaveZone(sys honeyos.Sys, name string, z fwZone) error {
+	var b strings.Builder
+	b.WriteString("<?xml version=\"1.0\" encoding=\"utf-8\"?>\n<zone")
+	if z.target != "default" {
+		fmt.Fprintf(&b, " target=%q", z.target)
+	}
+	fmt.Fprintf(&b, ">\n  <short>%v</short>\n", strings.Title(name))
+	if desc := fwZoneDescriptions[name]; desc != "" {
+		fmt.Fprintf(&b, "  <description>%v</description>\n", desc)
+	}
+	for _, s := range z.services {
+		fmt.Fprintf(&b, "  <service name=%q/>\n", s)
+	}
+	for _, p := range z.ports {
+		parts := strings.SplitN(p, "/", 2)
+		fmt.Fprintf(&b, "  <port protocol=%q port=%q/>\n", parts[1], parts[0])
+	}
+	for _, s := range z.sources {
+		fmt.Fprintf(&b, "  <source address=%q/>\n", s)
+	}
+	for _, s := range z.rich {
+		r, _ := parseRich(s)
+		b.WriteString("  <rule")
+		if r.family != "" {
+			fmt.Fprintf(&b, " family=%q", r.family)
+		}
+		b.WriteString(">\n")
+		if r.source != "" {
+			fmt.Fprintf(&b, "    <source address=%q/>\n", r.source)
+		}
+		if r.port != "" {
+			fmt.Fprintf(&b, "    <port protocol=%q port=%q/>\n", r.proto, r.port)
+		}
+		fmt.Fprintf(&b, "    <%v/>\n  </rule>\n", r.action)
+	}
+	b.WriteString("</zone>\n")
+	sys.FSys().MkdirAll(firewalldZones, 0750)
+	return afero.WriteFile(sys.FSys(), firewalldZones+name+".xml", []byte(b.String()), 0644)
+}
+
+// parseRich parses the rich rule, like
+// rule family="ipv4" source address="10.0.0.1" port port="22" protocol="tcp" accept
+func parseRich(s string) (fwRich, bool) {
+	var r fwRich
+	words := strings.Fields(s)
+	if len(words) == 0 || words[0] != "rule" {
+		return r, false
+	}
+	value := func(w, key string) (string, bool) {
+		if !strings.HasPrefix(w, key+"=") {
+			return "", false
+		}
+		return strings.Trim(strings.TrimPrefix(w, key+"="), `"'`), true
+	}
+	for i := 1; i < len(words); i++ {
+		w := words[i]
+		switch {
+		case strings.HasPrefix(w, "family="):
+			r.family, _ = value(w, "family")
+		case w == "source" && i+1 < len(words):
+			i++
+			v, ok := value(words[i], "address")
+			if !ok {
+				return r, false
+			}
+			r.source = v
+		case w == "port" && i+2 < len(words):
+			port, ok1 := value(words[i+1], "port")
+			proto, ok2 := value(words[i+2], "protocol")
+			if !ok1 || !ok2 {
+				return r, false
+			}
+			r.port, r.proto = port, proto
+			i += 2
+		case w == "accept" || w == "drop" || w == "reject":
+			r.action = w
+		default:
+			return r, false
+		}
+	}
+	if r.family != "" && r.family != "ipv4" && r.family != "ipv6" || r.action == "" || r.source == "" && r.port == "" {
+		return r, false
+	}
+	if r.port != "" {
+		if _, ok := fwPort(r.port + "/" + r.proto); !ok {
+			return r, false
+		}
+	}
+	if r.source != "" {
+		if _, ok := fwFamily(r.source); !ok {
+			return r, false
+		}
+	}
+	return r, true
+}
+
+// String formats the rule the way firewall-cmd lists it
+func (r fwRich) String() string {
+	parts := []string{"rule"}
+	if r.family != "" {
+		parts = append(parts, fmt.Sprintf("family=%q", r.family))
+	}
+	if r.source != "" {
+		parts = append(parts, fmt.Sprintf("source address=%q", r.source))
+	}
+	if r.port != "" {
+		parts = append(parts, fmt.Sprintf("port port=%q protocol=%q", r.port, r.proto))
+	}
+	return strings.Join(append(parts, r.action), " ")
+}
+
+// fwPort checks the port like 8080/tcp or 6000-6010/udp, returning it for
+// iptables like 6000:6010
+func fwPort(s string) (string, bool) {
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 || !strings.Contains("tcp udp sctp dccp", parts[1]) || parts[1] == "" {
+		return "", false
+	}
+	bounds := strings.Split(parts[0], "-")
+	if len(bounds) > 2 {
+		return "", false
+	}
+	for _, b := range bounds {
+		if n, err := strconv.Atoi(b); err != nil || n < 1 || n > 65535 {
+			return "", false
+		}
+	}
+	return strings.Join(bounds, ":"), true
+}
+
+// fwFamily tells if the address is IPv6, false if it is no address
+func fwFamily(addr string) (v6, ok bool) {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		var err error
+		if ip, _, err = net.ParseCIDR(addr); err != nil {
+			return false, false
+		}
+	}
+	return ip.To4() == nil, true
+}
+
+// fwAllow is the rule letting in the new connections to the port
+func fwAllow(port, extra string) string {
+	parts := strings.SplitN(port, "/", 2)
+	p, _ := fwPort(port)
+	return fmt.Sprintf("%v-p %v -m %v --dport %v -m conntrack --ctstate NEW,UNTRACKED -j ACCEPT", extra, parts[1], parts[1], p)
+}
+
+// fwRuleset is the chains firewalld sets up in the filter table for the
+// family with the zone on eth0, and their rules
+func fwRuleset(name string, z fwZone, v6 bool) (chains, lines []string) {
+	icmp, reject := "icmp", "icmp-host-prohibited"
+	if v6 {
+		icmp, reject = "ipv6-icmp", "icmp6-adm-prohibited"
+	}
+	in, fwdi, fwdo := "IN_"+name, "FWDI_"+name, "FWDO_"+name
+	chains = []string{"INPUT_direct", "INPUT_ZONES_SOURCE", "INPUT_ZONES", "FORWARD_direct", "FORWARD_IN_ZONES_SOURCE",
+		"FORWARD_IN_ZONES", "FORWARD_OUT_ZONES_SOURCE", "FORWARD_OUT_ZONES", "OUTPUT_direct"}
+	for _, c := range []string{in, fwdi, fwdo} {
+		chains = append(chains, c, c+"_log", c+"_deny", c+"_allow")
+	}
+	add := func(chain, format string, a ...interface{}) {
+		lines = append(lines, "-A "+chain+" "+fmt.Sprintf(format, a...))
+	}
+	for _, c := range []string{"INPUT", "FORWARD"} {
+		add(c, "-m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT")
+		add(c, "-i lo -j ACCEPT")
+		add(c, "-j %v_direct", c)
+		if c == "INPUT" {
+			add(c, "-j INPUT_ZONES_SOURCE")
+			add(c, "-j INPUT_ZONES")
+		} else {
+			for _, dir := range []string{"IN", "OUT"} {
+				add(c, "-j FORWARD_%v_ZONES_SOURCE", dir)
+				add(c, "-j FORWARD_%v_ZONES", dir)
+			}
+		}
+		add(c, "-m conntrack --ctstate INVALID -j DROP")
+		add(c, "-j REJECT --reject-with %v", reject)
+	}
+	add("OUTPUT", "-o lo -j ACCEPT")
+	add("OUTPUT", "-j OUTPUT_direct")
+	for _, src := range z.sources {
+		if isV6, _ := fwFamily(src); isV6 == v6 {
+			add("INPUT_ZONES_SOURCE", "-s %v -g %v", src, in)
+			add("FORWARD_IN_ZONES_SOURCE", "-s %v -g %v", src, fwdi)
+			add("FORWARD_OUT_ZONES_SOURCE", "-d %v -g %v", src, fwdo)
+		}
+	}
+	add("INPUT_ZONES", "-i eth0 -g %v", in)
+	add("INPUT_ZONES", "-g %v", in)
+	add("FORWARD_IN_ZONES", "-i eth0 -g %v", fwdi)
+	add("FORWARD_IN_ZONES", "-g %v", fwdi)
+	add("FORWARD_OUT_ZONES", "-o eth0 -g %v", fwdo)
+	add("FORWARD_OUT_ZONES", "-g %v", fwdo)
+	for _, c := range []string{in, fwdi, fwdo} {
+		for _, sub := range []string{"_log", "_deny", "_allow"} {
+			add(c, "-j %v%v", c, sub)
+		}
+	}
+	add(in, "-p %v -j ACCEPT", icmp)
+	add(fwdi, "-p %v -j ACCEPT", icmp)
+	switch z.target {
+	case "ACCEPT", "DROP":
+		for _, c := range []string{in, fwdi, fwdo} {
+			add(c, "-j %v", z.target)
+		}
+	case "%%REJECT%%":
+		for _, c := range []string{in, fwdi, fwdo} {
+			add(c, "-j REJECT --reject-with %v", reject)
+		}
+	}
+
+	for _, s := range z.services {
+		svc := fwServices[s]
+		if svc.dest != "" && !v6 {
+			continue
+		}
+		for _, port := range svc.ports {
+			extra := ""
+			if svc.dest != "" {
+				extra = "-d " + svc.dest + " "
+			}
+			add(in+"_allow", "%v", fwAllow(port, extra))
+		}
+	}
+	for _, port := range z.ports {
+		add(in+"_allow", "%v", fwAllow(port, ""))
+	}
+	for _, s := range z.rich {
+		r, _ := parseRich(s)
+		if r.family == "ipv6" != v6 && r.family != "" {
+			continue
+		}
+		if r.source != "" {
+			if isV6, _ := fwFamily(r.source); isV6 != v6 {
+				continue
+			}
+		}
+		extra := ""
+		if r.source != "" {
+			extra = "-s " + r.source + " "
+		}
+		switch {
+		case r.action == "accept" && r.port != "":
+			add(in+"_allow", "%v", fwAllow(r.port+"/"+r.proto, extra))
+		case r.action == "accept":
+			add(in+"_allow", "%v-m conntrack --ctstate NEW,UNTRACKED -j ACCEPT", extra)
+		default:
+			target := "DROP"
+			if r.action == "reject" {
+				target = "REJECT --reject-with " + reject
+			}
+			if r.port != "" {
+				p, _ := fwPort(r.port + "/" + r.proto)
+				extra += fmt.Sprintf("-p %v -m %v --dport %v ", r.proto, r.proto, p)
+			}
+			add(in+"_deny", "%v-j %v", extra, target)
+		}
+	}
+	return chains, lines
+}
+
+// fwApply sets up the filter tables with the zone on eth0, flushing them
+// first as firewalld does
+func fwApply(name string, z fwZone, ipt map[string][]*honeyos.Chain) {
+	for _, v6 := range []bool{false, true} {
+		key := "filter"
+		if v6 {
+			key = "ip6 filter"
+		}
+		var chains []*honeyos.Chain
+		for _, c := range ipt[key] {
+			if c.Policy != "" {
+				chains = append(chains, &honeyos.Chain{Name: c.Name, Policy: "ACCEPT"})
+			}
+		}
+		names, lines := fwRuleset(name, z, v6)
+		for _, n := range names {
+			chains = append(chains, &honeyos.Chain{Name: n})
+		}
+		t := iptables{v6}
+		for _, line := range lines {
+			args := iptSplit(line)
+			c := iptChain(chains, args[1])
+			if rule, err := t.rule(args[2:], chains); err == nil && c != nil {
+				c.Rules = append(c.Rules, rule)
+			}
+		}
+		ipt[key] = chains
+	}
+}
+
+// fwRuntime reads the zone on eth0 back from the filter tables, as changed
+// since by firewall-cmd or iptables. Ports of the services the zone has in
+// the permanent configuration, or of the other services unless added as
+// ports, are taken as the services. False if firewalld is not running
+func fwRuntime(sys honeyos.Sys) (name string, z fwZone, running bool) {
+	honeyos.UpdateFirewall(sys, func(ipt map[string][]*honeyos.Chain, _ *[]*honeyos.NftTable) {
+		zones := iptChain(ipt["filter"], "INPUT_ZONES")
+		if zones == nil {
+			return
+		}
+		for _, r := range zones.Rules {
+			args := iptSplit(r)
+			if len(args) >= 2 && args[len(args)-2] == "-g" {
+				name = strings.TrimPrefix(args[len(args)-1], "IN_")
+			}
+		}
+		if name == "" {
+			return
+		}
+		running = true
+		z.target = "default"
+		perm, _ := fwLoadZone(sys, name)
+		// open counts the rules opening the port, each service and port
+		// added having its own
+		open := map[string]int{}
+		var order []string
+		for _, key := range []string{"filter", "ip6 filter"} {
+			if c := iptChain(ipt[key], "IN_"+name); c != nil && len(c.Rules) > 0 {
+				last := iptSplit(c.Rules[len(c.Rules)-1])
+				switch target := last[len(last)-1]; {
+				case len(last) == 2 && (target == "ACCEPT" || target == "DROP"):
+					z.target = target
+				case len(last) >= 2 && last[0] == "-j" && last[1] == "REJECT":
+					z.target = "%%REJECT%%"
+				}
+			}
+			if c := iptChain(ipt[key], "INPUT_ZONES_SOURCE"); c != nil {
+				for _, r := range c.Rules {
+					args := iptSplit(r)
+					if len(args) >= 4 && args[0] == "-s" && args[len(args)-1] == "IN_"+name {
+						z.sources = append(z.sources, strings.TrimSuffix(strings.TrimSuffix(args[1], "/32"), "/128"))
+					}
+				}
+			}
+			for _, sub := range []string{"_allow", "_deny"} {
+				c := iptChain(ipt[key], "IN_"+name+sub)
+				if c == nil {
+					continue
+				}
+				for _, r := range c.Rules {
+					if rich, port, ok := fwParseRule(r, key == "ip6 filter"); ok && rich.action != "" {
+						z.rich = append(z.rich, rich.String())
+					} else if ok && (key == "filter" || strings.Contains(port, " ")) {
+						if open[port] == 0 {
+							order = append(order, port)
+						}
+						open[port]++
+					}
+				}
+			}
+		}
+		// The ports added permanently are taken first, then the services
+		// of the zone and the others whose ports are all open
+		for _, p := range perm.ports {
+			if open[p] > 0 {
+				open[p]--
+				z.ports = append(z.ports, p)
+			}
+		}
+		var others []string
+		for s := range fwServices {
+			if !fwHas(perm.services, s) {
+				others = append(others, s)
+			}
+		}
+		sort.Strings(others)
+		for _, s := range append(append([]string{}, perm.services...), others...) {
+			svc, all := fwServices[s], true
+			for _, p := range svc.ports {
+				all = all && open[strings.TrimSpace(svc.dest+" "+p)] > 0
+			}
+			if !all {
+				continue
+			}
+			z.services = append(z.services, s)
+			for _, p := range svc.ports {
+				open[strings.TrimSpace(svc.dest+" "+p)]--
+			}
+		}
+		for _, p := range order {
+			if open[p] > 0 && !strings.Contains(p, " ") {
+				z.ports = append(z.ports, p)
+			}
+		}
+		// Rich rules are listed as given if added permanently
+		for i, r := range z.rich {
+			for _, given := range perm.rich {
+				pr, _ := parseRich(given)
+				if pr.family == "" && strings.Replace(r, ` family="ipv4"`, "", 1) == pr.String() ||
+					pr.family == "" && strings.Replace(r, ` family="ipv6"`, "", 1) == pr.String() {
+					z.rich[i] = given
+				}
+			}
+		}
+		z.rich = fwUnique(z.rich)
+		z.sources = fwUnique(z.sources)
+	})
+	return name, z, running
+}
+
+// fwParseRule reads the rule of the allow or deny chain of the zone. Rules
+// from an address are rich rules, the others open the port, keyed with the
+// address they are limited to like fe80::/64 546/udp
+func fwParseRule(r string, v6 bool) (rich fwRich, port string, ok bool) {
+	args := iptSplit(r)
+	var src, dst, proto, dport, target string
+	for i := 0; i+1 < len(args); i++ {
+		switch args[i] {
+		case "-s":
+			src = strings.TrimSuffix(strings.TrimSuffix(args[i+1], "/32"), "/128")
+		case "-d":
+			dst = args[i+1]
+		case "-p":
+			proto = args[i+1]
+		case "--dport":
+			dport = strings.Replace(args[i+1], ":", "-", 1)
+		case "-j":
+			target = args[i+1]
+		}
+	}
+	if src == "" {
+		if target != "ACCEPT" || dport == "" {
+			return rich, "", false
+		}
+		port = dport + "/" + proto
+		if dst != "" {
+			port = dst + " " + port
+		}
+		return rich, port, true
+	}
+	rich = fwRich{family: "ipv4", source: src, action: strings.ToLower(target)}
+	if v6 {
+		rich.family = "ipv6"
+	}
+	if dport != "" {
+		rich.port, rich.proto = dport, proto
+	}
+	if rich.action != "accept" && rich.action != "drop" && rich.action != "reject" {
+		return rich, "", false
+	}
+	return rich, "", true
+}
+
+// fwHas tells if the list has the item
+func fwHas(list []string, item string) bool {
+	for _, s := range list {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
+
+// fwUnique drops the items listed again
+func fwUnique(list []string) []string {
+	var out []string
+	for _, s := range list {
+		if !fwHas(out, s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// fwListAll formats the zone like --list-all
+func fwListAll(name string, z fwZone, active bool) string {
+	var b strings.Builder
+	services := append([]string{}, z.services...)
+	sort.Strings(services)
+	interfaces := ""
+	if active {
+		name += " (active)"
+		interfaces = "eth0"
+	}
+	fmt.Fprintf(&b, "%v\n  target: %v\n  icmp-block-inversion: no\n  interfaces: %v\n  sources: %v\n  services: %v\n",
+		name, z.target, interfaces, strings.Join(z.sources, " "), strings.Join(services, " "))
+	fmt.Fprintf(&b, "  ports: %v\n  protocols: \n  masquerade: no\n  forward-ports: \n  source-ports: \n  icmp-blocks: \n  rich rules: \n",
+		strings.Join(z.ports, " "))
+	for _, r := range z.rich {
+		fmt.Fprintf(&b, "\t%v\n", r)
+	}
+	return b.String()
+}
+
+func (f firewallCmd) Exec(args []string, sys honeyos.Sys) int {
+	if !loadPkgDB(sys, pkgFamily()).installed("firewalld") {
+		return honeyos.CommandNotFound(sys, append([]string{"firewall-cmd"}, args...))
+	}
+	type action struct{ name, value string }
+	var actions []action
+	permanent, quiet := false, false
+	zone := ""
+	// Options taking a value, given as --opt=value or --opt value
+	valued := map[string]bool{"--zone": true, "--add-port": true, "--remove-port": true, "--query-port": true,
+		"--add-service": true, "--remove-service": true, "--query-service": true, "--add-source": true,
+		"--remove-source": true, "--add-rich-rule": true, "--remove-rich-rule": true, "--set-default-zone": true,
+		"--timeout": true}
+	for i := 0; i < len(args); i++ {
+		name, value := args[i], ""
+		if j := strings.IndexByte(name, '='); j > 0 {
+			name, value = name[:j], name[j+1:]
+		} else if valued[name] {
+			if i+1 >= len(args) {
+				fmt.Fprintf(sys.Err(), "usage: see firewall-cmd man page\nfirewall-cmd: error: argument %v: expected one argument\n", name)
+				return 2
+			}
+			i++
+			value = args[i]
+		}
+		switch name {
+		case "-h", "--help":
+			fmt.Fprint(sys.Out(), f.GetHelp())
+			return 0
+		case "-V", "--version":
+			fmt.Fprintln(sys.Out(), "0.6.3")
+			return 0
+		case "--permanent":
+			permanent = true
+		case "-q", "--quiet":
+			quiet = true
+		case "--zone":
+			zone = value
+		case "--timeout":
+		default:
+			if !strings.HasPrefix(name, "--") || !valued[name] && value != "" {
+				fmt.Fprintf(sys.Err(), "usage: see firewall-cmd man page\nfirewall-cmd: error: unrecognized arguments: %v\n", args[i])
+				return 2
+			}
+			actions = append(actions, action{name, value})
+		}
+	}
+	if len(actions) == 0 {
+		fmt.Fprintln(sys.Err(), "usage: see firewall-cmd man page\nNo option specified.")
+		return 2
+	}
+
+	runtimeZone, runtime, running := fwRuntime(sys)
+	if actions[0].name == "--state" {
+		if !running {
+			fmt.Fprintln(sys.Err(), "not running")
+			return 252
+		}
+		fmt.Fprintln(sys.Out(), "running")
+		return 0
+	}
+	if !running && !permanent {
+		fmt.Fprintln(sys.Err(), "FirewallD is not running")
+		return 252
+	}
+	def := fwDefaultZone(sys)
+	if running {
+		def = runtimeZone
+	}
+	if zone == "" {
+		zone = def
+	}
+	if _, ok := fwLoadZone(sys, zone); !ok {
+		fmt.Fprintf(sys.Err(), "Error: INVALID_ZONE: %v\n", zone)
+		return 112
+	}
+	z, _ := fwLoadZone(sys, zone)
+	if !permanent {
+		if zone != runtimeZone {
+			// Only the zone of eth0 is set up in the tables
+			z.sources = nil
+		} else {
+			z = runtime
+		}
+	}
+
+	logger := sys.Log().WithField("args", args)
+	changed := false
+	out := func(s string) {
+		if !quiet {
+			fmt.Fprintln(sys.Out(), s)
+		}
+	}
+	for _, a := range actions {
+		change := strings.HasPrefix(a.name, "--add-") || strings.HasPrefix(a.name, "--remove-") ||
+			strings.HasPrefix(a.name, "--set-") || a.name == "--reload" || a.name == "--complete-reload" ||
+			a.name == "--runtime-to-permanent"
+		if change && !isRoot(sys) {
+			fmt.Fprintln(sys.Err(), "Authorization failed.\n    Make sure polkit agent is running or run the application as superuser.")
+			return 253
+		}
+		// list is the item of the zone the action is about, and item how
+		// firewalld names the value in its messages
+		var list *[]string
+		item, value := a.value, a.value
+		switch strings.TrimPrefix(strings.TrimPrefix(strings.TrimPrefix(a.name, "--add-"), "--remove-"), "--query-") {
+		case "port":
+			if _, ok := fwPort(value); !ok {
+				fmt.Fprintf(sys.Err(), "Error: INVALID_PORT: %v\n", strings.SplitN(value, "/", 2)[0])
+				return 102
+			}
+			list, item = &z.ports, strings.Replace(value, "/", ":", 1)
+		case "service":
+			if _, ok := fwServices[value]; !ok {
+				fmt.Fprintf(sys.Err(), "Error: INVALID_SERVICE: '%v' not among existing services\n", value)
+				return 101
+			}
+			list = &z.services
+		case "source":
+			if _, ok := fwFamily(value); !ok {
+				fmt.Fprintf(sys.Err(), "Error: INVALID_ADDR: %v\n", value)
+				return 105
+			}
+			list = &z.sources
+		case "rich-rule":
+			r, ok := parseRich(value)
+			if !ok {
+				fmt.Fprintf(sys.Err(), "Error: INVALID_RULE: %v\n", value)
+				return 122
+			}
+			value = r.String()
+			list, item = &z.rich, "rule '"+value+"'"
+		}
+
+		switch {
+		case strings.HasPrefix(a.name, "--add-") && list != nil:
+			if fwHas(*list, value) {
+				fmt.Fprintf(sys.Err(), "Warning: ALREADY_ENABLED: %v\n", item)
+				continue
+			}
+			*list, changed = append(*list, value), true
+			if a.name == "--add-source" || strings.Contains(value, "drop") || strings.Contains(value, "reject") {
+				logger.WithField("zone", zone).Infof("User changed firewall rules with firewall-cmd")
+			} else {
+				logger.WithField("zone", zone).Warnf("User opened port with firewall-cmd")
+			}
+		case strings.HasPrefix(a.name, "--remove-") && list != nil:
+			if !fwHas(*list, value) {
+				fmt.Fprintf(sys.Err(), "Warning: NOT_ENABLED: %v\n", item)
+				continue
+			}
+			var kept []string
+			for _, s := range *list {
+				if s != value {
+					kept = append(kept, s)
+				}
+			}
+			*list, changed = kept, true
+			logger.WithField("zone", zone).Infof("User changed firewall rules with firewall-cmd")
+		case strings.HasPrefix(a.name, "--query-") && list != nil:
+			if !fwHas(*list, value) {
+				out("no")
+				return 1
+			}
+			out("yes")
+			return 0
+		case a.name == "--list-all":
+			fmt.Fprint(sys.Out(), fwListAll(zone, z, !permanent && zone == runtimeZone))
+		case a.name == "--list-all-zones":
+			var names []string
+			for n := range fwZones {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			for i, n := range names {
+				nz, _ := fwLoadZone(sys, n)
+				if n == zone {
+					nz = z
+				} else if !permanent && n == runtimeZone {
+					nz = runtime
+				}
+				if i > 0 {
+					fmt.Fprintln(sys.Out())
+				}
+				fmt.Fprint(sys.Out(), fwListAll(n, nz, !permanent && n == runtimeZone))
+			}
+		case a.name == "--list-ports":
+			fmt.Fprintln(sys.Out(), strings.Join(z.ports, " "))
+		case a.name == "--list-services":
+			services := append([]string{}, z.services...)
+			sort.Strings(services)
+			fmt.Fprintln(sys.Out(), strings.Join(services, " "))
+		case a.name == "--list-sources":
+			fmt.Fprintln(sys.Out(), strings.Join(z.sources, " "))
+		case a.name == "--list-rich-rules":
+			for _, r := range z.rich {
+				fmt.Fprintln(sys.Out(), r)
+			}
+		case a.name == "--get-default-zone":
+			fmt.Fprintln(sys.Out(), def)
+		case a.name == "--get-active-zones":
+			if !running {
+				continue
+			}
+			fmt.Fprintf(sys.Out(), "%v\n  interfaces: eth0\n", runtimeZone)
+			if len(runtime.sources) > 0 {
+				fmt.Fprintf(sys.Out(), "  sources: %v\n", strings.Join(runtime.sources, " "))
+			}
+		case a.name == "--get-zones":
+			var names []string
+			for n := range fwZones {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			fmt.Fprintln(sys.Out(), strings.Join(names, " "))
+		case a.name == "--get-services":
+			var names []string
+			for n := range fwServices {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			fmt.Fprintln(sys.Out(), strings.Join(names, " "))
+		case a.name == "--set-default-zone":
+			nz, ok := fwLoadZone(sys, a.value)
+			if !ok {
+				fmt.Fprintf(sys.Err(), "Error: INVALID_ZONE: %v\n", a.value)
+				return 112
+			}
+			if a.value == def {
+				fmt.Fprintf(sys.Err(), "Warning: ZONE_ALREADY_SET: %v\n", a.value)
+				continue
+			}
+			writeSetting(sys, firewalldConf, "DefaultZone=public\n", "DefaultZone", a.value)
+			zone, z, def, changed = a.value, nz, a.value, true
+			permanent = false
+			if nz.target == "ACCEPT" {
+				logger.WithField("zone", a.value).Warnf("User let in all traffic with firewall-cmd")
+			} else {
+				logger.WithField("zone", a.value).Infof("User changed the default zone with firewall-cmd")
+			}
+		case a.name == "--reload" || a.name == "--complete-reload":
+			if !running {
+				fmt.Fprintln(sys.Err(), "FirewallD is not running")
+				return 252
+			}
+			zone = fwDefaultZone(sys)
+			z, _ = fwLoadZone(sys, zone)
+			permanent, changed = false, true
+		case a.name == "--runtime-to-permanent":
+			if err := fwSaveZone(sys, runtimeZone, runtime); err != nil {
+				fmt.Fprintf(sys.Err(), "Error: %v\n", err)
+				return 254
+			}
+			out("success")
+		default:
+			fmt.Fprintf(sys.Err(), "usage: see firewall-cmd man page\nfirewall-cmd: error: unrecognized arguments: %v\n", a.name)
+			return 2
+		}
+	}
+	if !changed {
+		if len(actions) > 0 && (strings.HasPrefix(actions[0].name, "--add-") || strings.HasPrefix(actions[0].name, "--remove-")) {
+			out("success")
+		}
+		return 0
+	}
+	switch {
+	case permanent:
+		if err := fwSaveZone(sys, zone, z); err != nil {
+			fmt.Fprintf(sys.Err(), "Error: %v\n", err)
+			return 254
+		}
+	case zone == def:
+		honeyos.UpdateFirewall(sys, func(ipt map[string][]*honeyos.Chain, _ *[]*honeyos.NftTable) {
+			fwApply(zone, z, ipt)
+		})
+	}
+	out("success")
+	return 0
+}
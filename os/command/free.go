@@ -0,0 +1,173 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/pflag"
+)
+
+// free shows the memory usage of the virtual machine, the same as top does
+type free struct{}
+
+func init() {
+	honeyos.RegisterCommand("free", free{})
+}
+
+func (free) GetHelp() string {
+	return "\nUsage:\n free [options]\n\nOptions:\n" +
+		" -b, --bytes         show output in bytes\n" +
+		" -k, --kilo          show output in kilobytes\n" +
+		" -m, --mega          show output in megabytes\n" +
+		" -g, --giga          show output in gigabytes\n" +
+		"     --tera          show output in terabytes\n" +
+		"     --peta          show output in petabytes\n" +
+		" -h, --human         show human-readable output\n" +
+		"     --si            use powers of 1000 not 1024\n" +
+		" -l, --lohi          show detailed low and high memory statistics\n" +
+		" -t, --total         show total for RAM + swap\n" +
+		" -s N, --seconds N   repeat printing every N seconds\n" +
+		" -c N, --count N     repeat printing N times, then exit\n" +
+		" -w, --wide          wide output\n\n" +
+		"     --help     display this help and exit\n" +
+		" -V, --version  output version information and exit\n\n" +
+		"For more details see free(1).\n"
+}
+
+func (free) Where() string {
+	return "/usr/bin/free"
+}
+
+// freeHuman scales the size in kB like procps does, e.g. 1.9G or 276M
+func freeHuman(kb int, base float64) string {
+	v := float64(kb) * 1024
+	units := "BKMGTP"
+	i := 0
+	for v >= base && i < len(units)-1 {
+		v /= base
+		i++
+	}
+	switch {
+	case i == 0:
+		return fmt.Sprintf("%.0fB", v)
+	case v < 10:
+		return fmt.Sprintf("%.1f%c", v, units[i])
+	}
+	return fmt.Sprintf("%.0f%c", v, units[i])
+}
+
+func (f free) Exec(args []string, sys honeyos.Sys) int {
+	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	bytes := flag.BoolP("bytes", "b", false, "show output in bytes")
+	flag.BoolP("kilo", "k", false, "show output in kilobytes")
+	mega := flag.BoolP("mega", "m", false, "show output in megabytes")
+	giga := flag.BoolP("giga", "g", false, "show output in gigabytes")
+	tera := flag.Bool("tera", false, "show output in terabytes")
+	peta := flag.Bool("peta", false, "show output in petabytes")
+	human := flag.BoolP("human", "h", false, "show human-readable output")
+	si := flag.Bool("si", false, "use powers of 1000 not 1024")
+	lohi := flag.BoolP("lohi", "l", false, "show detailed low and high memory statistics")
+	total := flag.BoolP("total", "t", false, "show total for RAM + swap")
+	seconds := flag.StringP("seconds", "s", "", "repeat printing every N seconds")
+	count := flag.IntP("count", "c", 0, "repeat printing N times, then exit")
+	wide := flag.BoolP("wide", "w", false, "wide output")
+	help := flag.Bool("help", false, "display this help and exit")
+	version := flag.BoolP("version", "V", false, "output version information and exit")
+	if err := flag.Parse(args); err != nil {
+		msg := strings.Replace(err.Error(), "unknown shorthand flag: ", "invalid option -- ", 1)
+		if i := strings.Index(msg, " in -"); i >= 0 {
+			msg = msg[:i]
+		}
+		fmt.Fprintf(sys.Err(), "free: %v\n%v", msg, f.GetHelp())
+		return 1
+	}
+	switch {
+	case *help:
+		fmt.Fprint(sys.Out(), f.GetHelp())
+		return 0
+	case *version:
+		fmt.Fprintln(sys.Out(), "free from procps-ng 3.3.10")
+		return 0
+	}
+	interval := time.Duration(0)
+	if flag.Changed("seconds") {
+		d, err := parseSleepDuration(*seconds)
+		if err != nil || d <= 0 {
+			fmt.Fprintf(sys.Err(), "free: seconds argument `%v' failed\n", *seconds)
+			return 1
+		}
+		interval = d
+	}
+	if flag.Changed("count") {
+		if *count < 1 {
+			fmt.Fprintf(sys.Err(), "free: failed to parse count argument: '%v'\n", *count)
+			return 1
+		}
+		if interval == 0 {
+			interval = time.Second
+		}
+	}
+	base := 1024.0
+	if *si {
+		base = 1000
+	}
+	// Sizes are in kB unless scaled
+	num := func(kb int) string {
+		v := float64(kb) * 1024 / base
+		switch {
+		case *human:
+			return freeHuman(kb, base)
+		case *bytes:
+			return fmt.Sprint(kb * 1024)
+		case *mega:
+			v /= base
+		case *giga:
+			v /= base * base
+		case *tera:
+			v /= base * base * base
+		case *peta:
+			v /= base * base * base * base
+		}
+		return fmt.Sprint(int64(v))
+	}
+	for i := 0; ; i++ {
+		m := readMemInfo(sys)
+		var b strings.Builder
+		row := func(label string, values ...int) {
+			fmt.Fprintf(&b, "%-7v", label)
+			for _, v := range values {
+				fmt.Fprintf(&b, " %11v", num(v))
+			}
+			b.WriteString("\n")
+		}
+		used := m.total - m.free - m.buffers - m.cached
+		if *wide {
+			b.WriteString("              total        used        free      shared     buffers       cache   available\n")
+			row("Mem:", m.total, used, m.free, m.shared, m.buffers, m.cached, m.available)
+		} else {
+			b.WriteString("              total        used        free      shared  buff/cache   available\n")
+			row("Mem:", m.total, used, m.free, m.shared, m.buffers+m.cached, m.available)
+		}
+		if *lohi {
+			row("Low:", m.total, used+m.buffers+m.cached, m.free)
+			row("High:", 0, 0, 0)
+		}
+		swapUsed := m.swapTotal - m.swapFree
+		row("Swap:", m.swapTotal, swapUsed, m.swapFree)
+		if *total {
+			row("Total:", m.total+m.swapTotal, used+swapUsed, m.free+m.swapFree)
+		}
+		fmt.Fprint(sys.Out(), b.String())
+		if interval == 0 || *count > 0 && i+1 >= *count {
+			return 0
+		}
+		fmt.Fprintln(sys.Out())
+		if !pkgSleep(sys, interval) {
+			return 130
+		}
+	}
+}
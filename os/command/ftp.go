@@ -0,0 +1,547 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	pathlib "path"
+	"sort"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/mkishere/sshsyrup/virtualfs"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+// ftp is the classic ftp client. The hosts ssh lands on take the logins they
+// take for ssh, serving the files of the machine. Servers on the Internet,
+// reachable if the honeypot is online, take any login as they are likely
+// the ones of the attacker. Nothing leaves the honeypot: files put there are
+// kept in memory for the session and captured as artifacts
+type ftp struct{}
+
+// ftpConn is the state of the client, and of the server it is connected to
+type ftpConn struct {
+	sys                     honeyos.Sys
+	host, user, cwd         string
+	ip                      net.IP
+	port                    int
+	landing, loggedIn       bool
+	verbose, prompt, passve bool
+	ascii                   bool
+	// remote is the file system of the server, the one of the machine for the
+	// hosts ssh lands on
+	remote afero.Fs
+	tty    io.Reader
+	logger *log.Entry
+}
+
+func init() {
+	honeyos.RegisterCommand("ftp", ftp{})
+}
+
+func (ftp) GetHelp() string {
+	return "usage: ftp [-46pinegvtd] [hostname [port]]\n"
+}
+
+func (ftp) Where() string {
+	return "/usr/bin/ftp"
+}
+
+func (f ftp) Exec(args []string, sys honeyos.Sys) int {
+	if pkgFamily() != "deb" && !loadPkgDB(sys, pkgFamily()).installed("ftp") {
+		return honeyos.CommandNotFound(sys, append([]string{"ftp"}, args...))
+	}
+	interactive := honeyos.IsTerminal(sys.In())
+	c := &ftpConn{sys: sys, verbose: interactive, prompt: interactive}
+	autoLogin := true
+	var rest []string
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") || len(arg) == 1 {
+			rest = append(rest, arg)
+			continue
+		}
+		for _, ch := range arg[1:] {
+			switch ch {
+			case 'v':
+				c.verbose = true
+			case 'i':
+				c.prompt = false
+			case 'n':
+				autoLogin = false
+			case 'p':
+				c.passve = true
+			case '4', '6', 'e', 'g', 't', 'd':
+			default:
+				fmt.Fprintf(sys.Err(), "ftp: invalid option -- '%c'\n", ch)
+				fmt.Fprint(sys.Err(), f.GetHelp())
+				return 1
+			}
+		}
+	}
+	if c.tty = honeyos.OpenTTY(sys); c.tty == nil || !interactive {
+		c.tty = sys.In()
+	}
+	if len(rest) > 0 && c.open(rest) && autoLogin {
+		c.login("", "")
+	}
+	return c.repl()
+}
+
+// reply prints the reply of the server, shown only in verbose mode
+func (c *ftpConn) reply(format string, a ...interface{}) {
+	if c.verbose {
+		fmt.Fprintf(c.sys.Out(), format+"\n", a...)
+	}
+}
+
+// open connects to the host, as given on the command line or to open
+func (c *ftpConn) open(args []string) bool {
+	sys := c.sys
+	if c.host != "" {
+		fmt.Fprintf(sys.Out(), "Already connected to %v, use close first.\n", c.host)
+		return false
+	}
+	host, port := args[0], 21
+	if len(args) > 1 {
+		var ok bool
+		if port, ok = telnetPort(args[1]); !ok {
+			fmt.Fprintf(sys.Out(), "%v: bad port number-- %v\n", args[1], args[1])
+			fmt.Fprintln(sys.Out(), "usage: open host-name [port]")
+			return false
+		}
+	}
+	ip := netResolve(sys, host)
+	if ip == nil {
+		fmt.Fprintf(sys.Err(), "ftp: %v: Name or service not known\n", host)
+		return false
+	}
+	c.logger = sys.Log().WithFields(log.Fields{"ftpHost": host, "ftpPort": port})
+	c.logger.Infof("User connecting to %v port %v with ftp", host, port)
+	open, reachable := netPortOpen(sys, ip, port)
+	switch {
+	case !reachable:
+		if !pkgSleep(sys, 127*time.Second) {
+			return false
+		}
+		fmt.Fprintln(sys.Err(), "ftp: connect: Connection timed out")
+		return false
+	case !open:
+		fmt.Fprintln(sys.Err(), "ftp: connect: Connection refused")
+		return false
+	}
+	c.host, c.ip, c.port = host, ip, port
+	_, c.landing = sshLookup(sys, ip.String())
+	fmt.Fprintf(sys.Out(), "Connected to %v.\n", host)
+	fmt.Fprintln(sys.Out(), "220 (vsFTPd 3.0.3)")
+	return true
+}
+
+// login logs in to the server, asking for the name and password not given
+func (c *ftpConn) login(user, pass string) {
+	sys := c.sys
+	if user == "" {
+		me := honeyos.GetUserByID(sys.CurrentUser()).Name
+		fmt.Fprintf(sys.Out(), "Name (%v:%v): ", c.host, me)
+		line, ok := readLine(c.tty)
+		if !ok {
+			fmt.Fprintln(sys.Out())
+			return
+		}
+		if user = strings.TrimSpace(line); user == "" {
+			user = me
+		}
+	}
+	c.reply("331 Please specify the password.")
+	if pass == "" {
+		var ok bool
+		if pass, ok = readPasswordFrom(sys, c.tty, "Password:"); !ok {
+			return
+		}
+	}
+	target, _ := sshLookup(sys, c.ip.String())
+	anonymous := user == "anonymous" || user == "ftp"
+	accepted := !c.landing || !anonymous && sshAccepts(target, user, pass)
+	c.logger.WithFields(log.Fields{"ftpUser": user, "password": pass, "accepted": accepted}).
+		Infof("User tried ftp password for %v@%v", user, c.host)
+	if !accepted {
+		fmt.Fprintln(sys.Out(), "530 Login incorrect.\nLogin failed.")
+		return
+	}
+	c.user, c.loggedIn, c.cwd = user, true, "/"
+	if c.landing {
+		c.remote, c.cwd = sys.FSys(), honeyos.GetUser(user).Homedir
+	} else {
+		c.remote = afero.NewMemMapFs()
+	}
+	c.reply("230 Login successful.")
+	fmt.Fprintln(sys.Out(), "Remote system type is UNIX.\nUsing binary mode to transfer files.")
+}
+
+// repl reads the commands of ftp, from the terminal or the script piped in
+func (c *ftpConn) repl() int {
+	sys := c.sys
+	for {
+		if honeyos.IsTerminal(sys.In()) {
+			fmt.Fprint(sys.Out(), "ftp> ")
+		}
+		line, ok := readLine(sys.In())
+		if !ok {
+			if c.host != "" {
+				c.reply("221 Goodbye.")
+			}
+			return 0
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		sys.Log().WithField("ftpHost", c.host).Infof("User typed %v into ftp", strings.TrimSpace(line))
+		if !c.command(strings.ToLower(fields[0]), fields[1:]) {
+			return 0
+		}
+	}
+}
+
+// remotePath resolves the path on the server
+func (c *ftpConn) remotePath(p string) string {
+	if !pathlib.IsAbs(p) {
+		p = pathlib.Join(c.cwd, p)
+	}
+	return pathlib.Clean(p)
+}
+
+// transfer prints how long the transfer took, which is always quick
+func (c *ftpConn) transfer(n int64, verb string) {
+	secs := 0.0001 + float64(n)/50e6
+	fmt.Fprintf(c.sys.Out(), "%v bytes %v in %.2g secs (%.4f MB/s)\n", n, verb, secs, float64(n)/secs/1e6)
+}
+
+// dataConn prints the replies for opening the data connection
+func (c *ftpConn) dataConn() {
+	if c.passve {
+		ip := strings.Replace(c.ip.String(), ".", ",", -1)
+		port := 30000 + int(fnvString(time.Now().String())%20000)
+		c.reply("227 Entering Passive Mode (%v,%v,%v).", ip, port/256, port%256)
+	} else {
+		c.reply("200 PORT command successful. Consider using PASV.")
+	}
+}
+
+// command runs the command of ftp, returning false to quit
+func (c *ftpConn) command(cmd string, args []string) bool {
+	sys := c.sys
+	out := sys.Out()
+	// Commands not talking to the server
+	switch cmd {
+	case "bye", "quit", "exit":
+		if c.host != "" {
+			c.reply("221 Goodbye.")
+		}
+		return false
+	case "open", "o":
+		if len(args) == 0 {
+			fmt.Fprint(out, "(to) ")
+			line, _ := readLine(c.tty)
+			if args = strings.Fields(line); len(args) == 0 {
+				fmt.Fprintln(out, "usage: open host-name [port]")
+				return true
+			}
+		}
+		if c.open(args) {
+			c.login("", "")
+		}
+		return true
+	case "lcd":
+		dir := honeyos.GetUserByID(sys.CurrentUser()).Homedir
+		if len(args) > 0 {
+			dir = absPath(sys, args[0])
+		}
+		if fi, err := sys.FSys().Stat(dir); err != nil || !fi.IsDir() {
+			fmt.Fprintf(out, "local: %v: No such file or directory\n", args[0])
+			return true
+		}
+		sys.Chdir(dir)
+		fmt.Fprintf(out, "Local directory now %v\n", dir)
+		return true
+	case "prompt":
+		c.prompt = !c.prompt
+		fmt.Fprintf(out, "Interactive mode %v.\n", map[bool]string{true: "on", false: "off"}[c.prompt])
+		return true
+	case "verbose":
+		c.verbose = !c.verbose
+		fmt.Fprintf(out, "Verbose mode %v.\n", map[bool]string{true: "on", false: "off"}[c.verbose])
+		return true
+	case "passive":
+		c.passve = !c.passve
+		fmt.Fprintf(out, "Passive mode %v.\n", map[bool]string{true: "on", false: "off"}[c.passve])
+		return true
+	case "help", "?":
+		fmt.Fprint(out, "Commands may be abbreviated.  Commands are:\n\n"+
+			"!\t\tdir\t\tmdelete\t\tqc\t\tsite\n$\t\tdisconnect\tmdir\t\tsendport\tsize\n"+
+			"account\t\texit\t\tmget\t\tput\t\tstatus\nappend\t\tform\t\tmkdir\t\tpwd\t\tstruct\n"+
+			"ascii\t\tget\t\tmls\t\tquit\t\tsystem\nbell\t\tglob\t\tmode\t\tquote\t\tsunique\n"+
+			"binary\t\thash\t\tmodtime\t\trecv\t\ttenex\nbye\t\thelp\t\tmput\t\treget\t\ttick\n"+
+			"case\t\tidle\t\tnewer\t\trstatus\t\ttrace\ncd\t\timage\t\tnmap\t\trhelp\t\ttype\n"+
+			"cdup\t\tipany\t\tnlist\t\trename\t\tuser\nchmod\t\tipv4\t\tntrans\t\treset\t\tumask\n"+
+			"close\t\tipv6\t\topen\t\trestart\t\tverbose\ncr\t\tlcd\t\tprompt\t\trmdir\t\t?\n"+
+			"delete\t\tls\t\tpassive\t\trunique\ndebug\t\tmacdef\t\tproxy\t\tsend\n")
+		return true
+	case "status":
+		if c.host != "" {
+			fmt.Fprintf(out, "Connected to %v.\n", c.host)
+		} else {
+			fmt.Fprintln(out, "Not connected.")
+		}
+		fmt.Fprintf(out, "No proxy connection.\nMode: stream; Type: %v; Form: non-print; Structure: file\n"+
+			"Verbose: %v; Bell: off; Prompting: %v; Globbing: on\n", map[bool]string{true: "ascii", false: "binary"}[c.ascii],
+			map[bool]string{true: "on", false: "off"}[c.verbose], map[bool]string{true: "on", false: "off"}[c.prompt])
+		return true
+	}
+	if c.host == "" {
+		if _, ok := ftpCommands[cmd]; ok {
+			fmt.Fprintln(out, "Not connected.")
+		} else {
+			fmt.Fprintln(out, "?Invalid command")
+		}
+		return true
+	}
+	switch cmd {
+	case "close", "disconnect":
+		c.reply("221 Goodbye.")
+		c.host, c.loggedIn, c.remote = "", false, nil
+		return true
+	case "user":
+		if len(args) == 0 {
+			fmt.Fprintln(out, "usage: user username [password] [account]")
+			return true
+		}
+		pass := ""
+		if len(args) > 1 {
+			pass = args[1]
+		}
+		c.login(args[0], pass)
+		return true
+	case "system":
+		c.reply("215 UNIX Type: L8")
+		return true
+	}
+	if _, ok := ftpCommands[cmd]; !ok {
+		fmt.Fprintln(out, "?Invalid command")
+		return true
+	}
+	if !c.loggedIn {
+		fmt.Fprintln(out, "530 Please login with USER and PASS.")
+		return true
+	}
+	c.remoteCommand(cmd, args)
+	return true
+}
+
+// ftpCommands are the commands talking to the server
+var ftpCommands = map[string]struct{}{
+	"ls": {}, "dir": {}, "nlist": {}, "cd": {}, "cdup": {}, "pwd": {}, "get": {}, "recv": {}, "put": {},
+	"send": {}, "mget": {}, "mput": {}, "delete": {}, "mkdir": {}, "rmdir": {}, "binary": {}, "bin": {},
+	"ascii": {}, "image": {}, "size": {}, "rename": {}, "chmod": {}, "site": {}, "quote": {}, "append": {},
+	"close": {}, "disconnect": {}, "user": {}, "system": {},
+}
+
+// remoteCommand runs the command on the server logged in
+func (c *ftpConn) remoteCommand(cmd string, args []string) {
+	sys := c.sys
+	out := sys.Out()
+	argOr := func(i int, def string) string {
+		if i < len(args) {
+			return args[i]
+		}
+		return def
+	}
+	switch cmd {
+	case "pwd":
+		fmt.Fprintf(out, "257 \"%v\" is the current directory\n", c.cwd)
+	case "cd", "cdup":
+		dir := c.remotePath(argOr(0, ".."))
+		if fi, err := c.remote.Stat(dir); err != nil || !fi.IsDir() {
+			fmt.Fprintln(out, "550 Failed to change directory.")
+			return
+		}
+		c.cwd = dir
+		c.reply("250 Directory successfully changed.")
+	case "binary", "bin", "image":
+		c.ascii = false
+		c.reply("200 Switching to Binary mode.")
+	case "ascii":
+		c.ascii = true
+		c.reply("200 Switching to ASCII mode.")
+	case "ls", "dir", "nlist":
+		c.list(cmd == "nlist", argOr(0, "."))
+	case "get", "recv":
+		if len(args) == 0 {
+			fmt.Fprintln(out, "usage: get remote-file [local-file]")
+			return
+		}
+		c.get(args[0], argOr(1, pathlib.Base(args[0])))
+	case "mget":
+		for _, pattern := range args {
+			matches, _ := afero.Glob(c.remote, c.remotePath(pattern))
+			for _, m := range matches {
+				if c.confirm("mget", pathlib.Base(m)) {
+					c.get(m, pathlib.Base(m))
+				}
+			}
+		}
+	case "put", "send", "append":
+		if len(args) == 0 {
+			fmt.Fprintf(out, "usage: %v local-file [remote-file]\n", cmd)
+			return
+		}
+		c.put(args[0], argOr(1, args[0]))
+	case "mput":
+		for _, pattern := range args {
+			matches, _ := afero.Glob(sys.FSys(), absPath(sys, pattern))
+			for _, m := range matches {
+				if c.confirm("mput", m) {
+					c.put(m, pathlib.Base(m))
+				}
+			}
+		}
+	case "delete":
+		if p := c.remotePath(argOr(0, "")); len(args) > 0 && c.remote.Remove(p) == nil {
+			c.reply("250 Delete operation successful.")
+		} else {
+			fmt.Fprintln(out, "550 Delete operation failed.")
+		}
+	case "mkdir":
+		if p := c.remotePath(argOr(0, "")); len(args) > 0 && c.remote.Mkdir(p, 0755) == nil {
+			c.reply("257 \"%v\" created", p)
+		} else {
+			fmt.Fprintln(out, "550 Create directory operation failed.")
+		}
+	case "rmdir":
+		if p := c.remotePath(argOr(0, "")); len(args) > 0 && c.remote.Remove(p) == nil {
+			c.reply("250 Remove directory operation successful.")
+		} else {
+			fmt.Fprintln(out, "550 Remove directory operation failed.")
+		}
+	case "size":
+		if fi, err := c.remote.Stat(c.remotePath(argOr(0, ""))); err == nil && !fi.IsDir() {
+			fmt.Fprintf(out, "213 %v\n", fi.Size())
+		} else {
+			fmt.Fprintln(out, "550 Could not get file size.")
+		}
+	case "rename":
+		if len(args) < 2 || c.remote.Rename(c.remotePath(args[0]), c.remotePath(args[1])) != nil {
+			fmt.Fprintln(out, "550 RNFR command failed.")
+			return
+		}
+		c.reply("350 Ready for RNTO.")
+		c.reply("250 Rename successful.")
+	default:
+		fmt.Fprintln(out, "500 Unknown command.")
+	}
+}
+
+// confirm asks before each file of mget and mput, unless prompting is off
+func (c *ftpConn) confirm(cmd, name string) bool {
+	if !c.prompt {
+		return true
+	}
+	fmt.Fprintf(c.sys.Out(), "%v %v? ", cmd, name)
+	answer, ok := readLine(c.tty)
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return ok && (answer == "" || answer[0] == 'y')
+}
+
+// list prints the files of the directory on the server, like vsftpd does
+func (c *ftpConn) list(names bool, p string) {
+	out := c.sys.Out()
+	dir := c.remotePath(p)
+	fi, err := c.remote.Stat(dir)
+	var entries []os.FileInfo
+	switch {
+	case err != nil:
+	case fi.IsDir():
+		entries, _ = afero.ReadDir(c.remote, dir)
+	default:
+		entries = []os.FileInfo{fi}
+	}
+	c.dataConn()
+	c.reply("150 Here comes the directory listing.")
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	for _, e := range entries {
+		if names {
+			fmt.Fprintln(out, e.Name())
+			continue
+		}
+		uid, gid, _, _ := virtualfs.GetExtraInfo(e)
+		stamp := e.ModTime().Format("Jan _2 15:04")
+		if time.Since(e.ModTime()) > 180*24*time.Hour {
+			stamp = e.ModTime().Format("Jan _2  2006")
+		}
+		fmt.Fprintf(out, "%v    1 %-8d %-8d %12d %v %v\n", lsMode(e.Mode()), uid, gid, e.Size(), stamp, e.Name())
+	}
+	c.reply("226 Directory send OK.")
+}
+
+// get downloads the file from the server to the local machine
+func (c *ftpConn) get(remote, local string) {
+	sys := c.sys
+	p := c.remotePath(remote)
+	fmt.Fprintf(sys.Out(), "local: %v remote: %v\n", local, remote)
+	c.dataConn()
+	data, err := afero.ReadFile(c.remote, p)
+	if err != nil {
+		fmt.Fprintln(sys.Out(), "550 Failed to open file.")
+		return
+	}
+	c.logger.WithField("path", p).Infof("User downloaded %v from %v with ftp", p, c.host)
+	if err := afero.WriteFile(sys.FSys(), absPath(sys, local), data, 0644); err != nil {
+		fmt.Fprintf(sys.Out(), "local: %v: %v\n", local, ftpError(err))
+		return
+	}
+	mode := "BINARY"
+	if c.ascii {
+		mode = "ASCII"
+	}
+	c.reply("150 Opening %v mode data connection for %v (%v bytes).", mode, remote, len(data))
+	c.reply("226 Transfer complete.")
+	c.transfer(int64(len(data)), "received")
+}
+
+// put uploads the local file to the server. That is how files are taken out
+// through ftp, so the file is captured whatever the server is
+func (c *ftpConn) put(local, remote string) {
+	sys := c.sys
+	data, err := afero.ReadFile(sys.FSys(), absPath(sys, local))
+	if err != nil {
+		fmt.Fprintf(sys.Out(), "local: %v: %v\n", local, ftpError(err))
+		return
+	}
+	p := c.remotePath(remote)
+	fmt.Fprintf(sys.Out(), "local: %v remote: %v\n", local, remote)
+	c.logger.WithFields(log.Fields{"path": p, "local": absPath(sys, local), "size": len(data)}).
+		Warnf("User uploaded %v to %v with ftp", local, c.host)
+	honeyos.SaveArtifact(sys, data, "ftp put "+c.host+":"+p)
+	c.dataConn()
+	if err := afero.WriteFile(c.remote, p, data, 0644); err != nil {
+		fmt.Fprintln(sys.Out(), "553 Could not create file.")
+		return
+	}
+	c.reply("150 Ok to send data.")
+	c.reply("226 Transfer complete.")
+	c.transfer(int64(len(data)), "sent")
+}
+
+// ftpError is how ftp tells why the local file can't be used
+func ftpError(err error) string {
+	switch {
+	case os.IsNotExist(err):
+		return "No such file or directory"
+	case os.IsPermission(err):
+		return "Permission denied"
+	}
+	return "Is a directory"
+}
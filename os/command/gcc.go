@@ -0,0 +1,768 @@
+package command
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+// gcc is the C compiler, gcc and cc. The sources are captured, checked for
+// the headers and libraries missing in the image and for main, and the
+// binary written is an ELF made up for the machine, which crashes when run
+// like the other binaries
+type gcc struct {
+	name string
+}
+
+// ccBuild is the gcc of the distribution
+type ccBuild struct {
+	version, banner, year string
+	// triple is the target of the x86-64 build, and crt the start file with
+	// the function calling main
+	triple, crt, start string
+	// pie builds position independent executables, shared objects to file
+	pie bool
+}
+
+var ccBuilds = map[string]ccBuild{
+	"ubuntu": {"5", "gcc (Ubuntu 5.4.0-6ubuntu1~16.04.12) 5.4.0 20160609", "2015", "x86_64-linux-gnu",
+		"/usr/lib/gcc/x86_64-linux-gnu/5/../../../x86_64-linux-gnu/crt1.o", "_start", false},
+	"debian": {"6", "gcc (Debian 6.3.0-18+deb9u1) 6.3.0 20170516", "2016", "x86_64-linux-gnu",
+		"/usr/lib/gcc/x86_64-linux-gnu/6/../../../x86_64-linux-gnu/Scrt1.o", "_start", true},
+	"centos": {"4.8.5", "gcc (GCC) 4.8.5 20150623 (Red Hat 4.8.5-44)", "2015", "x86_64-redhat-linux",
+		"/usr/lib/gcc/x86_64-redhat-linux/4.8.5/../../../../lib64/crt1.o", "_start", false},
+	"alpine": {"10.3.1", "gcc (Alpine 10.3.1_git20211027) 10.3.1 20211027", "2020", "x86_64-alpine-linux-musl",
+		"/usr/lib/gcc/x86_64-alpine-linux-musl/10.3.1/../../../../lib/Scrt1.o", "_start_c", true},
+}
+
+// ccHeaderDirs are the directories of the system headers, of libc and the
+// kernel, which are there with the compiler
+var ccHeaderDirs = map[string]bool{"sys": true, "netinet": true, "arpa": true, "net": true, "linux": true,
+	"asm": true, "asm-generic": true, "bits": true, "netpacket": true, "scsi": true, "mtd": true, "rpc": true}
+
+// ccHeaders are the headers of libc
+var ccHeaders = map[string]bool{
+	"assert.h": true, "complex.h": true, "ctype.h": true, "dirent.h": true, "dlfcn.h": true, "elf.h": true,
+	"errno.h": true, "fcntl.h": true, "fenv.h": true, "float.h": true, "fnmatch.h": true, "getopt.h": true,
+	"glob.h": true, "grp.h": true, "ifaddrs.h": true, "inttypes.h": true, "iso646.h": true, "libgen.h": true,
+	"limits.h": true, "link.h": true, "locale.h": true, "malloc.h": true, "math.h": true, "memory.h": true,
+	"netdb.h": true, "paths.h": true, "poll.h": true, "pthread.h": true, "pty.h": true, "pwd.h": true,
+	"regex.h": true, "resolv.h": true, "sched.h": true, "search.h": true, "semaphore.h": true, "setjmp.h": true,
+	"shadow.h": true, "signal.h": true, "spawn.h": true, "stdarg.h": true, "stdbool.h": true, "stddef.h": true,
+	"stdint.h": true, "stdio.h": true, "stdlib.h": true, "string.h": true, "strings.h": true, "syscall.h": true,
+	"syslog.h": true, "termios.h": true, "time.h": true, "ucontext.h": true, "unistd.h": true, "utime.h": true,
+	"utmp.h": true, "utmpx.h": true, "wchar.h": true, "wctype.h": true, "crypt.h": true, "err.h": true,
+	"features.h": true, "alloca.h": true, "endian.h": true, "byteswap.h": true, "stdalign.h": true,
+	"stdnoreturn.h": true, "threads.h": true, "cpio.h": true, "tar.h": true, "ulimit.h": true, "wordexp.h": true,
+}
+
+// ccDevPkgs are the headers and libraries of the development packages,
+// there once installed
+var ccDevPkgs = []struct {
+	dir  string
+	libs []string
+	pkgs []string
+}{
+	{"openssl", []string{"ssl", "crypto"}, []string{"libssl-dev", "openssl-devel"}},
+	{"zlib.h", []string{"z"}, []string{"zlib1g-dev", "libssl-dev"}},
+	{"pcap", []string{"pcap"}, []string{"libpcap0.8", "libpcap"}},
+}
+
+// ccLibs are the libraries of libc
+var ccLibs = map[string]bool{"c": true, "m": true, "pthread": true, "dl": true, "rt": true, "crypt": true,
+	"util": true, "resolv": true, "nsl": true, "gcc": true}
+
+var (
+	ccInclude = regexp.MustCompile(`^\s*#\s*include\s*([<"])([^>"]+)[>"]`)
+	ccMain    = regexp.MustCompile(`\bmain\s*\(`)
+	ccFunc    = regexp.MustCompile(`(?m)^\w[\w \t\*]*?\b(\w+)\s*\([^;{}]*\)\s*\{`)
+)
+
+func init() {
+	honeyos.RegisterCommand("gcc", gcc{"gcc"})
+	honeyos.RegisterCommand("cc", gcc{"cc"})
+}
+
+func (g gcc) GetHelp() string {
+	return `Usage: ` + g.name + ` [options] file...
+Options:
+  -pass-exit-codes         Exit with highest error code from a phase
+  --help                   Display this information
+  --target-help            Display target specific command line options
+  --version                Display compiler version information
+  -dumpversion             Display the version of the compiler
+  -dumpmachine             Display the compiler's target processor
+  -print-search-dirs       Display the directories in the compiler's search path
+  -Wa,<options>            Pass comma-separated <options> on to the assembler
+  -Wp,<options>            Pass comma-separated <options> on to the preprocessor
+  -Wl,<options>            Pass comma-separated <options> on to the linker
+  -Xlinker <arg>           Pass <arg> on to the linker
+  -save-temps              Do not delete intermediate files
+  -std=<standard>          Assume that the input sources are for <standard>
+  -pipe                    Use pipes rather than intermediate files
+  -time                    Time the execution of each subprocess
+  -v                       Display the programs invoked by the compiler
+  -E                       Preprocess only; do not compile, assemble or link
+  -S                       Compile only; do not assemble or link
+  -c                       Compile and assemble, but do not link
+  -o <file>                Place the output into <file>
+  -pie                     Create a position independent executable
+  -shared                  Create a shared library
+  -x <language>            Specify the language of the following input files
+                           Permissible languages include: c c++ assembler none
+                           'none' means revert to the default behavior of
+                           guessing the language based on the file's extension
+
+For bug reporting instructions, please see:
+<file:///usr/share/doc/gcc-5/README.Bugs>.
+`
+}
+
+func (g gcc) Where() string {
+	return "/usr/bin/" + g.name
+}
+
+// ccBuildOf is the gcc of the distribution, for the machine
+func ccBuildOf() ccBuild {
+	b, ok := ccBuilds[honeyos.Distro()]
+	if !ok {
+		b = ccBuilds["ubuntu"]
+	}
+	arch := strings.NewReplacer("arm64", "aarch64", "i386", "i686").Replace(honeyos.Arch())
+	if arch != "" {
+		b.triple = strings.Replace(b.triple, "x86_64", arch, 1)
+		b.crt = strings.Replace(b.crt, "x86_64", arch, 1)
+	}
+	return b
+}
+
+// ccQuote quotes the name in the messages, with the typographic quotes in
+// UTF-8 locales
+func ccQuote(sys honeyos.Sys, s string) string {
+	if strings.Contains(strings.ToLower(honeyos.Getenv(sys, "LANG")), "utf") {
+		return "‘" + s + "’"
+	}
+	return "'" + s + "'"
+}
+
+func (g gcc) Exec(args []string, sys honeyos.Sys) int {
+	if !loadPkgDB(sys, pkgFamily()).installed("gcc") {
+		return honeyos.CommandNotFound(sys, append([]string{g.name}, args...))
+	}
+	build := ccBuildOf()
+	var inputs, libs, incDirs []string
+	output, mode, lang := "", "", ""
+	static, shared, strip, debug := false, false, false, false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		// Options taking the value in the next argument as well
+		value := func(opt string) (string, bool) {
+			if len(arg) > len(opt) {
+				return arg[len(opt):], true
+			}
+			if i+1 == len(args) {
+				fmt.Fprintf(sys.Err(), "%v: error: missing argument to '%v'\n%v: fatal error: no input files\ncompilation terminated.\n", g.name, opt, g.name)
+				return "", false
+			}
+			i++
+			return args[i], true
+		}
+		var ok bool
+		switch {
+		case arg == "--help":
+			fmt.Fprint(sys.Out(), g.GetHelp())
+			return 0
+		case arg == "--version":
+			fmt.Fprintf(sys.Out(), "%v %v\nCopyright (C) %v Free Software Foundation, Inc.\n"+
+				"This is free software; see the source for copying conditions.  There is NO\n"+
+				"warranty; not even for MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.\n\n",
+				g.name, strings.TrimPrefix(build.banner, "gcc "), build.year)
+			return 0
+		case arg == "-dumpversion":
+			fmt.Fprintln(sys.Out(), build.version)
+			return 0
+		case arg == "-dumpmachine":
+			fmt.Fprintln(sys.Out(), build.triple)
+			return 0
+		case arg == "-v" && len(args) == 1:
+			fmt.Fprintf(sys.Err(), "Using built-in specs.\nCOLLECT_GCC=%v\nTarget: %v\nThread model: posix\ngcc version %v\n",
+				g.name, build.triple, strings.SplitN(build.banner, ") ", 2)[1])
+			return 0
+		case arg == "-c" || arg == "-S" || arg == "-E":
+			mode = arg
+		case arg == "-static":
+			static = true
+		case arg == "-shared":
+			shared = true
+		case arg == "-s":
+			strip = true
+		case strings.HasPrefix(arg, "-g"):
+			debug = true
+		case strings.HasPrefix(arg, "-o"):
+			if output, ok = value("-o"); !ok {
+				return 1
+			}
+		case strings.HasPrefix(arg, "-l"):
+			var lib string
+			if lib, ok = value("-l"); !ok {
+				return 1
+			}
+			libs = append(libs, lib)
+		case strings.HasPrefix(arg, "-I"):
+			var dir string
+			if dir, ok = value("-I"); !ok {
+				return 1
+			}
+			incDirs = append(incDirs, dir)
+		case strings.HasPrefix(arg, "-x"):
+			if lang, ok = value("-x"); !ok {
+				return 1
+			}
+		case arg == "-L" || arg == "-D" || arg == "-U" || arg == "-include" || arg == "-Xlinker":
+			i++
+		case arg == "-":
+			inputs = append(inputs, arg)
+		case strings.HasPrefix(arg, "-"):
+		default:
+			inputs = append(inputs, arg)
+		}
+	}
+	// The sources are read first, so what was given is kept even if it
+	// fails to compile
+	var sources, objects []string
+	code := map[string][]byte{}
+	failed := false
+	for _, in := range inputs {
+		if in == "-" {
+			if lang == "" {
+				fmt.Fprintf(sys.Err(), "%v: error: -E or -x required when input is from standard input\n", g.name)
+				return 1
+			}
+			data, _ := ioutil.ReadAll(sys.In())
+			code[in] = data
+			sources = append(sources, in)
+			honeyos.SaveArtifact(sys, data, g.name+" stdin")
+			continue
+		}
+		data, err := readFile(sys, absPath(sys, in))
+		if err != nil {
+			fmt.Fprintf(sys.Err(), "%v: error: %v: No such file or directory\n", g.name, in)
+			failed = true
+			continue
+		}
+		switch path.Ext(in) {
+		case ".o", ".a", ".so":
+			objects = append(objects, in)
+			continue
+		case ".c", ".h", ".i", ".s", ".S":
+		default:
+			if lang == "" {
+				// Taken by the linker, which fails on the text
+				objects = append(objects, in)
+				continue
+			}
+		}
+		code[in] = data
+		sources = append(sources, in)
+		sum, _ := honeyos.SaveArtifact(sys, data, g.name+" "+absPath(sys, in))
+		sys.Log().WithFields(log.Fields{
+			"path":   absPath(sys, in),
+			"sha256": sum,
+			"args":   args,
+		}).Infof("User compiled %v with %v", in, g.name)
+	}
+	if len(sources)+len(objects) == 0 {
+		fmt.Fprintf(sys.Err(), "%v: fatal error: no input files\ncompilation terminated.\n", g.name)
+		return 1
+	}
+	if failed {
+		return 1
+	}
+	if output != "" && mode != "" && len(sources) > 1 {
+		fmt.Fprintf(sys.Err(), "%v: fatal error: cannot specify -o with -c, -S or -E with multiple files\ncompilation terminated.\n", g.name)
+		return 1
+	}
+
+	for _, src := range sources {
+		if !g.compile(sys, build, src, code[src], incDirs) {
+			return 1
+		}
+	}
+	switch mode {
+	case "-E":
+		for _, src := range sources {
+			g.preprocess(sys, src, code[src], output)
+		}
+		return 0
+	case "-S", "-c":
+		for _, src := range sources {
+			out := output
+			if out == "" {
+				ext := map[string]string{"-S": ".s", "-c": ".o"}[mode]
+				out = strings.TrimSuffix(path.Base(src), path.Ext(src)) + ext
+				if src == "-" {
+					out = "-" + ext
+				}
+			}
+			var data []byte
+			if mode == "-S" {
+				data = g.assembly(build, src, code[src])
+			} else {
+				data = ccELF(elf.ET_REL, "", false, false, !strip, debug, src, code[src])
+			}
+			if afero.WriteFile(sys.FSys(), absPath(sys, out), data, 0666&^sys.Umask()) != nil {
+				fmt.Fprintf(sys.Err(), "%v: fatal error: opening output file %v: Permission denied\ncompilation terminated.\n", src, out)
+				return 1
+			}
+		}
+		return 0
+	}
+
+	ld := "/usr/bin/ld"
+	if honeyos.Distro() == "alpine" {
+		ld = fmt.Sprintf("/usr/lib/gcc/%v/%v/../../../../%v/bin/ld", build.triple, build.version, build.triple)
+	}
+	hasMain := len(objects) > 0
+	for _, src := range sources {
+		hasMain = hasMain || ccMain.Match(code[src])
+	}
+	for _, obj := range objects {
+		if data, _ := readFile(sys, absPath(sys, obj)); !bytes.HasPrefix(data, []byte("\x7fELF")) && !bytes.HasPrefix(data, []byte("!<arch>")) {
+			fmt.Fprintf(sys.Err(), "%v:%v: file format not recognized; treating as linker script\n%v:%v:1: syntax error\n"+
+				"collect2: error: ld returned 1 exit status\n", ld, obj, ld, obj)
+			return 1
+		}
+	}
+	for _, lib := range libs {
+		if !ccLibs[lib] && !ccDevInstalled(sys, lib, true) {
+			fmt.Fprintf(sys.Err(), "%v: cannot find -l%v\ncollect2: error: ld returned 1 exit status\n", ld, lib)
+			return 1
+		}
+	}
+	if !hasMain && !shared {
+		if honeyos.Distro() == "alpine" {
+			fmt.Fprintf(sys.Err(), "%v: %v: in function `%v':\n/home/buildozer/aports/main/musl/src/musl-1.2.2/crt/crt1.c:18: undefined reference to `main'\n",
+				ld, build.crt, build.start)
+		} else {
+			fmt.Fprintf(sys.Err(), "%v: In function `%v':\n(.text+0x20): undefined reference to `main'\n", build.crt, build.start)
+		}
+		fmt.Fprintln(sys.Err(), "collect2: error: ld returned 1 exit status")
+		return 1
+	}
+	for _, src := range sources {
+		if bytes.Contains(code[src], []byte("gets(")) && !bytes.Contains(code[src], []byte("fgets(")) {
+			fmt.Fprintf(sys.Err(), "/tmp/cc%v.o: In function `main':\n%v:(.text+0x%x): warning: the `gets' function is dangerous and should not be used.\n",
+				strconv.FormatInt(rand.Int63n(1<<36), 36), src, 0x10+rand.Intn(0x40))
+		}
+	}
+	if output == "" {
+		output = "a.out"
+	}
+	kind, interp := elf.ET_EXEC, ccInterp()
+	if build.pie || shared {
+		kind = elf.ET_DYN
+	}
+	if static || shared {
+		interp = ""
+	}
+	var all []byte
+	for _, src := range sources {
+		all = append(all, code[src]...)
+	}
+	data := ccELF(kind, interp, !static, honeyos.Distro() != "alpine", !strip, debug, strings.Join(sources, " "), all)
+	if afero.WriteFile(sys.FSys(), absPath(sys, output), data, 0777&^sys.Umask()) != nil {
+		fmt.Fprintf(sys.Err(), "%v: cannot open output file %v: Permission denied\ncollect2: error: ld returned 1 exit status\n", ld, output)
+		return 1
+	}
+	sys.FSys().Chmod(absPath(sys, output), 0777&^sys.Umask())
+	return 0
+}
+
+// ccDevInstalled tells if the header or library of the development package
+// is installed
+func ccDevInstalled(sys honeyos.Sys, name string, lib bool) bool {
+	db := loadPkgDB(sys, pkgFamily())
+	for _, dev := range ccDevPkgs {
+		match := dev.dir == name
+		if lib {
+			match = false
+			for _, l := range dev.libs {
+				match = match || l == name
+			}
+		}
+		if !match {
+			continue
+		}
+		for _, p := range dev.pkgs {
+			if db.installed(p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// compile checks the source for the headers it includes and the braces
+// closed at the end, failing with the errors of gcc
+func (g gcc) compile(sys honeyos.Sys, build ccBuild, src string, code []byte, incDirs []string) bool {
+	lines := strings.Split(string(code), "\n")
+	for n, line := range lines {
+		m := ccInclude.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		header := m[2]
+		found := ccHeaders[header]
+		if dir := strings.SplitN(header, "/", 2)[0]; strings.Contains(header, "/") && ccHeaderDirs[dir] {
+			found = true
+		}
+		found = found || ccDevInstalled(sys, strings.SplitN(header, "/", 2)[0], false)
+		dirs := incDirs
+		if m[1] == `"` {
+			dirs = append([]string{path.Dir(src)}, incDirs...)
+		}
+		for _, dir := range dirs {
+			if _, err := sys.FSys().Stat(absPath(sys, path.Join(dir, header))); err == nil {
+				found = true
+			}
+		}
+		if found {
+			continue
+		}
+		// gcc 9 on points at the header, with the line number in the margin.
+		// Older ones point past it
+		col := len(strings.TrimRight(line, " \t\r")) + 1
+		major, _ := strconv.Atoi(strings.SplitN(build.version, ".", 2)[0])
+		if major >= 9 {
+			col = strings.Index(line, m[1]) + 1
+		}
+		fmt.Fprintf(sys.Err(), "%v:%d:%d: fatal error: %v: No such file or directory\n", src, n+1, col, header)
+		if major >= 9 {
+			fmt.Fprintf(sys.Err(), "%5d | %v\n      | %v^%v\n", n+1, line, strings.Repeat(" ", col-1), strings.Repeat("~", len(header)+1))
+		} else {
+			fmt.Fprintf(sys.Err(), " %v\n %v^\n", line, strings.Repeat(" ", col-1))
+		}
+		fmt.Fprintln(sys.Err(), "compilation terminated.")
+		return false
+	}
+	if ccUnclosed(code) {
+		fn := "main"
+		if all := ccFunc.FindAllSubmatch(code, -1); len(all) > 0 {
+			fn = string(all[len(all)-1][1])
+		}
+		last := len(lines)
+		for last > 1 && strings.TrimSpace(lines[last-1]) == "" {
+			last--
+		}
+		fmt.Fprintf(sys.Err(), "%v: In function %v:\n%v:%d:%d: error: expected declaration or statement at end of input\n",
+			src, ccQuote(sys, fn), src, last, len(lines[last-1])+1)
+		return false
+	}
+	return true
+}
+
+// ccUnclosed tells if the braces of the code are left open, leaving out
+// those in comments, strings and characters
+func ccUnclosed(code []byte) bool {
+	depth := 0
+	for i := 0; i < len(code); i++ {
+		switch c := code[i]; {
+		case c == '/' && i+1 < len(code) && code[i+1] == '/':
+			for i < len(code) && code[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(code) && code[i+1] == '*':
+			end := bytes.Index(code[i+2:], []byte("*/"))
+			if end < 0 {
+				return false
+			}
+			i += end + 3
+		case c == '"' || c == '\'':
+			for i++; i < len(code) && code[i] != c && code[i] != '\n'; i++ {
+				if code[i] == '\\' {
+					i++
+				}
+			}
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+		}
+	}
+	return depth > 0
+}
+
+// preprocess writes the source without the includes, with the line markers
+// of cpp
+func (g gcc) preprocess(sys honeyos.Sys, src string, code []byte, output string) {
+	name := src
+	if src == "-" {
+		name = "<stdin>"
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# 1 %q\n# 1 \"<built-in>\"\n# 1 \"<command-line>\"\n# 1 %q\n", name, name)
+	for _, line := range strings.Split(string(code), "\n") {
+		if ccInclude.MatchString(line) {
+			line = ""
+		}
+		fmt.Fprintln(&b, line)
+	}
+	if output == "" || output == "-" {
+		sys.Out().Write(b.Bytes())
+		return
+	}
+	afero.WriteFile(sys.FSys(), absPath(sys, output), b.Bytes(), 0666&^sys.Umask())
+}
+
+// assembly makes up the assembly of the source, with the strings it has in
+// the read only data
+func (g gcc) assembly(build ccBuild, src string, code []byte) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "\t.file\t%q\n\t.section\t.rodata\n", path.Base(src))
+	for i, s := range regexp.MustCompile(`"(?:[^"\\\n]|\\.)*"`).FindAll(code, -1) {
+		fmt.Fprintf(&b, ".LC%d:\n\t.string\t%s\n", i, s)
+	}
+	b.WriteString("\t.text\n\t.globl\tmain\n\t.type\tmain, @function\nmain:\n.LFB0:\n\t.cfi_startproc\n" +
+		"\tpushq\t%rbp\n\t.cfi_def_cfa_offset 16\n\t.cfi_offset 6, -16\n\tmovq\t%rsp, %rbp\n\t.cfi_def_cfa_register 6\n" +
+		"\tmovl\t$0, %eax\n\tpopq\t%rbp\n\t.cfi_def_cfa 7, 8\n\tret\n\t.cfi_endproc\n.LFE0:\n\t.size\tmain, .-main\n")
+	fmt.Fprintf(&b, "\t.ident\t\"GCC: %v\"\n\t.section\t.note.GNU-stack,\"\",@progbits\n", strings.TrimPrefix(build.banner, "gcc "))
+	return b.Bytes()
+}
+
+// ccInterp is the dynamic loader of the distribution for the machine
+func ccInterp() string {
+	switch arch := honeyos.Arch(); {
+	case honeyos.Distro() == "alpine":
+		return "/lib/ld-musl-" + strings.Replace(arch, "arm64", "aarch64", 1) + ".so.1"
+	case arch == "aarch64" || arch == "arm64":
+		return "/lib/ld-linux-aarch64.so.1"
+	case arch == "i386" || arch == "i686":
+		return "/lib/ld-linux.so.2"
+	}
+	return "/lib64/ld-linux-x86-64.so.2"
+}
+
+// ccELF makes up the ELF gcc writes for the code, sized like it and with the
+// headers file reads: the loader, the ABI and build ID notes, and the
+// symbols unless stripped. The code itself is random, seeded by the source
+// so the same source builds the same binary
+func ccELF(kind elf.Type, interp string, dynamic, abiNote, symtab, debug bool, src string, code []byte) []byte {
+	sum := sha1.Sum(code)
+	rnd := rand.New(rand.NewSource(int64(binary.LittleEndian.Uint64(sum[:]))))
+	machine, is64 := elf.EM_X86_64, true
+	switch honeyos.Arch() {
+	case "aarch64", "arm64":
+		machine = elf.EM_AARCH64
+	case "i386", "i686":
+		machine, is64 = elf.EM_386, false
+	}
+	word, ehsize, phentsize, shentsize, symsize := 8, 64, 56, 64, 24
+	if !is64 {
+		word, ehsize, phentsize, shentsize, symsize = 4, 52, 32, 40, 16
+	}
+	le := binary.LittleEndian
+	putWord := func(b *bytes.Buffer, v uint64) {
+		if is64 {
+			binary.Write(b, le, v)
+		} else {
+			binary.Write(b, le, uint32(v))
+		}
+	}
+
+	// The sections, laid out after the headers of the program
+	type section struct {
+		name       string
+		typ        elf.SectionType
+		flags      elf.SectionFlag
+		data       []byte
+		link, info uint32
+		align      int
+		entsize    int
+		off        int
+	}
+	note := func(typ uint32, desc []byte) []byte {
+		var b bytes.Buffer
+		binary.Write(&b, le, []uint32{4, uint32(len(desc)), typ})
+		b.WriteString("GNU\x00")
+		b.Write(desc)
+		return b.Bytes()
+	}
+	size := 400
+	switch {
+	case kind != elf.ET_REL && dynamic:
+		// Padded to the pages of the segments
+		size = 6200
+	case kind != elf.ET_REL:
+		// The libc linked in
+		size = 780000
+	}
+	text := make([]byte, size+len(code)/3)
+	rnd.Read(text)
+	var strtab bytes.Buffer
+	strtab.WriteString("\x00" + path.Base(src) + "\x00main\x00")
+	var syms bytes.Buffer
+	sym := func(name uint32, info byte, shndx uint16, value, size uint64) {
+		binary.Write(&syms, le, name)
+		if is64 {
+			binary.Write(&syms, le, []byte{info, 0})
+			binary.Write(&syms, le, shndx)
+			binary.Write(&syms, le, []uint64{value, size})
+		} else {
+			binary.Write(&syms, le, []uint32{uint32(value), uint32(size)})
+			binary.Write(&syms, le, []byte{info, 0})
+			binary.Write(&syms, le, shndx)
+		}
+	}
+
+	sections := []*section{{}}
+	add := func(s *section) int {
+		sections = append(sections, s)
+		return len(sections) - 1
+	}
+	if interp != "" {
+		add(&section{name: ".interp", typ: elf.SHT_PROGBITS, flags: elf.SHF_ALLOC, data: []byte(interp + "\x00"), align: 1})
+	}
+	var notes []*section
+	if kind != elf.ET_REL {
+		if abiNote {
+			s := &section{name: ".note.ABI-tag", typ: elf.SHT_NOTE, flags: elf.SHF_ALLOC, data: note(1, []byte{0, 0, 0, 0, 2, 0, 0, 0, 6, 0, 0, 0, 32, 0, 0, 0}), align: 4}
+			add(s)
+			notes = append(notes, s)
+		}
+		s := &section{name: ".note.gnu.build-id", typ: elf.SHT_NOTE, flags: elf.SHF_ALLOC, data: note(3, sum[:]), align: 4}
+		add(s)
+		notes = append(notes, s)
+	}
+	textSec := &section{name: ".text", typ: elf.SHT_PROGBITS, flags: elf.SHF_ALLOC | elf.SHF_EXECINSTR, data: text, align: 16}
+	textIdx := add(textSec)
+	var dynSec *section
+	if dynamic && kind != elf.ET_REL {
+		dynSec = &section{name: ".dynamic", typ: elf.SHT_DYNAMIC, flags: elf.SHF_ALLOC | elf.SHF_WRITE,
+			data: make([]byte, 24*2*word), align: word, entsize: 2 * word}
+		add(dynSec)
+	}
+	add(&section{name: ".comment", typ: elf.SHT_PROGBITS, data: []byte("GCC: " + strings.TrimPrefix(ccBuildOf().banner, "gcc ") + "\x00"), align: 1})
+	if debug {
+		info := make([]byte, 64+len(code)/2)
+		rnd.Read(info)
+		add(&section{name: ".debug_info", typ: elf.SHT_PROGBITS, data: info, align: 1})
+	}
+	if symtab {
+		sym(0, 0, 0, 0, 0)
+		sym(1, byte(elf.STB_LOCAL)<<4|byte(elf.STT_FILE), uint16(elf.SHN_ABS), 0, 0)
+		sym(uint32(len(path.Base(src))+2), byte(elf.STB_GLOBAL)<<4|byte(elf.STT_FUNC), uint16(textIdx), 0, uint64(len(text)/8))
+		idx := add(&section{name: ".symtab", typ: elf.SHT_SYMTAB, data: syms.Bytes(), info: 2, align: word, entsize: symsize})
+		sections[idx].link = uint32(add(&section{name: ".strtab", typ: elf.SHT_STRTAB, data: strtab.Bytes(), align: 1}))
+	}
+	shstrtab := &section{name: ".shstrtab", typ: elf.SHT_STRTAB, align: 1}
+	shstrndx := add(shstrtab)
+	names := map[string]int{}
+	var shstr bytes.Buffer
+	shstr.WriteByte(0)
+	for _, s := range sections[1:] {
+		names[s.name] = shstr.Len()
+		shstr.WriteString(s.name + "\x00")
+	}
+	shstrtab.data = shstr.Bytes()
+
+	phnum := 0
+	if kind != elf.ET_REL {
+		phnum = 2 // LOAD and NOTE
+		if interp != "" {
+			phnum += 2
+		}
+		if dynSec != nil {
+			phnum++
+		}
+	}
+	off := ehsize + phnum*phentsize
+	for _, s := range sections[1:] {
+		off = (off + s.align - 1) / s.align * s.align
+		s.off = off
+		off += len(s.data)
+	}
+	shoff := (off + word - 1) / word * word
+	base := uint64(0)
+	if kind == elf.ET_EXEC {
+		base = 0x400000
+		if !is64 {
+			base = 0x8048000
+		}
+	}
+	addr := func(s *section) uint64 {
+		if s.flags&elf.SHF_ALLOC == 0 {
+			return 0
+		}
+		return base + uint64(s.off)
+	}
+
+	var b bytes.Buffer
+	b.Write([]byte{0x7f, 'E', 'L', 'F', map[bool]byte{true: 2, false: 1}[is64], 1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+	binary.Write(&b, le, []uint16{uint16(kind), uint16(machine)})
+	binary.Write(&b, le, uint32(1))
+	entry := uint64(0)
+	if kind != elf.ET_REL {
+		entry = addr(textSec)
+	}
+	putWord(&b, entry)
+	putWord(&b, map[bool]uint64{true: uint64(ehsize), false: 0}[phnum > 0])
+	putWord(&b, uint64(shoff))
+	binary.Write(&b, le, uint32(0))
+	binary.Write(&b, le, []uint16{uint16(ehsize), uint16(map[bool]int{true: phentsize}[phnum > 0]), uint16(phnum),
+		uint16(shentsize), uint16(len(sections)), uint16(shstrndx)})
+
+	prog := func(typ elf.ProgType, flags elf.ProgFlag, off, filesz int, align uint64) {
+		vaddr := base + uint64(off)
+		if is64 {
+			binary.Write(&b, le, []uint32{uint32(typ), uint32(flags)})
+			binary.Write(&b, le, []uint64{uint64(off), vaddr, vaddr, uint64(filesz), uint64(filesz), align})
+		} else {
+			binary.Write(&b, le, []uint32{uint32(typ), uint32(off), uint32(vaddr), uint32(vaddr), uint32(filesz), uint32(filesz),
+				uint32(flags), uint32(align)})
+		}
+	}
+	if phnum > 0 {
+		if interp != "" {
+			prog(elf.PT_PHDR, elf.PF_R|elf.PF_X, ehsize, phnum*phentsize, uint64(word))
+			prog(elf.PT_INTERP, elf.PF_R, sections[1].off, len(sections[1].data), 1)
+		}
+		prog(elf.PT_LOAD, elf.PF_R|elf.PF_X, 0, off, 0x200000)
+		if dynSec != nil {
+			prog(elf.PT_DYNAMIC, elf.PF_R|elf.PF_W, dynSec.off, len(dynSec.data), uint64(word))
+		}
+		notesEnd := notes[len(notes)-1]
+		prog(elf.PT_NOTE, elf.PF_R, notes[0].off, notesEnd.off+len(notesEnd.data)-notes[0].off, 4)
+	}
+	for _, s := range sections[1:] {
+		b.Write(make([]byte, s.off-b.Len()))
+		b.Write(s.data)
+	}
+	b.Write(make([]byte, shoff-b.Len()))
+	for _, s := range sections {
+		if s.name == "" {
+			b.Write(make([]byte, shentsize))
+			continue
+		}
+		binary.Write(&b, le, []uint32{uint32(names[s.name]), uint32(s.typ)})
+		putWord(&b, uint64(s.flags))
+		putWord(&b, addr(s))
+		putWord(&b, uint64(s.off))
+		putWord(&b, uint64(len(s.data)))
+		binary.Write(&b, le, []uint32{s.link, s.info})
+		putWord(&b, uint64(s.align))
+		putWord(&b, uint64(s.entsize))
+	}
+	return b.Bytes()
+}
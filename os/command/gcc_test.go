@@ -0,0 +1,96 @@
+package command
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/spf13/afero"
+)
+
+const ccHello = "#include <stdio.h>\nint main(void) {\n\tprintf(\"hi\\n\");\n\treturn 0;\n}\n"
+
+func TestGcc(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		output string
+		errMsg string
+		status int
+	}{
+		{"gcc", []string{"hello.c"}, "/root/a.out", "", 0},
+		{"cc", []string{"-O2", "-o", "/tmp/hello", "hello.c"}, "/tmp/hello", "", 0},
+		{"gcc", []string{"-ohello", "hello.c", "-lm", "-lpthread"}, "/root/hello", "", 0},
+		{"gcc", []string{"-c", "hello.c"}, "/root/hello.o", "", 0},
+		{"gcc", []string{"-S", "hello.c"}, "/root/hello.s", "", 0},
+		{"gcc", []string{"-shared", "-fPIC", "-o", "lib.so", "lib.c"}, "/root/lib.so", "", 0},
+		{"gcc", []string{"-I", "/tmp/inc", "local.c"}, "/root/a.out", "", 0},
+		{"cc", []string{"lib.c"}, "", "/usr/lib/gcc/x86_64-linux-gnu/5/../../../x86_64-linux-gnu/crt1.o: In function `_start':\n" +
+			"(.text+0x20): undefined reference to `main'\ncollect2: error: ld returned 1 exit status\n", 1},
+		{"gcc", []string{"ssl.c"}, "", "ssl.c:1:25: fatal error: openssl/ssl.h: No such file or directory\n" +
+			" #include <openssl/ssl.h>\n                         ^\ncompilation terminated.\n", 1},
+		{"gcc", []string{"local.c"}, "", "local.c:1:17: fatal error: exp.h: No such file or directory\n" +
+			" #include \"exp.h\"\n                 ^\ncompilation terminated.\n", 1},
+		{"gcc", []string{"open.c"}, "", "open.c: In function ‘main’:\nopen.c:4:2: error: expected declaration or statement at end of input\n", 1},
+		{"gcc", []string{"hello.c", "-lssl"}, "", "/usr/bin/ld: cannot find -lssl\ncollect2: error: ld returned 1 exit status\n", 1},
+		{"gcc", []string{"none.c"}, "", "gcc: error: none.c: No such file or directory\ngcc: fatal error: no input files\ncompilation terminated.\n", 1},
+		{"cc", nil, "", "cc: fatal error: no input files\ncompilation terminated.\n", 1},
+		{"gcc", []string{"-o"}, "", "gcc: error: missing argument to '-o'\ngcc: fatal error: no input files\ncompilation terminated.\n", 1},
+	}
+	for _, tt := range tests {
+		sys := newTestSys("")
+		sys.install("gcc")
+		fs := sys.FSys()
+		fs.MkdirAll("/root", 0700)
+		fs.MkdirAll("/tmp/inc", 0755)
+		sys.Chdir("/root")
+		afero.WriteFile(fs, "/root/hello.c", []byte(ccHello), 0644)
+		afero.WriteFile(fs, "/root/lib.c", []byte("int f(void) { return 1; }\n"), 0644)
+		afero.WriteFile(fs, "/root/ssl.c", []byte("#include <openssl/ssl.h>\nint main() {}\n"), 0644)
+		afero.WriteFile(fs, "/root/local.c", []byte("#include \"exp.h\"\nint main() { return 0; }\n"), 0644)
+		afero.WriteFile(fs, "/root/open.c", []byte("int main() {\n\tif (1) {\n\treturn 0;\n}\n"), 0644)
+		if tt.args != nil && tt.args[0] == "-I" {
+			afero.WriteFile(fs, "/tmp/inc/exp.h", []byte("#define X 1\n"), 0644)
+		}
+		status := sys.run(gcc{tt.name}, tt.args...)
+		if errMsg := sys.err.String(); errMsg != tt.errMsg || status != tt.status {
+			t.Errorf("%v %q, expect %q with status %v, got %q with status %v", tt.name, tt.args, tt.errMsg, tt.status, errMsg, status)
+		}
+		if tt.output == "" {
+			continue
+		}
+		data, err := afero.ReadFile(fs, tt.output)
+		if err != nil {
+			t.Errorf("%v %q, expect %v written, got %v", tt.name, tt.args, tt.output, err)
+			continue
+		}
+		if tt.args[0] == "-S" {
+			if !bytes.Contains(data, []byte(`.string	"hi\n"`)) {
+				t.Errorf("%v %q, expect the assembly to have the string, got %q", tt.name, tt.args, data)
+			}
+		} else if !bytes.HasPrefix(data, []byte("\x7fELF")) {
+			t.Errorf("%v %q, expect an ELF written to %v", tt.name, tt.args, tt.output)
+		}
+	}
+}
+
+func TestGccCapture(t *testing.T) {
+	sys := newTestSys("")
+	sys.install("gcc")
+	hook := test.NewLocal(sys.Log().Logger)
+	afero.WriteFile(sys.FSys(), "/tmp/x.c", []byte(ccHello), 0644)
+	sys.run(gcc{"gcc"}, "-o", "/tmp/x", "/tmp/x.c")
+	logged := false
+	for _, e := range hook.AllEntries() {
+		logged = logged || e.Data["path"] == "/tmp/x.c" && e.Data["sha256"] != ""
+	}
+	if !logged {
+		t.Errorf("Expect the source logged, got %v", hook.AllEntries())
+	}
+	// The same source builds the same binary
+	a, _ := afero.ReadFile(sys.FSys(), "/tmp/x")
+	sys.run(gcc{"gcc"}, "-o", "/tmp/y", "/tmp/x.c")
+	if b, _ := afero.ReadFile(sys.FSys(), "/tmp/y"); len(a) == 0 || !bytes.Equal(a, b) {
+		t.Error("Expect the binaries of the same source to be the same")
+	}
+}
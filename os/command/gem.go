@@ -0,0 +1,242 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+)
+
+// gem installs the Ruby gems to the gem directory of the system, or of the
+// user with --user-install. The gems requested are logged
+type gem struct{}
+
+// gemBuild is the RubyGems of the distribution and where it installs
+type gemBuild struct {
+	version, ruby, dir string
+}
+
+var gemBuilds = map[string]gemBuild{
+	"ubuntu": {"2.5.2.1", "2.3.0", "/var/lib/gems/2.3.0"},
+	"debian": {"2.6.14", "2.3.0", "/var/lib/gems/2.3.0"},
+	"centos": {"2.0.14.1", "2.0.0", "/usr/local/share/gems"},
+	"alpine": {"3.2.22", "3.0.0", "/usr/lib/ruby/gems/3.0.0"},
+}
+
+// gemDefaults are the gems which come with Ruby
+var gemDefaults = map[string]string{"bigdecimal": "1.2.8", "io-console": "0.4.5", "json": "1.8.3",
+	"minitest": "5.8.2", "net-telnet": "0.1.1", "power_assert": "0.2.6", "psych": "2.0.17", "rake": "10.5.0",
+	"rdoc": "4.2.1", "test-unit": "3.1.5"}
+
+const gemUsage = `RubyGems is a sophisticated package manager for Ruby.  This is a
+basic help message containing pointers to more information.
+
+  Usage:
+    gem -h/--help
+    gem -v/--version
+    gem command [arguments...] [options...]
+
+  Examples:
+    gem install rake
+    gem list --local
+    gem build package.gemspec
+    gem help install
+
+  Further help:
+    gem help commands            list all 'gem' commands
+    gem help examples            show some examples of usage
+    gem help gem_dependencies    gem dependencies file guide
+    gem help platforms           gem platforms guide
+    gem help <COMMAND>           show help on COMMAND
+                                   (e.g. 'gem help install')
+    gem server                   present a web page at
+                                 http://localhost:8808/
+                                 with info about installed gems
+  Further information:
+    http://guides.rubygems.org
+`
+
+func init() {
+	honeyos.RegisterCommand("gem", gem{})
+}
+
+func (gem) GetHelp() string {
+	return gemUsage
+}
+
+func (gem) Where() string {
+	return "/usr/bin/gem"
+}
+
+func (g gem) Exec(args []string, sys honeyos.Sys) int {
+	if !loadPkgDB(sys, pkgFamily()).installed("ruby") {
+		return honeyos.CommandNotFound(sys, append([]string{"gem"}, args...))
+	}
+	build, ok := gemBuilds[honeyos.Distro()]
+	if !ok {
+		build = gemBuilds["ubuntu"]
+	}
+	userDir := honeyos.GetUserByID(sys.CurrentUser()).Homedir + "/.gem/ruby/" + build.ruby
+	var cmd, version string
+	var names []string
+	user, docs := false, true
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "-v" || arg == "--version":
+			if cmd == "" {
+				fmt.Fprintln(sys.Out(), build.version)
+				return 0
+			}
+			if i+1 < len(args) {
+				i++
+				version = strings.TrimLeft(args[i], "=~> ")
+			}
+		case arg == "-h" || arg == "--help":
+			fmt.Fprint(sys.Out(), gemUsage)
+			return 0
+		case arg == "--user-install":
+			user = true
+		case arg == "-N" || arg == "--no-document" || arg == "--no-ri" || arg == "--no-rdoc":
+			docs = false
+		case arg == "-i" || arg == "--install-dir" || arg == "-s" || arg == "--source" || arg == "-p" || arg == "--http-proxy":
+			i++
+		case strings.HasPrefix(arg, "-"):
+		case cmd == "":
+			cmd = arg
+		default:
+			names = append(names, arg)
+		}
+	}
+	dir := build.dir
+	if user {
+		dir = userDir
+	}
+	switch cmd {
+	case "":
+		fmt.Fprint(sys.Out(), gemUsage)
+		return 1
+	case "install", "i":
+		return g.install(sys, build, dir, names, version, docs)
+	case "uninstall":
+		return g.uninstall(sys, build, userDir, names)
+	case "list", "query":
+		fmt.Fprint(sys.Out(), "\n*** LOCAL GEMS ***\n\n")
+		gems := map[string]string{}
+		for name, v := range gemDefaults {
+			gems[name] = v
+		}
+		for _, d := range []string{build.dir, userDir} {
+			for name, v := range langInstalled(sys, d+"/specifications", ".gemspec") {
+				gems[name] = v
+			}
+		}
+		for _, name := range langSorted(gems) {
+			if len(names) == 0 || strings.Contains(name, names[0]) {
+				fmt.Fprintf(sys.Out(), "%v (%v)\n", name, gems[name])
+			}
+		}
+		return 0
+	case "env":
+		fmt.Fprintf(sys.Out(), "RubyGems Environment:\n  - RUBYGEMS VERSION: %v\n  - RUBY VERSION: %v\n"+
+			"  - INSTALLATION DIRECTORY: %v\n  - USER INSTALLATION DIRECTORY: %v\n  - RUBY EXECUTABLE: /usr/bin/ruby\n"+
+			"  - EXECUTABLE DIRECTORY: /usr/local/bin\n  - GEM PATHS:\n     - %v\n     - %v\n",
+			build.version, build.ruby, build.dir, userDir, build.dir, userDir)
+		return 0
+	}
+	fmt.Fprintf(sys.Err(), "ERROR:  While executing gem ... (Gem::CommandLineError)\n    Unknown command %v\n", cmd)
+	return 1
+}
+
+// gemDenied is the error of gem writing to the directory of root
+func gemDenied(sys honeyos.Sys, dir string) int {
+	fmt.Fprintf(sys.Err(), "ERROR:  While executing gem ... (Gem::FilePermissionError)\n"+
+		"    You don't have write permissions for the %v directory.\n", dir)
+	return 1
+}
+
+func (g gem) install(sys honeyos.Sys, build gemBuild, dir string, names []string, version string, docs bool) int {
+	if len(names) == 0 {
+		fmt.Fprintln(sys.Err(), "ERROR:  While executing gem ... (Gem::CommandLineError)\n    Please specify at least one gem name (e.g. gem build GEMNAME)")
+		return 1
+	}
+	logPkgRequest(sys, "gem", "install", names)
+	versions, requested := map[string]string{}, map[string]bool{}
+	for _, name := range names {
+		requested[name] = true
+		if version != "" {
+			versions[strings.ToLower(name)] = version
+		}
+	}
+	installed := langInstalled(sys, dir+"/specifications", ".gemspec")
+	var pkgs []langPkg
+	for _, p := range langResolve(gemCatalog, names, versions) {
+		if _, ok := installed[p.name]; !ok || requested[p.name] {
+			pkgs = append(pkgs, p)
+		}
+	}
+	// Dependencies are installed before the gems needing them
+	var done []string
+	for i := len(pkgs) - 1; i >= 0; i-- {
+		p := pkgs[i]
+		if !langDownload(sys, p) {
+			return 1
+		}
+		if strings.HasPrefix(build.version, "3.") {
+			fmt.Fprintf(sys.Out(), "Fetching %v-%v.gem\n", p.name, p.version)
+		} else {
+			fmt.Fprintf(sys.Out(), "Fetching: %v-%v.gem (100%%)\n", p.name, p.version)
+		}
+		if dir == build.dir && !isRoot(sys) {
+			return gemDenied(sys, dir)
+		}
+		if p.native {
+			fmt.Fprintln(sys.Out(), "Building native extensions.  This could take a while...")
+			if !langBuild(sys, p) {
+				return 1
+			}
+		}
+		langWrite(sys, fmt.Sprintf("%v/specifications/%v-%v.gemspec", dir, p.name, p.version),
+			fmt.Sprintf("# -*- encoding: utf-8 -*-\nGem::Specification.new do |s|\n  s.name = %q\n  s.version = %q\nend\n",
+				p.name, p.version))
+		langMkdir(sys, fmt.Sprintf("%v/gems/%v-%v/lib", dir, p.name, p.version))
+		fmt.Fprintf(sys.Out(), "Successfully installed %v-%v\n", p.name, p.version)
+		done = append(done, p.name)
+	}
+	if docs {
+		for i := len(pkgs) - 1; i >= 0; i-- {
+			fmt.Fprintf(sys.Out(), "Parsing documentation for %[1]v-%[2]v\nInstalling ri documentation for %[1]v-%[2]v\n",
+				pkgs[i].name, pkgs[i].version)
+		}
+		secs := 1 + len(pkgs)/3
+		if !pkgSleep(sys, time.Duration(secs)*time.Second) {
+			return 1
+		}
+		fmt.Fprintf(sys.Out(), "Done installing documentation for %v after %v seconds\n", strings.Join(done, ", "), secs)
+	}
+	fmt.Fprintf(sys.Out(), "%v gem%v installed\n", len(requested), plural(len(requested)))
+	return 0
+}
+
+func (g gem) uninstall(sys honeyos.Sys, build gemBuild, userDir string, names []string) int {
+	logPkgRequest(sys, "gem", "uninstall", names)
+	for _, name := range names {
+		dir := build.dir
+		version, ok := langInstalled(sys, dir+"/specifications", ".gemspec")[name]
+		if !ok {
+			dir = userDir
+			version, ok = langInstalled(sys, dir+"/specifications", ".gemspec")[name]
+		}
+		if !ok {
+			fmt.Fprintf(sys.Err(), "ERROR:  While executing gem ... (Gem::InstallError)\n    gem %q is not installed\n", name)
+			return 1
+		}
+		if dir == build.dir && !isRoot(sys) {
+			return gemDenied(sys, dir)
+		}
+		sys.FSys().Remove(fmt.Sprintf("%v/specifications/%v-%v.gemspec", dir, name, version))
+		sys.FSys().RemoveAll(fmt.Sprintf("%v/gems/%v-%v", dir, name, version))
+		fmt.Fprintf(sys.Out(), "Successfully uninstalled %v-%v\n", name, version)
+	}
+	return 0
+}
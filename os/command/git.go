@@ -0,0 +1,940 @@
+package command
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	urllib "net/url"
+	"os"
+	pathlib "path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+)
+
+type git struct{}
+
+// gitSession is what a git command runs in, dir being the working directory
+// which -C changes
+type gitSession struct {
+	sys honeyos.Sys
+	dir string
+}
+
+// gitVersions are the versions of git shipped by the distributions
+var gitVersions = map[string]string{"ubuntu": "2.7.4", "debian": "2.11.0", "centos": "1.8.3.1", "alpine": "2.34.1"}
+
+// gitRepoCommands are the commands accepted in a repository, doing nothing
+var gitRepoCommands = map[string]bool{
+	"add": true, "commit": true, "push": true, "checkout": true, "log": true, "diff": true, "config": true,
+	"submodule": true, "reset": true, "merge": true, "rebase": true, "tag": true, "show": true, "stash": true,
+	"rm": true, "mv": true, "switch": true, "restore": true,
+}
+
+// errGitLimit is returned when the archive is bigger than downloads are
+// allowed to be
+var errGitLimit = errors.New("archive too large")
+
+func init() {
+	honeyos.RegisterCommand("git", git{})
+}
+
+func (git) GetHelp() string {
+	return `usage: git [--version] [--help] [-C <path>] [-c name=value]
+           [--exec-path[=<path>]] [--html-path] [--man-path] [--info-path]
+           [-p | --paginate | --no-pager] [--no-replace-objects] [--bare]
+           [--git-dir=<path>] [--work-tree=<path>] [--namespace=<name>]
+           <command> [<args>]
+
+These are common Git commands used in various situations:
+
+start a working area (see also: git help tutorial)
+   clone      Clone a repository into a new directory
+   init       Create an empty Git repository or reinitialize an existing one
+
+work on the current change (see also: git help everyday)
+   add        Add file contents to the index
+   mv         Move or rename a file, a directory, or a symlink
+   reset      Reset current HEAD to the specified state
+   rm         Remove files from the working tree and from the index
+
+examine the history and state (see also: git help revisions)
+   bisect     Use binary search to find the commit that introduced a bug
+   grep       Print lines matching a pattern
+   log        Show commit logs
+   show       Show various types of objects
+   status     Show the working tree status
+
+grow, mark and tweak your common history
+   branch     List, create, or delete branches
+   checkout   Switch branches or restore working tree files
+   commit     Record changes to the repository
+   diff       Show changes between commits, commit and working tree, etc
+   merge      Join two or more development histories together
+   rebase     Forward-port local commits to the updated upstream head
+   tag        Create, list, delete or verify a tag object signed with GPG
+
+collaborate (see also: git help workflows)
+   fetch      Download objects and refs from another repository
+   pull       Fetch from and integrate with another repository or a local branch
+   push       Update remote refs along with local branches
+
+'git help -a' and 'git help -g' list available subcommands and some
+concept guides. See 'git help <command>' or 'git help <concept>'
+to read about a specific subcommand or concept.
+`
+}
+
+func (git) Where() string {
+	return "/usr/bin/git"
+}
+
+func (g git) Exec(args []string, sys honeyos.Sys) int {
+	s := &gitSession{sys: sys, dir: sys.Getcwd()}
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		switch arg := args[0]; {
+		case arg == "--version":
+			fmt.Fprintln(sys.Out(), "git version "+gitVersion())
+			return 0
+		case arg == "--help" || arg == "-h":
+			fmt.Fprint(sys.Out(), g.GetHelp())
+			return 0
+		case arg == "-C" || arg == "-c":
+			if len(args) < 2 && arg == "-C" {
+				fmt.Fprint(sys.Err(), "error: no directory given for -C\n\n"+g.GetHelp())
+				return 129
+			} else if len(args) < 2 {
+				fmt.Fprint(sys.Err(), "error: -c expects a configuration string\n\n"+g.GetHelp())
+				return 129
+			}
+			if arg == "-C" {
+				s.dir = pathlib.Join(s.dir, args[1])
+				if pathlib.IsAbs(args[1]) {
+					s.dir = pathlib.Clean(args[1])
+				}
+				if fi, err := sys.FSys().Stat(s.dir); err != nil || !fi.IsDir() {
+					fmt.Fprintf(sys.Err(), "fatal: cannot change to '%v': No such file or directory\n", args[1])
+					return 128
+				}
+			}
+			args = args[1:]
+		}
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		fmt.Fprint(sys.Out(), g.GetHelp())
+		return 1
+	}
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "version":
+		fmt.Fprintln(sys.Out(), "git version "+gitVersion())
+		return 0
+	case "help":
+		fmt.Fprint(sys.Out(), g.GetHelp())
+		return 0
+	case "clone":
+		return s.clone(args)
+	case "init":
+		return s.init(args)
+	}
+	repo, ok := findGitRepo(sys, s.dir)
+	if _, known := gitRepoCommands[cmd]; !ok && (known || cmd == "pull" || cmd == "fetch" || cmd == "status" ||
+		cmd == "remote" || cmd == "branch") {
+		if gitVersionAtLeast(2, 10) {
+			fmt.Fprintln(sys.Err(), "fatal: not a git repository (or any of the parent directories): .git")
+		} else {
+			fmt.Fprintln(sys.Err(), "fatal: Not a git repository (or any of the parent directories): .git")
+		}
+		return 128
+	}
+	switch cmd {
+	case "pull", "fetch":
+		return s.pull(repo, cmd == "pull")
+	case "status":
+		return s.status(repo, args)
+	case "remote":
+		return s.remote(repo, args)
+	case "branch":
+		if b := repo.branch(sys); repo.head(sys) != "" {
+			if honeyos.IsTerminal(sys.Out()) {
+				b = "\x1b[32m" + b + "\x1b[m"
+			}
+			fmt.Fprintln(sys.Out(), "* "+b)
+		}
+		return 0
+	}
+	if gitRepoCommands[cmd] {
+		sys.Log().WithField("args", args).Infof("User ran git %v", cmd)
+		return 0
+	}
+	fmt.Fprintf(sys.Err(), "git: '%v' is not a git command. See 'git --help'.\n", cmd)
+	return 1
+}
+
+func gitVersion() string {
+	if v, ok := gitVersions[honeyos.Distro()]; ok {
+		return v
+	}
+	return gitVersions["ubuntu"]
+}
+
+// gitVersionAtLeast tells if the version of git is major.minor or later, as
+// some messages changed over time
+func gitVersionAtLeast(major, minor int) bool {
+	parts := strings.Split(gitVersion(), ".")
+	maj, _ := strconv.Atoi(parts[0])
+	min, _ := strconv.Atoi(parts[1])
+	return maj > major || maj == major && min >= minor
+}
+
+// gitHTTPURL returns the url to fetch the repository over HTTP, which git://
+// urls of the forges are served as well. ssh is true for the urls cloned
+// through ssh, like git@github.com:user/repo.git
+func gitHTTPURL(url string) (httpURL, host string, ssh bool) {
+	switch {
+	case strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://"):
+		httpURL = url
+	case strings.HasPrefix(url, "git://"):
+		httpURL = "https://" + strings.TrimPrefix(url, "git://")
+	case strings.HasPrefix(url, "ssh://"):
+		u, err := urllib.Parse(url)
+		if err != nil {
+			return "", "", false
+		}
+		return "", u.Hostname(), true
+	default:
+		// scp-like syntax, user@host:path
+		if i := strings.Index(url, ":"); i > 0 && !strings.Contains(url[:i], "/") {
+			host = url[:i]
+			if at := strings.LastIndex(host, "@"); at >= 0 {
+				host = host[at+1:]
+			}
+			return "", host, true
+		}
+		return "", "", false
+	}
+	u, err := urllib.Parse(httpURL)
+	if err != nil || u.Hostname() == "" {
+		return "", "", false
+	}
+	return strings.TrimRight(httpURL, "/"), u.Hostname(), false
+}
+
+// gitCloneDir is the directory git names after the repository
+func gitCloneDir(url string) string {
+	name := strings.TrimRight(url, "/")
+	if i := strings.LastIndexAny(name, "/:"); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.TrimSuffix(name, ".git")
+	if name == "" {
+		return "repo"
+	}
+	return name
+}
+
+// unreachable prints why the remote can't be reached: ssh has no keys, and
+// without downloads the host doesn't resolve
+func (s *gitSession) unreachable(url, httpURL, host string, ssh bool) bool {
+	sys := s.sys
+	switch {
+	case ssh && !viper.GetBool("server.allowDownload"):
+		fmt.Fprintf(sys.Err(), "ssh: Could not resolve hostname %v: Temporary failure in name resolution\n", host)
+	case ssh:
+		fmt.Fprintln(sys.Err(), "Permission denied (publickey).")
+	case httpURL == "":
+		fmt.Fprintf(sys.Err(), "fatal: repository '%v' does not exist\n", url)
+		return true
+	case !viper.GetBool("server.allowDownload"):
+		fmt.Fprintf(sys.Err(), "fatal: unable to access '%v/': Could not resolve host: %v\n", httpURL, host)
+		return true
+	default:
+		return false
+	}
+	fmt.Fprintln(sys.Err(), "fatal: Could not read from remote repository.\n\n"+
+		"Please make sure you have the correct access rights\nand the repository exists.")
+	return true
+}
+
+func (s *gitSession) clone(args []string) int {
+	sys := s.sys
+	var operands []string
+	var branch string
+	quiet, shallow, noCheckout := false, false, false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		value := ""
+		if eq := strings.Index(arg, "="); strings.HasPrefix(arg, "--") && eq > 0 {
+			arg, value = arg[:eq], arg[eq+1:]
+		}
+		switch arg {
+		case "-q", "--quiet":
+			quiet = true
+		case "-n", "--no-checkout":
+			noCheckout = true
+		case "-b", "--branch", "--depth", "-o", "--origin", "--reference", "-c", "--config", "-j", "--jobs":
+			if value == "" {
+				if i+1 == len(args) {
+					fmt.Fprintf(sys.Err(), "error: switch `%v' requires a value\n", strings.TrimLeft(arg, "-"))
+					return 129
+				}
+				i++
+				value = args[i]
+			}
+			switch arg {
+			case "-b", "--branch":
+				branch = value
+			case "--depth":
+				shallow = true
+			}
+		case "-v", "--verbose", "--progress", "--recursive", "--recurse-submodules", "--single-branch",
+			"--no-single-branch", "--shallow-submodules", "--no-tags", "-l", "--local", "--no-hardlinks", "-s",
+			"--shared":
+		case "--":
+			operands = append(operands, args[i+1:]...)
+			i = len(args)
+		default:
+			if strings.HasPrefix(arg, "-") {
+				fmt.Fprintf(sys.Err(), "error: unknown option `%v'\n", strings.TrimLeft(arg, "-"))
+				fmt.Fprintln(sys.Err(), "usage: git clone [<options>] [--] <repo> [<dir>]")
+				return 129
+			}
+			operands = append(operands, arg)
+		}
+	}
+	if len(operands) == 0 {
+		fmt.Fprintln(sys.Err(), "fatal: You must specify a repository to clone.\n\n"+
+			"usage: git clone [<options>] [--] <repo> [<dir>]")
+		return 129
+	}
+	url := operands[0]
+	dir := gitCloneDir(url)
+	if len(operands) > 1 {
+		dir = operands[1]
+	}
+	p := pathlib.Join(s.dir, dir)
+	if pathlib.IsAbs(dir) {
+		p = pathlib.Clean(dir)
+	}
+	if list, err := afero.ReadDir(sys.FSys(), p); err == nil && len(list) > 0 {
+		fmt.Fprintf(sys.Err(), "fatal: destination path '%v' already exists and is not an empty directory.\n", dir)
+		return 128
+	} else if fi, err := sys.FSys().Stat(p); err == nil && !fi.IsDir() {
+		fmt.Fprintf(sys.Err(), "fatal: destination path '%v' already exists and is not an empty directory.\n", dir)
+		return 128
+	}
+	sys.Log().WithField("url", url).WithField("path", p).Infof("User cloning git repository %v", url)
+	if !quiet {
+		fmt.Fprintf(sys.Err(), "Cloning into '%v'...\n", dir)
+	}
+	httpURL, host, ssh := gitHTTPURL(url)
+	if s.unreachable(url, httpURL, host, ssh) {
+		return 128
+	}
+
+	var commit string
+	var files []gitFile
+	var size int64
+	if viper.GetBool("server.mirrorGitRepos") {
+		refs, head, status, err := gitRefs(sys, httpURL)
+		if !s.remoteError(httpURL, host, status, err) {
+			return 128
+		}
+		if branch == "" {
+			branch = head
+		}
+		if commit = refs[branch]; commit == "" && len(refs) > 0 {
+			fmt.Fprintf(sys.Err(), "warning: Could not find remote branch %v to clone.\n", branch)
+			fmt.Fprintf(sys.Err(), "fatal: Remote branch %v not found in upstream origin\n", branch)
+			return 128
+		}
+		if commit != "" {
+			if files, size, err = gitArchive(sys, httpURL, host, commit); err != nil {
+				s.transferError(err)
+				return 128
+			}
+		}
+	}
+	if branch == "" {
+		branch = "master"
+	}
+
+	if err := sys.FSys().MkdirAll(p, 0777&^sys.Umask()); err != nil {
+		fmt.Fprintf(sys.Err(), "fatal: could not create work tree dir '%v': Permission denied\n", dir)
+		return 128
+	}
+	repo := initRepo(sys, p, branch)
+	repo.setRemote(sys, url, branch, commit)
+	if commit == "" {
+		if !quiet {
+			fmt.Fprintln(sys.Err(), "warning: You appear to have cloned an empty repository.")
+		}
+		if !gitVersionAtLeast(2, 10) && !quiet {
+			fmt.Fprintln(sys.Err(), "Checking connectivity... done.")
+		}
+		return 0
+	}
+	if !quiet && honeyos.IsTerminal(sys.Err()) {
+		if !s.transfer(url, files, size, shallow) {
+			return 130
+		}
+	}
+	if noCheckout {
+		return 0
+	}
+	if err := repo.checkout(sys, files); err != nil {
+		fmt.Fprintln(sys.Err(), "error: unable to write file: Permission denied\nfatal: unable to checkout working tree")
+		return 128
+	}
+	return 0
+}
+
+// remoteError prints the error of asking for the refs, returning false if
+// there is one
+func (s *gitSession) remoteError(url, host string, status int, err error) bool {
+	switch {
+	case err != nil:
+		scheme := "443"
+		if strings.HasPrefix(url, "http://") {
+			scheme = "80"
+		}
+		fmt.Fprintf(s.sys.Err(), "fatal: unable to access '%v/': Failed to connect to %v port %v: Connection refused\n",
+			url, host, scheme)
+	case status == http.StatusNotFound || status == http.StatusUnauthorized || status == http.StatusForbidden:
+		fmt.Fprintln(s.sys.Err(), "remote: Repository not found.")
+		fmt.Fprintf(s.sys.Err(), "fatal: repository '%v/' not found\n", url)
+	case status != http.StatusOK:
+		fmt.Fprintf(s.sys.Err(), "fatal: unable to access '%v/': The requested URL returned error: %v\n", url, status)
+	default:
+		return true
+	}
+	return false
+}
+
+// transferError prints how the transfer of the pack broke off
+func (s *gitSession) transferError(err error) {
+	if err == errGitLimit {
+		fmt.Fprintln(s.sys.Err(), "error: RPC failed; curl 18 transfer closed with outstanding read data remaining")
+	}
+	fmt.Fprintln(s.sys.Err(), "fatal: The remote end hung up unexpectedly\nfatal: early EOF\nfatal: index-pack failed")
+}
+
+// transfer shows the progress of receiving the objects of the files, as the
+// server counts them and the client receives. The history is made up unless
+// cloned shallow
+func (s *gitSession) transfer(url string, files []gitFile, size int64, shallow bool) bool {
+	sys := s.sys
+	dirs := map[string]bool{}
+	for _, f := range files {
+		for d := pathlib.Dir(f.path); d != "."; d = pathlib.Dir(d) {
+			dirs[d] = true
+		}
+	}
+	total, deltas := len(files)+len(dirs)+2, 0
+	if !shallow {
+		commits := 20 + int(fnvString(url)%2000)
+		total += commits * 3
+		deltas = total / 3
+		size *= 3
+	}
+	reused := total / 10
+	fmt.Fprintf(sys.Err(), "remote: Enumerating objects: %v, done.\n", total)
+	fmt.Fprintf(sys.Err(), "remote: Counting objects: 100%% (%v/%v), done.\n", total, total)
+	fmt.Fprintf(sys.Err(), "remote: Compressing objects: 100%% (%v/%v), done.\n", total*2/3, total*2/3)
+	fmt.Fprintf(sys.Err(), "remote: Total %v (delta %v), reused %v (delta %v), pack-reused %v\n",
+		total, deltas, reused, deltas/10, total-reused)
+	start := time.Now()
+	// Received at about 4 MiB/s, taking a few seconds at most
+	d := time.Duration(size) * time.Second / (4 << 20)
+	if d > 3*time.Second {
+		d = 3 * time.Second
+	}
+	for i := 0; i < 4; i++ {
+		fmt.Fprintf(sys.Err(), "\rReceiving objects: %3d%% (%v/%v)", i*25, total*i/4, total)
+		if !pkgSleep(sys, d/4) {
+			return false
+		}
+	}
+	rate := float64(size) / (time.Since(start).Seconds() + 0.001)
+	fmt.Fprintf(sys.Err(), "\rReceiving objects: 100%% (%v/%v), %v | %v/s, done.\n", total, total,
+		gitBytes(float64(size)), gitBytes(rate))
+	if deltas > 0 {
+		fmt.Fprintf(sys.Err(), "Resolving deltas: 100%% (%v/%v), done.\n", deltas, deltas)
+	}
+	if !gitVersionAtLeast(2, 10) {
+		fmt.Fprintln(sys.Err(), "Checking connectivity... done.")
+	}
+	return true
+}
+
+// gitBytes formats the size like git does in progress, e.g. 1.23 MiB
+func gitBytes(n float64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.2f GiB", n/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.2f MiB", n/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.2f KiB", n/(1<<10))
+	}
+	return fmt.Sprintf("%v bytes", int(n))
+}
+
+// gitGet fetches the url the way git does, with body read up to the limit of
+// downloads
+func gitGet(sys honeyos.Sys, url string) (body []byte, status int, err error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("User-Agent", "git/"+gitVersion())
+	client := &http.Client{Timeout: 60 * time.Second}
+	resp, err := client.Do(req.WithContext(sys.Context()))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.StatusCode, nil
+	}
+	r := io.Reader(resp.Body)
+	limit := int64(viper.GetSizeInBytes("server.downloadFileSizeLimit"))
+	if limit > 0 {
+		r = io.LimitReader(resp.Body, limit+1)
+	}
+	body, err = ioutil.ReadAll(r)
+	if limit > 0 && int64(len(body)) > limit {
+		return nil, resp.StatusCode, errGitLimit
+	}
+	return body, resp.StatusCode, err
+}
+
+// gitRefs asks the server of the repository for its branches over smart
+// HTTP, as git does first. head is the default branch
+func gitRefs(sys honeyos.Sys, url string) (refs map[string]string, head string, status int, err error) {
+	body, status, err := gitGet(sys, url+"/info/refs?service=git-upload-pack")
+	if err != nil || status != http.StatusOK {
+		return nil, "", status, err
+	}
+	refs = map[string]string{}
+	// The body is pkt-lines, each with 4 hex digits of its length first
+	for len(body) >= 4 {
+		n, err := strconv.ParseUint(string(body[:4]), 16, 16)
+		if err != nil || int(n) > len(body) {
+			break
+		}
+		if n < 4 {
+			body = body[4:]
+			continue
+		}
+		line := strings.TrimSuffix(string(body[4:n]), "\n")
+		body = body[n:]
+		caps := ""
+		if i := strings.IndexByte(line, 0); i >= 0 {
+			line, caps = line[:i], line[i+1:]
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 || len(fields[0]) != 40 {
+			continue
+		}
+		if name := strings.TrimPrefix(fields[1], "refs/heads/"); name != fields[1] {
+			refs[name] = fields[0]
+		}
+		for _, c := range strings.Fields(caps) {
+			if strings.HasPrefix(c, "symref=HEAD:refs/heads/") {
+				head = strings.TrimPrefix(c, "symref=HEAD:refs/heads/")
+			}
+		}
+	}
+	if head == "" {
+		head = "master"
+	}
+	return refs, head, status, nil
+}
+
+// gitArchive downloads the files of the commit as the archive the forge
+// serves, keeping it in the artifact directory. Repositories elsewhere are
+// cloned without files, as the pack protocol is not spoken here
+func gitArchive(sys honeyos.Sys, url, host, commit string) ([]gitFile, int64, error) {
+	u, _ := urllib.Parse(url)
+	repoPath := strings.TrimSuffix(strings.TrimRight(u.Path, "/"), ".git")
+	var archive string
+	switch host {
+	case "github.com":
+		archive = "https://github.com" + repoPath + "/archive/" + commit + ".tar.gz"
+	case "gitlab.com":
+		archive = "https://gitlab.com" + repoPath + "/-/archive/" + commit + "/" + pathlib.Base(repoPath) + "-" +
+			commit + ".tar.gz"
+	case "bitbucket.org":
+		archive = "https://bitbucket.org" + repoPath + "/get/" + commit + ".tar.gz"
+	default:
+		return nil, 0, nil
+	}
+	data, status, err := gitGet(sys, archive)
+	if err == errGitLimit {
+		return nil, 0, err
+	}
+	if err != nil || status != http.StatusOK {
+		sys.Log().WithError(err).WithField("url", archive).Info("Cannot download archive of git repository")
+		return nil, 0, nil
+	}
+	if _, err := honeyos.SaveArtifact(sys, data, url+"@"+commit); err != nil {
+		sys.Log().WithError(err).Error("Cannot save git repository to artifact directory")
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, int64(len(data)), nil
+	}
+	var files []gitFile
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		// Members are under the directory named after the repository
+		name := hdr.Name
+		if i := strings.IndexByte(name, '/'); i >= 0 {
+			name = name[i+1:]
+		}
+		name = strings.TrimPrefix(pathlib.Clean("/"+name), "/")
+		if name == "" {
+			continue
+		}
+		switch hdr.Typeflag {
+		case tar.TypeReg, tar.TypeRegA:
+			content, err := ioutil.ReadAll(tr)
+			if err != nil {
+				break
+			}
+			files = append(files, gitFile{path: name, exec: hdr.Mode&0100 != 0, data: content})
+		case tar.TypeSymlink:
+			files = append(files, gitFile{path: name, link: hdr.Linkname})
+		}
+	}
+	return files, int64(len(data)), nil
+}
+
+func (s *gitSession) pull(repo gitRepo, merge bool) int {
+	sys := s.sys
+	url := repo.remoteURL(sys)
+	if url == "" {
+		fmt.Fprintln(sys.Err(), "fatal: No remote repository specified.  Please, specify either a URL or a\n"+
+			"remote name from which new revisions should be fetched.")
+		return 1
+	}
+	sys.Log().WithField("url", url).Infof("User pulling git repository %v", url)
+	httpURL, host, ssh := gitHTTPURL(url)
+	if s.unreachable(url, httpURL, host, ssh) {
+		return 1
+	}
+	branch, old := repo.branch(sys), repo.head(sys)
+	commit := old
+	var files []gitFile
+	if viper.GetBool("server.mirrorGitRepos") {
+		refs, _, status, err := gitRefs(sys, httpURL)
+		if !s.remoteError(httpURL, host, status, err) {
+			return 1
+		}
+		if refs[branch] != "" && refs[branch] != old {
+			commit = refs[branch]
+			if files, _, err = gitArchive(sys, httpURL, host, commit); err != nil {
+				s.transferError(err)
+				return 1
+			}
+		}
+	}
+	if commit == old || files == nil {
+		if merge {
+			if gitVersionAtLeast(2, 17) {
+				fmt.Fprintln(sys.Out(), "Already up to date.")
+			} else {
+				fmt.Fprintln(sys.Out(), "Already up-to-date.")
+			}
+		}
+		return 0
+	}
+	if old == "" {
+		// The repository was cloned empty, the branch is new
+		fmt.Fprintf(sys.Err(), "From %v\n * [new branch]      %-10v -> origin/%v\n", url, branch, branch)
+		if merge {
+			repo.setHead(sys, branch, commit, "pull: initial pull")
+			repo.checkout(sys, files)
+		}
+		return 0
+	}
+	fmt.Fprintf(sys.Err(), "From %v\n   %v..%v  %-10v -> origin/%v\n", url, old[:7], commit[:7], branch, branch)
+	if !merge {
+		return 0
+	}
+	stats := s.diffstat(repo, files)
+	repo.setHead(sys, branch, commit, "pull: Fast-forward")
+	if err := repo.checkout(sys, files); err != nil {
+		fmt.Fprintln(sys.Err(), "error: unable to write file: Permission denied")
+		return 1
+	}
+	fmt.Fprintf(sys.Out(), "Updating %v..%v\nFast-forward\n%v", old[:7], commit[:7], stats)
+	return 0
+}
+
+// diffstat compares the files checked out with the new ones, removing those
+// no longer in the commit, and returns the summary as git pull prints it
+func (s *gitSession) diffstat(repo gitRepo, files []gitFile) string {
+	sys := s.sys
+	type change struct {
+		path     string
+		ins, del int
+	}
+	var changes []change
+	var created, removed []string
+	seen := map[string]bool{}
+	for _, f := range files {
+		seen[f.path] = true
+		content := f.data
+		if f.link != "" {
+			content = []byte(f.link)
+		}
+		p := pathlib.Join(repo.root, f.path)
+		old, err := readFile(sys, p)
+		if fi, lerr := honeyos.Lstat(sys.FSys(), p); lerr == nil && fi.Mode()&os.ModeSymlink != 0 {
+			target, _ := honeyos.Readlink(sys.FSys(), p)
+			old, err = []byte(target), nil
+		}
+		if err != nil {
+			created = append(created, f.path)
+		}
+		if ins, del := gitLineDiff(old, content); ins+del > 0 {
+			changes = append(changes, change{f.path, ins, del})
+		}
+	}
+	for _, e := range repo.readIndex(sys) {
+		if !seen[e.path] {
+			p := pathlib.Join(repo.root, e.path)
+			old, _ := readFile(sys, p)
+			sys.FSys().Remove(p)
+			removed = append(removed, e.path)
+			changes = append(changes, change{e.path, 0, strings.Count(string(old), "\n")})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].path < changes[j].path })
+	var b strings.Builder
+	width, most, ins, del := 0, 0, 0, 0
+	for _, c := range changes {
+		width = intMax(width, len(c.path))
+		most = intMax(most, c.ins+c.del)
+	}
+	for _, c := range changes {
+		plus, minus := c.ins, c.del
+		if most > 40 {
+			plus, minus = c.ins*40/most, c.del*40/most
+		}
+		fmt.Fprintf(&b, " %-*v | %*v %v%v\n", width, c.path, len(strconv.Itoa(most)), c.ins+c.del,
+			strings.Repeat("+", plus), strings.Repeat("-", minus))
+		ins += c.ins
+		del += c.del
+	}
+	summary := fmt.Sprintf(" %v file%v changed", len(changes), plural(len(changes)))
+	if ins > 0 || del == 0 {
+		summary += fmt.Sprintf(", %v insertion%v(+)", ins, plural(ins))
+	}
+	if del > 0 {
+		summary += fmt.Sprintf(", %v deletion%v(-)", del, plural(del))
+	}
+	b.WriteString(summary + "\n")
+	for _, name := range created {
+		fmt.Fprintf(&b, " create mode 100644 %v\n", name)
+	}
+	for _, name := range removed {
+		fmt.Fprintf(&b, " delete mode 100644 %v\n", name)
+	}
+	return b.String()
+}
+
+// gitLineDiff counts the lines added and removed, by the lines that are in
+// one and not the other
+func gitLineDiff(old, new []byte) (ins, del int) {
+	count := map[string]int{}
+	scan := bufio.NewScanner(bytes.NewReader(old))
+	for scan.Scan() {
+		count[scan.Text()]++
+	}
+	scan = bufio.NewScanner(bytes.NewReader(new))
+	for scan.Scan() {
+		if count[scan.Text()] > 0 {
+			count[scan.Text()]--
+		} else {
+			ins++
+		}
+	}
+	for _, n := range count {
+		del += n
+	}
+	return
+}
+
+func (s *gitSession) status(repo gitRepo, args []string) int {
+	sys := s.sys
+	short := false
+	for _, a := range args {
+		switch a {
+		case "-s", "--short", "--porcelain":
+			short = true
+		}
+	}
+	modified, deleted, untracked := repo.changes(sys)
+	rel := func(name string) string {
+		p := relativePath(s.dir, pathlib.Join(repo.root, name))
+		if strings.HasSuffix(name, "/") {
+			p += "/"
+		}
+		return p
+	}
+	out := sys.Out()
+	if short {
+		var lines []string
+		for _, name := range modified {
+			lines = append(lines, " M "+rel(name))
+		}
+		for _, name := range deleted {
+			lines = append(lines, " D "+rel(name))
+		}
+		sort.Strings(lines)
+		for _, name := range untracked {
+			lines = append(lines, "?? "+rel(name))
+		}
+		for _, line := range lines {
+			fmt.Fprintln(out, line)
+		}
+		return 0
+	}
+	red := func(s string) string { return s }
+	if honeyos.IsTerminal(out) {
+		red = func(s string) string { return "\x1b[31m" + s + "\x1b[m" }
+	}
+	branch, head := repo.branch(sys), repo.head(sys)
+	fmt.Fprintf(out, "On branch %v\n", branch)
+	switch {
+	case head == "" && gitVersionAtLeast(2, 17):
+		fmt.Fprint(out, "\nNo commits yet\n\n")
+	case head == "":
+		fmt.Fprint(out, "\nInitial commit\n\n")
+	case repo.remoteURL(sys) != "" && gitVersionAtLeast(2, 15):
+		fmt.Fprintf(out, "Your branch is up to date with 'origin/%v'.\n", branch)
+	case repo.remoteURL(sys) != "":
+		fmt.Fprintf(out, "Your branch is up-to-date with 'origin/%v'.\n", branch)
+	}
+	if len(modified)+len(deleted) > 0 {
+		add := "add"
+		if len(deleted) > 0 {
+			add = "add/rm"
+		}
+		fmt.Fprintf(out, "Changes not staged for commit:\n  (use \"git %v <file>...\" to update what will be committed)\n", add)
+		if gitVersionAtLeast(2, 23) {
+			fmt.Fprint(out, "  (use \"git restore <file>...\" to discard changes in working directory)\n\n")
+		} else {
+			fmt.Fprint(out, "  (use \"git checkout -- <file>...\" to discard changes in working directory)\n\n")
+		}
+		var lines []string
+		for _, name := range modified {
+			lines = append(lines, rel(name)+"\x00modified:   ")
+		}
+		for _, name := range deleted {
+			lines = append(lines, rel(name)+"\x00deleted:    ")
+		}
+		sort.Strings(lines)
+		for _, line := range lines {
+			parts := strings.SplitN(line, "\x00", 2)
+			fmt.Fprintf(out, "\t%v\n", red(parts[1]+parts[0]))
+		}
+		fmt.Fprintln(out)
+	}
+	if len(untracked) > 0 {
+		fmt.Fprint(out, "Untracked files:\n  (use \"git add <file>...\" to include in what will be committed)\n\n")
+		for _, name := range untracked {
+			fmt.Fprintf(out, "\t%v\n", red(rel(name)))
+		}
+		fmt.Fprintln(out)
+	}
+	switch {
+	case len(modified)+len(deleted) > 0:
+		fmt.Fprintln(out, "no changes added to commit (use \"git add\" and/or \"git commit -a\")")
+	case len(untracked) > 0:
+		fmt.Fprintln(out, "nothing added to commit but untracked files present (use \"git add\" to track)")
+	case head == "":
+		fmt.Fprintln(out, "nothing to commit (create/copy files and use \"git add\" to track)")
+	case gitVersionAtLeast(2, 9):
+		fmt.Fprintln(out, "nothing to commit, working tree clean")
+	default:
+		fmt.Fprintln(out, "nothing to commit, working directory clean")
+	}
+	return 0
+}
+
+func (s *gitSession) init(args []string) int {
+	sys := s.sys
+	quiet, dir := false, s.dir
+	for _, a := range args {
+		switch {
+		case a == "-q" || a == "--quiet":
+			quiet = true
+		case !strings.HasPrefix(a, "-"):
+			dir = pathlib.Join(s.dir, a)
+			if pathlib.IsAbs(a) {
+				dir = pathlib.Clean(a)
+			}
+		}
+	}
+	if err := sys.FSys().MkdirAll(dir, 0777&^sys.Umask()); err != nil {
+		fmt.Fprintf(sys.Err(), "fatal: cannot mkdir %v: Permission denied\n", dir)
+		return 128
+	}
+	msg := "Initialized empty"
+	if fi, err := sys.FSys().Stat(pathlib.Join(dir, ".git")); err == nil && fi.IsDir() {
+		msg = "Reinitialized existing"
+	} else {
+		initRepo(sys, dir, "master")
+	}
+	sys.Log().WithField("path", dir).Info("User created git repository")
+	if !quiet {
+		fmt.Fprintf(sys.Out(), "%v Git repository in %v/\n", msg, pathlib.Join(dir, ".git"))
+	}
+	return 0
+}
+
+func (s *gitSession) remote(repo gitRepo, args []string) int {
+	sys := s.sys
+	url := repo.remoteURL(sys)
+	switch {
+	case len(args) == 0:
+		if url != "" {
+			fmt.Fprintln(sys.Out(), "origin")
+		}
+	case args[0] == "-v" || args[0] == "--verbose":
+		if url != "" {
+			fmt.Fprintf(sys.Out(), "origin\t%v (fetch)\norigin\t%v (push)\n", url, url)
+		}
+	case args[0] == "add" && len(args) == 3:
+		if args[1] == "origin" && url != "" {
+			fmt.Fprintln(sys.Err(), "fatal: remote origin already exists.")
+			return 128
+		}
+		sys.Log().WithField("url", args[2]).Info("User added git remote")
+		if args[1] == "origin" {
+			repo.write(sys, "config", repo.read(sys, "config")+"\n[remote \"origin\"]\n\turl = "+args[2]+
+				"\n\tfetch = +refs/heads/*:refs/remotes/origin/*\n")
+		}
+	}
+	return 0
+}
@@ -0,0 +1,310 @@
+package command
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"os"
+	pathlib "path"
+	"sort"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/afero"
+)
+
+// gitRepo is a repository in the virtual filesystem, kept in .git of its
+// working tree the way git does, so looking into it finds what is expected
+type gitRepo struct {
+	root string
+}
+
+// gitEntry is a file tracked in the index of the repository
+type gitEntry struct {
+	path string
+	// mode is 0100644, 0100755 or 0120000 for symbolic link
+	mode  uint32
+	sum   [20]byte
+	size  uint32
+	mtime time.Time
+}
+
+// gitFile is a file checked out of a commit, link is the target if it is a
+// symbolic link
+type gitFile struct {
+	path string
+	exec bool
+	data []byte
+	link string
+}
+
+// findGitRepo looks for the repository dir is in, from dir up to /
+func findGitRepo(sys honeyos.Sys, dir string) (gitRepo, bool) {
+	for {
+		if fi, err := sys.FSys().Stat(pathlib.Join(dir, ".git")); err == nil && fi.IsDir() {
+			return gitRepo{dir}, true
+		}
+		if dir == "/" {
+			return gitRepo{}, false
+		}
+		dir = pathlib.Dir(dir)
+	}
+}
+
+func (r gitRepo) path(name string) string {
+	return pathlib.Join(r.root, ".git", name)
+}
+
+// read returns the content of the file in .git, trimmed
+func (r gitRepo) read(sys honeyos.Sys, name string) string {
+	data, err := readFile(sys, r.path(name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func (r gitRepo) write(sys honeyos.Sys, name, content string) error {
+	p := r.path(name)
+	sys.FSys().MkdirAll(pathlib.Dir(p), 0755&^sys.Umask())
+	return afero.WriteFile(sys.FSys(), p, []byte(content), 0644&^sys.Umask())
+}
+
+// branch is the branch checked out, from HEAD
+func (r gitRepo) branch(sys honeyos.Sys) string {
+	return strings.TrimPrefix(r.read(sys, "HEAD"), "ref: refs/heads/")
+}
+
+// head is the commit of the branch checked out, empty before the first
+// commit
+func (r gitRepo) head(sys honeyos.Sys) string {
+	return r.read(sys, "refs/heads/"+r.branch(sys))
+}
+
+// remoteURL is the url of origin in the config of the repository
+func (r gitRepo) remoteURL(sys honeyos.Sys) string {
+	section := ""
+	for _, line := range strings.Split(r.read(sys, "config"), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			section = line
+			continue
+		}
+		if kv := strings.SplitN(line, "=", 2); section == `[remote "origin"]` && len(kv) == 2 &&
+			strings.TrimSpace(kv[0]) == "url" {
+			return strings.TrimSpace(kv[1])
+		}
+	}
+	return ""
+}
+
+// initRepo writes the skeleton of .git like git init does
+func initRepo(sys honeyos.Sys, root, branch string) gitRepo {
+	r := gitRepo{root}
+	fs := sys.FSys()
+	for _, dir := range []string{"branches", "hooks", "info", "objects/info", "objects/pack", "refs/heads", "refs/tags"} {
+		fs.MkdirAll(r.path(dir), 0755&^sys.Umask())
+	}
+	r.write(sys, "HEAD", "ref: refs/heads/"+branch+"\n")
+	r.write(sys, "description", "Unnamed repository; edit this file 'description' to name the repository.\n")
+	r.write(sys, "config", "[core]\n\trepositoryformatversion = 0\n\tfilemode = true\n\tbare = false\n"+
+		"\tlogallrefupdates = true\n")
+	r.write(sys, "info/exclude", "# git ls-files --others --exclude-from=.git/info/exclude\n"+
+		"# Lines that start with '#' are comments.\n# For a project mostly in C, the following would be a good set of\n"+
+		"# exclude patterns (uncomment them if you want to use them):\n# *.[oa]\n# *~\n")
+	return r
+}
+
+// setRemote records the url of origin and its branch, which is checked out
+// at the commit
+func (r gitRepo) setRemote(sys honeyos.Sys, url, branch, commit string) {
+	config := r.read(sys, "config") + "\n" +
+		"[remote \"origin\"]\n\turl = " + url + "\n\tfetch = +refs/heads/*:refs/remotes/origin/*\n" +
+		"[branch \"" + branch + "\"]\n\tremote = origin\n\tmerge = refs/heads/" + branch + "\n"
+	r.write(sys, "config", config)
+	r.setHead(sys, branch, commit, "clone: from "+url)
+}
+
+// setHead moves the branch and origin to the commit, with the reflog
+func (r gitRepo) setHead(sys honeyos.Sys, branch, commit, reason string) {
+	if commit == "" {
+		return
+	}
+	old := r.head(sys)
+	if old == "" {
+		old = strings.Repeat("0", 40)
+	}
+	r.write(sys, "refs/heads/"+branch, commit+"\n")
+	r.write(sys, "refs/remotes/origin/HEAD", "ref: refs/remotes/origin/"+branch+"\n")
+	r.write(sys, "packed-refs", "# pack-refs with: peeled fully-peeled \n"+commit+" refs/remotes/origin/"+branch+"\n")
+	user := honeyos.GetUserByID(sys.CurrentUser()).Name
+	line := fmt.Sprintf("%v %v %v <%v@%v> %v +0000\t%v\n", old, commit, user, user, sys.Hostname(), time.Now().Unix(), reason)
+	for _, log := range []string{"logs/HEAD", "logs/refs/heads/" + branch} {
+		content := line
+		if prev := r.read(sys, log); prev != "" {
+			content = prev + "\n" + line
+		}
+		r.write(sys, log, content)
+	}
+}
+
+// gitBlob is the object id of the content, as git hash-object gives it
+func gitBlob(data []byte) [20]byte {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(data))
+	h.Write(data)
+	var sum [20]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// checkout writes the files into the working tree and the index
+func (r gitRepo) checkout(sys honeyos.Sys, files []gitFile) error {
+	fs := sys.FSys()
+	var entries []gitEntry
+	for _, f := range files {
+		p := pathlib.Join(r.root, f.path)
+		fs.MkdirAll(pathlib.Dir(p), 0777&^sys.Umask())
+		e := gitEntry{path: f.path, mode: 0100644}
+		if f.link != "" {
+			fs.Remove(p)
+			if err := honeyos.Symlink(fs, f.link, p); err != nil {
+				return err
+			}
+			e.mode, e.sum, e.size = 0120000, gitBlob([]byte(f.link)), uint32(len(f.link))
+		} else {
+			mode := 0666 &^ sys.Umask()
+			if f.exec {
+				mode, e.mode = 0777&^sys.Umask(), 0100755
+			}
+			if err := afero.WriteFile(fs, p, f.data, mode); err != nil {
+				return err
+			}
+			fs.Chmod(p, mode)
+			e.sum, e.size = gitBlob(f.data), uint32(len(f.data))
+		}
+		e.mtime = time.Now()
+		entries = append(entries, e)
+	}
+	return r.writeIndex(sys, entries)
+}
+
+// writeIndex writes the index in version 2 of the format git uses
+func (r gitRepo) writeIndex(sys honeyos.Sys, entries []gitEntry) error {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].path < entries[j].path })
+	var b bytes.Buffer
+	b.WriteString("DIRC")
+	binary.Write(&b, binary.BigEndian, []uint32{2, uint32(len(entries))})
+	for _, e := range entries {
+		t := uint32(e.mtime.Unix())
+		binary.Write(&b, binary.BigEndian, []uint32{t, 0, t, 0, 0, 0, e.mode,
+			uint32(sys.CurrentUser()), uint32(sys.CurrentGroup()), e.size})
+		b.Write(e.sum[:])
+		flags := len(e.path)
+		if flags > 0xfff {
+			flags = 0xfff
+		}
+		binary.Write(&b, binary.BigEndian, uint16(flags))
+		b.WriteString(e.path)
+		// Entries are padded with NUL to multiple of 8 bytes
+		n := 62 + len(e.path)
+		b.Write(make([]byte, 8-n%8))
+	}
+	sum := sha1.Sum(b.Bytes())
+	b.Write(sum[:])
+	return afero.WriteFile(sys.FSys(), r.path("index"), b.Bytes(), 0644&^sys.Umask())
+}
+
+// readIndex reads the entries of the index written by writeIndex
+func (r gitRepo) readIndex(sys honeyos.Sys) []gitEntry {
+	data, err := readFile(sys, r.path("index"))
+	if err != nil || len(data) < 12 || string(data[:4]) != "DIRC" {
+		return nil
+	}
+	count := binary.BigEndian.Uint32(data[8:12])
+	var entries []gitEntry
+	pos := 12
+	for i := uint32(0); i < count && pos+62 <= len(data); i++ {
+		e := gitEntry{
+			mtime: time.Unix(int64(binary.BigEndian.Uint32(data[pos+8:])), 0),
+			mode:  binary.BigEndian.Uint32(data[pos+24:]),
+			size:  binary.BigEndian.Uint32(data[pos+36:]),
+		}
+		copy(e.sum[:], data[pos+40:pos+60])
+		end := bytes.IndexByte(data[pos+62:], 0)
+		if end < 0 {
+			break
+		}
+		e.path = string(data[pos+62 : pos+62+end])
+		entries = append(entries, e)
+		n := 62 + end
+		pos += n + 8 - n%8
+	}
+	return entries
+}
+
+// changes compares the working tree with the index, giving the tracked
+// files modified or deleted and the untracked ones, with directories of
+// only untracked files as dir/
+func (r gitRepo) changes(sys honeyos.Sys) (modified, deleted, untracked []string) {
+	fs := sys.FSys()
+	entries := r.readIndex(sys)
+	tracked := map[string]bool{}
+	trackedDirs := map[string]bool{}
+	for _, e := range entries {
+		tracked[e.path] = true
+		for d := pathlib.Dir(e.path); d != "."; d = pathlib.Dir(d) {
+			trackedDirs[d] = true
+		}
+		p := pathlib.Join(r.root, e.path)
+		fi, err := honeyos.Lstat(fs, p)
+		if err != nil {
+			deleted = append(deleted, e.path)
+			continue
+		}
+		var sum [20]byte
+		if fi.Mode()&os.ModeSymlink != 0 {
+			target, _ := honeyos.Readlink(fs, p)
+			sum = gitBlob([]byte(target))
+		} else if data, err := readFile(sys, p); err == nil {
+			sum = gitBlob(data)
+		}
+		if sum != e.sum || e.mode == 0100755 && fi.Mode()&0100 == 0 || e.mode == 0100644 && fi.Mode()&0100 != 0 {
+			modified = append(modified, e.path)
+		}
+	}
+	var walk func(rel string)
+	walk = func(rel string) {
+		list, _ := afero.ReadDir(fs, pathlib.Join(r.root, rel))
+		for _, fi := range list {
+			name := pathlib.Join(rel, fi.Name())
+			switch {
+			case rel == "" && fi.Name() == ".git":
+			case fi.IsDir() && trackedDirs[name]:
+				walk(name)
+			case fi.IsDir():
+				if r.hasFiles(sys, name) {
+					untracked = append(untracked, name+"/")
+				}
+			case !tracked[name]:
+				untracked = append(untracked, name)
+			}
+		}
+	}
+	walk("")
+	return
+}
+
+// hasFiles tells if there are files in the directory, as git does not show
+// empty directories
+func (r gitRepo) hasFiles(sys honeyos.Sys, rel string) bool {
+	list, _ := afero.ReadDir(sys.FSys(), pathlib.Join(r.root, rel))
+	for _, fi := range list {
+		if !fi.IsDir() || r.hasFiles(sys, pathlib.Join(rel, fi.Name())) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,529 @@
+package command
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	pathlib "path"
+	"path/filepath"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/mkishere/sshsyrup/util/terminal"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/spf13/pflag"
+)
+
+// grep searches the files or stdin for lines matching the patterns. Syntax
+// is the default regexp syntax, E for egrep and F for fgrep
+type grep struct {
+	syntax byte
+}
+
+type grepOptions struct {
+	patterns             []string
+	patternFiles         []string
+	extended, fixed      bool
+	perl, basic          bool
+	ignoreCase, invert   bool
+	word, line           bool
+	count, quiet         bool
+	filesWith            bool
+	filesWithout         bool
+	lineNumber           bool
+	withName, noName     bool
+	onlyMatching         bool
+	noMessages           bool
+	recursive, deref     bool
+	after, before, ctx   int
+	maxCount             int
+	color                string
+	include, exclude     []string
+	excludeDir           []string
+	text, skipBinary     bool
+	nullData, byteOffset bool
+}
+
+// grepper keeps the compiled patterns and the state of the output
+type grepper struct {
+	sys     honeyos.Sys
+	opt     grepOptions
+	re      *regexp.Regexp
+	colored bool
+	// name tells if the file name is printed before the lines
+	name   bool
+	status int
+}
+
+const grepUsage = "Usage: grep [OPTION]... PATTERN [FILE]...\nTry 'grep --help' for more information."
+
+func init() {
+	honeyos.RegisterCommand("grep", grep{'G'})
+	honeyos.RegisterCommand("egrep", grep{'E'})
+	honeyos.RegisterCommand("fgrep", grep{'F'})
+}
+
+func (grep) GetHelp() string {
+	return grepUsage + "\n"
+}
+
+func (g grep) Where() string {
+	switch g.syntax {
+	case 'E':
+		return "/bin/egrep"
+	case 'F':
+		return "/bin/fgrep"
+	}
+	return "/bin/grep"
+}
+
+func (g grep) Exec(args []string, sys honeyos.Sys) int {
+	var opt grepOptions
+	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	flag.StringArrayVarP(&opt.patterns, "regexp", "e", nil, "use PATTERN for matching")
+	flag.StringArrayVarP(&opt.patternFiles, "file", "f", nil, "obtain PATTERN from FILE")
+	flag.BoolVarP(&opt.extended, "extended-regexp", "E", g.syntax == 'E', "PATTERN is an extended regular expression")
+	flag.BoolVarP(&opt.fixed, "fixed-strings", "F", g.syntax == 'F', "PATTERN is a set of newline-separated strings")
+	flag.BoolVarP(&opt.basic, "basic-regexp", "G", false, "PATTERN is a basic regular expression")
+	flag.BoolVarP(&opt.perl, "perl-regexp", "P", false, "PATTERN is a Perl regular expression")
+	flag.BoolVarP(&opt.ignoreCase, "ignore-case", "i", false, "ignore case distinctions")
+	flag.BoolP("no-ignore-case", "y", false, "")
+	flag.BoolVarP(&opt.invert, "invert-match", "v", false, "select non-matching lines")
+	flag.BoolVarP(&opt.word, "word-regexp", "w", false, "force PATTERN to match only whole words")
+	flag.BoolVarP(&opt.line, "line-regexp", "x", false, "force PATTERN to match only whole lines")
+	flag.BoolVarP(&opt.count, "count", "c", false, "print only a count of matching lines per FILE")
+	flag.BoolVarP(&opt.quiet, "quiet", "q", false, "suppress all normal output")
+	flag.BoolVar(&opt.quiet, "silent", false, "suppress all normal output")
+	flag.BoolVarP(&opt.filesWith, "files-with-matches", "l", false, "print only names of FILEs with selected lines")
+	flag.BoolVarP(&opt.filesWithout, "files-without-match", "L", false, "print only names of FILEs with no selected lines")
+	flag.BoolVarP(&opt.lineNumber, "line-number", "n", false, "print line number with output lines")
+	flag.BoolVarP(&opt.withName, "with-filename", "H", false, "print the file name for each match")
+	flag.BoolVarP(&opt.noName, "no-filename", "h", false, "suppress the file name prefix on output")
+	flag.BoolVarP(&opt.onlyMatching, "only-matching", "o", false, "show only the part of a line matching PATTERN")
+	flag.BoolVarP(&opt.noMessages, "no-messages", "s", false, "suppress error messages")
+	flag.BoolVarP(&opt.recursive, "recursive", "r", false, "")
+	flag.BoolVarP(&opt.deref, "dereference-recursive", "R", false, "likewise, but follow all symlinks")
+	flag.IntVarP(&opt.after, "after-context", "A", 0, "print NUM lines of trailing context")
+	flag.IntVarP(&opt.before, "before-context", "B", 0, "print NUM lines of leading context")
+	flag.IntVarP(&opt.ctx, "context", "C", 0, "print NUM lines of output context")
+	flag.IntVarP(&opt.maxCount, "max-count", "m", -1, "stop after NUM selected lines")
+	flag.StringVar(&opt.color, "color", "never", "use markers to highlight the matching strings")
+	flag.Lookup("color").NoOptDefVal = "auto"
+	flag.StringVar(&opt.color, "colour", "never", "")
+	flag.Lookup("colour").NoOptDefVal = "auto"
+	flag.StringArrayVar(&opt.include, "include", nil, "search only files that match FILE_PATTERN")
+	flag.StringArrayVar(&opt.exclude, "exclude", nil, "skip files and directories matching FILE_PATTERN")
+	flag.StringArrayVar(&opt.excludeDir, "exclude-dir", nil, "skip directories that match PATTERN")
+	flag.BoolVarP(&opt.text, "text", "a", false, "equivalent to --binary-files=text")
+	flag.BoolVarP(&opt.skipBinary, "binary-without-match", "I", false, "equivalent to --binary-files=without-match")
+	flag.BoolVarP(&opt.nullData, "null-data", "z", false, "a data line ends in 0 byte, not newline")
+	flag.BoolVarP(&opt.byteOffset, "byte-offset", "b", false, "print the byte offset with output lines")
+	flag.BoolP("line-buffered", "U", false, "")
+	flag.BoolP("null", "Z", false, "")
+	// -NUM is the same as --context=NUM
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if len(arg) > 1 && arg[0] == '-' && strings.Trim(arg[1:], "0123456789") == "" {
+			args[i] = "--context=" + arg[1:]
+		}
+	}
+	if err := flag.Parse(args); err != nil {
+		msg := strings.Replace(err.Error(), "unknown shorthand flag: ", "invalid option -- ", 1)
+		if i := strings.Index(msg, " in -"); i >= 0 {
+			msg = msg[:i]
+		}
+		fmt.Fprintf(sys.Err(), "grep: %v\n%v\n", msg, grepUsage)
+		return 2
+	}
+	files := flag.Args()
+	for _, name := range opt.patternFiles {
+		data, err := afero.ReadFile(sys.FSys(), absPath(sys, name))
+		if err != nil {
+			fmt.Fprintf(sys.Err(), "grep: %v: No such file or directory\n", name)
+			return 2
+		}
+		opt.patterns = append(opt.patterns, strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")...)
+	}
+	if len(opt.patterns) == 0 && len(opt.patternFiles) == 0 {
+		if len(files) == 0 {
+			fmt.Fprintln(sys.Err(), grepUsage)
+			return 2
+		}
+		opt.patterns, files = strings.Split(files[0], "\n"), files[1:]
+	}
+	if opt.ctx > 0 {
+		if !flag.Changed("after-context") {
+			opt.after = opt.ctx
+		}
+		if !flag.Changed("before-context") {
+			opt.before = opt.ctx
+		}
+	}
+	opt.recursive = opt.recursive || opt.deref
+
+	re, err := grepCompile(opt)
+	if err != nil {
+		fmt.Fprintf(sys.Err(), "grep: %v\n", err)
+		return 2
+	}
+	gr := &grepper{sys: sys, opt: opt, re: re, status: 1}
+	switch opt.color {
+	case "always", "yes", "force":
+		gr.colored = true
+	case "auto", "tty", "if-tty":
+		gr.colored = honeyos.IsTerminal(sys.Out()) && terminal.ColorTerm(honeyos.Getenv(sys, "TERM"))
+	case "never", "no", "none":
+	default:
+		fmt.Fprintf(sys.Err(), "grep: invalid argument ‘%v’ for ‘--color’\n", opt.color)
+		fmt.Fprintln(sys.Err(), "Valid arguments are:\n  - ‘always’, ‘yes’, ‘force’\n  - ‘never’, ‘no’, ‘none’\n  - ‘auto’, ‘tty’, ‘if-tty’")
+		fmt.Fprintln(sys.Err(), grepUsage)
+		return 2
+	}
+	sys.Log().WithFields(log.Fields{"patterns": opt.patterns, "files": files}).
+		Infof("User searching for %v with grep", strings.Join(opt.patterns, " "))
+
+	recursiveDefault := false
+	if len(files) == 0 {
+		if opt.recursive {
+			// Names are shown without ./ when searching the current
+			// directory by default
+			files, recursiveDefault = []string{"."}, true
+		} else {
+			files = []string{"-"}
+		}
+	}
+	gr.name = (len(files) > 1 || opt.recursive || opt.withName) && !opt.noName
+	for _, name := range files {
+		if gr.quit() {
+			break
+		}
+		if name == "-" {
+			gr.search(sys.In(), "(standard input)")
+			continue
+		}
+		p := absPath(sys, name)
+		fi, err := sys.FSys().Stat(p)
+		if err != nil {
+			gr.errorf("%v: No such file or directory", name)
+			continue
+		}
+		if !fi.IsDir() {
+			gr.searchFile(p, name)
+			continue
+		}
+		if !opt.recursive {
+			gr.errorf("%v: Is a directory", name)
+			continue
+		}
+		afero.Walk(sys.FSys(), p, func(sub string, fi os.FileInfo, err error) error {
+			if gr.quit() {
+				return io.EOF
+			}
+			shown := name + strings.TrimPrefix(sub, p)
+			if strings.HasSuffix(name, "/") && sub != p {
+				shown = name + strings.TrimPrefix(sub, p+"/")
+			}
+			if recursiveDefault {
+				shown = strings.TrimPrefix(strings.TrimPrefix(sub, p), "/")
+			}
+			if err != nil {
+				gr.errorf("%v: Permission denied", shown)
+				return nil
+			}
+			if fi.IsDir() {
+				if sub != p && grepGlob(opt.excludeDir, fi.Name()) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if fi.Mode()&os.ModeSymlink != 0 && !opt.deref {
+				return nil
+			}
+			gr.searchFile(sub, shown)
+			return nil
+		})
+	}
+	if gr.status == 1 && opt.quiet {
+		return 1
+	}
+	return gr.status
+}
+
+func (gr *grepper) errorf(format string, a ...interface{}) {
+	if !gr.opt.noMessages {
+		fmt.Fprintf(gr.sys.Err(), "grep: "+format+"\n", a...)
+	}
+	gr.status = 2
+}
+
+// quit tells if grep can stop searching, which -q does on the first match
+func (gr *grepper) quit() bool {
+	return gr.opt.quiet && gr.status == 0 || gr.sys.Context().Err() != nil
+}
+
+func (gr *grepper) searchFile(p, shown string) {
+	base := pathlib.Base(p)
+	if len(gr.opt.include) > 0 && !grepGlob(gr.opt.include, base) || grepGlob(gr.opt.exclude, base) {
+		return
+	}
+	data, err := readFile(gr.sys, p)
+	if err != nil {
+		gr.errorf("%v: Permission denied", shown)
+		return
+	}
+	gr.search(bytes.NewReader(data), shown)
+}
+
+// paint highlights the text if output is colored, with the default
+// GREP_COLORS of matches, file names, line numbers and separators
+func (gr *grepper) paint(part byte, text string) string {
+	if !gr.colored || text == "" {
+		return text
+	}
+	sgr := map[byte]string{'m': "01;31", 'f': "35", 'l': "32", 's': "36"}[part]
+	return "\x1b[" + sgr + "m\x1b[K" + text + "\x1b[m\x1b[K"
+}
+
+// search prints the selected lines of the input with context as options
+// say. Binary files only tell if they match
+func (gr *grepper) search(r io.Reader, name string) {
+	opt := gr.opt
+	br := bufio.NewReader(r)
+	delim := byte('\n')
+	if opt.nullData {
+		delim = 0
+	}
+	type line struct {
+		no     int
+		text   string
+		offset int
+	}
+	var before []line
+	count, lastPrinted, afterLeft, offset := 0, 0, 0, 0
+	binary := false
+	out := gr.sys.Out()
+	emit := func(l line, sep string) {
+		if lastPrinted > 0 && l.no > lastPrinted+1 && (opt.before > 0 || opt.after > 0) {
+			fmt.Fprintln(out, gr.paint('s', "--"))
+		}
+		lastPrinted = l.no
+		prefix := ""
+		if gr.name {
+			prefix += gr.paint('f', name) + gr.paint('s', sep)
+		}
+		if opt.lineNumber {
+			prefix += gr.paint('l', fmt.Sprint(l.no)) + gr.paint('s', sep)
+		}
+		if opt.byteOffset {
+			prefix += fmt.Sprint(l.offset) + gr.paint('s', sep)
+		}
+		matches := [][]int(nil)
+		if !opt.invert {
+			matches = gr.re.FindAllStringIndex(l.text, -1)
+		}
+		if opt.onlyMatching {
+			if sep == ":" {
+				for _, m := range matches {
+					if m[1] > m[0] {
+						fmt.Fprint(out, prefix+gr.paint('m', l.text[m[0]:m[1]])+string(delim))
+					}
+				}
+			}
+			return
+		}
+		text := l.text
+		if gr.colored && sep == ":" {
+			var b strings.Builder
+			last := 0
+			for _, m := range matches {
+				b.WriteString(text[last:m[0]] + gr.paint('m', text[m[0]:m[1]]))
+				last = m[1]
+			}
+			text = b.String() + text[last:]
+		}
+		fmt.Fprint(out, prefix+text+string(delim))
+	}
+	for no := 1; ; no++ {
+		s, err := br.ReadString(delim)
+		if s == "" && err != nil {
+			break
+		}
+		l := line{no, strings.TrimSuffix(s, string(delim)), offset}
+		offset += len(s)
+		if !opt.text && !opt.nullData && strings.IndexByte(s, 0) >= 0 {
+			if opt.skipBinary {
+				return
+			}
+			binary = true
+		}
+		if gr.re.MatchString(l.text) != opt.invert {
+			count++
+			gr.status = 0
+			if opt.quiet || opt.filesWith {
+				break
+			}
+			if !opt.count && !opt.filesWithout && !binary {
+				for _, b := range before {
+					if b.no > lastPrinted {
+						emit(b, "-")
+					}
+				}
+				before = nil
+				emit(l, ":")
+				afterLeft = opt.after
+			}
+			if count == opt.maxCount {
+				break
+			}
+			continue
+		}
+		if afterLeft > 0 && !binary {
+			afterLeft--
+			emit(l, "-")
+			continue
+		}
+		if opt.before > 0 {
+			before = append(before, l)
+			if len(before) > opt.before {
+				before = before[1:]
+			}
+		}
+	}
+	switch {
+	case opt.quiet:
+	case opt.count:
+		if gr.name {
+			fmt.Fprint(out, gr.paint('f', name)+gr.paint('s', ":"))
+		}
+		fmt.Fprintln(out, count)
+	case opt.filesWith && count > 0, opt.filesWithout && count == 0:
+		fmt.Fprintln(out, gr.paint('f', name))
+	case binary && count > 0:
+		fmt.Fprintf(out, "Binary file %v matches\n", name)
+	}
+}
+
+// grepCompile converts the patterns into single regexp of Go, which is
+// close enough to POSIX regexp for what is usually searched
+func grepCompile(opt grepOptions) (*regexp.Regexp, error) {
+	var alts []string
+	for _, p := range opt.patterns {
+		switch {
+		case opt.fixed:
+			p = regexp.QuoteMeta(p)
+		case opt.perl:
+		default:
+			p = grepConvert(p, opt.extended && !opt.basic)
+		}
+		alts = append(alts, "(?:"+p+")")
+	}
+	expr := strings.Join(alts, "|")
+	switch {
+	case opt.line:
+		expr = "^(?:" + expr + ")$"
+	case opt.word:
+		expr = `\b(?:` + expr + `)\b`
+	}
+	if opt.ignoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err == nil {
+		return re, nil
+	}
+	msg := "Invalid regular expression"
+	if e, ok := err.(*syntax.Error); ok {
+		switch e.Code {
+		case syntax.ErrMissingParen:
+			msg = "Unmatched ( or \\("
+		case syntax.ErrUnexpectedParen:
+			msg = "Unmatched ) or \\)"
+		case syntax.ErrMissingBracket:
+			msg = "Unmatched [ or [^"
+		case syntax.ErrInvalidEscape:
+			if regexp.MustCompile(`\\[1-9]`).MatchString(e.Expr) {
+				msg = "Invalid back reference"
+			}
+		}
+	}
+	return nil, fmt.Errorf("%v", msg)
+}
+
+// grepConvert converts POSIX basic or extended regexp to Go syntax. In basic
+// regexp, the operators are escaped to be special
+func grepConvert(p string, extended bool) string {
+	var b strings.Builder
+	special := "(){}|+?"
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch {
+		case c == '[':
+			// Backslash is literal in bracket expressions
+			j := i + 1
+			if j < len(p) && p[j] == '^' {
+				j++
+			}
+			if j < len(p) && p[j] == ']' {
+				j++
+			}
+			for j < len(p) && p[j] != ']' {
+				if p[j] == '[' && j+1 < len(p) && (p[j+1] == ':' || p[j+1] == '.' || p[j+1] == '=') {
+					if end := strings.Index(p[j+2:], string(p[j+1])+"]"); end >= 0 {
+						j += end + 4
+						continue
+					}
+				}
+				j++
+			}
+			if j >= len(p) {
+				b.WriteString(p[i:])
+				return b.String()
+			}
+			b.WriteString(strings.Replace(p[i:j+1], `\`, `\\`, -1))
+			i = j
+		case c == '\\' && i+1 < len(p):
+			i++
+			n := p[i]
+			switch {
+			case n == '<' || n == '>':
+				b.WriteString(`\b`)
+			case strings.IndexByte(special, n) >= 0 && !extended:
+				b.WriteByte(n)
+			case strings.IndexByte("wWsSbB", n) >= 0 || n >= '1' && n <= '9':
+				b.WriteString(`\` + string(n))
+			default:
+				b.WriteString(regexp.QuoteMeta(string(n)))
+			}
+		case strings.IndexByte(special, c) >= 0 && !extended:
+			b.WriteString(`\` + string(c))
+		case c == '*' && !extended && (i == 0 || p[i-1] == '^' || strings.HasSuffix(p[:i], `\(`) || strings.HasSuffix(p[:i], `\|`)):
+			// Leading star is literal in basic regexp
+			b.WriteString(`\*`)
+		case c == '{' && extended && !regexp.MustCompile(`^\{[0-9]+(,[0-9]*)?\}`).MatchString(p[i:]):
+			b.WriteString(`\{`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// grepGlob tells if the name matches any of the patterns of --include and
+// --exclude
+func grepGlob(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := pathlib.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mkishere/sshsyrup/os"
+)
+
+type groups struct{}
+
+func init() {
+	os.RegisterCommand("groups", groups{})
+}
+
+func (groups) GetHelp() string {
+	return `Usage: groups [OPTION]... [USERNAME]...
+Print group memberships for each USERNAME or, if no USERNAME is specified, for
+the current process (which may differ if the groups database has changed).
+      --help     display this help and exit
+      --version  output version information and exit
+`
+}
+
+func (g groups) Exec(args []string, sys os.Sys) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "--help":
+			fmt.Fprint(sys.Out(), g.GetHelp())
+			return 0
+		case "--version":
+			fmt.Fprintln(sys.Out(), "groups (GNU coreutils) 8.32")
+			return 0
+		}
+	}
+	if len(args) == 0 {
+		fmt.Fprintln(sys.Out(), g.names(os.GetUserByID(sys.CurrentUser())))
+		return 0
+	}
+	status := 0
+	for _, name := range args {
+		if _, exists := os.IsUserExist(name); !exists {
+			fmt.Fprintf(sys.Err(), "groups: '%v': no such user\n", name)
+			status = 1
+			continue
+		}
+		fmt.Fprintf(sys.Out(), "%v : %v\n", name, g.names(os.GetUser(name)))
+	}
+	return status
+}
+
+// names lists the groups of user by name, or GID if the group has no name
+func (groups) names(u os.User) string {
+	var names []string
+	for _, grp := range os.UserGroups(u) {
+		if grp.Name == "" {
+			names = append(names, strconv.Itoa(grp.GID))
+		} else {
+			names = append(names, grp.Name)
+		}
+	}
+	return strings.Join(names, " ")
+}
+
+func (groups) Where() string {
+	return "/usr/bin/groups"
+}
@@ -0,0 +1,238 @@
+package command
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/afero"
+)
+
+// gzipCmd compresses and decompresses files, as gzip, gunzip and zcat
+type gzipCmd struct {
+	name string
+}
+
+// gzipOptions are the options of gzip
+type gzipOptions struct {
+	decompress, stdout bool
+	keep, force        bool
+	verbose, test      bool
+	level              int
+}
+
+func init() {
+	honeyos.RegisterCommand("gzip", gzipCmd{name: "gzip"})
+	honeyos.RegisterCommand("gunzip", gzipCmd{name: "gunzip"})
+	honeyos.RegisterCommand("zcat", gzipCmd{name: "zcat"})
+}
+
+func (gzipCmd) GetHelp() string {
+	return ""
+}
+
+func (g gzipCmd) Where() string {
+	return "/bin/" + g.name
+}
+
+func (g gzipCmd) Exec(args []string, sys honeyos.Sys) int {
+	opt := gzipOptions{decompress: g.name != "gzip", stdout: g.name == "zcat", level: gzip.DefaultCompression}
+	long := map[string]byte{"decompress": 'd', "uncompress": 'd', "stdout": 'c', "to-stdout": 'c', "keep": 'k',
+		"force": 'f', "verbose": 'v', "test": 't', "fast": '1', "best": '9', "quiet": 'q'}
+	var files []string
+	for i, arg := range args {
+		if arg == "--" {
+			files = append(files, args[i+1:]...)
+			break
+		}
+		flags := arg
+		switch {
+		case strings.HasPrefix(arg, "--"):
+			c, ok := long[arg[2:]]
+			if !ok {
+				fmt.Fprintf(sys.Err(), "gzip: unrecognized option '%v'\nTry `gzip --help' for more information.\n", arg)
+				return 1
+			}
+			flags = "-" + string(c)
+		case !strings.HasPrefix(arg, "-") || arg == "-":
+			files = append(files, arg)
+			continue
+		}
+		for _, c := range flags[1:] {
+			switch {
+			case c == 'd':
+				opt.decompress = true
+			case c == 'c':
+				opt.stdout = true
+			case c == 'k':
+				opt.keep = true
+			case c == 'f':
+				opt.force = true
+			case c == 'v':
+				opt.verbose = true
+			case c == 't':
+				opt.test, opt.decompress = true, true
+			case c >= '1' && c <= '9':
+				opt.level = int(c - '0')
+			case c == 'q', c == 'n', c == 'N', c == 'r':
+			default:
+				fmt.Fprintf(sys.Err(), "gzip: invalid option -- '%c'\nTry `gzip --help' for more information.\n", c)
+				return 1
+			}
+		}
+	}
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	res := 0
+	for _, f := range files {
+		if f == "-" {
+			if !g.stream(opt, sys) {
+				res = 1
+			}
+			continue
+		}
+		if !g.file(f, opt, sys) {
+			res = 1
+		}
+	}
+	return res
+}
+
+// stream compresses or decompresses the input to output
+func (gzipCmd) stream(opt gzipOptions, sys honeyos.Sys) bool {
+	if !opt.decompress && !opt.force && honeyos.IsTerminal(sys.Out()) {
+		fmt.Fprintln(sys.Err(), "gzip: compressed data not written to a terminal. Use -f to force compression.\nFor help, type: gzip -h")
+		return false
+	}
+	data, _ := ioutil.ReadAll(sys.In())
+	if !opt.decompress {
+		sys.Out().Write(gzipData(data, opt.level))
+		return true
+	}
+	plain, err := gunzipData(data)
+	if err != nil {
+		fmt.Fprintln(sys.Err(), "\ngzip: stdin: not in gzip format")
+		return false
+	}
+	honeyos.SaveArtifact(sys, plain, "gunzip stdin")
+	if !opt.test {
+		sys.Out().Write(plain)
+	}
+	return true
+}
+
+// file compresses or decompresses the file, to a file of the name with .gz
+// added or removed unless it goes to output
+func (gzipCmd) file(name string, opt gzipOptions, sys honeyos.Sys) bool {
+	src := absPath(sys, name)
+	fi, err := sys.FSys().Stat(src)
+	switch {
+	case err != nil && !opt.decompress:
+		fmt.Fprintf(sys.Err(), "gzip: %v: No such file or directory\n", name)
+		return false
+	case err != nil:
+		// gunzip tries the name with .gz added as well
+		if fi, err = sys.FSys().Stat(src + ".gz"); err != nil {
+			fmt.Fprintf(sys.Err(), "gzip: %v.gz: No such file or directory\n", name)
+			return false
+		}
+		name, src = name+".gz", src+".gz"
+	}
+	if fi.IsDir() {
+		fmt.Fprintf(sys.Err(), "gzip: %v is a directory -- ignored\n", name)
+		return false
+	}
+	dst := ""
+	switch {
+	case !opt.decompress && strings.HasSuffix(name, ".gz"):
+		if !opt.stdout {
+			fmt.Fprintf(sys.Err(), "gzip: %v already has .gz suffix -- unchanged\n", name)
+			return false
+		}
+	case !opt.decompress:
+		dst = src + ".gz"
+	case strings.HasSuffix(name, ".gz"), strings.HasSuffix(name, ".z"):
+		dst = src[:strings.LastIndex(src, ".")]
+	case strings.HasSuffix(name, ".tgz"):
+		dst = strings.TrimSuffix(src, ".tgz") + ".tar"
+	case !opt.stdout && !opt.test:
+		fmt.Fprintf(sys.Err(), "gzip: %v: unknown suffix -- ignored\n", name)
+		return false
+	}
+	data, err := afero.ReadFile(sys.FSys(), src)
+	if err != nil {
+		fmt.Fprintf(sys.Err(), "gzip: %v: Permission denied\n", name)
+		return false
+	}
+	out := gzipData(data, opt.level)
+	if opt.decompress {
+		if out, err = gunzipData(data); err != nil {
+			fmt.Fprintf(sys.Err(), "gzip: %v: not in gzip format\n", name)
+			return false
+		}
+		honeyos.SaveArtifact(sys, out, "gunzip "+src)
+	}
+	switch {
+	case opt.test:
+		return true
+	case opt.stdout:
+		sys.Out().Write(out)
+		return true
+	}
+	if _, err := sys.FSys().Stat(dst); err == nil && !opt.force {
+		fmt.Fprintf(sys.Err(), "gzip: %v already exists; not overwritten\n", path.Base(dst))
+		return false
+	}
+	if err := afero.WriteFile(sys.FSys(), dst, out, fi.Mode().Perm()); err != nil {
+		fmt.Fprintf(sys.Err(), "gzip: %v: Permission denied\n", path.Base(dst))
+		return false
+	}
+	sys.FSys().Chmod(dst, fi.Mode().Perm())
+	sys.FSys().Chtimes(dst, fi.ModTime(), fi.ModTime())
+	if opt.verbose {
+		ratio := 0.0
+		if a, b := len(data), len(out); opt.decompress && b > 0 {
+			ratio = 100 * (1 - float64(a)/float64(b))
+		} else if !opt.decompress && a > 0 {
+			ratio = 100 * (1 - float64(b)/float64(a))
+		}
+		fmt.Fprintf(sys.Err(), "%v:\t%5.1f%% -- replaced with %v\n", name, ratio, path.Base(dst))
+	}
+	if !opt.keep {
+		sys.FSys().Remove(src)
+	}
+	return true
+}
+
+func gzipData(data []byte, level int) []byte {
+	var b bytes.Buffer
+	w, err := gzip.NewWriterLevel(&b, level)
+	if err != nil {
+		w = gzip.NewWriter(&b)
+	}
+	// Made on Unix, as the header of gzip says
+	w.OS = 3
+	w.Write(data)
+	w.Close()
+	return b.Bytes()
+}
+
+// gunzipData decompresses all members of the data, like gzip does for files
+// concatenated
+func gunzipData(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	plain, err := ioutil.ReadAll(r)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	return plain, nil
+}
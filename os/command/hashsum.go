@@ -0,0 +1,242 @@
+package command
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/pflag"
+)
+
+// hashsum prints or checks the digests of the files, as md5sum, sha1sum,
+// sha256sum and sha512sum
+type hashsum struct {
+	name string
+	// algo is the name of the digest in the BSD style lines of --tag
+	algo string
+	hash func() hash.Hash
+}
+
+func init() {
+	honeyos.RegisterCommand("md5sum", hashsum{"md5sum", "MD5", md5.New})
+	honeyos.RegisterCommand("sha1sum", hashsum{"sha1sum", "SHA1", sha1.New})
+	honeyos.RegisterCommand("sha256sum", hashsum{"sha256sum", "SHA256", sha256.New})
+	honeyos.RegisterCommand("sha512sum", hashsum{"sha512sum", "SHA512", sha512.New})
+}
+
+func (h hashsum) GetHelp() string {
+	return "Usage: " + h.name + " [OPTION]... [FILE]...\nPrint or check " + h.algo + " checksums.\n\n" +
+		"With no FILE, or when FILE is -, read standard input.\n\n" +
+		"  -b, --binary         read in binary mode\n" +
+		"  -c, --check          read " + h.algo + " sums from the FILEs and check them\n" +
+		"      --tag            create a BSD-style checksum\n" +
+		"  -t, --text           read in text mode (default)\n\n" +
+		"The following four options are useful only when verifying checksums:\n" +
+		"      --ignore-missing  don't fail or report status for missing files\n" +
+		"      --quiet          don't print OK for each successfully verified file\n" +
+		"      --status         don't output anything, status code shows success\n" +
+		"      --strict         exit non-zero for improperly formatted checksum lines\n" +
+		"  -w, --warn           warn about improperly formatted checksum lines\n\n" +
+		"      --help     display this help and exit\n" +
+		"      --version  output version information and exit\n"
+}
+
+func (h hashsum) Where() string {
+	return "/usr/bin/" + h.name
+}
+
+// read reads the file, or stdin for -, reporting errors like the coreutils
+// do
+func (h hashsum) read(sys honeyos.Sys, name string) ([]byte, bool) {
+	if name == "-" {
+		data, _ := ioutil.ReadAll(sys.In())
+		return data, true
+	}
+	p := absPath(sys, name)
+	if fi, err := sys.FSys().Stat(p); err == nil && fi.IsDir() {
+		fmt.Fprintf(sys.Err(), "%v: %v: Is a directory\n", h.name, name)
+		return nil, false
+	}
+	data, err := readFile(sys, p)
+	if err != nil {
+		reason := "No such file or directory"
+		if os.IsPermission(err) {
+			reason = "Permission denied"
+		}
+		fmt.Fprintf(sys.Err(), "%v: %v: %v\n", h.name, name, reason)
+		return nil, false
+	}
+	return data, true
+}
+
+// sum returns the digest of the data in hex
+func (h hashsum) sum(data []byte) string {
+	d := h.hash()
+	d.Write(data)
+	return hex.EncodeToString(d.Sum(nil))
+}
+
+func (h hashsum) Exec(args []string, sys honeyos.Sys) int {
+	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	binary := flag.BoolP("binary", "b", false, "")
+	flag.BoolP("text", "t", false, "")
+	check := flag.BoolP("check", "c", false, "")
+	tag := flag.Bool("tag", false, "")
+	ignoreMissing := flag.Bool("ignore-missing", false, "")
+	quiet := flag.Bool("quiet", false, "")
+	status := flag.Bool("status", false, "")
+	strict := flag.Bool("strict", false, "")
+	warn := flag.BoolP("warn", "w", false, "")
+	help := flag.Bool("help", false, "")
+	if err := flag.Parse(args); err != nil {
+		msg := strings.Replace(err.Error(), "unknown shorthand flag: ", "invalid option -- ", 1)
+		if i := strings.Index(msg, " in -"); i >= 0 {
+			msg = msg[:i]
+		}
+		fmt.Fprintf(sys.Err(), "%v: %v\nTry '%v --help' for more information.\n", h.name, msg, h.name)
+		return 1
+	}
+	if *help {
+		fmt.Fprint(sys.Out(), h.GetHelp())
+		return 0
+	}
+	if !*check && (*ignoreMissing || *quiet || *status || *strict || *warn) {
+		fmt.Fprintf(sys.Err(), "%v: the --quiet, --status, --strict, --warn and --ignore-missing options are meaningful only when verifying checksums\nTry '%v --help' for more information.\n",
+			h.name, h.name)
+		return 1
+	}
+	files := flag.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	if *check {
+		return h.check(sys, files, *ignoreMissing, *quiet, *status, *strict, *warn)
+	}
+	res := 0
+	for _, name := range files {
+		data, ok := h.read(sys, name)
+		if !ok {
+			res = 1
+			continue
+		}
+		switch {
+		case *tag:
+			fmt.Fprintf(sys.Out(), "%v (%v) = %v\n", h.algo, name, h.sum(data))
+		case *binary:
+			fmt.Fprintf(sys.Out(), "%v *%v\n", h.sum(data), name)
+		default:
+			fmt.Fprintf(sys.Out(), "%v  %v\n", h.sum(data), name)
+		}
+	}
+	return res
+}
+
+// parseLine splits the line of sums into the digest and file name, in the
+// format printed with or without --tag
+func (h hashsum) parseLine(line string) (sum, name string, ok bool) {
+	size := h.hash().Size() * 2
+	if strings.HasPrefix(line, h.algo+" (") {
+		i := strings.LastIndex(line, ") = ")
+		if i < 0 {
+			return "", "", false
+		}
+		sum, name = line[i+4:], line[len(h.algo)+2:i]
+	} else {
+		if len(line) < size+2 || line[size] != ' ' || line[size+1] != ' ' && line[size+1] != '*' {
+			return "", "", false
+		}
+		sum, name = line[:size], line[size+2:]
+	}
+	if len(sum) != size || strings.Trim(strings.ToLower(sum), "0123456789abcdef") != "" || name == "" {
+		return "", "", false
+	}
+	return strings.ToLower(sum), name, true
+}
+
+// check verifies the sums listed in the files
+func (h hashsum) check(sys honeyos.Sys, files []string, ignoreMissing, quiet, status, strict, warn bool) int {
+	res := 0
+	plural := func(n int, one, many string) string {
+		if n == 1 {
+			return one
+		}
+		return many
+	}
+	for _, list := range files {
+		data, ok := h.read(sys, list)
+		if !ok {
+			res = 1
+			continue
+		}
+		display := list
+		if list == "-" {
+			display = "standard input"
+		}
+		var formatted, bad, failed, unread int
+		sc := bufio.NewScanner(bytes.NewReader(data))
+		for n := 1; sc.Scan(); n++ {
+			line := strings.TrimRight(sc.Text(), "\r")
+			if strings.HasPrefix(line, "#") {
+				continue
+			}
+			sum, name, ok := h.parseLine(line)
+			if !ok {
+				bad++
+				if warn {
+					fmt.Fprintf(sys.Err(), "%v: %v: %v: improperly formatted %v checksum line\n", h.name, display, n, h.algo)
+				}
+				continue
+			}
+			formatted++
+			if _, err := sys.FSys().Stat(absPath(sys, name)); err != nil && ignoreMissing && os.IsNotExist(err) {
+				continue
+			}
+			content, ok := h.read(sys, name)
+			switch {
+			case !ok:
+				unread++
+				if !status {
+					fmt.Fprintf(sys.Out(), "%v: FAILED open or read\n", name)
+				}
+			case h.sum(content) != sum:
+				failed++
+				if !status {
+					fmt.Fprintf(sys.Out(), "%v: FAILED\n", name)
+				}
+			case !quiet && !status:
+				fmt.Fprintf(sys.Out(), "%v: OK\n", name)
+			}
+		}
+		if formatted == 0 {
+			fmt.Fprintf(sys.Err(), "%v: %v: no properly formatted %v checksum lines found\n", h.name, display, h.algo)
+			res = 1
+			continue
+		}
+		if !status {
+			if bad > 0 {
+				fmt.Fprintf(sys.Err(), "%v: WARNING: %v %v improperly formatted\n", h.name, bad, plural(bad, "line is", "lines are"))
+			}
+			if unread > 0 {
+				fmt.Fprintf(sys.Err(), "%v: WARNING: %v listed %v could not be read\n", h.name, unread, plural(unread, "file", "files"))
+			}
+			if failed > 0 {
+				fmt.Fprintf(sys.Err(), "%v: WARNING: %v computed %v did NOT match\n", h.name, failed, plural(failed, "checksum", "checksums"))
+			}
+		}
+		if failed > 0 || unread > 0 || strict && bad > 0 {
+			res = 1
+		}
+	}
+	return res
+}
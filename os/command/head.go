@@ -0,0 +1,205 @@
+package command
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/pflag"
+)
+
+// head prints the first lines or bytes of the files
+type head struct{}
+
+func init() {
+	honeyos.RegisterCommand("head", head{})
+}
+
+func (head) GetHelp() string {
+	return "Usage: head [OPTION]... [FILE]...\nTry 'head --help' for more information.\n"
+}
+
+func (head) Where() string {
+	return "/usr/bin/head"
+}
+
+// headCount parses the count of -n and -c with suffixes like K and MB. sign
+// is the leading + or - which are given meaning by tail and head
+func headCount(s string) (n int64, sign byte, ok bool) {
+	if s != "" && (s[0] == '+' || s[0] == '-') {
+		sign, s = s[0], s[1:]
+	}
+	mult := int64(1)
+	for suffix, m := range map[string]int64{"b": 512, "kB": 1000, "K": 1024, "KiB": 1024, "MB": 1000 * 1000, "M": 1 << 20, "MiB": 1 << 20, "GB": 1000 * 1000 * 1000, "G": 1 << 30, "GiB": 1 << 30} {
+		if strings.HasSuffix(s, suffix) && len(s) > len(suffix) {
+			s, mult = strings.TrimSuffix(s, suffix), m
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, sign, false
+	}
+	return n * mult, sign, true
+}
+
+// headParseArgs rewrites the obsolete -NUM of head and tail to --lines=NUM,
+// with the trailing c for bytes and f for follow. It is only taken as the
+// first argument like coreutils do
+func headParseArgs(args []string) []string {
+	if len(args) == 0 {
+		return args
+	}
+	arg := args[0]
+	if len(arg) < 2 || arg[0] != '-' || arg[1] < '0' || arg[1] > '9' {
+		return args
+	}
+	num := strings.TrimRight(arg[1:], "lcbf")
+	if strings.Trim(num, "0123456789") != "" {
+		return args
+	}
+	opt := "--lines="
+	if strings.ContainsAny(arg[1+len(num):], "cb") {
+		opt = "--bytes="
+	}
+	out := []string{opt + num}
+	if strings.Contains(arg[1+len(num):], "f") {
+		out = append(out, "--follow")
+	}
+	return append(out, args[1:]...)
+}
+
+// headOpen reads the file, or stdin for -, reporting errors like the
+// coreutils do
+func headOpen(sys honeyos.Sys, cmd, name string) ([]byte, bool) {
+	if name == "-" {
+		data, _ := ioutil.ReadAll(sys.In())
+		return data, true
+	}
+	p := absPath(sys, name)
+	if fi, err := sys.FSys().Stat(p); err == nil && fi.IsDir() {
+		fmt.Fprintf(sys.Err(), "%v: error reading '%v': Is a directory\n", cmd, name)
+		return nil, false
+	}
+	data, err := readFile(sys, p)
+	if err != nil {
+		reason := "No such file or directory"
+		if os.IsPermission(err) {
+			reason = "Permission denied"
+		}
+		fmt.Fprintf(sys.Err(), "%v: cannot open '%v' for reading: %v\n", cmd, name, reason)
+		return nil, false
+	}
+	return data, true
+}
+
+// headLines splits the data into lines keeping the line endings
+func headLines(data []byte, delim byte) [][]byte {
+	var lines [][]byte
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, delim)
+		if i < 0 {
+			lines = append(lines, data)
+			break
+		}
+		lines = append(lines, data[:i+1])
+		data = data[i+1:]
+	}
+	return lines
+}
+
+func (head) Exec(args []string, sys honeyos.Sys) int {
+	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	lines := flag.StringP("lines", "n", "10", "print the first NUM lines instead of the first 10")
+	byteCount := flag.StringP("bytes", "c", "", "print the first NUM bytes of each file")
+	quiet := flag.BoolP("quiet", "q", false, "never print headers giving file names")
+	flag.BoolVar(quiet, "silent", false, "")
+	verbose := flag.BoolP("verbose", "v", false, "always print headers giving file names")
+	zero := flag.BoolP("zero-terminated", "z", false, "line delimiter is NUL, not newline")
+	if err := flag.Parse(headParseArgs(args)); err != nil {
+		msg := strings.Replace(err.Error(), "unknown shorthand flag: ", "invalid option -- ", 1)
+		if i := strings.Index(msg, " in -"); i >= 0 {
+			msg = msg[:i]
+		}
+		fmt.Fprintf(sys.Err(), "head: %v\nTry 'head --help' for more information.\n", msg)
+		return 1
+	}
+	spec, unit := *lines, "lines"
+	if flag.Changed("bytes") {
+		spec, unit = *byteCount, "bytes"
+	}
+	n, sign, ok := headCount(spec)
+	if !ok {
+		fmt.Fprintf(sys.Err(), "head: invalid number of %v: ‘%v’\n", unit, spec)
+		return 1
+	}
+	delim := byte('\n')
+	if *zero {
+		delim = 0
+	}
+	files := flag.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	status := 0
+	first := true
+	for _, name := range files {
+		var data []byte
+		if name == "-" && unit == "lines" && sign != '-' {
+			// Lines are read as they come so head ends without waiting
+			// for the end of input
+			r := bufio.NewReader(sys.In())
+			for i := int64(0); i < n; i++ {
+				line, err := r.ReadBytes(delim)
+				data = append(data, line...)
+				if err != nil {
+					break
+				}
+			}
+		} else if data, ok = headOpen(sys, "head", name); !ok {
+			status = 1
+			continue
+		}
+		if *verbose || len(files) > 1 && !*quiet {
+			if !first {
+				fmt.Fprintln(sys.Out())
+			}
+			display := name
+			if name == "-" {
+				display = "standard input"
+			}
+			fmt.Fprintf(sys.Out(), "==> %v <==\n", display)
+		}
+		first = false
+		// Negative count prints all but the last ones
+		if unit == "bytes" {
+			end := int(n)
+			if sign == '-' {
+				end = len(data) - int(n)
+			}
+			if end > len(data) {
+				end = len(data)
+			}
+			if end > 0 {
+				sys.Out().Write(data[:end])
+			}
+			continue
+		}
+		ls := headLines(data, delim)
+		end := int(n)
+		if sign == '-' {
+			end = len(ls) - int(n)
+		}
+		for i := 0; i < end && i < len(ls); i++ {
+			io.WriteString(sys.Out(), string(ls[i]))
+		}
+	}
+	return status
+}
@@ -0,0 +1,171 @@
+package command
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+)
+
+// host queries the fake DNS and tells the records in sentences
+type host struct{}
+
+const hostUsage = `Usage: host [-aCdilrTvVw] [-c class] [-N ndots] [-t type] [-W time]
+            [-R number] [-m flag] hostname [server]
+       -a is equivalent to -v -t ANY
+       -c specifies query class for non-IN data
+       -C compares SOA records on authoritative nameservers
+       -d is equivalent to -v
+       -l lists all hosts in a domain, using AXFR
+       -i IP6.INT reverse lookups
+       -N changes the number of dots allowed before root lookup is done
+       -r disables recursive processing
+       -R specifies number of retries for UDP packets
+       -s a SERVFAIL response should stop query
+       -t specifies the query type
+       -T enables TCP/IP mode
+       -v enables verbose output
+       -w specifies to wait forever for a reply
+       -W specifies how long to wait for a reply
+       -4 use IPv4 query transport only
+       -6 use IPv6 query transport only
+       -m set memory debugging flag (trace|record|usage)
+       -V print version number and exit
+`
+
+func init() {
+	honeyos.RegisterCommand("host", host{})
+}
+
+func (host) GetHelp() string {
+	return hostUsage
+}
+
+func (host) Where() string {
+	return "/usr/bin/host"
+}
+
+func (h host) Exec(args []string, sys honeyos.Sys) int {
+	if !dnsTools(sys) {
+		return honeyos.CommandNotFound(sys, append([]string{"host"}, args...))
+	}
+	out := sys.Out()
+	kind := ""
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || len(arg) == 1 {
+			rest = append(rest, arg)
+			continue
+		}
+		for j := 1; j < len(arg); j++ {
+			c := arg[j]
+			if strings.IndexByte("ctNRWm", c) >= 0 {
+				val := arg[j+1:]
+				if val == "" {
+					if i+1 >= len(args) {
+						fmt.Fprint(sys.Err(), hostUsage)
+						return 1
+					}
+					i++
+					val = args[i]
+				}
+				if c == 't' {
+					t, ok := dnsType(val)
+					if !ok {
+						fmt.Fprintf(sys.Err(), "host: invalid type: %v\n", val)
+						return 1
+					}
+					kind = t
+				}
+				break
+			}
+			switch c {
+			case 'a':
+				kind = "ANY"
+			case 'V':
+				fmt.Fprintf(out, "host %v\n", strings.SplitN(dig{}.version(), "-", 2)[0])
+				return 0
+			case 'C', 'd', 'l', 'i', 'r', 's', 'T', 'v', 'w', '4', '6':
+			default:
+				fmt.Fprint(sys.Err(), hostUsage)
+				return 1
+			}
+		}
+	}
+	if len(rest) == 0 {
+		fmt.Fprint(sys.Err(), hostUsage)
+		return 1
+	}
+
+	name, server := rest[0], dnsServer()
+	if len(rest) > 1 {
+		server = rest[1]
+		ip := net.ParseIP(server)
+		if ip == nil {
+			ip = netResolve(sys, server)
+		}
+		if ip == nil {
+			fmt.Fprintf(sys.Err(), "host: couldn't get address for '%v': not found\n", server)
+			return 1
+		}
+		fmt.Fprintf(out, "Using domain server:\nName: %v\nAddress: %v#53\nAliases: \n\n", server, ip)
+	}
+	kinds := []string{kind}
+	if ip := net.ParseIP(name); ip != nil && kind == "" {
+		name, kinds = dnsReverse(ip), []string{"PTR"}
+	} else if kind == "" {
+		kinds = []string{"A", "AAAA", "MX"}
+	}
+
+	found := false
+	for n, k := range kinds {
+		answer := dnsQuery(sys, "host", server, name, k)
+		switch answer.status {
+		case "timeout":
+			if !pkgSleep(sys, 10*time.Second) {
+				return 1
+			}
+			fmt.Fprintln(out, ";; connection timed out; no servers could be reached")
+			return 1
+		case "NXDOMAIN":
+			fmt.Fprintf(out, "Host %v not found: 3(NXDOMAIN)\n", strings.TrimSuffix(name, "."))
+			return 1
+		case "SERVFAIL":
+			fmt.Fprintf(out, "Host %v not found: 2(SERVFAIL)\n", strings.TrimSuffix(name, "."))
+			return 1
+		}
+		for _, r := range answer.records {
+			// The aliases are told once, with the first type
+			if r.kind == "CNAME" && k != "CNAME" && n > 0 {
+				continue
+			}
+			found = true
+			rname := strings.TrimSuffix(r.name, ".")
+			switch r.kind {
+			case "A":
+				fmt.Fprintf(out, "%v has address %v\n", rname, r.value)
+			case "AAAA":
+				fmt.Fprintf(out, "%v has IPv6 address %v\n", rname, r.value)
+			case "MX":
+				fmt.Fprintf(out, "%v mail is handled by %v\n", rname, r.value)
+			case "TXT":
+				fmt.Fprintf(out, "%v descriptive text %v\n", rname, r.value)
+			case "NS":
+				fmt.Fprintf(out, "%v name server %v\n", rname, r.value)
+			case "CNAME":
+				fmt.Fprintf(out, "%v is an alias for %v\n", rname, r.value)
+			case "PTR":
+				fmt.Fprintf(out, "%v domain name pointer %v\n", rname, r.value)
+			default:
+				fmt.Fprintf(out, "%v has %v record %v\n", rname, r.kind, r.value)
+			}
+		}
+	}
+	if !found && kind != "" {
+		fmt.Fprintf(out, "%v has no %v record\n", strings.TrimSuffix(name, "."), kind)
+	}
+	return 0
+}
@@ -0,0 +1,281 @@
+package command
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/afero"
+)
+
+type hostname struct{}
+
+type hostnamectl struct{}
+
+// hostnamePattern is what sethostname in the hostname utility accepts
+var hostnamePattern = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?)*$`)
+
+func init() {
+	honeyos.RegisterCommand("hostname", hostname{})
+	honeyos.RegisterCommand("hostnamectl", hostnamectl{})
+}
+
+func (hostname) GetHelp() string {
+	return `Usage: hostname [-b] {hostname|-F file}         set host name (from file)
+       hostname [-a|-A|-d|-f|-i|-I|-s|-y]       display formatted name
+       hostname                                 display host name
+
+       {yp,nis,}domainname {nisdomain|-F file}  set NIS domain name (from file)
+       {yp,nis,}domainname                      display NIS domain name
+
+       dnsdomainname                            display dns domain name
+
+       hostname -V|--version|-h|--help          print info and exit
+
+Program name:
+       {yp,nis,}domainname=hostname -y
+       dnsdomainname=hostname -d
+
+Program options:
+    -a, --alias            alias names
+    -A, --all-fqdns        all long host names (FQDNs)
+    -b, --boot             set default hostname if none available
+    -d, --domain           DNS domain name
+    -f, --fqdn, --long     long host name (FQDN)
+    -F, --file             read host name or NIS domain name from given file
+    -i, --ip-address       addresses for the host name
+    -I, --all-ip-addresses all addresses for the host
+    -s, --short            short host name
+    -y, --yp, --nis        NIS/YP domain name
+
+Description:
+   This command can get or set the host name or the NIS domain name. You can
+   also get the DNS domain or the FQDN (fully qualified domain name).
+   Unless you are using bind or NIS for host lookups you can change the
+   FQDN (Fully Qualified Domain Name) and the DNS domain name (which is
+   part of the FQDN) in the /etc/hosts file.
+`
+}
+
+func (hostname) Where() string {
+	return "/bin/hostname"
+}
+
+func (h hostname) Exec(args []string, sys honeyos.Sys) int {
+	format := ""
+	var name, file string
+	opts := true
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !opts || arg == "-" || !strings.HasPrefix(arg, "-") {
+			name = arg
+			continue
+		}
+		switch arg {
+		case "--":
+			// The arguments after are not options
+			opts = false
+		case "-h", "--help":
+			fmt.Fprint(sys.Err(), h.GetHelp())
+			return 0
+		case "-V", "--version":
+			fmt.Fprintln(sys.Err(), "hostname 3.23")
+			return 0
+		case "-b", "--boot":
+		case "-F", "--file":
+			if i+1 >= len(args) {
+				fmt.Fprint(sys.Err(), "hostname: option requires an argument -- 'F'\n"+h.GetHelp())
+				return 1
+			}
+			i++
+			file = args[i]
+		case "-a", "--alias", "-A", "--all-fqdns", "-d", "--domain", "-f", "--fqdn", "--long",
+			"-i", "--ip-address", "-I", "--all-ip-addresses", "-s", "--short", "-y", "--yp", "--nis":
+			format = arg
+		default:
+			if strings.HasPrefix(arg, "--") {
+				fmt.Fprintf(sys.Err(), "hostname: unrecognized option '%v'\n%v", arg, h.GetHelp())
+			} else {
+				fmt.Fprintf(sys.Err(), "hostname: invalid option -- '%v'\n%v", arg[1:2], h.GetHelp())
+			}
+			return 1
+		}
+	}
+	if file != "" {
+		content, err := readFile(sys, file)
+		if err != nil {
+			fmt.Fprintf(sys.Err(), "hostname: %v: No such file or directory\n", file)
+			return 1
+		}
+		for _, line := range strings.Split(string(content), "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				name = line
+				break
+			}
+		}
+	}
+	if name != "" {
+		if !hostnamePattern.MatchString(name) {
+			fmt.Fprintln(sys.Err(), "hostname: the specified hostname is invalid")
+			return 1
+		}
+		if !renameHost(sys, name) {
+			fmt.Fprintln(sys.Err(), "hostname: you must be root to change the host name")
+			return 1
+		}
+		return 0
+	}
+	host := sys.Hostname()
+	short := strings.SplitN(host, ".", 2)[0]
+	switch format {
+	case "":
+		fmt.Fprintln(sys.Out(), host)
+	case "-s", "--short":
+		fmt.Fprintln(sys.Out(), short)
+	case "-f", "--fqdn", "--long", "-A", "--all-fqdns":
+		fmt.Fprintln(sys.Out(), host)
+	case "-d", "--domain":
+		if len(host) > len(short) {
+			fmt.Fprintln(sys.Out(), host[len(short)+1:])
+		} else {
+			fmt.Fprintln(sys.Out())
+		}
+	case "-i", "--ip-address":
+		fmt.Fprintln(sys.Out(), honeyos.IPAddress())
+	case "-I", "--all-ip-addresses":
+		fmt.Fprintln(sys.Out(), honeyos.IPAddress()+" ")
+	case "-y", "--yp", "--nis":
+		fmt.Fprintln(sys.Err(), "hostname: Local domain name not set")
+		return 1
+	case "-a", "--alias":
+		fmt.Fprintln(sys.Out())
+	}
+	return 0
+}
+
+// renameHost changes the host name for the session and writes it to
+// /etc/hostname. It is false if the user is not allowed to
+func renameHost(sys honeyos.Sys, name string) bool {
+	if err := honeyos.SetHostname(sys, name); err != nil {
+		sys.Log().WithField("hostname", name).Infof("User tried to rename host to %v", name)
+		return false
+	}
+	afero.WriteFile(sys.FSys(), "/etc/hostname", []byte(name+"\n"), 0644)
+	return true
+}
+
+func (hostnamectl) GetHelp() string {
+	return `hostnamectl [OPTIONS...] COMMAND ...
+
+Query or change system hostname.
+
+  -h --help              Show this help
+     --version           Show package version
+     --no-ask-password   Do not prompt for password
+  -H --host=[USER@]HOST  Operate on remote host
+  -M --machine=CONTAINER Operate on local container
+     --transient         Only set transient hostname
+     --static            Only set static hostname
+     --pretty            Only set pretty hostname
+
+Commands:
+  status                 Show current hostname settings
+  set-hostname NAME      Set system hostname
+  set-icon-name NAME     Set icon name for host
+  set-chassis NAME       Set chassis type for host
+  set-deployment NAME    Set deployment environment for host
+  set-location NAME      Set location for host
+`
+}
+
+func (hostnamectl) Where() string {
+	return "/usr/bin/hostnamectl"
+}
+
+func (h hostnamectl) Exec(args []string, sys honeyos.Sys) int {
+	if honeyos.Distro() == "alpine" {
+		// No systemd on Alpine
+		return honeyos.CommandNotFound(sys, append([]string{"hostnamectl"}, args...))
+	}
+	var cmd []string
+	for _, arg := range args {
+		switch {
+		case arg == "-h" || arg == "--help":
+			fmt.Fprint(sys.Out(), h.GetHelp())
+			return 0
+		case arg == "--version":
+			fmt.Fprintln(sys.Out(), "systemd 245 (245.4-4ubuntu3.22)")
+			return 0
+		case arg == "--transient" || arg == "--static" || arg == "--pretty" || arg == "--no-ask-password":
+		case strings.HasPrefix(arg, "-"):
+			fmt.Fprintf(sys.Err(), "hostnamectl: unrecognized option '%v'\n", arg)
+			return 1
+		default:
+			cmd = append(cmd, arg)
+		}
+	}
+	if len(cmd) == 0 {
+		cmd = []string{"status"}
+	}
+	switch cmd[0] {
+	case "status":
+		h.status(sys)
+		return 0
+	case "set-hostname", "set-icon-name", "set-chassis", "set-deployment", "set-location":
+		if len(cmd) < 2 {
+			fmt.Fprintln(sys.Err(), "Too few arguments.")
+			return 1
+		} else if len(cmd) > 2 {
+			fmt.Fprintln(sys.Err(), "Too many arguments.")
+			return 1
+		}
+	default:
+		fmt.Fprintf(sys.Err(), "Unknown operation %v.\n", cmd[0])
+		return 1
+	}
+	if sys.CurrentUser() != 0 {
+		sys.Log().WithField("args", cmd).Infof("User tried to %v without root", cmd[0])
+		fmt.Fprintln(sys.Err(), "Could not set property: Interactive authentication required.")
+		return 1
+	}
+	if cmd[0] != "set-hostname" {
+		return 0
+	}
+	// The static hostname is cleaned up rather than rejected
+	name := strings.Trim(strings.ToLower(regexp.MustCompile(`[^a-zA-Z0-9.-]+`).ReplaceAllString(cmd[1], "")), ".-")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	if name == "" {
+		fmt.Fprintf(sys.Err(), "Could not set property: Invalid static hostname '%v'\n", cmd[1])
+		return 1
+	}
+	renameHost(sys, name)
+	return 0
+}
+
+func (hostnamectl) status(sys honeyos.Sys) {
+	static := sys.Hostname()
+	if content, err := readFile(sys, "/etc/hostname"); err == nil && strings.TrimSpace(string(content)) != "" {
+		static = strings.TrimSpace(string(content))
+	}
+	_, description, _, _ := honeyos.DistroName()
+	lines := [][2]string{{"Static hostname", static}}
+	if static != sys.Hostname() {
+		lines = append(lines, [2]string{"Transient hostname", sys.Hostname()})
+	}
+	lines = append(lines, [][2]string{
+		{"Icon name", "computer-vm"},
+		{"Chassis", "vm"},
+		{"Machine ID", honeyos.MachineID()},
+		{"Boot ID", honeyos.BootID()},
+		{"Virtualization", "kvm"},
+		{"Operating System", description},
+		{"Kernel", "Linux " + honeyos.KernelRelease()},
+		{"Architecture", strings.Replace(honeyos.Arch(), "_", "-", 1)},
+	}...)
+	for _, l := range lines {
+		fmt.Fprintf(sys.Out(), "%18v: %v\n", l[0], l[1])
+	}
+}
@@ -2,8 +2,12 @@ package command
 
 import (
 	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
 
 	"github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/pflag"
 )
 
 type id struct{}
@@ -13,19 +17,150 @@ func init() {
 }
 
 func (i id) GetHelp() string {
-	return ""
+	return `Usage: id [OPTION]... [USER]...
+Print user and group information for each specified USER,
+or (when USER omitted) for the current user.
+
+  -a             ignore, for compatibility with other versions
+  -Z, --context  print only the security context of the process
+  -g, --group    print only the effective group ID
+  -G, --groups   print all group IDs
+  -n, --name     print a name instead of a number, for -ugG
+  -r, --real     print the real ID instead of the effective ID, with -ugG
+  -u, --user     print only the effective user ID
+  -z, --zero     delimit entries with NUL characters, not whitespace;
+                   not permitted in default format
+      --help     display this help and exit
+      --version  output version information and exit
+
+Without any OPTION, print some useful set of identified information.
+`
 }
 
 func (i id) Exec(args []string, sys os.Sys) int {
-	uid := sys.CurrentUser()
-	gid := sys.CurrentGroup()
-
-	user := os.GetUserByID(uid)
-	group := os.GetGroupByID(gid)
-	fmt.Fprintf(sys.Out(), "uid=%d(%s) gid=%d(%s) groups=%d(%s)\n", uid, user.Name, gid, group.Name, gid, group.Name)
+	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	flag.BoolP("all", "a", false, "")
+	context := flag.BoolP("context", "Z", false, "")
+	onlyGroup := flag.BoolP("group", "g", false, "")
+	allGroups := flag.BoolP("groups", "G", false, "")
+	name := flag.BoolP("name", "n", false, "")
+	real := flag.BoolP("real", "r", false, "")
+	onlyUser := flag.BoolP("user", "u", false, "")
+	zero := flag.BoolP("zero", "z", false, "")
+	help := flag.Bool("help", false, "")
+	version := flag.Bool("version", false, "")
+	if err := flag.Parse(args); err != nil {
+		msg := strings.Replace(err.Error(), "unknown shorthand flag: ", "invalid option -- ", 1)
+		if i := strings.Index(msg, " in -"); i >= 0 {
+			msg = msg[:i]
+		}
+		fmt.Fprintf(sys.Err(), "id: %v\nTry 'id --help' for more information.\n", msg)
+		return 1
+	}
+	switch {
+	case *help:
+		fmt.Fprint(sys.Out(), i.GetHelp())
+		return 0
+	case *version:
+		fmt.Fprintln(sys.Out(), "id (GNU coreutils) 8.32")
+		return 0
+	}
+	only := 0
+	for _, b := range []bool{*onlyGroup, *allGroups, *onlyUser, *context} {
+		if b {
+			only++
+		}
+	}
+	switch {
+	case only > 1:
+		fmt.Fprintln(sys.Err(), "id: cannot print \"only\" of more than one choice")
+		return 1
+	case only == 0 && (*name || *real):
+		fmt.Fprintln(sys.Err(), "id: cannot print only names or real IDs in default format")
+		return 1
+	case only == 0 && *zero:
+		fmt.Fprintln(sys.Err(), "id: option --zero not permitted in default format")
+		return 1
+	case *context:
+		fmt.Fprintln(sys.Err(), "id: --context (-Z) works only on an SELinux-enabled kernel")
+		return 1
+	}
+	users := []os.User{os.GetUserByID(sys.CurrentUser())}
+	if flag.NArg() > 0 {
+		users = nil
+		for _, arg := range flag.Args() {
+			u, ok := lookupUser(arg)
+			if !ok {
+				fmt.Fprintf(sys.Err(), "id: '%v': no such user\n", arg)
+				return 1
+			}
+			users = append(users, u)
+		}
+	}
+	sep := " "
+	if *zero {
+		sep = "\x00"
+	}
+	for _, u := range users {
+		groups := os.UserGroups(u)
+		var fields []string
+		switch {
+		case *onlyUser:
+			fields = []string{idName(u.UID, u.Name, *name)}
+		case *onlyGroup:
+			fields = []string{idName(u.GID, groups[0].Name, *name)}
+		case *allGroups:
+			for _, g := range groups {
+				fields = append(fields, idName(g.GID, g.Name, *name))
+			}
+		default:
+			list := make([]string, len(groups))
+			for j, g := range groups {
+				list[j] = idFormat(g.GID, g.Name)
+			}
+			fmt.Fprintf(sys.Out(), "uid=%v gid=%v groups=%v\n", idFormat(u.UID, u.Name), idFormat(u.GID, groups[0].Name),
+				strings.Join(list, ","))
+			continue
+		}
+		if *zero {
+			fmt.Fprint(sys.Out(), strings.Join(fields, sep)+"\x00")
+		} else {
+			fmt.Fprintln(sys.Out(), strings.Join(fields, sep))
+		}
+	}
 	return 0
 }
 
 func (i id) Where() string {
-	return "/bin/uname"
+	return "/usr/bin/id"
+}
+
+// lookupUser finds the user by name, or by UID if no user has the name
+func lookupUser(arg string) (os.User, bool) {
+	if _, exists := os.IsUserExist(arg); exists {
+		return os.GetUser(arg), true
+	}
+	if uid, err := strconv.Atoi(arg); err == nil {
+		if u := os.GetUserByID(uid); u.Name != "" {
+			return u, true
+		}
+	}
+	return os.User{}, false
+}
+
+// idName is the ID as number, or as name if asked and there is one
+func idName(id int, name string, byName bool) string {
+	if byName && name != "" {
+		return name
+	}
+	return strconv.Itoa(id)
+}
+
+// idFormat formats the ID like 0(root), or only the number without name
+func idFormat(id int, name string) string {
+	if name == "" {
+		return strconv.Itoa(id)
+	}
+	return fmt.Sprintf("%v(%v)", id, name)
 }
@@ -0,0 +1,194 @@
+package command
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/viper"
+)
+
+type ifconfig struct{}
+
+// netIface is a network interface of the machine, with its address set in
+// persona config
+type netIface struct {
+	index     int
+	name, mac string
+	addr      net.IP
+	mask      net.IPMask
+	mtu       int
+	loopback  bool
+	// Traffic counters keep increasing since boot
+	rxPackets, rxBytes, txPackets, txBytes int64
+}
+
+// netIfaces returns the loopback and the ethernet interface
+func netIfaces() []netIface {
+	up := int64(time.Since(honeyos.BootTime()).Seconds())
+	mask := net.IPMask(net.ParseIP(viper.GetString("persona.netmask")).To4())
+	if mask == nil {
+		mask = net.CIDRMask(24, 32)
+	}
+	lo := netIface{index: 1, name: "lo", mac: "00:00:00:00:00:00", addr: net.IPv4(127, 0, 0, 1), mask: net.CIDRMask(8, 32),
+		mtu: 65536, loopback: true, rxBytes: 29440 + up*12}
+	lo.txBytes, lo.rxPackets = lo.rxBytes, lo.rxBytes/74
+	lo.txPackets = lo.rxPackets
+	eth := netIface{index: 2, name: "eth0", mac: strings.ToLower(viper.GetString("persona.mac")), addr: net.ParseIP(honeyos.IPAddress()),
+		mask: mask, mtu: 1500, rxBytes: 48213507 + up*1537, txBytes: 9120331 + up*493}
+	eth.rxPackets, eth.txPackets = eth.rxBytes/611, eth.txBytes/402
+	return []netIface{lo, eth}
+}
+
+func findIface(name string) (netIface, bool) {
+	for _, i := range netIfaces() {
+		if i.name == name {
+			return i, true
+		}
+	}
+	return netIface{}, false
+}
+
+// prefixLen is the length of the netmask, like 24 for 255.255.255.0
+func (i netIface) prefixLen() int {
+	ones, _ := i.mask.Size()
+	return ones
+}
+
+func (i netIface) broadcast() net.IP {
+	ip := i.addr.To4()
+	b := make(net.IP, len(ip))
+	for j := range ip {
+		b[j] = ip[j] | ^i.mask[j]
+	}
+	return b
+}
+
+// addr6 is the link local address made from MAC address, or ::1 for
+// loopback
+func (i netIface) addr6() string {
+	if i.loopback {
+		return "::1"
+	}
+	hw, err := net.ParseMAC(i.mac)
+	if err != nil || len(hw) != 6 {
+		return "fe80::1"
+	}
+	ip := net.IP{0xfe, 0x80, 0, 0, 0, 0, 0, 0, hw[0] ^ 2, hw[1], hw[2], 0xff, 0xfe, hw[3], hw[4], hw[5]}
+	return ip.String()
+}
+
+// ifSize formats bytes counter, in SI units like net-tools 1.60 or in IEC
+// units like the newer ifconfig and busybox
+func ifSize(n int64, iec bool) string {
+	base, units := 1000.0, []string{"B", "KB", "MB", "GB", "TB"}
+	if iec {
+		base, units = 1024.0, []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	}
+	v, u := float64(n), 0
+	for v >= base && u < len(units)-1 {
+		v /= base
+		u++
+	}
+	if u == 0 {
+		return fmt.Sprintf("%v %v", n, units[0])
+	}
+	return fmt.Sprintf("%.1f %v", v, units[u])
+}
+
+func init() {
+	honeyos.RegisterCommand("ifconfig", ifconfig{})
+}
+
+func (ifconfig) GetHelp() string {
+	return ""
+}
+
+func (ifconfig) Where() string {
+	return "/sbin/ifconfig"
+}
+
+func (c ifconfig) Exec(args []string, sys honeyos.Sys) int {
+	if pkgFamily() == "rpm" && !loadPkgDB(sys, "rpm").installed("net-tools") {
+		return honeyos.CommandNotFound(sys, append([]string{"ifconfig"}, args...))
+	}
+	if len(args) > 0 && args[0] == "-a" {
+		args = args[1:]
+	}
+	ifaces := netIfaces()
+	if len(args) > 0 {
+		i, ok := findIface(args[0])
+		if !ok {
+			fmt.Fprintf(sys.Err(), "%v: error fetching interface information: Device not found\n", args[0])
+			return 1
+		}
+		if len(args) > 1 {
+			// Changes to the interface are accepted but never applied
+			sys.Log().WithField("args", args).Info("User tried to configure network interface")
+			if !isRoot(sys) {
+				fmt.Fprintln(sys.Err(), "SIOCSIFFLAGS: Operation not permitted")
+				return 1
+			}
+			return 0
+		}
+		ifaces = []netIface{i}
+	} else {
+		// Interfaces are listed in the order of name
+		ifaces[0], ifaces[1] = ifaces[1], ifaces[0]
+	}
+	for _, i := range ifaces {
+		if pkgFamily() == "rpm" {
+			c.printNew(sys, i)
+		} else {
+			c.printOld(sys, i, honeyos.Distro() == "alpine")
+		}
+	}
+	return 0
+}
+
+// printOld prints the interface like net-tools 1.60, which busybox also
+// follows
+func (ifconfig) printOld(sys honeyos.Sys, i netIface, busybox bool) {
+	w := sys.Out()
+	if i.loopback {
+		fmt.Fprintf(w, "%-10vLink encap:Local Loopback  \n", i.name)
+		fmt.Fprintf(w, "          inet addr:%v  Mask:%v\n", i.addr, net.IP(i.mask))
+		fmt.Fprintf(w, "          inet6 addr: %v/128 Scope:Host\n", i.addr6())
+		fmt.Fprintf(w, "          UP LOOPBACK RUNNING  MTU:%v  Metric:1\n", i.mtu)
+	} else {
+		fmt.Fprintf(w, "%-10vLink encap:Ethernet  HWaddr %v  \n", i.name, i.mac)
+		fmt.Fprintf(w, "          inet addr:%v  Bcast:%v  Mask:%v\n", i.addr, i.broadcast(), net.IP(i.mask))
+		fmt.Fprintf(w, "          inet6 addr: %v/64 Scope:Link\n", i.addr6())
+		fmt.Fprintf(w, "          UP BROADCAST RUNNING MULTICAST  MTU:%v  Metric:1\n", i.mtu)
+	}
+	fmt.Fprintf(w, "          RX packets:%v errors:0 dropped:0 overruns:0 frame:0\n", i.rxPackets)
+	fmt.Fprintf(w, "          TX packets:%v errors:0 dropped:0 overruns:0 carrier:0\n", i.txPackets)
+	qlen := 1000
+	if i.loopback {
+		qlen = 1
+	}
+	fmt.Fprintf(w, "          collisions:0 txqueuelen:%v \n", qlen)
+	fmt.Fprintf(w, "          RX bytes:%v (%v)  TX bytes:%v (%v)\n\n", i.rxBytes, ifSize(i.rxBytes, busybox), i.txBytes, ifSize(i.txBytes, busybox))
+}
+
+// printNew prints the interface like net-tools 2.0 of CentOS 7
+func (ifconfig) printNew(sys honeyos.Sys, i netIface) {
+	w := sys.Out()
+	if i.loopback {
+		fmt.Fprintf(w, "%v: flags=73<UP,LOOPBACK,RUNNING>  mtu %v\n", i.name, i.mtu)
+		fmt.Fprintf(w, "        inet %v  netmask %v\n", i.addr, net.IP(i.mask))
+		fmt.Fprintf(w, "        inet6 %v  prefixlen 128  scopeid 0x10<host>\n", i.addr6())
+		fmt.Fprintln(w, "        loop  txqueuelen 1  (Local Loopback)")
+	} else {
+		fmt.Fprintf(w, "%v: flags=4163<UP,BROADCAST,RUNNING,MULTICAST>  mtu %v\n", i.name, i.mtu)
+		fmt.Fprintf(w, "        inet %v  netmask %v  broadcast %v\n", i.addr, net.IP(i.mask), i.broadcast())
+		fmt.Fprintf(w, "        inet6 %v  prefixlen 64  scopeid 0x20<link>\n", i.addr6())
+		fmt.Fprintf(w, "        ether %v  txqueuelen 1000  (Ethernet)\n", i.mac)
+	}
+	fmt.Fprintf(w, "        RX packets %v  bytes %v (%v)\n", i.rxPackets, i.rxBytes, ifSize(i.rxBytes, true))
+	fmt.Fprintln(w, "        RX errors 0  dropped 0  overruns 0  frame 0")
+	fmt.Fprintf(w, "        TX packets %v  bytes %v (%v)\n", i.txPackets, i.txBytes, ifSize(i.txBytes, true))
+	fmt.Fprintf(w, "        TX errors 0  dropped 0 overruns 0  carrier 0  collisions 0\n\n")
+}
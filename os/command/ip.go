@@ -0,0 +1,197 @@
+package command
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/viper"
+)
+
+type ip struct{}
+
+const ipUsage = `Usage: ip [ OPTIONS ] OBJECT { COMMAND | help }
+       ip [ -force ] -batch filename
+where  OBJECT := { link | address | addrlabel | route | rule | neigh | ntable |
+                   tunnel | tuntap | maddress | mroute | mrule | monitor | xfrm |
+                   netns | l2tp | macsec | tcp_metrics | token }
+       OPTIONS := { -V[ersion] | -s[tatistics] | -d[etails] | -r[esolve] |
+                    -h[uman-readable] | -iec |
+                    -f[amily] { inet | inet6 | ipx | dnet | bridge | link } |
+                    -4 | -6 | -I | -D | -B | -0 |
+                    -l[oops] { maximum-addr-flush-attempts } |
+                    -o[neline] | -t[imestamp] | -ts[hort] | -b[atch] [filename] |
+                    -rc[vbuf] [size] | -n[etns] name | -a[ll] }`
+
+// gatewayMAC is the MAC address of the default gateway
+const gatewayMAC = "52:54:00:12:35:02"
+
+// ipObjects are the objects of ip, which can be abbreviated like ip a
+var ipObjects = []string{"address", "link", "route", "neighbour"}
+
+func init() {
+	honeyos.RegisterCommand("ip", ip{})
+}
+
+func (ip) GetHelp() string {
+	return ""
+}
+
+func (ip) Where() string {
+	return "/sbin/ip"
+}
+
+func (c ip) Exec(args []string, sys honeyos.Sys) int {
+	var stats, inet4, inet6 bool
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		switch opt := strings.TrimLeft(args[0], "-"); {
+		case opt == "4":
+			inet4 = true
+		case opt == "6":
+			inet6 = true
+		case strings.HasPrefix("statistics", opt):
+			stats = true
+		case strings.HasPrefix("details", opt), strings.HasPrefix("oneline", opt), strings.HasPrefix("resolve", opt),
+			strings.HasPrefix("human-readable", opt), strings.HasPrefix("color", opt):
+		default:
+			fmt.Fprintf(sys.Err(), "Option \"%v\" is unknown, try \"ip -help\".\n", args[0])
+			return 255
+		}
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		fmt.Fprintln(sys.Err(), ipUsage)
+		return 255
+	}
+	object := ""
+	for _, o := range ipObjects {
+		if strings.HasPrefix(o, args[0]) {
+			object = o
+			break
+		}
+	}
+	if args[0] == "help" {
+		fmt.Fprintln(sys.Err(), ipUsage)
+		return 255
+	}
+	if object == "" {
+		fmt.Fprintf(sys.Err(), "Object \"%v\" is unknown, try \"ip help\".\n", args[0])
+		return 255
+	}
+	cmd, rest := "show", args[1:]
+	if len(rest) > 0 {
+		cmd, rest = rest[0], rest[1:]
+	}
+	switch cmd {
+	case "show", "list", "s", "sh", "ls", "l", "lst":
+	case "add", "del", "delete", "set", "change", "replace", "flush", "append":
+		// Changes are accepted from root, but the interfaces stay the same
+		sys.Log().WithField("args", args).Info("User tried to configure network")
+		if !isRoot(sys) {
+			fmt.Fprintln(sys.Err(), "RTNETLINK answers: Operation not permitted")
+			return 2
+		}
+		return 0
+	default:
+		// Interface name can follow the object without show, like ip a eth0
+		rest = args[1:]
+	}
+	device := ""
+	for i := 0; i < len(rest); i++ {
+		if rest[i] == "dev" && i+1 < len(rest) {
+			i++
+		}
+		device = rest[i]
+	}
+	ifaces := netIfaces()
+	if device != "" && object != "route" {
+		i, ok := findIface(device)
+		if !ok {
+			fmt.Fprintf(sys.Err(), "Device \"%v\" does not exist.\n", device)
+			return 1
+		}
+		ifaces = []netIface{i}
+	}
+	switch object {
+	case "address":
+		for _, i := range ifaces {
+			c.printLink(sys, i, false)
+			if !inet6 {
+				scope := "global " + i.name
+				if i.loopback {
+					scope = "host " + i.name
+					fmt.Fprintf(sys.Out(), "    inet %v/%v scope %v\n", i.addr, i.prefixLen(), scope)
+				} else {
+					fmt.Fprintf(sys.Out(), "    inet %v/%v brd %v scope %v\n", i.addr, i.prefixLen(), i.broadcast(), scope)
+				}
+				fmt.Fprintln(sys.Out(), "       valid_lft forever preferred_lft forever")
+			}
+			if !inet4 {
+				if i.loopback {
+					fmt.Fprintf(sys.Out(), "    inet6 %v/128 scope host \n", i.addr6())
+				} else {
+					fmt.Fprintf(sys.Out(), "    inet6 %v/64 scope link \n", i.addr6())
+				}
+				fmt.Fprintln(sys.Out(), "       valid_lft forever preferred_lft forever")
+			}
+		}
+	case "link":
+		for _, i := range ifaces {
+			c.printLink(sys, i, true)
+			if stats {
+				fmt.Fprintln(sys.Out(), "    RX: bytes  packets  errors  dropped overrun mcast   ")
+				fmt.Fprintf(sys.Out(), "    %-10v %-8v 0       0       0       0       \n", i.rxBytes, i.rxPackets)
+				fmt.Fprintln(sys.Out(), "    TX: bytes  packets  errors  dropped carrier collsns ")
+				fmt.Fprintf(sys.Out(), "    %-10v %-8v 0       0       0       0       \n", i.txBytes, i.txPackets)
+			}
+		}
+	case "route":
+		c.printRoutes(sys, inet6)
+	case "neighbour":
+		if !inet6 {
+			for _, n := range netNeighbours(sys) {
+				state := "REACHABLE"
+				if n.stale {
+					state = "STALE"
+				}
+				fmt.Fprintf(sys.Out(), "%v dev eth0 lladdr %v %v\n", n.ip, n.mac, state)
+			}
+		}
+	}
+	return 0
+}
+
+// printLink prints the first lines of the interface, which are the same in
+// ip link and ip address
+func (ip) printLink(sys honeyos.Sys, i netIface, mode bool) {
+	flags, qdisc, state, qlen, link, brd := "BROADCAST,MULTICAST,UP,LOWER_UP", "pfifo_fast", "UP", 1000, "ether", "ff:ff:ff:ff:ff:ff"
+	if i.loopback {
+		flags, qdisc, state, qlen, link, brd = "LOOPBACK,UP,LOWER_UP", "noqueue", "UNKNOWN", 1, "loopback", "00:00:00:00:00:00"
+	}
+	modeDefault := ""
+	if mode {
+		modeDefault = "mode DEFAULT "
+	}
+	fmt.Fprintf(sys.Out(), "%v: %v: <%v> mtu %v qdisc %v state %v %vgroup default qlen %v\n", i.index, i.name, flags, i.mtu, qdisc,
+		state, modeDefault, qlen)
+	fmt.Fprintf(sys.Out(), "    link/%v %v brd %v\n", link, i.mac, brd)
+}
+
+// printRoutes prints the routing table of the ethernet interface
+func (ip) printRoutes(sys honeyos.Sys, inet6 bool) {
+	eth, _ := findIface("eth0")
+	if inet6 {
+		fmt.Fprintln(sys.Out(), "fe80::/64 dev eth0  proto kernel  metric 256  pref medium")
+		return
+	}
+	network := &net.IPNet{IP: eth.addr.Mask(eth.mask), Mask: eth.mask}
+	gateway := viper.GetString("persona.gateway")
+	if pkgFamily() == "rpm" {
+		fmt.Fprintf(sys.Out(), "default via %v dev eth0 proto dhcp metric 100 \n", gateway)
+		fmt.Fprintf(sys.Out(), "%v dev eth0 proto kernel scope link src %v metric 100 \n", network, eth.addr)
+		return
+	}
+	fmt.Fprintf(sys.Out(), "default via %v dev eth0 \n", gateway)
+	fmt.Fprintf(sys.Out(), "%v dev eth0  proto kernel  scope link  src %v \n", network, eth.addr)
+}
@@ -0,0 +1,1313 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	log "github.com/sirupsen/logrus"
+)
+
+// iptables sets up the packet filter of the session. The rules are kept in
+// the form of iptables-save, and the changes logged. Flushing the rules and
+// opening ports are logged as warnings, as attackers do so to keep their
+// backdoors reachable
+type iptables struct {
+	v6 bool
+}
+
+// iptablesSave prints the rules like iptables-save
+type iptablesSave struct {
+	v6 bool
+}
+
+// iptablesRestore loads the rules printed by iptables-save
+type iptablesRestore struct {
+	v6 bool
+}
+
+func init() {
+	honeyos.RegisterCommand("iptables", iptables{})
+	honeyos.RegisterCommand("ip6tables", iptables{v6: true})
+	honeyos.RegisterCommand("iptables-save", iptablesSave{})
+	honeyos.RegisterCommand("ip6tables-save", iptablesSave{v6: true})
+	honeyos.RegisterCommand("iptables-restore", iptablesRestore{})
+	honeyos.RegisterCommand("ip6tables-restore", iptablesRestore{v6: true})
+}
+
+// iptError is what iptables prints when it fails, with its exit status
+type iptError struct {
+	msg  string
+	code int
+}
+
+var (
+	// iptMatches are the match extensions loaded with -m
+	iptMatches = map[string]bool{
+		"addrtype": true, "comment": true, "connlimit": true, "conntrack": true, "hashlimit": true, "icmp": true,
+		"icmp6": true, "iprange": true, "length": true, "limit": true, "mac": true, "mark": true, "multiport": true,
+		"owner": true, "physdev": true, "pkttype": true, "recent": true, "set": true, "state": true, "string": true,
+		"tcp": true, "time": true, "u32": true, "udp": true,
+	}
+	// iptTargets are the target extensions besides the user chains
+	iptTargets = map[string]bool{
+		"ACCEPT": true, "CT": true, "DNAT": true, "DROP": true, "LOG": true, "MARK": true, "MASQUERADE": true,
+		"NFQUEUE": true, "NOTRACK": true, "QUEUE": true, "REDIRECT": true, "REJECT": true, "RETURN": true,
+		"SNAT": true, "TCPMSS": true, "TPROXY": true,
+	}
+	// iptTargetOptions are the options of the targets rather than the matches
+	iptTargetOptions = map[string]bool{
+		"--reject-with": true, "--log-prefix": true, "--log-level": true, "--log-tcp-sequence": true,
+		"--log-tcp-options": true, "--log-ip-options": true, "--log-uid": true, "--to-destination": true,
+		"--to-source": true, "--to-ports": true, "--to": true, "--random": true, "--persistent": true,
+		"--queue-num": true, "--queue-bypass": true, "--set-mark": true, "--set-xmark": true,
+		"--clamp-mss-to-pmtu": true, "--set-mss": true, "--on-port": true,
+	}
+	// iptFlags are the options taking no value
+	iptFlags = map[string]bool{
+		"--syn": true, "-f": true, "--fragment": true, "--log-tcp-sequence": true, "--log-tcp-options": true,
+		"--log-ip-options": true, "--log-uid": true, "--random": true, "--persistent": true,
+		"--queue-bypass": true, "--clamp-mss-to-pmtu": true, "--set": true, "--rcheck": true,
+		"--update": true, "--remove": true,
+	}
+	// iptProtoOptions are the options of the protocol matches, loaded
+	// without -m after -p
+	iptProtoOptions = map[string]string{
+		"--dport": "--dport", "--destination-port": "--dport", "--sport": "--sport", "--source-port": "--sport",
+		"--syn": "--syn", "--tcp-flags": "--tcp-flags", "--icmp-type": "--icmp-type", "--icmpv6-type": "--icmpv6-type",
+	}
+	iptLongOptions = map[string]string{
+		"--source": "-s", "--src": "-s", "--destination": "-d", "--dst": "-d", "--protocol": "-p",
+		"--in-interface": "-i", "--out-interface": "-o", "--jump": "-j", "--goto": "-g", "--match": "-m",
+		"--fragment": "-f",
+	}
+	iptCommands = map[string]string{
+		"--append": "-A", "--check": "-C", "--delete": "-D", "--insert": "-I", "--replace": "-R", "--list": "-L",
+		"--list-rules": "-S", "--flush": "-F", "--zero": "-Z", "--new-chain": "-N", "--new": "-N",
+		"--delete-chain": "-X", "--policy": "-P", "--rename-chain": "-E",
+	}
+	iptProtocols = []string{"tcp", "udp", "udplite", "icmp", "icmpv6", "ipv6-icmp", "esp", "ah", "sctp", "gre", "mh", "all"}
+)
+
+// iptOpt is an option of a rule with its values, negated with !
+type iptOpt struct {
+	neg  bool
+	name string
+	vals []string
+}
+
+// iptMatch is a match extension of a rule with its options
+type iptMatch struct {
+	name string
+	opts []iptOpt
+}
+
+// iptSpec is a rule specification
+type iptSpec struct {
+	base    map[string]iptOpt
+	matches []iptMatch
+	// jump is -j or -g
+	jump, target string
+	targetOpts   []iptOpt
+}
+
+func (t iptables) name() string {
+	if t.v6 {
+		return "ip6tables"
+	}
+	return "iptables"
+}
+
+func (t iptables) usageError(msg string) *iptError {
+	return &iptError{fmt.Sprintf("%v v1.6.0: %v\nTry `%v -h' or '%v --help' for more information.", t.name(), msg, t.name(), t.name()), 2}
+}
+
+// anywhere is the address matching all hosts
+func (t iptables) anywhere() string {
+	if t.v6 {
+		return "::/0"
+	}
+	return "0.0.0.0/0"
+}
+
+// address normalizes the address or network of the rule, like 10.0.0.1/32
+func (t iptables) address(s string) (string, bool) {
+	if s == "localhost" {
+		s = "127.0.0.1"
+		if t.v6 {
+			s = "::1"
+		}
+	}
+	if s == "0/0" {
+		return t.anywhere(), true
+	}
+	if ip := net.ParseIP(s); ip != nil {
+		if ip.To4() != nil == t.v6 {
+			return "", false
+		}
+		if t.v6 {
+			return ip.String() + "/128", true
+		}
+		return ip.String() + "/32", true
+	}
+	ip, network, err := net.ParseCIDR(s)
+	if err != nil || ip.To4() != nil == t.v6 {
+		return "", false
+	}
+	return network.String(), true
+}
+
+// iptPort normalizes the port or range of the rule to numbers, like 22 or
+// 1000:2000
+func iptPort(s string) (string, bool) {
+	ports := strings.Split(s, ":")
+	for i, p := range ports {
+		if p == "" && len(ports) == 2 {
+			continue
+		}
+		if n, err := strconv.Atoi(p); err == nil && n >= 0 && n < 65536 {
+			continue
+		}
+		found := false
+		for num, name := range services {
+			if name == p {
+				ports[i], found = num, true
+			}
+		}
+		if !found {
+			return "", false
+		}
+	}
+	return strings.Join(ports, ":"), len(ports) <= 2
+}
+
+// parseSpec parses the rule specification, or the rule as saved
+func (t iptables) parseSpec(args []string) (*iptSpec, *iptError) {
+	spec := &iptSpec{base: map[string]iptOpt{}}
+	neg := false
+	for i := 0; i < len(args); i++ {
+		name, given := args[i], args[i]
+		if name == "!" {
+			neg = true
+			continue
+		}
+		var vals []string
+		if j := strings.IndexByte(name, '='); strings.HasPrefix(name, "--") && j > 0 {
+			name, vals = name[:j], []string{name[j+1:]}
+		}
+		if long, ok := iptLongOptions[name]; ok {
+			name = long
+		}
+		if !strings.HasPrefix(name, "-") {
+			return nil, t.usageError(fmt.Sprintf("Bad argument `%v'", name))
+		}
+		if vals == nil {
+			n := 1
+			switch {
+			case iptFlags[name]:
+				n = 0
+			case name == "--tcp-flags":
+				n = 2
+			}
+			if i+n >= len(args) {
+				return nil, t.usageError(fmt.Sprintf("option \"%v\" requires an argument", args[i]))
+			}
+			vals = args[i+1 : i+1+n]
+			i += n
+		}
+		opt := iptOpt{neg, name, vals}
+		neg = false
+		switch name {
+		case "-s", "-d":
+			addr, ok := t.address(vals[0])
+			if !ok {
+				return nil, t.usageError(fmt.Sprintf("host/network `%v' not found", vals[0]))
+			}
+			if addr == t.anywhere() && !opt.neg {
+				continue
+			}
+			opt.vals = []string{addr}
+			spec.base[name] = opt
+		case "-p":
+			p := strings.ToLower(vals[0])
+			if p == "icmp" && t.v6 {
+				p = "icmpv6"
+			}
+			valid := false
+			for _, known := range iptProtocols {
+				valid = valid || p == known
+			}
+			if n, err := strconv.Atoi(p); err == nil && n >= 0 && n < 256 {
+				valid = true
+			}
+			if !valid {
+				return nil, t.usageError(fmt.Sprintf("unknown protocol \"%v\" specified", vals[0]))
+			}
+			if p == "all" {
+				continue
+			}
+			opt.vals = []string{p}
+			spec.base[name] = opt
+		case "-i", "-o", "-f":
+			spec.base[name] = opt
+		case "-m":
+			m := strings.ToLower(vals[0])
+			if !iptMatches[m] {
+				return nil, t.usageError(fmt.Sprintf("Couldn't load match `%v':No such file or directory\n", vals[0]))
+			}
+			spec.matches = append(spec.matches, iptMatch{name: m})
+		case "-j", "-g":
+			spec.jump, spec.target = name, vals[0]
+		default:
+			if iptTargetOptions[name] && spec.jump != "" {
+				spec.targetOpts = append(spec.targetOpts, opt)
+				continue
+			}
+			if canon, ok := iptProtoOptions[name]; ok {
+				proto := spec.base["-p"].vals
+				if len(proto) == 0 || !strings.Contains("tcp udp icmp icmpv6", proto[0]) {
+					return nil, t.usageError(fmt.Sprintf("unknown option \"%v\"", given))
+				}
+				opt.name = canon
+				if canon == "--dport" || canon == "--sport" {
+					port, ok := iptPort(vals[0])
+					if !ok {
+						return nil, t.usageError(fmt.Sprintf("invalid port/service `%v' specified", vals[0]))
+					}
+					opt.vals = []string{port}
+				}
+				m := proto[0]
+				if m == "icmpv6" {
+					m = "icmp6"
+				}
+				found := false
+				for j := range spec.matches {
+					if spec.matches[j].name == m {
+						spec.matches[j].opts = append(spec.matches[j].opts, opt)
+						found = true
+					}
+				}
+				if !found {
+					spec.matches = append(spec.matches, iptMatch{m, []iptOpt{opt}})
+				}
+				continue
+			}
+			if len(spec.matches) == 0 {
+				return nil, t.usageError(fmt.Sprintf("unknown option \"%v\"", given))
+			}
+			if name == "--dports" || name == "--sports" || name == "--ports" {
+				ports := strings.Split(vals[0], ",")
+				for j, p := range ports {
+					port, ok := iptPort(p)
+					if !ok {
+						return nil, t.usageError(fmt.Sprintf("invalid port/service `%v' specified", p))
+					}
+					ports[j] = port
+				}
+				opt.vals = []string{strings.Join(ports, ",")}
+			}
+			m := &spec.matches[len(spec.matches)-1]
+			m.opts = append(m.opts, opt)
+		}
+	}
+	if spec.target == "REJECT" && len(spec.targetOpts) == 0 {
+		with := "icmp-port-unreachable"
+		if t.v6 {
+			with = "icmp6-port-unreachable"
+		}
+		spec.targetOpts = []iptOpt{{name: "--reject-with", vals: []string{with}}}
+	}
+	return spec, nil
+}
+
+// String formats the rule like iptables-save
+func (s *iptSpec) String() string {
+	var parts []string
+	add := func(o iptOpt) {
+		if o.neg {
+			parts = append(parts, "!")
+		}
+		parts = append(parts, o.name)
+		for _, v := range o.vals {
+			if o.name == "--comment" || o.name == "--log-prefix" || strings.ContainsAny(v, " \t") {
+				v = strconv.Quote(v)
+			}
+			parts = append(parts, v)
+		}
+	}
+	for _, name := range []string{"-s", "-d", "-i", "-o", "-p", "-f"} {
+		if o, ok := s.base[name]; ok {
+			add(o)
+		}
+	}
+	for _, m := range s.matches {
+		parts = append(parts, "-m", m.name)
+		for _, o := range m.opts {
+			add(o)
+		}
+	}
+	if s.jump != "" {
+		parts = append(parts, s.jump, s.target)
+		for _, o := range s.targetOpts {
+			add(o)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// iptSplit splits the rule as saved into arguments, unquoting the values
+func iptSplit(rule string) []string {
+	var args []string
+	for rule = strings.TrimSpace(rule); rule != ""; rule = strings.TrimSpace(rule) {
+		if rule[0] == '"' {
+			// The value runs to the closing quote not escaped
+			end := 1
+			for end < len(rule) && rule[end] != '"' {
+				if rule[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end < len(rule) {
+				if v, err := strconv.Unquote(rule[:end+1]); err == nil {
+					args = append(args, v)
+					rule = rule[end+1:]
+					continue
+				}
+			}
+		}
+		i := strings.IndexAny(rule, " \t")
+		if i < 0 {
+			i = len(rule)
+		}
+		args = append(args, rule[:i])
+		rule = rule[i:]
+	}
+	return args
+}
+
+// iptChain finds the chain in the table
+func iptChain(chains []*honeyos.Chain, name string) *honeyos.Chain {
+	for _, c := range chains {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// iptReferences counts the rules jumping to the chain
+func iptReferences(chains []*honeyos.Chain, name string) int {
+	n := 0
+	for _, c := range chains {
+		for _, r := range c.Rules {
+			args := iptSplit(r)
+			for i := 0; i+1 < len(args); i++ {
+				if (args[i] == "-j" || args[i] == "-g") && args[i+1] == name {
+					n++
+				}
+			}
+		}
+	}
+	return n
+}
+
+// iptCounters are the packets and bytes through the builtin chain since
+// boot, of a server seeing little traffic
+func iptCounters(table, chain string) (packets, bytes int64) {
+	secs := int64(time.Since(honeyos.BootTime()).Seconds())
+	switch {
+	case table == "nat" && (chain == "PREROUTING" || chain == "INPUT"):
+		packets = secs / 40
+		bytes = packets * 60
+	case table == "nat":
+		packets = secs / 25
+		bytes = packets * 72
+	case chain == "INPUT" || chain == "PREROUTING":
+		packets = secs * 4
+		bytes = packets * 96
+	case chain == "OUTPUT" || chain == "POSTROUTING":
+		packets = secs * 3
+		bytes = packets * 142
+	}
+	return
+}
+
+// iptNum formats the counter like iptables, exact or scaled to K, M and G,
+// aligned to the table or not
+func iptNum(n int64, exact, table bool) string {
+	if exact {
+		if table {
+			return fmt.Sprintf("%8d ", n)
+		}
+		return fmt.Sprintf("%d ", n)
+	}
+	if n <= 99999 {
+		if table {
+			return fmt.Sprintf("%5d ", n)
+		}
+		return fmt.Sprintf("%d ", n)
+	}
+	suffix := 'K'
+	for _, s := range "KMGT" {
+		suffix = s
+		n = (n + 500) / 1000
+		if n <= 9999 {
+			break
+		}
+	}
+	if table {
+		return fmt.Sprintf("%4d%c ", n, suffix)
+	}
+	return fmt.Sprintf("%d%c ", n, suffix)
+}
+
+// iptArgs are the command and options given to iptables
+type iptArgs struct {
+	command, chain string
+	// params are the rule number, the target or the new name after the chain
+	params                                           []string
+	table                                            string
+	spec                                             []string
+	numeric, verbose, exact, lineNumbers, help, vers bool
+}
+
+func (t iptables) parseArgs(args []string) (*iptArgs, *iptError) {
+	a := &iptArgs{table: "filter"}
+	// Options like -nvL are combined
+	var expanded []string
+	for _, arg := range args {
+		if len(arg) > 2 && arg[0] == '-' && arg[1] != '-' && strings.Trim(arg[1:], "nvxLSFZ") == "" {
+			for _, c := range arg[1:] {
+				expanded = append(expanded, "-"+string(c))
+			}
+			continue
+		}
+		expanded = append(expanded, arg)
+	}
+	args = expanded
+	isNum := func(s string) bool {
+		_, err := strconv.Atoi(s)
+		return err == nil
+	}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if c, ok := iptCommands[arg]; ok {
+			arg = c
+		}
+		switch arg {
+		case "-A", "-C", "-D", "-I", "-R", "-L", "-S", "-F", "-Z", "-N", "-X", "-P", "-E":
+			if a.command != "" {
+				return nil, t.usageError(fmt.Sprintf("Can't use %v with %v\n", arg, a.command))
+			}
+			a.command = arg
+			// The chain and the params following, some of them optional
+			required, optionalNum := map[string]int{"-A": 1, "-C": 1, "-D": 1, "-I": 1, "-R": 2, "-N": 1, "-P": 2, "-E": 2}[arg], false
+			switch arg {
+			case "-D", "-I", "-L", "-S", "-Z":
+				optionalNum = true
+			}
+			var params []string
+			for len(params) < required && i+1 < len(args) {
+				i++
+				params = append(params, args[i])
+			}
+			if len(params) < required {
+				return nil, t.usageError(fmt.Sprintf("option \"%v\" requires an argument", args[i]))
+			}
+			if required == 0 && i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") && args[i+1] != "!" {
+				i++
+				params = append(params, args[i])
+			}
+			if optionalNum && len(params) > 0 && i+1 < len(args) && isNum(args[i+1]) {
+				i++
+				params = append(params, args[i])
+			}
+			if len(params) > 0 {
+				a.chain, a.params = params[0], params[1:]
+			}
+		case "-t", "--table":
+			if i+1 >= len(args) {
+				return nil, t.usageError("option \"-t\" requires an argument")
+			}
+			i++
+			a.table = args[i]
+		case "-n", "--numeric":
+			a.numeric = true
+		case "-v", "--verbose":
+			a.verbose = true
+		case "-x", "--exact":
+			a.exact = true
+		case "--line-numbers":
+			a.lineNumbers = true
+		case "-w", "--wait":
+			if i+1 < len(args) && isNum(args[i+1]) {
+				i++
+			}
+		case "-W", "--wait-interval":
+			i++
+		case "-4", "-6":
+		case "-h", "--help":
+			a.help = true
+		case "-V", "--version":
+			a.vers = true
+		default:
+			if !strings.HasPrefix(arg, "--modprobe") {
+				a.spec = append(a.spec, args[i])
+			}
+		}
+	}
+	return a, nil
+}
+
+func (t iptables) GetHelp() string {
+	n := t.name()
+	return n + " v1.6.0\n\n" +
+		"Usage: " + n + " -[ACD] chain rule-specification [options]\n" +
+		"       " + n + " -I chain [rulenum] rule-specification [options]\n" +
+		"       " + n + " -R chain rulenum rule-specification [options]\n" +
+		"       " + n + " -D chain rulenum [options]\n" +
+		"       " + n + " -[LS] [chain [rulenum]] [options]\n" +
+		"       " + n + " -[FZ] [chain] [options]\n" +
+		"       " + n + " -[NX] chain\n" +
+		"       " + n + " -E old-chain-name new-chain-name\n" +
+		"       " + n + " -P chain target [options]\n" +
+		"       " + n + " -h (print this help information)\n\n" +
+		"Commands:\nEither long or short options are allowed.\n" +
+		"  --append  -A chain\t\tAppend to chain\n" +
+		"  --check   -C chain\t\tCheck for the existence of a rule\n" +
+		"  --delete  -D chain\t\tDelete matching rule from chain\n" +
+		"  --delete  -D chain rulenum\n\t\t\t\tDelete rule rulenum (1 = first) from chain\n" +
+		"  --insert  -I chain [rulenum]\n\t\t\t\tInsert in chain as rulenum (default 1=first)\n" +
+		"  --replace -R chain rulenum\n\t\t\t\tReplace rule rulenum (1 = first) in chain\n" +
+		"  --list    -L [chain [rulenum]]\n\t\t\t\tList the rules in a chain or all chains\n" +
+		"  --list-rules -S [chain [rulenum]]\n\t\t\t\tPrint the rules in a chain or all chains\n" +
+		"  --flush   -F [chain]\t\tDelete all rules in  chain or all chains\n" +
+		"  --zero    -Z [chain [rulenum]]\n\t\t\t\tZero counters in chain or all chains\n" +
+		"  --new     -N chain\t\tCreate a new user-defined chain\n" +
+		"  --delete-chain\n            -X [chain]\t\tDelete a user-defined chain\n" +
+		"  --policy  -P chain target\n\t\t\t\tChange policy on chain to target\n" +
+		"  --rename-chain\n            -E old-chain new-chain\n\t\t\t\tChange chain name, (moving any references)\n" +
+		"Options:\n" +
+		"[!] --protocol\t-p proto\tprotocol: by number or name, eg. `tcp'\n" +
+		"[!] --source\t-s address[/mask][...]\n\t\t\t\tsource specification\n" +
+		"[!] --destination -d address[/mask][...]\n\t\t\t\tdestination specification\n" +
+		"[!] --in-interface -i input name[+]\n\t\t\t\tnetwork interface name ([+] for wildcard)\n" +
+		" --jump\t-j target\n\t\t\t\ttarget for rule (may load target extension)\n" +
+		"  --goto      -g chain\n                              jump to chain with no return\n" +
+		"  --match\t-m match\n\t\t\t\textended match (may load extension)\n" +
+		"  --numeric\t-n\t\tnumeric output of addresses and ports\n" +
+		"[!] --out-interface -o output name[+]\n\t\t\t\tnetwork interface name ([+] for wildcard)\n" +
+		"  --table\t-t table\ttable to manipulate (default: `filter')\n" +
+		"  --verbose\t-v\t\tverbose mode\n" +
+		"  --wait\t-w [seconds]\tmaximum wait to acquire xtables lock before give up\n" +
+		"  --line-numbers\t\tprint line numbers when listing\n" +
+		"  --exact\t-x\t\texpand numbers (display exact values)\n" +
+		"[!] --fragment\t-f\t\tmatch second or further fragments only\n" +
+		"  --modprobe=<command>\t\ttry to insert modules using this command\n" +
+		"  --set-counters PKTS BYTES\tset the counter during insert/append\n" +
+		"[!] --version\t-V\t\tprint package version.\n"
+}
+
+func (t iptables) Where() string {
+	return "/sbin/" + t.name()
+}
+
+func (t iptables) Exec(args []string, sys honeyos.Sys) int {
+	a, err := t.parseArgs(args)
+	switch {
+	case err != nil:
+	case a.help:
+		fmt.Fprint(sys.Out(), t.GetHelp())
+		return 0
+	case a.vers:
+		fmt.Fprintf(sys.Out(), "%v v1.6.0\n", t.name())
+		return 0
+	case a.command == "":
+		err = t.usageError("no command specified")
+	case !isRoot(sys):
+		err = &iptError{fmt.Sprintf("%v v1.6.0: can't initialize %v table `%v': Permission denied (you must be root)\n"+
+			"Perhaps %v or your kernel needs to be upgraded.", t.name(), t.name(), a.table, t.name()), 3}
+	default:
+		honeyos.UpdateFirewall(sys, func(ipt map[string][]*honeyos.Chain, _ *[]*honeyos.NftTable) {
+			err = t.run(sys, a, ipt)
+		})
+		if err == nil && a.command != "-L" && a.command != "-S" && a.command != "-C" {
+			t.logChange(sys, a, args)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(sys.Err(), err.msg)
+		return err.code
+	}
+	return 0
+}
+
+// logChange logs the change of the rules, flushing and opening ports as
+// warnings
+func (t iptables) logChange(sys honeyos.Sys, a *iptArgs, args []string) {
+	logger := sys.Log().WithFields(log.Fields{"table": a.table, "chain": a.chain, "args": args})
+	spec, _ := t.parseSpec(a.spec)
+	switch {
+	case a.command == "-F":
+		chain := a.chain
+		if chain == "" {
+			chain = "all chains"
+		}
+		logger.Warnf("User flushed firewall rules of %v in table %v with %v", chain, a.table, t.name())
+	case a.command == "-P" && strings.ToUpper(a.params[0]) == "ACCEPT":
+		logger.Warnf("User set firewall policy of %v to ACCEPT with %v", a.chain, t.name())
+	case (a.command == "-A" || a.command == "-I" || a.command == "-R") && spec != nil && spec.opens():
+		logger.WithField("rule", spec.String()).Warnf("User opened port with %v", t.name())
+	case a.command == "-D" || a.command == "-X":
+		logger.Warnf("User deleted firewall rules with %v", t.name())
+	default:
+		logger.Infof("User changed firewall rules with %v", t.name())
+	}
+}
+
+// opens tells if the rule lets in the traffic to some port, or forwards it
+func (s *iptSpec) opens() bool {
+	switch s.target {
+	case "DNAT", "REDIRECT":
+		return true
+	case "ACCEPT":
+		for _, m := range s.matches {
+			for _, o := range m.opts {
+				if o.name == "--dport" || o.name == "--dports" || o.name == "--ports" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// rule parses the rule for the chains of the table, checking the target
+func (t iptables) rule(spec []string, chains []*honeyos.Chain) (string, *iptError) {
+	s, err := t.parseSpec(spec)
+	if err != nil {
+		return "", err
+	}
+	if s.jump != "" && !iptTargets[s.target] {
+		if c := iptChain(chains, s.target); c == nil || c.Policy != "" {
+			return "", &iptError{t.name() + ": No chain/target/match by that name.", 1}
+		}
+	}
+	return s.String(), nil
+}
+
+func (t iptables) run(sys honeyos.Sys, a *iptArgs, ipt map[string][]*honeyos.Chain) *iptError {
+	key := a.table
+	if t.v6 {
+		key = "ip6 " + key
+	}
+	chains, ok := ipt[key]
+	if !ok {
+		return &iptError{fmt.Sprintf("%v v1.6.0: can't initialize %v table `%v': Table does not exist (do you need to insmod?)\n"+
+			"Perhaps %v or your kernel needs to be upgraded.", t.name(), t.name(), a.table, t.name()), 3}
+	}
+	noChain := &iptError{t.name() + ": No chain/target/match by that name.", 1}
+	var chain *honeyos.Chain
+	if a.chain != "" {
+		if chain = iptChain(chains, a.chain); chain == nil && a.command != "-N" {
+			return noChain
+		}
+	}
+	switch a.command {
+	case "-A", "-I", "-R", "-C", "-D", "-P", "-E":
+		// These work on a chain, which an empty name like '' is not
+		if chain == nil {
+			return noChain
+		}
+	}
+	// num is the rule number given, 0 if none
+	num := 0
+	if len(a.params) > 0 && a.command != "-P" && a.command != "-E" {
+		n, err := strconv.Atoi(a.params[0])
+		if err != nil || n < 1 {
+			return t.usageError(fmt.Sprintf("Invalid rule number `%v'", a.params[0]))
+		}
+		num = n
+	}
+	switch a.command {
+	case "-L":
+		t.list(sys, a, key, chains, chain, num)
+	case "-S":
+		for _, c := range chains {
+			if chain != nil && c != chain {
+				continue
+			}
+			if c.Policy != "" {
+				fmt.Fprintf(sys.Out(), "-P %v %v\n", c.Name, c.Policy)
+			} else {
+				fmt.Fprintf(sys.Out(), "-N %v\n", c.Name)
+			}
+		}
+		for _, c := range chains {
+			if chain != nil && c != chain {
+				continue
+			}
+			for i, r := range c.Rules {
+				if num == 0 || i+1 == num {
+					fmt.Fprintln(sys.Out(), strings.TrimSpace("-A "+c.Name+" "+r))
+				}
+			}
+		}
+	case "-A", "-I", "-R", "-C":
+		rule, err := t.rule(a.spec, chains)
+		if err != nil {
+			return err
+		}
+		switch a.command {
+		case "-A":
+			chain.Rules = append(chain.Rules, rule)
+		case "-I":
+			if num == 0 {
+				num = 1
+			}
+			if num > len(chain.Rules)+1 {
+				return &iptError{t.name() + ": Index of insertion too big.", 1}
+			}
+			chain.Rules = append(chain.Rules[:num-1], append([]string{rule}, chain.Rules[num-1:]...)...)
+		case "-R":
+			if num > len(chain.Rules) {
+				return &iptError{t.name() + ": Index of replacement too big.", 1}
+			}
+			chain.Rules[num-1] = rule
+		case "-C":
+			for _, r := range chain.Rules {
+				if r == rule {
+					return nil
+				}
+			}
+			return &iptError{t.name() + ": Bad rule (does a matching rule exist in that chain?).", 1}
+		}
+	case "-D":
+		if num > 0 {
+			if num > len(chain.Rules) {
+				return &iptError{t.name() + ": Index of deletion too big.", 1}
+			}
+			chain.Rules = append(chain.Rules[:num-1], chain.Rules[num:]...)
+			return nil
+		}
+		rule, err := t.rule(a.spec, chains)
+		if err != nil {
+			return err
+		}
+		for i, r := range chain.Rules {
+			if r == rule {
+				chain.Rules = append(chain.Rules[:i], chain.Rules[i+1:]...)
+				return nil
+			}
+		}
+		return &iptError{t.name() + ": Bad rule (does a matching rule exist in that chain?).", 1}
+	case "-F":
+		for _, c := range chains {
+			if chain == nil || c == chain {
+				c.Rules = nil
+			}
+		}
+	case "-Z":
+	case "-N":
+		if chain != nil || iptTargets[a.chain] {
+			return &iptError{t.name() + ": Chain already exists.", 1}
+		}
+		if a.chain == "" || len(a.chain) > 28 || strings.HasPrefix(a.chain, "-") || a.chain == "!" {
+			return t.usageError(fmt.Sprintf("Invalid chain name `%v'", a.chain))
+		}
+		ipt[key] = append(chains, &honeyos.Chain{Name: a.chain})
+	case "-X":
+		var kept []*honeyos.Chain
+		for _, c := range chains {
+			switch {
+			case chain != nil && c != chain:
+			case c.Policy != "" && chain != nil:
+				return &iptError{t.name() + ": Invalid argument. Run `dmesg' for more information.", 1}
+			case c.Policy != "":
+			case iptReferences(chains, c.Name) > 0:
+				return &iptError{t.name() + ": Too many links.", 1}
+			case len(c.Rules) > 0:
+				return &iptError{t.name() + ": Directory not empty.", 1}
+			default:
+				continue
+			}
+			kept = append(kept, c)
+		}
+		ipt[key] = kept
+	case "-P":
+		policy := strings.ToUpper(a.params[0])
+		if chain.Policy == "" {
+			return &iptError{t.name() + ": Bad built-in chain name.", 1}
+		}
+		if policy != "ACCEPT" && policy != "DROP" {
+			return &iptError{t.name() + ": Bad policy name. Run `dmesg' for more information.", 1}
+		}
+		chain.Policy = policy
+	case "-E":
+		if chain.Policy != "" {
+			return &iptError{t.name() + ": Invalid argument. Run `dmesg' for more information.", 1}
+		}
+		if iptChain(chains, a.params[0]) != nil {
+			return &iptError{t.name() + ": File exists.", 1}
+		}
+		for _, c := range chains {
+			for i, r := range c.Rules {
+				args := iptSplit(r)
+				for j := 0; j+1 < len(args); j++ {
+					if (args[j] == "-j" || args[j] == "-g") && args[j+1] == chain.Name {
+						args[j+1] = a.params[0]
+					}
+				}
+				if s, err := t.parseSpec(args); err == nil {
+					c.Rules[i] = s.String()
+				}
+			}
+		}
+		chain.Name = a.params[0]
+	}
+	return nil
+}
+
+// list prints the chains like iptables -L
+func (t iptables) list(sys honeyos.Sys, a *iptArgs, table string, chains []*honeyos.Chain, only *honeyos.Chain, num int) {
+	table = strings.TrimPrefix(table, "ip6 ")
+	header := ""
+	if a.lineNumbers {
+		header += "num  "
+	}
+	if a.verbose {
+		if a.exact {
+			header += fmt.Sprintf("%8s %10s ", "pkts", "bytes")
+		} else {
+			header += fmt.Sprintf("%5s %5s ", "pkts", "bytes")
+		}
+	}
+	header += fmt.Sprintf("%-9s  prot opt", "target")
+	if a.verbose {
+		header += fmt.Sprintf(" %-6s %-6s ", "in", "out")
+	}
+	header += fmt.Sprintf(" %-19s  %-19s ", "source", "destination")
+	first := true
+	for _, c := range chains {
+		if only != nil && c != only {
+			continue
+		}
+		if num == 0 {
+			if !first {
+				fmt.Fprintln(sys.Out())
+			}
+			first = false
+			if c.Policy != "" {
+				fmt.Fprintf(sys.Out(), "Chain %v (policy %v", c.Name, c.Policy)
+				if a.verbose {
+					packets, bytes := iptCounters(table, c.Name)
+					fmt.Fprintf(sys.Out(), " %vpackets, %vbytes", iptNum(packets, a.exact, false), iptNum(bytes, a.exact, false))
+				}
+				fmt.Fprintln(sys.Out(), ")")
+			} else {
+				fmt.Fprintf(sys.Out(), "Chain %v (%v references)\n", c.Name, iptReferences(chains, c.Name))
+			}
+			fmt.Fprintln(sys.Out(), header)
+		}
+		for i, r := range c.Rules {
+			if num != 0 && i+1 != num {
+				continue
+			}
+			s, err := t.parseSpec(iptSplit(r))
+			if err != nil {
+				continue
+			}
+			row := ""
+			if a.lineNumbers {
+				row += fmt.Sprintf("%-4d ", i+1)
+			}
+			if a.verbose {
+				row += iptNum(0, a.exact, true) + iptNum(0, a.exact, true)
+			}
+			row += fmt.Sprintf("%-9s ", s.target)
+			row += t.listRow(s, a.numeric, a.verbose)
+			fmt.Fprintln(sys.Out(), row)
+		}
+	}
+}
+
+// listRow formats the protocol, addresses and matches of the rule for -L
+func (t iptables) listRow(s *iptSpec, numeric, verbose bool) string {
+	var b strings.Builder
+	inv := func(o iptOpt) string {
+		if o.neg {
+			return "!"
+		}
+		return " "
+	}
+	proto := "all"
+	if p, ok := s.base["-p"]; ok {
+		b.WriteString(inv(p))
+		proto = p.vals[0]
+	} else {
+		b.WriteString(" ")
+	}
+	fmt.Fprintf(&b, "%-5s", proto)
+	if t.v6 {
+		b.WriteString("    ")
+	} else if f, ok := s.base["-f"]; ok {
+		fmt.Fprintf(&b, "%vf ", map[bool]string{true: "!", false: "-"}[f.neg])
+	} else {
+		b.WriteString("-- ")
+	}
+	if verbose {
+		iface := func(name string) string {
+			if o, ok := s.base[name]; ok {
+				return strings.TrimSpace(inv(o)) + o.vals[0]
+			}
+			return "*"
+		}
+		fmt.Fprintf(&b, " %-6s %-6s ", iface("-i"), iface("-o"))
+	}
+	for _, name := range []string{"-s", "-d"} {
+		addr, neg := t.anywhere(), " "
+		if o, ok := s.base[name]; ok {
+			addr, neg = o.vals[0], inv(o)
+		}
+		switch {
+		case addr == t.anywhere() && !numeric:
+			addr = "anywhere"
+		case strings.HasSuffix(addr, "/32") && !t.v6, strings.HasSuffix(addr, "/128"):
+			addr = addr[:strings.LastIndexByte(addr, '/')]
+		}
+		fmt.Fprintf(&b, "%v%-19s ", neg, addr)
+	}
+	port := func(p string) string {
+		if numeric {
+			return p
+		}
+		names := strings.Split(p, ",")
+		for i, n := range names {
+			if name, ok := services[n]; ok {
+				names[i] = name
+			}
+		}
+		return strings.Join(names, ",")
+	}
+	for _, m := range s.matches {
+		var extras []string
+		for _, o := range m.opts {
+			neg := ""
+			if o.neg {
+				neg = "!"
+			}
+			v := strings.Join(o.vals, " ")
+			switch o.name {
+			case "--dport", "--sport":
+				label := o.name[2:3] + "pt"
+				if strings.Contains(v, ":") {
+					label += "s"
+				}
+				extras = append(extras, label+":"+neg+port(v))
+			case "--dports", "--sports", "--ports":
+				extras = append(extras, neg+o.name[2:]+" "+port(v))
+			case "--syn":
+				extras = append(extras, "flags:"+neg+"0x17/0x02")
+			case "--tcp-flags":
+				extras = append(extras, "flags:"+neg+strings.Join(o.vals, "/"))
+			case "--icmp-type", "--icmpv6-type":
+				extras = append(extras, neg+"type "+v)
+			case "--state", "--ctstate":
+				extras = append(extras, neg+o.name[2:]+" "+v)
+			case "--comment":
+				extras = append(extras, "/* "+v+" */")
+			case "--limit":
+				extras = append(extras, "avg "+v)
+			case "--limit-burst":
+				extras = append(extras, "burst "+v)
+			case "--mac-source":
+				extras = append(extras, neg+strings.ToUpper(v))
+			default:
+				extras = append(extras, neg+o.name[2:]+" "+v)
+			}
+		}
+		name := m.name
+		switch name {
+		case "state", "conntrack", "comment":
+			name = ""
+		case "multiport":
+		case "limit":
+			name = "limit:"
+		case "mac":
+			name = "MAC"
+		}
+		b.WriteString(" " + strings.TrimSpace(name+" "+strings.Join(extras, " ")))
+	}
+	if s.jump == "-g" {
+		b.WriteString(" [goto]")
+	}
+	var extras []string
+	for _, o := range s.targetOpts {
+		v := strings.Join(o.vals, " ")
+		switch o.name {
+		case "--reject-with":
+			extras = append(extras, "reject-with "+v)
+		case "--log-prefix":
+			extras = append(extras, fmt.Sprintf("prefix %q", v))
+		case "--log-level":
+			extras = append(extras, "level "+v)
+		case "--to-destination", "--to-source", "--to":
+			extras = append(extras, "to:"+v)
+		case "--to-ports":
+			extras = append(extras, "redir ports "+v)
+		default:
+			extras = append(extras, o.name[2:]+" "+v)
+		}
+	}
+	if s.target == "LOG" {
+		extras = append([]string{"LOG flags 0 level 4"}, extras...)
+	}
+	if len(extras) > 0 {
+		b.WriteString(" " + strings.Join(extras, " "))
+	}
+	return b.String()
+}
+
+func (t iptablesSave) GetHelp() string {
+	return ""
+}
+
+func (t iptablesSave) Where() string {
+	return "/sbin/" + iptables{t.v6}.name() + "-save"
+}
+
+func (t iptablesSave) Exec(args []string, sys honeyos.Sys) int {
+	ipt := iptables{t.v6}
+	name := ipt.name() + "-save"
+	counters, only := false, ""
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-c", "--counters":
+			counters = true
+		case "-t", "--table":
+			if i+1 < len(args) {
+				i++
+				only = args[i]
+			}
+		case "-M", "--modprobe":
+			i++
+		default:
+			fmt.Fprintf(sys.Err(), "Unknown arguments found on commandline\n")
+			return 1
+		}
+	}
+	if !isRoot(sys) {
+		fmt.Fprintf(sys.Err(), "%v v1.6.0: Cannot initialize: Permission denied (you must be root)\n\n", name)
+		return 1
+	}
+	now := time.Now().Format("Mon Jan _2 15:04:05 2006")
+	var b strings.Builder
+	status := 0
+	honeyos.UpdateFirewall(sys, func(tables map[string][]*honeyos.Chain, _ *[]*honeyos.NftTable) {
+		for _, table := range []string{"security", "raw", "nat", "mangle", "filter"} {
+			key := table
+			if t.v6 {
+				key = "ip6 " + table
+			}
+			chains := tables[key]
+			// Tables are shown once loaded, filter always is
+			used := table == "filter" || table == only
+			for _, c := range chains {
+				used = used || c.Policy == "" || len(c.Rules) > 0 || c.Policy != "ACCEPT"
+			}
+			if only != "" && table != only || !used {
+				continue
+			}
+			fmt.Fprintf(&b, "# Generated by %v v1.6.0 on %v\n*%v\n", name, now, table)
+			for _, c := range chains {
+				policy := c.Policy
+				if policy == "" {
+					policy = "-"
+				}
+				packets, bytes := iptCounters(table, c.Name)
+				if c.Policy == "" {
+					packets, bytes = 0, 0
+				}
+				fmt.Fprintf(&b, ":%v %v [%v:%v]\n", c.Name, policy, packets, bytes)
+			}
+			for _, c := range chains {
+				for _, r := range c.Rules {
+					if counters {
+						b.WriteString("[0:0] ")
+					}
+					b.WriteString(strings.TrimSpace("-A "+c.Name+" "+r) + "\n")
+				}
+			}
+			fmt.Fprintf(&b, "COMMIT\n# Completed on %v\n", now)
+		}
+		if only != "" {
+			if _, ok := tables[only]; !ok {
+				status = 1
+			}
+		}
+	})
+	if status != 0 {
+		fmt.Fprintf(sys.Err(), "%v: Can't initialize table %v\n", name, only)
+		return status
+	}
+	fmt.Fprint(sys.Out(), b.String())
+	return 0
+}
+
+func (t iptablesRestore) GetHelp() string {
+	return "Usage: " + iptables{t.v6}.name() + "-restore [-c] [-v] [-t] [-h] [-n] [-w secs] [-T table] [-M command]\n" +
+		"\t   [ --counters ]\n\t   [ --verbose ]\n\t   [ --test ]\n\t   [ --help ]\n\t   [ --noflush ]\n" +
+		"\t   [ --wait=<seconds>\n\t   [ --table=<TABLE> ]\n\t   [ --modprobe=<command> ]\n"
+}
+
+func (t iptablesRestore) Where() string {
+	return "/sbin/" + iptables{t.v6}.name() + "-restore"
+}
+
+func (t iptablesRestore) Exec(args []string, sys honeyos.Sys) int {
+	ipt := iptables{t.v6}
+	name := ipt.name() + "-restore"
+	noflush, test, file := false, false, ""
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-n", "--noflush":
+			noflush = true
+		case "-t", "--test":
+			test = true
+		case "-c", "--counters", "-v", "--verbose":
+		case "-w", "-T", "-M", "--table", "--wait", "--modprobe":
+			i++
+		case "-h", "--help":
+			fmt.Fprint(sys.Out(), t.GetHelp())
+			return 0
+		default:
+			if strings.HasPrefix(args[i], "-") || file != "" {
+				fmt.Fprint(sys.Err(), t.GetHelp())
+				return 1
+			}
+			file = args[i]
+		}
+	}
+	var data []byte
+	var err error
+	if file == "" {
+		data, err = ioutil.ReadAll(sys.In())
+	} else {
+		data, err = readFile(sys, absPath(sys, file))
+	}
+	if err != nil {
+		fmt.Fprintf(sys.Err(), "Can't open %v: No such file or directory\n", file)
+		return 1
+	}
+	if !isRoot(sys) {
+		fmt.Fprintf(sys.Err(), "%v: unable to initialize table 'filter'\n\nError occurred at line: 2\n"+
+			"Try `%v -h' or '%v --help' for more information.\n", name, name, name)
+		return 1
+	}
+	// The tables are changed once all of them are read
+	staged := map[string][]*honeyos.Chain{}
+	var fail *iptError
+	honeyos.UpdateFirewall(sys, func(tables map[string][]*honeyos.Chain, _ *[]*honeyos.NftTable) {
+		var table string
+		var chains []*honeyos.Chain
+		for n, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			failed := &iptError{fmt.Sprintf("%v: line %v failed", name, n+1), 1}
+			switch {
+			case line == "" || line[0] == '#':
+			case line[0] == '*':
+				table = line[1:]
+				key := table
+				if t.v6 {
+					key = "ip6 " + key
+				}
+				current, ok := tables[key]
+				if !ok {
+					fail = failed
+					return
+				}
+				chains = nil
+				for _, c := range current {
+					if c.Policy != "" || noflush {
+						copied := &honeyos.Chain{Name: c.Name, Policy: c.Policy}
+						if noflush {
+							copied.Rules = append(copied.Rules, c.Rules...)
+						}
+						chains = append(chains, copied)
+					}
+				}
+			case table == "":
+				fail = failed
+				return
+			case line[0] == ':':
+				fields := strings.Fields(line[1:])
+				if len(fields) < 2 {
+					fail = failed
+					return
+				}
+				c := iptChain(chains, fields[0])
+				switch {
+				case c != nil && fields[1] != "-":
+					c.Policy = fields[1]
+				case c == nil && fields[1] == "-":
+					chains = append(chains, &honeyos.Chain{Name: fields[0]})
+				case c == nil:
+					fail = failed
+					return
+				}
+			case line == "COMMIT":
+				key := table
+				if t.v6 {
+					key = "ip6 " + key
+				}
+				staged[key], table = chains, ""
+			default:
+				fields := iptSplit(line)
+				if strings.HasPrefix(fields[0], "[") {
+					fields = fields[1:]
+				}
+				if len(fields) < 2 || fields[0] != "-A" && fields[0] != "-I" {
+					fail = failed
+					return
+				}
+				c := iptChain(chains, fields[1])
+				if c == nil {
+					fail = failed
+					return
+				}
+				rule, err := ipt.rule(fields[2:], chains)
+				if err != nil {
+					fail = failed
+					return
+				}
+				if fields[0] == "-I" {
+					c.Rules = append([]string{rule}, c.Rules...)
+				} else {
+					c.Rules = append(c.Rules, rule)
+				}
+			}
+		}
+		if table != "" {
+			fail = &iptError{fmt.Sprintf("%v: COMMIT expected at line %v", name, len(strings.Split(string(data), "\n"))), 1}
+			return
+		}
+		if !test {
+			for key, chains := range staged {
+				tables[key] = chains
+			}
+		}
+	})
+	if fail != nil {
+		fmt.Fprintln(sys.Err(), fail.msg)
+		return fail.code
+	}
+	if !test {
+		sys.Log().WithField("rules", string(data)).Warnf("User restored firewall rules with %v", name)
+	}
+	return 0
+}
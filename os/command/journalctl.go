@@ -0,0 +1,988 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	pathlib "path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/afero"
+	"github.com/spf13/pflag"
+)
+
+// journalctl shows the journal of systemd, made up from the boot in dmesg,
+// the daemons started, the scans sshd keeps turning away and the logins
+// in wtmp, including those to the honeypot from the real address. The
+// journal in /run only keeps what comes after the oldest file left in it, so
+// rotating and vacuuming it hides the logins
+type journalctl struct{}
+
+// journalEntry is a message in the journal
+type journalEntry struct {
+	t        time.Time
+	priority int
+	// ident is the syslog identifier, like sshd or kernel
+	ident string
+	pid   int
+	// unit is the systemd unit the message is about, e.g. ssh.service
+	unit string
+	msg  string
+}
+
+var journalPriorities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+func init() {
+	honeyos.RegisterCommand("journalctl", journalctl{})
+}
+
+func (journalctl) GetHelp() string {
+	return "journalctl [OPTIONS...] [MATCHES...]\n\n" +
+		"Query the journal.\n\n" +
+		"Flags:\n" +
+		"     --system              Show the system journal\n" +
+		"     --user                Show the user journal for the current user\n" +
+		"  -M --machine=CONTAINER   Operate on local container\n" +
+		"  -S --since=DATE          Show entries not older than the specified date\n" +
+		"  -U --until=DATE          Show entries not newer than the specified date\n" +
+		"  -c --cursor=CURSOR       Show entries starting at the specified cursor\n" +
+		"     --after-cursor=CURSOR Show entries after the specified cursor\n" +
+		"     --show-cursor         Print the cursor after all the entries\n" +
+		"  -b --boot[=ID]           Show current boot or the specified boot\n" +
+		"     --list-boots          Show terse information about recorded boots\n" +
+		"  -k --dmesg               Show kernel message log from the current boot\n" +
+		"  -u --unit=UNIT           Show logs from the specified unit\n" +
+		"     --user-unit=UNIT      Show logs from the specified user unit\n" +
+		"  -t --identifier=STRING   Show entries with the specified syslog identifier\n" +
+		"  -p --priority=RANGE      Show entries with the specified priority\n" +
+		"  -e --pager-end           Immediately jump to the end in the pager\n" +
+		"  -f --follow              Follow the journal\n" +
+		"  -n --lines[=INTEGER]     Number of journal entries to show\n" +
+		"     --no-tail             Show all lines, even in follow mode\n" +
+		"  -r --reverse             Show the newest entries first\n" +
+		"  -o --output=STRING       Change journal output mode (short, short-iso,\n" +
+		"                                   short-precise, short-monotonic, verbose,\n" +
+		"                                   export, json, json-pretty, json-sse, cat)\n" +
+		"     --utc                 Express time in Coordinated Universal Time (UTC)\n" +
+		"  -x --catalog             Add message explanations where available\n" +
+		"     --no-full             Ellipsize fields\n" +
+		"  -a --all                 Show all fields, including long and unprintable\n" +
+		"  -q --quiet               Do not show info messages and privilege warning\n" +
+		"     --no-pager            Do not pipe output into a pager\n" +
+		"  -m --merge               Show entries from all available journals\n" +
+		"  -D --directory=PATH      Show journal files from directory\n" +
+		"     --file=PATH           Show journal file\n" +
+		"     --root=ROOT           Operate on catalog files below a root directory\n" +
+		"\nCommands:\n" +
+		"  -h --help                Show this help text\n" +
+		"     --version             Show package version\n" +
+		"  -N --fields              List all field names currently used\n" +
+		"  -F --field=FIELD         List all values that a specified field takes\n" +
+		"     --disk-usage          Show total disk usage of all journal files\n" +
+		"     --vacuum-size=BYTES   Reduce disk usage below specified size\n" +
+		"     --vacuum-files=INT    Leave only the specified number of journal files\n" +
+		"     --vacuum-time=TIME    Remove journal files older than specified time\n" +
+		"     --verify              Verify journal file consistency\n" +
+		"     --sync                Synchronize unwritten journal messages to disk\n" +
+		"     --flush               Flush all journal data from /run into /var\n" +
+		"     --rotate              Request immediate rotation of the journal files\n" +
+		"     --header              Show journal header information\n" +
+		"     --list-catalog        Show all message IDs in the catalog\n" +
+		"     --dump-catalog        Show entries in the message catalog\n" +
+		"     --update-catalog      Update the message catalog database\n"
+}
+
+func (journalctl) Where() string {
+	if honeyos.Distro() == "centos" {
+		return "/usr/bin/journalctl"
+	}
+	return "/bin/journalctl"
+}
+
+// journalSystemd is the version of systemd of the distribution
+func journalSystemd() string {
+	switch honeyos.Distro() {
+	case "debian":
+		return "232"
+	case "centos":
+		return "219"
+	}
+	return "229"
+}
+
+// journalUnit is the unit of the daemon sending the message
+func journalUnit(ident string) string {
+	centos := honeyos.Distro() == "centos"
+	switch ident {
+	case "sshd":
+		if centos {
+			return "sshd.service"
+		}
+		return "ssh.service"
+	case "CRON", "cron", "crond", "CROND":
+		if centos {
+			return "crond.service"
+		}
+		return "cron.service"
+	case "systemd":
+		return "init.scope"
+	case "kernel":
+		return ""
+	}
+	return ident + ".service"
+}
+
+// journalDaemon finds the pid of the daemon started at boot by its command,
+// or the usual one if it is not running
+func journalDaemon(procs []honeyos.ProcInfo, cmd string, pid int) int {
+	for _, p := range procs {
+		if f := strings.Fields(p.Cmd); p.PPID == 1 && len(f) > 0 && pathlib.Base(f[0]) == cmd {
+			return p.PID
+		}
+	}
+	return pid
+}
+
+// journalEntries makes up the journal of the boot until now. The randomness
+// is seeded by the machine so it stays the same
+func journalEntries(sys honeyos.Sys, now time.Time) []journalEntry {
+	boot := honeyos.BootTime()
+	at := func(secs float64) time.Time {
+		return boot.Add(time.Duration(secs * float64(time.Second)))
+	}
+	var entries []journalEntry
+	var last float64
+	for _, e := range dmesgBoot(sys, now) {
+		entry := journalEntry{t: at(e.t), priority: e.level, ident: "kernel", msg: e.msg}
+		if e.facility != 0 {
+			if i := strings.Index(e.msg, ": "); i > 0 {
+				entry.ident, entry.msg = e.msg[:i], e.msg[i+2:]
+				if j := strings.IndexByte(entry.ident, '['); j > 0 {
+					entry.pid, _ = strconv.Atoi(strings.Trim(entry.ident[j:], "[]"))
+					entry.ident = entry.ident[:j]
+				}
+				entry.unit = journalUnit(entry.ident)
+			}
+		}
+		entries = append(entries, entry)
+		if e.t < 60 {
+			last = e.t
+		}
+	}
+
+	// Daemons started by systemd after the kernel is up
+	r := rand.New(rand.NewSource(int64(fnvString(sys.Hostname() + honeyos.IPAddress() + "journal"))))
+	procs := sys.Processes()
+	centos := honeyos.Distro() == "centos"
+	kernelDone := last
+	add := func(step float64, priority int, ident string, pid int, unit, format string, a ...interface{}) {
+		last += step * (0.5 + r.Float64())
+		entries = append(entries, journalEntry{t: at(last), priority: priority, ident: ident, pid: pid, unit: unit, msg: fmt.Sprintf(format, a...)})
+	}
+	started := func(step float64, unit, description string) {
+		add(step, 6, "systemd", 1, unit, "Starting %v...", description)
+		add(step, 6, "systemd", 1, unit, "Started %v.", description)
+	}
+	journald := journalDaemon(procs, "systemd-journald", 245)
+	add(0.01, 6, "systemd-journald", journald, "systemd-journald.service", "Runtime journal (/run/log/journal/%v) is 8.0M, max 99.2M, 91.2M free.", honeyos.MachineID())
+	started(0.05, "systemd-journal-flush.service", "Flush Journal to Persistent Storage")
+	started(0.02, "systemd-random-seed.service", "Load/Save Random Seed")
+	add(0.1, 6, "systemd", 1, "local-fs.target", "Reached target Local File Systems.")
+	if centos {
+		started(0.3, "network.service", "LSB: Bring up/down networking")
+	} else {
+		started(0.3, "networking.service", "Raise network interfaces")
+	}
+	add(0.01, 6, "systemd", 1, "network.target", "Reached target Network.")
+	cronUnit := journalUnit("cron")
+	if centos {
+		cron := journalDaemon(procs, "crond", 481)
+		add(0.05, 6, "systemd", 1, cronUnit, "Started Command Scheduler.")
+		add(0.01, 6, "crond", cron, cronUnit, "(CRON) INFO (RANDOM_DELAY will be scaled with factor %v%% if used.)", 10+r.Intn(90))
+		add(0.01, 6, "crond", cron, cronUnit, "(CRON) INFO (running with inotify support)")
+	} else {
+		cron := journalDaemon(procs, "cron", 811)
+		add(0.05, 6, "systemd", 1, cronUnit, "Started Regular background program processing daemon.")
+		add(0.01, 6, "cron", cron, cronUnit, "(CRON) INFO (pidfile fd = 3)")
+		add(0.01, 6, "cron", cron, cronUnit, "(CRON) INFO (Running @reboot jobs)")
+	}
+	logind := journalDaemon(procs, "systemd-logind", 815)
+	add(0.02, 6, "systemd", 1, "systemd-logind.service", "Starting Login Service...")
+	add(0.05, 6, "systemd-logind", logind, "systemd-logind.service", "New seat seat0.")
+	add(0.01, 6, "systemd", 1, "systemd-logind.service", "Started Login Service.")
+	sshdUnit, sshdName := journalUnit("sshd"), "OpenBSD Secure Shell server"
+	if centos {
+		sshdName = "OpenSSH server daemon"
+	}
+	sshd := journalDaemon(procs, "sshd", 944)
+	add(0.1, 6, "systemd", 1, sshdUnit, "Starting %v...", sshdName)
+	for _, s := range sys.Sockets() {
+		if s.State == "LISTEN" && strings.Contains(s.Program, "sshd") {
+			host, port := honeyos.SplitAddr(s.Local)
+			add(0.01, 6, "sshd", sshd, sshdUnit, "Server listening on %v port %v.", host, port)
+		}
+	}
+	add(0.01, 6, "systemd", 1, sshdUnit, "Started %v.", sshdName)
+	add(0.02, 6, "systemd", 1, "getty@tty1.service", "Started Getty on tty1.")
+	add(0.001, 6, "systemd", 1, "getty.target", "Reached target Login Prompts.")
+	add(0.01, 6, "systemd", 1, "multi-user.target", "Reached target Multi-User System.")
+	add(0.01, 6, "systemd", 1, "", "Startup finished in %.3fs (kernel) + %.3fs (userspace) = %.3fs.", kernelDone, last-kernelDone, last)
+
+	// Scans turned away by sshd and the jobs of cron, from then on
+	for t := boot.Add(time.Duration(60+r.Intn(600)) * time.Second); t.Before(now); t = t.Add(time.Duration(20+r.Intn(600))*time.Second + time.Duration(r.Intn(1e6))*time.Microsecond) {
+		kind := "auth"
+		if r.Intn(4) == 0 {
+			kind = "syslog"
+		}
+		if e, ok := journalNoise(kind, t, r); ok {
+			entries = append(entries, e)
+		}
+	}
+
+	// Logins of the administrators and the honeypot, numbered by logind
+	var records []honeyos.UtmpRecord
+	if data, err := readFile(sys, "/var/log/wtmp"); err == nil {
+		for _, rec := range honeyos.ParseUtmp(data) {
+			if rec.Time.After(boot) && (rec.Type == honeyos.UtmpUserProcess || rec.Type == honeyos.UtmpDeadProcess) {
+				records = append(records, rec)
+			}
+		}
+	}
+	records = append(records, honeyos.Sessions(sys, boot)...)
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Time.Before(records[j].Time) })
+	type session struct {
+		id   int
+		user string
+		host string
+		port string
+	}
+	sessions := map[int]session{}
+	sockets := sys.Sockets()
+	count := 0
+	for _, rec := range records {
+		add := func(t time.Time, ident string, pid int, unit, format string, a ...interface{}) {
+			entries = append(entries, journalEntry{t: t, priority: 6, ident: ident, pid: pid, unit: unit, msg: fmt.Sprintf(format, a...)})
+		}
+		if rec.Type == honeyos.UtmpUserProcess {
+			count++
+			s := session{id: count, user: rec.User, host: rec.Host, port: strconv.Itoa(30000 + int(fnvString(rec.Host+rec.Time.String())%35000))}
+			for _, sock := range sockets {
+				if sock.PID == rec.PID && sock.State == "ESTABLISHED" {
+					_, s.port = honeyos.SplitAddr(sock.Remote)
+				}
+			}
+			sessions[rec.PID] = s
+			scope := fmt.Sprintf("session-%v.scope", s.id)
+			add(rec.Time.Add(-time.Second), "sshd", rec.PID, sshdUnit, "Accepted password for %v from %v port %v ssh2", s.user, s.host, s.port)
+			add(rec.Time.Add(-time.Second), "sshd", rec.PID, sshdUnit, "pam_unix(sshd:session): session opened for user %v by (uid=0)", s.user)
+			add(rec.Time.Add(-time.Second), "systemd-logind", logind, "systemd-logind.service", "New session %v of user %v.", s.id, s.user)
+			add(rec.Time.Add(-time.Second), "systemd", 1, scope, "Started Session %v of user %v.", s.id, s.user)
+			continue
+		}
+		s, ok := sessions[rec.PID]
+		if !ok {
+			continue
+		}
+		delete(sessions, rec.PID)
+		add(rec.Time, "sshd", rec.PID, sshdUnit, "Received disconnect from %v port %v:11: disconnected by user", s.host, s.port)
+		add(rec.Time, "sshd", rec.PID, sshdUnit, "Disconnected from %v port %v", s.host, s.port)
+		add(rec.Time, "sshd", rec.PID, sshdUnit, "pam_unix(sshd:session): session closed for user %v", s.user)
+		add(rec.Time, "systemd-logind", logind, "systemd-logind.service", "Removed session %v.", s.id)
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].t.Before(entries[j].t) })
+	return entries
+}
+
+// journalNoise makes up the message logged at the time like logLine does,
+// false for those the journal has from elsewhere
+func journalNoise(kind string, t time.Time, r *rand.Rand) (journalEntry, bool) {
+	ident, msg := logMessage(kind, t, r)
+	e := journalEntry{t: t, priority: 6, ident: ident, msg: msg}
+	if i := strings.IndexByte(ident, '['); i > 0 {
+		e.pid, _ = strconv.Atoi(strings.Trim(ident[i:], "[]"))
+		e.ident = ident[:i]
+	}
+	centos := honeyos.Distro() == "centos"
+	switch {
+	case e.ident == "kernel" || e.ident == "systemd" || centos && e.ident == "systemd-timesyncd":
+		return e, false
+	case strings.Contains(msg, "authentication failure"):
+		e.priority = 5
+	}
+	switch {
+	case centos && e.ident == "CRON":
+		e.ident = "CROND"
+	case e.ident == "systemd-timesyncd":
+		// The daemon keeps running unlike the jobs of cron
+		e.pid = 560
+	}
+	e.unit = journalUnit(e.ident)
+	return e, true
+}
+
+// journalFiles finds the journal files left and the time of the oldest
+// entry in them. Files which are not journals are skipped like journalctl
+// does
+func journalFiles(sys honeyos.Sys) (files []string, head time.Time) {
+	dir := pathlib.Dir(honeyos.JournalPath())
+	infos, err := afero.ReadDir(sys.FSys(), dir)
+	if err != nil {
+		return nil, head
+	}
+	for _, fi := range infos {
+		if fi.IsDir() || !strings.HasSuffix(fi.Name(), ".journal") {
+			continue
+		}
+		data, err := readFile(sys, pathlib.Join(dir, fi.Name()))
+		if err != nil {
+			continue
+		}
+		t, ok := honeyos.ParseJournal(data)
+		if !ok {
+			continue
+		}
+		files = append(files, pathlib.Join(dir, fi.Name()))
+		if head.IsZero() || t.Before(head) {
+			head = t
+		}
+	}
+	return files, head
+}
+
+// journalTime parses the time given to --since and --until
+func journalTime(s string, now time.Time) (time.Time, bool) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch s {
+	case "now":
+		return now, true
+	case "today":
+		return today, true
+	case "yesterday":
+		return today.AddDate(0, 0, -1), true
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), true
+	}
+	// Relative times like -1h, "30 min ago" or +2days
+	rel := strings.TrimSpace(s)
+	sign := time.Duration(1)
+	switch {
+	case strings.HasSuffix(rel, " ago"):
+		rel, sign = strings.TrimSpace(strings.TrimSuffix(rel, " ago")), -1
+	case strings.HasPrefix(rel, "-"):
+		rel, sign = rel[1:], -1
+	case strings.HasPrefix(rel, "+"):
+		rel = rel[1:]
+	default:
+		rel = ""
+	}
+	if rel != "" {
+		if d, ok := journalSpan(rel); ok {
+			return now.Add(sign * d), true
+		}
+		return time.Time{}, false
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02", "15:04:05", "15:04"} {
+		t, err := time.ParseInLocation(layout, s, now.Location())
+		if err != nil {
+			continue
+		}
+		if !strings.HasPrefix(layout, "2006") {
+			t = today.Add(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second)
+		}
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// journalSpan parses the time span like 2d, 1h 30min or 10 weeks
+func journalSpan(s string) (time.Duration, bool) {
+	units := []struct {
+		names []string
+		d     time.Duration
+	}{
+		{[]string{"seconds", "second", "sec", "s"}, time.Second},
+		{[]string{"minutes", "minute", "min", "m"}, time.Minute},
+		{[]string{"hours", "hour", "hr", "h"}, time.Hour},
+		{[]string{"days", "day", "d"}, 24 * time.Hour},
+		{[]string{"weeks", "week", "w"}, 7 * 24 * time.Hour},
+		{[]string{"months", "month", "M"}, 30 * 24 * time.Hour},
+		{[]string{"years", "year", "y"}, 365 * 24 * time.Hour},
+	}
+	var total time.Duration
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, false
+	}
+	for s != "" {
+		i := 0
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		if i == 0 {
+			return 0, false
+		}
+		n, _ := strconv.Atoi(s[:i])
+		s = strings.TrimLeft(s[i:], " ")
+		j := 0
+		for j < len(s) && (s[j] >= 'a' && s[j] <= 'z' || s[j] >= 'A' && s[j] <= 'Z') {
+			j++
+		}
+		unit, found := time.Second, j == 0
+		for _, u := range units {
+			for _, name := range u.names {
+				if name == s[:j] {
+					unit, found = u.d, true
+				}
+			}
+		}
+		if !found {
+			return 0, false
+		}
+		total += time.Duration(n) * unit
+		s = strings.TrimLeft(s[j:], " ")
+	}
+	return total, true
+}
+
+// journalPriority parses the priority given to -p, by name or number
+func journalPriority(s string) (int, bool) {
+	if n, err := strconv.Atoi(s); err == nil && n >= 0 && n < len(journalPriorities) {
+		return n, true
+	}
+	for i, name := range journalPriorities {
+		if name == s {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (j journalctl) Exec(args []string, sys honeyos.Sys) int {
+	if honeyos.Distro() == "alpine" {
+		// No systemd on Alpine
+		return honeyos.CommandNotFound(sys, append([]string{"journalctl"}, args...))
+	}
+	// -n and -b take the value in the next argument if it looks like one
+	var fixed []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if i+1 < len(args) {
+			next := args[i+1]
+			_, err := strconv.Atoi(next)
+			if (arg == "-n" || arg == "--lines") && (err == nil || next == "all") ||
+				(arg == "-b" || arg == "--boot") && (err == nil || len(next) == 32) {
+				fixed = append(fixed, arg+"="+next)
+				i++
+				continue
+			}
+		}
+		fixed = append(fixed, arg)
+	}
+
+	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	flag.Bool("system", false, "Show the system journal")
+	user := flag.Bool("user", false, "Show the user journal for the current user")
+	flag.StringP("machine", "M", "", "Operate on local container")
+	since := flag.StringP("since", "S", "", "Show entries not older than the specified date")
+	until := flag.StringP("until", "U", "", "Show entries not newer than the specified date")
+	flag.StringP("cursor", "c", "", "Show entries starting at the specified cursor")
+	flag.String("after-cursor", "", "Show entries after the specified cursor")
+	flag.Bool("show-cursor", false, "Print the cursor after all the entries")
+	bootID := flag.StringP("boot", "b", "", "Show current boot or the specified boot")
+	flag.Lookup("boot").NoOptDefVal = "0"
+	listBoots := flag.Bool("list-boots", false, "Show terse information about recorded boots")
+	dmesgOnly := flag.BoolP("dmesg", "k", false, "Show kernel message log from the current boot")
+	units := flag.StringArrayP("unit", "u", nil, "Show logs from the specified unit")
+	flag.StringArray("user-unit", nil, "Show logs from the specified user unit")
+	idents := flag.StringArrayP("identifier", "t", nil, "Show entries with the specified syslog identifier")
+	priority := flag.StringP("priority", "p", "", "Show entries with the specified priority")
+	pagerEnd := flag.BoolP("pager-end", "e", false, "Immediately jump to the end in the pager")
+	follow := flag.BoolP("follow", "f", false, "Follow the journal")
+	lines := flag.StringP("lines", "n", "", "Number of journal entries to show")
+	flag.Lookup("lines").NoOptDefVal = "10"
+	noTail := flag.Bool("no-tail", false, "Show all lines, even in follow mode")
+	reverse := flag.BoolP("reverse", "r", false, "Show the newest entries first")
+	output := flag.StringP("output", "o", "short", "Change journal output mode")
+	utc := flag.Bool("utc", false, "Express time in Coordinated Universal Time (UTC)")
+	flag.BoolP("catalog", "x", false, "Add message explanations where available")
+	flag.Bool("no-full", false, "Ellipsize fields")
+	flag.BoolP("full", "l", false, "Show entries in full")
+	flag.BoolP("all", "a", false, "Show all fields, including long and unprintable")
+	quiet := flag.BoolP("quiet", "q", false, "Do not show info messages and privilege warning")
+	noPager := flag.Bool("no-pager", false, "Do not pipe output into a pager")
+	flag.BoolP("merge", "m", false, "Show entries from all available journals")
+	directory := flag.StringP("directory", "D", "", "Show journal files from directory")
+	files := flag.StringArray("file", nil, "Show journal file")
+	flag.String("root", "", "Operate on catalog files below a root directory")
+	help := flag.BoolP("help", "h", false, "Show this help text")
+	version := flag.Bool("version", false, "Show package version")
+	listFields := flag.BoolP("fields", "N", false, "List all field names currently used")
+	field := flag.StringP("field", "F", "", "List all values that a specified field takes")
+	diskUsage := flag.Bool("disk-usage", false, "Show total disk usage of all journal files")
+	vacuumSize := flag.String("vacuum-size", "", "Reduce disk usage below specified size")
+	vacuumFiles := flag.String("vacuum-files", "", "Leave only the specified number of journal files")
+	vacuumTime := flag.String("vacuum-time", "", "Remove journal files older than specified time")
+	verify := flag.Bool("verify", false, "Verify journal file consistency")
+	flag.Bool("sync", false, "Synchronize unwritten journal messages to disk")
+	flush := flag.Bool("flush", false, "Flush all journal data from /run into /var")
+	rotate := flag.Bool("rotate", false, "Request immediate rotation of the journal files")
+	header := flag.Bool("header", false, "Show journal header information")
+	flag.Bool("list-catalog", false, "Show all message IDs in the catalog")
+	flag.Bool("dump-catalog", false, "Show entries in the message catalog")
+	flag.Bool("update-catalog", false, "Update the message catalog database")
+	if err := flag.Parse(fixed); err != nil {
+		msg := err.Error()
+		switch {
+		case strings.HasPrefix(msg, "unknown shorthand flag: "):
+			msg = fmt.Sprintf("invalid option -- '%v'", strings.SplitN(strings.TrimPrefix(msg, "unknown shorthand flag: "), " ", 2)[0][1:2])
+		case strings.HasPrefix(msg, "unknown flag: "):
+			msg = fmt.Sprintf("unrecognized option '%v'", strings.TrimPrefix(msg, "unknown flag: "))
+		}
+		fmt.Fprintf(sys.Err(), "journalctl: %v\n", msg)
+		return 1
+	}
+	switch {
+	case *help:
+		fmt.Fprint(sys.Out(), j.GetHelp())
+		return 0
+	case *version:
+		fmt.Fprintf(sys.Out(), "systemd %v\n+PAM +AUDIT +SELINUX +IMA +APPARMOR +SMACK +SYSVINIT +UTMP +LIBCRYPTSETUP +GCRYPT +GNUTLS +ACL +XZ -LZ4 +SECCOMP +BLKID +ELFUTILS +KMOD -IDN\n", journalSystemd())
+		return 0
+	}
+	now := time.Now()
+	loc := time.Local
+	if *utc {
+		loc = time.UTC
+	}
+
+	// Cleaning the journal is what hides the logins, so it is logged
+	if *rotate || *flush || *vacuumSize != "" || *vacuumFiles != "" || *vacuumTime != "" {
+		if !isRoot(sys) {
+			if *rotate || *flush {
+				fmt.Fprintln(sys.Err(), "Failed to connect to bus: Permission denied")
+				return 1
+			}
+			fmt.Fprintln(sys.Err(), "Vacuuming done, freed 0B of archived journals on disk.")
+			return 0
+		}
+		var older time.Duration
+		if *vacuumTime != "" {
+			d, ok := journalSpan(*vacuumTime)
+			if !ok {
+				fmt.Fprintf(sys.Err(), "Failed to parse vacuum time: %v\n", *vacuumTime)
+				return 1
+			}
+			older = d
+		}
+		if *vacuumFiles != "" {
+			if _, err := strconv.Atoi(*vacuumFiles); err != nil {
+				fmt.Fprintf(sys.Err(), "Failed to parse vacuum files: %v\n", *vacuumFiles)
+				return 1
+			}
+		}
+		logger := sys.Log().WithField("args", args)
+		if *rotate {
+			logger.Infof("User rotated the journal with journalctl")
+			// The active file is archived and a new one is started
+			active := honeyos.JournalPath()
+			if data, err := readFile(sys, active); err == nil {
+				if head, ok := honeyos.ParseJournal(data); ok {
+					archived := pathlib.Join(pathlib.Dir(active), fmt.Sprintf("system@%x-%016x-%016x.journal",
+						fnvString(active+now.String()), 1+fnvString(head.String())%100000, head.UnixNano()/1000))
+					afero.WriteFile(sys.FSys(), archived, honeyos.EncodeJournal(head, now), 0640)
+				}
+			}
+			sys.FSys().MkdirAll(pathlib.Dir(active), 0755)
+			afero.WriteFile(sys.FSys(), active, honeyos.EncodeJournal(now, now), 0640)
+		}
+		if *vacuumSize != "" || *vacuumFiles != "" || *vacuumTime != "" {
+			logger.Warnf("User vacuumed the journal with journalctl")
+			paths, _ := journalFiles(sys)
+			var freed int
+			for _, p := range paths {
+				data, _ := readFile(sys, p)
+				head, _ := honeyos.ParseJournal(data)
+				if !strings.Contains(pathlib.Base(p), "@") || *vacuumTime != "" && head.After(now.Add(-older)) {
+					continue
+				}
+				if sys.FSys().Remove(p) == nil {
+					fmt.Fprintf(sys.Err(), "Deleted archived journal %v (8.0M).\n", p)
+					freed += 8
+				}
+			}
+			if freed > 0 {
+				fmt.Fprintf(sys.Err(), "Vacuuming done, freed %v.0M of archived journals on disk.\n", freed)
+			} else {
+				fmt.Fprintln(sys.Err(), "Vacuuming done, freed 0B of archived journals on disk.")
+			}
+		}
+		return 0
+	}
+
+	if !isRoot(sys) || *user {
+		if !*quiet {
+			fmt.Fprint(sys.Err(), "Hint: You are currently not seeing messages from other users and the system.\n"+
+				"      Users in the 'systemd-journal' group can see all messages. Pass -q to\n"+
+				"      turn off this notice.\n")
+		}
+		fmt.Fprintln(sys.Err(), "No journal files were opened due to insufficient permissions.")
+		return 1
+	}
+	paths, head := journalFiles(sys)
+	if boot := honeyos.BootTime(); head.Before(boot) {
+		// The journal in /run is gone with the reboot
+		head = boot
+	}
+	switch {
+	case *directory != "" || len(*files) > 0:
+		// Journals elsewhere are not made up
+		fmt.Fprintln(sys.Out(), "-- No entries --")
+		return 0
+	case *diskUsage:
+		fmt.Fprintf(sys.Out(), "Archived and active journals take up %v.0M on disk.\n", 8*len(paths))
+		return 0
+	case *listFields:
+		for _, f := range []string{"_BOOT_ID", "_MACHINE_ID", "_HOSTNAME", "_TRANSPORT", "PRIORITY", "SYSLOG_IDENTIFIER", "_PID", "_SYSTEMD_UNIT", "MESSAGE"} {
+			fmt.Fprintln(sys.Out(), f)
+		}
+		return 0
+	case *header:
+		for i, p := range paths {
+			data, _ := readFile(sys, p)
+			head, _ := honeyos.ParseJournal(data)
+			if i > 0 {
+				fmt.Fprintln(sys.Out())
+			}
+			fmt.Fprintf(sys.Out(), "File Path: %v\nMachine ID: %v\nBoot ID: %v\nState: ONLINE\nCompatible Flags:\nIncompatible Flags: COMPRESSED-XZ\n"+
+				"Head Realtime Timestamp: %v\nFile Size: 8.0M\n", p, honeyos.MachineID(), honeyos.BootID(), head.In(loc).Format("Mon 2006-01-02 15:04:05 MST"))
+		}
+		return 0
+	case *verify:
+		for _, p := range paths {
+			fmt.Fprintf(sys.Err(), "PASS: %v\n", p)
+		}
+		return 0
+	case *listBoots:
+		fmt.Fprintf(sys.Out(), " 0 %v %v—%v\n", honeyos.BootID(), honeyos.BootTime().In(loc).Format("Mon 2006-01-02 15:04:05 MST"),
+			now.In(loc).Format("Mon 2006-01-02 15:04:05 MST"))
+		return 0
+	case len(paths) == 0:
+		fmt.Fprintln(sys.Err(), "No journal files were found.")
+		fmt.Fprintln(sys.Out(), "-- No entries --")
+		return 0
+	}
+	if *bootID != "" && *bootID != "0" && *bootID != "-0" && *bootID != honeyos.BootID() {
+		fmt.Fprintf(sys.Err(), "Data from the specified boot (%v) is not available: No such boot ID in journal\n", *bootID)
+		return 1
+	}
+
+	// Filters
+	var from, to time.Time
+	for _, t := range []struct {
+		arg string
+		at  *time.Time
+	}{{*since, &from}, {*until, &to}} {
+		if t.arg == "" {
+			continue
+		}
+		at, ok := journalTime(t.arg, now.In(loc))
+		if !ok {
+			fmt.Fprintf(sys.Err(), "Failed to parse timestamp: %v\n", t.arg)
+			return 1
+		}
+		*t.at = at
+	}
+	maxPriority := 7
+	if *priority != "" {
+		p := *priority
+		if i := strings.Index(p, ".."); i >= 0 {
+			p = p[i+2:]
+		}
+		var ok bool
+		if maxPriority, ok = journalPriority(p); !ok {
+			fmt.Fprintf(sys.Err(), "Unknown log level %v\n", *priority)
+			return 1
+		}
+	}
+	unitSet := map[string]bool{}
+	for _, u := range *units {
+		if !strings.Contains(u, ".") {
+			u += ".service"
+		}
+		// sshd.service is an alias of ssh.service on Debian
+		if u == "sshd.service" || u == "ssh.service" {
+			u = journalUnit("sshd")
+		}
+		unitSet[u] = true
+	}
+	matches := map[string][]string{}
+	for _, m := range flag.Args() {
+		switch {
+		case m == "+":
+		case strings.HasPrefix(m, "/"):
+			matches["_COMM"] = append(matches["_COMM"], pathlib.Base(m))
+		case strings.Contains(m, "="):
+			kv := strings.SplitN(m, "=", 2)
+			matches[kv[0]] = append(matches[kv[0]], kv[1])
+		default:
+			fmt.Fprintf(sys.Err(), "Failed to add match '%v': Invalid argument\n", m)
+			fmt.Fprintln(sys.Err(), "Failed to add filters: Invalid argument")
+			return 1
+		}
+	}
+	in := func(v string, list []string) bool {
+		for _, s := range list {
+			if s == v {
+				return true
+			}
+		}
+		return false
+	}
+	keep := func(e journalEntry) bool {
+		if e.t.Before(head) || !from.IsZero() && e.t.Before(from) || !to.IsZero() && e.t.After(to) ||
+			e.priority > maxPriority || *dmesgOnly && e.ident != "kernel" || len(unitSet) > 0 && !unitSet[e.unit] {
+			return false
+		}
+		if len(*idents) > 0 && !in(e.ident, *idents) {
+			return false
+		}
+		for key, values := range matches {
+			var v string
+			switch key {
+			case "_COMM", "SYSLOG_IDENTIFIER":
+				v = e.ident
+			case "_PID", "SYSLOG_PID":
+				v = strconv.Itoa(e.pid)
+			case "_SYSTEMD_UNIT", "UNIT":
+				v = e.unit
+			case "PRIORITY":
+				v = strconv.Itoa(e.priority)
+			case "_HOSTNAME":
+				v = sys.Hostname()
+			case "_BOOT_ID":
+				v = honeyos.BootID()
+			case "_TRANSPORT":
+				v = "syslog"
+				if e.ident == "kernel" {
+					v = "kernel"
+				}
+			}
+			if !in(v, values) {
+				return false
+			}
+		}
+		return true
+	}
+
+	all := journalEntries(sys, now)
+	if *field != "" {
+		seen := map[string]bool{}
+		for _, e := range all {
+			var v string
+			switch *field {
+			case "_SYSTEMD_UNIT", "UNIT":
+				v = e.unit
+			case "_COMM", "SYSLOG_IDENTIFIER":
+				v = e.ident
+			case "_PID":
+				v = strconv.Itoa(e.pid)
+			case "PRIORITY":
+				v = strconv.Itoa(e.priority)
+			}
+			if v != "" && !seen[v] && !e.t.Before(head) {
+				seen[v] = true
+				fmt.Fprintln(sys.Out(), v)
+			}
+		}
+		return 0
+	}
+	var shown []journalEntry
+	for _, e := range all {
+		if keep(e) {
+			shown = append(shown, e)
+		}
+	}
+	n := -1
+	switch {
+	case *lines == "all" || *noTail:
+	case *lines != "":
+		var err error
+		if n, err = strconv.Atoi(*lines); err != nil || n < 0 {
+			fmt.Fprintf(sys.Err(), "Failed to parse lines '%v'\n", *lines)
+			return 1
+		}
+	case *follow:
+		n = 10
+	case *pagerEnd:
+		n = 1000
+	}
+	if n >= 0 && len(shown) > n {
+		shown = shown[len(shown)-n:]
+	}
+	if *reverse {
+		for i, k := 0, len(shown)-1; i < k; i, k = i+1, k-1 {
+			shown[i], shown[k] = shown[k], shown[i]
+		}
+	}
+
+	host := strings.SplitN(sys.Hostname(), ".", 2)[0]
+	format, ok := journalFormat(*output, host, loc)
+	if !ok {
+		fmt.Fprintf(sys.Err(), "Unknown output format '%v'.\n", *output)
+		return 1
+	}
+	var b strings.Builder
+	if strings.HasPrefix(*output, "short") || *output == "verbose" {
+		// The journal begins and ends with the entries left in it
+		var first, last time.Time
+		for _, e := range all {
+			if !e.t.Before(head) {
+				if first.IsZero() {
+					first = e.t
+				}
+				last = e.t
+			}
+		}
+		if !first.IsZero() {
+			fmt.Fprintf(&b, "-- Logs begin at %v, end at %v. --\n", first.In(loc).Format("Mon 2006-01-02 15:04:05 MST"),
+				last.In(loc).Format("Mon 2006-01-02 15:04:05 MST"))
+		}
+		if len(shown) == 0 {
+			b.WriteString("-- No entries --\n")
+		}
+	}
+	for _, e := range shown {
+		b.WriteString(format(e))
+	}
+	text := b.String()
+	if !*follow {
+		if out := strings.Count(text, "\n"); *noPager || out < sys.Height() || !honeyos.IsTerminal(sys.Out()) {
+			fmt.Fprint(sys.Out(), text)
+		} else {
+			pager{prompt: func(top, bottom, total int) string {
+				return fmt.Sprintf("lines %v-%v/%v %v%%", top+1, bottom, total, pagerPercent(bottom, total))
+			}, endPrompt: func(top, total int) string {
+				return fmt.Sprintf("lines %v-%v/%v (END)", top+1, total, total)
+			}, end: "(END)"}.page(text, sys)
+		}
+		return 0
+	}
+
+	// New messages keep coming from the scans while following
+	fmt.Fprint(sys.Out(), text)
+	r := rand.New(rand.NewSource(now.UnixNano()))
+	for {
+		if !pkgSleep(sys, time.Duration(500+r.Intn(8000))*time.Millisecond) {
+			return 130
+		}
+		kind := "auth"
+		if r.Intn(4) == 0 {
+			kind = "syslog"
+		}
+		if e, ok := journalNoise(kind, time.Now(), r); ok && keep(e) {
+			fmt.Fprint(sys.Out(), format(e))
+		}
+	}
+}
+
+// journalFormat returns the function writing the entry in the output mode
+// given to -o, false if there is no such mode
+func journalFormat(mode, host string, loc *time.Location) (func(e journalEntry) string, bool) {
+	ident := func(e journalEntry) string {
+		if e.pid != 0 {
+			return fmt.Sprintf("%v[%v]", e.ident, e.pid)
+		}
+		return e.ident
+	}
+	fields := func(e journalEntry) [][2]string {
+		f := [][2]string{
+			{"__REALTIME_TIMESTAMP", strconv.FormatInt(e.t.UnixNano()/1000, 10)},
+			{"__MONOTONIC_TIMESTAMP", strconv.FormatInt(int64(e.t.Sub(honeyos.BootTime())/time.Microsecond), 10)},
+			{"_BOOT_ID", honeyos.BootID()},
+			{"PRIORITY", strconv.Itoa(e.priority)},
+			{"_MACHINE_ID", honeyos.MachineID()},
+			{"_HOSTNAME", host},
+		}
+		if e.ident == "kernel" {
+			return append(f, [2]string{"_TRANSPORT", "kernel"}, [2]string{"SYSLOG_IDENTIFIER", "kernel"}, [2]string{"MESSAGE", e.msg})
+		}
+		f = append(f, [2]string{"_TRANSPORT", "syslog"}, [2]string{"SYSLOG_IDENTIFIER", e.ident})
+		if e.pid != 0 {
+			f = append(f, [2]string{"_PID", strconv.Itoa(e.pid)})
+		}
+		if e.unit != "" {
+			f = append(f, [2]string{"_SYSTEMD_UNIT", e.unit})
+		}
+		return append(f, [2]string{"MESSAGE", e.msg})
+	}
+	switch mode {
+	case "short":
+		return func(e journalEntry) string {
+			return fmt.Sprintf("%v %v %v: %v\n", e.t.In(loc).Format("Jan 02 15:04:05"), host, ident(e), e.msg)
+		}, true
+	case "short-iso":
+		return func(e journalEntry) string {
+			return fmt.Sprintf("%v %v %v: %v\n", e.t.In(loc).Format("2006-01-02T15:04:05-0700"), host, ident(e), e.msg)
+		}, true
+	case "short-precise":
+		return func(e journalEntry) string {
+			return fmt.Sprintf("%v %v %v: %v\n", e.t.In(loc).Format("Jan 02 15:04:05.000000"), host, ident(e), e.msg)
+		}, true
+	case "short-monotonic":
+		return func(e journalEntry) string {
+			return fmt.Sprintf("[%12.6f] %v %v: %v\n", e.t.Sub(honeyos.BootTime()).Seconds(), host, ident(e), e.msg)
+		}, true
+	case "cat":
+		return func(e journalEntry) string {
+			return e.msg + "\n"
+		}, true
+	case "verbose", "export":
+		return func(e journalEntry) string {
+			var b strings.Builder
+			indent := "    "
+			if mode == "verbose" {
+				fmt.Fprintf(&b, "%v [s=%x;b=%v;m=%x;t=%x]\n", e.t.In(loc).Format("Mon 2006-01-02 15:04:05.000000 MST"),
+					fnvString(honeyos.MachineID()), honeyos.BootID(), int64(e.t.Sub(honeyos.BootTime())/time.Microsecond), e.t.UnixNano()/1000)
+			} else {
+				indent = ""
+			}
+			for _, f := range fields(e) {
+				if mode == "verbose" && strings.HasPrefix(f[0], "__") {
+					continue
+				}
+				fmt.Fprintf(&b, "%v%v=%v\n", indent, f[0], f[1])
+			}
+			if mode == "export" {
+				b.WriteString("\n")
+			}
+			return b.String()
+		}, true
+	case "json", "json-pretty", "json-sse":
+		return func(e journalEntry) string {
+			var parts []string
+			for _, f := range fields(e) {
+				key, _ := json.Marshal(f[0])
+				value, _ := json.Marshal(f[1])
+				if mode == "json-pretty" {
+					parts = append(parts, fmt.Sprintf("\t%s : %s", key, value))
+				} else {
+					parts = append(parts, fmt.Sprintf("%s : %s", key, value))
+				}
+			}
+			switch mode {
+			case "json-pretty":
+				return "{\n" + strings.Join(parts, ",\n") + "\n}\n"
+			case "json-sse":
+				return "data: { " + strings.Join(parts, ", ") + " }\n\n"
+			}
+			return "{ " + strings.Join(parts, ", ") + " }\n"
+		}, true
+	}
+	return nil, false
+}
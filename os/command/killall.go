@@ -0,0 +1,358 @@
+package command
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+)
+
+type killall struct{}
+
+// pkill is pkill and pgrep, which select the processes the same way
+type pkill struct {
+	name string
+}
+
+func init() {
+	honeyos.RegisterCommand("killall", killall{})
+	honeyos.RegisterCommand("pkill", pkill{"pkill"})
+	honeyos.RegisterCommand("pgrep", pkill{"pgrep"})
+}
+
+func (killall) GetHelp() string {
+	return `Usage: killall [ -Z CONTEXT ] [ -u USER ] [ -y TIME ] [ -o TIME ] [ -eIgiqrvw ]
+               [ -s SIGNAL | -SIGNAL ] NAME...
+       killall -l, --list
+       killall -V, --version
+
+  -e,--exact          require exact match for very long names
+  -I,--ignore-case    case insensitive process name match
+  -g,--process-group  kill process group instead of process
+  -y,--younger-than   kill processes younger than TIME
+  -o,--older-than     kill processes older than TIME
+  -i,--interactive    ask for confirmation before killing
+  -l,--list           list all known signal names
+  -q,--quiet          don't print complaints
+  -r,--regexp         interpret NAME as an extended regular expression
+  -s,--signal SIGNAL  send this signal instead of SIGTERM
+  -u,--user USER      kill only process(es) running as USER
+  -v,--verbose        report if the signal was successfully sent
+  -V,--version        display version information
+  -w,--wait           wait for processes to die
+  -n,--ns PID         match processes that belong to the same namespaces
+                      as PID
+`
+}
+
+func (killall) Where() string {
+	return "/usr/bin/killall"
+}
+
+func (k killall) Exec(args []string, sys honeyos.Sys) int {
+	sig := 15
+	var exact, quiet, verbose, ignoreCase, isRegexp bool
+	var user string
+	var names []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-l" || arg == "--list":
+			for n := 1; n <= 31; n++ {
+				fmt.Fprint(sys.Out(), honeyos.SignalName(n))
+				if n%16 == 0 || n == 31 {
+					fmt.Fprintln(sys.Out())
+				} else {
+					fmt.Fprint(sys.Out(), " ")
+				}
+			}
+			return 0
+		case arg == "-V" || arg == "--version":
+			fmt.Fprintln(sys.Err(), "killall (PSmisc) 23.4")
+			return 0
+		case arg == "--help":
+			fmt.Fprint(sys.Err(), k.GetHelp())
+			return 0
+		case arg == "-s" || arg == "--signal" || arg == "-u" || arg == "--user":
+			if i+1 >= len(args) {
+				fmt.Fprint(sys.Err(), k.GetHelp())
+				return 1
+			}
+			i++
+			if arg == "-u" || arg == "--user" {
+				user = args[i]
+				continue
+			}
+			n, ok := honeyos.ParseSignal(args[i])
+			if !ok {
+				fmt.Fprintf(sys.Err(), "%v: unknown signal; killall -l lists signals.\n", args[i])
+				return 1
+			}
+			sig = n
+		case arg == "-e" || arg == "--exact":
+			exact = true
+		case arg == "-q" || arg == "--quiet":
+			quiet = true
+		case arg == "-v" || arg == "--verbose":
+			verbose = true
+		case arg == "-I" || arg == "--ignore-case":
+			ignoreCase = true
+		case arg == "-r" || arg == "--regexp":
+			isRegexp = true
+		case arg == "-i" || arg == "-w" || arg == "-g" || arg == "--wait" || arg == "--interactive":
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			n, ok := honeyos.ParseSignal(arg[1:])
+			if !ok {
+				fmt.Fprintf(sys.Err(), "%v: unknown signal; killall -l lists signals.\n", arg[1:])
+				return 1
+			}
+			sig = n
+		default:
+			names = append(names, arg)
+		}
+	}
+	if len(names) == 0 {
+		fmt.Fprint(sys.Err(), k.GetHelp())
+		return 1
+	}
+	status := 0
+	for _, name := range names {
+		alertKill(sys, name, sig)
+		match := func(comm string) bool {
+			if ignoreCase {
+				comm, name = strings.ToLower(comm), strings.ToLower(name)
+			}
+			// Kernel keeps only the first 15 characters of the names
+			if !exact && len(name) > 15 && len(comm) == 15 {
+				return strings.HasPrefix(name, comm)
+			}
+			return comm == name
+		}
+		if isRegexp {
+			re, err := regexp.Compile(name)
+			if err != nil {
+				fmt.Fprintf(sys.Err(), "Bad regular expression: %v\n", name)
+				return 1
+			}
+			match = re.MatchString
+		}
+		found := false
+		for _, p := range sys.Processes() {
+			comm := p.Comm()
+			if len(comm) > 15 {
+				comm = comm[:15]
+			}
+			if !match(comm) || user != "" && p.User != user {
+				continue
+			}
+			found = true
+			switch err := honeyos.Kill(sys, p.PID, sig); {
+			case err == syscall.EPERM:
+				if !quiet {
+					fmt.Fprintf(sys.Err(), "%v(%v): Operation not permitted\n", name, p.PID)
+				}
+				status = 1
+			case err == nil && verbose:
+				fmt.Fprintf(sys.Out(), "Killed %v(%v) with signal %v\n", p.Comm(), p.PID, sig)
+			}
+		}
+		if !found {
+			if !quiet {
+				fmt.Fprintf(sys.Err(), "%v: no process found\n", name)
+			}
+			status = 1
+		}
+	}
+	return status
+}
+
+func (p pkill) GetHelp() string {
+	action := "signal"
+	if p.name == "pgrep" {
+		action = "list"
+	}
+	return fmt.Sprintf(`
+Usage:
+ %v [options] <pattern>
+
+Options:
+ -<sig>, --signal <sig>    signal to send (either number or name)
+ -e, --echo                display what is killed
+ -c, --count               count of matching processes
+ -f, --full                use full process name to match
+ -l, --list-name           %v PID and process name
+ -a, --list-full           %v PID and full command line
+ -n, --newest              select most recently started
+ -o, --oldest              select least recently started
+ -u, --euid <ID,...>       match by effective IDs
+ -x, --exact               match exactly with the command name
+
+ -h, --help     display this help and exit
+ -V, --version  output version information and exit
+
+For more details see pgrep(1).
+`, p.name, action, action)
+}
+
+func (p pkill) Where() string {
+	return "/usr/bin/" + p.name
+}
+
+func (p pkill) Exec(args []string, sys honeyos.Sys) int {
+	sig := 15
+	var full, exact, count, listName, listFull, newest, oldest, echo bool
+	var users []string
+	var pattern string
+	usage := func(msg string) int {
+		fmt.Fprintf(sys.Err(), "%v: %v\nTry `%v --help' for more information.\n", p.name, msg, p.name)
+		return 2
+	}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-h" || arg == "--help":
+			fmt.Fprint(sys.Out(), p.GetHelp())
+			return 0
+		case arg == "-V" || arg == "--version":
+			fmt.Fprintf(sys.Out(), "%v from procps-ng 3.3.17\n", p.name)
+			return 0
+		case arg == "-f" || arg == "--full":
+			full = true
+		case arg == "-x" || arg == "--exact":
+			exact = true
+		case arg == "-c" || arg == "--count":
+			count = true
+		case arg == "-l" || arg == "--list-name":
+			listName = true
+		case arg == "-a" || arg == "--list-full":
+			listFull = true
+		case arg == "-n" || arg == "--newest":
+			newest = true
+		case arg == "-o" || arg == "--oldest":
+			oldest = true
+		case arg == "-e" || arg == "--echo":
+			echo = true
+		case arg == "-u" || arg == "--euid" || arg == "-U" || arg == "--uid" || arg == "--signal":
+			if i+1 >= len(args) {
+				return usage("option requires an argument -- '" + strings.TrimLeft(arg, "-")[:1] + "'")
+			}
+			i++
+			if arg == "--signal" {
+				n, ok := honeyos.ParseSignal(args[i])
+				if !ok {
+					return usage("Unknown signal \"" + args[i] + "\".")
+				}
+				sig = n
+				continue
+			}
+			users = append(users, strings.Split(args[i], ",")...)
+		case strings.HasPrefix(arg, "-") && len(arg) > 1 && p.name == "pkill":
+			n, ok := honeyos.ParseSignal(arg[1:])
+			if !ok {
+				return usage("invalid option -- '" + arg[1:2] + "'")
+			}
+			sig = n
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			return usage("invalid option -- '" + arg[1:2] + "'")
+		case pattern != "":
+			return usage("only one pattern can be provided")
+		default:
+			pattern = arg
+		}
+	}
+	if pattern == "" && len(users) == 0 {
+		return usage("no matching criteria specified")
+	}
+	if exact {
+		pattern = "^(" + pattern + ")$"
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		fmt.Fprintf(sys.Err(), "%v: cannot compile regular expression '%v': %v\n", p.name, pattern, err)
+		return 2
+	}
+	if p.name == "pkill" {
+		alertKill(sys, pattern, sig)
+	}
+	self := strings.TrimSpace(p.name + " " + strings.Join(args, " "))
+	var matched []honeyos.ProcInfo
+	for _, proc := range sys.Processes() {
+		text := proc.Comm()
+		if full {
+			text = proc.Cmd
+		} else if len(text) > 15 {
+			text = text[:15]
+		}
+		if proc.Cmd == self || !re.MatchString(text) {
+			continue
+		}
+		if len(users) > 0 && !hasUser(users, proc.User) {
+			continue
+		}
+		matched = append(matched, proc)
+	}
+	if len(matched) > 0 && (newest || oldest) {
+		// Processes are ordered by pid, which is in order of starting
+		pick := matched[0]
+		for _, proc := range matched {
+			if newest && proc.Start.After(pick.Start) || oldest && proc.Start.Before(pick.Start) {
+				pick = proc
+			}
+		}
+		matched = []honeyos.ProcInfo{pick}
+	}
+	if count {
+		fmt.Fprintln(sys.Out(), len(matched))
+	}
+	status := 1
+	for _, proc := range matched {
+		if p.name == "pgrep" {
+			status = 0
+			switch {
+			case count:
+			case listFull:
+				fmt.Fprintf(sys.Out(), "%v %v\n", proc.PID, proc.Cmd)
+			case listName:
+				fmt.Fprintf(sys.Out(), "%v %v\n", proc.PID, proc.Comm())
+			default:
+				fmt.Fprintln(sys.Out(), proc.PID)
+			}
+			continue
+		}
+		switch err := honeyos.Kill(sys, proc.PID, sig); err {
+		case nil:
+			status = 0
+			if echo {
+				fmt.Fprintf(sys.Out(), "%v killed (pid %v)\n", proc.Comm(), proc.PID)
+			}
+		case syscall.EPERM:
+			fmt.Fprintf(sys.Err(), "pkill: killing pid %v failed: Operation not permitted\n", proc.PID)
+			status = 3
+		}
+	}
+	return status
+}
+
+func hasUser(users []string, name string) bool {
+	for _, u := range users {
+		if u == name {
+			return true
+		}
+		if uid, err := strconv.Atoi(u); err == nil && honeyos.GetUserByID(uid).Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// alertKill logs the attempt to kill the security agents or miners by
+// name, whether or not they are running here
+func alertKill(sys honeyos.Sys, name string, sig int) {
+	if kind := honeyos.ProcessKind(name); kind != "" {
+		sys.Log().WithField("pattern", name).WithField("signal", honeyos.SignalName(sig)).
+			Warnf("User trying to kill %v process %v", kind, name)
+	}
+}
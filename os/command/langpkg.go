@@ -0,0 +1,218 @@
+package command
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/afero"
+)
+
+// langPkg is a package of the package manager of a language, pip, npm or
+// gem. size is in kB
+type langPkg struct {
+	name, version string
+	size          int
+	deps          []string
+	// native packages are built from source
+	native bool
+}
+
+// pipCatalog are the Python packages attackers install, by lower case name.
+// The others are made up
+var pipCatalog = langCatalog([]langPkg{
+	{"paramiko", "2.7.2", 206, []string{"bcrypt", "cryptography", "pynacl"}, false},
+	{"cryptography", "3.3.2", 2640, []string{"cffi", "six"}, false},
+	{"bcrypt", "3.1.7", 56, []string{"cffi", "six"}, false},
+	{"PyNaCl", "1.4.0", 961, []string{"cffi", "six"}, false},
+	{"cffi", "1.15.1", 390, []string{"pycparser"}, false},
+	{"pycparser", "2.21", 118, nil, false},
+	{"six", "1.16.0", 11, nil, false},
+	{"pycryptodome", "3.10.1", 1880, nil, false},
+	{"pycryptodomex", "3.10.1", 1880, nil, false},
+	{"pycrypto", "2.6.1", 446, nil, true},
+	{"requests", "2.25.1", 61, []string{"chardet", "idna", "urllib3", "certifi"}, false},
+	{"chardet", "4.0.0", 178, nil, false},
+	{"idna", "2.10", 58, nil, false},
+	{"urllib3", "1.26.4", 153, nil, false},
+	{"certifi", "2020.12.5", 147, nil, false},
+	{"scapy", "2.4.5", 1057, nil, true},
+	{"impacket", "0.9.22", 1425, []string{"pyasn1", "pycryptodomex", "pyOpenSSL", "six", "ldap3"}, false},
+	{"pyasn1", "0.4.8", 77, nil, false},
+	{"pyOpenSSL", "20.0.1", 54, []string{"cryptography", "six"}, false},
+	{"ldap3", "2.9", 432, []string{"pyasn1"}, false},
+	{"pysocks", "1.7.1", 16, nil, false},
+	{"dnspython", "1.16.0", 188, nil, false},
+	{"psutil", "5.8.0", 470, nil, true},
+	{"pexpect", "4.8.0", 59, []string{"ptyprocess"}, false},
+	{"ptyprocess", "0.7.0", 13, nil, false},
+	{"colorama", "0.4.4", 16, nil, false},
+	{"termcolor", "1.1.0", 4, nil, true},
+	{"click", "7.1.2", 82, nil, false},
+	{"shodan", "1.25.0", 44, []string{"click", "colorama", "requests"}, false},
+	{"beautifulsoup4", "4.9.3", 115, []string{"soupsieve"}, false},
+	{"soupsieve", "1.9.6", 33, nil, false},
+	{"lxml", "4.6.3", 5535, nil, false},
+	{"PyMySQL", "0.10.1", 47, nil, false},
+	{"redis", "3.5.3", 72, nil, false},
+	{"pymongo", "3.11.4", 497, nil, false},
+	{"pwntools", "4.5.1", 9932, []string{"paramiko", "pyelftools", "requests", "psutil", "six"}, false},
+	{"pyelftools", "0.27", 151, nil, false},
+})
+
+// npmCatalog are the Node.js packages attackers install
+var npmCatalog = langCatalog([]langPkg{
+	{"axios", "0.21.1", 54, []string{"follow-redirects"}, false},
+	{"follow-redirects", "1.14.0", 13, nil, false},
+	{"request", "2.88.2", 54, []string{"aws-sign2", "forever-agent", "form-data", "tough-cookie", "uuid"}, false},
+	{"aws-sign2", "0.7.0", 4, nil, false},
+	{"forever-agent", "0.6.1", 4, nil, false},
+	{"form-data", "2.3.3", 8, []string{"mime-types"}, false},
+	{"mime-types", "2.1.30", 5, []string{"mime-db"}, false},
+	{"mime-db", "1.47.0", 26, nil, false},
+	{"tough-cookie", "2.5.0", 28, nil, false},
+	{"uuid", "3.4.0", 14, nil, false},
+	{"ws", "7.4.6", 27, nil, false},
+	{"express", "4.17.1", 54, []string{"body-parser", "cookie", "debug"}, false},
+	{"body-parser", "1.19.0", 17, []string{"debug"}, false},
+	{"cookie", "0.4.0", 8, nil, false},
+	{"debug", "2.6.9", 16, []string{"ms"}, false},
+	{"ms", "2.0.0", 3, nil, false},
+	{"socks", "2.6.1", 26, []string{"ip", "smart-buffer"}, false},
+	{"ip", "1.1.5", 7, nil, false},
+	{"smart-buffer", "4.1.0", 22, nil, false},
+	{"ssh2", "0.8.9", 48, []string{"ssh2-streams"}, false},
+	{"ssh2-streams", "0.4.10", 98, []string{"asn1", "bcrypt-pbkdf", "streamsearch"}, false},
+	{"asn1", "0.2.4", 6, nil, false},
+	{"bcrypt-pbkdf", "1.0.2", 10, nil, false},
+	{"streamsearch", "0.1.2", 3, nil, false},
+	{"puppeteer", "5.5.0", 496, []string{"debug", "ws"}, false},
+	{"node-pty", "0.10.1", 112, nil, true},
+	{"pm2", "4.5.6", 410, []string{"debug", "ws"}, false},
+})
+
+// gemCatalog are the Ruby gems attackers install
+var gemCatalog = langCatalog([]langPkg{
+	{"net-ssh", "6.1.0", 126, nil, false},
+	{"net-scp", "3.0.0", 25, []string{"net-ssh"}, false},
+	{"net-ping", "2.0.8", 23, nil, false},
+	{"nokogiri", "1.10.10", 9145, []string{"mini_portile2"}, true},
+	{"mini_portile2", "2.4.0", 21, nil, false},
+	{"rest-client", "2.1.0", 51, []string{"http-accept", "http-cookie", "mime-types", "netrc"}, false},
+	{"http-accept", "1.7.0", 10, nil, false},
+	{"http-cookie", "1.0.3", 20, []string{"domain_name"}, false},
+	{"domain_name", "0.5.20190701", 66, []string{"unf"}, false},
+	{"unf", "0.1.4", 5, nil, false},
+	{"mime-types", "3.3.1", 23, []string{"mime-types-data"}, false},
+	{"mime-types-data", "3.2021.0225", 113, nil, false},
+	{"netrc", "0.11.0", 8, nil, false},
+	{"httparty", "0.18.1", 43, []string{"mime-types", "multi_xml"}, false},
+	{"multi_xml", "0.6.0", 9, nil, false},
+	{"colorize", "0.8.1", 11, nil, false},
+	{"msgpack", "1.4.2", 224, nil, true},
+	{"bundler", "1.17.3", 347, nil, false},
+})
+
+func langCatalog(pkgs []langPkg) map[string]langPkg {
+	catalog := map[string]langPkg{}
+	for _, p := range pkgs {
+		catalog[strings.ToLower(p.name)] = p
+	}
+	return catalog
+}
+
+// langLookup returns the package of the catalog, or makes up one so the
+// packages not in the catalog install too
+func langLookup(catalog map[string]langPkg, name string) langPkg {
+	if p, ok := catalog[strings.ToLower(name)]; ok {
+		return p
+	}
+	h := fnvString(strings.ToLower(name))
+	return langPkg{name: name, version: fmt.Sprintf("%v.%v.%v", h%3, h>>8%20, h>>16%10), size: int(8 + h>>24%400)}
+}
+
+// langResolve returns the packages requested and their dependencies, in the
+// order the package manager collects them. Packages given a version are
+// installed at that version
+func langResolve(catalog map[string]langPkg, names []string, versions map[string]string) []langPkg {
+	var pkgs []langPkg
+	seen := map[string]bool{}
+	queue := append([]string{}, names...)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if seen[strings.ToLower(name)] {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+		p := langLookup(catalog, name)
+		if v := versions[strings.ToLower(name)]; v != "" {
+			p.version = v
+		}
+		pkgs = append(pkgs, p)
+		queue = append(queue, p.deps...)
+	}
+	return pkgs
+}
+
+// langDownload waits for the download of the package, returning false if
+// interrupted
+func langDownload(sys honeyos.Sys, p langPkg) bool {
+	return pkgSleep(sys, time.Duration(120+p.size/3)*time.Millisecond)
+}
+
+// langBuild waits for the package to be built from source, returning false
+// if interrupted
+func langBuild(sys honeyos.Sys, p langPkg) bool {
+	return pkgSleep(sys, time.Duration(800+p.size*2)*time.Millisecond)
+}
+
+// langSpeed is the download rate shown for the package, in kB/s
+func langSpeed(p langPkg) float64 {
+	return float64(800 + fnvString(p.name)%4000)
+}
+
+// langInstalled lists the names of the packages recorded in the directory,
+// as the package manager names the entries like name-version.suffix
+func langInstalled(sys honeyos.Sys, dir, suffix string) map[string]string {
+	installed := map[string]string{}
+	infos, err := afero.ReadDir(sys.FSys(), dir)
+	if err != nil {
+		return installed
+	}
+	for _, fi := range infos {
+		entry := strings.TrimSuffix(fi.Name(), suffix)
+		i := strings.LastIndex(entry, "-")
+		if i <= 0 || suffix != "" && entry == fi.Name() {
+			continue
+		}
+		installed[entry[:i]] = entry[i+1:]
+	}
+	return installed
+}
+
+// langSorted returns the names of the map sorted case insensitively
+func langSorted(m map[string]string) []string {
+	var names []string
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return strings.ToLower(names[i]) < strings.ToLower(names[j]) })
+	return names
+}
+
+// langMkdir creates the directory of the package, owned by the user
+func langMkdir(sys honeyos.Sys, dirs ...string) {
+	for _, dir := range dirs {
+		sys.FSys().MkdirAll(dir, 0777&^sys.Umask())
+	}
+}
+
+// langWrite writes the file of the package, creating its directory
+func langWrite(sys honeyos.Sys, name, content string) {
+	langMkdir(sys, path.Dir(name))
+	afero.WriteFile(sys.FSys(), name, []byte(content), 0666&^sys.Umask())
+}
@@ -0,0 +1,209 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/afero"
+	"github.com/spf13/pflag"
+)
+
+// last lists the logins and boots in wtmp, newest first. The history made up
+// for the persona is followed by the logins to the honeypot, so the shell
+// of the attacker shows up as still logged in until wtmp is cleaned
+type last struct{}
+
+func init() {
+	honeyos.RegisterCommand("last", last{})
+}
+
+func (last) GetHelp() string {
+	return "\nUsage:\n last [options] [<username>...] [<tty>...]\n\n" +
+		"Show a listing of last logged in users.\n\nOptions:\n" +
+		" -<number>            how many lines to show\n" +
+		" -a, --hostlast       display hostnames in the last column\n" +
+		" -d, --dns            translate the IP number back into a hostname\n" +
+		" -f, --file <file>    use a specific file instead of /var/log/wtmp\n" +
+		" -F, --fulltimes      print full login and logout times and dates\n" +
+		" -i, --ip             display IP numbers in numbers-and-dots notation\n" +
+		" -n, --limit <number> how many lines to show\n" +
+		" -R, --nohostname     don't display the hostname field\n" +
+		" -w, --fullnames      display full user and domain names\n" +
+		" -x, --system         display system shutdown entries and run level changes\n\n" +
+		" -h, --help     display this help and exit\n" +
+		" -V, --version  output version information and exit\n\n" +
+		"For more details see last(1).\n"
+}
+
+func (last) Where() string {
+	return "/usr/bin/last"
+}
+
+// lastDuration formats how long a session or boot lasted, like (00:12) or
+// (3+04:05)
+func lastDuration(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	mins := int(d.Minutes())
+	if mins >= 24*60 {
+		return fmt.Sprintf(" (%d+%02d:%02d)", mins/(24*60), mins/60%24, mins%60)
+	}
+	return fmt.Sprintf(" (%02d:%02d)", mins/60, mins%60)
+}
+
+func (l last) Exec(args []string, sys honeyos.Sys) int {
+	// -<number> is the same as -n <number>
+	args = append([]string{}, args...)
+	for i, a := range args {
+		if len(a) > 1 && a[0] == '-' && strings.Trim(a[1:], "0123456789") == "" {
+			args[i] = "-n" + a[1:]
+		}
+	}
+	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	hostLast := flag.BoolP("hostlast", "a", false, "display hostnames in the last column")
+	flag.BoolP("dns", "d", false, "translate the IP number back into a hostname")
+	file := flag.StringP("file", "f", "/var/log/wtmp", "use a specific file instead of /var/log/wtmp")
+	fullTimes := flag.BoolP("fulltimes", "F", false, "print full login and logout times and dates")
+	ip := flag.BoolP("ip", "i", false, "display IP numbers in numbers-and-dots notation")
+	limit := flag.IntP("limit", "n", 0, "how many lines to show")
+	noHost := flag.BoolP("nohostname", "R", false, "don't display the hostname field")
+	fullNames := flag.BoolP("fullnames", "w", false, "display full user and domain names")
+	system := flag.BoolP("system", "x", false, "display system shutdown entries and run level changes")
+	help := flag.BoolP("help", "h", false, "display this help and exit")
+	version := flag.BoolP("version", "V", false, "output version information and exit")
+	if err := flag.Parse(args); err != nil {
+		msg := strings.Replace(err.Error(), "unknown shorthand flag: ", "invalid option -- ", 1)
+		if i := strings.Index(msg, " in -"); i >= 0 {
+			msg = msg[:i]
+		}
+		fmt.Fprintf(sys.Err(), "last: %v\nTry 'last --help' for more information.\n", msg)
+		return 1
+	}
+	switch {
+	case *help:
+		fmt.Fprint(sys.Out(), l.GetHelp())
+		return 0
+	case *version:
+		fmt.Fprintln(sys.Out(), "last from util-linux 2.27.1")
+		return 0
+	}
+	name := absPath(sys, *file)
+	data, err := afero.ReadFile(sys.FSys(), name)
+	fi, statErr := sys.FSys().Stat(name)
+	if err != nil || statErr != nil {
+		reason := "No such file or directory"
+		if os.IsPermission(err) {
+			reason = "Permission denied"
+		}
+		fmt.Fprintf(sys.Err(), "last: cannot open %v: %v\n", *file, reason)
+		return 1
+	}
+	records := honeyos.ParseUtmp(data)
+	begin := fi.ModTime()
+	if len(records) > 0 {
+		begin = records[0].Time
+	}
+	if name == "/var/log/wtmp" {
+		records = append(records, honeyos.Sessions(sys, begin)...)
+		sort.SliceStable(records, func(i, j int) bool { return records[i].Time.Before(records[j].Time) })
+	}
+
+	timeFormat, clockFormat := "Mon Jan _2 15:04", "15:04"
+	if *fullTimes {
+		timeFormat, clockFormat = "Mon Jan _2 15:04:05 2006", "Mon Jan _2 15:04:05 2006"
+	}
+	field := func(s string, width int) string {
+		if *fullNames && len(s) > width {
+			width = len(s)
+		}
+		return fmt.Sprintf("%-*.*s", width, width, s)
+	}
+	printed := 0
+	show := func(user, line, host string, login time.Time, logout, duration string) {
+		if operands := flag.Args(); len(operands) > 0 {
+			found := false
+			for _, o := range operands {
+				found = found || o == user || o == line || "tty"+o == line
+			}
+			if !found {
+				return
+			}
+		}
+		if *ip && net.ParseIP(host) == nil {
+			host = "0.0.0.0"
+		}
+		cols := []string{field(user, 8), field(line, 12)}
+		if !*noHost && !*hostLast {
+			cols = append(cols, field(host, 16))
+		}
+		if logout != "  still" {
+			logout = fmt.Sprintf("%-*s", len(clockFormat)+2, logout)
+		}
+		cols = append(cols, fmt.Sprintf("%-*s", len(timeFormat), login.Format(timeFormat)), logout, duration)
+		if !*noHost && *hostLast {
+			cols = append(cols, host)
+		}
+		fmt.Fprintln(sys.Out(), strings.TrimRight(strings.Join(cols, " "), " "))
+		printed++
+	}
+
+	// Walking back, a login ends at the logout following it on the same
+	// line, or at the shutdown or the crash before the next boot
+	logouts := map[string]time.Time{}
+	var down time.Time
+	shutdown := false
+	for i := len(records) - 1; i >= 0 && (*limit <= 0 || printed < *limit); i-- {
+		r := records[i]
+		until := func(t time.Time) (string, string) {
+			if t.IsZero() {
+				return "  still", "running"
+			}
+			return "- " + t.Format(clockFormat), lastDuration(t.Sub(r.Time))
+		}
+		switch r.Type {
+		case honeyos.UtmpDeadProcess:
+			logouts[r.Line] = r.Time
+		case honeyos.UtmpUserProcess:
+			end, ok := logouts[r.Line]
+			delete(logouts, r.Line)
+			switch {
+			case ok:
+				show(r.User, r.Line, r.Host, r.Time, "- "+end.Format(clockFormat), lastDuration(end.Sub(r.Time)))
+			case down.IsZero():
+				show(r.User, r.Line, r.Host, r.Time, "  still", "logged in")
+			case shutdown:
+				show(r.User, r.Line, r.Host, r.Time, "- down", lastDuration(down.Sub(r.Time)))
+			default:
+				show(r.User, r.Line, r.Host, r.Time, "- crash", lastDuration(down.Sub(r.Time)))
+			}
+		case honeyos.UtmpBootTime:
+			logout, duration := until(down)
+			show("reboot", "system boot", r.Host, r.Time, logout, duration)
+			down, shutdown = r.Time, false
+			logouts = map[string]time.Time{}
+		case honeyos.UtmpRunLevel:
+			if r.User == "shutdown" {
+				if *system {
+					logout, duration := until(down)
+					show("shutdown", "system down", r.Host, r.Time, logout, duration)
+				}
+				down, shutdown = r.Time, true
+				logouts = map[string]time.Time{}
+			} else if *system {
+				logout, duration := until(down)
+				show("runlevel", fmt.Sprintf("(to lvl %c)", r.PID%256), r.Host, r.Time, logout, duration)
+			}
+		}
+	}
+	fmt.Fprintf(sys.Out(), "\n%v begins %v\n", path.Base(name), begin.Format("Mon Jan _2 15:04:05 2006"))
+	return 0
+}
@@ -0,0 +1,159 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+)
+
+// less pages the file or the input in the alternate screen, searching with
+// / and ? and telling the position in the prompt
+type less struct{}
+
+func init() {
+	honeyos.RegisterCommand("less", less{})
+}
+
+func (less) GetHelp() string {
+	return "Missing filename (\"less --help\" for help)\n"
+}
+
+func (less) Where() string {
+	return "/usr/bin/less"
+}
+
+func (l less) Exec(args []string, sys honeyos.Sys) int {
+	p := pager{end: "(END)", altScreen: true}
+	var files []string
+	numbers, quitShort, longPrompt, percentPrompt := false, false, false, false
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			if !strings.HasPrefix(arg, "+") {
+				files = append(files, arg)
+			}
+			continue
+		}
+		if strings.HasPrefix(arg, "--") {
+			switch arg {
+			case "--help":
+				fmt.Fprint(sys.Out(), "\n                   SUMMARY OF LESS COMMANDS\n\n      Commands marked with * may be preceded by a number, N.\n"+
+					"      Notes in parentheses indicate the behavior if N is given.\n\n"+
+					"  h  H                 Display this help.\n  q  :q  Q  :Q  ZZ     Exit.\n")
+				return 0
+			case "--version":
+				fmt.Fprintln(sys.Out(), "less 481 (GNU regular expressions)\nCopyright (C) 1984-2015  Mark Nudelman")
+				return 0
+			case "--LINE-NUMBERS":
+				numbers = true
+			case "--ignore-case", "--IGNORE-CASE":
+				p.foldCase = true
+			case "--no-init":
+				p.altScreen = false
+			case "--quit-if-one-screen":
+				quitShort = true
+			case "--LONG-PROMPT":
+				longPrompt = true
+			case "--long-prompt":
+				percentPrompt = true
+			}
+			continue
+		}
+		for _, c := range arg[1:] {
+			switch c {
+			case 'N':
+				numbers = true
+			case 'i', 'I':
+				p.foldCase = true
+			case 'X':
+				p.altScreen = false
+			case 'F':
+				quitShort = true
+			case 'E', 'e':
+				p.end = ""
+			case 'M':
+				longPrompt = true
+			case 'm':
+				percentPrompt = true
+			case 'R', 'r', 'S', 's', 'f', 'K', 'q', 'Q', 'n', 'w', 'c', 'C', 'g', 'G', 'J', 'a':
+			default:
+				fmt.Fprintf(sys.Err(), "There is no -%c option (\"less --help\" for help)\n", c)
+				return 1
+			}
+		}
+	}
+
+	name := ""
+	var data []byte
+	switch {
+	case len(files) > 0 && files[0] != "-":
+		name = files[0]
+		fi, err := sys.FSys().Stat(absPath(sys, name))
+		if err == nil && fi.IsDir() {
+			fmt.Fprintf(sys.Err(), "%v is a directory\n", name)
+			return 1
+		}
+		if data, err = readFile(sys, absPath(sys, name)); err != nil {
+			reason := "No such file or directory"
+			if os.IsPermission(err) {
+				reason = "Permission denied"
+			}
+			fmt.Fprintf(sys.Err(), "%v: %v\n", name, reason)
+			return 1
+		}
+	case honeyos.IsTerminal(sys.In()) && len(files) == 0:
+		fmt.Fprint(sys.Err(), l.GetHelp())
+		return 1
+	default:
+		data, _ = ioutil.ReadAll(sys.In())
+	}
+
+	text := string(data)
+	if numbers {
+		lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+		for i := range lines {
+			lines[i] = fmt.Sprintf("%7d %v", i+1, lines[i])
+		}
+		text = strings.Join(lines, "\n") + "\n"
+	}
+	if quitShort {
+		// The text fitting the screen is written out like cat
+		lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+		if p.topOf(lines, len(lines), sys.Width(), sys.Height()-1) == 0 {
+			p.end = ""
+		}
+	}
+	first := true
+	p.prompt = func(top, bottom, total int) string {
+		defer func() { first = false }()
+		switch {
+		case longPrompt:
+			s := fmt.Sprintf("lines %d-%d/%d %d%%", top+1, bottom, total, pagerPercent(bottom, total))
+			if name != "" {
+				s = name + " " + s
+			}
+			return s
+		case percentPrompt:
+			if first && name != "" {
+				return fmt.Sprintf("%v %d%%", name, pagerPercent(bottom, total))
+			}
+			return fmt.Sprintf("%d%%", pagerPercent(bottom, total))
+		case first && name != "":
+			return name
+		}
+		return ":"
+	}
+	if longPrompt && p.end != "" {
+		p.endPrompt = func(top, total int) string {
+			s := fmt.Sprintf("lines %d-%d/%d (END)", top+1, total, total)
+			if name != "" {
+				s = name + " " + s
+			}
+			return s
+		}
+	}
+	p.page(text, sys)
+	return 0
+}
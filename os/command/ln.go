@@ -0,0 +1,255 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	pathlib "path"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+)
+
+type ln struct{}
+
+// lnOptions are the options of ln
+type lnOptions struct {
+	symbolic, force, interactive, noDeref bool
+	verbose, relative, noTarget           bool
+	target                                string
+}
+
+func init() {
+	honeyos.RegisterCommand("ln", ln{})
+}
+
+func (ln) GetHelp() string {
+	return `Usage: ln [OPTION]... [-T] TARGET LINK_NAME
+  or:  ln [OPTION]... TARGET
+  or:  ln [OPTION]... TARGET... DIRECTORY
+  or:  ln [OPTION]... -t DIRECTORY TARGET...
+In the 1st form, create a link to TARGET with the name LINK_NAME.
+In the 2nd form, create a link to TARGET in the current directory.
+In the 3rd and 4th forms, create links to each TARGET in DIRECTORY.
+Create hard links by default, symbolic links with --symbolic.
+By default, each destination (name of new link) should not already exist.
+When creating hard links, each TARGET must exist.  Symbolic links
+can hold arbitrary text; if later resolved, a relative link is
+interpreted in relation to its parent directory.
+
+Mandatory arguments to long options are mandatory for short options too.
+      --backup[=CONTROL]      make a backup of each existing destination file
+  -b                          like --backup but does not accept an argument
+  -d, -F, --directory         allow the superuser to attempt to hard link
+                                directories (note: will probably fail due to
+                                system restrictions, even for the superuser)
+  -f, --force                 remove existing destination files
+  -i, --interactive           prompt whether to remove destinations
+  -L, --logical               dereference TARGETs that are symbolic links
+  -n, --no-dereference        treat LINK_NAME as a normal file if
+                                it is a symbolic link to a directory
+  -P, --physical              make hard links directly to symbolic links
+  -r, --relative              create symbolic links relative to link location
+  -s, --symbolic              make symbolic links instead of hard links
+  -S, --suffix=SUFFIX         override the usual backup suffix
+  -t, --target-directory=DIRECTORY  specify the DIRECTORY in which to create
+                                the links
+  -T, --no-target-directory   treat LINK_NAME as a normal file always
+  -v, --verbose               print name of each linked file
+      --help     display this help and exit
+      --version  output version information and exit
+
+GNU coreutils online help: <https://www.gnu.org/software/coreutils/>
+Full documentation at: <https://www.gnu.org/software/coreutils/ln>
+or available locally via: info '(coreutils) ln invocation'
+`
+}
+
+func (ln) Where() string {
+	return "/bin/ln"
+}
+
+func (l ln) Exec(args []string, sys honeyos.Sys) int {
+	var opt lnOptions
+	operands, status := fileOperands("ln", args, sys, func(f string) (bool, int) {
+		switch f {
+		case "-s", "--symbolic":
+			opt.symbolic = true
+		case "-f", "--force":
+			opt.force, opt.interactive = true, false
+		case "-i", "--interactive":
+			opt.interactive, opt.force = true, false
+		case "-n", "--no-dereference":
+			opt.noDeref = true
+		case "-v", "--verbose":
+			opt.verbose = true
+		case "-r", "--relative":
+			opt.relative = true
+		case "-T", "--no-target-directory":
+			opt.noTarget = true
+		case "-t", "--target-directory":
+			return true, -1
+		case "-b", "--backup", "-d", "-F", "--directory", "-L", "--logical", "-P", "--physical":
+		case "--help":
+			fmt.Fprint(sys.Out(), l.GetHelp())
+			return false, 0
+		case "--version":
+			fmt.Fprintln(sys.Out(), "ln (GNU coreutils) 8.32")
+			return false, 0
+		default:
+			return false, 1
+		}
+		return false, -1
+	}, &opt.target)
+	if status >= 0 {
+		return status
+	}
+	if opt.relative && !opt.symbolic {
+		fmt.Fprintln(sys.Err(), "ln: cannot do --relative without --symbolic")
+		return 1
+	}
+	var targets []string
+	dest := opt.target
+	switch {
+	case len(operands) == 0:
+		fmt.Fprintln(sys.Err(), "ln: missing file operand\nTry 'ln --help' for more information.")
+		return 1
+	case dest != "":
+		if !l.isDir(sys, dest, opt) {
+			fmt.Fprintf(sys.Err(), "ln: target '%v' is not a directory\n", dest)
+			return 1
+		}
+		targets = operands
+	case len(operands) == 1:
+		// The link is made in the current directory
+		targets, dest = operands, "."
+	case opt.noTarget && len(operands) > 2:
+		fmt.Fprintf(sys.Err(), "ln: extra operand '%v'\nTry 'ln --help' for more information.\n", operands[2])
+		return 1
+	default:
+		targets, dest = operands[:len(operands)-1], operands[len(operands)-1]
+		if len(targets) > 1 && !l.isDir(sys, dest, opt) {
+			fmt.Fprintf(sys.Err(), "ln: target '%v' is not a directory\n", dest)
+			return 1
+		}
+	}
+	intoDir := !opt.noTarget && l.isDir(sys, dest, opt)
+	res := 0
+	for _, target := range targets {
+		name := dest
+		if intoDir {
+			name = pathlib.Join(dest, pathlib.Base(strings.TrimRight(target, "/")))
+		}
+		if !l.link(sys, &opt, target, name) {
+			res = 1
+		}
+	}
+	return res
+}
+
+// isDir tells if the links go into dest, which with -n is not when dest is
+// a symbolic link
+func (ln) isDir(sys honeyos.Sys, dest string, opt lnOptions) bool {
+	p := absPath(sys, dest)
+	if opt.noDeref {
+		if fi, err := honeyos.Lstat(sys.FSys(), p); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+			return false
+		}
+	}
+	fi, err := sys.FSys().Stat(p)
+	return err == nil && fi.IsDir()
+}
+
+// link makes the link name to target, replacing what is there with -f
+func (ln) link(sys honeyos.Sys, opt *lnOptions, target, name string) bool {
+	fs := sys.FSys()
+	p := absPath(sys, name)
+	kind := "hard link"
+	if opt.symbolic {
+		kind = "symbolic link"
+	}
+	if !opt.symbolic {
+		fi, err := honeyos.Lstat(fs, absPath(sys, target))
+		if err != nil {
+			fmt.Fprintf(sys.Err(), "ln: failed to access '%v': %v\n", target, fileError(err))
+			return false
+		}
+		if fi.IsDir() {
+			fmt.Fprintf(sys.Err(), "ln: %v: hard link not allowed for directory\n", target)
+			return false
+		}
+	}
+	if fi, err := honeyos.Lstat(fs, p); err == nil {
+		switch {
+		case fi.IsDir():
+			fmt.Fprintf(sys.Err(), "ln: %v: cannot overwrite directory\n", name)
+			return false
+		case !opt.symbolic && absPath(sys, target) == p:
+			fmt.Fprintf(sys.Err(), "ln: '%v' and '%v' are the same file\n", target, name)
+			return false
+		case opt.interactive:
+			if !fileConfirm(sys, fmt.Sprintf("ln: replace '%v'? ", name)) {
+				return true
+			}
+		case !opt.force:
+			fmt.Fprintf(sys.Err(), "ln: failed to create %v '%v': File exists\n", kind, name)
+			return false
+		}
+		if err := fs.Remove(p); err != nil {
+			fmt.Fprintf(sys.Err(), "ln: cannot remove '%v': %v\n", name, fileError(err))
+			return false
+		}
+	}
+	var err error
+	if opt.symbolic {
+		linkTarget := target
+		if opt.relative {
+			linkTarget = relativePath(pathlib.Dir(p), absPath(sys, target))
+		}
+		err = honeyos.Symlink(fs, linkTarget, p)
+	} else {
+		err = honeyos.Link(fs, absPath(sys, target), p)
+	}
+	if err != nil {
+		if opt.symbolic {
+			fmt.Fprintf(sys.Err(), "ln: failed to create %v '%v': %v\n", kind, name, fileError(err))
+		} else {
+			fmt.Fprintf(sys.Err(), "ln: failed to create %v '%v' => '%v': %v\n", kind, name, target, fileError(err))
+		}
+		return false
+	}
+	sys.Log().WithField("target", target).WithField("link", p).WithField("symbolic", opt.symbolic).Info("User created link")
+	if opt.verbose {
+		arrow := "=>"
+		if opt.symbolic {
+			arrow = "->"
+		}
+		fmt.Fprintf(sys.Out(), "'%v' %v '%v'\n", name, arrow, target)
+	}
+	return true
+}
+
+// relativePath is the path to target from the directory dir, like ln -r
+// makes
+func relativePath(dir, target string) string {
+	from := strings.Split(strings.Trim(pathlib.Clean(dir), "/"), "/")
+	to := strings.Split(strings.Trim(pathlib.Clean(target), "/"), "/")
+	if from[0] == "" {
+		from = nil
+	}
+	if to[0] == "" {
+		to = nil
+	}
+	i := 0
+	for i < len(from) && i < len(to) && from[i] == to[i] {
+		i++
+	}
+	var parts []string
+	for range from[i:] {
+		parts = append(parts, "..")
+	}
+	parts = append(parts, to[i:]...)
+	if len(parts) == 0 {
+		return "."
+	}
+	return strings.Join(parts, "/")
+}
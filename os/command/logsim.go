@@ -0,0 +1,95 @@
+package command
+
+import (
+	"fmt"
+	"math/rand"
+	pathlib "path"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+)
+
+// logKind tells the kind of system log by the file name, auth for auth.log
+// and secure, syslog for syslog and messages. Other files are not made up
+func logKind(name string) string {
+	switch pathlib.Base(name) {
+	case "auth.log", "secure":
+		return "auth"
+	case "syslog", "messages":
+		return "syslog"
+	}
+	return ""
+}
+
+var logUsers = []string{"admin", "test", "oracle", "ubuntu", "git", "postgres", "user", "guest", "ftpuser", "pi", "support", "deploy"}
+
+// logAttacker makes up the address of host scanning the internet, which are
+// what auth.log of the server on the internet is full of
+func logAttacker(r *rand.Rand) string {
+	first := []int{45, 61, 103, 112, 115, 118, 122, 139, 141, 159, 185, 193, 202, 218, 221, 222}
+	return fmt.Sprintf("%v.%v.%v.%v", first[r.Intn(len(first))], r.Intn(256), r.Intn(256), 1+r.Intn(254))
+}
+
+// logLine makes up the line logged at the time, like the lines keep coming
+// in tail -f of the logs
+func logLine(sys honeyos.Sys, kind string, t time.Time, r *rand.Rand) string {
+	host := strings.SplitN(sys.Hostname(), ".", 2)[0]
+	ident, msg := logMessage(kind, t, r)
+	return fmt.Sprintf("%v %v %v: %v", t.Format("Jan _2 15:04:05"), host, ident, msg)
+}
+
+// logMessage makes up the message logged at the time, with the identifier
+// of the sender like sshd[1234]. The journal has them without the syslog
+// header
+func logMessage(kind string, t time.Time, r *rand.Rand) (ident, msg string) {
+	pid := 1000 + r.Intn(30000)
+	sshd := fmt.Sprintf("sshd[%v]", pid)
+	switch kind {
+	case "auth":
+		ip, port := logAttacker(r), 30000+r.Intn(35000)
+		user := logUsers[r.Intn(len(logUsers))]
+		switch r.Intn(6) {
+		case 0:
+			return sshd, fmt.Sprintf("Failed password for root from %v port %v ssh2", ip, port)
+		case 1:
+			return sshd, fmt.Sprintf("Invalid user %v from %v port %v", user, ip, port)
+		case 2:
+			return sshd, fmt.Sprintf("Failed password for invalid user %v from %v port %v ssh2", user, ip, port)
+		case 3:
+			return sshd, fmt.Sprintf("pam_unix(sshd:auth): authentication failure; logname= uid=0 euid=0 tty=ssh ruser= rhost=%v  user=root", ip)
+		case 4:
+			return sshd, fmt.Sprintf("Received disconnect from %v port %v:11: Bye Bye [preauth]", ip, port)
+		default:
+			return fmt.Sprintf("CRON[%v]", pid), "pam_unix(cron:session): session opened for user root by (uid=0)"
+		}
+	default:
+		switch r.Intn(4) {
+		case 0:
+			return fmt.Sprintf("CRON[%v]", pid), "(root) CMD (   cd / && run-parts --report /etc/cron.hourly)"
+		case 1:
+			return "systemd[1]", fmt.Sprintf("Started Session %v of user root.", 100+r.Intn(900))
+		case 2:
+			up := t.Sub(honeyos.BootTime()).Seconds()
+			return "kernel", fmt.Sprintf("[%12.6f] %v", up, logUFW(r))
+		default:
+			return fmt.Sprintf("systemd-timesyncd[%v]", 500+r.Intn(300)), "Synchronized to time server 91.189.89.198:123 (ntp.ubuntu.com)."
+		}
+	}
+}
+
+// logUFW makes up the kernel message of ufw blocking the scan from the
+// internet
+func logUFW(r *rand.Rand) string {
+	return fmt.Sprintf("[UFW BLOCK] IN=eth0 OUT= MAC=%v SRC=%v DST=%v LEN=40 TOS=0x00 PREC=0x00 TTL=%v ID=%v PROTO=TCP SPT=%v DPT=%v WINDOW=1024 RES=0x00 SYN URGP=0",
+		logMAC(r), logAttacker(r), honeyos.IPAddress(), 40+r.Intn(200), r.Intn(65536), 1024+r.Intn(60000), []int{23, 445, 3389, 8080, 5900, 1433}[r.Intn(6)])
+}
+
+func logMAC(r *rand.Rand) string {
+	b := make([]string, 14)
+	for i := range b {
+		b[i] = fmt.Sprintf("%02x", r.Intn(256))
+	}
+	b[12], b[13] = "08", "00"
+	return strings.Join(b, ":")
+}
@@ -2,24 +2,130 @@ package command
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
+	pathlib "path"
 	"sort"
 	"strings"
+	"syscall"
+	"time"
+	"unicode"
 
 	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/mkishere/sshsyrup/util/terminal"
 	"github.com/mkishere/sshsyrup/virtualfs"
 	"github.com/spf13/pflag"
 )
 
 type ls struct{}
-type lsFileInfoSort []os.FileInfo
+
+// lsOptions are how ls lists the files
+type lsOptions struct {
+	all, almostAll, dirOnly, recursive, reverse bool
+	classify, slash, inode, size, numeric       bool
+	noOwner, noGroup, human, si, fullTime       bool
+	deref                                       bool
+	// format is l for long, 1 for a file each line, C for columns and m for
+	// names separated by commas
+	format byte
+	// sortBy is n for name, t for time, S for size, X for extension and U
+	// for the order in the directory
+	sortBy byte
+	colors lsColors
+	now    time.Time
+	loc    *time.Location
+	// collate sorts like en_US.UTF-8 does, ignoring case and punctuation
+	collate bool
+}
+
+// lsEntry is the file to list and the name to show it by
+type lsEntry struct {
+	name string
+	path string
+	fi   os.FileInfo
+	// target is where the symbolic link points, and targetInfo the file
+	// there or nil if the link is broken
+	target     string
+	targetInfo os.FileInfo
+}
+
+// newLsEntry makes the entry of the file, reading the link if it is one.
+// With deref the link is listed as the file it links to
+func newLsEntry(sys honeyos.Sys, name, p string, fi os.FileInfo, deref bool) lsEntry {
+	e := lsEntry{name: name, path: p, fi: fi}
+	if fi.Mode()&os.ModeSymlink == 0 {
+		return e
+	}
+	e.target, _ = honeyos.Readlink(sys.FSys(), p)
+	if tfi, err := sys.FSys().Stat(p); err == nil {
+		e.targetInfo = tfi
+		if deref {
+			e.fi = renamedFileInfo{tfi, fi.Name()}
+		}
+	}
+	return e
+}
 
 func init() {
 	honeyos.RegisterCommand("ls", ls{})
 }
 
 func (cmd ls) GetHelp() string {
-	return ""
+	return `Usage: ls [OPTION]... [FILE]...
+List information about the FILEs (the current directory by default).
+Sort entries alphabetically if none of -cftuvSUX nor --sort is specified.
+
+Mandatory arguments to long options are mandatory for short options too.
+  -a, --all                  do not ignore entries starting with .
+  -A, --almost-all           do not list implied . and ..
+  -b, --escape               print C-style escapes for nongraphic characters
+  -C                         list entries by columns
+      --color[=WHEN]         colorize the output; WHEN can be 'always' (default
+                               if omitted), 'auto', or 'never'
+  -d, --directory            list directories themselves, not their contents
+  -f                         do not sort, enable -aU, disable -ls --color
+  -F, --classify             append indicator (one of */=>@|) to entries
+      --full-time            like -l --time-style=full-iso
+  -g                         like -l, but do not list owner
+  -G, --no-group             in a long listing, don't print group names
+  -h, --human-readable       with -l and -s, print sizes like 1K 234M 2G etc.
+      --si                   likewise, but use powers of 1000 not 1024
+  -i, --inode                print the index number of each file
+  -l                         use a long listing format
+  -L, --dereference          when showing file information for a symbolic
+                               link, show information for the file the link
+                               references rather than for the link itself
+  -m                         fill width with a comma separated list of entries
+  -n, --numeric-uid-gid      like -l, but list numeric user and group IDs
+  -o                         like -l, but do not list group information
+  -p, --indicator-style=slash
+                             append / indicator to directories
+  -q, --hide-control-chars   print ? instead of nongraphic characters
+  -r, --reverse              reverse order while sorting
+  -R, --recursive            list subdirectories recursively
+  -s, --size                 print the allocated size of each file, in blocks
+  -S                         sort by file size, largest first
+      --sort=WORD            sort by WORD instead of name: none (-U), size (-S),
+                               time (-t), version (-v), extension (-X)
+  -t                         sort by modification time, newest first
+  -U                         do not sort; list entries in directory order
+  -X                         sort alphabetically by entry extension
+  -1                         list one file per line.  Avoid '\n' with -q or -b
+      --help     display this help and exit
+      --version  output version information and exit
+
+The SIZE argument is an integer and optional unit (example: 10K is 10*1024).
+Units are K,M,G,T,P,E,Z,Y (powers of 1024) or KB,MB,GB,... (powers of 1000).
+
+Exit status:
+ 0  if OK,
+ 1  if minor problems (e.g., cannot access subdirectory),
+ 2  if serious trouble (e.g., cannot access command-line argument).
+
+GNU coreutils online help: <https://www.gnu.org/software/coreutils/>
+Full documentation at: <https://www.gnu.org/software/coreutils/ls>
+or available locally via: info '(coreutils) ls invocation'
+`
 }
 
 func (cmd ls) Where() string {
@@ -27,78 +133,681 @@ func (cmd ls) Where() string {
 }
 
 func (cmd ls) Exec(args []string, sys honeyos.Sys) int {
-	flag := pflag.NewFlagSet("arg", pflag.PanicOnError)
-	flag.SetOutput(sys.Out())
-	lMode := flag.BoolP("", "l", false, "use a long listing format")
+	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	lMode := flag.BoolP("long", "l", false, "use a long listing format")
+	all := flag.BoolP("all", "a", false, "do not ignore entries starting with .")
+	almostAll := flag.BoolP("almost-all", "A", false, "do not list implied . and ..")
+	classify := flag.BoolP("classify", "F", false, "append indicator (one of */=>@|) to entries")
+	columns := flag.BoolP("columns", "C", false, "list entries by columns")
+	colorMode := flag.String("color", "never", "colorize the output")
+	flag.Lookup("color").NoOptDefVal = "always"
+	one := flag.BoolP("one", "1", false, "list one file per line")
+	commas := flag.BoolP("commas", "m", false, "fill width with a comma separated list of entries")
+	dirOnly := flag.BoolP("directory", "d", false, "list directories themselves, not their contents")
+	recursive := flag.BoolP("recursive", "R", false, "list subdirectories recursively")
+	reverse := flag.BoolP("reverse", "r", false, "reverse order while sorting")
+	byTime := flag.BoolP("time", "t", false, "sort by modification time, newest first")
+	bySize := flag.BoolP("size-sort", "S", false, "sort by file size, largest first")
+	byExt := flag.BoolP("extension", "X", false, "sort alphabetically by entry extension")
+	unsorted := flag.BoolP("unsorted", "U", false, "do not sort; list entries in directory order")
+	noSortAll := flag.BoolP("no-sort", "f", false, "do not sort, enable -aU")
+	sortWord := flag.String("sort", "", "sort by WORD instead of name")
+	human := flag.BoolP("human-readable", "h", false, "print human readable sizes")
+	si := flag.Bool("si", false, "likewise, but use powers of 1000 not 1024")
+	inode := flag.BoolP("inode", "i", false, "print the index number of each file")
+	size := flag.BoolP("size", "s", false, "print the allocated size of each file, in blocks")
+	numeric := flag.BoolP("numeric-uid-gid", "n", false, "like -l, but list numeric user and group IDs")
+	noOwner := flag.BoolP("no-owner", "g", false, "like -l, but do not list owner")
+	noGroupLong := flag.BoolP("no-group-long", "o", false, "like -l, but do not list group information")
+	noGroup := flag.BoolP("no-group", "G", false, "in a long listing, don't print group names")
+	slash := flag.BoolP("indicator-slash", "p", false, "append / indicator to directories")
+	fullTime := flag.Bool("full-time", false, "like -l --time-style=full-iso")
+	flag.BoolP("escape", "b", false, "print C-style escapes for nongraphic characters")
+	flag.BoolP("hide-control-chars", "q", false, "print ? instead of nongraphic characters")
+	deref := flag.BoolP("dereference", "L", false, "show information for the file references")
+	flag.BoolP("ctime", "c", false, "sort by, and show, ctime")
+	flag.BoolP("atime", "u", false, "sort by, and show, access time")
+	flag.BoolP("context", "Z", false, "print any security context of each file")
+	help := flag.Bool("help", false, "display this help and exit")
+	version := flag.Bool("version", false, "output version information and exit")
 	err := flag.Parse(args)
-	f := flag.Args()
-	var path string
-	if len(f) > 0 {
-		path = f[len(f)-1]
-	} else {
-		path = sys.Getcwd()
+	if err != nil {
+		msg := strings.Replace(err.Error(), "unknown shorthand flag: ", "invalid option -- ", 1)
+		if i := strings.Index(msg, " in -"); i > 0 {
+			msg = msg[:i]
+		}
+		msg = strings.Replace(msg, "unknown flag: ", "unrecognized option '", 1)
+		if strings.HasPrefix(msg, "unrecognized option '") {
+			msg += "'"
+		}
+		fmt.Fprintf(sys.Err(), "ls: %v\nTry 'ls --help' for more information.\n", msg)
+		return 2
+	}
+	if *help {
+		fmt.Fprint(sys.Out(), cmd.GetHelp())
+		return 0
+	}
+	if *version {
+		fmt.Fprintln(sys.Out(), "ls (GNU coreutils) 8.32")
+		return 0
+	}
+	opt := lsOptions{all: *all || *noSortAll, almostAll: *almostAll, dirOnly: *dirOnly, recursive: *recursive,
+		reverse: *reverse, classify: *classify, slash: *slash, inode: *inode, size: *size, numeric: *numeric,
+		noOwner: *noOwner, noGroup: *noGroup || *noGroupLong, human: *human, si: *si, fullTime: *fullTime, deref: *deref,
+		now: honeyos.Now(sys), loc: honeyos.Location(sys), format: 'C', sortBy: 'n'}
+	if !honeyos.IsTerminal(sys.Out()) {
+		opt.format = '1'
+	}
+	switch {
+	case *lMode || *numeric || *noOwner || *noGroupLong || *fullTime:
+		opt.format = 'l'
+	case *one:
+		opt.format = '1'
+	case *commas:
+		opt.format = 'm'
+	case *columns:
+		opt.format = 'C'
+	}
+	switch {
+	case *unsorted || *noSortAll || *sortWord == "none":
+		opt.sortBy = 'U'
+	case *bySize || *sortWord == "size":
+		opt.sortBy = 'S'
+	case *byTime || *sortWord == "time":
+		opt.sortBy = 't'
+	case *byExt || *sortWord == "extension":
+		opt.sortBy = 'X'
+	case *sortWord != "" && *sortWord != "name":
+		fmt.Fprintf(sys.Err(), "ls: invalid argument ‘%v’ for ‘--sort’\n", *sortWord)
+		fmt.Fprintln(sys.Err(), "Valid arguments are:\n  - ‘none’\n  - ‘time’\n  - ‘size’\n  - ‘extension’\n  - ‘version’")
+		fmt.Fprintln(sys.Err(), "Try 'ls --help' for more information.")
+		return 2
+	}
+	lang := honeyos.Getenv(sys, "LC_ALL")
+	if lang == "" {
+		lang = honeyos.Getenv(sys, "LANG")
+	}
+	opt.collate = lang != "" && lang != "C" && lang != "POSIX"
+	switch *colorMode {
+	case "always", "yes", "force":
+		opt.colors = getLSColors(sys)
+	case "auto", "tty", "if-tty":
+		if honeyos.IsTerminal(sys.Out()) && terminal.ColorTerm(honeyos.Getenv(sys, "TERM")) {
+			opt.colors = getLSColors(sys)
+		}
+	case "never", "no", "none":
+	default:
+		fmt.Fprintf(sys.Err(), "ls: invalid argument ‘%v’ for ‘--color’\n", *colorMode)
+		fmt.Fprintln(sys.Err(), "Valid arguments are:\n  - ‘always’, ‘yes’, ‘force’\n  - ‘never’, ‘no’, ‘none’\n  - ‘auto’, ‘tty’, ‘if-tty’")
+		fmt.Fprintln(sys.Err(), "Try 'ls --help' for more information.")
+		return 2
 	}
 
-	dir, err := sys.FSys().Open(path)
-	if err != nil {
-		fmt.Fprintf(sys.Out(), "ls: cannot access %v: No such file or directory\n", path)
-		return 1
+	operands := flag.Args()
+	if len(operands) == 0 {
+		operands = []string{"."}
 	}
-	if *lMode {
-		dir, err := dir.Readdir(-1)
-		sortDir := lsFileInfoSort(dir)
+	status := 0
+	var files, dirs []lsEntry
+	for _, name := range operands {
+		p := absPath(sys, name)
+		fi, err := honeyos.Lstat(sys.FSys(), p)
 		if err != nil {
-			fmt.Fprintf(sys.Out(), "ls: cannot access %v: No such file or directory\n", path)
-			return 1
+			fmt.Fprintf(sys.Err(), "ls: cannot access '%v': %v\n", name, fileError(err))
+			status = 2
+			continue
 		}
-		sort.Sort(sortDir)
-		for _, dir := range sortDir {
-			fmt.Fprintln(sys.Out(), getLsString(dir))
+		// Links to directories given are followed, unless the links are to
+		// be shown
+		follow := opt.deref || opt.format != 'l' && !opt.dirOnly && !opt.classify
+		e := newLsEntry(sys, name, p, fi, follow)
+		if e.fi.IsDir() && !opt.dirOnly {
+			dirs = append(dirs, e)
+		} else {
+			files = append(files, e)
 		}
-	} else {
+	}
+	opt.sort(files)
+	opt.sort(dirs)
+	printed := false
+	if len(files) > 0 {
+		opt.print(sys, files, false)
+		printed = true
+	}
+	for _, d := range dirs {
+		s := cmd.listDir(sys, &opt, d, printed || len(operands) > 1 || opt.recursive, status == 0 && len(dirs) > 0 && printed)
+		if s > status {
+			status = s
+		}
+		printed = true
+	}
+	return status
+}
 
-		// Sort directory list
-		dirName, err := dir.Readdirnames(-1)
-		if err != nil {
-			fmt.Fprintf(sys.Out(), "ls: cannot access %v: No such file or directory\n", path)
-			return 1
+// listDir lists the directory, and those under it with -R. The name of the
+// directory is shown as the heading if header is true
+func (cmd ls) listDir(sys honeyos.Sys, opt *lsOptions, dir lsEntry, header, blank bool) int {
+	if header {
+		if blank {
+			fmt.Fprintln(sys.Out())
 		}
-		maxlen := 0
-		for _, d := range dirName {
-			if len(d) > maxlen {
-				maxlen = len(d)
+		fmt.Fprintf(sys.Out(), "%v:\n", dir.name)
+	}
+	f, err := sys.FSys().Open(dir.path)
+	if err != nil {
+		fmt.Fprintf(sys.Err(), "ls: cannot open directory '%v': %v\n", dir.name, fileError(err))
+		return 2
+	}
+	list, err := f.Readdir(-1)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(sys.Err(), "ls: reading directory '%v': %v\n", dir.name, fileError(err))
+		return 2
+	}
+	var entries []lsEntry
+	if opt.all && !opt.almostAll {
+		// Implied . and ..
+		for _, name := range []string{".", ".."} {
+			p := pathlib.Join(dir.path, name)
+			if fi, err := sys.FSys().Stat(p); err == nil {
+				entries = append(entries, lsEntry{name: name, path: p, fi: renamedFileInfo{fi, name}})
 			}
 		}
-		sort.Strings(dirName)
+	}
+	for _, fi := range list {
+		if opt.all || opt.almostAll || !strings.HasPrefix(fi.Name(), ".") {
+			entries = append(entries, newLsEntry(sys, fi.Name(), pathlib.Join(dir.path, fi.Name()), fi, opt.deref))
+		}
+	}
+	opt.sort(entries)
+	opt.print(sys, entries, true)
+	if !opt.recursive {
+		return 0
+	}
+	status := 0
+	for _, e := range entries {
+		if e.fi.IsDir() && e.name != "." && e.name != ".." {
+			sub := lsEntry{name: strings.TrimSuffix(dir.name, "/") + "/" + e.name, path: e.path, fi: e.fi}
+			if dir.name == "/" {
+				sub.name = "/" + e.name
+			}
+			if s := cmd.listDir(sys, opt, sub, true, true); s > 0 {
+				status = 1
+			}
+		}
+	}
+	return status
+}
+
+// sort orders the entries as the options say, ties broken by name
+func (opt *lsOptions) sort(entries []lsEntry) {
+	if opt.sortBy == 'U' {
+		return
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		less, tie := opt.nameLess(a.name, b.name), true
+		switch opt.sortBy {
+		case 't':
+			if !a.fi.ModTime().Equal(b.fi.ModTime()) {
+				less, tie = a.fi.ModTime().After(b.fi.ModTime()), false
+			}
+		case 'S':
+			if a.fi.Size() != b.fi.Size() {
+				less, tie = a.fi.Size() > b.fi.Size(), false
+			}
+		case 'X':
+			if ea, eb := pathlib.Ext(a.name), pathlib.Ext(b.name); ea != eb {
+				less, tie = opt.nameLess(ea, eb), false
+			}
+		}
+		if opt.reverse {
+			if tie && a.name == b.name {
+				return false
+			}
+			return !less
+		}
+		return less
+	})
+}
 
-		itemPerRow := int(sys.Width()/(maxlen+1) - 1)
+// nameLess compares the names by the locale. en_US.UTF-8 ignores case and
+// punctuation unless the names are otherwise the same
+func (opt *lsOptions) nameLess(a, b string) bool {
+	if !opt.collate {
+		return a < b
+	}
+	key := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return unicode.ToLower(r)
+			}
+			return -1
+		}, s)
+	}
+	if ka, kb := key(a), key(b); ka != kb {
+		return ka < kb
+	}
+	return a < b
+}
 
-		for i := 0; i < len(dirName); i++ {
-			if (i+1)%itemPerRow == 0 {
-				fmt.Fprint(sys.Out(), "\n")
+// print lists the entries in the format. inDir tells if they are the
+// content of a directory, which has the total in long format
+func (opt *lsOptions) print(sys honeyos.Sys, entries []lsEntry, inDir bool) {
+	if opt.format == 'l' || opt.size {
+		if inDir {
+			var total int64
+			for _, e := range entries {
+				total += diskBlocks(e.fi)
 			}
-			fmt.Fprintf(sys.Out(), "%v%v  ", dirName[i], strings.Repeat(" ", maxlen-len(dirName[i])))
+			fmt.Fprintf(sys.Out(), "total %v\n", opt.blocks(total))
 		}
-		fmt.Fprint(sys.Out(), "\n")
 	}
-	return 0
+	if opt.format == 'l' {
+		opt.long(sys, entries)
+		return
+	}
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = opt.prefix(e, 0, 0) + opt.name(e)
+	}
+	if opt.inode || opt.size {
+		// Align the numbers in front of the names
+		iw, sw := 0, 0
+		for _, e := range entries {
+			iw = intMax(iw, len(fmt.Sprint(lsInode(e))))
+			sw = intMax(sw, len(opt.blocks(diskBlocks(e.fi))))
+		}
+		for i, e := range entries {
+			names[i] = opt.prefix(e, iw, sw) + opt.name(e)
+		}
+	}
+	switch opt.format {
+	case '1':
+		for _, n := range names {
+			fmt.Fprintln(sys.Out(), n)
+		}
+	case 'm':
+		width, line := sys.Width(), ""
+		for i, n := range names {
+			if i < len(names)-1 {
+				n += ","
+			}
+			if line != "" && terminal.StringWidth(line)+1+terminal.StringWidth(n) > width {
+				fmt.Fprintln(sys.Out(), line)
+				line = ""
+			}
+			if line != "" {
+				line += " "
+			}
+			line += n
+		}
+		if line != "" {
+			fmt.Fprintln(sys.Out(), line)
+		}
+	default:
+		lsColumns(sys, names)
+	}
 }
 
-func (fi lsFileInfoSort) Len() int { return len(fi) }
+// lsColumns lays the names out in columns down then across, with as many
+// columns as the terminal fits like ls -C
+func lsColumns(sys honeyos.Sys, names []string) {
+	if len(names) == 0 {
+		return
+	}
+	width := sys.Width()
+	if width <= 0 {
+		width = 80
+	}
+	widths := make([]int, len(names))
+	for i, n := range names {
+		widths[i] = terminal.StringWidth(n)
+	}
+	rows, colWidths := len(names), []int(nil)
+	for cols := len(names); cols > 1; cols-- {
+		r := (len(names) + cols - 1) / cols
+		if (len(names)+r-1)/r != cols {
+			continue
+		}
+		cw := make([]int, cols)
+		total := 0
+		for c := 0; c < cols; c++ {
+			for i := c * r; i < (c+1)*r && i < len(names); i++ {
+				cw[c] = intMax(cw[c], widths[i])
+			}
+			total += cw[c]
+		}
+		if total+2*(cols-1) < width {
+			rows, colWidths = r, cw
+			break
+		}
+	}
+	if colWidths == nil {
+		colWidths = []int{0}
+	}
+	for r := 0; r < rows; r++ {
+		var b strings.Builder
+		for c := range colWidths {
+			i := c*rows + r
+			if i >= len(names) {
+				break
+			}
+			b.WriteString(names[i])
+			if next := (c+1)*rows + r; c < len(colWidths)-1 && next < len(names) {
+				b.WriteString(strings.Repeat(" ", colWidths[c]-widths[i]+2))
+			}
+		}
+		fmt.Fprintln(sys.Out(), b.String())
+	}
+}
 
-func (fi lsFileInfoSort) Swap(i, j int) { fi[i], fi[j] = fi[j], fi[i] }
+// long writes the entries in long format, with the columns aligned
+func (opt *lsOptions) long(sys honeyos.Sys, entries []lsEntry) {
+	type row struct {
+		prefix, mode, links, owner, group, size, date, name string
+	}
+	rows := make([]row, len(entries))
+	var wl, wo, wg, ws, wi, wb int
+	for _, e := range entries {
+		wi = intMax(wi, len(fmt.Sprint(lsInode(e))))
+		wb = intMax(wb, len(opt.blocks(diskBlocks(e.fi))))
+	}
+	for i, e := range entries {
+		uid, gid, _, _ := virtualfs.GetExtraInfo(e.fi)
+		owner, group := fmt.Sprint(uid), fmt.Sprint(gid)
+		if !opt.numeric {
+			if u := honeyos.GetUserByID(uid); u.Name != "" {
+				owner = u.Name
+			}
+			if g := honeyos.GetGroupByID(gid); g.Name != "" {
+				group = g.Name
+			}
+		}
+		size := fmt.Sprint(e.fi.Size())
+		if e.fi.IsDir() {
+			size = "4096"
+		}
+		switch {
+		case opt.human:
+			size = humanSize(lsSize(e.fi), 1024)
+		case opt.si:
+			size = strings.Replace(humanSize(lsSize(e.fi), 1000), "K", "k", 1)
+		}
+		mode := e.fi.Mode()
+		if e.fi.IsDir() {
+			mode |= os.ModeDir
+		}
+		rows[i] = row{opt.prefix(e, wi, wb), lsMode(mode), fmt.Sprint(lsLinks(sys, e)), owner, group, size,
+			opt.date(e.fi.ModTime()), opt.name(e)}
+		if e.fi.Mode()&os.ModeSymlink != 0 && e.target != "" {
+			target := e.target
+			if e.targetInfo != nil {
+				target = opt.colors.paint(renamedFileInfo{e.targetInfo, e.target})
+				if opt.classify {
+					target += lsIndicator(e.targetInfo)
+				}
+			} else if sgr, ok := opt.colors["or"]; ok {
+				target = terminal.Color(sgr, target)
+			}
+			rows[i].name += " -> " + target
+		}
+		wl, wo, wg, ws = intMax(wl, len(rows[i].links)), intMax(wo, len(owner)), intMax(wg, len(group)), intMax(ws, len(size))
+	}
+	for _, r := range rows {
+		var b strings.Builder
+		fmt.Fprintf(&b, "%v%v %*v ", r.prefix, r.mode, wl, r.links)
+		if !opt.noOwner {
+			fmt.Fprintf(&b, "%-*v ", wo, r.owner)
+		}
+		if !opt.noGroup {
+			fmt.Fprintf(&b, "%-*v ", wg, r.group)
+		}
+		fmt.Fprintf(&b, "%*v %v %v", ws, r.size, r.date, r.name)
+		fmt.Fprintln(sys.Out(), b.String())
+	}
+}
+
+// prefix is the inode and blocks in front of the entry for -i and -s
+func (opt *lsOptions) prefix(e lsEntry, inodeWidth, blocksWidth int) string {
+	s := ""
+	if opt.inode {
+		s += fmt.Sprintf("%*v ", inodeWidth, lsInode(e))
+	}
+	if opt.size {
+		s += fmt.Sprintf("%*v ", blocksWidth, opt.blocks(diskBlocks(e.fi)))
+	}
+	return s
+}
+
+// name is the name in color with the indicator of its type
+func (opt *lsOptions) name(e lsEntry) string {
+	name := opt.colors.paint(renamedFileInfo{e.fi, e.name})
+	if sgr, ok := opt.colors["or"]; ok && e.fi.Mode()&os.ModeSymlink != 0 && e.targetInfo == nil {
+		name = terminal.Color(sgr, e.name)
+	}
+	switch {
+	case opt.classify && opt.format == 'l' && e.fi.Mode()&os.ModeSymlink != 0:
+		// The indicator follows the target instead
+	case opt.classify:
+		name += lsIndicator(e.fi)
+	case opt.slash && e.fi.IsDir():
+		name += "/"
+	}
+	return name
+}
+
+// date is the time of the file like ls shows, with the year instead of the
+// time for files older than six months or in the future
+func (opt *lsOptions) date(t time.Time) string {
+	t = t.In(opt.loc)
+	if opt.fullTime {
+		return t.Format("2006-01-02 15:04:05.000000000 -0700")
+	}
+	if t.After(opt.now) || opt.now.Sub(t) > 182*24*time.Hour {
+		return t.Format("Jan _2  2006")
+	}
+	return t.Format("Jan _2 15:04")
+}
+
+// blocks is the size allocated in units of 1K, or human readable with -h
+func (opt *lsOptions) blocks(n int64) string {
+	switch {
+	case opt.human:
+		return humanSize(n, 1024)
+	case opt.si:
+		return strings.Replace(humanSize(n, 1000), "K", "k", 1)
+	}
+	return fmt.Sprint((n + 1023) / 1024)
+}
+
+type renamedFileInfo struct {
+	os.FileInfo
+	name string
+}
 
-func (fi lsFileInfoSort) Less(i, j int) bool { return fi[i].Name() < fi[j].Name() }
+func (fi renamedFileInfo) Name() string { return fi.name }
 
-func getLsString(fi os.FileInfo) string {
-	uid, gid, _, _ := virtualfs.GetExtraInfo(fi)
-	uName := honeyos.GetUserByID(uid).Name
-	gName := honeyos.GetGroupByID(gid).Name
+// lsIndicator returns the character appended to filename for ls -F
+func lsIndicator(fi os.FileInfo) string {
+	switch {
+	case fi.IsDir():
+		return "/"
+	case fi.Mode()&os.ModeSymlink != 0:
+		return "@"
+	case fi.Mode()&0111 != 0:
+		return "*"
+	}
+	return ""
+}
 
-	size := fi.Size()
+// lsSize is the size shown in long format, where directories take a block
+func lsSize(fi os.FileInfo) int64 {
 	if fi.IsDir() {
-		size = 4096
+		return 4096
+	}
+	return fi.Size()
+}
+
+// lsInode is the inode number shown with -i
+func lsInode(e lsEntry) uint64 {
+	return honeyos.Inode(e.path, e.fi)
+}
+
+// lsLinks is the number of hard links, which for directories is one for each
+// subdirectory, its . and the entry in its parent
+func lsLinks(sys honeyos.Sys, e lsEntry) int {
+	if !e.fi.IsDir() {
+		return honeyos.Nlink(e.fi)
+	}
+	f, err := sys.FSys().Open(e.path)
+	if err != nil {
+		return 2
+	}
+	defer f.Close()
+	list, _ := f.Readdir(-1)
+	n := 2
+	for _, fi := range list {
+		if fi.IsDir() {
+			n++
+		}
+	}
+	return n
+}
+
+func intMax(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func intMin(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// lsMode is the file type and permissions, like drwxrwxrwt
+func lsMode(m os.FileMode) string {
+	kind := "-"
+	switch {
+	case m.IsDir():
+		kind = "d"
+	case m&os.ModeSymlink != 0:
+		kind = "l"
+	case m&os.ModeNamedPipe != 0:
+		kind = "p"
+	case m&os.ModeSocket != 0:
+		kind = "s"
+	case m&os.ModeCharDevice != 0:
+		kind = "c"
+	case m&os.ModeDevice != 0:
+		kind = "b"
+	}
+	return kind + permString(m)
+}
+
+// defaultLSColors is what dircolors sets LS_COLORS to in stock Ubuntu, used
+// if LS_COLORS is not set
+const defaultLSColors = "rs=0:di=01;34:ln=01;36:mh=00:pi=40;33:so=01;35:do=01;35:bd=40;33;01:cd=40;33;01:" +
+	"or=40;31;01:mi=00:su=37;41:sg=30;43:ca=30;41:tw=30;42:ow=34;42:st=37;44:ex=01;32:" +
+	"*.tar=01;31:*.tgz=01;31:*.arj=01;31:*.taz=01;31:*.lzh=01;31:*.lzma=01;31:*.tlz=01;31:" +
+	"*.txz=01;31:*.zip=01;31:*.z=01;31:*.Z=01;31:*.gz=01;31:*.lz=01;31:*.xz=01;31:*.bz2=01;31:" +
+	"*.tbz=01;31:*.tbz2=01;31:*.deb=01;31:*.rpm=01;31:*.jar=01;31:*.rar=01;31:*.7z=01;31:" +
+	"*.jpg=01;35:*.jpeg=01;35:*.gif=01;35:*.bmp=01;35:*.png=01;35:*.svg=01;35:*.mp4=01;35:" +
+	"*.avi=01;35:*.mkv=01;35:*.aac=00;36:*.flac=00;36:*.mp3=00;36:*.ogg=00;36:*.wav=00;36"
+
+// lsColors maps file type like di, ex and extension like *.tar to the SGR
+// sequence, in the format of LS_COLORS. nil means no color
+type lsColors map[string]string
+
+func getLSColors(sys honeyos.Sys) lsColors {
+	spec := honeyos.Getenv(sys, "LS_COLORS")
+	if spec == "" {
+		spec = defaultLSColors
+	}
+	colors := lsColors{}
+	for _, entry := range strings.Split(spec, ":") {
+		if kv := strings.SplitN(entry, "=", 2); len(kv) == 2 {
+			colors[kv[0]] = kv[1]
+		}
+	}
+	return colors
+}
+
+// paint returns the filename in color of its type
+func (c lsColors) paint(fi os.FileInfo) string {
+	if c == nil {
+		return fi.Name()
+	}
+	mode := fi.Mode()
+	key := ""
+	switch {
+	case mode.IsDir() && mode&os.ModeSticky != 0 && mode&0002 != 0:
+		key = "tw"
+	case mode.IsDir() && mode&0002 != 0:
+		key = "ow"
+	case mode.IsDir() && mode&os.ModeSticky != 0:
+		key = "st"
+	case mode.IsDir():
+		key = "di"
+	case mode&os.ModeSymlink != 0:
+		key = "ln"
+	case mode&os.ModeNamedPipe != 0:
+		key = "pi"
+	case mode&os.ModeSocket != 0:
+		key = "so"
+	case mode&os.ModeCharDevice != 0:
+		key = "cd"
+	case mode&os.ModeDevice != 0:
+		key = "bd"
+	case mode&os.ModeSetuid != 0:
+		key = "su"
+	case mode&os.ModeSetgid != 0:
+		key = "sg"
+	case mode&0111 != 0:
+		key = "ex"
+	}
+	if sgr, ok := c[key]; ok && key != "" {
+		return terminal.Color(sgr, fi.Name())
+	}
+	// Longest matching extension wins
+	match := ""
+	for k := range c {
+		if strings.HasPrefix(k, "*") && strings.HasSuffix(fi.Name(), k[1:]) && len(k) > len(match) {
+			match = k
+		}
+	}
+	return terminal.Color(c[match], fi.Name())
+}
+
+// fileError is the message of the error as the C library puts it, which the
+// file commands print after the name
+func fileError(err error) string {
+	switch e := err.(type) {
+	case *os.PathError:
+		err = e.Err
+	case *os.LinkError:
+		err = e.Err
+	}
+	switch {
+	case err == syscall.ENOTEMPTY:
+		return "Directory not empty"
+	case err == syscall.EISDIR:
+		return "Is a directory"
+	case err == syscall.ENOTDIR:
+		return "Not a directory"
+	case err == syscall.EXDEV:
+		return "Invalid cross-device link"
+	case os.IsExist(err):
+		return "File exists"
+	case os.IsPermission(err):
+		return "Permission denied"
+	case os.IsNotExist(err):
+		return "No such file or directory"
 	}
-	return fmt.Sprintf("%v    1 %-8s %-8s %8d %v %v", strings.ToLower(fi.Mode().String()), uName, gName,
-		size, fi.ModTime().Format("Jan 02 15:04"), fi.Name())
+	return "Input/output error"
 }
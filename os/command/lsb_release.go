@@ -0,0 +1,74 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/pflag"
+)
+
+type lsbRelease struct{}
+
+func init() {
+	honeyos.RegisterCommand("lsb_release", lsbRelease{})
+}
+
+func (lsbRelease) GetHelp() string {
+	return ""
+}
+
+func (lsbRelease) Where() string {
+	return "/usr/bin/lsb_release"
+}
+
+func (lsbRelease) Exec(args []string, sys honeyos.Sys) int {
+	// lsb-release is only in the base image of Debian and Ubuntu
+	if pkgFamily() != "deb" {
+		return honeyos.CommandNotFound(sys, append([]string{"lsb_release"}, args...))
+	}
+	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	all := flag.BoolP("all", "a", false, "show all of the above information")
+	id := flag.BoolP("id", "i", false, "show distributor ID")
+	desc := flag.BoolP("description", "d", false, "show description of this distribution")
+	rel := flag.BoolP("release", "r", false, "show release number of this distribution")
+	code := flag.BoolP("codename", "c", false, "show code name of this distribution")
+	shortFormat := flag.BoolP("short", "s", false, "show requested information in short format")
+	flag.BoolP("version", "v", false, "show LSB modules this system supports")
+	if err := flag.Parse(args); err != nil {
+		fmt.Fprintf(sys.Err(), "Usage: lsb_release [options]\n\nlsb_release: error: %v\n", err)
+		return 2
+	}
+	distID, description, release, codename := honeyos.DistroName()
+	if *all || !*id && !*desc && !*rel && !*code {
+		fmt.Fprintln(sys.Err(), "No LSB modules are available.")
+	}
+	if *all {
+		*id, *desc, *rel, *code = true, true, true, true
+	}
+	var short []string
+	for _, f := range []struct {
+		on           bool
+		label, value string
+	}{
+		{*id, "Distributor ID", distID},
+		{*desc, "Description", description},
+		{*rel, "Release", release},
+		{*code, "Codename", codename},
+	} {
+		if !f.on {
+			continue
+		}
+		if *shortFormat {
+			short = append(short, f.value)
+		} else {
+			fmt.Fprintf(sys.Out(), "%v:\t%v\n", f.label, f.value)
+		}
+	}
+	if len(short) > 0 {
+		fmt.Fprintln(sys.Out(), strings.Join(short, " "))
+	}
+	return 0
+}
@@ -0,0 +1,348 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+)
+
+type lsblk struct{}
+
+// blockDev is the block device as lsblk lists it
+type blockDev struct {
+	name, kind, mount, fsType string
+	major, minor              int
+	size                      int64
+	removable, readOnly       bool
+	model                     string
+	children                  []*blockDev
+}
+
+// lsblkColumns are the columns lsblk -o can show, by header
+var lsblkColumns = map[string]func(d *blockDev, bytes bool) string{
+	"NAME":    func(d *blockDev, _ bool) string { return d.name },
+	"KNAME":   func(d *blockDev, _ bool) string { return d.name },
+	"MAJ:MIN": func(d *blockDev, _ bool) string { return fmt.Sprintf("%3v:%v", d.major, d.minor) },
+	"RM":      func(d *blockDev, _ bool) string { return lsblkBool(d.removable) },
+	"RO":      func(d *blockDev, _ bool) string { return lsblkBool(d.readOnly) },
+	"SIZE": func(d *blockDev, bytes bool) string {
+		if bytes {
+			return strconv.FormatInt(d.size, 10)
+		}
+		return lsblkSize(d.size)
+	},
+	"TYPE":       func(d *blockDev, _ bool) string { return d.kind },
+	"MOUNTPOINT": func(d *blockDev, _ bool) string { return d.mount },
+	"FSTYPE":     func(d *blockDev, _ bool) string { return d.fsType },
+	"LABEL":      func(d *blockDev, _ bool) string { return "" },
+	"UUID": func(d *blockDev, _ bool) string {
+		if d.fsType == "" {
+			return ""
+		}
+		return honeyos.DiskUUID("/dev/" + d.name)
+	},
+	"MODEL": func(d *blockDev, _ bool) string { return d.model },
+	"ROTA":  func(d *blockDev, _ bool) string { return "1" },
+}
+
+func init() {
+	honeyos.RegisterCommand("lsblk", lsblk{})
+}
+
+func (lsblk) GetHelp() string {
+	return `
+Usage:
+ lsblk [options] [<device> ...]
+
+List information about block devices.
+
+Options:
+ -a, --all            print all devices
+ -b, --bytes          print SIZE in bytes rather than in human readable format
+ -d, --nodeps         don't print slaves or holders
+ -f, --fs             output info about filesystems
+ -l, --list           use list format output
+ -m, --perms          output info about permissions
+ -n, --noheadings     don't print headings
+ -o, --output <list>  output columns
+ -p, --paths          print complete device path
+ -r, --raw            use raw output format
+ -S, --scsi           output info about SCSI devices
+
+ -h, --help     display this help and exit
+ -V, --version  output version information and exit
+
+For more details see lsblk(8).
+`
+}
+
+func (lsblk) Where() string {
+	return "/bin/lsblk"
+}
+
+// blockDevices works out the disks and their partitions from the mounts,
+// the same ones dmesg finds at boot, and the CD drive of the VM
+func blockDevices(sys honeyos.Sys) []*blockDev {
+	mounts := sys.Mounts()
+	var devs []*blockDev
+	for i, d := range dmesgDisks(mounts) {
+		major, model := 8, "QEMU HARDDISK"
+		if strings.HasPrefix(d.name, "vd") {
+			major, model = 253, ""
+		}
+		disk := &blockDev{name: d.name, kind: "disk", major: major, minor: i * 16, size: d.size, model: model}
+		var rest int64 = d.size
+		for j, p := range d.partitions {
+			part := &blockDev{name: p, kind: "part", major: major, minor: i*16 + j + 1}
+			for _, m := range mounts {
+				if m.Device == "/dev/"+p {
+					part.size, part.mount, part.fsType = m.Size, m.Dir, m.Type
+				}
+			}
+			if part.size == 0 {
+				// The LVM partition takes what the mounted ones do not
+				part.size, part.fsType = rest, "LVM2_member"
+				minor := 0
+				for _, m := range mounts {
+					if !strings.HasPrefix(m.Device, "/dev/mapper/") {
+						continue
+					}
+					name := strings.TrimPrefix(m.Device, "/dev/mapper/")
+					part.children = append(part.children, &blockDev{name: name, kind: "lvm", major: 252, minor: minor,
+						size: m.Size, mount: m.Dir, fsType: m.Type})
+					minor++
+				}
+				if len(part.children) > 0 {
+					// Swap of the volume group is not mounted
+					swap := strings.SplitN(part.children[0].name, "-", 2)[0] + "-swap"
+					part.children = append(part.children, &blockDev{name: swap, kind: "lvm", major: 252, minor: minor,
+						size: swapTotal * 1024, mount: "[SWAP]", fsType: "swap"})
+				}
+			}
+			rest -= part.size
+			disk.children = append(disk.children, part)
+		}
+		devs = append(devs, disk)
+	}
+	if honeyos.Distro() != "debian" {
+		devs = append(devs, &blockDev{name: "sr0", kind: "rom", major: 11, size: 1 << 30, removable: true, model: "QEMU DVD-ROM"})
+	}
+	return devs
+}
+
+func (l lsblk) Exec(args []string, sys honeyos.Sys) int {
+	if honeyos.Distro() == "alpine" {
+		return honeyos.CommandNotFound(sys, append([]string{"lsblk"}, args...))
+	}
+	columns := []string{"NAME", "MAJ:MIN", "RM", "SIZE", "RO", "TYPE", "MOUNTPOINT"}
+	var bytes, nodeps, list, noHeadings, paths, raw bool
+	var names []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			names = append(names, arg)
+			continue
+		}
+		opts := []string{arg}
+		if !strings.HasPrefix(arg, "--") {
+			opts = nil
+			for _, c := range arg[1:] {
+				opts = append(opts, "-"+string(c))
+			}
+		}
+		for _, opt := range opts {
+			switch opt {
+			case "-h", "--help":
+				fmt.Fprint(sys.Out(), l.GetHelp())
+				return 0
+			case "-V", "--version":
+				fmt.Fprintln(sys.Out(), "lsblk from util-linux 2.27.1")
+				return 0
+			case "-a", "--all":
+			case "-b", "--bytes":
+				bytes = true
+			case "-d", "--nodeps":
+				nodeps = true
+			case "-f", "--fs":
+				columns = []string{"NAME", "FSTYPE", "LABEL", "UUID", "MOUNTPOINT"}
+			case "-m", "--perms":
+				columns = []string{"NAME", "SIZE", "OWNER", "GROUP", "MODE"}
+			case "-S", "--scsi":
+				columns, nodeps = []string{"NAME", "TYPE", "MODEL"}, true
+			case "-l", "--list":
+				list = true
+			case "-n", "--noheadings":
+				noHeadings = true
+			case "-p", "--paths":
+				paths = true
+			case "-r", "--raw":
+				raw, list = true, true
+			case "-o", "--output":
+				if i+1 >= len(args) {
+					fmt.Fprintf(sys.Err(), "lsblk: option requires an argument -- 'o'\n%v", l.GetHelp())
+					return 1
+				}
+				i++
+				columns = nil
+				for _, c := range strings.Split(strings.ToUpper(args[i]), ",") {
+					if _, ok := lsblkColumns[c]; !ok && c != "OWNER" && c != "GROUP" && c != "MODE" {
+						fmt.Fprintf(sys.Err(), "lsblk: unknown column: %v\n", c)
+						return 1
+					}
+					columns = append(columns, c)
+				}
+			default:
+				fmt.Fprintf(sys.Err(), "lsblk: invalid option -- '%v'\n%v", strings.TrimLeft(opt, "-"), l.GetHelp())
+				return 1
+			}
+		}
+	}
+	devs := blockDevices(sys)
+	if len(names) > 0 {
+		var selected []*blockDev
+		for _, name := range names {
+			d := findBlockDev(devs, strings.TrimPrefix(name, "/dev/"))
+			if d == nil {
+				fmt.Fprintf(sys.Err(), "lsblk: %v: not a block device\n", name)
+				return 32
+			}
+			selected = append(selected, d)
+		}
+		devs = selected
+	}
+	var rows [][]string
+	var walk func(d *blockDev, prefix string, last bool, depth int)
+	walk = func(d *blockDev, prefix string, last bool, depth int) {
+		var row []string
+		for _, c := range columns {
+			var v string
+			switch c {
+			case "OWNER", "GROUP":
+				v = "root"
+				if c == "GROUP" {
+					v = "disk"
+					if d.kind == "rom" {
+						v = "cdrom"
+					}
+				}
+			case "MODE":
+				v = "brw-rw----"
+			default:
+				v = lsblkColumns[c](d, bytes)
+			}
+			if c == "NAME" || c == "KNAME" {
+				if paths {
+					v = "/dev/" + v
+					if d.kind == "lvm" {
+						v = "/dev/mapper/" + d.name
+					}
+				}
+				if c == "NAME" && !list && depth > 0 {
+					branch := "├─"
+					if last {
+						branch = "└─"
+					}
+					v = prefix + branch + v
+				}
+			}
+			row = append(row, v)
+		}
+		rows = append(rows, row)
+		if nodeps {
+			return
+		}
+		childPrefix := prefix
+		if depth > 0 {
+			if last {
+				childPrefix += "  "
+			} else {
+				childPrefix += "│ "
+			}
+		}
+		for i, c := range d.children {
+			walk(c, childPrefix, i == len(d.children)-1, depth+1)
+		}
+	}
+	for _, d := range devs {
+		walk(d, "", true, 0)
+	}
+	if raw {
+		if !noHeadings {
+			fmt.Fprintln(sys.Out(), strings.Join(columns, " "))
+		}
+		for _, row := range rows {
+			fmt.Fprintln(sys.Out(), strings.Join(row, " "))
+		}
+		return 0
+	}
+	if !noHeadings {
+		rows = append([][]string{columns}, rows...)
+	}
+	widths := make([]int, len(columns))
+	for _, row := range rows {
+		for i, v := range row {
+			if n := len([]rune(v)); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	for _, row := range rows {
+		var b strings.Builder
+		for i, v := range row {
+			pad := strings.Repeat(" ", widths[i]-len([]rune(v)))
+			switch columns[i] {
+			case "MAJ:MIN", "RM", "RO", "SIZE", "ROTA":
+				b.WriteString(pad + v)
+			default:
+				b.WriteString(v + pad)
+			}
+			if i < len(row)-1 {
+				b.WriteString(" ")
+			}
+		}
+		fmt.Fprintln(sys.Out(), b.String())
+	}
+	return 0
+}
+
+// findBlockDev finds the device by name among the devices and their children
+func findBlockDev(devs []*blockDev, name string) *blockDev {
+	for _, d := range devs {
+		if d.name == name || d.kind == "lvm" && "mapper/"+d.name == name {
+			return d
+		}
+		if c := findBlockDev(d.children, name); c != nil {
+			return c
+		}
+	}
+	return nil
+}
+
+func lsblkBool(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// lsblkSize formats the size like lsblk, to one decimal below 10 like 9.8G
+// and whole numbers above like 40G
+func lsblkSize(n int64) string {
+	units := "BKMGTP"
+	v := float64(n)
+	i := 0
+	for v >= 1024 && i < len(units)-1 {
+		v /= 1024
+		i++
+	}
+	suffix := string(units[i])
+	if i == 0 {
+		suffix = "B"
+	}
+	if v == float64(int64(v)) {
+		return fmt.Sprintf("%v%v", int64(v), suffix)
+	}
+	return strings.TrimSuffix(fmt.Sprintf("%.1f", v), ".0") + suffix
+}
@@ -0,0 +1,179 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+)
+
+type lscpu struct{}
+
+type nproc struct{}
+
+func init() {
+	honeyos.RegisterCommand("lscpu", lscpu{})
+	honeyos.RegisterCommand("nproc", nproc{})
+}
+
+func (lscpu) GetHelp() string {
+	return `
+Usage:
+ lscpu [options]
+
+Display information about the CPU architecture.
+
+Options:
+ -a, --all               print both online and offline CPUs (default for -e)
+ -b, --online            print online CPUs only (default for -p)
+ -c, --offline           print offline CPUs only
+ -J, --json              use JSON for default or extended format
+ -e, --extended[=<list>] print out an extended readable format
+ -p, --parse[=<list>]    print out a parsable format
+ -s, --sysroot <dir>     use specified directory as system root
+ -x, --hex               print hexadecimal masks rather than lists of CPUs
+ -y, --physical          print physical instead of logical IDs
+
+ -h, --help     display this help and exit
+ -V, --version  output version information and exit
+
+For more details see lscpu(1).
+`
+}
+
+func (lscpu) Where() string {
+	return "/usr/bin/lscpu"
+}
+
+// lscpuFields are the lines of lscpu, which fingerprinting scripts parse
+func lscpuFields() [][2]string {
+	return [][2]string{
+		{"Architecture", honeyos.Arch()},
+		{"CPU op-mode(s)", "32-bit, 64-bit"},
+		{"Byte Order", "Little Endian"},
+		{"CPU(s)", "1"},
+		{"On-line CPU(s) list", "0"},
+		{"Thread(s) per core", "1"},
+		{"Core(s) per socket", "1"},
+		{"Socket(s)", "1"},
+		{"NUMA node(s)", "1"},
+		{"Vendor ID", "GenuineIntel"},
+		{"CPU family", "6"},
+		{"Model", "42"},
+		{"Model name", cpuModel},
+		{"Stepping", "1"},
+		{"CPU MHz", fmt.Sprintf("%.3f", cpuMHz)},
+		{"BogoMIPS", fmt.Sprintf("%.2f", cpuMHz*2)},
+		{"Hypervisor vendor", "KVM"},
+		{"Virtualization type", "full"},
+		{"L1d cache", "32K"},
+		{"L1i cache", "32K"},
+		{"L2 cache", "4096K"},
+		{"NUMA node0 CPU(s)", "0"},
+		{"Flags", cpuFlags},
+	}
+}
+
+func (l lscpu) Exec(args []string, sys honeyos.Sys) int {
+	if honeyos.Distro() == "alpine" {
+		return honeyos.CommandNotFound(sys, append([]string{"lscpu"}, args...))
+	}
+	mode := ""
+	for _, arg := range args {
+		switch {
+		case arg == "-h" || arg == "--help":
+			fmt.Fprint(sys.Out(), l.GetHelp())
+			return 0
+		case arg == "-V" || arg == "--version":
+			fmt.Fprintln(sys.Out(), "lscpu from util-linux 2.27.1")
+			return 0
+		case arg == "-J" || arg == "--json":
+			mode = "json"
+		case strings.HasPrefix(arg, "-e") || strings.HasPrefix(arg, "--extended"):
+			mode = "extended"
+		case strings.HasPrefix(arg, "-p") || strings.HasPrefix(arg, "--parse"):
+			mode = "parse"
+		case arg == "-a" || arg == "--all" || arg == "-b" || arg == "--online" || arg == "-x" || arg == "--hex" ||
+			arg == "-y" || arg == "--physical":
+		case arg == "-c" || arg == "--offline":
+			mode = "offline"
+		default:
+			fmt.Fprintf(sys.Err(), "lscpu: invalid option -- '%v'\n%v", strings.TrimLeft(arg, "-"), l.GetHelp())
+			return 1
+		}
+	}
+	switch mode {
+	case "extended":
+		fmt.Fprintln(sys.Out(), "CPU NODE SOCKET CORE L1d:L1i:L2 ONLINE\n  0    0      0    0 0:0:0       yes")
+	case "parse":
+		fmt.Fprintln(sys.Out(), "# The following is the parsable format, which can be fed to other\n"+
+			"# programs. Each different item in every column has an unique ID\n# starting from zero.\n"+
+			"# CPU,Core,Socket,Node,,L1d,L1i,L2\n0,0,0,0,,0,0,0")
+	case "offline":
+	case "json":
+		type field struct {
+			Field string `json:"field"`
+			Data  string `json:"data"`
+		}
+		var fields []field
+		for _, f := range lscpuFields() {
+			fields = append(fields, field{f[0] + ":", f[1]})
+		}
+		out, _ := json.MarshalIndent(map[string][]field{"lscpu": fields}, "", "   ")
+		fmt.Fprintln(sys.Out(), string(out))
+	default:
+		for _, f := range lscpuFields() {
+			fmt.Fprintf(sys.Out(), "%-23v%v\n", f[0]+":", f[1])
+		}
+	}
+	return 0
+}
+
+func (nproc) GetHelp() string {
+	return `Usage: nproc [OPTION]...
+Print the number of processing units available to the current process,
+which may be less than the number of online processors
+
+      --all      print the number of installed processors
+      --ignore=N  if possible, exclude N processing units
+      --help     display this help and exit
+      --version  output version information and exit
+`
+}
+
+func (nproc) Where() string {
+	return "/usr/bin/nproc"
+}
+
+func (n nproc) Exec(args []string, sys honeyos.Sys) int {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--all":
+		case arg == "--help":
+			fmt.Fprint(sys.Out(), n.GetHelp())
+			return 0
+		case arg == "--version":
+			fmt.Fprintln(sys.Out(), "nproc (GNU coreutils) 8.32")
+			return 0
+		case strings.HasPrefix(arg, "--ignore"):
+			value := strings.TrimPrefix(strings.TrimPrefix(arg, "--ignore"), "=")
+			if value == "" && i+1 < len(args) {
+				i++
+				value = args[i]
+			}
+			if _, err := strconv.ParseUint(value, 10, 64); err != nil {
+				fmt.Fprintf(sys.Err(), "nproc: invalid number: '%v'\n", value)
+				return 1
+			}
+		default:
+			fmt.Fprintf(sys.Err(), "nproc: extra operand '%v'\nTry 'nproc --help' for more information.\n", arg)
+			return 1
+		}
+	}
+	// The one processor is never ignored
+	fmt.Fprintln(sys.Out(), 1)
+	return 0
+}
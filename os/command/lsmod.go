@@ -0,0 +1,89 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+)
+
+type lsmod struct{}
+
+// kernelModule is the loaded module as /proc/modules lists it
+type kernelModule struct {
+	name   string
+	size   int
+	usedBy []string
+}
+
+func init() {
+	honeyos.RegisterCommand("lsmod", lsmod{})
+	honeyos.RegisterFile("/proc/modules", func(sys honeyos.Sys, _ []byte) []byte { return []byte(procModules(sys)) })
+}
+
+// kernelModules are the modules a KVM guest loads, with the drivers of the
+// devices lspci shows
+func kernelModules(sys honeyos.Sys) []kernelModule {
+	mods := []kernelModule{
+		{"kvm_intel", 282624, nil},
+		{"kvm", 663552, []string{"kvm_intel"}},
+		{"irqbypass", 16384, []string{"kvm"}},
+		{"crct10dif_pclmul", 16384, nil},
+		{"crc32_pclmul", 16384, nil},
+		{"ghash_clmulni_intel", 16384, nil},
+		{"aesni_intel", 372736, nil},
+		{"crypto_simd", 16384, []string{"aesni_intel"}},
+		{"cryptd", 24576, []string{"crypto_simd", "ghash_clmulni_intel"}},
+		{"glue_helper", 16384, []string{"aesni_intel"}},
+		{"joydev", 24576, nil},
+		{"input_leds", 16384, nil},
+		{"serio_raw", 20480, nil},
+		{"i2c_piix4", 28672, nil},
+		{"mac_hid", 16384, nil},
+		{"autofs4", 45056, nil},
+		{"psmouse", 155648, nil},
+		{"floppy", 81920, nil},
+		{"pata_acpi", 16384, nil},
+	}
+	disks := dmesgDisks(sys.Mounts())
+	if len(disks) > 0 && strings.HasPrefix(disks[0].name, "vd") {
+		mods = append(mods, kernelModule{"virtio_net", 57344, nil}, kernelModule{"net_failover", 20480, []string{"virtio_net"}},
+			kernelModule{"failover", 16384, []string{"net_failover"}}, kernelModule{"virtio_blk", 20480, nil})
+	} else {
+		mods = append(mods, kernelModule{"e1000", 147456, nil}, kernelModule{"virtio_scsi", 20480, nil})
+	}
+	return mods
+}
+
+// procModules is the content of /proc/modules
+func procModules(sys honeyos.Sys) string {
+	var b strings.Builder
+	for _, m := range kernelModules(sys) {
+		deps := "-"
+		if len(m.usedBy) > 0 {
+			deps = strings.Join(m.usedBy, ",") + ","
+		}
+		fmt.Fprintf(&b, "%v %v %v %v Live 0x0000000000000000\n", m.name, m.size, len(m.usedBy), deps)
+	}
+	return b.String()
+}
+
+func (lsmod) GetHelp() string {
+	return "Usage: lsmod\n"
+}
+
+func (lsmod) Where() string {
+	return "/sbin/lsmod"
+}
+
+func (l lsmod) Exec(args []string, sys honeyos.Sys) int {
+	if len(args) > 0 {
+		fmt.Fprint(sys.Err(), l.GetHelp())
+		return 1
+	}
+	fmt.Fprintln(sys.Out(), "Module                  Size  Used by")
+	for _, m := range kernelModules(sys) {
+		fmt.Fprintf(sys.Out(), "%-19v %8v  %v %v\n", m.name, m.size, len(m.usedBy), strings.Join(m.usedBy, ","))
+	}
+	return 0
+}
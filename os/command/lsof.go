@@ -0,0 +1,501 @@
+package command
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/afero"
+)
+
+// lsof lists the files open by the processes of the process table, and the
+// sockets the same as netstat shows
+type lsof struct{}
+
+// lsofRow is a line of lsof, a file open by the process
+type lsofRow struct {
+	proc                               honeyos.ProcInfo
+	fd, kind, device, size, node, name string
+	sock                               *honeyos.SockInfo
+}
+
+// lsofInet is the network files selected by -i, like 4tcp@10.0.0.1:22
+type lsofInet struct {
+	version     byte
+	proto, host string
+	ports       string
+}
+
+const lsofUsage = `lsof 4.89
+ latest revision: ftp://lsof.itap.purdue.edu/pub/tools/unix/lsof/
+ latest FAQ: ftp://lsof.itap.purdue.edu/pub/tools/unix/lsof/FAQ
+ latest man page: ftp://lsof.itap.purdue.edu/pub/tools/unix/lsof/lsof_man
+ usage: [-?abhKlnNoOPRtUvVX] [+|-c c] [+|-d s] [+D D] [+|-E] [+|-e s] [+|-f[gG]]
+ [-F [f]] [-g [s]] [-i [i]] [+|-L [l]] [+m [m]] [+|-M] [-o [o]] [-p s]
+ [+|-r [t]] [-s [p:s]] [-S [t]] [-T [t]] [-u s] [+|-w] [-x [fl]] [--] [names]
+`
+
+func init() {
+	honeyos.RegisterCommand("lsof", lsof{})
+}
+
+func (lsof) GetHelp() string {
+	return lsofUsage + `Defaults in parentheses; comma-separated set (s) items; dash-separated ranges.
+  -?|-h list help          -a AND selections (OR)     -b avoid kernel blocks
+  -c c  cmd c ^c /c/[bix]  +c w  COMMAND width (9)    +d s  dir s files
+  -d s  select by FD set   +D D  dir D tree *SLOW?*   +|-e s  exempt s *RISKY*
+  -i select IPv[46] files  -K list tasKs (threads)    -l list UID numbers
+  -n no host names         -N select NFS files        -o list file offset
+  -O no overhead *RISKY*   -P no port names           -R list paRent PID
+  -s list file size        -t terse listing           -T disable TCP/TPI info
+  -U select Unix socket    -v list version info       -V verbose search
+  +|-w  Warnings (+)       -X skip TCP&UDP* files     -Z Z  context [Z]
+  -- end option scan
+  -g [s] exclude(^)|select and print process group IDs
+  -i i   select by IPv[46] address: [46][proto][@host|addr][:svc_list|port_list]
+  +|-r [t[m<fmt>]] repeat every t seconds (15);  + until no files, - forever.
+  -s p:s  exclude(^)|select protocol (p = TCP|UDP) states by name(s).
+  -u s   exclude(^)|select login|UID set s
+  names  select named files or files on named file systems
+Anyone can list all files; /dev warnings disabled; kernel ID check disabled.
+`
+}
+
+func (lsof) Where() string {
+	if pkgFamily() == "rpm" {
+		return "/usr/sbin/lsof"
+	}
+	return "/usr/bin/lsof"
+}
+
+func (l lsof) Exec(args []string, sys honeyos.Sys) int {
+	if !loadPkgDB(sys, pkgFamily()).installed("lsof") {
+		return honeyos.CommandNotFound(sys, append([]string{"lsof"}, args...))
+	}
+	var inets []lsofInet
+	var pids, users, cmds, files []string
+	inet, and, terse, numericHost, numericPort, numericUser := false, false, false, false, false, false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			files = append(files, args[i+1:]...)
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			files = append(files, arg)
+			continue
+		}
+		for j := 1; j < len(arg); j++ {
+			switch c := arg[j]; c {
+			case 'a':
+				and = true
+			case 't':
+				terse = true
+			case 'n':
+				numericHost = true
+			case 'P':
+				numericPort = true
+			case 'l':
+				numericUser = true
+			case 'b', 'w', 'V', 'N', 'U', 'R', 'K', 'X', 'o', 's':
+			case 'i':
+				// The address is optional, taken from the next argument if it
+				// looks like one
+				spec := arg[j+1:]
+				if spec == "" && i+1 < len(args) && lsofInetArg(args[i+1]) {
+					i++
+					spec = args[i]
+				}
+				inet = true
+				if spec != "" {
+					in, ok := parseLsofInet(spec)
+					if !ok {
+						fmt.Fprintf(sys.Err(), "lsof: unknown protocol name (%v) in: -i %v\n%v", spec, spec, lsofUsage)
+						return 1
+					}
+					inets = append(inets, in)
+				}
+				j = len(arg)
+			case 'p', 'u', 'c':
+				val := arg[j+1:]
+				if val == "" {
+					if i+1 == len(args) {
+						fmt.Fprintf(sys.Err(), "lsof: -%c not followed by a value\n%v", c, lsofUsage)
+						return 1
+					}
+					i++
+					val = args[i]
+				}
+				switch c {
+				case 'p':
+					for _, pid := range strings.Split(val, ",") {
+						if _, err := strconv.Atoi(strings.TrimPrefix(pid, "^")); err != nil {
+							fmt.Fprintf(sys.Err(), "lsof: illegal process ID: %v\n%v", pid, lsofUsage)
+							return 1
+						}
+					}
+					pids = append(pids, strings.Split(val, ",")...)
+				case 'u':
+					users = append(users, strings.Split(val, ",")...)
+				case 'c':
+					cmds = append(cmds, val)
+				}
+				j = len(arg)
+			case 'h', '?':
+				fmt.Fprint(sys.Err(), l.GetHelp())
+				return 0
+			case 'v':
+				fmt.Fprintln(sys.Err(), "lsof version information:\n    revision: 4.89\n    latest revision: ftp://lsof.itap.purdue.edu/pub/tools/unix/lsof/\n"+
+					"    latest FAQ: ftp://lsof.itap.purdue.edu/pub/tools/unix/lsof/FAQ\n"+
+					"    latest man page: ftp://lsof.itap.purdue.edu/pub/tools/unix/lsof/lsof_man\n    constructed: Tue Dec 20 20:32:47 UTC 2016")
+				return 0
+			default:
+				fmt.Fprintf(sys.Err(), "lsof: illegal option character: %c\n%v", c, lsofUsage)
+				return 1
+			}
+		}
+	}
+
+	status := 0
+	var names []string
+	for _, f := range files {
+		if _, err := sys.FSys().Stat(absPath(sys, f)); err != nil {
+			fmt.Fprintf(sys.Err(), "lsof: status error on %v: No such file or directory\n", f)
+			status = 1
+			continue
+		}
+		names = append(names, path.Clean(absPath(sys, f)))
+	}
+	if len(files) > 0 && len(names) == 0 {
+		fmt.Fprint(sys.Err(), lsofUsage)
+		return 1
+	}
+
+	viewer := honeyos.GetUserByID(sys.CurrentUser()).Name
+	var rows []lsofRow
+	for _, p := range sys.Processes() {
+		for _, r := range l.files(sys, p, viewer) {
+			// Each kind of selection matches on its own, and the row is listed
+			// if any of them does, or all with -a
+			var sel []bool
+			if len(pids) > 0 {
+				sel = append(sel, lsofMatch(pids, func(s string) bool { return s == strconv.Itoa(p.PID) }))
+			}
+			if len(users) > 0 {
+				sel = append(sel, lsofMatch(users, func(s string) bool {
+					return s == p.User || s == strconv.Itoa(honeyos.GetUser(p.User).UID)
+				}))
+			}
+			if len(cmds) > 0 {
+				sel = append(sel, lsofMatch(cmds, func(s string) bool { return strings.HasPrefix(p.Comm(), s) }))
+			}
+			if inet {
+				sel = append(sel, r.sock != nil && (len(inets) == 0 || lsofInetMatch(inets, *r.sock)))
+			}
+			if len(names) > 0 {
+				sel = append(sel, lsofMatch(names, func(s string) bool { return s == r.name }))
+			}
+			match := len(sel) == 0 || and
+			for _, s := range sel {
+				if and {
+					match = match && s
+				} else {
+					match = match || s
+				}
+			}
+			if match {
+				rows = append(rows, r)
+			}
+		}
+	}
+	if len(rows) == 0 {
+		return 1
+	}
+	sys.Log().WithField("args", strings.Join(args, " ")).Infof("User listed %v open files", len(rows))
+
+	if terse {
+		seen := map[int]bool{}
+		for _, r := range rows {
+			if !seen[r.proc.PID] {
+				seen[r.proc.PID] = true
+				fmt.Fprintln(sys.Out(), r.proc.PID)
+			}
+		}
+		return status
+	}
+	table := [][]string{{"COMMAND", "PID", "USER", "FD", "TYPE", "DEVICE", "SIZE/OFF", "NODE", "NAME"}}
+	for _, r := range rows {
+		comm := r.proc.Comm()
+		if len(comm) > 9 {
+			comm = comm[:9]
+		}
+		user := r.proc.User
+		switch {
+		case numericUser:
+			user = strconv.Itoa(honeyos.GetUser(user).UID)
+		case len(user) > 8:
+			user = user[:8]
+		}
+		name := r.name
+		if r.sock != nil {
+			name = lsofSockName(*r.sock, numericHost, numericPort)
+		}
+		table = append(table, []string{comm, strconv.Itoa(r.proc.PID), user, r.fd, r.kind, r.device, r.size, r.node, name})
+	}
+	// The FD is the number or name aligned right, followed by the mode and
+	// the lock
+	fdWidth := 4
+	for _, row := range table {
+		fd, mode := row[3], " "
+		if fd[0] >= '0' && fd[0] <= '9' {
+			fd, mode = fd[:len(fd)-1], fd[len(fd)-1:]
+		}
+		row[3] = fmt.Sprintf("%*v%v ", fdWidth, fd, mode)
+	}
+	widths := make([]int, len(table[0]))
+	for _, row := range table {
+		for c, cell := range row {
+			widths[c] = intMax(widths[c], len(cell))
+		}
+	}
+	for _, row := range table {
+		var b strings.Builder
+		for c, cell := range row {
+			if c > 0 {
+				b.WriteString(" ")
+			}
+			switch c {
+			case 0, 2:
+				fmt.Fprintf(&b, "%-*v", widths[c], cell)
+			case 8:
+				b.WriteString(cell)
+			default:
+				fmt.Fprintf(&b, "%*v", widths[c], cell)
+			}
+		}
+		fmt.Fprintln(sys.Out(), b.String())
+	}
+	return status
+}
+
+// files returns the files open by the process: the working and root
+// directories, the executable and libraries, the standard streams and the
+// sockets. Processes of other users can't be looked into unless root
+func (lsof) files(sys honeyos.Sys, p honeyos.ProcInfo, viewer string) []lsofRow {
+	file := func(fd, name string) lsofRow {
+		r := lsofRow{proc: p, fd: fd, kind: "REG", device: "8,1", size: "0", name: name,
+			node: strconv.FormatUint(fnvString(name)%4000000+12, 10)}
+		if fi, err := sys.FSys().Stat(name); err == nil {
+			if fi.IsDir() {
+				r.kind = "DIR"
+			}
+			r.size, r.node = strconv.FormatInt(fi.Size(), 10), strconv.FormatUint(honeyos.Inode(name, fi), 10)
+		}
+		return r
+	}
+	unknown := func(fd, name string) lsofRow {
+		return lsofRow{proc: p, fd: fd, kind: "unknown", name: name}
+	}
+	pid := strconv.Itoa(p.PID)
+	if strings.HasPrefix(p.Cmd, "[") {
+		return []lsofRow{file("cwd", "/"), file("rtd", "/"), unknown("txt", "/proc/"+pid+"/exe")}
+	}
+	if viewer != "root" && viewer != p.User {
+		return []lsofRow{
+			unknown("cwd", "/proc/"+pid+"/cwd (readlink: Permission denied)"),
+			unknown("rtd", "/proc/"+pid+"/root (readlink: Permission denied)"),
+			unknown("txt", "/proc/"+pid+"/exe (readlink: Permission denied)"),
+			{proc: p, fd: "NOFD", name: "/proc/" + pid + "/fd (opendir: Permission denied)"},
+		}
+	}
+
+	cwd := "/"
+	if p.TTY != "?" {
+		cwd = sys.Getcwd()
+	}
+	rows := []lsofRow{file("cwd", cwd), file("rtd", "/"), file("txt", lsofExe(sys, p.Cmd))}
+	for _, dir := range []string{"/lib/x86_64-linux-gnu", "/lib64", "/lib"} {
+		libs, _ := afero.Glob(sys.FSys(), dir+"/libc-*.so")
+		ld, _ := afero.Glob(sys.FSys(), dir+"/ld-*.so")
+		if len(libs) > 0 {
+			for _, lib := range append(libs[:1], ld...) {
+				rows = append(rows, file("mem", lib))
+			}
+			break
+		}
+	}
+	std := lsofRow{proc: p, kind: "CHR", device: "1,3", size: "0t0", node: "6", name: "/dev/null"}
+	if p.TTY != "?" {
+		std.device, std.node, std.name = "136,0", "3", "/dev/"+p.TTY
+	}
+	for fd := 0; fd < 3; fd++ {
+		std.fd = fmt.Sprintf("%du", fd)
+		rows = append(rows, std)
+	}
+	last := 2
+	for _, s := range sys.Sockets() {
+		if s.PID != p.PID {
+			continue
+		}
+		s := s
+		kind, node := "IPv4", "TCP"
+		if strings.HasSuffix(s.Proto, "6") {
+			kind = "IPv6"
+		}
+		if strings.HasPrefix(s.Proto, "udp") {
+			node = "UDP"
+		}
+		rows = append(rows, lsofRow{proc: p, fd: fmt.Sprintf("%du", s.FD), kind: kind, size: "0t0", node: node, sock: &s,
+			device: strconv.FormatUint(fnvString(s.Local+s.Remote)%90000+10000, 10)})
+		last = intMax(last, s.FD)
+	}
+	// The sshd of the session holds the master of its terminal
+	if strings.HasPrefix(p.Cmd, "sshd: ") && strings.Contains(p.Cmd, "@pts/") {
+		rows = append(rows, lsofRow{proc: p, fd: fmt.Sprintf("%du", last+1), kind: "CHR", device: "5,2", size: "0t0",
+			node: "1087", name: "/dev/ptmx"})
+	}
+	return rows
+}
+
+// lsofExe returns the executable of the command line
+func lsofExe(sys honeyos.Sys, cmd string) string {
+	fields := strings.Fields(cmd)
+	if len(fields) == 0 {
+		return "/"
+	}
+	name := strings.TrimPrefix(fields[0], "-")
+	switch {
+	case name == "sshd:":
+		return "/usr/sbin/sshd"
+	case path.IsAbs(name):
+		return name
+	}
+	if found := honeyos.LookPath(sys, honeyos.Getenv(sys, "PATH"), name); len(found) > 0 {
+		return found[0]
+	}
+	return "/usr/bin/" + name
+}
+
+// lsofMatch tells if the item matches the list, where items with ^ exclude
+func lsofMatch(list []string, match func(string) bool) bool {
+	selected, included := false, false
+	for _, s := range list {
+		if strings.HasPrefix(s, "^") {
+			if match(s[1:]) {
+				return false
+			}
+			continue
+		}
+		included = true
+		selected = selected || match(s)
+	}
+	return selected || !included
+}
+
+// lsofInetArg tells if the argument following -i is its address
+func lsofInetArg(arg string) bool {
+	arg = strings.ToLower(arg)
+	return strings.HasPrefix(arg, "4") || strings.HasPrefix(arg, "6") || strings.HasPrefix(arg, ":") ||
+		strings.HasPrefix(arg, "@") || strings.HasPrefix(arg, "tcp") || strings.HasPrefix(arg, "udp")
+}
+
+// parseLsofInet parses the address of -i as [46][proto][@host][:ports]
+func parseLsofInet(spec string) (lsofInet, bool) {
+	var in lsofInet
+	if spec[0] == '4' || spec[0] == '6' {
+		in.version, spec = spec[0], spec[1:]
+	}
+	if i := strings.LastIndexByte(spec, ':'); i >= 0 && !strings.HasSuffix(spec, "]") {
+		in.ports, spec = spec[i+1:], spec[:i]
+	}
+	if i := strings.IndexByte(spec, '@'); i >= 0 {
+		in.host, spec = strings.Trim(spec[i+1:], "[]"), spec[:i]
+	}
+	switch in.proto = strings.ToLower(spec); in.proto {
+	case "", "tcp", "udp":
+		return in, true
+	}
+	return in, false
+}
+
+// lsofInetMatch tells if the socket is selected by any of the addresses
+func lsofInetMatch(inets []lsofInet, s honeyos.SockInfo) bool {
+	for _, in := range inets {
+		switch {
+		case in.version == '4' && strings.HasSuffix(s.Proto, "6"), in.version == '6' && !strings.HasSuffix(s.Proto, "6"):
+			continue
+		case in.proto != "" && !strings.HasPrefix(s.Proto, in.proto):
+			continue
+		}
+		localHost, localPort := honeyos.SplitAddr(s.Local)
+		remoteHost, remotePort := honeyos.SplitAddr(s.Remote)
+		if in.host != "" {
+			host := in.host
+			if host == "localhost" {
+				host = "127.0.0.1"
+			}
+			if host != localHost && host != remoteHost {
+				continue
+			}
+		}
+		if in.ports != "" && !lsofPort(in.ports, localPort) && !lsofPort(in.ports, remotePort) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// lsofPort tells if the port is in the list of ports, ranges like 1-1024
+// and service names
+func lsofPort(list, port string) bool {
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		return false
+	}
+	for _, item := range strings.Split(list, ",") {
+		lo, hi := item, item
+		if i := strings.IndexByte(item, '-'); i > 0 {
+			lo, hi = item[:i], item[i+1:]
+		}
+		from, err1 := strconv.Atoi(lo)
+		to, err2 := strconv.Atoi(hi)
+		switch {
+		case err1 == nil && err2 == nil && n >= from && n <= to:
+			return true
+		case services[port] == item:
+			return true
+		}
+	}
+	return false
+}
+
+// lsofSockName is the name of the socket like localhost:ssh->10.0.0.1:51234
+// (ESTABLISHED)
+func lsofSockName(s honeyos.SockInfo, numericHost, numericPort bool) string {
+	addr := func(a string) string {
+		host, port := honeyos.SplitAddr(a)
+		switch {
+		case host == "0.0.0.0" || host == "::" || host == "*":
+			host = "*"
+		case (host == "127.0.0.1" || host == "::1") && !numericHost:
+			host = "localhost"
+		case strings.Contains(host, ":"):
+			host = "[" + host + "]"
+		}
+		if name, ok := services[port]; ok && !numericPort {
+			port = name
+		}
+		return host + ":" + port
+	}
+	name := addr(s.Local)
+	if _, port := honeyos.SplitAddr(s.Remote); port != "*" {
+		name += "->" + addr(s.Remote)
+	}
+	if s.State != "" {
+		name += " (" + s.State + ")"
+	}
+	return name
+}
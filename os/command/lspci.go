@@ -0,0 +1,232 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+)
+
+type lspci struct{}
+
+type lsusb struct{}
+
+// pciDev is the device on the PCI bus of the VM
+type pciDev struct {
+	slot, class, classID, vendor, device, ids, driver string
+}
+
+func init() {
+	honeyos.RegisterCommand("lspci", lspci{})
+	honeyos.RegisterCommand("lsusb", lsusb{})
+}
+
+// pciDevices are the devices QEMU gives the i440FX machine, with disks and
+// network on virtio or emulated hardware like dmesg finds them. The VGA is
+// the emulated Cirrus, no GPU for mining
+func pciDevices(sys honeyos.Sys) []pciDev {
+	devs := []pciDev{
+		{"00:00.0", "Host bridge", "0600", "Intel Corporation", "440FX - 82441FX PMC [Natoma] (rev 02)", "8086:1237", ""},
+		{"00:01.0", "ISA bridge", "0601", "Intel Corporation", "82371SB PIIX3 ISA [Natoma/Triton II]", "8086:7000", ""},
+		{"00:01.1", "IDE interface", "0101", "Intel Corporation", "82371SB PIIX3 IDE [Natoma/Triton II]", "8086:7010", "ata_piix"},
+		{"00:01.2", "USB controller", "0c03", "Intel Corporation", "82371SB PIIX3 USB [Natoma/Triton II] (rev 01)", "8086:7020", "uhci_hcd"},
+		{"00:01.3", "Bridge", "0680", "Intel Corporation", "82371AB/EB/MB PIIX4 ACPI (rev 03)", "8086:7113", "piix4_smbus"},
+		{"00:02.0", "VGA compatible controller", "0300", "Cirrus Logic", "GD 5446", "1013:00b8", ""},
+	}
+	disks := dmesgDisks(sys.Mounts())
+	if len(disks) > 0 && strings.HasPrefix(disks[0].name, "vd") {
+		devs = append(devs,
+			pciDev{"00:03.0", "Ethernet controller", "0200", "Red Hat, Inc.", "Virtio network device", "1af4:1000", "virtio-pci"},
+			pciDev{"00:04.0", "SCSI storage controller", "0100", "Red Hat, Inc.", "Virtio block device", "1af4:1001", "virtio-pci"},
+			pciDev{"00:05.0", "Unclassified device [00ff]", "00ff", "Red Hat, Inc.", "Virtio memory balloon", "1af4:1002", "virtio-pci"})
+	} else {
+		devs = append(devs,
+			pciDev{"00:03.0", "Ethernet controller", "0200", "Intel Corporation", "82540EM Gigabit Ethernet Controller (rev 03)", "8086:100e", "e1000"},
+			pciDev{"00:04.0", "SCSI storage controller", "0100", "Red Hat, Inc.", "Virtio SCSI", "1af4:1004", "virtio-pci"},
+			pciDev{"00:05.0", "Unclassified device [00ff]", "00ff", "Red Hat, Inc.", "Virtio memory balloon", "1af4:1002", "virtio-pci"})
+	}
+	return devs
+}
+
+func (lspci) GetHelp() string {
+	return `Usage: lspci [<switches>]
+
+Basic display modes:
+-mm		Produce machine-readable output (single -m for an obsolete format)
+-t		Show bus tree
+
+Display options:
+-v		Be verbose (-vv for very verbose)
+-k		Show kernel drivers handling each device
+-x		Show hex-dump of the standard part of the config space
+-b		Bus-centric view (addresses and IRQ's as seen by the bus)
+-D		Always show domain numbers
+
+Resolving of device ID's to names:
+-n		Show numeric ID's
+-nn		Show both textual and numeric ID's (names & numbers)
+-q		Query the PCI ID database for unknown ID's via DNS
+-qq		As above, but re-query locally cached entries
+-Q		Query the PCI ID database for all ID's via DNS
+
+Selection of devices:
+-s [[[[<domain>]:]<bus>]:][<slot>][.[<func>]]	Show only devices in selected slots
+-d [<vendor>]:[<device>]			Show only devices with specified ID's
+`
+}
+
+func (lspci) Where() string {
+	return "/usr/bin/lspci"
+}
+
+func (l lspci) Exec(args []string, sys honeyos.Sys) int {
+	numeric, both, verbose, kernel, domain, tree := false, false, false, false, false, false
+	opts := true
+	for _, arg := range args {
+		if !opts || arg == "-" || !strings.HasPrefix(arg, "-") {
+			fmt.Fprintf(sys.Err(), "lspci: Unexpected arguments\n")
+			return 2
+		}
+		switch arg {
+		case "--":
+			// The arguments after are not options
+			opts = false
+		case "--help":
+			fmt.Fprint(sys.Out(), l.GetHelp())
+			return 0
+		case "--version":
+			fmt.Fprintln(sys.Out(), "lspci version 3.3.1")
+			return 0
+		case "-n":
+			numeric = true
+		case "-nn":
+			both = true
+		case "-v", "-vv", "-vvv":
+			verbose, kernel = true, true
+		case "-k":
+			kernel = true
+		case "-D":
+			domain = true
+		case "-t", "-tv":
+			tree = true
+		case "-m", "-mm", "-q", "-qq", "-Q", "-b", "-x":
+		default:
+			if strings.HasPrefix(arg, "--") {
+				fmt.Fprintf(sys.Err(), "lspci: unrecognized option '%v'\n%v", arg, l.GetHelp())
+			} else {
+				fmt.Fprintf(sys.Err(), "lspci: invalid option -- '%v'\n%v", arg[1:2], l.GetHelp())
+			}
+			return 2
+		}
+	}
+	devs := pciDevices(sys)
+	if honeyos.Distro() == "alpine" {
+		// lspci of busybox shows only the numbers
+		for _, d := range devs {
+			fmt.Fprintf(sys.Out(), "%v Class %v: %v\n", d.slot, d.classID, d.ids)
+		}
+		return 0
+	}
+	if tree {
+		fmt.Fprintln(sys.Out(), "-[0000:00]-+-00.0")
+		for i, d := range devs[1:] {
+			branch := "+-"
+			if i == len(devs)-2 {
+				branch = "\\-"
+			}
+			fmt.Fprintf(sys.Out(), "           %v%v\n", branch, d.slot[3:])
+		}
+		return 0
+	}
+	for _, d := range devs {
+		slot := d.slot
+		if domain {
+			slot = "0000:" + slot
+		}
+		switch {
+		case numeric:
+			fmt.Fprintf(sys.Out(), "%v %v: %v\n", slot, d.classID, d.ids)
+		case both:
+			fmt.Fprintf(sys.Out(), "%v %v [%v]: %v %v [%v]\n", slot, d.class, d.classID, d.vendor, d.device, d.ids)
+		default:
+			fmt.Fprintf(sys.Out(), "%v %v: %v %v\n", slot, d.class, d.vendor, d.device)
+		}
+		if verbose {
+			fmt.Fprintf(sys.Out(), "\tSubsystem: Red Hat, Inc. Qemu virtual machine\n\tFlags: fast devsel\n")
+		} else if kernel {
+			fmt.Fprintln(sys.Out(), "\tSubsystem: Red Hat, Inc. Qemu virtual machine")
+		}
+		if kernel && d.driver != "" {
+			fmt.Fprintf(sys.Out(), "\tKernel driver in use: %v\n", d.driver)
+		}
+		if verbose {
+			fmt.Fprintln(sys.Out())
+		}
+	}
+	return 0
+}
+
+func (lsusb) GetHelp() string {
+	return `Usage: lsusb [options]...
+List USB devices
+  -v, --verbose
+      Increase verbosity (show descriptors)
+  -s [[bus]:][devnum]
+      Show only devices with specified device and/or
+      bus numbers (in decimal)
+  -d vendor:[product]
+      Show only devices with the specified vendor and
+      product ID numbers (in hexadecimal)
+  -D device
+      Selects which device lsusb will examine
+  -t, --tree
+      Dump the physical USB device hierarchy as a tree
+  -V, --version
+      Show version of program
+  -h, --help
+      Show usage and help
+`
+}
+
+func (lsusb) Where() string {
+	return "/usr/bin/lsusb"
+}
+
+func (l lsusb) Exec(args []string, sys honeyos.Sys) int {
+	for _, arg := range args {
+		if arg == "--" {
+			// The arguments after are not options, and lsusb takes none
+			break
+		}
+		switch arg {
+		case "-h", "--help":
+			fmt.Fprint(sys.Out(), l.GetHelp())
+			return 0
+		case "-V", "--version":
+			fmt.Fprintln(sys.Out(), "lsusb (usbutils) 007")
+			return 0
+		case "-t", "--tree":
+			fmt.Fprintln(sys.Out(), "/:  Bus 01.Port 1: Dev 1, Class=root_hub, Driver=uhci_hcd/2p, 12M\n"+
+				"    |__ Port 1: Dev 2, If 0, Class=Human Interface Device, Driver=usbhid, 12M")
+			return 0
+		case "-v", "--verbose":
+		default:
+			switch {
+			case arg == "-" || !strings.HasPrefix(arg, "-"):
+			case strings.HasPrefix(arg, "--"):
+				fmt.Fprintf(sys.Err(), "lsusb: unrecognized option '%v'\n%v", arg, l.GetHelp())
+				return 1
+			default:
+				fmt.Fprintf(sys.Err(), "lsusb: invalid option -- '%v'\n%v", arg[1:2], l.GetHelp())
+				return 1
+			}
+		}
+	}
+	if honeyos.Distro() == "alpine" {
+		fmt.Fprintln(sys.Out(), "Bus 001 Device 002: ID 0627:0001\nBus 001 Device 001: ID 1d6b:0001")
+		return 0
+	}
+	fmt.Fprintln(sys.Out(), "Bus 001 Device 002: ID 0627:0001 Adomax Technology Co., Ltd \n"+
+		"Bus 001 Device 001: ID 1d6b:0001 Linux Foundation 1.1 root hub")
+	return 0
+}
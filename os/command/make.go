@@ -0,0 +1,935 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/afero"
+)
+
+// gnuMake is GNU make. It reads the makefile with its variables,
+// conditionals and the common functions, and runs the recipes of the
+// targets out of date through the shell, so the compiler and the commands
+// run get logged and captured on their own. Without makefile the built-in
+// rules build the program from its C source
+type gnuMake struct{}
+
+// makeRule is a rule of the makefile, with the % of the stem in pattern
+// rules
+type makeRule struct {
+	targets []string
+	deps    []string
+	recipe  []makeLine
+}
+
+// makeLine is a line of the recipe, and where it is in the makefile
+type makeLine struct {
+	text   string
+	file   string
+	lineNo int
+}
+
+// makeVar is a variable, expanded when set if simple
+type makeVar struct {
+	value  string
+	simple bool
+}
+
+// makeBuild is the make of the distribution
+type makeBuild struct {
+	version, target, years string
+}
+
+var makeBuilds = map[string]makeBuild{
+	"ubuntu": {"4.1", "x86_64-pc-linux-gnu", "1988-2014"},
+	"debian": {"4.1", "x86_64-pc-linux-gnu", "1988-2014"},
+	"centos": {"3.82", "x86_64-redhat-linux-gnu", "2010"},
+	"alpine": {"4.3", "x86_64-alpine-linux-musl", "1988-2020"},
+}
+
+// makeBuiltinRules are the implicit rules of make for C, as make -p shows
+var makeBuiltinRules = []*makeRule{
+	{[]string{"%.o"}, []string{"%.c"}, []makeLine{{text: "$(COMPILE.c) $(OUTPUT_OPTION) $<"}}},
+	{[]string{"%"}, []string{"%.c"}, []makeLine{{text: "$(LINK.c) $^ $(LOADLIBES) $(LDLIBS) -o $@"}}},
+	{[]string{"%"}, []string{"%.o"}, []makeLine{{text: "$(LINK.o) $^ $(LOADLIBES) $(LDLIBS) -o $@"}}},
+}
+
+// makefile is the makefile read, and the state of the targets made
+type makefile struct {
+	sys     honeyos.Sys
+	build   makeBuild
+	dir     string
+	name    string
+	level   int
+	vars    map[string]makeVar
+	rules   map[string]*makeRule
+	pattern []*makeRule
+	phony   map[string]bool
+	goal    string
+	made    map[string]bool
+	making  map[string]bool
+	dryRun  bool
+	silent  bool
+	ignore  bool
+	always  bool
+	// ran tells if any recipe was run, for telling nothing was to be done
+	ran bool
+}
+
+func init() {
+	honeyos.RegisterCommand("make", gnuMake{})
+}
+
+func (gnuMake) GetHelp() string {
+	return `Usage: make [options] [target] ...
+Options:
+  -b, -m                      Ignored for compatibility.
+  -B, --always-make           Unconditionally make all targets.
+  -C DIRECTORY, --directory=DIRECTORY
+                              Change to DIRECTORY before doing anything.
+  -d                          Print lots of debugging information.
+  -e, --environment-overrides
+                              Environment variables override makefiles.
+  -f FILE, --file=FILE, --makefile=FILE
+                              Read FILE as a makefile.
+  -h, --help                  Print this message and exit.
+  -i, --ignore-errors         Ignore errors from recipes.
+  -I DIRECTORY, --include-dir=DIRECTORY
+                              Search DIRECTORY for included makefiles.
+  -j [N], --jobs[=N]          Allow N jobs at once; infinite jobs with no arg.
+  -k, --keep-going            Keep going when some targets can't be made.
+  -n, --just-print, --dry-run, --recon
+                              Don't actually run any recipe; just print them.
+  -s, --silent, --quiet       Don't echo recipes.
+  -v, --version               Print the version number of make and exit.
+  -w, --print-directory       Print the current directory.
+
+This program built for x86_64-pc-linux-gnu
+Report bugs to <bug-make@gnu.org>
+`
+}
+
+func (gnuMake) Where() string {
+	return "/usr/bin/make"
+}
+
+func (mk gnuMake) Exec(args []string, sys honeyos.Sys) int {
+	if !loadPkgDB(sys, pkgFamily()).installed("make") {
+		return honeyos.CommandNotFound(sys, append([]string{"make"}, args...))
+	}
+	build, ok := makeBuilds[honeyos.Distro()]
+	if !ok {
+		build = makeBuilds["ubuntu"]
+	}
+	m := &makefile{sys: sys, build: build, dir: sys.Getcwd(), vars: map[string]makeVar{}, rules: map[string]*makeRule{},
+		phony: map[string]bool{}, made: map[string]bool{}, making: map[string]bool{}}
+	m.level, _ = strconv.Atoi(honeyos.Getenv(sys, "MAKELEVEL"))
+	var files, goals []string
+	overrides := map[string]string{}
+	chdir := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		value := func(opt string) (string, bool) {
+			if v := strings.TrimPrefix(arg, opt); v != "" {
+				return strings.TrimPrefix(v, "="), true
+			}
+			if i+1 == len(args) {
+				fmt.Fprintf(sys.Err(), "make: option requires an argument -- '%v'\n%v", strings.TrimLeft(opt, "-"), makeUsage)
+				return "", false
+			}
+			i++
+			return args[i], true
+		}
+		switch {
+		case arg == "-h" || arg == "--help":
+			fmt.Fprint(sys.Out(), mk.GetHelp())
+			return 0
+		case arg == "-v" || arg == "--version":
+			fmt.Fprintf(sys.Out(), "GNU Make %v\nBuilt for %v\nCopyright (C) %v Free Software Foundation, Inc.\n"+
+				"License GPLv3+: GNU GPL version 3 or later <http://gnu.org/licenses/gpl.html>\n"+
+				"This is free software: you are free to change and redistribute it.\n"+
+				"There is NO WARRANTY, to the extent permitted by law.\n", build.version, build.target, build.years)
+			return 0
+		case strings.HasPrefix(arg, "-C") || strings.HasPrefix(arg, "--directory"):
+			dir, ok := value(map[bool]string{true: "-C", false: "--directory"}[strings.HasPrefix(arg, "-C")])
+			if !ok {
+				return 2
+			}
+			// Each -C is relative to the one before
+			if !path.IsAbs(dir) {
+				dir = path.Join(m.dir, dir)
+			}
+			dir = absPath(sys, dir)
+			if fi, err := sys.FSys().Stat(dir); err != nil || !fi.IsDir() {
+				fmt.Fprintf(sys.Err(), "make: *** %v: No such file or directory.  Stop.\n", dir)
+				return 2
+			}
+			m.dir, chdir = dir, true
+		case strings.HasPrefix(arg, "-f") || strings.HasPrefix(arg, "--file") || strings.HasPrefix(arg, "--makefile"):
+			opt := "-f"
+			if strings.HasPrefix(arg, "--") {
+				opt = strings.SplitN(arg, "=", 2)[0]
+			}
+			f, ok := value(opt)
+			if !ok {
+				return 2
+			}
+			files = append(files, f)
+		case len(arg) >= 2 && strings.Contains("IoW", arg[1:2]) && arg[0] == '-':
+			if _, ok := value(arg[:2]); !ok {
+				return 2
+			}
+		case strings.HasPrefix(arg, "-j") || strings.HasPrefix(arg, "--jobs") || strings.HasPrefix(arg, "-l"):
+			// The number of jobs is optional
+			if i+1 < len(args) && len(arg) == 2 {
+				if _, err := strconv.Atoi(args[i+1]); err == nil {
+					i++
+				}
+			}
+		case arg == "--dry-run" || arg == "--just-print" || arg == "--recon":
+			m.dryRun = true
+		case arg == "--silent" || arg == "--quiet":
+			m.silent = true
+		case arg == "--always-make":
+			m.always = true
+		case arg == "--ignore-errors":
+			m.ignore = true
+		case strings.HasPrefix(arg, "--"):
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			for _, c := range arg[1:] {
+				switch c {
+				case 'n':
+					m.dryRun = true
+				case 's':
+					m.silent = true
+				case 'B':
+					m.always = true
+				case 'i':
+					m.ignore = true
+				case 'b', 'm', 'd', 'e', 'k', 'w', 'r', 'R', 'S', 'q', 't':
+				default:
+					fmt.Fprintf(sys.Err(), "make: invalid option -- '%c'\n%v", c, makeUsage)
+					return 2
+				}
+			}
+		case strings.Contains(arg, "="):
+			kv := strings.SplitN(arg, "=", 2)
+			overrides[strings.TrimSuffix(strings.TrimSpace(kv[0]), ":")] = kv[1]
+		default:
+			goals = append(goals, arg)
+		}
+	}
+
+	if chdir || m.level > 0 {
+		fmt.Fprintf(sys.Out(), "%v: Entering directory %v\n", m.prog(), m.quote(m.dir))
+		defer fmt.Fprintf(sys.Out(), "%v: Leaving directory %v\n", m.prog(), m.quote(m.dir))
+	}
+	m.defaults(overrides)
+	if len(files) == 0 {
+		for _, name := range []string{"GNUmakefile", "makefile", "Makefile"} {
+			if fi, err := sys.FSys().Stat(m.path(name)); err == nil && !fi.IsDir() {
+				files = []string{name}
+				break
+			}
+		}
+	}
+	for _, f := range files {
+		data, err := readFile(sys, m.path(f))
+		if err != nil {
+			fmt.Fprintf(sys.Err(), "make: %v: No such file or directory\nmake: *** No rule to make target %v.  Stop.\n", f, m.quote(f))
+			return 2
+		}
+		m.name = f
+		honeyos.SaveArtifact(sys, data, "make "+m.path(f))
+		if !m.parse(f, string(data)) {
+			return 2
+		}
+	}
+	// Variables of the command line override the makefile
+	for k, v := range overrides {
+		m.vars[k] = makeVar{value: v}
+	}
+	if len(goals) == 0 {
+		if m.goal == "" {
+			if len(files) == 0 {
+				fmt.Fprintf(sys.Err(), "%v: *** No targets specified and no makefile found.  Stop.\n", m.prog())
+			} else {
+				fmt.Fprintf(sys.Err(), "%v: *** No targets.  Stop.\n", m.prog())
+			}
+			return 2
+		}
+		goals = []string{m.goal}
+	}
+	sys.Log().WithField("dir", m.dir).WithField("makefile", m.name).Infof("User ran make of %v", strings.Join(goals, " "))
+	for _, goal := range goals {
+		m.ran = false
+		rule, _ := m.rule(goal)
+		if _, ok := m.make(goal, ""); !ok {
+			return 2
+		}
+		if !m.ran && !m.dryRun {
+			if rule != nil && len(rule.recipe) > 0 && !m.phony[goal] {
+				fmt.Fprintf(sys.Out(), "%v: %v is up to date.\n", m.prog(), m.quote(goal))
+			} else {
+				fmt.Fprintf(sys.Out(), "%v: Nothing to be done for %v.\n", m.prog(), m.quote(goal))
+			}
+		}
+	}
+	return 0
+}
+
+const makeUsage = "Usage: make [options] [target] ...\nTry 'make --help' for more information.\n"
+
+// prog is the name of make in the messages, with the level of recursion
+func (m *makefile) prog() string {
+	if m.level > 0 {
+		return fmt.Sprintf("make[%d]", m.level)
+	}
+	return "make"
+}
+
+// quote quotes the name like the version of make does
+func (m *makefile) quote(s string) string {
+	if m.build.version < "4" {
+		return "`" + s + "'"
+	}
+	return "'" + s + "'"
+}
+
+// path is the file of the name in the directory of make
+func (m *makefile) path(name string) string {
+	if path.IsAbs(name) {
+		return name
+	}
+	return path.Join(m.dir, name)
+}
+
+// defaults sets the variables make starts with, from the environment and
+// the built-in rules
+func (m *makefile) defaults(overrides map[string]string) {
+	for _, env := range m.sys.Environ() {
+		kv := strings.SplitN(env, "=", 2)
+		m.vars[kv[0]] = makeVar{value: kv[1]}
+	}
+	for k, v := range map[string]string{
+		"CC": "cc", "CXX": "g++", "CPP": "$(CC) -E", "RM": "rm -f", "AR": "ar", "ARFLAGS": "rv", "AS": "as",
+		"SHELL": "/bin/sh", "MAKE": "make", "CURDIR": m.dir, "OUTPUT_OPTION": "-o $@",
+		"COMPILE.c": "$(CC) $(CFLAGS) $(CPPFLAGS) $(TARGET_ARCH) -c",
+		"LINK.c":    "$(CC) $(CFLAGS) $(CPPFLAGS) $(LDFLAGS) $(TARGET_ARCH)",
+		"LINK.o":    "$(CC) $(LDFLAGS) $(TARGET_ARCH)",
+	} {
+		m.vars[k] = makeVar{value: v}
+	}
+	for k, v := range overrides {
+		m.vars[k] = makeVar{value: v}
+	}
+}
+
+// parse reads the makefile, with its variables, rules and conditionals
+func (m *makefile) parse(file, data string) bool {
+	lines := strings.Split(strings.Replace(data, "\r\n", "\n", -1), "\n")
+	var current []*makeRule
+	// skip is the state of the conditionals, true where they are false
+	var skip []bool
+	skipping := func() bool {
+		for _, s := range skip {
+			if s {
+				return true
+			}
+		}
+		return false
+	}
+	var define string
+	var defined []string
+	for n := 0; n < len(lines); n++ {
+		lineNo := n + 1
+		line := lines[n]
+		for strings.HasSuffix(line, "\\") && n+1 < len(lines) {
+			n++
+			line = strings.TrimSuffix(line, "\\") + " " + strings.TrimLeft(lines[n], " \t")
+		}
+		if define != "" {
+			if strings.TrimSpace(line) == "endef" {
+				if !skipping() {
+					m.vars[define] = makeVar{value: strings.Join(defined, "\n")}
+				}
+				define, defined = "", nil
+			} else {
+				defined = append(defined, line)
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "\t") && current != nil {
+			if !skipping() {
+				for _, r := range current {
+					r.recipe = append(r.recipe, makeLine{strings.TrimPrefix(line, "\t"), file, lineNo})
+				}
+			}
+			continue
+		}
+		if i := strings.IndexByte(line, '#'); i >= 0 && (i == 0 || line[i-1] != '\\') {
+			line = line[:i]
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		word := strings.Fields(trimmed)[0]
+		rest := strings.TrimSpace(strings.TrimPrefix(trimmed, word))
+		switch word {
+		case "ifeq", "ifneq":
+			a, b := m.condArgs(rest)
+			skip = append(skip, (a == b) != (word == "ifeq"))
+			continue
+		case "ifdef", "ifndef":
+			_, ok := m.vars[m.expand(rest, nil)]
+			skip = append(skip, ok != (word == "ifdef"))
+			continue
+		case "else":
+			if len(skip) > 0 {
+				skip[len(skip)-1] = !skip[len(skip)-1]
+			}
+			continue
+		case "endif":
+			if len(skip) > 0 {
+				skip = skip[:len(skip)-1]
+			}
+			continue
+		}
+		if skipping() {
+			continue
+		}
+		switch word {
+		case "define":
+			define = strings.TrimSpace(strings.TrimRight(rest, "=:"))
+			continue
+		case "include", "-include", "sinclude":
+			for _, inc := range strings.Fields(m.expand(rest, nil)) {
+				data, err := readFile(m.sys, m.path(inc))
+				if err != nil {
+					if word != "include" {
+						continue
+					}
+					fmt.Fprintf(m.sys.Err(), "%v:%d: %v: No such file or directory\n%v: *** No rule to make target %v.  Stop.\n",
+						file, lineNo, inc, m.prog(), m.quote(inc))
+					return false
+				}
+				if !m.parse(inc, string(data)) {
+					return false
+				}
+			}
+			continue
+		case "export", "unexport", "override", "private":
+			trimmed = rest
+			if !strings.Contains(trimmed, "=") {
+				continue
+			}
+		case "vpath":
+			continue
+		}
+		if name, op, value, ok := makeAssignment(trimmed); ok {
+			m.assign(name, op, value)
+			current = nil
+			continue
+		}
+		colon := strings.IndexByte(trimmed, ':')
+		if colon < 0 {
+			fmt.Fprintf(m.sys.Err(), "%v:%d: *** missing separator.  Stop.\n", file, lineNo)
+			return false
+		}
+		targets := strings.Fields(m.expand(trimmed[:colon], nil))
+		deps := strings.TrimLeft(trimmed[colon+1:], ":")
+		var recipe []makeLine
+		if i := strings.IndexByte(deps, ';'); i >= 0 {
+			recipe = append(recipe, makeLine{strings.TrimSpace(deps[i+1:]), file, lineNo})
+			deps = deps[:i]
+		}
+		var depList []string
+		for _, d := range strings.Fields(m.expand(deps, nil)) {
+			if d != "|" {
+				depList = append(depList, d)
+			}
+		}
+		current = nil
+		for _, t := range targets {
+			if t == ".PHONY" {
+				for _, d := range depList {
+					m.phony[d] = true
+				}
+				continue
+			}
+			if strings.HasPrefix(t, ".") && !strings.Contains(t, "/") {
+				continue
+			}
+			if strings.Contains(t, "%") {
+				r := &makeRule{[]string{t}, depList, recipe}
+				m.pattern = append(m.pattern, r)
+				current = append(current, r)
+				continue
+			}
+			if m.goal == "" {
+				m.goal = t
+			}
+			r, ok := m.rules[t]
+			if !ok {
+				r = &makeRule{targets: []string{t}}
+				m.rules[t] = r
+			}
+			r.deps = append(r.deps, depList...)
+			if recipe != nil {
+				r.recipe = append([]makeLine(nil), recipe...)
+			}
+			current = append(current, r)
+		}
+	}
+	return true
+}
+
+// makeAssignment splits the assignment of the variable with its operator,
+// like CFLAGS += -O2
+func makeAssignment(line string) (name, op, value string, ok bool) {
+	i := strings.IndexAny(line, "=:")
+	if i <= 0 {
+		return "", "", "", false
+	}
+	ops := []string{"::=", ":=", "?=", "+=", "!=", "="}
+	end := i
+	if end > 0 && strings.ContainsAny(line[end-1:end], "?+!") {
+		end--
+	}
+	for _, o := range ops {
+		if strings.HasPrefix(line[end:], o) {
+			name = strings.TrimSpace(line[:end])
+			if name == "" || strings.ContainsAny(name, " \t") {
+				return "", "", "", false
+			}
+			return name, o, strings.TrimSpace(line[end+len(o):]), true
+		}
+	}
+	return "", "", "", false
+}
+
+// assign sets the variable by the operator
+func (m *makefile) assign(name, op, value string) {
+	switch op {
+	case ":=", "::=":
+		m.vars[name] = makeVar{value: m.expand(value, nil), simple: true}
+	case "?=":
+		if _, ok := m.vars[name]; !ok {
+			m.vars[name] = makeVar{value: value}
+		}
+	case "+=":
+		v, ok := m.vars[name]
+		if v.simple {
+			value = m.expand(value, nil)
+		}
+		if ok && v.value != "" {
+			value = v.value + " " + value
+		}
+		m.vars[name] = makeVar{value: value, simple: v.simple}
+	case "!=":
+		m.vars[name] = makeVar{value: m.shell(m.expand(value, nil)), simple: true}
+	default:
+		m.vars[name] = makeVar{value: value}
+	}
+}
+
+// condArgs are the strings compared by ifeq, as (a,b) or "a" "b"
+func (m *makefile) condArgs(s string) (string, string) {
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		args := makeSplitArgs(s[1 : len(s)-1])
+		if len(args) == 2 {
+			return strings.TrimSpace(m.expand(args[0], nil)), strings.TrimSpace(m.expand(args[1], nil))
+		}
+	}
+	f := strings.Fields(s)
+	if len(f) == 2 {
+		return m.expand(strings.Trim(f[0], `"'`), nil), m.expand(strings.Trim(f[1], `"'`), nil)
+	}
+	return "", "x"
+}
+
+// makeSplitArgs splits the arguments of the function by the commas outside
+// parentheses
+func makeSplitArgs(s string) []string {
+	var args []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(', '{':
+			depth++
+		case ')', '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				args = append(args, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(args, s[start:])
+}
+
+// expand expands the variables and functions in s, with the automatic
+// variables of the recipe
+func (m *makefile) expand(s string, auto map[string]string) string {
+	return m.expandDepth(s, auto, 0)
+}
+
+func (m *makefile) expandDepth(s string, auto map[string]string, depth int) string {
+	if depth > 32 || !strings.Contains(s, "$") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		var ref string
+		switch c := s[i]; c {
+		case '$':
+			b.WriteByte('$')
+			continue
+		case '(', '{':
+			closer := map[byte]byte{'(': ')', '{': '}'}[c]
+			level, j := 1, i+1
+			for ; j < len(s) && level > 0; j++ {
+				switch s[j] {
+				case c:
+					level++
+				case closer:
+					level--
+				}
+			}
+			// The rest is the reference if it is never closed
+			if level > 0 {
+				j++
+			}
+			ref = s[i+1 : j-1]
+			i = j - 1
+		default:
+			ref = string(c)
+		}
+		b.WriteString(m.reference(ref, auto, depth))
+	}
+	return b.String()
+}
+
+// reference is the value of the variable, function or substitution
+// reference inside $()
+func (m *makefile) reference(ref string, auto map[string]string, depth int) string {
+	if v, ok := auto[ref]; ok {
+		return v
+	}
+	if len(ref) == 2 && (ref[1] == 'D' || ref[1] == 'F') {
+		if v, ok := auto[ref[:1]]; ok {
+			if ref[1] == 'D' {
+				return path.Dir(v)
+			}
+			return path.Base(v)
+		}
+	}
+	if i := strings.IndexAny(ref, " \t"); i > 0 {
+		if v, ok := m.function(ref[:i], strings.TrimLeft(ref[i+1:], " \t"), auto, depth); ok {
+			return v
+		}
+	}
+	// $(SRC:.c=.o)
+	if i := strings.IndexByte(ref, ':'); i > 0 && strings.Contains(ref[i:], "=") {
+		sub := strings.SplitN(ref[i+1:], "=", 2)
+		from, to := sub[0], sub[1]
+		if !strings.Contains(from, "%") {
+			from, to = "%"+from, "%"+to
+		}
+		var out []string
+		for _, w := range strings.Fields(m.reference(ref[:i], auto, depth)) {
+			out = append(out, makePatsubst(from, to, w))
+		}
+		return strings.Join(out, " ")
+	}
+	v := m.vars[m.expandDepth(ref, auto, depth+1)]
+	if v.simple {
+		return v.value
+	}
+	return m.expandDepth(v.value, auto, depth+1)
+}
+
+// function runs the function of make, telling false if there is no such
+func (m *makefile) function(name, argStr string, auto map[string]string, depth int) (string, bool) {
+	args := makeSplitArgs(argStr)
+	arg := func(i int) string {
+		if i >= len(args) {
+			return ""
+		}
+		return m.expandDepth(args[i], auto, depth+1)
+	}
+	each := func(words string, f func(w string) string) string {
+		var out []string
+		for _, w := range strings.Fields(words) {
+			if r := f(w); r != "" {
+				out = append(out, r)
+			}
+		}
+		return strings.Join(out, " ")
+	}
+	switch name {
+	case "shell":
+		return m.shell(m.expandDepth(argStr, auto, depth+1)), true
+	case "wildcard":
+		return each(arg(0), func(w string) string {
+			found, _ := afero.Glob(m.sys.FSys(), m.path(w))
+			for i, f := range found {
+				if !path.IsAbs(w) {
+					found[i] = strings.TrimPrefix(f, m.dir+"/")
+				}
+			}
+			sort.Strings(found)
+			return strings.Join(found, " ")
+		}), true
+	case "patsubst":
+		from, to := arg(0), arg(1)
+		return each(arg(2), func(w string) string { return makePatsubst(from, to, w) }), true
+	case "subst":
+		return strings.Replace(arg(2), arg(0), arg(1), -1), true
+	case "addprefix":
+		p := arg(0)
+		return each(arg(1), func(w string) string { return p + w }), true
+	case "addsuffix":
+		p := arg(0)
+		return each(arg(1), func(w string) string { return w + p }), true
+	case "notdir":
+		return each(arg(0), path.Base), true
+	case "dir":
+		return each(arg(0), func(w string) string { return path.Dir(w) + "/" }), true
+	case "basename":
+		return each(arg(0), func(w string) string { return strings.TrimSuffix(w, path.Ext(w)) }), true
+	case "strip":
+		return strings.Join(strings.Fields(arg(0)), " "), true
+	case "filter", "filter-out":
+		pats := strings.Fields(arg(0))
+		return each(arg(1), func(w string) string {
+			match := false
+			for _, p := range pats {
+				match = match || makePatsubst(p, "x", w) == "x"
+			}
+			if match == (name == "filter") {
+				return w
+			}
+			return ""
+		}), true
+	case "sort":
+		words := strings.Fields(arg(0))
+		sort.Strings(words)
+		return strings.Join(words, " "), true
+	case "info":
+		fmt.Fprintln(m.sys.Out(), arg(0))
+		return "", true
+	case "warning":
+		fmt.Fprintf(m.sys.Err(), "%v: %v\n", m.name, arg(0))
+		return "", true
+	}
+	return "", false
+}
+
+// makePatsubst replaces the word matching the pattern, where % is the
+// stem, and leaves it as is otherwise
+func makePatsubst(from, to, word string) string {
+	i := strings.IndexByte(from, '%')
+	if i < 0 {
+		if word == from {
+			return to
+		}
+		return word
+	}
+	prefix, suffix := from[:i], from[i+1:]
+	if len(word) < len(prefix)+len(suffix) || !strings.HasPrefix(word, prefix) || !strings.HasSuffix(word, suffix) {
+		return word
+	}
+	return strings.Replace(to, "%", word[len(prefix):len(word)-len(suffix)], 1)
+}
+
+// shell runs the command of $(shell), with the newlines of the output
+// turned into spaces
+func (m *makefile) shell(cmd string) string {
+	var out bytes.Buffer
+	honeyos.RunAs(m.sys, honeyos.Credential{UID: m.sys.CurrentUser(), Dir: m.dir, Stdout: &out}, []string{"sh", "-c", cmd})
+	return strings.TrimSpace(strings.Replace(out.String(), "\n", " ", -1))
+}
+
+// mtime is the modification time of the file, zero if it does not exist
+func (m *makefile) mtime(name string) (time.Time, bool) {
+	fi, err := m.sys.FSys().Stat(m.path(name))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return fi.ModTime(), true
+}
+
+// rule finds the rule of the target: its own, or the pattern rule of which
+// prerequisites exist or can be made. The stem of the pattern is returned
+// for $*
+func (m *makefile) rule(target string) (*makeRule, string) {
+	explicit := m.rules[target]
+	if explicit != nil && len(explicit.recipe) > 0 {
+		return explicit, ""
+	}
+	for _, rules := range [][]*makeRule{m.pattern, makeBuiltinRules} {
+		for _, r := range rules {
+			pat := r.targets[0]
+			stem := makePatsubst(pat, "%", target)
+			if stem == target && pat != "%" {
+				continue
+			}
+			deps := make([]string, len(r.deps))
+			usable := true
+			for i, d := range r.deps {
+				deps[i] = strings.Replace(d, "%", stem, 1)
+				_, exists := m.mtime(deps[i])
+				usable = usable && (exists || m.rules[deps[i]] != nil)
+			}
+			if !usable {
+				continue
+			}
+			matched := &makeRule{[]string{target}, deps, r.recipe}
+			if explicit != nil {
+				matched.deps = append(matched.deps, explicit.deps...)
+			}
+			return matched, stem
+		}
+	}
+	return explicit, ""
+}
+
+// make brings the target up to date, making its prerequisites first. It
+// tells if the target was remade, and false for ok once make has to stop
+func (m *makefile) make(target, parent string) (remade bool, ok bool) {
+	if m.made[target] {
+		return false, true
+	}
+	if m.making[target] {
+		fmt.Fprintf(m.sys.Err(), "%v: Circular %v <- %v dependency dropped.\n", m.prog(), parent, target)
+		return false, true
+	}
+	m.making[target] = true
+	defer delete(m.making, target)
+
+	rule, stem := m.rule(target)
+	mtime, exists := m.mtime(target)
+	if rule == nil {
+		if exists {
+			m.made[target] = true
+			return false, true
+		}
+		if parent != "" {
+			fmt.Fprintf(m.sys.Err(), "%v: *** No rule to make target %v, needed by %v.  Stop.\n", m.prog(), m.quote(target), m.quote(parent))
+		} else {
+			fmt.Fprintf(m.sys.Err(), "%v: *** No rule to make target %v.  Stop.\n", m.prog(), m.quote(target))
+		}
+		return false, false
+	}
+	stale := !exists || m.always || m.phony[target]
+	for _, d := range rule.deps {
+		depRemade, ok := m.make(d, target)
+		if !ok {
+			return false, false
+		}
+		if t, _ := m.mtime(d); depRemade || t.After(mtime) {
+			stale = true
+		}
+	}
+	m.made[target] = true
+	if !stale || len(rule.recipe) == 0 {
+		return false, true
+	}
+
+	var newer []string
+	for _, d := range rule.deps {
+		if t, _ := m.mtime(d); !exists || t.After(mtime) {
+			newer = append(newer, d)
+		}
+	}
+	auto := map[string]string{"@": target, "^": strings.Join(makeUnique(rule.deps), " "), "+": strings.Join(rule.deps, " "),
+		"?": strings.Join(newer, " "), "*": stem, "<": ""}
+	if len(rule.deps) > 0 {
+		auto["<"] = rule.deps[0]
+	}
+	for _, line := range rule.recipe {
+		cmd := strings.TrimSpace(m.expand(line.text, auto))
+		silent, ignore := m.silent, m.ignore
+		for len(cmd) > 0 && strings.ContainsAny(cmd[:1], "@-+") {
+			switch cmd[0] {
+			case '@':
+				silent = true
+			case '-':
+				ignore = true
+			}
+			cmd = strings.TrimLeft(cmd[1:], " \t")
+		}
+		if cmd == "" {
+			continue
+		}
+		m.ran = true
+		if !silent || m.dryRun {
+			fmt.Fprintln(m.sys.Out(), cmd)
+		}
+		if m.dryRun {
+			continue
+		}
+		cred := honeyos.Credential{UID: m.sys.CurrentUser(), Dir: m.dir, Env: map[string]string{"MAKELEVEL": strconv.Itoa(m.level + 1)}}
+		status, _ := honeyos.RunAs(m.sys, cred, []string{"sh", "-c", cmd})
+		if m.sys.Context().Err() != nil {
+			fmt.Fprintf(m.sys.Err(), "%v: *** [%v] Interrupt\n", m.prog(), target)
+			return false, false
+		}
+		if status == 0 {
+			continue
+		}
+		m.recipeFailed(line, target, status, ignore)
+		if !ignore {
+			return false, false
+		}
+	}
+	return true, true
+}
+
+// recipeFailed tells the recipe failed, the way the version of make does
+func (m *makefile) recipeFailed(line makeLine, target string, status int, ignored bool) {
+	suffix := ""
+	if ignored {
+		suffix = " (ignored)"
+	}
+	star := "*** "
+	if ignored {
+		star = ""
+	}
+	switch {
+	case line.file == "" || m.build.version < "4":
+		fmt.Fprintf(m.sys.Err(), "%v: %v[%v] Error %d%v\n", m.prog(), star, target, status, suffix)
+	case m.build.version < "4.2":
+		fmt.Fprintf(m.sys.Err(), "%v:%d: recipe for target %v failed\n%v: %v[%v] Error %d%v\n",
+			line.file, line.lineNo, m.quote(target), m.prog(), star, target, status, suffix)
+	default:
+		fmt.Fprintf(m.sys.Err(), "%v: %v[%v:%d: %v] Error %d%v\n", m.prog(), star, line.file, line.lineNo, target, status, suffix)
+	}
+}
+
+// makeUnique leaves out the names repeated, for $^
+func makeUnique(names []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, n := range names {
+		if !seen[n] {
+			seen[n] = true
+			out = append(out, n)
+		}
+	}
+	return out
+}
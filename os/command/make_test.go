@@ -0,0 +1,52 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+const makeProject = "CC = gcc\nCFLAGS = -O2 -Wall\nSRCS = $(wildcard *.c)\nOBJS = $(SRCS:.c=.o)\n\n" +
+	"all: prog\n\nprog: $(OBJS)\n\t$(CC) $(CFLAGS) -o $@ $^\n\n%.o: %.c\n\t$(CC) $(CFLAGS) -c $<\n\n" +
+	"clean:\n\t@echo cleaning\n\trm -f prog *.o\n\n.PHONY: all clean\n"
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		args           []string
+		expect, errMsg string
+		status         int
+	}{
+		{[]string{"-n"}, "gcc -O2 -Wall -c a.c\ngcc -O2 -Wall -c b.c\ngcc -O2 -Wall -o prog a.o b.o\n", "", 0},
+		{[]string{"-n", "CC=clang", "a.o"}, "clang -O2 -Wall -c a.c\n", "", 0},
+		{[]string{"--dry-run", "clean"}, "echo cleaning\nrm -f prog *.o\n", "", 0},
+		{[]string{"-n", "-C", "sub", "tool"}, "make: Entering directory '/root/src/sub'\n" +
+			"cc     tool.c   -o tool\nmake: Leaving directory '/root/src/sub'\n", "", 0},
+		{[]string{"-C", "/root/src/sub", "tool.c"}, "make: Entering directory '/root/src/sub'\n" +
+			"make: Nothing to be done for 'tool.c'.\nmake: Leaving directory '/root/src/sub'\n", "", 0},
+		{[]string{"nothing"}, "", "make: *** No rule to make target 'nothing'.  Stop.\n", 2},
+		{[]string{"-f", "other.mk"}, "", "make: other.mk: No such file or directory\nmake: *** No rule to make target 'other.mk'.  Stop.\n", 2},
+		{[]string{"-C", "/nonexistent"}, "", "make: *** /nonexistent: No such file or directory.  Stop.\n", 2},
+		{[]string{"-C", "sub"}, "make: Entering directory '/root/src/sub'\nmake: Leaving directory '/root/src/sub'\n",
+			"make: *** No targets specified and no makefile found.  Stop.\n", 2},
+		{[]string{"-X"}, "", "make: invalid option -- 'X'\n" + makeUsage, 2},
+		// Unterminated references are taken to the end of line
+		{[]string{"-n", "-f", "broken.mk"}, "echo cc\n", "", 0},
+	}
+	for _, test := range tests {
+		sys := newTestSys("")
+		sys.install("make")
+		fs := sys.FSys()
+		fs.MkdirAll("/root/src/sub", 0755)
+		afero.WriteFile(fs, "/root/src/Makefile", []byte(makeProject), 0644)
+		afero.WriteFile(fs, "/root/src/broken.mk", []byte("x:\n\techo $(CC\n"), 0644)
+		afero.WriteFile(fs, "/root/src/a.c", []byte(ccHello), 0644)
+		afero.WriteFile(fs, "/root/src/b.c", []byte(ccHello), 0644)
+		afero.WriteFile(fs, "/root/src/sub/tool.c", []byte(ccHello), 0644)
+		sys.Chdir("/root/src")
+		status := sys.run(gnuMake{}, test.args...)
+		if out, errMsg := sys.out.String(), sys.err.String(); out != test.expect || errMsg != test.errMsg || status != test.status {
+			t.Errorf("make %q, expect %q, %q with status %v, got %q, %q with status %v",
+				test.args, test.expect, test.errMsg, test.status, out, errMsg, status)
+		}
+	}
+}
@@ -0,0 +1,363 @@
+package command
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	pathlib "path"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/mkishere/sshsyrup/util/terminal"
+	"github.com/spf13/afero"
+)
+
+// man is man and its apropos and whatis, which search the names of pages
+type man struct {
+	name string
+}
+
+// manSections are searched in the order of SECTION in man-db's man_db.conf
+var manSections = []string{"1", "n", "l", "8", "3", "0", "2", "5", "4", "9", "6", "7"}
+
+func init() {
+	honeyos.RegisterCommand("man", man{"man"})
+	honeyos.RegisterCommand("apropos", man{"apropos"})
+	honeyos.RegisterCommand("whatis", man{"whatis"})
+}
+
+func (m man) GetHelp() string {
+	switch m.name {
+	case "apropos":
+		return `Usage: apropos [OPTION...] KEYWORD...
+
+  -d, --debug                emit debugging messages
+  -v, --verbose              print verbose warning messages
+  -e, --exact                search each keyword for exact match
+  -r, --regex                interpret each keyword as a regex
+  -w, --wildcard             the keyword(s) contain wildcards
+  -a, --and                  require all keywords to match
+  -l, --long                 do not trim output to terminal width
+  -s, --sections=LIST, --section=LIST
+                             search only these sections (colon-separated)
+  -?, --help                 give this help list
+  -V, --version              print program version
+`
+	case "whatis":
+		return `Usage: whatis [OPTION...] KEYWORD...
+
+  -d, --debug                emit debugging messages
+  -v, --verbose              print verbose warning messages
+  -r, --regex                interpret each keyword as a regex
+  -w, --wildcard             the keyword(s) contain wildcards
+  -l, --long                 do not trim output to terminal width
+  -s, --sections=LIST, --section=LIST
+                             search only these sections (colon-separated)
+  -?, --help                 give this help list
+  -V, --version              print program version
+`
+	}
+	return `Usage: man [OPTION...] [SECTION] PAGE...
+
+  -C, --config-file=FILE     use this user configuration file
+  -d, --debug                emit debugging messages
+  -D, --default              reset all options to their default values
+      --warnings[=WARNINGS]  enable warnings from groff
+
+ Main modes of operation:
+  -f, --whatis               equivalent to whatis
+  -k, --apropos              equivalent to apropos
+  -K, --global-apropos       search for text in all pages
+  -l, --local-file           interpret PAGE argument(s) as local filename(s)
+  -w, --where, --path, --location
+                             print physical location of man page(s)
+  -W, --where-cat, --location-cat
+                             print physical location of cat file(s)
+
+ Finding manual pages:
+  -L, --locale=LOCALE        define the locale for this particular man search
+  -m, --systems=SYSTEM       use manual pages from other systems
+  -M, --manpath=PATH         set search path for manual pages to PATH
+  -S, -s, --sections=LIST    use colon separated section list
+  -a, --all                  find all matching manual pages
+
+ Controlling formatted output:
+  -P, --pager=PAGER          use program PAGER to display output
+  -r, --prompt=STRING        provide the 'less' pager with a prompt
+  -7, --ascii                display ASCII translation of certain latin1 chars
+  -E, --encoding=ENCODING    use selected output encoding
+  -H, --html[=BROWSER]       use www-browser or BROWSER to display HTML output
+  -Z, --ditroff              use groff and force it to produce ditroff
+
+  -?, --help                 give this help list
+      --usage                give a short usage message
+  -V, --version              print program version
+
+Mandatory or optional arguments to long options are also mandatory or optional
+for any corresponding short options.
+
+Report bugs to cjwatson@debian.org.
+`
+}
+
+func (m man) Where() string {
+	return "/usr/bin/" + m.name
+}
+
+func (m man) Exec(args []string, sys honeyos.Sys) int {
+	if honeyos.Distro() == "alpine" {
+		// man is not installed on Alpine unless mandoc is
+		return honeyos.CommandNotFound(sys, append([]string{m.name}, args...))
+	}
+	mode := m.name
+	var sections []string
+	var names []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			names = append(names, arg)
+			continue
+		}
+		opt, value := arg, ""
+		if strings.HasPrefix(arg, "--") && strings.Contains(arg, "=") {
+			kv := strings.SplitN(arg, "=", 2)
+			opt, value = kv[0], kv[1]
+		} else if !strings.HasPrefix(arg, "--") && len(arg) > 2 {
+			opt, value = arg[:2], arg[2:]
+		}
+		needValue := func() bool {
+			if value == "" {
+				if i+1 >= len(args) {
+					fmt.Fprintf(sys.Err(), "%v: option requires an argument -- '%v'\nTry '%v --help' or '%v --usage' for more information.\n",
+						m.name, strings.TrimLeft(opt, "-")[:1], m.name, m.name)
+					return false
+				}
+				i++
+				value = args[i]
+			}
+			return true
+		}
+		switch opt {
+		case "-h", "-?", "--help":
+			fmt.Fprint(sys.Out(), m.GetHelp())
+			return 0
+		case "-V", "--version":
+			version := "2.7.5"
+			if honeyos.Distro() == "centos" {
+				version = "2.6.3"
+			}
+			fmt.Fprintf(sys.Out(), "%v %v\n", m.name, version)
+			return 0
+		case "-f", "--whatis":
+			mode = "whatis"
+		case "-k", "--apropos":
+			mode = "apropos"
+		case "-w", "--where", "--path", "--location":
+			// -w is --wildcard of apropos and whatis
+			if m.name == "man" {
+				mode = "where"
+			}
+		case "-a", "--all", "-d", "--debug", "-D", "--default", "-7", "--ascii", "-Z", "--ditroff",
+			"-v", "--verbose", "-e", "--exact", "-l", "--long", "-r", "--regex", "--wildcard":
+		case "-s", "-S", "--sections", "--section":
+			if !needValue() {
+				return 1
+			}
+			sections = strings.Split(value, ":")
+		case "-P", "--pager", "-C", "--config-file", "-L", "--locale", "-m", "--systems", "-M", "--manpath",
+			"-E", "--encoding":
+			if !needValue() {
+				return 1
+			}
+		default:
+			fmt.Fprintf(sys.Err(), "%v: unrecognized option '%v'\nTry '%v --help' or '%v --usage' for more information.\n",
+				m.name, arg, m.name, m.name)
+			return 1
+		}
+	}
+	if mode == "man" && len(sections) == 0 && len(names) > 1 && isManSection(names[0]) {
+		sections, names = []string{names[0]}, names[1:]
+	}
+	if len(names) == 0 {
+		if mode == "man" {
+			fmt.Fprintln(sys.Err(), "What manual page do you want?")
+		} else {
+			fmt.Fprintf(sys.Err(), "%v what?\n", mode)
+		}
+		return 1
+	}
+	if mode == "apropos" || mode == "whatis" {
+		return m.search(mode, names, sections, sys)
+	}
+	status := 0
+	for _, name := range names {
+		p := findManPage(sys, name, sections)
+		if p == "" {
+			if len(sections) > 0 {
+				fmt.Fprintf(sys.Err(), "No manual entry for %v in section %v\n", name, sections[0])
+			} else {
+				fmt.Fprintf(sys.Err(), "No manual entry for %v\n", name)
+			}
+			status = 16
+			continue
+		}
+		if mode == "where" {
+			fmt.Fprintln(sys.Out(), p)
+			continue
+		}
+		page, err := readManPage(sys, p)
+		if err != nil {
+			fmt.Fprintf(sys.Err(), "man: can't open %v: %v\n", p, err)
+			status = 16
+			continue
+		}
+		styled := honeyos.IsTerminal(sys.Out())
+		width := 80
+		if styled && sys.Width() > 0 {
+			width = sys.Width()
+		}
+		lines := newRoff(width-2, styled).render(page)
+		section := strings.TrimPrefix(pathlib.Base(pathlib.Dir(p)), "man")
+		prompt := func(s string) string {
+			return fmt.Sprintf(" Manual page %v(%v) %v (press h for help or q to quit)", name, section, s)
+		}
+		pager{prompt: func(top, bottom, total int) string {
+			return prompt(fmt.Sprintf("line %v", top+1))
+		}, end: prompt("line " + fmt.Sprint(len(lines)) + " (END)"), altScreen: true}.page(strings.Join(lines, "\n")+"\n", sys)
+	}
+	return status
+}
+
+// search finds the pages for apropos by keyword in their names and
+// descriptions, or for whatis by their names
+func (m man) search(mode string, keywords, sections []string, sys honeyos.Sys) int {
+	pages := manIndex(sys, sections)
+	status := 0
+	for _, keyword := range keywords {
+		found := false
+		for _, page := range pages {
+			match := page.name == keyword
+			if mode == "apropos" {
+				match = strings.Contains(strings.ToLower(page.name+" "+page.description), strings.ToLower(keyword))
+			}
+			if match {
+				line := fmt.Sprintf("%-21v- %v", fmt.Sprintf("%v (%v)", page.name, page.section), page.description)
+				if w := sys.Width(); honeyos.IsTerminal(sys.Out()) && w > 0 && terminal.StringWidth(line) > w {
+					line = string([]rune(line)[:w-3]) + "..."
+				}
+				fmt.Fprintln(sys.Out(), line)
+				found = true
+			}
+		}
+		if !found {
+			fmt.Fprintf(sys.Out(), "%v: nothing appropriate.\n", keyword)
+			status = 16
+		}
+	}
+	return status
+}
+
+// manEntry is the page as whatis lists it
+type manEntry struct {
+	name, section, description string
+}
+
+// manIndex reads the NAME of the installed pages, which mandb keeps in its
+// index
+func manIndex(sys honeyos.Sys, sections []string) []manEntry {
+	if len(sections) == 0 {
+		sections = manSections
+	}
+	var entries []manEntry
+	for _, section := range sections {
+		dir := "/usr/share/man/man" + section
+		files, err := afero.ReadDir(sys.FSys(), dir)
+		if err != nil {
+			continue
+		}
+		for _, fi := range files {
+			page, err := readManPage(sys, pathlib.Join(dir, fi.Name()))
+			if err != nil {
+				continue
+			}
+			name := strings.TrimSuffix(fi.Name(), ".gz")
+			entries = append(entries, manEntry{strings.TrimSuffix(name, pathlib.Ext(name)), section, manDescription(page)})
+		}
+	}
+	return entries
+}
+
+// manDescription is what follows the name in the NAME section of the page
+func manDescription(page string) string {
+	inName := false
+	for _, line := range strings.Split(page, "\n") {
+		switch {
+		case strings.HasPrefix(line, ".SH"):
+			if inName {
+				return ""
+			}
+			inName = strings.Contains(strings.ToUpper(line), "NAME")
+		case inName && !strings.HasPrefix(line, ".") && strings.TrimSpace(line) != "":
+			var font byte = 'R'
+			text := ""
+			for _, c := range newRoff(80, false).inline(line, &font) {
+				text += string(c.r)
+			}
+			if kv := strings.SplitN(text, " - ", 2); len(kv) == 2 {
+				return strings.TrimSpace(kv[1])
+			}
+			return strings.TrimSpace(text)
+		}
+	}
+	return ""
+}
+
+func isManSection(s string) bool {
+	for _, section := range manSections {
+		if s == section {
+			return true
+		}
+	}
+	return false
+}
+
+// findManPage finds the page of the name in the sections, in order of
+// manSections if none are given
+func findManPage(sys honeyos.Sys, name string, sections []string) string {
+	if strings.Contains(name, "/") {
+		return ""
+	}
+	if len(sections) == 0 {
+		sections = manSections
+	}
+	for _, section := range sections {
+		for _, p := range []string{
+			fmt.Sprintf("/usr/share/man/man%v/%v.%v.gz", section, name, section),
+			fmt.Sprintf("/usr/share/man/man%v/%v.%v", section, name, section),
+		} {
+			if fi, err := sys.FSys().Stat(p); err == nil && !fi.IsDir() {
+				return p
+			}
+		}
+	}
+	return ""
+}
+
+// readManPage reads the page, uncompressing it if it is gzipped
+func readManPage(sys honeyos.Sys, p string) (string, error) {
+	content, err := readFile(sys, p)
+	if err != nil {
+		return "", err
+	}
+	if strings.HasSuffix(p, ".gz") {
+		r, err := gzip.NewReader(bytes.NewReader(content))
+		if err != nil {
+			return "", err
+		}
+		if content, err = ioutil.ReadAll(r); err != nil {
+			return "", err
+		}
+	}
+	return string(content), nil
+}
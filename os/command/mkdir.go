@@ -0,0 +1,150 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	pathlib "path"
+	"strings"
+	"syscall"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+)
+
+type mkdir struct{}
+
+func init() {
+	honeyos.RegisterCommand("mkdir", mkdir{})
+}
+
+func (mkdir) GetHelp() string {
+	return `Usage: mkdir [OPTION]... DIRECTORY...
+Create the DIRECTORY(ies), if they do not already exist.
+
+Mandatory arguments to long options are mandatory for short options too.
+  -m, --mode=MODE   set file mode (as in chmod), not a=rwx - umask
+  -p, --parents     no error if existing, make parent directories as needed
+  -v, --verbose     print a message for each created directory
+  -Z                   set SELinux security context of each created directory
+                         to the default type
+      --context[=CTX]  like -Z, or if CTX is specified then set the SELinux
+                         or SMACK security context to CTX
+      --help     display this help and exit
+      --version  output version information and exit
+
+GNU coreutils online help: <https://www.gnu.org/software/coreutils/>
+Full documentation at: <https://www.gnu.org/software/coreutils/mkdir>
+or available locally via: info '(coreutils) mkdir invocation'
+`
+}
+
+func (mkdir) Where() string {
+	return "/bin/mkdir"
+}
+
+func (m mkdir) Exec(args []string, sys honeyos.Sys) int {
+	var parents, verbose bool
+	var spec string
+	operands, status := fileOperands("mkdir", args, sys, func(f string) (bool, int) {
+		switch f {
+		case "-p", "--parents":
+			parents = true
+		case "-v", "--verbose":
+			verbose = true
+		case "-m", "--mode":
+			return true, -1
+		case "-Z", "--context":
+		case "--help":
+			fmt.Fprint(sys.Out(), m.GetHelp())
+			return false, 0
+		case "--version":
+			fmt.Fprintln(sys.Out(), "mkdir (GNU coreutils) 8.32")
+			return false, 0
+		default:
+			return false, 1
+		}
+		return false, -1
+	}, &spec)
+	if status >= 0 {
+		return status
+	}
+	if len(operands) == 0 {
+		fmt.Fprintln(sys.Err(), "mkdir: missing operand\nTry 'mkdir --help' for more information.")
+		return 1
+	}
+	// Without -m the mode is a=rwx less umask, which Mkdir applies
+	mode := os.ModeDir | 0777&^sys.Umask()
+	if spec != "" {
+		var ok bool
+		if mode, ok = chmodMode(spec, os.ModeDir|0777, sys.Umask(), true); !ok {
+			fmt.Fprintf(sys.Err(), "mkdir: invalid mode ‘%v’\n", spec)
+			return 1
+		}
+	}
+	sys.Log().WithField("dirs", operands).Info("User created directories")
+	res := 0
+	for _, name := range operands {
+		if !m.create(sys, name, mode, parents, verbose) {
+			res = 1
+		}
+	}
+	return res
+}
+
+// create makes the directory, and with -p those above it which are created
+// with u+wx so the rest can be made in them
+func (m mkdir) create(sys honeyos.Sys, name string, mode os.FileMode, parents, verbose bool) bool {
+	fs := sys.FSys()
+	p := absPath(sys, name)
+	if parents {
+		shown := ""
+		parts := strings.Split(strings.TrimSuffix(name, "/"), "/")
+		for i, part := range parts {
+			if i > 0 || part == "" {
+				shown += "/"
+			}
+			shown += part
+			if part == "" || part == "." || part == ".." || i == len(parts)-1 {
+				continue
+			}
+			if fi, err := fs.Stat(absPath(sys, shown)); err == nil {
+				if !fi.IsDir() {
+					fmt.Fprintf(sys.Err(), "mkdir: cannot create directory ‘%v’: Not a directory\n", name)
+					return false
+				}
+				continue
+			}
+			if !m.mkdir(sys, shown, os.ModeDir|(0777&^sys.Umask())|0300, verbose) {
+				return false
+			}
+		}
+		if fi, err := fs.Stat(p); err == nil && fi.IsDir() {
+			return true
+		}
+	}
+	return m.mkdir(sys, name, mode, verbose)
+}
+
+// mkdir makes the one directory, whose parent has to exist
+func (mkdir) mkdir(sys honeyos.Sys, name string, mode os.FileMode, verbose bool) bool {
+	fs := sys.FSys()
+	p := absPath(sys, name)
+	var err error
+	if fi, e := fs.Stat(pathlib.Dir(p)); e != nil {
+		err = e
+	} else if !fi.IsDir() {
+		err = syscall.ENOTDIR
+	} else if _, e := fs.Stat(p); e == nil {
+		err = os.ErrExist
+	} else {
+		err = fs.Mkdir(p, mode.Perm())
+	}
+	if err != nil {
+		fmt.Fprintf(sys.Err(), "mkdir: cannot create directory ‘%v’: %v\n", name, fileError(err))
+		return false
+	}
+	fs.Chmod(p, mode)
+	if verbose {
+		fmt.Fprintf(sys.Out(), "mkdir: created directory '%v'\n", name)
+	}
+	return true
+}
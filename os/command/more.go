@@ -0,0 +1,69 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+)
+
+type more struct{}
+
+func init() {
+	honeyos.RegisterCommand("more", more{})
+}
+
+func (more) GetHelp() string {
+	return ""
+}
+
+func (more) Where() string {
+	return "/bin/more"
+}
+
+func (more) Exec(args []string, sys honeyos.Sys) int {
+	var text bytes.Buffer
+	percent := true
+	if len(args) == 0 {
+		if honeyos.IsTerminal(sys.In()) {
+			fmt.Fprintln(sys.Err(), "more: bad usage\nTry 'more --help' for more information.")
+			return 1
+		}
+		io.Copy(&text, sys.In())
+		// Size of input from pipe is unknown until it is all read
+		percent = false
+	}
+	res := 0
+	for _, arg := range args {
+		filePath := arg
+		if !path.IsAbs(filePath) {
+			filePath = path.Join(sys.Getcwd(), filePath)
+		}
+		f, err := sys.FSys().OpenFile(filePath, os.O_RDONLY, os.ModeType)
+		if err != nil {
+			fmt.Fprintf(sys.Err(), "more: cannot open %v: No such file or directory\n", arg)
+			res = 1
+			continue
+		}
+		if fi, err := f.Stat(); err == nil && fi.IsDir() {
+			fmt.Fprintf(sys.Err(), "\n*** %v: directory ***\n\n", arg)
+			f.Close()
+			continue
+		}
+		if len(args) > 1 {
+			fmt.Fprintf(&text, "::::::::::::::\n%v\n::::::::::::::\n", arg)
+		}
+		io.Copy(&text, f)
+		f.Close()
+	}
+	pager{prompt: func(top, bottom, total int) string {
+		if !percent {
+			return "--More--"
+		}
+		return fmt.Sprintf("--More--(%v%%)", pagerPercent(bottom, total))
+	}}.page(text.String(), sys)
+	return res
+}
@@ -0,0 +1,337 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/pflag"
+)
+
+// mount lists the mount table, and mounts the virtual filesystems like tmpfs
+// and overlay. Disks are not there to be mounted, so mounting them fails the
+// way it does for a wrong filesystem type
+type mount struct{}
+
+// umount removes what was mounted, but not the filesystems the system needs
+type umount struct{}
+
+// mountVirtual are the filesystems not backed by a device, which can be
+// mounted anywhere
+var mountVirtual = map[string]bool{
+	"tmpfs": true, "ramfs": true, "overlay": true, "proc": true, "sysfs": true, "devpts": true,
+	"devtmpfs": true, "cgroup": true, "mqueue": true, "debugfs": true, "securityfs": true,
+	"hugetlbfs": true, "binfmt_misc": true, "fusectl": true,
+}
+
+// mountRemote are the filesystems known besides disks, which have no
+// server to mount
+var mountRemote = map[string]bool{"nfs": true, "nfs4": true, "cifs": true, "iso9660": true, "ntfs": true, "fuse": true}
+
+// mountBusy are the mount points in use by the system, which cannot be
+// unmounted
+var mountBusy = map[string]bool{
+	"/": true, "/proc": true, "/sys": true, "/dev": true, "/dev/pts": true, "/run": true, "/sys/fs/cgroup": true,
+}
+
+const mountWrongFs = "mount: wrong fs type, bad option, bad superblock on %v,\n" +
+	"       missing codepage or helper program, or other error\n\n" +
+	"       In some cases useful info is found in syslog - try\n" +
+	"       dmesg | tail or so.\n"
+
+func init() {
+	honeyos.RegisterCommand("mount", mount{})
+	honeyos.RegisterCommand("umount", umount{})
+}
+
+func (mount) GetHelp() string {
+	return "Usage:\n mount [-lhV]\n mount -a [options]\n mount [options] [--source] <source> | [--target] <directory>\n" +
+		" mount [options] <source> <directory>\n mount <operation> <mountpoint> [<target>]\n"
+}
+
+func (mount) Where() string {
+	return "/bin/mount"
+}
+
+// findMount returns the index of the filesystem last mounted on the
+// directory or from the device, -1 if there is none
+func findMount(mounts []honeyos.MountInfo, target string) int {
+	for i := len(mounts) - 1; i >= 0; i-- {
+		if mounts[i].Dir == target || mounts[i].Device == target {
+			return i
+		}
+	}
+	return -1
+}
+
+// mountOptions merges the options given with -o into the defaults, with ro
+// or rw and the atime first as the kernel shows them
+func mountOptions(given []string, readOnly bool) string {
+	mode, opts := "rw", []string{}
+	for _, list := range given {
+		for _, o := range strings.Split(list, ",") {
+			switch o {
+			case "ro", "rw":
+				mode = o
+			case "", "defaults", "remount", "bind", "rbind", "loop", "auto", "noauto", "user", "nouser", "_netdev":
+			default:
+				opts = append(opts, o)
+			}
+		}
+	}
+	if readOnly {
+		mode = "ro"
+	}
+	atime := "relatime"
+	for _, o := range opts {
+		if strings.HasSuffix(o, "atime") {
+			atime = ""
+		}
+	}
+	list := []string{mode}
+	if atime != "" {
+		list = append(list, atime)
+	}
+	return strings.Join(append(list, opts...), ",")
+}
+
+// mountOption returns the value of the option like size=64m
+func mountOption(opts, key string) string {
+	for _, o := range strings.Split(opts, ",") {
+		if strings.HasPrefix(o, key+"=") {
+			return o[len(key)+1:]
+		}
+	}
+	return ""
+}
+
+func (m mount) Exec(args []string, sys honeyos.Sys) int {
+	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	all := flag.BoolP("all", "a", false, "mount all filesystems mentioned in fstab")
+	fsType := flag.StringP("types", "t", "", "limit the set of filesystem types")
+	options := flag.StringArrayP("options", "o", nil, "comma-separated list of mount options")
+	readOnly := flag.BoolP("read-only", "r", false, "mount the filesystem read-only (same as -o ro)")
+	flag.BoolP("rw", "w", false, "mount the filesystem read-write (default)")
+	verbose := flag.BoolP("verbose", "v", false, "say what is being done")
+	flag.BoolP("show-labels", "l", false, "show also filesystem labels")
+	flag.BoolP("no-mtab", "n", false, "don't write to /etc/mtab")
+	fake := flag.BoolP("fake", "f", false, "dry run; skip the mount(2) syscall")
+	bind := flag.BoolP("bind", "B", false, "mount a subtree somewhere else (same as -o bind)")
+	flag.BoolVarP(bind, "rbind", "R", false, "mount a subtree and all submounts somewhere else")
+	if err := flag.Parse(args); err != nil {
+		msg := strings.Replace(err.Error(), "unknown shorthand flag: ", "invalid option -- ", 1)
+		if i := strings.Index(msg, " in -"); i >= 0 {
+			msg = msg[:i]
+		}
+		fmt.Fprintf(sys.Err(), "mount: %v\n\n%v\nFor more details see mount(8).\n", msg, m.GetHelp())
+		return 1
+	}
+	mounts := sys.Mounts()
+	operands := flag.Args()
+	if len(operands) == 0 && !*all {
+		types := strings.Split(*fsType, ",")
+		for _, mi := range mounts {
+			if *fsType != "" {
+				found := false
+				for _, t := range types {
+					found = found || t == mi.Type
+				}
+				if !found {
+					continue
+				}
+			}
+			fmt.Fprintf(sys.Out(), "%v on %v type %v (%v)\n", mi.Device, mi.Dir, mi.Type, mi.Options)
+		}
+		return 0
+	}
+	if !isRoot(sys) {
+		if *all {
+			fmt.Fprintln(sys.Err(), `mount: only root can use "--all" option`)
+		} else {
+			fmt.Fprintln(sys.Err(), "mount: only root can do that")
+		}
+		return 1
+	}
+	if *all {
+		// Everything in fstab is mounted already
+		return 0
+	}
+	for _, list := range *options {
+		for _, o := range strings.Split(list, ",") {
+			*bind = *bind || o == "bind" || o == "rbind"
+		}
+	}
+	opts := mountOptions(*options, *readOnly)
+	sys.Log().WithField("args", operands).WithField("type", *fsType).WithField("options", opts).
+		Infof("User mounting %v", strings.Join(operands, " "))
+
+	if len(operands) == 1 {
+		target := operands[0]
+		if strings.HasPrefix(target, "/") || strings.HasPrefix(target, ".") {
+			target = absPath(sys, target)
+		}
+		if i := findMount(mounts, target); i >= 0 && strings.Contains(strings.Join(*options, ","), "remount") {
+			// Remounting only changes the options
+			mi := mounts[i]
+			honeyos.Unmount(sys, mi.Dir)
+			mi.Options = opts
+			honeyos.Mount(sys, mi)
+			return 0
+		} else if i >= 0 {
+			fmt.Fprintf(sys.Err(), "mount: %v is already mounted or %v busy\n", mounts[i].Device, mounts[i].Dir)
+			return 32
+		}
+		fmt.Fprintf(sys.Err(), "mount: can't find %v in /etc/fstab\n", operands[0])
+		return 1
+	}
+	device, dir := operands[0], absPath(sys, operands[1])
+	if fi, err := sys.FSys().Stat(dir); err != nil {
+		fmt.Fprintf(sys.Err(), "mount: mount point %v does not exist\n", operands[1])
+		return 32
+	} else if !fi.IsDir() && !*bind {
+		fmt.Fprintf(sys.Err(), "mount: mount point %v is not a directory\n", operands[1])
+		return 32
+	}
+	mi := honeyos.MountInfo{Device: device, Dir: dir, Type: *fsType, Options: opts}
+	switch {
+	case *bind:
+		// Bind mounts show the filesystem the source is on
+		src := absPath(sys, device)
+		if _, err := sys.FSys().Stat(src); err != nil {
+			fmt.Fprintf(sys.Err(), "mount: special device %v does not exist\n", device)
+			return 32
+		}
+		if i := mountIndex(mounts, src); i >= 0 {
+			mi.Device, mi.Type, mi.Size = mounts[i].Device, mounts[i].Type, mounts[i].Size
+		}
+	case mi.Type == "" && mountVirtual[device]:
+		mi.Type = device
+		fallthrough
+	case mountVirtual[mi.Type]:
+		switch mi.Type {
+		case "tmpfs":
+			mi.Size = honeyos.MemTotal / 2 * 1024
+			if size := mountOption(opts, "size"); size != "" {
+				if strings.HasSuffix(size, "%") {
+					var pct int64
+					fmt.Sscan(strings.TrimSuffix(size, "%"), &pct)
+					mi.Size = honeyos.MemTotal * 1024 / 100 * pct
+				} else {
+					mi.Size = honeyos.ParseSize(size)
+				}
+			}
+		case "overlay":
+			lower := mountOption(opts, "lowerdir")
+			if lower == "" {
+				fmt.Fprintf(sys.Err(), mountWrongFs, device)
+				return 32
+			}
+			if i := mountIndex(mounts, absPath(sys, strings.Split(lower, ":")[0])); i >= 0 {
+				mi.Size = mounts[i].Size
+			}
+		}
+	case mi.Type != "" && mi.Type != "auto" && !dfDisk(mi.Type) && !mountRemote[mi.Type]:
+		fmt.Fprintf(sys.Err(), "mount: unknown filesystem type '%v'\n", mi.Type)
+		return 32
+	default:
+		// Disks are already mounted, or have nothing the kernel can read
+		if i := findMount(mounts, device); i >= 0 {
+			fmt.Fprintf(sys.Err(), "mount: %v is already mounted or %v busy\n       %v is already mounted on %v\n",
+				device, operands[1], device, mounts[i].Dir)
+			return 32
+		}
+		if _, err := sys.FSys().Stat(absPath(sys, device)); err != nil {
+			fmt.Fprintf(sys.Err(), "mount: special device %v does not exist\n", device)
+			return 32
+		}
+		fmt.Fprintf(sys.Err(), mountWrongFs, device)
+		return 32
+	}
+	if !*fake {
+		honeyos.Mount(sys, mi)
+	}
+	if *verbose {
+		fmt.Fprintf(sys.Out(), "mount: %v mounted on %v.\n", device, dir)
+	}
+	return 0
+}
+
+func (umount) GetHelp() string {
+	return "Usage:\n umount [-hV]\n umount -a [options]\n umount [options] <source> | <directory>\n"
+}
+
+func (umount) Where() string {
+	return "/bin/umount"
+}
+
+func (u umount) Exec(args []string, sys honeyos.Sys) int {
+	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	all := flag.BoolP("all", "a", false, "unmount all filesystems")
+	lazy := flag.BoolP("lazy", "l", false, "detach the filesystem now, clean up things later")
+	flag.BoolP("force", "f", false, "force unmount (in case of an unreachable NFS system)")
+	flag.BoolP("recursive", "R", false, "recursively unmount a target with all its children")
+	flag.BoolP("no-mtab", "n", false, "don't write to /etc/mtab")
+	flag.StringP("types", "t", "", "limit the set of filesystem types")
+	verbose := flag.BoolP("verbose", "v", false, "say what is being done")
+	if err := flag.Parse(args); err != nil {
+		msg := strings.Replace(err.Error(), "unknown shorthand flag: ", "invalid option -- ", 1)
+		if i := strings.Index(msg, " in -"); i >= 0 {
+			msg = msg[:i]
+		}
+		fmt.Fprintf(sys.Err(), "umount: %v\n\n%v\nFor more details see umount(8).\n", msg, u.GetHelp())
+		return 1
+	}
+	targets := flag.Args()
+	if len(targets) == 0 && !*all {
+		fmt.Fprintf(sys.Err(), "%v\nFor more details see umount(8).\n", u.GetHelp())
+		return 1
+	}
+	if *all {
+		if !isRoot(sys) {
+			fmt.Fprintln(sys.Err(), `umount: only root can use "--all" option`)
+			return 1
+		}
+		return 0
+	}
+	status := 0
+	for _, name := range targets {
+		mounts := sys.Mounts()
+		target := name
+		if strings.HasPrefix(name, "/") || strings.HasPrefix(name, ".") {
+			target = absPath(sys, name)
+		}
+		i := findMount(mounts, target)
+		if i < 0 {
+			if _, err := sys.FSys().Stat(absPath(sys, name)); err != nil {
+				fmt.Fprintf(sys.Err(), "umount: %v: mount point not found\n", name)
+			} else {
+				fmt.Fprintf(sys.Err(), "umount: %v: not mounted\n", name)
+			}
+			status = 32
+			continue
+		}
+		mi := mounts[i]
+		if !isRoot(sys) {
+			fmt.Fprintf(sys.Err(), "umount: only root can unmount %v from %v\n", mi.Device, mi.Dir)
+			status = 1
+			continue
+		}
+		sys.Log().WithField("device", mi.Device).WithField("dir", mi.Dir).Infof("User unmounting %v", mi.Dir)
+		cwd := sys.Getcwd()
+		inUse := mountBusy[mi.Dir] || cwd == mi.Dir || strings.HasPrefix(cwd, strings.TrimSuffix(mi.Dir, "/")+"/")
+		if inUse && !*lazy {
+			fmt.Fprintf(sys.Err(), "umount: %v: target is busy\n        (In some cases useful info about processes that\n"+
+				"         use the device is found by lsof(8) or fuser(1).)\n", name)
+			status = 32
+			continue
+		}
+		honeyos.Unmount(sys, mi.Dir)
+		if *verbose {
+			fmt.Fprintf(sys.Out(), "umount: %v (%v) unmounted\n", mi.Dir, mi.Device)
+		}
+	}
+	return status
+}
@@ -0,0 +1,158 @@
+package command
+
+import (
+	"fmt"
+	pathlib "path"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/afero"
+)
+
+type mv struct{}
+
+func init() {
+	honeyos.RegisterCommand("mv", mv{})
+}
+
+func (mv) GetHelp() string {
+	return `Usage: mv [OPTION]... [-T] SOURCE DEST
+  or:  mv [OPTION]... SOURCE... DIRECTORY
+  or:  mv [OPTION]... -t DIRECTORY SOURCE...
+Rename SOURCE to DEST, or move SOURCE(s) to DIRECTORY.
+
+Mandatory arguments to long options are mandatory for short options too.
+      --backup[=CONTROL]       make a backup of each existing destination file
+  -b                           like --backup but does not accept an argument
+  -f, --force                  do not prompt before overwriting
+  -i, --interactive            prompt before overwrite
+  -n, --no-clobber             do not overwrite an existing file
+If you specify more than one of -i, -f, -n, only the final one takes effect.
+      --strip-trailing-slashes  remove any trailing slashes from each SOURCE
+                                 argument
+  -S, --suffix=SUFFIX          override the usual backup suffix
+  -t, --target-directory=DIRECTORY  move all SOURCE arguments into DIRECTORY
+  -T, --no-target-directory    treat DEST as a normal file
+  -u, --update                 move only when the SOURCE file is newer
+                                 than the destination file or when the
+                                 destination file is missing
+  -v, --verbose                explain what is being done
+  -Z, --context                set SELinux security context of destination
+                                 file to default type
+      --help     display this help and exit
+      --version  output version information and exit
+
+GNU coreutils online help: <https://www.gnu.org/software/coreutils/>
+Full documentation at: <https://www.gnu.org/software/coreutils/mv>
+or available locally via: info '(coreutils) mv invocation'
+`
+}
+
+func (mv) Where() string {
+	return "/bin/mv"
+}
+
+func (m mv) Exec(args []string, sys honeyos.Sys) int {
+	var opt cpOptions
+	operands, status := fileOperands("mv", args, sys, func(f string) (bool, int) {
+		switch f {
+		case "-f", "--force":
+			opt.force, opt.interactive, opt.noClobber = true, false, false
+		case "-i", "--interactive":
+			opt.interactive, opt.force, opt.noClobber = true, false, false
+		case "-n", "--no-clobber":
+			opt.noClobber, opt.force, opt.interactive = true, false, false
+		case "-v", "--verbose":
+			opt.verbose = true
+		case "-u", "--update":
+			opt.update = true
+		case "-T", "--no-target-directory":
+			opt.noTarget = true
+		case "-t", "--target-directory":
+			return true, -1
+		case "-b", "--backup", "-Z", "--context", "--strip-trailing-slashes":
+		case "--help":
+			fmt.Fprint(sys.Out(), m.GetHelp())
+			return false, 0
+		case "--version":
+			fmt.Fprintln(sys.Out(), "mv (GNU coreutils) 8.32")
+			return false, 0
+		default:
+			return false, 1
+		}
+		return false, -1
+	}, &opt.target)
+	if status >= 0 {
+		return status
+	}
+	sources, dest, ok := fileTarget("mv", operands, opt, sys)
+	if !ok {
+		return 1
+	}
+	sys.Log().WithField("files", sources).WithField("dest", dest).Info("User moved files")
+	res := 0
+	for _, src := range sources {
+		dst := dest
+		if fileIntoDir(sys, dest, opt) {
+			dst = pathlib.Join(dest, pathlib.Base(strings.TrimSuffix(src, "/")))
+		}
+		if !m.move(sys, &opt, src, dst) {
+			res = 1
+		}
+	}
+	return res
+}
+
+// move renames src to dst, replacing dst if it is a file or an empty
+// directory in place of a directory
+func (m mv) move(sys honeyos.Sys, opt *cpOptions, src, dst string) bool {
+	fs := sys.FSys()
+	srcPath, dstPath := absPath(sys, src), absPath(sys, dst)
+	fi, err := honeyos.Lstat(fs, srcPath)
+	if err != nil {
+		fmt.Fprintf(sys.Err(), "mv: cannot stat '%v': %v\n", src, fileError(err))
+		return false
+	}
+	if srcPath == dstPath {
+		fmt.Fprintf(sys.Err(), "mv: '%v' and '%v' are the same file\n", src, dst)
+		return false
+	}
+	if fi.IsDir() && inside(srcPath, dstPath) {
+		fmt.Fprintf(sys.Err(), "mv: cannot move '%v' to a subdirectory of itself, '%v'\n", src, dst)
+		return false
+	}
+	if dfi, err := honeyos.Lstat(fs, dstPath); err == nil {
+		switch {
+		case dfi.IsDir() && !fi.IsDir():
+			fmt.Fprintf(sys.Err(), "mv: cannot overwrite directory '%v' with non-directory\n", dst)
+			return false
+		case !dfi.IsDir() && fi.IsDir():
+			fmt.Fprintf(sys.Err(), "mv: cannot overwrite non-directory '%v' with directory '%v'\n", dst, src)
+			return false
+		case opt.noClobber:
+			return true
+		case opt.update && !fi.ModTime().After(dfi.ModTime()):
+			return true
+		case opt.interactive && !fileConfirm(sys, fmt.Sprintf("mv: overwrite '%v'? ", dst)):
+			return true
+		}
+		if dfi.IsDir() {
+			if list, _ := afero.ReadDir(fs, dstPath); len(list) > 0 {
+				fmt.Fprintf(sys.Err(), "mv: cannot move '%v' to '%v': Directory not empty\n", src, dst)
+				return false
+			}
+		}
+		if err := fs.Remove(dstPath); err != nil {
+			fmt.Fprintf(sys.Err(), "mv: cannot move '%v' to '%v': %v\n", src, dst, fileError(err))
+			return false
+		}
+	}
+	if err := fs.Rename(srcPath, dstPath); err != nil {
+		fmt.Fprintf(sys.Err(), "mv: cannot move '%v' to '%v': %v\n", src, dst, fileError(err))
+		return false
+	}
+	if opt.verbose {
+		fmt.Fprintf(sys.Out(), "renamed '%v' -> '%v'\n", src, dst)
+	}
+	return true
+}
@@ -0,0 +1,651 @@
+package command
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+)
+
+// mysql is the client of MySQL, or MariaDB on other distributions than
+// Ubuntu. The servers in persona.databases let the client in to the fake
+// tables, the others deny access after taking the password
+type mysql struct{}
+
+// mysqlBuild is the MySQL of the distribution, with the version of the
+// server and the one the client tells
+type mysqlBuild struct {
+	server, client, comment string
+	mariadb                 bool
+}
+
+var mysqlBuilds = map[string]mysqlBuild{
+	"ubuntu": {"5.7.33-0ubuntu0.16.04.1", "mysql  Ver 14.14 Distrib 5.7.33, for Linux (x86_64) using  EditLine wrapper",
+		"(Ubuntu)", false},
+	"debian": {"10.1.48-MariaDB-0+deb9u2", "mysql  Ver 15.1 Distrib 10.1.48-MariaDB, for debian-linux-gnu (x86_64) using readline 5.2",
+		"Debian 9.13", true},
+	"centos": {"5.5.68-MariaDB", "mysql  Ver 15.1 Distrib 5.5.68-MariaDB, for Linux (x86_64) using readline 5.1",
+		"MariaDB Server", true},
+	"alpine": {"10.6.12-MariaDB", "mysql  Ver 15.1 Distrib 10.6.12-MariaDB, for Linux (x86_64) using readline 5.1",
+		"MariaDB Server", true},
+}
+
+// mysqlClient is the state of the client connected to the server
+type mysqlClient struct {
+	*dbSession
+	build                           mysqlBuild
+	id                              int
+	client                          string
+	batch, tabular, vertical, names bool
+	// line is the line of input the statement starts, for errors in batch
+	// mode
+	line int
+}
+
+func init() {
+	honeyos.RegisterCommand("mysql", mysql{})
+}
+
+func (mysql) GetHelp() string {
+	return mysqlBuildOf().client + `
+Copyright (c) 2000, 2021, Oracle and/or its affiliates.
+
+Usage: mysql [OPTIONS] [database]
+  -?, --help          Display this help and exit.
+  -B, --batch         Don't use history file. Disable interactive behavior.
+  -D, --database=name Database to use.
+  -e, --execute=name  Execute command and quit.
+  -E, --vertical      Print the output of a query (rows) vertically.
+  -h, --host=name     Connect to host.
+  -N, --skip-column-names
+                      Don't write column names in results.
+  -p, --password[=name]
+                      Password to use when connecting to server. If password is
+                      not given it's asked from the tty.
+  -P, --port=#        Port number to use for connection.
+  -S, --socket=name   The socket file to use for connection.
+  -t, --table         Output in table format.
+  -u, --user=name     User for login if not current user.
+  -V, --version       Output version information and exit.
+`
+}
+
+func (mysql) Where() string {
+	return "/usr/bin/mysql"
+}
+
+func mysqlBuildOf() mysqlBuild {
+	if b, ok := mysqlBuilds[honeyos.Distro()]; ok {
+		return b
+	}
+	return mysqlBuilds["ubuntu"]
+}
+
+func (m mysql) Exec(args []string, sys honeyos.Sys) int {
+	build := mysqlBuildOf()
+	user, host, port, db := honeyos.Getenv(sys, "USER"), "localhost", 3306, ""
+	var password, execute *string
+	ask := false
+	c := &mysqlClient{build: build, names: true}
+	// Values of short options can be attached, like -proot
+	value := func(i *int, arg, long string) (string, bool) {
+		if strings.HasPrefix(arg, long+"=") {
+			return arg[len(long)+1:], true
+		}
+		if len(arg) > 2 && arg[1] != '-' {
+			return arg[2:], true
+		}
+		if *i+1 < len(args) {
+			*i++
+			return args[*i], true
+		}
+		fmt.Fprintf(sys.Err(), "mysql: option '%v' requires an argument\n", arg)
+		return "", false
+	}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		var ok = true
+		var v string
+		switch {
+		case arg == "-?" || arg == "--help" || arg == "-I":
+			fmt.Fprint(sys.Out(), m.GetHelp())
+			return 0
+		case arg == "-V" || arg == "--version":
+			fmt.Fprintln(sys.Out(), build.client)
+			return 0
+		case strings.HasPrefix(arg, "-p") && !strings.HasPrefix(arg, "-P") || arg == "--password" ||
+			strings.HasPrefix(arg, "--password="):
+			// The password is only taken attached, -p alone asks for it
+			v = strings.TrimPrefix(strings.TrimPrefix(arg, "--password"), "=")
+			if strings.HasPrefix(arg, "-p") {
+				v = arg[2:]
+			}
+			password = &v
+			ask = v == "" && !strings.HasPrefix(arg, "--password=")
+		case strings.HasPrefix(arg, "-u") || strings.HasPrefix(arg, "--user"):
+			user, ok = value(&i, arg, "--user")
+		case strings.HasPrefix(arg, "-h") || strings.HasPrefix(arg, "--host"):
+			host, ok = value(&i, arg, "--host")
+		case strings.HasPrefix(arg, "-P") || strings.HasPrefix(arg, "--port"):
+			if v, ok = value(&i, arg, "--port"); ok {
+				port, _ = strconv.Atoi(v)
+			}
+		case strings.HasPrefix(arg, "-D") || strings.HasPrefix(arg, "--database"):
+			db, ok = value(&i, arg, "--database")
+		case strings.HasPrefix(arg, "-e") || strings.HasPrefix(arg, "--execute"):
+			if v, ok = value(&i, arg, "--execute"); ok {
+				execute = &v
+			}
+		case strings.HasPrefix(arg, "-S") || strings.HasPrefix(arg, "--socket"):
+			_, ok = value(&i, arg, "--socket")
+		case arg == "-B" || arg == "--batch" || arg == "-s" || arg == "--silent":
+			c.batch = true
+		case arg == "-N" || arg == "--skip-column-names":
+			c.names = false
+		case arg == "-t" || arg == "--table":
+			c.tabular = true
+		case arg == "-E" || arg == "--vertical":
+			c.vertical = true
+		case strings.HasPrefix(arg, "--"):
+			// Options of connection like --protocol and --ssl-mode change
+			// nothing here
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			fmt.Fprintf(sys.Err(), "mysql: [ERROR] unknown option '%v'\n", arg[:2])
+			return 7
+		case db == "":
+			db = arg
+		}
+		if !ok {
+			return 1
+		}
+	}
+
+	interactive := execute == nil && honeyos.IsTerminal(sys.In())
+	if ask {
+		in := honeyos.OpenTTY(sys)
+		if in == nil {
+			in = sys.In()
+		}
+		v, _ := readPasswordFrom(sys, in, "Enter password: ")
+		password = &v
+	}
+	pass := ""
+	if password != nil {
+		pass = *password
+	}
+	if pass != "" && !ask && !build.mariadb {
+		fmt.Fprintln(sys.Err(), "mysql: [Warning] Using a password on the command line interface can be insecure.")
+	}
+	ip := net.ParseIP("127.0.0.1")
+	if host != "localhost" {
+		if ip = netResolve(sys, host); ip == nil {
+			fmt.Fprintf(sys.Err(), "ERROR 2005 (HY000): Unknown MySQL server host '%v' (2)\n", host)
+			return 1
+		}
+	}
+	sys.Log().WithField("host", host).WithField("port", port).Infof("User connecting to MySQL server %v:%v", host, port)
+	open, reachable := dbReach(sys, "mysql", ip, port)
+	switch {
+	case !reachable:
+		if !pkgSleep(sys, 127*time.Second) {
+			return 1
+		}
+		fmt.Fprintf(sys.Err(), "ERROR 2003 (HY000): Can't connect to MySQL server on '%v' (110)\n", host)
+		return 1
+	case !open && host == "localhost":
+		fmt.Fprintln(sys.Err(), "ERROR 2002 (HY000): Can't connect to local MySQL server through socket '/var/run/mysqld/mysqld.sock' (2)")
+		return 1
+	case !open:
+		fmt.Fprintf(sys.Err(), "ERROR 2003 (HY000): Can't connect to MySQL server on '%v' (111)\n", host)
+		return 1
+	}
+	c.client = "localhost"
+	if !ip.IsLoopback() {
+		c.client = honeyos.IPAddress()
+	}
+	if !dbLogin(sys, "mysql", host, ip, user, pass) {
+		using := "NO"
+		if pass != "" {
+			using = "YES"
+		}
+		fmt.Fprintf(sys.Err(), "ERROR 1045 (28000): Access denied for user '%v'@'%v' (using password: %v)\n",
+			user, c.client, using)
+		return 1
+	}
+	c.dbSession = newDBSession(sys, "mysql", host, user, "", mysqlSchemas(sys, build)...)
+	c.id = 2 + int(fnvString(sys.Hostname()+user)%400)
+	c.funcs = map[string]string{
+		"version()":         build.server,
+		"@@version":         build.server,
+		"@@version_comment": build.comment,
+		"user()":            user + "@" + c.client,
+		"current_user()":    user + "@%",
+		"current_user":      user + "@%",
+		"system_user()":     user + "@" + c.client,
+		"@@hostname":        sys.Hostname(),
+		"@@datadir":         "/var/lib/mysql/",
+		"@@port":            strconv.Itoa(port),
+		"connection_id()":   strconv.Itoa(c.id),
+	}
+	if db != "" {
+		if c.schema(db) == nil {
+			fmt.Fprintf(sys.Err(), "ERROR 1049 (42000): Unknown database '%v'\n", db)
+			return 1
+		}
+		c.db = db
+	}
+	switch {
+	case execute != nil:
+		c.batch = c.batch || !honeyos.IsTerminal(sys.Out())
+		return c.script(*execute)
+	case !interactive:
+		c.batch = true
+		data, _ := ioutil.ReadAll(sys.In())
+		return c.script(string(data))
+	}
+	return c.repl()
+}
+
+// mysqlStatement is a statement of input, ended with ; or \g, or with \G to
+// show the rows vertically. The last one is unterminated if the input ends
+// before its terminator, line is where it starts
+type mysqlStatement struct {
+	text                   string
+	vertical, unterminated bool
+	line                   int
+}
+
+// mysqlSplit splits the input into statements. Quotes are not taken into
+// account
+func mysqlSplit(input string) []mysqlStatement {
+	var list []mysqlStatement
+	start := 0
+	add := func(end int, vertical bool) {
+		list = append(list, mysqlStatement{text: input[start:end], vertical: vertical,
+			line: strings.Count(input[:start], "\n") + 1})
+	}
+	for i := 0; i < len(input); i++ {
+		switch {
+		case input[i] == ';':
+			add(i, false)
+			start = i + 1
+		case input[i] == '\\' && i+1 < len(input) && (input[i+1] == 'g' || input[i+1] == 'G'):
+			add(i, input[i+1] == 'G')
+			i++
+			start = i + 1
+		}
+	}
+	if strings.TrimSpace(input[start:]) != "" {
+		add(len(input), false)
+		list[len(list)-1].unterminated = true
+	}
+	return list
+}
+
+// script runs the statements of -e or input that is not terminal, stopping
+// at the first error like mysql does without --force
+func (c *mysqlClient) script(input string) int {
+	for _, stmt := range mysqlSplit(input) {
+		c.line = stmt.line
+		if !c.run(stmt.text, stmt.vertical, false) {
+			return 1
+		}
+	}
+	return 0
+}
+
+func (c *mysqlClient) prompt() string {
+	if !c.build.mariadb {
+		return "mysql> "
+	}
+	db := c.db
+	if db == "" {
+		db = "(none)"
+	}
+	return "MariaDB [" + db + "]> "
+}
+
+func (c *mysqlClient) repl() int {
+	sys := c.sys
+	server := c.build.server + " " + c.build.comment
+	if c.build.mariadb {
+		fmt.Fprintf(sys.Out(), "Welcome to the MariaDB monitor.  Commands end with ; or \\g.\n"+
+			"Your MariaDB connection id is %v\nServer version: %v\n\n"+
+			"Copyright (c) 2000, 2018, Oracle, MariaDB Corporation Ab and others.\n\n"+
+			"Type 'help;' or '\\h' for help. Type '\\c' to clear the current input statement.\n\n", c.id, server)
+	} else {
+		fmt.Fprintf(sys.Out(), "Welcome to the MySQL monitor.  Commands end with ; or \\g.\n"+
+			"Your MySQL connection id is %v\nServer version: %v\n\n"+
+			"Copyright (c) 2000, 2021, Oracle and/or its affiliates.\n\n"+
+			"Oracle is a registered trademark of Oracle Corporation and/or its\n"+
+			"affiliates. Other names may be trademarks of their respective\nowners.\n\n"+
+			"Type 'help;' or '\\h' for help. Type '\\c' to clear the current input statement.\n\n", c.id, server)
+	}
+	in := bufio.NewReader(sys.In())
+	pending := ""
+	for {
+		if pending == "" {
+			fmt.Fprint(sys.Out(), c.prompt())
+		} else {
+			fmt.Fprint(sys.Out(), "    -> ")
+		}
+		line, err := in.ReadString('\n')
+		if err != nil && line == "" {
+			if select1(sys.Context().Done()) {
+				if c.build.mariadb {
+					fmt.Fprintln(sys.Out(), "^C")
+				} else {
+					fmt.Fprintln(sys.Out(), "^CCtrl-C -- exit!\nAborted")
+				}
+				return 1
+			}
+			fmt.Fprintln(sys.Out(), "Bye")
+			return 0
+		}
+		line = strings.TrimRight(line, "\r\n")
+		sys.Log().Infof("User typed %v into mysql", line)
+		// Commands of the client are taken without terminator at the start
+		// of statement
+		if pending == "" {
+			switch word := strings.ToLower(strings.TrimRight(strings.TrimSpace(line), ";")); {
+			case word == "exit" || word == "quit" || word == `\q`:
+				fmt.Fprintln(sys.Out(), "Bye")
+				return 0
+			case word == "help" || word == `\h` || word == "?" || word == `\?`:
+				fmt.Fprint(sys.Out(), mysqlHelp)
+				continue
+			case word == "status" || word == `\s`:
+				c.status()
+				continue
+			case strings.HasPrefix(word, "use ") || strings.HasPrefix(word, `\u `):
+				c.run(strings.TrimSpace(line), false, true)
+				continue
+			}
+		}
+		stmts := mysqlSplit(pending + line)
+		pending = ""
+		for _, stmt := range stmts {
+			switch text := strings.TrimSpace(stmt.text); {
+			case strings.HasSuffix(text, `\c`):
+			case strings.HasSuffix(text, `\q`):
+				fmt.Fprintln(sys.Out(), "Bye")
+				return 0
+			case stmt.unterminated:
+				// The statement goes on in the next line
+				pending = stmt.text + "\n"
+			default:
+				c.run(stmt.text, stmt.vertical, true)
+			}
+		}
+	}
+}
+
+// run runs the statement, printing the rows. It returns false on error
+func (c *mysqlClient) run(text string, vertical, interactive bool) bool {
+	sys := c.sys
+	text = strings.TrimSpace(text)
+	if text == "" {
+		if interactive {
+			fmt.Fprint(sys.Err(), "ERROR: \nNo query specified\n\n")
+		}
+		return true
+	}
+	start := time.Now()
+	res, err := c.statement(text)
+	if err != nil {
+		msg := c.errorMessage(err)
+		if !interactive {
+			msg = strings.Replace(msg, ":", fmt.Sprintf(" at line %v:", c.line), 1)
+		}
+		fmt.Fprintln(sys.Err(), msg)
+		return false
+	}
+	elapsed := fmt.Sprintf("(%.2f sec)", time.Since(start).Seconds())
+	switch {
+	case res == nil:
+		// USE prints nothing but this
+		if interactive {
+			fmt.Fprintln(sys.Out(), "Database changed")
+		}
+	case res.cols == nil && interactive:
+		fmt.Fprintf(sys.Out(), "Query OK, %v row%v affected %v\n\n", res.affected, plural(res.affected), elapsed)
+	case res.cols == nil:
+	case vertical || c.vertical:
+		c.printVertical(res)
+		if interactive {
+			fmt.Fprintf(sys.Out(), "%v in set %v\n\n", mysqlRows(len(res.rows)), elapsed)
+		}
+	case c.batch && !c.tabular:
+		if c.names {
+			fmt.Fprintln(sys.Out(), strings.Join(res.cols, "\t"))
+		}
+		for _, row := range res.rows {
+			fmt.Fprintln(sys.Out(), strings.Join(row, "\t"))
+		}
+	case len(res.rows) == 0 && interactive:
+		fmt.Fprintf(sys.Out(), "Empty set %v\n\n", elapsed)
+	default:
+		c.printTable(res)
+		if interactive {
+			fmt.Fprintf(sys.Out(), "%v in set %v\n\n", mysqlRows(len(res.rows)), elapsed)
+		}
+	}
+	return true
+}
+
+func mysqlRows(n int) string {
+	if n == 1 {
+		return "1 row"
+	}
+	return fmt.Sprintf("%v rows", n)
+}
+
+// statement runs the statements the client knows besides those of
+// dbSession, like SHOW and USE. A nil result is of USE
+func (c *mysqlClient) statement(text string) (*dbResult, error) {
+	words := strings.Fields(strings.ToLower(strings.TrimRight(text, ";")))
+	switch {
+	case len(words) == 2 && (words[0] == "use" || words[0] == `\u`):
+		c.log(text)
+		name := strings.Trim(strings.Fields(text)[1], "`;")
+		if c.schema(name) == nil {
+			return nil, dbError{dbErrNoDB, name}
+		}
+		c.db = name
+		return nil, nil
+	case len(words) >= 2 && words[0] == "show" && (words[1] == "databases" || words[1] == "schemas"):
+		res := &dbResult{cols: []string{"Database"}, numeric: []bool{false}}
+		for _, d := range c.schemas {
+			res.rows = append(res.rows, []string{d.name})
+		}
+		// information_schema comes first, the others are sorted
+		sort.Slice(res.rows[1:], func(i, j int) bool { return res.rows[i+1][0] < res.rows[j+1][0] })
+		c.log(text)
+		return res, nil
+	case len(words) >= 2 && words[0] == "show" && words[1] == "tables":
+		if c.db == "" {
+			return nil, dbError{kind: dbErrNoDB}
+		}
+		res := &dbResult{cols: []string{"Tables_in_" + c.db}, numeric: []bool{false}}
+		for _, t := range c.schema(c.db).tables {
+			res.rows = append(res.rows, []string{t.name})
+		}
+		c.log(text)
+		return res, nil
+	case len(words) >= 2 && words[0] == "show" && words[1] == "grants":
+		c.log(text)
+		return &dbResult{cols: []string{"Grants for " + c.user + "@" + c.client}, numeric: []bool{false},
+			rows: [][]string{{"GRANT ALL PRIVILEGES ON *.* TO '" + c.user + "'@'" + c.client + "' WITH GRANT OPTION"}}}, nil
+	case len(words) >= 2 && (words[0] == "describe" || words[0] == "desc") ||
+		len(words) >= 4 && words[0] == "show" && words[1] == "columns" && words[2] == "from":
+		c.log(text)
+		t, err := c.table(strings.Trim(strings.Fields(strings.TrimRight(text, ";"))[len(words)-1], "`"))
+		if err != nil {
+			return nil, err
+		}
+		res := &dbResult{cols: []string{"Field", "Type", "Null", "Key", "Default", "Extra"},
+			numeric: make([]bool, 6)}
+		for i, col := range t.cols {
+			row := []string{col, "varchar(255)", "YES", "", "NULL", ""}
+			if t.numeric[i] {
+				row[1] = "int(11)"
+			}
+			if col == "id" {
+				row[2], row[3], row[5] = "NO", "PRI", "auto_increment"
+			}
+			res.rows = append(res.rows, row)
+		}
+		return res, nil
+	case len(words) >= 1 && words[0] == "show":
+		c.log(text)
+		return &dbResult{cols: []string{"Variable_name", "Value"}, numeric: []bool{false, false}}, nil
+	}
+	return c.query(text)
+}
+
+// errorMessage is how mysql reports the error of the query
+func (c *mysqlClient) errorMessage(err error) string {
+	e, _ := err.(dbError)
+	switch e.kind {
+	case dbErrNoTable:
+		return fmt.Sprintf("ERROR 1146 (42S02): Table '%v' doesn't exist", e.name)
+	case dbErrNoColumn:
+		return fmt.Sprintf("ERROR 1054 (42S22): Unknown column '%v' in 'field list'", e.name)
+	case dbErrNoDB:
+		if e.name != "" {
+			return fmt.Sprintf("ERROR 1049 (42000): Unknown database '%v'", e.name)
+		}
+		return "ERROR 1046 (3D000): No database selected"
+	}
+	server := "MySQL"
+	if c.build.mariadb {
+		server = "MariaDB"
+	}
+	return fmt.Sprintf("ERROR 1064 (42000): You have an error in your SQL syntax; check the manual that corresponds "+
+		"to your %v server version for the right syntax to use near '%v' at line 1", server, e.name)
+}
+
+func (c *mysqlClient) printTable(res *dbResult) {
+	widths := make([]int, len(res.cols))
+	for i, col := range res.cols {
+		widths[i] = len(col)
+		for _, row := range res.rows {
+			widths[i] = intMax(widths[i], len(row[i]))
+		}
+	}
+	var b strings.Builder
+	border := "+"
+	for _, w := range widths {
+		border += strings.Repeat("-", w+2) + "+"
+	}
+	line := func(values []string, header bool) {
+		b.WriteString("|")
+		for i, v := range values {
+			if res.numeric[i] && !header {
+				fmt.Fprintf(&b, " %*v |", widths[i], v)
+			} else {
+				fmt.Fprintf(&b, " %-*v |", widths[i], v)
+			}
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(border + "\n")
+	if c.names {
+		line(res.cols, true)
+		b.WriteString(border + "\n")
+	}
+	for _, row := range res.rows {
+		line(row, false)
+	}
+	b.WriteString(border + "\n")
+	fmt.Fprint(c.sys.Out(), b.String())
+}
+
+func (c *mysqlClient) printVertical(res *dbResult) {
+	width := 0
+	for _, col := range res.cols {
+		width = intMax(width, len(col))
+	}
+	for n, row := range res.rows {
+		fmt.Fprintf(c.sys.Out(), "*************************** %v. row ***************************\n", n+1)
+		for i, v := range row {
+			fmt.Fprintf(c.sys.Out(), "%*v: %v\n", width, res.cols[i], v)
+		}
+	}
+}
+
+func (c *mysqlClient) status() {
+	up := honeyos.Uptime()
+	days, hours, mins, secs := int(up.Hours())/24, int(up.Hours())%24, int(up.Minutes())%60, int(up.Seconds())%60
+	conn := "Localhost via UNIX socket"
+	if c.client != "localhost" {
+		conn = c.host + " via TCP/IP"
+	}
+	fmt.Fprintf(c.sys.Out(), "--------------\n%v\n\nConnection id:\t\t%v\nCurrent database:\t%v\n"+
+		"Current user:\t\t%v@%v\nSSL:\t\t\tNot in use\nCurrent pager:\t\tstdout\nUsing outfile:\t\t''\n"+
+		"Using delimiter:\t;\nServer version:\t\t%v %v\nProtocol version:\t10\nConnection:\t\t%v\n"+
+		"Uptime:\t\t\t%v days %v hours %v min %v sec\n--------------\n\n",
+		c.build.client, c.id, c.db, c.user, c.client, c.build.server, c.build.comment, conn, days, hours, mins, secs)
+}
+
+const mysqlHelp = `
+For information about MySQL products and services, visit:
+   http://www.mysql.com/
+For developer information, including the MySQL Reference Manual, visit:
+   http://dev.mysql.com/
+To buy MySQL Enterprise support, training, or other products, visit:
+   https://shop.mysql.com/
+
+List of all MySQL commands:
+Note that all text commands must be first on line and end with ';'
+?         (\?) Synonym for 'help'.
+clear     (\c) Clear the current input statement.
+connect   (\r) Reconnect to the server. Optional arguments are db and host.
+ego       (\G) Send command to mysql server, display result vertically.
+exit      (\q) Exit mysql. Same as quit.
+go        (\g) Send command to mysql server.
+help      (\h) Display this help.
+quit      (\q) Quit mysql.
+status    (\s) Get status information from the server.
+use       (\u) Use another database. Takes database name as argument.
+
+For server side help, type 'help contents'
+
+`
+
+// mysqlSchemas are the databases the server comes with, with the accounts in
+// mysql.user
+func mysqlSchemas(sys honeyos.Sys, build mysqlBuild) []*dbSchema {
+	password := "authentication_string"
+	if build.mariadb {
+		password = "Password"
+	}
+	users := &dbTable{name: "user", cols: []string{"Host", "User", password, "plugin"},
+		numeric: []bool{false, false, false, false}}
+	accounts := []string{"root", "mysql.session", "mysql.sys", "debian-sys-maint"}
+	if build.mariadb {
+		accounts = []string{"root", "root", "root"}
+	}
+	for i, name := range accounts {
+		host, hash, plugin := "localhost", mysqlHash(sys, name), "mysql_native_password"
+		if build.mariadb {
+			host, plugin = []string{"localhost", "127.0.0.1", "::1"}[i], ""
+		}
+		if strings.HasPrefix(name, "mysql.") {
+			hash = "*THISISNOTAVALIDPASSWORDTHATCANBEUSEDHERE"
+		}
+		users.rows = append(users.rows, []string{host, name, hash, plugin})
+	}
+	list := []*dbSchema{{name: "information_schema"}, {name: "mysql", tables: []*dbTable{users}},
+		{name: "performance_schema"}}
+	if !build.mariadb {
+		list = append(list, &dbSchema{name: "sys"})
+	}
+	return list
+}
@@ -0,0 +1,767 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/mkishere/sshsyrup/util/terminal"
+	"github.com/spf13/afero"
+)
+
+// nano is a modeless editor with the shortcut bar at the bottom, for those
+// not knowing their way around vi
+type nano struct{}
+
+// nanoVersions are the versions of nano coming with the distros
+var nanoVersions = map[string]string{
+	"ubuntu": "2.5.3",
+	"debian": "2.7.4",
+	"centos": "2.3.1",
+	"alpine": "5.9",
+}
+
+// nanoShortcuts are the shortcuts shown at the bottom, in pairs of the two
+// rows
+var nanoShortcuts = [][2]string{
+	{"^G", "Get Help"}, {"^X", "Exit"},
+	{"^O", "Write Out"}, {"^R", "Read File"},
+	{"^W", "Where Is"}, {"^\\", "Replace"},
+	{"^K", "Cut Text"}, {"^U", "Uncut Text"},
+	{"^J", "Justify"}, {"^T", "To Spell"},
+	{"^C", "Cur Pos"}, {"^_", "Go To Line"},
+	{"^Y", "Prev Page"}, {"^V", "Next Page"},
+}
+
+const nanoHelp = `Main nano help text
+
+ The nano editor is designed to emulate the functionality and ease-of-use of
+ the UW Pico text editor.  There are four main sections of the editor.  The
+ top line shows the program version, the current filename being edited, and
+ whether or not the file has been modified.  Next is the main editor window
+ showing the file being edited.  The status line is the third line from the
+ bottom and shows important messages.  The bottom two lines show the most
+ commonly used shortcuts in the editor.
+
+ The notation for shortcuts is as follows: Control-key sequences are notated
+ with a caret (^) symbol and can be entered either by using the Control (Ctrl)
+ key or pressing the Escape (Esc) key twice.  Escape-key sequences are notated
+ with the Meta (M-) symbol and can be entered using either the Esc, Alt, or
+ Meta key depending on your keyboard setup.
+
+^G      (F1)    Display this help text
+^X      (F2)    Close the current file buffer / Exit from nano
+^O      (F3)    Write the current file to disk
+^R      (F5)    Insert another file into the current one
+^W      (F6)    Search for a string or a regular expression
+^\      (M-R)   Replace a string or a regular expression
+^K      (F9)    Cut the current line and store it in the cutbuffer
+^U      (F10)   Uncut from the cutbuffer into the current line
+^C      (F11)   Display the position of the cursor
+^_      (M-G)   Go to line and column number
+^Y      (F7)    Go one screenful up
+^V      (F8)    Go one screenful down`
+
+// nanoEditor is the state of a nano session
+type nanoEditor struct {
+	sys honeyos.Sys
+	out io.Writer
+	// file is the name as given, and path the absolute path of it
+	file, path string
+	// lines always ends with an empty line, which is where the last newline
+	// of the file leads to
+	lines    [][]rune
+	row, col int
+	top      int
+	modified bool
+	message  string
+	// cut holds the lines cut, which keeps growing on consecutive ^K
+	cut     [][]rune
+	lastCut bool
+	search  string
+	help    bool
+	// prompt is the question asked on status line, answered by done. Yes or
+	// no questions only take a single key
+	prompt string
+	answer []rune
+	yesNo  bool
+	done   func(answer string, ok bool)
+	quit   bool
+	width  int
+	height int
+}
+
+func init() {
+	honeyos.RegisterCommand("nano", nano{})
+}
+
+func (nano) GetHelp() string {
+	return ""
+}
+
+func (nano) Where() string {
+	if pkgFamily() == "rpm" {
+		return "/usr/bin/nano"
+	}
+	return "/bin/nano"
+}
+
+func (nano) Exec(args []string, sys honeyos.Sys) int {
+	// Neither CentOS minimal nor Alpine comes with nano
+	if pkgFamily() != "deb" && !loadPkgDB(sys, pkgFamily()).installed("nano") {
+		return honeyos.CommandNotFound(sys, append([]string{"nano"}, args...))
+	}
+	var file string
+	line := 0
+	for _, arg := range args {
+		switch {
+		case arg == "-V", arg == "--version":
+			fmt.Fprintf(sys.Out(), " GNU nano, version %v\n (C) 1999..2016 Free Software Foundation, Inc.\n", nanoVersions[honeyos.Distro()])
+			fmt.Fprintln(sys.Out(), " Email: nano@nano-editor.org\tWeb: https://nano-editor.org/")
+			return 0
+		case strings.HasPrefix(arg, "+"):
+			line, _ = strconv.Atoi(strings.SplitN(arg[1:], ",", 2)[0])
+		case strings.HasPrefix(arg, "-"):
+		case file == "":
+			file = arg
+		}
+	}
+	keys, modes := honeyos.OpenTTY(sys), sys.Termios()
+	if keys == nil || modes == nil {
+		fmt.Fprintln(sys.Err(), "Error opening terminal: unknown.")
+		return 1
+	}
+	e := &nanoEditor{sys: sys, out: sys.Out(), lines: [][]rune{{}}, width: sys.Width(), height: sys.Height()}
+	if file != "" {
+		e.open(file)
+	}
+	if line > 0 {
+		e.row = clampInt(line-1, 0, len(e.lines)-1)
+	}
+	sys.Log().WithField("file", e.path).Info("User started nano")
+
+	// ^C, ^Z and the like are shortcuts of nano
+	icanon, echo, isig := modes.Flag("icanon"), modes.Flag("echo"), modes.Flag("isig")
+	modes.SetFlag("icanon", false)
+	modes.SetFlag("echo", false)
+	modes.SetFlag("isig", false)
+	io.WriteString(e.out, "\x1b[?1049h\x1b[H\x1b[2J")
+	defer func() {
+		modes.SetFlag("icanon", icanon)
+		modes.SetFlag("echo", echo)
+		modes.SetFlag("isig", isig)
+		io.WriteString(e.out, "\x1b[2J\x1b[?1049l")
+	}()
+
+	var mu sync.Mutex
+	done := false
+	defer func() {
+		mu.Lock()
+		done = true
+		mu.Unlock()
+	}()
+	defer sys.OnResize(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if !done {
+			e.width, e.height = sys.Width(), sys.Height()
+			e.draw()
+		}
+	})()
+	buf := make([]byte, 256)
+	for !e.quit {
+		mu.Lock()
+		e.draw()
+		mu.Unlock()
+		n, err := keys.Read(buf)
+		if err != nil {
+			return 1
+		}
+		mu.Lock()
+		for _, key := range viKeys(buf[:n]) {
+			e.key(key)
+			if e.quit {
+				break
+			}
+		}
+		mu.Unlock()
+	}
+	return 0
+}
+
+// open reads the file into the buffer
+func (e *nanoEditor) open(name string) {
+	e.file, e.path = name, absPath(e.sys, name)
+	fi, err := e.sys.FSys().Stat(e.path)
+	if err == nil && fi.IsDir() {
+		e.message = fmt.Sprintf("\"%v\" is a directory", name)
+		e.file, e.path = "", ""
+		return
+	}
+	data, err := afero.ReadFile(e.sys.FSys(), e.path)
+	if err != nil {
+		e.message = "New File"
+		return
+	}
+	e.lines = e.split(data)
+	e.message = fmt.Sprintf("Read %v line%v", len(e.lines)-1, plural(len(e.lines)-1))
+}
+
+// split breaks the text into lines, ending with the empty line after the
+// last newline
+func (e *nanoEditor) split(data []byte) [][]rune {
+	var lines [][]rune
+	for _, l := range strings.Split(string(data), "\n") {
+		lines = append(lines, []rune(l))
+	}
+	if len(lines[len(lines)-1]) > 0 {
+		lines = append(lines, []rune{})
+	}
+	return lines
+}
+
+// content is the text of the buffer as saved to file
+func (e *nanoEditor) content() []byte {
+	var b strings.Builder
+	for _, l := range e.lines[:len(e.lines)-1] {
+		b.WriteString(string(l) + "\n")
+	}
+	b.WriteString(string(e.lines[len(e.lines)-1]))
+	return []byte(b.String())
+}
+
+// write saves the buffer to the file named, and keeps editing it
+func (e *nanoEditor) write(name string) bool {
+	p := absPath(e.sys, name)
+	data := e.content()
+	if err := afero.WriteFile(e.sys.FSys(), p, data, 0666&^e.sys.Umask()); err != nil {
+		e.message = fmt.Sprintf("Error writing %v: Permission denied", p)
+		return false
+	}
+	if len(data) > 0 {
+		honeyos.SaveArtifact(e.sys, data, "nano "+p)
+	}
+	e.file, e.path, e.modified = name, p, false
+	e.message = fmt.Sprintf("Wrote %v line%v", len(e.lines)-1, plural(len(e.lines)-1))
+	return true
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
+
+// ask shows the prompt on status line, and calls done when it is answered
+// or cancelled
+func (e *nanoEditor) ask(prompt, answer string, done func(answer string, ok bool)) {
+	e.prompt, e.answer, e.yesNo, e.done = prompt, []rune(answer), false, done
+}
+
+func (e *nanoEditor) change() {
+	e.modified = true
+	if len(e.lines[len(e.lines)-1]) > 0 {
+		e.lines = append(e.lines, []rune{})
+	}
+}
+
+// editRows is the number of rows for the text, below the title bar and
+// above the status line and shortcuts
+func (e *nanoEditor) editRows() int {
+	if n := e.height - 5; n > 0 {
+		return n
+	}
+	return 1
+}
+
+// pageStart is the first column shown of a line too long for the screen,
+// which scrolls by pages like nano does
+func (e *nanoEditor) pageStart(x int) int {
+	if x < e.width-1 || e.width <= 8 {
+		return 0
+	}
+	return x - 7 - (x-7)%(e.width-8)
+}
+
+func (e *nanoEditor) draw() {
+	if e.width <= 0 || e.height <= 4 {
+		return
+	}
+	rows := e.editRows()
+	if e.row < e.top {
+		e.top = e.row
+	} else if e.row >= e.top+rows {
+		e.top = e.row - rows + 1
+	}
+	var b strings.Builder
+	b.WriteString("\x1b[?25l\x1b[H")
+	b.WriteString(e.titleBar() + "\n\x1b[K\n")
+	curRow, curCol := 0, 0
+	if e.help {
+		text := strings.Split(nanoHelp, "\n")
+		for i := 0; i < rows; i++ {
+			l := ""
+			if i < len(text) {
+				l = text[i]
+			}
+			if utf8.RuneCountInString(l) > e.width {
+				l = string([]rune(l)[:e.width])
+			}
+			b.WriteString(l + "\x1b[K\n")
+		}
+	} else {
+		for i := e.top; i < e.top+rows; i++ {
+			if i >= len(e.lines) {
+				b.WriteString("\x1b[K\n")
+				continue
+			}
+			text, offsets := viCells(e.lines[i])
+			runes, start := []rune(text), 0
+			if i == e.row {
+				x := offsets[clampInt(e.col, 0, len(e.lines[i]))]
+				start = e.pageStart(x)
+				curRow, curCol = i-e.top, x-start
+			}
+			if start > len(runes) {
+				start = len(runes)
+			}
+			runes = runes[start:]
+			if start > 0 {
+				runes[0] = '$'
+			}
+			if len(runes) > e.width {
+				runes = append(runes[:e.width-1], '$')
+			}
+			b.WriteString(string(runes) + "\x1b[K\n")
+		}
+	}
+	switch {
+	case e.prompt != "":
+		status := e.prompt + string(e.answer)
+		pad := e.width - utf8.RuneCountInString(status)
+		if pad < 0 {
+			pad = 0
+		}
+		b.WriteString(terminal.Color(terminal.Reverse, status+strings.Repeat(" ", pad)))
+		curRow, curCol = rows, utf8.RuneCountInString(status)
+		if curCol >= e.width {
+			curCol = e.width - 1
+		}
+	case e.message != "":
+		msg := "[ " + e.message + " ]"
+		pad := (e.width - utf8.RuneCountInString(msg)) / 2
+		if pad < 0 {
+			pad = 0
+		}
+		b.WriteString(strings.Repeat(" ", pad) + terminal.Color(terminal.Reverse, msg))
+	}
+	b.WriteString("\x1b[K\n")
+	b.WriteString(e.shortcutBar())
+	if e.help {
+		curRow, curCol = 0, 0
+	} else {
+		curRow += 2
+	}
+	fmt.Fprintf(&b, "\x1b[%v;%vH\x1b[?25h", curRow+1, curCol+1)
+	io.WriteString(e.out, b.String())
+}
+
+// titleBar has the version on the left, the file in the middle and whether
+// it is modified on the right
+func (e *nanoEditor) titleBar() string {
+	version := "  GNU nano " + nanoVersions[honeyos.Distro()]
+	name := "New Buffer"
+	if e.file != "" {
+		name = "File: " + e.file
+	}
+	if e.help {
+		name = "Help!"
+	}
+	state := ""
+	if e.modified {
+		state = "Modified  "
+	}
+	line := []rune(strings.Repeat(" ", e.width))
+	copy(line, []rune(version))
+	if mid := (e.width - utf8.RuneCountInString(name)) / 2; mid > len(version) {
+		copy(line[mid:], []rune(name))
+	}
+	if len(line) > len(state) {
+		copy(line[len(line)-len(state):], []rune(state))
+	}
+	return terminal.Color(terminal.Reverse, string(line))
+}
+
+// shortcutBar is the two rows of shortcuts, as many as fit in the width
+func (e *nanoEditor) shortcutBar() string {
+	items := nanoShortcuts
+	switch {
+	case e.yesNo:
+		items = [][2]string{{" Y", "Yes"}, {" N", "No"}, {"^C", "Cancel"}}
+	case e.prompt != "":
+		items = [][2]string{{"^G", "Get Help"}, {"^C", "Cancel"}}
+	case e.help:
+		items = [][2]string{{"^X", "Exit"}}
+	}
+	cols := (len(items) + 1) / 2
+	if max := e.width / 13; cols > max {
+		cols = max
+	}
+	if cols < 1 {
+		return ""
+	}
+	colWidth := e.width / cols
+	var b strings.Builder
+	for r := 0; r < 2; r++ {
+		for c := 0; c < cols && c*2+r < len(items); c++ {
+			item := items[c*2+r]
+			label := item[1]
+			if n := colWidth - len(item[0]) - 1; len(label) < n && c < cols-1 {
+				label += strings.Repeat(" ", n-len(label))
+			} else if len(label) > n && n > 0 {
+				label = label[:n]
+			}
+			b.WriteString(terminal.Color(terminal.Reverse, item[0]) + " " + label)
+		}
+		b.WriteString("\x1b[K")
+		if r == 0 {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+func (e *nanoEditor) key(key string) {
+	if e.prompt != "" {
+		e.promptKey(key)
+		return
+	}
+	e.message = ""
+	if e.help {
+		e.help = false
+		return
+	}
+	cut := false
+	line := e.lines[e.row]
+	switch key {
+	case "\x07", "\x1bOP":
+		e.help = true
+	case "\x18", "\x1bOQ":
+		if !e.modified {
+			e.quit = true
+			return
+		}
+		e.prompt, e.yesNo = "Save modified buffer (ANSWERING \"No\" WILL DESTROY CHANGES) ? ", true
+		e.done = func(answer string, ok bool) {
+			switch {
+			case !ok:
+				e.message = "Cancelled"
+			case answer == "n":
+				e.quit = true
+			default:
+				e.ask("File Name to Write: ", e.file, func(name string, ok bool) {
+					if !ok || name == "" {
+						e.message = "Cancelled"
+					} else if e.write(name) {
+						e.quit = true
+					}
+				})
+			}
+		}
+	case "\x0f", "\x1bOR":
+		e.ask("File Name to Write: ", e.file, func(name string, ok bool) {
+			if !ok || name == "" {
+				e.message = "Cancelled"
+				return
+			}
+			e.write(name)
+		})
+	case "\x12":
+		e.ask("File to insert [from ./] : ", "", func(name string, ok bool) {
+			if !ok {
+				e.message = "Cancelled"
+				return
+			}
+			data, err := afero.ReadFile(e.sys.FSys(), absPath(e.sys, name))
+			if err != nil {
+				e.message = fmt.Sprintf("File \"%v\" not found", name)
+				return
+			}
+			ins, cur := e.split(data), e.lines[e.row]
+			tail := append([]rune(nil), cur[e.col:]...)
+			lines := append([][]rune(nil), e.lines[:e.row]...)
+			lines = append(lines, append(append([]rune(nil), cur[:e.col]...), ins[0]...))
+			lines = append(lines, ins[1:]...)
+			last, rest := len(lines)-1, e.lines[e.row+1:]
+			e.row, e.col = last, len(lines[last])
+			lines[last] = append(lines[last], tail...)
+			e.lines = append(lines, rest...)
+			e.change()
+			e.message = fmt.Sprintf("Read %v line%v", len(ins)-1, plural(len(ins)-1))
+		})
+	case "\x17":
+		e.ask(e.searchPrompt("Search"), "", func(s string, ok bool) {
+			if !ok {
+				e.message = "Cancelled"
+				return
+			}
+			if s != "" {
+				e.search = s
+			}
+			e.find()
+		})
+	case "\x1c":
+		e.ask(e.searchPrompt("Search (to replace)"), "", func(s string, ok bool) {
+			if !ok {
+				e.message = "Cancelled"
+				return
+			}
+			if s != "" {
+				e.search = s
+			}
+			if e.search == "" {
+				return
+			}
+			e.ask("Replace with: ", "", func(with string, ok bool) {
+				if !ok {
+					e.message = "Cancelled"
+					return
+				}
+				count := 0
+				for i, l := range e.lines {
+					count += strings.Count(string(l), e.search)
+					e.lines[i] = []rune(strings.Replace(string(l), e.search, with, -1))
+				}
+				if count > 0 {
+					e.change()
+				}
+				e.col = clampInt(e.col, 0, len(e.lines[e.row]))
+				e.message = fmt.Sprintf("Replaced %v occurrence%v", count, plural(count))
+			})
+		})
+	case "\x0b":
+		if e.lastCut {
+			e.cut = append(e.cut, line)
+		} else {
+			e.cut = [][]rune{line}
+		}
+		cut = true
+		if e.row == len(e.lines)-1 {
+			e.lines[e.row] = []rune{}
+		} else {
+			e.lines = append(e.lines[:e.row], e.lines[e.row+1:]...)
+		}
+		e.col = 0
+		e.change()
+	case "\x15":
+		if len(e.cut) == 0 {
+			break
+		}
+		lines := append([][]rune(nil), e.lines[:e.row]...)
+		for _, l := range e.cut {
+			lines = append(lines, append([]rune(nil), l...))
+		}
+		e.lines = append(lines, e.lines[e.row:]...)
+		e.row += len(e.cut)
+		e.col = 0
+		e.change()
+	case "\x03":
+		e.message = e.position()
+	case "\x1f":
+		e.ask("Enter line number, column number: ", "", func(s string, ok bool) {
+			if !ok {
+				e.message = "Cancelled"
+				return
+			}
+			parts := strings.SplitN(s, ",", 2)
+			n, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+			if err != nil {
+				e.message = "Invalid line or column number"
+				return
+			}
+			e.row, e.col = clampInt(n-1, 0, len(e.lines)-1), 0
+			if len(parts) > 1 {
+				if c, err := strconv.Atoi(strings.TrimSpace(parts[1])); err == nil {
+					e.col = clampInt(c-1, 0, len(e.lines[e.row]))
+				}
+			}
+		})
+	case "\x0a":
+		e.message = "Can now UnJustify!"
+	case "\x14":
+		e.message = "Spell checking failed: Error invoking \"spell\""
+	case "\x1b[A", "\x10":
+		e.moveRow(-1)
+	case "\x1b[B", "\x0e":
+		e.moveRow(1)
+	case "\x1b[C", "\x06":
+		if e.col < len(line) {
+			e.col++
+		} else if e.row < len(e.lines)-1 {
+			e.row, e.col = e.row+1, 0
+		}
+	case "\x1b[D", "\x02":
+		if e.col > 0 {
+			e.col--
+		} else if e.row > 0 {
+			e.row--
+			e.col = len(e.lines[e.row])
+		}
+	case "\x01", "\x1b[H", "\x1b[1~", "\x1bOH":
+		e.col = 0
+	case "\x05", "\x1b[F", "\x1b[4~", "\x1bOF":
+		e.col = len(line)
+	case "\x19", "\x1b[5~":
+		e.moveRow(-e.editRows())
+	case "\x16", "\x1b[6~":
+		e.moveRow(e.editRows())
+	case "\r":
+		tail := append([]rune(nil), line[e.col:]...)
+		e.lines[e.row] = line[:e.col]
+		lines := append([][]rune(nil), e.lines[:e.row+1]...)
+		lines = append(lines, tail)
+		e.lines = append(lines, e.lines[e.row+1:]...)
+		e.row, e.col = e.row+1, 0
+		e.change()
+	case "\x7f", "\x08":
+		if e.col > 0 {
+			e.lines[e.row] = append(line[:e.col-1], line[e.col:]...)
+			e.col--
+			e.change()
+		} else if e.row > 0 {
+			e.row--
+			e.col = len(e.lines[e.row])
+			e.joinNext()
+		}
+	case "\x04", "\x1b[3~":
+		if e.col < len(line) {
+			e.lines[e.row] = append(line[:e.col], line[e.col+1:]...)
+			e.change()
+		} else if e.row < len(e.lines)-1 {
+			e.joinNext()
+		}
+	default:
+		r, _ := utf8.DecodeRuneInString(key)
+		if len(key) > 1 && key[0] == 0x1b || r < 0x20 && r != '\t' {
+			// Meta keys and the rest of control keys are not bound
+			break
+		}
+		l := append([]rune(nil), line[:e.col]...)
+		l = append(l, r)
+		e.lines[e.row] = append(l, line[e.col:]...)
+		e.col++
+		e.change()
+	}
+	e.lastCut = cut
+}
+
+// promptKey edits the answer on status line
+func (e *nanoEditor) promptKey(key string) {
+	if e.yesNo {
+		switch strings.ToLower(key) {
+		case "y", "n":
+			e.finish(strings.ToLower(key), true)
+		case "\x03":
+			e.finish("", false)
+		}
+		return
+	}
+	switch key {
+	case "\r", "\n":
+		e.finish(string(e.answer), true)
+	case "\x03":
+		e.finish("", false)
+	case "\x7f", "\x08":
+		if len(e.answer) > 0 {
+			e.answer = e.answer[:len(e.answer)-1]
+		}
+	default:
+		r, _ := utf8.DecodeRuneInString(key)
+		if len(key) == 1 && r < 0x20 || len(key) > 1 && key[0] == 0x1b {
+			break
+		}
+		e.answer = append(e.answer, r)
+	}
+}
+
+// finish closes the prompt, then calls its done, which may ask again
+func (e *nanoEditor) finish(answer string, ok bool) {
+	done := e.done
+	e.prompt, e.answer, e.yesNo, e.done = "", nil, false, nil
+	done(answer, ok)
+}
+
+func (e *nanoEditor) searchPrompt(prompt string) string {
+	if e.search != "" {
+		return fmt.Sprintf("%v [%v]: ", prompt, e.search)
+	}
+	return prompt + ": "
+}
+
+// find moves the cursor to the next match of search, wrapping around the
+// end of file
+func (e *nanoEditor) find() {
+	if e.search == "" {
+		return
+	}
+	for i := 0; i <= len(e.lines); i++ {
+		row := (e.row + i) % len(e.lines)
+		l, from := string(e.lines[row]), 0
+		if i == 0 {
+			from = len(string(e.lines[row][:clampInt(e.col+1, 0, len(e.lines[row]))]))
+		}
+		if from > len(l) {
+			continue
+		}
+		if idx := strings.Index(l[from:], e.search); idx >= 0 {
+			if row < e.row || row == e.row && i > 0 {
+				e.message = "Search Wrapped"
+			}
+			e.row, e.col = row, utf8.RuneCountInString(l[:from+idx])
+			return
+		}
+	}
+	e.message = fmt.Sprintf("\"%v\" not found", e.search)
+}
+
+func (e *nanoEditor) moveRow(n int) {
+	e.row = clampInt(e.row+n, 0, len(e.lines)-1)
+	e.col = clampInt(e.col, 0, len(e.lines[e.row]))
+}
+
+// joinNext joins the next line to the current one
+func (e *nanoEditor) joinNext() {
+	e.lines[e.row] = append(e.lines[e.row][:len(e.lines[e.row]):len(e.lines[e.row])], e.lines[e.row+1]...)
+	e.lines = append(e.lines[:e.row+1], e.lines[e.row+2:]...)
+	e.change()
+}
+
+// position tells where the cursor is, like ^C of nano
+func (e *nanoEditor) position() string {
+	chars, total := 0, 0
+	for i, l := range e.lines {
+		n := len(string(l))
+		if i < len(e.lines)-1 {
+			n++
+		}
+		if i < e.row {
+			chars += n
+		}
+		total += n
+	}
+	chars += len(string(e.lines[e.row][:e.col]))
+	lines, cols := len(e.lines), len(e.lines[e.row])+1
+	pct := func(n, of int) int {
+		if of == 0 {
+			return 0
+		}
+		return 100 * n / of
+	}
+	return fmt.Sprintf("line %v/%v (%v%%), col %v/%v (%v%%), char %v/%v (%v%%)", e.row+1, lines, pct(e.row+1, lines),
+		e.col+1, cols, pct(e.col+1, cols), chars, total, pct(chars, total))
+}
@@ -0,0 +1,384 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// nc connects to or listens on the fake network. Nothing is really sent, the
+// data piped into it is captured instead, which is often the payload or the
+// shell of reverse shell attempts
+type nc struct{}
+
+const ncUsage = `usage: nc [-46bCDdhjklnrStUuvZz] [-I length] [-i interval] [-O length]
+	  [-P proxy_username] [-p source_port] [-q seconds] [-s source]
+	  [-T toskeyword] [-V rtable] [-w timeout] [-X proxy_protocol]
+	  [-x proxy_address[:port]] [destination] [port]
+`
+
+const ncBusyboxUsage = `BusyBox v1.34.1 (2022-04-04 10:19:27 UTC) multi-call binary.
+
+Usage: nc [OPTIONS] HOST PORT  - connect
+nc [OPTIONS] -l -p PORT [HOST] [PORT]  - listen
+
+	-e PROG	Run PROG after connect (must be last)
+	-l	Listen mode, for inbound connects
+	-lk	With -e, provides persistent server
+	-p PORT	Local port
+	-s ADDR	Local address
+	-w SEC	Timeout for connects and final net reads
+	-i SEC	Delay interval for lines sent
+	-n	Don't do DNS resolution
+	-u	UDP mode
+	-v	Verbose
+	-o FILE	Hex dump traffic
+	-z	Zero-I/O mode (scanning)
+`
+
+// ncServices are the names of ports shown when connected verbosely
+var ncServices = map[int]string{21: "ftp", 22: "ssh", 23: "telnet", 25: "smtp", 53: "domain", 80: "http",
+	110: "pop3", 143: "imap", 443: "https", 3306: "mysql", 5432: "postgresql", 6379: "*", 8080: "http-alt"}
+
+func init() {
+	honeyos.RegisterCommand("nc", nc{})
+	honeyos.RegisterCommand("netcat", nc{})
+	honeyos.RegisterCommand("ncat", nc{})
+}
+
+func (nc) GetHelp() string {
+	return ncUsage
+}
+
+func (nc) Where() string {
+	if pkgFamily() == "rpm" {
+		return "/usr/bin/nc"
+	}
+	return "/bin/nc"
+}
+
+func (n nc) Exec(args []string, sys honeyos.Sys) int {
+	busybox := pkgFamily() == "apk"
+	// Only nc of busybox and ncat of Nmap run the program after connecting.
+	// CentOS does not have either unless installed
+	ncat := pkgFamily() == "rpm"
+	if ncat && !loadPkgDB(sys, "rpm").installed("nmap-ncat") {
+		return honeyos.CommandNotFound(sys, append([]string{"nc"}, args...))
+	}
+	usage := func() int {
+		if busybox {
+			fmt.Fprint(sys.Err(), ncBusyboxUsage)
+		} else {
+			fmt.Fprint(sys.Err(), ncUsage)
+		}
+		return 1
+	}
+	listen, verbose, scan, udp := false, false, false, false
+	var exec, localPort string
+	var timeout, quit time.Duration = 0, -1
+	var operands []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			operands = append(operands, arg)
+			continue
+		}
+		if ncat && strings.HasPrefix(arg, "--") {
+			// Long options of ncat, like --exec and --sh-exec
+			switch opt := strings.SplitN(arg[2:], "=", 2); opt[0] {
+			case "exec", "sh-exec", "lua-exec":
+				if len(opt) == 1 && i+1 < len(args) {
+					i++
+					opt = append(opt, args[i])
+				}
+				if len(opt) > 1 {
+					exec = opt[1]
+				}
+			case "listen":
+				listen = true
+			case "verbose":
+				verbose = true
+			case "udp":
+				udp = true
+			}
+			continue
+		}
+		for j := 1; j < len(arg); j++ {
+			c := arg[j]
+			takesArg := "IiOPpqsTVwXx"
+			if busybox || ncat {
+				takesArg += "ec"
+			}
+			if strings.IndexByte(takesArg, c) >= 0 {
+				val := arg[j+1:]
+				if val == "" {
+					if i+1 >= len(args) {
+						fmt.Fprintf(sys.Err(), "nc: option requires an argument -- '%c'\n", c)
+						return usage()
+					}
+					i++
+					val = args[i]
+				}
+				secs, _ := strconv.Atoi(val)
+				switch c {
+				case 'e', 'c':
+					// Busybox takes the rest of the arguments as the program
+					exec = strings.Join(append([]string{val}, args[i+1:]...), " ")
+					if !busybox {
+						exec = val
+					} else {
+						i = len(args)
+					}
+				case 'p':
+					localPort = val
+				case 'w':
+					timeout = time.Duration(secs) * time.Second
+				case 'q':
+					quit = time.Duration(secs) * time.Second
+				}
+				break
+			}
+			switch c {
+			case 'l':
+				listen = true
+			case 'v':
+				verbose = true
+			case 'z':
+				scan = true
+			case 'u':
+				udp = true
+			case 'h':
+				return usage()
+			case '4', '6', 'b', 'C', 'D', 'd', 'j', 'k', 'n', 'r', 'S', 't', 'U', 'Z', 'N':
+			default:
+				fmt.Fprintf(sys.Err(), "nc: invalid option -- '%c'\n", c)
+				return usage()
+			}
+		}
+	}
+	logger := sys.Log().WithField("args", args)
+	proto := "tcp"
+	if udp {
+		proto = "udp"
+	}
+
+	if listen {
+		port := localPort
+		if len(operands) > 0 && port == "" {
+			port = operands[len(operands)-1]
+		}
+		if p, err := strconv.Atoi(port); err != nil || p <= 0 || p > 65535 {
+			if port == "" && !ncat {
+				return usage()
+			}
+			if port == "" {
+				port = "31337"
+			} else {
+				fmt.Fprintf(sys.Err(), "nc: port number invalid: %v\n", port)
+				return 1
+			}
+		}
+		p, _ := strconv.Atoi(port)
+		if p < 1024 && !isRoot(sys) {
+			fmt.Fprintln(sys.Err(), "nc: Permission denied")
+			return 1
+		}
+		if open, _ := netPortOpen(sys, net.ParseIP("127.0.0.1"), p); open {
+			fmt.Fprintln(sys.Err(), "nc: Address already in use")
+			return 1
+		}
+		logger.WithField("port", port).WithField("exec", exec).Infof("User listening on port %v with nc", port)
+		sock := honeyos.SockInfo{Proto: proto, Local: "0.0.0.0:" + port, Remote: "0.0.0.0:*"}
+		if !udp {
+			sock.State = "LISTEN"
+		}
+		defer honeyos.OpenSocket(sys, sock)()
+		if verbose {
+			if ncat {
+				fmt.Fprintln(sys.Err(), "Ncat: Version 7.50 ( https://nmap.org/ncat )")
+				fmt.Fprintf(sys.Err(), "Ncat: Listening on :::%v\nNcat: Listening on 0.0.0.0:%v\n", port, port)
+			} else if busybox {
+				fmt.Fprintf(sys.Err(), "listening on [::]:%v ...\n", port)
+			} else {
+				fmt.Fprintf(sys.Err(), "Listening on [0.0.0.0] (family 0, port %v)\n", port)
+			}
+		}
+		// No one ever connects, but what is typed meanwhile is kept
+		data := n.capture(sys, 0, -1, false)
+		if len(data) > 0 {
+			logger.WithField("port", port).WithField("data", string(data)).Infof("User typed %v bytes into nc listening on %v", len(data), port)
+		}
+		return 0
+	}
+
+	if len(operands) < 2 {
+		return usage()
+	}
+	host := operands[0]
+	var ports []int
+	for _, spec := range operands[1:] {
+		lo, hi := spec, spec
+		if r := strings.SplitN(spec, "-", 2); len(r) == 2 {
+			lo, hi = r[0], r[1]
+		}
+		from, err1 := strconv.Atoi(lo)
+		to, err2 := strconv.Atoi(hi)
+		if err1 != nil || err2 != nil || from <= 0 || to > 65535 || from > to {
+			fmt.Fprintf(sys.Err(), "nc: port range not valid\n")
+			return 1
+		}
+		for p := from; p <= to; p++ {
+			ports = append(ports, p)
+		}
+	}
+	ip := netResolve(sys, host)
+	if ip == nil {
+		switch {
+		case busybox:
+			fmt.Fprintf(sys.Err(), "nc: bad address '%v'\n", host)
+		case ncat:
+			fmt.Fprintln(sys.Err(), "Ncat: Could not resolve hostname \""+host+"\": Name or service not known. QUITTING.")
+		default:
+			fmt.Fprintf(sys.Err(), "nc: getaddrinfo: Name or service not known\n")
+		}
+		return 1
+	}
+	logger = logger.WithFields(log.Fields{"host": host, "ip": ip.String(), "exec": exec})
+	if exec != "" {
+		sys.Log().WithFields(log.Fields{"host": host, "port": operands[1], "exec": exec}).
+			Warnf("User started reverse shell to %v:%v running %v with nc", host, operands[1], exec)
+	} else {
+		logger.WithField("ports", operands[1:]).Infof("User connecting to %v port %v with nc", host, strings.Join(operands[1:], " "))
+	}
+
+	status := 1
+	for _, port := range ports {
+		open, reachable := netPortOpen(sys, ip, port)
+		if !reachable && !udp {
+			// Connecting to host that drops the packets hangs until timeout
+			wait := 127 * time.Second
+			if timeout > 0 {
+				wait = timeout
+			}
+			if !pkgSleep(sys, wait) {
+				return 1
+			}
+			if verbose || !scan {
+				fmt.Fprintf(sys.Err(), "nc: connect to %v port %v (%v) failed: Connection timed out\n", host, port, proto)
+			}
+			continue
+		}
+		if !open && !udp {
+			switch {
+			case busybox:
+				fmt.Fprintf(sys.Err(), "nc: can't connect to remote host (%v): Connection refused\n", ip)
+			case ncat:
+				fmt.Fprintln(sys.Err(), "Ncat: Connection refused.")
+			case verbose || !scan:
+				fmt.Fprintf(sys.Err(), "nc: connect to %v port %v (%v) failed: Connection refused\n", host, port, proto)
+			}
+			continue
+		}
+		status = 0
+		if verbose {
+			service := ncServices[port]
+			if service == "" {
+				service = "*"
+			}
+			fmt.Fprintf(sys.Err(), "Connection to %v %v port [%v/%v] succeeded!\n", host, port, proto, service)
+		}
+		if scan {
+			continue
+		}
+		sock := honeyos.SockInfo{Proto: proto, Local: fmt.Sprintf("%v:%v", honeyos.IPAddress(), 40000+port%20000),
+			Remote: fmt.Sprintf("%v:%v", ip, port), State: "ESTABLISHED"}
+		defer honeyos.OpenSocket(sys, sock)()
+		banner := ""
+		if self, _ := sshLookup(sys, ip.String()); port == 22 && self.addr != "" {
+			banner = viper.GetString("server.ident")
+			fmt.Fprintln(sys.Out(), banner)
+		}
+		// sshd hangs up right away on anything but its protocol
+		data := n.capture(sys, timeout, quit, banner != "")
+		if banner != "" && len(data) > 0 {
+			if strings.HasPrefix(string(data), "SSH-") {
+				// Waiting for the key exchange until the login grace time
+				pkgSleep(sys, 120*time.Second)
+			} else {
+				fmt.Fprintln(sys.Out(), "Protocol mismatch.")
+			}
+		}
+		logger.WithField("port", port).WithField("data", string(data)).
+			Infof("User sent %v bytes to %v:%v with nc", len(data), host, port)
+		if len(data) > 0 {
+			honeyos.SaveArtifact(sys, data, fmt.Sprintf("nc %v %v", host, port))
+		}
+		break
+	}
+	return status
+}
+
+// capture reads stdin until the connection is closed, which happens on
+// Ctrl-C, after timeout of idle or quit after the end of input. The peer
+// never closes the connection, and negative quit waits forever like nc does
+// without -q. Reading stops after the first input if once is set
+func (nc) capture(sys honeyos.Sys, timeout, quit time.Duration, once bool) []byte {
+	intr := honeyos.Interrupt(sys)
+	type chunk struct {
+		data []byte
+		err  error
+	}
+	chunks := make(chan chunk)
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := sys.In().Read(buf)
+			select {
+			case chunks <- chunk{append([]byte(nil), buf[:n]...), err}:
+			case <-sys.Context().Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	var data []byte
+	var idle, closed <-chan time.Time
+	if timeout > 0 {
+		idle = time.After(timeout)
+	}
+	for {
+		select {
+		case c := <-chunks:
+			data = append(data, c.data...)
+			if once {
+				return data
+			}
+			if timeout > 0 {
+				idle = time.After(timeout)
+			}
+			if c.err == io.EOF || c.err != nil {
+				chunks = nil
+				if quit >= 0 {
+					closed = time.After(quit)
+				}
+			}
+		case <-idle:
+			return data
+		case <-closed:
+			return data
+		case <-intr:
+			return data
+		case <-sys.Context().Done():
+			return data
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package command
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"net"
+	"strconv"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/viper"
+)
+
+// netRoute is how the fake network reaches the host, for commands like ping
+// and traceroute. The route is made up from the address, so it stays the
+// same across sessions
+type netRoute struct {
+	// hops are the routers before the host, nil for those not answering
+	hops []net.IP
+	// rtt is the round trip time to the host, without jitter
+	rtt time.Duration
+	// ttl is the TTL of the replies from the host as received
+	ttl int
+	// alive tells if the host answers
+	alive bool
+	// local is set for the hosts in the subnet, which are reached directly
+	local bool
+}
+
+// netResolve finds the address of the host. Names are only resolved if the
+// honeypot is online, except for the hosts ssh lands on and the A records of
+// persona.dns
+func netResolve(sys honeyos.Sys, host string) net.IP {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip
+	}
+	if h, ok := sshLookup(sys, host); ok {
+		return net.ParseIP(h.addr)
+	}
+	if answer, ok := dnsLocal(sys, host, "A"); ok {
+		for _, r := range answer.records {
+			if r.kind == "A" {
+				return net.ParseIP(r.value)
+			}
+		}
+		return nil
+	}
+	if !viper.GetBool("server.allowDownload") {
+		return nil
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return nil
+	}
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			return ip
+		}
+	}
+	return nil
+}
+
+// netTrace makes up the route to the address. Besides the machine itself and
+// the hosts ssh lands on, hosts only answer if the honeypot is online
+func netTrace(sys honeyos.Sys, ip net.IP) netRoute {
+	self := net.ParseIP(honeyos.IPAddress())
+	gateway := net.ParseIP(viper.GetString("persona.gateway"))
+	if ip.IsLoopback() || ip.Equal(self) {
+		return netRoute{rtt: 40 * time.Microsecond, ttl: 64, alive: true, local: true}
+	}
+	_, landing := sshLookup(sys, ip.String())
+	mask := net.IPMask(net.ParseIP(viper.GetString("persona.netmask")).To4())
+	if self != nil && mask != nil && self.Mask(mask).Equal(ip.Mask(mask)) {
+		return netRoute{rtt: 350 * time.Microsecond, ttl: 64, alive: landing || ip.Equal(gateway), local: true}
+	}
+
+	r := rand.New(rand.NewSource(int64(fnvString(ip.String()))))
+	route := netRoute{hops: []net.IP{gateway}, ttl: []int{64, 128, 255}[r.Intn(3)]}
+	if landing {
+		// Hosts of the same site are behind the gateway
+		route.rtt, route.alive = 700*time.Microsecond, true
+		route.ttl = 63
+		return route
+	}
+	// Routers of the ISP are the same for all hosts, then comes the backbone
+	// and the network of the host
+	isp := rand.New(rand.NewSource(int64(fnvString(viper.GetString("persona.gateway")))))
+	route.hops = append(route.hops, net.IPv4(10, byte(isp.Intn(256)), byte(isp.Intn(256)), 1), nil,
+		net.IPv4(byte(60+isp.Intn(150)), byte(isp.Intn(256)), byte(isp.Intn(256)), byte(1+isp.Intn(254))))
+	for n := 3 + r.Intn(8); n > 0; n-- {
+		if r.Intn(6) == 0 {
+			route.hops = append(route.hops, nil)
+			continue
+		}
+		route.hops = append(route.hops, net.IPv4(byte(60+r.Intn(150)), byte(r.Intn(256)), byte(r.Intn(256)), byte(1+r.Intn(254))))
+	}
+	ip4 := ip.To4()
+	if ip4 != nil {
+		route.hops = append(route.hops, net.IPv4(ip4[0], ip4[1], byte(r.Intn(256)), byte(1+r.Intn(254))))
+	}
+	route.rtt = time.Duration(4+r.Intn(90)) * time.Millisecond
+	route.ttl -= len(route.hops)
+	route.alive = viper.GetBool("server.allowDownload")
+	return route
+}
+
+// hopRTT is the round trip time to the nth hop, with jitter. The host is
+// the hop after the routers
+func (r netRoute) hopRTT(n int) time.Duration {
+	base := r.rtt
+	if n < len(r.hops) {
+		if n == 0 {
+			base = 500 * time.Microsecond
+		} else {
+			base = r.rtt * time.Duration(n+1) / time.Duration(len(r.hops)+1)
+		}
+	}
+	return base + time.Duration(rand.Int63n(int64(base)/8+1)) - time.Duration(int64(base)/16)
+}
+
+// fnvString hashes the string for seeding the made up values
+func fnvString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// netPortOpen tells if the port of the host accepts connections, which are
+// the ports listening on the machine itself, ssh, telnet and ftp on the
+// hosts ssh lands on, and any port on the Internet if the honeypot is
+// online. reachable is false if connecting times out instead of being
+// refused
+func netPortOpen(sys honeyos.Sys, ip net.IP, port int) (open, reachable bool) {
+	if ip.IsLoopback() || ip.Equal(net.ParseIP(honeyos.IPAddress())) {
+		for _, s := range sys.Sockets() {
+			if _, p := honeyos.SplitAddr(s.Local); s.State == "LISTEN" && p == strconv.Itoa(port) {
+				return true, true
+			}
+		}
+		return false, true
+	}
+	if _, landing := sshLookup(sys, ip.String()); landing {
+		return port == 22 || port == 23 || port == 21, true
+	}
+	route := netTrace(sys, ip)
+	return route.alive && !route.local, route.alive
+}
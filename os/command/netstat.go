@@ -0,0 +1,210 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+)
+
+type netstat struct{}
+
+// sockOptions selects the sockets shown by netstat and ss
+type sockOptions struct {
+	tcp, udp          bool
+	listening, all    bool
+	numeric, programs bool
+}
+
+const netstatUsage = `usage: netstat [-vWeenNcCF] [<Af>] -r         netstat {-V|--version|-h|--help}
+       netstat [-vWnNcaeol] [<Socket> ...]
+       netstat { [-vWeenNac] -i | [-cWnNe] -M | -s }
+
+        -r, --route              display routing table
+        -i, --interfaces         display interface table
+        -g, --groups             display multicast group memberships
+        -s, --statistics         display networking statistics (like SNMP)
+        -M, --masquerade         display masqueraded connections
+
+        -v, --verbose            be verbose
+        -W, --wide               don't truncate IP addresses
+        -n, --numeric            don't resolve names
+        --numeric-hosts          don't resolve host names
+        --numeric-ports          don't resolve port names
+        --numeric-users          don't resolve user names
+        -N, --symbolic           resolve hardware names
+        -e, --extend             display other/more information
+        -p, --programs           display PID/Program name for sockets
+        -c, --continuous         continuous listing
+
+        -l, --listening          display listening server sockets
+        -a, --all, --listening   display all sockets (default: connected)
+        -o, --timers             display timers
+        -F, --fib                display Forwarding Information Base (default)
+        -C, --cache              display routing cache instead of FIB
+
+  <Socket>={-t|--tcp} {-u|--udp} {-w|--raw} {-x|--unix} --ax25 --ipx --netrom
+  <AF>=Use '-6|-4' or '-A <af>' or '--<af>'; default: inet
+  List of possible address families (which support routing):
+    inet (DARPA Internet) inet6 (IPv6) ax25 (AMPR AX.25)
+    netrom (AMPR NET/ROM) ipx (Novell IPX) ddp (Appletalk DDP)
+    x25 (CCITT X.25) `
+
+// services are the names of well known ports in /etc/services
+var services = map[string]string{
+	"21": "ftp", "22": "ssh", "23": "telnet", "25": "smtp", "53": "domain", "67": "bootps", "68": "bootpc",
+	"80": "http", "110": "pop3", "123": "ntp", "143": "imap2", "443": "https", "3306": "mysql",
+	"5432": "postgresql", "8080": "http-alt",
+}
+
+func init() {
+	honeyos.RegisterCommand("netstat", netstat{})
+}
+
+func (netstat) GetHelp() string {
+	return ""
+}
+
+func (netstat) Where() string {
+	return "/bin/netstat"
+}
+
+func (netstat) Exec(args []string, sys honeyos.Sys) int {
+	// CentOS minimal does not come with net-tools
+	if pkgFamily() == "rpm" && !loadPkgDB(sys, "rpm").installed("net-tools") {
+		return honeyos.CommandNotFound(sys, append([]string{"netstat"}, args...))
+	}
+	long := map[string]byte{"tcp": 't', "udp": 'u', "listening": 'l', "all": 'a', "numeric": 'n', "programs": 'p',
+		"wide": 'W', "extend": 'e', "verbose": 'v', "route": 'r'}
+	var opt sockOptions
+	routes := false
+	for _, arg := range args {
+		flags := arg
+		if strings.HasPrefix(arg, "--") {
+			c, ok := long[arg[2:]]
+			if !ok {
+				fmt.Fprintf(sys.Err(), "netstat: unrecognized option '%v'\n%v\n", arg, netstatUsage)
+				return 1
+			}
+			flags = "-" + string(c)
+		} else if !strings.HasPrefix(arg, "-") {
+			fmt.Fprintln(sys.Err(), netstatUsage)
+			return 1
+		}
+		for _, c := range flags[1:] {
+			switch c {
+			case 't':
+				opt.tcp = true
+			case 'u':
+				opt.udp = true
+			case 'l':
+				opt.listening = true
+			case 'a':
+				opt.all = true
+			case 'n':
+				opt.numeric = true
+			case 'p':
+				opt.programs = true
+			case 'r':
+				routes = true
+			case 'W', 'e', 'v', '4', '6', 'o':
+			default:
+				fmt.Fprintf(sys.Err(), "netstat: invalid option -- '%c'\n%v\n", c, netstatUsage)
+				return 1
+			}
+		}
+	}
+	if routes {
+		printRouteTable(sys, opt.numeric, true)
+		return 0
+	}
+	if !opt.tcp && !opt.udp {
+		opt.tcp, opt.udp = true, true
+	}
+	root := isRoot(sys)
+	if opt.programs && !root && honeyos.Distro() != "alpine" {
+		fmt.Fprintln(sys.Out(), "(Not all processes could be identified, non-owned process info\n will not be shown, you would have to be root to see it all.)")
+	}
+	switch {
+	case opt.all:
+		fmt.Fprintln(sys.Out(), "Active Internet connections (servers and established)")
+	case opt.listening:
+		fmt.Fprintln(sys.Out(), "Active Internet connections (only servers)")
+	default:
+		fmt.Fprintln(sys.Out(), "Active Internet connections (w/o servers)")
+	}
+	header := "Proto Recv-Q Send-Q Local Address           Foreign Address         State      "
+	if opt.programs {
+		header += " PID/Program name"
+	}
+	fmt.Fprintln(sys.Out(), header)
+	for _, s := range opt.selectSockets(sys, "tcp", "tcp6", "udp", "udp6") {
+		line := fmt.Sprintf("%-5v %6v %6v %-23v %-23v %-11v", s.Proto, s.RecvQ, s.SendQ, netstatAddr(s.Local, opt.numeric),
+			netstatAddr(s.Remote, opt.numeric), s.State)
+		if opt.programs {
+			prog := "-"
+			if s.PID > 0 && (root || s.User == honeyos.GetUserByID(sys.CurrentUser()).Name) {
+				name := honeyos.ProcInfo{Cmd: s.Program}.Comm()
+				if strings.HasPrefix(s.Program, "sshd: ") {
+					name = s.Program
+				}
+				prog = strconv.Itoa(s.PID) + "/" + name
+				if len(prog) > 18 {
+					prog = prog[:18]
+				}
+			}
+			line += fmt.Sprintf(" %-16v", prog)
+		}
+		fmt.Fprintln(sys.Out(), line)
+	}
+	return 0
+}
+
+// selectSockets returns the sockets of the protocols and states selected,
+// in the order of protos. Only connections are shown by default, like
+// netstat and ss
+func (opt sockOptions) selectSockets(sys honeyos.Sys, protos ...string) []honeyos.SockInfo {
+	var socks []honeyos.SockInfo
+	for _, s := range sys.Sockets() {
+		tcp := strings.HasPrefix(s.Proto, "tcp")
+		listening := s.State == "LISTEN" || !tcp && strings.HasSuffix(s.Remote, ":*")
+		switch {
+		case tcp && !opt.tcp, !tcp && !opt.udp:
+		case opt.all, opt.listening == listening:
+			socks = append(socks, s)
+		}
+	}
+	rank := func(proto string) int {
+		for i, p := range protos {
+			if p == proto {
+				return i
+			}
+		}
+		return len(protos)
+	}
+	sort.SliceStable(socks, func(i, j int) bool { return rank(socks[i].Proto) < rank(socks[j].Proto) })
+	return socks
+}
+
+// netstatAddr formats the address like net-tools does, e.g. *:ssh for
+// 0.0.0.0:22 unless names are not resolved
+func netstatAddr(addr string, numeric bool) string {
+	host, port := honeyos.SplitAddr(addr)
+	if numeric {
+		return addr
+	}
+	switch host {
+	case "0.0.0.0":
+		host = "*"
+	case "::":
+		host = "[::]"
+	case "127.0.0.1", "::1":
+		host = "localhost"
+	}
+	if name, ok := services[port]; ok {
+		port = name
+	}
+	return host + ":" + port
+}
@@ -0,0 +1,617 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	log "github.com/sirupsen/logrus"
+)
+
+// nft sets up the nftables ruleset of the session, which is apart from the
+// rules of iptables like on the machines still using the legacy iptables.
+// Changes are logged the same way as iptables does
+type nft struct{}
+
+func init() {
+	honeyos.RegisterCommand("nft", nft{})
+}
+
+var (
+	nftFamilies = map[string]bool{"ip": true, "ip6": true, "inet": true, "arp": true, "bridge": true, "netdev": true}
+	// nftExprs are the words a rule can start with
+	nftExprs = map[string]bool{
+		"accept": true, "arp": true, "counter": true, "ct": true, "dccp": true, "dnat": true, "drop": true,
+		"ether": true, "fib": true, "goto": true, "icmp": true, "icmpv6": true, "iif": true, "iifname": true,
+		"ip": true, "ip6": true, "jump": true, "limit": true, "log": true, "mark": true, "masquerade": true,
+		"meta": true, "notrack": true, "oif": true, "oifname": true, "queue": true, "redirect": true,
+		"reject": true, "return": true, "sctp": true, "snat": true, "tcp": true, "th": true, "udp": true,
+		"udplite": true, "vlan": true,
+	}
+)
+
+// nftStmt is a command of nft, or a statement in the block of a table or
+// chain
+type nftStmt struct {
+	words []string
+	block []nftStmt
+}
+
+// nftTokens splits the commands into words, with braces, semicolons and
+// newlines apart. Comments are left out
+func nftTokens(s string) []string {
+	var tokens []string
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == '#':
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case strings.IndexByte("{};\n", c) >= 0:
+			tokens = append(tokens, string(c))
+			i++
+		case c == '"':
+			end := len(s)
+			if j := strings.IndexByte(s[i+1:], '"'); j >= 0 {
+				end = i + j + 2
+			}
+			tokens = append(tokens, s[i:end])
+			i = end
+		default:
+			j := i
+			for j < len(s) && strings.IndexByte(" \t\r{};\n#\"", s[j]) < 0 {
+				j++
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		}
+	}
+	return tokens
+}
+
+// nftParse parses the tokens into statements, until the closing brace
+func nftParse(tokens []string) (stmts []nftStmt, rest []string, err error) {
+	var cur nftStmt
+	end := func() {
+		if len(cur.words) > 0 || cur.block != nil {
+			stmts = append(stmts, cur)
+		}
+		cur = nftStmt{}
+	}
+	for len(tokens) > 0 {
+		tok := tokens[0]
+		tokens = tokens[1:]
+		switch tok {
+		case ";", "\n":
+			end()
+		case "{":
+			block, left, err := nftParse(tokens)
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(left) == 0 {
+				return nil, nil, fmt.Errorf("syntax error, unexpected end of file, expecting '}'")
+			}
+			if block == nil {
+				block = []nftStmt{}
+			}
+			cur.block = block
+			tokens = left[1:]
+			end()
+		case "}":
+			end()
+			return stmts, append([]string{"}"}, tokens...), nil
+		default:
+			cur.words = append(cur.words, tok)
+		}
+	}
+	end()
+	return stmts, nil, nil
+}
+
+// nftError is the error of a command, with the command underlined
+type nftError struct {
+	msg, cmd string
+}
+
+func (e *nftError) Error() string {
+	if e.cmd == "" {
+		return "Error: " + e.msg
+	}
+	return fmt.Sprintf("Error: %v\n%v\n%v", e.msg, e.cmd, strings.Repeat("^", len(e.cmd)))
+}
+
+// nftRule normalizes the statements of the rule, counters starting at zero
+func nftRule(words []string) (string, bool) {
+	if len(words) == 0 || !nftExprs[words[0]] {
+		return "", false
+	}
+	var out []string
+	for i, w := range words {
+		out = append(out, w)
+		if w == "counter" && (i+1 >= len(words) || words[i+1] != "packets") {
+			out = append(out, "packets", "0", "bytes", "0")
+		}
+	}
+	return strings.Join(out, " "), true
+}
+
+// nftRun runs the commands on the ruleset
+type nftRun struct {
+	ruleset *[]*honeyos.NftTable
+	// What the commands did, for the log
+	flushed, deleted, opened bool
+	out                      strings.Builder
+	handles                  bool
+}
+
+func (r *nftRun) table(family, name string) *honeyos.NftTable {
+	for _, t := range *r.ruleset {
+		if t.Family == family && t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+func nftFindChain(t *honeyos.NftTable, name string) *honeyos.NftChain {
+	for _, c := range t.Chains {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// nftNextHandle numbers the new rule after the chains and rules of the table
+func nftNextHandle(t *honeyos.NftTable) int {
+	handle := len(t.Chains)
+	for _, c := range t.Chains {
+		for _, rule := range c.Rules {
+			if rule.Handle > handle {
+				handle = rule.Handle
+			}
+		}
+	}
+	return handle + 1
+}
+
+// nftFamily takes the family of the table from the words if given, ip if not
+func nftFamily(words []string) (string, []string) {
+	if len(words) > 0 && nftFamilies[words[0]] {
+		return words[0], words[1:]
+	}
+	return "ip", words
+}
+
+// chainBlock sets up the chain from the statements of its block, the type,
+// hook and policy, and the rules
+func (r *nftRun) chainBlock(t *honeyos.NftTable, c *honeyos.NftChain, block []nftStmt, cmd string) error {
+	for _, s := range block {
+		w := s.words
+		if len(w) == 0 {
+			continue
+		}
+		switch w[0] {
+		case "type":
+			if len(w) < 6 || w[2] != "hook" || w[4] != "priority" {
+				return &nftError{"syntax error, unexpected end of file", cmd}
+			}
+			prio, err := strconv.Atoi(w[5])
+			if err != nil {
+				prio = map[string]int{"raw": -300, "mangle": -150, "dstnat": -100, "filter": 0, "security": 50, "srcnat": 100}[w[5]]
+			}
+			c.Type, c.Hook, c.Priority = w[1], w[3], prio
+			if c.Policy == "" {
+				c.Policy = "accept"
+			}
+			if len(w) > 7 && w[6] == "policy" {
+				c.Policy = w[7]
+			}
+		case "policy":
+			if len(w) < 2 || w[1] != "accept" && w[1] != "drop" {
+				return &nftError{"syntax error, unexpected end of file, expecting accept or drop", cmd}
+			}
+			c.Policy = w[1]
+		default:
+			rule, ok := nftRule(w)
+			if !ok {
+				return &nftError{fmt.Sprintf("syntax error, unexpected %v", w[0]), cmd}
+			}
+			c.Rules = append(c.Rules, honeyos.NftRule{Handle: nftNextHandle(t), Expr: rule})
+			r.opened = r.opened || nftOpens(rule)
+		}
+	}
+	return nil
+}
+
+// nftOpens tells if the rule accepts the traffic to some port
+func nftOpens(rule string) bool {
+	return strings.Contains(rule, "dport") && (strings.HasSuffix(rule, "accept") || strings.Contains(rule, "dnat") ||
+		strings.Contains(rule, "redirect"))
+}
+
+// exec runs the command, the words of the statement and its block
+func (r *nftRun) exec(s nftStmt) error {
+	cmd := strings.Join(s.words, " ")
+	w := s.words
+	if len(w) > 0 && (w[0] == "table" || w[0] == "chain" || w[0] == "rule") {
+		// The file of nft -f has no add
+		w = append([]string{"add"}, w...)
+	}
+	if len(w) < 2 {
+		if len(w) == 0 {
+			return nil
+		}
+		return &nftError{fmt.Sprintf("syntax error, unexpected %v", w[0]), cmd}
+	}
+	noEntry := &nftError{"Could not process rule: No such file or directory", cmd}
+	verb, object := w[0], w[1]
+	family, args := nftFamily(w[2:])
+	switch {
+	case verb == "list" && (object == "ruleset" || object == "tables"):
+		family = ""
+		if len(w) > 2 {
+			family = w[2]
+		}
+		for _, t := range *r.ruleset {
+			if family == "" || t.Family == family {
+				if object == "tables" {
+					fmt.Fprintf(&r.out, "table %v %v\n", t.Family, t.Name)
+				} else {
+					r.list(t, nil)
+				}
+			}
+		}
+	case verb == "flush" && object == "ruleset":
+		if len(w) < 3 {
+			*r.ruleset = nil
+		} else {
+			var kept []*honeyos.NftTable
+			for _, t := range *r.ruleset {
+				if t.Family != w[2] {
+					kept = append(kept, t)
+				}
+			}
+			*r.ruleset = kept
+		}
+		r.flushed = true
+	case object == "table":
+		if len(args) != 1 {
+			return &nftError{"syntax error, unexpected end of file", cmd}
+		}
+		t := r.table(family, args[0])
+		switch verb {
+		case "add", "create":
+			if t != nil && verb == "create" {
+				return &nftError{"Could not process rule: File exists", cmd}
+			}
+			if t == nil {
+				t = &honeyos.NftTable{Family: family, Name: args[0]}
+				*r.ruleset = append(*r.ruleset, t)
+			}
+			// Statements in the block of the table define the chains
+			for _, stmt := range s.block {
+				sw := stmt.words
+				switch {
+				case len(sw) == 2 && sw[0] == "chain":
+					if err := r.exec(nftStmt{[]string{"add", "chain", family, args[0], sw[1]}, stmt.block}); err != nil {
+						return err
+					}
+				case len(sw) > 0 && sw[0] != "flags" && sw[0] != "comment":
+					return &nftError{fmt.Sprintf("syntax error, unexpected %v", sw[0]), strings.Join(sw, " ")}
+				}
+			}
+		case "delete", "flush", "list":
+			if t == nil {
+				return noEntry
+			}
+			switch verb {
+			case "delete":
+				for i, x := range *r.ruleset {
+					if x == t {
+						*r.ruleset = append((*r.ruleset)[:i], (*r.ruleset)[i+1:]...)
+						break
+					}
+				}
+				r.deleted = true
+			case "flush":
+				for _, c := range t.Chains {
+					c.Rules = nil
+				}
+				r.flushed = true
+			default:
+				r.list(t, nil)
+			}
+		default:
+			return &nftError{fmt.Sprintf("syntax error, unexpected %v", verb), cmd}
+		}
+	case object == "chain" || object == "chains":
+		if object == "chains" {
+			for _, t := range *r.ruleset {
+				r.list(t, nil)
+			}
+			return nil
+		}
+		if len(args) != 2 {
+			return &nftError{"syntax error, unexpected end of file", cmd}
+		}
+		t := r.table(family, args[0])
+		if t == nil {
+			return noEntry
+		}
+		c := nftFindChain(t, args[1])
+		switch verb {
+		case "add", "create":
+			if c != nil && verb == "create" {
+				return &nftError{"Could not process rule: File exists", cmd}
+			}
+			if c == nil {
+				c = &honeyos.NftChain{Name: args[1]}
+				t.Chains = append(t.Chains, c)
+			}
+			return r.chainBlock(t, c, s.block, cmd)
+		case "delete", "flush", "list":
+			if c == nil {
+				return noEntry
+			}
+			switch verb {
+			case "delete":
+				if len(c.Rules) > 0 {
+					return &nftError{"Could not process rule: Device or resource busy", cmd}
+				}
+				for i, x := range t.Chains {
+					if x == c {
+						t.Chains = append(t.Chains[:i], t.Chains[i+1:]...)
+						break
+					}
+				}
+				r.deleted = true
+			case "flush":
+				c.Rules = nil
+				r.flushed = true
+			default:
+				r.list(t, c)
+			}
+		default:
+			return &nftError{fmt.Sprintf("syntax error, unexpected %v", verb), cmd}
+		}
+	case object == "rule":
+		if len(args) < 2 {
+			return &nftError{"syntax error, unexpected end of file", cmd}
+		}
+		t := r.table(family, args[0])
+		if t == nil {
+			return noEntry
+		}
+		c := nftFindChain(t, args[1])
+		if c == nil {
+			return noEntry
+		}
+		args = args[2:]
+		// The rule is placed by its handle or position
+		at := -1
+		if len(args) >= 2 && (args[0] == "handle" || args[0] == "position" || args[0] == "index") {
+			n, err := strconv.Atoi(args[1])
+			if err != nil {
+				return &nftError{"syntax error, unexpected string, expecting number", cmd}
+			}
+			for i, rule := range c.Rules {
+				if args[0] == "index" && i == n || args[0] != "index" && rule.Handle == n {
+					at = i
+				}
+			}
+			if at < 0 {
+				return noEntry
+			}
+			args = args[2:]
+		}
+		if verb == "delete" {
+			if at < 0 {
+				return &nftError{"syntax error, unexpected end of file, expecting handle", cmd}
+			}
+			c.Rules = append(c.Rules[:at], c.Rules[at+1:]...)
+			r.deleted = true
+			return nil
+		}
+		rule, ok := nftRule(args)
+		if !ok {
+			unexpected := "end of file"
+			if len(args) > 0 {
+				unexpected = args[0]
+			}
+			return &nftError{"syntax error, unexpected " + unexpected, cmd}
+		}
+		r.opened = r.opened || nftOpens(rule)
+		added := honeyos.NftRule{Handle: nftNextHandle(t), Expr: rule}
+		switch verb {
+		case "add":
+			if at < 0 {
+				at = len(c.Rules) - 1
+			}
+			c.Rules = append(c.Rules[:at+1], append([]honeyos.NftRule{added}, c.Rules[at+1:]...)...)
+		case "insert":
+			if at < 0 {
+				at = 0
+			}
+			c.Rules = append(c.Rules[:at], append([]honeyos.NftRule{added}, c.Rules[at:]...)...)
+		case "replace":
+			if at < 0 {
+				return &nftError{"syntax error, unexpected end of file, expecting handle", cmd}
+			}
+			added.Handle = c.Rules[at].Handle
+			c.Rules[at] = added
+		default:
+			return &nftError{fmt.Sprintf("syntax error, unexpected %v", verb), cmd}
+		}
+	default:
+		return &nftError{fmt.Sprintf("syntax error, unexpected %v", w[1]), cmd}
+	}
+	return nil
+}
+
+// list prints the table like nft list, all chains or only one
+func (r *nftRun) list(t *honeyos.NftTable, only *honeyos.NftChain) {
+	fmt.Fprintf(&r.out, "table %v %v {\n", t.Family, t.Name)
+	first := true
+	for _, c := range t.Chains {
+		if only != nil && c != only {
+			continue
+		}
+		if !first {
+			r.out.WriteString("\n")
+		}
+		first = false
+		fmt.Fprintf(&r.out, "\tchain %v {\n", c.Name)
+		if c.Type != "" {
+			fmt.Fprintf(&r.out, "\t\ttype %v hook %v priority %v; policy %v;\n", c.Type, c.Hook, c.Priority, c.Policy)
+		}
+		for _, rule := range c.Rules {
+			fmt.Fprintf(&r.out, "\t\t%v", rule.Expr)
+			if r.handles {
+				fmt.Fprintf(&r.out, " # handle %v", rule.Handle)
+			}
+			r.out.WriteString("\n")
+		}
+		r.out.WriteString("\t}\n")
+	}
+	r.out.WriteString("}\n")
+}
+
+func (nft) GetHelp() string {
+	return "Usage: nft [ options ] [ cmds... ]\n\n" +
+		"Options:\n" +
+		"  -h, --help\t\t\tShow this help\n" +
+		"  -v, --version\t\t\tShow version information\n\n" +
+		"  -c, --check\t\t\tCheck commands validity without actually applying the changes.\n" +
+		"  -f, --file <filename>\t\tRead input from <filename>\n" +
+		"  -i, --interactive\t\tRead input from interactive CLI\n\n" +
+		"  -n, --numeric\t\t\tWhen specified once, show network addresses numerically (default behaviour).\n" +
+		"  \t\t\t\tSpecify twice to also show Internet services (port numbers) numerically.\n" +
+		"\t\t\t\tSpecify three times to also show protocols, user IDs, and group IDs numerically.\n" +
+		"  -s, --stateless\t\tOmit stateful information of ruleset.\n" +
+		"  -N\t\t\t\tTranslate IP addresses to names.\n" +
+		"  -a, --handle\t\t\tOutput rule handle.\n" +
+		"  -e, --echo\t\t\tEcho what has been added, inserted or replaced.\n" +
+		"  -I, --includepath <directory>\tAdd <directory> to the paths searched for include files. Default is: /etc\n" +
+		"  --debug <level [,level...]>\tSpecify debugging level (scanner, parser, eval, netlink, mnl, proto-ctx, segtree, all)\n\n"
+}
+
+func (nft) Where() string {
+	return "/usr/sbin/nft"
+}
+
+func (n nft) Exec(args []string, sys honeyos.Sys) int {
+	r := &nftRun{}
+	check, input := false, ""
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			input = strings.Join(args[i:], " ")
+			break
+		}
+		switch arg {
+		case "-h", "--help":
+			fmt.Fprint(sys.Out(), n.GetHelp())
+			return 0
+		case "-v", "--version":
+			fmt.Fprintln(sys.Out(), "nftables v0.9.0 (Fearless Fosdick)")
+			return 0
+		case "-a", "--handle":
+			r.handles = true
+		case "-c", "--check":
+			check = true
+		case "-n", "-nn", "-nnn", "--numeric", "-s", "--stateless", "-N", "-e", "--echo":
+		case "-j", "--json":
+			fmt.Fprintln(sys.Err(), "Error: JSON support not compiled-in")
+			return 1
+		case "-i", "--interactive":
+			fmt.Fprintln(sys.Err(), "Error: interactive CLI not supported in this build")
+			return 1
+		case "-f", "--file", "-I", "--includepath", "--debug":
+			if i+1 >= len(args) {
+				fmt.Fprintf(sys.Err(), "nft: option requires an argument -- '%v'\n%v", strings.TrimLeft(arg, "-"), n.GetHelp())
+				return 1
+			}
+			i++
+			if arg == "-f" || arg == "--file" {
+				data, err := readFile(sys, absPath(sys, args[i]))
+				if err != nil {
+					fmt.Fprintf(sys.Err(), "Error: Could not process rule: No such file or directory\ninclude \"%v\"\n^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^\n", args[i])
+					return 1
+				}
+				input = string(data)
+			}
+		default:
+			fmt.Fprintf(sys.Err(), "nft: unrecognized option '%v'\n%v", arg, n.GetHelp())
+			return 1
+		}
+	}
+	if strings.TrimSpace(input) == "" {
+		return 0
+	}
+	if !isRoot(sys) {
+		fmt.Fprintln(sys.Err(), "netlink: Error: cache initialization failed: Operation not permitted")
+		return 1
+	}
+	stmts, rest, err := nftParse(nftTokens(input))
+	if err == nil && len(rest) > 0 {
+		err = fmt.Errorf("syntax error, unexpected '}'")
+	}
+	if err != nil {
+		fmt.Fprintln(sys.Err(), "Error:", err)
+		return 1
+	}
+	honeyos.UpdateFirewall(sys, func(_ map[string][]*honeyos.Chain, ruleset *[]*honeyos.NftTable) {
+		// Commands are applied all or none like a transaction
+		staged := nftCopy(*ruleset)
+		r.ruleset = &staged
+		for _, s := range stmts {
+			if err = r.exec(s); err != nil {
+				return
+			}
+		}
+		if !check {
+			*ruleset = staged
+		}
+	})
+	if err != nil {
+		fmt.Fprintln(sys.Err(), err)
+		return 1
+	}
+	fmt.Fprint(sys.Out(), r.out.String())
+	if check {
+		return 0
+	}
+	logger := sys.Log().WithFields(log.Fields{"args": args, "commands": input})
+	switch {
+	case r.flushed:
+		logger.Warnf("User flushed firewall rules with nft")
+	case r.opened:
+		logger.Warnf("User opened port with nft")
+	case r.deleted:
+		logger.Warnf("User deleted firewall rules with nft")
+	case strings.HasPrefix(input, "list"):
+	default:
+		logger.Infof("User changed firewall rules with nft")
+	}
+	return 0
+}
+
+// nftCopy copies the ruleset for the commands to change
+func nftCopy(ruleset []*honeyos.NftTable) []*honeyos.NftTable {
+	var tables []*honeyos.NftTable
+	for _, t := range ruleset {
+		copied := &honeyos.NftTable{Family: t.Family, Name: t.Name}
+		for _, c := range t.Chains {
+			chain := *c
+			chain.Rules = append([]honeyos.NftRule(nil), c.Rules...)
+			copied.Chains = append(copied.Chains, &chain)
+		}
+		tables = append(tables, copied)
+	}
+	return tables
+}
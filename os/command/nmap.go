@@ -0,0 +1,1147 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+// nmap scans the hosts of the fake network. Hosts answer and ports are open
+// the way the other network commands find them, and the scan takes as long
+// as it would. The ranges and options are logged
+type nmap struct{}
+
+// nmapScan is what the options of nmap ask for
+type nmapScan struct {
+	tcp, udp, connect                   bool
+	ping, list                          bool
+	noPing, versions, osDetect, tracert bool
+	openOnly, numeric, reason           bool
+	verbose                             int
+	ports                               nmapPorts
+	// timing scales the time taken, 1 for -T3
+	timing float64
+}
+
+// nmapPorts are the ports scanned of each protocol
+type nmapPorts struct {
+	tcp, udp []int
+}
+
+// nmapPort is a port shown in the report of the host
+type nmapPort struct {
+	port                  int
+	proto, state, service string
+	version, reasonFor    string
+}
+
+// nmapTopPorts are the 100 ports scanned by -F, most common first
+var nmapTopPorts = []int{80, 23, 443, 21, 22, 25, 3389, 110, 445, 139, 143, 53, 135, 3306, 8080, 1723, 111, 995, 993,
+	5900, 1025, 587, 8888, 199, 1720, 465, 548, 113, 81, 6001, 10000, 514, 5060, 179, 1026, 2000, 8443, 8000, 32768,
+	554, 26, 1433, 49152, 2001, 515, 8008, 49154, 1027, 5666, 646, 5000, 5631, 631, 49153, 8081, 2049, 88, 79, 5800,
+	106, 2121, 1110, 49155, 6000, 513, 990, 5357, 427, 49156, 543, 544, 5101, 144, 7, 389, 8009, 3128, 444, 9999, 5009,
+	7070, 5190, 3000, 5432, 1900, 3986, 13, 1029, 9, 5051, 6646, 49157, 1028, 873, 1755, 2717, 4899, 9100, 119, 37}
+
+// nmapExtraPorts are in the top 1000 besides the first 1024
+var nmapExtraPorts = map[int]bool{1080: true, 1433: true, 1521: true, 2375: true, 3000: true, 3306: true,
+	3389: true, 4444: true, 5432: true, 5900: true, 5984: true, 6379: true, 6667: true, 7001: true, 8000: true,
+	8008: true, 8080: true, 8081: true, 8088: true, 8443: true, 8888: true, 9000: true, 9090: true, 9200: true,
+	10000: true, 11211: true, 27017: true, 50000: true}
+
+// nmapServices are the names nmap gives the ports, from nmap-services
+var nmapServices = map[int]string{7: "echo", 9: "discard", 13: "daytime", 21: "ftp", 22: "ssh", 23: "telnet",
+	25: "smtp", 26: "rsftp", 37: "time", 53: "domain", 67: "dhcps", 68: "dhcpc", 79: "finger", 80: "http", 81: "hosts2-ns",
+	88: "kerberos-sec", 106: "pop3pw", 110: "pop3", 111: "rpcbind", 113: "ident", 119: "nntp", 123: "ntp",
+	135: "msrpc", 137: "netbios-ns", 139: "netbios-ssn", 143: "imap", 161: "snmp", 179: "bgp", 199: "smux",
+	323: "rpki-rtr", 389: "ldap", 443: "https", 445: "microsoft-ds", 465: "smtps", 514: "shell", 515: "printer",
+	548: "afp", 554: "rtsp", 587: "submission", 631: "ipp", 873: "rsync", 993: "imaps", 995: "pop3s",
+	1080: "socks", 1433: "ms-sql-s", 1521: "oracle", 1723: "pptp", 2049: "nfs", 2375: "docker", 3000: "ppp",
+	3128: "squid-http", 3306: "mysql", 3389: "ms-wbt-server", 4444: "krb524", 5060: "sip", 5432: "postgresql",
+	5900: "vnc", 5984: "couchdb", 6000: "X11", 6379: "redis", 6667: "irc", 8000: "http-alt", 8008: "http",
+	8080: "http-proxy", 8081: "blackice-icecap", 8443: "https-alt", 8888: "sun-answerbook", 9000: "cslistener",
+	9090: "zeus-admin", 9100: "jetdirect", 9200: "wap-wsp", 10000: "snet-sensor-mgmt", 11211: "memcache",
+	27017: "mongod"}
+
+// nmapVersions are what -sV finds listening on the ports
+var nmapVersions = map[int][2]string{22: {"ssh", "OpenSSH 7.4 (protocol 2.0)"}, 21: {"ftp", "vsftpd 3.0.3"}, 23: {"telnet", "Linux telnetd"},
+	25: {"smtp", "Postfix smtpd"}, 53: {"domain", "ISC BIND 9.11.3"}, 80: {"http", "nginx 1.18.0"},
+	443: {"ssl/http", "nginx 1.18.0"}, 3306: {"mysql", "MySQL 5.7.33-0ubuntu0.16.04.1"},
+	3389: {"ms-wbt-server", "Microsoft Terminal Services"}, 5432: {"postgresql", "PostgreSQL DB 9.6.0 or later"},
+	6379: {"redis", "Redis key-value store 3.0.6"}, 8080: {"http", "Apache Tomcat 8.5.72"},
+	8443: {"ssl/http", "Apache httpd 2.4.41"}, 27017: {"mongodb", "MongoDB 3.6.8"}}
+
+const nmapVersion = "7.01"
+
+func init() {
+	honeyos.RegisterCommand("nmap", nmap{})
+}
+
+func (nmap) GetHelp() string {
+	return nmapUsage
+}
+
+func (nmap) Where() string {
+	return "/usr/bin/nmap"
+}
+
+const nmapUsage = `Nmap 7.01 ( https://nmap.org )
+Usage: nmap [Scan Type(s)] [Options] {target specification}
+TARGET SPECIFICATION:
+  Can pass hostnames, IP addresses, networks, etc.
+  Ex: scanme.nmap.org, microsoft.com/24, 192.168.0.1; 10.0.0-255.1-254
+  -iL <inputfilename>: Input from list of hosts/networks
+  -iR <num hosts>: Choose random targets
+  --exclude <host1[,host2][,host3],...>: Exclude hosts/networks
+  --excludefile <exclude_file>: Exclude list from file
+HOST DISCOVERY:
+  -sL: List Scan - simply list targets to scan
+  -sn: Ping Scan - disable port scan
+  -Pn: Treat all hosts as online -- skip host discovery
+  -PS/PA/PU/PY[portlist]: TCP SYN/ACK, UDP or SCTP discovery to given ports
+  -PE/PP/PM: ICMP echo, timestamp, and netmask request discovery probes
+  -PO[protocol list]: IP Protocol Ping
+  -n/-R: Never do DNS resolution/Always resolve [default: sometimes]
+  --dns-servers <serv1[,serv2],...>: Specify custom DNS servers
+  --system-dns: Use OS's DNS resolver
+  --traceroute: Trace hop path to each host
+SCAN TECHNIQUES:
+  -sS/sT/sA/sW/sM: TCP SYN/Connect()/ACK/Window/Maimon scans
+  -sU: UDP Scan
+  -sN/sF/sX: TCP Null, FIN, and Xmas scans
+  --scanflags <flags>: Customize TCP scan flags
+  -sI <zombie host[:probeport]>: Idle scan
+  -sY/sZ: SCTP INIT/COOKIE-ECHO scans
+  -sO: IP protocol scan
+  -b <FTP relay host>: FTP bounce scan
+PORT SPECIFICATION AND SCAN ORDER:
+  -p <port ranges>: Only scan specified ports
+    Ex: -p22; -p1-65535; -p U:53,111,137,T:21-25,80,139,8080,S:9
+  --exclude-ports <port ranges>: Exclude the specified ports from scanning
+  -F: Fast mode - Scan fewer ports than the default scan
+  -r: Scan ports consecutively - don't randomize
+  --top-ports <number>: Scan <number> most common ports
+  --port-ratio <ratio>: Scan ports more common than <ratio>
+SERVICE/VERSION DETECTION:
+  -sV: Probe open ports to determine service/version info
+  --version-intensity <level>: Set from 0 (light) to 9 (try all probes)
+  --version-light: Limit to most likely probes (intensity 2)
+  --version-all: Try every single probe (intensity 9)
+  --version-trace: Show detailed version scan activity (for debugging)
+SCRIPT SCAN:
+  -sC: equivalent to --script=default
+  --script=<Lua scripts>: <Lua scripts> is a comma separated list of
+           directories, script-files or script-categories
+  --script-args=<n1=v1,[n2=v2,...]>: provide arguments to scripts
+OS DETECTION:
+  -O: Enable OS detection
+  --osscan-limit: Limit OS detection to promising targets
+  --osscan-guess: Guess OS more aggressively
+TIMING AND PERFORMANCE:
+  Options which take <time> are in seconds, or append 'ms' (milliseconds),
+  's' (seconds), 'm' (minutes), or 'h' (hours) to the value (e.g. 30m).
+  -T<0-5>: Set timing template (higher is faster)
+  --min-hostgroup/max-hostgroup <size>: Parallel host scan group sizes
+  --min-parallelism/max-parallelism <numprobes>: Probe parallelization
+  --max-retries <tries>: Caps number of port scan probe retransmissions.
+  --host-timeout <time>: Give up on target after this long
+  --scan-delay/--max-scan-delay <time>: Adjust delay between probes
+  --min-rate <number>: Send packets no slower than <number> per second
+  --max-rate <number>: Send packets no faster than <number> per second
+FIREWALL/IDS EVASION AND SPOOFING:
+  -f; --mtu <val>: fragment packets (optionally w/given MTU)
+  -D <decoy1,decoy2[,ME],...>: Cloak a scan with decoys
+  -S <IP_Address>: Spoof source address
+  -e <iface>: Use specified interface
+  -g/--source-port <portnum>: Use given port number
+  --data-length <num>: Append random data to sent packets
+  --spoof-mac <mac address/prefix/vendor name>: Spoof your MAC address
+OUTPUT:
+  -oN/-oX/-oS/-oG <file>: Output scan in normal, XML, s|<rIpt kIddi3,
+     and Grepable format, respectively, to the given filename.
+  -oA <basename>: Output in the three major formats at once
+  -v: Increase verbosity level (use -vv or more for greater effect)
+  -d: Increase debugging level (use -dd or more for greater effect)
+  --reason: Display the reason a port is in a particular state
+  --open: Only show open (or possibly open) ports
+  --packet-trace: Show all packets sent and received
+  --iflist: Print host interfaces and routes (for debugging)
+  --append-output: Append to rather than clobber specified output files
+  --resume <filename>: Resume an aborted scan
+MISC:
+  -6: Enable IPv6 scanning
+  -A: Enable OS detection, version detection, script scanning, and traceroute
+  -V: Print version number
+  -h: Print this help summary page.
+EXAMPLES:
+  nmap -v -A scanme.nmap.org
+  nmap -v -sn 192.168.0.0/16 10.0.0.0/8
+  nmap -v -iR 10000 -Pn -p 80
+SEE THE MAN PAGE (https://nmap.org/book/man.html) FOR MORE OPTIONS AND EXAMPLES
+`
+
+// nmapValueOpts take a value which is not used
+var nmapValueOpts = map[string]bool{"--script": true, "--script-args": true, "-e": true, "-S": true, "-D": true,
+	"-g": true, "--source-port": true, "--data-length": true, "--spoof-mac": true, "--mtu": true,
+	"--min-rate": true, "--max-rate": true, "--max-retries": true, "--host-timeout": true, "--scan-delay": true,
+	"--max-scan-delay": true, "--min-hostgroup": true, "--max-hostgroup": true, "--min-parallelism": true,
+	"--max-parallelism": true, "--version-intensity": true, "--dns-servers": true, "--exclude-ports": true,
+	"--excludefile": true, "--port-ratio": true, "--scanflags": true, "--resume": true, "--datadir": true,
+	"--ttl": true, "--stylesheet": true, "-iR": true, "-oS": true, "--script-timeout": true}
+
+// nmapTimings are the factors of the time taken by -T0 to -T5
+var nmapTimings = []float64{300, 15, 4, 1, 0.7, 0.5}
+
+// nmapCommon are the ports by how common they are, the top ports first then
+// the rest of the first 1000 scanned by default
+func nmapCommon(n int) []int {
+	list := append([]int{}, nmapTopPorts...)
+	seen := map[int]bool{}
+	for _, p := range list {
+		seen[p] = true
+	}
+	var extra []int
+	for p := range nmapExtraPorts {
+		extra = append(extra, p)
+	}
+	sort.Ints(extra)
+	for p := 1; p <= 1024; p++ {
+		extra = append(extra, p)
+	}
+	for _, p := range extra {
+		if !seen[p] {
+			list, seen[p] = append(list, p), true
+		}
+	}
+	if n > len(list) {
+		n = len(list)
+	}
+	list = append([]int{}, list[:n]...)
+	sort.Ints(list)
+	return list
+}
+
+// nmapParsePorts parses the port ranges of -p like 22,80-90,U:53,T:-1024 or
+// service names
+func nmapParsePorts(spec string) (nmapPorts, bool) {
+	var ports nmapPorts
+	proto := ""
+	add := func(p int) {
+		if proto != "U" {
+			ports.tcp = append(ports.tcp, p)
+		}
+		if proto != "T" {
+			ports.udp = append(ports.udp, p)
+		}
+	}
+	for _, item := range strings.Split(spec, ",") {
+		if len(item) > 2 && item[1] == ':' {
+			switch proto = strings.ToUpper(item[:1]); proto {
+			case "T", "U":
+			case "S":
+				// SCTP is not scanned
+				continue
+			default:
+				return ports, false
+			}
+			item = item[2:]
+		}
+		if name, found := nmapServiceNamed(item); found {
+			add(name)
+			continue
+		}
+		from, to := item, item
+		if i := strings.Index(item, "-"); i >= 0 {
+			from, to = item[:i], item[i+1:]
+			if from == "" {
+				from = "1"
+			}
+			if to == "" {
+				to = "65535"
+			}
+		}
+		lo, err1 := strconv.Atoi(from)
+		hi, err2 := strconv.Atoi(to)
+		if err1 != nil || err2 != nil || lo < 0 || hi > 65535 || lo > hi {
+			return ports, false
+		}
+		for p := lo; p <= hi; p++ {
+			add(p)
+		}
+	}
+	return ports, true
+}
+
+func nmapServiceNamed(name string) (int, bool) {
+	for p, s := range nmapServices {
+		if s == name {
+			return p, true
+		}
+	}
+	return 0, false
+}
+
+// nmapTargets expands the targets like 10.0.0.0/24, 10.0.1-3.* or
+// 10.0.0.1,5 to their addresses. Names are resolved, nil is returned for
+// those which do not
+func nmapTargets(sys honeyos.Sys, target string, limit int) (ips []net.IP, names []string, ok bool) {
+	if _, network, err := net.ParseCIDR(target); err == nil {
+		return nmapNetwork(network, limit), nil, true
+	}
+	if i := strings.LastIndex(target, "/"); i > 0 {
+		bits, err := strconv.Atoi(target[i+1:])
+		if ip := netResolve(sys, target[:i]); err == nil && ip != nil && ip.To4() != nil && bits >= 0 && bits <= 32 {
+			return nmapNetwork(&net.IPNet{IP: ip.To4().Mask(net.CIDRMask(bits, 32)), Mask: net.CIDRMask(bits, 32)}, limit), nil, true
+		}
+	}
+	if octets := strings.Split(target, "."); len(octets) == 4 && strings.ContainsAny(target, "-*,") {
+		var ranges [4][]byte
+		for i, o := range octets {
+			if ranges[i] = nmapOctets(o); ranges[i] == nil {
+				return nil, nil, false
+			}
+		}
+		for _, a := range ranges[0] {
+			for _, b := range ranges[1] {
+				for _, c := range ranges[2] {
+					for _, d := range ranges[3] {
+						if len(ips) >= limit {
+							return ips, nil, true
+						}
+						ips = append(ips, net.IPv4(a, b, c, d))
+					}
+				}
+			}
+		}
+		return ips, nil, true
+	}
+	ip := netResolve(sys, target)
+	if ip == nil {
+		return nil, nil, false
+	}
+	name := ""
+	if net.ParseIP(target) == nil {
+		name = target
+	}
+	return []net.IP{ip}, []string{name}, true
+}
+
+// nmapNetwork are the addresses of the network, up to the limit
+func nmapNetwork(network *net.IPNet, limit int) []net.IP {
+	var ips []net.IP
+	ip := network.IP.To4()
+	if ip == nil {
+		return nil
+	}
+	for n := binaryIP(ip); network.Contains(ipBinary(n)) && len(ips) < limit; n++ {
+		ips = append(ips, ipBinary(n))
+		if n == math.MaxUint32 {
+			break
+		}
+	}
+	return ips
+}
+
+func binaryIP(ip net.IP) uint32 {
+	ip = ip.To4()
+	return uint32(ip[0])<<24 | uint32(ip[1])<<16 | uint32(ip[2])<<8 | uint32(ip[3])
+}
+
+func ipBinary(n uint32) net.IP {
+	return net.IPv4(byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+}
+
+// nmapOctets expands an octet like 1-254, * or 1,3,5
+func nmapOctets(o string) []byte {
+	var list []byte
+	for _, item := range strings.Split(o, ",") {
+		from, to := item, item
+		if item == "*" {
+			from, to = "0", "255"
+		} else if i := strings.Index(item, "-"); i >= 0 {
+			from, to = item[:i], item[i+1:]
+			if from == "" {
+				from = "0"
+			}
+			if to == "" {
+				to = "255"
+			}
+		}
+		lo, err1 := strconv.Atoi(from)
+		hi, err2 := strconv.Atoi(to)
+		if err1 != nil || err2 != nil || lo < 0 || hi > 255 || lo > hi {
+			return nil
+		}
+		for n := lo; n <= hi; n++ {
+			list = append(list, byte(n))
+		}
+	}
+	return list
+}
+
+// nmapHost is what the scan finds of a host
+type nmapHost struct {
+	ip               net.IP
+	name, mac        string
+	up, forced       bool
+	route            netRoute
+	latency, elapsed time.Duration
+	ports            []nmapPort
+}
+
+// probe finds if the host is up and the state of its ports, and how long
+// it takes
+func (s *nmapScan) probe(sys honeyos.Sys, ip net.IP, name string) nmapHost {
+	h := nmapHost{ip: ip, name: name, route: netTrace(sys, ip)}
+	self := ip.IsLoopback() || ip.Equal(net.ParseIP(honeyos.IPAddress()))
+	landingHost, landing := sshLookup(sys, ip.String())
+	switch {
+	case self:
+		h.up = true
+	case h.route.local:
+		h.up = h.route.alive
+	default:
+		h.up = h.route.alive && (landing || fnvString(ip.String())%3 != 0)
+	}
+	if h.up {
+		h.latency = h.route.hopRTT(len(h.route.hops))
+	} else if s.noPing {
+		h.up, h.forced = true, true
+	}
+	if h.name == "" && landing && !s.numeric {
+		h.name = landingHost.hostname
+		if ip.IsLoopback() {
+			h.name = "localhost"
+		}
+	}
+	if h.up && !self && h.route.local && isRoot(sys) {
+		h.mac = netMAC(ip)
+	}
+	if !h.up || s.ping || s.list {
+		return h
+	}
+
+	r := rand.New(rand.NewSource(int64(fnvString("nmap " + ip.String()))))
+	internet := map[int]bool{}
+	for _, p := range []int{22, 80, 443} {
+		if r.Intn(3) > 0 {
+			internet[p] = true
+		}
+	}
+	extra := []int{21, 25, 53, 3306, 8080, 8443, 3389, 6379}
+	internet[extra[r.Intn(len(extra))]] = r.Intn(2) == 0
+
+	if s.tcp {
+		for _, p := range s.ports.tcp {
+			port := nmapPort{port: p, proto: "tcp", state: "closed", reasonFor: "reset"}
+			switch {
+			case h.forced:
+				port.state, port.reasonFor = "filtered", "no-response"
+			case self:
+				if nmapListening(sys, "tcp", p, ip.IsLoopback()) {
+					port.state, port.reasonFor = "open", "syn-ack"
+				}
+			case landing:
+				if p == 21 || p == 22 || p == 23 {
+					port.state, port.reasonFor = "open", "syn-ack"
+				}
+			case h.route.local:
+			case internet[p]:
+				port.state, port.reasonFor = "open", "syn-ack"
+			default:
+				port.state, port.reasonFor = "filtered", "no-response"
+			}
+			if s.connect && port.state == "closed" {
+				port.reasonFor = "conn-refused"
+			}
+			h.ports = append(h.ports, port)
+		}
+	}
+	if s.udp {
+		for _, p := range s.ports.udp {
+			port := nmapPort{port: p, proto: "udp", state: "closed", reasonFor: "port-unreach"}
+			switch {
+			case h.forced || !h.route.local && !landing:
+				port.state, port.reasonFor = "open|filtered", "no-response"
+			case self && nmapListening(sys, "udp", p, ip.IsLoopback()):
+				port.state, port.reasonFor = "open|filtered", "no-response"
+			}
+			h.ports = append(h.ports, port)
+		}
+	}
+	for i, port := range h.ports {
+		h.ports[i].service = nmapServices[port.port]
+		if h.ports[i].service == "" {
+			h.ports[i].service = "unknown"
+		}
+		if !s.versions || port.state != "open" {
+			continue
+		}
+		if port.port == 22 && (self || landing) {
+			h.ports[i].service, h.ports[i].version = "ssh", nmapSSHVersion()
+		} else if v, found := nmapVersions[port.port]; found && !self {
+			h.ports[i].service, h.ports[i].version = v[0], v[1]
+		}
+	}
+	h.elapsed = s.cost(h)
+	return h
+}
+
+// nmapListening tells if a socket of the machine listens on the port. Those
+// bound to the loopback only answer the scans of localhost
+func nmapListening(sys honeyos.Sys, proto string, port int, loopback bool) bool {
+	for _, sock := range sys.Sockets() {
+		addr, p := honeyos.SplitAddr(sock.Local)
+		if !strings.HasPrefix(sock.Proto, proto) || p != strconv.Itoa(port) {
+			continue
+		}
+		if proto == "tcp" && sock.State != "LISTEN" {
+			continue
+		}
+		if local := net.ParseIP(addr); local != nil && local.IsLoopback() && !loopback {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// nmapSSHVersion is the sshd version -sV finds in the distribution
+func nmapSSHVersion() string {
+	switch {
+	case pkgFamily() == "rpm":
+		return "OpenSSH 7.4 (protocol 2.0)"
+	case pkgFamily() == "apk":
+		return "OpenSSH 8.8 (protocol 2.0)"
+	case honeyos.Distro() == "debian":
+		return "OpenSSH 7.4p1 Debian 10+deb9u7 (protocol 2.0)"
+	}
+	return "OpenSSH 7.2p2 Ubuntu 4ubuntu2.10 (Ubuntu Linux; protocol 2.0)"
+}
+
+// cost is how long scanning the ports of the host takes. Closed ports answer
+// at once, filtered ones are retried, and the replies to UDP probes are
+// rate limited by the kernel
+func (s *nmapScan) cost(h nmapHost) time.Duration {
+	d := 2*h.latency + 30*time.Millisecond
+	for _, p := range h.ports {
+		switch {
+		case p.proto == "udp" && p.state == "closed":
+			d += time.Second
+		case p.proto == "udp":
+			d += 50 * time.Millisecond
+		case p.state == "filtered":
+			d += 5 * time.Millisecond
+		default:
+			d += 15*time.Microsecond + time.Duration(rand.Int63n(int64(25*time.Microsecond)))
+		}
+	}
+	for _, p := range h.ports {
+		if p.state == "open" && s.versions {
+			d += 6 * time.Second
+			break
+		}
+	}
+	if s.osDetect {
+		d += 2 * time.Second
+	}
+	return time.Duration(float64(d) * s.timing)
+}
+
+func (n nmap) Exec(args []string, sys honeyos.Sys) int {
+	if !loadPkgDB(sys, pkgFamily()).installed("nmap") {
+		return honeyos.CommandNotFound(sys, append([]string{"nmap"}, args...))
+	}
+	if len(args) == 0 {
+		fmt.Fprint(sys.Out(), nmapUsage)
+		return 0
+	}
+	s := nmapScan{timing: 1}
+	var targets, excludes []string
+	var portSpec, listFile string
+	var outputs [][2]string
+	top, fast, aggressive := 0, false, false
+	bad := func(format string, a ...interface{}) int {
+		fmt.Fprintf(sys.Err(), format+"\nSee the output of nmap -h for a summary of options.\n", a...)
+		return 255
+	}
+	quit := func(msg string) int {
+		fmt.Fprintln(sys.Err(), msg+"\nQUITTING!")
+		return 1
+	}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		// value takes the value of the option, either joined or the next arg
+		value := func(opt string) (string, bool) {
+			if len(arg) > len(opt) {
+				return strings.TrimPrefix(arg[len(opt):], "="), true
+			}
+			if i+1 < len(args) {
+				i++
+				return args[i], true
+			}
+			return "", false
+		}
+		name := arg
+		if j := strings.Index(arg, "="); j > 0 && strings.HasPrefix(arg, "--") {
+			name = arg[:j]
+		}
+		switch {
+		case !strings.HasPrefix(arg, "-") || arg == "-":
+			targets = append(targets, arg)
+		case arg == "-h" || arg == "--help":
+			fmt.Fprint(sys.Out(), nmapUsage)
+			return 0
+		case arg == "-V" || arg == "--version":
+			fmt.Fprintf(sys.Out(), "Nmap version %v ( https://nmap.org )\nPlatform: x86_64-pc-linux-gnu\n"+
+				"Compiled with: liblua-5.2.4 openssl-1.0.2g libpcre-8.38 libpcap-1.7.4 nmap-libdnet-1.12 ipv6\n"+
+				"Compiled without:\nAvailable nsock engines: epoll poll select\n", nmapVersion)
+			return 0
+		case strings.HasPrefix(arg, "-s") && len(arg) > 2 && !strings.HasPrefix(arg, "--"):
+			for _, c := range arg[2:] {
+				switch c {
+				case 'S', 'A', 'W', 'M', 'N', 'F', 'X':
+					s.tcp = true
+				case 'T':
+					s.tcp, s.connect = true, true
+				case 'U':
+					s.udp = true
+				case 'V':
+					s.versions = true
+				case 'C':
+				case 'n', 'P':
+					s.ping = true
+				case 'L':
+					s.list = true
+				default:
+					return bad("Scantype %v not supported", string(c))
+				}
+				if strings.ContainsRune("SAWMNFXU", c) && !isRoot(sys) {
+					return quit("You requested a scan type which requires root privileges.")
+				}
+			}
+		case arg == "-A":
+			aggressive = true
+		case arg == "-O":
+			s.osDetect = true
+		case arg == "-Pn" || arg == "-PN" || arg == "-P0":
+			s.noPing = true
+		case strings.HasPrefix(arg, "-P"):
+			// The probes of host discovery do not change what answers
+		case arg == "-n":
+			s.numeric = true
+		case arg == "-R" || arg == "-r" || arg == "-6" || arg == "-f" || arg == "--system-dns":
+		case strings.HasPrefix(arg, "-v") && strings.Trim(arg[1:], "v") == "":
+			s.verbose += len(arg) - 1
+		case strings.HasPrefix(arg, "-d") && strings.Trim(arg[1:], "d") == "":
+		case strings.HasPrefix(arg, "-T") && len(arg) == 3 && arg[2] >= '0' && arg[2] <= '5':
+			s.timing = nmapTimings[arg[2]-'0']
+		case strings.HasPrefix(arg, "-T"):
+			return quit("Unknown timing mode (-T argument).  Use either \"paranoid\", \"sneaky\", \"polite\", \"normal\", \"aggressive\", \"insane\" or a number from 0 (paranoid) to 5 (insane)")
+		case arg == "-F":
+			fast = true
+		case name == "--open":
+			s.openOnly = true
+		case name == "--reason":
+			s.reason = true
+		case name == "--traceroute":
+			s.tracert = true
+		case strings.HasPrefix(arg, "-p"):
+			v, ok := value("-p")
+			if !ok {
+				return bad("nmap: option requires an argument -- 'p'")
+			}
+			portSpec = v
+		case name == "--top-ports":
+			v, ok := value("--top-ports")
+			if top, _ = strconv.Atoi(v); !ok || top <= 0 {
+				return quit("Argument to --top-ports must be a positive integer")
+			}
+		case strings.HasPrefix(arg, "-iL"):
+			if listFile, _ = value("-iL"); listFile == "" {
+				return bad("nmap: option requires an argument -- 'iL'")
+			}
+		case name == "--exclude":
+			v, _ := value("--exclude")
+			excludes = append(excludes, strings.Split(v, ",")...)
+		case strings.HasPrefix(arg, "-o") && len(arg) >= 3 && strings.Contains("NGXA", arg[2:3]):
+			v, ok := value(arg[:3])
+			if !ok {
+				return bad("nmap: option requires an argument -- '%v'", arg[1:3])
+			}
+			outputs = append(outputs, [2]string{arg[2:3], v})
+		case nmapValueOpts[name] || strings.HasPrefix(arg, "-e") || strings.HasPrefix(arg, "-D") ||
+			strings.HasPrefix(arg, "-S") || strings.HasPrefix(arg, "-g"):
+			if name == arg && (len(arg) <= 2 || nmapValueOpts[arg]) {
+				i++
+			}
+		case strings.HasPrefix(arg, "--"):
+			if strings.HasPrefix(arg, "--version-") || strings.HasPrefix(arg, "--osscan-") ||
+				arg == "--packet-trace" || arg == "--append-output" || arg == "--iflist" ||
+				arg == "--send-eth" || arg == "--send-ip" || arg == "--privileged" || arg == "--badsum" {
+				continue
+			}
+			return bad("nmap: unrecognized option '%v'", arg)
+		default:
+			return bad("nmap: invalid option -- '%v'", arg[1:2])
+		}
+	}
+	if aggressive {
+		s.versions, s.osDetect, s.tracert = true, true, true
+	}
+	if s.osDetect && !isRoot(sys) {
+		return quit("TCP/IP fingerprinting (for OS scan) requires root privileges.")
+	}
+	if !s.tcp && !s.udp {
+		s.tcp, s.connect = true, !isRoot(sys)
+	}
+	switch {
+	case portSpec != "":
+		ports, ok := nmapParsePorts(portSpec)
+		if !ok {
+			return quit("Your port specifications are illegal.  Example of proper form: \"-100,200-1024,T:3000-4000,U:60000-\"")
+		}
+		s.ports = ports
+	case fast:
+		s.ports.tcp = nmapCommon(100)
+	case top > 0:
+		s.ports.tcp = nmapCommon(top)
+	default:
+		s.ports.tcp = nmapCommon(1000)
+	}
+	if portSpec == "" {
+		s.ports.udp = s.ports.tcp
+	}
+	if listFile != "" {
+		var data []byte
+		var err error
+		if listFile == "-" {
+			data, err = afero.ReadAll(sys.In())
+		} else {
+			data, err = readFile(sys, absPath(sys, listFile))
+		}
+		if err != nil {
+			return quit(fmt.Sprintf("Failed to open input file %v for reading", listFile))
+		}
+		targets = append(targets, strings.Fields(string(data))...)
+	}
+	return n.scan(sys, &s, args, targets, excludes, outputs)
+}
+
+// scan probes the targets by groups of 256 hosts, printing the reports as
+// the hosts of the group are done
+func (nmap) scan(sys honeyos.Sys, s *nmapScan, args, targets, excludes []string, outputs [][2]string) int {
+	began, start := time.Now(), honeyos.Now(sys)
+	sys.Log().WithFields(log.Fields{"targets": targets, "exclude": excludes, "args": args}).
+		Infof("User scanning %v with nmap", strings.Join(targets, " "))
+	var normal, grepable, xml bytes.Buffer
+	out := io.MultiWriter(sys.Out(), &normal)
+	fmt.Fprintf(sys.Out(), "\nStarting Nmap %v ( https://nmap.org ) at %v\n", nmapVersion, start.Format("2006-01-02 15:04 MST"))
+	initiated := fmt.Sprintf("# Nmap %v scan initiated %v as: nmap %v\n", nmapVersion, start.Format(time.ANSIC), strings.Join(args, " "))
+	normal.WriteString(initiated)
+	grepable.WriteString(initiated)
+	fmt.Fprintf(&xml, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<!DOCTYPE nmaprun>\n"+
+		"<nmaprun scanner=\"nmap\" args=\"nmap %v\" start=\"%v\" startstr=\"%v\" version=\"%v\" xmloutputversion=\"1.04\">\n",
+		html.EscapeString(strings.Join(args, " ")), start.Unix(), start.Format(time.ANSIC), nmapVersion)
+
+	excluded := map[string]bool{}
+	for _, e := range excludes {
+		ips, _, _ := nmapTargets(sys, e, 65536)
+		for _, ip := range ips {
+			excluded[ip.String()] = true
+		}
+	}
+	var ips []net.IP
+	var names []string
+	for _, t := range targets {
+		list, named, ok := nmapTargets(sys, t, 65536-len(ips))
+		if !ok {
+			fmt.Fprintf(sys.Err(), "Failed to resolve \"%v\".\n", t)
+			continue
+		}
+		for i, ip := range list {
+			if excluded[ip.String()] {
+				continue
+			}
+			name := ""
+			if i < len(named) {
+				name = named[i]
+			}
+			ips, names = append(ips, ip), append(names, name)
+		}
+	}
+	if len(ips) == 0 {
+		fmt.Fprintln(out, "WARNING: No targets were specified, so 0 hosts scanned.")
+	}
+
+	var up []string
+	interrupted := false
+	for g := 0; g < len(ips) && !interrupted; g += 256 {
+		var group []nmapHost
+		down := false
+		for i := g; i < len(ips) && i < g+256; i++ {
+			h := s.probe(sys, ips[i], names[i])
+			down = down || !h.up
+			group = append(group, h)
+		}
+		if down && !s.list && !pkgSleep(sys, time.Duration(float64(2*time.Second)*s.timing)) {
+			interrupted = true
+			break
+		}
+		for _, h := range group {
+			if !h.up && !s.list {
+				continue
+			}
+			if s.verbose > 0 && len(h.ports) > 0 {
+				s.verboseScan(sys, out, h, start)
+			}
+			if !pkgSleep(sys, h.elapsed) {
+				interrupted = true
+				break
+			}
+			fmt.Fprint(out, s.report(h))
+			grepable.WriteString(s.grepable(h))
+			xml.WriteString(s.xml(h))
+			if h.up && !s.list {
+				up = append(up, h.ip.String())
+			}
+		}
+	}
+	if interrupted {
+		fmt.Fprintln(sys.Out(), "caught SIGINT signal, cleaning up")
+		return 1
+	}
+	if len(ips) == 1 && len(up) == 0 && !s.list {
+		fmt.Fprintln(out, "Note: Host seems down. If it is really up, but blocking our ping probes, try -Pn")
+	}
+	if detected := map[[2]bool]string{{true, false}: "Service", {false, true}: "OS", {true, true}: "OS and Service"}[[2]bool{
+		s.versions, s.osDetect}]; detected != "" && !s.ping && !s.list && len(up) > 0 {
+		fmt.Fprintf(out, "%v detection performed. Please report any incorrect results at https://nmap.org/submit/ .\n", detected)
+	}
+	took := time.Since(began).Seconds()
+	addresses := "address"
+	if len(ips) != 1 {
+		addresses = "addresses"
+	}
+	summary := fmt.Sprintf("%v IP %v (%v host%v up) scanned in %.2f seconds", len(ips), addresses, len(up), plural(len(up)), took)
+	fmt.Fprintf(sys.Out(), "Nmap done: %v\n", summary)
+	done := fmt.Sprintf("# Nmap done at %v -- %v\n", honeyos.Now(sys).Format(time.ANSIC), summary)
+	normal.WriteString(done)
+	grepable.WriteString(done)
+	fmt.Fprintf(&xml, "<runstats><finished time=\"%v\" timestr=\"%v\" elapsed=\"%.2f\" exit=\"success\"/>"+
+		"<hosts up=\"%v\" down=\"%v\" total=\"%v\"/></runstats>\n</nmaprun>\n",
+		honeyos.Now(sys).Unix(), honeyos.Now(sys).Format(time.ANSIC), took, len(up), len(ips)-len(up), len(ips))
+	sys.Log().WithField("up", up).Infof("nmap found %v hosts up", len(up))
+
+	for _, o := range outputs {
+		files := map[string][]byte{"N": normal.Bytes(), "G": grepable.Bytes(), "X": xml.Bytes()}
+		if o[0] == "A" {
+			files = map[string][]byte{o[1] + ".nmap": files["N"], o[1] + ".gnmap": files["G"], o[1] + ".xml": files["X"]}
+		} else {
+			files = map[string][]byte{o[1]: files[o[0]]}
+		}
+		for name, data := range files {
+			if afero.WriteFile(sys.FSys(), absPath(sys, name), data, 0666&^sys.Umask()) != nil {
+				fmt.Fprintf(sys.Err(), "Failed to open %v output file %v for writing\n", o[0], name)
+				return 1
+			}
+		}
+	}
+	return 0
+}
+
+// title is the host as the reports name it
+func (h nmapHost) title() string {
+	if h.name != "" {
+		return fmt.Sprintf("%v (%v)", h.name, h.ip)
+	}
+	return h.ip.String()
+}
+
+// nmapLatency formats the time to two significant digits like nmap does
+func nmapLatency(d time.Duration) string {
+	sec := d.Seconds()
+	digits := 1 - int(math.Floor(math.Log10(sec)))
+	if digits < 2 {
+		digits = 2
+	}
+	return strconv.FormatFloat(sec, 'f', digits, 64) + "s"
+}
+
+// report is the normal output of the host
+func (s *nmapScan) report(h nmapHost) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Nmap scan report for %v\n", h.title())
+	if s.list {
+		return b.String()
+	}
+	if h.forced {
+		b.WriteString("Host is up.\n")
+	} else {
+		fmt.Fprintf(&b, "Host is up (%v latency).\n", nmapLatency(h.latency))
+	}
+	if len(h.ports) > 0 {
+		s.portTable(&b, h)
+	}
+	if h.mac != "" {
+		fmt.Fprintf(&b, "MAC Address: %v (QEMU virtual NIC)\n", strings.ToUpper(h.mac))
+	}
+	if s.ping {
+		return b.String()
+	}
+	for _, p := range h.ports {
+		if p.version == "" {
+			continue
+		}
+		if strings.Contains(p.version, "Microsoft") {
+			b.WriteString("Service Info: OS: Windows; CPE: cpe:/o:microsoft:windows\n")
+			break
+		} else if strings.Contains(p.version, "Linux") {
+			b.WriteString("Service Info: OS: Linux; CPE: cpe:/o:linux:linux_kernel\n")
+			break
+		}
+	}
+	if s.osDetect && !h.forced {
+		s.osReport(&b, h)
+	}
+	if s.tracert && !h.forced && !h.ip.IsLoopback() && !h.ip.Equal(net.ParseIP(honeyos.IPAddress())) {
+		s.traceReport(&b, h)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// portTable shows the ports by state, leaving out the states with too many
+// ports to list
+func (s *nmapScan) portTable(b *strings.Builder, h nmapHost) {
+	count := map[string]int{}
+	var states []string
+	for _, p := range h.ports {
+		if count[p.state] == 0 {
+			states = append(states, p.state)
+		}
+		count[p.state]++
+	}
+	hidden := map[string]bool{}
+	var notShown []string
+	for _, st := range states {
+		if count[st] > 25 || s.openOnly && !strings.HasPrefix(st, "open") {
+			hidden[st] = true
+			notShown = append(notShown, fmt.Sprintf("%v %v port%v", count[st], st, plural(count[st])))
+		}
+	}
+	if len(states) == 1 && hidden[states[0]] {
+		fmt.Fprintf(b, "All %v scanned ports on %v are %v\n", len(h.ports), h.title(), states[0])
+		return
+	}
+	if len(notShown) > 0 {
+		fmt.Fprintf(b, "Not shown: %v\n", strings.Join(notShown, ", "))
+	}
+	rows := [][]string{{"PORT", "STATE", "SERVICE"}}
+	if s.reason {
+		rows[0] = append(rows[0], "REASON")
+	}
+	if s.versions {
+		rows[0] = append(rows[0], "VERSION")
+	}
+	for _, p := range h.ports {
+		if hidden[p.state] {
+			continue
+		}
+		row := []string{fmt.Sprintf("%v/%v", p.port, p.proto), p.state, p.service}
+		if s.reason {
+			row = append(row, s.portReason(p, h))
+		}
+		if s.versions {
+			row = append(row, p.version)
+		}
+		rows = append(rows, row)
+	}
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, col := range row {
+			if len(col) > widths[i] {
+				widths[i] = len(col)
+			}
+		}
+	}
+	for _, row := range rows {
+		line := ""
+		for i, col := range row {
+			if i == len(row)-1 {
+				line += col
+			} else {
+				line += fmt.Sprintf("%-*v ", widths[i], col)
+			}
+		}
+		fmt.Fprintln(b, strings.TrimRight(line, " "))
+	}
+}
+
+// portReason is why the port is in its state, with the TTL of the answer if
+// the scan sees the packets
+func (s *nmapScan) portReason(p nmapPort, h nmapHost) string {
+	if p.reasonFor == "no-response" || s.connect && p.proto == "tcp" {
+		return p.reasonFor
+	}
+	return fmt.Sprintf("%v ttl %v", p.reasonFor, h.route.ttl)
+}
+
+// osReport guesses the OS of the host from the TTL of its answers
+func (s *nmapScan) osReport(b *strings.Builder, h nmapHost) {
+	distance := len(h.route.hops) + 1
+	switch {
+	case h.ip.IsLoopback() || h.ip.Equal(net.ParseIP(honeyos.IPAddress())):
+		distance = 0
+	case h.route.local:
+		distance = 1
+	}
+	open, closed := false, false
+	for _, p := range h.ports {
+		open = open || p.state == "open"
+		closed = closed || p.state == "closed"
+	}
+	if !open || !closed {
+		b.WriteString("Warning: OSScan results may be unreliable because we could not find at least 1 open and 1 closed port\n")
+	}
+	switch ttl := h.route.ttl + len(h.route.hops); {
+	case distance == 0:
+		b.WriteString("Device type: general purpose\nRunning: Linux 2.6.X\nOS CPE: cpe:/o:linux:linux_kernel:2.6.32\n" +
+			"OS details: Linux 2.6.32\n")
+	case ttl > 128:
+		b.WriteString("Device type: router\nRunning: Cisco IOS 15.X\nOS CPE: cpe:/o:cisco:ios:15.2\nOS details: Cisco IOS 15.2\n")
+	case ttl > 64:
+		b.WriteString("Device type: general purpose\nRunning: Microsoft Windows 2012\n" +
+			"OS CPE: cpe:/o:microsoft:windows_server_2012\nOS details: Microsoft Windows Server 2012\n")
+	default:
+		b.WriteString("Device type: general purpose\nRunning: Linux 3.X|4.X\n" +
+			"OS CPE: cpe:/o:linux:linux_kernel:3 cpe:/o:linux:linux_kernel:4\nOS details: Linux 3.2 - 4.9\n")
+	}
+	fmt.Fprintf(b, "Network Distance: %v hop%v\n", distance, plural(distance))
+}
+
+// traceReport shows the route to the host, probing the first open port
+func (s *nmapScan) traceReport(b *strings.Builder, h nmapHost) {
+	using := "proto 1/icmp"
+	for _, p := range h.ports {
+		if p.state == "open" {
+			using = fmt.Sprintf("port %v/%v", p.port, p.proto)
+			break
+		}
+	}
+	fmt.Fprintf(b, "\nTRACEROUTE (using %v)\nHOP RTT      ADDRESS\n", using)
+	for i, hop := range append(append([]net.IP{}, h.route.hops...), h.ip) {
+		switch {
+		case hop == nil:
+			fmt.Fprintf(b, "%-3v ...\n", i+1)
+		case i == len(h.route.hops):
+			fmt.Fprintf(b, "%-3v %-8v %v\n", i+1, fmt.Sprintf("%.2f ms", h.route.hopRTT(i).Seconds()*1000), h.title())
+		default:
+			fmt.Fprintf(b, "%-3v %-8v %v\n", i+1, fmt.Sprintf("%.2f ms", h.route.hopRTT(i).Seconds()*1000), hop)
+		}
+	}
+}
+
+// verboseScan shows the progress of the port scan of the host
+func (s *nmapScan) verboseScan(sys honeyos.Sys, out io.Writer, h nmapHost, start time.Time) {
+	name := "SYN Stealth Scan"
+	if s.connect {
+		name = "Connect Scan"
+	} else if !s.tcp {
+		name = "UDP Scan"
+	}
+	now := honeyos.Now(sys)
+	fmt.Fprintf(out, "Initiating %v at %v\nScanning %v [%v ports]\n", name, now.Format("15:04"), h.title(), len(h.ports))
+	for _, p := range h.ports {
+		if p.state == "open" {
+			fmt.Fprintf(out, "Discovered open port %v/%v on %v\n", p.port, p.proto, h.ip)
+		}
+	}
+	fmt.Fprintf(out, "Completed %v at %v, %.2fs elapsed (%v total ports)\n", name, now.Add(h.elapsed).Format("15:04"),
+		h.elapsed.Seconds(), len(h.ports))
+}
+
+// grepable is the line of the host in the output of -oG
+func (s *nmapScan) grepable(h nmapHost) string {
+	host := fmt.Sprintf("Host: %v (%v)", h.ip, h.name)
+	if s.list {
+		return host + "\tStatus: Unknown\n"
+	}
+	line := host + "\tStatus: Up\n"
+	if len(h.ports) == 0 {
+		return line
+	}
+	ignored, most := h.ignored()
+	var ports []string
+	for _, p := range h.ports {
+		if p.state != ignored {
+			ports = append(ports, fmt.Sprintf("%v/%v/%v//%v//%v/", p.port, p.state, p.proto, p.service, p.version))
+		}
+	}
+	line += host + "\tPorts: " + strings.Join(ports, ", ")
+	if ignored != "" {
+		line += fmt.Sprintf("\tIgnored State: %v (%v)", ignored, most)
+	}
+	return line + "\n"
+}
+
+// xml is the host element of the output of -oX
+func (s *nmapScan) xml(h nmapHost) string {
+	var b strings.Builder
+	state := "up"
+	if s.list {
+		state = "unknown"
+	}
+	fmt.Fprintf(&b, "<host><status state=\"%v\" reason=\"%v\"/>\n<address addr=\"%v\" addrtype=\"ipv4\"/>\n", state,
+		map[bool]string{true: "user-set", false: "syn-ack"}[h.forced || s.list], h.ip)
+	if h.mac != "" {
+		fmt.Fprintf(&b, "<address addr=\"%v\" addrtype=\"mac\" vendor=\"QEMU virtual NIC\"/>\n", strings.ToUpper(h.mac))
+	}
+	if h.name != "" {
+		fmt.Fprintf(&b, "<hostnames>\n<hostname name=\"%v\" type=\"user\"/>\n</hostnames>\n", html.EscapeString(h.name))
+	}
+	if len(h.ports) > 0 {
+		ignored, most := h.ignored()
+		b.WriteString("<ports>")
+		if ignored != "" {
+			fmt.Fprintf(&b, "<extraports state=\"%v\" count=\"%v\"/>\n", ignored, most)
+		}
+		for _, p := range h.ports {
+			if p.state == ignored {
+				continue
+			}
+			fmt.Fprintf(&b, "<port protocol=\"%v\" portid=\"%v\"><state state=\"%v\" reason=\"%v\" reason_ttl=\"%v\"/>"+
+				"<service name=\"%v\" method=\"table\" conf=\"3\"/></port>\n", p.proto, p.port, p.state, p.reasonFor,
+				h.route.ttl, p.service)
+		}
+		b.WriteString("</ports>\n")
+	}
+	b.WriteString("</host>\n")
+	return b.String()
+}
+
+// ignored is the state of most ports of the host, which the grepable and
+// XML outputs count instead of listing
+func (h nmapHost) ignored() (state string, count int) {
+	counts := map[string]int{}
+	for _, p := range h.ports {
+		counts[p.state]++
+	}
+	for st, n := range counts {
+		if n > 25 && n > count {
+			state, count = st, n
+		}
+	}
+	return state, count
+}
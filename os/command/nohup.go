@@ -0,0 +1,121 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+)
+
+// nohup runs the command ignoring the hangup, so it keeps running after the
+// session closes. Output to the terminal goes to nohup.out instead
+type nohup struct{}
+
+func init() {
+	honeyos.RegisterCommand("nohup", nohup{})
+}
+
+func (nohup) GetHelp() string {
+	return `Usage: nohup COMMAND [ARG]...
+  or:  nohup OPTION
+Run COMMAND, ignoring hangup signals.
+
+      --help     display this help and exit
+      --version  output version information and exit
+
+If standard input is a terminal, redirect it from an unreadable file.
+If standard output is a terminal, append output to 'nohup.out' if possible,
+'$HOME/nohup.out' otherwise.
+If standard error is a terminal, redirect it to standard output.
+To save output to FILE, use 'nohup COMMAND > FILE'.
+
+NOTE: your shell may have its own version of nohup, which usually supersedes
+the version described here.  Please refer to your shell's documentation
+for details about the options it supports.
+
+GNU coreutils online help: <http://www.gnu.org/software/coreutils/>
+Full documentation at: <http://www.gnu.org/software/coreutils/nohup>
+or available locally via: info '(coreutils) nohup invocation'
+`
+}
+
+func (nohup) Where() string {
+	return "/usr/bin/nohup"
+}
+
+func (n nohup) Exec(args []string, sys honeyos.Sys) int {
+	if len(args) > 0 {
+		switch arg := args[0]; {
+		case arg == "--help":
+			fmt.Fprint(sys.Out(), n.GetHelp())
+			return 0
+		case arg == "--version":
+			fmt.Fprintln(sys.Out(), "nohup (GNU coreutils) 8.25")
+			return 0
+		case arg == "--":
+			args = args[1:]
+		case strings.HasPrefix(arg, "--"):
+			fmt.Fprintf(sys.Err(), "nohup: unrecognized option '%v'\nTry 'nohup --help' for more information.\n", arg)
+			return 125
+		case strings.HasPrefix(arg, "-") && arg != "-":
+			fmt.Fprintf(sys.Err(), "nohup: invalid option -- '%c'\nTry 'nohup --help' for more information.\n", arg[1])
+			return 125
+		}
+	}
+	if len(args) == 0 {
+		fmt.Fprintln(sys.Err(), "nohup: missing operand\nTry 'nohup --help' for more information.")
+		return 125
+	}
+
+	cred := honeyos.Credential{UID: sys.CurrentUser(), NoHangup: true}
+	ignoreInput := honeyos.IsTerminal(sys.In())
+	if ignoreInput {
+		cred.Stdin = strings.NewReader("")
+	}
+	var msg string
+	switch {
+	case honeyos.IsTerminal(sys.Out()):
+		// nohup.out is in the working directory, or the home if that can't be
+		// written to
+		name := "nohup.out"
+		f, err := sys.FSys().OpenFile(absPath(sys, name), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			name = path.Join(honeyos.Getenv(sys, "HOME"), "nohup.out")
+			if f, err = sys.FSys().OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600); err != nil {
+				fmt.Fprintf(sys.Err(), "nohup: failed to open '%v': Permission denied\n", name)
+				return 125
+			}
+		}
+		defer f.Close()
+		cred.Stdout = f
+		if honeyos.IsTerminal(sys.Err()) {
+			cred.Stderr = f
+		}
+		msg = fmt.Sprintf("appending output to '%v'", name)
+	case honeyos.IsTerminal(sys.Err()):
+		cred.Stderr = sys.Out()
+		msg = "redirecting stderr to stdout"
+	}
+	switch {
+	case ignoreInput && msg != "":
+		fmt.Fprintf(sys.Err(), "nohup: ignoring input and %v\n", msg)
+	case ignoreInput:
+		fmt.Fprintln(sys.Err(), "nohup: ignoring input")
+	case msg != "":
+		fmt.Fprintf(sys.Err(), "nohup: %v\n", msg)
+	}
+	sys.Log().WithField("cmd", strings.Join(args, " ")).Infof("User ran %v with nohup", args[0])
+	status, found := honeyos.RunAs(sys, cred, args)
+	if !found {
+		// The error goes where stderr was redirected
+		errOut := sys.Err()
+		if cred.Stderr != nil {
+			errOut = cred.Stderr
+		}
+		fmt.Fprintf(errOut, "nohup: failed to run command '%v': No such file or directory\n", args[0])
+		return 127
+	}
+	return status
+}
@@ -0,0 +1,26 @@
+package command
+
+import "testing"
+
+func TestNohupSetsid(t *testing.T) {
+	tests := []struct {
+		cmd, expect string
+		status      int
+	}{
+		{"nohup true", "", 0},
+		{"nohup false", "", 1},
+		{"nohup /bin/true", "", 0},
+		{"nohup nonexistent", "nohup: failed to run command 'nonexistent': No such file or directory\n", 127},
+		// Builtins without an executable can't be run
+		{"nohup cd", "nohup: failed to run command 'cd': No such file or directory\n", 127},
+		{"setsid true", "", 0},
+		{"setsid -w false", "", 1},
+		{"setsid nonexistent", "setsid: failed to execute nonexistent: No such file or directory\n", 1},
+		{"setsid -w nonexistent", "setsid: failed to execute nonexistent: No such file or directory\n", 1},
+	}
+	for _, tt := range tests {
+		if out, status := execShell(tt.cmd); out != tt.expect || status != tt.status {
+			t.Errorf("%v, expect %q with status %v, got %q with status %v", tt.cmd, tt.expect, tt.status, out, status)
+		}
+	}
+}
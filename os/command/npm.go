@@ -0,0 +1,347 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/afero"
+)
+
+// npm installs the Node.js packages to node_modules of the working
+// directory, or of /usr/local/lib with -g. The packages requested are
+// logged
+type npm struct{}
+
+// npmBuild is the npm of the distribution, and the node it runs on
+type npmBuild struct {
+	version, node string
+}
+
+var npmBuilds = map[string]npmBuild{
+	"deb": {"3.5.2", "v4.2.6"},
+	"rpm": {"6.14.11", "v10.24.0"},
+	"apk": {"7.17.0", "v14.20.0"},
+}
+
+const npmUsage = `
+Usage: npm <command>
+
+where <command> is one of:
+    access, add-user, adduser, apihelp, author, bin, bugs, c,
+    cache, completion, config, ddp, dedupe, deprecate, dist-tag,
+    dist-tags, docs, edit, explore, faq, find, find-dupes, get,
+    help, help-search, home, i, info, init, install,
+    install-test, issues, it, la, link, list, ll, ln, login,
+    logout, ls, outdated, owner, pack, ping, prefix, prune,
+    publish, r, rb, rebuild, remove, repo, restart, rm, root,
+    run, run-script, s, se, search, set, show, shrinkwrap, star,
+    stars, start, stop, t, tag, team, test, tst, un, uninstall,
+    unlink, unpublish, unstar, up, update, upgrade, v, verison,
+    version, view, whoami
+
+npm <cmd> -h     quick help on <cmd>
+npm -l           display full usage info
+npm help <term>  search for help on <term>
+npm help npm     involved overview
+
+Specify configs in the ini-formatted file:
+    %v/.npmrc
+or on the command line via: npm <command> --key value
+Config info can be viewed via: npm help config
+
+npm@%v /usr/share/npm
+`
+
+// npmGlobal is where npm -g installs
+const npmGlobal = "/usr/local/lib"
+
+func init() {
+	honeyos.RegisterCommand("npm", npm{})
+}
+
+func (npm) GetHelp() string {
+	return fmt.Sprintf(npmUsage, "~", npmBuilds["deb"].version)
+}
+
+func (npm) Where() string {
+	return "/usr/bin/npm"
+}
+
+func (n npm) Exec(args []string, sys honeyos.Sys) int {
+	if !loadPkgDB(sys, pkgFamily()).installed("npm") {
+		return honeyos.CommandNotFound(sys, append([]string{"npm"}, args...))
+	}
+	build, ok := npmBuilds[pkgFamily()]
+	if !ok {
+		build = npmBuilds["deb"]
+	}
+	var cmd string
+	var names []string
+	global, silent := false, false
+	for _, arg := range args {
+		switch {
+		case arg == "-v" || arg == "--version":
+			if cmd == "" {
+				fmt.Fprintln(sys.Out(), build.version)
+				return 0
+			}
+		case arg == "-g" || arg == "--global":
+			global = true
+		case arg == "-s" || arg == "--silent" || arg == "--quiet":
+			silent = true
+		case strings.HasPrefix(arg, "-"):
+		case cmd == "":
+			cmd = arg
+		default:
+			names = append(names, arg)
+		}
+	}
+	prefix := sys.Getcwd()
+	if global {
+		prefix = npmGlobal
+	}
+	switch cmd {
+	case "install", "i", "add", "isntall":
+		return n.install(sys, build, args, names, prefix, global, silent)
+	case "uninstall", "un", "remove", "rm", "r", "unlink":
+		return n.uninstall(sys, build, args, names, prefix, global)
+	case "ls", "list", "la", "ll":
+		n.list(sys, prefix)
+		return 0
+	case "root":
+		fmt.Fprintln(sys.Out(), prefix+"/node_modules")
+		return 0
+	case "prefix":
+		fmt.Fprintln(sys.Out(), prefix)
+		return 0
+	}
+	fmt.Fprintf(sys.Out(), npmUsage, honeyos.GetUserByID(sys.CurrentUser()).Homedir, build.version)
+	return 1
+}
+
+// npmSpec splits the package like express@4.17.1 or @types/node@14 to the
+// name and version. The name of git repositories and tarballs is the base
+// name
+func npmSpec(spec string) (name, version string) {
+	if strings.Contains(spec, "://") || strings.HasPrefix(spec, "git+") || strings.HasSuffix(spec, ".tgz") {
+		base := path.Base(strings.TrimSuffix(strings.SplitN(spec, "#", 2)[0], "/"))
+		return strings.TrimSuffix(strings.TrimSuffix(base, ".git"), ".tgz"), ""
+	}
+	if i := strings.LastIndex(spec, "@"); i > 0 {
+		version = strings.TrimLeft(spec[i+1:], "^~=v")
+		if version == "latest" || strings.ContainsAny(version, "<>*x ") {
+			version = ""
+		}
+		return spec[:i], version
+	}
+	return spec, ""
+}
+
+// npmManifest are the dependencies of package.json in the directory, or
+// nil if there is none
+func npmManifest(sys honeyos.Sys, dir string) map[string]string {
+	data, err := readFile(sys, dir+"/package.json")
+	if err != nil {
+		return nil
+	}
+	var manifest struct {
+		Dependencies    map[string]string `json:"dependencies"`
+		DevDependencies map[string]string `json:"devDependencies"`
+	}
+	json.Unmarshal(data, &manifest)
+	deps := map[string]string{}
+	for _, m := range []map[string]string{manifest.Dependencies, manifest.DevDependencies} {
+		for name, version := range m {
+			deps[name] = version
+		}
+	}
+	return deps
+}
+
+// npmInstalled are the packages in node_modules of the prefix
+func npmInstalled(sys honeyos.Sys, prefix string) map[string]string {
+	installed := map[string]string{}
+	infos, _ := afero.ReadDir(sys.FSys(), prefix+"/node_modules")
+	for _, fi := range infos {
+		var manifest struct{ Name, Version string }
+		data, err := readFile(sys, prefix+"/node_modules/"+fi.Name()+"/package.json")
+		if err == nil && json.Unmarshal(data, &manifest) == nil && manifest.Name != "" {
+			installed[manifest.Name] = manifest.Version
+		}
+	}
+	return installed
+}
+
+// npmDenied is the error of npm writing to the directory of root
+func npmDenied(sys honeyos.Sys, build npmBuild, args []string, dir string) int {
+	if build.version == npmBuilds["deb"].version {
+		fmt.Fprintf(sys.Err(), "npm ERR! Linux %v\nnpm ERR! argv \"/usr/bin/nodejs\" \"/usr/bin/npm\" \"%v\"\n"+
+			"npm ERR! node %v\nnpm ERR! npm  v%v\n", honeyos.KernelRelease(), strings.Join(args, "\" \""), build.node, build.version)
+	}
+	fmt.Fprintf(sys.Err(), "npm ERR! path %[1]v\nnpm ERR! code EACCES\nnpm ERR! errno -13\nnpm ERR! syscall access\n\n"+
+		"npm ERR! Error: EACCES: permission denied, access '%[1]v'\n"+
+		"npm ERR! \nnpm ERR! Please try running this command again as root/Administrator.\n\n"+
+		"npm ERR! Please include the following file with any support request:\nnpm ERR!     %[2]v/npm-debug.log\n",
+		dir, sys.Getcwd())
+	return 243
+}
+
+func (n npm) install(sys honeyos.Sys, build npmBuild, args, specs []string, prefix string, global, silent bool) int {
+	manifest := npmManifest(sys, prefix)
+	if len(specs) == 0 && !global {
+		for name, version := range manifest {
+			specs = append(specs, name+"@"+version)
+		}
+		sort.Strings(specs)
+	}
+	logPkgRequest(sys, "npm", "install", specs)
+	if global && !isRoot(sys) {
+		return npmDenied(sys, build, args, prefix+"/node_modules")
+	}
+	var names []string
+	versions, requested := map[string]string{}, map[string]bool{}
+	for _, spec := range specs {
+		name, version := npmSpec(spec)
+		names = append(names, name)
+		versions[strings.ToLower(name)], requested[name] = version, true
+	}
+	installed := npmInstalled(sys, prefix)
+	pkgs := langResolve(npmCatalog, names, versions)
+	added := map[string]bool{}
+	for _, p := range pkgs {
+		// Dependencies already there are kept, the packages requested are
+		// installed again
+		if _, ok := installed[p.name]; ok && !requested[p.name] {
+			continue
+		}
+		if !langDownload(sys, p) {
+			return 130
+		}
+		if p.native {
+			fmt.Fprintf(sys.Out(), "\n> %v@%v install %v/node_modules/%v\n> node-gyp rebuild\n\n", p.name, p.version, prefix, p.name)
+			if !langBuild(sys, p) {
+				return 130
+			}
+		}
+		langWrite(sys, fmt.Sprintf("%v/node_modules/%v/package.json", prefix, p.name),
+			fmt.Sprintf("{\n  \"name\": \"%v\",\n  \"version\": \"%v\"\n}\n", p.name, p.version))
+		added[p.name] = true
+	}
+	if silent {
+		return 0
+	}
+	out := sys.Out()
+	switch {
+	case build.version == npmBuilds["deb"].version:
+		fmt.Fprintln(out, prefix)
+		var top []langPkg
+		for _, p := range pkgs {
+			if added[p.name] && requested[p.name] {
+				top = append(top, p)
+			}
+		}
+		for i, p := range top {
+			deps := npmDeps(pkgs, p, added)
+			branch, indent := "├─", "│ "
+			if i == len(top)-1 {
+				branch, indent = "└─", "  "
+			}
+			if len(deps) == 0 {
+				fmt.Fprintf(out, "%v─ %v@%v \n", branch, p.name, p.version)
+				continue
+			}
+			fmt.Fprintf(out, "%v┬ %v@%v \n", branch, p.name, p.version)
+			for j, d := range deps {
+				leaf := "├──"
+				if j == len(deps)-1 {
+					leaf = "└──"
+				}
+				fmt.Fprintf(out, "%v%v %v@%v \n", indent, leaf, d.name, d.version)
+			}
+		}
+		if !global && manifest == nil {
+			fmt.Fprintln(out)
+			fmt.Fprintf(sys.Err(), "npm WARN enoent ENOENT: no such file or directory, open '%v/package.json'\n"+
+				"npm WARN %[2]v No description\nnpm WARN %[2]v No repository field.\nnpm WARN %[2]v No README data\n"+
+				"npm WARN %[2]v No license field.\n", prefix, path.Base(prefix))
+		}
+	case build.version == npmBuilds["rpm"].version:
+		for _, p := range pkgs {
+			if !requested[p.name] {
+				continue
+			}
+			fmt.Fprintf(out, "+ %v@%v\n", p.name, p.version)
+		}
+		fmt.Fprintf(out, "added %v package%v from %v contributor%v and audited %v package%v in %.3fs\nfound 0 vulnerabilities\n\n",
+			len(added), plural(len(added)), len(added), plural(len(added)), len(added), plural(len(added)),
+			float64(len(added))*0.4+0.6)
+	default:
+		fmt.Fprintf(out, "\nadded %v package%v, and audited %v package%v in %vs\n\nfound 0 vulnerabilities\n",
+			len(added), plural(len(added)), len(added)+1, plural(len(added)+1), 1+len(added)/3)
+	}
+	return 0
+}
+
+// npmDeps are the packages added with the package
+func npmDeps(pkgs []langPkg, top langPkg, added map[string]bool) []langPkg {
+	var deps []langPkg
+	seen := map[string]bool{top.name: true}
+	queue := append([]string{}, top.deps...)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, p := range pkgs {
+			if p.name == name && !seen[name] && added[name] {
+				seen[name] = true
+				deps = append(deps, p)
+				queue = append(queue, p.deps...)
+			}
+		}
+	}
+	sort.Slice(deps, func(i, j int) bool { return deps[i].name < deps[j].name })
+	return deps
+}
+
+func (n npm) uninstall(sys honeyos.Sys, build npmBuild, args, names []string, prefix string, global bool) int {
+	logPkgRequest(sys, "npm", "uninstall", names)
+	if global && !isRoot(sys) {
+		return npmDenied(sys, build, args, prefix+"/node_modules")
+	}
+	installed := npmInstalled(sys, prefix)
+	for _, spec := range names {
+		name, _ := npmSpec(spec)
+		version, ok := installed[name]
+		if !ok {
+			continue
+		}
+		sys.FSys().RemoveAll(prefix + "/node_modules/" + name)
+		if build.version == npmBuilds["deb"].version {
+			fmt.Fprintf(sys.Out(), "- %v@%v node_modules/%v\n", name, version, name)
+		}
+	}
+	return 0
+}
+
+// list shows the packages in node_modules like npm ls does without the
+// tree of dependencies
+func (n npm) list(sys honeyos.Sys, prefix string) {
+	installed := npmInstalled(sys, prefix)
+	fmt.Fprintln(sys.Out(), prefix)
+	if len(installed) == 0 {
+		fmt.Fprint(sys.Out(), "└── (empty)\n\n")
+		return
+	}
+	names := langSorted(installed)
+	for i, name := range names {
+		branch := "├──"
+		if i == len(names)-1 {
+			branch = "└──"
+		}
+		fmt.Fprintf(sys.Out(), "%v %v@%v\n", branch, name, installed[name])
+	}
+	fmt.Fprintln(sys.Out())
+}
@@ -0,0 +1,212 @@
+package command
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+)
+
+// nslookup queries the fake DNS, by the arguments or interactively at its
+// prompt. Alpine has the applet of busybox without bind-tools
+type nslookup struct{}
+
+// nslookupSession keeps the server and type set at the prompt
+type nslookupSession struct {
+	sys          honeyos.Sys
+	server, kind string
+	busybox      bool
+}
+
+func init() {
+	honeyos.RegisterCommand("nslookup", nslookup{})
+}
+
+func (nslookup) GetHelp() string {
+	return "Usage: nslookup [-opt ...] [HOST] [SERVER]\n"
+}
+
+func (nslookup) Where() string {
+	return "/usr/bin/nslookup"
+}
+
+func (n nslookup) Exec(args []string, sys honeyos.Sys) int {
+	s := &nslookupSession{sys: sys, server: dnsServer(), kind: "A"}
+	if !dnsTools(sys) {
+		if pkgFamily() != "apk" {
+			return honeyos.CommandNotFound(sys, append([]string{"nslookup"}, args...))
+		}
+		s.busybox = true
+	}
+	var rest []string
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			rest = append(rest, arg)
+			continue
+		}
+		if !s.set(strings.TrimPrefix(arg, "-")) {
+			fmt.Fprintf(sys.Err(), "*** Invalid option: %v\n", strings.TrimPrefix(arg, "-"))
+			return 1
+		}
+	}
+	if len(rest) > 1 {
+		if rest[1] != "-" {
+			s.server = rest[1]
+		}
+	}
+	if net.ParseIP(s.server) == nil && netResolve(sys, s.server) == nil {
+		fmt.Fprintf(sys.Err(), "nslookup: couldn't get address for '%v': not found\n", s.server)
+		return 1
+	}
+	if len(rest) == 0 || rest[0] == "-" {
+		return s.interactive()
+	}
+	if !s.lookup(rest[0]) {
+		return 1
+	}
+	return 0
+}
+
+// set takes the option given as -opt or set opt at the prompt
+func (s *nslookupSession) set(opt string) bool {
+	name, value := opt, ""
+	if i := strings.IndexByte(opt, '='); i >= 0 {
+		name, value = opt[:i], opt[i+1:]
+	}
+	switch strings.ToLower(name) {
+	case "type", "ty", "querytype", "query", "q":
+		t, ok := dnsType(value)
+		if !ok {
+			return false
+		}
+		s.kind = t
+	case "debug", "nodebug", "d2", "nod2", "recurse", "norecurse", "search", "nosearch", "vc", "novc",
+		"timeout", "retry", "port", "domain", "class", "all":
+	default:
+		return false
+	}
+	return true
+}
+
+// interactive reads the names and commands at the prompt of nslookup
+func (s *nslookupSession) interactive() int {
+	sys := s.sys
+	for {
+		fmt.Fprint(sys.Out(), "> ")
+		line, ok := readLine(sys.In())
+		if !ok {
+			fmt.Fprintln(sys.Out())
+			return 0
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToLower(fields[0]) {
+		case "exit":
+			return 0
+		case "server", "lserver":
+			if len(fields) < 2 {
+				fmt.Fprintln(sys.Out(), "*** Invalid option: server")
+				continue
+			}
+			ip := net.ParseIP(fields[1])
+			if ip == nil {
+				ip = netResolve(sys, fields[1])
+			}
+			if ip == nil {
+				fmt.Fprintf(sys.Out(), "*** Can't find address for server %v: NXDOMAIN\n", fields[1])
+				continue
+			}
+			s.server = fields[1]
+			fmt.Fprintf(sys.Out(), "Default server: %v\nAddress: %v#53\n", fields[1], ip)
+		case "set":
+			if len(fields) < 2 || !s.set(fields[1]) {
+				fmt.Fprintf(sys.Out(), "*** Invalid option: %v\n", strings.Join(fields[1:], " "))
+			}
+		default:
+			server := s.server
+			if len(fields) > 1 {
+				s.server = fields[1]
+			}
+			s.lookup(fields[0])
+			s.server = server
+		}
+	}
+}
+
+// lookup asks the server for the name, an address being looked up for its
+// name. It returns false if no answer was found
+func (s *nslookupSession) lookup(name string) bool {
+	sys := s.sys
+	out := sys.Out()
+	kinds := []string{s.kind}
+	if ip := net.ParseIP(name); ip != nil && s.kind == "A" {
+		name, kinds = dnsReverse(ip), []string{"PTR"}
+	} else if s.kind == "A" && (s.busybox || honeyos.Distro() == "alpine" || honeyos.Distro() == "centos") {
+		// Newer versions ask for both addresses
+		kinds = append(kinds, "AAAA")
+	}
+	ip := s.server
+	if addr := net.ParseIP(s.server); addr == nil {
+		ip = netResolve(sys, s.server).String()
+	}
+	port := "#53"
+	if s.busybox {
+		port = ":53"
+	}
+	var answers []dnsAnswer
+	for _, kind := range kinds {
+		answer := dnsQuery(sys, "nslookup", s.server, name, kind)
+		if answer.status == "timeout" {
+			if !pkgSleep(sys, 15*time.Second) {
+				return false
+			}
+			fmt.Fprintln(out, ";; connection timed out; no servers could be reached")
+			fmt.Fprintln(out)
+			return false
+		}
+		answers = append(answers, answer)
+	}
+	fmt.Fprintf(out, "Server:\t\t%v\nAddress:\t%v%v\n\n", s.server, ip, port)
+
+	found := false
+	for _, answer := range answers {
+		if answer.status != "NOERROR" {
+			fmt.Fprintf(out, "** server can't find %v: %v\n\n", strings.TrimSuffix(name, "."), answer.status)
+			return false
+		}
+		if len(answer.records) == 0 {
+			continue
+		}
+		if !found && !answer.authoritative {
+			fmt.Fprintln(out, "Non-authoritative answer:")
+		}
+		found = true
+		for _, r := range answer.records {
+			switch r.kind {
+			case "A", "AAAA":
+				fmt.Fprintf(out, "Name:\t%v\nAddress: %v\n", r.name, r.value)
+			case "CNAME":
+				fmt.Fprintf(out, "%v\tcanonical name = %v\n", r.name, r.value)
+			case "MX":
+				fmt.Fprintf(out, "%v\tmail exchanger = %v\n", r.name, r.value)
+			case "TXT":
+				fmt.Fprintf(out, "%v\ttext = %v\n", r.name, r.value)
+			case "NS":
+				fmt.Fprintf(out, "%v\tnameserver = %v\n", r.name, r.value)
+			case "PTR":
+				fmt.Fprintf(out, "%v\tname = %v\n", r.name, r.value)
+			default:
+				fmt.Fprintf(out, "%v\t%v = %v\n", r.name, strings.ToLower(r.kind), r.value)
+			}
+		}
+	}
+	if !found {
+		fmt.Fprintf(out, "*** Can't find %v: No answer\n", strings.TrimSuffix(name, "."))
+	}
+	fmt.Fprintln(out)
+	return found
+}
@@ -0,0 +1,1226 @@
+package command
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/md5"
+	crand "crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// openssl runs the commands of OpenSSL attackers use: enc to encrypt the data
+// they take out, dgst, rand, and s_client to test TLS endpoints. Encryption
+// is real, so the data can be decrypted elsewhere, and the passphrases and
+// keys are logged with the data encrypted. s_client connects to the fake
+// network, capturing what is sent
+type openssl struct{}
+
+// opensslRelease is the version of OpenSSL of the distribution. v11 is set
+// from 1.1.0 on, and v111 from 1.1.1 on
+type opensslRelease struct {
+	version   string
+	v11, v111 bool
+}
+
+// opensslCipher is a cipher of enc, with the mode of the block cipher
+type opensslCipher struct {
+	keyLen, ivLen int
+	block         func(key []byte) (cipher.Block, error)
+	mode          string
+}
+
+var (
+	opensslCiphers = map[string]opensslCipher{
+		"des-cbc":      {8, 8, des.NewCipher, "cbc"},
+		"des-ecb":      {8, 0, des.NewCipher, "ecb"},
+		"des-ede3-cbc": {24, 8, des.NewTripleDESCipher, "cbc"},
+		"des-ede3":     {24, 0, des.NewTripleDESCipher, "ecb"},
+	}
+	// opensslAliases are the short names of the ciphers
+	opensslAliases = map[string]string{
+		"aes128": "aes-128-cbc", "aes192": "aes-192-cbc", "aes256": "aes-256-cbc", "des": "des-cbc",
+		"des3": "des-ede3-cbc",
+	}
+	opensslDigests = map[string]struct {
+		hash func() hash.Hash
+		id   crypto.Hash
+	}{
+		"md5": {md5.New, crypto.MD5}, "sha1": {sha1.New, crypto.SHA1}, "sha224": {sha256.New224, crypto.SHA224},
+		"sha256": {sha256.New, crypto.SHA256}, "sha384": {sha512.New384, crypto.SHA384},
+		"sha512": {sha512.New, crypto.SHA512},
+	}
+)
+
+const opensslStandardCommands = `asn1parse ca ciphers cms crl crl2pkcs7 dgst dh dhparam dsa dsaparam ec ecparam enc engine errstr
+gendh gendsa genpkey genrsa nseq ocsp passwd pkcs12 pkcs7 pkcs8 pkey pkeyparam pkeyutl prime rand req rsa rsautl
+s_client s_server s_time sess_id smime speed spkac srp ts verify version x509`
+
+const opensslEncUsage = `options are
+-in <file>     input file
+-out <file>    output file
+-pass <arg>    pass phrase source
+-e             encrypt
+-d             decrypt
+-a/-base64     base64 encode/decode, depending on encryption flag
+-k             passphrase is the next argument
+-kfile         passphrase is the first line of the file argument
+-md            the next argument is the md to use to create a key
+                 from a passphrase. See openssl dgst -h for list.
+-S             salt in hex is the next argument
+-K/-iv         key/iv in hex is the next argument
+-[pP]          print the iv/key (then exit if -P)
+-bufsize <n>   buffer size
+-nopad         disable standard block padding
+-engine e      use engine e, possibly a hardware device.
+Cipher Types
+`
+
+func init() {
+	honeyos.RegisterCommand("openssl", openssl{})
+	for _, mode := range []string{"cbc", "ecb", "ctr", "cfb", "ofb"} {
+		for _, bits := range []int{128, 192, 256} {
+			ivLen := aes.BlockSize
+			if mode == "ecb" {
+				ivLen = 0
+			}
+			opensslCiphers[fmt.Sprintf("aes-%v-%v", bits, mode)] = opensslCipher{bits / 8, ivLen, aes.NewCipher, mode}
+		}
+	}
+}
+
+func (openssl) GetHelp() string {
+	return "Usage: openssl command [ command_opts ] [ command_args ]\n"
+}
+
+func (openssl) Where() string {
+	return "/usr/bin/openssl"
+}
+
+// opensslVersion is the release of OpenSSL the distribution comes with
+func opensslVersion() opensslRelease {
+	switch honeyos.Distro() {
+	case "debian":
+		return opensslRelease{"OpenSSL 1.1.0l  10 Sep 2019", true, false}
+	case "centos":
+		return opensslRelease{"OpenSSL 1.0.2k-fips  26 Jan 2017", false, false}
+	case "alpine":
+		return opensslRelease{"OpenSSL 1.1.1l  24 Aug 2021", true, true}
+	}
+	return opensslRelease{"OpenSSL 1.0.2g  1 Mar 2016", false, false}
+}
+
+// opensslError prints the errors from the error queue of OpenSSL, which are
+// prefixed by the thread ID
+func opensslError(sys honeyos.Sys, errs ...string) {
+	tid := 140000000000000 + fnvString(honeyos.BootID())%700000000000
+	for _, e := range errs {
+		fmt.Fprintf(sys.Err(), "%v:error:%v\n", tid, e)
+	}
+}
+
+// badOption reports the unknown option of the command the way the release
+// does, with the usage for 1.0.2
+func (openssl) badOption(sys honeyos.Sys, cmd, opt, usage string) int {
+	if opensslVersion().v11 {
+		fmt.Fprintf(sys.Err(), "%v: Unrecognized flag %v\n%v: Use -help for summary.\n", cmd, strings.TrimLeft(opt, "-"), cmd)
+		return 1
+	}
+	fmt.Fprintf(sys.Err(), "unknown option '%v'\n%v", opt, usage)
+	return 1
+}
+
+// readInput reads the file given by -in, or stdin if none
+func (openssl) readInput(sys honeyos.Sys, name string) ([]byte, bool) {
+	if name == "" || name == "-" {
+		data, _ := ioutil.ReadAll(sys.In())
+		return data, true
+	}
+	data, err := readFile(sys, absPath(sys, name))
+	if err == nil {
+		return data, true
+	}
+	reason, code := "No such file or directory", "02001002"
+	if os.IsPermission(err) {
+		reason, code = "Permission denied", "0200100D"
+	}
+	if opensslVersion().v11 {
+		fmt.Fprintf(sys.Err(), "Can't open %v for reading, %v\n", name, reason)
+		opensslError(sys, fmt.Sprintf("%v:system library:fopen:%v:crypto/bio/bss_file.c:72:fopen('%v','r')", code, reason, name),
+			"2006D080:BIO routines:BIO_new_file:no such file:crypto/bio/bss_file.c:79:")
+		return nil, false
+	}
+	fmt.Fprintln(sys.Err(), "Can't open file for reading")
+	opensslError(sys, fmt.Sprintf("%v:system library:fopen:%v:bss_file.c:398:fopen('%v','r')", code, reason, name),
+		"20074002:BIO routines:FILE_CTRL:system lib:bss_file.c:400:")
+	return nil, false
+}
+
+// writeOutput writes to the file given by -out, or stdout if none
+func (openssl) writeOutput(sys honeyos.Sys, name string, data []byte) bool {
+	if name == "" || name == "-" {
+		sys.Out().Write(data)
+		return true
+	}
+	if err := afero.WriteFile(sys.FSys(), absPath(sys, name), data, 0666&^sys.Umask()); err != nil {
+		fmt.Fprintf(sys.Err(), "%v: Permission denied\n", name)
+		opensslError(sys, fmt.Sprintf("0200100D:system library:fopen:Permission denied:bss_file.c:398:fopen('%v','w')", name))
+		return false
+	}
+	return true
+}
+
+// passArg reads the pass phrase given like pass:secret, env:VAR, file:name,
+// fd:0 or stdin
+func (o openssl) passArg(sys honeyos.Sys, arg string) (string, bool) {
+	kind := strings.SplitN(arg, ":", 2)
+	switch {
+	case kind[0] == "stdin" && len(kind) == 1:
+		line, _ := readLine(sys.In())
+		return line, true
+	case len(kind) < 2:
+	case kind[0] == "pass":
+		return kind[1], true
+	case kind[0] == "env":
+		for _, kv := range sys.Environ() {
+			if strings.HasPrefix(kv, kind[1]+"=") {
+				return strings.TrimPrefix(kv, kind[1]+"="), true
+			}
+		}
+		fmt.Fprintf(sys.Err(), "Can't read environment variable %v\n", kind[1])
+		return "", false
+	case kind[0] == "file":
+		data, err := readFile(sys, absPath(sys, kind[1]))
+		if err != nil {
+			fmt.Fprintf(sys.Err(), "Can't open file %v\n", kind[1])
+			return "", false
+		}
+		return strings.TrimRight(strings.SplitN(string(data), "\n", 2)[0], "\r"), true
+	case kind[0] == "fd" && kind[1] == "0":
+		line, _ := readLine(sys.In())
+		return line, true
+	}
+	fmt.Fprintln(sys.Err(), "Invalid password argument \""+arg+"\"")
+	return "", false
+}
+
+// opensslBase64 encodes the data in lines of 64 characters like the base64
+// BIO, or in one line
+func opensslBase64(data []byte, oneLine bool) []byte {
+	enc := base64.StdEncoding.EncodeToString(data)
+	var b bytes.Buffer
+	for len(enc) > 64 && !oneLine {
+		b.WriteString(enc[:64] + "\n")
+		enc = enc[64:]
+	}
+	if enc != "" {
+		b.WriteString(enc + "\n")
+	}
+	return b.Bytes()
+}
+
+// bytesToKey derives the key and IV from the pass phrase like
+// EVP_BytesToKey with one iteration
+func bytesToKey(md func() hash.Hash, pass, salt []byte, keyLen, ivLen int) (key, iv []byte) {
+	var out, prev []byte
+	for len(out) < keyLen+ivLen {
+		h := md()
+		h.Write(prev)
+		h.Write(pass)
+		h.Write(salt)
+		prev = h.Sum(nil)
+		out = append(out, prev...)
+	}
+	return out[:keyLen], out[keyLen : keyLen+ivLen]
+}
+
+// crypt encrypts or decrypts the data. ok is false for bad decrypt, when the
+// padding is wrong
+func (c opensslCipher) crypt(key, iv, data []byte, decrypt, pad bool) ([]byte, bool) {
+	block, err := c.block(key)
+	if err != nil {
+		return nil, false
+	}
+	out := make([]byte, len(data))
+	switch c.mode {
+	case "ctr":
+		cipher.NewCTR(block, iv).XORKeyStream(out, data)
+		return out, true
+	case "ofb":
+		cipher.NewOFB(block, iv).XORKeyStream(out, data)
+		return out, true
+	case "cfb":
+		if decrypt {
+			cipher.NewCFBDecrypter(block, iv).XORKeyStream(out, data)
+		} else {
+			cipher.NewCFBEncrypter(block, iv).XORKeyStream(out, data)
+		}
+		return out, true
+	}
+	bs := block.BlockSize()
+	if !decrypt && pad {
+		n := bs - len(data)%bs
+		data = append(append([]byte{}, data...), bytes.Repeat([]byte{byte(n)}, n)...)
+		out = make([]byte, len(data))
+	}
+	if len(data)%bs != 0 {
+		return nil, false
+	}
+	switch {
+	case c.mode == "ecb" && decrypt:
+		for i := 0; i < len(data); i += bs {
+			block.Decrypt(out[i:], data[i:])
+		}
+	case c.mode == "ecb":
+		for i := 0; i < len(data); i += bs {
+			block.Encrypt(out[i:], data[i:])
+		}
+	case decrypt:
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+	default:
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, data)
+	}
+	if decrypt && pad {
+		if len(out) == 0 {
+			return nil, false
+		}
+		n := int(out[len(out)-1])
+		if n == 0 || n > bs || n > len(out) || !bytes.Equal(out[len(out)-n:], bytes.Repeat([]byte{byte(n)}, n)) {
+			return nil, false
+		}
+		out = out[:len(out)-n]
+	}
+	return out, true
+}
+
+// hexArg decodes the key, IV or salt given in hex, padded with zeros to n
+// bytes
+func hexArg(s string, n int) ([]byte, bool) {
+	if len(s)%2 == 1 {
+		s += "0"
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, false
+	}
+	if len(b) < n {
+		b = append(b, make([]byte, n-len(b))...)
+	}
+	return b[:n], true
+}
+
+func (o openssl) Exec(args []string, sys honeyos.Sys) int {
+	if !loadPkgDB(sys, pkgFamily()).installed("openssl") {
+		return honeyos.CommandNotFound(sys, append([]string{"openssl"}, args...))
+	}
+	if len(args) > 0 {
+		return o.run(args, sys)
+	}
+	// Without a command it reads them from stdin
+	status := 0
+	for {
+		fmt.Fprint(sys.Out(), "OpenSSL> ")
+		line, ok := readLine(sys.In())
+		if !ok {
+			return status
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+		case fields[0] == "quit" || fields[0] == "q" || fields[0] == "exit":
+			return status
+		default:
+			if status = o.run(fields, sys); status != 0 {
+				fmt.Fprintln(sys.Err(), "error in "+fields[0])
+			}
+		}
+	}
+}
+
+// run runs the command of openssl
+func (o openssl) run(args []string, sys honeyos.Sys) int {
+	cmd := args[0]
+	if alias, ok := opensslAliases[cmd]; ok {
+		cmd = alias
+	}
+	switch {
+	case cmd == "version":
+		v := opensslVersion()
+		fmt.Fprintln(sys.Out(), v.version)
+		if len(args) > 1 && args[1] == "-a" {
+			fmt.Fprintf(sys.Out(), "built on: reproducible build, date unspecified\nplatform: linux-x86_64\n"+
+				"OPENSSLDIR: \"%v\"\n", map[bool]string{true: "/etc/ssl", false: "/usr/lib/ssl"}[v.v11])
+		}
+		return 0
+	case cmd == "enc":
+		return o.enc("", args[1:], sys)
+	case cmd == "base64":
+		return o.enc("base64", args[1:], sys)
+	case cmd == "dgst":
+		return o.dgst("", args[1:], sys)
+	case cmd == "rand":
+		return o.rand(args[1:], sys)
+	case cmd == "s_client":
+		return o.sClient(args[1:], sys)
+	case cmd == "help":
+		o.commands(sys)
+		return 0
+	}
+	if _, ok := opensslCiphers[cmd]; ok {
+		return o.enc(cmd, args[1:], sys)
+	}
+	if _, ok := opensslDigests[cmd]; ok {
+		return o.dgst(cmd, args[1:], sys)
+	}
+	if opensslVersion().v11 {
+		fmt.Fprintf(sys.Err(), "Invalid command '%v'; type \"help\" for a list.\n", args[0])
+		return 1
+	}
+	fmt.Fprintf(sys.Err(), "openssl:Error: '%v' is an invalid command.\n\n", args[0])
+	o.commands(sys)
+	return 1
+}
+
+// commands lists the commands like help does
+func (openssl) commands(sys honeyos.Sys) {
+	table := func(title string, names []string) {
+		fmt.Fprintln(sys.Err(), title)
+		for i, n := range names {
+			fmt.Fprintf(sys.Err(), "%-18v", n)
+			if i%4 == 3 || i == len(names)-1 {
+				fmt.Fprintln(sys.Err())
+			}
+		}
+		fmt.Fprintln(sys.Err())
+	}
+	table("Standard commands", strings.Fields(opensslStandardCommands))
+	table("Message Digest commands (see the `dgst' command for more details)",
+		[]string{"md4", "md5", "rmd160", "sha", "sha1", "sha224", "sha256", "sha384", "sha512"})
+	table("Cipher commands (see the `enc' command for more details)", []string{"aes-128-cbc", "aes-128-ecb",
+		"aes-192-cbc", "aes-192-ecb", "aes-256-cbc", "aes-256-ecb", "base64", "bf", "bf-cbc", "bf-cfb", "bf-ecb",
+		"bf-ofb", "camellia-128-cbc", "camellia-128-ecb", "camellia-192-cbc", "camellia-192-ecb",
+		"camellia-256-cbc", "camellia-256-ecb", "cast", "cast-cbc", "cast5-cbc", "cast5-cfb", "cast5-ecb",
+		"cast5-ofb", "des", "des-cbc", "des-cfb", "des-ecb", "des-ede", "des-ede-cbc", "des-ede-cfb",
+		"des-ede-ofb", "des-ede3", "des-ede3-cbc", "des-ede3-cfb", "des-ede3-ofb", "des-ofb", "des3", "desx",
+		"rc2", "rc2-40-cbc", "rc2-64-cbc", "rc2-cbc", "rc2-cfb", "rc2-ecb", "rc2-ofb", "rc4", "rc4-40", "seed",
+		"seed-cbc", "seed-cfb", "seed-ecb", "seed-ofb", "zlib"})
+}
+
+// enc encrypts or decrypts with the cipher, or base64 encodes or decodes
+func (o openssl) enc(name string, args []string, sys honeyos.Sys) int {
+	v := opensslVersion()
+	decrypt, b64, oneLine, printKey, printOnly, noSalt, noPad, usePBKDF2 := false, name == "base64", false, false,
+		false, false, false, false
+	var in, out, pass, keyHex, ivHex, saltHex, md string
+	havePass := false
+	iter := 0
+	if name == "base64" {
+		name = ""
+	}
+	cmdName := "enc"
+	for i := 0; i < len(args); i++ {
+		opt := args[i]
+		if strings.HasPrefix(opt, "--") {
+			opt = opt[1:]
+		}
+		value := func() (string, bool) {
+			if i+1 >= len(args) {
+				return "", false
+			}
+			i++
+			return args[i], true
+		}
+		var ok = true
+		switch opt {
+		case "-e":
+			decrypt = false
+		case "-d":
+			decrypt = true
+		case "-a", "-base64":
+			b64 = true
+		case "-A":
+			oneLine = true
+		case "-p":
+			printKey = true
+		case "-P":
+			printKey, printOnly = true, true
+		case "-salt":
+			noSalt = false
+		case "-nosalt":
+			noSalt = true
+		case "-nopad":
+			noPad = true
+		case "-in":
+			in, ok = value()
+		case "-out":
+			out, ok = value()
+		case "-k":
+			pass, ok = value()
+			havePass = ok
+		case "-pass", "-kfile":
+			var arg string
+			if arg, ok = value(); ok {
+				if opt == "-kfile" {
+					arg = "file:" + arg
+				}
+				if pass, ok = o.passArg(sys, arg); !ok {
+					fmt.Fprintln(sys.Err(), "Error getting password")
+					return 1
+				}
+				havePass = true
+			}
+		case "-K":
+			keyHex, ok = value()
+		case "-iv":
+			ivHex, ok = value()
+		case "-S":
+			saltHex, ok = value()
+		case "-md":
+			md, ok = value()
+		case "-bufsize", "-engine":
+			_, ok = value()
+		case "-none", "-v", "-debug", "-z":
+		case "-pbkdf2", "-iter":
+			if !v.v111 {
+				return o.badOption(sys, cmdName, opt, opensslEncUsage)
+			}
+			usePBKDF2 = true
+			if opt == "-iter" {
+				var n string
+				if n, ok = value(); ok {
+					iter, _ = strconv.Atoi(n)
+				}
+			}
+		case "-help", "-h":
+			fmt.Fprint(sys.Err(), opensslEncUsage)
+			return 1
+		default:
+			c := strings.TrimPrefix(opt, "-")
+			if alias, found := opensslAliases[c]; found {
+				c = alias
+			}
+			if _, found := opensslCiphers[c]; !found || !strings.HasPrefix(opt, "-") {
+				return o.badOption(sys, cmdName, args[i], opensslEncUsage)
+			}
+			name = c
+		}
+		if !ok {
+			if v.v11 {
+				fmt.Fprintf(sys.Err(), "%v: Option %v needs a value\n%v: Use -help for summary.\n", cmdName, args[i], cmdName)
+			} else {
+				fmt.Fprintf(sys.Err(), "missing argument to '%v'\n%v", args[i], opensslEncUsage)
+			}
+			return 1
+		}
+	}
+	if usePBKDF2 && iter == 0 {
+		iter = 10000
+	}
+	digest := "md5"
+	if v.v11 {
+		digest = "sha256"
+	}
+	if md != "" {
+		digest = strings.ToLower(strings.TrimPrefix(md, "-"))
+		if _, ok := opensslDigests[digest]; !ok {
+			fmt.Fprintf(sys.Err(), "%v is an unsupported message digest type\n", md)
+			return 1
+		}
+	}
+
+	data, ok := o.readInput(sys, in)
+	if !ok {
+		return 1
+	}
+	if decrypt && b64 {
+		s := strings.Map(func(r rune) rune {
+			if r == '\n' || r == '\r' || r == ' ' || r == '\t' {
+				return -1
+			}
+			return r
+		}, string(data))
+		dec, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			if name != "" {
+				fmt.Fprintln(sys.Err(), "error reading input file")
+				return 1
+			}
+			// The base64 BIO stops at the bad input
+			dec = nil
+		}
+		data = dec
+	}
+	logger := sys.Log().WithFields(log.Fields{"in": in, "out": out})
+	if name == "" {
+		if !decrypt && b64 {
+			data = opensslBase64(data, oneLine)
+		}
+		if decrypt && b64 && len(data) > 0 {
+			logger.WithField("decoded", string(data)).Infof("User decoded %v bytes with openssl base64", len(data))
+		}
+		if !o.writeOutput(sys, out, data) {
+			return 1
+		}
+		return 0
+	}
+
+	c := opensslCiphers[name]
+	op := map[bool]string{true: "decryption", false: "encryption"}[decrypt]
+	var key, iv, salt []byte
+	if keyHex != "" {
+		if key, ok = hexArg(keyHex, c.keyLen); !ok {
+			fmt.Fprintln(sys.Err(), "non-hex digit\ninvalid hex key value")
+			return 1
+		}
+	} else {
+		if !havePass {
+			var ok bool
+			if pass, ok = readPassword(sys, fmt.Sprintf("enter %v %v password:", name, op)); !ok {
+				fmt.Fprintln(sys.Err(), "bad password read")
+				return 1
+			}
+			if !decrypt {
+				again, _ := readPassword(sys, fmt.Sprintf("Verifying - enter %v %v password:", name, op))
+				if again != pass {
+					fmt.Fprintln(sys.Err(), "Verify failure\nbad password read")
+					return 1
+				}
+			}
+		}
+		if !noSalt {
+			if decrypt {
+				if len(data) < 16 || string(data[:8]) != "Salted__" {
+					fmt.Fprintln(sys.Err(), "bad magic number")
+					return 1
+				}
+				salt, data = data[8:16], data[16:]
+			} else if saltHex != "" {
+				if salt, ok = hexArg(saltHex, 8); !ok {
+					fmt.Fprintln(sys.Err(), "invalid hex salt value")
+					return 1
+				}
+			} else {
+				salt = make([]byte, 8)
+				crand.Read(salt)
+			}
+		}
+		if usePBKDF2 {
+			dk := pbkdf2.Key([]byte(pass), salt, iter, c.keyLen+c.ivLen, opensslDigests[digest].hash)
+			key, iv = dk[:c.keyLen], dk[c.keyLen:]
+		} else {
+			if v.v111 {
+				fmt.Fprintln(sys.Err(), "*** WARNING : deprecated key derivation used.\nUsing -iter or -pbkdf2 would be better.")
+			}
+			key, iv = bytesToKey(opensslDigests[digest].hash, []byte(pass), salt, c.keyLen, c.ivLen)
+		}
+	}
+	if ivHex != "" {
+		if iv, ok = hexArg(ivHex, c.ivLen); !ok {
+			fmt.Fprintln(sys.Err(), "non-hex digit\ninvalid hex iv value")
+			return 1
+		}
+	} else if keyHex != "" && c.ivLen > 0 {
+		fmt.Fprintln(sys.Err(), "iv undefined")
+		return 1
+	}
+
+	logger = logger.WithFields(log.Fields{"cipher": name, "pass": pass, "key": hex.EncodeToString(key),
+		"iv": hex.EncodeToString(iv)})
+	if printKey {
+		var b strings.Builder
+		if salt != nil {
+			fmt.Fprintf(&b, "salt=%X\n", salt)
+		}
+		fmt.Fprintf(&b, "key=%X\n", key)
+		if c.ivLen > 0 {
+			fmt.Fprintf(&b, "iv =%X\n", iv)
+		}
+		fmt.Fprint(sys.Out(), b.String())
+		if printOnly {
+			logger.Infof("User derived %v key with openssl", name)
+			return 0
+		}
+	}
+	result, ok := c.crypt(key, iv, data, decrypt, !noPad)
+	if !ok {
+		fmt.Fprintln(sys.Err(), "bad decrypt")
+		if v.v11 {
+			opensslError(sys, "06065064:digital envelope routines:EVP_DecryptFinal_ex:bad decrypt:crypto/evp/evp_enc.c:537:")
+		} else {
+			opensslError(sys, "06065064:digital envelope routines:EVP_DecryptFinal_ex:bad decrypt:evp_enc.c:529:")
+		}
+		logger.Infof("User failed to decrypt %v with openssl", name)
+		return 1
+	}
+	plain := data
+	if decrypt {
+		plain = result
+		logger.Infof("User decrypted %v bytes with openssl %v", len(result), name)
+	} else {
+		if salt != nil {
+			result = append(append([]byte("Salted__"), salt...), result...)
+		}
+		logger.Infof("User encrypted %v bytes with openssl %v", len(data), name)
+	}
+	if len(plain) > 0 {
+		honeyos.SaveArtifact(sys, plain, "openssl "+name+" "+in)
+	}
+	if !decrypt && b64 {
+		result = opensslBase64(result, oneLine)
+	}
+	if !o.writeOutput(sys, out, result) {
+		return 1
+	}
+	return 0
+}
+
+// dgst prints the digests of the files, or their HMAC or signature
+func (o openssl) dgst(name string, args []string, sys honeyos.Sys) int {
+	v := opensslVersion()
+	if name == "" {
+		name = "md5"
+		if v.v11 {
+			name = "sha256"
+		}
+	}
+	reverse, colons, binary, hexOut := false, false, false, false
+	var out, hmacKey, signKey, passin string
+	haveHMAC := false
+	var files []string
+	usage := "options are\n" +
+		"-c              to output the digest with separating colons\n" +
+		"-r              to output the digest in coreutils format\n" +
+		"-d              to output debug info\n" +
+		"-hex            output as hex dump\n" +
+		"-binary         output in binary form\n" +
+		"-hmac arg       set the HMAC key to arg\n" +
+		"-sign   file    sign digest using private key in file\n" +
+		"-verify file    verify a signature using public key in file\n" +
+		"-passin arg     input file pass phrase source\n" +
+		"-out file       output to file rather than stdout\n"
+	for i := 0; i < len(args); i++ {
+		opt := args[i]
+		if !strings.HasPrefix(opt, "-") || opt == "-" {
+			files = append(files, args[i:]...)
+			break
+		}
+		value := func() (string, bool) {
+			if i+1 >= len(args) {
+				if v.v11 {
+					fmt.Fprintf(sys.Err(), "dgst: Option %v needs a value\ndgst: Use -help for summary.\n", opt)
+				} else {
+					fmt.Fprint(sys.Err(), usage)
+				}
+				return "", false
+			}
+			i++
+			return args[i], true
+		}
+		var ok = true
+		switch opt {
+		case "-r":
+			reverse = true
+		case "-c":
+			colons = true
+		case "-hex":
+			binary, hexOut = false, true
+		case "-binary":
+			binary = true
+		case "-d", "-non-fips-allow":
+		case "-out":
+			out, ok = value()
+		case "-hmac":
+			hmacKey, ok = value()
+			haveHMAC = ok
+		case "-sign", "-prverify":
+			signKey, ok = value()
+		case "-passin":
+			passin, ok = value()
+		case "-keyform", "-engine", "-sigopt", "-signature", "-verify":
+			_, ok = value()
+		default:
+			if _, found := opensslDigests[opt[1:]]; !found {
+				return o.badOption(sys, "dgst", opt, usage)
+			}
+			name = opt[1:]
+		}
+		if !ok {
+			return 1
+		}
+	}
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	md := opensslDigests[name]
+	logger := sys.Log().WithField("args", args)
+
+	var signer crypto.Signer
+	if signKey != "" {
+		var ok bool
+		if signer, ok = o.loadKey(sys, signKey, passin); !ok {
+			return 1
+		}
+		// Signatures are binary unless asked for in hex
+		binary = !hexOut
+	}
+	if haveHMAC {
+		logger.WithField("key", hmacKey).Infof("User computed HMAC with openssl dgst")
+	}
+
+	var result bytes.Buffer
+	status := 0
+	for _, f := range files {
+		data, ok := o.readInput(sys, f)
+		if !ok {
+			status = 1
+			continue
+		}
+		var sum []byte
+		label := strings.ToUpper(name)
+		if haveHMAC {
+			h := hmac.New(md.hash, []byte(hmacKey))
+			h.Write(data)
+			sum, label = h.Sum(nil), "HMAC-"+label
+		} else {
+			h := md.hash()
+			h.Write(data)
+			sum = h.Sum(nil)
+		}
+		if signer != nil {
+			sig, err := signer.Sign(crand.Reader, sum, md.id)
+			if err != nil {
+				opensslError(sys, "04075070:rsa routines:RSA_sign:digest too big for rsa key:rsa_sign.c:127:")
+				return 1
+			}
+			sum = sig
+			if _, isEC := signer.(*ecdsa.PrivateKey); isEC {
+				label = "EC-" + label
+			} else {
+				label = "RSA-" + label
+			}
+		}
+		if binary {
+			result.Write(sum)
+			continue
+		}
+		digest := hex.EncodeToString(sum)
+		if colons {
+			var parts []string
+			for i := 0; i < len(digest); i += 2 {
+				parts = append(parts, digest[i:i+2])
+			}
+			digest = strings.Join(parts, ":")
+		}
+		switch {
+		case reverse && f == "-":
+			fmt.Fprintf(&result, "%v *stdin\n", digest)
+		case reverse:
+			fmt.Fprintf(&result, "%v *%v\n", digest, f)
+		case f == "-":
+			fmt.Fprintf(&result, "(stdin)= %v\n", digest)
+		default:
+			fmt.Fprintf(&result, "%v(%v)= %v\n", label, f, digest)
+		}
+	}
+	if !o.writeOutput(sys, out, result.Bytes()) {
+		return 1
+	}
+	return status
+}
+
+// loadKey reads the private key of the PEM file, with the pass phrase if it
+// is encrypted. The key is captured
+func (o openssl) loadKey(sys honeyos.Sys, name, passin string) (crypto.Signer, bool) {
+	fail := func() (crypto.Signer, bool) {
+		fmt.Fprintln(sys.Err(), "unable to load key file")
+		return nil, false
+	}
+	data, ok := o.readInput(sys, name)
+	if !ok {
+		return fail()
+	}
+	logger := sys.Log().WithField("file", name)
+	honeyos.SaveArtifact(sys, data, "openssl key "+name)
+	block, _ := pem.Decode(data)
+	if block == nil {
+		opensslError(sys, "0906D06C:PEM routines:PEM_read_bio:no start line:pem_lib.c:707:Expecting: ANY PRIVATE KEY")
+		return fail()
+	}
+	der := block.Bytes
+	if x509.IsEncryptedPEMBlock(block) {
+		var pass string
+		if passin != "" {
+			if pass, ok = o.passArg(sys, passin); !ok {
+				return fail()
+			}
+		} else if pass, ok = readPassword(sys, fmt.Sprintf("Enter pass phrase for %v:", name)); !ok {
+			return fail()
+		}
+		logger = logger.WithField("pass", pass)
+		if der, err := x509.DecryptPEMBlock(block, []byte(pass)); err == nil {
+			block.Bytes = der
+		} else {
+			logger.Infof("User gave wrong pass phrase of key to openssl")
+			opensslError(sys, "06065064:digital envelope routines:EVP_DecryptFinal_ex:bad decrypt:evp_enc.c:529:",
+				"0906A065:PEM routines:PEM_do_header:bad decrypt:pem_lib.c:483:")
+			return fail()
+		}
+		der = block.Bytes
+	}
+	logger.Infof("User loaded private key %v into openssl", name)
+	if k, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return k, true
+	}
+	if k, err := x509.ParseECPrivateKey(der); err == nil {
+		return k, true
+	}
+	if k, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		if s, ok := k.(crypto.Signer); ok {
+			return s, true
+		}
+	}
+	opensslError(sys, "0D0680A8:asn1 encoding routines:ASN1_CHECK_TLEN:wrong tag:tasn_dec.c:1220:")
+	return fail()
+}
+
+// rand prints random bytes
+func (o openssl) rand(args []string, sys honeyos.Sys) int {
+	v := opensslVersion()
+	usage := func() int {
+		if v.v11 {
+			fmt.Fprintln(sys.Err(), "Usage: rand [flags] num\nValid options are:\n -help               Display this summary\n"+
+				" -out outfile        Output file\n -rand val           Load the file(s) into the random number generator\n"+
+				" -writerand outfile  Write random data to the specified file\n -base64             Base64 encode output\n"+
+				" -hex                Hex encode output\n -engine val         Use engine, possibly a hardware device")
+		} else {
+			fmt.Fprintln(sys.Err(), "Usage: rand [options] num\nwhere options are\n-out file             - write to file\n"+
+				"-engine e             - use engine e, possibly a hardware device.\n"+
+				"-rand file:file:... - seed PRNG from files\n-base64               - base64 encode output\n"+
+				"-hex                  - hex encode output")
+		}
+		return 1
+	}
+	var out string
+	encoding := ""
+	num := -1
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-hex", "-base64":
+			encoding = args[i]
+		case "-out", "-rand", "-engine", "-writerand":
+			if i+1 >= len(args) {
+				return usage()
+			}
+			i++
+			if args[i-1] == "-out" {
+				out = args[i]
+			}
+		default:
+			n, err := strconv.Atoi(args[i])
+			if err != nil || n < 0 || num >= 0 {
+				return usage()
+			}
+			num = n
+		}
+	}
+	if num < 0 {
+		return usage()
+	}
+	data := make([]byte, num)
+	crand.Read(data)
+	var result []byte
+	switch encoding {
+	case "-hex":
+		result = []byte(hex.EncodeToString(data) + "\n")
+	case "-base64":
+		result = opensslBase64(data, false)
+	default:
+		result = data
+	}
+	sys.Log().WithFields(log.Fields{"value": hex.EncodeToString(data), "out": out}).
+		Infof("User generated %v random bytes with openssl rand", num)
+	if !o.writeOutput(sys, out, result) {
+		return 1
+	}
+	return 0
+}
+
+// opensslChain makes up the certificates of the server, signed by Let's
+// Encrypt, and the key exchange of its TLS
+func opensslChain(name string, now time.Time) (leaf, intermediate *x509.Certificate) {
+	seed := fnvString(name)
+	notBefore := now.Add(-time.Duration(seed%(60*24)) * time.Hour).Truncate(time.Hour)
+	rootKey, _ := ecdsa.GenerateKey(elliptic.P256(), crand.Reader)
+	interKey, _ := ecdsa.GenerateKey(elliptic.P256(), crand.Reader)
+	leafKey, _ := ecdsa.GenerateKey(elliptic.P256(), crand.Reader)
+	root := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{Organization: []string{"Digital Signature Trust Co."},
+		CommonName: "DST Root CA X3"}, NotBefore: notBefore.AddDate(-21, 0, 0), NotAfter: notBefore.AddDate(1, 0, 0),
+		IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign}
+	interTmpl := &x509.Certificate{SerialNumber: new(big.Int).SetUint64(seed >> 1),
+		Subject:   pkix.Name{Country: []string{"US"}, Organization: []string{"Let's Encrypt"}, CommonName: "R3"},
+		NotBefore: notBefore.AddDate(-1, 0, 0), NotAfter: notBefore.AddDate(1, 0, 0), IsCA: true,
+		BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature}
+	der, _ := x509.CreateCertificate(crand.Reader, interTmpl, root, &interKey.PublicKey, rootKey)
+	intermediate, _ = x509.ParseCertificate(der)
+	leafTmpl := &x509.Certificate{SerialNumber: new(big.Int).SetUint64(seed), Subject: pkix.Name{CommonName: name},
+		NotBefore: notBefore, NotAfter: notBefore.AddDate(0, 0, 90), KeyUsage: x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}}
+	if ip := net.ParseIP(name); ip != nil {
+		leafTmpl.IPAddresses = []net.IP{ip}
+	} else {
+		leafTmpl.DNSNames = []string{name}
+	}
+	der, _ = x509.CreateCertificate(crand.Reader, leafTmpl, intermediate, &leafKey.PublicKey, interKey)
+	leaf, _ = x509.ParseCertificate(der)
+	return leaf, intermediate
+}
+
+// opensslName formats the name of the certificate the way the release does,
+// like /C=US/O=Let's Encrypt/CN=R3 or C = US, O = Let's Encrypt, CN = R3
+func opensslName(n pkix.Name, v11 bool) string {
+	var parts []string
+	add := func(key string, vals []string) {
+		for _, v := range vals {
+			if v11 {
+				parts = append(parts, key+" = "+v)
+			} else {
+				parts = append(parts, "/"+key+"="+v)
+			}
+		}
+	}
+	add("C", n.Country)
+	add("O", n.Organization)
+	if n.CommonName != "" {
+		add("CN", []string{n.CommonName})
+	}
+	if v11 {
+		return strings.Join(parts, ", ")
+	}
+	return strings.Join(parts, "")
+}
+
+// sClient connects to the TLS server. Only the hosts on the Internet speak
+// TLS, what the user types is captured as sent over it
+func (o openssl) sClient(args []string, sys honeyos.Sys) int {
+	v := opensslVersion()
+	connect, servername, keyFile := "localhost:4433", "", ""
+	showCerts, quiet, brief := false, false, false
+	for i := 0; i < len(args); i++ {
+		opt := args[i]
+		switch opt {
+		case "-connect", "-servername", "-starttls", "-CAfile", "-CApath", "-cert", "-key", "-pass", "-proxy",
+			"-cipher", "-alpn", "-sess_out", "-sess_in", "-host", "-port", "-verify", "-xmpphost", "-name":
+			if i+1 >= len(args) {
+				if v.v11 {
+					fmt.Fprintf(sys.Err(), "s_client: Option %v needs a value\ns_client: Use -help for summary.\n", opt)
+				} else {
+					fmt.Fprintln(sys.Err(), "usage: s_client args")
+				}
+				return 1
+			}
+			i++
+			switch opt {
+			case "-connect":
+				connect = args[i]
+			case "-servername":
+				servername = args[i]
+			case "-key", "-cert":
+				if keyFile == "" || opt == "-key" {
+					keyFile = args[i]
+				}
+			}
+		case "-showcerts":
+			showCerts = true
+		case "-quiet":
+			quiet = true
+		case "-brief":
+			if !v.v11 {
+				return o.badOption(sys, "s_client", opt, "usage: s_client args\n")
+			}
+			brief = true
+		case "-crlf", "-ign_eof", "-no_ign_eof", "-debug", "-msg", "-state", "-prexit", "-nbio", "-tls1", "-tls1_1",
+			"-tls1_2", "-tls1_3", "-no_ssl3", "-no_tls1", "-no_tls1_1", "-no_tls1_2", "-ssl3", "-4", "-6", "-status",
+			"-reconnect", "-no_ticket", "-bugs":
+		default:
+			if v.v11 {
+				fmt.Fprintf(sys.Err(), "s_client: Unrecognized flag %v\ns_client: Use -help for summary.\n", strings.TrimLeft(opt, "-"))
+			} else {
+				fmt.Fprintf(sys.Err(), "unknown option %v\nusage: s_client args\n", opt)
+			}
+			return 1
+		}
+	}
+	host, port, err := net.SplitHostPort(connect)
+	if err != nil {
+		host, port = connect, "443"
+	}
+	portNum, err := strconv.Atoi(port)
+	if err != nil || portNum <= 0 || portNum > 65535 {
+		fmt.Fprintf(sys.Err(), "getservbyname failure for %v\nconnect:errno=0\n", port)
+		return 1
+	}
+	logger := sys.Log().WithFields(log.Fields{"host": host, "port": port, "servername": servername})
+	if keyFile != "" {
+		if data, err := readFile(sys, absPath(sys, keyFile)); err == nil {
+			honeyos.SaveArtifact(sys, data, "openssl s_client "+keyFile)
+			logger = logger.WithField("key", keyFile)
+		}
+	}
+	logger.Infof("User connecting to %v:%v with openssl s_client", host, port)
+	ip := netResolve(sys, host)
+	if ip == nil {
+		if v.v11 {
+			fmt.Fprintf(sys.Err(), "%v: Name or service not known\nconnect:errno=0\n", host)
+		} else {
+			fmt.Fprintln(sys.Err(), "getaddrinfo: Name or service not known\nconnect:errno=0")
+		}
+		return 1
+	}
+	open, reachable := netPortOpen(sys, ip, portNum)
+	if !reachable {
+		if !pkgSleep(sys, 127*time.Second) {
+			return 1
+		}
+		fmt.Fprintln(sys.Err(), "connect: Connection timed out\nconnect:errno=110")
+		return 1
+	}
+	if !open {
+		fmt.Fprintln(sys.Err(), "connect: Connection refused\nconnect:errno=111")
+		return 1
+	}
+	route := netTrace(sys, ip)
+	if !pkgSleep(sys, route.hopRTT(len(route.hops))) {
+		return 1
+	}
+	fmt.Fprintln(sys.Out(), "CONNECTED(00000003)")
+	_, landing := sshLookup(sys, ip.String())
+	if landing || route.local {
+		// Neither sshd nor the others here speak TLS
+		if !pkgSleep(sys, route.hopRTT(len(route.hops))) {
+			return 1
+		}
+		if v.v11 {
+			opensslError(sys, "1408F10B:SSL routines:ssl3_get_record:wrong version number:ssl/record/ssl3_record.c:332:")
+		} else {
+			opensslError(sys, "140770FC:SSL routines:SSL23_GET_SERVER_HELLO:unknown protocol:s23_clnt.c:794:")
+		}
+		fmt.Fprintln(sys.Out(), "---\nno peer certificate available\n---\nNo client certificate CA names sent\n---\n"+
+			"SSL handshake has read 7 bytes and written 289 bytes\n---\nNew, (NONE), Cipher is (NONE)\n"+
+			"Secure Renegotiation IS NOT supported\nCompression: NONE\nExpansion: NONE\nNo ALPN negotiated\n---")
+		return 1
+	}
+
+	name := servername
+	if name == "" {
+		name = host
+	}
+	now := honeyos.Now(sys)
+	leaf, inter := opensslChain(name, now)
+	if !pkgSleep(sys, route.hopRTT(len(route.hops))*2) {
+		return 1
+	}
+	cipherName, protocol := "ECDHE-ECDSA-AES256-GCM-SHA384", "TLSv1.2"
+	if v.v111 {
+		cipherName, protocol = "TLS_AES_256_GCM_SHA384", "TLSv1.3"
+	}
+	pemOf := func(c *x509.Certificate) string {
+		return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw}))
+	}
+	var b strings.Builder
+	depth := []string{opensslName(inter.Issuer, v.v11), opensslName(inter.Subject, v.v11), opensslName(leaf.Subject, v.v11)}
+	for i, d := range depth {
+		fmt.Fprintf(&b, "depth=%v %v\nverify return:1\n", 2-i, d)
+	}
+	if brief {
+		fmt.Fprintf(&b, "CONNECTION ESTABLISHED\nProtocol version: %v\nCiphersuite: %v\n"+
+			"Peer certificate: %v\nHash used: SHA256\nSignature type: ECDSA\nVerification: OK\n"+
+			"Server Temp Key: X25519, 253 bits\n", protocol, cipherName, opensslName(leaf.Subject, true))
+	} else if !quiet {
+		fmt.Fprintf(&b, "---\nCertificate chain\n 0 s:%v\n   i:%v\n", opensslName(leaf.Subject, v.v11), opensslName(leaf.Issuer, v.v11))
+		if showCerts {
+			b.WriteString(pemOf(leaf))
+		}
+		fmt.Fprintf(&b, " 1 s:%v\n   i:%v\n", opensslName(inter.Subject, v.v11), opensslName(inter.Issuer, v.v11))
+		if showCerts {
+			b.WriteString(pemOf(inter))
+		}
+		fmt.Fprintf(&b, "---\nServer certificate\n%vsubject=%v\n\nissuer=%v\n\n---\n", pemOf(leaf),
+			opensslName(leaf.Subject, v.v11), opensslName(leaf.Issuer, v.v11))
+		b.WriteString("No client certificate CA names sent\nPeer signing digest: SHA256\n")
+		if v.v11 {
+			b.WriteString("Peer signature type: ECDSA\nServer Temp Key: X25519, 253 bits\n")
+		} else {
+			b.WriteString("Server Temp Key: ECDH, P-256, 256 bits\n")
+		}
+		fmt.Fprintf(&b, "---\nSSL handshake has read %v bytes and written %v bytes\n", 2200+len(leaf.Raw)+len(inter.Raw), 300+len(name))
+		if v.v111 {
+			b.WriteString("Verification: OK\n")
+		}
+		newLine := "New, TLSv1/SSLv3, Cipher is "
+		if v.v11 {
+			newLine = "New, " + protocol + ", Cipher is "
+		}
+		sessionID, masterKey := make([]byte, 32), make([]byte, 48)
+		crand.Read(sessionID)
+		crand.Read(masterKey)
+		fmt.Fprintf(&b, "---\n%v%v\nServer public key is 256 bit\nSecure Renegotiation IS supported\n"+
+			"Compression: NONE\nExpansion: NONE\nNo ALPN negotiated\nSSL-Session:\n    Protocol  : %v\n"+
+			"    Cipher    : %v\n    Session-ID: %X\n    Session-ID-ctx: \n    Master-Key: %X\n",
+			newLine, cipherName, protocol, cipherName, sessionID, masterKey)
+		if !v.v11 {
+			b.WriteString("    Key-Arg   : None\n")
+		}
+		fmt.Fprintf(&b, "    PSK identity: None\n    PSK identity hint: None\n    SRP username: None\n"+
+			"    Start Time: %v\n    Timeout   : %v (sec)\n    Verify return code: 0 (ok)\n", now.Unix(),
+			map[bool]int{true: 7200, false: 300}[v.v11])
+		if v.v11 {
+			b.WriteString("    Extended master secret: yes\n")
+		}
+		b.WriteString("---\n")
+	}
+	if quiet || brief {
+		fmt.Fprint(sys.Err(), b.String())
+	} else {
+		fmt.Fprint(sys.Out(), b.String())
+	}
+
+	sock := honeyos.SockInfo{Proto: "tcp", Local: fmt.Sprintf("%v:%v", honeyos.IPAddress(), 40000+portNum%20000),
+		Remote: fmt.Sprintf("%v:%v", ip, port), State: "ESTABLISHED"}
+	defer honeyos.OpenSocket(sys, sock)()
+	// The server waits for the request until the end of input
+	data := nc{}.capture(sys, 0, 0, false)
+	logger.WithField("data", string(data)).Infof("User sent %v bytes to %v:%v with openssl s_client", len(data), host, port)
+	if len(data) > 0 {
+		honeyos.SaveArtifact(sys, data, fmt.Sprintf("openssl s_client %v:%v", host, port))
+	}
+	if !quiet {
+		fmt.Fprintln(sys.Err(), "DONE")
+	}
+	return 0
+}
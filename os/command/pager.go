@@ -0,0 +1,326 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/mkishere/sshsyrup/util/terminal"
+)
+
+// pager shows text a screen at a time on the terminal, for commands like
+// more, less and man
+type pager struct {
+	// prompt returns the status line at the bottom, given the lines shown
+	// from top until before bottom and the number of lines
+	prompt func(top, bottom, total int) string
+	// end is the prompt when the end of text is reached, e.g. (END) of less.
+	// If it is empty the pager quits at the end like more
+	end string
+	// endPrompt replaces end with the status line given the top line and
+	// the number of lines, if set
+	endPrompt func(top, total int) string
+	// altScreen shows the text in alternate screen, which is restored when
+	// the pager quits
+	altScreen bool
+	// foldCase makes the searches with / and ? ignore case
+	foldCase bool
+	// highlight is the pattern last searched, shown in reverse
+	highlight *regexp.Regexp
+}
+
+// pagerPercent is the percentage of the text shown until before bottom
+func pagerPercent(bottom, total int) int {
+	if total == 0 {
+		return 100
+	}
+	return bottom * 100 / total
+}
+
+// page writes the text to stdout, pausing after every screen if stdout is
+// the terminal. Keys are read from the terminal even if stdin is redirected
+func (p pager) page(text string, sys honeyos.Sys) {
+	keys, modes := honeyos.OpenTTY(sys), sys.Termios()
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	if keys == nil || modes == nil || !honeyos.IsTerminal(sys.Out()) ||
+		p.end == "" && p.topOf(lines, len(lines), sys.Width(), sys.Height()-1) == 0 {
+		io.WriteString(sys.Out(), text)
+		return
+	}
+	// Read keys one by one without echo
+	icanon, echo := modes.Flag("icanon"), modes.Flag("echo")
+	modes.SetFlag("icanon", false)
+	modes.SetFlag("echo", false)
+	defer func() {
+		modes.SetFlag("icanon", icanon)
+		modes.SetFlag("echo", echo)
+	}()
+	out := sys.Out()
+	if p.altScreen {
+		io.WriteString(out, "\x1b[?1049h\x1b[H\x1b[2J")
+		defer io.WriteString(out, "\x1b[?1049l")
+	}
+
+	// Screen is drawn by the key loop and the resize callback, which are
+	// serialized by mu. Key loop holds it except when waiting for a key
+	var mu sync.Mutex
+	done := false
+	width, height := sys.Width(), sys.Height()
+	top := 0
+	bottom := p.draw(out, lines, top, width, height-1)
+	status := func() {
+		s := p.end
+		if bottom < len(lines) {
+			s = p.prompt(top, bottom, len(lines))
+		} else if p.endPrompt != nil {
+			s = p.endPrompt(top, len(lines))
+		}
+		io.WriteString(out, terminal.Color(terminal.Reverse, s))
+	}
+	mu.Lock()
+	defer func() {
+		done = true
+		mu.Unlock()
+	}()
+	defer sys.OnResize(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if done {
+			return
+		}
+		// Draw the screen again in new size
+		width, height = sys.Width(), sys.Height()
+		io.WriteString(out, "\r\x1b[K\x1b[H\x1b[2J")
+		bottom = p.draw(out, lines, top, width, height-1)
+		status()
+	})()
+
+	buf := make([]byte, 16)
+	backwardSearch := false
+	// pending are the keys read along, as when pasted, which are taken one
+	// by one except the escape sequences
+	pending := ""
+	for {
+		atEnd := bottom >= len(lines)
+		if atEnd && p.end == "" {
+			return
+		}
+		status()
+		if pending == "" {
+			mu.Unlock()
+			n, err := keys.Read(buf)
+			mu.Lock()
+			if err != nil {
+				io.WriteString(out, "\r\x1b[K")
+				return
+			}
+			pending = string(buf[:n])
+		}
+		io.WriteString(out, "\r\x1b[K")
+		key := pending
+		pending = ""
+		if key[0] != '\x1b' && len(key) > 1 && key != ":q" && key != "ZZ" {
+			key, pending = key[:1], key[1:]
+		}
+		atEnd = bottom >= len(lines)
+		page := height - 1
+		if page < 1 {
+			page = 1
+		}
+		forward, backward := 0, 0
+		switch key {
+		case "q", "Q", ":q", "ZZ":
+			return
+		case "/", "?", "n", "N":
+			if key == "/" || key == "?" {
+				backwardSearch = key == "?"
+				mu.Unlock()
+				re, ok := p.readPattern(sys, keys, key, pending)
+				pending = ""
+				mu.Lock()
+				if !ok {
+					continue
+				}
+				if re != nil {
+					p.highlight = re
+				}
+			}
+			if p.highlight == nil {
+				io.WriteString(out, terminal.Color(terminal.Reverse, "No previous regular expression"))
+				continue
+			}
+			back := backwardSearch != (key == "N")
+			match := -1
+			if back {
+				for i := top - 1; i >= 0 && match < 0; i-- {
+					if p.highlight.MatchString(lines[i]) {
+						match = i
+					}
+				}
+			} else {
+				for i := top + 1; i < len(lines) && match < 0; i++ {
+					if p.highlight.MatchString(lines[i]) {
+						match = i
+					}
+				}
+			}
+			if match < 0 {
+				io.WriteString(out, terminal.Color(terminal.Reverse, "Pattern not found  (press RETURN)"))
+				if pending != "" {
+					pending = pending[1:]
+				} else {
+					mu.Unlock()
+					keys.Read(buf)
+					mu.Lock()
+				}
+				io.WriteString(out, "\r\x1b[K")
+				continue
+			}
+			top = match
+			if p.end == "" {
+				// more stops at the end without going back
+				if t := p.topOf(lines, len(lines), width, page); t < top {
+					top = t
+				}
+			}
+			io.WriteString(out, "\x1b[H\x1b[2J")
+			bottom = p.draw(out, lines, top, width, page)
+			continue
+		case " ", "f", "z", "\x06", "\x16", "\x1b[6~", "\x1b ":
+			forward = page
+		case "\r", "\n", "j", "e", "\x0e", "\x1b[B", "\x1bOB":
+			forward = 1
+		case "d", "\x04":
+			forward = page / 2
+		case "b", "w", "\x02", "\x1b[5~", "\x1bv":
+			backward = page
+		case "k", "y", "\x10", "\x1b[A", "\x1bOA":
+			backward = 1
+		case "u", "\x15":
+			backward = page / 2
+		case "g", "<", "\x1b<":
+			backward = len(lines)
+		case "G", ">", "\x1b>":
+			forward = len(lines)
+		default:
+			io.WriteString(out, "\a")
+		}
+		switch {
+		case forward > 0 && !atEnd:
+			// Print the following lines and let the terminal scroll
+			bottom = p.draw(out, lines, bottom, width, forward)
+			top = p.topOf(lines, bottom, width, page)
+		case backward > 0 && top > 0:
+			top = p.topOf(lines, top, width, backward)
+			if backward >= len(lines) {
+				top = 0
+			}
+			io.WriteString(out, "\x1b[H\x1b[2J")
+			bottom = p.draw(out, lines, top, width, page)
+		}
+	}
+}
+
+// draw writes the lines from start until the given rows are filled, and
+// returns the index of the line following the last one written. Matches of
+// the last search are shown in reverse
+func (p pager) draw(out io.Writer, lines []string, start, width, rows int) int {
+	end := start
+	for end < len(lines) {
+		r := lineRows(lines[end], width)
+		if rows < r && end > start {
+			break
+		}
+		line := lines[end]
+		if p.highlight != nil {
+			line = p.highlight.ReplaceAllStringFunc(line, func(m string) string {
+				return terminal.Color(terminal.Reverse, m)
+			})
+		}
+		fmt.Fprintln(out, line)
+		rows -= r
+		end++
+	}
+	return end
+}
+
+// readPattern reads the pattern to search for at the bottom line, after the
+// / or ? and what was typed along. An empty pattern repeats the last search,
+// returning nil. ok is false if the search is cancelled
+func (p pager) readPattern(sys honeyos.Sys, keys io.Reader, mark, typed string) (re *regexp.Regexp, ok bool) {
+	out := sys.Out()
+	io.WriteString(out, mark)
+	var pattern []byte
+	buf := make([]byte, 16)
+	n := copy(buf, typed)
+	for {
+		if n == 0 {
+			var err error
+			if n, err = keys.Read(buf); err != nil {
+				return nil, false
+			}
+		}
+		input := buf[:n]
+		n = 0
+		for _, c := range input {
+			switch c {
+			case '\r', '\n':
+				io.WriteString(out, "\r\x1b[K")
+				if len(pattern) == 0 {
+					return nil, true
+				}
+				expr := string(pattern)
+				if p.foldCase {
+					expr = "(?i)" + expr
+				}
+				re, err := regexp.Compile(expr)
+				if err != nil {
+					re = regexp.MustCompile(regexp.QuoteMeta(string(pattern)))
+				}
+				return re, true
+			case 0x7f, '\b':
+				if len(pattern) == 0 {
+					io.WriteString(out, "\r\x1b[K")
+					return nil, false
+				}
+				pattern = pattern[:len(pattern)-1]
+				io.WriteString(out, "\b \b")
+			case 0x03, 0x1b:
+				io.WriteString(out, "\r\x1b[K")
+				return nil, false
+			default:
+				if c >= ' ' {
+					pattern = append(pattern, c)
+					out.Write([]byte{c})
+				}
+			}
+		}
+	}
+}
+
+// topOf returns the index of the first line, if the lines before end are
+// laid out in the given rows
+func (pager) topOf(lines []string, end, width, rows int) int {
+	top := end
+	for top > 0 {
+		r := lineRows(lines[top-1], width)
+		if rows < r {
+			break
+		}
+		rows -= r
+		top--
+	}
+	return top
+}
+
+// lineRows is the number of rows the line takes on screen after wrapping
+func lineRows(line string, width int) int {
+	w := terminal.StringWidth(line)
+	if width <= 0 || w <= width {
+		return 1
+	}
+	return (w + width - 1) / width
+}
@@ -0,0 +1,280 @@
+package command
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/afero"
+)
+
+// passwd changes the password of accounts. The passwords typed are logged,
+// since they are what attackers would login with later
+type passwd struct{}
+
+// chpasswd changes passwords in batch, reading user:password lines
+type chpasswd struct{}
+
+func init() {
+	honeyos.RegisterCommand("passwd", passwd{})
+	honeyos.RegisterCommand("chpasswd", chpasswd{})
+}
+
+func (passwd) GetHelp() string {
+	return `Usage: passwd [options] [LOGIN]
+
+Options:
+  -d, --delete                  delete the password for the named account
+  -h, --help                    display this help message and exit
+  -l, --lock                    lock the password of the named account
+  -S, --status                  report password status on the named account
+  -u, --unlock                  unlock the password of the named account
+`
+}
+
+func (passwd) Where() string {
+	return "/usr/bin/passwd"
+}
+
+func (p passwd) Exec(args []string, sys honeyos.Sys) int {
+	action, stdin, name := "", false, ""
+	for _, arg := range args {
+		switch arg {
+		case "-d", "--delete":
+			action = "d"
+		case "-l", "--lock":
+			action = "l"
+		case "-u", "--unlock":
+			action = "u"
+		case "-S", "--status":
+			action = "S"
+		case "--stdin":
+			// Only passwd of Red Hat reads the password from stdin
+			if pkgFamily() != "rpm" {
+				fmt.Fprintf(sys.Err(), "passwd: unrecognized option '%v'\n", arg)
+				fmt.Fprint(sys.Err(), p.GetHelp())
+				return 2
+			}
+			stdin = true
+		case "-h", "--help":
+			fmt.Fprint(sys.Out(), p.GetHelp())
+			return 0
+		default:
+			if strings.HasPrefix(arg, "-") {
+				fmt.Fprintf(sys.Err(), "passwd: invalid option -- '%v'\n", strings.TrimLeft(arg, "-"))
+				fmt.Fprint(sys.Err(), p.GetHelp())
+				return 2
+			}
+			name = arg
+		}
+	}
+	self := honeyos.GetUserByID(sys.CurrentUser()).Name
+	if name == "" {
+		name = self
+	}
+	user := honeyos.GetUser(name)
+	switch {
+	case user.Name == "":
+		fmt.Fprintf(sys.Err(), "passwd: user '%v' does not exist\n", name)
+		return 1
+	case !isRoot(sys) && (name != self || action != "" && action != "S"):
+		fmt.Fprintf(sys.Err(), "passwd: You may not view or modify password information for %v.\n", name)
+		return 1
+	}
+	switch action {
+	case "S":
+		status := "P"
+		if strings.HasPrefix(user.Password, "!") {
+			status = "L"
+		} else if user.Password == "" {
+			status = "NP"
+		}
+		fmt.Fprintf(sys.Out(), "%v %v %v 0 99999 7 -1\n", name, status, time.Now().Format("01/02/2006"))
+		return 0
+	case "d", "l", "u":
+		hash := passwordHash(sys, name)
+		switch {
+		case action == "d":
+			hash = ""
+		case action == "l" && !strings.HasPrefix(hash, "!"):
+			hash = "!" + hash
+		case action == "u":
+			hash = strings.TrimPrefix(hash, "!")
+		}
+		editAccountFile(sys, "/etc/shadow", name, shadowLine(name, hash))
+		sys.Log().WithField("account", name).WithField("action", action).Infof("User changed password status of %v", name)
+		fmt.Fprintln(sys.Out(), "passwd: password expiry information changed.")
+		return 0
+	}
+
+	rpm := pkgFamily() == "rpm"
+	if rpm {
+		fmt.Fprintf(sys.Out(), "Changing password for user %v.\n", name)
+	} else if !isRoot(sys) {
+		fmt.Fprintf(sys.Out(), "Changing password for %v.\n", name)
+	}
+	var current, pass string
+	if stdin {
+		line, _ := bufio.NewReader(sys.In()).ReadString('\n')
+		pass = strings.TrimRight(line, "\r\n")
+	} else {
+		var ok bool
+		if !isRoot(sys) {
+			prompt := "(current) UNIX password: "
+			if rpm {
+				prompt = "Current password: "
+			}
+			if current, ok = readPassword(sys, prompt); !ok {
+				fmt.Fprintln(sys.Err(), "passwd: Authentication token manipulation error\npasswd: password unchanged")
+				return 10
+			}
+		}
+		newPrompt, retypePrompt := "Enter new UNIX password: ", "Retype new UNIX password: "
+		if rpm {
+			newPrompt, retypePrompt = "New password: ", "Retype new password: "
+		}
+		if pass, ok = readPassword(sys, newPrompt); !ok || pass == "" {
+			fmt.Fprintln(sys.Err(), "No password supplied\npasswd: Authentication token manipulation error\npasswd: password unchanged")
+			return 10
+		}
+		retype, _ := readPassword(sys, retypePrompt)
+		if retype != pass {
+			sys.Log().WithField("account", name).WithField("password", pass).WithField("retyped", retype).
+				Infof("User mistyped new password of %v", name)
+			fmt.Fprintln(sys.Err(), "Sorry, passwords do not match\npasswd: Authentication token manipulation error\npasswd: password unchanged")
+			return 10
+		}
+	}
+	sys.Log().WithField("account", name).WithField("password", pass).WithField("currentPassword", current).
+		Infof("User changed password of %v", name)
+	setPassword(sys, name, pass)
+	if rpm {
+		fmt.Fprintln(sys.Out(), "passwd: all authentication tokens updated successfully.")
+	} else {
+		fmt.Fprintln(sys.Out(), "passwd: password updated successfully")
+	}
+	return 0
+}
+
+func (chpasswd) GetHelp() string {
+	return `Usage: chpasswd [options]
+
+Options:
+  -e, --encrypted               supplied passwords are encrypted
+  -h, --help                    display this help message and exit
+`
+}
+
+func (chpasswd) Where() string {
+	return "/usr/sbin/chpasswd"
+}
+
+func (c chpasswd) Exec(args []string, sys honeyos.Sys) int {
+	encrypted := false
+	for _, arg := range args {
+		switch arg {
+		case "-e", "--encrypted":
+			encrypted = true
+		case "-m", "--md5", "-c", "--crypt-method", "-s", "--sha-rounds":
+		case "-h", "--help":
+			fmt.Fprint(sys.Out(), c.GetHelp())
+			return 0
+		}
+	}
+	if !isRoot(sys) {
+		fmt.Fprintln(sys.Err(), "chpasswd: cannot lock /etc/passwd; try again later.")
+		return 1
+	}
+	type change struct{ name, pass string }
+	var changes []change
+	sc := bufio.NewScanner(sys.In())
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		sep := strings.IndexByte(line, ':')
+		if sep < 0 {
+			fmt.Fprintf(sys.Err(), "chpasswd: line %v: missing new password\n", n)
+			fmt.Fprintln(sys.Err(), "chpasswd: error detected, changes ignored")
+			return 1
+		}
+		name := line[:sep]
+		if honeyos.GetUser(name).Name == "" {
+			fmt.Fprintf(sys.Err(), "chpasswd: line %v: user '%v' does not exist\n", n, name)
+			fmt.Fprintln(sys.Err(), "chpasswd: error detected, changes ignored")
+			return 1
+		}
+		changes = append(changes, change{name, line[sep+1:]})
+	}
+	for _, ch := range changes {
+		sys.Log().WithField("account", ch.name).WithField("password", ch.pass).WithField("encrypted", encrypted).
+			Infof("User changed password of %v", ch.name)
+		if encrypted {
+			honeyos.SetPassword(ch.name, ch.pass)
+			editAccountFile(sys, "/etc/shadow", ch.name, shadowLine(ch.name, ch.pass))
+		} else {
+			setPassword(sys, ch.name, ch.pass)
+		}
+	}
+	return 0
+}
+
+// setPassword changes the password of the account, so the user can login
+// with it, and writes its hash to /etc/shadow
+func setPassword(sys honeyos.Sys, name, pass string) {
+	honeyos.SetPassword(name, pass)
+	editAccountFile(sys, "/etc/shadow", name, shadowLine(name, cryptPassword(pass)))
+}
+
+// passwordHash reads the password of the account in /etc/shadow
+func passwordHash(sys honeyos.Sys, name string) string {
+	data, _ := afero.ReadFile(honeyos.SetUIDFs(sys.FSys()), "/etc/shadow")
+	for _, line := range strings.Split(string(data), "\n") {
+		if fields := strings.Split(line, ":"); len(fields) > 1 && fields[0] == name {
+			return fields[1]
+		}
+	}
+	return "*"
+}
+
+// readPassword prompts and reads the line with echo off, like getpass(3).
+// ok is false if the input ends before the line does
+func readPassword(sys honeyos.Sys, prompt string) (string, bool) {
+	return readPasswordFrom(sys, sys.In(), prompt)
+}
+
+// readPasswordFrom reads the password from in, which is the terminal for
+// commands like ssh that reads it from /dev/tty
+func readPasswordFrom(sys honeyos.Sys, in io.Reader, prompt string) (string, bool) {
+	fmt.Fprint(sys.Err(), prompt)
+	if t := sys.Termios(); t != nil && t.Flag("echo") {
+		t.SetFlag("echo", false)
+		defer t.SetFlag("echo", true)
+	}
+	// Read byte by byte so the next line is left for the next prompt
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := in.Read(b)
+		if n > 0 {
+			if b[0] == '\n' {
+				break
+			}
+			line = append(line, b[0])
+		}
+		if err != nil {
+			if honeyos.IsTerminal(in) {
+				fmt.Fprintln(sys.Err())
+			}
+			return string(line), false
+		}
+	}
+	if honeyos.IsTerminal(in) {
+		fmt.Fprintln(sys.Err())
+	}
+	return strings.TrimSuffix(string(line), "\r"), true
+}
@@ -0,0 +1,251 @@
+package command
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+)
+
+// ping sends echo requests to the host over the fake network, printing the
+// replies at the interval like the real one. Ctrl-C shows the statistics
+type ping struct{}
+
+const pingUsage = `Usage: ping [-aAbBdDfhLnOqrRUvV] [-c count] [-i interval] [-I interface]
+            [-m mark] [-M pmtudisc_option] [-l preload] [-p pattern] [-Q tos]
+            [-s packetsize] [-S sndbuf] [-t ttl] [-T timestamp_option]
+            [-w deadline] [-W timeout] [hop1 ...] destination
+`
+
+func init() {
+	honeyos.RegisterCommand("ping", ping{})
+}
+
+func (ping) GetHelp() string {
+	return pingUsage
+}
+
+func (ping) Where() string {
+	return "/bin/ping"
+}
+
+func (p ping) Exec(args []string, sys honeyos.Sys) int {
+	busybox := pkgFamily() == "apk"
+	count, size, ttl := 0, 56, 0
+	interval, deadline := time.Second, time.Duration(0)
+	quiet := false
+	host := ""
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			host = arg
+			continue
+		}
+		for j := 1; j < len(arg); j++ {
+			c := arg[j]
+			if strings.IndexByte("ciwWstIpmMlQST", c) >= 0 {
+				val := arg[j+1:]
+				if val == "" {
+					if i+1 >= len(args) {
+						fmt.Fprintf(sys.Err(), "ping: option requires an argument -- '%c'\n", c)
+						fmt.Fprint(sys.Err(), pingUsage)
+						return 2
+					}
+					i++
+					val = args[i]
+				}
+				n, err := strconv.ParseFloat(val, 64)
+				if strings.IndexByte("ciwWst", c) >= 0 && (err != nil || n < 0) {
+					fmt.Fprintf(sys.Err(), "ping: bad %v.\n", map[byte]string{'c': "number of packets to transmit", 'i': "timing interval",
+						'w': "wait time", 'W': "linger time", 's': "packet size", 't': "TTL"}[c])
+					return 2
+				}
+				switch c {
+				case 'c':
+					count = int(n)
+				case 'i':
+					interval = time.Duration(n * float64(time.Second))
+					if interval < 200*time.Millisecond && !isRoot(sys) {
+						fmt.Fprintln(sys.Err(), "ping: cannot flood; minimal interval allowed for user is 200ms")
+						return 2
+					}
+				case 'w':
+					deadline = time.Duration(n * float64(time.Second))
+				case 's':
+					size = int(n)
+				case 't':
+					ttl = int(n)
+				}
+				break
+			}
+			switch c {
+			case 'q':
+				quiet = true
+			case 'f':
+				if !isRoot(sys) {
+					fmt.Fprintln(sys.Err(), "ping: cannot flood; minimal interval allowed for user is 200ms")
+					return 2
+				}
+				interval = 10 * time.Millisecond
+			case 'V':
+				fmt.Fprintln(sys.Out(), "ping utility, iputils-s20121221")
+				return 0
+			case 'h':
+				fmt.Fprint(sys.Err(), pingUsage)
+				return 2
+			case '4', '6', 'a', 'A', 'b', 'B', 'd', 'D', 'L', 'n', 'O', 'r', 'R', 'U', 'v':
+			default:
+				fmt.Fprintf(sys.Err(), "ping: invalid option -- '%c'\n", c)
+				fmt.Fprint(sys.Err(), pingUsage)
+				return 2
+			}
+		}
+	}
+	if host == "" {
+		fmt.Fprint(sys.Err(), pingUsage)
+		return 2
+	}
+	ip := netResolve(sys, host)
+	if ip == nil {
+		switch {
+		case busybox:
+			fmt.Fprintf(sys.Err(), "ping: bad address '%v'\n", host)
+		case pkgFamily() == "rpm":
+			fmt.Fprintf(sys.Err(), "ping: %v: Name or service not known\n", host)
+		default:
+			fmt.Fprintf(sys.Err(), "ping: unknown host %v\n", host)
+		}
+		return 2
+	}
+	sys.Log().WithField("host", host).WithField("ip", ip.String()).Infof("User pinging %v", host)
+	route := netTrace(sys, ip)
+	if busybox {
+		fmt.Fprintf(sys.Out(), "PING %v (%v): %v data bytes\n", host, ip, size)
+	} else {
+		fmt.Fprintf(sys.Out(), "PING %v (%v) %v(%v) bytes of data.\n", host, ip, size, size+28)
+	}
+
+	intr := honeyos.Interrupt(sys)
+	var stop <-chan time.Time
+	if deadline > 0 {
+		stop = time.After(deadline)
+	}
+	// wait returns false once ping should stop
+	wait := func(d time.Duration) bool {
+		select {
+		case <-time.After(d):
+			return true
+		case <-intr:
+		case <-stop:
+		case <-sys.Context().Done():
+		}
+		return false
+	}
+	var rtts []float64
+	sent, errors := 0, 0
+	start := time.Now()
+	// Requests are sent at the interval, regardless of the time waiting for
+	// the reply
+	var waited time.Duration
+loop:
+	for seq := 1; count == 0 || seq <= count; seq++ {
+		if seq > 1 && !wait(interval-waited) {
+			break
+		}
+		waited = 0
+		sent++
+		icmpSeq := fmt.Sprintf("icmp_seq=%v", seq)
+		if busybox {
+			icmpSeq = fmt.Sprintf("seq=%v", seq-1)
+		}
+		switch {
+		case ttl > 0 && ttl <= len(route.hops):
+			if hop := route.hops[ttl-1]; hop != nil {
+				errors++
+				if !quiet {
+					fmt.Fprintf(sys.Out(), "From %v %v Time to live exceeded\n", hop, icmpSeq)
+				}
+			}
+		case route.alive:
+			rtt := route.hopRTT(len(route.hops))
+			if !wait(rtt) {
+				break loop
+			}
+			waited = rtt
+			ms := float64(rtt) / float64(time.Millisecond)
+			rtts = append(rtts, ms)
+			if !quiet {
+				fmt.Fprintf(sys.Out(), "%v bytes from %v: %v ttl=%v time=%v ms\n", size+8, ip, icmpSeq, route.ttl, pingTime(rtt, busybox))
+			}
+		case route.local:
+			errors++
+			if !quiet {
+				fmt.Fprintf(sys.Out(), "From %v %v Destination Host Unreachable\n", honeyos.IPAddress(), icmpSeq)
+			}
+		}
+	}
+	elapsed := int64(time.Since(start) / time.Millisecond)
+
+	loss := 0
+	if sent > 0 {
+		loss = (sent - len(rtts)) * 100 / sent
+	}
+	fmt.Fprintf(sys.Out(), "\n--- %v ping statistics ---\n", host)
+	min, avg, max, mdev := pingStats(rtts)
+	if busybox {
+		fmt.Fprintf(sys.Out(), "%v packets transmitted, %v packets received, %v%% packet loss\n", sent, len(rtts), loss)
+		if len(rtts) > 0 {
+			fmt.Fprintf(sys.Out(), "round-trip min/avg/max = %.3f/%.3f/%.3f ms\n", min, avg, max)
+		}
+	} else {
+		errs := ""
+		if errors > 0 {
+			errs = fmt.Sprintf("+%v errors, ", errors)
+		}
+		fmt.Fprintf(sys.Out(), "%v packets transmitted, %v received, %v%v%% packet loss, time %vms\n", sent, len(rtts), errs, loss, elapsed)
+		if len(rtts) > 0 {
+			fmt.Fprintf(sys.Out(), "rtt min/avg/max/mdev = %.3f/%.3f/%.3f/%.3f ms\n", min, avg, max, mdev)
+		}
+	}
+	if len(rtts) == 0 {
+		return 1
+	}
+	return 0
+}
+
+// pingTime formats the round trip time with the precision iputils uses
+func pingTime(d time.Duration, busybox bool) string {
+	ms := float64(d) / float64(time.Millisecond)
+	switch {
+	case busybox:
+		return fmt.Sprintf("%.3f", ms)
+	case ms >= 100:
+		return fmt.Sprintf("%.0f", ms)
+	case ms >= 10:
+		return fmt.Sprintf("%.1f", ms)
+	case ms >= 1:
+		return fmt.Sprintf("%.2f", ms)
+	}
+	return fmt.Sprintf("%.3f", ms)
+}
+
+// pingStats computes the min, average, max and mean deviation of the round
+// trip times in ms
+func pingStats(rtts []float64) (min, avg, max, mdev float64) {
+	if len(rtts) == 0 {
+		return
+	}
+	min = rtts[0]
+	var sum, sum2 float64
+	for _, t := range rtts {
+		min, max = math.Min(min, t), math.Max(max, t)
+		sum += t
+		sum2 += t * t
+	}
+	avg = sum / float64(len(rtts))
+	mdev = math.Sqrt(math.Max(sum2/float64(len(rtts))-avg*avg, 0))
+	return
+}
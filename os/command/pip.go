@@ -0,0 +1,530 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/afero"
+)
+
+// pip installs the Python packages, recording them in the site packages of
+// the system or of the user with --user. The packages requested are logged
+// as they tell what the attacker runs next
+type pip struct {
+	name string
+	v3   bool
+}
+
+// pipEnv is the pip of the distribution and where it installs
+type pipEnv struct {
+	version, python string
+	site, userSite  string
+}
+
+const pipUsage = `
+Usage:
+  %[1]v <command> [options]
+
+Commands:
+  install                     Install packages.
+  download                    Download packages.
+  uninstall                   Uninstall packages.
+  freeze                      Output installed packages in requirements format.
+  list                        List installed packages.
+  show                        Show information about installed packages.
+  search                      Search PyPI for packages.
+  wheel                       Build wheels from your requirements.
+  hash                        Compute hashes of package archives.
+  completion                  A helper command used for command completion
+  help                        Show help for commands.
+
+General Options:
+  -h, --help                  Show help.
+  --isolated                  Run pip in an isolated mode, ignoring
+                              environment variables and user configuration.
+  -v, --verbose               Give more output. Option is additive, and can be
+                              used up to 3 times.
+  -V, --version               Show version and exit.
+  -q, --quiet                 Give less output.
+  --log <path>                Path to a verbose appending log.
+  --proxy <proxy>             Specify a proxy in the form
+                              [user:passwd@]proxy.server:port.
+  --retries <retries>         Maximum number of retries each connection should
+                              attempt (default 5 times).
+  --timeout <sec>             Set the socket timeout (default 15 seconds).
+  --cache-dir <dir>           Store the cache data in <dir>.
+  --no-cache-dir              Disable the cache.
+  --disable-pip-version-check
+                              Don't periodically check PyPI to determine
+                              whether a new version of pip is available for
+                              download. Implied with --no-index.
+`
+
+func init() {
+	honeyos.RegisterCommand("pip", pip{"pip", false})
+	honeyos.RegisterCommand("pip2", pip{"pip2", false})
+	honeyos.RegisterCommand("pip3", pip{"pip3", true})
+}
+
+func (p pip) GetHelp() string {
+	return fmt.Sprintf(pipUsage, p.name)
+}
+
+func (p pip) Where() string {
+	return "/usr/bin/" + p.name
+}
+
+// env returns the pip of the distribution, with the site packages of the
+// user running it
+func (p pip) env(sys honeyos.Sys) pipEnv {
+	builds, ok := pyBuilds[honeyos.Distro()]
+	if !ok {
+		builds = pyBuilds["ubuntu"]
+	}
+	build := builds[0]
+	if p.v3 || pkgFamily() == "apk" {
+		build = builds[1]
+	}
+	python := build.version[:strings.LastIndex(build.version, ".")]
+	env := pipEnv{python: python, site: "/usr/local/lib/python" + python + "/dist-packages",
+		userSite: honeyos.GetUserByID(sys.CurrentUser()).Homedir + "/.local/lib/python" + python + "/site-packages"}
+	switch {
+	case pkgFamily() == "apk":
+		env.version, env.site = "20.3.4", "/usr/lib/python"+python+"/site-packages"
+	case pkgFamily() == "rpm":
+		env.version, env.site = "9.0.3", "/usr/local/lib/python"+python+"/site-packages"
+	case honeyos.Distro() == "debian":
+		env.version = "9.0.1"
+	default:
+		env.version = "8.1.1"
+	}
+	return env
+}
+
+// modern tells if the pip is 10 or later, which words its messages
+// differently
+func (e pipEnv) modern() bool {
+	return !strings.HasPrefix(e.version, "8.") && !strings.HasPrefix(e.version, "9.")
+}
+
+// installed tells if the package of the pip is installed
+func (p pip) installed(sys honeyos.Sys) bool {
+	pkg := "python-pip"
+	if p.v3 {
+		pkg = map[bool]string{true: "py3-pip", false: "python3-pip"}[pkgFamily() == "apk"]
+	}
+	return loadPkgDB(sys, pkgFamily()).installed(pkg)
+}
+
+func (p pip) Exec(args []string, sys honeyos.Sys) int {
+	if !p.installed(sys) {
+		return honeyos.CommandNotFound(sys, append([]string{p.name}, args...))
+	}
+	env := p.env(sys)
+	var cmd string
+	var rest []string
+	for i, arg := range args {
+		switch {
+		case arg == "-V" || arg == "--version":
+			fmt.Fprintf(sys.Out(), "pip %v from %v/pip (python %v)\n", env.version, pipLib(env), env.python)
+			return 0
+		case arg == "-h" || arg == "--help":
+			if cmd == "" {
+				fmt.Fprint(sys.Out(), p.GetHelp())
+				return 0
+			}
+			rest = append(rest, arg)
+		case cmd == "" && !strings.HasPrefix(arg, "-"):
+			cmd, rest = arg, args[i+1:]
+		}
+		if cmd != "" {
+			break
+		}
+	}
+	switch cmd {
+	case "":
+		fmt.Fprint(sys.Out(), p.GetHelp())
+		return 0
+	case "install", "download":
+		return p.install(sys, env, cmd, rest)
+	case "uninstall":
+		return p.uninstall(sys, env, rest)
+	case "list", "freeze":
+		return p.list(sys, env, cmd, rest)
+	case "show":
+		return p.show(sys, env, rest)
+	case "help":
+		fmt.Fprint(sys.Out(), p.GetHelp())
+		return 0
+	}
+	fmt.Fprintf(sys.Err(), "ERROR: unknown command \"%v\"\n", cmd)
+	return 1
+}
+
+// pipLib is where pip itself is installed
+func pipLib(env pipEnv) string {
+	if strings.HasPrefix(env.site, "/usr/local") && !strings.HasSuffix(env.site, "site-packages") {
+		if strings.HasPrefix(env.python, "3") {
+			return "/usr/lib/python3/dist-packages"
+		}
+		return "/usr/lib/python" + env.python + "/dist-packages"
+	}
+	return strings.Replace(env.site, "/usr/local/lib", "/usr/lib", 1)
+}
+
+// pipRequirement splits the requirement like paramiko==2.4.2 or
+// requests[socks]>=2 to the name and the version pinned. The name of URLs
+// and archives is the base name
+func pipRequirement(spec string) (name, version string) {
+	spec = strings.TrimSpace(spec)
+	if strings.Contains(spec, "://") || strings.HasSuffix(spec, ".whl") || strings.HasSuffix(spec, ".tar.gz") {
+		if i := strings.Index(spec, "#egg="); i >= 0 {
+			return spec[i+5:], ""
+		}
+		base := path.Base(strings.TrimSuffix(spec, "/"))
+		for _, suffix := range []string{".git", ".zip", ".tar.gz", ".whl"} {
+			base = strings.TrimSuffix(base, suffix)
+		}
+		if i := strings.Index(base, "-"); i > 0 && strings.HasSuffix(spec, ".whl") {
+			base = base[:i]
+		}
+		return base, ""
+	}
+	name = spec
+	if i := strings.IndexAny(spec, "=<>!~;[ "); i >= 0 {
+		name = spec[:i]
+		if j := strings.Index(spec, "=="); j >= 0 {
+			version = strings.TrimSpace(strings.SplitN(spec[j+2:], ";", 2)[0])
+		}
+	}
+	return name, version
+}
+
+func (p pip) install(sys honeyos.Sys, env pipEnv, cmd string, args []string) int {
+	var specs []string
+	user, upgrade, quiet := false, false, false
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--user":
+			user = true
+		case arg == "-U" || arg == "--upgrade":
+			upgrade = true
+		case arg == "-q" || arg == "--quiet":
+			quiet = true
+		case arg == "-r" || arg == "--requirement" || strings.HasPrefix(arg, "-r") && len(arg) > 2:
+			file := strings.TrimPrefix(arg, "-r")
+			if file == "" || file == "--requirement" {
+				if i++; i >= len(args) {
+					fmt.Fprintf(sys.Err(), "\nUsage:   \n  %v %v [options] <requirement specifier> [package-index-options] ...\n\n%v: error: -r option requires an argument\n", p.name, cmd, p.name)
+					return 2
+				}
+				file = args[i]
+			}
+			data, err := readFile(sys, absPath(sys, file))
+			if err != nil {
+				fmt.Fprintf(sys.Err(), "Could not open requirements file: [Errno 2] No such file or directory: '%v'\n", file)
+				return 1
+			}
+			for _, line := range strings.Split(string(data), "\n") {
+				if line = strings.TrimSpace(strings.SplitN(line, "#", 2)[0]); line != "" && !strings.HasPrefix(line, "-") {
+					specs = append(specs, line)
+				}
+			}
+		case arg == "-i" || arg == "--index-url" || arg == "--extra-index-url" || arg == "--proxy" ||
+			arg == "-t" || arg == "--target" || arg == "-d" || arg == "--dest" || arg == "--trusted-host":
+			i++
+		case arg == "-e" || arg == "--editable":
+		case !strings.HasPrefix(arg, "-"):
+			specs = append(specs, arg)
+		}
+	}
+	if len(specs) == 0 {
+		fmt.Fprintf(sys.Err(), "ERROR: You must give at least one requirement to %v (see \"pip help %v\")\n", cmd, cmd)
+		return 1
+	}
+	logPkgRequest(sys, p.name, cmd, specs)
+	out := sys.Out()
+	if quiet {
+		out = ioutil.Discard
+	}
+
+	var names []string
+	versions := map[string]string{}
+	for _, spec := range specs {
+		name, version := pipRequirement(spec)
+		names = append(names, name)
+		versions[strings.ToLower(name)] = version
+	}
+	site := env.site
+	if user {
+		site = env.userSite
+	}
+	installed := langInstalled(sys, env.site, ".dist-info")
+	for name, v := range langInstalled(sys, env.userSite, ".dist-info") {
+		installed[name] = v
+	}
+	var pkgs []langPkg
+	requested := map[string]bool{}
+	for i, p := range langResolve(pipCatalog, names, versions) {
+		requested[strings.ToLower(p.name)] = i < len(names)
+		if _, ok := installed[p.name]; ok && (!upgrade || !requested[strings.ToLower(p.name)]) && cmd == "install" {
+			if env.modern() {
+				fmt.Fprintf(out, "Requirement already satisfied: %v in %v\n", p.name, site)
+			} else if i < len(names) {
+				fmt.Fprintf(out, "Requirement already satisfied (use --upgrade to upgrade): %v in %v\n", p.name, site)
+			}
+			continue
+		}
+		// Repositories and archives are built from source
+		p.native = p.native || strings.Contains(specFor(specs, p.name), "://")
+		pkgs = append(pkgs, p)
+	}
+	for _, p := range pkgs {
+		if requested[strings.ToLower(p.name)] {
+			fmt.Fprintf(out, "Collecting %v\n", specFor(specs, p.name))
+		} else {
+			fmt.Fprintf(out, "Collecting %v (from %v)\n", p.name, pipParent(pkgs, p.name))
+		}
+		file, spec := pipArchive(p), specFor(specs, p.name)
+		remote := strings.Contains(spec, "://")
+		if remote {
+			url := strings.SplitN(strings.TrimPrefix(spec, "git+"), "#", 2)[0]
+			if strings.HasPrefix(spec, "git+") {
+				fmt.Fprintf(out, "  Cloning %v to /tmp/pip-%x-build\n", url, fnvString(url)&0xffffff)
+			} else {
+				fmt.Fprintf(out, "  Downloading %v\n", url)
+			}
+		} else if env.modern() {
+			fmt.Fprintf(out, "  Downloading %v (%v kB)\n", file, p.size)
+		} else {
+			fmt.Fprintf(out, "  Downloading %v (%vkB)\n", file, p.size)
+		}
+		if !langDownload(sys, p) {
+			fmt.Fprintln(sys.Err(), "Operation cancelled by user")
+			return 1
+		}
+		switch {
+		case remote:
+		case env.modern():
+			fmt.Fprintf(out, "     |%v| %v kB %.1f MB/s \n", strings.Repeat("█", 32), p.size, langSpeed(p)/1000)
+		default:
+			fmt.Fprintf(out, "    100%% |%v| %vkB %.1fMB/s \n", strings.Repeat("█", 32), p.size, langSpeed(p)/1000)
+		}
+		if cmd == "download" {
+			afero.WriteFile(sys.FSys(), absPath(sys, file), []byte{}, 0666&^sys.Umask())
+		}
+	}
+	if cmd == "download" {
+		var saved []string
+		for _, p := range pkgs {
+			saved = append(saved, p.name)
+		}
+		fmt.Fprintf(out, "Successfully downloaded %v\n", strings.Join(saved, " "))
+		return 0
+	}
+	if len(pkgs) == 0 {
+		return 0
+	}
+	var order []string
+	for i := len(pkgs) - 1; i >= 0; i-- {
+		order = append(order, pkgs[i].name)
+	}
+	fmt.Fprintf(out, "Installing collected packages: %v\n", strings.Join(order, ", "))
+	if !user && !isRoot(sys) {
+		denied := fmt.Sprintf("[Errno 13] Permission denied: '%v/%v'", site, strings.ToLower(pkgs[len(pkgs)-1].name))
+		if env.modern() {
+			fmt.Fprintf(sys.Err(), "ERROR: Could not install packages due to an EnvironmentError: %v\nConsider using the `--user` option or check the permissions.\n\n", denied)
+		} else {
+			fmt.Fprintf(sys.Err(), "Exception:\nTraceback (most recent call last):\n"+
+				"  File \"%[1]v/pip/basecommand.py\", line 215, in main\n    status = self.run(options, args)\n"+
+				"  File \"%[1]v/pip/commands/install.py\", line 342, in run\n    prefix=options.prefix_path,\n"+
+				"  File \"%[1]v/pip/req/req_set.py\", line 784, in install\n    **kwargs\n"+
+				"  File \"/usr/lib/python%[2]v/shutil.py\", line 303, in move\n    os.rename(src, real_dst)\nOSError: %[3]v\n",
+				pipLib(env), env.python, denied)
+		}
+		return 1
+	}
+	var done []string
+	for i := len(pkgs) - 1; i >= 0; i-- {
+		pkg := pkgs[i]
+		if pkg.native {
+			fmt.Fprintf(out, "  Running setup.py install for %v ... ", pkg.name)
+			if !langBuild(sys, pkg) {
+				fmt.Fprintln(sys.Err(), "Operation cancelled by user")
+				return 1
+			}
+			fmt.Fprintln(out, "done")
+		}
+		if v, ok := installed[pkg.name]; ok {
+			fmt.Fprintf(out, "  Found existing installation: %v %v\n    Uninstalling %v-%v:\n      Successfully uninstalled %v-%v\n",
+				pkg.name, v, pkg.name, v, pkg.name, v)
+			sys.FSys().RemoveAll(fmt.Sprintf("%v/%v-%v.dist-info", site, pkg.name, v))
+		}
+		langWrite(sys, fmt.Sprintf("%v/%v-%v.dist-info/METADATA", site, pkg.name, pkg.version),
+			fmt.Sprintf("Metadata-Version: 2.1\nName: %v\nVersion: %v\nRequires-Dist: %v\n", pkg.name, pkg.version, strings.Join(pkg.deps, ", ")))
+		langMkdir(sys, site+"/"+strings.ToLower(strings.Replace(pkg.name, "-", "_", -1)))
+		done = append(done, pkg.name+"-"+pkg.version)
+	}
+	fmt.Fprintf(out, "Successfully installed %v\n", strings.Join(done, " "))
+	if !env.modern() {
+		fmt.Fprintf(sys.Err(), "You are using pip version %v, however version 20.3.4 is available.\n"+
+			"You should consider upgrading via the 'pip install --upgrade pip' command.\n", env.version)
+	}
+	return 0
+}
+
+// specFor is the requirement given for the package
+func specFor(specs []string, name string) string {
+	for _, spec := range specs {
+		if n, _ := pipRequirement(spec); strings.EqualFold(n, name) {
+			return spec
+		}
+	}
+	return name
+}
+
+// pipParent is the package depending on the package
+func pipParent(pkgs []langPkg, name string) string {
+	for _, p := range pkgs {
+		for _, d := range p.deps {
+			if strings.EqualFold(d, name) {
+				return p.name
+			}
+		}
+	}
+	return name
+}
+
+// pipArchive is the file downloaded for the package, a wheel or the source
+// of the packages built
+func pipArchive(p langPkg) string {
+	if p.native {
+		return fmt.Sprintf("%v-%v.tar.gz", p.name, p.version)
+	}
+	return fmt.Sprintf("%v-%v-py2.py3-none-any.whl", strings.Replace(p.name, "-", "_", -1), p.version)
+}
+
+func (p pip) uninstall(sys honeyos.Sys, env pipEnv, args []string) int {
+	var names []string
+	yes := false
+	for _, arg := range args {
+		switch {
+		case arg == "-y" || arg == "--yes":
+			yes = true
+		case !strings.HasPrefix(arg, "-"):
+			name, _ := pipRequirement(arg)
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		fmt.Fprintln(sys.Err(), "ERROR: You must give at least one requirement to uninstall (see \"pip help uninstall\")")
+		return 1
+	}
+	logPkgRequest(sys, p.name, "uninstall", names)
+	for _, name := range names {
+		site := env.site
+		version, ok := langInstalled(sys, site, ".dist-info")[name]
+		if !ok {
+			site = env.userSite
+			version, ok = langInstalled(sys, site, ".dist-info")[name]
+		}
+		if !ok {
+			if env.modern() {
+				fmt.Fprintf(sys.Err(), "WARNING: Skipping %v as it is not installed.\n", name)
+				continue
+			}
+			fmt.Fprintf(sys.Err(), "Cannot uninstall requirement %v, not installed\n", name)
+			return 1
+		}
+		if site == env.site && !isRoot(sys) {
+			fmt.Fprintf(sys.Err(), "OSError: [Errno 13] Permission denied: '%v/%v-%v.dist-info'\n", site, name, version)
+			return 1
+		}
+		dir := site + "/" + strings.ToLower(strings.Replace(name, "-", "_", -1))
+		fmt.Fprintf(sys.Out(), "Uninstalling %v-%v:\n", name, version)
+		if !yes {
+			fmt.Fprintf(sys.Out(), "  Would remove:\n    %v/%v-%v.dist-info/*\n    %v/*\n", site, name, version, dir)
+			if !pkgConfirm(sys, "Proceed (y/n)? ", false) {
+				continue
+			}
+		}
+		sys.FSys().RemoveAll(fmt.Sprintf("%v/%v-%v.dist-info", site, name, version))
+		sys.FSys().RemoveAll(dir)
+		fmt.Fprintf(sys.Out(), "  Successfully uninstalled %v-%v\n", name, version)
+	}
+	return 0
+}
+
+// pipBase are the packages installed with pip itself
+var pipBase = map[string]string{"pip": "", "setuptools": "20.7.0", "wheel": "0.29.0"}
+
+func (p pip) list(sys honeyos.Sys, env pipEnv, cmd string, args []string) int {
+	pkgs := map[string]string{}
+	for name, v := range pipBase {
+		pkgs[name] = v
+	}
+	pkgs["pip"] = env.version
+	for name, v := range langInstalled(sys, env.site, ".dist-info") {
+		pkgs[name] = v
+	}
+	for name, v := range langInstalled(sys, env.userSite, ".dist-info") {
+		pkgs[name] = v
+	}
+	names := langSorted(pkgs)
+	switch {
+	case cmd == "freeze":
+		for _, name := range names {
+			if _, base := pipBase[name]; !base {
+				fmt.Fprintf(sys.Out(), "%v==%v\n", name, pkgs[name])
+			}
+		}
+	case env.modern():
+		width := len("Package")
+		for _, name := range names {
+			if len(name) > width {
+				width = len(name)
+			}
+		}
+		fmt.Fprintf(sys.Out(), "%-*v Version\n%v -------\n", width, "Package", strings.Repeat("-", width))
+		for _, name := range names {
+			fmt.Fprintf(sys.Out(), "%-*v %v\n", width, name, pkgs[name])
+		}
+	default:
+		for _, name := range names {
+			fmt.Fprintf(sys.Out(), "%v (%v)\n", name, pkgs[name])
+		}
+	}
+	return 0
+}
+
+func (p pip) show(sys honeyos.Sys, env pipEnv, args []string) int {
+	status := 1
+	for _, name := range args {
+		if strings.HasPrefix(name, "-") {
+			continue
+		}
+		site := env.site
+		version, ok := langInstalled(sys, site, ".dist-info")[name]
+		if !ok {
+			site = env.userSite
+			version, ok = langInstalled(sys, site, ".dist-info")[name]
+		}
+		if !ok {
+			continue
+		}
+		if status == 0 {
+			fmt.Fprintln(sys.Out(), "---")
+		}
+		status = 0
+		pkg := langLookup(pipCatalog, name)
+		fmt.Fprintf(sys.Out(), "Name: %v\nVersion: %v\nSummary: \nHome-page: https://pypi.org/project/%v/\nAuthor: \nAuthor-email: \nLicense: \nLocation: %v\nRequires: %v\n",
+			name, version, name, site, strings.Join(pkg.deps, ", "))
+	}
+	if status != 0 && env.modern() {
+		fmt.Fprintf(sys.Err(), "WARNING: Package(s) not found: %v\n", strings.Join(args, ", "))
+	}
+	return status
+}
@@ -0,0 +1,497 @@
+package command
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+// pkgInfo is a package in the fake repository of package managers
+type pkgInfo struct {
+	name string
+	// version is the Debian version, which is converted for other formats
+	version string
+	// size is the installed size in kB
+	size int
+	deps []string
+	// bins are the executables installed, which become stubs in the
+	// virtual filesystem
+	bins []string
+	desc string
+	// family limits the package to deb, rpm or apk based distributions
+	family string
+}
+
+var pkgCatalog = []pkgInfo{
+	{"libpcap0.8", "1.7.4-2", 390, nil, nil, "system interface for user-level packet capture", "deb"},
+	{"libpcap", "1.5.3-12.el7", 330, nil, nil, "A system-independent interface for user-level packet capture", "rpm"},
+	{"libpcap", "1.9.1-r0", 280, nil, nil, "A system-independent interface for user-level packet capture", "apk"},
+	{"libblas3", "3.6.0-2ubuntu2", 616, nil, nil, "Basic Linear Algebra Reference implementations, shared library", "deb"},
+	{"liblinear3", "2.1.0+dfsg-1", 109, []string{"libblas3"}, nil, "Library for Large Linear Classification", "deb"},
+	{"liblua5.2-0", "5.2.4-1ubuntu1", 396, nil, nil, "Shared library for the Lua interpreter version 5.2", "deb"},
+	{"libssh-4", "0.6.3-4.3ubuntu0.6", 440, nil, nil, "tiny C SSH library (OpenSSL flavor)", "deb"},
+	{"libevent-2.0-5", "2.0.21-stable-2ubuntu0.16.04.1", 389, nil, nil, "Asynchronous event notification library", "deb"},
+	{"libutempter0", "1.1.6-3", 47, nil, nil, "privileged helper for utmp/wtmp updates (runtime)", "deb"},
+	{"libproxychains3", "3.1-7", 48, nil, nil, "proxy chains -- shared library", "deb"},
+	{"liberror-perl", "0.17-1.2", 66, nil, nil, "Perl module for error/exception handling in an OO-ish way", "deb"},
+	{"zlib1g-dev", "1:1.2.8.dfsg-2ubuntu4.3", 416, nil, nil, "compression library - development", "deb"},
+	{"libssl-dev", "1.0.2g-1ubuntu4.20", 6552, []string{"zlib1g-dev"}, nil, "Secure Sockets Layer toolkit - development files", "deb"},
+	{"openssl-devel", "1.0.2k-19.el7", 3153, nil, nil, "Files for development of applications which will use OpenSSL", "rpm"},
+	{"openssl", "1.0.2g-1ubuntu4.20", 934, nil, []string{"/usr/bin/openssl"}, "Secure Sockets Layer toolkit - cryptographic utility", "deb"},
+	{"openssl", "1:1.0.2k-26.el7_9", 1557, nil, []string{"/usr/bin/openssl"},
+		"Utilities from the general purpose cryptography library with TLS implementation", "rpm"},
+	{"openssl", "1.1.1l-r8", 508, []string{"libcrypto1.1", "libssl1.1"}, []string{"/usr/bin/openssl"},
+		"Toolkit for Transport Layer Security (TLS)", "apk"},
+	{"nmap", "7.01-2ubuntu2", 4364, []string{"libpcap0.8", "libpcap", "libblas3", "liblinear3", "liblua5.2-0"},
+		[]string{"/usr/bin/nmap"}, "The Network Mapper", ""},
+	{"masscan", "1.0.3-95-gb395f18~ds0-2", 816, []string{"libpcap0.8", "libpcap"}, []string{"/usr/bin/masscan"},
+		"TCP port scanner", ""},
+	{"hydra", "8.1-1build1", 620, []string{"libssh-4"}, []string{"/usr/bin/hydra", "/usr/bin/pw-inspector"},
+		"very fast network logon cracker", ""},
+	{"john", "1.8.0-2", 1255, nil, []string{"/usr/sbin/john", "/usr/sbin/unshadow"},
+		"active password cracking tool", ""},
+	{"netcat-openbsd", "1.105-7ubuntu1", 109, nil, []string{"/bin/nc.openbsd"}, "TCP/IP swiss army knife", "deb"},
+	{"netcat-traditional", "1.10-41", 132, nil, []string{"/bin/nc.traditional"}, "TCP/IP swiss army knife", "deb"},
+	{"netcat", "1.10-41", 132, nil, []string{"/bin/nc.traditional"}, "TCP/IP swiss army knife", "deb"},
+	{"rsync", "3.1.1-3ubuntu1.3", 647, nil, []string{"/usr/bin/rsync"}, "fast, versatile, remote (and local) file-copying tool", ""},
+	{"telnet", "0.17-40", 167, nil, []string{"/usr/bin/telnet"}, "basic telnet client", ""},
+	{"busybox-extras", "1.34.1-r3", 148, nil, []string{"/usr/bin/telnet", "/usr/bin/ftpget", "/usr/bin/ftpput"},
+		"Additional binaries of Busybox", "apk"},
+	{"ftp", "0.17-33", 138, nil, []string{"/usr/bin/ftp"}, "classical file transfer client", ""},
+	{"nmap-ncat", "6.40-19.el7", 477, []string{"libpcap"}, []string{"/usr/bin/ncat"}, "Nmap's Netcat replacement", "rpm"},
+	{"netcat-openbsd", "1.130-r1", 60, nil, []string{"/usr/bin/nc"}, "Netcat OpenBSD variant", "apk"},
+	{"socat", "1.7.3.1-1", 1124, nil, []string{"/usr/bin/socat"}, "multipurpose relay for bidirectional data transfer", ""},
+	{"tcpdump", "4.9.3-0ubuntu0.16.04.1", 1080, []string{"libpcap0.8", "libpcap"}, []string{"/usr/sbin/tcpdump"},
+		"command-line network traffic analyzer", ""},
+	{"screen", "4.3.1-2build1", 1004, nil, []string{"/usr/bin/screen"}, "terminal multiplexer with VT100/ANSI terminal emulation", ""},
+	{"tmux", "2.1-3build1", 611, []string{"libevent-2.0-5", "libutempter0"}, []string{"/usr/bin/tmux"},
+		"terminal multiplexer", ""},
+	{"vim", "2:7.4.1689-3ubuntu1.4", 2202, nil, []string{"/usr/bin/vim.basic"}, "Vi IMproved - enhanced vi editor", ""},
+	{"nano", "2.5.3-2ubuntu2", 1596, nil, []string{"/bin/nano"}, "small, friendly text editor inspired by Pico", ""},
+	{"htop", "2.0.1-1ubuntu1", 216, nil, []string{"/usr/bin/htop"}, "interactive processes viewer", ""},
+	{"git-man", "1:2.7.4-0ubuntu1.10", 3310, nil, nil, "fast, scalable, distributed revision control system (manual pages)", "deb"},
+	{"git", "1:2.7.4-0ubuntu1.10", 22750, []string{"git-man", "liberror-perl"}, []string{"/usr/bin/git"},
+		"fast, scalable, distributed revision control system", ""},
+	{"cpp", "4:5.3.1-1ubuntu1", 30, nil, []string{"/usr/bin/cpp"}, "GNU C preprocessor (cpp)", "deb"},
+	{"gcc-5", "5.4.0-6ubuntu1~16.04.12", 17630, nil, []string{"/usr/bin/gcc-5"}, "GNU C compiler", "deb"},
+	{"gcc", "4:5.3.1-1ubuntu1", 41, []string{"cpp", "gcc-5"}, []string{"/usr/bin/gcc", "/usr/bin/cc"},
+		"GNU C compiler", ""},
+	{"g++-5", "5.4.0-6ubuntu1~16.04.12", 22723, []string{"gcc-5"}, []string{"/usr/bin/g++-5"}, "GNU C++ compiler", "deb"},
+	{"g++", "4:5.3.1-1ubuntu1", 16, []string{"g++-5", "gcc"}, []string{"/usr/bin/g++", "/usr/bin/c++"}, "GNU C++ compiler", "deb"},
+	{"gcc-c++", "4.8.5-44.el7", 17102, []string{"gcc"}, []string{"/usr/bin/g++", "/usr/bin/c++"}, "C++ support for GCC", "rpm"},
+	{"make", "4.1-6", 1228, nil, []string{"/usr/bin/make"}, "utility for directing compilation", ""},
+	{"dpkg-dev", "1.18.4ubuntu1.7", 1580, []string{"make"}, nil, "Debian package development tools", "deb"},
+	{"build-essential", "12.1ubuntu2", 20, []string{"gcc", "g++", "make", "dpkg-dev"}, nil,
+		"Informational list of build-essential packages", "deb"},
+	{"build-base", "0.5-r3", 4, []string{"gcc", "make"}, nil, "Meta package for build base", "apk"},
+	{"python", "2.7.12-1~16.04", 635, nil, []string{"/usr/bin/python2.7"}, "interactive high-level object-oriented language (default version)", "deb"},
+	{"python", "2.7.5-90.el7", 80, nil, []string{"/usr/bin/python2.7"}, "An interpreted, interactive, object-oriented programming language", "rpm"},
+	{"python2", "2.7.18-r0", 45000, nil, []string{"/usr/bin/python2"}, "A high-level scripting language", "apk"},
+	{"python3", "3.5.1-3", 67, nil, []string{"/usr/bin/python3.5"}, "interactive high-level object-oriented language (default python3 version)", ""},
+	{"python-pip", "8.1.1-2ubuntu0.6", 486, []string{"python"}, []string{"/usr/bin/pip", "/usr/bin/pip2"},
+		"Python package installer", "deb"},
+	{"python3-pip", "8.1.1-2ubuntu0.6", 486, []string{"python3"}, []string{"/usr/bin/pip3"}, "Python package installer", ""},
+	{"py3-pip", "20.3.4-r0", 10000, []string{"python3"}, []string{"/usr/bin/pip3"}, "Tool for installing and managing Python packages", "apk"},
+	{"unzip", "6.0-20ubuntu1.1", 524, nil, []string{"/usr/bin/unzip"}, "De-archiver for .zip files", ""},
+	{"zip", "3.0-11", 608, nil, []string{"/usr/bin/zip"}, "Archiver for .zip files", ""},
+	{"tor-geoipdb", "0.2.9.14-1ubuntu1~16.04.3", 4776, nil, nil, "GeoIP database for Tor", "deb"},
+	{"tor", "0.2.9.14-1ubuntu1~16.04.3", 5018, []string{"libevent-2.0-5", "tor-geoipdb"}, []string{"/usr/bin/tor"},
+		"anonymizing overlay network for TCP", ""},
+	{"proxychains", "3.1-7", 93, []string{"libproxychains3"}, []string{"/usr/bin/proxychains"},
+		"proxy chains - redirect connections through proxy servers", ""},
+	{"dnsutils", "1:9.10.3.dfsg.P4-8ubuntu1.19", 508, nil, []string{"/usr/bin/dig", "/usr/bin/nslookup", "/usr/bin/host"},
+		"Clients provided with BIND", "deb"},
+	{"bind-utils", "9.11.4-26.P2.el7", 1720, nil, []string{"/usr/bin/dig", "/usr/bin/nslookup", "/usr/bin/host"},
+		"Utilities for querying DNS name servers", "rpm"},
+	{"bind-tools", "9.16.20-r0", 3384, nil, []string{"/usr/bin/dig", "/usr/bin/nslookup", "/usr/bin/host"},
+		"The ISC DNS tools (dig, host, nslookup, nsupdate)", "apk"},
+	{"docker.io", "18.09.7-0ubuntu1~16.04.9", 194999, nil, []string{"/usr/bin/docker", "/usr/bin/dockerd"},
+		"Linux container runtime", "deb"},
+	{"docker", "1.13.1-209.git7d71120.el7", 63120, nil, []string{"/usr/bin/docker", "/usr/bin/dockerd"},
+		"Automates deployment of containerized applications", "rpm"},
+	{"docker", "20.10.11-r0", 4, nil, []string{"/usr/bin/docker", "/usr/bin/dockerd"}, "Pack, ship and run any application as a lightweight container", "apk"},
+	{"nodejs", "4.2.6~dfsg-1ubuntu4.2", 11479, nil, []string{"/usr/bin/nodejs"}, "evented I/O for V8 javascript", ""},
+	{"npm", "3.5.2-0ubuntu4", 9674, []string{"nodejs"}, []string{"/usr/bin/npm"}, "package manager for Node.js", ""},
+	{"golang", "2:1.6-1ubuntu4", 59, nil, []string{"/usr/bin/go", "/usr/bin/gofmt"}, "Go programming language compiler, linker, compiled stdlib", ""},
+	{"ruby", "1:2.3.0+1", 31, nil, []string{"/usr/bin/ruby", "/usr/bin/gem"}, "Interpreter of object-oriented scripting language Ruby", ""},
+	{"php", "1:7.0+35ubuntu6.1", 11, nil, []string{"/usr/bin/php"}, "server-side, HTML-embedded scripting language", ""},
+	{"apache2", "2.4.18-2ubuntu3.17", 540, nil, []string{"/usr/sbin/apache2"}, "Apache HTTP Server", "deb"},
+	{"httpd", "2.4.6-97.el7.centos", 9821, nil, []string{"/usr/sbin/httpd"}, "Apache HTTP Server", "rpm"},
+	{"nginx", "1.10.3-0ubuntu0.16.04.5", 37, nil, []string{"/usr/sbin/nginx"}, "small, powerful, scalable web/proxy server", ""},
+	{"epel-release", "7-11", 24, nil, nil, "Extra Packages for Enterprise Linux repository configuration", "rpm"},
+	{"perl", "5.22.1-9ubuntu0.9", 640, nil, []string{"/usr/bin/perl"}, "Larry Wall's Practical Extraction and Report Language", ""},
+	{"sudo", "1.8.16-0ubuntu1.10", 1812, nil, []string{"/usr/bin/sudo"}, "Provide limited super user privileges to specific users", ""},
+	{"wget", "1.17.1-1ubuntu1.5", 904, nil, []string{"/usr/bin/wget"}, "retrieves files from the web", ""},
+	{"curl", "7.47.0-1ubuntu2.19", 332, nil, []string{"/usr/bin/curl"}, "command line tool for transferring data with URL syntax", ""},
+	{"openssh-server", "1:7.2p2-4ubuntu2.10", 1106, nil, []string{"/usr/sbin/sshd"}, "secure shell (SSH) server, for secure access from remote machines", ""},
+	{"openssh-client", "8.8_p1-r1", 1140, nil, []string{"/usr/bin/ssh", "/usr/bin/scp"}, "OpenBSD's SSH client", "apk"},
+	{"net-tools", "1.60-26ubuntu1", 928, nil, []string{"/sbin/ifconfig", "/bin/netstat", "/sbin/route", "/usr/sbin/arp"},
+		"NET-3 networking toolkit", ""},
+	{"lsof", "4.89+dfsg-0.1", 451, nil, []string{"/usr/bin/lsof"}, "Utility to list open files", "deb"},
+	{"lsof", "4.87-6.el7", 927, nil, []string{"/usr/sbin/lsof"}, "A utility which lists open files on a Linux/UNIX system", "rpm"},
+	{"lsof", "4.94.0-r0", 232, nil, []string{"/usr/bin/lsof"}, "LiSt Open Files", "apk"},
+	{"strace", "4.11-1ubuntu3", 1300, nil, []string{"/usr/bin/strace"}, "System call tracer", "deb"},
+	{"strace", "4.24-6.el7", 2418, nil, []string{"/usr/bin/strace"}, "Tracks and displays system calls associated with a running process", "rpm"},
+	{"strace", "5.14-r0", 1416, nil, []string{"/usr/bin/strace"}, "Diagnostic, debugging and instructional userspace tracer", "apk"},
+	{"ltrace", "0.7.3-5.1ubuntu4", 420, nil, []string{"/usr/bin/ltrace"}, "Tracks runtime library calls in dynamically linked programs", "deb"},
+	{"ltrace", "0.7.91-16.el7", 453, nil, []string{"/usr/bin/ltrace"}, "Tracing tool for library calls", "rpm"},
+	{"traceroute", "1:2.0.21-1", 156, nil, []string{"/usr/bin/traceroute"}, "Traces the route taken by packets over an IPv4/IPv6 network", ""},
+	{"nmap-ncat", "2:6.40-19.el7", 423, nil, []string{"/usr/bin/ncat", "/usr/bin/nc"}, "Nmap's Netcat replacement", "rpm"},
+	{"iptables", "1.6.0-2ubuntu3", 1379, nil, []string{"/sbin/iptables"}, "administration tools for packet filtering and NAT", ""},
+	{"ufw", "0.35-0ubuntu2", 832, []string{"iptables"}, []string{"/usr/sbin/ufw"}, "program for managing a Netfilter firewall", "deb"},
+	{"firewalld", "0.6.3-13.el7_9", 2483, nil, []string{"/usr/bin/firewall-cmd", "/usr/sbin/firewalld"},
+		"A firewall daemon with D-Bus interface providing a dynamic firewall", "rpm"},
+	{"cron", "3.0pl1-128ubuntu2", 244, nil, []string{"/usr/sbin/cron"}, "process scheduling daemon", "deb"},
+	{"cronie", "1.4.11-23.el7", 234, nil, []string{"/usr/sbin/crond"}, "Cron daemon for executing programs at set times", "rpm"},
+	// Packages of the base image
+	{"adduser", "3.113+nmu3ubuntu4", 648, nil, nil, "add and remove users and groups", "deb"},
+	{"apt", "1.2.35", 3598, nil, nil, "commandline package manager", "deb"},
+	{"base-files", "9.4ubuntu4.14", 356, nil, nil, "Debian base system miscellaneous files", "deb"},
+	{"bash", "4.3-14ubuntu1.4", 1466, nil, nil, "GNU Bourne Again SHell", "deb"},
+	{"coreutils", "8.25-2ubuntu3~16.04", 6008, nil, nil, "GNU core utilities", "deb"},
+	{"dash", "0.5.8-2.1ubuntu2", 209, nil, nil, "POSIX-compliant shell", "deb"},
+	{"debconf", "1.5.58ubuntu2", 558, nil, nil, "Debian configuration management system", "deb"},
+	{"dpkg", "1.18.4ubuntu1.7", 6638, nil, nil, "Debian package management system", "deb"},
+	{"grep", "2.25-1~16.04.1", 1060, nil, nil, "GNU grep, egrep and fgrep", "deb"},
+	{"gzip", "1.6-4ubuntu1", 221, nil, nil, "GNU compression utilities", "deb"},
+	{"hostname", "3.16ubuntu2", 47, nil, nil, "utility to set/show the host name or domain name", "deb"},
+	{"iproute2", "4.3.0-1ubuntu3.16.04.5", 1548, nil, nil, "networking and traffic control tools", "deb"},
+	{"iputils-ping", "3:20121221-5ubuntu2", 138, nil, nil, "Tools to test the reachability of network hosts", "deb"},
+	{"less", "481-2.1ubuntu0.2", 310, nil, nil, "pager program similar to more", "deb"},
+	{"libc-bin", "2.23-0ubuntu11.3", 3574, nil, nil, "GNU C Library: Binaries", "deb"},
+	{"libc6", "2.23-0ubuntu11.3", 10793, nil, nil, "GNU C Library: Shared libraries", "deb"},
+	{"login", "1:4.2-3.1ubuntu5.4", 1210, nil, nil, "system login tools", "deb"},
+	{"mount", "2.27.1-6ubuntu3.10", 443, nil, nil, "tools for mounting and manipulating filesystems", "deb"},
+	{"openssh-client", "1:7.2p2-4ubuntu2.10", 3795, nil, nil, "secure shell (SSH) client, for secure access to remote machines", "deb"},
+	{"passwd", "1:4.2-3.1ubuntu5.4", 2585, nil, nil, "change and administer password and group data", "deb"},
+	{"procps", "2:3.3.10-4ubuntu2.5", 644, nil, nil, "/proc file system utilities", "deb"},
+	{"sed", "4.2.2-7", 736, nil, nil, "The GNU sed stream editor", "deb"},
+	{"systemd", "229-4ubuntu21.31", 18092, nil, nil, "system and service manager", "deb"},
+	{"tar", "1.28-2.1ubuntu0.2", 669, nil, nil, "GNU version of the tar archiving utility", "deb"},
+	{"tzdata", "2021a-0ubuntu0.16.04", 2981, nil, nil, "time zone and daylight-saving time data", "deb"},
+	{"util-linux", "2.27.1-6ubuntu3.10", 3430, nil, nil, "miscellaneous system utilities", "deb"},
+	{"bash", "4.2.46-34.el7", 3667, nil, nil, "The GNU Bourne Again shell", "rpm"},
+	{"basesystem", "10.0-7.el7.centos", 0, nil, nil, "The skeleton package which defines a simple CentOS Linux system", "rpm"},
+	{"coreutils", "8.22-24.el7_9.2", 14588, nil, nil, "A set of basic GNU tools commonly used in shell scripts", "rpm"},
+	{"filesystem", "3.2-25.el7", 0, nil, nil, "The basic directory layout for a Linux system", "rpm"},
+	{"glibc", "2.17-326.el7_9", 14164, nil, nil, "The GNU libc libraries", "rpm"},
+	{"grep", "2.20-3.el7", 1195, nil, nil, "Pattern matching utilities", "rpm"},
+	{"gzip", "1.5-10.el7", 250, nil, nil, "The GNU data compression program", "rpm"},
+	{"iproute", "4.11.0-30.el7", 2096, nil, nil, "Advanced IP routing and network device configuration tools", "rpm"},
+	{"openssh-clients", "7.4p1-22.el7_9", 2651, nil, nil, "An open source SSH client applications", "rpm"},
+	{"openssh-server", "7.4p1-22.el7_9", 1066, nil, nil, "An open source SSH server daemon", "rpm"},
+	{"passwd", "0.79-6.el7", 420, nil, nil, "An utility for setting or changing passwords using PAM", "rpm"},
+	{"procps-ng", "3.3.10-28.el7", 759, nil, nil, "System and process monitoring utilities", "rpm"},
+	{"rpm", "4.11.3-48.el7_9", 2622, nil, nil, "The RPM package management system", "rpm"},
+	{"sed", "4.2.2-7.el7", 601, nil, nil, "A GNU stream text editor", "rpm"},
+	{"systemd", "219-78.el7_9.5", 24518, nil, nil, "A System and Service Manager", "rpm"},
+	{"tar", "1.26-35.el7", 2838, nil, nil, "A GNU file archiving program", "rpm"},
+	{"util-linux", "2.23.2-65.el7_9.1", 8281, nil, nil, "A collection of basic system utilities", "rpm"},
+	{"yum", "3.4.3-168.el7.centos", 5742, nil, nil, "RPM package installer/updater/manager", "rpm"},
+	{"alpine-baselayout", "3.2.0-r18", 404, nil, nil, "Alpine base dir structure and init scripts", "apk"},
+	{"alpine-keys", "2.4-r1", 156, nil, nil, "Public keys for Alpine Linux packages", "apk"},
+	{"apk-tools", "2.12.7-r3", 304, nil, nil, "Alpine Package Keeper - package manager for alpine", "apk"},
+	{"busybox", "1.34.1-r3", 944, nil, nil, "Size optimized toolbox of many common UNIX utilities", "apk"},
+	{"ca-certificates-bundle", "20211220-r0", 228, nil, nil, "Pre generated bundle of Mozilla certificates", "apk"},
+	{"libc-utils", "0.7.2-r3", 4, nil, nil, "Meta package to pull in correct libc", "apk"},
+	{"libcrypto1.1", "1.1.1l-r8", 2704, nil, nil, "Crypto library from openssl", "apk"},
+	{"libssl1.1", "1.1.1l-r8", 528, nil, nil, "SSL shared libraries", "apk"},
+	{"musl", "1.2.2-r7", 608, nil, nil, "the musl c library (libc) implementation", "apk"},
+	{"musl-utils", "1.2.2-r7", 144, nil, nil, "the musl c library (libc) implementation", "apk"},
+	{"scanelf", "1.3.3-r0", 92, nil, nil, "Scan ELF binaries for stuff", "apk"},
+	{"ssl_client", "1.34.1-r3", 28, nil, nil, "EXternal ssl_client for busybox wget", "apk"},
+	{"zlib", "1.2.11-r3", 108, nil, nil, "A compression/decompression Library", "apk"},
+}
+
+// basePackages are installed in the image before the client installs any
+var basePackages = map[string][]string{
+	"deb": {"adduser", "apt", "base-files", "bash", "coreutils", "cron", "curl", "dash", "debconf", "dnsutils",
+		"dpkg", "ftp", "grep", "gzip", "hostname", "iproute2", "iptables", "iputils-ping", "less", "libc-bin", "libc6",
+		"login", "lsof", "ltrace", "mount", "net-tools", "openssh-client", "openssh-server", "openssl", "passwd", "perl",
+		"procps", "python3", "rsync", "sed", "strace", "sudo", "systemd", "tar", "telnet", "tzdata", "ufw", "util-linux", "wget"},
+	"rpm": {"bash", "basesystem", "coreutils", "cronie", "curl", "filesystem", "firewalld", "glibc", "grep", "gzip",
+		"iproute", "openssh-clients", "openssh-server", "openssl", "passwd", "procps-ng", "python", "rpm", "sed", "sudo",
+		"systemd", "tar", "util-linux", "yum"},
+	"apk": {"alpine-baselayout", "alpine-keys", "apk-tools", "busybox", "ca-certificates-bundle",
+		"libc-utils", "libcrypto1.1", "libssl1.1", "musl", "musl-utils", "scanelf", "ssl_client", "zlib"},
+}
+
+// pkgFamily returns the package format of the distribution
+func pkgFamily() string {
+	switch honeyos.Distro() {
+	case "centos", "rhel", "fedora":
+		return "rpm"
+	case "alpine":
+		return "apk"
+	}
+	return "deb"
+}
+
+// lookupPkg finds the package available in the distribution
+func lookupPkg(family, name string) (pkgInfo, bool) {
+	// Packages built for the distribution take precedence
+	for _, f := range []string{family, ""} {
+		for _, p := range pkgCatalog {
+			if p.name == name && p.family == f {
+				return p, true
+			}
+		}
+	}
+	return pkgInfo{}, false
+}
+
+// pkgVersion returns version of the package in the format of the family,
+// e.g. 7.01-2ubuntu2 in deb becomes 7.01-1.el7 in rpm
+func pkgVersion(p pkgInfo, family string) string {
+	if p.family == family {
+		return p.version
+	}
+	v := p.version
+	if i := strings.Index(v, ":"); i >= 0 {
+		v = v[i+1:]
+	}
+	if i := strings.IndexAny(v, "-+~"); i >= 0 {
+		v = v[:i]
+	}
+	switch family {
+	case "rpm":
+		return v + "-1.el7"
+	case "apk":
+		return v + "-r0"
+	}
+	return p.version
+}
+
+// resolvePkgs returns the packages to be installed for the names, with the
+// dependencies not installed yet, and the names not in the repository
+func resolvePkgs(family string, names []string, db *pkgDB) (pkgs []pkgInfo, missing []string) {
+	seen := map[string]bool{}
+	var add func(name string, explicit bool)
+	add = func(name string, explicit bool) {
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		p, ok := lookupPkg(family, name)
+		if !ok {
+			if explicit {
+				missing = append(missing, name)
+			}
+			return
+		}
+		for _, d := range p.deps {
+			if !db.installed(d) {
+				add(d, false)
+			}
+		}
+		if !db.installed(name) {
+			pkgs = append(pkgs, p)
+		}
+	}
+	for _, name := range names {
+		add(name, true)
+	}
+	return
+}
+
+// pkgDBFormat is the database file of installed packages and its field names
+type pkgDBFormat struct {
+	path                           string
+	sep                            string
+	name, version, size, desc, dep string
+}
+
+var pkgDBFormats = map[string]pkgDBFormat{
+	"deb": {"/var/lib/dpkg/status", ": ", "Package", "Version", "Installed-Size", "Description", "Depends"},
+	"rpm": {"/var/lib/rpm/Packages", ": ", "Name", "Version", "Size", "Summary", "Requires"},
+	"apk": {"/lib/apk/db/installed", ":", "P", "V", "I", "T", "D"},
+}
+
+// pkgDB is the packages installed in the system, which is kept in the
+// virtual filesystem like the database of the real package manager
+type pkgDB struct {
+	family string
+	pkgs   map[string]pkgInfo
+}
+
+func loadPkgDB(sys honeyos.Sys, family string) *pkgDB {
+	db := &pkgDB{family: family, pkgs: map[string]pkgInfo{}}
+	format := pkgDBFormats[family]
+	b, err := afero.ReadFile(sys.FSys(), format.path)
+	if err != nil || len(b) == 0 {
+		for _, name := range basePackages[family] {
+			if p, ok := lookupPkg(family, name); ok {
+				p.version = pkgVersion(p, family)
+				db.pkgs[name] = p
+			}
+		}
+		return db
+	}
+	var p pkgInfo
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if p.name != "" {
+				db.pkgs[p.name] = p
+			}
+			p = pkgInfo{}
+			continue
+		}
+		kv := strings.SplitN(line, strings.TrimSpace(format.sep), 2)
+		if len(kv) != 2 {
+			continue
+		}
+		v := strings.TrimSpace(kv[1])
+		switch kv[0] {
+		case format.name:
+			p.name = v
+		case format.version:
+			p.version = v
+		case format.size:
+			p.size, _ = strconv.Atoi(v)
+			if family == "apk" {
+				p.size /= 1024
+			}
+		case format.desc:
+			p.desc = v
+		case format.dep:
+			p.deps = strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' })
+		}
+	}
+	if p.name != "" {
+		db.pkgs[p.name] = p
+	}
+	return db
+}
+
+func (db *pkgDB) installed(name string) bool {
+	_, ok := db.pkgs[name]
+	return ok
+}
+
+// names returns the installed packages in order
+func (db *pkgDB) names() []string {
+	var names []string
+	for name := range db.pkgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (db *pkgDB) save(sys honeyos.Sys) error {
+	format := pkgDBFormats[db.family]
+	var buf bytes.Buffer
+	for _, name := range db.names() {
+		p := db.pkgs[name]
+		fmt.Fprintf(&buf, "%v%v%v\n", format.name, format.sep, p.name)
+		if db.family == "deb" {
+			fmt.Fprintf(&buf, "Status: install ok installed\nPriority: optional\nArchitecture: amd64\n")
+		}
+		fmt.Fprintf(&buf, "%v%v%v\n", format.version, format.sep, p.version)
+		size := p.size
+		if db.family == "apk" {
+			size *= 1024
+		}
+		fmt.Fprintf(&buf, "%v%v%v\n", format.size, format.sep, size)
+		if len(p.deps) > 0 {
+			sep := " "
+			if db.family == "deb" {
+				sep = ", "
+			}
+			fmt.Fprintf(&buf, "%v%v%v\n", format.dep, format.sep, strings.Join(p.deps, sep))
+		}
+		fmt.Fprintf(&buf, "%v%v%v\n\n", format.desc, format.sep, p.desc)
+	}
+	fs := sys.FSys()
+	fs.MkdirAll(path.Dir(format.path), 0755)
+	return afero.WriteFile(fs, format.path, buf.Bytes(), 0644)
+}
+
+// pkgStubMarker is in every stub installed, so they can be told from other
+// files when the package is removed
+const pkgStubMarker = "# installed by package manager"
+
+// install adds the package to the database and creates its executables.
+// We can't run the real program, so the stub fails like the binary is
+// broken
+func (db *pkgDB) install(sys honeyos.Sys, p pkgInfo) {
+	p.version = pkgVersion(p, db.family)
+	db.pkgs[p.name] = p
+	fs := sys.FSys()
+	for _, bin := range p.bins {
+		if exists, _ := afero.Exists(fs, bin); exists {
+			continue
+		}
+		stub := fmt.Sprintf("#!/bin/sh\n%v\necho \"%v: error while loading shared libraries: lib%v.so.0: cannot open shared object file: No such file or directory\" >&2\nexit 127\n",
+			pkgStubMarker, path.Base(bin), strings.TrimPrefix(p.name, "lib"))
+		fs.MkdirAll(path.Dir(bin), 0755)
+		afero.WriteFile(fs, bin, []byte(stub), 0755)
+	}
+}
+
+// remove deletes the package from the database, with the stubs installed
+func (db *pkgDB) remove(sys honeyos.Sys, name string) {
+	p := db.pkgs[name]
+	delete(db.pkgs, name)
+	fs := sys.FSys()
+	for _, bin := range p.bins {
+		if b, err := afero.ReadFile(fs, bin); err == nil && bytes.Contains(b, []byte(pkgStubMarker)) {
+			fs.Remove(bin)
+		}
+	}
+}
+
+// logPkgRequest records the packages requested, which tell what tools the
+// attacker intends to use
+func logPkgRequest(sys honeyos.Sys, manager, action string, names []string) {
+	sys.Log().WithFields(log.Fields{
+		"manager":  manager,
+		"action":   action,
+		"packages": names,
+	}).Infof("User requested %v %v of %v", manager, action, strings.Join(names, " "))
+}
+
+// pkgConfirm asks the question and reads the answer from stdin, leaving the
+// rest of input for the next prompts. def is the answer when user just
+// presses enter
+func pkgConfirm(sys honeyos.Sys, question string, def bool) bool {
+	fmt.Fprint(sys.Out(), question)
+	line, ok := readLine(sys.In())
+	if !ok {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "":
+		return def
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// pkgSleep pauses like waiting for the network, and returns false if the
+// command is interrupted meanwhile
+func pkgSleep(sys honeyos.Sys, d time.Duration) bool {
+	select {
+	case <-sys.Context().Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// isRoot tells if the command is run by root, as package managers require
+func isRoot(sys honeyos.Sys) bool {
+	return sys.CurrentUser() == 0
+}
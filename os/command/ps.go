@@ -0,0 +1,249 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+)
+
+type ps struct{}
+
+// psOptions is the selection and format of processes, from either the
+// UNIX (-ef) or BSD (aux) style of options
+type psOptions struct {
+	all, withTTY, noTTY bool
+	full, user, bsd     bool
+	wide                bool
+	users, cmds         []string
+	pids                []int
+}
+
+const psUsage = `
+Usage:
+ ps [options]
+
+ Try 'ps --help <simple|list|output|threads|misc|all>'
+  or 'ps --help <s|l|o|t|m|a>'
+ for additional help text.
+
+For more details see ps(1).`
+
+func init() {
+	honeyos.RegisterCommand("ps", ps{})
+}
+
+func (ps) GetHelp() string {
+	return ""
+}
+
+func (ps) Where() string {
+	return "/bin/ps"
+}
+
+func (p ps) Exec(args []string, sys honeyos.Sys) int {
+	if honeyos.Distro() == "alpine" {
+		return p.busybox(sys)
+	}
+	var opt psOptions
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case strings.HasPrefix(arg, "--"):
+			// Long options like --forest and --sort only changes the order
+			continue
+		case strings.HasPrefix(arg, "-"):
+			for j := 1; j < len(arg); j++ {
+				switch c := arg[j]; c {
+				case 'e', 'A':
+					opt.all = true
+				case 'a':
+					opt.withTTY = true
+				case 'f', 'F', 'l':
+					opt.full = true
+				case 'x':
+					opt.noTTY = true
+				case 'w':
+					opt.wide = true
+				case 'u', 'U', 'p', 'C':
+					value := arg[j+1:]
+					if value == "" && i+1 < len(args) {
+						i++
+						value = args[i]
+					}
+					if value == "" {
+						fmt.Fprintln(sys.Err(), "error: list of "+map[byte]string{'u': "users", 'U': "users", 'p': "process IDs", 'C': "command names"}[c]+" must follow -"+string(c)+psUsage)
+						return 1
+					}
+					if !opt.parseList(c, value) {
+						fmt.Fprintln(sys.Err(), "error: process ID list syntax error"+psUsage)
+						return 1
+					}
+					j = len(arg)
+				default:
+					fmt.Fprintln(sys.Err(), "error: unsupported SysV option"+psUsage)
+					return 1
+				}
+			}
+		default:
+			opt.bsd = true
+			for j := 0; j < len(arg); j++ {
+				switch arg[j] {
+				case 'a':
+					opt.withTTY = true
+				case 'x':
+					opt.noTTY = true
+				case 'u':
+					opt.user = true
+				case 'w':
+					opt.wide = true
+				case 'e', 'f', 'j', 'c':
+				default:
+					fmt.Fprintln(sys.Err(), "error: unsupported option (BSD syntax)"+psUsage)
+					return 1
+				}
+			}
+		}
+	}
+	procs := opt.selectProcs(sys)
+	width := 0
+	if honeyos.IsTerminal(sys.Out()) && !opt.wide {
+		width = sys.Width()
+	}
+	print := func(line string) {
+		if width > 0 && len(line) > width {
+			line = line[:width]
+		}
+		fmt.Fprintln(sys.Out(), line)
+	}
+	switch {
+	case opt.bsd && opt.user:
+		print("USER       PID %CPU %MEM    VSZ   RSS TTY      STAT START   TIME COMMAND")
+		for _, pr := range procs {
+			print(fmt.Sprintf("%-8v %5v %4.1f %4.1f %6v %5v %-8v %-4v %5v %6v %v", psUser(pr.User), pr.PID, pr.CPU, pr.Mem,
+				pr.VSZ, pr.RSS, pr.TTY, pr.Stat, psStart(pr.Start), psTime(pr.Time, false), pr.Cmd))
+		}
+	case opt.bsd:
+		print("  PID TTY      STAT   TIME COMMAND")
+		for _, pr := range procs {
+			print(fmt.Sprintf("%5v %-8v %-4v %6v %v", pr.PID, pr.TTY, pr.Stat, psTime(pr.Time, false), pr.Cmd))
+		}
+	case opt.full:
+		print("UID        PID  PPID  C STIME TTY          TIME CMD")
+		for _, pr := range procs {
+			print(fmt.Sprintf("%-8v %5v %5v %2v %5v %-8v %8v %v", psUser(pr.User), pr.PID, pr.PPID, int(pr.CPU),
+				psStart(pr.Start), pr.TTY, psTime(pr.Time, true), pr.Cmd))
+		}
+	default:
+		print("  PID TTY          TIME CMD")
+		for _, pr := range procs {
+			print(fmt.Sprintf("%5v %-8v %8v %v", pr.PID, pr.TTY, psTime(pr.Time, true), pr.Comm()))
+		}
+	}
+	return 0
+}
+
+// parseList adds the comma separated list of the option
+func (opt *psOptions) parseList(c byte, value string) bool {
+	for _, v := range strings.Split(value, ",") {
+		switch c {
+		case 'p':
+			pid, err := strconv.Atoi(v)
+			if err != nil {
+				return false
+			}
+			opt.pids = append(opt.pids, pid)
+		case 'C':
+			opt.cmds = append(opt.cmds, v)
+		default:
+			opt.users = append(opt.users, v)
+		}
+	}
+	return true
+}
+
+// selectProcs returns the processes selected by the options. By default
+// they are the ones of the user on the same terminal
+func (opt psOptions) selectProcs(sys honeyos.Sys) []honeyos.ProcInfo {
+	user := honeyos.GetUserByID(sys.CurrentUser()).Name
+	tty := "?"
+	for _, p := range sys.Processes() {
+		if p.User == user && p.TTY != "?" && !strings.HasPrefix(p.TTY, "tty") {
+			tty = p.TTY
+			break
+		}
+	}
+	list := len(opt.users) > 0 || len(opt.pids) > 0 || len(opt.cmds) > 0
+	var procs []honeyos.ProcInfo
+	for _, p := range sys.Processes() {
+		var selected bool
+		switch {
+		case opt.all || opt.withTTY && opt.noTTY:
+			selected = true
+		case list:
+			selected = false
+		case opt.withTTY:
+			selected = p.TTY != "?"
+		case opt.noTTY:
+			selected = p.User == user
+		default:
+			selected = p.User == user && p.TTY == tty
+		}
+		for _, u := range opt.users {
+			selected = selected || p.User == u
+		}
+		for _, pid := range opt.pids {
+			selected = selected || p.PID == pid
+		}
+		for _, cmd := range opt.cmds {
+			selected = selected || p.Comm() == cmd
+		}
+		if selected {
+			procs = append(procs, p)
+		}
+	}
+	return procs
+}
+
+// psUser truncates the user name to the column, like systemd+ for
+// systemd-timesync
+func psUser(name string) string {
+	if len(name) > 8 {
+		return name[:7] + "+"
+	}
+	return name
+}
+
+// psStart formats start time as time of day for processes started today,
+// and the date otherwise
+func psStart(t time.Time) string {
+	now := time.Now()
+	switch {
+	case now.Sub(t) < 24*time.Hour:
+		return t.Format("15:04")
+	case t.Year() == now.Year():
+		return t.Format("Jan02")
+	}
+	return t.Format("2006")
+}
+
+// psTime formats the CPU time, like 0:04 in BSD format and 00:00:04 in
+// UNIX format
+func psTime(d time.Duration, long bool) string {
+	s := int(d.Seconds())
+	if long {
+		return fmt.Sprintf("%02d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
+
+// busybox is ps of busybox, which takes no options and lists every process
+func (ps) busybox(sys honeyos.Sys) int {
+	fmt.Fprintln(sys.Out(), "PID   USER     TIME  COMMAND")
+	for _, p := range sys.Processes() {
+		fmt.Fprintf(sys.Out(), "%5v %-8.8v %5v %v\n", p.PID, p.User, psTime(p.Time, false), p.Cmd)
+	}
+	return 0
+}
@@ -0,0 +1,1136 @@
+package command
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+// pyFile is the file opened for writing by open. What is written is saved
+// as artifact once closed
+type pyFile struct {
+	f      afero.File
+	data   []byte
+	closed bool
+}
+
+// functions returns the builtins and the functions of the modules, keyed
+// by module.name
+func (in *pyInterp) functions() map[string]func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+	text := func(args []pyValue, kw map[string]pyValue, n int, name string) (string, error) {
+		v := pyArg(args, kw, n, name)
+		if s, ok := pyText(v); ok {
+			return s, nil
+		}
+		if _, ok := v.(pyUnknown); ok {
+			return "", nil
+		}
+		return "", &pyError{"TypeError", fmt.Sprintf("expected str, bytes or os.PathLike object, not %v", in.typeName(v))}
+	}
+	decoder := func(decode func(string) (string, error), kind, msg string) func([]pyValue, map[string]pyValue) (pyValue, error) {
+		return func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+			s, err := text(args, kw, 0, "s")
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := pyArg(args, kw, 0, "s").(pyUnknown); ok {
+				return pyUnknown{}, nil
+			}
+			out, err := decode(s)
+			if err != nil {
+				return nil, &pyError{kind, msg}
+			}
+			return in.bytesOf(out), nil
+		}
+	}
+	b64decode := decoder(func(s string) (string, error) {
+		s = strings.TrimRight(strings.Map(func(r rune) rune {
+			if strings.ContainsRune(" \t\r\n", r) {
+				return -1
+			}
+			return r
+		}, s), "=")
+		b, err := base64.RawStdEncoding.DecodeString(s)
+		if err != nil {
+			b, err = base64.RawURLEncoding.DecodeString(s)
+		}
+		return string(b), err
+	}, "binascii.Error", "Incorrect padding")
+	b64encode := func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+		s, err := text(args, kw, 0, "s")
+		if err != nil {
+			return nil, err
+		}
+		return in.bytesOf(base64.StdEncoding.EncodeToString([]byte(s))), nil
+	}
+	unhexlify := decoder(func(s string) (string, error) {
+		b, err := hex.DecodeString(s)
+		return string(b), err
+	}, "binascii.Error", "Non-hexadecimal digit found")
+	hexlify := func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+		s, err := text(args, kw, 0, "data")
+		if err != nil {
+			return nil, err
+		}
+		return in.bytesOf(hex.EncodeToString([]byte(s))), nil
+	}
+	decompress := decoder(func(s string) (string, error) {
+		r, err := zlib.NewReader(strings.NewReader(s))
+		if err != nil {
+			return "", err
+		}
+		b, err := ioutil.ReadAll(r)
+		return string(b), err
+	}, "zlib.error", "Error -3 while decompressing data: incorrect header check")
+	shell := func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+		n, err := in.execute(pyArg(args, kw, 0, "args"), kw["shell"] == true, nil)
+		if err != nil {
+			return nil, err
+		}
+		return n, nil
+	}
+	output := func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+		var buf bytes.Buffer
+		n, err := in.execute(pyArg(args, kw, 0, "args"), kw["shell"] == true, &buf)
+		if err != nil {
+			return nil, err
+		}
+		if n != 0 {
+			return nil, &pyError{"subprocess.CalledProcessError",
+				fmt.Sprintf("Command %v returned non-zero exit status %v", in.repr(pyArg(args, kw, 0, "args")), n)}
+		}
+		return in.bytesOf(buf.String()), nil
+	}
+	getoutput := func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+		var buf bytes.Buffer
+		if _, err := in.execute(pyArg(args, kw, 0, "cmd"), true, &buf); err != nil {
+			return nil, err
+		}
+		return strings.TrimSuffix(buf.String(), "\n"), nil
+	}
+	popen := func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+		var buf bytes.Buffer
+		var out io.Writer
+		if kw["stdout"] == -1 {
+			out = &buf
+		}
+		n, err := in.execute(pyArg(args, kw, 0, "args"), kw["shell"] == true, out)
+		if err != nil {
+			return nil, err
+		}
+		stdout := &pyObject{class: "file", fields: map[string]pyValue{"name": "<stdout>", "mode": "rb", "data": in.bytesOf(buf.String())}}
+		return &pyObject{class: "Popen", fields: map[string]pyValue{"returncode": n, "pid": 4000 + n, "stdout": stdout}}, nil
+	}
+	urlopen := func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+		url, err := text(args, kw, 0, "url")
+		if err != nil {
+			return nil, err
+		}
+		data, err := in.fetch(url)
+		if err != nil {
+			return nil, err
+		}
+		return &pyObject{class: "HTTPResponse", fields: map[string]pyValue{"data": in.bytesOf(data), "url": url, "status": 200}}, nil
+	}
+	urlretrieve := func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+		url, err := text(args, kw, 0, "url")
+		if err != nil {
+			return nil, err
+		}
+		name, _ := text(args, kw, 1, "filename")
+		if name == "" {
+			name = "/tmp/tmp" + strconv.FormatInt(time.Now().UnixNano()%1e8, 36)
+		}
+		data, err := in.fetch(url)
+		if err != nil {
+			return nil, err
+		}
+		if err := afero.WriteFile(in.sys.FSys(), absPath(in.sys, name), []byte(data), 0666&^in.sys.Umask()); err != nil {
+			return nil, in.fileError("IOError", err, name)
+		}
+		honeyos.SaveArtifact(in.sys, []byte(data), url)
+		return pyTuple{name, pyUnknown{}}, nil
+	}
+	spawn := func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+		argv := pyArg(args, kw, 0, "argv")
+		if s, ok := argv.(string); ok {
+			argv = pyList{s}
+		}
+		n, err := in.execute(argv, false, nil)
+		if err != nil {
+			return nil, err
+		}
+		return n << 8, nil
+	}
+	execv := func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+		argv := pyList{}
+		switch a := pyArg(args, kw, 1, "args").(type) {
+		case pyList:
+			argv = a
+		case pyTuple:
+			argv = pyList(a)
+		default:
+			argv = append(argv, args[1:]...)
+		}
+		if len(argv) > 0 {
+			argv[0] = pyArg(args, kw, 0, "path")
+		}
+		n, err := in.execute(argv, false, nil)
+		if err != nil {
+			return nil, err
+		}
+		return nil, pyExit{n}
+	}
+	system := func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+		n, err := in.execute(pyArg(args, kw, 0, "command"), true, nil)
+		if err != nil {
+			return nil, err
+		}
+		return n << 8, nil
+	}
+	exit := func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+		switch code := pyArg(args, kw, 0, "code").(type) {
+		case nil:
+			return nil, pyExit{0}
+		case int:
+			return nil, pyExit{code}
+		default:
+			fmt.Fprintln(in.sys.Err(), in.str(code))
+			return nil, pyExit{1}
+		}
+	}
+	sleep := func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+		var secs float64
+		switch n := pyArg(args, kw, 0, "secs").(type) {
+		case int:
+			secs = float64(n)
+		case float64:
+			secs = n
+		}
+		if !pkgSleep(in.sys, time.Duration(secs*float64(time.Second))) {
+			return nil, pyInterrupted
+		}
+		return nil, nil
+	}
+	uname := func([]pyValue, map[string]pyValue) (pyValue, error) {
+		return pyTuple{"Linux", in.sys.Hostname(), honeyos.KernelRelease(), honeyos.KernelVersion(), honeyos.Arch()}, nil
+	}
+	funcs := map[string]func(args []pyValue, kw map[string]pyValue) (pyValue, error){
+		"print": func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+			sep, end := " ", "\n"
+			if s, ok := kw["sep"].(string); ok {
+				sep = s
+			}
+			if s, ok := kw["end"].(string); ok {
+				end = s
+			}
+			out := in.sys.Out()
+			if f, ok := kw["file"].(*pyObject); ok && f.fields["name"] == "<stderr>" {
+				out = in.sys.Err()
+			}
+			var words []string
+			for _, a := range args {
+				words = append(words, in.str(a))
+			}
+			fmt.Fprint(out, strings.Join(words, sep)+end)
+			return nil, nil
+		},
+		"input": func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+			if len(args) > 0 {
+				fmt.Fprint(in.sys.Out(), in.str(args[0]))
+			}
+			line, err := in.input().ReadString('\n')
+			if err != nil && line == "" {
+				return nil, &pyError{"EOFError", "EOF when reading a line"}
+			}
+			line = strings.TrimRight(line, "\r\n")
+			in.sys.Log().Infof("User typed %v into %v", line, in.name)
+			return line, nil
+		},
+		"open": in.open,
+		"len": func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+			switch v := pyArg(args, kw, 0, "obj").(type) {
+			case string:
+				return len([]rune(v)), nil
+			case pyBytes:
+				return len(v), nil
+			case pyList:
+				return len(v), nil
+			case pyTuple:
+				return len(v), nil
+			case pyUnknown:
+				return v, nil
+			default:
+				return nil, &pyError{"TypeError", fmt.Sprintf("object of type '%v' has no len()", in.typeName(v))}
+			}
+		},
+		"str": func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+			if len(args) == 0 {
+				return "", nil
+			}
+			return in.str(args[0]), nil
+		},
+		"repr": func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+			return in.repr(pyArg(args, kw, 0, "obj")), nil
+		},
+		"int": func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+			switch v := pyArg(args, kw, 0, "x").(type) {
+			case nil:
+				return 0, nil
+			case int, pyUnknown:
+				return v, nil
+			case bool:
+				n, _ := pyInt(v)
+				return n, nil
+			case float64:
+				return int(v), nil
+			case string:
+				base, _ := pyInt(pyArg(args, kw, 1, "base"))
+				if base == 0 {
+					base = 10
+				}
+				n, err := strconv.ParseInt(strings.TrimSpace(v), base, 64)
+				if err != nil {
+					return nil, &pyError{"ValueError", fmt.Sprintf("invalid literal for int() with base %v: %v", base, in.repr(v))}
+				}
+				return int(n), nil
+			default:
+				return nil, &pyError{"TypeError", fmt.Sprintf("int() argument must be a string or a number, not '%v'", in.typeName(v))}
+			}
+		},
+		"chr": func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+			n, _ := pyInt(pyArg(args, kw, 0, "i"))
+			if !in.v3 && n > 255 {
+				return nil, &pyError{"ValueError", "chr() arg not in range(256)"}
+			}
+			return string(rune(n)), nil
+		},
+		"ord": func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+			s, ok := pyText(pyArg(args, kw, 0, "c"))
+			if !ok || len([]rune(s)) != 1 {
+				return nil, &pyError{"TypeError", "ord() expected a character"}
+			}
+			return int([]rune(s)[0]), nil
+		},
+		"hex": func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+			n, _ := pyInt(pyArg(args, kw, 0, "number"))
+			return "0x" + strconv.FormatInt(int64(n), 16), nil
+		},
+		"range": func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+			var bounds []int
+			for _, a := range args {
+				n, _ := pyInt(a)
+				bounds = append(bounds, n)
+			}
+			lo, hi := 0, 0
+			switch len(bounds) {
+			case 1:
+				hi = bounds[0]
+			case 2, 3:
+				lo, hi = bounds[0], bounds[1]
+			}
+			var l pyList
+			for i := lo; i < hi && len(l) < 1<<16; i++ {
+				l = append(l, i)
+			}
+			return l, nil
+		},
+		"__import__": func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+			name, err := text(args, kw, 0, "name")
+			if err != nil {
+				return nil, err
+			}
+			return in.importModule(name)
+		},
+		"exec": func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+			return nil, in.exec(pyArg(args, kw, 0, "source"))
+		},
+		"eval": func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+			src, ok := pyText(pyArg(args, kw, 0, "source"))
+			if !ok {
+				return pyUnknown{}, nil
+			}
+			in.sys.Log().WithField("code", src).Infof("User ran decoded code with %v", in.name)
+			toks, err := pyLex(src)
+			if err != nil {
+				return nil, err
+			}
+			x, err := pyParseExpr(toks)
+			if err != nil {
+				if _, ok := err.(pyUnsupported); ok {
+					return pyUnknown{}, nil
+				}
+				return nil, err
+			}
+			return in.eval(x)
+		},
+		"exit": exit,
+		"quit": exit,
+
+		"os.system":    system,
+		"os.popen":     in.popen,
+		"os.execv":     execv,
+		"os.execve":    execv,
+		"os.execvp":    execv,
+		"os.execl":     execv,
+		"os.execlp":    execv,
+		"os.getcwd":    func([]pyValue, map[string]pyValue) (pyValue, error) { return in.sys.Getcwd(), nil },
+		"os.getuid":    func([]pyValue, map[string]pyValue) (pyValue, error) { return in.sys.CurrentUser(), nil },
+		"os.geteuid":   func([]pyValue, map[string]pyValue) (pyValue, error) { return in.sys.CurrentUser(), nil },
+		"os.getgid":    func([]pyValue, map[string]pyValue) (pyValue, error) { return in.sys.CurrentGroup(), nil },
+		"os.getegid":   func([]pyValue, map[string]pyValue) (pyValue, error) { return in.sys.CurrentGroup(), nil },
+		"os.getpid":    func([]pyValue, map[string]pyValue) (pyValue, error) { return os.Getpid()%30000 + 2000, nil },
+		"os.getlogin":  in.getuser,
+		"os.uname":     uname,
+		"os.dup2":      func([]pyValue, map[string]pyValue) (pyValue, error) { return nil, nil },
+		"os.fork":      func([]pyValue, map[string]pyValue) (pyValue, error) { return 0, nil },
+		"os.setsid":    func([]pyValue, map[string]pyValue) (pyValue, error) { return nil, nil },
+		"os._exit":     exit,
+		"os.listdir":   in.listdir,
+		"os.chdir":     in.chdir,
+		"os.remove":    in.remove,
+		"os.unlink":    in.remove,
+		"os.getenv":    in.environGet,
+		"os.path.join": in.pathJoin,
+		"os.path.exists": func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+			_, err := in.stat(args, kw)
+			return err == nil, nil
+		},
+		"os.path.isfile": func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+			fi, err := in.stat(args, kw)
+			return err == nil && fi.Mode().IsRegular(), nil
+		},
+		"os.path.isdir": func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+			fi, err := in.stat(args, kw)
+			return err == nil && fi.IsDir(), nil
+		},
+		"os.path.basename": func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+			p, err := text(args, kw, 0, "p")
+			return p[strings.LastIndex(p, "/")+1:], err
+		},
+		"os.path.dirname": func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+			p, err := text(args, kw, 0, "p")
+			return p[:strings.LastIndex(p, "/")+1], err
+		},
+		"os.path.expanduser": func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+			p, err := text(args, kw, 0, "path")
+			if strings.HasPrefix(p, "~") {
+				p = honeyos.GetUserByID(in.sys.CurrentUser()).Homedir + p[1:]
+			}
+			return p, err
+		},
+		"os.path.abspath": func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+			p, err := text(args, kw, 0, "path")
+			return absPath(in.sys, p), err
+		},
+
+		"sys.exit": exit,
+
+		"subprocess.call":            shell,
+		"subprocess.check_call":      shell,
+		"subprocess.run":             shell,
+		"subprocess.check_output":    output,
+		"subprocess.getoutput":       getoutput,
+		"subprocess.getstatusoutput": getoutput,
+		"subprocess.Popen":           popen,
+		"commands.getoutput":         getoutput,
+		"commands.getstatusoutput":   getoutput,
+		"pty.spawn":                  spawn,
+		"os.spawnl":                  spawn,
+		"time.sleep":                 sleep,
+		"time.time":                  func([]pyValue, map[string]pyValue) (pyValue, error) { return float64(time.Now().UnixNano()) / 1e9, nil },
+		"socket.socket":              in.socket,
+		"socket.create_connection":   in.createConnection,
+		"socket.gethostname":         func([]pyValue, map[string]pyValue) (pyValue, error) { return in.sys.Hostname(), nil },
+		"socket.gethostbyname":       in.gethostbyname,
+		"platform.system":            func([]pyValue, map[string]pyValue) (pyValue, error) { return "Linux", nil },
+		"platform.node":              func([]pyValue, map[string]pyValue) (pyValue, error) { return in.sys.Hostname(), nil },
+		"platform.release":           func([]pyValue, map[string]pyValue) (pyValue, error) { return honeyos.KernelRelease(), nil },
+		"platform.version":           func([]pyValue, map[string]pyValue) (pyValue, error) { return honeyos.KernelVersion(), nil },
+		"platform.machine":           func([]pyValue, map[string]pyValue) (pyValue, error) { return honeyos.Arch(), nil },
+		"platform.python_version":    func([]pyValue, map[string]pyValue) (pyValue, error) { return in.build.version, nil },
+		"platform.uname":             uname,
+		"platform.platform":          in.platform,
+		"getpass.getuser":            in.getuser,
+		"getpass.getpass":            in.getpass,
+		"base64.b64decode":           b64decode,
+		"base64.standard_b64decode":  b64decode,
+		"base64.urlsafe_b64decode":   b64decode,
+		"base64.decodestring":        b64decode,
+		"base64.decodebytes":         b64decode,
+		"base64.b64encode":           b64encode,
+		"base64.standard_b64encode":  b64encode,
+		"base64.encodestring":        b64encode,
+		"base64.encodebytes":         b64encode,
+		"binascii.unhexlify":         unhexlify,
+		"binascii.a2b_hex":           unhexlify,
+		"binascii.hexlify":           hexlify,
+		"binascii.b2a_hex":           hexlify,
+		"binascii.a2b_base64":        b64decode,
+		"zlib.decompress":            decompress,
+		"codecs.decode":              in.codec(b64decode, unhexlify, decompress),
+		"urllib.urlopen":             urlopen,
+		"urllib.urlretrieve":         urlretrieve,
+		"urllib2.urlopen":            urlopen,
+		"urllib.request.urlopen":     urlopen,
+		"urllib.request.urlretrieve": urlretrieve,
+	}
+	if in.v3 {
+		delete(funcs, "commands.getoutput")
+		delete(funcs, "commands.getstatusoutput")
+	} else {
+		funcs["raw_input"] = funcs["input"]
+		// print and exec are statements in Python 2
+		delete(funcs, "print")
+		delete(funcs, "exec")
+		delete(funcs, "subprocess.run")
+		delete(funcs, "subprocess.getoutput")
+		delete(funcs, "subprocess.getstatusoutput")
+	}
+	return funcs
+}
+
+// input returns the reader of stdin, shared by the REPL and input
+func (in *pyInterp) input() *bufio.Reader {
+	if in.stdin == nil {
+		in.stdin = bufio.NewReader(in.sys.In())
+	}
+	return in.stdin
+}
+
+// pyShell tells if the command line starts an interactive shell, as the
+// reverse shells end with
+func pyShell(argv []string) bool {
+	if len(argv) == 0 {
+		return false
+	}
+	switch path.Base(argv[0]) {
+	case "sh", "bash", "dash", "ash", "zsh":
+	default:
+		return false
+	}
+	for _, arg := range argv[1:] {
+		if !strings.HasPrefix(arg, "-") || strings.ContainsAny(arg, "c") {
+			return false
+		}
+	}
+	return true
+}
+
+// execute runs the command given as list of arguments, or as string run by
+// the shell if shell is set. The output goes to out if not nil. Shells run
+// after connecting the socket are reverse shells, which hang until the
+// command is interrupted since the peer never sends anything
+func (in *pyInterp) execute(cmd pyValue, shell bool, out io.Writer) (int, error) {
+	var argv []string
+	switch c := cmd.(type) {
+	case string, pyBytes:
+		s, _ := pyText(c)
+		if shell {
+			argv = []string{"sh", "-c", s}
+			if fields := strings.Fields(s); pyShell(fields) {
+				argv = fields
+			}
+		} else {
+			argv = []string{s}
+		}
+	case pyList, pyTuple:
+		seq, _ := c.(pyList)
+		if t, ok := c.(pyTuple); ok {
+			seq = pyList(t)
+		}
+		for _, a := range seq {
+			s, ok := pyText(a)
+			if !ok {
+				return 0, &pyError{"TypeError", fmt.Sprintf("expected str, bytes or os.PathLike object, not %v", in.typeName(a))}
+			}
+			argv = append(argv, s)
+		}
+		if shell && len(argv) > 0 {
+			argv = append([]string{"sh", "-c"}, argv...)
+		}
+	case pyUnknown:
+		return 0, nil
+	default:
+		return 0, &pyError{"TypeError", fmt.Sprintf("'%v' object is not iterable", in.typeName(cmd))}
+	}
+	if len(argv) == 0 {
+		return 0, &pyError{"IndexError", "list index out of range"}
+	}
+	in.sys.Log().WithField("command", argv).Infof("User ran %v with %v", strings.Join(argv, " "), in.name)
+	if pyShell(argv) {
+		if in.remote != "" {
+			in.sys.Log().WithFields(log.Fields{"remote": in.remote, "shell": argv[0]}).Warnf("User started reverse shell to %v with %v", in.remote, in.name)
+			<-in.sys.Context().Done()
+			return 0, pyInterrupted
+		}
+		n, _ := honeyos.RunAs(in.sys, honeyos.Credential{UID: in.sys.CurrentUser(), Stdout: out}, nil)
+		return n, nil
+	}
+	n, found := honeyos.RunAs(in.sys, honeyos.Credential{UID: in.sys.CurrentUser(), Stdout: out}, argv)
+	if !found {
+		return 0, in.osError("OSError", 2, "")
+	}
+	return n, nil
+}
+
+// fileError returns the error of the file operation, raised as py2 in
+// Python 2
+func (in *pyInterp) fileError(py2 string, err error, name string) *pyError {
+	errno := 2
+	switch {
+	case os.IsPermission(err):
+		errno = 13
+	case os.IsNotExist(err):
+	case strings.Contains(err.Error(), "not a directory"):
+		errno = 20
+	default:
+		errno = 21
+	}
+	return in.osError(py2, errno, name)
+}
+
+// open opens the file in the virtual filesystem
+func (in *pyInterp) open(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+	name, ok := pyText(pyArg(args, kw, 0, "file"))
+	if !ok {
+		return pyUnknown{}, nil
+	}
+	mode, ok := pyArg(args, kw, 1, "mode").(string)
+	if !ok {
+		mode = "r"
+	}
+	p := absPath(in.sys, name)
+	f := &pyObject{class: "file", fields: map[string]pyValue{"name": name, "mode": mode}}
+	if !strings.ContainsAny(mode, "wa") {
+		if fi, err := in.sys.FSys().Stat(p); err == nil && fi.IsDir() {
+			return nil, in.osError("IOError", 21, name)
+		}
+		data, err := afero.ReadFile(in.sys.FSys(), p)
+		if err != nil {
+			return nil, in.fileError("IOError", err, name)
+		}
+		f.fields["data"] = string(data)
+		if strings.Contains(mode, "b") {
+			f.fields["data"] = in.bytesOf(string(data))
+		}
+		return f, nil
+	}
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if strings.Contains(mode, "a") {
+		flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	file, err := in.sys.FSys().OpenFile(p, flag, 0666&^in.sys.Umask())
+	if err != nil {
+		return nil, in.fileError("IOError", err, name)
+	}
+	w := &pyFile{f: file}
+	f.fields["w"] = w
+	in.closers = append(in.closers, func() { in.closeFile(name, w) })
+	return f, nil
+}
+
+// closeFile closes the file written, saving what was written
+func (in *pyInterp) closeFile(name string, w *pyFile) {
+	if w.closed {
+		return
+	}
+	w.closed = true
+	w.f.Close()
+	if len(w.data) > 0 {
+		honeyos.SaveArtifact(in.sys, w.data, in.name+" "+name)
+	}
+}
+
+// popen runs the command with its output read from the file returned
+func (in *pyInterp) popen(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+	var buf bytes.Buffer
+	if _, err := in.execute(pyArg(args, kw, 0, "cmd"), true, &buf); err != nil {
+		return nil, err
+	}
+	return &pyObject{class: "file", fields: map[string]pyValue{"name": pyArg(args, kw, 0, "cmd"), "mode": "r", "data": buf.String()}}, nil
+}
+
+// stat returns the file of the first argument
+func (in *pyInterp) stat(args []pyValue, kw map[string]pyValue) (os.FileInfo, error) {
+	name, _ := pyText(pyArg(args, kw, 0, "path"))
+	return in.sys.FSys().Stat(absPath(in.sys, name))
+}
+
+func (in *pyInterp) listdir(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+	name, ok := pyText(pyArg(args, kw, 0, "path"))
+	if !ok {
+		name = "."
+	}
+	infos, err := afero.ReadDir(in.sys.FSys(), absPath(in.sys, name))
+	if err != nil {
+		return nil, in.fileError("OSError", err, name)
+	}
+	var l pyList
+	for _, fi := range infos {
+		l = append(l, fi.Name())
+	}
+	return l, nil
+}
+
+func (in *pyInterp) chdir(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+	name, _ := pyText(pyArg(args, kw, 0, "path"))
+	if err := in.sys.Chdir(absPath(in.sys, name)); err != nil {
+		return nil, in.fileError("OSError", err, name)
+	}
+	return nil, nil
+}
+
+func (in *pyInterp) remove(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+	name, _ := pyText(pyArg(args, kw, 0, "path"))
+	if err := in.sys.FSys().Remove(absPath(in.sys, name)); err != nil {
+		return nil, in.fileError("OSError", err, name)
+	}
+	return nil, nil
+}
+
+// environGet is os.getenv and os.environ.get
+func (in *pyInterp) environGet(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+	key, _ := pyText(pyArg(args, kw, 0, "key"))
+	if v, ok := in.getenv(key); ok {
+		return v, nil
+	}
+	return pyArg(args, kw, 1, "default"), nil
+}
+
+func (in *pyInterp) pathJoin(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+	p := ""
+	for _, a := range args {
+		s, _ := pyText(a)
+		switch {
+		case strings.HasPrefix(s, "/"), p == "":
+			p = s
+		case strings.HasSuffix(p, "/"):
+			p += s
+		default:
+			p += "/" + s
+		}
+	}
+	return p, nil
+}
+
+func (in *pyInterp) getuser([]pyValue, map[string]pyValue) (pyValue, error) {
+	return honeyos.GetUserByID(in.sys.CurrentUser()).Name, nil
+}
+
+// getpass reads the password without echo, logged like the other input
+func (in *pyInterp) getpass(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+	prompt, ok := pyArg(args, kw, 0, "prompt").(string)
+	if !ok {
+		prompt = "Password: "
+	}
+	fmt.Fprint(in.sys.Err(), prompt)
+	line, _ := in.input().ReadString('\n')
+	fmt.Fprintln(in.sys.Err())
+	line = strings.TrimRight(line, "\r\n")
+	in.sys.Log().WithField("password", line).Infof("User typed password into %v", in.name)
+	return line, nil
+}
+
+func (in *pyInterp) platform([]pyValue, map[string]pyValue) (pyValue, error) {
+	id, _, release, _ := honeyos.DistroName()
+	return fmt.Sprintf("Linux-%v-%v-with-%v-%v", honeyos.KernelRelease(), honeyos.Arch(), id, release), nil
+}
+
+// codec returns codecs.decode for base64, hex and zlib, the codecs payloads
+// are encoded with
+func (in *pyInterp) codec(b64, hex, zlib func([]pyValue, map[string]pyValue) (pyValue, error)) func([]pyValue, map[string]pyValue) (pyValue, error) {
+	return func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+		name, _ := pyArg(args, kw, 1, "encoding").(string)
+		switch strings.ToLower(strings.Replace(name, "_", "", -1)) {
+		case "base64", "base64codec":
+			return b64(args[:1], nil)
+		case "hex", "hexcodec":
+			return hex(args[:1], nil)
+		case "zlib", "zlibcodec":
+			return zlib(args[:1], nil)
+		case "rot13", "rot13codec":
+			s, _ := pyText(pyArg(args, kw, 0, "obj"))
+			return strings.Map(func(r rune) rune {
+				switch {
+				case r >= 'a' && r <= 'z':
+					return 'a' + (r-'a'+13)%26
+				case r >= 'A' && r <= 'Z':
+					return 'A' + (r-'A'+13)%26
+				}
+				return r
+			}, s), nil
+		}
+		return pyArg(args, kw, 0, "obj"), nil
+	}
+}
+
+// gethostbyname resolves the host, raising gaierror for unknown ones
+func (in *pyInterp) gethostbyname(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+	host, _ := pyText(pyArg(args, kw, 0, "hostname"))
+	ip := netResolve(in.sys, host)
+	if ip == nil {
+		return nil, &pyError{"socket.gaierror", "[Errno -2] Name or service not known"}
+	}
+	return ip.String(), nil
+}
+
+func (in *pyInterp) socket([]pyValue, map[string]pyValue) (pyValue, error) {
+	return &pyObject{class: "socket", fields: map[string]pyValue{}}, nil
+}
+
+func (in *pyInterp) createConnection(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+	s := &pyObject{class: "socket", fields: map[string]pyValue{}}
+	if _, err := in.connect(s, pyArg(args, kw, 0, "address")); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// connect connects the socket to the address as simulated by the network
+// of the honeypot. The connection is logged as the start of the reverse
+// shells. It returns the errno of the failure for connect_ex
+func (in *pyInterp) connect(s *pyObject, addr pyValue) (int, error) {
+	t, ok := addr.(pyTuple)
+	if !ok || len(t) != 2 {
+		return 0, &pyError{"TypeError", "getsockaddrarg: AF_INET address must be tuple, not " + in.typeName(addr)}
+	}
+	host, _ := pyText(t[0])
+	port, ok := pyInt(t[1])
+	if !ok {
+		return 0, &pyError{"TypeError", "an integer is required (got type " + in.typeName(t[1]) + ")"}
+	}
+	in.sys.Log().WithFields(log.Fields{"host": host, "port": port}).Warnf("User connecting to %v:%v with %v", host, port, in.name)
+	ip := netResolve(in.sys, host)
+	if ip == nil {
+		return 0, &pyError{"socket.gaierror", "[Errno -2] Name or service not known"}
+	}
+	open, reachable := netPortOpen(in.sys, ip, port)
+	switch {
+	case !reachable:
+		if !pkgSleep(in.sys, 127*time.Second) {
+			return 0, pyInterrupted
+		}
+		return 110, in.osError("socket.error", 110, "")
+	case !open:
+		return 111, in.osError("socket.error", 111, "")
+	}
+	in.remote = fmt.Sprintf("%v:%v", ip, port)
+	s.fields["remote"] = in.remote
+	in.closers = append(in.closers, honeyos.OpenSocket(in.sys, honeyos.SockInfo{
+		Proto: "tcp", Local: fmt.Sprintf("%v:%v", honeyos.IPAddress(), 40000+port%20000), Remote: in.remote, State: "ESTABLISHED",
+	}))
+	return 0, nil
+}
+
+// method returns the method of the object simulated. Methods not simulated
+// do nothing
+func (in *pyInterp) method(obj *pyObject, name string) (pyValue, error) {
+	var f func(args []pyValue, kw map[string]pyValue) (pyValue, error)
+	switch obj.class + "." + name {
+	case "socket.connect", "socket.connect_ex":
+		f = func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+			errno, err := in.connect(obj, pyArg(args, kw, 0, "address"))
+			if name == "connect_ex" && errno != 0 {
+				return errno, nil
+			}
+			return nil, err
+		}
+	case "socket.bind":
+		f = func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+			var port int
+			if t, ok := pyArg(args, kw, 0, "address").(pyTuple); ok && len(t) == 2 {
+				port, _ = pyInt(t[1])
+			}
+			if port < 1024 && !isRoot(in.sys) {
+				return nil, in.osError("socket.error", 13, "")
+			}
+			if open, _ := netPortOpen(in.sys, net.ParseIP("127.0.0.1"), port); open {
+				return nil, in.osError("socket.error", 98, "")
+			}
+			obj.fields["port"] = port
+			return nil, nil
+		}
+	case "socket.listen":
+		f = func([]pyValue, map[string]pyValue) (pyValue, error) {
+			port, _ := obj.fields["port"].(int)
+			in.sys.Log().WithField("port", port).Warnf("User listening on port %v with %v", port, in.name)
+			in.closers = append(in.closers, honeyos.OpenSocket(in.sys, honeyos.SockInfo{
+				Proto: "tcp", Local: "0.0.0.0:" + strconv.Itoa(port), Remote: "0.0.0.0:*", State: "LISTEN",
+			}))
+			return nil, nil
+		}
+	case "socket.accept", "socket.recv", "socket.recvfrom", "socket.recv_into":
+		f = func([]pyValue, map[string]pyValue) (pyValue, error) {
+			if _, ok := obj.fields["remote"]; !ok && name != "accept" {
+				return nil, in.osError("socket.error", 107, "")
+			}
+			// Nobody connects nor sends anything
+			<-in.sys.Context().Done()
+			return nil, pyInterrupted
+		}
+	case "socket.send", "socket.sendall", "socket.sendto":
+		f = func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+			data, _ := pyText(pyArg(args, kw, 0, "data"))
+			remote, ok := obj.fields["remote"]
+			if !ok {
+				return nil, in.osError("socket.error", 32, "")
+			}
+			in.sys.Log().WithField("data", data).Infof("User sent %v bytes to %v with %v", len(data), remote, in.name)
+			if name == "sendall" {
+				return nil, nil
+			}
+			return len(data), nil
+		}
+	case "socket.fileno":
+		f = func([]pyValue, map[string]pyValue) (pyValue, error) { return 3, nil }
+
+	case "file.read", "HTTPResponse.read":
+		f = func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+			data := obj.fields["data"]
+			if data == nil && obj.fields["name"] == "<stdin>" {
+				b, _ := ioutil.ReadAll(in.input())
+				data = string(b)
+			}
+			s, _ := pyText(data)
+			pos, _ := obj.fields["pos"].(int)
+			end := len(s)
+			if n, ok := pyInt(pyArg(args, kw, 0, "size")); ok && n >= 0 && pos+n < end {
+				end = pos + n
+			}
+			obj.fields["pos"] = end
+			if _, ok := data.(pyBytes); ok {
+				return pyBytes(s[pos:end]), nil
+			}
+			return s[pos:end], nil
+		}
+	case "file.readline":
+		f = func([]pyValue, map[string]pyValue) (pyValue, error) {
+			if obj.fields["name"] == "<stdin>" {
+				line, _ := in.input().ReadString('\n')
+				return line, nil
+			}
+			s, _ := pyText(obj.fields["data"])
+			pos, _ := obj.fields["pos"].(int)
+			end := len(s)
+			if i := strings.IndexByte(s[pos:], '\n'); i >= 0 {
+				end = pos + i + 1
+			}
+			obj.fields["pos"] = end
+			return s[pos:end], nil
+		}
+	case "file.readlines", "HTTPResponse.readlines":
+		f = func([]pyValue, map[string]pyValue) (pyValue, error) {
+			s, _ := pyText(obj.fields["data"])
+			var l pyList
+			for _, line := range strings.SplitAfter(s, "\n") {
+				if line != "" {
+					l = append(l, line)
+				}
+			}
+			return l, nil
+		}
+	case "file.write":
+		f = func(args []pyValue, kw map[string]pyValue) (pyValue, error) {
+			s, _ := pyText(pyArg(args, kw, 0, "s"))
+			switch obj.fields["name"] {
+			case "<stdout>":
+				fmt.Fprint(in.sys.Out(), s)
+			case "<stderr>":
+				fmt.Fprint(in.sys.Err(), s)
+			default:
+				w, ok := obj.fields["w"].(*pyFile)
+				if !ok || w.closed {
+					return nil, &pyError{"ValueError", "I/O operation on closed file."}
+				}
+				w.f.Write([]byte(s))
+				w.data = append(w.data, s...)
+			}
+			return len(s), nil
+		}
+	case "file.close":
+		f = func([]pyValue, map[string]pyValue) (pyValue, error) {
+			if w, ok := obj.fields["w"].(*pyFile); ok {
+				in.closeFile(in.str(obj.fields["name"]), w)
+			}
+			return nil, nil
+		}
+	case "file.fileno":
+		f = func([]pyValue, map[string]pyValue) (pyValue, error) {
+			switch obj.fields["name"] {
+			case "<stdin>":
+				return 0, nil
+			case "<stdout>":
+				return 1, nil
+			case "<stderr>":
+				return 2, nil
+			}
+			return 3, nil
+		}
+	case "HTTPResponse.getcode":
+		f = func([]pyValue, map[string]pyValue) (pyValue, error) { return obj.fields["status"], nil }
+	case "HTTPResponse.geturl":
+		f = func([]pyValue, map[string]pyValue) (pyValue, error) { return obj.fields["url"], nil }
+	case "Popen.communicate":
+		f = func([]pyValue, map[string]pyValue) (pyValue, error) {
+			stdout := obj.fields["stdout"].(*pyObject)
+			return pyTuple{stdout.fields["data"], nil}, nil
+		}
+	case "Popen.wait", "Popen.poll":
+		f = func([]pyValue, map[string]pyValue) (pyValue, error) { return obj.fields["returncode"], nil }
+	case "environ.get":
+		f = in.environGet
+	case "environ.keys":
+		f = func([]pyValue, map[string]pyValue) (pyValue, error) {
+			var keys []string
+			for _, env := range in.sys.Environ() {
+				keys = append(keys, strings.SplitN(env, "=", 2)[0])
+			}
+			sort.Strings(keys)
+			var l pyList
+			for _, k := range keys {
+				l = append(l, k)
+			}
+			return l, nil
+		}
+	default:
+		return pyUnknown{}, nil
+	}
+	return &pyFunc{name: name, call: f}, nil
+}
+
+// fetch downloads the url with curl as urlopen does, so the download is
+// logged and the network simulated like curl
+func (in *pyInterp) fetch(url string) (string, error) {
+	if !strings.Contains(url, "://") {
+		if in.v3 {
+			return "", &pyError{"ValueError", "unknown url type: " + in.repr(url)}
+		}
+		return "", &pyError{"ValueError", "unknown url type: " + url}
+	}
+	in.sys.Log().WithField("url", url).Infof("User downloading %v with %v", url, in.name)
+	var buf bytes.Buffer
+	n, found := honeyos.RunAs(in.sys, honeyos.Credential{UID: in.sys.CurrentUser(), Stdout: &buf}, []string{"curl", "-s", "-L", url})
+	if found && n == 0 {
+		return buf.String(), nil
+	}
+	reason := "[Errno 104] Connection reset by peer"
+	switch n {
+	case 6:
+		reason = "[Errno -2] Name or service not known"
+	case 7:
+		reason = "[Errno 111] Connection refused"
+	case 28:
+		reason = "[Errno 110] Connection timed out"
+	}
+	kind := "urllib.error.URLError"
+	if !in.v3 {
+		kind = "urllib2.URLError"
+	}
+	return "", &pyError{kind, "<urlopen error " + reason + ">"}
+}
+
+// repl runs the interactive interpreter, with the banner printed unless
+// it follows the script run with -i. Each line is logged as typed
+func (in *pyInterp) repl(banner bool) int {
+	in.echo, in.file = true, "<stdin>"
+	platform, _ := in.constant("sys.platform")
+	if banner {
+		fmt.Fprintf(in.sys.Err(), "Python %v %v on %v\nType \"help\", \"copyright\", \"credits\" or \"license\" for more information.\n",
+			in.build.version, in.build.build, platform)
+	}
+	var block []string
+	for {
+		prompt := ">>> "
+		if len(block) > 0 {
+			prompt = "... "
+		}
+		fmt.Fprint(in.sys.Err(), prompt)
+		line, err := in.input().ReadString('\n')
+		if err != nil && line == "" {
+			if select1(in.sys.Context().Done()) {
+				fmt.Fprintln(in.sys.Err(), "\nKeyboardInterrupt")
+				return 130
+			}
+			fmt.Fprintln(in.sys.Err())
+			return 0
+		}
+		line = strings.TrimRight(line, "\r\n")
+		in.sys.Log().Infof("User typed %v into %v", line, in.name)
+		// Compound statements go on until the blank line
+		if len(block) > 0 || strings.HasSuffix(strings.TrimSpace(line), ":") {
+			if strings.TrimSpace(line) != "" {
+				block = append(block, line)
+				continue
+			}
+			line, block = strings.Join(block, "\n"), nil
+		}
+		in.lines = strings.Split(line, "\n")
+		err = in.run(line)
+		if t, ok := err.(*pyTrace); ok {
+			if e, ok := t.err.(pyExit); ok {
+				return e.code
+			}
+		}
+		if status := in.report(err); status == 130 {
+			return status
+		}
+	}
+}
+
+// module runs the library module of -m. The HTTP server attackers use to
+// serve files listens until interrupted, the others do nothing
+func (in *pyInterp) module(name string) int {
+	in.sys.Log().WithField("module", name).Infof("User ran module %v with %v", name, in.name)
+	if cmd := (pip{"pip", in.v3}); name == "pip" && cmd.installed(in.sys) {
+		return cmd.Exec(in.argv[1:], in.sys)
+	}
+	if _, err := in.importModule(name); err != nil || name == "pip" {
+		fmt.Fprintf(in.sys.Err(), "/usr/bin/%v: No module named %v\n", in.name, name)
+		return 1
+	}
+	if name != "http.server" && name != "SimpleHTTPServer" {
+		return 0
+	}
+	port := 8000
+	if len(in.argv) > 1 {
+		if p, err := strconv.Atoi(in.argv[1]); err == nil {
+			port = p
+		}
+	}
+	if port < 1024 && !isRoot(in.sys) {
+		fmt.Fprintf(in.sys.Err(), "Traceback (most recent call last):\n  File \"/usr/lib/python%v/socketserver.py\", line 452, in server_bind\n    self.socket.bind(self.server_address)\n%v\n",
+			in.build.version[:3], in.osError("socket.error", 13, "").Error())
+		return 1
+	}
+	in.sys.Log().WithField("port", port).Warnf("User started HTTP server on port %v with %v", port, in.name)
+	defer honeyos.OpenSocket(in.sys, honeyos.SockInfo{Proto: "tcp", Local: "0.0.0.0:" + strconv.Itoa(port), Remote: "0.0.0.0:*", State: "LISTEN"})()
+	if in.v3 && !strings.HasPrefix(in.build.version, "3.5") {
+		fmt.Fprintf(in.sys.Out(), "Serving HTTP on 0.0.0.0 port %v (http://0.0.0.0:%v/) ...\n", port, port)
+	} else {
+		fmt.Fprintf(in.sys.Out(), "Serving HTTP on 0.0.0.0 port %v ...\n", port)
+	}
+	<-in.sys.Context().Done()
+	if in.v3 {
+		fmt.Fprintln(in.sys.Out(), "\nKeyboard interrupt received, exiting.")
+		return 0
+	}
+	fmt.Fprintln(in.sys.Err(), "^CTraceback (most recent call last):\nKeyboardInterrupt")
+	return 130
+}
@@ -0,0 +1,558 @@
+package command
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Tokens of Python source
+const (
+	pyTokEOF = iota
+	pyTokName
+	pyTokNumber
+	pyTokString
+	pyTokBytes
+	pyTokPunct
+)
+
+type pyToken struct {
+	kind int
+	s    string
+}
+
+// pyPuncts are the operators and delimiters of Python, longest first
+var pyPuncts = []string{"**=", "//=", ">>=", "<<=", "**", "//", "==", "!=", "<=", ">=", "+=", "-=", "*=",
+	"/=", "%=", "&=", "|=", "^=", "->", "<<", ">>", "+", "-", "*", "/", "%", "(", ")", "[", "]", "{", "}",
+	",", ".", ":", ";", "=", "<", ">", "&", "|", "^", "~", "@", "!"}
+
+// pySyntaxError is an error in the source, at the offset of the statement
+type pySyntaxError struct {
+	msg    string
+	offset int
+}
+
+func (e pySyntaxError) Error() string {
+	return e.msg
+}
+
+// pyUnsupported is the code the interpreter cannot parse, which is skipped
+// rather than failing code that would run fine on the real one
+type pyUnsupported struct{}
+
+func (pyUnsupported) Error() string {
+	return "unsupported"
+}
+
+// pyStmt is a statement of the source, with the line it starts and its
+// indent. Compound statements like if and for are kept whole with their body
+type pyStmt struct {
+	src      string
+	line     int
+	indent   int
+	compound bool
+}
+
+// pyCompound are the keywords starting compound statements
+var pyCompound = map[string]bool{"if": true, "elif": true, "else": true, "for": true, "while": true, "def": true,
+	"class": true, "try": true, "except": true, "finally": true, "with": true, "async": true, "@": true}
+
+// pyStatements splits the source into statements, by the lines outside
+// brackets and strings and the semicolons on them
+func pyStatements(src string) ([]pyStmt, error) {
+	var stmts []pyStmt
+	var b strings.Builder
+	line, start, depth := 1, 1, 0
+	flush := func() {
+		text := b.String()
+		b.Reset()
+		trimmed := strings.TrimLeft(text, " \t")
+		if strings.TrimSpace(trimmed) == "" {
+			return
+		}
+		indent := len(text) - len(trimmed)
+		word := trimmed
+		if i := strings.IndexAny(word, " \t(:"); i >= 0 {
+			word = word[:i]
+		}
+		if pyCompound[word] || strings.HasPrefix(word, "@") {
+			stmts = append(stmts, pyStmt{strings.TrimSpace(trimmed), start, indent, true})
+			return
+		}
+		for _, s := range pySplitSemicolons(trimmed) {
+			if s = strings.TrimSpace(s); s != "" {
+				stmts = append(stmts, pyStmt{s, start, indent, false})
+			}
+		}
+	}
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			i--
+		case c == '\'' || c == '"':
+			end, err := pyStringEnd(src, i)
+			if err != nil {
+				return nil, err
+			}
+			line += strings.Count(src[i:end], "\n")
+			b.WriteString(src[i:end])
+			i = end - 1
+		case c == '\\' && i+1 < len(src) && src[i+1] == '\n':
+			i++
+			line++
+		case c == '\n':
+			line++
+			if depth > 0 {
+				b.WriteByte(' ')
+				continue
+			}
+			flush()
+			start = line
+		default:
+			switch c {
+			case '(', '[', '{':
+				depth++
+			case ')', ']', '}':
+				depth--
+			}
+			b.WriteByte(c)
+		}
+	}
+	flush()
+	return stmts, nil
+}
+
+// pySplitSemicolons splits the line by the semicolons outside strings
+func pySplitSemicolons(s string) []string {
+	var parts []string
+	last := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\'', '"':
+			if end, err := pyStringEnd(s, i); err == nil {
+				i = end - 1
+			}
+		case ';':
+			parts = append(parts, s[last:i])
+			last = i + 1
+		}
+	}
+	return append(parts, s[last:])
+}
+
+// pyStringEnd returns the offset after the string literal starting at i
+func pyStringEnd(s string, i int) (int, error) {
+	q := s[i : i+1]
+	if strings.HasPrefix(s[i:], q+q+q) {
+		q = q + q + q
+	}
+	for j := i + len(q); j < len(s); j++ {
+		switch {
+		case s[j] == '\\':
+			j++
+		case strings.HasPrefix(s[j:], q):
+			return j + len(q), nil
+		case s[j] == '\n' && len(q) == 1:
+			return 0, pySyntaxError{"EOL while scanning string literal", j - 1}
+		}
+	}
+	if len(q) == 3 {
+		return 0, pySyntaxError{"EOF while scanning triple-quoted string literal", len(s) - 1}
+	}
+	return 0, pySyntaxError{"EOL while scanning string literal", len(s)}
+}
+
+// pyUnescape processes the escapes of the body of the string literal
+func pyUnescape(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch c := s[i]; c {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case '0':
+			b.WriteByte(0)
+		case '\\', '\'', '"':
+			b.WriteByte(c)
+		case '\n':
+		case 'x':
+			if i+3 <= len(s) {
+				if n, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+					b.WriteByte(byte(n))
+					i += 2
+					continue
+				}
+			}
+			b.WriteString("\\x")
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// pyLex splits the statement into tokens
+func pyLex(src string) ([]pyToken, error) {
+	var toks []pyToken
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '\'' || c == '"':
+			end, err := pyStringEnd(src, i)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, pyString(src[i:end], ""))
+			i = end
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9':
+			j := i
+			for j < len(src) && (pyNameChar(src[j]) || src[j] == '.') {
+				j++
+			}
+			toks = append(toks, pyToken{pyTokNumber, src[i:j]})
+			i = j
+		case pyNameChar(c) || c >= 0x80:
+			j := i
+			for j < len(src) && (pyNameChar(src[j]) || src[j] >= 0x80) {
+				j++
+			}
+			word := src[i:j]
+			// String prefixes like r, b and rb
+			if j < len(src) && (src[j] == '\'' || src[j] == '"') && len(word) <= 2 &&
+				strings.Trim(strings.ToLower(word), "rbuf") == "" {
+				end, err := pyStringEnd(src, j)
+				if err != nil {
+					return nil, err
+				}
+				toks = append(toks, pyString(src[j:end], strings.ToLower(word)))
+				i = end
+				continue
+			}
+			toks = append(toks, pyToken{pyTokName, word})
+			i = j
+		default:
+			matched := false
+			for _, p := range pyPuncts {
+				if strings.HasPrefix(src[i:], p) {
+					toks = append(toks, pyToken{pyTokPunct, p})
+					i += len(p)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, pySyntaxError{"invalid syntax", i}
+			}
+		}
+	}
+	return toks, nil
+}
+
+// pyNameChar tells if the byte can be in a name
+func pyNameChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// pyString returns the token of the string literal with the prefix
+func pyString(lit, prefix string) pyToken {
+	q := 1
+	if len(lit) >= 6 && (strings.HasPrefix(lit, `"""`) || strings.HasPrefix(lit, `'''`)) {
+		q = 3
+	}
+	body := lit[q : len(lit)-q]
+	if !strings.Contains(prefix, "r") {
+		body = pyUnescape(body)
+	}
+	if strings.Contains(prefix, "b") {
+		return pyToken{pyTokBytes, body}
+	}
+	return pyToken{pyTokString, body}
+}
+
+// Nodes of Python expressions
+type (
+	pyExpr interface{}
+	pyLit  struct{ v pyValue }
+	pyName struct{ name string }
+	pyAttr struct {
+		x    pyExpr
+		name string
+	}
+	pyCall struct {
+		fn   pyExpr
+		args []pyExpr
+		kw   map[string]pyExpr
+	}
+	pyIndex struct{ x, index pyExpr }
+	// pySlice is x[lo:hi], either bound nil if left out
+	pySlice struct{ x, lo, hi pyExpr }
+	pyBin   struct {
+		op   string
+		x, y pyExpr
+	}
+	pyNeg struct{ x pyExpr }
+	pySeq struct {
+		elems []pyExpr
+		list  bool
+	}
+)
+
+// pyParser parses the expression of a statement
+type pyParser struct {
+	toks []pyToken
+	pos  int
+}
+
+func (p *pyParser) peek() pyToken {
+	if p.pos >= len(p.toks) {
+		return pyToken{kind: pyTokEOF}
+	}
+	return p.toks[p.pos]
+}
+
+func (p *pyParser) next() pyToken {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+// is tells if the next token is the punctuation
+func (p *pyParser) is(s string) bool {
+	t := p.peek()
+	return t.kind == pyTokPunct && t.s == s
+}
+
+func (p *pyParser) expect(s string) error {
+	if !p.is(s) {
+		return pyUnsupported{}
+	}
+	p.pos++
+	return nil
+}
+
+// tuple parses expressions separated by commas, a tuple if more than one
+func (p *pyParser) tuple() (pyExpr, error) {
+	x, err := p.expr()
+	if err != nil || !p.is(",") {
+		return x, err
+	}
+	elems := []pyExpr{x}
+	for p.is(",") {
+		p.pos++
+		if t := p.peek(); t.kind == pyTokEOF || t.kind == pyTokPunct && (t.s == "=" || t.s == ")") {
+			break
+		}
+		x, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, x)
+	}
+	return pySeq{elems: elems}, nil
+}
+
+var pyPrecedence = map[string]int{"+": 1, "-": 1, "*": 2, "/": 2, "//": 2, "%": 2, "**": 3}
+
+func (p *pyParser) expr() (pyExpr, error) {
+	return p.binary(1)
+}
+
+func (p *pyParser) binary(prec int) (pyExpr, error) {
+	x, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		level, ok := pyPrecedence[t.s]
+		if t.kind != pyTokPunct || !ok || level < prec {
+			return x, nil
+		}
+		p.pos++
+		y, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = pyBin{t.s, x, y}
+	}
+}
+
+func (p *pyParser) unary() (pyExpr, error) {
+	if p.is("-") || p.is("+") {
+		neg := p.next().s == "-"
+		x, err := p.unary()
+		if neg {
+			x = pyNeg{x}
+		}
+		return x, err
+	}
+	return p.postfix()
+}
+
+func (p *pyParser) postfix() (pyExpr, error) {
+	x, err := p.atom()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.is("."):
+			p.pos++
+			t := p.next()
+			if t.kind != pyTokName {
+				return nil, pyUnsupported{}
+			}
+			x = pyAttr{x, t.s}
+		case p.is("("):
+			p.pos++
+			call := pyCall{fn: x, kw: map[string]pyExpr{}}
+			for !p.is(")") {
+				if t := p.peek(); t.kind == pyTokName && p.pos+1 < len(p.toks) &&
+					p.toks[p.pos+1].kind == pyTokPunct && p.toks[p.pos+1].s == "=" {
+					p.pos += 2
+					v, err := p.expr()
+					if err != nil {
+						return nil, err
+					}
+					call.kw[t.s] = v
+				} else {
+					arg, err := p.expr()
+					if err != nil {
+						return nil, err
+					}
+					call.args = append(call.args, arg)
+				}
+				if !p.is(",") {
+					break
+				}
+				p.pos++
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			x = call
+		case p.is("["):
+			p.pos++
+			var lo, hi pyExpr
+			var err error
+			if !p.is(":") {
+				if lo, err = p.expr(); err != nil {
+					return nil, err
+				}
+			}
+			slice := p.is(":")
+			if slice {
+				p.pos++
+				if !p.is("]") {
+					if hi, err = p.expr(); err != nil {
+						return nil, err
+					}
+				}
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			if slice {
+				x = pySlice{x, lo, hi}
+			} else {
+				x = pyIndex{x, lo}
+			}
+		default:
+			return x, nil
+		}
+	}
+}
+
+func (p *pyParser) atom() (pyExpr, error) {
+	t := p.next()
+	switch t.kind {
+	case pyTokName:
+		switch t.s {
+		case "None":
+			return pyLit{nil}, nil
+		case "True":
+			return pyLit{true}, nil
+		case "False":
+			return pyLit{false}, nil
+		case "lambda", "not", "yield", "await", "if", "for", "in", "and", "or", "is":
+			return nil, pyUnsupported{}
+		}
+		return pyName{t.s}, nil
+	case pyTokNumber:
+		s := strings.Replace(t.s, "_", "", -1)
+		if n, err := strconv.ParseInt(s, 0, 64); err == nil {
+			return pyLit{int(n)}, nil
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return pyLit{f}, nil
+		}
+		return nil, pySyntaxError{"invalid syntax", 0}
+	case pyTokString, pyTokBytes:
+		// Adjacent literals are joined
+		s := t.s
+		for n := p.peek(); n.kind == t.kind; n = p.peek() {
+			s += p.next().s
+		}
+		if t.kind == pyTokBytes {
+			return pyLit{pyBytes(s)}, nil
+		}
+		return pyLit{s}, nil
+	case pyTokPunct:
+		switch t.s {
+		case "(", "[":
+			end := map[string]string{"(": ")", "[": "]"}[t.s]
+			seq := pySeq{list: t.s == "["}
+			for !p.is(end) {
+				x, err := p.expr()
+				if err != nil {
+					return nil, err
+				}
+				seq.elems = append(seq.elems, x)
+				if !p.is(",") {
+					// A single expression in parentheses is not a tuple
+					if !seq.list && len(seq.elems) == 1 && p.is(")") {
+						p.pos++
+						return x, nil
+					}
+					break
+				}
+				p.pos++
+			}
+			if err := p.expect(end); err != nil {
+				return nil, err
+			}
+			return seq, nil
+		}
+	}
+	return nil, pyUnsupported{}
+}
+
+// pyParseExpr parses the whole of the tokens as an expression list
+func pyParseExpr(toks []pyToken) (pyExpr, error) {
+	p := &pyParser{toks: toks}
+	x, err := p.tuple()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, pyUnsupported{}
+	}
+	return x, nil
+}
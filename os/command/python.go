@@ -0,0 +1,1503 @@
+package command
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/afero"
+)
+
+// python is the Python interpreter. It runs the simple statements of the
+// code, which is enough for the one-liners of attackers like reverse shells
+// and decoders of payloads, and passes over what it cannot run. The code is
+// logged as given
+type python struct {
+	name string
+	v3   bool
+}
+
+func init() {
+	honeyos.RegisterCommand("python", python{"python", false})
+	honeyos.RegisterCommand("python2", python{"python2", false})
+	honeyos.RegisterCommand("python3", python{"python3", true})
+}
+
+func (p python) GetHelp() string {
+	return "usage: " + p.name + " [option] ... [-c cmd | -m mod | file | -] [arg] ...\n" +
+		"Options and arguments (and corresponding environment variables):\n" +
+		"-B     : don't write .py[co] files on import; also PYTHONDONTWRITEBYTECODE=x\n" +
+		"-c cmd : program passed in as string (terminates option list)\n" +
+		"-E     : ignore PYTHON* environment variables (such as PYTHONPATH)\n" +
+		"-h     : print this help message and exit (also --help)\n" +
+		"-i     : inspect interactively after running script; forces a prompt even\n" +
+		"         if stdin does not appear to be a terminal; also PYTHONINSPECT=x\n" +
+		"-m mod : run library module as a script (terminates option list)\n" +
+		"-O     : optimize generated bytecode slightly; also PYTHONOPTIMIZE=x\n" +
+		"-q     : don't print version and copyright messages on interactive startup\n" +
+		"-s     : don't add user site directory to sys.path; also PYTHONNOUSERSITE\n" +
+		"-S     : don't imply 'import site' on initialization\n" +
+		"-u     : unbuffered binary stdout and stderr, stdin always buffered\n" +
+		"-v     : verbose (trace import statements); also PYTHONVERBOSE=x\n" +
+		"-V     : print the Python version number and exit (also --version)\n" +
+		"-x     : skip first line of source, allowing use of non-Unix forms of #!cmd\n" +
+		"file   : program read from script file\n" +
+		"-      : program read from stdin (default; interactive mode if a tty)\n" +
+		"arg ...: arguments passed to program in sys.argv[1:]\n"
+}
+
+func (p python) Where() string {
+	return "/usr/bin/" + p.name
+}
+
+// pyBuild is the Python of the distribution, with the build shown in the
+// banner of the REPL
+type pyBuild struct {
+	version, build string
+}
+
+// pyBuilds are the Python 2 and 3 of the distributions
+var pyBuilds = map[string][2]pyBuild{
+	"ubuntu": {{"2.7.12", "(default, Mar  1 2021, 11:38:31) \n[GCC 5.4.0 20160609]"},
+		{"3.5.2", "(default, Jan 26 2021, 13:30:48) \n[GCC 5.4.0 20160609]"}},
+	"debian": {{"2.7.13", "(default, Aug 22 2020, 10:03:02) \n[GCC 6.3.0 20170516]"},
+		{"3.5.3", "(default, Nov 18 2020, 16:51:42) \n[GCC 6.3.0 20170516]"}},
+	"centos": {{"2.7.5", "(default, Nov 16 2020, 22:23:17) \n[GCC 4.8.5 20150623 (Red Hat 4.8.5-44)]"},
+		{"3.6.8", "(default, Nov 16 2020, 16:55:22) \n[GCC 4.8.5 20150623 (Red Hat 4.8.5-44)]"}},
+	"alpine": {{"2.7.18", "(default, Jan 24 2021, 13:22:56) \n[GCC 10.2.1 20201203]"},
+		{"3.9.5", "(default, May 12 2021, 20:44:22) \n[GCC 10.3.1 20210424]"}},
+}
+
+// pyModules are the modules that can be imported, of both versions or only
+// of Python 2 or 3 as the value is 2 or 3
+var pyModules = map[string]int{
+	"os": 0, "os.path": 0, "sys": 0, "socket": 0, "subprocess": 0, "pty": 0, "time": 0, "base64": 0,
+	"json": 0, "random": 0, "threading": 0, "re": 0, "string": 0, "struct": 0, "hashlib": 0, "platform": 0,
+	"getpass": 0, "shutil": 0, "signal": 0, "select": 0, "telnetlib": 0, "zlib": 0, "binascii": 0,
+	"codecs": 0, "math": 0, "datetime": 0, "uuid": 0, "ssl": 0, "fcntl": 0, "tempfile": 0, "ftplib": 0,
+	"smtplib": 0, "urllib": 0, "collections": 0, "itertools": 0, "functools": 0, "io": 0, "argparse": 0,
+	"getopt": 0, "glob": 0, "stat": 0, "errno": 0, "logging": 0, "pickle": 0, "marshal": 0, "ctypes": 0,
+	"multiprocessing": 0, "traceback": 0, "warnings": 0, "crypt": 0, "pwd": 0, "grp": 0, "termios": 0,
+	"tty": 0, "resource": 0, "gzip": 0, "zipfile": 0, "tarfile": 0, "csv": 0, "copy": 0, "operator": 0,
+	"types": 0, "atexit": 0, "webbrowser": 0, "__future__": 0, "xml": 0, "email": 0, "http": 3,
+	"urllib2": 2, "httplib": 2, "Queue": 2, "urlparse": 2, "cStringIO": 2, "StringIO": 2, "commands": 2,
+	"thread": 2, "SimpleHTTPServer": 2, "BaseHTTPServer": 2, "SocketServer": 2,
+	"urllib.request": 3, "urllib.parse": 3, "urllib.error": 3, "http.client": 3, "http.server": 3,
+	"queue": 3, "socketserver": 3, "_thread": 3, "asyncio": 3,
+}
+
+// pyBuiltinNames are the builtins not simulated, which do nothing rather
+// than being undefined
+var pyBuiltinNames = map[string]bool{
+	"abs": true, "all": true, "any": true, "bin": true, "bool": true, "bytearray": true, "callable": true,
+	"classmethod": true, "compile": true, "complex": true, "delattr": true, "dict": true, "dir": true,
+	"divmod": true, "enumerate": true, "filter": true, "float": true, "format": true, "frozenset": true,
+	"getattr": true, "globals": true, "hasattr": true, "hash": true, "help": true, "id": true,
+	"isinstance": true, "issubclass": true, "iter": true, "list": true, "locals": true, "map": true,
+	"max": true, "memoryview": true, "min": true, "next": true, "object": true, "oct": true, "pow": true,
+	"property": true, "reversed": true, "round": true, "set": true, "setattr": true, "slice": true,
+	"sorted": true, "staticmethod": true, "sum": true, "super": true, "tuple": true, "type": true,
+	"vars": true, "zip": true, "Exception": true, "KeyboardInterrupt": true, "SystemExit": true,
+}
+
+// pyErrnos are the messages of the errors raised
+var pyErrnos = map[int]string{
+	2: "No such file or directory", 13: "Permission denied", 20: "Not a directory", 21: "Is a directory",
+	32: "Broken pipe", 98: "Address already in use", 107: "Transport endpoint is not connected",
+	110: "Connection timed out", 111: "Connection refused",
+}
+
+// pyValue is a value of the interpreter: nil for None, bool, int, float64,
+// string, pyBytes, pyList, pyTuple, and the modules, objects and functions
+// simulated
+type pyValue interface{}
+
+// pyUnknown is the result of what is not simulated, passed along silently
+// so the code goes on as if it worked
+type pyUnknown struct{}
+
+type (
+	pyBytes string
+	pyList  []pyValue
+	pyTuple []pyValue
+)
+
+// pyModule is a module imported
+type pyModule struct{ name string }
+
+// pyObject is an instance of the classes simulated, like socket and file
+type pyObject struct {
+	class  string
+	fields map[string]pyValue
+}
+
+// pyFunc is a function of the interpreter. repr replaces how it is shown,
+// for exit telling how to leave the REPL
+type pyFunc struct {
+	name, repr string
+	call       func(args []pyValue, kw map[string]pyValue) (pyValue, error)
+}
+
+// pyError is the exception raised, shown as the last line of traceback
+type pyError struct{ kind, msg string }
+
+func (e *pyError) Error() string {
+	if e.msg == "" {
+		return e.kind
+	}
+	return e.kind + ": " + e.msg
+}
+
+// pyExit is raised by sys.exit with the exit status
+type pyExit struct{ code int }
+
+func (pyExit) Error() string {
+	return "SystemExit"
+}
+
+// pyTrace is the exception raised at the line of the source
+type pyTrace struct {
+	err  error
+	line int
+	src  string
+}
+
+func (t *pyTrace) Error() string {
+	return t.err.Error()
+}
+
+// pyInterrupted is raised when the command is interrupted
+var pyInterrupted = &pyError{kind: "KeyboardInterrupt"}
+
+// pyInterp runs the code of the session
+type pyInterp struct {
+	sys     honeyos.Sys
+	name    string
+	v3      bool
+	build   pyBuild
+	file    string
+	argv    []string
+	vars    map[string]pyValue
+	funcs   map[string]func(args []pyValue, kw map[string]pyValue) (pyValue, error)
+	stdin   *bufio.Reader
+	echo    bool
+	lines   []string
+	remote  string
+	closers []func()
+}
+
+func (p python) Exec(args []string, sys honeyos.Sys) int {
+	family := pkgFamily()
+	pkg := "python"
+	if p.v3 {
+		pkg = "python3"
+	} else if family == "apk" {
+		pkg = "python2"
+	}
+	if !loadPkgDB(sys, family).installed(pkg) {
+		return honeyos.CommandNotFound(sys, append([]string{p.name}, args...))
+	}
+	builds, ok := pyBuilds[honeyos.Distro()]
+	if !ok {
+		builds = pyBuilds["ubuntu"]
+	}
+	in := &pyInterp{sys: sys, name: p.name, v3: p.v3, build: builds[0], file: "<stdin>", vars: map[string]pyValue{}}
+	if p.v3 {
+		in.build = builds[1]
+	}
+	in.funcs = in.functions()
+	defer func() {
+		for _, c := range in.closers {
+			c()
+		}
+	}()
+	usage := func(msg string) int {
+		fmt.Fprintf(sys.Err(), "%vusage: %v [option] ... [-c cmd | -m mod | file | -] [arg] ...\nTry `python -h' for more information.\n",
+			msg, p.name)
+		return 2
+	}
+	var code, module, script string
+	hasCode, inspect := false, false
+	i := 0
+options:
+	for ; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--help":
+			fmt.Fprint(sys.Out(), p.GetHelp())
+			return 0
+		case arg == "--version":
+			arg = "-V"
+		case arg == "-" || !strings.HasPrefix(arg, "-"):
+			script = arg
+			i++
+			break options
+		case strings.HasPrefix(arg, "--"):
+			return usage(fmt.Sprintf("Unknown option: %v\n", arg))
+		}
+		for j := 1; j < len(arg); j++ {
+			switch c := arg[j]; c {
+			case 'c', 'm':
+				val := arg[j+1:]
+				if val == "" {
+					if i+1 >= len(args) {
+						return usage(fmt.Sprintf("Argument expected for the -%c option\n", c))
+					}
+					i++
+					val = args[i]
+				}
+				if c == 'c' {
+					code, hasCode = val, true
+				} else {
+					module = val
+				}
+				i++
+				break options
+			case 'h', '?':
+				fmt.Fprint(sys.Out(), p.GetHelp())
+				return 0
+			case 'V':
+				// Python 2 prints the version to stderr
+				out := sys.Out()
+				if !p.v3 {
+					out = sys.Err()
+				}
+				fmt.Fprintf(out, "Python %v\n", in.build.version)
+				return 0
+			case 'i':
+				inspect = true
+			case 'b', 'B', 'd', 'E', 'I', 'O', 'q', 's', 'S', 'u', 'v', 'x', '3', 't', 'R':
+			default:
+				return usage(fmt.Sprintf("Unknown option: -%c\n", c))
+			}
+		}
+	}
+	in.argv = append([]string{script}, args[i:]...)
+
+	status := 0
+	switch {
+	case hasCode:
+		in.argv[0], in.file = "-c", "<string>"
+		sys.Log().WithField("code", code).Infof("User ran code with %v", p.name)
+		status = in.top(code)
+	case module != "":
+		in.argv[0] = module
+		return in.module(module)
+	case script != "" && script != "-":
+		data, err := afero.ReadFile(sys.FSys(), absPath(sys, script))
+		if err != nil {
+			errno := 2
+			if os.IsPermission(err) {
+				errno = 13
+			} else if fi, statErr := sys.FSys().Stat(absPath(sys, script)); statErr == nil && fi.IsDir() {
+				errno = 21
+			}
+			fmt.Fprintf(sys.Err(), "%v: can't open file '%v': [Errno %v] %v\n", p.name, script, errno, pyErrnos[errno])
+			return 2
+		}
+		in.file = script
+		sys.Log().WithField("script", script).WithField("code", string(data)).Infof("User ran script %v with %v", script, p.name)
+		status = in.top(string(data))
+	case script == "-" || !honeyos.IsTerminal(sys.In()):
+		data, _ := ioutil.ReadAll(sys.In())
+		if len(data) > 0 {
+			honeyos.SaveArtifact(sys, data, p.name+" stdin")
+		}
+		sys.Log().WithField("code", string(data)).Infof("User ran code from stdin with %v", p.name)
+		in.argv[0] = "-"
+		status = in.top(string(data))
+	default:
+		return in.repl(true)
+	}
+	if inspect && status != 130 {
+		return in.repl(false)
+	}
+	return status
+}
+
+// top runs the code as the main program, printing the traceback of the
+// exception raised. It returns the exit status
+func (in *pyInterp) top(src string) int {
+	in.lines = strings.Split(src, "\n")
+	err := in.run(src)
+	return in.report(err)
+}
+
+// report prints the exception raised and returns the exit status for it
+func (in *pyInterp) report(err error) int {
+	if err == nil {
+		return 0
+	}
+	if select1(in.sys.Context().Done()) {
+		return 130
+	}
+	t, ok := err.(*pyTrace)
+	if !ok {
+		t = &pyTrace{err: err, line: 1}
+	}
+	switch e := t.err.(type) {
+	case pyExit:
+		return e.code
+	case pySyntaxError:
+		offset := e.offset
+		if offset > len(t.src) || offset < 0 {
+			offset = len(t.src)
+		}
+		fmt.Fprintf(in.sys.Err(), "  File \"%v\", line %v\n    %v\n    %v^\nSyntaxError: %v\n",
+			in.file, t.line, t.src, strings.Repeat(" ", offset), e.msg)
+		return 1
+	}
+	fmt.Fprintf(in.sys.Err(), "Traceback (most recent call last):\n  File \"%v\", line %v, in <module>\n", in.file, t.line)
+	if !strings.HasPrefix(in.file, "<") && t.line <= len(in.lines) {
+		fmt.Fprintf(in.sys.Err(), "    %v\n", strings.TrimSpace(in.lines[t.line-1]))
+	}
+	fmt.Fprintln(in.sys.Err(), t.err.Error())
+	if t.err == pyInterrupted {
+		return 130
+	}
+	return 1
+}
+
+// select1 tells if the channel is closed
+func select1(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// run runs the statements of the source. Compound statements are passed
+// over with their body, as the interpreter only runs simple ones
+func (in *pyInterp) run(src string) error {
+	stmts, err := pyStatements(src)
+	if err != nil {
+		return &pyTrace{err: err, line: strings.Count(src, "\n") + 1, src: strings.TrimSpace(src)}
+	}
+	for i := 0; i < len(stmts); i++ {
+		st := stmts[i]
+		if st.indent > 0 {
+			return &pyTrace{err: pySyntaxError{"unexpected indent", 0}, line: st.line, src: st.src}
+		}
+		if st.compound {
+			for i+1 < len(stmts) && stmts[i+1].indent > 0 {
+				i++
+			}
+			continue
+		}
+		if err := in.stmt(st.src); err != nil {
+			if _, ok := err.(*pyTrace); ok {
+				return err
+			}
+			return &pyTrace{err: err, line: st.line, src: st.src}
+		}
+		if select1(in.sys.Context().Done()) {
+			return pyInterrupted
+		}
+	}
+	return nil
+}
+
+// stmt runs the simple statement
+func (in *pyInterp) stmt(src string) error {
+	toks, err := pyLex(src)
+	if err != nil || len(toks) == 0 {
+		return err
+	}
+	first := toks[0]
+	if first.kind == pyTokName {
+		switch first.s {
+		case "import":
+			return in.importStmt(toks[1:])
+		case "from":
+			return in.fromStmt(toks[1:])
+		case "pass", "global", "nonlocal", "del", "assert", "raise", "break", "continue", "return", "yield":
+			return nil
+		case "print", "exec":
+			if !in.v3 {
+				return in.py2Stmt(first.s, toks[1:])
+			}
+			if len(toks) > 1 && toks[1].kind != pyTokPunct {
+				return pySyntaxError{fmt.Sprintf("Missing parentheses in call to '%v'", first.s), len(src) - 1}
+			}
+		}
+	}
+	// Assignments are split at the equal signs outside brackets
+	var parts [][]pyToken
+	depth, last := 0, 0
+	aug := ""
+	for i, t := range toks {
+		if t.kind != pyTokPunct {
+			continue
+		}
+		switch t.s {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+		case "=", "+=", "-=", "*=", "/=", "%=":
+			if depth == 0 {
+				parts = append(parts, toks[last:i])
+				last = i + 1
+				if t.s != "=" {
+					aug = t.s[:1]
+				}
+			}
+		}
+	}
+	parts = append(parts, toks[last:])
+	x, err := pyParseExpr(parts[len(parts)-1])
+	var v pyValue = pyUnknown{}
+	if err == nil {
+		if v, err = in.eval(x); err != nil {
+			return err
+		}
+	} else if _, ok := err.(pyUnsupported); !ok {
+		return err
+	}
+	if len(parts) == 1 {
+		if in.echo && err == nil {
+			if _, unknown := v.(pyUnknown); !unknown && v != nil {
+				fmt.Fprintln(in.sys.Out(), in.repr(v))
+			}
+		}
+		return nil
+	}
+	for _, target := range parts[:len(parts)-1] {
+		value := v
+		if aug != "" && len(target) == 1 {
+			old, ok := in.vars[target[0].s]
+			if !ok {
+				return &pyError{"NameError", fmt.Sprintf("name '%v' is not defined", target[0].s)}
+			}
+			if value, err = in.binop(aug, old, v); err != nil {
+				return err
+			}
+		}
+		in.assign(target, value)
+	}
+	return nil
+}
+
+// assign binds the names of the target to the value, unpacking tuples.
+// Targets like attributes are not kept
+func (in *pyInterp) assign(target []pyToken, v pyValue) {
+	var names []string
+	for _, t := range target {
+		switch {
+		case t.kind == pyTokName:
+			names = append(names, t.s)
+		case t.kind == pyTokPunct && (t.s == "," || t.s == "(" || t.s == ")" || t.s == "[" || t.s == "]"):
+		default:
+			return
+		}
+	}
+	if len(names) == 1 && !(len(target) > 1 && target[len(target)-1].s == ",") {
+		in.vars[names[0]] = v
+		return
+	}
+	var seq []pyValue
+	switch s := v.(type) {
+	case pyTuple:
+		seq = s
+	case pyList:
+		seq = s
+	}
+	for i, name := range names {
+		if len(seq) == len(names) {
+			in.vars[name] = seq[i]
+		} else {
+			in.vars[name] = pyUnknown{}
+		}
+	}
+}
+
+// py2Stmt runs the print and exec statements of Python 2
+func (in *pyInterp) py2Stmt(keyword string, toks []pyToken) error {
+	out := in.sys.Out()
+	if len(toks) > 0 && toks[0].kind == pyTokPunct && toks[0].s == ">>" {
+		i := 1
+		for i < len(toks) && !(toks[i].kind == pyTokPunct && toks[i].s == ",") {
+			i++
+		}
+		if i-1 >= 1 && toks[i-1].s == "stderr" {
+			out = in.sys.Err()
+		}
+		if i < len(toks) {
+			i++
+		}
+		toks = toks[i:]
+	}
+	// exec code in namespace runs the code all the same
+	for i, t := range toks {
+		if keyword == "exec" && t.kind == pyTokName && t.s == "in" {
+			toks = toks[:i]
+			break
+		}
+	}
+	if len(toks) == 0 {
+		if keyword == "print" {
+			fmt.Fprintln(out)
+		}
+		return nil
+	}
+	newline := !(toks[len(toks)-1].kind == pyTokPunct && toks[len(toks)-1].s == ",")
+	x, err := pyParseExpr(toks)
+	if err != nil {
+		if _, ok := err.(pyUnsupported); ok {
+			return nil
+		}
+		return err
+	}
+	v, err := in.eval(x)
+	if err != nil {
+		return err
+	}
+	if keyword == "exec" {
+		return in.exec(v)
+	}
+	var words []string
+	if seq, ok := x.(pySeq); ok && !seq.list {
+		for _, elem := range v.(pyTuple) {
+			words = append(words, in.str(elem))
+		}
+	} else {
+		words = []string{in.str(v)}
+	}
+	s := strings.Join(words, " ")
+	if newline {
+		s += "\n"
+	} else {
+		s += " "
+	}
+	fmt.Fprint(out, s)
+	return nil
+}
+
+// exec runs the code given as string, like exec and eval of decoded
+// payloads. The code is logged as it is only seen decoded here
+func (in *pyInterp) exec(code pyValue) error {
+	src, ok := pyText(code)
+	if !ok {
+		if _, unknown := code.(pyUnknown); unknown {
+			return nil
+		}
+		return &pyError{"TypeError", "exec() arg 1 must be a string, bytes or code object"}
+	}
+	in.sys.Log().WithField("code", src).Infof("User ran decoded code with %v", in.name)
+	err := in.run(src)
+	if t, ok := err.(*pyTrace); ok {
+		// The line is of the code run by exec, which traceback does not show
+		if _, syntax := t.err.(pySyntaxError); !syntax {
+			return t.err
+		}
+		t.line = 1
+	}
+	return err
+}
+
+// importStmt runs import a, b.c as d
+func (in *pyInterp) importStmt(toks []pyToken) error {
+	for len(toks) > 0 {
+		name, rest := pyDotted(toks)
+		if name == "" {
+			return pySyntaxError{"invalid syntax", 0}
+		}
+		bind := strings.Split(name, ".")[0]
+		var err error
+		var v pyValue
+		if len(rest) >= 2 && rest[0].s == "as" {
+			bind, rest = rest[1].s, rest[2:]
+			v, err = in.importModule(name)
+		} else {
+			if _, err = in.importModule(name); err == nil {
+				v = &pyModule{bind}
+			}
+		}
+		if err != nil {
+			return err
+		}
+		in.vars[bind] = v
+		if len(rest) > 0 && rest[0].s == "," {
+			rest = rest[1:]
+		}
+		toks = rest
+	}
+	return nil
+}
+
+// fromStmt runs from a import b, c as d
+func (in *pyInterp) fromStmt(toks []pyToken) error {
+	name, rest := pyDotted(toks)
+	if name == "" || len(rest) == 0 || rest[0].s != "import" {
+		return pySyntaxError{"invalid syntax", 0}
+	}
+	m, err := in.importModule(name)
+	if err != nil {
+		return err
+	}
+	for _, t := range rest[1:] {
+		if t.s == "*" {
+			for key := range in.funcs {
+				if strings.HasPrefix(key, name+".") && !strings.Contains(key[len(name)+1:], ".") {
+					in.vars[key[len(name)+1:]], _ = in.attr(m, key[len(name)+1:])
+				}
+			}
+			return nil
+		}
+	}
+	rest = rest[1:]
+	for len(rest) > 0 {
+		if rest[0].kind != pyTokName {
+			rest = rest[1:]
+			continue
+		}
+		attr, bind := rest[0].s, rest[0].s
+		rest = rest[1:]
+		if len(rest) >= 2 && rest[0].s == "as" {
+			bind, rest = rest[1].s, rest[2:]
+		}
+		if _, ok := pyModules[name+"."+attr]; ok {
+			in.vars[bind] = &pyModule{name + "." + attr}
+			continue
+		}
+		v, err := in.attr(m, attr)
+		if err != nil {
+			return &pyError{"ImportError", "cannot import name " + in.repr(attr)}
+		}
+		in.vars[bind] = v
+	}
+	return nil
+}
+
+// pyDotted reads the dotted name at the start of the tokens
+func pyDotted(toks []pyToken) (string, []pyToken) {
+	var parts []string
+	i := 0
+	for i < len(toks) && toks[i].kind == pyTokName {
+		parts = append(parts, toks[i].s)
+		i++
+		if i < len(toks) && toks[i].s == "." {
+			i++
+			continue
+		}
+		break
+	}
+	return strings.Join(parts, "."), toks[i:]
+}
+
+// importModule returns the module of the name, or raises the error of module
+// not found
+func (in *pyInterp) importModule(name string) (pyValue, error) {
+	version, ok := pyModules[name]
+	if ok && (version == 0 || version == 3 && in.v3 || version == 2 && !in.v3) {
+		return &pyModule{name}, nil
+	}
+	switch {
+	case !in.v3:
+		return nil, &pyError{"ImportError", "No module named " + strings.Split(name, ".")[len(strings.Split(name, "."))-1]}
+	case strings.HasPrefix(in.build.version, "3.5"):
+		return nil, &pyError{"ImportError", fmt.Sprintf("No module named '%v'", name)}
+	}
+	return nil, &pyError{"ModuleNotFoundError", fmt.Sprintf("No module named '%v'", name)}
+}
+
+// eval evaluates the expression
+func (in *pyInterp) eval(x pyExpr) (pyValue, error) {
+	switch x := x.(type) {
+	case pyLit:
+		return x.v, nil
+	case pyName:
+		if v, ok := in.vars[x.name]; ok {
+			return v, nil
+		}
+		if v, ok := in.builtin(x.name); ok {
+			return v, nil
+		}
+		return nil, &pyError{"NameError", fmt.Sprintf("name '%v' is not defined", x.name)}
+	case pyAttr:
+		v, err := in.eval(x.x)
+		if err != nil {
+			return nil, err
+		}
+		return in.attr(v, x.name)
+	case pyCall:
+		fn, err := in.eval(x.fn)
+		if err != nil {
+			return nil, err
+		}
+		var args []pyValue
+		for _, a := range x.args {
+			v, err := in.eval(a)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, v)
+		}
+		kw := map[string]pyValue{}
+		for k, a := range x.kw {
+			v, err := in.eval(a)
+			if err != nil {
+				return nil, err
+			}
+			kw[k] = v
+		}
+		switch f := fn.(type) {
+		case *pyFunc:
+			return f.call(args, kw)
+		case pyUnknown:
+			return pyUnknown{}, nil
+		}
+		return nil, &pyError{"TypeError", fmt.Sprintf("'%v' object is not callable", in.typeName(fn))}
+	case pyIndex:
+		v, err := in.eval(x.x)
+		if err != nil {
+			return nil, err
+		}
+		index, err := in.eval(x.index)
+		if err != nil {
+			return nil, err
+		}
+		return in.index(v, index)
+	case pySlice:
+		v, err := in.eval(x.x)
+		if err != nil {
+			return nil, err
+		}
+		var bounds [2]pyValue
+		for i, b := range []pyExpr{x.lo, x.hi} {
+			if b != nil {
+				if bounds[i], err = in.eval(b); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return in.slice(v, bounds[0], bounds[1])
+	case pyBin:
+		a, err := in.eval(x.x)
+		if err != nil {
+			return nil, err
+		}
+		b, err := in.eval(x.y)
+		if err != nil {
+			return nil, err
+		}
+		return in.binop(x.op, a, b)
+	case pyNeg:
+		v, err := in.eval(x.x)
+		if err != nil {
+			return nil, err
+		}
+		switch n := v.(type) {
+		case int:
+			return -n, nil
+		case float64:
+			return -n, nil
+		case pyUnknown:
+			return v, nil
+		}
+		return nil, &pyError{"TypeError", fmt.Sprintf("bad operand type for unary -: '%v'", in.typeName(v))}
+	case pySeq:
+		var elems []pyValue
+		for _, e := range x.elems {
+			v, err := in.eval(e)
+			if err != nil {
+				return nil, err
+			}
+			elems = append(elems, v)
+		}
+		if x.list {
+			return pyList(elems), nil
+		}
+		return pyTuple(elems), nil
+	}
+	return pyUnknown{}, nil
+}
+
+// builtin returns the builtin function of the name
+func (in *pyInterp) builtin(name string) (pyValue, bool) {
+	if f, ok := in.funcs[name]; ok {
+		fn := &pyFunc{name: name, call: f}
+		if name == "exit" || name == "quit" {
+			fn.repr = "Use " + name + "() or Ctrl-D (i.e. EOF) to exit"
+		}
+		return fn, true
+	}
+	if pyBuiltinNames[name] {
+		return pyUnknown{}, true
+	}
+	return nil, false
+}
+
+// attr returns the attribute of the value
+func (in *pyInterp) attr(v pyValue, name string) (pyValue, error) {
+	switch v := v.(type) {
+	case pyUnknown:
+		return v, nil
+	case *pyModule:
+		key := v.name + "." + name
+		if _, ok := pyModules[key]; ok {
+			return &pyModule{key}, nil
+		}
+		if c, ok := in.constant(key); ok {
+			return c, nil
+		}
+		if f, ok := in.funcs[key]; ok {
+			return &pyFunc{name: name, call: f}, nil
+		}
+		return pyUnknown{}, nil
+	case *pyObject:
+		if f, ok := v.fields[name]; ok {
+			return f, nil
+		}
+		return in.method(v, name)
+	case string:
+		return in.strMethod(v, name, false)
+	case pyBytes:
+		return in.strMethod(string(v), name, in.v3)
+	}
+	return nil, &pyError{"AttributeError", fmt.Sprintf("'%v' object has no attribute '%v'", in.typeName(v), name)}
+}
+
+// constant returns the values of the modules other than functions
+func (in *pyInterp) constant(key string) (pyValue, bool) {
+	switch key {
+	case "socket.AF_INET":
+		return 2, true
+	case "socket.AF_INET6":
+		return 10, true
+	case "socket.AF_UNIX":
+		return 1, true
+	case "socket.SOCK_STREAM":
+		return 1, true
+	case "socket.SOCK_DGRAM":
+		return 2, true
+	case "socket.SOL_SOCKET":
+		return 1, true
+	case "socket.SO_REUSEADDR":
+		return 2, true
+	case "subprocess.PIPE":
+		return -1, true
+	case "subprocess.STDOUT":
+		return -2, true
+	case "subprocess.DEVNULL":
+		return -3, true
+	case "os.name":
+		return "posix", true
+	case "os.sep":
+		return "/", true
+	case "os.linesep":
+		return "\n", true
+	case "os.environ":
+		return &pyObject{class: "environ", fields: map[string]pyValue{}}, true
+	case "sys.version":
+		return in.build.version + " " + in.build.build, true
+	case "sys.platform":
+		if in.v3 {
+			return "linux", true
+		}
+		return "linux2", true
+	case "sys.executable":
+		return "/usr/bin/" + in.name, true
+	case "sys.argv":
+		var argv pyList
+		for _, a := range in.argv {
+			argv = append(argv, a)
+		}
+		return argv, true
+	case "sys.stdin", "sys.stdout", "sys.stderr":
+		return &pyObject{class: "file", fields: map[string]pyValue{"name": "<" + key[4:] + ">", "mode": "w"}}, true
+	}
+	return nil, false
+}
+
+// pyText returns the string of str and bytes
+func pyText(v pyValue) (string, bool) {
+	switch s := v.(type) {
+	case string:
+		return s, true
+	case pyBytes:
+		return string(s), true
+	}
+	return "", false
+}
+
+// pyInt returns the integer of int and bool
+func pyInt(v pyValue) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case bool:
+		if n {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// pyArg returns the argument at the position or of the keyword, nil if not
+// given
+func pyArg(args []pyValue, kw map[string]pyValue, n int, name string) pyValue {
+	if n < len(args) {
+		return args[n]
+	}
+	return kw[name]
+}
+
+// bytesOf returns the result of functions returning bytes, which are str
+// in Python 2
+func (in *pyInterp) bytesOf(s string) pyValue {
+	if in.v3 {
+		return pyBytes(s)
+	}
+	return s
+}
+
+// osError returns the error with the errno, raised as the subclass of
+// OSError in Python 3 and the kind given in Python 2
+func (in *pyInterp) osError(py2 string, errno int, filename string) *pyError {
+	msg := fmt.Sprintf("[Errno %v] %v", errno, pyErrnos[errno])
+	if filename != "" {
+		msg += ": " + in.repr(filename)
+	}
+	if !in.v3 {
+		return &pyError{py2, msg}
+	}
+	kind, ok := map[int]string{2: "FileNotFoundError", 13: "PermissionError", 21: "IsADirectoryError",
+		32: "BrokenPipeError", 110: "TimeoutError", 111: "ConnectionRefusedError"}[errno]
+	if !ok {
+		kind = "OSError"
+	}
+	return &pyError{kind, msg}
+}
+
+// typeName is the name of the type of the value
+func (in *pyInterp) typeName(v pyValue) string {
+	switch v := v.(type) {
+	case nil:
+		return "NoneType"
+	case bool:
+		return "bool"
+	case int:
+		return "int"
+	case float64:
+		return "float"
+	case string:
+		return "str"
+	case pyBytes:
+		if in.v3 {
+			return "bytes"
+		}
+		return "str"
+	case pyList:
+		return "list"
+	case pyTuple:
+		return "tuple"
+	case *pyModule:
+		return "module"
+	case *pyFunc:
+		return "builtin_function_or_method"
+	case *pyObject:
+		return v.class
+	}
+	return "object"
+}
+
+// repr formats the value as the REPL shows it
+func (in *pyInterp) repr(v pyValue) string {
+	switch v := v.(type) {
+	case nil:
+		return "None"
+	case bool:
+		if v {
+			return "True"
+		}
+		return "False"
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1e16 {
+			return strconv.FormatFloat(v, 'f', 1, 64)
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		return pyQuote(v, !in.v3)
+	case pyBytes:
+		if in.v3 {
+			return "b" + pyQuote(string(v), true)
+		}
+		return pyQuote(string(v), true)
+	case pyList, pyTuple:
+		var elems []string
+		seq, _ := v.(pyList)
+		if t, ok := v.(pyTuple); ok {
+			seq = pyList(t)
+		}
+		for _, e := range seq {
+			elems = append(elems, in.repr(e))
+		}
+		if _, ok := v.(pyList); ok {
+			return "[" + strings.Join(elems, ", ") + "]"
+		}
+		if len(elems) == 1 {
+			return "(" + elems[0] + ",)"
+		}
+		return "(" + strings.Join(elems, ", ") + ")"
+	case *pyModule:
+		switch v.name {
+		case "sys", "time", "posix", "marshal", "_thread", "thread", "itertools", "errno", "pwd", "grp":
+			return fmt.Sprintf("<module '%v' (built-in)>", v.name)
+		}
+		file := path.Join("/usr/lib/python2.7", strings.Replace(v.name, ".", "/", -1)+".pyc")
+		if in.v3 {
+			file = path.Join("/usr/lib/python"+in.build.version[:3], strings.Replace(v.name, ".", "/", -1)+".py")
+		}
+		return fmt.Sprintf("<module '%v' from '%v'>", v.name, file)
+	case *pyFunc:
+		if v.repr != "" {
+			return v.repr
+		}
+		return fmt.Sprintf("<built-in function %v>", v.name)
+	case *pyObject:
+		if r, ok := v.fields["repr"].(string); ok {
+			return r
+		}
+		if v.class == "file" {
+			if in.v3 {
+				return fmt.Sprintf("<_io.TextIOWrapper name=%v mode=%v encoding='UTF-8'>", in.repr(v.fields["name"]), in.repr(v.fields["mode"]))
+			}
+			return fmt.Sprintf("<open file %v, mode %v at 0x7f3a1c2e4150>", in.repr(v.fields["name"]), in.repr(v.fields["mode"]))
+		}
+		return fmt.Sprintf("<%v object at 0x7f3a1c2d9e48>", v.class)
+	}
+	return ""
+}
+
+// str formats the value as print does
+func (in *pyInterp) str(v pyValue) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case pyBytes:
+		if !in.v3 {
+			return string(s)
+		}
+	case pyUnknown:
+		return ""
+	}
+	return in.repr(v)
+}
+
+// pyQuote quotes the string like repr does. Bytes escape all but printable
+// ASCII, strings only the control characters
+func pyQuote(s string, bytes bool) string {
+	q := "'"
+	if strings.Contains(s, "'") && !strings.Contains(s, "\"") {
+		q = "\""
+	}
+	var b strings.Builder
+	b.WriteString(q)
+	write := func(r rune) {
+		switch {
+		case r == '\\':
+			b.WriteString("\\\\")
+		case r == '\n':
+			b.WriteString("\\n")
+		case r == '\r':
+			b.WriteString("\\r")
+		case r == '\t':
+			b.WriteString("\\t")
+		case string(r) == q:
+			b.WriteString("\\" + q)
+		case r < 0x20 || r == 0x7f || r >= 0x80 && (bytes || r < 0xa0):
+			fmt.Fprintf(&b, "\\x%02x", r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if bytes {
+		for i := 0; i < len(s); i++ {
+			write(rune(s[i]))
+		}
+	} else {
+		for _, r := range s {
+			write(r)
+		}
+	}
+	b.WriteString(q)
+	return b.String()
+}
+
+// binop applies the arithmetic operator
+func (in *pyInterp) binop(op string, a, b pyValue) (pyValue, error) {
+	if _, ok := a.(pyUnknown); ok {
+		return a, nil
+	}
+	if _, ok := b.(pyUnknown); ok {
+		return b, nil
+	}
+	x, xInt := pyInt(a)
+	y, yInt := pyInt(b)
+	if xInt && yInt {
+		switch op {
+		case "+":
+			return x + y, nil
+		case "-":
+			return x - y, nil
+		case "*":
+			return x * y, nil
+		case "**":
+			return int(math.Pow(float64(x), float64(y))), nil
+		case "/", "//", "%":
+			if y == 0 && op == "/" && in.v3 {
+				return nil, &pyError{"ZeroDivisionError", "division by zero"}
+			}
+			if y == 0 {
+				return nil, &pyError{"ZeroDivisionError", "integer division or modulo by zero"}
+			}
+			switch {
+			case op == "%":
+				return ((x % y) + y) % y, nil
+			case op == "/" && in.v3:
+				return float64(x) / float64(y), nil
+			}
+			return int(math.Floor(float64(x) / float64(y))), nil
+		}
+	}
+	fa, aNum := a.(float64)
+	fb, bNum := b.(float64)
+	if xInt {
+		fa, aNum = float64(x), true
+	}
+	if yInt {
+		fb, bNum = float64(y), true
+	}
+	if aNum && bNum {
+		switch op {
+		case "+":
+			return fa + fb, nil
+		case "-":
+			return fa - fb, nil
+		case "*":
+			return fa * fb, nil
+		case "**":
+			return math.Pow(fa, fb), nil
+		case "/", "//":
+			if fb == 0 {
+				return nil, &pyError{"ZeroDivisionError", "float division by zero"}
+			}
+			if op == "//" {
+				return math.Floor(fa / fb), nil
+			}
+			return fa / fb, nil
+		}
+	}
+	switch op {
+	case "+":
+		switch a := a.(type) {
+		case string:
+			if s, ok := b.(string); ok {
+				return a + s, nil
+			}
+		case pyBytes:
+			if s, ok := b.(pyBytes); ok {
+				return a + s, nil
+			}
+		case pyList:
+			if l, ok := b.(pyList); ok {
+				return append(append(pyList{}, a...), l...), nil
+			}
+		}
+	case "*":
+		if s, ok := a.(string); ok && yInt && y >= 0 && y*len(s) < 1<<20 {
+			return strings.Repeat(s, y), nil
+		}
+	case "%":
+		if s, ok := a.(string); ok {
+			return in.format(s, b)
+		}
+	}
+	return nil, &pyError{"TypeError", fmt.Sprintf("unsupported operand type(s) for %v: '%v' and '%v'", op, in.typeName(a), in.typeName(b))}
+}
+
+// format formats the string with % like printf
+func (in *pyInterp) format(s string, v pyValue) (pyValue, error) {
+	args, ok := v.(pyTuple)
+	if !ok {
+		args = pyTuple{v}
+	}
+	var b strings.Builder
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if s[i] == '%' {
+			b.WriteByte('%')
+			continue
+		}
+		if n >= len(args) {
+			return nil, &pyError{"TypeError", "not enough arguments for format string"}
+		}
+		arg := args[n]
+		n++
+		switch s[i] {
+		case 'd', 'i':
+			num, ok := pyInt(arg)
+			if !ok {
+				return nil, &pyError{"TypeError", fmt.Sprintf("%%d format: a number is required, not %v", in.typeName(arg))}
+			}
+			b.WriteString(strconv.Itoa(num))
+		case 'r':
+			b.WriteString(in.repr(arg))
+		case 'x':
+			num, _ := pyInt(arg)
+			b.WriteString(strconv.FormatInt(int64(num), 16))
+		default:
+			b.WriteString(in.str(arg))
+		}
+	}
+	if n < len(args) {
+		return nil, &pyError{"TypeError", "not all arguments converted during string formatting"}
+	}
+	return b.String(), nil
+}
+
+// index returns the item of the sequence
+func (in *pyInterp) index(v, index pyValue) (pyValue, error) {
+	switch seq := v.(type) {
+	case pyUnknown:
+		return v, nil
+	case *pyObject:
+		if seq.class == "environ" {
+			key, _ := pyText(index)
+			if val, ok := in.getenv(key); ok {
+				return val, nil
+			}
+			return nil, &pyError{"KeyError", in.repr(index)}
+		}
+	case string, pyBytes, pyList, pyTuple:
+		i, ok := pyInt(index)
+		if !ok {
+			if _, unknown := index.(pyUnknown); unknown {
+				return index, nil
+			}
+			return nil, &pyError{"TypeError", fmt.Sprintf("%v indices must be integers, not %v", in.typeName(v), in.typeName(index))}
+		}
+		var items []pyValue
+		switch seq := seq.(type) {
+		case string:
+			for _, r := range seq {
+				items = append(items, string(r))
+			}
+		case pyBytes:
+			for j := 0; j < len(seq); j++ {
+				if in.v3 {
+					items = append(items, int(seq[j]))
+				} else {
+					items = append(items, string(seq[j]))
+				}
+			}
+		case pyList:
+			items = seq
+		case pyTuple:
+			items = seq
+		}
+		if i < 0 {
+			i += len(items)
+		}
+		if i < 0 || i >= len(items) {
+			name := in.typeName(v)
+			if name == "str" {
+				name = "string"
+			}
+			return nil, &pyError{"IndexError", name + " index out of range"}
+		}
+		return items[i], nil
+	}
+	return nil, &pyError{"TypeError", fmt.Sprintf("'%v' object is not subscriptable", in.typeName(v))}
+}
+
+// slice returns the part of the sequence between the bounds
+func (in *pyInterp) slice(v, lo, hi pyValue) (pyValue, error) {
+	length := 0
+	switch seq := v.(type) {
+	case string:
+		length = len(seq)
+	case pyBytes:
+		length = len(seq)
+	case pyList:
+		length = len(seq)
+	case pyTuple:
+		length = len(seq)
+	case pyUnknown:
+		return v, nil
+	default:
+		return nil, &pyError{"TypeError", fmt.Sprintf("'%v' object is not subscriptable", in.typeName(v))}
+	}
+	bound := func(b pyValue, def int) int {
+		n, ok := pyInt(b)
+		if !ok {
+			return def
+		}
+		if n < 0 {
+			n += length
+		}
+		if n < 0 {
+			return 0
+		}
+		if n > length {
+			return length
+		}
+		return n
+	}
+	i, j := bound(lo, 0), bound(hi, length)
+	if i > j {
+		j = i
+	}
+	switch seq := v.(type) {
+	case string:
+		return seq[i:j], nil
+	case pyBytes:
+		return seq[i:j], nil
+	case pyList:
+		return seq[i:j], nil
+	}
+	return v.(pyTuple)[i:j], nil
+}
+
+// getenv returns the environment variable of the process
+func (in *pyInterp) getenv(key string) (string, bool) {
+	for _, env := range in.sys.Environ() {
+		if strings.HasPrefix(env, key+"=") {
+			return env[len(key)+1:], true
+		}
+	}
+	return "", false
+}
+
+// strMethod returns the method of str, or of bytes if bytes is set
+func (in *pyInterp) strMethod(s, name string, bytes bool) (pyValue, error) {
+	typ := "str"
+	if bytes {
+		typ = "bytes"
+	}
+	wrap := func(r string) pyValue {
+		if bytes {
+			return pyBytes(r)
+		}
+		return r
+	}
+	text := func(args []pyValue, n int, def string) string {
+		if n < len(args) {
+			if t, ok := pyText(args[n]); ok {
+				return t
+			}
+		}
+		return def
+	}
+	var f func(args []pyValue, kw map[string]pyValue) (pyValue, error)
+	switch name {
+	case "encode":
+		if bytes {
+			break
+		}
+		f = func([]pyValue, map[string]pyValue) (pyValue, error) { return in.bytesOf(s), nil }
+	case "decode":
+		if !bytes && in.v3 {
+			break
+		}
+		f = func([]pyValue, map[string]pyValue) (pyValue, error) { return s, nil }
+	case "strip", "lstrip", "rstrip":
+		f = func(args []pyValue, _ map[string]pyValue) (pyValue, error) {
+			cut := text(args, 0, " \t\n\r\x0b\x0c")
+			switch name {
+			case "lstrip":
+				return wrap(strings.TrimLeft(s, cut)), nil
+			case "rstrip":
+				return wrap(strings.TrimRight(s, cut)), nil
+			}
+			return wrap(strings.Trim(s, cut)), nil
+		}
+	case "split", "splitlines":
+		f = func(args []pyValue, _ map[string]pyValue) (pyValue, error) {
+			var parts []string
+			switch sep := text(args, 0, ""); {
+			case name == "splitlines":
+				parts = strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+			case sep == "":
+				parts = strings.Fields(s)
+			default:
+				parts = strings.Split(s, sep)
+			}
+			var l pyList
+			for _, p := range parts {
+				l = append(l, wrap(p))
+			}
+			return l, nil
+		}
+	case "join":
+		f = func(args []pyValue, _ map[string]pyValue) (pyValue, error) {
+			var items []pyValue
+			switch seq := pyArg(args, nil, 0, "").(type) {
+			case pyList:
+				items = seq
+			case pyTuple:
+				items = seq
+			case pyUnknown:
+				return seq, nil
+			}
+			var parts []string
+			for _, item := range items {
+				t, _ := pyText(item)
+				parts = append(parts, t)
+			}
+			return wrap(strings.Join(parts, s)), nil
+		}
+	case "replace":
+		f = func(args []pyValue, _ map[string]pyValue) (pyValue, error) {
+			return wrap(strings.Replace(s, text(args, 0, ""), text(args, 1, ""), -1)), nil
+		}
+	case "upper", "lower":
+		f = func([]pyValue, map[string]pyValue) (pyValue, error) {
+			if name == "upper" {
+				return wrap(strings.ToUpper(s)), nil
+			}
+			return wrap(strings.ToLower(s)), nil
+		}
+	case "startswith", "endswith":
+		f = func(args []pyValue, _ map[string]pyValue) (pyValue, error) {
+			if name == "startswith" {
+				return strings.HasPrefix(s, text(args, 0, "")), nil
+			}
+			return strings.HasSuffix(s, text(args, 0, "")), nil
+		}
+	case "find":
+		f = func(args []pyValue, _ map[string]pyValue) (pyValue, error) {
+			return strings.Index(s, text(args, 0, "")), nil
+		}
+	case "format":
+		f = func(args []pyValue, _ map[string]pyValue) (pyValue, error) {
+			out := s
+			for i, a := range args {
+				out = strings.Replace(out, fmt.Sprintf("{%v}", i), in.str(a), -1)
+				out = strings.Replace(out, "{}", in.str(a), 1)
+			}
+			return out, nil
+		}
+	}
+	if f == nil {
+		return nil, &pyError{"AttributeError", fmt.Sprintf("'%v' object has no attribute '%v'", typ, name)}
+	}
+	return &pyFunc{name: name, call: f}, nil
+}
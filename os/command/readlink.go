@@ -0,0 +1,227 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	pathlib "path"
+	"strings"
+	"syscall"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+)
+
+// readlink is readlink and realpath, which resolve links the same way
+type readlink struct {
+	name string
+}
+
+func init() {
+	honeyos.RegisterCommand("readlink", readlink{"readlink"})
+	honeyos.RegisterCommand("realpath", readlink{"realpath"})
+}
+
+func (r readlink) GetHelp() string {
+	if r.name == "realpath" {
+		return `Usage: realpath [OPTION]... FILE...
+Print the resolved absolute file name;
+all but the last component must exist
+
+  -e, --canonicalize-existing  all components of the path must exist
+  -m, --canonicalize-missing   no path components need exist or be a directory
+  -L, --logical                resolve '..' components before symlinks
+  -P, --physical               resolve symlinks as encountered (default)
+  -q, --quiet                  suppress most error messages
+      --relative-to=DIR        print the resolved path relative to DIR
+      --relative-base=DIR      print absolute paths unless paths below DIR
+  -s, --strip, --no-symlinks   don't expand symlinks
+  -z, --zero                   end each output line with NUL, not newline
+
+      --help     display this help and exit
+      --version  output version information and exit
+
+GNU coreutils online help: <https://www.gnu.org/software/coreutils/>
+Full documentation at: <https://www.gnu.org/software/coreutils/realpath>
+or available locally via: info '(coreutils) realpath invocation'
+`
+	}
+	return `Usage: readlink [OPTION]... FILE...
+Print value of a symbolic link or canonical file name
+
+  -f, --canonicalize            canonicalize by following every symlink in
+                                every component of the given name recursively;
+                                all but the last component must exist
+  -e, --canonicalize-existing   canonicalize by following every symlink in
+                                every component of the given name recursively,
+                                all components must exist
+  -m, --canonicalize-missing    canonicalize by following every symlink in
+                                every component of the given name recursively,
+                                without requirements on components existence
+  -n, --no-newline              do not output the trailing delimiter
+  -q, --quiet
+  -s, --silent                  suppress most error messages (on by default)
+  -v, --verbose                 report error messages
+  -z, --zero                    end each output line with NUL, not newline
+      --help     display this help and exit
+      --version  output version information and exit
+
+GNU coreutils online help: <https://www.gnu.org/software/coreutils/>
+Full documentation at: <https://www.gnu.org/software/coreutils/readlink>
+or available locally via: info '(coreutils) readlink invocation'
+`
+}
+
+func (r readlink) Where() string {
+	return "/bin/" + r.name
+}
+
+func (r readlink) Exec(args []string, sys honeyos.Sys) int {
+	// mode is f, e or m as the options of readlink, and empty for reading
+	// the link only
+	mode := ""
+	if r.name == "realpath" {
+		mode = "f"
+	}
+	var noNewline, verbose, zero, strip bool
+	var relativeTo string
+	operands, status := fileOperands(r.name, args, sys, func(f string) (bool, int) {
+		switch f {
+		case "-f", "--canonicalize":
+			mode = "f"
+		case "-e", "--canonicalize-existing":
+			mode = "e"
+		case "-m", "--canonicalize-missing":
+			mode = "m"
+		case "-q", "--quiet", "--silent":
+			verbose = false
+		case "-z", "--zero":
+			zero = true
+		case "-L", "--logical", "-P", "--physical":
+		case "--help":
+			fmt.Fprint(sys.Out(), r.GetHelp())
+			return false, 0
+		case "--version":
+			fmt.Fprintf(sys.Out(), "%v (GNU coreutils) 8.32\n", r.name)
+			return false, 0
+		default:
+			switch {
+			case r.name == "readlink" && (f == "-n" || f == "--no-newline"):
+				noNewline = true
+			case r.name == "readlink" && (f == "-v" || f == "--verbose"):
+				verbose = true
+			case r.name == "readlink" && f == "-s":
+				verbose = false
+			case r.name == "realpath" && (f == "-s" || f == "--strip" || f == "--no-symlinks"):
+				strip = true
+			case r.name == "realpath" && (f == "--relative-to" || f == "--relative-base"):
+				return true, -1
+			default:
+				return false, 1
+			}
+		}
+		return false, -1
+	}, &relativeTo)
+	if status >= 0 {
+		return status
+	}
+	if r.name == "realpath" {
+		verbose = true
+	}
+	if len(operands) == 0 {
+		fmt.Fprintf(sys.Err(), "%v: missing operand\nTry '%v --help' for more information.\n", r.name, r.name)
+		return 1
+	}
+	if noNewline && len(operands) > 1 {
+		fmt.Fprintf(sys.Err(), "%v: ignoring --no-newline with multiple arguments\n", r.name)
+		noNewline = false
+	}
+	end := "\n"
+	switch {
+	case zero:
+		end = "\x00"
+	case noNewline:
+		end = ""
+	}
+	res := 0
+	for _, name := range operands {
+		var out string
+		var err error
+		switch {
+		case mode == "":
+			out, err = honeyos.Readlink(sys.FSys(), absPath(sys, name))
+		case strip:
+			out = absPath(sys, name)
+		default:
+			out, err = canonicalPath(sys, name, mode)
+		}
+		if err != nil {
+			if verbose {
+				fmt.Fprintf(sys.Err(), "%v: %v: %v\n", r.name, name, fileError(err))
+			}
+			res = 1
+			continue
+		}
+		if relativeTo != "" {
+			if dir, err := canonicalPath(sys, relativeTo, "m"); err == nil {
+				out = relativePath(dir, out)
+			}
+		}
+		fmt.Fprint(sys.Out(), out+end)
+	}
+	return res
+}
+
+// canonicalPath follows every link in the name to the absolute path. mode
+// is f if the last component may not exist, e if all have to and m if none
+func canonicalPath(sys honeyos.Sys, name, mode string) (string, error) {
+	fs := sys.FSys()
+	parts := strings.Split(absPath(sys, name), "/")
+	cur, hops := "/", 0
+	for len(parts) > 0 {
+		part := parts[0]
+		parts = parts[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			cur = pathlib.Dir(cur)
+			continue
+		}
+		next := pathlib.Join(cur, part)
+		rest := false
+		for _, p := range parts {
+			if p != "" && p != "." {
+				rest = true
+			}
+		}
+		fi, err := honeyos.Lstat(fs, next)
+		if err != nil {
+			if mode == "m" || mode == "f" && !rest {
+				cur = next
+				continue
+			}
+			return "", err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			if target, err := honeyos.Readlink(fs, next); err == nil {
+				if hops++; hops > 40 {
+					return "", syscall.ELOOP
+				}
+				if strings.HasPrefix(target, "/") {
+					cur = "/"
+				}
+				parts = append(strings.Split(target, "/"), parts...)
+				continue
+			}
+			// Links of the image don't keep their targets
+			fi, err = fs.Stat(next)
+			if err != nil {
+				return "", err
+			}
+		}
+		if !fi.IsDir() && rest && mode != "m" {
+			return "", syscall.ENOTDIR
+		}
+		cur = next
+	}
+	return cur, nil
+}
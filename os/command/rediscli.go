@@ -0,0 +1,686 @@
+package command
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"net"
+	pathlib "path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+// redisCLI is the client of Redis. Servers in persona.databases with *
+// password take commands without AUTH, like the exposed servers attackers
+// look for. What is set is kept for the session, and SAVE writes the keys
+// into the file of dir and dbfilename if the server is the machine itself,
+// as the attacks writing crontab and authorized_keys through Redis do
+type redisCLI struct{}
+
+// redisVersions are the versions of Redis of the distributions
+var redisVersions = map[string]string{"ubuntu": "3.0.6", "debian": "3.2.6", "centos": "3.2.12", "alpine": "6.2.14"}
+
+// redisArity is the count of arguments of the commands including the name,
+// negative for at least that many
+var redisArity = map[string]int{
+	"get": 2, "set": -3, "del": -2, "exists": -2, "keys": 2, "type": 2, "select": 2, "echo": 2, "config": -2,
+	"slaveof": 3, "replicaof": 3, "auth": -2, "module": -2, "append": 3, "incr": 2, "expire": 3, "ttl": 2,
+}
+
+// redisReply is the reply of the server in the types of the protocol: +
+// status, - error, : integer, $ bulk string and * array of bulk strings
+type redisReply struct {
+	kind byte
+	str  string
+	null bool
+	list []string
+}
+
+// redisConn is the connection of the client to the server
+type redisConn struct {
+	sys                 honeyos.Sys
+	version, host, addr string
+	ip                  net.IP
+	port, db            int
+	authed, raw, local  bool
+	connected, nopass   bool
+}
+
+func init() {
+	honeyos.RegisterCommand("redis-cli", redisCLI{})
+}
+
+func (r redisCLI) GetHelp() string {
+	return "redis-cli " + redisVersion() + `
+
+Usage: redis-cli [OPTIONS] [cmd [arg [arg ...]]]
+  -h <hostname>      Server hostname (default: 127.0.0.1).
+  -p <port>          Server port (default: 6379).
+  -a <password>      Password to use when connecting to the server.
+  -n <db>            Database number.
+  --raw              Use raw formatting for replies (default when STDOUT is
+                     not a tty).
+  --no-raw           Force formatted output even when STDOUT is not a tty.
+  --help             Output this help and exit.
+  --version          Output version and exit.
+
+Examples:
+  cat /etc/passwd | redis-cli -x set mypasswd
+  redis-cli get mypasswd
+  redis-cli -r 100 lpush mylist x
+  redis-cli -r 100 -i 1 info | grep used_memory_human:
+
+When no command is given, redis-cli starts in interactive mode.
+Type "help" in interactive mode for information on available commands.
+
+`
+}
+
+func (redisCLI) Where() string {
+	return "/usr/bin/redis-cli"
+}
+
+func redisVersion() string {
+	if v, ok := redisVersions[honeyos.Distro()]; ok {
+		return v
+	}
+	return redisVersions["ubuntu"]
+}
+
+func (r redisCLI) Exec(args []string, sys honeyos.Sys) int {
+	c := &redisConn{sys: sys, version: redisVersion(), host: "127.0.0.1", port: 6379,
+		raw: !honeyos.IsTerminal(sys.Out())}
+	password, user := "", "default"
+	var cmd []string
+	quiet := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-h", "-p", "-a", "-n", "--user", "--pass", "-u", "-r", "-i":
+			if i+1 == len(args) {
+				fmt.Fprintf(sys.Err(), "Unrecognized option or bad number of args for: '%v'\n", arg)
+				return 1
+			}
+			i++
+			switch v := args[i]; arg {
+			case "-h":
+				c.host = v
+			case "-p":
+				c.port, _ = strconv.Atoi(v)
+			case "-a", "--pass":
+				password = v
+			case "-n":
+				c.db, _ = strconv.Atoi(v)
+			case "--user":
+				user = v
+			}
+		case "--raw":
+			c.raw = true
+		case "--no-raw":
+			c.raw = false
+		case "--no-auth-warning":
+			quiet = true
+		case "-v", "--version":
+			fmt.Fprintf(sys.Out(), "redis-cli %v\n", c.version)
+			return 0
+		case "--help":
+			fmt.Fprint(sys.Out(), r.GetHelp())
+			return 0
+		case "-c", "-x", "--no-raw-output":
+		default:
+			if strings.HasPrefix(arg, "-") && cmd == nil {
+				fmt.Fprintf(sys.Err(), "Unrecognized option or bad number of args for: '%v'\n", arg)
+				return 1
+			}
+			cmd = args[i:]
+			i = len(args)
+		}
+	}
+	c.addr = fmt.Sprintf("%v:%v", c.host, c.port)
+	if c.ip = netResolve(sys, c.host); c.ip != nil {
+		sys.Log().WithField("host", c.host).WithField("port", c.port).
+			Infof("User connecting to Redis server %v", c.addr)
+		c.connected = c.connect()
+	} else {
+		fmt.Fprintf(sys.Err(), "Could not connect to Redis at %v: Name or service not known\n", c.addr)
+	}
+	if c.connected && password != "" {
+		if c.version >= "6" && !quiet {
+			fmt.Fprintln(sys.Err(), "Warning: Using a password with '-a' or '-u' option on the command line interface may not be safe.")
+		}
+		if reply := c.auth(user, password); reply.kind == '-' && c.version >= "6" {
+			fmt.Fprintf(sys.Err(), "AUTH failed: %v\n", reply.str)
+		}
+	}
+	if c.connected && c.db != 0 {
+		if reply := c.exec([]string{"select", strconv.Itoa(c.db)}); reply.kind == '-' {
+			c.db = 0
+		}
+	}
+	switch {
+	case cmd != nil && !c.connected:
+		return 1
+	case cmd != nil:
+		c.print(c.exec(cmd))
+		return 0
+	case !honeyos.IsTerminal(sys.In()):
+		// Commands are read from the pipe, one per line
+		data, _ := ioutil.ReadAll(sys.In())
+		for _, line := range strings.Split(string(data), "\n") {
+			if args, err := redisSplit(line); err == nil && len(args) > 0 && c.connected {
+				c.print(c.exec(args))
+			}
+		}
+		return 0
+	}
+	return c.repl()
+}
+
+// connect tells if the server takes the connection, printing why not
+func (c *redisConn) connect() bool {
+	open, reachable := dbReach(c.sys, "redis", c.ip, c.port)
+	switch {
+	case !reachable:
+		if !pkgSleep(c.sys, 127*time.Second) {
+			return false
+		}
+		fmt.Fprintf(c.sys.Err(), "Could not connect to Redis at %v: Connection timed out\n", c.addr)
+		return false
+	case !open:
+		fmt.Fprintf(c.sys.Err(), "Could not connect to Redis at %v: Connection refused\n", c.addr)
+		return false
+	}
+	for _, s := range dbServers(c.sys, "redis", c.ip) {
+		c.nopass = c.nopass || s.password == "*"
+	}
+	c.authed = c.nopass
+	c.local = c.ip.IsLoopback() || c.ip.Equal(net.ParseIP(honeyos.IPAddress()))
+	return true
+}
+
+func (c *redisConn) prompt() string {
+	switch {
+	case !c.connected:
+		return "not connected> "
+	case c.db != 0:
+		return fmt.Sprintf("%v[%v]> ", c.addr, c.db)
+	}
+	return c.addr + "> "
+}
+
+func (c *redisConn) repl() int {
+	sys := c.sys
+	in := bufio.NewReader(sys.In())
+	for {
+		fmt.Fprint(sys.Out(), c.prompt())
+		line, err := in.ReadString('\n')
+		if err != nil && line == "" {
+			if !select1(sys.Context().Done()) {
+				fmt.Fprintln(sys.Out())
+			}
+			return 0
+		}
+		line = strings.TrimSpace(line)
+		sys.Log().Infof("User typed %v into redis-cli", line)
+		args, err := redisSplit(line)
+		switch {
+		case err != nil:
+			fmt.Fprintln(sys.Out(), "Invalid argument(s)")
+			continue
+		case len(args) == 0:
+			continue
+		case strings.EqualFold(args[0], "quit") || strings.EqualFold(args[0], "exit"):
+			return 0
+		case strings.EqualFold(args[0], "help"):
+			fmt.Fprintf(sys.Out(), "redis-cli %v\nType: \"help @<group>\" to get a list of commands in <group>\n"+
+				"      \"help <command>\" for help on <command>\n      \"help <tab>\" to get a list of possible help topics\n"+
+				"      \"quit\" to exit\n", c.version)
+			continue
+		case !c.connected:
+			if c.connected = c.ip != nil && c.connect(); !c.connected {
+				continue
+			}
+		}
+		c.print(c.exec(args))
+	}
+}
+
+// print prints the reply the way redis-cli does, quoted and numbered on
+// terminal, as it is otherwise
+func (c *redisConn) print(r redisReply) {
+	out := c.sys.Out()
+	if c.raw {
+		switch {
+		case r.kind == ':':
+			fmt.Fprintln(out, r.str)
+		case r.kind == '*':
+			for _, s := range r.list {
+				fmt.Fprintln(out, s)
+			}
+		default:
+			fmt.Fprintln(out, r.str)
+		}
+		return
+	}
+	switch r.kind {
+	case '-':
+		fmt.Fprintln(out, "(error) "+r.str)
+	case ':':
+		fmt.Fprintln(out, "(integer) "+r.str)
+	case '$':
+		if r.null {
+			fmt.Fprintln(out, "(nil)")
+		} else {
+			fmt.Fprintln(out, strconv.Quote(r.str))
+		}
+	case '*':
+		if len(r.list) == 0 && c.version >= "6" {
+			fmt.Fprintln(out, "(empty array)")
+		} else if len(r.list) == 0 {
+			fmt.Fprintln(out, "(empty list or set)")
+		}
+		width := len(strconv.Itoa(len(r.list)))
+		for i, s := range r.list {
+			fmt.Fprintf(out, "%*d) %v\n", width, i+1, strconv.Quote(s))
+		}
+	default:
+		fmt.Fprintln(out, r.str)
+	}
+}
+
+// redisSplit splits the line into arguments the way redis-cli does, with
+// the escapes of C in double quotes
+func redisSplit(line string) ([]string, error) {
+	var args []string
+	for i := 0; i < len(line); {
+		if line[i] == ' ' || line[i] == '\t' {
+			i++
+			continue
+		}
+		var arg []byte
+		var quote byte
+		for ; i < len(line); i++ {
+			ch := line[i]
+			switch {
+			case quote == 0 && (ch == '"' || ch == '\''):
+				quote = ch
+				continue
+			case quote == 0 && (ch == ' ' || ch == '\t'):
+			case ch == quote:
+				quote = 0
+				continue
+			case quote == '"' && ch == '\\' && i+3 < len(line) && line[i+1] == 'x':
+				if n, err := strconv.ParseUint(line[i+2:i+4], 16, 8); err == nil {
+					arg = append(arg, byte(n))
+					i += 3
+					continue
+				}
+				arg = append(arg, ch)
+				continue
+			case quote != 0 && ch == '\\' && i+1 < len(line):
+				i++
+				ch = line[i]
+				if quote == '"' {
+					if e, ok := map[byte]byte{'n': '\n', 'r': '\r', 't': '\t', 'b': '\b', 'a': '\a'}[ch]; ok {
+						ch = e
+					}
+				} else if ch != '\'' {
+					arg = append(arg, '\\')
+				}
+				arg = append(arg, ch)
+				continue
+			default:
+				arg = append(arg, ch)
+				continue
+			}
+			break
+		}
+		if quote != 0 {
+			return nil, fmt.Errorf("unbalanced quotes")
+		}
+		args = append(args, string(arg))
+	}
+	return args, nil
+}
+
+func redisError(msg string) redisReply {
+	return redisReply{kind: '-', str: msg}
+}
+
+func redisInt(n int) redisReply {
+	return redisReply{kind: ':', str: strconv.Itoa(n)}
+}
+
+var redisOK = redisReply{kind: '+', str: "OK"}
+
+// auth checks the password the way of the version, as Redis 6 has users
+func (c *redisConn) auth(user, password string) redisReply {
+	accepted := dbLogin(c.sys, "redis", c.host, c.ip, user, password)
+	switch {
+	case c.nopass:
+		return redisError("ERR Client sent AUTH, but no password is set")
+	case accepted:
+		c.authed = true
+		return redisOK
+	case c.version >= "6":
+		return redisError("WRONGPASS invalid username-password pair or user is disabled.")
+	}
+	return redisError("ERR invalid password")
+}
+
+// exec runs the command on the server of the session
+func (c *redisConn) exec(args []string) redisReply {
+	sys := c.sys
+	line := strings.Join(args, " ")
+	sys.Log().WithFields(log.Fields{"host": c.host, "command": line}).Infof("User sent Redis command %v", line)
+	name := strings.ToLower(args[0])
+	if n, ok := redisArity[name]; ok && (n > 0 && len(args) != n || n < 0 && len(args) < -n) {
+		return redisError(fmt.Sprintf("ERR wrong number of arguments for '%v' command", name))
+	}
+	if name == "auth" {
+		if len(args) > 2 {
+			return c.auth(args[1], args[2])
+		}
+		return c.auth("default", args[1])
+	}
+	if !c.authed {
+		return redisError("NOAUTH Authentication required.")
+	}
+	var reply redisReply
+	honeyos.UpdateRedis(sys, c.ip.String()+":"+strconv.Itoa(c.port), c.seed, func(s *honeyos.RedisServer) {
+		reply = c.command(s, name, args[1:])
+	})
+	return reply
+}
+
+// command runs the command on the server, with the keys of the database
+// selected
+func (c *redisConn) command(s *honeyos.RedisServer, name string, args []string) redisReply {
+	sys := c.sys
+	keys := s.DBs[c.db]
+	if keys == nil {
+		keys = map[string]string{}
+		s.DBs[c.db] = keys
+	}
+	switch name {
+	case "ping":
+		if len(args) > 0 {
+			return redisReply{kind: '$', str: args[0]}
+		}
+		return redisReply{kind: '+', str: "PONG"}
+	case "echo":
+		return redisReply{kind: '$', str: args[0]}
+	case "get":
+		v, ok := keys[args[0]]
+		return redisReply{kind: '$', str: v, null: !ok}
+	case "set":
+		keys[args[0]] = args[1]
+		return redisOK
+	case "append":
+		keys[args[0]] += args[1]
+		return redisInt(len(keys[args[0]]))
+	case "incr":
+		n, err := strconv.Atoi(keys[args[0]])
+		if _, ok := keys[args[0]]; ok && err != nil {
+			return redisError("ERR value is not an integer or out of range")
+		}
+		keys[args[0]] = strconv.Itoa(n + 1)
+		return redisInt(n + 1)
+	case "del", "exists":
+		n := 0
+		for _, k := range args {
+			if _, ok := keys[k]; ok {
+				n++
+				if name == "del" {
+					delete(keys, k)
+				}
+			}
+		}
+		return redisInt(n)
+	case "expire":
+		if _, ok := keys[args[0]]; ok {
+			return redisInt(1)
+		}
+		return redisInt(0)
+	case "ttl":
+		if _, ok := keys[args[0]]; ok {
+			return redisInt(-1)
+		}
+		return redisInt(-2)
+	case "type":
+		if _, ok := keys[args[0]]; ok {
+			return redisReply{kind: '+', str: "string"}
+		}
+		return redisReply{kind: '+', str: "none"}
+	case "keys":
+		var list []string
+		for k := range keys {
+			if ok, _ := pathlib.Match(args[0], k); ok {
+				list = append(list, k)
+			}
+		}
+		sort.Strings(list)
+		return redisReply{kind: '*', list: list}
+	case "dbsize":
+		return redisInt(len(keys))
+	case "select":
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 0 || n > 15 {
+			if c.version >= "3.2" {
+				return redisError("ERR DB index is out of range")
+			}
+			return redisError("ERR invalid DB index")
+		}
+		c.db = n
+		return redisOK
+	case "flushall":
+		s.DBs = map[int]map[string]string{}
+		return redisOK
+	case "flushdb":
+		delete(s.DBs, c.db)
+		return redisOK
+	case "config":
+		return c.config(s, args)
+	case "save":
+		return c.save(s)
+	case "bgsave":
+		if reply := c.save(s); reply.kind == '-' {
+			return reply
+		}
+		return redisReply{kind: '+', str: "Background saving started"}
+	case "lastsave":
+		return redisInt(int(honeyos.BootTime().Unix()))
+	case "slaveof", "replicaof":
+		sys.Log().WithField("master", args[0]+":"+args[1]).
+			Warnf("User made Redis server replica of %v:%v", args[0], args[1])
+		return redisOK
+	case "module":
+		if strings.EqualFold(args[0], "load") && len(args) > 1 {
+			sys.Log().WithField("module", args[1]).Warnf("User loaded Redis module %v", args[1])
+			return redisError("ERR Error loading the extension. Please check the server logs.")
+		}
+		return redisError("ERR MODULE subcommand must be one of LOAD, UNLOAD or LIST")
+	case "info":
+		return redisReply{kind: '$', str: c.info(s)}
+	case "client", "command", "monitor", "time", "flushcache":
+		return redisReply{kind: '*'}
+	}
+	if c.version >= "6" {
+		var quoted []string
+		for _, a := range args {
+			quoted = append(quoted, "'"+a+"'")
+		}
+		return redisError(fmt.Sprintf("ERR unknown command `%v`, with args beginning with: %v", name,
+			strings.Join(quoted, " ")))
+	}
+	return redisError(fmt.Sprintf("ERR unknown command '%v'", name))
+}
+
+// config gets and sets the parameters of the server. Changing where the
+// database is saved is how attackers write files through Redis
+func (c *redisConn) config(s *honeyos.RedisServer, args []string) redisReply {
+	switch strings.ToLower(args[0]) {
+	case "get":
+		if len(args) != 2 {
+			return redisError("ERR Wrong number of arguments for CONFIG get")
+		}
+		var names []string
+		for name := range s.Config {
+			if ok, _ := pathlib.Match(strings.ToLower(args[1]), name); ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		var list []string
+		for _, name := range names {
+			list = append(list, name, s.Config[name])
+		}
+		return redisReply{kind: '*', list: list}
+	case "set":
+		if len(args) != 3 {
+			return redisError("ERR Wrong number of arguments for CONFIG set")
+		}
+		name := strings.ToLower(args[1])
+		if _, ok := s.Config[name]; !ok {
+			return redisError(fmt.Sprintf("ERR Unsupported CONFIG parameter: %v", args[1]))
+		}
+		if name == "dir" && c.local {
+			if fi, err := c.sys.FSys().Stat(args[2]); err != nil || !fi.IsDir() {
+				return redisError("ERR Changing directory: No such file or directory")
+			}
+		}
+		if name == "dir" || name == "dbfilename" {
+			c.sys.Log().WithField(name, args[2]).Warnf("User changed Redis %v to %v", name, args[2])
+		}
+		s.Config[name] = args[2]
+		return redisOK
+	case "resetstat", "rewrite":
+		return redisOK
+	}
+	return redisError("ERR CONFIG subcommand must be one of GET, SET, RESETSTAT, REWRITE")
+}
+
+// save writes the keys in the file of the database, if the server is this
+// machine. The file is kept as artifact either way
+func (c *redisConn) save(s *honeyos.RedisServer) redisReply {
+	p := pathlib.Join(s.Config["dir"], s.Config["dbfilename"])
+	data := redisDump(c.version, s)
+	c.sys.Log().WithField("path", p).Warnf("User saved Redis database to %v", p)
+	honeyos.SaveArtifact(c.sys, data, "redis save "+c.addr+" "+p)
+	if !c.local {
+		return redisOK
+	}
+	// The directory of the default is missing where no redis package is
+	// installed, and other directories are checked by CONFIG SET
+	c.sys.FSys().MkdirAll(pathlib.Dir(p), 0755)
+	if err := afero.WriteFile(c.sys.FSys(), p, data, 0644); err != nil {
+		c.sys.Log().WithError(err).Info("Cannot write Redis database")
+		return redisError("ERR")
+	}
+	return redisOK
+}
+
+// redisDump is the database in RDB format, with the strings of the keys
+// as they are. Lines set as values come out whole, which cron and sshd
+// take from the file
+func redisDump(version string, s *honeyos.RedisServer) []byte {
+	rdb := map[string]string{"3": "6", "6": "9"}[version[:1]]
+	if strings.HasPrefix(version, "3.2") {
+		rdb = "7"
+	}
+	b := []byte("REDIS000" + rdb)
+	str := func(v string) {
+		switch n := len(v); {
+		case n < 1<<6:
+			b = append(b, byte(n))
+		case n < 1<<14:
+			b = append(b, byte(0x40|n>>8), byte(n))
+		default:
+			var size [4]byte
+			binary.BigEndian.PutUint32(size[:], uint32(n))
+			b = append(append(b, 0x80), size[:]...)
+		}
+		b = append(b, v...)
+	}
+	var dbs []int
+	for db := range s.DBs {
+		dbs = append(dbs, db)
+	}
+	sort.Ints(dbs)
+	for _, db := range dbs {
+		if len(s.DBs[db]) == 0 {
+			continue
+		}
+		b = append(b, 0xfe, byte(db))
+		var keys []string
+		for k := range s.DBs[db] {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			b = append(b, 0)
+			str(k)
+			str(s.DBs[db][k])
+		}
+	}
+	b = append(b, 0xff)
+	return append(b, make([]byte, 8)...)
+}
+
+// info is the reply of INFO, with the sections clients look at
+func (c *redisConn) info(s *honeyos.RedisServer) string {
+	up := honeyos.Uptime()
+	var keyspace strings.Builder
+	var dbs []int
+	for db, keys := range s.DBs {
+		if len(keys) > 0 {
+			dbs = append(dbs, db)
+		}
+	}
+	sort.Ints(dbs)
+	for _, db := range dbs {
+		fmt.Fprintf(&keyspace, "db%v:keys=%v,expires=0,avg_ttl=0\r\n", db, len(s.DBs[db]))
+	}
+	return fmt.Sprintf("# Server\r\nredis_version:%v\r\nredis_git_sha1:00000000\r\nredis_git_dirty:0\r\n"+
+		"redis_mode:standalone\r\nos:Linux %v %v\r\narch_bits:64\r\nmultiplexing_api:epoll\r\n"+
+		"process_id:%v\r\ntcp_port:%v\r\nuptime_in_seconds:%v\r\nuptime_in_days:%v\r\n"+
+		"executable:/usr/bin/redis-server\r\nconfig_file:/etc/redis/redis.conf\r\n\r\n"+
+		"# Clients\r\nconnected_clients:1\r\nblocked_clients:0\r\n\r\n"+
+		"# Memory\r\nused_memory:838568\r\nused_memory_human:818.91K\r\n\r\n"+
+		"# Persistence\r\nloading:0\r\nrdb_changes_since_last_save:0\r\nrdb_last_save_time:%v\r\n\r\n"+
+		"# Replication\r\nrole:master\r\nconnected_slaves:0\r\n\r\n# Keyspace\r\n%v",
+		c.version, honeyos.KernelRelease(), honeyos.Arch(), 800+fnvString(c.addr)%600, c.port,
+		int(up.Seconds()), int(up.Hours()/24), honeyos.BootTime().Unix(), keyspace.String())
+}
+
+// seed makes up the server the first time it is reached, with the keys of
+// sessions and cache of the web application
+func (c *redisConn) seed() *honeyos.RedisServer {
+	sum := fnvString(c.sys.Hostname() + c.addr)
+	keys := map[string]string{
+		"stats:visits":            strconv.Itoa(10000 + int(sum%90000)),
+		"config:maintenance_mode": "0",
+		"cache:homepage":          "<!DOCTYPE html><html><head><title>Example Store</title></head><body></body></html>",
+	}
+	for i := 0; i < 3; i++ {
+		id := fmt.Sprintf("%016x", fnvString(fmt.Sprint(sum, i))*0x9e3779b97f4a7c15)
+		keys["session:"+id] = fmt.Sprintf(`{"user_id":%v,"ip":"10.0.3.%v","expires":%v}`, i+2, 17+int(sum%50)+i,
+			honeyos.BootTime().Add(time.Duration(i+1)*time.Hour).Unix())
+	}
+	config := map[string]string{
+		"dir": "/var/lib/redis", "dbfilename": "dump.rdb", "requirepass": "", "port": strconv.Itoa(c.port),
+		"bind": "0.0.0.0", "maxmemory": "0", "appendonly": "no", "save": "900 1 300 10 60 10000",
+		"daemonize": "yes", "logfile": "/var/log/redis/redis-server.log",
+	}
+	if c.version >= "3.2" {
+		config["protected-mode"] = "no"
+	}
+	return &honeyos.RedisServer{DBs: map[int]map[string]string{0: keys}, Config: config}
+}
@@ -0,0 +1,244 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	pathlib "path"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/afero"
+)
+
+type rm struct{}
+
+// rmOptions are the options of rm
+type rmOptions struct {
+	force, interactive, once, recursive, dir, verbose bool
+	noPreserveRoot                                    bool
+}
+
+func init() {
+	honeyos.RegisterCommand("rm", rm{})
+}
+
+func (rm) GetHelp() string {
+	return `Usage: rm [OPTION]... [FILE]...
+Remove (unlink) the FILE(s).
+
+  -f, --force           ignore nonexistent files and arguments, never prompt
+  -i                    prompt before every removal
+  -I                    prompt once before removing more than three files, or
+                          when removing recursively; less intrusive than -i,
+                          while still giving protection against most mistakes
+      --interactive[=WHEN]  prompt according to WHEN: never, once (-I), or
+                          always (-i); without WHEN, prompt always
+      --one-file-system  when removing a hierarchy recursively, skip any
+                          directory that is on a file system different from
+                          that of the corresponding command line argument
+      --no-preserve-root  do not treat '/' specially
+      --preserve-root[=all]  do not remove '/' (default);
+                              with 'all', reject any command line argument
+                              on a separate device from its parent
+  -r, -R, --recursive   remove directories and their contents recursively
+  -d, --dir             remove empty directories
+  -v, --verbose         explain what is being done
+      --help     display this help and exit
+      --version  output version information and exit
+
+By default, rm does not remove directories.  Use the --recursive (-r or -R)
+option to remove each listed directory, too, along with all of its contents.
+
+To remove a file whose name starts with a '-', for example '-foo',
+use one of these commands:
+  rm -- -foo
+
+  rm ./-foo
+
+Note that if you use rm to remove a file, it might be possible to recover
+some of its contents, given sufficient expertise and/or time.  For greater
+assurance that the contents are truly unrecoverable, consider using shred.
+
+GNU coreutils online help: <https://www.gnu.org/software/coreutils/>
+Full documentation at: <https://www.gnu.org/software/coreutils/rm>
+or available locally via: info '(coreutils) rm invocation'
+`
+}
+
+func (rm) Where() string {
+	return "/bin/rm"
+}
+
+func (r rm) Exec(args []string, sys honeyos.Sys) int {
+	var opt rmOptions
+	var when, badWhen string
+	operands, status := fileOperands("rm", args, sys, func(f string) (bool, int) {
+		switch f {
+		case "-f", "--force":
+			opt.force, opt.interactive, opt.once = true, false, false
+		case "-i":
+			opt.interactive, opt.force, opt.once = true, false, false
+		case "-I":
+			opt.once, opt.force, opt.interactive = true, false, false
+		case "--interactive":
+			switch when {
+			case "", "always", "yes":
+				opt.interactive, opt.force, opt.once = true, false, false
+			case "once":
+				opt.once, opt.force, opt.interactive = true, false, false
+			case "never", "no", "none":
+				opt.interactive, opt.once = false, false
+			default:
+				badWhen = when
+			}
+		case "-r", "-R", "--recursive":
+			opt.recursive = true
+		case "-d", "--dir":
+			opt.dir = true
+		case "-v", "--verbose":
+			opt.verbose = true
+		case "--no-preserve-root":
+			opt.noPreserveRoot = true
+		case "--preserve-root":
+			opt.noPreserveRoot = false
+		case "--one-file-system":
+		case "--help":
+			fmt.Fprint(sys.Out(), r.GetHelp())
+			return false, 0
+		case "--version":
+			fmt.Fprintln(sys.Out(), "rm (GNU coreutils) 8.32")
+			return false, 0
+		default:
+			return false, 1
+		}
+		return false, -1
+	}, &when)
+	if status >= 0 {
+		return status
+	}
+	if badWhen != "" {
+		fmt.Fprintf(sys.Err(), "rm: invalid argument ‘%v’ for ‘--interactive’\n", badWhen)
+		fmt.Fprintln(sys.Err(), "Valid arguments are:\n  - ‘never’, ‘no’, ‘none’\n  - ‘once’\n  - ‘always’, ‘yes’")
+		fmt.Fprintln(sys.Err(), "Try 'rm --help' for more information.")
+		return 1
+	}
+	if len(operands) == 0 {
+		if opt.force {
+			return 0
+		}
+		fmt.Fprintln(sys.Err(), "rm: missing operand\nTry 'rm --help' for more information.")
+		return 1
+	}
+	if opt.once && (len(operands) > 3 || opt.recursive) {
+		question := fmt.Sprintf("rm: remove %v arguments", len(operands))
+		if len(operands) == 1 {
+			question = "rm: remove 1 argument"
+		}
+		if opt.recursive {
+			question += " recursively"
+		}
+		if !fileConfirm(sys, question+"? ") {
+			return 0
+		}
+	}
+	sys.Log().WithField("files", operands).WithField("recursive", opt.recursive).Info("User removed files")
+	res := 0
+	for _, name := range operands {
+		if base := pathlib.Base(strings.TrimRight(name, "/")); strings.Trim(name, "/") != "" && (base == "." || base == "..") {
+			fmt.Fprintf(sys.Err(), "rm: refusing to remove '.' or '..' directory: skipping '%v'\n", name)
+			res = 1
+			continue
+		}
+		p := absPath(sys, name)
+		if p == "/" && opt.recursive && !opt.noPreserveRoot {
+			fmt.Fprintf(sys.Err(), "rm: it is dangerous to operate recursively on '/'\n")
+			fmt.Fprintf(sys.Err(), "rm: use --no-preserve-root to override this failsafe\n")
+			res = 1
+			continue
+		}
+		if !r.remove(sys, &opt, p, name) {
+			res = 1
+		}
+	}
+	return res
+}
+
+// remove removes the file, or the directory with -r after what is in it. It
+// returns false if anything is left
+func (r rm) remove(sys honeyos.Sys, opt *rmOptions, p, name string) bool {
+	fs := sys.FSys()
+	fi, err := honeyos.Lstat(fs, p)
+	if err != nil {
+		if opt.force && os.IsNotExist(err) {
+			return true
+		}
+		fmt.Fprintf(sys.Err(), "rm: cannot remove '%v': %v\n", name, fileError(err))
+		return false
+	}
+	if fi.IsDir() {
+		list, err := afero.ReadDir(fs, p)
+		if err != nil && opt.recursive {
+			fmt.Fprintf(sys.Err(), "rm: cannot remove '%v': %v\n", name, fileError(err))
+			return false
+		}
+		switch {
+		case opt.recursive && len(list) > 0:
+			if opt.interactive && !fileConfirm(sys, fmt.Sprintf("rm: descend into directory '%v'? ", name)) {
+				return true
+			}
+			ok := true
+			for _, child := range list {
+				if !r.remove(sys, opt, pathlib.Join(p, child.Name()), pathlib.Join(name, child.Name())) {
+					ok = false
+				}
+			}
+			if !ok {
+				return false
+			}
+		case !opt.recursive && !opt.dir:
+			fmt.Fprintf(sys.Err(), "rm: cannot remove '%v': Is a directory\n", name)
+			return false
+		case !opt.recursive && len(list) > 0:
+			fmt.Fprintf(sys.Err(), "rm: cannot remove '%v': Directory not empty\n", name)
+			return false
+		}
+	}
+	if !r.confirm(sys, opt, p, name, fi) {
+		return true
+	}
+	if err := fs.Remove(p); err != nil {
+		fmt.Fprintf(sys.Err(), "rm: cannot remove '%v': %v\n", name, fileError(err))
+		return false
+	}
+	if opt.verbose {
+		if fi.IsDir() {
+			fmt.Fprintf(sys.Out(), "removed directory '%v'\n", name)
+		} else {
+			fmt.Fprintf(sys.Out(), "removed '%v'\n", name)
+		}
+	}
+	return true
+}
+
+// confirm asks before removing with -i, or removing a write-protected file
+// when the user is at the terminal
+func (r rm) confirm(sys honeyos.Sys, opt *rmOptions, p, name string, fi os.FileInfo) bool {
+	protected := !opt.force && fi.Mode()&os.ModeSymlink == 0 && !honeyos.Access(sys, p, 2) &&
+		honeyos.IsTerminal(sys.In())
+	if !opt.interactive && !protected {
+		return true
+	}
+	kind := "regular file"
+	switch {
+	case fi.IsDir():
+		kind = "directory"
+	case fi.Mode()&os.ModeSymlink != 0:
+		kind = "symbolic link"
+	case fi.Size() == 0:
+		kind = "regular empty file"
+	}
+	if protected {
+		kind = "write-protected " + kind
+	}
+	return fileConfirm(sys, fmt.Sprintf("rm: remove %v '%v'? ", kind, name))
+}
@@ -0,0 +1,561 @@
+package command
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/mkishere/sshsyrup/util/terminal"
+)
+
+// cell is the character laid out by the roff formatter, in the font it is
+// set in: R for roman, B for bold and I for italic
+type cell struct {
+	r    rune
+	font byte
+	// nbsp is the space that does not break the word, e.g. "\ "
+	nbsp bool
+}
+
+// roffSpecial are the special characters of \(xx and \[xx] in man pages
+var roffSpecial = map[string]string{
+	"em": "—", "en": "–", "hy": "-", "mi": "-", "bu": "·", "co": "©", "rg": "®",
+	"aq": "'", "dq": "\"", "lq": "“", "rq": "”", "oq": "‘", "cq": "’", "ga": "`",
+	"ti": "~", "ha": "^", "rs": "\\", "sl": "/", "ba": "|", "or": "|", "pl": "+",
+	"mu": "×", "de": "°", ">=": "≥", "<=": "≤", "->": "→", "<-": "←", "tm": "™",
+	"L\"": "\"", "R\"": "\"", "C`": "", "C'": "",
+}
+
+// roff formats manual pages in the man macros, the part of groff -man that
+// pages of the commands use, into lines for the terminal
+type roff struct {
+	// width is the line length. Text is set in bold and underline with
+	// escape sequences if styled
+	width  int
+	styled bool
+
+	lines []string
+	font  byte
+	fill  bool
+	// margin is where text starts, which moves with .RS and tagged
+	// paragraphs, and base is the margin of level paragraphs
+	margin, base int
+	rsStack      []int
+	words        [][]cell
+	// tag is put in front of the next line for .TP and .IP, if tagged is
+	// true. pendingTag is set by .TP, whose tag is the next line
+	tag          []cell
+	tagged       bool
+	pendingTag   bool
+	tagIndent    int
+	pendingHead  string
+	paraSpace    bool
+	url          string
+	skipUntilDot bool
+	// afterHead is set when the last line is a heading, which paragraphs
+	// follow without a blank line
+	afterHead bool
+
+	title, section, date, source, manual string
+}
+
+func newRoff(width int, styled bool) *roff {
+	return &roff{width: width, styled: styled, font: 'R', fill: true, margin: 7, base: 7, paraSpace: true}
+}
+
+// render formats the whole page and returns its lines, with the header and
+// footer like man shows
+func (f *roff) render(page string) []string {
+	for _, line := range strings.Split(page, "\n") {
+		f.line(line)
+	}
+	f.flush()
+	head := strings.ToUpper(f.title)
+	if f.section != "" {
+		head += "(" + f.section + ")"
+	}
+	header := f.threePart(head, f.manual, head)
+	footer := f.threePart(f.source, f.date, head)
+	for len(f.lines) > 0 && f.lines[0] == "" {
+		f.lines = f.lines[1:]
+	}
+	for len(f.lines) > 0 && f.lines[len(f.lines)-1] == "" {
+		f.lines = f.lines[:len(f.lines)-1]
+	}
+	out := append([]string{header, ""}, f.lines...)
+	return append(out, "", footer)
+}
+
+func (f *roff) threePart(left, center, right string) string {
+	line := []rune(strings.Repeat(" ", f.width))
+	copy(line, []rune(left))
+	c := []rune(center)
+	if start := (f.width - len(c)) / 2; start > len([]rune(left)) {
+		copy(line[start:], c)
+	}
+	r := []rune(right)
+	if start := f.width - len(r); start >= 0 {
+		copy(line[start:], r)
+	}
+	return strings.TrimRight(string(line), " ")
+}
+
+func (f *roff) line(line string) {
+	if f.skipUntilDot {
+		f.skipUntilDot = strings.TrimSpace(line) != ".."
+		return
+	}
+	if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+		f.request(line[1:])
+		return
+	}
+	if !f.fill {
+		f.flushLine(f.inline(line, &f.font))
+		return
+	}
+	if strings.TrimSpace(line) == "" {
+		f.flush()
+		f.space()
+		return
+	}
+	if strings.HasPrefix(line, " ") {
+		f.flush()
+	}
+	f.text(f.inline(line, &f.font))
+}
+
+// text adds the cells to the paragraph, or takes them as the heading or the
+// tag if one of them is expected
+func (f *roff) text(cells []cell) {
+	switch {
+	case f.pendingHead != "":
+		level := f.pendingHead
+		f.pendingHead = ""
+		f.heading(level, cells)
+	case f.pendingTag:
+		f.pendingTag = false
+		f.setTag(cells)
+	default:
+		word := []cell{}
+		for _, c := range cells {
+			if c.r == ' ' && !c.nbsp {
+				if len(word) > 0 {
+					f.words = append(f.words, word)
+				}
+				word = []cell{}
+				continue
+			}
+			word = append(word, c)
+		}
+		if len(word) > 0 {
+			f.words = append(f.words, word)
+		}
+	}
+}
+
+// request handles the line of control, e.g. ".SH NAME"
+func (f *roff) request(line string) {
+	line = strings.TrimLeft(line, " \t")
+	if strings.HasPrefix(line, `\"`) || line == "" {
+		return
+	}
+	name := line
+	rest := ""
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		name, rest = line[:i], strings.TrimSpace(line[i+1:])
+	}
+	args := roffArgs(rest)
+	switch name {
+	case "TH":
+		for i, v := range []*string{&f.title, &f.section, &f.date, &f.source, &f.manual} {
+			if i < len(args) {
+				*v = f.plain(args[i])
+			}
+		}
+	case "SH", "SS":
+		f.flush()
+		if len(args) == 0 {
+			f.pendingHead = name
+			return
+		}
+		f.heading(name, f.inline(strings.Join(args, " "), &f.font))
+	case "PP", "LP", "P":
+		f.flush()
+		f.space()
+		f.margin = f.base
+	case "TP":
+		f.flush()
+		f.space()
+		f.tagIndent = 7
+		if len(args) > 0 {
+			f.tagIndent = roffIndent(args[0], 7)
+		}
+		f.pendingTag = true
+	case "IP":
+		f.flush()
+		f.space()
+		f.tagIndent = 7
+		if len(args) > 1 {
+			f.tagIndent = roffIndent(args[1], 7)
+		}
+		if len(args) > 0 && args[0] != "" {
+			f.setTag(f.inline(args[0], &f.font))
+		} else {
+			f.margin = f.base + f.tagIndent
+		}
+	case "HP":
+		f.flush()
+		f.space()
+		f.margin = f.base
+	case "RS":
+		f.flush()
+		f.rsStack = append(f.rsStack, f.base)
+		step := 7
+		if len(args) > 0 {
+			step = roffIndent(args[0], 7)
+		}
+		f.base += step
+		f.margin = f.base
+	case "RE":
+		f.flush()
+		if n := len(f.rsStack); n > 0 {
+			f.base, f.rsStack = f.rsStack[n-1], f.rsStack[:n-1]
+		}
+		f.margin = f.base
+	case "br":
+		f.flush()
+	case "sp":
+		f.flush()
+		n := 1
+		if len(args) > 0 {
+			n = roffIndent(args[0], 1)
+		}
+		for i := 0; i < n; i++ {
+			f.lines = append(f.lines, "")
+		}
+	case "nf", "EX":
+		f.flush()
+		f.fill = false
+	case "fi", "EE":
+		f.flush()
+		f.fill = true
+	case "PD":
+		f.paraSpace = len(args) == 0 || args[0] != "0"
+	case "B", "I", "SB", "SM":
+		font := map[string]byte{"B": 'B', "I": 'I', "SB": 'B', "SM": 'R'}[name]
+		if len(args) == 0 {
+			return
+		}
+		f.text(f.inline(strings.Join(args, " "), &font))
+	case "BR", "RB", "BI", "IB", "IR", "RI":
+		var cells []cell
+		for i, arg := range args {
+			font := name[i%2]
+			cells = append(cells, f.inline(arg, &font)...)
+		}
+		f.text(cells)
+	case "ft":
+		if len(args) > 0 {
+			font := f.font
+			f.inline(`\f`+args[0], &font)
+			f.font = font
+		} else {
+			f.font = 'R'
+		}
+	case "UR":
+		if len(args) > 0 {
+			f.url = args[0]
+		}
+	case "UE":
+		if f.url != "" {
+			punct := ""
+			if len(args) > 0 {
+				punct = args[0]
+			}
+			f.text(f.inline("<"+f.url+">"+punct, &f.font))
+			f.url = ""
+		}
+	case "de", "ig":
+		f.skipUntilDot = true
+	}
+}
+
+// heading writes the heading of .SH at the left and .SS a bit indented
+func (f *roff) heading(level string, cells []cell) {
+	f.space()
+	f.margin, f.base, f.rsStack = 7, 7, nil
+	indent := 0
+	if level == "SS" {
+		indent = 3
+	}
+	for i := range cells {
+		cells[i].font = 'B'
+	}
+	f.lines = append(f.lines, strings.Repeat(" ", indent)+f.cells(cells))
+	f.afterHead = true
+}
+
+// setTag starts the tagged paragraph. The tag goes on its own line if it
+// does not fit in the indent of the body
+func (f *roff) setTag(cells []cell) {
+	f.margin = f.base
+	if len(cells) < f.tagIndent {
+		f.tag, f.tagged = cells, true
+	} else {
+		f.flushLine(cells)
+	}
+	f.margin = f.base + f.tagIndent
+}
+
+// space puts the blank line between paragraphs, unless .PD 0 says not to
+func (f *roff) space() {
+	if f.paraSpace && !f.afterHead && len(f.lines) > 0 && f.lines[len(f.lines)-1] != "" {
+		f.lines = append(f.lines, "")
+	}
+}
+
+// flush fills the words of the paragraph into lines, justified on both
+// sides except the last line
+func (f *roff) flush() {
+	if len(f.words) == 0 {
+		if f.tagged {
+			f.flushLine(nil)
+		}
+		return
+	}
+	avail := f.width - f.margin
+	if avail < 10 {
+		avail = 10
+	}
+	for len(f.words) > 0 {
+		n, used := 1, len(f.words[0])
+		for n < len(f.words) && used+1+len(f.words[n]) <= avail {
+			used += 1 + len(f.words[n])
+			n++
+		}
+		lineWords := f.words[:n]
+		f.words = f.words[n:]
+		gaps := make([]int, len(lineWords))
+		for i := 1; i < len(lineWords); i++ {
+			gaps[i] = 1
+		}
+		if len(f.words) > 0 && len(lineWords) > 1 {
+			extra := avail - used
+			for i := 0; extra > 0; i = (i + 1) % (len(lineWords) - 1) {
+				gaps[len(lineWords)-1-i]++
+				extra--
+			}
+		}
+		var line []cell
+		for i, w := range lineWords {
+			for j := 0; j < gaps[i]; j++ {
+				line = append(line, cell{r: ' ', font: 'R'})
+			}
+			line = append(line, w...)
+		}
+		f.flushLine(line)
+	}
+}
+
+// flushLine writes the line at the margin, after the tag if there is one
+func (f *roff) flushLine(cells []cell) {
+	prefix := strings.Repeat(" ", f.margin)
+	if f.tagged {
+		tagMargin := f.margin - f.tagIndent
+		if tagMargin < 0 {
+			tagMargin = 0
+		}
+		prefix = strings.Repeat(" ", tagMargin) + f.cells(f.tag) + strings.Repeat(" ", f.tagIndent-len(f.tag))
+		f.tag, f.tagged = nil, false
+	}
+	f.lines = append(f.lines, strings.TrimRight(prefix+f.cells(cells), " "))
+	f.afterHead = false
+}
+
+// cells writes the cells with bold and underline if styled
+func (f *roff) cells(cells []cell) string {
+	var b strings.Builder
+	font := byte('R')
+	for _, c := range cells {
+		if c.r == ' ' && !c.nbsp {
+			c.font = 'R'
+		}
+		if f.styled && c.font != font {
+			if font != 'R' {
+				b.WriteString("\x1b[0m")
+			}
+			switch c.font {
+			case 'B':
+				b.WriteString("\x1b[" + terminal.Bold + "m")
+			case 'I':
+				b.WriteString("\x1b[4m")
+			}
+			font = c.font
+		}
+		b.WriteRune(c.r)
+	}
+	if f.styled && font != 'R' {
+		b.WriteString("\x1b[0m")
+	}
+	return b.String()
+}
+
+// plain is the text of the escapes without fonts, for .TH
+func (f *roff) plain(s string) string {
+	var b strings.Builder
+	font := byte('R')
+	for _, c := range f.inline(s, &font) {
+		b.WriteRune(c.r)
+	}
+	return b.String()
+}
+
+// inline interprets the escapes in the text, changing the font as \f says
+func (f *roff) inline(s string, font *byte) []cell {
+	var cells []cell
+	prev := *font
+	rs := []rune(s)
+	// name reads the name of escape like \(xx, \[name] or a single x
+	name := func(i int) (string, int) {
+		if i >= len(rs) {
+			return "", i
+		}
+		switch rs[i] {
+		case '(':
+			if i+2 < len(rs) {
+				return string(rs[i+1 : i+3]), i + 3
+			}
+			return "", len(rs)
+		case '[':
+			end := i + 1
+			for end < len(rs) && rs[end] != ']' {
+				end++
+			}
+			return string(rs[i+1 : end]), end + 1
+		}
+		return string(rs[i]), i + 1
+	}
+	add := func(text string, nbsp bool) {
+		for _, r := range text {
+			cells = append(cells, cell{r: r, font: *font, nbsp: nbsp})
+		}
+	}
+	for i := 0; i < len(rs); {
+		if rs[i] != '\\' || i+1 >= len(rs) {
+			add(string(rs[i]), false)
+			i++
+			continue
+		}
+		c := rs[i+1]
+		i += 2
+		switch c {
+		case 'f':
+			var n string
+			n, i = name(i)
+			switch n {
+			case "B", "3", "CB":
+				prev, *font = *font, 'B'
+			case "I", "2", "CI":
+				prev, *font = *font, 'I'
+			case "BI":
+				prev, *font = *font, 'B'
+			case "P":
+				prev, *font = *font, prev
+			default:
+				prev, *font = *font, 'R'
+			}
+		case '-':
+			add("-", false)
+		case 'e', '\\':
+			add("\\", false)
+		case '&', '|', '^', ':', '%', 'c', ')', ',', '/':
+		case ' ', '~', '0':
+			add(" ", true)
+		case '(', '[':
+			var n string
+			n, i = name(i - 1)
+			add(roffSpecial[n], false)
+		case '*':
+			var n string
+			n, i = name(i)
+			add(roffSpecial[n], false)
+		case '"':
+			i = len(rs)
+		case 's':
+			if i < len(rs) && (rs[i] == '+' || rs[i] == '-') {
+				i++
+			}
+			if i < len(rs) && (rs[i] == '(' || rs[i] == '[') {
+				_, i = name(i)
+			} else if i < len(rs) {
+				i++
+			}
+		case 'n':
+			_, i = name(i)
+		case 'h', 'v', 'w', 'o', 'l', 'L':
+			// Skip the quoted argument of motions and other drawing
+			if i < len(rs) && rs[i] == '\'' {
+				end := strings.IndexRune(string(rs[i+1:]), '\'')
+				if end < 0 {
+					i = len(rs)
+				} else {
+					i += len([]rune(string(rs[i+1:])[:end])) + 2
+				}
+			}
+		case '\'':
+			add("'", false)
+		case '`':
+			add("`", false)
+		case '.':
+			add(".", false)
+		default:
+			add(string(c), false)
+		}
+	}
+	return cells
+}
+
+// roffArgs splits the arguments of the request, keeping the quoted ones
+// together
+func roffArgs(s string) []string {
+	var args []string
+	for s = strings.TrimLeft(s, " \t"); s != ""; s = strings.TrimLeft(s, " \t") {
+		if strings.HasPrefix(s, `\"`) {
+			break
+		}
+		if s[0] == '"' {
+			end := 1
+			for end < len(s) && (s[end] != '"' || end+1 < len(s) && s[end+1] == '"') {
+				if s[end] == '"' {
+					end++
+				}
+				end++
+			}
+			args = append(args, strings.Replace(s[1:end], `""`, `"`, -1))
+			if end < len(s) {
+				end++
+			}
+			s = s[end:]
+			continue
+		}
+		end := strings.IndexAny(s, " \t")
+		if end < 0 {
+			end = len(s)
+		}
+		args = append(args, s[:end])
+		s = s[end:]
+	}
+	return args
+}
+
+// roffIndent reads the indent like 4 or 5n in ens, which are columns on the
+// terminal
+func roffIndent(s string, def int) int {
+	n, err := strconv.ParseFloat(strings.TrimRight(s, "nmviupPc"), 64)
+	if err != nil || n < 0 {
+		return def
+	}
+	if strings.HasSuffix(s, "i") {
+		n *= 10
+	}
+	return int(n)
+}
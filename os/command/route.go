@@ -0,0 +1,173 @@
+package command
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/viper"
+)
+
+// route shows the routing table of the kernel, the same routes as ip route
+type route struct{}
+
+// netRouteEntry is a route of the kernel routing table, shown by route and
+// netstat -r
+type netRouteEntry struct {
+	dest, gateway, mask net.IP
+	flags, iface        string
+	metric              int
+}
+
+const routeUsage = `Usage: route [-nNvee] [-FC] [<AF>]           List kernel routing tables
+       route [-v] [-FC] {add|del|flush} ...  Modify routing table for AF.
+
+       route {-h|--help} [<AF>]              Detailed usage syntax for specified AF.
+       route {-V|--version}                  Display version/author and exit.
+
+        -v, --verbose            be verbose
+        -n, --numeric            don't resolve names
+        -e, --extend             display other/more information
+        -F, --fib                display Forwarding Information Base (default)
+        -C, --cache              display routing cache instead of FIB
+
+  <AF>=Use -4, -6, '-A <af>' or '--<af>'; default: inet
+`
+
+func init() {
+	honeyos.RegisterCommand("route", route{})
+}
+
+func (route) GetHelp() string {
+	return routeUsage
+}
+
+func (route) Where() string {
+	return "/sbin/route"
+}
+
+// netRoutes are the default route through the gateway and the route of the
+// subnet of the ethernet interface
+func netRoutes() []netRouteEntry {
+	eth, _ := findIface("eth0")
+	metric := 0
+	if pkgFamily() == "rpm" {
+		metric = 100
+	}
+	return []netRouteEntry{
+		{dest: net.IPv4zero, gateway: net.ParseIP(viper.GetString("persona.gateway")), mask: net.IPv4zero,
+			flags: "UG", iface: "eth0", metric: metric},
+		{dest: eth.addr.Mask(eth.mask), gateway: net.IPv4zero, mask: net.IP(eth.mask), flags: "U", iface: "eth0", metric: metric},
+	}
+}
+
+// routeName names the destination or gateway unless numeric, like default
+// for 0.0.0.0. Newer net-tools of CentOS keep the gateway of the subnet as is
+func routeName(ip net.IP, gateway, numeric bool) string {
+	switch {
+	case numeric:
+	case ip.Equal(net.IPv4zero) && gateway && pkgFamily() != "rpm":
+		return "*"
+	case ip.Equal(net.IPv4zero) && !gateway:
+		return "default"
+	}
+	return ip.String()
+}
+
+func (r route) Exec(args []string, sys honeyos.Sys) int {
+	if pkgFamily() == "rpm" && !loadPkgDB(sys, "rpm").installed("net-tools") {
+		return honeyos.CommandNotFound(sys, append([]string{"route"}, args...))
+	}
+	numeric, extend, inet6 := false, false, false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "add", "del", "flush":
+			// Changes are accepted from root, but the routes stay the same
+			sys.Log().WithField("args", args).Info("User tried to configure network")
+			if !isRoot(sys) {
+				fmt.Fprintln(sys.Err(), "SIOCADDRT: Operation not permitted")
+				return 7
+			}
+			return 0
+		case "-A":
+			if i+1 < len(args) {
+				i++
+				inet6 = args[i] == "inet6"
+			}
+			continue
+		case "--inet6":
+			inet6 = true
+			continue
+		case "-h", "--help":
+			fmt.Fprint(sys.Out(), routeUsage)
+			return 0
+		case "--numeric":
+			numeric = true
+			continue
+		case "--extend":
+			extend = true
+			continue
+		}
+		if !strings.HasPrefix(arg, "-") || strings.HasPrefix(arg, "--") {
+			fmt.Fprint(sys.Err(), routeUsage)
+			return 4
+		}
+		for _, c := range arg[1:] {
+			switch c {
+			case 'n':
+				numeric = true
+			case 'e':
+				extend = true
+			case '6':
+				inet6 = true
+			case 'v', 'N', 'F', 'C', '4':
+			default:
+				fmt.Fprintf(sys.Err(), "route: invalid option -- '%c'\n", c)
+				fmt.Fprint(sys.Err(), routeUsage)
+				return 4
+			}
+		}
+	}
+	if inet6 {
+		r.printInet6(sys)
+		return 0
+	}
+	printRouteTable(sys, numeric, extend)
+	return 0
+}
+
+// printRouteTable prints the routes like route and netstat -r, netstat
+// having the columns of route -e
+func printRouteTable(sys honeyos.Sys, numeric, netstat bool) {
+	fmt.Fprintln(sys.Out(), "Kernel IP routing table")
+	if netstat {
+		fmt.Fprintln(sys.Out(), "Destination     Gateway         Genmask         Flags   MSS Window  irtt Iface")
+	} else {
+		fmt.Fprintln(sys.Out(), "Destination     Gateway         Genmask         Flags Metric Ref    Use Iface")
+	}
+	for _, e := range netRoutes() {
+		line := fmt.Sprintf("%-15v %-15v %-15v ", routeName(e.dest, false, numeric), routeName(e.gateway, true, numeric),
+			e.mask)
+		if netstat {
+			line += fmt.Sprintf("%-5v %5d %-6d %5d %v", e.flags, 0, 0, 0, e.iface)
+		} else {
+			line += fmt.Sprintf("%-5v %-6d %-6d %3d %v", e.flags, e.metric, 0, 0, e.iface)
+		}
+		fmt.Fprintln(sys.Out(), line)
+	}
+}
+
+func (route) printInet6(sys honeyos.Sys) {
+	fmt.Fprintln(sys.Out(), "Kernel IPv6 routing table")
+	fmt.Fprintln(sys.Out(), "Destination                    Next Hop                   Flag Met Ref Use If")
+	rows := [][]string{
+		{"fe80::/64", "::", "U", "256", "eth0"},
+		{"::1/128", "::", "Un", "0", "lo"},
+		{"ff00::/8", "::", "U", "256", "eth0"},
+	}
+	for _, row := range rows {
+		fmt.Fprintf(sys.Out(), "%-30v %-26v %-4v %-3v %-3v %3v %v\n", row[0], row[1], row[2], row[3], 1, 0, row[4])
+	}
+}
@@ -0,0 +1,708 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	pathlib "path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+// rsync syncs files with other hosts, over the connection ssh pretends to
+// make like scp, or with rsync daemons. Daemons are only on the Internet,
+// reachable if the honeypot is online, and take whatever is pushed to them
+// as servers of the attacker do. Files pushed out are captured as artifact
+type rsync struct{}
+
+// rsyncVersions are the versions of rsync of the distributions
+var rsyncVersions = map[string]string{"ubuntu": "3.1.1", "debian": "3.1.2", "centos": "3.1.2", "alpine": "3.2.3"}
+
+const rsyncUsage = `rsync  version %v  protocol version 31
+Copyright (C) 1996-2014 by Andrew Tridgell, Wayne Davison, and others.
+Web site: http://rsync.samba.org/
+
+rsync comes with ABSOLUTELY NO WARRANTY.  This is free software, and you
+are welcome to redistribute it under certain conditions.  See the GNU
+General Public Licence for details.
+
+rsync is a file transfer program capable of efficient remote update
+via a fast differencing algorithm.
+
+Usage: rsync [OPTION]... SRC [SRC]... DEST
+  or   rsync [OPTION]... SRC [SRC]... [USER@]HOST:DEST
+  or   rsync [OPTION]... SRC [SRC]... [USER@]HOST::DEST
+  or   rsync [OPTION]... SRC [SRC]... rsync://[USER@]HOST[:PORT]/DEST
+  or   rsync [OPTION]... [USER@]HOST:SRC [DEST]
+  or   rsync [OPTION]... [USER@]HOST::SRC [DEST]
+  or   rsync [OPTION]... rsync://[USER@]HOST[:PORT]/SRC [DEST]
+The ':' usages connect via remote shell, while '::' & 'rsync://' usages connect
+to an rsync daemon, and require SRC or DEST to start with a module name.
+
+Options
+ -v, --verbose               increase verbosity
+ -q, --quiet                 suppress non-error messages
+ -c, --checksum              skip based on checksum, not mod-time & size
+ -a, --archive               archive mode; equals -rlptgoD (no -H,-A,-X)
+ -r, --recursive             recurse into directories
+ -u, --update                skip files that are newer on the receiver
+ -l, --links                 copy symlinks as symlinks
+ -p, --perms                 preserve permissions
+ -t, --times                 preserve modification times
+ -n, --dry-run               perform a trial run with no changes made
+ -e, --rsh=COMMAND           specify the remote shell to use
+     --delete                delete extraneous files from destination dirs
+     --exclude=PATTERN       exclude files matching PATTERN
+ -z, --compress              compress file data during the transfer
+     --stats                 give some file-transfer stats
+ -h, --human-readable        output numbers in a human-readable format
+     --progress              show progress during transfer
+ -P                          same as --partial --progress
+     --password-file=FILE    read daemon-access password from FILE
+     --version               print version number
+(-h) --help                  show this help (-h is --help only if used alone)
+
+Use "rsync --daemon --help" to see the daemon-mode command-line options.
+Please see the rsync(1) and rsyncd.conf(5) man pages for full documentation.
+See http://rsync.samba.org/ for updates, bug reports, and answers
+`
+
+// rsyncPath is the operand of rsync, remote over ssh if host is set, or on
+// the daemon of the host
+type rsyncPath struct {
+	scpPath
+	daemon bool
+	port   int
+}
+
+// rsyncFile is the file of the list sent, named relative to the destination
+type rsyncFile struct {
+	name, path string
+	fi         os.FileInfo
+	fs         afero.Fs
+}
+
+// rsyncRun is the state of the transfer, with the options given
+type rsyncRun struct {
+	sys                                  honeyos.Sys
+	version                              string
+	verbose, recursive, dryRun, progress bool
+	times, update, delete, stats, quiet  bool
+	fresh                                bool
+	excludes                             []string
+	ssh                                  sshConn
+	password                             string
+	conns                                map[string]*sshConn
+	daemons                              map[string]afero.Fs
+	sent, total                          int64
+	xfr, port                            int
+}
+
+func init() {
+	honeyos.RegisterCommand("rsync", rsync{})
+}
+
+func (r rsync) GetHelp() string {
+	return fmt.Sprintf(rsyncUsage, rsyncVersion())
+}
+
+func (rsync) Where() string {
+	return "/usr/bin/rsync"
+}
+
+func rsyncVersion() string {
+	if v, ok := rsyncVersions[honeyos.Distro()]; ok {
+		return v
+	}
+	return rsyncVersions["ubuntu"]
+}
+
+func (r rsync) Exec(args []string, sys honeyos.Sys) int {
+	if pkgFamily() != "deb" && !loadPkgDB(sys, pkgFamily()).installed("rsync") {
+		return honeyos.CommandNotFound(sys, append([]string{"rsync"}, args...))
+	}
+	run := &rsyncRun{sys: sys, version: rsyncVersion(), ssh: sshConn{options: map[string]string{}},
+		conns: map[string]*sshConn{}, daemons: map[string]afero.Fs{}}
+	run.password = honeyos.Getenv(sys, "RSYNC_PASSWORD")
+	var operands []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		// Long options take the value after = or as the next argument
+		value := func(name string) (string, bool) {
+			if strings.HasPrefix(arg, name+"=") {
+				return arg[len(name)+1:], true
+			}
+			if i+1 < len(args) {
+				i++
+				return args[i], true
+			}
+			fmt.Fprintf(sys.Err(), "rsync: option `%v' requires an argument\n", name)
+			return "", false
+		}
+		var ok = true
+		var v string
+		switch {
+		case arg == "--":
+			operands = append(operands, args[i+1:]...)
+			i = len(args)
+		case arg == "--version":
+			fmt.Fprint(sys.Out(), strings.SplitN(r.GetHelp(), "\nrsync is", 2)[0]+"\n")
+			return 0
+		case arg == "--help" || arg == "-h" && len(args) == 1:
+			fmt.Fprint(sys.Out(), r.GetHelp())
+			return 0
+		case arg == "--verbose":
+			run.verbose = true
+		case arg == "--recursive" || arg == "--archive":
+			run.recursive = true
+			run.times = run.times || arg == "--archive"
+		case arg == "--dry-run":
+			run.dryRun = true
+		case arg == "--progress":
+			run.progress = true
+		case arg == "--times":
+			run.times = true
+		case arg == "--update":
+			run.update = true
+		case arg == "--quiet":
+			run.quiet = true
+		case arg == "--stats":
+			run.stats = true
+		case strings.HasPrefix(arg, "--delete"):
+			run.delete = true
+		case strings.HasPrefix(arg, "--exclude"):
+			if v, ok = value("--exclude"); ok {
+				run.excludes = append(run.excludes, v)
+			}
+		case strings.HasPrefix(arg, "--rsh"):
+			if v, ok = value("--rsh"); ok {
+				ok = run.rsh(v)
+			}
+		case strings.HasPrefix(arg, "--port"):
+			if v, ok = value("--port"); ok {
+				run.port, _ = strconv.Atoi(v)
+			}
+		case strings.HasPrefix(arg, "--password-file"):
+			if v, ok = value("--password-file"); ok {
+				data, err := afero.ReadFile(sys.FSys(), absPath(sys, v))
+				if err != nil {
+					fmt.Fprintf(sys.Err(), "rsync: could not open password file \"%v\": No such file or directory (2)\n", v)
+					return 5
+				}
+				run.password = strings.SplitN(string(data), "\n", 2)[0]
+			}
+		case strings.HasPrefix(arg, "--"):
+			// Options of what is preserved and how change nothing here
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			for j := 1; j < len(arg) && ok; j++ {
+				switch c := arg[j]; c {
+				case 'v':
+					run.verbose = true
+				case 'r':
+					run.recursive = true
+				case 'a':
+					run.recursive, run.times = true, true
+				case 'n':
+					run.dryRun = true
+				case 'P':
+					run.progress = true
+				case 't':
+					run.times = true
+				case 'u':
+					run.update = true
+				case 'q':
+					run.quiet = true
+				case 'e':
+					if v = arg[j+1:]; v == "" && i+1 < len(args) {
+						i++
+						v = args[i]
+					}
+					ok = run.rsh(v)
+					j = len(arg)
+				case 'z', 'h', 'c', 'l', 'p', 'o', 'g', 'D', 'H', 'x', 'A', 'X', 'S', 'W', 'R', 'b', 'd', 'i', 'm', 'L', 'k', 'K', 'O', 'E':
+				default:
+					fmt.Fprintf(sys.Err(), "rsync: -%c: unknown option\n", c)
+					ok = false
+				}
+			}
+		default:
+			operands = append(operands, arg)
+		}
+		if !ok {
+			fmt.Fprintf(sys.Err(), "rsync error: syntax or usage error (code 1) at main.c(1572) [client=%v]\n", run.version)
+			return 1
+		}
+	}
+	switch len(operands) {
+	case 0:
+		fmt.Fprint(sys.Err(), strings.SplitN(r.GetHelp(), "\nOptions", 2)[0]+"\n")
+		fmt.Fprintf(sys.Err(), "rsync error: syntax or usage error (code 1) at main.c(1572) [client=%v]\n", run.version)
+		return 1
+	case 1:
+		return run.list(parseRsyncPath(operands[0]))
+	}
+	return run.sync(operands[:len(operands)-1], operands[len(operands)-1])
+}
+
+// rsh takes the options of ssh given as the remote shell, like
+// -e "ssh -p 2222 -i key"
+func (r *rsyncRun) rsh(cmd string) bool {
+	words, err := honeyos.SplitArgs(cmd)
+	if err != nil || len(words) == 0 {
+		fmt.Fprintln(r.sys.Err(), "rsync: Failed to exec : No such file or directory (2)")
+		return false
+	}
+	for i := 1; i < len(words); i++ {
+		w := words[i]
+		if len(w) < 2 || w[0] != '-' || strings.IndexByte("pilo", w[1]) < 0 {
+			continue
+		}
+		v := w[2:]
+		if v == "" && i+1 < len(words) {
+			i++
+			v = words[i]
+		}
+		switch w[1] {
+		case 'p':
+			r.ssh.port = v
+		case 'i':
+			r.ssh.identity = v
+		case 'l':
+			r.ssh.login = v
+		case 'o':
+			r.ssh.option(r.sys, v)
+		}
+	}
+	return true
+}
+
+// parseRsyncPath splits the operand into where the files are. Double colon
+// and rsync:// are for the daemons, single colon for ssh
+func parseRsyncPath(arg string) rsyncPath {
+	if strings.HasPrefix(arg, "rsync://") {
+		rest := strings.TrimPrefix(arg, "rsync://")
+		hostPart, path := rest, ""
+		if slash := strings.IndexByte(rest, '/'); slash >= 0 {
+			hostPart, path = rest[:slash], rest[slash+1:]
+		}
+		p := rsyncPath{daemon: true, port: 873}
+		if at := strings.LastIndexByte(hostPart, '@'); at >= 0 {
+			p.login, hostPart = hostPart[:at], hostPart[at+1:]
+		}
+		if colon := strings.LastIndexByte(hostPart, ':'); colon >= 0 {
+			p.port, _ = strconv.Atoi(hostPart[colon+1:])
+			hostPart = hostPart[:colon]
+		}
+		p.host, p.path = hostPart, path
+		return p
+	}
+	if i := strings.Index(arg, "::"); i > 0 && !strings.Contains(arg[:i], "/") {
+		return rsyncPath{scpPath: parseScpPath(arg[:i] + ":" + arg[i+2:]), daemon: true, port: 873}
+	}
+	return rsyncPath{scpPath: parseScpPath(arg)}
+}
+
+// fsOf returns the filesystem and the absolute path of the operand,
+// connecting to the host the first time
+func (r *rsyncRun) fsOf(p rsyncPath) (afero.Fs, string, int) {
+	sys := r.sys
+	switch {
+	case p.host == "":
+		return sys.FSys(), absPath(sys, p.path), 0
+	case p.daemon:
+		return r.daemon(p)
+	}
+	key := p.login + "@" + p.host
+	conn, ok := r.conns[key]
+	if !ok {
+		c := r.ssh
+		c.options = r.ssh.options
+		if p.login != "" {
+			c.login = p.login
+		}
+		c.host = p.host
+		if c.connect(sys, log.Fields{"command": "rsync --server"}) {
+			conn = &c
+		}
+		r.conns[key] = conn
+	}
+	if conn == nil {
+		fmt.Fprintln(sys.Err(), "rsync: connection unexpectedly closed (0 bytes received so far) [sender]")
+		fmt.Fprintf(sys.Err(), "rsync error: unexplained error (code 255) at io.c(226) [sender=%v]\n", r.version)
+		return nil, "", 255
+	}
+	fs, name := conn.remoteFs(sys, p.path)
+	if p.path == "" {
+		name += "/"
+	}
+	return fs, name, 0
+}
+
+// daemon connects to the rsync daemon of the host. The module is the first
+// element of the path, and the files of the daemon are kept for the run
+func (r *rsyncRun) daemon(p rsyncPath) (afero.Fs, string, int) {
+	sys := r.sys
+	if r.port != 0 && p.port == 873 {
+		p.port = r.port
+	}
+	addr := fmt.Sprintf("%v:%v", p.host, p.port)
+	if fs, ok := r.daemons[addr]; ok {
+		return fs, "/" + p.path, 0
+	}
+	logger := sys.Log().WithFields(log.Fields{"rsyncHost": p.host, "rsyncPort": p.port, "rsyncUser": p.login})
+	logger.Infof("User connecting to rsync daemon %v", addr)
+	ip := netResolve(sys, p.host)
+	if ip == nil {
+		fmt.Fprintf(sys.Err(), "rsync: getaddrinfo: %v %v: Name or service not known\n", p.host, p.port)
+		fmt.Fprintf(sys.Err(), "rsync error: error in socket IO (code 10) at clientserver.c(128) [sender=%v]\n", r.version)
+		return nil, "", 10
+	}
+	open, reachable := netPortOpen(sys, ip, p.port)
+	switch {
+	case !reachable:
+		if !pkgSleep(sys, 127*time.Second) {
+			return nil, "", 10
+		}
+		fmt.Fprintf(sys.Err(), "rsync: failed to connect to %v (%v): Connection timed out (110)\n", p.host, ip)
+	case !open:
+		fmt.Fprintf(sys.Err(), "rsync: failed to connect to %v (%v): Connection refused (111)\n", p.host, ip)
+	}
+	if !open {
+		fmt.Fprintf(sys.Err(), "rsync error: error in socket IO (code 10) at clientserver.c(128) [sender=%v]\n", r.version)
+		return nil, "", 10
+	}
+	if p.login != "" {
+		password := r.password
+		if password == "" {
+			tty := honeyos.OpenTTY(sys)
+			if tty == nil {
+				tty = sys.In()
+			}
+			password, _ = readPasswordFrom(sys, tty, "Password: ")
+		}
+		logger.WithFields(log.Fields{"password": password, "accepted": true}).
+			Infof("User tried rsync password for %v@%v", p.login, p.host)
+	}
+	fs := afero.NewMemMapFs()
+	if module := strings.SplitN(p.path, "/", 2)[0]; module != "" {
+		fs.MkdirAll("/"+module, 0755)
+	}
+	r.daemons[addr] = fs
+	return fs, "/" + p.path, 0
+}
+
+// list lists the files of the operand, as rsync does given no destination
+func (r *rsyncRun) list(p rsyncPath) int {
+	fs, name, code := r.fsOf(p)
+	if code != 0 {
+		return code
+	}
+	if p.daemon && strings.Trim(p.path, "/") == "" {
+		// Daemons list their modules, none here
+		return 0
+	}
+	// Without -r, the content of the directory is listed if it ends with /
+	recursive := r.recursive
+	r.recursive = true
+	files, ok := r.fileList(fs, name, p.path)
+	if !ok {
+		return r.partial()
+	}
+	for _, f := range files {
+		if !recursive && strings.Count(strings.TrimPrefix(f.name, "./"), "/") > 0 {
+			continue
+		}
+		if !recursive && len(files) > 1 && !strings.HasSuffix(p.path, "/") && f.name != pathlib.Base(name) {
+			continue
+		}
+		fmt.Fprintf(r.sys.Out(), "%v %14v %v %v\n", lsMode(f.fi.Mode()), rsyncNum(f.fi.Size()),
+			f.fi.ModTime().Format("2006/01/02 15:04:05"), f.name)
+	}
+	return 0
+}
+
+// missing tells the source is not found
+func (r *rsyncRun) missing(name string) {
+	fmt.Fprintf(r.sys.Err(), "rsync: link_stat \"%v\" failed: No such file or directory (2)\n", absPath(r.sys, name))
+}
+
+// partial fails for the files not transferred
+func (r *rsyncRun) partial() int {
+	fmt.Fprintf(r.sys.Err(), "rsync error: some files/attrs were not transferred (see previous errors) (code 23) at main.c(1183) [sender=%v]\n", r.version)
+	return 23
+}
+
+// sync sends the sources to the destination like rsync, showing the files
+// with -v and the progress with --progress
+func (r *rsyncRun) sync(sources []string, dest string) int {
+	sys := r.sys
+	target := parseRsyncPath(dest)
+	var srcPaths []rsyncPath
+	remote := ""
+	for _, s := range sources {
+		p := parseRsyncPath(s)
+		srcPaths = append(srcPaths, p)
+		if p.host != "" {
+			remote = p.host
+		}
+	}
+	if remote != "" && target.host != "" {
+		fmt.Fprintln(sys.Err(), "The source and destination cannot both be remote.")
+		fmt.Fprintf(sys.Err(), "rsync error: syntax or usage error (code 1) at main.c(1296) [Receiver=%v]\n", r.version)
+		return 1
+	}
+	sys.Log().WithFields(log.Fields{"sources": sources, "target": dest}).
+		Infof("User syncing %v to %v with rsync", strings.Join(sources, " "), dest)
+
+	// The list of files is made before connecting for pushes, so what is
+	// taken out is known even if the host can't be reached
+	var files []rsyncFile
+	failed := false
+	if target.host != "" {
+		for _, p := range srcPaths {
+			list, ok := r.fileList(sys.FSys(), absPath(sys, p.path), p.path)
+			files = append(files, list...)
+			failed = failed || !ok
+		}
+		var names []string
+		for _, f := range files {
+			names = append(names, f.path)
+		}
+		sys.Log().WithFields(log.Fields{"target": dest, "files": names}).
+			Warnf("User pushing %v files to %v with rsync", len(names), target.host)
+	}
+	dstFs, dstName, code := r.fsOf(target)
+	if code != 0 {
+		return code
+	}
+	if target.host == "" {
+		for _, p := range srcPaths {
+			fs, name, code := r.fsOf(p)
+			if code != 0 {
+				return code
+			}
+			list, ok := r.fileList(fs, name, p.path)
+			files = append(files, list...)
+			failed = failed || !ok
+		}
+		if remote != "" {
+			var names []string
+			for _, f := range files {
+				names = append(names, f.path)
+			}
+			sys.Log().WithFields(log.Fields{"source": sources, "files": names}).
+				Infof("User pulling %v files from %v with rsync", len(names), remote)
+		}
+	}
+
+	if r.verbose && !r.quiet {
+		if remote != "" {
+			fmt.Fprintln(sys.Out(), "receiving incremental file list")
+		} else {
+			fmt.Fprintln(sys.Out(), "sending incremental file list")
+		}
+	}
+	// A single file goes to the destination as named, the rest into it as
+	// directory
+	single := len(files) == 1 && !files[0].fi.IsDir() && !strings.HasSuffix(dest, "/")
+	if fi, err := dstFs.Stat(dstName); err == nil && fi.IsDir() {
+		single = false
+	} else if !single && !r.dryRun {
+		if dstFs.MkdirAll(dstName, 0755) != nil {
+			fmt.Fprintf(sys.Err(), "rsync: mkdir \"%v\" failed: Permission denied (13)\n", dstName)
+			fmt.Fprintf(sys.Err(), "rsync error: error in file IO (code 11) at main.c(675) [Receiver=%v]\n", r.version)
+			return 11
+		}
+		r.fresh = true
+		if r.verbose && !r.quiet {
+			fmt.Fprintf(sys.Out(), "created directory %v\n", strings.TrimSuffix(dest[strings.LastIndexByte(dest, ':')+1:], "/"))
+		}
+	}
+	for i, f := range files {
+		to := pathlib.Join(dstName, f.name)
+		if single {
+			to = dstName
+		}
+		failed = !r.transfer(dstFs, f, to, len(files)-1-i, len(files), target.host != "") || failed
+	}
+	if r.delete && r.recursive {
+		r.deleteExtra(dstFs, dstName, files)
+	}
+	r.summary()
+	if failed {
+		return r.partial()
+	}
+	return 0
+}
+
+// fileList lists the files of the source, named the way they go in the
+// destination: the directory itself, or its content if the name ends with /
+func (r *rsyncRun) fileList(fs afero.Fs, root, arg string) ([]rsyncFile, bool) {
+	fi, err := fs.Stat(root)
+	if err != nil {
+		r.missing(arg)
+		return nil, false
+	}
+	if fi.IsDir() && !r.recursive {
+		if !r.quiet {
+			fmt.Fprintf(r.sys.Out(), "skipping directory %v\n", pathlib.Base(root))
+		}
+		return nil, true
+	}
+	prefix := pathlib.Base(root)
+	if strings.HasSuffix(arg, "/") || arg == "" {
+		prefix = ""
+	}
+	var files []rsyncFile
+	afero.Walk(fs, root, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		name := pathlib.Join(prefix, strings.TrimPrefix(p, root))
+		for _, pattern := range r.excludes {
+			pattern = strings.TrimSuffix(pattern, "/")
+			base, _ := pathlib.Match(pattern, fi.Name())
+			whole, _ := pathlib.Match(strings.TrimPrefix(pattern, "/"), name)
+			if p != root && (base || whole) {
+				if fi.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+		if fi.IsDir() && name == "" {
+			name = "."
+		}
+		files = append(files, rsyncFile{name: name, path: p, fi: fi, fs: fs})
+		return nil
+	})
+	return files, true
+}
+
+// transfer sends the file unless the destination has it already, with the
+// same size and time. Files pushed to other hosts are captured as artifact
+func (r *rsyncRun) transfer(dstFs afero.Fs, f rsyncFile, to string, toCheck, total int, push bool) bool {
+	sys := r.sys
+	show := (r.verbose || r.progress) && !r.quiet
+	name := f.name
+	if f.fi.IsDir() {
+		// Directories are listed when made, as the destination itself is
+		if _, err := dstFs.Stat(to); show && (err != nil || name == "." && r.fresh) {
+			fmt.Fprintln(sys.Out(), name+"/")
+		}
+		if !r.dryRun {
+			dstFs.MkdirAll(to, f.fi.Mode().Perm())
+		}
+		return true
+	}
+	r.total += f.fi.Size()
+	if dfi, err := dstFs.Stat(to); err == nil && dfi.Size() == f.fi.Size() &&
+		(dfi.ModTime().Equal(f.fi.ModTime()) || r.update && !dfi.ModTime().Before(f.fi.ModTime())) {
+		return true
+	}
+	if show {
+		fmt.Fprintln(sys.Out(), name)
+	}
+	r.sent += 40 + int64(len(name))
+	if r.dryRun {
+		return true
+	}
+	data, err := afero.ReadFile(f.fs, f.path)
+	if err != nil {
+		fmt.Fprintf(sys.Err(), "rsync: send_files failed to open \"%v\": Permission denied (13)\n", f.path)
+		return false
+	}
+	if err := afero.WriteFile(dstFs, to, data, f.fi.Mode().Perm()); err != nil {
+		fmt.Fprintf(sys.Err(), "rsync: mkstemp \"%v\" failed: Permission denied (13)\n", to)
+		return false
+	}
+	if r.times {
+		dstFs.Chtimes(to, f.fi.ModTime(), f.fi.ModTime())
+	}
+	if push {
+		honeyos.SaveArtifact(sys, data, "rsync "+f.path)
+	}
+	r.sent += int64(len(data))
+	r.xfr++
+	if r.progress && !r.quiet {
+		size := int64(len(data))
+		fmt.Fprintf(sys.Out(), "%15v 100%% %7v    0:00:00 (xfr#%v, to-chk=%v/%v)\n", rsyncNum(size),
+			rsyncRate(float64(size)/0.01), r.xfr, toCheck, total)
+	}
+	return true
+}
+
+// deleteExtra removes the files of the destination not in the list
+func (r *rsyncRun) deleteExtra(dstFs afero.Fs, dstName string, files []rsyncFile) {
+	keep := map[string]bool{}
+	for _, f := range files {
+		keep[pathlib.Clean(f.name)] = true
+	}
+	var extra []string
+	afero.Walk(dstFs, dstName, func(p string, fi os.FileInfo, err error) error {
+		if err == nil && p != dstName {
+			if rel := strings.TrimPrefix(p, strings.TrimSuffix(dstName, "/")+"/"); !keep[rel] {
+				extra = append(extra, rel)
+			}
+		}
+		return nil
+	})
+	sort.Sort(sort.Reverse(sort.StringSlice(extra)))
+	for _, rel := range extra {
+		if r.verbose && !r.quiet {
+			fmt.Fprintf(r.sys.Out(), "deleting %v\n", rel)
+		}
+		if !r.dryRun {
+			dstFs.RemoveAll(pathlib.Join(dstName, rel))
+		}
+	}
+}
+
+// summary prints what was sent, like rsync does at the end with -v
+func (r *rsyncRun) summary() {
+	out := r.sys.Out()
+	received := 19 + int64(r.xfr)*22
+	sent := r.sent + 60
+	if r.stats && !r.quiet {
+		fmt.Fprintf(out, "\nNumber of files: %v\nNumber of regular files transferred: %v\n"+
+			"Total file size: %v bytes\nLiteral data: %v bytes\n", rsyncNum(int64(r.xfr)), rsyncNum(int64(r.xfr)),
+			rsyncNum(r.total), rsyncNum(r.sent))
+	}
+	if !r.verbose && !r.stats || r.quiet {
+		return
+	}
+	speedup := float64(r.total) / float64(sent+received)
+	fmt.Fprintf(out, "\nsent %v bytes  received %v bytes  %v bytes/sec\n", rsyncNum(sent), rsyncNum(received),
+		rsyncNum((sent+received)*2)+".00")
+	dry := ""
+	if r.dryRun {
+		dry = " (DRY RUN)"
+	}
+	fmt.Fprintf(out, "total size is %v  speedup is %.2f%v\n", rsyncNum(r.total), speedup, dry)
+}
+
+// rsyncNum formats the number with thousands separated, as rsync 3.1 does
+func rsyncNum(n int64) string {
+	s := strconv.FormatInt(n, 10)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+// rsyncRate formats the rate of the progress, like 1.23MB/s
+func rsyncRate(rate float64) string {
+	for _, unit := range []string{"kB/s", "MB/s", "GB/s"} {
+		rate /= 1024
+		if rate < 1024 {
+			return fmt.Sprintf("%.2f%v", rate, unit)
+		}
+	}
+	return fmt.Sprintf("%.2fTB/s", rate/1024)
+}
@@ -160,7 +160,7 @@ func (scp *SCP) sourceMode(path string, isRecursive bool) int {
 	cwd := path
 	dirLevel := 0
 	if isRecursive {
-		fs := afero.Afero{scp.Fs}
+		fs := afero.Afero{Fs: scp.Fs}
 		fs.Walk(path, func(p string, info os.FileInfo, err error) error {
 			p = strings.Replace(p, "\\", "/", -1)
 			if !strings.HasPrefix(p, cwd) {
@@ -0,0 +1,254 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	pathlib "path"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+// scpClient copies files to and from other hosts, over the connection ssh
+// pretends to make. Files are only copied for the hosts ssh lands on, the
+// others collect the credentials and what attackers try to exfiltrate
+type scpClient struct{}
+
+// scpPath is the operand of scp, which is on the remote host if host is set
+type scpPath struct {
+	login, host, path string
+}
+
+const scpUsage = `usage: scp [-12346BCpqrv] [-c cipher] [-F ssh_config] [-i identity_file]
+           [-l limit] [-o ssh_option] [-P port] [-S program]
+           [[user@]host1:]file1 ... [[user@]host2:]file2
+`
+
+func init() {
+	honeyos.RegisterCommand("scp", scpClient{})
+}
+
+func (scpClient) GetHelp() string {
+	return scpUsage
+}
+
+func (scpClient) Where() string {
+	return "/usr/bin/scp"
+}
+
+func (s scpClient) Exec(args []string, sys honeyos.Sys) int {
+	if pkgFamily() == "apk" && !loadPkgDB(sys, "apk").installed("openssh-client") {
+		return honeyos.CommandNotFound(sys, append([]string{"scp"}, args...))
+	}
+	proto := sshConn{options: map[string]string{}}
+	recursive := false
+	var operands []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			operands = append(operands, args[i+1:]...)
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			operands = append(operands, arg)
+			continue
+		}
+		for j := 1; j < len(arg); j++ {
+			c := arg[j]
+			if strings.IndexByte("cFiloPS", c) >= 0 {
+				val := arg[j+1:]
+				if val == "" {
+					if i+1 >= len(args) {
+						fmt.Fprintf(sys.Err(), "scp: option requires an argument -- %c\n", c)
+						fmt.Fprint(sys.Err(), scpUsage)
+						return 1
+					}
+					i++
+					val = args[i]
+				}
+				switch c {
+				case 'i':
+					proto.identity = val
+				case 'P':
+					proto.port = val
+				case 'o':
+					if !proto.option(sys, val) {
+						return 1
+					}
+				}
+				break
+			}
+			switch c {
+			case 'r':
+				recursive = true
+			case 'q':
+				proto.quiet = true
+			case 'v':
+				proto.verbose = true
+			case '1', '2', '3', '4', '6', 'B', 'C', 'p', 'T':
+			default:
+				fmt.Fprintf(sys.Err(), "scp: unknown option -- %c\n", c)
+				fmt.Fprint(sys.Err(), scpUsage)
+				return 1
+			}
+		}
+	}
+	if len(operands) < 2 {
+		fmt.Fprint(sys.Err(), scpUsage)
+		return 1
+	}
+
+	sources, dest := operands[:len(operands)-1], operands[len(operands)-1]
+	sys.Log().WithFields(log.Fields{"sources": sources, "target": dest}).
+		Infof("User copying %v to %v with scp", strings.Join(sources, " "), dest)
+
+	// Connect once for each host, like scp running a ssh for each
+	conns := map[string]*sshConn{}
+	connect := func(p scpPath) *sshConn {
+		key := p.login + "@" + p.host
+		if conn, ok := conns[key]; ok {
+			return conn
+		}
+		conn := proto
+		conn.options = proto.options
+		conn.login, conn.host = p.login, p.host
+		if !conn.connect(sys, log.Fields{"command": "scp " + strings.Join(args, " ")}) {
+			fmt.Fprintln(sys.Err(), "lost connection")
+			conns[key] = nil
+			return nil
+		}
+		conns[key] = &conn
+		return &conn
+	}
+	// fsOf returns the filesystem and the absolute path of the operand
+	fsOf := func(p scpPath) (afero.Fs, string, bool) {
+		if p.host == "" {
+			return sys.FSys(), absPath(sys, p.path), true
+		}
+		conn := connect(p)
+		if conn == nil {
+			return nil, "", false
+		}
+		fs, name := conn.remoteFs(sys, p.path)
+		return fs, name, true
+	}
+
+	target := parseScpPath(dest)
+	status := 0
+	for _, op := range sources {
+		src := parseScpPath(op)
+		srcFs, srcName, ok := fsOf(src)
+		if !ok {
+			status = 1
+			continue
+		}
+		dstFs, dstName, ok := fsOf(target)
+		if !ok {
+			return 1
+		}
+		fi, err := srcFs.Stat(srcName)
+		switch {
+		case err != nil:
+			fmt.Fprintf(sys.Err(), "scp: %v: No such file or directory\n", src.path)
+			status = 1
+			continue
+		case fi.IsDir() && !recursive:
+			fmt.Fprintf(sys.Err(), "scp: %v: not a regular file\n", src.path)
+			status = 1
+			continue
+		}
+		if dfi, err := dstFs.Stat(dstName); err == nil && dfi.IsDir() {
+			dstName = pathlib.Join(dstName, pathlib.Base(srcName))
+		}
+		logger := sys.Log().WithFields(log.Fields{"source": op, "target": dest})
+		switch {
+		case target.host != "":
+			logger.Infof("User uploading %v to %v with scp", op, target.host)
+		case src.host != "":
+			logger.Infof("User downloading %v from %v with scp", src.path, src.host)
+		}
+		if !s.copy(sys, srcFs, srcName, dstFs, dstName, src.host == "" && target.host != "") {
+			status = 1
+		}
+	}
+	return status
+}
+
+// copy copies the file or directory tree, showing the progress like scp.
+// Files uploaded are captured as artifact
+func (scpClient) copy(sys honeyos.Sys, srcFs afero.Fs, src string, dstFs afero.Fs, dst string, upload bool) bool {
+	ok := true
+	afero.Walk(srcFs, src, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		name := pathlib.Join(dst, strings.TrimPrefix(p, src))
+		if fi.IsDir() {
+			dstFs.MkdirAll(name, fi.Mode().Perm())
+			return nil
+		}
+		data, err := afero.ReadFile(srcFs, p)
+		if err != nil {
+			fmt.Fprintf(sys.Err(), "scp: %v: Permission denied\n", p)
+			ok = false
+			return nil
+		}
+		if err := afero.WriteFile(dstFs, name, data, fi.Mode().Perm()); err != nil {
+			fmt.Fprintf(sys.Err(), "scp: %v: Permission denied\n", name)
+			ok = false
+			return nil
+		}
+		if upload {
+			honeyos.SaveArtifact(sys, data, "scp "+p)
+		}
+		if honeyos.IsTerminal(sys.Out()) {
+			width := sys.Width() - 36
+			if width < 10 {
+				width = 10
+			}
+			fmt.Fprintf(sys.Out(), "%-*.*s 100%% %7s %7s/s   00:00    \n", width, width, pathlib.Base(p),
+				scpSize(int64(len(data))), scpSize(int64(len(data))))
+		}
+		return nil
+	})
+	return ok
+}
+
+// remoteFs returns the filesystem and the absolute path of the file on the
+// host logged in, relative to the home of the user
+func (c *sshConn) remoteFs(sys honeyos.Sys, name string) (afero.Fs, string) {
+	if !pathlib.IsAbs(name) {
+		name = pathlib.Join(c.user.Homedir, name)
+	}
+	if c.user.UID == 0 {
+		return honeyos.SetUIDFs(sys.FSys()), name
+	}
+	return sys.FSys(), name
+}
+
+// parseScpPath splits [user@]host:path. The colon makes the path remote,
+// unless there is a slash before it
+func parseScpPath(arg string) scpPath {
+	colon := strings.IndexByte(arg, ':')
+	if colon <= 0 || strings.Contains(arg[:colon], "/") {
+		return scpPath{path: arg}
+	}
+	p := scpPath{host: arg[:colon], path: arg[colon+1:]}
+	if at := strings.LastIndexByte(p.host, '@'); at >= 0 {
+		p.login, p.host = p.host[:at], p.host[at+1:]
+	}
+	return p
+}
+
+// scpSize formats the size like the progress meter of scp
+func scpSize(n int64) string {
+	if n < 1024*1024 {
+		if n < 1024 {
+			return fmt.Sprint(n)
+		}
+		return fmt.Sprintf("%.1fKB", float64(n)/1024)
+	}
+	return fmt.Sprintf("%.1fMB", float64(n)/1024/1024)
+}
@@ -0,0 +1,406 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+)
+
+type screen struct{}
+
+// screenOptions are the options of screen
+type screenOptions struct {
+	detach, detached, resume, create, multi, list bool
+	// command is set by -X, sending the arguments to the session as screen
+	// command
+	command bool
+	name    string
+	prefix  rune
+	// server is the command line of the server process, which is screen
+	// with the arguments given
+	server string
+}
+
+// screenVersions are the versions of screen shipped by the distributions
+var screenVersions = map[string]string{
+	"ubuntu": "4.03.01 (GNU) 28-Jun-15",
+	"debian": "4.05.00 (GNU) 10-Dec-16",
+	"centos": "4.01.00devel (GNU) 2-May-06",
+	"alpine": "4.08.00 (GNU) 05-Feb-20",
+}
+
+func init() {
+	honeyos.RegisterCommand("screen", screen{})
+}
+
+func (screen) GetHelp() string {
+	return `Use: screen [-opts] [cmd [args]]
+ or: screen -r [host.tty]
+
+Options:
+-4            Resolve hostnames only to IPv4 addresses.
+-6            Resolve hostnames only to IPv6 addresses.
+-a            Force all capabilities into each window's termcap.
+-A -[r|R]     Adapt all windows to the new display width & height.
+-c file       Read configuration file instead of '.screenrc'.
+-d (-r)       Detach the elsewhere running screen (and reattach here).
+-dmS name     Start as daemon: Screen session in detached mode.
+-D (-r)       Detach and logout remote (and reattach here).
+-D -RR        Do whatever is needed to get a screen session.
+-e xy         Change command characters.
+-f            Flow control on, -fn = off, -fa = auto.
+-h lines      Set the size of the scrollback history buffer.
+-i            Interrupt output sooner when flow control is on.
+-l            Login mode on (update /var/run/utmp), -ln = off.
+-ls [match]   or
+-list         Do nothing, just list our SockDir [on possible matches].
+-L            Turn on output logging.
+-m            ignore $STY variable, do create a new screen session.
+-O            Choose optimal output rather than exact vt100 emulation.
+-p window     Preselect the named window if it exists.
+-q            Quiet startup. Exits with non-zero return code if unsuccessful.
+-Q            Commands will send the response to the stdout of the querying process.
+-r [session]  Reattach to a detached screen process.
+-R            Reattach if possible, otherwise start a new session.
+-s shell      Shell to execute rather than $SHELL.
+-S sockname   Name this session <pid>.sockname instead of <pid>.<tty>.<host>.
+-t title      Set title. (window's name).
+-T term       Use term as $TERM for windows, rather than "screen".
+-U            Tell screen to use UTF-8 encoding.
+-v            Print "Screen version 4.03.01 (GNU) 28-Jun-15".
+-wipe [match] Do nothing, just clean up SockDir [on possible matches].
+-x            Attach to a not detached screen. (Multi display mode).
+-X            Execute <cmd> as a screen command in the specified session.
+`
+}
+
+func (screen) Where() string {
+	return "/usr/bin/screen"
+}
+
+func (s screen) Exec(args []string, sys honeyos.Sys) int {
+	opt := screenOptions{prefix: 1, server: strings.Join(append([]string{"SCREEN"}, args...), " ")}
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && len(args[0]) > 1 {
+		arg := args[0]
+		args = args[1:]
+		switch arg {
+		case "-ls", "-list", "-wipe":
+			opt.list = true
+			continue
+		case "--help", "-help":
+			fmt.Fprint(sys.Out(), s.GetHelp())
+			return 0
+		case "--version":
+			arg = "-v"
+		case "--":
+			arg = ""
+		}
+		for i := 1; i < len(arg); i++ {
+			c := arg[i]
+			switch c {
+			case 'd', 'D':
+				opt.detach = true
+			case 'm':
+				opt.detached = true
+			case 'r':
+				opt.resume = true
+			case 'R':
+				opt.resume, opt.create = true, true
+			case 'x':
+				opt.resume, opt.multi = true, true
+			case 'X':
+				opt.command = true
+			case 'v':
+				v := screenVersions["ubuntu"]
+				if found, ok := screenVersions[honeyos.Distro()]; ok {
+					v = found
+				}
+				fmt.Fprintf(sys.Out(), "Screen version %v\n", v)
+				return 0
+			case '4', '6', 'a', 'A', 'f', 'i', 'l', 'n', 'L', 'O', 'q', 'Q', 'U':
+			case 'S', 'c', 'e', 'h', 'p', 's', 't', 'T':
+				value := arg[i+1:]
+				if value == "" {
+					if len(args) == 0 {
+						fmt.Fprintf(sys.Err(), "Error: Option %c requires an argument\n", c)
+						return 1
+					}
+					value, args = args[0], args[1:]
+				}
+				switch c {
+				case 'S':
+					opt.name = value
+				case 'e':
+					if strings.HasPrefix(value, "^") && len(value) > 1 {
+						opt.prefix = rune(strings.ToLower(value[1:2])[0]) & 0x1f
+					}
+				}
+				i = len(arg)
+			default:
+				fmt.Fprintf(sys.Err(), "Error: Unknown option %c\n", c)
+				return 1
+			}
+		}
+	}
+	switch {
+	case opt.list:
+		return s.list(sys, &opt, args)
+	case opt.command:
+		return s.command(sys, &opt, args)
+	case opt.resume:
+		if len(args) > 0 && opt.name == "" {
+			opt.name, args = args[0], args[1:]
+		}
+		return s.resume(sys, &opt, args)
+	case opt.detach && !opt.detached:
+		if len(args) > 0 && opt.name == "" {
+			opt.name = args[0]
+		}
+		return s.detachRemote(sys, &opt)
+	}
+	return s.start(sys, &opt, args)
+}
+
+// socketDir is where screen keeps the sockets of the sessions of the user
+func (screen) socketDir(sys honeyos.Sys) string {
+	user := honeyos.GetUserByID(sys.CurrentUser()).Name
+	if honeyos.Distro() == "centos" {
+		return "/var/run/screen/S-" + user
+	}
+	return "/run/screen/S-" + user
+}
+
+// sessions are the screen sessions matching the name, which is the name
+// given with -S or pid.name, or its prefix
+func (screen) sessions(sys honeyos.Sys, name string) []*honeyos.Multiplexer {
+	var found []*honeyos.Multiplexer
+	for _, m := range honeyos.Multiplexers(sys) {
+		id := strconv.Itoa(m.PID) + "." + m.Name
+		if m.Tool == "screen" && (name == "" || strings.HasPrefix(id, name) || strings.HasPrefix(m.Name, name)) {
+			found = append(found, m)
+		}
+	}
+	return found
+}
+
+// sessionLine is the session as screen -ls shows it
+func (screen) sessionLine(m *honeyos.Multiplexer) string {
+	state := "Detached"
+	if m.Attached {
+		state = "Attached"
+	}
+	return fmt.Sprintf("\t%v.%v\t(%v)\t(%v)\n", m.PID, m.Name, m.Created.Format("01/02/2006 03:04:05 PM"), state)
+}
+
+func (s screen) list(sys honeyos.Sys, opt *screenOptions, args []string) int {
+	match := opt.name
+	if len(args) > 0 {
+		match = args[0]
+	}
+	found := s.sessions(sys, match)
+	dir := s.socketDir(sys)
+	if len(found) == 0 {
+		fmt.Fprintf(sys.Out(), "No Sockets found in %v.\n\n", dir)
+		return 1
+	}
+	if len(found) == 1 {
+		fmt.Fprintln(sys.Out(), "There is a screen on:")
+	} else {
+		fmt.Fprintln(sys.Out(), "There are screens on:")
+	}
+	for _, m := range found {
+		fmt.Fprint(sys.Out(), s.sessionLine(m))
+	}
+	if len(found) == 1 {
+		fmt.Fprintf(sys.Out(), "1 Socket in %v.\n\n", dir)
+	} else {
+		fmt.Fprintf(sys.Out(), "%v Sockets in %v.\n\n", len(found), dir)
+	}
+	return 0
+}
+
+// command runs the screen commands of -X in the session, of which quit and
+// stuff do something here
+func (s screen) command(sys honeyos.Sys, opt *screenOptions, args []string) int {
+	name := opt.name
+	if name == "" {
+		name = honeyos.Getenv(sys, "STY")
+	}
+	found := s.sessions(sys, name)
+	if len(found) == 0 {
+		fmt.Fprintln(sys.Out(), "No screen session found.")
+		return 1
+	}
+	if len(found) > 1 && name == "" {
+		fmt.Fprintln(sys.Out(), "There are several suitable screens on:")
+		for _, m := range found {
+			fmt.Fprint(sys.Out(), s.sessionLine(m))
+		}
+		fmt.Fprintln(sys.Out(), "Use -S to specify a session.")
+		return 1
+	}
+	m := found[0]
+	sys.Log().WithField("session", m.Name).WithField("args", args).Info("User sent screen command")
+	if len(args) == 0 {
+		return 0
+	}
+	switch args[0] {
+	case "quit", "kill":
+		m.Kill()
+	case "stuff":
+		if len(args) > 1 {
+			m.Send(screenUnescape(args[1]))
+		}
+	case "detach":
+		m.Detach()
+	}
+	return 0
+}
+
+// screenUnescape reads the string of stuff like screen does, with ^X for
+// control characters and backslash escapes
+func screenUnescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '^' && i+1 < len(s):
+			i++
+			if s[i] == '?' {
+				b.WriteByte(0x7f)
+			} else {
+				b.WriteByte(s[i] & 0x1f)
+			}
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func (s screen) resume(sys honeyos.Sys, opt *screenOptions, args []string) int {
+	var detached []*honeyos.Multiplexer
+	found := s.sessions(sys, opt.name)
+	for _, m := range found {
+		if !m.Attached || opt.multi {
+			detached = append(detached, m)
+		}
+	}
+	switch {
+	case len(detached) == 1:
+		return s.attach(sys, detached[0], opt.prefix, true)
+	case len(detached) > 1:
+		fmt.Fprintln(sys.Out(), "There are several suitable screens on:")
+		for _, m := range detached {
+			fmt.Fprint(sys.Out(), s.sessionLine(m))
+		}
+		fmt.Fprintln(sys.Out(), `Type "screen [-d] -r [pid.]tty.host" to resume one of them.`)
+		return 1
+	case opt.create:
+		return s.start(sys, opt, args)
+	}
+	if len(found) > 0 {
+		fmt.Fprintln(sys.Out(), "There is a screen on:")
+		for _, m := range found {
+			fmt.Fprint(sys.Out(), s.sessionLine(m))
+		}
+	}
+	if opt.name != "" {
+		fmt.Fprintf(sys.Out(), "There is no screen to be resumed matching %v.\n", opt.name)
+	} else {
+		fmt.Fprintln(sys.Out(), "There is no screen to be resumed.")
+	}
+	return 1
+}
+
+// detachRemote detaches the session attached, which can only be the one the
+// command is typed in
+func (s screen) detachRemote(sys honeyos.Sys, opt *screenOptions) int {
+	for _, m := range s.sessions(sys, opt.name) {
+		if m.Attached {
+			m.Detach()
+			fmt.Fprintf(sys.Out(), "[%v.%v detached.]\n\n", m.PID, m.Name)
+			return 0
+		}
+	}
+	if opt.name != "" {
+		fmt.Fprintf(sys.Out(), "There is no screen to be detached matching %v.\n", opt.name)
+	} else {
+		fmt.Fprintln(sys.Out(), "There is no screen to be detached.")
+	}
+	return 1
+}
+
+// start creates the session, running the command or the shell, and attaches
+// to it unless -dm is given
+func (s screen) start(sys honeyos.Sys, opt *screenOptions, args []string) int {
+	name := opt.name
+	if name == "" {
+		name = "pts-0." + sys.Hostname()
+	}
+	var quoted []string
+	for _, a := range args {
+		quoted = append(quoted, statQuote(a))
+	}
+	cmd := strings.Join(quoted, " ")
+	m := honeyos.NewMultiplexer(sys, "screen", name, opt.server, cmd)
+	if m == nil {
+		return 1
+	}
+	m.Setenv("STY", fmt.Sprintf("%v.%v", m.PID, name))
+	m.Setenv("WINDOW", "0")
+	if opt.detach && opt.detached {
+		m.Run()
+		return 0
+	}
+	if !honeyos.IsTerminal(sys.In()) {
+		m.Kill()
+		fmt.Fprintln(sys.Err(), "Must be connected to a terminal.")
+		return 1
+	}
+	return s.attach(sys, m, opt.prefix, false)
+}
+
+// attach shows the window in the alternate screen, with the output kept
+// while detached when resuming
+func (screen) attach(sys honeyos.Sys, m *honeyos.Multiplexer, prefix rune, resume bool) int {
+	if !honeyos.IsTerminal(sys.In()) {
+		fmt.Fprintln(sys.Err(), "Must be connected to a terminal.")
+		return 1
+	}
+	fmt.Fprint(sys.Out(), "\x1b[?1049h\x1b[H\x1b[2J")
+	if resume {
+		fmt.Fprint(sys.Out(), muxTail(m.Output(), sys.Height()-1))
+	}
+	detached := m.Attach(sys, prefix, sys.Height())
+	fmt.Fprint(sys.Out(), "\x1b[?1049l")
+	if detached {
+		fmt.Fprintf(sys.Out(), "[detached from %v.%v]\n", m.PID, m.Name)
+	} else {
+		fmt.Fprintln(sys.Out(), "[screen is terminating]")
+	}
+	return 0
+}
+
+// muxTail is the last lines of the output, as much as fits in the window
+func muxTail(out string, rows int) string {
+	lines := strings.SplitAfter(out, "\n")
+	if rows > 0 && len(lines) > rows {
+		lines = lines[len(lines)-rows:]
+	}
+	return strings.Join(lines, "")
+}
@@ -0,0 +1,889 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	pathlib "path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+// sed edits the stream with the script of GNU sed. Files edited in place are
+// captured, as attackers use it to change configs like sshd_config
+type sed struct{}
+
+// sedAddr selects lines by number, the last line with $ or regexp
+type sedAddr struct {
+	line int
+	last bool
+	re   *regexp.Regexp
+}
+
+// sedCmd is a command of the script, with the addresses selecting the lines
+// it applies to
+type sedCmd struct {
+	addr1, addr2 *sedAddr
+	negate       bool
+	// active is set while inside the range of addr1,addr2
+	active bool
+	name   byte
+	// s and y
+	re      *regexp.Regexp
+	repl    string
+	global  bool
+	nth     int
+	print   bool
+	from    []rune
+	to      []rune
+	text    string
+	wfile   string
+	label   string
+	exitVal int
+	// end is the index of the matching } of {, and where b and t jump to
+	end int
+}
+
+// sedState is the state of processing the input, which is all the files or
+// each file with -i and -s
+type sedState struct {
+	lines   []string
+	pos     int
+	lineNo  int
+	out     io.Writer
+	quit    bool
+	exitVal int
+	// noEOL tells if the last line of input has no newline
+	noEOL bool
+}
+
+type sedError struct {
+	expr, char int
+	msg        string
+}
+
+func (e sedError) Error() string {
+	return fmt.Sprintf("-e expression #%v, char %v: %v", e.expr, e.char, e.msg)
+}
+
+const sedUsage = `Usage: sed [OPTION]... {script-only-if-no-other-script} [input-file]...
+
+  -n, --quiet, --silent
+                 suppress automatic printing of pattern space
+  -e script, --expression=script
+                 add the script to the commands to be executed
+  -f script-file, --file=script-file
+                 add the contents of script-file to the commands to be executed
+  -i[SUFFIX], --in-place[=SUFFIX]
+                 edit files in place (makes backup if SUFFIX supplied)
+  -E, -r, --regexp-extended
+                 use extended regular expressions in the script
+  -s, --separate
+                 consider files as separate rather than as a single
+                 continuous long stream.
+`
+
+func init() {
+	honeyos.RegisterCommand("sed", sed{})
+}
+
+func (sed) GetHelp() string {
+	return sedUsage
+}
+
+func (sed) Where() string {
+	return "/bin/sed"
+}
+
+func (s sed) Exec(args []string, sys honeyos.Sys) int {
+	quiet, extended, separate, inPlace := false, false, false, false
+	suffix := ""
+	var scripts []string
+	var files []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			files = append(files, args[i+1:]...)
+			i = len(args)
+			continue
+		case arg == "-" || !strings.HasPrefix(arg, "-"):
+			files = append(files, arg)
+			continue
+		case strings.HasPrefix(arg, "--"):
+			opt := strings.SplitN(arg[2:], "=", 2)
+			switch opt[0] {
+			case "quiet", "silent":
+				quiet = true
+			case "regexp-extended":
+				extended = true
+			case "separate":
+				separate = true
+			case "in-place":
+				inPlace = true
+				if len(opt) > 1 {
+					suffix = opt[1]
+				}
+			case "expression", "file":
+				val := ""
+				if len(opt) > 1 {
+					val = opt[1]
+				} else if i+1 < len(args) {
+					i++
+					val = args[i]
+				} else {
+					fmt.Fprintf(sys.Err(), "sed: option '--%v' requires an argument\n%v", opt[0], sedUsage)
+					return 1
+				}
+				if opt[0] == "file" {
+					data, err := readFile(sys, absPath(sys, val))
+					if err != nil {
+						fmt.Fprintf(sys.Err(), "sed: couldn't open file %v: No such file or directory\n", val)
+						return 1
+					}
+					val = strings.TrimSuffix(string(data), "\n")
+				}
+				scripts = append(scripts, val)
+			case "help":
+				fmt.Fprint(sys.Out(), sedUsage)
+				return 0
+			case "version":
+				fmt.Fprintln(sys.Out(), "sed (GNU sed) 4.2.2")
+				return 0
+			case "posix", "debug", "sandbox", "follow-symlinks", "unbuffered", "null-data", "zero-terminated":
+			default:
+				fmt.Fprintf(sys.Err(), "sed: unrecognized option '%v'\n%v", arg, sedUsage)
+				return 1
+			}
+			continue
+		}
+		for j := 1; j < len(arg); j++ {
+			c := arg[j]
+			switch c {
+			case 'n':
+				quiet = true
+			case 'E', 'r':
+				extended = true
+			case 's':
+				separate = true
+			case 'u', 'z':
+			case 'i':
+				// Suffix of backup is the rest of the argument
+				inPlace, suffix = true, arg[j+1:]
+				j = len(arg)
+			case 'e', 'f':
+				val := arg[j+1:]
+				if val == "" {
+					if i+1 >= len(args) {
+						fmt.Fprintf(sys.Err(), "sed: option requires an argument -- '%c'\n%v", c, sedUsage)
+						return 1
+					}
+					i++
+					val = args[i]
+				}
+				if c == 'f' {
+					data, err := readFile(sys, absPath(sys, val))
+					if err != nil {
+						fmt.Fprintf(sys.Err(), "sed: couldn't open file %v: No such file or directory\n", val)
+						return 1
+					}
+					val = strings.TrimSuffix(string(data), "\n")
+				}
+				scripts = append(scripts, val)
+				j = len(arg)
+			default:
+				fmt.Fprintf(sys.Err(), "sed: invalid option -- '%c'\n%v", c, sedUsage)
+				return 1
+			}
+		}
+	}
+	if len(scripts) == 0 {
+		if len(files) == 0 {
+			fmt.Fprint(sys.Err(), sedUsage)
+			return 1
+		}
+		scripts, files = files[:1], files[1:]
+	}
+	script := strings.Join(scripts, "\n")
+	cmds, err := sedParse(scripts, extended)
+	if err != nil {
+		fmt.Fprintf(sys.Err(), "sed: %v\n", err)
+		return 1
+	}
+	logger := sys.Log().WithFields(log.Fields{"script": script, "files": files})
+	if inPlace {
+		if len(files) == 0 {
+			fmt.Fprintln(sys.Err(), "sed: no input files")
+			return 4
+		}
+		logger.Infof("User editing %v in place with sed", strings.Join(files, " "))
+	}
+
+	status := 0
+	if inPlace || separate {
+		for _, name := range files {
+			if name == "-" && inPlace {
+				fmt.Fprintln(sys.Err(), "sed: couldn't edit -: not a regular file")
+				status = 4
+				continue
+			}
+			lines, noEOL, ok := sedRead(sys, []string{name})
+			if !ok {
+				status = 2
+				continue
+			}
+			var b strings.Builder
+			st := &sedState{lines: lines, noEOL: noEOL, out: sys.Out()}
+			if inPlace {
+				st.out = &b
+			}
+			s.run(sys, cmds, st, quiet)
+			if inPlace {
+				p := absPath(sys, name)
+				fi, _ := sys.FSys().Stat(p)
+				if suffix != "" {
+					backup := strings.Replace(suffix, "*", pathlib.Base(p), -1)
+					if !strings.Contains(suffix, "*") {
+						backup = pathlib.Base(p) + suffix
+					}
+					old, _ := readFile(sys, p)
+					afero.WriteFile(sys.FSys(), pathlib.Join(pathlib.Dir(p), backup), old, fi.Mode().Perm())
+				}
+				data := []byte(b.String())
+				if err := afero.WriteFile(sys.FSys(), p, data, fi.Mode().Perm()); err != nil {
+					tmp := make([]byte, 6)
+					for i := range tmp {
+						tmp[i] = cryptAlphabet[2+rand.Intn(len(cryptAlphabet)-2)]
+					}
+					fmt.Fprintf(sys.Err(), "sed: couldn't open temporary file %v/sed%s: Permission denied\n", pathlib.Dir(p), tmp)
+					status = 4
+					continue
+				}
+				logger.WithField("file", p).Infof("User edited %v with sed", p)
+				honeyos.SaveArtifact(sys, data, "sed "+p)
+			}
+			if st.quit {
+				return st.exitVal
+			}
+		}
+		return status
+	}
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	lines, noEOL, ok := sedRead(sys, files)
+	if !ok {
+		status = 2
+	}
+	st := &sedState{lines: lines, noEOL: noEOL, out: sys.Out()}
+	s.run(sys, cmds, st, quiet)
+	if st.quit {
+		return st.exitVal
+	}
+	return status
+}
+
+// sedRead reads the lines of the files as one stream
+func sedRead(sys honeyos.Sys, files []string) (lines []string, noEOL, ok bool) {
+	ok = true
+	for _, name := range files {
+		var data []byte
+		var err error
+		if name == "-" {
+			data, err = ioutil.ReadAll(sys.In())
+		} else {
+			p := absPath(sys, name)
+			if fi, statErr := sys.FSys().Stat(p); statErr == nil && fi.IsDir() {
+				fmt.Fprintf(sys.Err(), "sed: couldn't edit %v: not a regular file\n", name)
+				ok = false
+				continue
+			}
+			data, err = readFile(sys, p)
+		}
+		if err != nil {
+			if os.IsPermission(err) {
+				fmt.Fprintf(sys.Err(), "sed: can't read %v: Permission denied\n", name)
+			} else {
+				fmt.Fprintf(sys.Err(), "sed: can't read %v: No such file or directory\n", name)
+			}
+			ok = false
+			continue
+		}
+		if len(data) == 0 {
+			continue
+		}
+		text := string(data)
+		noEOL = !strings.HasSuffix(text, "\n")
+		lines = append(lines, strings.Split(strings.TrimSuffix(text, "\n"), "\n")...)
+	}
+	return
+}
+
+// run executes the script on each line of the input
+func (sed) run(sys honeyos.Sys, cmds []*sedCmd, st *sedState, quiet bool) {
+	var hold string
+	emit := func(s string) {
+		io.WriteString(st.out, s)
+		if st.pos < len(st.lines) || !st.noEOL {
+			io.WriteString(st.out, "\n")
+		}
+	}
+	for st.pos < len(st.lines) && !st.quit && sys.Context().Err() == nil {
+		ps := st.lines[st.pos]
+		st.pos++
+		st.lineNo++
+		var appended []string
+		deleted, substituted := false, false
+	cycle:
+		for pc := 0; pc < len(cmds); pc++ {
+			c := cmds[pc]
+			if !c.selects(st, ps) {
+				if c.name == '{' {
+					pc = c.end
+				}
+				continue
+			}
+			switch c.name {
+			case '{', '}', ':':
+			case 's':
+				var n bool
+				if ps, n = c.substitute(ps); n {
+					substituted = true
+					if c.print {
+						emit(ps)
+					}
+					if c.wfile != "" {
+						sedWrite(sys, c.wfile, ps)
+					}
+				}
+			case 'y':
+				var b strings.Builder
+				for _, r := range ps {
+					for i, f := range c.from {
+						if r == f {
+							r = c.to[i]
+							break
+						}
+					}
+					b.WriteRune(r)
+				}
+				ps = b.String()
+			case 'd':
+				deleted = true
+				break cycle
+			case 'D':
+				if i := strings.IndexByte(ps, '\n'); i >= 0 {
+					st.pos--
+					st.lineNo--
+					st.lines[st.pos] = ps[i+1:]
+				}
+				deleted = true
+				break cycle
+			case 'p':
+				emit(ps)
+			case 'P':
+				emit(strings.SplitN(ps, "\n", 2)[0])
+			case 'n':
+				if st.pos >= len(st.lines) {
+					break cycle
+				}
+				if !quiet {
+					emit(ps)
+				}
+				ps = st.lines[st.pos]
+				st.pos++
+				st.lineNo++
+			case 'N':
+				if st.pos >= len(st.lines) {
+					break cycle
+				}
+				ps += "\n" + st.lines[st.pos]
+				st.pos++
+				st.lineNo++
+			case 'q':
+				st.quit, st.exitVal = true, c.exitVal
+				break cycle
+			case 'Q':
+				st.quit, st.exitVal = true, c.exitVal
+				return
+			case '=':
+				fmt.Fprintln(st.out, st.lineNo)
+			case 'a':
+				appended = append(appended, c.text)
+			case 'i':
+				fmt.Fprintln(st.out, c.text)
+			case 'c':
+				// In a range the text replaces the whole range
+				if c.addr2 == nil || !c.active {
+					fmt.Fprintln(st.out, c.text)
+				}
+				deleted = true
+				break cycle
+			case 'r':
+				if data, err := readFile(sys, absPath(sys, c.text)); err == nil {
+					appended = append(appended, strings.TrimSuffix(string(data), "\n"))
+				}
+			case 'w':
+				sedWrite(sys, c.wfile, ps)
+			case 'h':
+				hold = ps
+			case 'H':
+				hold += "\n" + ps
+			case 'g':
+				ps = hold
+			case 'G':
+				ps += "\n" + hold
+			case 'x':
+				ps, hold = hold, ps
+			case 'l':
+				fmt.Fprintln(st.out, strings.Trim(strconv.QuoteToASCII(ps), `"`)+"$")
+			case 'b', 't', 'T':
+				if c.name == 'b' || c.name == 't' && substituted || c.name == 'T' && !substituted {
+					if c.name != 'b' {
+						substituted = false
+					}
+					pc = c.end
+				}
+			}
+		}
+		if !deleted && !quiet {
+			emit(ps)
+		}
+		for _, text := range appended {
+			fmt.Fprintln(st.out, text)
+		}
+	}
+}
+
+// selects tells if the command applies to the line
+func (c *sedCmd) selects(st *sedState, ps string) bool {
+	match := func(a *sedAddr) bool {
+		switch {
+		case a.last:
+			return st.pos >= len(st.lines)
+		case a.re != nil:
+			return a.re.MatchString(ps)
+		}
+		return a.line == st.lineNo
+	}
+	var ok bool
+	switch {
+	case c.addr1 == nil:
+		ok = true
+	case c.addr2 == nil:
+		ok = match(c.addr1)
+	case c.active:
+		ok = true
+		if match(c.addr2) || c.addr2.line > 0 && st.lineNo >= c.addr2.line {
+			c.active = false
+		}
+	case match(c.addr1):
+		ok = true
+		// The range ends right away if the line number is already past
+		c.active = !(c.addr2.line > 0 && c.addr2.line <= st.lineNo) && !(c.addr2.last && st.pos >= len(st.lines))
+	}
+	return ok != c.negate
+}
+
+// substitute replaces the matches of the s command in the pattern space
+func (c *sedCmd) substitute(ps string) (string, bool) {
+	matches := c.re.FindAllStringSubmatchIndex(ps, -1)
+	var b strings.Builder
+	last, replaced := 0, false
+	for i, m := range matches {
+		n := i + 1
+		if n < c.nth || n > c.nth && !c.global {
+			continue
+		}
+		b.WriteString(ps[last:m[0]])
+		for j := 0; j < len(c.repl); j++ {
+			ch := c.repl[j]
+			switch {
+			case ch == '&':
+				b.WriteString(ps[m[0]:m[1]])
+			case ch == '\\' && j+1 < len(c.repl):
+				j++
+				switch d := c.repl[j]; {
+				case d >= '0' && d <= '9':
+					if g := int(d - '0'); 2*g+1 < len(m) && m[2*g] >= 0 {
+						b.WriteString(ps[m[2*g]:m[2*g+1]])
+					}
+				case d == 'n':
+					b.WriteByte('\n')
+				case d == 't':
+					b.WriteByte('\t')
+				default:
+					b.WriteByte(d)
+				}
+			default:
+				b.WriteByte(ch)
+			}
+		}
+		last, replaced = m[1], true
+	}
+	if !replaced {
+		return ps, false
+	}
+	b.WriteString(ps[last:])
+	return b.String(), true
+}
+
+// sedWrite appends the line to the file of w command
+func sedWrite(sys honeyos.Sys, name, line string) {
+	p := absPath(sys, name)
+	if name == "/dev/stdout" {
+		fmt.Fprintln(sys.Out(), line)
+		return
+	}
+	f, err := sys.FSys().OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666&^sys.Umask())
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	io.WriteString(f, line+"\n")
+}
+
+// sedParse compiles the scripts given by -e and -f, which are joined as
+// lines of one script
+func sedParse(scripts []string, extended bool) ([]*sedCmd, error) {
+	var cmds []*sedCmd
+	var blocks []int
+	var lastRE *regexp.Regexp
+	for n, script := range scripts {
+		p := &sedParser{s: script, expr: n + 1, extended: extended, lastRE: lastRE}
+		for {
+			p.skip(" \t\n;")
+			if p.eof() {
+				break
+			}
+			c, err := p.command()
+			if err != nil {
+				return nil, err
+			}
+			switch c.name {
+			case '{':
+				blocks = append(blocks, len(cmds))
+			case '}':
+				if len(blocks) == 0 {
+					return nil, p.errorf("unexpected `}'")
+				}
+				cmds[blocks[len(blocks)-1]].end = len(cmds)
+				blocks = blocks[:len(blocks)-1]
+			}
+			cmds = append(cmds, c)
+		}
+		lastRE = p.lastRE
+	}
+	if len(blocks) > 0 {
+		return nil, sedError{1, 0, "unmatched `{'"}
+	}
+	// Branches jump to the label, or the end of script without label
+	for _, c := range cmds {
+		if c.name != 'b' && c.name != 't' && c.name != 'T' {
+			continue
+		}
+		c.end = len(cmds)
+		if c.label == "" {
+			continue
+		}
+		found := false
+		for i, l := range cmds {
+			if l.name == ':' && l.label == c.label {
+				c.end, found = i, true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("-e expression #1, char 0: can't find label for jump to `%v'", c.label)
+		}
+	}
+	return cmds, nil
+}
+
+type sedParser struct {
+	s        string
+	i        int
+	expr     int
+	extended bool
+	lastRE   *regexp.Regexp
+}
+
+func (p *sedParser) eof() bool { return p.i >= len(p.s) }
+
+func (p *sedParser) errorf(format string, a ...interface{}) error {
+	return sedError{p.expr, p.i, fmt.Sprintf(format, a...)}
+}
+
+func (p *sedParser) skip(chars string) {
+	for !p.eof() && strings.IndexByte(chars, p.s[p.i]) >= 0 {
+		p.i++
+	}
+}
+
+// delimited reads up to the unescaped delimiter, turning \delim into delim
+func (p *sedParser) delimited(delim byte, keepEscapes bool) (string, bool) {
+	var b strings.Builder
+	for !p.eof() {
+		c := p.s[p.i]
+		p.i++
+		switch {
+		case c == delim:
+			return b.String(), true
+		case c == '\\' && !p.eof():
+			n := p.s[p.i]
+			p.i++
+			switch {
+			case n == delim && delim != '&':
+				b.WriteByte(n)
+			case n == 'n' && !keepEscapes:
+				b.WriteByte('\n')
+			default:
+				b.WriteString("\\" + string(n))
+			}
+		case c == '\n' && !keepEscapes:
+			return b.String(), false
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), false
+}
+
+func (p *sedParser) regexp(pattern string, flags string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		if p.lastRE == nil {
+			return nil, p.errorf("no previous regular expression")
+		}
+		return p.lastRE, nil
+	}
+	expr := grepConvert(pattern, p.extended)
+	re, err := regexp.Compile(flags + expr)
+	if err != nil {
+		return nil, p.errorf("Invalid preceding regular expression")
+	}
+	p.lastRE = re
+	return re, nil
+}
+
+func (p *sedParser) address() (*sedAddr, error) {
+	c := p.s[p.i]
+	switch {
+	case c >= '0' && c <= '9':
+		start := p.i
+		for !p.eof() && p.s[p.i] >= '0' && p.s[p.i] <= '9' {
+			p.i++
+		}
+		n, _ := strconv.Atoi(p.s[start:p.i])
+		if n == 0 {
+			return nil, p.errorf("invalid usage of line address 0")
+		}
+		return &sedAddr{line: n}, nil
+	case c == '$':
+		p.i++
+		return &sedAddr{last: true}, nil
+	case c == '/' || c == '\\':
+		delim := byte('/')
+		if c == '\\' {
+			p.i++
+			if p.eof() {
+				return nil, p.errorf("unexpected `,'")
+			}
+			delim = p.s[p.i]
+		}
+		p.i++
+		pattern, ok := p.delimited(delim, true)
+		if !ok {
+			return nil, p.errorf("unterminated address regex")
+		}
+		flags := ""
+		for !p.eof() && (p.s[p.i] == 'I' || p.s[p.i] == 'M') {
+			flags += map[byte]string{'I': "(?i)", 'M': "(?m)"}[p.s[p.i]]
+			p.i++
+		}
+		re, err := p.regexp(pattern, flags)
+		if err != nil {
+			return nil, err
+		}
+		return &sedAddr{re: re}, nil
+	}
+	return nil, nil
+}
+
+// text reads the text of a, i and c up to the end of line. The one-liner
+// form like a foo is supported as well as a\ followed by the text
+func (p *sedParser) text() string {
+	p.skip(" \t")
+	if !p.eof() && p.s[p.i] == '\\' {
+		p.i++
+		if !p.eof() && p.s[p.i] == '\n' {
+			p.i++
+		}
+	}
+	var b strings.Builder
+	for !p.eof() && p.s[p.i] != '\n' {
+		if p.s[p.i] == '\\' && p.i+1 < len(p.s) {
+			p.i++
+		}
+		b.WriteByte(p.s[p.i])
+		p.i++
+	}
+	return b.String()
+}
+
+// word reads the label or file name up to the end of command
+func (p *sedParser) word(stop string) string {
+	p.skip(" \t")
+	start := p.i
+	for !p.eof() && strings.IndexByte(stop, p.s[p.i]) < 0 {
+		p.i++
+	}
+	return strings.TrimSpace(p.s[start:p.i])
+}
+
+func (p *sedParser) command() (*sedCmd, error) {
+	c := &sedCmd{}
+	var err error
+	if c.addr1, err = p.address(); err != nil {
+		return nil, err
+	}
+	if c.addr1 != nil && !p.eof() && p.s[p.i] == ',' {
+		p.i++
+		if p.eof() {
+			return nil, p.errorf("unexpected `,'")
+		}
+		if c.addr2, err = p.address(); err != nil {
+			return nil, err
+		}
+		if c.addr2 == nil {
+			return nil, p.errorf("unexpected `,'")
+		}
+	}
+	p.skip(" \t")
+	for !p.eof() && p.s[p.i] == '!' {
+		c.negate = true
+		p.i++
+		p.skip(" \t")
+	}
+	if p.eof() {
+		return nil, p.errorf("missing command")
+	}
+	c.name = p.s[p.i]
+	p.i++
+	switch c.name {
+	case '{', '=', 'd', 'D', 'g', 'G', 'h', 'H', 'l', 'n', 'N', 'p', 'P', 'x', 'F', 'z':
+	case '}':
+		if c.addr1 != nil {
+			return nil, p.errorf("} doesn't want any addresses")
+		}
+	case 'q', 'Q':
+		p.skip(" \t")
+		start := p.i
+		for !p.eof() && p.s[p.i] >= '0' && p.s[p.i] <= '9' {
+			p.i++
+		}
+		c.exitVal, _ = strconv.Atoi(p.s[start:p.i])
+	case 'a', 'i', 'c':
+		c.text = p.text()
+		if c.text == "" {
+			return nil, p.errorf("expected \\ after `a', `c' or `i'")
+		}
+	case ':':
+		if c.addr1 != nil {
+			return nil, p.errorf(": doesn't want any addresses")
+		}
+		if c.label = p.word(";\n"); c.label == "" {
+			return nil, p.errorf("\":\" lacks a label")
+		}
+	case 'b', 't', 'T':
+		c.label = p.word(";\n}")
+	case 'r', 'R':
+		c.name = 'r'
+		c.text = p.word("\n")
+	case 'w', 'W':
+		c.name = 'w'
+		c.wfile = p.word("\n")
+	case 's':
+		if p.eof() {
+			return nil, p.errorf("unterminated `s' command")
+		}
+		delim := p.s[p.i]
+		p.i++
+		pattern, ok := p.delimited(delim, true)
+		if !ok {
+			return nil, p.errorf("unterminated `s' command")
+		}
+		if c.repl, ok = p.delimited(delim, false); !ok {
+			return nil, p.errorf("unterminated `s' command")
+		}
+		flags := ""
+	flags:
+		for !p.eof() {
+			f := p.s[p.i]
+			switch {
+			case f == 'g':
+				c.global = true
+			case f == 'p':
+				c.print = true
+			case f == 'i' || f == 'I':
+				flags += "(?i)"
+			case f == 'm' || f == 'M':
+				flags += "(?m)"
+			case f == 'e':
+			case f >= '0' && f <= '9':
+				start := p.i
+				for !p.eof() && p.s[p.i] >= '0' && p.s[p.i] <= '9' {
+					p.i++
+				}
+				c.nth, _ = strconv.Atoi(p.s[start:p.i])
+				if c.nth == 0 {
+					return nil, p.errorf("number option to `s' command may not be zero")
+				}
+				continue
+			case f == 'w':
+				p.i++
+				c.wfile = p.word("\n")
+				break flags
+			case f == ';' || f == '\n' || f == '}' || f == ' ' || f == '\t':
+				break flags
+			default:
+				return nil, p.errorf("unknown option to `s'")
+			}
+			p.i++
+		}
+		if c.nth == 0 {
+			c.nth = 1
+		}
+		if c.re, err = p.regexp(pattern, flags); err != nil {
+			return nil, err
+		}
+	case 'y':
+		if p.eof() {
+			return nil, p.errorf("unterminated `y' command")
+		}
+		delim := p.s[p.i]
+		p.i++
+		from, ok := p.delimited(delim, false)
+		to, ok2 := p.delimited(delim, false)
+		if !ok || !ok2 {
+			return nil, p.errorf("unterminated `y' command")
+		}
+		c.from, c.to = []rune(from), []rune(to)
+		if len(c.from) != len(c.to) {
+			return nil, p.errorf("strings for `y' command are different lengths")
+		}
+	default:
+		return nil, p.errorf("unknown command: `%c'", c.name)
+	}
+	// Only ; } and end of line may follow the command
+	p.skip(" \t")
+	if !p.eof() && strings.IndexByte(";\n}", p.s[p.i]) < 0 && c.name != '{' {
+		return nil, p.errorf("extra characters after command")
+	}
+	return c, nil
+}
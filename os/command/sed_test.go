@@ -0,0 +1,91 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+const sedSSHConfig = "Port 22\n#PermitRootLogin prohibit-password\nPasswordAuthentication no\n"
+
+func TestSed(t *testing.T) {
+	tests := []struct {
+		args   []string
+		stdin  string
+		expect string
+		status int
+	}{
+		{[]string{"s/a/b/"}, "aaa\nxa\n", "baa\nxb\n", 0},
+		{[]string{"s/a/b/g"}, "aaa\n", "bbb\n", 0},
+		{[]string{"s/a/b/2"}, "aaa\n", "aba\n", 0},
+		{[]string{"s|/bin|/usr/bin|"}, "/bin/sh\n", "/usr/bin/sh\n", 0},
+		{[]string{"s/\\(a\\)\\(b\\)/\\2\\1/"}, "abc\n", "bac\n", 0},
+		{[]string{"-E", "s/([0-9]+)/<\\1>/g"}, "a1b22\n", "a<1>b<22>\n", 0},
+		{[]string{"s/[0-9]*/(&)/"}, "12ab\n", "(12)ab\n", 0},
+		{[]string{"s/x/y/I"}, "X\n", "y\n", 0},
+		{[]string{"-n", "s/a/b/p"}, "a\nc\n", "b\n", 0},
+		{[]string{"-e", "s/a/b/", "-e", "s/b/c/"}, "a\n", "c\n", 0},
+		{[]string{"s/a/b/;s/c/d/"}, "ac\n", "bd\n", 0},
+		{[]string{"2d"}, "1\n2\n3\n", "1\n3\n", 0},
+		{[]string{"/^#/d"}, "#x\ny\n", "y\n", 0},
+		{[]string{"-n", "2,3p"}, "1\n2\n3\n4\n", "2\n3\n", 0},
+		{[]string{"-n", "$p"}, "1\n2\n", "2\n", 0},
+		{[]string{"/b/,/d/s/^/-/"}, "a\nb\nc\nd\ne\n", "a\n-b\n-c\n-d\ne\n", 0},
+		{[]string{"2!d"}, "1\n2\n3\n", "2\n", 0},
+		{[]string{"1i\\first"}, "a\n", "first\na\n", 0},
+		{[]string{"$a last"}, "a\n", "a\nlast\n", 0},
+		{[]string{"2c\\two"}, "1\n2\n", "1\ntwo\n", 0},
+		{[]string{"y/abc/xyz/"}, "aabbcc\n", "xxyyzz\n", 0},
+		{[]string{"2q"}, "1\n2\n3\n", "1\n2\n", 0},
+		{[]string{"-n", "/x/{p;p}"}, "x\ny\n", "x\nx\n", 0},
+		{[]string{"="}, "a\nb\n", "1\na\n2\nb\n", 0},
+		{[]string{"s/a/b/"}, "a", "b", 0},
+		{[]string{"-s", "-n", "$p", "/tmp/a", "/tmp/b"}, "", "2\n4\n", 0},
+		{[]string{"-n", "$p", "/tmp/a", "/tmp/b"}, "", "4\n", 0},
+		// Errors
+		{[]string{"s/a/b"}, "", "", 1},
+		{[]string{"k"}, "", "", 1},
+		{[]string{"p", "/nonexistent"}, "", "", 2},
+		{[]string{"-i", "s/a/b/"}, "", "", 4},
+	}
+	for _, test := range tests {
+		sys := newTestSys(test.stdin)
+		afero.WriteFile(sys.FSys(), "/tmp/a", []byte("1\n2\n"), 0644)
+		afero.WriteFile(sys.FSys(), "/tmp/b", []byte("3\n4\n"), 0644)
+		status := sys.run(sed{}, test.args...)
+		if out := sys.out.String(); out != test.expect || status != test.status {
+			t.Errorf("sed %q, expect %q with status %v, got %q with status %v (%q)",
+				test.args, test.expect, test.status, out, status, sys.err.String())
+		}
+	}
+}
+
+func TestSedInPlace(t *testing.T) {
+	tests := []struct {
+		args           []string
+		expect, backup string
+	}{
+		{[]string{"-i", "s/^#PermitRootLogin.*/PermitRootLogin yes/"}, "Port 22\nPermitRootLogin yes\nPasswordAuthentication no\n", ""},
+		{[]string{"-i.bak", "s/no$/yes/"}, "Port 22\n#PermitRootLogin prohibit-password\nPasswordAuthentication yes\n", "sshd_config.bak"},
+		{[]string{"--in-place=old_*", "/^#/d"}, "Port 22\nPasswordAuthentication no\n", "old_sshd_config"},
+		{[]string{"-i", "-e", "1d", "-e", "$a UseDNS no"}, "#PermitRootLogin prohibit-password\nPasswordAuthentication no\nUseDNS no\n", ""},
+	}
+	for _, test := range tests {
+		sys := newTestSys("")
+		sys.FSys().MkdirAll("/etc/ssh", 0755)
+		afero.WriteFile(sys.FSys(), "/etc/ssh/sshd_config", []byte(sedSSHConfig), 0644)
+		args := append(test.args, "/etc/ssh/sshd_config")
+		if status := sys.run(sed{}, args...); status != 0 || sys.out.Len() > 0 {
+			t.Errorf("sed %q, expect no output with status 0, got %q with status %v (%q)", args, sys.out.String(), status, sys.err.String())
+		}
+		if data, _ := afero.ReadFile(sys.FSys(), "/etc/ssh/sshd_config"); string(data) != test.expect {
+			t.Errorf("sed %q, expect the file edited to %q, got %q", args, test.expect, data)
+		}
+		if test.backup == "" {
+			continue
+		}
+		if data, _ := afero.ReadFile(sys.FSys(), "/etc/ssh/"+test.backup); string(data) != sedSSHConfig {
+			t.Errorf("sed %q, expect the backup %v to be the original, got %q", args, test.backup, data)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/pflag"
+)
+
+// setsid runs the command in a new session without the terminal, so it
+// keeps running after the session closes
+type setsid struct{}
+
+func init() {
+	honeyos.RegisterCommand("setsid", setsid{})
+}
+
+func (setsid) GetHelp() string {
+	return `
+Usage:
+ setsid [options] <program> [arguments ...]
+
+Run a program in a new session.
+
+Options:
+ -c, --ctty     set the controlling terminal to the current one
+ -f, --fork     always fork
+ -w, --wait     wait program to exit, and use the same return
+
+ -h, --help     display this help and exit
+ -V, --version  output version information and exit
+
+For more details see setsid(1).
+`
+}
+
+func (setsid) Where() string {
+	return "/usr/bin/setsid"
+}
+
+func (s setsid) Exec(args []string, sys honeyos.Sys) int {
+	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	flag.SetInterspersed(false)
+	flag.BoolP("ctty", "c", false, "")
+	flag.BoolP("fork", "f", false, "")
+	wait := flag.BoolP("wait", "w", false, "")
+	help := flag.BoolP("help", "h", false, "")
+	version := flag.BoolP("version", "V", false, "")
+	if err := flag.Parse(args); err != nil {
+		msg := strings.Replace(err.Error(), "unknown shorthand flag: ", "invalid option -- ", 1)
+		if i := strings.Index(msg, " in -"); i >= 0 {
+			msg = msg[:i]
+		}
+		fmt.Fprintf(sys.Err(), "setsid: %v\n%v", msg, s.GetHelp())
+		return 1
+	}
+	switch {
+	case *help:
+		fmt.Fprint(sys.Out(), s.GetHelp())
+		return 0
+	case *version:
+		fmt.Fprintln(sys.Out(), "setsid from util-linux 2.27.1")
+		return 0
+	case flag.NArg() == 0:
+		fmt.Fprint(sys.Err(), s.GetHelp())
+		return 1
+	}
+	cmd := flag.Args()
+	sys.Log().WithField("cmd", strings.Join(cmd, " ")).Infof("User ran %v with setsid", cmd[0])
+	// Run from the shell setsid is the process group leader, so it forks
+	// and returns at once unless waiting
+	n, found := honeyos.RunAs(sys, honeyos.Credential{UID: sys.CurrentUser(), NoHangup: true, Detach: !*wait}, cmd)
+	if !found {
+		fmt.Fprintf(sys.Err(), "setsid: failed to execute %v: No such file or directory\n", cmd[0])
+		return 1
+	}
+	return n
+}
@@ -0,0 +1,220 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/pflag"
+)
+
+// shutdown is shutdown, reboot, poweroff and halt, the way systemctl runs
+// them. Taking the machine down drops the session
+type shutdown struct {
+	name string
+}
+
+// shutdownScheduled is the shutdown of the machine pending, cancelled by
+// shutdown -c
+var shutdownScheduled struct {
+	sync.Mutex
+	timer *time.Timer
+}
+
+func init() {
+	for _, name := range []string{"shutdown", "reboot", "poweroff", "halt"} {
+		honeyos.RegisterCommand(name, shutdown{name})
+	}
+}
+
+func (s shutdown) GetHelp() string {
+	if s.name == "shutdown" {
+		return `shutdown [OPTIONS...] [TIME] [WALL...]
+
+Shut down the system.
+
+     --help      Show this help
+  -H --halt      Halt the machine
+  -P --poweroff  Power-off the machine
+  -r --reboot    Reboot the machine
+  -h             Equivalent to --poweroff, overridden by --halt
+  -k             Don't halt/power-off/reboot, just send warnings
+     --no-wall   Don't send wall message before halt/power-off/reboot
+  -c             Cancel a pending shutdown
+`
+	}
+	return fmt.Sprintf(`%v [OPTIONS...]
+
+%v the system.
+
+     --help      Show this help
+     --halt      Halt the machine
+  -p --poweroff  Switch off the machine
+     --reboot    Reboot the machine
+  -f --force     Force immediate halt/power-off/reboot
+  -w --wtmp-only Don't halt/power-off/reboot, just write wtmp record
+  -d --no-wtmp   Don't write wtmp record
+     --no-wall   Don't send wall message before halt/power-off/reboot
+`, s.name, map[string]string{"reboot": "Reboot", "poweroff": "Power off", "halt": "Halt"}[s.name])
+}
+
+func (s shutdown) Where() string {
+	if honeyos.Distro() == "centos" {
+		return "/usr/sbin/" + s.name
+	}
+	return "/sbin/" + s.name
+}
+
+func (s shutdown) Exec(args []string, sys honeyos.Sys) int {
+	if s.name == "shutdown" && honeyos.Distro() == "alpine" {
+		// busybox has no shutdown
+		return honeyos.CommandNotFound(sys, append([]string{s.name}, args...))
+	}
+	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	help := flag.Bool("help", false, "")
+	halt := flag.Bool("halt", false, "")
+	poweroff := flag.BoolP("poweroff", "P", false, "")
+	reboot := flag.BoolP("reboot", "r", false, "")
+	noWall := flag.Bool("no-wall", false, "")
+	var warnOnly, cancel, wtmpOnly *bool
+	if s.name == "shutdown" {
+		flag.BoolVarP(halt, "H", "H", false, "")
+		flag.BoolP("h", "h", false, "")
+		warnOnly = flag.BoolP("k", "k", false, "")
+		cancel = flag.BoolP("c", "c", false, "")
+	} else {
+		flag.BoolVarP(poweroff, "p", "p", false, "")
+		flag.BoolP("force", "f", false, "")
+		wtmpOnly = flag.BoolP("wtmp-only", "w", false, "")
+		flag.BoolP("no-wtmp", "d", false, "")
+		flag.BoolP("no-sync", "n", false, "")
+	}
+	if err := flag.Parse(args); err != nil {
+		msg := strings.Replace(err.Error(), "unknown shorthand flag: ", "invalid option -- ", 1)
+		if i := strings.Index(msg, " in -"); i >= 0 {
+			msg = msg[:i]
+		}
+		fmt.Fprintf(sys.Err(), "%v: %v\n", s.name, msg)
+		return 1
+	}
+	if *help {
+		fmt.Fprint(sys.Out(), s.GetHelp())
+		return 0
+	}
+
+	action := map[string]string{"shutdown": "power-off", "reboot": "reboot", "poweroff": "power-off", "halt": "halt"}[s.name]
+	switch {
+	case *reboot:
+		action = "reboot"
+	case *halt:
+		action = "halt"
+	case *poweroff:
+		action = "power-off"
+	}
+	if !isRoot(sys) {
+		sys.Log().WithField("action", action).Warnf("User tried to %v the machine without root", action)
+		target := map[string]string{"power-off": "poweroff", "reboot": "reboot", "halt": "halt"}[action]
+		fmt.Fprint(sys.Err(), "Failed to set wall message, ignoring: Interactive authentication required.\n")
+		if s.name == "shutdown" {
+			fmt.Fprint(sys.Err(), "Failed to call ScheduleShutdown in logind, proceeding with immediate shutdown: Interactive authentication required.\n")
+		} else {
+			fmt.Fprintf(sys.Err(), "Failed to %v system via logind: Interactive authentication required.\n", action)
+		}
+		fmt.Fprintf(sys.Err(), "Failed to start %v.target: Interactive authentication required.\n"+
+			"See system logs and 'systemctl status %v.target' for details.\n"+
+			"Failed to open /dev/initctl: Permission denied\nFailed to talk to init daemon.\n", target, target)
+		return 1
+	}
+	if wtmpOnly != nil && *wtmpOnly {
+		return 0
+	}
+	// goingDown takes the machine down at once, with the last warning
+	goingDown := func() {
+		if !*noWall && honeyos.Distro() != "alpine" {
+			honeyos.Wall(sys, fmt.Sprintf("The system is going down for %v NOW!", action))
+		}
+		honeyos.Shutdown(sys, action)
+	}
+	if s.name != "shutdown" {
+		goingDown()
+		return 0
+	}
+
+	// shutdown takes the time, 1 minute from now by default, and the rest
+	// is the wall message
+	when, wall := "+1", flag.Args()
+	if len(wall) > 0 {
+		when, wall = wall[0], wall[1:]
+	}
+	shutdownScheduled.Lock()
+	defer shutdownScheduled.Unlock()
+	now := honeyos.Now(sys)
+	if *cancel {
+		if shutdownScheduled.timer != nil && shutdownScheduled.timer.Stop() {
+			sys.Log().Info("User cancelled the shutdown scheduled")
+			if !*noWall {
+				honeyos.Wall(sys, fmt.Sprintf("The system shutdown has been cancelled at %v!", now.Format("Mon 2006-01-02 15:04:05 MST")))
+			}
+		}
+		shutdownScheduled.timer = nil
+		return 0
+	}
+	delay, ok := shutdownDelay(when, now)
+	if !ok {
+		fmt.Fprintf(sys.Err(), "Failed to parse time specification: %v\n", when)
+		return 1
+	}
+	if delay == 0 && !*warnOnly {
+		goingDown()
+		return 0
+	}
+	at := now.Add(delay)
+	msg := fmt.Sprintf("The system is going down for %v at %v!", action, at.Format("Mon 2006-01-02 15:04:05 MST"))
+	if len(wall) > 0 {
+		msg = strings.Join(wall, " ") + "\n" + msg
+	}
+	if !*noWall {
+		honeyos.Wall(sys, msg)
+	}
+	if *warnOnly {
+		return 0
+	}
+	sys.Log().WithField("action", action).WithField("at", at.Format(time.RFC3339)).Warnf("User scheduled %v of the machine", action)
+	fmt.Fprintf(sys.Err(), "Shutdown scheduled for %v, use 'shutdown -c' to cancel.\n", at.Format("Mon 2006-01-02 15:04:05 MST"))
+	if shutdownScheduled.timer != nil {
+		shutdownScheduled.timer.Stop()
+	}
+	shutdownScheduled.timer = time.AfterFunc(delay, func() {
+		shutdownScheduled.Lock()
+		shutdownScheduled.timer = nil
+		shutdownScheduled.Unlock()
+		goingDown()
+	})
+	return 0
+}
+
+// shutdownDelay parses the time of shutdown: now, +minutes or hh:mm of the
+// clock, which is tomorrow if already passed
+func shutdownDelay(when string, now time.Time) (time.Duration, bool) {
+	switch {
+	case when == "now":
+		return 0, true
+	case strings.HasPrefix(when, "+"):
+		m, err := strconv.ParseUint(when[1:], 10, 32)
+		return time.Duration(m) * time.Minute, err == nil
+	}
+	t, err := time.ParseInLocation("15:04", when, now.Location())
+	if err != nil {
+		return 0, false
+	}
+	at := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+	if !at.After(now) {
+		at = at.AddDate(0, 0, 1)
+	}
+	return at.Sub(now), true
+}
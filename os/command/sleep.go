@@ -0,0 +1,66 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mkishere/sshsyrup/os"
+)
+
+type sleep struct{}
+
+func init() {
+	os.RegisterCommand("sleep", sleep{})
+}
+
+func (sleep) GetHelp() string {
+	return ""
+}
+
+func (sleep) Exec(args []string, sys os.Sys) int {
+	if len(args) == 0 {
+		fmt.Fprintln(sys.Err(), "sleep: missing operand\nTry 'sleep --help' for more information.")
+		return 1
+	}
+	var total time.Duration
+	for _, arg := range args {
+		d, err := parseSleepDuration(arg)
+		if err != nil {
+			fmt.Fprintf(sys.Err(), "sleep: invalid time interval ‘%v’\nTry 'sleep --help' for more information.\n", arg)
+			return 1
+		}
+		total += d
+	}
+	select {
+	case <-time.After(total):
+		return 0
+	case <-sys.Context().Done():
+		return 130
+	}
+}
+
+func (sleep) Where() string {
+	return "/bin/sleep"
+}
+
+// parseSleepDuration parses number with optional suffix s, m, h or d
+func parseSleepDuration(arg string) (time.Duration, error) {
+	unit := time.Second
+	switch {
+	case strings.HasSuffix(arg, "s"):
+		arg = arg[:len(arg)-1]
+	case strings.HasSuffix(arg, "m"):
+		unit, arg = time.Minute, arg[:len(arg)-1]
+	case strings.HasSuffix(arg, "h"):
+		unit, arg = time.Hour, arg[:len(arg)-1]
+	case strings.HasSuffix(arg, "d"):
+		unit, arg = 24*time.Hour, arg[:len(arg)-1]
+	}
+	n, err := strconv.ParseFloat(arg, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid time interval")
+	}
+	return time.Duration(n * float64(unit)), nil
+}
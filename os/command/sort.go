@@ -0,0 +1,387 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/afero"
+	"github.com/spf13/pflag"
+)
+
+// sort sorts the lines of the files, comparing bytes like the C locale
+type sortCmd struct{}
+
+// sortKey is the part of the line compared, from -k. Fields and characters
+// count from 1, end field 0 is the end of line
+type sortKey struct {
+	startField, startChar, endField, endChar int
+	sortOrder
+}
+
+// sortOrder is how the keys compare, set for all keys by the options or per
+// key by the letters after -k
+type sortOrder struct {
+	numeric, general, human, version, random, fold, blanks, reverse bool
+}
+
+func init() {
+	honeyos.RegisterCommand("sort", sortCmd{})
+}
+
+func (sortCmd) GetHelp() string {
+	return "Usage: sort [OPTION]... [FILE]...\n  or:  sort [OPTION]... --files0-from=F\nTry 'sort --help' for more information.\n"
+}
+
+func (sortCmd) Where() string {
+	return "/usr/bin/sort"
+}
+
+func (s sortCmd) Exec(args []string, sys honeyos.Sys) int {
+	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	var global sortOrder
+	flag.BoolVarP(&global.blanks, "ignore-leading-blanks", "b", false, "")
+	flag.BoolVarP(&global.fold, "ignore-case", "f", false, "")
+	flag.BoolVarP(&global.general, "general-numeric-sort", "g", false, "")
+	flag.BoolVarP(&global.human, "human-numeric-sort", "h", false, "")
+	flag.BoolVarP(&global.numeric, "numeric-sort", "n", false, "")
+	flag.BoolVarP(&global.random, "random-sort", "R", false, "")
+	flag.BoolVarP(&global.reverse, "reverse", "r", false, "")
+	flag.BoolVarP(&global.version, "version-sort", "V", false, "")
+	flag.BoolP("month-sort", "M", false, "")
+	flag.BoolP("dictionary-order", "d", false, "")
+	flag.BoolP("ignore-nonprinting", "i", false, "")
+	check := flag.BoolP("check", "c", false, "")
+	keys := flag.StringArrayP("key", "k", nil, "")
+	output := flag.StringP("output", "o", "", "")
+	stable := flag.BoolP("stable", "s", false, "")
+	sep := flag.StringP("field-separator", "t", "", "")
+	unique := flag.BoolP("unique", "u", false, "")
+	zero := flag.BoolP("zero-terminated", "z", false, "")
+	flag.StringP("buffer-size", "S", "", "")
+	flag.StringP("temporary-directory", "T", "", "")
+	flag.String("parallel", "", "")
+	help := flag.Bool("help", false, "")
+	if err := flag.Parse(args); err != nil {
+		msg := strings.Replace(err.Error(), "unknown shorthand flag: ", "invalid option -- ", 1)
+		if i := strings.Index(msg, " in -"); i >= 0 {
+			msg = msg[:i]
+		}
+		fmt.Fprintf(sys.Err(), "sort: %v\nTry 'sort --help' for more information.\n", msg)
+		return 2
+	}
+	if *help {
+		fmt.Fprint(sys.Out(), s.GetHelp())
+		return 0
+	}
+	if len(*sep) > 1 {
+		if *sep != `\0` {
+			fmt.Fprintf(sys.Err(), "sort: multi-character tab ‘%v’\n", *sep)
+			return 2
+		}
+		*sep = "\x00"
+	}
+	var parsed []sortKey
+	for _, k := range *keys {
+		key, ok := parseSortKey(k, global)
+		if !ok {
+			fmt.Fprintf(sys.Err(), "sort: invalid number at field start: invalid count at start of ‘%v’\n", k)
+			return 2
+		}
+		parsed = append(parsed, key)
+	}
+
+	delim := byte('\n')
+	if *zero {
+		delim = 0
+	}
+	files := flag.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	var lines [][]byte
+	for _, name := range files {
+		data, ok := headOpen(sys, "sort", name)
+		if !ok {
+			return 2
+		}
+		for _, line := range headLines(data, delim) {
+			lines = append(lines, bytes.TrimSuffix(line, []byte{delim}))
+		}
+	}
+
+	seed := rand.Int63()
+	compare := func(a, b []byte) int {
+		if len(parsed) == 0 {
+			if c := global.compare(a, b, seed); c != 0 || global.isDefault() {
+				return c
+			}
+		}
+		for _, k := range parsed {
+			if c := k.compare(k.extract(a, *sep), k.extract(b, *sep), seed); c != 0 {
+				return c
+			}
+		}
+		if *stable || *unique {
+			return 0
+		}
+		// Lines equal by the keys compare as a whole, as the last resort
+		c := bytes.Compare(a, b)
+		if global.reverse {
+			c = -c
+		}
+		return c
+	}
+
+	if *check {
+		for i := 1; i < len(lines); i++ {
+			if c := compare(lines[i-1], lines[i]); c > 0 || *unique && c == 0 {
+				fmt.Fprintf(sys.Err(), "sort: %v:%d: disorder: %s\n", files[0], i+1, lines[i])
+				return 1
+			}
+		}
+		return 0
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return compare(lines[i], lines[j]) < 0 })
+	if *unique {
+		var kept [][]byte
+		for i, line := range lines {
+			if i == 0 || compare(kept[len(kept)-1], line) != 0 {
+				kept = append(kept, line)
+			}
+		}
+		lines = kept
+	}
+
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.Write(line)
+		buf.WriteByte(delim)
+	}
+	if *output != "" {
+		if err := afero.WriteFile(sys.FSys(), absPath(sys, *output), buf.Bytes(), 0644); err != nil {
+			fmt.Fprintf(sys.Err(), "sort: open failed: %v: Permission denied\n", *output)
+			return 2
+		}
+		return 0
+	}
+	sys.Out().Write(buf.Bytes())
+	return 0
+}
+
+// parseSortKey parses the KEYDEF of -k, like 2,2n or 1.3b. Keys without
+// ordering letters take the global ones
+func parseSortKey(def string, global sortOrder) (sortKey, bool) {
+	var k sortKey
+	parts := strings.SplitN(def, ",", 2)
+	order := sortOrder{}
+	pos := func(s string, start bool) (field, char int, ok bool) {
+		i := strings.IndexFunc(s, func(r rune) bool { return !unicode.IsDigit(r) && r != '.' })
+		spec, letters := s, ""
+		if i >= 0 {
+			spec, letters = s[:i], s[i:]
+		}
+		for _, c := range letters {
+			switch c {
+			case 'n':
+				order.numeric = true
+			case 'g':
+				order.general = true
+			case 'h':
+				order.human = true
+			case 'V':
+				order.version = true
+			case 'R':
+				order.random = true
+			case 'f':
+				order.fold = true
+			case 'b':
+				order.blanks = true
+			case 'r':
+				order.reverse = true
+			case 'd', 'i', 'M':
+			default:
+				return 0, 0, false
+			}
+		}
+		fc := strings.SplitN(spec, ".", 2)
+		field, err := strconv.Atoi(fc[0])
+		if err != nil || field < 1 && start {
+			return 0, 0, false
+		}
+		if len(fc) > 1 {
+			if char, err = strconv.Atoi(fc[1]); err != nil {
+				return 0, 0, false
+			}
+		}
+		return field, char, true
+	}
+	var ok bool
+	if k.startField, k.startChar, ok = pos(parts[0], true); !ok {
+		return k, false
+	}
+	if len(parts) > 1 {
+		if k.endField, k.endChar, ok = pos(parts[1], false); !ok {
+			return k, false
+		}
+	}
+	if order == (sortOrder{}) {
+		order = global
+	}
+	k.sortOrder = order
+	return k, true
+}
+
+// extract returns the part of the line the key covers. Without separator,
+// fields start at blanks, which belong to the field that follows
+func (k sortKey) extract(line []byte, sep string) []byte {
+	var starts, ends []int
+	if sep != "" {
+		starts = append(starts, 0)
+		for i, c := range line {
+			if c == sep[0] {
+				ends = append(ends, i)
+				starts = append(starts, i+1)
+			}
+		}
+		ends = append(ends, len(line))
+	} else {
+		for i := 0; i < len(line); {
+			start := i
+			for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+				i++
+			}
+			for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+				i++
+			}
+			starts, ends = append(starts, start), append(ends, i)
+		}
+	}
+	if k.startField > len(starts) {
+		return nil
+	}
+	from := starts[k.startField-1]
+	if k.blanks {
+		for from < ends[k.startField-1] && (line[from] == ' ' || line[from] == '\t') {
+			from++
+		}
+	}
+	if k.startChar > 0 {
+		from = intMin(from+k.startChar-1, len(line))
+	}
+	to := len(line)
+	if k.endField > 0 && k.endField <= len(ends) {
+		to = ends[k.endField-1]
+		if k.endChar > 0 {
+			to = intMin(starts[k.endField-1]+k.endChar, len(line))
+		}
+	}
+	if to < from {
+		return nil
+	}
+	return line[from:to]
+}
+
+func (o sortOrder) isDefault() bool {
+	return !o.numeric && !o.general && !o.human && !o.version && !o.random && !o.fold && !o.blanks
+}
+
+// compare compares the keys by the order, with the random order from the
+// hash of the keys so equal keys stay together
+func (o sortOrder) compare(a, b []byte, seed int64) int {
+	if o.blanks {
+		a, b = bytes.TrimLeft(a, " \t"), bytes.TrimLeft(b, " \t")
+	}
+	c := 0
+	switch {
+	case o.random:
+		ha, hb := fnvString(fmt.Sprint(seed)+string(a)), fnvString(fmt.Sprint(seed)+string(b))
+		switch {
+		case ha < hb:
+			c = -1
+		case ha > hb:
+			c = 1
+		}
+	case o.numeric, o.general, o.human:
+		na, nb := sortNumber(a, o.human), sortNumber(b, o.human)
+		switch {
+		case na < nb:
+			c = -1
+		case na > nb:
+			c = 1
+		}
+	case o.version:
+		c = sortVersion(string(a), string(b))
+	case o.fold:
+		c = bytes.Compare(bytes.ToUpper(a), bytes.ToUpper(b))
+	default:
+		c = bytes.Compare(a, b)
+	}
+	if o.reverse {
+		c = -c
+	}
+	return c
+}
+
+// sortNumber reads the number at the start of the key, 0 if there is none.
+// Human numbers take the suffixes like 2K and 1G
+func sortNumber(s []byte, human bool) float64 {
+	t := strings.TrimLeft(string(s), " \t")
+	end := 0
+	for end < len(t) && (t[end] >= '0' && t[end] <= '9' || t[end] == '.' || end == 0 && t[end] == '-') {
+		end++
+	}
+	n, _ := strconv.ParseFloat(t[:end], 64)
+	if human && end < len(t) {
+		if i := strings.IndexByte("KMGTPEZY", t[end]); i >= 0 {
+			for ; i >= 0; i-- {
+				n *= 1024
+			}
+		}
+	}
+	return n
+}
+
+// sortVersion compares the names with the numbers in them by value, like
+// file-1.2 before file-1.10
+func sortVersion(a, b string) int {
+	for a != "" && b != "" {
+		da, db := a[0] >= '0' && a[0] <= '9', b[0] >= '0' && b[0] <= '9'
+		if da && db {
+			i, j := 0, 0
+			for i < len(a) && a[i] >= '0' && a[i] <= '9' {
+				i++
+			}
+			for j < len(b) && b[j] >= '0' && b[j] <= '9' {
+				j++
+			}
+			na, nb := strings.TrimLeft(a[:i], "0"), strings.TrimLeft(b[:j], "0")
+			if len(na) != len(nb) {
+				if len(na) < len(nb) {
+					return -1
+				}
+				return 1
+			}
+			if c := strings.Compare(na, nb); c != 0 {
+				return c
+			}
+			a, b = a[i:], b[j:]
+			continue
+		}
+		if a[0] != b[0] {
+			if a[0] < b[0] {
+				return -1
+			}
+			return 1
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) - len(b)
+}
@@ -0,0 +1,159 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+)
+
+type ss struct{}
+
+const ssUsage = `Usage: ss [ OPTIONS ]
+       ss [ OPTIONS ] [ FILTER ]
+   -h, --help          this message
+   -V, --version       output version information
+   -n, --numeric       don't resolve service names
+   -r, --resolve       resolve host names
+   -a, --all           display all sockets
+   -l, --listening     display listening sockets
+   -o, --options       show timer information
+   -e, --extended      show detailed socket information
+   -m, --memory        show socket memory usage
+   -p, --processes     show process using socket
+   -i, --info          show internal TCP information
+   -s, --summary       show socket usage summary
+   -b, --bpf           show bpf filter socket information
+   -E, --events        continually display sockets as they are destroyed
+   -Z, --context       display process SELinux security contexts
+   -z, --contexts      display process and socket SELinux security contexts
+   -N, --net           switch to the specified network namespace name
+
+   -4, --ipv4          display only IP version 4 sockets
+   -6, --ipv6          display only IP version 6 sockets
+   -0, --packet        display PACKET sockets
+   -t, --tcp           display only TCP sockets
+   -u, --udp           display only UDP sockets
+   -d, --dccp          display only DCCP sockets
+   -w, --raw           display only RAW sockets
+   -x, --unix          display only Unix domain sockets
+   -f, --family=FAMILY display sockets of type FAMILY
+
+   -A, --query=QUERY, --socket=QUERY
+       QUERY := {all|inet|tcp|udp|raw|unix|unix_dgram|unix_stream|unix_seqpacket|packet|netlink}[,QUERY]
+
+   -D, --diag=FILE     Dump raw information about TCP sockets to FILE
+   -F, --filter=FILE   read filter information from FILE
+       FILTER := [ state STATE-FILTER ] [ EXPRESSION ]
+       STATE-FILTER := {all|connected|synchronized|bucket|big|TCP-STATES}
+         TCP-STATES := {established|syn-sent|syn-recv|fin-wait-{1,2}|time-wait|closed|close-wait|last-ack|listen|closing}
+          connected := {established|syn-sent|syn-recv|fin-wait-{1,2}|time-wait|close-wait|last-ack|closing}
+      synchronized := {established|syn-recv|fin-wait-{1,2}|time-wait|close-wait|last-ack|closing}
+             bucket := {syn-recv|time-wait}
+                big := {established|syn-sent|fin-wait-{1,2}|closed|close-wait|last-ack|listen|closing}`
+
+func init() {
+	honeyos.RegisterCommand("ss", ss{})
+}
+
+func (ss) GetHelp() string {
+	return ""
+}
+
+func (ss) Where() string {
+	if pkgFamily() == "rpm" {
+		return "/usr/sbin/ss"
+	}
+	return "/bin/ss"
+}
+
+func (ss) Exec(args []string, sys honeyos.Sys) int {
+	// iproute2 is not installed in Alpine, which only has busybox
+	if honeyos.Distro() == "alpine" {
+		return honeyos.CommandNotFound(sys, append([]string{"ss"}, args...))
+	}
+	long := map[string]byte{"tcp": 't', "udp": 'u', "listening": 'l', "all": 'a', "numeric": 'n', "processes": 'p',
+		"extended": 'e', "options": 'o', "ipv4": '4', "ipv6": '6', "help": 'h'}
+	var opt sockOptions
+	for _, arg := range args {
+		flags := arg
+		if strings.HasPrefix(arg, "--") {
+			c, ok := long[arg[2:]]
+			if !ok {
+				fmt.Fprintf(sys.Err(), "ss: unrecognized option '%v'\n%v\n", arg, ssUsage)
+				return 255
+			}
+			flags = "-" + string(c)
+		} else if !strings.HasPrefix(arg, "-") {
+			// Filters of state and address are accepted but not applied
+			continue
+		}
+		for _, c := range flags[1:] {
+			switch c {
+			case 't':
+				opt.tcp = true
+			case 'u':
+				opt.udp = true
+			case 'l':
+				opt.listening = true
+			case 'a':
+				opt.all = true
+			case 'n':
+				opt.numeric = true
+			case 'p':
+				opt.programs = true
+			case 'h':
+				fmt.Fprintln(sys.Out(), ssUsage)
+				return 0
+			case 'e', 'o', '4', '6', 'r', 'm', 'i':
+			default:
+				fmt.Fprintf(sys.Err(), "ss: invalid option -- '%c'\n%v\n", c, ssUsage)
+				return 255
+			}
+		}
+	}
+	netid := opt.tcp == opt.udp
+	if !opt.tcp && !opt.udp {
+		opt.tcp, opt.udp = true, true
+	}
+	header := "State      Recv-Q Send-Q      Local Address:Port                     Peer Address:Port              "
+	if netid {
+		header = "Netid  " + header
+	}
+	fmt.Fprintln(sys.Out(), header)
+	root := isRoot(sys)
+	for _, s := range opt.selectSockets(sys, "udp", "udp6", "tcp", "tcp6") {
+		state, recvQ, sendQ := s.State, s.RecvQ, s.SendQ
+		switch state {
+		case "":
+			state = "UNCONN"
+		case "LISTEN":
+			// Send-Q of listening sockets is the backlog
+			sendQ = 128
+		case "ESTABLISHED":
+			state = "ESTAB"
+		}
+		local, peer := ssAddr(s.Local, opt.numeric), ssAddr(s.Remote, opt.numeric)
+		line := fmt.Sprintf("%-10v %-6v %-6v %26v %32v", state, recvQ, sendQ, local, peer)
+		if netid {
+			line = fmt.Sprintf("%-6v %v", strings.TrimSuffix(s.Proto, "6"), line)
+		}
+		if opt.programs && s.PID > 0 && (root || s.User == honeyos.GetUserByID(sys.CurrentUser()).Name) {
+			line += fmt.Sprintf("              users:((\"%v\",pid=%v,fd=%v))", honeyos.ProcInfo{Cmd: s.Program}.Comm(), s.PID, s.FD)
+		}
+		fmt.Fprintln(sys.Out(), line)
+	}
+	return 0
+}
+
+// ssAddr formats the address like ss does, e.g. *:22 for 0.0.0.0:22
+func ssAddr(addr string, numeric bool) string {
+	host, port := honeyos.SplitAddr(addr)
+	if host == "0.0.0.0" {
+		host = "*"
+	}
+	if name, ok := services[port]; ok && !numeric {
+		port = name
+	}
+	return host + ":" + port
+}
@@ -0,0 +1,459 @@
+package command
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+)
+
+// sshClient pretends to connect to other hosts. Nothing leaves the honeypot:
+// the host key and password prompts are simulated to collect the credentials
+// attackers pivot with, and the hosts in persona.ssh.hosts accept them,
+// landing in the shell of what looks like another machine
+type sshClient struct{}
+
+// sshHost is the host ssh lands on, configured as address, hostname and
+// password, with * for any password
+type sshHost struct {
+	addr, hostname, password string
+}
+
+const sshUsage = `usage: ssh [-1246AaCfGgKkMNnqsTtVvXxYy] [-b bind_address] [-c cipher_spec]
+           [-D [bind_address:]port] [-E log_file] [-e escape_char]
+           [-F configfile] [-I pkcs11] [-i identity_file] [-L address]
+           [-l login_name] [-m mac_spec] [-O ctl_cmd] [-o option] [-p port]
+           [-Q query_option] [-R address] [-S ctl_path] [-W host:port]
+           [-w local_tun[:remote_tun]] [user@]hostname [command]
+`
+
+func init() {
+	honeyos.RegisterCommand("ssh", sshClient{})
+}
+
+func (sshClient) GetHelp() string {
+	return sshUsage
+}
+
+func (sshClient) Where() string {
+	return "/usr/bin/ssh"
+}
+
+// sshConn is the connection to host that ssh and scp pretend to make
+type sshConn struct {
+	login, host, port, identity string
+	options                     map[string]string
+	verbose, quiet              bool
+	// target is the host logged in and user the account on it
+	target sshHost
+	user   honeyos.User
+	logger *log.Entry
+}
+
+func (s sshClient) Exec(args []string, sys honeyos.Sys) int {
+	if pkgFamily() == "apk" && !loadPkgDB(sys, "apk").installed("openssh-client") {
+		return honeyos.CommandNotFound(sys, append([]string{"ssh"}, args...))
+	}
+	var dest string
+	var cmd []string
+	noCommand := false
+	conn := &sshConn{options: map[string]string{}}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if dest != "" && (arg == "--" || !strings.HasPrefix(arg, "-")) {
+			// Options can follow the destination, up to the command
+			if arg == "--" {
+				i++
+			}
+			cmd = args[i:]
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			dest = arg
+			continue
+		}
+		for j := 1; j < len(arg); j++ {
+			c := arg[j]
+			if strings.IndexByte("BbcDEeFIiJLlmOopQRSWw", c) >= 0 {
+				val := arg[j+1:]
+				if val == "" {
+					if i+1 >= len(args) {
+						fmt.Fprintf(sys.Err(), "option requires an argument -- %c\n", c)
+						fmt.Fprint(sys.Err(), sshUsage)
+						return 255
+					}
+					i++
+					val = args[i]
+				}
+				switch c {
+				case 'l':
+					conn.login = val
+				case 'p':
+					conn.port = val
+				case 'i':
+					conn.identity = val
+				case 'o':
+					if !conn.option(sys, val) {
+						return 255
+					}
+				}
+				break
+			}
+			switch c {
+			case 'v':
+				conn.verbose = true
+			case 'q':
+				conn.quiet = true
+			case 'N':
+				noCommand = true
+			case 'V':
+				fmt.Fprintln(sys.Err(), sshVersion())
+				return 0
+			case '1', '2', '4', '6', 'A', 'a', 'C', 'f', 'G', 'g', 'K', 'k', 'M', 'n', 's', 'T', 't', 'X', 'x', 'Y', 'y':
+			default:
+				fmt.Fprintf(sys.Err(), "unknown option -- %c\n", c)
+				fmt.Fprint(sys.Err(), sshUsage)
+				return 255
+			}
+		}
+	}
+	if dest == "" {
+		fmt.Fprint(sys.Err(), sshUsage)
+		return 255
+	}
+	conn.host = strings.TrimPrefix(dest, "ssh://")
+	if at := strings.LastIndexByte(conn.host, '@'); at >= 0 {
+		if conn.login == "" {
+			conn.login = conn.host[:at]
+		}
+		conn.host = conn.host[at+1:]
+	}
+	if h, p, err := net.SplitHostPort(conn.host); err == nil && strings.HasPrefix(dest, "ssh://") {
+		conn.host, conn.port = h, p
+	}
+	if !conn.connect(sys, log.Fields{"command": strings.Join(cmd, " ")}) {
+		return 255
+	}
+
+	cred := honeyos.Credential{UID: conn.user.UID, Login: true, Hostname: conn.target.hostname}
+	if noCommand {
+		<-sys.Context().Done()
+		return 255
+	}
+	if len(cmd) > 0 {
+		shell := conn.user.Shell
+		if shell == "" {
+			shell = "/bin/sh"
+		}
+		n, _ := honeyos.RunAs(sys, cred, []string{shell, "-c", strings.Join(cmd, " ")})
+		return n
+	}
+	if motd, err := afero.ReadFile(sys.FSys(), "/etc/motd"); err == nil {
+		sys.Out().Write(motd)
+	}
+	fmt.Fprintf(sys.Out(), "Last login: %v from %v\n", time.Now().Add(-26*time.Hour).Format("Mon Jan _2 15:04:05 2006"),
+		viper.GetString("persona.address"))
+	n, _ := honeyos.RunAs(sys, cred, nil)
+	fmt.Fprintf(sys.Err(), "Connection to %v closed.\n", conn.host)
+	return n
+}
+
+// option sets the option given with -o, like StrictHostKeyChecking=no
+func (c *sshConn) option(sys honeyos.Sys, val string) bool {
+	kv := strings.SplitN(strings.Replace(val, "=", " ", 1), " ", 2)
+	if len(kv) < 2 {
+		fmt.Fprintln(sys.Err(), "command-line line 0: missing argument.")
+		return false
+	}
+	c.options[strings.ToLower(kv[0])] = strings.TrimSpace(kv[1])
+	return true
+}
+
+// connect pretends to connect and login to the host, asking for the host key
+// and password. Only the hosts ssh lands on accept the login, the others
+// just collect the passwords tried
+func (c *sshConn) connect(sys honeyos.Sys, fields log.Fields) bool {
+	if c.login == "" {
+		c.login = c.options["user"]
+	}
+	if c.login == "" {
+		c.login = honeyos.GetUserByID(sys.CurrentUser()).Name
+	}
+	if c.port == "" {
+		if c.port = c.options["port"]; c.port == "" {
+			c.port = "22"
+		}
+	}
+	if n, err := strconv.Atoi(c.port); err != nil || n <= 0 || n > 65535 {
+		fmt.Fprintf(sys.Err(), "Bad port '%v'\n", c.port)
+		return false
+	}
+	login, host, port := c.login, c.host, c.port
+	c.logger = sys.Log().WithFields(log.Fields{"sshHost": host, "sshPort": port, "sshUser": login})
+	c.logger.WithFields(fields).Infof("User connecting to %v@%v", login, host)
+	if c.verbose {
+		fmt.Fprintln(sys.Err(), sshVersion())
+		fmt.Fprintln(sys.Err(), "debug1: Reading configuration data /etc/ssh/ssh_config")
+	}
+
+	target, landing := sshLookup(sys, host)
+	ip := target.addr
+	if ip == "" {
+		if parsed := net.ParseIP(host); parsed != nil {
+			ip = parsed.String()
+		} else if viper.GetBool("server.allowDownload") {
+			if ips, err := net.LookupIP(host); err == nil && len(ips) > 0 {
+				ip = ips[0].String()
+			}
+		}
+	}
+	if ip == "" {
+		reason := "Name or service not known"
+		if !viper.GetBool("server.allowDownload") {
+			reason = "Temporary failure in name resolution"
+		}
+		fmt.Fprintf(sys.Err(), "ssh: Could not resolve hostname %v: %v\n", host, reason)
+		return false
+	}
+	if c.verbose {
+		fmt.Fprintf(sys.Err(), "debug1: Connecting to %v [%v] port %v.\n", host, ip, port)
+	}
+	if !landing && !viper.GetBool("server.allowDownload") {
+		// Offline honeypot can't reach hosts other than its own
+		pkgSleep(sys, 10*time.Second)
+		fmt.Fprintf(sys.Err(), "ssh: connect to host %v port %v: Connection timed out\n", host, port)
+		return false
+	}
+	if !pkgSleep(sys, 300*time.Millisecond) {
+		return false
+	}
+	if c.verbose {
+		fmt.Fprintln(sys.Err(), "debug1: Connection established.")
+		fmt.Fprintf(sys.Err(), "debug1: Remote protocol version 2.0, remote software version %v\n",
+			strings.SplitN(strings.TrimPrefix(viper.GetString("server.ident"), "SSH-2.0-"), " ", 2)[0])
+	}
+	if c.identity != "" {
+		if data, err := afero.ReadFile(sys.FSys(), absPath(sys, c.identity)); err != nil {
+			fmt.Fprintf(sys.Err(), "Warning: Identity file %v not accessible: No such file or directory.\n", c.identity)
+		} else {
+			c.logger.WithField("identity", c.identity).Infof("User offered identity %v to ssh", c.identity)
+			honeyos.SaveArtifact(sys, data, "ssh -i "+c.identity)
+		}
+	}
+
+	tty := honeyos.OpenTTY(sys)
+	if !c.checkHostKey(sys, tty, ip) {
+		return false
+	}
+	if banner := viper.GetString("persona.ssh.banner"); banner != "" && !c.quiet {
+		fmt.Fprint(sys.Err(), strings.TrimRight(banner, "\n")+"\n")
+	}
+	if c.options["batchmode"] == "yes" || c.options["passwordauthentication"] == "no" || tty == nil {
+		fmt.Fprintf(sys.Err(), "%v@%v: Permission denied (publickey,password).\n", login, host)
+		return false
+	}
+	tries := 3
+	if n, err := strconv.Atoi(c.options["numberofpasswordprompts"]); err == nil {
+		tries = n
+	}
+	accepted := false
+	for i := 0; i < tries && !accepted; i++ {
+		if i > 0 {
+			fmt.Fprintln(sys.Err(), "Permission denied, please try again.")
+		}
+		pass, ok := readPasswordFrom(sys, tty, fmt.Sprintf("%v@%v's password: ", login, host))
+		accepted = landing && sshAccepts(target, login, pass)
+		c.logger.WithField("password", pass).WithField("accepted", accepted).Infof("User tried ssh password for %v@%v", login, host)
+		if !ok {
+			break
+		}
+		if !accepted && !pkgSleep(sys, 2*time.Second) {
+			return false
+		}
+	}
+	if !accepted {
+		fmt.Fprintf(sys.Err(), "%v@%v: Permission denied (publickey,password).\n", login, host)
+		return false
+	}
+	c.logger.WithField("hostname", target.hostname).Infof("User logged in to %v as %v", target.hostname, login)
+	c.target, c.user = target, honeyos.GetUser(login)
+	return true
+}
+
+// checkHostKey verifies the host key against known_hosts, asking to add it
+// if the host is new. It returns false if the key is not accepted
+func (c *sshConn) checkHostKey(sys honeyos.Sys, tty io.Reader, ip string) bool {
+	host, port, options := c.host, c.port, c.options
+	name, addr := host, ip
+	if port != "22" {
+		name, addr = "["+host+"]:"+port, "["+ip+"]:"+port
+	}
+	file := options["userknownhostsfile"]
+	if file == "" {
+		file = honeyos.Getenv(sys, "HOME") + "/.ssh/known_hosts"
+	}
+	file = strings.Replace(file, "~", honeyos.Getenv(sys, "HOME"), 1)
+	data, _ := afero.ReadFile(sys.FSys(), file)
+	for _, line := range strings.Split(string(data), "\n") {
+		if fields := strings.Fields(line); len(fields) > 1 {
+			for _, n := range strings.Split(fields[0], ",") {
+				if n == name || n == addr {
+					return true
+				}
+			}
+		}
+	}
+	blob := sshHostKey(ip)
+	sum := sha256.Sum256(blob)
+	added := func() {
+		if !c.quiet {
+			fmt.Fprintf(sys.Err(), "Warning: Permanently added '%v' (ECDSA) to the list of known hosts.\n", strings.Join(uniqNames(name, addr), ","))
+		}
+		if file == "/dev/null" {
+			return
+		}
+		sys.FSys().MkdirAll(file[:strings.LastIndexByte(file, '/')+1], 0700)
+		entry := strings.Join(uniqNames(name, addr), ",") + " ecdsa-sha2-nistp256 " + base64.StdEncoding.EncodeToString(blob) + "\n"
+		afero.WriteFile(sys.FSys(), file, append(data, entry...), 0644)
+	}
+	switch strings.ToLower(options["stricthostkeychecking"]) {
+	case "no", "off", "accept-new":
+		added()
+		return true
+	case "yes", "on":
+		fmt.Fprintf(sys.Err(), "No ECDSA host key is known for %v and you have requested strict checking.\nHost key verification failed.\n", name)
+		return false
+	}
+	if tty == nil {
+		fmt.Fprintln(sys.Err(), "Host key verification failed.")
+		return false
+	}
+	label := "'" + name + " (" + ip + ")'"
+	if name != host {
+		label = "'" + name + " (" + addr + ")'"
+	}
+	fmt.Fprintf(sys.Err(), "The authenticity of host %v can't be established.\n", label)
+	fmt.Fprintf(sys.Err(), "ECDSA key fingerprint is SHA256:%v.\n", base64.RawStdEncoding.EncodeToString(sum[:]))
+	if pkgFamily() == "rpm" {
+		md := md5.Sum(blob)
+		hex := make([]string, len(md))
+		for i, b := range md {
+			hex[i] = fmt.Sprintf("%02x", b)
+		}
+		fmt.Fprintf(sys.Err(), "ECDSA key fingerprint is MD5:%v.\n", strings.Join(hex, ":"))
+	}
+	prompt := "Are you sure you want to continue connecting (yes/no)? "
+	if pkgFamily() == "apk" {
+		prompt = "This key is not known by any other names\nAre you sure you want to continue connecting (yes/no/[fingerprint])? "
+	}
+	fmt.Fprint(sys.Err(), prompt)
+	for {
+		answer, ok := readLine(tty)
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "yes":
+			added()
+			return true
+		case "no":
+			fmt.Fprintln(sys.Err(), "Host key verification failed.")
+			return false
+		}
+		if !ok {
+			fmt.Fprintln(sys.Err(), "\nHost key verification failed.")
+			return false
+		}
+		fmt.Fprint(sys.Err(), "Please type 'yes' or 'no': ")
+	}
+}
+
+// sshLookup finds the host ssh can land on by its address or hostname. The
+// honeypot itself is one of them
+func sshLookup(sys honeyos.Sys, host string) (sshHost, bool) {
+	self := sshHost{addr: viper.GetString("persona.address"), hostname: sys.Hostname()}
+	switch host {
+	case "localhost":
+		self.addr = "127.0.0.1"
+		return self, true
+	case "127.0.0.1", "::1":
+		self.addr = host
+		return self, true
+	case self.addr, self.hostname:
+		return self, true
+	}
+	for _, entry := range viper.GetStringSlice("persona.ssh.hosts") {
+		fields := strings.Fields(entry)
+		if len(fields) < 2 {
+			continue
+		}
+		h := sshHost{addr: fields[0], hostname: fields[1], password: "*"}
+		if len(fields) > 2 {
+			h.password = fields[2]
+		}
+		if host == h.addr || host == h.hostname || strings.HasPrefix(h.hostname, host+".") {
+			return h, true
+		}
+	}
+	return sshHost{}, false
+}
+
+// sshAccepts tells if the host ssh lands on takes the password for the
+// login. Telnet and ftp log in to the hosts the same way
+func sshAccepts(target sshHost, login, pass string) bool {
+	switch {
+	case pass == "" || honeyos.GetUser(login).Name == "":
+		return false
+	case target.password == "":
+		// Hosts of the honeypot itself take the account password
+		stored, _ := honeyos.IsUserExist(login)
+		return stored == pass || stored == "*"
+	}
+	return target.password == "*" || target.password == pass
+}
+
+// sshHostKey makes up the ECDSA public key of the host, so the fingerprint
+// stays the same across sessions
+func sshHostKey(ip string) []byte {
+	seed := sha256.Sum256([]byte("syrup host key " + ip))
+	point := append([]byte{4}, seed[:]...)
+	seed = sha256.Sum256(seed[:])
+	point = append(point, seed[:]...)
+	var blob []byte
+	for _, s := range [][]byte{[]byte("ecdsa-sha2-nistp256"), []byte("nistp256"), point} {
+		var l [4]byte
+		binary.BigEndian.PutUint32(l[:], uint32(len(s)))
+		blob = append(append(blob, l[:]...), s...)
+	}
+	return blob
+}
+
+// sshVersion is what ssh -V prints in the distribution
+func sshVersion() string {
+	switch {
+	case pkgFamily() == "rpm":
+		return "OpenSSH_7.4p1, OpenSSL 1.0.2k-fips  26 Jan 2017"
+	case pkgFamily() == "apk":
+		return "OpenSSH_8.8p1, OpenSSL 1.1.1n  15 Mar 2022"
+	case honeyos.Distro() == "debian":
+		return "OpenSSH_7.4p1 Debian-10+deb9u7, OpenSSL 1.0.2u  20 Dec 2019"
+	}
+	return "OpenSSH_7.2p2 Ubuntu-4ubuntu2.10, OpenSSL 1.0.2g  1 Mar 2016"
+}
+
+// uniqNames drops the address from the names of known host if it is the
+// same as the name
+func uniqNames(name, addr string) []string {
+	if name == addr {
+		return []string{name}
+	}
+	return []string{name, addr}
+}
@@ -0,0 +1,547 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	pathlib "path"
+	"strconv"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/mkishere/sshsyrup/virtualfs"
+)
+
+type stat struct{}
+
+// statFile is the file stat shows, with the filesystem it is on
+type statFile struct {
+	name, path, target string
+	fi                 os.FileInfo
+	mount              honeyos.MountInfo
+	major, minor       int
+}
+
+// statFSTypes are the names and magic numbers stat -f shows for the types
+// of filesystems mounted
+var statFSTypes = map[string]struct {
+	name  string
+	magic uint64
+}{
+	"ext2":       {"ext2/ext3", 0xef53},
+	"ext3":       {"ext2/ext3", 0xef53},
+	"ext4":       {"ext2/ext3", 0xef53},
+	"xfs":        {"xfs", 0x58465342},
+	"btrfs":      {"btrfs", 0x9123683e},
+	"vfat":       {"msdos", 0x4d44},
+	"tmpfs":      {"tmpfs", 0x1021994},
+	"devtmpfs":   {"tmpfs", 0x1021994},
+	"proc":       {"proc", 0x9fa0},
+	"sysfs":      {"sysfs", 0x62656572},
+	"devpts":     {"devpts", 0x1cd1},
+	"securityfs": {"securityfs", 0x73636673},
+	"cgroup":     {"cgroupfs", 0x27e0eb},
+	"cgroup2":    {"cgroup2fs", 0x63677270},
+}
+
+// statDevices are the major and minor numbers of the device files every
+// system has
+var statDevices = map[string][2]int{
+	"/dev/null": {1, 3}, "/dev/zero": {1, 5}, "/dev/full": {1, 7}, "/dev/random": {1, 8},
+	"/dev/urandom": {1, 9}, "/dev/tty": {5, 0}, "/dev/console": {5, 1}, "/dev/ptmx": {5, 2},
+}
+
+const (
+	statDefault = "  File: %N\n  Size: %-10s\tBlocks: %-10b IO Block: %-6o %F\n" +
+		"Device: %Dh/%dd\tInode: %-11i Links: %h\n" +
+		"Access: (%04a/%10.10A)  Uid: (%5u/%8U)   Gid: (%5g/%8G)\n" +
+		"Access: %x\nModify: %y\nChange: %z\n Birth: %w\n"
+	statDevice = "  File: %N\n  Size: %-10s\tBlocks: %-10b IO Block: %-6o %F\n" +
+		"Device: %Dh/%dd\tInode: %-11i Links: %-5h Device type: %t,%T\n" +
+		"Access: (%04a/%10.10A)  Uid: (%5u/%8U)   Gid: (%5g/%8G)\n" +
+		"Access: %x\nModify: %y\nChange: %z\n Birth: %w\n"
+	statTerse = "%n %s %b %f %u %g %D %i %h %t %T %X %Y %Z %W %o\n"
+	statFS    = "  File: \"%n\"\n    ID: %-8i Namelen: %-7l Type: %T\nBlock size: %-10s Fundamental block size: %S\n" +
+		"Blocks: Total: %-10b Free: %-10f Available: %a\nInodes: Total: %-10c Free: %d\n"
+	statFSTerse = "%n %i %l %t %s %S %b %f %a %c %d\n"
+)
+
+func init() {
+	honeyos.RegisterCommand("stat", stat{})
+}
+
+func (stat) GetHelp() string {
+	return `Usage: stat [OPTION]... FILE...
+Display file or file system status.
+
+Mandatory arguments to long options are mandatory for short options too.
+  -L, --dereference     follow links
+  -f, --file-system     display file system status instead of file status
+  -c  --format=FORMAT   use the specified FORMAT instead of the default;
+                          output a newline after each use of FORMAT
+      --printf=FORMAT   like --format, but interpret backslash escapes,
+                          and do not output a mandatory trailing newline;
+                          if you want a newline, include \n in FORMAT
+  -t, --terse           print the information in terse form
+      --help     display this help and exit
+      --version  output version information and exit
+
+The valid format sequences for files (without --file-system):
+
+  %a   access rights in octal (note '#' and '0' printf flags)
+  %A   access rights in human readable form
+  %b   number of blocks allocated (see %B)
+  %B   the size in bytes of each block reported by %b
+  %C   SELinux security context string
+  %d   device number in decimal
+  %D   device number in hex
+  %f   raw mode in hex
+  %F   file type
+  %g   group ID of owner
+  %G   group name of owner
+  %h   number of hard links
+  %i   inode number
+  %m   mount point
+  %n   file name
+  %N   quoted file name with dereference if symbolic link
+  %o   optimal I/O transfer size hint
+  %s   total size, in bytes
+  %t   major device type in hex, for character/block device special files
+  %T   minor device type in hex, for character/block device special files
+  %u   user ID of owner
+  %U   user name of owner
+  %w   time of file birth, human-readable; - if unknown
+  %W   time of file birth, seconds since Epoch; 0 if unknown
+  %x   time of last access, human-readable
+  %X   time of last access, seconds since Epoch
+  %y   time of last data modification, human-readable
+  %Y   time of last data modification, seconds since Epoch
+  %z   time of last status change, human-readable
+  %Z   time of last status change, seconds since Epoch
+
+Valid format sequences for file systems:
+
+  %a   free blocks available to non-superuser
+  %b   total data blocks in file system
+  %c   total file nodes in file system
+  %d   free file nodes in file system
+  %f   free blocks in file system
+  %i   file system ID in hex
+  %l   maximum length of filenames
+  %n   file name
+  %s   block size (for faster transfers)
+  %S   fundamental block size (for block counts)
+  %t   file system type in hex
+  %T   file system type in human readable form
+
+--terse is equivalent to the following FORMAT:
+    %n %s %b %f %u %g %D %i %h %t %T %X %Y %Z %W %o %C
+--terse --file-system is equivalent to the following FORMAT:
+    %n %i %l %t %s %S %b %f %a %c %d
+
+NOTE: your shell may have its own version of stat, which usually supersedes
+the version described here.  Please refer to your shell's documentation
+for details about the options it supports.
+
+GNU coreutils online help: <https://www.gnu.org/software/coreutils/>
+Full documentation at: <https://www.gnu.org/software/coreutils/stat>
+or available locally via: info '(coreutils) stat invocation'
+`
+}
+
+func (stat) Where() string {
+	return "/usr/bin/stat"
+}
+
+func (s stat) Exec(args []string, sys honeyos.Sys) int {
+	var deref, fsMode, terse, printf, hasFormat bool
+	var format string
+	operands, status := fileOperands("stat", args, sys, func(f string) (bool, int) {
+		switch f {
+		case "-L", "--dereference":
+			deref = true
+		case "-f", "--file-system":
+			fsMode = true
+		case "-t", "--terse":
+			terse = true
+		case "-c", "--format":
+			printf, hasFormat = false, true
+			return true, -1
+		case "--printf":
+			printf, hasFormat = true, true
+			return true, -1
+		case "--cached":
+			return true, -1
+		case "--help":
+			fmt.Fprint(sys.Out(), s.GetHelp())
+			return false, 0
+		case "--version":
+			fmt.Fprintln(sys.Out(), "stat (GNU coreutils) 8.32")
+			return false, 0
+		default:
+			return false, 1
+		}
+		return false, -1
+	}, &format)
+	if status >= 0 {
+		return status
+	}
+	if len(operands) == 0 {
+		fmt.Fprintln(sys.Err(), "stat: missing operand\nTry 'stat --help' for more information.")
+		return 1
+	}
+	switch {
+	case hasFormat && printf:
+		format = statEscapes(format)
+	case hasFormat:
+		format += "\n"
+	case fsMode && terse:
+		format = statFSTerse
+	case fsMode:
+		format = statFS
+	case terse:
+		format = statTerse
+	}
+	res := 0
+	for _, name := range operands {
+		f, err := s.lookup(sys, name, deref || fsMode)
+		if err != nil {
+			if fsMode {
+				fmt.Fprintf(sys.Err(), "stat: cannot read file system information for '%v': %v\n", name, fileError(err))
+			} else {
+				fmt.Fprintf(sys.Err(), "stat: cannot statx '%v': %v\n", name, fileError(err))
+			}
+			res = 1
+			continue
+		}
+		spec := format
+		if spec == "" {
+			spec = statDefault
+			if f.fi.Mode()&os.ModeDevice != 0 {
+				spec = statDevice
+			}
+		}
+		if fsMode {
+			fmt.Fprint(sys.Out(), statFormat(spec, func(c byte) interface{} { return s.fsField(sys, f, c) }))
+		} else {
+			fmt.Fprint(sys.Out(), statFormat(spec, func(c byte) interface{} { return s.field(sys, f, c) }))
+		}
+	}
+	return res
+}
+
+// lookup gets the file, without following the link at the end of the name
+// unless deref is set
+func (stat) lookup(sys honeyos.Sys, name string, deref bool) (statFile, error) {
+	fs := sys.FSys()
+	f := statFile{name: name, path: absPath(sys, name)}
+	var err error
+	if deref {
+		f.fi, err = fs.Stat(f.path)
+	} else {
+		f.fi, err = honeyos.Lstat(fs, f.path)
+	}
+	if err != nil {
+		return f, err
+	}
+	if f.fi.Mode()&os.ModeSymlink != 0 {
+		f.target, _ = honeyos.Readlink(fs, f.path)
+	}
+	mounts := sys.Mounts()
+	i := mountIndex(mounts, f.path)
+	if i < 0 {
+		return f, nil
+	}
+	f.mount = mounts[i]
+	// Pseudo filesystems get anonymous devices, numbered as they were
+	// mounted
+	f.minor = 20 + i
+	dev := strings.TrimPrefix(strings.TrimPrefix(f.mount.Device, "/dev/mapper/"), "/dev/")
+	var find func(devs []*blockDev)
+	find = func(devs []*blockDev) {
+		for _, d := range devs {
+			if d.name == dev {
+				f.major, f.minor = d.major, d.minor
+			}
+			find(d.children)
+		}
+	}
+	find(blockDevices(sys))
+	return f, nil
+}
+
+// field is the value of the format sequence for the file. Numbers are kept
+// as numbers so they can be padded with zeros
+func (stat) field(sys honeyos.Sys, f statFile, c byte) interface{} {
+	fi := f.fi
+	mode := fi.Mode()
+	if fi.IsDir() {
+		mode |= os.ModeDir
+	}
+	uid, gid, _, _ := virtualfs.GetExtraInfo(fi)
+	atime, mtime, ctime := honeyos.FileTimes(fi)
+	loc := honeyos.Location(sys)
+	switch c {
+	case 'a':
+		return strconv.FormatUint(uint64(unixMode(mode)), 8)
+	case 'A':
+		return lsMode(mode)
+	case 'b':
+		if mode&os.ModeSymlink != 0 {
+			return 0
+		}
+		return diskBlocks(fi) / 512
+	case 'B':
+		return 512
+	case 'C':
+		return "?"
+	case 'd':
+		return f.major<<8 | f.minor
+	case 'D':
+		return fmt.Sprintf("%x", f.major<<8|f.minor)
+	case 'f':
+		return fmt.Sprintf("%x", statRawMode(mode))
+	case 'F':
+		return statType(fi, mode)
+	case 'g':
+		return gid
+	case 'G':
+		if g := honeyos.GetGroupByID(gid); g.Name != "" {
+			return g.Name
+		}
+		return "UNKNOWN"
+	case 'h':
+		if fi.IsDir() {
+			return lsLinks(sys, lsEntry{path: f.path, fi: fi})
+		}
+		return honeyos.Nlink(fi)
+	case 'i':
+		return honeyos.Inode(f.path, fi)
+	case 'm':
+		return f.mount.Dir
+	case 'n':
+		return f.name
+	case 'N':
+		if f.target != "" {
+			return statQuote(f.name) + " -> " + statQuote(f.target)
+		}
+		return statQuote(f.name)
+	case 'o':
+		return 4096
+	case 's':
+		return lsSize(fi)
+	case 't', 'T':
+		dev := statDevices[pathlib.Clean(f.path)]
+		if mode&os.ModeDevice == 0 {
+			dev = [2]int{0, 0}
+		}
+		if c == 't' {
+			return fmt.Sprintf("%x", dev[0])
+		}
+		return fmt.Sprintf("%x", dev[1])
+	case 'u':
+		return uid
+	case 'U':
+		if u := honeyos.GetUserByID(uid); u.Name != "" {
+			return u.Name
+		}
+		return "UNKNOWN"
+	case 'w':
+		return "-"
+	case 'W':
+		return 0
+	case 'x':
+		return statTime(atime.In(loc))
+	case 'X':
+		return atime.Unix()
+	case 'y':
+		return statTime(mtime.In(loc))
+	case 'Y':
+		return mtime.Unix()
+	case 'z':
+		return statTime(ctime.In(loc))
+	case 'Z':
+		return ctime.Unix()
+	}
+	return nil
+}
+
+// fsField is the value of the format sequence for the filesystem the file
+// is on, sized like df shows
+func (stat) fsField(sys honeyos.Sys, f statFile, c byte) interface{} {
+	var u dfUsage
+	if c == 'a' || c == 'c' || c == 'd' || c == 'f' {
+		mounts := sys.Mounts()
+		if i := mountIndex(mounts, f.path); i >= 0 {
+			u = mountUsage(sys, mounts)[i]
+		}
+	}
+	fsType := statFSTypes[f.mount.Type]
+	switch c {
+	case 'a':
+		return u.avail / 4096
+	case 'b':
+		return f.mount.Size / 4096
+	case 'c':
+		return u.inodes
+	case 'd':
+		return u.ifree
+	case 'f':
+		return (u.Size - u.used) / 4096
+	case 'i':
+		if f.mount.Size == 0 {
+			return "0"
+		}
+		return fmt.Sprintf("%x", fnvString(f.mount.Device+f.mount.Dir))
+	case 'l':
+		return 255
+	case 'n':
+		return f.name
+	case 's', 'S':
+		return 4096
+	case 't':
+		return fmt.Sprintf("%x", fsType.magic)
+	case 'T':
+		if fsType.name == "" {
+			return f.mount.Type
+		}
+		return fsType.name
+	}
+	return nil
+}
+
+// statFormat expands the format sequences like %-10s, taking the value of
+// each sequence from field. Unknown sequences are left as they are
+func statFormat(format string, field func(c byte) interface{}) string {
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			b.WriteByte(format[i])
+			continue
+		}
+		j := i + 1
+		for j < len(format) && strings.IndexByte("-+ #0'", format[j]) >= 0 {
+			j++
+		}
+		for j < len(format) && (format[j] >= '0' && format[j] <= '9' || format[j] == '.') {
+			j++
+		}
+		if j >= len(format) {
+			b.WriteString(format[i:])
+			break
+		}
+		if format[j] == '%' {
+			b.WriteByte('%')
+			i = j
+			continue
+		}
+		spec := strings.Replace(format[i:j], "'", "", -1)
+		switch v := field(format[j]).(type) {
+		case nil:
+			b.WriteString(format[i : j+1])
+		case string:
+			fmt.Fprintf(&b, spec+"s", v)
+		default:
+			fmt.Fprintf(&b, spec+"d", v)
+		}
+		i = j
+	}
+	return b.String()
+}
+
+// statEscapes interprets the backslash escapes of --printf
+func statEscapes(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch c := s[i]; c {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'v':
+			b.WriteByte('\v')
+		case 'e':
+			b.WriteByte('\x1b')
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			n, j := 0, i
+			for ; j < len(s) && j < i+3 && s[j] >= '0' && s[j] <= '7'; j++ {
+				n = n*8 + int(s[j]-'0')
+			}
+			b.WriteByte(byte(n))
+			i = j - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// statType is the file type in words
+func statType(fi os.FileInfo, mode os.FileMode) string {
+	switch {
+	case mode.IsDir():
+		return "directory"
+	case mode&os.ModeSymlink != 0:
+		return "symbolic link"
+	case mode&os.ModeNamedPipe != 0:
+		return "fifo"
+	case mode&os.ModeSocket != 0:
+		return "socket"
+	case mode&os.ModeCharDevice != 0:
+		return "character special file"
+	case mode&os.ModeDevice != 0:
+		return "block special file"
+	case fi.Size() == 0:
+		return "regular empty file"
+	}
+	return "regular file"
+}
+
+// statRawMode is st_mode with the type bits, like 81a4 for a regular file
+func statRawMode(mode os.FileMode) uint32 {
+	kind := uint32(0100000)
+	switch {
+	case mode.IsDir():
+		kind = 040000
+	case mode&os.ModeSymlink != 0:
+		kind = 0120000
+	case mode&os.ModeNamedPipe != 0:
+		kind = 010000
+	case mode&os.ModeSocket != 0:
+		kind = 0140000
+	case mode&os.ModeCharDevice != 0:
+		kind = 020000
+	case mode&os.ModeDevice != 0:
+		kind = 060000
+	}
+	return kind | unixMode(mode)
+}
+
+// statTime is the time with nanoseconds as stat shows it
+func statTime(t time.Time) string {
+	return t.Format("2006-01-02 15:04:05.000000000 -0700")
+}
+
+// statQuote quotes the name like the shell-escape style of coreutils, only
+// when it has characters the shell would take
+func statQuote(s string) string {
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("/._-+,:@%=^", c)) {
+			return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+		}
+	}
+	return s
+}
@@ -0,0 +1,620 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/afero"
+)
+
+// trace is strace and ltrace. The trace of the command run is made up of the
+// start of a dynamically linked program, the files named in the arguments
+// and the writes of its output, so it matches what the command shows.
+// Attaching to a running process needs root
+type trace struct {
+	name string
+}
+
+// traceLog writes the calls traced, or counts them for the summary of -c
+type traceLog struct {
+	out     io.Writer
+	lib     bool
+	only    map[string]bool
+	strSize int
+	stamps  int
+	summary bool
+	now     func() time.Time
+	counts  map[string]*traceCount
+	order   []string
+	// base is where the libraries are mapped, different in each run
+	base uint64
+}
+
+// traceCount is the calls of a function for the summary
+type traceCount struct {
+	calls, errors int
+}
+
+// traceClasses are the sets of system calls -e trace= takes for their names
+var traceClasses = map[string][]string{
+	"file":    {"execve", "access", "open", "openat", "stat", "fstat", "lstat"},
+	"process": {"execve", "exit_group", "arch_prctl", "set_tid_address"},
+	"memory":  {"brk", "mmap", "mprotect", "munmap"},
+	"desc":    {"open", "openat", "read", "write", "close", "fstat", "getdents", "mmap"},
+}
+
+func init() {
+	honeyos.RegisterCommand("strace", trace{"strace"})
+	honeyos.RegisterCommand("ltrace", trace{"ltrace"})
+}
+
+func (t trace) GetHelp() string {
+	if t.name == "ltrace" {
+		return `Usage: ltrace [option ...] [command [arg ...]]
+Trace library calls of a given program.
+
+  -a, --align=COLUMN  align return values in a secific column.
+  -c                  count time and calls, and report a summary on exit.
+  -e FILTER           modify which library calls to trace.
+  -f                  trace children (fork() and clone()).
+  -h, --help          display this help and exit.
+  -n, --indent=NR     indent output by NR spaces for each call level nesting.
+  -o, --output=FILENAME write the trace output to file with given name.
+  -p PID              attach to the process with the process ID pid.
+  -s STRSIZE          specify the maximum string size to print.
+  -S                  trace system calls as well as library calls.
+  -t, -tt, -ttt       print absolute timestamps.
+  -V, --version       output version information and exit.
+
+Report bugs to ltrace-devel@lists.alioth.debian.org
+`
+	}
+	return `usage: strace [-CdffhiqrtttTvVxxy] [-I n] [-e expr]...
+              [-a column] [-o file] [-s strsize] [-P path]...
+              -p pid... / [-D] [-E var=val]... [-u username] PROG [ARGS]
+   or: strace -c[df] [-I n] [-e expr]... [-O overhead] [-S sortby]
+              -p pid... / [-D] [-E var=val]... [-u username] PROG [ARGS]
+-c -- count time, calls, and errors for each syscall and report summary
+-f -- follow forks, -ff -- with output into separate files
+-h -- print help message, -q -- suppress messages about attaching, detaching, etc.
+-t -- absolute timestamp, -tt -- with usecs, -ttt -- with usecs since epoch
+-v -- verbose mode: print unabbreviated argv, stat, termios, etc. args
+-V -- print version
+-e expr -- a qualifying expression: option=[!]all or option=[!]val1[,val2]...
+   options: trace, abbrev, verbose, raw, signal, read, write
+-o file -- send trace output to FILE instead of stderr
+-p pid -- trace process with process id PID, may be repeated
+-s strsize -- limit length of print strings to STRSIZE chars (default 32)
+`
+}
+
+func (t trace) Where() string {
+	return "/usr/bin/" + t.name
+}
+
+func (t trace) Exec(args []string, sys honeyos.Sys) int {
+	if !loadPkgDB(sys, pkgFamily()).installed(t.name) {
+		return honeyos.CommandNotFound(sys, append([]string{t.name}, args...))
+	}
+	log := &traceLog{out: sys.Err(), lib: t.name == "ltrace", strSize: 32, now: func() time.Time { return honeyos.Now(sys) },
+		counts: map[string]*traceCount{}, base: 0x7f0000000000 + uint64(rand.Int63n(0xfffffff))<<12}
+	var pids []string
+	output := ""
+	i := 0
+	for ; i < len(args) && strings.HasPrefix(args[i], "-") && args[i] != "-"; i++ {
+		arg := args[i]
+		if arg == "--" {
+			i++
+			break
+		}
+		switch arg {
+		case "--help":
+			fmt.Fprint(sys.Out(), t.GetHelp())
+			return 0
+		case "--version":
+			arg = "-V"
+		}
+		for j := 1; j < len(arg); j++ {
+			switch c := arg[j]; c {
+			case 'c':
+				log.summary = true
+			case 't':
+				log.stamps++
+			case 'h':
+				fmt.Fprint(sys.Out(), t.GetHelp())
+				return 0
+			case 'V':
+				if log.lib {
+					fmt.Fprintln(sys.Out(), "ltrace version 0.7.3.\nCopyright (C) 1997-2009 Juan Cespedes <cespedes@debian.org>.\n"+
+						"This is free software; see the GNU General Public Licence\nversion 2 or later for copying conditions.  There is NO warranty.")
+				} else {
+					fmt.Fprintln(sys.Out(), "strace -- version 4.11")
+				}
+				return 0
+			case 'f', 'F', 'C', 'r', 'T', 'q', 'v', 'x', 'y', 'i', 'd', 'D', 'w', 'S', 'n', 'l', 'L':
+			case 'o', 'e', 's', 'p', 'u', 'E', 'a', 'I', 'O', 'P':
+				val := arg[j+1:]
+				if val == "" {
+					if i+1 == len(args) {
+						fmt.Fprintf(sys.Err(), "%v: option requires an argument -- '%c'\nTry '%v -h' for more information.\n", t.name, c, t.name)
+						return 1
+					}
+					i++
+					val = args[i]
+				}
+				switch c {
+				case 'o':
+					output = val
+				case 'p':
+					pids = append(pids, strings.FieldsFunc(val, func(r rune) bool { return r == ',' || r == ' ' })...)
+				case 's':
+					n, err := strconv.Atoi(val)
+					if err != nil || n < 0 {
+						fmt.Fprintf(sys.Err(), "%v: invalid -s argument: '%v'\n", t.name, val)
+						return 1
+					}
+					log.strSize = n
+				case 'e':
+					log.filter(val)
+				}
+				j = len(arg)
+			default:
+				fmt.Fprintf(sys.Err(), "%v: invalid option -- '%c'\nTry '%v -h' for more information.\n", t.name, c, t.name)
+				return 1
+			}
+		}
+	}
+	argv := args[i:]
+	if len(argv) == 0 && len(pids) == 0 {
+		if log.lib {
+			fmt.Fprintln(sys.Err(), "ltrace: too few arguments\nTry `ltrace --help' for more information.")
+		} else {
+			fmt.Fprintln(sys.Err(), "strace: must have PROG [ARGS] or -p PID\nTry 'strace -h' for more information.")
+		}
+		return 1
+	}
+	if output != "" {
+		f, err := sys.FSys().OpenFile(absPath(sys, output), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			fmt.Fprintf(sys.Err(), "%v: can't fopen '%v': Permission denied\n", t.name, output)
+			return 1
+		}
+		defer f.Close()
+		log.out = f
+	}
+	if len(argv) == 0 {
+		return t.attach(sys, log, pids)
+	}
+
+	exe := argv[0]
+	if !strings.Contains(exe, "/") {
+		found := honeyos.LookPath(sys, honeyos.Getenv(sys, "PATH"), exe)
+		if len(found) > 0 {
+			exe = found[0]
+		}
+	}
+	if fi, err := sys.FSys().Stat(absPath(sys, exe)); err != nil || fi.IsDir() || !strings.Contains(exe, "/") {
+		if log.lib {
+			fmt.Fprintf(sys.Err(), "Can't execute `%v': No such file or directory\n", argv[0])
+		} else {
+			fmt.Fprintf(sys.Err(), "%v: Can't stat '%v': No such file or directory\n", t.name, argv[0])
+		}
+		return 1
+	}
+	sys.Log().WithField("cmd", strings.Join(argv, " ")).Infof("User ran %v with %v", argv[0], t.name)
+
+	log.start(exe, argv, len(sys.Environ()), honeyos.Getenv(sys, "LANG"))
+	for _, arg := range argv[1:] {
+		if !strings.HasPrefix(arg, "-") {
+			if fi, err := sys.FSys().Stat(absPath(sys, arg)); err == nil {
+				log.open(sys, arg, fi)
+			}
+		}
+	}
+	tap := func(fd int, w io.Writer) io.Writer {
+		// Each line to the terminal is written on its own, as stdio buffers
+		// by lines there. On the same terminal the output comes out in the
+		// middle of the call
+		inline := !log.summary && honeyos.IsTerminal(w) && honeyos.IsTerminal(log.out)
+		return honeyos.Tap(w, func(p []byte) (int, error) {
+			chunks := [][]byte{p}
+			if honeyos.IsTerminal(w) {
+				chunks = bytes.SplitAfter(p, []byte("\n"))
+			}
+			for _, c := range chunks {
+				if len(c) > 0 {
+					log.write(fd, c, w, inline)
+				}
+			}
+			return len(p), nil
+		})
+	}
+	cred := honeyos.Credential{UID: sys.CurrentUser(), Stdout: tap(1, sys.Out()), Stderr: tap(2, sys.Err())}
+	status, _ := honeyos.RunAs(sys, cred, argv)
+	log.exit(status)
+	return status
+}
+
+// attach traces the running processes, which needs root. The process is
+// found waiting in the call, and is left alone once interrupted
+func (t trace) attach(sys honeyos.Sys, log *traceLog, pids []string) int {
+	procs := map[int]honeyos.ProcInfo{}
+	for _, p := range sys.Processes() {
+		procs[p.PID] = p
+	}
+	var attached []honeyos.ProcInfo
+	for _, s := range pids {
+		pid, err := strconv.Atoi(s)
+		if err != nil {
+			fmt.Fprintf(sys.Err(), "%v: Invalid process id: '%v'\n", t.name, s)
+			return 1
+		}
+		p, ok := procs[pid]
+		reason := "Operation not permitted"
+		if !ok {
+			reason = "No such process"
+		}
+		// ptrace is limited to the descendants unless root, with yama
+		if !ok || !isRoot(sys) {
+			sys.Log().WithField("pid", pid).Infof("User failed to attach %v to process %v", t.name, pid)
+			if log.lib {
+				fmt.Fprintf(sys.Err(), "Cannot attach to pid %v: %v\n", pid, reason)
+			} else {
+				fmt.Fprintf(sys.Err(), "strace: attach: ptrace(PTRACE_SEIZE, %v): %v\n", pid, reason)
+			}
+			return 1
+		}
+		sys.Log().WithField("pid", pid).WithField("cmd", p.Cmd).Warnf("User attached %v to process %v", t.name, pid)
+		attached = append(attached, p)
+	}
+	for _, p := range attached {
+		if !log.lib {
+			fmt.Fprintf(sys.Err(), "strace: Process %v attached\n", p.PID)
+		}
+		if log.summary || log.lib || len(attached) > 1 {
+			continue
+		}
+		blocked := "restart_syscall(<... resuming interrupted poll ...>"
+		switch p.Comm() {
+		case "sshd":
+			blocked = "select(8, [3 4], NULL, NULL, NULL"
+		case "bash", "sh", "ash":
+			blocked = "wait4(-1, "
+		case "cron", "crond":
+			blocked = "nanosleep({60, 0}, "
+		case "rsyslogd", "syslogd":
+			blocked = "select(1, NULL, NULL, NULL, {542, 361888}"
+		}
+		fmt.Fprint(log.out, log.prefix()+blocked)
+	}
+	select {
+	case <-honeyos.Interrupt(sys):
+	case <-sys.Context().Done():
+	}
+	for _, p := range attached {
+		if !log.lib {
+			fmt.Fprintf(sys.Err(), "strace: Process %v detached\n <detached ...>\n", p.PID)
+		}
+	}
+	if log.summary {
+		log.report()
+	}
+	return 0
+}
+
+// filter takes the calls to trace from -e trace=open,write or the class
+// like file
+func (t *traceLog) filter(expr string) {
+	expr = strings.TrimPrefix(strings.TrimPrefix(expr, "trace="), "t=")
+	if strings.Contains(expr, "=") || expr == "all" {
+		return
+	}
+	t.only = map[string]bool{}
+	for _, name := range strings.Split(expr, ",") {
+		if class, ok := traceClasses[name]; ok {
+			for _, c := range class {
+				t.only[c] = true
+			}
+		}
+		t.only[name] = true
+	}
+}
+
+// prefix is the time of the call with -t, -tt and -ttt
+func (t *traceLog) prefix() string {
+	now := t.now()
+	switch t.stamps {
+	case 0:
+		return ""
+	case 1:
+		return now.Format("15:04:05 ")
+	case 2:
+		return now.Format("15:04:05.000000 ")
+	}
+	return fmt.Sprintf("%d.%06d ", now.Unix(), now.Nanosecond()/1000)
+}
+
+// traced counts the call and tells if it is to be written
+func (t *traceLog) traced(name, ret string) bool {
+	if t.only != nil && !t.only[name] {
+		return false
+	}
+	c, ok := t.counts[name]
+	if !ok {
+		c = &traceCount{}
+		t.counts[name] = c
+		t.order = append(t.order, name)
+	}
+	c.calls++
+	if strings.HasPrefix(ret, "-1 ") {
+		c.errors++
+	}
+	return !t.summary
+}
+
+// call writes the call with the value returned, aligned in the column as
+// strace and ltrace do
+func (t *traceLog) call(name, args, ret string) {
+	if !t.traced(name, ret) {
+		return
+	}
+	width := 39
+	if t.lib {
+		width = 45
+	}
+	fmt.Fprintf(t.out, "%-*v = %v\n", width, t.prefix()+name+"("+args+")", ret)
+}
+
+// unfinished writes the call not returning before the trace ends
+func (t *traceLog) unfinished(name, args string) {
+	if t.traced(name, "") {
+		fmt.Fprintf(t.out, "%v%v(%v <unfinished ...>\n", t.prefix(), name, args)
+	}
+}
+
+// quote quotes the bytes like C strings, cut to the string size
+func (t *traceLog) quote(data []byte) string {
+	cut := len(data) > t.strSize
+	if cut {
+		data = data[:t.strSize]
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, c := range data {
+		switch c {
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			if c < 32 || c >= 127 {
+				fmt.Fprintf(&b, `\%o`, c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	b.WriteByte('"')
+	if cut {
+		b.WriteString("...")
+	}
+	return b.String()
+}
+
+// addr is the address at the offset from where the libraries are mapped
+func (t *traceLog) addr(offset uint64) string {
+	return fmt.Sprintf("0x%x", t.base+offset)
+}
+
+// start traces the program loaded, linking with libc
+func (t *traceLog) start(exe string, argv []string, envs int, lang string) {
+	if t.lib {
+		t.unfinished("__libc_start_main", fmt.Sprintf("0x4028c0, %d, 0x7ffd%08x, 0x413bc0", len(argv), rand.Uint32()&^0xf))
+		if lang == "" {
+			lang = "C"
+		}
+		t.call("setlocale", `LC_ALL, ""`, t.quote([]byte(lang)))
+		name := path.Base(exe)
+		t.call("bindtextdomain", fmt.Sprintf(`"%v", "/usr/share/locale"`, name), `"/usr/share/locale"`)
+		t.call("textdomain", fmt.Sprintf(`"%v"`, name), fmt.Sprintf(`"%v"`, name))
+		t.call("__cxa_atexit", "0x405290, 0, 0, 0x736c6974756572", "0")
+		return
+	}
+	quoted := make([]string, len(argv))
+	for i, arg := range argv {
+		quoted[i] = t.quote([]byte(arg))
+	}
+	t.call("execve", fmt.Sprintf("%v, [%v], [/* %d vars */]", t.quote([]byte(exe)), strings.Join(quoted, ", "), envs), "0")
+	if honeyos.Distro() == "alpine" {
+		// musl has the loader in libc
+		t.call("arch_prctl", "ARCH_SET_FS, "+t.addr(0x1f6b48), "0")
+		t.call("set_tid_address", t.addr(0x1f6f88), strconv.Itoa(2000+rand.Intn(30000)))
+		return
+	}
+	libc := "/lib/x86_64-linux-gnu/libc.so.6"
+	if pkgFamily() == "rpm" {
+		libc = "/lib64/libc.so.6"
+	}
+	heap := fmt.Sprintf("0x%x", 0x1000000+rand.Intn(0x1000)<<12)
+	t.call("brk", "NULL", heap)
+	t.call("access", `"/etc/ld.so.nohwcap", F_OK`, "-1 ENOENT (No such file or directory)")
+	t.call("access", `"/etc/ld.so.preload", R_OK`, "-1 ENOENT (No such file or directory)")
+	t.call("open", `"/etc/ld.so.cache", O_RDONLY|O_CLOEXEC`, "3")
+	t.call("fstat", "3, {st_mode=S_IFREG|0644, st_size=26258, ...}", "0")
+	t.call("mmap", "NULL, 26258, PROT_READ, MAP_PRIVATE, 3, 0", t.addr(0x5ea000))
+	t.call("close", "3", "0")
+	t.call("access", `"/etc/ld.so.nohwcap", F_OK`, "-1 ENOENT (No such file or directory)")
+	t.call("open", t.quote([]byte(libc))+", O_RDONLY|O_CLOEXEC", "3")
+	t.call("read", `3, "\177ELF\2\1\1\3\0\0\0\0\0\0\0\0\3\0>\0\1\0\0\0P\t\2\0\0\0\0\0"..., 832`, "832")
+	t.call("fstat", "3, {st_mode=S_IFREG|0755, st_size=1868984, ...}", "0")
+	t.call("mmap", "NULL, 4096, PROT_READ|PROT_WRITE, MAP_PRIVATE|MAP_ANONYMOUS, -1, 0", t.addr(0x5e9000))
+	t.call("mmap", "NULL, 3971488, PROT_READ|PROT_EXEC, MAP_PRIVATE|MAP_DENYWRITE, 3, 0", t.addr(0))
+	t.call("mprotect", t.addr(0x1c0000)+", 2097152, PROT_NONE", "0")
+	t.call("mmap", t.addr(0x3c0000)+", 24576, PROT_READ|PROT_WRITE, MAP_PRIVATE|MAP_FIXED|MAP_DENYWRITE, 3, 0x1c0000", t.addr(0x3c0000))
+	t.call("mmap", t.addr(0x3c6000)+", 14752, PROT_READ|PROT_WRITE, MAP_PRIVATE|MAP_FIXED|MAP_ANONYMOUS, -1, 0", t.addr(0x3c6000))
+	t.call("close", "3", "0")
+	t.call("mmap", "NULL, 4096, PROT_READ|PROT_WRITE, MAP_PRIVATE|MAP_ANONYMOUS, -1, 0", t.addr(0x5e8000))
+	t.call("arch_prctl", "ARCH_SET_FS, "+t.addr(0x5e8700), "0")
+	t.call("mprotect", t.addr(0x3c0000)+", 16384, PROT_READ", "0")
+	t.call("mprotect", "0x61d000, 4096, PROT_READ", "0")
+	t.call("mprotect", t.addr(0x5f0000)+", 4096, PROT_READ", "0")
+	t.call("munmap", t.addr(0x5ea000)+", 26258", "0")
+	t.call("brk", "NULL", heap)
+}
+
+// open traces the file or directory named in the arguments read
+func (t *traceLog) open(sys honeyos.Sys, name string, fi os.FileInfo) {
+	if fi.IsDir() {
+		if t.lib {
+			t.call("opendir", t.quote([]byte(name)), "{ 3 }")
+			t.call("readdir", "{ 3 }", "0")
+			t.call("closedir", "{ 3 }", "0")
+			return
+		}
+		entries, _ := afero.ReadDir(sys.FSys(), absPath(sys, name))
+		t.call("openat", "AT_FDCWD, "+t.quote([]byte(name))+", O_RDONLY|O_NONBLOCK|O_DIRECTORY|O_CLOEXEC", "3")
+		t.call("fstat", "3, {st_mode=S_IFDIR|0755, st_size=4096, ...}", "0")
+		t.call("getdents", fmt.Sprintf("3, /* %d entries */, 32768", len(entries)+2), strconv.Itoa(48+len(entries)*32))
+		t.call("getdents", "3, /* 0 entries */, 32768", "0")
+		t.call("close", "3", "0")
+		return
+	}
+	data, err := readFile(sys, absPath(sys, name))
+	if err != nil {
+		if t.lib {
+			t.call("fopen", t.quote([]byte(name))+`, "r"`, "0")
+		} else {
+			t.call("open", t.quote([]byte(name))+", O_RDONLY", "-1 EACCES (Permission denied)")
+		}
+		return
+	}
+	if t.lib {
+		t.call("fopen", t.quote([]byte(name))+`, "r"`, t.addr(0x7c3010))
+		t.call("fread", fmt.Sprintf("%v, 1, 4096, %v", t.addr(0x7c4000), t.addr(0x7c3010)), strconv.Itoa(intMin(len(data), 4096)))
+		t.call("fclose", t.addr(0x7c3010), "0")
+		return
+	}
+	t.call("open", t.quote([]byte(name))+", O_RDONLY", "3")
+	t.call("fstat", fmt.Sprintf("3, {st_mode=S_IFREG|%04o, st_size=%d, ...}", fi.Mode().Perm(), fi.Size()), "0")
+	if len(data) > 0 {
+		t.call("read", fmt.Sprintf("3, %v, 131072", t.quote(data)), strconv.Itoa(len(data)))
+	}
+	t.call("read", `3, "", 131072`, "0")
+	t.call("close", "3", "0")
+}
+
+// write traces the output written to the stream, passing it on to w. Inline
+// writes the output between the arguments and the value returned, as it
+// comes on the same terminal
+func (t *traceLog) write(fd int, data []byte, w io.Writer, inline bool) {
+	name, args := "write", fmt.Sprintf("%d, %v, %d", fd, t.quote(data), len(data))
+	if t.lib {
+		stream := map[int]uint64{1: 0x3c5620, 2: 0x3c5540}[fd]
+		name, args = "fwrite", fmt.Sprintf("%v, 1, %d, %v", t.quote(data), len(data), t.addr(stream))
+	}
+	ret := strconv.Itoa(len(data))
+	if !inline || !t.traced(name, ret) {
+		w.Write(data)
+		if !inline {
+			t.call(name, args, ret)
+		}
+		return
+	}
+	fmt.Fprintf(t.out, "%v%v(%v", t.prefix(), name, args)
+	w.Write(data)
+	fmt.Fprintf(t.out, ") = %v\n", ret)
+}
+
+// exit traces the end of the program with the status, or the interrupt
+func (t *traceLog) exit(status int) {
+	if status == 130 {
+		if !t.summary {
+			if t.lib {
+				fmt.Fprint(t.out, "--- SIGINT (Interrupt) ---\n+++ killed by SIGINT +++\n")
+			} else {
+				fmt.Fprint(t.out, "--- SIGINT {si_signo=SIGINT, si_code=SI_KERNEL} ---\n+++ killed by SIGINT +++\n")
+			}
+		}
+	} else if t.lib {
+		t.unfinished("exit", strconv.Itoa(status))
+		t.call("__fpending", t.addr(0x3c5620)+", 0, 0x405290, "+t.addr(0x3c6c70), "0")
+		t.call("fclose", t.addr(0x3c5620), "0")
+		t.call("fclose", t.addr(0x3c5540), "0")
+		if !t.summary {
+			fmt.Fprintf(t.out, "+++ exited (status %d) +++\n", status)
+		}
+	} else {
+		t.call("close", "1", "0")
+		t.call("close", "2", "0")
+		t.call("exit_group", strconv.Itoa(status), "?")
+		if !t.summary {
+			fmt.Fprintf(t.out, "+++ exited with %d +++\n", status)
+		}
+	}
+	if t.summary {
+		t.report()
+	}
+}
+
+// report writes the summary of the calls counted, with the time each took
+// made up
+func (t *traceLog) report() {
+	usecs := map[string]int{}
+	total, calls, errors := 0, 0, 0
+	for _, name := range t.order {
+		c := t.counts[name]
+		per := 1 + rand.Intn(5)
+		if t.lib {
+			per = 60 + rand.Intn(200)
+		}
+		usecs[name] = per * c.calls
+		total += usecs[name]
+		calls += c.calls
+		errors += c.errors
+	}
+	percent := func(n int) float64 {
+		if total == 0 {
+			return 0
+		}
+		return float64(n) * 100 / float64(total)
+	}
+	if t.lib {
+		fmt.Fprintln(t.out, "% time     seconds  usecs/call     calls      function\n"+
+			"------ ----------- ----------- --------- --------------------")
+	} else {
+		fmt.Fprintln(t.out, "% time     seconds  usecs/call     calls    errors syscall\n"+
+			"------ ----------- ----------- --------- --------- ----------------")
+	}
+	for _, name := range t.order {
+		c := t.counts[name]
+		line := fmt.Sprintf("%6.2f %11.6f %11d %9d", percent(usecs[name]), float64(usecs[name])/1e6, usecs[name]/c.calls, c.calls)
+		switch {
+		case t.lib:
+			fmt.Fprintf(t.out, "%v %v\n", line, name)
+		case c.errors > 0:
+			fmt.Fprintf(t.out, "%v %9d %v\n", line, c.errors, name)
+		default:
+			fmt.Fprintf(t.out, "%v %9v %v\n", line, "", name)
+		}
+	}
+	if t.lib {
+		fmt.Fprintf(t.out, "------ ----------- ----------- --------- --------------------\n"+
+			"100.00 %11.6f             %9d total\n", float64(total)/1e6, calls)
+	} else {
+		fmt.Fprintf(t.out, "------ ----------- ----------- --------- --------- ----------------\n"+
+			"100.00 %11.6f             %9d %9v total\n", float64(total)/1e6, calls, map[bool]string{true: strconv.Itoa(errors)}[errors > 0])
+	}
+}
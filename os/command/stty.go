@@ -0,0 +1,364 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mkishere/sshsyrup/os"
+)
+
+type stty struct{}
+
+func init() {
+	os.RegisterCommand("stty", stty{})
+}
+
+// termBits are the bits of termios flags, for index of the flag groups in
+// os.TermFlags
+var termBits = []map[string]uint32{
+	{"parenb": 0400, "parodd": 01000, "cmspar": 010000000000, "cs8": 060, "hupcl": 02000,
+		"cstopb": 0100, "cread": 0200, "clocal": 04000, "crtscts": 020000000000},
+	{"ignbrk": 01, "brkint": 02, "ignpar": 04, "parmrk": 010, "inpck": 020, "istrip": 040,
+		"inlcr": 0100, "igncr": 0200, "icrnl": 0400, "iuclc": 01000, "ixon": 02000, "ixany": 04000,
+		"ixoff": 010000, "imaxbel": 020000, "iutf8": 040000},
+	{"opost": 01, "olcuc": 02, "onlcr": 04, "ocrnl": 010, "onocr": 020, "onlret": 040, "ofill": 0100,
+		"ofdel": 0200},
+	{"isig": 01, "icanon": 02, "xcase": 04, "echo": 010, "echoe": 020, "echok": 040, "echonl": 0100,
+		"noflsh": 0200, "tostop": 0400, "echoctl": 01000, "echoprt": 02000, "echoke": 04000,
+		"flusho": 010000, "extproc": 0200000, "iexten": 0100000},
+}
+
+// ttyCharIndex is the index of special characters in c_cc of Linux
+var ttyCharIndex = map[string]int{
+	"intr": 0, "quit": 1, "erase": 2, "kill": 3, "eof": 4, "time": 5, "min": 6, "swtch": 7,
+	"start": 8, "stop": 9, "susp": 10, "eol": 11, "rprnt": 12, "discard": 13, "werase": 14,
+	"lnext": 15, "eol2": 16,
+}
+
+// baudBits are the speed bits in c_cflag
+var baudBits = map[int]uint32{
+	0: 0, 50: 01, 75: 02, 110: 03, 134: 04, 150: 05, 200: 06, 300: 07, 600: 010, 1200: 011,
+	1800: 012, 2400: 013, 4800: 014, 9600: 015, 19200: 016, 38400: 017, 57600: 010001,
+	115200: 010002, 230400: 010003,
+}
+
+// sttyRaw and sttyCooked are the flags changed by stty raw and stty cooked
+var (
+	sttyRaw = []string{"-ignbrk", "-brkint", "-ignpar", "-parmrk", "-inpck", "-istrip", "-inlcr",
+		"-igncr", "-icrnl", "-ixon", "-ixoff", "-icanon", "-opost", "-isig", "-iuclc", "-ixany",
+		"-imaxbel", "-xcase"}
+	sttyCooked = []string{"brkint", "ignpar", "istrip", "icrnl", "ixon", "opost", "isig", "icanon"}
+)
+
+func (stty) GetHelp() string {
+	return ""
+}
+
+func (stty) Where() string {
+	return "/bin/stty"
+}
+
+func (c stty) Exec(args []string, sys os.Sys) int {
+	settings := []string{}
+	mode := ""
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "-a" || arg == "--all" || arg == "-g" || arg == "--save":
+			mode = arg[len(arg)-1:]
+			if arg == "--save" {
+				mode = "g"
+			}
+		case arg == "-F" || arg == "--file" || strings.HasPrefix(arg, "--file="):
+			dev := strings.TrimPrefix(arg, "--file=")
+			if dev == arg {
+				if i+1 >= len(args) {
+					fmt.Fprintf(sys.Err(), "stty: option requires an argument -- 'F'\nTry 'stty --help' for more information.\n")
+					return 1
+				}
+				i++
+				dev = args[i]
+			}
+			if dev != "/dev/tty" && !strings.HasPrefix(dev, "/dev/pts/") {
+				fmt.Fprintf(sys.Err(), "stty: %v: No such file or directory\n", dev)
+				return 1
+			}
+		default:
+			settings = append(settings, arg)
+		}
+	}
+	t := sys.Termios()
+	if t == nil {
+		fmt.Fprintln(sys.Err(), "stty: 'standard input': Inappropriate ioctl for device")
+		return 1
+	}
+	switch {
+	case mode == "a":
+		c.printAll(t, sys)
+		return 0
+	case mode == "g":
+		fmt.Fprintln(sys.Out(), c.save(t))
+		return 0
+	case len(settings) == 0:
+		c.printChanged(t, sys)
+		return 0
+	}
+	return c.apply(t, settings, sys)
+}
+
+func (c stty) apply(t *os.Termios, settings []string, sys os.Sys) int {
+	invalid := func(arg string) int {
+		fmt.Fprintf(sys.Err(), "stty: invalid argument ‘%v’\nTry 'stty --help' for more information.\n", arg)
+		return 1
+	}
+	for i := 0; i < len(settings); i++ {
+		arg := settings[i]
+		// Settings that take a value
+		needValue := func() (string, bool) {
+			if i+1 >= len(settings) {
+				fmt.Fprintf(sys.Err(), "stty: missing argument to ‘%v’\nTry 'stty --help' for more information.\n", arg)
+				return "", false
+			}
+			i++
+			return settings[i], true
+		}
+		switch {
+		case arg == "size":
+			rows, cols := t.Size()
+			fmt.Fprintf(sys.Out(), "%v %v\n", rows, cols)
+		case arg == "speed":
+			_, ospeed := t.Speed()
+			fmt.Fprintln(sys.Out(), ospeed)
+		case arg == "sane":
+			t.Sane()
+		case arg == "raw" || arg == "-cooked":
+			c.setFlags(t, sttyRaw)
+			t.SetChar("min", 1)
+			t.SetChar("time", 0)
+		case arg == "-raw" || arg == "cooked":
+			c.setFlags(t, sttyCooked)
+		case arg == "cbreak":
+			t.SetFlag("icanon", false)
+		case arg == "-cbreak":
+			t.SetFlag("icanon", true)
+		case arg == "rows" || arg == "cols" || arg == "columns":
+			v, ok := needValue()
+			if !ok {
+				return 1
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return invalid(v)
+			}
+			rows, cols := t.Size()
+			if arg == "rows" {
+				rows = n
+			} else {
+				cols = n
+			}
+			t.SetSize(rows, cols)
+		case arg == "ispeed" || arg == "ospeed":
+			v, ok := needValue()
+			if !ok {
+				return 1
+			}
+			n, err := strconv.Atoi(v)
+			if _, valid := baudBits[n]; err != nil || !valid {
+				return invalid(v)
+			}
+			if arg == "ispeed" {
+				t.SetSpeed(n, 0)
+			} else {
+				t.SetSpeed(0, n)
+			}
+		case strings.Contains(arg, ":"):
+			if !c.restore(t, arg) {
+				return invalid(arg)
+			}
+		default:
+			if n, err := strconv.Atoi(arg); err == nil {
+				if _, valid := baudBits[n]; !valid {
+					return invalid(arg)
+				}
+				t.SetSpeed(n, n)
+				continue
+			}
+			if _, isChar := ttyCharIndex[arg]; isChar {
+				v, ok := needValue()
+				if !ok {
+					return 1
+				}
+				ch, valid := parseTermChar(arg, v)
+				if !valid {
+					return invalid(v)
+				}
+				t.SetChar(arg, ch)
+				continue
+			}
+			if !t.SetFlag(strings.TrimPrefix(arg, "-"), !strings.HasPrefix(arg, "-")) {
+				return invalid(arg)
+			}
+		}
+	}
+	return 0
+}
+
+func (stty) setFlags(t *os.Termios, flags []string) {
+	for _, f := range flags {
+		t.SetFlag(strings.TrimPrefix(f, "-"), !strings.HasPrefix(f, "-"))
+	}
+}
+
+// parseTermChar parses the value of special character, e.g. ^C, ^? or undef
+func parseTermChar(name, v string) (byte, bool) {
+	if name == "min" || name == "time" {
+		n, err := strconv.ParseUint(v, 10, 8)
+		return byte(n), err == nil
+	}
+	switch {
+	case v == "undef" || v == "^-":
+		return 0, true
+	case v == "^?":
+		return 0x7f, true
+	case len(v) == 2 && v[0] == '^':
+		return strings.ToUpper(v)[1] & 0x1f, true
+	case len(v) == 1:
+		return v[0], true
+	}
+	return 0, false
+}
+
+// formatTermChar formats the special character the way stty prints it
+func formatTermChar(name string, c byte) string {
+	switch {
+	case name == "min" || name == "time":
+		return strconv.Itoa(int(c))
+	case c == 0:
+		return "<undef>"
+	case c == 0x7f:
+		return "^?"
+	case c < 0x20:
+		return "^" + string(c+'@')
+	}
+	return string(c)
+}
+
+func (stty) printAll(t *os.Termios, sys os.Sys) {
+	rows, cols := t.Size()
+	_, ospeed := t.Speed()
+	fmt.Fprintf(sys.Out(), "speed %v baud; rows %v; columns %v; line = 0;\n", ospeed, rows, cols)
+	var buf bytes.Buffer
+	for i, name := range os.TermChars {
+		fmt.Fprintf(&buf, "%v = %v;", name, formatTermChar(name, t.Char(name)))
+		if i == 9 || i == len(os.TermChars)-1 {
+			buf.WriteString("\n")
+		} else {
+			buf.WriteString(" ")
+		}
+	}
+	sys.Out().Write(buf.Bytes())
+	for i, group := range os.TermFlags {
+		var flags []string
+		for _, f := range group {
+			switch {
+			case f == "cs8" && t.Flag(f):
+				flags = append(flags, "cs8")
+			case f == "cs8":
+				flags = append(flags, "cs7")
+			case t.Flag(f):
+				flags = append(flags, f)
+			default:
+				flags = append(flags, "-"+f)
+			}
+		}
+		if i == 2 {
+			flags = append(flags, "nl0", "cr0", "tab0", "bs0", "vt0", "ff0")
+		}
+		fmt.Fprintln(sys.Out(), strings.Join(flags, " "))
+	}
+}
+
+// printChanged prints the settings different from stty sane, which is what
+// stty without argument does
+func (stty) printChanged(t *os.Termios, sys os.Sys) {
+	_, ospeed := t.Speed()
+	fmt.Fprintf(sys.Out(), "speed %v baud; line = 0;\n", ospeed)
+	sane := map[string]bool{"brkint": true, "imaxbel": true}
+	for _, f := range []string{"cs8", "cread", "icrnl", "ixon", "opost", "onlcr", "isig", "icanon",
+		"iexten", "echo", "echoe", "echok", "echoctl", "echoke"} {
+		sane[f] = true
+	}
+	var changed []string
+	for _, group := range os.TermFlags {
+		for _, f := range group {
+			if on := t.Flag(f); on != sane[f] {
+				if !on {
+					f = "-" + f
+				}
+				changed = append(changed, f)
+			}
+		}
+	}
+	if len(changed) > 0 {
+		fmt.Fprintln(sys.Out(), strings.Join(changed, " "))
+	}
+}
+
+// save formats the settings like stty -g, which can be passed to stty
+// later to restore them
+func (stty) save(t *os.Termios) string {
+	var flags [4]uint32
+	for i, group := range os.TermFlags {
+		for _, f := range group {
+			if t.Flag(f) {
+				flags[i] |= termBits[i][f]
+			}
+		}
+	}
+	_, ospeed := t.Speed()
+	flags[0] |= baudBits[ospeed]
+	// Fields are iflag, oflag, cflag and lflag, then 32 characters
+	fields := []string{
+		strconv.FormatUint(uint64(flags[1]), 16), strconv.FormatUint(uint64(flags[2]), 16),
+		strconv.FormatUint(uint64(flags[0]), 16), strconv.FormatUint(uint64(flags[3]), 16),
+	}
+	var cc [32]byte
+	for name, i := range ttyCharIndex {
+		cc[i] = t.Char(name)
+	}
+	for _, c := range cc {
+		fields = append(fields, strconv.FormatUint(uint64(c), 16))
+	}
+	return strings.Join(fields, ":")
+}
+
+// restore applies the settings saved by stty -g
+func (stty) restore(t *os.Termios, saved string) bool {
+	fields := strings.Split(saved, ":")
+	if len(fields) < 4 {
+		return false
+	}
+	var values []uint64
+	for _, f := range fields {
+		v, err := strconv.ParseUint(f, 16, 32)
+		if err != nil {
+			return false
+		}
+		values = append(values, v)
+	}
+	// Flag groups are stored as cflag, iflag, oflag and lflag in os.TermFlags
+	order := []int{2, 0, 1, 3}
+	for i, group := range os.TermFlags {
+		v := uint32(values[order[i]])
+		for _, f := range group {
+			t.SetFlag(f, v&termBits[i][f] == termBits[i][f])
+		}
+	}
+	for name, i := range ttyCharIndex {
+		if 4+i < len(values) {
+			t.SetChar(name, byte(values[4+i]))
+		}
+	}
+	return true
+}
@@ -0,0 +1,143 @@
+package command
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	log "github.com/sirupsen/logrus"
+)
+
+// su switches to another user, root by default. The password is checked
+// against the account as login does, so the password guessed by attackers
+// for root works the same
+type su struct{}
+
+func init() {
+	honeyos.RegisterCommand("su", su{})
+}
+
+func (su) GetHelp() string {
+	return `Usage: su [options] [LOGIN]
+
+Options:
+  -c, --command COMMAND         pass COMMAND to the invoked shell
+  -h, --help                    display this help message and exit
+  -, -l, --login                make the shell a login shell
+  -m, -p,
+  --preserve-environment        do not reset environment variables, and
+                                keep the same shell
+  -s, --shell SHELL             use SHELL instead of the default in passwd
+
+`
+}
+
+func (su) Where() string {
+	return "/bin/su"
+}
+
+func (s su) Exec(args []string, sys honeyos.Sys) int {
+	login, preserve := false, false
+	command, shell, name := "", "", ""
+	var shellArgs []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case name != "":
+			shellArgs = append(shellArgs, arg)
+		case arg == "-" || arg == "-l" || arg == "--login":
+			login = true
+		case arg == "-m" || arg == "-p" || arg == "--preserve-environment":
+			preserve = true
+		case arg == "-c" || arg == "--command" || arg == "-s" || arg == "--shell":
+			if i+1 >= len(args) {
+				fmt.Fprintf(sys.Err(), "su: option requires an argument -- '%v'\n", strings.TrimLeft(arg, "-")[:1])
+				fmt.Fprint(sys.Err(), s.GetHelp())
+				return 1
+			}
+			i++
+			if arg[len(arg)-1] == 'c' || arg == "--command" {
+				command = args[i]
+			} else {
+				shell = args[i]
+			}
+		case strings.HasPrefix(arg, "--command="):
+			command = strings.TrimPrefix(arg, "--command=")
+		case strings.HasPrefix(arg, "--shell="):
+			shell = strings.TrimPrefix(arg, "--shell=")
+		case arg == "-h" || arg == "--help":
+			fmt.Fprint(sys.Out(), s.GetHelp())
+			return 0
+		case strings.HasPrefix(arg, "-"):
+			fmt.Fprintf(sys.Err(), "su: invalid option -- '%v'\n", strings.TrimLeft(arg, "-"))
+			fmt.Fprint(sys.Err(), s.GetHelp())
+			return 1
+		default:
+			name = arg
+		}
+	}
+	if name == "" {
+		name = "root"
+	}
+	self := honeyos.GetUserByID(sys.CurrentUser())
+	target := honeyos.GetUser(name)
+	if target.Name == "" {
+		fmt.Fprintf(sys.Err(), "No passwd entry for user '%v'\n", name)
+		return 1
+	}
+	logger := sys.Log().WithFields(log.Fields{"suUser": self.Name, "target": name, "login": login, "command": command})
+	if self.UID != 0 {
+		if !honeyos.IsTerminal(sys.In()) {
+			fmt.Fprintln(sys.Err(), "su: must be run from a terminal")
+			return 1
+		}
+		pass, _ := readPassword(sys, "Password: ")
+		stored, _ := honeyos.IsUserExist(name)
+		accepted := pass != "" && (stored == pass || stored == "*")
+		logger.WithField("password", pass).WithField("accepted", accepted).Infof("User typed su password of %v", name)
+		if !accepted {
+			pkgSleep(sys, 3*time.Second)
+			if pkgFamily() == "apk" {
+				fmt.Fprintln(sys.Err(), "su: incorrect password")
+			} else {
+				fmt.Fprintln(sys.Err(), "su: Authentication failure")
+			}
+			return 1
+		}
+	}
+	if shell == "" || !preserve && self.UID != 0 {
+		shell = target.Shell
+	}
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+	if base := path.Base(shell); base == "nologin" || base == "false" {
+		if base == "nologin" {
+			fmt.Fprintln(sys.Out(), "This account is currently not available.")
+		}
+		return 1
+	}
+	cred := honeyos.Credential{UID: target.UID, Login: login}
+	if !login && !preserve {
+		cred.Env = map[string]string{"HOME": target.Homedir, "SHELL": shell, "USER": target.Name, "LOGNAME": target.Name}
+		if target.UID == 0 {
+			cred.Env["PATH"] = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+		}
+	}
+	var cmd []string
+	switch {
+	case command != "":
+		cmd = append([]string{shell, "-c", command}, shellArgs...)
+	case len(shellArgs) > 0:
+		cmd = append([]string{shell}, shellArgs...)
+	}
+	logger.Infof("User switched to %v with su", name)
+	n, found := honeyos.RunAs(sys, cred, cmd)
+	if !found {
+		fmt.Fprintf(sys.Err(), "su: failed to execute %v: No such file or directory\n", shell)
+		return 1
+	}
+	return n
+}
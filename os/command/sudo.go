@@ -0,0 +1,409 @@
+package command
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+)
+
+// sudo runs commands as root. Who may use it and which passwords it takes
+// are set in persona config, and every attempt is logged
+type sudo struct{}
+
+// sudoOptions are the options of sudo
+type sudoOptions struct {
+	user                 string
+	login, shell, list   bool
+	validate, invalidate bool
+	stdin, nonInteract   bool
+	setHome              bool
+	prompt               string
+}
+
+const sudoLecture = `
+We trust you have received the usual lecture from the local System
+Administrator. It usually boils down to these three things:
+
+    #1) Respect the privacy of others.
+    #2) Think before you type.
+    #3) With great power comes great responsibility.
+
+`
+
+const sudoUsage = `usage: sudo -h | -K | -k | -V
+usage: sudo -v [-AknS] [-g group] [-h host] [-p prompt] [-u user]
+usage: sudo -l [-AknS] [-g group] [-h host] [-p prompt] [-U user] [-u user]
+            [command]
+usage: sudo [-AbEHknPS] [-r role] [-t type] [-C num] [-g group] [-h host] [-p
+            prompt] [-T timeout] [-u user] [VAR=value] [-i|-s] [<command>]
+usage: sudo -e [-AknS] [-r role] [-t type] [-C num] [-g group] [-h host] [-p
+            prompt] [-T timeout] [-u user] file ...
+`
+
+// sudoTimeout is how long sudo remembers the password, as timestamp_timeout
+const sudoTimeout = 15 * time.Minute
+
+func init() {
+	honeyos.RegisterCommand("sudo", sudo{})
+}
+
+func (sudo) GetHelp() string {
+	return ""
+}
+
+func (sudo) Where() string {
+	return "/usr/bin/sudo"
+}
+
+func (s sudo) Exec(args []string, sys honeyos.Sys) int {
+	if pkgFamily() == "apk" && !loadPkgDB(sys, "apk").installed("sudo") {
+		return honeyos.CommandNotFound(sys, append([]string{"sudo"}, args...))
+	}
+	opt := sudoOptions{user: "root"}
+	long := map[string]byte{"login": 'i', "shell": 's', "list": 'l', "validate": 'v', "reset-timestamp": 'k',
+		"remove-timestamp": 'K', "stdin": 'S', "non-interactive": 'n', "set-home": 'H', "user": 'u',
+		"prompt": 'p', "group": 'g', "preserve-env": 'E', "background": 'b', "version": 'V', "help": 'h'}
+	i := 0
+	for ; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			i++
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			break
+		}
+		flags := arg
+		if strings.HasPrefix(arg, "--") {
+			opt := strings.SplitN(arg[2:], "=", 2)
+			c, ok := long[opt[0]]
+			if !ok {
+				fmt.Fprintf(sys.Err(), "sudo: unrecognized option '%v'\n", arg)
+				fmt.Fprint(sys.Err(), sudoUsage)
+				return 1
+			}
+			flags = "-" + string(c)
+			if len(opt) > 1 {
+				flags += opt[1]
+			}
+		}
+		for j := 1; j < len(flags); j++ {
+			c := flags[j]
+			if strings.IndexByte("upgUCrtT", c) >= 0 {
+				val := flags[j+1:]
+				if val == "" {
+					if i+1 >= len(args) {
+						fmt.Fprintf(sys.Err(), "sudo: option requires an argument -- '%c'\n", c)
+						fmt.Fprint(sys.Err(), sudoUsage)
+						return 1
+					}
+					i++
+					val = args[i]
+				}
+				switch c {
+				case 'u':
+					opt.user = val
+				case 'p':
+					opt.prompt = val
+				}
+				break
+			}
+			switch c {
+			case 'i':
+				opt.login = true
+			case 's':
+				opt.shell = true
+			case 'l':
+				opt.list = true
+			case 'v':
+				opt.validate = true
+			case 'k', 'K':
+				opt.invalidate = true
+			case 'S':
+				opt.stdin = true
+			case 'n':
+				opt.nonInteract = true
+			case 'H':
+				opt.setHome = true
+			case 'E', 'b', 'A', 'P':
+			case 'V':
+				s.version(sys)
+				return 0
+			case 'h':
+				fmt.Fprintf(sys.Out(), "sudo - execute a command as another user\n\n%v", sudoUsage)
+				return 0
+			default:
+				fmt.Fprintf(sys.Err(), "sudo: invalid option -- '%c'\n", c)
+				fmt.Fprint(sys.Err(), sudoUsage)
+				return 1
+			}
+		}
+	}
+	// Assignments before the command go to its environment
+	env := map[string]string{}
+	for ; i < len(args) && strings.Contains(args[i], "=") && !strings.HasPrefix(args[i], "="); i++ {
+		kv := strings.SplitN(args[i], "=", 2)
+		env[kv[0]] = kv[1]
+	}
+	cmd := args[i:]
+	if opt.login && opt.shell {
+		fmt.Fprintln(sys.Err(), "sudo: you may not specify both the `-i' and `-s' options")
+		fmt.Fprint(sys.Err(), sudoUsage)
+		return 1
+	}
+	if len(cmd) == 0 && !opt.login && !opt.shell && !opt.list && !opt.validate && !opt.invalidate {
+		fmt.Fprint(sys.Err(), sudoUsage)
+		return 1
+	}
+
+	self := honeyos.GetUserByID(sys.CurrentUser())
+	target, ok := s.targetUser(opt.user)
+	if !ok {
+		fmt.Fprintf(sys.Err(), "sudo: unknown user: %v\nsudo: unable to initialize policy plugin\n", strings.TrimPrefix(opt.user, "#"))
+		return 1
+	}
+	logger := sys.Log().WithFields(log.Fields{"sudoUser": self.Name, "runAs": target.Name, "args": cmd})
+	if opt.invalidate {
+		honeyos.SetUIDFs(sys.FSys()).Remove("/run/sudo/ts/" + self.Name)
+		if len(cmd) == 0 && !opt.login && !opt.shell && !opt.list && !opt.validate {
+			return 0
+		}
+	}
+	if !s.authenticate(sys, opt, self, logger) {
+		return 1
+	}
+	allowed := sudoAllowed(self)
+	host := strings.SplitN(sys.Hostname(), ".", 2)[0]
+	if opt.list {
+		return s.listPrivileges(sys, self, host, allowed, cmd)
+	}
+	if !allowed {
+		logger.Infof("User not in sudoers tried to run %v as %v", strings.Join(cmd, " "), target.Name)
+		fmt.Fprintf(sys.Err(), "%v is not in the sudoers file.  This incident will be reported.\n", self.Name)
+		return 1
+	}
+	if opt.validate && len(cmd) == 0 {
+		return 0
+	}
+
+	shell := target.Shell
+	if shell == "" {
+		shell = "/bin/bash"
+	}
+	switch {
+	case (opt.login || opt.shell) && len(cmd) > 0:
+		cmd = []string{shell, "-c", strings.Join(cmd, " ")}
+	case opt.shell:
+		cmd = nil
+	}
+	env["SUDO_USER"] = self.Name
+	env["SUDO_UID"] = strconv.Itoa(self.UID)
+	env["SUDO_GID"] = strconv.Itoa(self.GID)
+	env["SUDO_COMMAND"] = strings.Join(cmd, " ")
+	if len(cmd) == 0 {
+		env["SUDO_COMMAND"] = shell
+	}
+	if !opt.login {
+		env["USER"], env["LOGNAME"], env["USERNAME"] = target.Name, target.Name, target.Name
+		env["PATH"] = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin:/snap/bin"
+		if pkgFamily() == "rpm" {
+			env["PATH"] = "/sbin:/bin:/usr/sbin:/usr/bin"
+		}
+		// Ubuntu keeps HOME of the user unless -H is given
+		if opt.setHome || pkgFamily() != "deb" {
+			env["HOME"] = target.Homedir
+		}
+		if len(cmd) == 0 {
+			env["SHELL"] = shell
+		}
+	}
+	logger.Infof("User ran %v with sudo as %v", env["SUDO_COMMAND"], target.Name)
+	n, found := honeyos.RunAs(sys, honeyos.Credential{UID: target.UID, Login: opt.login, Env: env}, cmd)
+	if !found {
+		fmt.Fprintf(sys.Err(), "sudo: %v: command not found\n", cmd[0])
+		return 1
+	}
+	return n
+}
+
+// targetUser finds the user to run as, given by name or #uid
+func (sudo) targetUser(name string) (honeyos.User, bool) {
+	if strings.HasPrefix(name, "#") {
+		uid, err := strconv.Atoi(name[1:])
+		if err != nil {
+			return honeyos.User{}, false
+		}
+		u := honeyos.GetUserByID(uid)
+		if u.Name == "" {
+			// Users without account can be run as by uid
+			u = honeyos.User{UID: uid, GID: uid, Name: name, Homedir: "/", Shell: "/bin/sh"}
+		}
+		return u, true
+	}
+	u := honeyos.GetUser(name)
+	return u, u.Name != ""
+}
+
+// authenticate asks the password of the user unless it was typed lately.
+// Root never needs to
+func (sudo) authenticate(sys honeyos.Sys, opt sudoOptions, self honeyos.User, logger *log.Entry) bool {
+	fs := honeyos.SetUIDFs(sys.FSys())
+	ts := "/run/sudo/ts/" + self.Name
+	if self.UID == 0 || viper.GetBool("persona.sudo.nopasswd") {
+		return true
+	}
+	if fi, err := fs.Stat(ts); err == nil && !opt.invalidate && time.Since(fi.ModTime()) < sudoTimeout {
+		fs.Chtimes(ts, time.Now(), time.Now())
+		return true
+	}
+	if opt.nonInteract {
+		fmt.Fprintln(sys.Err(), "sudo: a password is required")
+		return false
+	}
+	if !opt.stdin && !honeyos.IsTerminal(sys.In()) {
+		fmt.Fprintln(sys.Err(), "sudo: no tty present and no askpass program specified")
+		return false
+	}
+	lectured := "/var/lib/sudo/lectured/" + self.Name
+	if exists, _ := afero.Exists(fs, lectured); !exists {
+		fmt.Fprint(sys.Err(), sudoLecture)
+		fs.MkdirAll(path.Dir(lectured), 0700)
+		afero.WriteFile(fs, lectured, nil, 0600)
+	}
+	prompt := "[sudo] password for %p: "
+	if pkgFamily() == "rpm" {
+		prompt = "[sudo] password for %u: "
+	}
+	if opt.prompt != "" {
+		prompt = opt.prompt
+	}
+	prompt = strings.NewReplacer("%p", self.Name, "%u", self.Name, "%U", opt.user,
+		"%h", strings.SplitN(sys.Hostname(), ".", 2)[0], "%H", sys.Hostname(), "%%", "%").Replace(prompt)
+	for tries := 1; tries <= 3; tries++ {
+		pass, ok := readPassword(sys, prompt)
+		if !ok && pass == "" {
+			if tries == 1 {
+				fmt.Fprintln(sys.Err(), "sudo: no password was provided")
+			} else {
+				fmt.Fprintf(sys.Err(), "sudo: %v incorrect password attempt", tries-1)
+				if tries > 2 {
+					fmt.Fprint(sys.Err(), "s")
+				}
+				fmt.Fprintln(sys.Err())
+			}
+			return false
+		}
+		accepted := sudoPassword(self, pass)
+		logger.WithField("password", pass).WithField("accepted", accepted).Infof("User typed sudo password of %v", self.Name)
+		if accepted {
+			// Only users in sudoers get the timestamp
+			if sudoAllowed(self) {
+				fs.MkdirAll(path.Dir(ts), 0700)
+				afero.WriteFile(fs, ts, nil, 0600)
+			}
+			return true
+		}
+		if !pkgSleep(sys, 2*time.Second) {
+			return false
+		}
+		if tries < 3 {
+			fmt.Fprintln(sys.Err(), "Sorry, try again.")
+		}
+	}
+	fmt.Fprintln(sys.Err(), "sudo: 3 incorrect password attempts")
+	return false
+}
+
+// sudoAllowed tells if the user is in the sudoers, which are members of the
+// admin group of the distribution and the users listed in persona config
+func sudoAllowed(u honeyos.User) bool {
+	if u.UID == 0 {
+		return true
+	}
+	for _, name := range viper.GetStringSlice("persona.sudo.users") {
+		if name == u.Name || name == "ALL" {
+			return true
+		}
+	}
+	for _, name := range []string{"sudo", "admin", "wheel"} {
+		g, ok := honeyos.GetGroup(name)
+		if !ok || name == "wheel" && pkgFamily() != "rpm" || name != "wheel" && pkgFamily() == "rpm" {
+			continue
+		}
+		if u.GID == g.GID {
+			return true
+		}
+		for _, m := range g.Userlist {
+			if m == u.Name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sudoPassword tells if sudo takes the password, as set in persona.sudo.password:
+// any takes every password, login takes the password of the account, and
+// none rejects all to collect more guesses
+func sudoPassword(u honeyos.User, pass string) bool {
+	switch viper.GetString("persona.sudo.password") {
+	case "none":
+		return false
+	case "login":
+		stored, _ := honeyos.IsUserExist(u.Name)
+		return stored == pass || stored == "*" && pass != ""
+	}
+	return pass != ""
+}
+
+func (sudo) listPrivileges(sys honeyos.Sys, u honeyos.User, host string, allowed bool, cmd []string) int {
+	if !allowed {
+		fmt.Fprintf(sys.Err(), "Sorry, user %v may not run sudo on %v.\n", u.Name, host)
+		return 1
+	}
+	if len(cmd) > 0 {
+		// The command is allowed, which is shown with its path
+		fmt.Fprintln(sys.Out(), cmd[0])
+		return 0
+	}
+	fmt.Fprintf(sys.Out(), "Matching Defaults entries for %v on %v:\n", u.Name, host)
+	if pkgFamily() == "rpm" {
+		fmt.Fprintln(sys.Out(), `    !visiblepw, always_set_home, match_group_by_gid, always_query_group_plugin, env_reset,
+    env_keep="COLORS DISPLAY HOSTNAME HISTSIZE KDEDIR LS_COLORS", env_keep+="MAIL PS1 PS2 QTDIR
+    USERNAME LANG LC_ADDRESS LC_CTYPE", secure_path=/sbin\:/bin\:/usr/sbin\:/usr/bin`)
+	} else {
+		fmt.Fprintln(sys.Out(), `    env_reset, mail_badpass, secure_path=/usr/local/sbin\:/usr/local/bin\:/usr/sbin\:/usr/bin\:/sbin\:/bin\:/snap/bin`)
+	}
+	fmt.Fprintf(sys.Out(), "\nUser %v may run the following commands on %v:\n", u.Name, host)
+	switch {
+	case viper.GetBool("persona.sudo.nopasswd"):
+		fmt.Fprintln(sys.Out(), "    (ALL : ALL) NOPASSWD: ALL")
+	case pkgFamily() == "rpm":
+		fmt.Fprintln(sys.Out(), "    (ALL) ALL")
+	default:
+		fmt.Fprintln(sys.Out(), "    (ALL : ALL) ALL")
+	}
+	return 0
+}
+
+func (sudo) version(sys honeyos.Sys) {
+	v := map[string]string{"rpm": "1.8.23", "apk": "1.9.8p2"}[pkgFamily()]
+	if v == "" {
+		v = "1.8.16"
+		if honeyos.Distro() == "debian" {
+			v = "1.8.19p1"
+		}
+	}
+	fmt.Fprintf(sys.Out(), "Sudo version %v\n", v)
+	if sys.CurrentUser() == 0 {
+		fmt.Fprintf(sys.Out(), "Configure options: --prefix=/usr --sysconfdir=/etc\nSudoers policy plugin version %v\n"+
+			"Sudoers file grammar version 45\nSudoers I/O plugin version %v\n", v, v)
+	}
+}
@@ -0,0 +1,83 @@
+package command
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+// testSys is the system the commands run on in tests, with the input given
+// and the output captured
+type testSys struct {
+	honeyos.Sys
+	in       io.Reader
+	out, err bytes.Buffer
+}
+
+func (s *testSys) In() io.Reader  { return s.in }
+func (s *testSys) Out() io.Writer { return &s.out }
+func (s *testSys) Err() io.Writer { return &s.err }
+
+// newTestSys returns the system of root on an empty filesystem, reading
+// stdin from the string
+func newTestSys(stdin string) *testSys {
+	honeyos.AddUser(honeyos.User{Name: "root", UID: 0, GID: 0, Homedir: "/root", Shell: "/bin/bash"})
+	fs := honeyos.NewOwnerFs(afero.NewMemMapFs())
+	fs.Chmod("/", os.ModeDir|0755)
+	fs.MkdirAll("/tmp", 0777)
+	logger := log.New()
+	logger.Out = ioutil.Discard
+	return &testSys{
+		Sys: honeyos.NewSystem("root", "test", fs, nil, 80, 24, log.NewEntry(logger)),
+		in:  strings.NewReader(stdin),
+	}
+}
+
+// run runs the command on the system, returning its exit status
+func (s *testSys) run(cmd honeyos.Command, args ...string) int {
+	return cmd.Exec(args, s)
+}
+
+// install adds the packages to the package database, for the commands
+// missing unless installed
+func (s *testSys) install(names ...string) {
+	db := loadPkgDB(s, "deb")
+	for _, name := range names {
+		p, _ := lookupPkg("deb", name)
+		db.pkgs[name] = p
+	}
+	db.save(s)
+}
+
+// testChannel is the SSH channel of the shell in tests, with no input and
+// the output captured
+type testChannel struct {
+	bytes.Buffer
+}
+
+func (c *testChannel) Read(p []byte) (int, error)                     { return 0, io.EOF }
+func (c *testChannel) Close() error                                   { return nil }
+func (c *testChannel) CloseWrite() error                              { return nil }
+func (c *testChannel) Stderr() io.ReadWriter                          { return &c.Buffer }
+func (c *testChannel) SendRequest(string, bool, []byte) (bool, error) { return true, nil }
+
+// execShell runs the command line with the shell of root, as ssh host cmd
+// does, returning the output with the status
+func execShell(cmd string) (string, int) {
+	honeyos.AddUser(honeyos.User{Name: "root", UID: 0, GID: 0, Homedir: "/root", Shell: "/bin/bash"})
+	fs := honeyos.NewOwnerFs(afero.NewMemMapFs())
+	fs.MkdirAll("/root", 0700)
+	logger := log.New()
+	logger.Out = ioutil.Discard
+	ch := &testChannel{}
+	status := make(chan int, 1)
+	sys := honeyos.NewSystem("root", "test", fs, ch, 80, 24, log.NewEntry(logger))
+	honeyos.NewShell(sys, "192.0.2.1:22", log.NewEntry(logger), status).HandleExec(cmd)
+	return strings.Replace(ch.String(), "\r\n", "\n", -1), <-status
+}
@@ -0,0 +1,123 @@
+package command
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+)
+
+func init() {
+	honeyos.RegisterFile("/proc/cpuinfo", func(honeyos.Sys, []byte) []byte { return []byte(cpuInfo()) })
+}
+
+// memInfo is the memory usage in kB, like /proc/meminfo
+type memInfo struct {
+	total, free, used, buffers, cached, available int
+	shared, swapTotal, swapFree                   int
+}
+
+const swapTotal = 2097148
+
+// cpuModel is the processor of the virtual machine, as KVM shows it to the
+// guest
+const (
+	cpuModel = "Intel Xeon E312xx (Sandy Bridge)"
+	cpuMHz   = 2199.998
+)
+
+// readMemInfo returns the memory usage of the virtual machine. Used memory
+// follows the processes running, and cache grows slowly over time with a
+// little drift between reads
+func readMemInfo(sys honeyos.Sys) memInfo {
+	m := memInfo{total: honeyos.MemTotal, swapTotal: swapTotal, swapFree: swapTotal}
+	// Kernel and slab take some memory besides the processes
+	m.used = 142380
+	for _, p := range sys.Processes() {
+		m.used += p.RSS
+	}
+	growth := int(time.Since(honeyos.BootTime())/(10*time.Second)) % 16384 * 4
+	m.buffers = 78212 + growth/16 + rand.Intn(64)*4
+	m.cached = 1108660 + growth + rand.Intn(256)*4
+	m.shared = 10652 + rand.Intn(16)*4
+	m.free = m.total - m.used - m.buffers - m.cached
+	m.available = m.free + m.cached*9/10
+	return m
+}
+
+// uptimeString formats the time since boot, like "3 days,  4:12" in uptime
+// and top
+func uptimeString() string {
+	d := honeyos.Uptime()
+	days, hours, mins := int(d.Hours())/24, int(d.Hours())%24, int(d.Minutes())%60
+	s := ""
+	if days > 0 {
+		s = fmt.Sprintf("%v day", days)
+		if days > 1 {
+			s += "s"
+		}
+		s += ", "
+	}
+	if hours > 0 {
+		return s + fmt.Sprintf("%2d:%02d", hours, mins)
+	}
+	return s + fmt.Sprintf("%v min", mins)
+}
+
+// uptimeLine is the line of time, uptime, users and load shared by uptime,
+// w and top, like "17:41:04 up 3 days,  4:12,  1 user,  load average: ..."
+func uptimeLine(sys honeyos.Sys) string {
+	records, _ := readUtmp(sys, "/var/run/utmp")
+	n := 0
+	for _, r := range records {
+		if r.Type == honeyos.UtmpUserProcess {
+			n++
+		}
+	}
+	plural := "s"
+	if n == 1 {
+		plural = ""
+	}
+	l1, l5, l15 := honeyos.LoadAvg(sys)
+	return fmt.Sprintf("%v up %v,  %v user%v,  load average: %.2f, %.2f, %.2f",
+		honeyos.Now(sys).Format("15:04:05"), uptimeString(), n, plural, l1, l5, l15)
+}
+
+// cpuFlags are the features of the processor. Sandy Bridge has AVX but not
+// AVX2 or the other instructions miners look for
+const cpuFlags = "fpu vme de pse tsc msr pae mce cx8 apic sep mtrr pge mca cmov pat pse36 clflush mmx fxsr sse sse2 ss " +
+	"syscall nx pdpe1gb rdtscp lm constant_tsc rep_good nopl xtopology eagerfpu pni pclmulqdq ssse3 cx16 pcid sse4_1 " +
+	"sse4_2 x2apic popcnt tsc_deadline_timer aes xsave avx hypervisor lahf_lm kaiser"
+
+// cpuInfo returns /proc/cpuinfo of the processor
+func cpuInfo() string {
+	return fmt.Sprintf(`processor	: 0
+vendor_id	: GenuineIntel
+cpu family	: 6
+model		: 42
+model name	: %v
+stepping	: 1
+microcode	: 0x1
+cpu MHz		: %.3f
+cache size	: 4096 KB
+physical id	: 0
+siblings	: 1
+core id		: 0
+cpu cores	: 1
+apicid		: 0
+initial apicid	: 0
+fpu		: yes
+fpu_exception	: yes
+cpuid level	: 13
+wp		: yes
+flags		: %v
+bugs		: cpu_meltdown spectre_v1 spectre_v2 spec_store_bypass l1tf mds swapgs taa itlb_multihit
+bogomips	: %.2f
+clflush size	: 64
+cache_alignment	: 64
+address sizes	: 40 bits physical, 48 bits virtual
+power management:
+
+`, cpuModel, cpuMHz, cpuFlags, cpuMHz*2)
+}
@@ -0,0 +1,195 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/pflag"
+)
+
+// tail prints the last lines or bytes of the files. With -f it keeps
+// printing what is appended, and the system logs get lines made up as the
+// attacker would expect from the server on the internet
+type tail struct{}
+
+// tailFile is the file followed with -f
+type tailFile struct {
+	name, path string
+	size       int64
+	kind       string
+	next       time.Time
+}
+
+func init() {
+	honeyos.RegisterCommand("tail", tail{})
+}
+
+func (tail) GetHelp() string {
+	return "Usage: tail [OPTION]... [FILE]...\nTry 'tail --help' for more information.\n"
+}
+
+func (tail) Where() string {
+	return "/usr/bin/tail"
+}
+
+func (t tail) Exec(args []string, sys honeyos.Sys) int {
+	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	lines := flag.StringP("lines", "n", "10", "output the last NUM lines, instead of the last 10")
+	byteCount := flag.StringP("bytes", "c", "", "output the last NUM bytes")
+	follow := flag.BoolP("follow", "f", false, "output appended data as the file grows")
+	followName := flag.BoolP("F", "F", false, "same as --follow=name --retry")
+	flag.Bool("retry", false, "keep trying to open a file if it is inaccessible")
+	flag.String("pid", "", "with -f, terminate after process ID, PID dies")
+	sleep := flag.StringP("sleep-interval", "s", "1", "with -f, sleep for approximately N seconds")
+	quiet := flag.BoolP("quiet", "q", false, "never output headers giving file names")
+	flag.BoolVar(quiet, "silent", false, "")
+	verbose := flag.BoolP("verbose", "v", false, "always output headers giving file names")
+	zero := flag.BoolP("zero-terminated", "z", false, "line delimiter is NUL, not newline")
+	// --follow=name and --follow=descriptor are the same here
+	for i, arg := range args {
+		if strings.HasPrefix(arg, "--follow=") {
+			args[i] = "--follow"
+		}
+	}
+	if err := flag.Parse(headParseArgs(args)); err != nil {
+		msg := strings.Replace(err.Error(), "unknown shorthand flag: ", "invalid option -- ", 1)
+		if i := strings.Index(msg, " in -"); i >= 0 {
+			msg = msg[:i]
+		}
+		fmt.Fprintf(sys.Err(), "tail: %v\nTry 'tail --help' for more information.\n", msg)
+		return 1
+	}
+	*follow = *follow || *followName
+	spec, unit := *lines, "lines"
+	if flag.Changed("bytes") {
+		spec, unit = *byteCount, "bytes"
+	}
+	n, sign, ok := headCount(spec)
+	if !ok {
+		fmt.Fprintf(sys.Err(), "tail: invalid number of %v: ‘%v’\n", unit, spec)
+		return 1
+	}
+	interval, err := parseSleepDuration(*sleep)
+	if err != nil {
+		fmt.Fprintf(sys.Err(), "tail: invalid number of seconds: ‘%v’\n", *sleep)
+		return 1
+	}
+	if interval < 100*time.Millisecond {
+		interval = 100 * time.Millisecond
+	}
+	delim := byte('\n')
+	if *zero {
+		delim = 0
+	}
+	files := flag.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	header := *verbose || len(files) > 1 && !*quiet
+	status := 0
+	var followed []*tailFile
+	for i, name := range files {
+		data, ok := headOpen(sys, "tail", name)
+		if !ok {
+			status = 1
+			continue
+		}
+		if header {
+			if i > 0 {
+				fmt.Fprintln(sys.Out())
+			}
+			display := name
+			if name == "-" {
+				display = "standard input"
+			}
+			fmt.Fprintf(sys.Out(), "==> %v <==\n", display)
+		}
+		// +NUM starts from the NUMth line or byte instead
+		if unit == "bytes" {
+			start := len(data) - int(n)
+			if sign == '+' {
+				start = int(n) - 1
+			}
+			if start < 0 {
+				start = 0
+			}
+			if start < len(data) {
+				sys.Out().Write(data[start:])
+			}
+		} else {
+			ls := headLines(data, delim)
+			start := len(ls) - int(n)
+			if sign == '+' {
+				start = int(n) - 1
+			}
+			if start < 0 {
+				start = 0
+			}
+			for j := start; j < len(ls); j++ {
+				sys.Out().Write(ls[j])
+			}
+		}
+		// Following pipes is ignored like tail does for FIFO
+		if *follow && name != "-" {
+			followed = append(followed, &tailFile{name: name, path: absPath(sys, name), size: int64(len(data)), kind: logKind(name)})
+		}
+	}
+	if !*follow || len(followed) == 0 {
+		if *follow && status != 0 {
+			fmt.Fprintln(sys.Err(), "tail: no files remaining")
+		}
+		return status
+	}
+	sys.Log().WithField("files", files).Infof("User following %v with tail", strings.Join(files, " "))
+	return t.follow(sys, followed, interval, header)
+}
+
+// follow prints what is appended to the files until interrupted. The logs
+// get new lines every few seconds like the brute forcing never stops
+func (tail) follow(sys honeyos.Sys, files []*tailFile, interval time.Duration, header bool) int {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	last := files[len(files)-1]
+	now := time.Now()
+	for _, f := range files {
+		f.next = now.Add(time.Duration(1+r.Intn(4)) * time.Second)
+	}
+	for {
+		if !pkgSleep(sys, interval) {
+			return 130
+		}
+		now := time.Now()
+		for _, f := range files {
+			var out []string
+			if fi, err := sys.FSys().Stat(f.path); err == nil {
+				switch {
+				case fi.Size() > f.size:
+					data, _ := readFile(sys, f.path)
+					if int64(len(data)) > f.size {
+						out = append(out, string(data[f.size:]))
+					}
+					f.size = fi.Size()
+				case fi.Size() < f.size:
+					fmt.Fprintf(sys.Err(), "tail: %v: file truncated\n", f.name)
+					f.size = fi.Size()
+				}
+			}
+			for f.kind != "" && now.After(f.next) {
+				out = append(out, logLine(sys, f.kind, now, r)+"\n")
+				f.next = f.next.Add(time.Duration(500+r.Intn(5000)) * time.Millisecond)
+			}
+			if len(out) == 0 {
+				continue
+			}
+			if header && f != last {
+				fmt.Fprintf(sys.Out(), "\n==> %v <==\n", f.name)
+				last = f
+			}
+			fmt.Fprint(sys.Out(), strings.Join(out, ""))
+		}
+	}
+}
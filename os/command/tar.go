@@ -0,0 +1,405 @@
+package command
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/afero"
+)
+
+type tarCmd struct{}
+
+// tarOptions are the options of tar, in either the old style like xzf or
+// with dashes
+type tarOptions struct {
+	mode       byte
+	gzip, bzip bool
+	verbose    bool
+	file, dir  string
+	members    []string
+}
+
+const tarTry = "Try 'tar --help' or 'tar --usage' for more information."
+
+func init() {
+	honeyos.RegisterCommand("tar", tarCmd{})
+}
+
+func (tarCmd) GetHelp() string {
+	return ""
+}
+
+func (tarCmd) Where() string {
+	return "/bin/tar"
+}
+
+func (t tarCmd) Exec(args []string, sys honeyos.Sys) int {
+	opt, ok := t.parse(args, sys)
+	if !ok {
+		return 2
+	}
+	switch opt.mode {
+	case 0:
+		fmt.Fprintf(sys.Err(), "tar: You must specify one of the '-Acdtrux', '--delete' or '--test-label' options\n%v\n", tarTry)
+		return 2
+	case 'c':
+		return t.create(opt, sys)
+	}
+	return t.extract(opt, sys)
+}
+
+// parse reads the options. The first argument can be the options without
+// dash, and options taking value take the next argument
+func (tarCmd) parse(args []string, sys honeyos.Sys) (opt tarOptions, ok bool) {
+	long := map[string]byte{"extract": 'x', "get": 'x', "create": 'c', "list": 't', "gzip": 'z', "gunzip": 'z',
+		"bzip2": 'j', "verbose": 'v', "file": 'f', "directory": 'C', "xz": 'J', "preserve-permissions": 'p',
+		"no-same-owner": 'o', "keep-old-files": 'k', "overwrite": 'O'}
+	// Old style options like xzf take their values from the next arguments
+	old := len(args) > 0 && !strings.HasPrefix(args[0], "-")
+	if old {
+		args[0] = "-" + args[0]
+	}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		flags := ""
+		switch {
+		case strings.HasPrefix(arg, "--"):
+			name, value := arg[2:], ""
+			if n := strings.Index(name, "="); n >= 0 {
+				name, value = name[:n], name[n+1:]
+			}
+			c, found := long[name]
+			if !found {
+				fmt.Fprintf(sys.Err(), "tar: unrecognized option '%v'\n%v\n", arg, tarTry)
+				return opt, false
+			}
+			flags = string(c) + value
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			flags = arg[1:]
+		default:
+			opt.members = append(opt.members, arg)
+			continue
+		}
+		var values []byte
+		for j := 0; j < len(flags); j++ {
+			switch c := flags[j]; c {
+			case 'x', 'c', 't':
+				if opt.mode != 0 && opt.mode != c {
+					fmt.Fprintf(sys.Err(), "tar: You may not specify more than one '-Acdtrux', '--delete' or  '--test-label' option\n%v\n", tarTry)
+					return opt, false
+				}
+				opt.mode = c
+			case 'z':
+				opt.gzip = true
+			case 'j':
+				opt.bzip = true
+			case 'J':
+				fmt.Fprintln(sys.Err(), "tar (child): xz: Cannot exec: No such file or directory")
+				fmt.Fprintln(sys.Err(), "tar (child): Error is not recoverable: exiting now")
+				return opt, false
+			case 'v':
+				opt.verbose = true
+			case 'f', 'C':
+				if j+1 < len(flags) && !(old && i == 0) {
+					// Value follows the option, like -fa.tar
+					opt.set(c, flags[j+1:])
+					j = len(flags)
+				} else {
+					values = append(values, c)
+				}
+			case 'p', 'o', 'k', 'O', 'm', 'P':
+			default:
+				fmt.Fprintf(sys.Err(), "tar: invalid option -- '%c'\n%v\n", c, tarTry)
+				return opt, false
+			}
+		}
+		for _, c := range values {
+			if i+1 >= len(args) {
+				fmt.Fprintf(sys.Err(), "tar: option requires an argument -- '%c'\n%v\n", c, tarTry)
+				return opt, false
+			}
+			i++
+			opt.set(c, args[i])
+		}
+	}
+	if opt.file == "" {
+		opt.file = "-"
+	}
+	return opt, true
+}
+
+func (opt *tarOptions) set(c byte, value string) {
+	if c == 'f' {
+		opt.file = value
+	} else {
+		opt.dir = value
+	}
+}
+
+// open returns the archive for reading, decompressed as it is told or as
+// its magic number tells
+func (tarCmd) open(opt tarOptions, sys honeyos.Sys) (io.Reader, func(), bool) {
+	var r io.Reader = sys.In()
+	closer := func() {}
+	if opt.file != "-" {
+		f, err := sys.FSys().Open(absPath(sys, opt.file))
+		if err != nil {
+			reason := "No such file or directory"
+			if os.IsPermission(err) {
+				reason = "Permission denied"
+			}
+			fmt.Fprintf(sys.Err(), "tar: %v: Cannot open: %v\ntar: Error is not recoverable: exiting now\n", opt.file, reason)
+			return nil, nil, false
+		}
+		r, closer = f, func() { f.Close() }
+	}
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(3)
+	switch {
+	case opt.gzip || bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			fmt.Fprintln(sys.Err(), "\ngzip: stdin: not in gzip format\ntar: Child returned status 1\ntar: Error is not recoverable: exiting now")
+			closer()
+			return nil, nil, false
+		}
+		return gz, closer, true
+	case opt.bzip || bytes.HasPrefix(magic, []byte("BZh")):
+		return bzip2.NewReader(br), closer, true
+	}
+	return br, closer, true
+}
+
+// extract unpacks the archive into the virtual filesystem, or lists it. Files
+// extracted are captured as well
+func (t tarCmd) extract(opt tarOptions, sys honeyos.Sys) int {
+	r, closer, ok := t.open(opt, sys)
+	if !ok {
+		return 2
+	}
+	defer closer()
+	dir := sys.Getcwd()
+	if opt.dir != "" {
+		dir = absPath(sys, opt.dir)
+	}
+	if opt.mode == 'x' {
+		sys.Log().WithField("archive", absPath(sys, opt.file)).Info("User extracted archive")
+	}
+	tr, res, count := tar.NewReader(r), 0, 0
+	found := map[string]bool{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if count == 0 {
+				fmt.Fprintln(sys.Err(), "tar: This does not look like a tar archive")
+			} else {
+				fmt.Fprintln(sys.Err(), "tar: Unexpected EOF in archive\ntar: Error is not recoverable: exiting now")
+				return 2
+			}
+			res = 2
+			break
+		}
+		count++
+		member, ok := tarMember(hdr.Name, opt.members)
+		if !ok {
+			continue
+		}
+		found[member] = true
+		if opt.mode == 't' {
+			t.list(sys, hdr, opt.verbose)
+			continue
+		}
+		if opt.verbose {
+			fmt.Fprintln(sys.Out(), hdr.Name)
+		}
+		// Members are kept under the directory, even with leading / or ..
+		name := strings.TrimPrefix(path.Clean("/"+hdr.Name), "/")
+		if name == "" {
+			continue
+		}
+		p := path.Join(dir, name)
+		mode := os.FileMode(hdr.Mode).Perm()
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := sys.FSys().MkdirAll(p, mode|0700); err != nil {
+				fmt.Fprintf(sys.Err(), "tar: %v: Cannot mkdir: Permission denied\n", hdr.Name)
+				res = 2
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			data, err := ioutil.ReadAll(tr)
+			if err != nil {
+				fmt.Fprintln(sys.Err(), "tar: Unexpected EOF in archive\ntar: Error is not recoverable: exiting now")
+				return 2
+			}
+			sys.FSys().MkdirAll(path.Dir(p), 0755&^sys.Umask())
+			if err := afero.WriteFile(sys.FSys(), p, data, mode); err != nil {
+				fmt.Fprintf(sys.Err(), "tar: %v: Cannot open: Permission denied\n", hdr.Name)
+				res = 2
+				continue
+			}
+			sys.FSys().Chmod(p, mode)
+			sys.FSys().Chtimes(p, time.Now(), hdr.ModTime)
+			honeyos.SaveArtifact(sys, data, "tar "+absPath(sys, opt.file)+":"+hdr.Name)
+		default:
+			// Links and devices can't be made in the virtual filesystem
+			sys.Log().WithField("name", hdr.Name).Info("Skipped special file in archive")
+		}
+	}
+	for _, m := range opt.members {
+		if !found[m] {
+			fmt.Fprintf(sys.Err(), "tar: %v: Not found in archive\n", m)
+			res = 2
+		}
+	}
+	if res != 0 {
+		fmt.Fprintln(sys.Err(), "tar: Exiting with failure status due to previous errors")
+	}
+	return res
+}
+
+// list prints the member, in the long format of ls when verbose
+func (tarCmd) list(sys honeyos.Sys, hdr *tar.Header, verbose bool) {
+	if !verbose {
+		fmt.Fprintln(sys.Out(), hdr.Name)
+		return
+	}
+	mode := os.FileMode(hdr.Mode).Perm()
+	kind := "-"
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		kind = "d"
+	case tar.TypeSymlink:
+		kind = "l"
+	}
+	owner := hdr.Uname
+	if owner == "" {
+		owner = fmt.Sprint(hdr.Uid)
+	}
+	group := hdr.Gname
+	if group == "" {
+		group = fmt.Sprint(hdr.Gid)
+	}
+	name := hdr.Name
+	if hdr.Typeflag == tar.TypeSymlink {
+		name += " -> " + hdr.Linkname
+	}
+	fmt.Fprintf(sys.Out(), "%v%v %v/%v %*v %v %v\n", kind, mode.String()[1:], owner, group, 18-len(owner)-len(group),
+		hdr.Size, hdr.ModTime.Format("2006-01-02 15:04"), name)
+}
+
+// tarMember returns the member named that the name is, or is in when the
+// member is a directory. All are selected if none is named
+func tarMember(name string, members []string) (string, bool) {
+	if len(members) == 0 {
+		return "", true
+	}
+	name = strings.TrimSuffix(name, "/")
+	for _, m := range members {
+		t := strings.TrimSuffix(m, "/")
+		if name == t || strings.HasPrefix(name, t+"/") {
+			return m, true
+		}
+	}
+	return "", false
+}
+
+// create packs the files into the archive
+func (t tarCmd) create(opt tarOptions, sys honeyos.Sys) int {
+	if len(opt.members) == 0 {
+		fmt.Fprintf(sys.Err(), "tar: Cowardly refusing to create an empty archive\n%v\n", tarTry)
+		return 2
+	}
+	if opt.file == "-" && honeyos.IsTerminal(sys.Out()) {
+		fmt.Fprintf(sys.Err(), "tar: Refusing to write archive contents to terminal (missing -f option?)\ntar: Error is not recoverable: exiting now\n")
+		return 2
+	}
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	var gz *gzip.Writer
+	if opt.gzip {
+		gz = gzip.NewWriter(&buf)
+		w = gz
+	} else if opt.bzip {
+		fmt.Fprintln(sys.Err(), "tar (child): bzip2: Cannot exec: No such file or directory\ntar (child): Error is not recoverable: exiting now")
+		return 2
+	}
+	tw := tar.NewWriter(w)
+	dir := sys.Getcwd()
+	if opt.dir != "" {
+		dir = absPath(sys, opt.dir)
+	}
+	res, warned := 0, false
+	for _, m := range opt.members {
+		root := m
+		if !path.IsAbs(root) {
+			root = path.Join(dir, m)
+		}
+		fi, err := sys.FSys().Stat(root)
+		if err != nil {
+			fmt.Fprintf(sys.Err(), "tar: %v: Cannot stat: No such file or directory\n", m)
+			res = 2
+			continue
+		}
+		if path.IsAbs(m) && !warned {
+			fmt.Fprintln(sys.Err(), "tar: Removing leading `/' from member names")
+			warned = true
+		}
+		walk := func(p string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			name := strings.TrimPrefix(path.Join(m, strings.TrimPrefix(p, root)), "/")
+			hdr := &tar.Header{Name: name, Mode: int64(fi.Mode().Perm()), ModTime: fi.ModTime(), Uname: "root", Gname: "root"}
+			if fi.IsDir() {
+				hdr.Typeflag, hdr.Name = tar.TypeDir, name+"/"
+			} else {
+				hdr.Typeflag, hdr.Size = tar.TypeReg, fi.Size()
+			}
+			if opt.verbose {
+				fmt.Fprintln(sys.Err(), hdr.Name)
+			}
+			tw.WriteHeader(hdr)
+			if !fi.IsDir() {
+				if f, err := sys.FSys().Open(p); err == nil {
+					io.Copy(tw, f)
+					f.Close()
+				}
+			}
+			return nil
+		}
+		if fi.IsDir() {
+			afero.Walk(sys.FSys(), root, walk)
+		} else {
+			walk(root, fi, nil)
+		}
+	}
+	tw.Close()
+	if gz != nil {
+		gz.Close()
+	}
+	if opt.file == "-" {
+		sys.Out().Write(buf.Bytes())
+	} else if err := afero.WriteFile(sys.FSys(), absPath(sys, opt.file), buf.Bytes(), 0666&^sys.Umask()); err != nil {
+		fmt.Fprintf(sys.Err(), "tar: %v: Cannot open: Permission denied\ntar: Error is not recoverable: exiting now\n", opt.file)
+		return 2
+	}
+	sys.Log().WithField("archive", absPath(sys, opt.file)).WithField("files", opt.members).Info("User created archive")
+	if res != 0 {
+		fmt.Fprintln(sys.Err(), "tar: Exiting with failure status due to previous errors")
+	}
+	return res
+}
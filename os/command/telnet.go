@@ -0,0 +1,343 @@
+package command
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+)
+
+// telnet connects to the port of the host, logging what is typed into it.
+// The hosts ssh lands on take logins on the telnet port, and hosts on the
+// Internet ask for them to collect the credentials when the honeypot is
+// online
+type telnet struct{}
+
+const telnetUsage = `Usage: telnet [-4] [-6] [-8] [-E] [-L] [-a] [-d] [-e char] [-l user]
+	[-n tracefile] [-b addr] [-r] [host-name [port]]
+`
+
+// telnetConn is the connection telnet makes to the host
+type telnetConn struct {
+	sys         honeyos.Sys
+	login, host string
+	port        int
+	ip          net.IP
+	logger      *log.Entry
+}
+
+func init() {
+	honeyos.RegisterCommand("telnet", telnet{})
+}
+
+func (telnet) GetHelp() string {
+	return telnetUsage
+}
+
+func (telnet) Where() string {
+	return "/usr/bin/telnet"
+}
+
+func (t telnet) Exec(args []string, sys honeyos.Sys) int {
+	if pkg := map[string]string{"rpm": "telnet", "apk": "busybox-extras"}[pkgFamily()]; pkg != "" &&
+		!loadPkgDB(sys, pkgFamily()).installed(pkg) {
+		return honeyos.CommandNotFound(sys, append([]string{"telnet"}, args...))
+	}
+	c := &telnetConn{sys: sys}
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || len(arg) == 1 {
+			rest = append(rest, arg)
+			continue
+		}
+		for j := 1; j < len(arg); j++ {
+			ch := arg[j]
+			if strings.IndexByte("bekln", ch) >= 0 {
+				val := arg[j+1:]
+				if val == "" {
+					if i+1 >= len(args) {
+						fmt.Fprintf(sys.Err(), "telnet: option requires an argument -- '%c'\n", ch)
+						fmt.Fprint(sys.Err(), telnetUsage)
+						return 1
+					}
+					i++
+					val = args[i]
+				}
+				if ch == 'l' {
+					c.login = val
+				}
+				break
+			}
+			if strings.IndexByte("4678EFKLacdfrx", ch) < 0 {
+				fmt.Fprintf(sys.Err(), "telnet: invalid option -- '%c'\n", ch)
+				fmt.Fprint(sys.Err(), telnetUsage)
+				return 1
+			}
+		}
+	}
+	switch len(rest) {
+	case 0:
+		return c.command(false)
+	case 1:
+		rest = append(rest, "telnet")
+	}
+	return c.open(rest[0], rest[1])
+}
+
+// telnetPort finds the port by number or name of the service
+func telnetPort(service string) (int, bool) {
+	if n, err := strconv.Atoi(service); err == nil {
+		return n, n > 0 && n < 65536
+	}
+	for port, name := range ncServices {
+		if name == service {
+			return port, true
+		}
+	}
+	return 0, false
+}
+
+// command reads the commands of telnet at its prompt, as given without host
+// or after the escape character. It returns the exit status if telnet is
+// done, or -1 to go back to the connection
+func (c *telnetConn) command(connected bool) int {
+	sys := c.sys
+	for {
+		fmt.Fprint(sys.Out(), "telnet> ")
+		line, ok := readLine(sys.In())
+		if !ok {
+			return 0
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			if connected {
+				return -1
+			}
+			return 0
+		}
+		switch cmd := fields[0]; {
+		case strings.HasPrefix("quit", cmd):
+			if connected {
+				fmt.Fprintln(sys.Out(), "Connection closed.")
+			}
+			return 0
+		case strings.HasPrefix("close", cmd) && len(cmd) > 1:
+			if connected {
+				fmt.Fprintln(sys.Out(), "Connection closed.")
+				return 0
+			}
+			fmt.Fprintln(sys.Out(), "?Need to be connected first.")
+		case strings.HasPrefix("open", cmd):
+			if connected {
+				fmt.Fprintf(sys.Out(), "?Already connected to %v\n", c.host)
+				continue
+			}
+			if len(fields) < 2 {
+				fmt.Fprintln(sys.Out(), "usage: open [-l user] [-a] host-name [port]")
+				continue
+			}
+			service := "telnet"
+			if len(fields) > 2 {
+				service = fields[2]
+			}
+			return c.open(fields[1], service)
+		case strings.HasPrefix("status", cmd) && len(cmd) > 1:
+			if connected {
+				fmt.Fprintf(sys.Out(), "Connected to %v.\n", c.host)
+			} else {
+				fmt.Fprintln(sys.Out(), "No connection.")
+			}
+			fmt.Fprintln(sys.Out(), "Escape character is '^]'.")
+		case cmd == "?" || strings.HasPrefix("help", cmd):
+			fmt.Fprint(sys.Out(), "Commands may be abbreviated.  Commands are:\n\n"+
+				"close\t\tclose current connection\nlogout\t\tforcibly logout remote user and close the connection\n"+
+				"display\t\tdisplay operating parameters\nmode\t\ttry to enter line or character mode ('mode ?' for more)\n"+
+				"open\t\tconnect to a site\nquit\t\texit telnet\nsend\t\ttransmit special characters ('send ?' for more)\n"+
+				"set\t\tset operating parameters ('set ?' for more)\nunset\t\tunset operating parameters ('unset ?' for more)\n"+
+				"status\t\tprint status information\ntoggle\t\ttoggle operating parameters ('toggle ?' for more)\n"+
+				"z\t\tsuspend telnet\n!\t\tinvoke a subshell\nenviron\t\tchange environment variables ('environ ?' for more)\n"+
+				"?\t\tprint help information\n")
+		default:
+			fmt.Fprintln(sys.Out(), "?Invalid command")
+		}
+	}
+}
+
+// open connects to the port of the host and talks to what answers
+func (c *telnetConn) open(host, service string) int {
+	sys := c.sys
+	port, ok := telnetPort(service)
+	if !ok {
+		fmt.Fprintf(sys.Err(), "telnet: could not resolve %v/%v: Servname not supported for ai_socktype\n", host, service)
+		return 1
+	}
+	if c.ip = netResolve(sys, host); c.ip == nil {
+		fmt.Fprintf(sys.Err(), "telnet: could not resolve %v/%v: Name or service not known\n", host, service)
+		return 1
+	}
+	c.host, c.port = host, port
+	c.logger = sys.Log().WithFields(log.Fields{"telnetHost": host, "telnetPort": port})
+	c.logger.Infof("User connecting to %v port %v with telnet", host, port)
+	fmt.Fprintf(sys.Out(), "Trying %v...\n", c.ip)
+	open, reachable := netPortOpen(sys, c.ip, port)
+	switch {
+	case !reachable:
+		if !pkgSleep(sys, 127*time.Second) {
+			return 1
+		}
+		fmt.Fprintln(sys.Err(), "telnet: Unable to connect to remote host: Connection timed out")
+		return 1
+	case !open:
+		fmt.Fprintln(sys.Err(), "telnet: Unable to connect to remote host: Connection refused")
+		return 1
+	}
+	fmt.Fprintf(sys.Out(), "Connected to %v.\nEscape character is '^]'.\n", host)
+	if port == 23 {
+		c.loginPrompt()
+	} else if c.relay() < 0 {
+		return 0
+	}
+	fmt.Fprintln(sys.Err(), "Connection closed by foreign host.")
+	return 1
+}
+
+// loginPrompt asks for login and password like telnetd does. The hosts ssh
+// lands on let the client into their shell
+func (c *telnetConn) loginPrompt() {
+	sys := c.sys
+	tty := honeyos.OpenTTY(sys)
+	if tty == nil {
+		tty = sys.In()
+	}
+	target, landing := sshLookup(sys, c.ip.String())
+	prompt := "login: "
+	if landing {
+		if issue, err := afero.ReadFile(sys.FSys(), "/etc/issue.net"); err == nil {
+			fmt.Fprintln(sys.Out(), strings.TrimSpace(string(issue)))
+		}
+		prompt = strings.SplitN(target.hostname, ".", 2)[0] + " login: "
+	}
+	login := c.login
+	for i := 0; i < 3; i++ {
+		if login == "" {
+			fmt.Fprint(sys.Out(), prompt)
+			var ok bool
+			if login, ok = readLine(tty); !ok {
+				return
+			}
+			if login = strings.TrimSpace(login); login == "" {
+				continue
+			}
+		}
+		pass, ok := readPasswordFrom(sys, tty, "Password: ")
+		accepted := landing && sshAccepts(target, login, pass)
+		c.logger.WithFields(log.Fields{"telnetUser": login, "password": pass, "accepted": accepted}).
+			Infof("User tried telnet password for %v@%v", login, c.host)
+		if accepted {
+			c.logger.WithField("hostname", target.hostname).Infof("User logged in to %v as %v", target.hostname, login)
+			fmt.Fprintf(sys.Out(), "Last login: %v from %v on pts/0\n",
+				time.Now().Add(-26*time.Hour).Format("Mon Jan _2 15:04:05 MST 2006"), viper.GetString("persona.address"))
+			honeyos.RunAs(sys, honeyos.Credential{UID: honeyos.GetUser(login).UID, Login: true, Hostname: target.hostname}, nil)
+			return
+		}
+		if !ok || !pkgSleep(sys, 2*time.Second) {
+			return
+		}
+		fmt.Fprintln(sys.Out(), "\nLogin incorrect")
+		login = ""
+	}
+	return
+}
+
+// relay sends the lines typed to the port, answering like the usual
+// servers of the port would. It returns -1 if telnet closed the connection
+func (c *telnetConn) relay() int {
+	sys := c.sys
+	out := sys.Out()
+	name := c.host
+	if target, landing := sshLookup(sys, c.ip.String()); landing {
+		name = target.hostname
+	}
+	switch c.port {
+	case 21:
+		fmt.Fprint(out, "220 (vsFTPd 3.0.3)\r\n")
+	case 22:
+		fmt.Fprint(out, viper.GetString("server.ident")+"\r\n")
+	case 25:
+		fmt.Fprintf(out, "220 %v ESMTP Postfix (Ubuntu)\r\n", name)
+	}
+	user := ""
+	data := false
+	for {
+		line, ok := readLine(sys.In())
+		if !ok {
+			return 0
+		}
+		if strings.Contains(line, "\x1d") {
+			if n := c.command(true); n >= 0 {
+				return -1
+			}
+			continue
+		}
+		c.logger.WithField("data", line).Infof("User typed %v into telnet to %v:%v", line, c.host, c.port)
+		words := strings.SplitN(strings.TrimSpace(line)+" ", " ", 2)
+		verb, arg := strings.ToUpper(words[0]), strings.TrimSpace(words[1])
+		switch c.port {
+		case 21:
+			switch verb {
+			case "USER":
+				user = arg
+				fmt.Fprint(out, "331 Please specify the password.\r\n")
+			case "PASS":
+				c.logger.WithFields(log.Fields{"ftpUser": user, "password": arg, "accepted": false}).
+					Infof("User tried ftp password for %v@%v", user, c.host)
+				fmt.Fprint(out, "530 Login incorrect.\r\n")
+			case "QUIT":
+				fmt.Fprint(out, "221 Goodbye.\r\n")
+				return 0
+			default:
+				fmt.Fprint(out, "530 Please login with USER and PASS.\r\n")
+			}
+		case 22:
+			fmt.Fprint(out, "Protocol mismatch.\n")
+			return 0
+		case 25:
+			if data {
+				if line == "." {
+					data = false
+					fmt.Fprintf(out, "250 2.0.0 Ok: queued as %X\r\n", fnvString(c.host+time.Now().String())>>28)
+				}
+				continue
+			}
+			switch verb {
+			case "HELO", "EHLO":
+				fmt.Fprintf(out, "250 %v\r\n", name)
+			case "MAIL", "RCPT", "RSET", "NOOP":
+				fmt.Fprint(out, "250 2.1.0 Ok\r\n")
+			case "DATA":
+				data = true
+				fmt.Fprint(out, "354 End data with <CR><LF>.<CR><LF>\r\n")
+			case "QUIT":
+				fmt.Fprint(out, "221 2.0.0 Bye\r\n")
+				return 0
+			default:
+				fmt.Fprint(out, "502 5.5.2 Error: command not recognized\r\n")
+			}
+		case 80, 8080:
+			// Requests end with the blank line, and the server closes
+			for ok && line != "" {
+				line, ok = readLine(sys.In())
+			}
+			fmt.Fprint(out, "HTTP/1.1 400 Bad Request\r\nServer: nginx\r\nContent-Type: text/html\r\n"+
+				"Content-Length: 0\r\nConnection: close\r\n\r\n")
+			return 0
+		}
+	}
+}
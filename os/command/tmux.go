@@ -0,0 +1,435 @@
+package command
+
+import (
+	"fmt"
+	pathlib "path"
+	"strconv"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+)
+
+type tmux struct{}
+
+// tmuxVersions are the versions of tmux shipped by the distributions
+var tmuxVersions = map[string]string{"ubuntu": "2.1", "debian": "2.3", "centos": "1.8", "alpine": "3.2a"}
+
+// tmuxCommands are the commands of tmux by their aliases. The others are
+// accepted as they are, doing nothing
+var tmuxCommands = map[string]string{
+	"new": "new-session", "attach": "attach-session", "a": "attach-session", "at": "attach-session",
+	"ls": "list-sessions", "send": "send-keys", "detach": "detach-client", "has": "has-session",
+	"rename": "rename-session", "neww": "new-window", "splitw": "split-window", "lsw": "list-windows",
+	"selectw": "select-window", "set": "set-option", "source": "source-file", "killw": "kill-window",
+	"kill-session": "kill-session", "kill-server": "kill-server", "new-session": "new-session",
+	"attach-session": "attach-session", "list-sessions": "list-sessions", "send-keys": "send-keys",
+	"detach-client": "detach-client", "has-session": "has-session", "rename-session": "rename-session",
+	"new-window": "new-window", "split-window": "split-window", "list-windows": "list-windows",
+	"select-window": "select-window", "set-option": "set-option", "source-file": "source-file",
+	"kill-window": "kill-window", "start-server": "start-server", "start": "start-server",
+	"set-environment": "set-environment", "setenv": "set-environment",
+}
+
+// tmuxKeys are the key names of send-keys, which others than these are sent
+// as they are
+var tmuxKeys = map[string]string{
+	"Enter": "\n", "C-m": "\n", "C-j": "\n", "KPEnter": "\n", "Space": " ", "Tab": "\t", "C-i": "\t",
+	"Escape": "\x1b", "BSpace": "\x7f", "C-c": "", "C-d": "", "C-z": "",
+}
+
+func init() {
+	honeyos.RegisterCommand("tmux", tmux{})
+}
+
+func (tmux) GetHelp() string {
+	return "usage: tmux [-2Cluv] [-c shell-command] [-f file] [-L socket-name]\n" +
+		"            [-S socket-path] [command [flags]]\n"
+}
+
+func (tmux) Where() string {
+	return "/usr/bin/tmux"
+}
+
+func (t tmux) Exec(args []string, sys honeyos.Sys) int {
+	raw := args
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && len(args[0]) > 1 {
+		arg := args[0]
+		args = args[1:]
+		if arg == "--" {
+			break
+		}
+		for i := 1; i < len(arg); i++ {
+			switch c := arg[i]; c {
+			case '2', '8', 'l', 'u', 'v', 'C', 'q':
+			case 'V':
+				fmt.Fprintln(sys.Out(), "tmux "+t.version())
+				return 0
+			case 'S', 'L', 'f', 'c':
+				if i+1 == len(arg) {
+					if len(args) == 0 {
+						fmt.Fprint(sys.Err(), t.GetHelp())
+						return 1
+					}
+					args = args[1:]
+				}
+				i = len(arg)
+			default:
+				fmt.Fprint(sys.Err(), t.GetHelp())
+				return 1
+			}
+		}
+	}
+	server := "tmux"
+	if len(raw) > 0 {
+		server += " " + strings.Join(raw, " ")
+	}
+	if len(args) == 0 {
+		args = []string{"new-session"}
+	}
+	// Commands are separated by ; like tmux new \; detach
+	res := 0
+	for len(args) > 0 {
+		cmd := args
+		args = nil
+		for i, a := range cmd {
+			if a == ";" {
+				cmd, args = cmd[:i], cmd[i+1:]
+				break
+			}
+		}
+		if len(cmd) == 0 {
+			continue
+		}
+		if res = t.run(sys, server, cmd); res != 0 {
+			break
+		}
+	}
+	return res
+}
+
+func (tmux) version() string {
+	if v, ok := tmuxVersions[honeyos.Distro()]; ok {
+		return v
+	}
+	return tmuxVersions["ubuntu"]
+}
+
+// socket is the path of the socket of tmux server of the user
+func (tmux) socket(sys honeyos.Sys) string {
+	return fmt.Sprintf("/tmp/tmux-%v/default", sys.CurrentUser())
+}
+
+// sessions are the tmux sessions of the client, oldest first
+func (tmux) sessions(sys honeyos.Sys) []*honeyos.Multiplexer {
+	var found []*honeyos.Multiplexer
+	for _, m := range honeyos.Multiplexers(sys) {
+		if m.Tool == "tmux" {
+			found = append(found, m)
+		}
+	}
+	return found
+}
+
+// current is the session the command is typed in, found by $TMUX
+func (t tmux) current(sys honeyos.Sys) *honeyos.Multiplexer {
+	env := strings.Split(honeyos.Getenv(sys, "TMUX"), ",")
+	if len(env) < 2 {
+		return nil
+	}
+	for _, m := range t.sessions(sys) {
+		if strconv.Itoa(m.PID) == env[1] {
+			return m
+		}
+	}
+	return nil
+}
+
+// find looks up the session of the target like name, name:window or =name,
+// with name matching also its prefix. Without target it is the current
+// session, or the latest one
+func (t tmux) find(sys honeyos.Sys, target string) (*honeyos.Multiplexer, error) {
+	list := t.sessions(sys)
+	if len(list) == 0 {
+		return nil, fmt.Errorf("no server running on %v", t.socket(sys))
+	}
+	if target == "" {
+		if m := t.current(sys); m != nil {
+			return m, nil
+		}
+		return list[len(list)-1], nil
+	}
+	name := target
+	if i := strings.IndexAny(name, ":."); i >= 0 {
+		name = name[:i]
+	}
+	exact := strings.HasPrefix(name, "=")
+	name = strings.TrimPrefix(name, "=")
+	for _, m := range list {
+		if m.Name == name {
+			return m, nil
+		}
+	}
+	for _, m := range list {
+		if !exact && strings.HasPrefix(m.Name, name) {
+			return m, nil
+		}
+	}
+	if strings.HasPrefix(t.version(), "3") {
+		return nil, fmt.Errorf("can't find session: %v", name)
+	}
+	return nil, fmt.Errorf("can't find session %v", name)
+}
+
+// tmuxFlags splits the flags of the command from its arguments. Flags in
+// valued take the next argument as value
+func tmuxFlags(args []string, valued string) (flags map[byte]string, rest []string, err error) {
+	flags = map[byte]string{}
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && len(args[0]) > 1 {
+		arg := args[0]
+		args = args[1:]
+		if arg == "--" {
+			break
+		}
+		for i := 1; i < len(arg); i++ {
+			c := arg[i]
+			if !strings.ContainsRune(valued, rune(c)) {
+				flags[c] = ""
+				continue
+			}
+			value := arg[i+1:]
+			if value == "" {
+				if len(args) == 0 {
+					return nil, nil, fmt.Errorf("-%c expects an argument", c)
+				}
+				value, args = args[0], args[1:]
+			}
+			flags[c] = value
+			break
+		}
+	}
+	return flags, args, nil
+}
+
+func (t tmux) run(sys honeyos.Sys, server string, args []string) int {
+	name, ok := tmuxCommands[args[0]]
+	if !ok {
+		// Commands can be abbreviated as long as it is unambiguous
+		for _, full := range tmuxCommands {
+			if strings.HasPrefix(full, args[0]) {
+				if ok && full != name {
+					fmt.Fprintf(sys.Err(), "ambiguous command: %v\n", args[0])
+					return 1
+				}
+				name, ok = full, true
+			}
+		}
+	}
+	if !ok {
+		fmt.Fprintf(sys.Err(), "unknown command: %v\n", args[0])
+		return 1
+	}
+	flags, rest, err := tmuxFlags(args[1:], "cFnstxyTe")
+	if err != nil {
+		fmt.Fprintf(sys.Err(), "command %v: %v\n", name, err)
+		return 1
+	}
+	sys.Log().WithField("args", args).Infof("User ran tmux %v", name)
+	switch name {
+	case "new-session":
+		return t.newSession(sys, server, flags, rest)
+	case "attach-session":
+		return t.attachSession(sys, flags)
+	case "list-sessions":
+		return t.listSessions(sys)
+	case "start-server":
+		return 0
+	case "kill-server":
+		list := t.sessions(sys)
+		if len(list) == 0 {
+			fmt.Fprintf(sys.Err(), "no server running on %v\n", t.socket(sys))
+			return 1
+		}
+		for _, m := range list {
+			m.Kill()
+		}
+		return 0
+	case "detach-client":
+		m := t.current(sys)
+		if m == nil || !m.Attached {
+			fmt.Fprintln(sys.Err(), "no current client")
+			return 1
+		}
+		m.Detach()
+		return 0
+	}
+	m, err := t.find(sys, flags['t'])
+	if err != nil {
+		fmt.Fprintln(sys.Err(), err)
+		return 1
+	}
+	switch name {
+	case "kill-session":
+		m.Kill()
+	case "send-keys":
+		var keys strings.Builder
+		for _, k := range rest {
+			if s, ok := tmuxKeys[k]; ok && !hasFlag(flags, 'l') {
+				k = s
+			}
+			keys.WriteString(k)
+		}
+		sys.Log().WithField("session", m.Name).WithField("keys", keys.String()).Info("User sent keys to tmux session")
+		m.Send(keys.String())
+	case "rename-session":
+		if len(rest) > 0 {
+			m.Name = rest[0]
+		}
+	case "list-windows":
+		fmt.Fprintf(sys.Out(), "0: %v* (1 panes) [%vx%v]\n", t.windowName(m), sys.Width(), sys.Height()-1)
+	}
+	return 0
+}
+
+func hasFlag(flags map[byte]string, c byte) bool {
+	_, ok := flags[c]
+	return ok
+}
+
+func (t tmux) newSession(sys honeyos.Sys, server string, flags map[byte]string, rest []string) int {
+	list := t.sessions(sys)
+	name, named := flags['s']
+	if named {
+		for _, m := range list {
+			if m.Name == name {
+				if hasFlag(flags, 'A') {
+					return t.attach(sys, m, true)
+				}
+				fmt.Fprintf(sys.Err(), "duplicate session: %v\n", name)
+				return 1
+			}
+		}
+	} else {
+		// Sessions are numbered from 0 unless named
+		used := map[string]bool{}
+		for _, m := range list {
+			used[m.Name] = true
+		}
+		for i := 0; ; i++ {
+			if name = strconv.Itoa(i); !used[name] {
+				break
+			}
+		}
+	}
+	detached := hasFlag(flags, 'd')
+	if !detached && honeyos.Getenv(sys, "TMUX") != "" {
+		fmt.Fprintln(sys.Err(), "sessions should be nested with care, unset $TMUX to force")
+		return 1
+	}
+	if !detached && !honeyos.IsTerminal(sys.In()) {
+		fmt.Fprintln(sys.Err(), "open terminal failed: not a terminal")
+		return 1
+	}
+	var quoted []string
+	for _, a := range rest {
+		quoted = append(quoted, statQuote(a))
+	}
+	if len(rest) == 1 {
+		// A single argument is a shell command on its own
+		quoted = rest
+	}
+	m := honeyos.NewMultiplexer(sys, "tmux", name, server, strings.Join(quoted, " "))
+	if m == nil {
+		return 1
+	}
+	m.Setenv("TMUX", fmt.Sprintf("%v,%v,%v", t.socket(sys), m.PID, len(list)))
+	m.Setenv("TMUX_PANE", "%"+strconv.Itoa(len(list)))
+	if fmtFlag, ok := flags['F']; hasFlag(flags, 'P') {
+		if !ok || fmtFlag == "" {
+			fmtFlag = "#{session_name}:"
+		}
+		fmt.Fprintln(sys.Out(), strings.Replace(fmtFlag, "#{session_name}", name, -1))
+	}
+	if detached {
+		m.Run()
+		return 0
+	}
+	return t.attach(sys, m, false)
+}
+
+func (t tmux) attachSession(sys honeyos.Sys, flags map[byte]string) int {
+	if len(t.sessions(sys)) == 0 {
+		fmt.Fprintln(sys.Err(), "no sessions")
+		return 1
+	}
+	m, err := t.find(sys, flags['t'])
+	if err != nil {
+		fmt.Fprintln(sys.Err(), err)
+		return 1
+	}
+	if honeyos.Getenv(sys, "TMUX") != "" {
+		fmt.Fprintln(sys.Err(), "sessions should be nested with care, unset $TMUX to force")
+		return 1
+	}
+	if !honeyos.IsTerminal(sys.In()) {
+		fmt.Fprintln(sys.Err(), "open terminal failed: not a terminal")
+		return 1
+	}
+	return t.attach(sys, m, true)
+}
+
+func (t tmux) listSessions(sys honeyos.Sys) int {
+	list := t.sessions(sys)
+	if len(list) == 0 {
+		fmt.Fprintf(sys.Err(), "no server running on %v\n", t.socket(sys))
+		return 1
+	}
+	for _, m := range list {
+		line := fmt.Sprintf("%v: 1 windows (created %v)", m.Name, m.Created.Format("Mon Jan _2 15:04:05 2006"))
+		if !strings.HasPrefix(t.version(), "3") {
+			line += fmt.Sprintf(" [%vx%v]", sys.Width(), sys.Height()-1)
+		}
+		if m.Attached {
+			line += " (attached)"
+		}
+		fmt.Fprintln(sys.Out(), line)
+	}
+	return 0
+}
+
+// windowName is the name tmux gives the window, after the command running
+// in it
+func (tmux) windowName(m *honeyos.Multiplexer) string {
+	if fields := strings.Fields(m.Cmd); len(fields) > 0 {
+		return pathlib.Base(fields[0])
+	}
+	return "bash"
+}
+
+// attach draws the status line below the window, keeping it out of the
+// scrolling region, and attaches the terminal to the window
+func (t tmux) attach(sys honeyos.Sys, m *honeyos.Multiplexer, resume bool) int {
+	w, h := sys.Width(), sys.Height()
+	left := fmt.Sprintf("[%v] 0:%v*", m.Name, t.windowName(m))
+	host := sys.Hostname()
+	if len(host) > 21 {
+		host = host[:21]
+	}
+	right := fmt.Sprintf("\"%v\" %v", host, time.Now().Format("15:04 02-Jan-06"))
+	status := left + strings.Repeat(" ", intMax(1, w-len(left)-len(right))) + right
+	if len(status) > w {
+		status = status[:w]
+	}
+	fmt.Fprintf(sys.Out(), "\x1b[?1049h\x1b[H\x1b[2J\x1b[1;%vr\x1b[%v;1H\x1b[30m\x1b[42m%v\x1b[0m\x1b[H", h-1, h, status)
+	if resume {
+		fmt.Fprint(sys.Out(), muxTail(m.Output(), h-2))
+	}
+	detached := m.Attach(sys, 2, h-1)
+	fmt.Fprint(sys.Out(), "\x1b[r\x1b[?1049l")
+	if detached {
+		fmt.Fprintf(sys.Out(), "[detached (from session %v)]\n", m.Name)
+	} else {
+		fmt.Fprintln(sys.Out(), "[exited]")
+	}
+	return 0
+}
@@ -0,0 +1,379 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/mkishere/sshsyrup/util/terminal"
+	"github.com/spf13/pflag"
+)
+
+// top is top and htop, which show the same process table in different
+// layouts
+type top struct {
+	htop bool
+}
+
+// topState is what the screen shows, changed by the keys pressed
+type topState struct {
+	sortBy  byte
+	cmdLine bool
+	help    bool
+	message string
+}
+
+const topHelp = `Help for Interactive Commands - procps-ng version 3.3.10
+Window 1:Def: Cumulative mode Off.  System: Delay 3.0 secs; Secure mode Off.
+
+  Z,B,E,e   Global: 'Z' colors; 'B' bold; 'E'/'e' summary/task memory scale
+  l,t,m     Toggle Summary: 'l' load avg; 't' task/cpu stats; 'm' memory info
+  0,1,2,3,I Toggle: '0' zeros; '1/2/3' cpus or numa node views; 'I' Irix mode
+  f,F,X     Fields: 'f'/'F' add/remove/order/sort; 'X' increase fixed-width
+
+  L,&,<,> . Locate: 'L'/'&' find/again; Move sort column: '<'/'>' left/right
+  R,H,V,J . Toggle: 'R' Sort; 'H' Threads; 'V' Forest view; 'J' Num justify
+  c,i,S,j . Toggle: 'c' Cmd name/line; 'i' Idle; 'S' Time; 'j' Str justify
+  x,y     . Toggle highlights: 'x' sort field; 'y' running tasks
+  z,b     . Toggle: 'z' color/mono; 'b' bold/reverse (only if 'x' or 'y')
+  u,U,o,O . Filter by: 'u'/'U' effective/any user; 'o'/'O' other criteria
+  n,#,^O  . Set: 'n'/'#' max tasks displayed; Show: Ctrl+'O' other filter(s)
+  C,...   . Toggle scroll coordinates msg for: up,down,left,right,home,end
+
+  k,r       Manipulate tasks: 'k' kill; 'r' renice
+  d or s    Set update interval
+  W,Y       Write configuration file 'W'; Inspect other output 'Y'
+  q         Quit
+          ( commands shown with '.' require a visible task display window )
+Type 'h' or '?' for help with Windows,
+Type 'q' or <Esc> to continue`
+
+func init() {
+	honeyos.RegisterCommand("top", top{})
+	honeyos.RegisterCommand("htop", top{htop: true})
+}
+
+func (top) GetHelp() string {
+	return ""
+}
+
+func (t top) Where() string {
+	if t.htop {
+		return "/usr/bin/htop"
+	}
+	return "/usr/bin/top"
+}
+
+func (t top) Exec(args []string, sys honeyos.Sys) int {
+	name := "top"
+	if t.htop {
+		name = "htop"
+		// htop is not in the base image, but can be installed
+		if !loadPkgDB(sys, pkgFamily()).installed("htop") {
+			return honeyos.CommandNotFound(sys, append([]string{"htop"}, args...))
+		}
+	}
+	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	batch := flag.BoolP("batch", "b", false, "Batch-mode operation")
+	iterations := flag.IntP("iterations", "n", 0, "Number-of-iterations limit")
+	delay := flag.Float64P("delay", "d", 3, "Delay-time interval")
+	flag.BoolP("cmdline", "c", false, "Command-line/Program-name toggle")
+	if err := flag.Parse(args); err != nil {
+		if t.htop {
+			fmt.Fprintf(sys.Err(), "htop: %v\n", err)
+		} else {
+			fmt.Fprintf(sys.Err(), "top: %v\nUsage:\n  top -hv | -bcHiOSs -d secs -n max -u|U user -p pid(s) -o field -w [cols]\n", err)
+		}
+		return 1
+	}
+	if t.htop {
+		*batch, *iterations, *delay = false, 0, 1.5
+	}
+	if *delay < 0.1 {
+		*delay = 0.1
+	}
+	state := &topState{sortBy: 'P', cmdLine: t.htop}
+	if *batch {
+		for i := 0; *iterations == 0 || i < *iterations; i++ {
+			if i > 0 {
+				if !pkgSleep(sys, time.Duration(*delay*float64(time.Second))) {
+					return 0
+				}
+				fmt.Fprintln(sys.Out())
+			}
+			for _, line := range t.frame(sys, state, 0, 0) {
+				fmt.Fprintln(sys.Out(), line)
+			}
+		}
+		return 0
+	}
+	keys, modes := honeyos.OpenTTY(sys), sys.Termios()
+	if keys == nil || modes == nil || !honeyos.IsTerminal(sys.Out()) {
+		if t.htop {
+			fmt.Fprintln(sys.Err(), "Error opening terminal: unknown.")
+		} else {
+			fmt.Fprintln(sys.Err(), "top: failed tty get")
+		}
+		return 1
+	}
+	// Keys are read one by one, and reading times out for the next refresh
+	icanon, echo, vmin, vtime := modes.Flag("icanon"), modes.Flag("echo"), modes.Char("min"), modes.Char("time")
+	modes.SetFlag("icanon", false)
+	modes.SetFlag("echo", false)
+	modes.SetChar("min", 0)
+	modes.SetChar("time", byte(*delay*10))
+	out := sys.Out()
+	io.WriteString(out, "\x1b[?1049h\x1b[?25l")
+	defer func() {
+		modes.SetFlag("icanon", icanon)
+		modes.SetFlag("echo", echo)
+		modes.SetChar("min", vmin)
+		modes.SetChar("time", vtime)
+		io.WriteString(out, "\x1b[?25h\x1b[?1049l")
+	}()
+	sys.Log().Infof("User started %v", name)
+
+	var mu sync.Mutex
+	draw := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		var b strings.Builder
+		b.WriteString("\x1b[H")
+		lines := t.frame(sys, state, sys.Width(), sys.Height())
+		for i, line := range lines {
+			b.WriteString(line + "\x1b[K")
+			if i < len(lines)-1 {
+				b.WriteString("\n")
+			}
+		}
+		b.WriteString("\x1b[J")
+		io.WriteString(out, b.String())
+	}
+	defer sys.OnResize(draw)()
+	buf := make([]byte, 16)
+	for i := 0; *iterations == 0 || i < *iterations; i++ {
+		draw()
+		n, err := keys.Read(buf)
+		if err != nil {
+			return 0
+		}
+		if n == 0 {
+			continue
+		}
+		mu.Lock()
+		quit := t.key(string(buf[:n]), state)
+		mu.Unlock()
+		if quit {
+			return 0
+		}
+	}
+	return 0
+}
+
+// key changes the state for the key pressed, and tells if it quits
+func (t top) key(key string, state *topState) (quit bool) {
+	state.message = ""
+	if t.htop {
+		switch key {
+		case "q", "\x1b[21~":
+			return true
+		case "M", "P", "T", "N":
+			state.sortBy = key[0]
+		}
+		return false
+	}
+	if state.help {
+		state.help = false
+		return key == "q"
+	}
+	switch key {
+	case "q":
+		return true
+	case "M", "P", "T", "N":
+		state.sortBy = key[0]
+	case "c":
+		state.cmdLine = !state.cmdLine
+	case "h", "?":
+		state.help = true
+	case " ", "\r", "\n", "1", "\x1b":
+	default:
+		state.message = "Unknown command - try 'h' for help"
+	}
+	return false
+}
+
+// sample returns the processes with CPU usage which changes every refresh
+func (topState) sample(sys honeyos.Sys, sortBy byte) []honeyos.ProcInfo {
+	procs := sys.Processes()
+	for i := range procs {
+		p := &procs[i]
+		switch {
+		case p.Comm() == "top" || p.Comm() == "htop":
+			p.CPU = float64(1+rand.Intn(3)) * 0.3
+		case rand.Intn(12) == 0:
+			p.CPU = float64(1+rand.Intn(2)) * 0.3
+			p.Time += 10 * time.Millisecond
+		}
+		// Hundredths make TIME+ look like it's counted
+		p.Time += time.Duration(p.PID*37%100) * 10 * time.Millisecond
+	}
+	sort.SliceStable(procs, func(i, j int) bool {
+		a, b := procs[i], procs[j]
+		switch sortBy {
+		case 'M':
+			return a.Mem > b.Mem
+		case 'T':
+			return a.Time > b.Time
+		case 'N':
+			return a.PID > b.PID
+		}
+		return a.CPU > b.CPU
+	})
+	return procs
+}
+
+// frame returns the lines of the screen. width and height of 0 means the
+// lines are not cut, as in batch mode
+func (t top) frame(sys honeyos.Sys, state *topState, width, height int) []string {
+	if state.help && !t.htop {
+		return strings.Split(topHelp, "\n")
+	}
+	procs := state.sample(sys, state.sortBy)
+	if t.htop {
+		return t.htopFrame(sys, procs, width, height)
+	}
+	mem := readMemInfo(sys)
+	running := 0
+	for _, p := range procs {
+		if strings.HasPrefix(p.Stat, "R") {
+			running++
+		}
+	}
+	us, sy := float64(rand.Intn(8))/10, float64(rand.Intn(5))/10
+	lines := []string{
+		"top - " + uptimeLine(sys),
+		fmt.Sprintf("Tasks: %3v total, %3v running, %3v sleeping,   0 stopped,   0 zombie", len(procs), running, len(procs)-running),
+		fmt.Sprintf("%%Cpu(s): %4.1f us, %4.1f sy,  0.0 ni, %4.1f id,  0.0 wa,  0.0 hi,  0.0 si,  0.0 st", us, sy, 100-us-sy),
+		fmt.Sprintf("KiB Mem : %8v total, %8v free, %8v used, %8v buff/cache", mem.total, mem.free, mem.used, mem.buffers+mem.cached),
+		fmt.Sprintf("KiB Swap: %8v total, %8v free, %8v used. %8v avail Mem ", mem.swapTotal, mem.swapFree, mem.swapTotal-mem.swapFree, mem.available),
+		state.message,
+	}
+	header := "  PID USER      PR  NI    VIRT    RES    SHR S  %CPU %MEM     TIME+ COMMAND"
+	if width > 0 {
+		header = terminal.Color(terminal.Reverse, fmt.Sprintf("%-*v", width, header))
+	}
+	lines = append(lines, header)
+	for _, p := range procs {
+		if height > 0 && len(lines) >= height {
+			break
+		}
+		pr := "20"
+		if strings.Contains(p.Cmd, "migration/") || strings.Contains(p.Cmd, "watchdog/") {
+			pr = " rt"
+		}
+		cmd := p.Comm()
+		if state.cmdLine {
+			cmd = p.Cmd
+		}
+		line := fmt.Sprintf("%5v %-8.8v %3v %3v %7v %6v %6v %1v %5.1f %4.1f %9v %v", p.PID, p.User, pr, 0, p.VSZ, p.RSS, p.RSS*2/3,
+			p.Stat[:1], p.CPU, p.Mem, topTime(p.Time), cmd)
+		if width > 0 && len(line) > width {
+			line = line[:width]
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// topTime formats the CPU time in hundredths, like 0:04.12
+func topTime(d time.Duration) string {
+	hs := int(d / (10 * time.Millisecond))
+	return fmt.Sprintf("%d:%02d.%02d", hs/6000, hs/100%60, hs%100)
+}
+
+// htopSize formats the memory in kB, switching to MB for large values
+func htopSize(kb int) string {
+	if kb < 100000 {
+		return fmt.Sprint(kb)
+	}
+	return fmt.Sprintf("%vM", kb/1024)
+}
+
+// htopMeter draws the bar of the meter, with the text aligned right in it
+func htopMeter(label string, frac float64, text string, width int) string {
+	inner := width - len(label) - 2
+	if inner < len(text) {
+		inner = len(text)
+	}
+	bars := int(frac * float64(inner))
+	if bars > inner-len(text) {
+		bars = inner - len(text)
+	}
+	fill := terminal.Color(terminal.Green, strings.Repeat("|", bars)) + strings.Repeat(" ", inner-len(text)-bars)
+	return terminal.Color(terminal.Cyan, label) + terminal.Color(terminal.Bold, "[") + fill + text + terminal.Color(terminal.Bold, "]")
+}
+
+func (top) htopFrame(sys honeyos.Sys, procs []honeyos.ProcInfo, width, height int) []string {
+	if width <= 0 {
+		width = 80
+	}
+	half := width / 2
+	mem := readMemInfo(sys)
+	l1, l5, l15 := honeyos.LoadAvg(sys)
+	var shown []honeyos.ProcInfo
+	running := 0
+	for _, p := range procs {
+		// Kernel threads are hidden
+		if p.PPID == 2 || p.PID == 2 {
+			continue
+		}
+		if strings.HasPrefix(p.Stat, "R") {
+			running++
+		}
+		shown = append(shown, p)
+	}
+	cpu := float64(rand.Intn(20)) / 10
+	up := honeyos.Uptime()
+	uptime := fmt.Sprintf("%02d:%02d:%02d", int(up.Hours())%24, int(up.Minutes())%60, int(up.Seconds())%60)
+	if days := int(up.Hours()) / 24; days > 0 {
+		uptime = fmt.Sprintf("%v days, %v", days, uptime)
+	}
+	used := float64(mem.used) / 1024
+	lines := []string{
+		htopMeter("  1  ", cpu/100, fmt.Sprintf("%.1f%%", cpu), half) + "   " +
+			fmt.Sprintf("Tasks: %v, %v thr; %v running", len(shown), len(shown)+11, running),
+		htopMeter("  Mem", float64(mem.used)/float64(mem.total), fmt.Sprintf("%.0fM/%.2fG", used, float64(mem.total)/1024/1024), half) + "   " +
+			fmt.Sprintf("Load average: %.2f %.2f %.2f ", l1, l5, l15),
+		htopMeter("  Swp", 0, fmt.Sprintf("0K/%.2fG", float64(mem.swapTotal)/1024/1024), half) + "   " +
+			"Uptime: " + uptime,
+		"",
+		"\x1b[30;42m" + fmt.Sprintf("%-*v", width, "  PID USER      PRI  NI  VIRT   RES   SHR S CPU% MEM%   TIME+  Command") + "\x1b[0m",
+	}
+	rows := height - len(lines) - 1
+	for i, p := range shown {
+		if height > 0 && i >= rows {
+			break
+		}
+		line := fmt.Sprintf("%5v %-9.9v %3v %3v %5v %5v %5v %1v %4.1f %4.1f %7v  %v", p.PID, p.User, 20, 0, htopSize(p.VSZ),
+			htopSize(p.RSS), htopSize(p.RSS*2/3), p.Stat[:1], p.CPU, p.Mem, topTime(p.Time), p.Cmd)
+		if len(line) > width {
+			line = line[:width]
+		}
+		lines = append(lines, line)
+	}
+	for height > 0 && len(lines) < height-1 {
+		lines = append(lines, "")
+	}
+	var footer strings.Builder
+	for i, label := range []string{"Help  ", "Setup ", "Search", "Filter", "Tree  ", "SortBy", "Nice -", "Nice +", "Kill  ", "Quit  "} {
+		footer.WriteString(fmt.Sprintf("F%v\x1b[30;46m%v\x1b[0m", i+1, label))
+	}
+	return append(lines, footer.String())
+}
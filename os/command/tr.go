@@ -0,0 +1,245 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/pflag"
+)
+
+// tr translates, squeezes or deletes the bytes of the input
+type tr struct{}
+
+// trClasses are the bytes of the character classes like [:alpha:]
+var trClasses = map[string]func(c byte) bool{
+	"alnum": func(c byte) bool { return c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' },
+	"alpha": func(c byte) bool { return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' },
+	"blank": func(c byte) bool { return c == ' ' || c == '\t' },
+	"cntrl": func(c byte) bool { return c < 32 || c == 127 },
+	"digit": func(c byte) bool { return c >= '0' && c <= '9' },
+	"graph": func(c byte) bool { return c > 32 && c < 127 },
+	"lower": func(c byte) bool { return c >= 'a' && c <= 'z' },
+	"print": func(c byte) bool { return c >= 32 && c < 127 },
+	"punct": func(c byte) bool {
+		return c > 32 && c < 48 || c >= ':' && c <= '@' || c >= '[' && c <= '`' || c >= '{' && c < 127
+	},
+	"space":  func(c byte) bool { return c == ' ' || c >= '\t' && c <= '\r' },
+	"upper":  func(c byte) bool { return c >= 'A' && c <= 'Z' },
+	"xdigit": func(c byte) bool { return c >= '0' && c <= '9' || c >= 'A' && c <= 'F' || c >= 'a' && c <= 'f' },
+}
+
+func init() {
+	honeyos.RegisterCommand("tr", tr{})
+}
+
+func (tr) GetHelp() string {
+	return "Usage: tr [OPTION]... SET1 [SET2]\nTry 'tr --help' for more information.\n"
+}
+
+func (tr) Where() string {
+	return "/usr/bin/tr"
+}
+
+func (t tr) Exec(args []string, sys honeyos.Sys) int {
+	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	complement := flag.BoolP("complement", "c", false, "")
+	flag.BoolVarP(complement, "C", "C", false, "")
+	del := flag.BoolP("delete", "d", false, "")
+	squeeze := flag.BoolP("squeeze-repeats", "s", false, "")
+	truncate := flag.BoolP("truncate-set1", "t", false, "")
+	help := flag.Bool("help", false, "")
+	if err := flag.Parse(args); err != nil {
+		msg := strings.Replace(err.Error(), "unknown shorthand flag: ", "invalid option -- ", 1)
+		if i := strings.Index(msg, " in -"); i >= 0 {
+			msg = msg[:i]
+		}
+		fmt.Fprintf(sys.Err(), "tr: %v\nTry 'tr --help' for more information.\n", msg)
+		return 1
+	}
+	if *help {
+		fmt.Fprint(sys.Out(), t.GetHelp())
+		return 0
+	}
+	sets := flag.Args()
+	want := 2
+	if *del && !*squeeze || *squeeze && !*del && len(sets) < 2 {
+		want = 1
+	}
+	switch {
+	case len(sets) == 0:
+		fmt.Fprintln(sys.Err(), "tr: missing operand\nTry 'tr --help' for more information.")
+		return 1
+	case len(sets) < want:
+		fmt.Fprintf(sys.Err(), "tr: missing operand after ‘%v’\nTwo strings must be given when translating.\nTry 'tr --help' for more information.\n", sets[0])
+		return 1
+	case len(sets) > want:
+		fmt.Fprintf(sys.Err(), "tr: extra operand ‘%v’\nTry 'tr --help' for more information.\n", sets[want])
+		return 1
+	}
+	set1, err := trExpand(sets[0])
+	if err != "" {
+		fmt.Fprintf(sys.Err(), "tr: %v\n", err)
+		return 1
+	}
+	if *complement {
+		var in [256]bool
+		for _, c := range set1 {
+			in[c] = true
+		}
+		set1 = nil
+		for c := 0; c < 256; c++ {
+			if !in[c] {
+				set1 = append(set1, byte(c))
+			}
+		}
+	}
+	var set2 []byte
+	if len(sets) > 1 {
+		if set2, err = trExpand(sets[1]); err != "" {
+			fmt.Fprintf(sys.Err(), "tr: %v\n", err)
+			return 1
+		}
+	}
+
+	// Translation maps the bytes of set1 to set2, the last byte of set2
+	// repeating to the length of set1 unless truncated
+	var table [256]int
+	for i := range table {
+		table[i] = i
+	}
+	var inSet1, inSqueeze [256]bool
+	for _, c := range set1 {
+		inSet1[c] = true
+	}
+	if !*del && len(sets) > 1 {
+		if len(set2) == 0 && len(set1) > 0 && !*truncate {
+			fmt.Fprintln(sys.Err(), "tr: when not truncating set1, string2 must be non-empty")
+			return 1
+		}
+		for i, c := range set1 {
+			switch {
+			case i < len(set2):
+				table[c] = int(set2[i])
+			case !*truncate:
+				table[c] = int(set2[len(set2)-1])
+			}
+		}
+	}
+	squeezeSet := set1
+	if len(set2) > 0 {
+		squeezeSet = set2
+	}
+	for _, c := range squeezeSet {
+		inSqueeze[c] = true
+	}
+
+	data, _ := ioutil.ReadAll(sys.In())
+	var buf bytes.Buffer
+	last := -1
+	for _, c := range data {
+		if *del && inSet1[c] {
+			continue
+		}
+		out := byte(table[c])
+		if *squeeze && inSqueeze[out] && int(out) == last {
+			continue
+		}
+		buf.WriteByte(out)
+		last = int(out)
+	}
+	sys.Out().Write(buf.Bytes())
+	return 0
+}
+
+// trExpand expands the set with its ranges, escapes, classes as [:digit:]
+// and repeats as [c*n] into the bytes
+func trExpand(set string) ([]byte, string) {
+	var out []byte
+	// next reads the byte at i, taking the escapes, and returns the index
+	// after it
+	next := func(i int) (byte, int) {
+		if set[i] != '\\' || i+1 == len(set) {
+			return set[i], i + 1
+		}
+		i++
+		switch c := set[i]; c {
+		case 'n':
+			return '\n', i + 1
+		case 't':
+			return '\t', i + 1
+		case 'r':
+			return '\r', i + 1
+		case 'a':
+			return '\a', i + 1
+		case 'b':
+			return '\b', i + 1
+		case 'f':
+			return '\f', i + 1
+		case 'v':
+			return '\v', i + 1
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			j := i
+			for j < len(set) && j < i+3 && set[j] >= '0' && set[j] <= '7' {
+				j++
+			}
+			n, _ := strconv.ParseUint(set[i:j], 8, 8)
+			return byte(n), j
+		default:
+			return c, i + 1
+		}
+	}
+	for i := 0; i < len(set); {
+		if strings.HasPrefix(set[i:], "[:") {
+			if end := strings.Index(set[i:], ":]"); end > 2 {
+				name := set[i+2 : i+end]
+				class, ok := trClasses[name]
+				if !ok {
+					return nil, fmt.Sprintf("invalid character class ‘%v’", name)
+				}
+				for c := 0; c < 256; c++ {
+					if class(byte(c)) {
+						out = append(out, byte(c))
+					}
+				}
+				i += end + 2
+				continue
+			}
+		}
+		if set[i] == '[' && i+2 < len(set) {
+			if star := strings.IndexByte(set[i:], '*'); star > 1 {
+				if end := strings.IndexByte(set[i:], ']'); end > star {
+					c, after := next(i + 1)
+					if after == i+star {
+						n, err := strconv.Atoi(set[i+star+1 : i+end])
+						if err != nil {
+							n = 1
+						}
+						out = append(out, bytes.Repeat([]byte{c}, n)...)
+						i += end + 1
+						continue
+					}
+				}
+			}
+		}
+		c, after := next(i)
+		if after < len(set)-1 && set[after] == '-' {
+			hi, end := next(after + 1)
+			if hi < c {
+				return nil, fmt.Sprintf("range-endpoints of ‘%c-%c’ are in reverse collating sequence order", c, hi)
+			}
+			for b := int(c); b <= int(hi); b++ {
+				out = append(out, byte(b))
+			}
+			i = end
+			continue
+		}
+		out = append(out, c)
+		i = after
+	}
+	return out, ""
+}
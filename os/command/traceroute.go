@@ -0,0 +1,168 @@
+package command
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+)
+
+// traceroute prints the routers on the way to the host in the fake network,
+// hop by hop as the probes come back
+type traceroute struct{}
+
+const tracerouteUsage = `Usage: traceroute [ -46dFITnreAUDV ] [ -f first_ttl ] [ -g gate,... ] [ -i device ] [ -m max_ttl ] [ -N squeries ] [ -p port ] [ -t tos ] [ -l flow_label ] [ -w waittime ] [ -q nqueries ] [ -s src_addr ] [ -z sendwait ] [ --fwmark=num ] host [ packetlen ]
+`
+
+func init() {
+	honeyos.RegisterCommand("traceroute", traceroute{})
+}
+
+func (traceroute) GetHelp() string {
+	return tracerouteUsage
+}
+
+func (traceroute) Where() string {
+	return "/usr/bin/traceroute"
+}
+
+func (t traceroute) Exec(args []string, sys honeyos.Sys) int {
+	busybox := pkgFamily() == "apk"
+	if !busybox && !loadPkgDB(sys, pkgFamily()).installed("traceroute") {
+		return honeyos.CommandNotFound(sys, append([]string{"traceroute"}, args...))
+	}
+	numeric := false
+	first, maxTTL, queries := 1, 30, 3
+	host := ""
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			if host == "" {
+				host = arg
+			}
+			continue
+		}
+		for j := 1; j < len(arg); j++ {
+			c := arg[j]
+			if strings.IndexByte("fgimNptlwqsz", c) >= 0 {
+				val := arg[j+1:]
+				if val == "" {
+					if i+1 >= len(args) {
+						fmt.Fprintf(sys.Err(), "Option `-%c' (argc %v) requires an argument: `-%c %v'\n", c, i+1, c,
+							map[byte]string{'f': "first_ttl", 'm': "max_ttl", 'q': "nqueries", 'w': "waittime"}[c])
+						return 2
+					}
+					i++
+					val = args[i]
+				}
+				n, err := strconv.Atoi(val)
+				switch c {
+				case 'f':
+					first = n
+				case 'm':
+					maxTTL = n
+				case 'q':
+					queries = n
+				}
+				if err != nil && strings.IndexByte("fmq", c) >= 0 {
+					fmt.Fprintf(sys.Err(), "Cannot handle `-%c' option with arg `%v' (argc %v)\n", c, val, i+1)
+					return 2
+				}
+				break
+			}
+			switch c {
+			case 'n':
+				numeric = true
+			case '4', '6', 'd', 'F', 'I', 'T', 'r', 'e', 'A', 'U', 'D':
+			case 'V':
+				fmt.Fprintln(sys.Out(), "Modern traceroute for Linux, version 2.0.21\nCopyright (c) 2008  Dmitry Butskoy,   License: GPL v2 or any later")
+				return 0
+			default:
+				fmt.Fprintf(sys.Err(), "Bad option `-%c' (argc %v)\n", c, i+1)
+				return 2
+			}
+		}
+	}
+	if host == "" {
+		if busybox {
+			fmt.Fprintln(sys.Err(), "BusyBox v1.34.1 (2022-04-04 10:19:27 UTC) multi-call binary.\n\nUsage: traceroute [-46FIlnrv] [-f 1ST_TTL] [-m MAXTTL] [-q PROBES] [-p PORT]\n\t[-t TOS] [-w WAIT_SEC] [-s SRC_IP] [-i IFACE]\n\t[-z PAUSE_MSEC] HOST [BYTES]")
+		} else {
+			fmt.Fprint(sys.Err(), tracerouteUsage)
+		}
+		return 2
+	}
+	if maxTTL < 1 || maxTTL > 255 || first < 1 || first > maxTTL {
+		fmt.Fprintln(sys.Err(), "first hop out of range")
+		return 2
+	}
+	if queries < 1 || queries > 10 {
+		fmt.Fprintln(sys.Err(), "no more than 10 probes per hop")
+		return 2
+	}
+	ip := netResolve(sys, host)
+	if ip == nil {
+		if busybox {
+			fmt.Fprintf(sys.Err(), "traceroute: bad address '%v'\n", host)
+		} else {
+			fmt.Fprintf(sys.Err(), "%v: Name or service not known\nCannot handle \"host\" cmdline arg `%v' on position 1 (argc %v)\n", host, host, len(args))
+		}
+		return 2
+	}
+	sys.Log().WithField("host", host).WithField("ip", ip.String()).Infof("User tracing route to %v", host)
+	route := netTrace(sys, ip)
+	size := 60
+	if busybox {
+		size = 46
+	}
+	fmt.Fprintf(sys.Out(), "traceroute to %v (%v), %v hops max, %v byte packets\n", host, ip, maxTTL, size)
+	name := func(ip net.IP) string {
+		if numeric {
+			return ip.String()
+		}
+		return fmt.Sprintf("%v (%v)", ip, ip)
+	}
+	for hop := first; hop <= maxTTL; hop++ {
+		var addr net.IP
+		reached := hop > len(route.hops)
+		switch {
+		case route.local && !route.alive:
+			// No one answers the ARP of hosts in the subnet
+			addr = net.ParseIP(honeyos.IPAddress())
+		case reached && route.alive:
+			addr = ip
+		case !reached:
+			addr = route.hops[hop-1]
+		}
+		line := fmt.Sprintf("%2d  ", hop)
+		if addr == nil {
+			for q := 0; q < queries; q++ {
+				if !pkgSleep(sys, 5*time.Second/time.Duration(queries)) {
+					return 0
+				}
+				line += "* "
+			}
+			fmt.Fprintln(sys.Out(), strings.TrimRight(line, " "))
+			continue
+		}
+		line += name(addr)
+		for q := 0; q < queries; q++ {
+			rtt := route.hopRTT(hop - 1)
+			mark := ""
+			if route.local && !route.alive {
+				rtt, mark = 3*time.Second+rtt, " !H"
+			}
+			if !pkgSleep(sys, rtt) {
+				return 0
+			}
+			line += fmt.Sprintf("  %.3f ms%v", float64(rtt)/float64(time.Millisecond), mark)
+		}
+		fmt.Fprintln(sys.Out(), line)
+		if addr.Equal(ip) || route.local {
+			break
+		}
+	}
+	return 0
+}
@@ -0,0 +1,31 @@
+package command
+
+import (
+	honeyos "github.com/mkishere/sshsyrup/os"
+)
+
+// truth is /bin/true and /bin/false, run instead of the shell builtins by
+// commands like nohup and setsid
+type truth struct {
+	status int
+}
+
+func init() {
+	honeyos.RegisterCommand("true", truth{0})
+	honeyos.RegisterCommand("false", truth{1})
+}
+
+func (truth) GetHelp() string {
+	return ""
+}
+
+func (t truth) Exec(args []string, sys honeyos.Sys) int {
+	return t.status
+}
+
+func (t truth) Where() string {
+	if t.status == 0 {
+		return "/bin/true"
+	}
+	return "/bin/false"
+}
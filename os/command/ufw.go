@@ -0,0 +1,1230 @@
+package command
+
+import (
+	"fmt"
+	"net"
+	pathlib "path"
+	"strconv"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/afero"
+)
+
+// ufw is the firewall frontend of Ubuntu. Its settings and rules are kept in
+// the files ufw uses so they last, and loaded into the iptables tables of the
+// session while it is enabled, where iptables sees and changes them.
+// Disabling it, opening ports and allowing everything in are logged as
+// warnings
+type ufw struct{}
+
+const (
+	ufwConfFile    = "/etc/ufw/ufw.conf"
+	ufwDefaultFile = "/etc/default/ufw"
+	ufwRulesFile   = "/lib/ufw/user.rules"
+	ufwRules6File  = "/lib/ufw/user6.rules"
+)
+
+// ufwConfig is the settings in ufw.conf and /etc/default/ufw
+type ufwConfig struct {
+	enabled  bool
+	logLevel string
+	// policy is the default of input, output and forward, like DROP
+	policy map[string]string
+	ipv6   bool
+}
+
+// ufwRule is a rule added with ufw, as the tuple it is saved with
+type ufwRule struct {
+	action     string
+	proto      string
+	dport, dst string
+	sport, src string
+	// dapp and sapp are the application profiles, - if none
+	dapp, sapp string
+	// dir is in or out, with the interface like in_eth0
+	dir string
+	v6  bool
+}
+
+// ufwApps are the application profiles in /etc/ufw/applications.d
+var ufwApps = map[string]struct {
+	title, desc, ports string
+}{
+	"OpenSSH": {"Secure shell server, an rshd replacement",
+		"OpenSSH is a free implementation of the Secure Shell protocol.", "22/tcp"},
+}
+
+// ufwServices are the services of /etc/services rules can name ports by
+var ufwServices = map[string]string{
+	"ftp": "21/tcp", "ssh": "22/tcp", "telnet": "23/tcp", "smtp": "25/tcp", "domain": "53", "http": "80/tcp",
+	"pop3": "110/tcp", "imap2": "143/tcp", "https": "443/tcp", "imaps": "993/tcp", "pop3s": "995/tcp",
+	"mysql": "3306/tcp", "ms-wbt-server": "3389/tcp", "postgresql": "5432/tcp", "http-alt": "8080/tcp",
+}
+
+const ufwConfTemplate = `# /etc/ufw/ufw.conf
+#
+
+# Set to yes to start on boot. If setting this remotely, be sure to add a rule
+# to allow your remote connection before starting ufw. Eg: 'ufw allow 22/tcp'
+ENABLED=no
+
+# Please use the 'ufw' command to set the loglevel. Eg: 'ufw logging medium'.
+# See 'man ufw' for details.
+LOGLEVEL=low
+`
+
+const ufwDefaultTemplate = `# /etc/default/ufw
+#
+
+# Set to yes to apply rules to support IPv6 (no means only IPv6 on loopback
+# accepted). You will need to 'disable' and then 'enable' the firewall for
+# the changes to take affect.
+IPV6=yes
+
+# Set the default input policy to ACCEPT, DROP, or REJECT. Please note that if
+# you change this you will most likely want to adjust your rules.
+DEFAULT_INPUT_POLICY="DROP"
+
+# Set the default output policy to ACCEPT, DROP, or REJECT. Please note that if
+# you change this you will most likely want to adjust your rules.
+DEFAULT_OUTPUT_POLICY="ACCEPT"
+
+# Set the default forward policy to ACCEPT, DROP or REJECT.  Please note that
+# if you change this you will most likely want to adjust your rules
+DEFAULT_FORWARD_POLICY="DROP"
+
+# Set the default application policy to ACCEPT, DROP, REJECT or SKIP. Please
+# note that setting this to ACCEPT may be a security risk. See 'man ufw' for
+# details
+DEFAULT_APPLICATION_POLICY="SKIP"
+
+# By default, ufw only touches its own chains. Set this to 'yes' to have ufw
+# manage the built-in chains too. Warning: setting this to 'yes' will break
+# non-ufw managed firewall rules
+MANAGE_BUILTINS=no
+
+#
+# IPT backend
+#
+# only enable if using iptables backend
+IPT_SYSCTL=/etc/ufw/sysctl.conf
+
+# Extra connection tracking modules to load. Complete list can be found in
+# net/netfilter/Kconfig of your kernel source. Some common modules:
+# nf_conntrack_irc, nf_nat_irc: DCC (Direct Client to Client) support
+# nf_conntrack_netbios_ns: NetBIOS (samba) client support
+# nf_conntrack_pptp, nf_nat_pptp: PPTP over stateful firewall/NAT
+# nf_conntrack_ftp, nf_nat_ftp: active FTP support
+# nf_conntrack_tftp, nf_nat_tftp: TFTP support (server side)
+IPT_MODULES="nf_conntrack_ftp nf_nat_ftp nf_conntrack_netbios_ns"
+`
+
+func init() {
+	honeyos.RegisterCommand("ufw", ufw{})
+	honeyos.OnFirewallLoad(func(sys honeyos.Sys, ipt map[string][]*honeyos.Chain) {
+		if !loadPkgDB(sys, pkgFamily()).installed("ufw") {
+			return
+		}
+		if cfg := ufwLoadConfig(sys); cfg.enabled {
+			ufwApply(cfg, ufwLoadRules(sys), ipt)
+		}
+	})
+}
+
+func (ufw) GetHelp() string {
+	return `
+Usage: ufw COMMAND
+
+Commands:
+ enable                          enables the firewall
+ disable                         disables the firewall
+ default ARG                     set default policy
+ logging LEVEL                   set logging to LEVEL
+ allow ARGS                      add allow rule
+ deny ARGS                       add deny rule
+ reject ARGS                     add reject rule
+ limit ARGS                      add limit rule
+ delete RULE|NUM                 delete RULE
+ insert NUM RULE                 insert RULE at NUM
+ reload                          reload firewall
+ reset                           reset firewall
+ status                          show firewall status
+ status numbered                 show firewall status as numbered list of RULES
+ status verbose                  show verbose firewall status
+ show ARG                        show firewall report
+ version                         display version information
+
+Application profile commands:
+ app list                        list application profiles
+ app info PROFILE                show information on PROFILE
+ app update PROFILE              update PROFILE
+ app default ARG                 set default application policy
+`
+}
+
+func (ufw) Where() string {
+	return "/usr/sbin/ufw"
+}
+
+// readSettings reads KEY=value of the file, unquoting the values
+func readSettings(sys honeyos.Sys, name string) map[string]string {
+	settings := map[string]string{}
+	data, _ := readFile(sys, name)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if i := strings.IndexByte(line, '='); i > 0 && !strings.HasPrefix(line, "#") {
+			settings[line[:i]] = strings.Trim(line[i+1:], `"'`)
+		}
+	}
+	return settings
+}
+
+// writeSetting changes the setting in the file, which is made from the
+// template if it is emptied
+func writeSetting(sys honeyos.Sys, name, template, key, value string) {
+	data, _ := readFile(sys, name)
+	if strings.TrimSpace(string(data)) == "" {
+		data = []byte(template)
+	}
+	lines := strings.Split(string(data), "\n")
+	found := false
+	for i, line := range lines {
+		if strings.HasPrefix(line, key+"=") {
+			lines[i], found = key+"="+value, true
+		}
+	}
+	if !found {
+		lines = append(lines[:len(lines)-1], key+"="+value, "")
+	}
+	sys.FSys().MkdirAll(pathlib.Dir(name), 0755)
+	afero.WriteFile(sys.FSys(), name, []byte(strings.Join(lines, "\n")), 0644)
+}
+
+// ufwLoadConfig reads the settings. The files emptied in the image are taken
+// as installed, where ufw is enabled on Ubuntu only
+func ufwLoadConfig(sys honeyos.Sys) ufwConfig {
+	conf, defaults := readSettings(sys, ufwConfFile), readSettings(sys, ufwDefaultFile)
+	cfg := ufwConfig{enabled: honeyos.Distro() == "ubuntu", logLevel: "low", ipv6: defaults["IPV6"] != "no",
+		policy: map[string]string{"input": "DROP", "output": "ACCEPT", "forward": "DROP"}}
+	if v, ok := conf["ENABLED"]; ok {
+		cfg.enabled = v == "yes"
+	}
+	if v, ok := conf["LOGLEVEL"]; ok {
+		cfg.logLevel = v
+	}
+	for dir := range cfg.policy {
+		if v, ok := defaults["DEFAULT_"+strings.ToUpper(dir)+"_POLICY"]; ok {
+			cfg.policy[dir] = v
+		}
+	}
+	return cfg
+}
+
+// ufwAnywhere is the address of anywhere for the family
+func ufwAnywhere(v6 bool) string {
+	if v6 {
+		return "::/0"
+	}
+	return "0.0.0.0/0"
+}
+
+// tuple is how the rule is saved in user.rules, for ufw to read it back
+func (r ufwRule) tuple() string {
+	fields := []string{r.action, r.proto, r.dport, r.dst, r.sport, r.src}
+	if r.dapp != "-" || r.sapp != "-" {
+		fields = append(fields, strings.Replace(r.dapp, " ", "%20", -1), strings.Replace(r.sapp, " ", "%20", -1))
+	}
+	return strings.Join(append(fields, r.dir), " ")
+}
+
+// ufwLoadRules reads the rules of user.rules and user6.rules. If neither
+// has any, the image is taken as installed, with ssh let in on Ubuntu
+func ufwLoadRules(sys honeyos.Sys) []ufwRule {
+	var rules []ufwRule
+	saved := false
+	for _, v6 := range []bool{false, true} {
+		name := ufwRulesFile
+		if v6 {
+			name = ufwRules6File
+		}
+		data, _ := readFile(sys, name)
+		saved = saved || strings.Contains(string(data), "### RULES ###")
+		for _, line := range strings.Split(string(data), "\n") {
+			if !strings.HasPrefix(line, "### tuple ### ") {
+				continue
+			}
+			f := strings.Fields(strings.TrimPrefix(line, "### tuple ### "))
+			r := ufwRule{dapp: "-", sapp: "-", v6: v6}
+			switch len(f) {
+			case 7:
+				r.action, r.proto, r.dport, r.dst, r.sport, r.src, r.dir = f[0], f[1], f[2], f[3], f[4], f[5], f[6]
+			case 9:
+				r.action, r.proto, r.dport, r.dst, r.sport, r.src, r.dir = f[0], f[1], f[2], f[3], f[4], f[5], f[8]
+				r.dapp, r.sapp = strings.Replace(f[6], "%20", " ", -1), strings.Replace(f[7], "%20", " ", -1)
+			default:
+				continue
+			}
+			rules = append(rules, r)
+		}
+	}
+	if !saved && honeyos.Distro() == "ubuntu" {
+		for _, v6 := range []bool{false, true} {
+			rules = append(rules, ufwRule{action: "allow", proto: "tcp", dport: "22", dst: ufwAnywhere(v6), sport: "any",
+				src: ufwAnywhere(v6), dapp: "-", sapp: "-", dir: "in", v6: v6})
+		}
+	}
+	return rules
+}
+
+// ufwSaveRules writes the rules to user.rules and user6.rules the way ufw
+// does
+func ufwSaveRules(sys honeyos.Sys, cfg ufwConfig, rules []ufwRule) {
+	for _, v6 := range []bool{false, true} {
+		name, prefix := ufwRulesFile, "ufw-"
+		if v6 {
+			name, prefix = ufwRules6File, "ufw6-"
+		}
+		var b strings.Builder
+		b.WriteString("*filter\n")
+		for _, c := range []string{"user-input", "user-output", "user-forward", "before-logging-input", "before-logging-output",
+			"before-logging-forward", "user-logging-input", "user-logging-output", "user-logging-forward", "after-logging-input",
+			"after-logging-output", "after-logging-forward", "logging-deny", "logging-allow", "user-limit", "user-limit-accept"} {
+			fmt.Fprintf(&b, ":%v%v - [0:0]\n", prefix, c)
+		}
+		b.WriteString("### RULES ###\n")
+		for _, r := range rules {
+			if r.v6 != v6 {
+				continue
+			}
+			fmt.Fprintf(&b, "\n### tuple ### %v\n", r.tuple())
+			for _, rule := range r.iptRules(prefix) {
+				b.WriteString(rule + "\n")
+			}
+		}
+		b.WriteString("\n### END RULES ###\n\n### LOGGING ###\n")
+		for _, rule := range ufwLogging(cfg, prefix) {
+			b.WriteString(rule + "\n")
+		}
+		b.WriteString("### END LOGGING ###\n\n### RATE LIMITING ###\n")
+		if cfg.logLevel != "off" {
+			fmt.Fprintf(&b, "-A %vuser-limit -m limit --limit 3/minute -j LOG --log-prefix \"[UFW LIMIT BLOCK] \"\n", prefix)
+		}
+		fmt.Fprintf(&b, "-A %vuser-limit -j REJECT\n-A %vuser-limit-accept -j ACCEPT\n### END RATE LIMITING ###\nCOMMIT\n", prefix, prefix)
+		sys.FSys().MkdirAll(pathlib.Dir(name), 0755)
+		afero.WriteFile(sys.FSys(), name, []byte(b.String()), 0640)
+	}
+}
+
+// ufwLogging is the rules logging what is blocked, unless logging is off
+func ufwLogging(cfg ufwConfig, prefix string) []string {
+	if cfg.logLevel == "off" {
+		return nil
+	}
+	limit := "-m limit --limit 3/min --limit-burst 10"
+	return []string{
+		fmt.Sprintf("-A %vafter-logging-input -j LOG --log-prefix \"[UFW BLOCK] \" %v", prefix, limit),
+		fmt.Sprintf("-A %vafter-logging-forward -j LOG --log-prefix \"[UFW BLOCK] \" %v", prefix, limit),
+		fmt.Sprintf("-A %vlogging-deny -m conntrack --ctstate INVALID -j RETURN %v", prefix, limit),
+		fmt.Sprintf("-A %vlogging-deny -j LOG --log-prefix \"[UFW BLOCK] \" %v", prefix, limit),
+		fmt.Sprintf("-A %vlogging-allow -j LOG --log-prefix \"[UFW ALLOW] \" %v", prefix, limit),
+	}
+}
+
+// iptRules are the iptables rules of the rule, in the user chains
+func (r ufwRule) iptRules(prefix string) []string {
+	chain := prefix + "user-input"
+	var base []string
+	if strings.HasPrefix(r.dir, "out") {
+		chain = prefix + "user-output"
+	}
+	if i := strings.IndexByte(r.dir, '_'); i > 0 {
+		flag := "-i"
+		if strings.HasPrefix(r.dir, "out") {
+			flag = "-o"
+		}
+		base = append(base, flag, r.dir[i+1:])
+	}
+	protos := []string{r.proto}
+	if r.proto == "any" {
+		protos = []string{""}
+		if r.dport != "any" || r.sport != "any" {
+			protos = []string{"tcp", "udp"}
+		}
+	}
+	var rules []string
+	for _, proto := range protos {
+		spec := append([]string{}, base...)
+		if proto != "" {
+			spec = append(spec, "-p", proto)
+		}
+		for _, addr := range []struct{ flag, addr, portFlag, port string }{
+			{"-d", r.dst, "--dport", r.dport}, {"-s", r.src, "--sport", r.sport}} {
+			if addr.addr != ufwAnywhere(r.v6) {
+				spec = append(spec, addr.flag, addr.addr)
+			}
+			switch {
+			case addr.port == "any":
+			case strings.ContainsAny(addr.port, ",:"):
+				spec = append(spec, "-m", "multiport", addr.portFlag+"s", addr.port)
+			default:
+				spec = append(spec, addr.portFlag, addr.port)
+			}
+		}
+		s := strings.Join(spec, " ")
+		add := func(rule string) {
+			rules = append(rules, strings.Join(strings.Fields("-A "+chain+" "+rule), " "))
+		}
+		switch r.action {
+		case "allow":
+			add(s + " -j ACCEPT")
+		case "deny":
+			add(s + " -j DROP")
+		case "reject":
+			if proto == "tcp" {
+				add(s + " -j REJECT --reject-with tcp-reset")
+			} else {
+				add(s + " -j REJECT")
+			}
+		case "limit":
+			add(s + " -m conntrack --ctstate NEW -m recent --set")
+			add(s + " -m conntrack --ctstate NEW -m recent --update --seconds 30 --hitcount 6 -j " + prefix + "user-limit")
+			add(s + " -j " + prefix + "user-limit-accept")
+		}
+	}
+	return rules
+}
+
+// ufwRuleset is the chains of ufw and their rules for the family, as set up
+// when it is enabled
+func ufwRuleset(cfg ufwConfig, rules []ufwRule, v6 bool) (chains, lines []string) {
+	prefix := "ufw-"
+	if v6 {
+		prefix = "ufw6-"
+	}
+	for _, dir := range []string{"input", "output", "forward"} {
+		for _, c := range []string{"before-logging-", "before-", "user-", "user-logging-", "after-", "after-logging-",
+			"reject-", "track-", "skip-to-policy-"} {
+			chains = append(chains, prefix+c+dir)
+		}
+	}
+	chains = append(chains, prefix+"logging-deny", prefix+"logging-allow", prefix+"user-limit", prefix+"user-limit-accept")
+	if !v6 {
+		chains = append(chains, prefix+"not-local")
+	}
+	add := func(chain, format string, a ...interface{}) {
+		lines = append(lines, "-A "+prefix+chain+" "+fmt.Sprintf(format, a...))
+	}
+	for _, dir := range []string{"input", "output", "forward"} {
+		builtin := strings.ToUpper(dir)
+		for _, c := range []string{"before-logging-", "before-", "after-", "after-logging-", "reject-", "track-"} {
+			lines = append(lines, fmt.Sprintf("-A %v -j %v%v%v", builtin, prefix, c, dir))
+		}
+		policy := cfg.policy[dir]
+		if policy == "REJECT" {
+			add("reject-"+dir, "-j REJECT")
+			policy = "DROP"
+		}
+		add("skip-to-policy-"+dir, "-j %v", policy)
+	}
+
+	add("before-input", "-i lo -j ACCEPT")
+	add("before-output", "-o lo -j ACCEPT")
+	for _, c := range []string{"before-input", "before-output", "before-forward"} {
+		add(c, "-m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT")
+	}
+	add("before-input", "-m conntrack --ctstate INVALID -j %vlogging-deny", prefix)
+	add("before-input", "-m conntrack --ctstate INVALID -j DROP")
+	if v6 {
+		for _, t := range []int{1, 2, 3, 4, 128, 133, 134, 135, 136} {
+			add("before-input", "-p icmpv6 --icmpv6-type %v -j ACCEPT", t)
+		}
+		add("before-input", "-s fe80::/10 -d fe80::/10 -p udp --sport 547 --dport 546 -j ACCEPT")
+		add("before-input", "-d ff02::fb/128 -p udp --dport 5353 -j ACCEPT")
+	} else {
+		for _, t := range []int{3, 4, 11, 12, 8} {
+			add("before-input", "-p icmp --icmp-type %v -j ACCEPT", t)
+		}
+		add("before-input", "-p udp --sport 67 --dport 68 -j ACCEPT")
+		add("before-input", "-j ufw-not-local")
+		add("before-input", "-d 224.0.0.251/32 -p udp --dport 5353 -j ACCEPT")
+		add("before-input", "-d 239.255.255.250/32 -p udp --dport 1900 -j ACCEPT")
+		for _, t := range []string{"LOCAL", "MULTICAST", "BROADCAST"} {
+			add("not-local", "-m addrtype --dst-type %v -j RETURN", t)
+		}
+		add("not-local", "-m limit --limit 3/min --limit-burst 10 -j ufw-logging-deny")
+		add("not-local", "-j DROP")
+	}
+	for _, dir := range []string{"input", "output", "forward"} {
+		add("before-"+dir, "-j %vuser-%v", prefix, dir)
+	}
+	for _, port := range []string{"udp 137", "udp 138", "tcp 139", "tcp 445", "udp 67", "udp 68"} {
+		p := strings.Fields(port)
+		add("after-input", "-p %v --dport %v -j %vskip-to-policy-input", p[0], p[1], prefix)
+	}
+	if !v6 {
+		add("after-input", "-m addrtype --dst-type BROADCAST -j ufw-skip-to-policy-input")
+	}
+	add("track-output", "-p tcp -m conntrack --ctstate NEW -j ACCEPT")
+	add("track-output", "-p udp -m conntrack --ctstate NEW -j ACCEPT")
+	lines = append(lines, ufwLogging(cfg, prefix)...)
+	if cfg.logLevel != "off" {
+		add("user-limit", "-m limit --limit 3/minute -j LOG --log-prefix \"[UFW LIMIT BLOCK] \"")
+	}
+	add("user-limit", "-j REJECT")
+	add("user-limit-accept", "-j ACCEPT")
+	for _, r := range rules {
+		if r.v6 == v6 {
+			lines = append(lines, r.iptRules(prefix)...)
+		}
+	}
+	return chains, lines
+}
+
+// iptJumpsTo tells if the rule jumps or goes to a chain starting with prefix
+func iptJumpsTo(rule, prefix string) bool {
+	args := iptSplit(rule)
+	for i := 0; i+1 < len(args); i++ {
+		if (args[i] == "-j" || args[i] == "-g") && strings.HasPrefix(args[i+1], prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ufwApply takes the chains of ufw out of the filter tables, and puts them
+// back as set up if ufw is enabled. Rules added by others are left alone
+func ufwApply(cfg ufwConfig, rules []ufwRule, ipt map[string][]*honeyos.Chain) {
+	for _, v6 := range []bool{false, true} {
+		key, prefix := "filter", "ufw-"
+		if v6 {
+			key, prefix = "ip6 filter", "ufw6-"
+		}
+		var chains []*honeyos.Chain
+		for _, c := range ipt[key] {
+			if strings.HasPrefix(c.Name, prefix) {
+				continue
+			}
+			var kept []string
+			for _, r := range c.Rules {
+				if !iptJumpsTo(r, prefix) {
+					kept = append(kept, r)
+				}
+			}
+			c.Rules = kept
+			if c.Policy != "" {
+				// ufw leaves the builtin chains accepting when it stops
+				c.Policy = "ACCEPT"
+			}
+			chains = append(chains, c)
+		}
+		if cfg.enabled && (!v6 || cfg.ipv6) {
+			names, lines := ufwRuleset(cfg, rules, v6)
+			for _, name := range names {
+				chains = append(chains, &honeyos.Chain{Name: name})
+			}
+			for _, c := range chains {
+				if policy := cfg.policy[strings.ToLower(c.Name)]; policy != "" && c.Policy != "" {
+					c.Policy = strings.Replace(policy, "REJECT", "DROP", 1)
+				}
+			}
+			ipt := iptables{v6}
+			for _, line := range lines {
+				args := iptSplit(line)
+				c := iptChain(chains, args[1])
+				if rule, err := ipt.rule(args[2:], chains); err == nil && c != nil {
+					c.Rules = append(c.Rules, rule)
+				}
+			}
+		}
+		ipt[key] = chains
+	}
+}
+
+// ufwRunning tells if the chains of ufw are in the firewall of the session,
+// which iptables may have flushed
+func ufwRunning(sys honeyos.Sys) bool {
+	running := false
+	honeyos.UpdateFirewall(sys, func(ipt map[string][]*honeyos.Chain, _ *[]*honeyos.NftTable) {
+		running = iptChain(ipt["filter"], "ufw-user-input") != nil
+	})
+	return running
+}
+
+// ufwPort resolves the port given, like 22, 80,443, 6000:6007 or ssh, with
+// the protocol of the service if named by one
+func ufwPort(s string) (port, proto string, ok bool) {
+	if svc, found := ufwServices[s]; found {
+		parts := strings.SplitN(svc, "/", 2)
+		if len(parts) == 2 {
+			return parts[0], parts[1], true
+		}
+		return parts[0], "any", true
+	}
+	list := strings.Split(s, ",")
+	for _, p := range list {
+		bounds := strings.Split(p, ":")
+		if len(bounds) > 2 {
+			return "", "", false
+		}
+		for _, b := range bounds {
+			if n, err := strconv.Atoi(b); err != nil || n < 1 || n > 65535 {
+				return "", "", false
+			}
+		}
+	}
+	return s, "any", true
+}
+
+// ufwParse parses the rule given to allow, deny, reject and limit into the
+// rules for IPv4 and IPv6
+func ufwParse(cfg ufwConfig, action string, args []string) ([]ufwRule, string) {
+	r := ufwRule{action: action, proto: "any", dport: "any", sport: "any", dapp: "-", sapp: "-", dir: "in"}
+	if len(args) > 0 && (args[0] == "in" || args[0] == "out") {
+		r.dir, args = args[0], args[1:]
+	}
+	if len(args) > 1 && args[0] == "on" {
+		r.dir, args = r.dir+"_"+args[1], args[2:]
+	}
+	if len(args) > 0 && (args[0] == "log" || args[0] == "log-all") {
+		args = args[1:]
+	}
+	src, dst := "any", "any"
+	switch {
+	case len(args) == 0:
+		return nil, "ERROR: Invalid syntax"
+	case len(args) == 1:
+		spec := args[0]
+		if app, ok := ufwApps[spec]; ok {
+			parts := strings.SplitN(app.ports, "/", 2)
+			r.dapp, r.dport, r.proto = spec, parts[0], parts[1]
+			break
+		}
+		proto := ""
+		if i := strings.IndexByte(spec, '/'); i >= 0 {
+			spec, proto = spec[:i], spec[i+1:]
+			if proto != "tcp" && proto != "udp" {
+				return nil, "ERROR: Invalid syntax"
+			}
+		}
+		port, svcProto, ok := ufwPort(spec)
+		if !ok {
+			if _, err := strconv.Atoi(strings.SplitN(spec, ":", 2)[0]); err == nil {
+				return nil, "ERROR: Bad port"
+			}
+			return nil, fmt.Sprintf("ERROR: Could not find a profile matching '%v'", args[0])
+		}
+		if proto == "" {
+			proto = svcProto
+		}
+		if proto == "any" && strings.ContainsAny(port, ",:") {
+			return nil, "ERROR: Must specify 'tcp' or 'udp' with multiple ports"
+		}
+		r.dport, r.proto = port, proto
+	default:
+		var last *string
+		to := false
+		for i := 0; i < len(args); i++ {
+			if i+1 >= len(args) {
+				return nil, "ERROR: Invalid syntax"
+			}
+			v := args[i+1]
+			switch args[i] {
+			case "from":
+				src, last, to = v, &r.sport, false
+			case "to":
+				dst, last, to = v, &r.dport, true
+			case "port":
+				if last == nil {
+					return nil, "ERROR: Invalid syntax"
+				}
+				port, proto, ok := ufwPort(v)
+				if !ok {
+					return nil, "ERROR: Bad port"
+				}
+				*last = port
+				if proto != "any" {
+					r.proto = proto
+				}
+			case "proto":
+				if v != "tcp" && v != "udp" && v != "any" {
+					return nil, fmt.Sprintf("ERROR: Unsupported protocol '%v'", v)
+				}
+				r.proto = v
+			case "app":
+				app, ok := ufwApps[v]
+				if !ok {
+					return nil, fmt.Sprintf("ERROR: Could not find a profile matching '%v'", v)
+				}
+				parts := strings.SplitN(app.ports, "/", 2)
+				if to {
+					r.dapp, r.dport = v, parts[0]
+				} else {
+					r.sapp, r.sport = v, parts[0]
+				}
+				r.proto = parts[1]
+			case "comment":
+			default:
+				return nil, "ERROR: Invalid syntax"
+			}
+			i++
+		}
+		if src == "any" && dst == "any" && r.dport == "any" && r.sport == "any" {
+			return nil, "ERROR: Need 'to' or 'from' clause"
+		}
+		if r.proto == "any" && (strings.ContainsAny(r.dport, ",:") || strings.ContainsAny(r.sport, ",:")) {
+			return nil, "ERROR: Must specify 'tcp' or 'udp' with multiple ports"
+		}
+	}
+
+	// The rule is for the families its addresses are of
+	family := func(addr, what string) (v4, v6 bool, msg string) {
+		if addr == "any" {
+			return true, true, ""
+		}
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			var err error
+			if ip, _, err = net.ParseCIDR(addr); err != nil {
+				return false, false, fmt.Sprintf("ERROR: Bad %v address", what)
+			}
+		}
+		return ip.To4() != nil, ip.To4() == nil, ""
+	}
+	src4, src6, msg := family(src, "source")
+	if msg != "" {
+		return nil, msg
+	}
+	dst4, dst6, msg := family(dst, "destination")
+	if msg != "" {
+		return nil, msg
+	}
+	var rules []ufwRule
+	for _, v6 := range []bool{false, true} {
+		if v6 && (!src6 || !dst6 || !cfg.ipv6) || !v6 && (!src4 || !dst4) {
+			continue
+		}
+		rule := r
+		rule.v6, rule.src, rule.dst = v6, ufwAnywhere(v6), ufwAnywhere(v6)
+		a := iptables{v6}
+		if src != "any" {
+			rule.src, _ = a.address(src)
+			rule.src = strings.TrimSuffix(strings.TrimSuffix(rule.src, "/32"), "/128")
+		}
+		if dst != "any" {
+			rule.dst, _ = a.address(dst)
+			rule.dst = strings.TrimSuffix(strings.TrimSuffix(rule.dst, "/32"), "/128")
+		}
+		rules = append(rules, rule)
+	}
+	if len(rules) == 0 {
+		return nil, "ERROR: Invalid address family"
+	}
+	return rules, ""
+}
+
+// ufwEndpoint is the address and port as shown in the status
+func ufwEndpoint(addr, port, proto, app string, v6 bool) string {
+	var s string
+	switch {
+	case app != "-":
+		s = app
+	case port != "any" && proto != "any":
+		s = port + "/" + proto
+	case port != "any":
+		s = port
+	}
+	if addr != ufwAnywhere(v6) {
+		return strings.TrimSpace(addr + " " + s)
+	}
+	if s == "" {
+		s = "Anywhere"
+	}
+	if v6 {
+		s += " (v6)"
+	}
+	return s
+}
+
+// status formats the rule as a row of ufw status
+func (r ufwRule) status(verbose bool) string {
+	action := strings.ToUpper(r.action)
+	out := strings.HasPrefix(r.dir, "out")
+	switch {
+	case out:
+		action += " OUT"
+	case verbose:
+		action += " IN"
+	}
+	to := ufwEndpoint(r.dst, r.dport, r.proto, r.dapp, r.v6)
+	from := ufwEndpoint(r.src, r.sport, r.proto, r.sapp, r.v6)
+	if r.sport == "any" && r.sapp == "-" && r.src == ufwAnywhere(r.v6) {
+		from = "Anywhere"
+		if r.v6 {
+			from += " (v6)"
+		}
+	}
+	if i := strings.IndexByte(r.dir, '_'); i > 0 {
+		to += " on " + r.dir[i+1:]
+	}
+	return fmt.Sprintf("%-26v %-12v%v", to, action, from)
+}
+
+// command is the rule as given to ufw, as ufw show added lists it
+func (r ufwRule) command() string {
+	parts := []string{"ufw", r.action}
+	if r.dir != "in" {
+		dir := strings.SplitN(r.dir, "_", 2)
+		parts = append(parts, dir[0])
+		if len(dir) > 1 {
+			parts = append(parts, "on", dir[1])
+		}
+	}
+	anywhere := ufwAnywhere(r.v6)
+	if r.src == anywhere && r.dst == anywhere && r.sport == "any" && r.sapp == "-" {
+		switch {
+		case r.dapp != "-":
+			return strings.Join(append(parts, r.dapp), " ")
+		case r.proto != "any":
+			return strings.Join(append(parts, r.dport+"/"+r.proto), " ")
+		case r.dport != "any":
+			return strings.Join(append(parts, r.dport), " ")
+		}
+	}
+	addr := func(a string) string {
+		if a == anywhere {
+			return "any"
+		}
+		return a
+	}
+	parts = append(parts, "from", addr(r.src))
+	if r.sport != "any" {
+		parts = append(parts, "port", r.sport)
+	}
+	parts = append(parts, "to", addr(r.dst))
+	if r.dport != "any" {
+		parts = append(parts, "port", r.dport)
+	}
+	if r.proto != "any" {
+		parts = append(parts, "proto", r.proto)
+	}
+	return strings.Join(parts, " ")
+}
+
+// ufwOrdered is the rules for IPv4 first, as numbered by ufw
+func ufwOrdered(rules []ufwRule) []ufwRule {
+	var ordered []ufwRule
+	for _, v6 := range []bool{false, true} {
+		for _, r := range rules {
+			if r.v6 == v6 {
+				ordered = append(ordered, r)
+			}
+		}
+	}
+	return ordered
+}
+
+func (u ufw) Exec(args []string, sys honeyos.Sys) int {
+	if !loadPkgDB(sys, pkgFamily()).installed("ufw") {
+		return honeyos.CommandNotFound(sys, append([]string{"ufw"}, args...))
+	}
+	force, dryRun := false, false
+	var cmd []string
+	for _, arg := range args {
+		switch arg {
+		case "--force":
+			force = true
+		case "--dry-run":
+			dryRun = true
+		case "-h", "--help", "help":
+			fmt.Fprint(sys.Out(), u.GetHelp())
+			return 0
+		case "--version", "version":
+			fmt.Fprintln(sys.Out(), "ufw 0.35\nCopyright 2008-2015 Canonical Ltd.")
+			return 0
+		default:
+			cmd = append(cmd, arg)
+		}
+	}
+	if len(cmd) == 0 {
+		fmt.Fprintln(sys.Err(), "ERROR: not enough args")
+		return 1
+	}
+	if !isRoot(sys) {
+		fmt.Fprintln(sys.Err(), "ERROR: You need to be root to run this script")
+		return 1
+	}
+	logger := sys.Log().WithField("args", args)
+	cfg, rules := ufwLoadConfig(sys), ufwLoadRules(sys)
+	active := cfg.enabled && ufwRunning(sys)
+	confirm := func(question string) bool {
+		if force {
+			return true
+		}
+		if !strings.HasSuffix(question, "\n") {
+			question += " "
+		}
+		fmt.Fprint(sys.Out(), question+"Proceed with operation (y|n)? ")
+		answer, _ := readLine(sys.In())
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+			fmt.Fprintln(sys.Out(), "Aborted")
+			return false
+		}
+		return true
+	}
+	apply := func() {
+		if dryRun {
+			return
+		}
+		ufwSaveRules(sys, cfg, rules)
+		honeyos.UpdateFirewall(sys, func(ipt map[string][]*honeyos.Chain, _ *[]*honeyos.NftTable) {
+			ufwApply(cfg, rules, ipt)
+		})
+	}
+	invalid := func() int {
+		fmt.Fprintln(sys.Err(), "ERROR: Invalid syntax")
+		return 1
+	}
+
+	switch cmd[0] {
+	case "enable":
+		if len(cmd) > 1 {
+			return invalid()
+		}
+		if !confirm("Command may disrupt existing ssh connections.") {
+			return 0
+		}
+		cfg.enabled = true
+		if !dryRun {
+			writeSetting(sys, ufwConfFile, ufwConfTemplate, "ENABLED", "yes")
+		}
+		apply()
+		logger.Infof("User enabled the firewall with ufw")
+		fmt.Fprintln(sys.Out(), "Firewall is active and enabled on system startup")
+	case "disable":
+		if len(cmd) > 1 {
+			return invalid()
+		}
+		cfg.enabled = false
+		if !dryRun {
+			writeSetting(sys, ufwConfFile, ufwConfTemplate, "ENABLED", "no")
+		}
+		apply()
+		logger.Warnf("User disabled the firewall with ufw")
+		fmt.Fprintln(sys.Out(), "Firewall stopped and disabled on system startup")
+	case "reload":
+		if !active {
+			fmt.Fprintln(sys.Out(), "Firewall not enabled (skipping reload)")
+			return 0
+		}
+		apply()
+		fmt.Fprintln(sys.Out(), "Firewall reloaded")
+	case "status":
+		verbose, numbered := false, false
+		for _, opt := range cmd[1:] {
+			switch opt {
+			case "verbose":
+				verbose = true
+			case "numbered":
+				numbered = true
+			default:
+				return invalid()
+			}
+		}
+		if !active {
+			fmt.Fprintln(sys.Out(), "Status: inactive")
+			return 0
+		}
+		var b strings.Builder
+		b.WriteString("Status: active\n")
+		if verbose {
+			logging := "off"
+			if cfg.logLevel != "off" {
+				logging = "on (" + cfg.logLevel + ")"
+			}
+			word := map[string]string{"DROP": "deny", "ACCEPT": "allow", "REJECT": "reject"}
+			fmt.Fprintf(&b, "Logging: %v\nDefault: %v (incoming), %v (outgoing), disabled (routed)\nNew profiles: skip\n",
+				logging, word[cfg.policy["input"]], word[cfg.policy["output"]])
+		}
+		if len(rules) > 0 {
+			indent := ""
+			if numbered {
+				indent = "     "
+			}
+			fmt.Fprintf(&b, "\n%vTo                         Action      From\n%v--                         ------      ----\n", indent, indent)
+			for i, r := range ufwOrdered(rules) {
+				if numbered {
+					fmt.Fprintf(&b, "[%2d] ", i+1)
+				}
+				b.WriteString(strings.TrimRight(r.status(verbose || numbered), " ") + "\n")
+			}
+			b.WriteString("\n")
+		}
+		fmt.Fprint(sys.Out(), b.String())
+	case "default":
+		if len(cmd) < 2 || len(cmd) > 3 {
+			return invalid()
+		}
+		policy := map[string]string{"allow": "ACCEPT", "deny": "DROP", "reject": "REJECT"}[cmd[1]]
+		dir := "incoming"
+		if len(cmd) == 3 {
+			dir = cmd[2]
+		}
+		key := map[string]string{"incoming": "input", "outgoing": "output", "routed": "forward"}[dir]
+		if policy == "" || key == "" {
+			return invalid()
+		}
+		cfg.policy[key] = policy
+		if !dryRun {
+			writeSetting(sys, ufwDefaultFile, ufwDefaultTemplate, "DEFAULT_"+strings.ToUpper(key)+"_POLICY", `"`+policy+`"`)
+		}
+		if active {
+			apply()
+		}
+		if policy == "ACCEPT" && key == "input" {
+			logger.Warnf("User set default incoming policy to allow with ufw")
+		} else {
+			logger.Infof("User changed default policy with ufw")
+		}
+		fmt.Fprintf(sys.Out(), "Default %v policy changed to '%v'\n(be sure to update your rules accordingly)\n", dir, cmd[1])
+	case "logging":
+		if len(cmd) != 2 {
+			return invalid()
+		}
+		level := cmd[1]
+		switch level {
+		case "on":
+			level = "low"
+		case "off", "low", "medium", "high", "full":
+		default:
+			return invalid()
+		}
+		cfg.logLevel = level
+		if !dryRun {
+			writeSetting(sys, ufwConfFile, ufwConfTemplate, "LOGLEVEL", level)
+		}
+		if active {
+			apply()
+		}
+		if level == "off" {
+			logger.Warnf("User turned off firewall logging with ufw")
+			fmt.Fprintln(sys.Out(), "Logging disabled")
+		} else {
+			fmt.Fprintln(sys.Out(), "Logging enabled")
+		}
+	case "allow", "deny", "reject", "limit", "insert":
+		pos := 0
+		spec := cmd
+		if cmd[0] == "insert" {
+			if len(cmd) < 3 {
+				return invalid()
+			}
+			n, err := strconv.Atoi(cmd[1])
+			if err != nil || n < 1 || n > len(rules) {
+				fmt.Fprintf(sys.Err(), "ERROR: Invalid position '%v'\n", cmd[1])
+				return 1
+			}
+			pos, spec = n, cmd[2:]
+		}
+		switch spec[0] {
+		case "allow", "deny", "reject", "limit":
+		default:
+			return invalid()
+		}
+		added, msg := ufwParse(cfg, spec[0], spec[1:])
+		if msg != "" {
+			fmt.Fprintln(sys.Err(), msg)
+			return 1
+		}
+		for _, r := range added {
+			suffix := ""
+			if r.v6 {
+				suffix = " (v6)"
+			}
+			exists := false
+			for _, old := range rules {
+				exists = exists || old == r
+			}
+			switch {
+			case exists:
+				fmt.Fprintln(sys.Out(), "Skipping adding existing rule"+suffix)
+				continue
+			case pos > 0:
+				ordered := ufwOrdered(rules)
+				at := ordered[pos-1]
+				if at.v6 != r.v6 {
+					// Rules are inserted among those of their family
+					rules = append(rules, r)
+				} else {
+					var inserted []ufwRule
+					for _, old := range rules {
+						if old == at {
+							inserted = append(inserted, r)
+						}
+						inserted = append(inserted, old)
+					}
+					rules = inserted
+				}
+			default:
+				rules = append(rules, r)
+			}
+			switch {
+			case !active:
+				fmt.Fprintln(sys.Out(), "Rules updated"+suffix)
+			case pos > 0:
+				fmt.Fprintln(sys.Out(), "Rule inserted"+suffix)
+			default:
+				fmt.Fprintln(sys.Out(), "Rule added"+suffix)
+			}
+		}
+		if active {
+			apply()
+		} else if !dryRun {
+			ufwSaveRules(sys, cfg, rules)
+		}
+		logger = logger.WithField("rule", added[0].command())
+		if spec[0] == "allow" || spec[0] == "limit" {
+			logger.Warnf("User opened port with ufw")
+		} else {
+			logger.Infof("User changed firewall rules with ufw")
+		}
+	case "delete":
+		if len(cmd) < 2 {
+			return invalid()
+		}
+		var removed []ufwRule
+		if n, err := strconv.Atoi(cmd[1]); err == nil && len(cmd) == 2 {
+			ordered := ufwOrdered(rules)
+			if n < 1 || n > len(ordered) {
+				fmt.Fprintf(sys.Err(), "ERROR: Could not find rule '%v'\n", cmd[1])
+				return 1
+			}
+			r := ordered[n-1]
+			if !confirm(fmt.Sprintf("Deleting:\n %v\n", strings.TrimPrefix(r.command(), "ufw "))) {
+				return 0
+			}
+			removed = []ufwRule{r}
+		} else {
+			switch cmd[1] {
+			case "allow", "deny", "reject", "limit":
+			default:
+				return invalid()
+			}
+			var msg string
+			if removed, msg = ufwParse(cfg, cmd[1], cmd[2:]); msg != "" {
+				fmt.Fprintln(sys.Err(), msg)
+				return 1
+			}
+		}
+		for _, r := range removed {
+			suffix := ""
+			if r.v6 {
+				suffix = " (v6)"
+			}
+			var kept []ufwRule
+			for _, old := range rules {
+				if old != r {
+					kept = append(kept, old)
+				}
+			}
+			if len(kept) == len(rules) {
+				fmt.Fprintln(sys.Out(), "Could not delete non-existent rule"+suffix)
+				continue
+			}
+			rules = kept
+			if active {
+				fmt.Fprintln(sys.Out(), "Rule deleted"+suffix)
+			} else {
+				fmt.Fprintln(sys.Out(), "Rules updated"+suffix)
+			}
+		}
+		if active {
+			apply()
+		} else if !dryRun {
+			ufwSaveRules(sys, cfg, rules)
+		}
+		logger.Warnf("User deleted firewall rules with ufw")
+	case "reset":
+		if len(cmd) > 1 {
+			return invalid()
+		}
+		if !confirm("Resetting all rules to installed defaults.") {
+			return 0
+		}
+		if !dryRun {
+			stamp := honeyos.Now(sys).Format("20060102_150405")
+			for _, name := range []string{ufwRulesFile, "/etc/ufw/before.rules", "/etc/ufw/after.rules",
+				ufwRules6File, "/etc/ufw/before6.rules", "/etc/ufw/after6.rules"} {
+				data, err := readFile(sys, name)
+				if err != nil {
+					continue
+				}
+				backup := name + "." + stamp
+				fmt.Fprintf(sys.Out(), "Backing up '%v' to '%v'\n", pathlib.Base(name), backup)
+				afero.WriteFile(sys.FSys(), backup, data, 0640)
+			}
+			writeSetting(sys, ufwConfFile, ufwConfTemplate, "ENABLED", "no")
+		}
+		cfg.enabled, rules = false, nil
+		apply()
+		logger.Warnf("User reset the firewall with ufw")
+	case "show":
+		if len(cmd) != 2 {
+			return invalid()
+		}
+		switch cmd[1] {
+		case "added":
+			fmt.Fprintln(sys.Out(), "Added user rules (see 'ufw status' for running firewall):")
+			seen := map[string]bool{}
+			for _, r := range ufwOrdered(rules) {
+				if c := r.command(); !seen[c] {
+					seen[c] = true
+					fmt.Fprintln(sys.Out(), c)
+				}
+			}
+			if len(rules) == 0 {
+				fmt.Fprintln(sys.Out(), "(None)")
+			}
+		case "user-rules":
+			for _, name := range []string{ufwRulesFile, ufwRules6File} {
+				data, _ := readFile(sys, name)
+				fmt.Fprint(sys.Out(), string(data))
+			}
+		default:
+			return invalid()
+		}
+	case "app":
+		if len(cmd) < 2 {
+			return invalid()
+		}
+		switch cmd[1] {
+		case "list":
+			fmt.Fprintln(sys.Out(), "Available applications:")
+			for name := range ufwApps {
+				fmt.Fprintln(sys.Out(), "  "+name)
+			}
+		case "info":
+			if len(cmd) != 3 {
+				return invalid()
+			}
+			app, ok := ufwApps[cmd[2]]
+			if !ok {
+				fmt.Fprintf(sys.Err(), "ERROR: Could not find profile '%v'\n", cmd[2])
+				return 1
+			}
+			fmt.Fprintf(sys.Out(), "Profile: %v\nTitle: %v\nDescription: %v\n\nPort:\n  %v\n", cmd[2], app.title, app.desc, app.ports)
+		case "update", "default":
+			if len(cmd) != 3 {
+				return invalid()
+			}
+			if cmd[1] == "default" {
+				fmt.Fprintf(sys.Out(), "Default application policy changed to '%v'\n", cmd[2])
+			}
+		default:
+			return invalid()
+		}
+	default:
+		return invalid()
+	}
+	return 0
+}
@@ -11,16 +11,6 @@ import (
 
 type uname struct{}
 
-const (
-	unameKName  = "Linux"
-	unameKRel   = "4.4.0-43-generic"
-	unameKVer   = "#129-Ubuntu SMP Thu Mar 17 20:17:14 UTC 2017"
-	unameMach   = "x86-64"
-	unameProc   = "x86-64"
-	unameHWPlat = "x86-64"
-	unameOS     = "GNU/Linux"
-)
-
 func init() {
 	os.RegisterCommand("uname", uname{})
 }
@@ -42,7 +32,7 @@ func (un uname) Exec(args []string, sys os.Sys) int {
 	hwPlat := flag.BoolP("hardware-platform", "i", false, "print the hardware platform or \"unknown\"")
 	help := flag.Bool("help", false, "display this help and exit")
 	ver := flag.Bool("version", false, "output version information and exit")
-	os := flag.BoolP("operating-system", "o", false, "print the operating system")
+	opSys := flag.BoolP("operating-system", "o", false, "print the operating system")
 	flag.SetOutput(sys.Out())
 	flag.Usage = func() {
 		fmt.Fprintf(sys.Out(), "Usage: uname [OPTION]...\n")
@@ -57,44 +47,42 @@ func (un uname) Exec(args []string, sys os.Sys) int {
 	if err != nil {
 		return 1
 	}
-	if *all {
-		fmt.Fprintf(sys.Out(), "%v %v %v %v %v %v %v %v\n", unameKName, sys.Hostname(), unameKRel,
-			unameKVer, unameMach, unameProc, unameHWPlat, unameOS)
-	} else if len(args) == 0 {
-		fmt.Fprintln(sys.Out(), unameKName)
-	} else if *ver {
+	if *ver {
 		fmt.Fprint(sys.Out(), un.PrintVer())
+		return 0
 	} else if *help {
 		flag.Usage()
-	} else {
-		var uNameStr bytes.Buffer
-		switch {
-		case *kName:
-			uNameStr.WriteString(unameKName)
-			fallthrough
-		case *nName:
-			uNameStr.WriteString(" " + sys.Hostname())
-			fallthrough
-		case *kRel:
-			uNameStr.WriteString(" " + unameKRel)
-			fallthrough
-		case *kVer:
-			uNameStr.WriteString(" " + unameKVer)
-			fallthrough
-		case *mach:
-			uNameStr.WriteString(" " + unameMach)
-			fallthrough
-		case *proc:
-			uNameStr.WriteString(" " + unameProc)
-			fallthrough
-		case *hwPlat:
-			uNameStr.WriteString(" " + unameHWPlat)
-			fallthrough
-		case *os:
-			uNameStr.WriteString(" " + unameOS)
+		return 0
+	}
+	busybox := os.Distro() == "alpine"
+	osName := "GNU/Linux"
+	if busybox {
+		osName = "Linux"
+	}
+	if len(args) == 0 {
+		*kName = true
+	}
+	// Fields are printed in the same order, whatever order the flags are in
+	fields := []struct {
+		on    bool
+		value string
+	}{
+		{*all || *kName, "Linux"},
+		{*all || *nName, sys.Hostname()},
+		{*all || *kRel, os.KernelRelease()},
+		{*all || *kVer, os.KernelVersion()},
+		{*all || *mach, os.Arch()},
+		{*all && !busybox || *proc, os.Arch()},
+		{*all && !busybox || *hwPlat, os.Arch()},
+		{*all || *opSys, osName},
+	}
+	var uNameStr bytes.Buffer
+	for _, f := range fields {
+		if f.on {
+			uNameStr.WriteString(" " + f.value)
 		}
-		fmt.Fprintln(sys.Out(), strings.TrimSpace(uNameStr.String()))
 	}
+	fmt.Fprintln(sys.Out(), strings.TrimSpace(uNameStr.String()))
 	return 0
 }
 
@@ -103,8 +91,8 @@ func (un uname) Where() string {
 }
 
 func (un uname) PrintVer() string {
-	return "uname (GNU coreutils) 8.21\n" +
-		"Copyright (C) 2013 Free Software Foundation, Inc.\n" +
+	return "uname (GNU coreutils) 8.25\n" +
+		"Copyright (C) 2016 Free Software Foundation, Inc.\n" +
 		"License GPLv3+: GNU GPL version 3 or later <http://gnu.org/licenses/gpl.html>.\n" +
 		"This is free software: you are free to change and redistribute it.\n" +
 		"There is NO WARRANTY, to the extent permitted by law.\n\n" +
@@ -0,0 +1,132 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/afero"
+	"github.com/spf13/pflag"
+)
+
+// uniq drops the repeated adjacent lines of the input
+type uniq struct{}
+
+func init() {
+	honeyos.RegisterCommand("uniq", uniq{})
+}
+
+func (uniq) GetHelp() string {
+	return "Usage: uniq [OPTION]... [INPUT [OUTPUT]]\nTry 'uniq --help' for more information.\n"
+}
+
+func (uniq) Where() string {
+	return "/usr/bin/uniq"
+}
+
+func (u uniq) Exec(args []string, sys honeyos.Sys) int {
+	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	count := flag.BoolP("count", "c", false, "")
+	repeated := flag.BoolP("repeated", "d", false, "")
+	allRepeated := flag.BoolP("all-repeated", "D", false, "")
+	unique := flag.BoolP("unique", "u", false, "")
+	fold := flag.BoolP("ignore-case", "i", false, "")
+	skipFields := flag.IntP("skip-fields", "f", 0, "")
+	skipChars := flag.IntP("skip-chars", "s", 0, "")
+	width := flag.IntP("check-chars", "w", -1, "")
+	zero := flag.BoolP("zero-terminated", "z", false, "")
+	help := flag.Bool("help", false, "")
+	if err := flag.Parse(args); err != nil {
+		msg := strings.Replace(err.Error(), "unknown shorthand flag: ", "invalid option -- ", 1)
+		if i := strings.Index(msg, " in -"); i >= 0 {
+			msg = msg[:i]
+		}
+		fmt.Fprintf(sys.Err(), "uniq: %v\nTry 'uniq --help' for more information.\n", msg)
+		return 1
+	}
+	if *help {
+		fmt.Fprint(sys.Out(), u.GetHelp())
+		return 0
+	}
+	files := flag.Args()
+	if len(files) > 2 {
+		fmt.Fprintf(sys.Err(), "uniq: extra operand ‘%v’\nTry 'uniq --help' for more information.\n", files[2])
+		return 1
+	}
+	if *count && *allRepeated {
+		fmt.Fprintln(sys.Err(), "uniq: printing all duplicated lines and repeat counts is meaningless\nTry 'uniq --help' for more information.")
+		return 1
+	}
+	input := "-"
+	if len(files) > 0 {
+		input = files[0]
+	}
+	data, ok := headOpen(sys, "uniq", input)
+	if !ok {
+		return 1
+	}
+	delim := byte('\n')
+	if *zero {
+		delim = 0
+	}
+
+	// key is the part of the line compared, after the skipped fields and
+	// characters
+	key := func(line []byte) []byte {
+		line = bytes.TrimSuffix(line, []byte{delim})
+		for f := 0; f < *skipFields; f++ {
+			line = bytes.TrimLeft(line, " \t")
+			if i := bytes.IndexAny(line, " \t"); i >= 0 {
+				line = line[i:]
+			} else {
+				line = nil
+			}
+		}
+		line = line[intMin(*skipChars, len(line)):]
+		if *width >= 0 {
+			line = line[:intMin(*width, len(line))]
+		}
+		if *fold {
+			line = bytes.ToLower(line)
+		}
+		return line
+	}
+	var buf bytes.Buffer
+	lines := headLines(data, delim)
+	for i := 0; i < len(lines); {
+		j := i + 1
+		for j < len(lines) && bytes.Equal(key(lines[i]), key(lines[j])) {
+			j++
+		}
+		n := j - i
+		switch {
+		case *allRepeated:
+			if n > 1 {
+				for _, line := range lines[i:j] {
+					buf.Write(bytes.TrimSuffix(line, []byte{delim}))
+					buf.WriteByte(delim)
+				}
+			}
+		case *repeated && n == 1, *unique && n > 1:
+		default:
+			if *count {
+				fmt.Fprintf(&buf, "%7d ", n)
+			}
+			buf.Write(bytes.TrimSuffix(lines[i], []byte{delim}))
+			buf.WriteByte(delim)
+		}
+		i = j
+	}
+	if len(files) > 1 && files[1] != "-" {
+		if err := afero.WriteFile(sys.FSys(), absPath(sys, files[1]), buf.Bytes(), 0644); err != nil {
+			fmt.Fprintf(sys.Err(), "uniq: %v: Permission denied\n", files[1])
+			return 1
+		}
+		return 0
+	}
+	sys.Out().Write(buf.Bytes())
+	return 0
+}
@@ -2,8 +2,7 @@ package command
 
 import (
 	"fmt"
-	"math/rand"
-	"time"
+	"strings"
 
 	"github.com/mkishere/sshsyrup/os"
 )
@@ -15,18 +14,70 @@ func init() {
 }
 
 func (uptime) GetHelp() string {
-	return ""
+	return `
+Usage:
+ uptime [options]
+
+Options:
+ -p, --pretty   show uptime in pretty format
+ -h, --help     display this help and exit
+ -s, --since    system up since
+ -V, --version  output version information and exit
+
+For more details see uptime(1).
+`
 }
 
-func (uptime) Exec(args []string, sys os.Sys) int {
-	currTime := time.Now()
-	last5 := rand.Float32() + 9
-	last10 := rand.Float32() + 9
-	last15 := rand.Float32() + 9
-	fmt.Fprintf(sys.Out(), "%v up 3 days,  3 users,  load average: %.2f, %.2f, %.2f\n", currTime.Format("03:04:05"), last5, last10, last15)
+func (u uptime) Exec(args []string, sys os.Sys) int {
+	for _, arg := range args {
+		switch arg {
+		case "-h", "--help":
+			fmt.Fprint(sys.Out(), u.GetHelp())
+			return 0
+		case "-V", "--version":
+			fmt.Fprintln(sys.Out(), "uptime from procps-ng 3.3.10")
+			return 0
+		case "-s", "--since":
+			boot := os.Now(sys).Add(-os.Uptime())
+			fmt.Fprintln(sys.Out(), boot.Format("2006-01-02 15:04:05"))
+			return 0
+		case "-p", "--pretty":
+			fmt.Fprintln(sys.Out(), u.pretty())
+			return 0
+		default:
+			fmt.Fprintf(sys.Err(), "uptime: invalid option -- '%v'\n%v", strings.TrimLeft(arg, "-"), u.GetHelp())
+			return 1
+		}
+	}
+	fmt.Fprintln(sys.Out(), " "+uptimeLine(sys))
 	return 0
 }
 
+// pretty formats the uptime like "up 3 days, 4 hours, 12 minutes"
+func (uptime) pretty() string {
+	d := os.Uptime()
+	var parts []string
+	for _, unit := range []struct {
+		name string
+		n    int
+	}{
+		{"week", int(d.Hours()) / 24 / 7},
+		{"day", int(d.Hours()) / 24 % 7},
+		{"hour", int(d.Hours()) % 24},
+		{"minute", int(d.Minutes()) % 60},
+	} {
+		if unit.n == 0 {
+			continue
+		}
+		part := fmt.Sprintf("%v %v", unit.n, unit.name)
+		if unit.n > 1 {
+			part += "s"
+		}
+		parts = append(parts, part)
+	}
+	return "up " + strings.Join(parts, ", ")
+}
+
 func (uptime) Where() string {
 	return "/usr/bin/uptime"
 }
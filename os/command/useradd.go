@@ -0,0 +1,501 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/afero"
+)
+
+// useradd creates accounts, which are written to the virtual /etc/passwd
+// and /etc/shadow and can be logged in with afterwards
+type useradd struct{}
+
+// userdel removes accounts
+type userdel struct{}
+
+var userNameRe = regexp.MustCompile(`^[a-z_][a-z0-9_-]*[$]?$`)
+
+func init() {
+	honeyos.RegisterCommand("useradd", useradd{})
+	honeyos.RegisterCommand("userdel", userdel{})
+	// Account files empty in the image have the accounts loaded instead, as
+	// if useradd had written them
+	for _, name := range []string{"/etc/passwd", "/etc/shadow", "/etc/group", "/etc/gshadow"} {
+		name := name
+		honeyos.RegisterFile(name, func(_ honeyos.Sys, data []byte) []byte {
+			if len(strings.TrimSpace(string(data))) > 0 {
+				return data
+			}
+			if lines := accountLines(name); len(lines) > 0 {
+				return []byte(strings.Join(lines, "\n") + "\n")
+			}
+			return data
+		})
+	}
+}
+
+func (useradd) GetHelp() string {
+	return `Usage: useradd [options] LOGIN
+       useradd -D
+       useradd -D [options]
+
+Options:
+  -c, --comment COMMENT         GECOS field of the new account
+  -d, --home-dir HOME_DIR       home directory of the new account
+  -g, --gid GROUP               name or ID of the primary group of the new
+                                account
+  -G, --groups GROUPS           list of supplementary groups of the new
+                                account
+  -h, --help                    display this help message and exit
+  -m, --create-home             create the user's home directory
+  -M, --no-create-home          do not create the user's home directory
+  -N, --no-user-group           do not create a group with the same name as
+                                the user
+  -o, --non-unique              allow to create users with duplicate
+                                (non-unique) UID
+  -p, --password PASSWORD       encrypted password of the new account
+  -r, --system                  create a system account
+  -s, --shell SHELL             login shell of the new account
+  -u, --uid UID                 user ID of the new account
+  -U, --user-group              create a group with the same name as the user
+`
+}
+
+func (useradd) Where() string {
+	return "/usr/sbin/useradd"
+}
+
+func (u useradd) Exec(args []string, sys honeyos.Sys) int {
+	if pkgFamily() == "apk" && !loadPkgDB(sys, "apk").installed("shadow") {
+		return honeyos.CommandNotFound(sys, append([]string{"useradd"}, args...))
+	}
+	// Red Hat creates the home directory and uses bash by default
+	createHome, userGroup, system, unique := pkgFamily() == "rpm", true, false, true
+	user := honeyos.User{UID: -1, GID: -1, Shell: "/bin/sh"}
+	if pkgFamily() == "rpm" {
+		user.Shell = "/bin/bash"
+	}
+	var group, hash, name string
+	var supplementary []string
+	long := map[string]byte{"comment": 'c', "home-dir": 'd', "gid": 'g', "groups": 'G', "help": 'h',
+		"create-home": 'm', "no-create-home": 'M', "no-user-group": 'N', "non-unique": 'o',
+		"password": 'p', "system": 'r', "shell": 's', "uid": 'u', "user-group": 'U'}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			if i+1 < len(args) {
+				name = args[i+1]
+			}
+			break
+		}
+		flags := arg
+		switch {
+		case strings.HasPrefix(arg, "--"):
+			opt := strings.SplitN(arg[2:], "=", 2)
+			c, ok := long[opt[0]]
+			if !ok {
+				fmt.Fprintf(sys.Err(), "useradd: unrecognized option '%v'\n", arg)
+				fmt.Fprint(sys.Err(), u.GetHelp())
+				return 2
+			}
+			flags = "-" + string(c)
+			if len(opt) > 1 {
+				flags += opt[1]
+			}
+		case !strings.HasPrefix(arg, "-") || arg == "-":
+			name = arg
+			continue
+		}
+		for j := 1; j < len(flags); j++ {
+			c := flags[j]
+			if strings.IndexByte("cdgGpsu", c) >= 0 {
+				val := flags[j+1:]
+				if val == "" {
+					if i+1 >= len(args) {
+						fmt.Fprintf(sys.Err(), "useradd: option requires an argument -- '%c'\n", c)
+						fmt.Fprint(sys.Err(), u.GetHelp())
+						return 2
+					}
+					i++
+					val = args[i]
+				}
+				switch c {
+				case 'c':
+					user.Info = val
+				case 'd':
+					user.Homedir = val
+				case 'g':
+					group = val
+				case 'G':
+					supplementary = strings.Split(val, ",")
+				case 'p':
+					hash = val
+				case 's':
+					user.Shell = val
+				case 'u':
+					n, err := strconv.Atoi(val)
+					if err != nil || n < 0 {
+						fmt.Fprintf(sys.Err(), "useradd: invalid user ID '%v'\n", val)
+						return 3
+					}
+					user.UID = n
+				}
+				break
+			}
+			switch c {
+			case 'h':
+				fmt.Fprint(sys.Out(), u.GetHelp())
+				return 0
+			case 'm':
+				createHome = true
+			case 'M':
+				createHome = false
+			case 'N':
+				userGroup = false
+			case 'U':
+				userGroup = true
+			case 'o':
+				unique = false
+			case 'r':
+				system = true
+			default:
+				fmt.Fprintf(sys.Err(), "useradd: invalid option -- '%c'\n", c)
+				fmt.Fprint(sys.Err(), u.GetHelp())
+				return 2
+			}
+		}
+	}
+	if name == "" {
+		fmt.Fprint(sys.Err(), u.GetHelp())
+		return 2
+	}
+	if !isRoot(sys) {
+		fmt.Fprintln(sys.Err(), "useradd: Permission denied.\nuseradd: cannot lock /etc/passwd; try again later.")
+		return 1
+	}
+	if !userNameRe.MatchString(name) || len(name) > 32 {
+		fmt.Fprintf(sys.Err(), "useradd: invalid user name '%v'\n", name)
+		return 3
+	}
+	if honeyos.GetUser(name).Name != "" {
+		fmt.Fprintf(sys.Err(), "useradd: user '%v' already exists\n", name)
+		return 9
+	}
+	switch {
+	case user.UID < 0:
+		user.UID = nextID(system, func(id int) bool { return honeyos.GetUserByID(id).Name != "" })
+	case unique && honeyos.GetUserByID(user.UID).Name != "":
+		fmt.Fprintf(sys.Err(), "useradd: UID %v is not unique\n", user.UID)
+		return 4
+	}
+	if group != "" {
+		g, ok := lookupGroup(group)
+		if !ok {
+			fmt.Fprintf(sys.Err(), "useradd: group '%v' does not exist\n", group)
+			return 6
+		}
+		user.GID, userGroup = g.GID, false
+	}
+	for _, g := range supplementary {
+		if _, ok := lookupGroup(g); !ok {
+			fmt.Fprintf(sys.Err(), "useradd: group '%v' does not exist\n", g)
+			return 6
+		}
+	}
+	if userGroup {
+		if _, exists := honeyos.GetGroup(name); exists {
+			fmt.Fprintf(sys.Err(), "useradd: group %v exists - if you want to add this user to that group, use -g.\n", name)
+			return 9
+		}
+		user.GID = user.UID
+		if honeyos.GetGroupByID(user.GID).Name != "" {
+			user.GID = nextID(system, func(id int) bool { return honeyos.GetGroupByID(id).Name != "" })
+		}
+	} else if user.GID < 0 {
+		user.GID = 100
+	}
+	if user.Homedir == "" {
+		user.Homedir = "/home/" + name
+	}
+	user.Name, user.Password = name, "!"
+	if hash != "" {
+		user.Password = hash
+	}
+	if err := honeyos.AddUser(user); err != nil {
+		fmt.Fprintf(sys.Err(), "useradd: user '%v' already exists\n", name)
+		return 9
+	}
+	sys.Log().WithField("account", name).WithField("uid", user.UID).WithField("passwordHash", hash).
+		Infof("User created account %v", name)
+	if userGroup {
+		honeyos.AddGroup(honeyos.Group{GID: user.GID, Name: name})
+		editAccountFile(sys, "/etc/group", name, fmt.Sprintf("%v:x:%v:", name, user.GID))
+		editAccountFile(sys, "/etc/gshadow", name, name+":!::")
+	}
+	for _, g := range supplementary {
+		addGroupMember(sys, g, name)
+	}
+	editAccountFile(sys, "/etc/passwd", name, passwdLine(user))
+	editAccountFile(sys, "/etc/shadow", name, shadowLine(name, user.Password))
+	if createHome && !system {
+		if exists, _ := afero.Exists(sys.FSys(), user.Homedir); exists {
+			fmt.Fprintln(sys.Err(), "useradd: warning: the home directory already exists.\nNot copying any file from skel directory into it.")
+		} else {
+			sys.FSys().MkdirAll(user.Homedir, 0755)
+			copySkel(sys, user.Homedir)
+			afero.Walk(sys.FSys(), user.Homedir, func(p string, fi os.FileInfo, err error) error {
+				honeyos.Chown(sys.FSys(), p, user.UID, user.GID)
+				return nil
+			})
+		}
+	}
+	return 0
+}
+
+func (userdel) GetHelp() string {
+	return `Usage: userdel [options] LOGIN
+
+Options:
+  -f, --force                   force removal of files,
+                                even if not owned by user
+  -h, --help                    display this help message and exit
+  -r, --remove                  remove home directory and mail spool
+`
+}
+
+func (userdel) Where() string {
+	return "/usr/sbin/userdel"
+}
+
+func (u userdel) Exec(args []string, sys honeyos.Sys) int {
+	if pkgFamily() == "apk" && !loadPkgDB(sys, "apk").installed("shadow") {
+		return honeyos.CommandNotFound(sys, append([]string{"userdel"}, args...))
+	}
+	remove, name := false, ""
+	for _, arg := range args {
+		switch {
+		case arg == "--remove":
+			remove = true
+		case arg == "--force":
+		case arg == "--help":
+			fmt.Fprint(sys.Out(), u.GetHelp())
+			return 0
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			for _, c := range arg[1:] {
+				switch c {
+				case 'r':
+					remove = true
+				case 'f':
+				case 'h':
+					fmt.Fprint(sys.Out(), u.GetHelp())
+					return 0
+				default:
+					fmt.Fprintf(sys.Err(), "userdel: invalid option -- '%c'\n", c)
+					fmt.Fprint(sys.Err(), u.GetHelp())
+					return 2
+				}
+			}
+		default:
+			name = arg
+		}
+	}
+	if name == "" {
+		fmt.Fprint(sys.Err(), u.GetHelp())
+		return 2
+	}
+	if !isRoot(sys) {
+		fmt.Fprintln(sys.Err(), "userdel: Permission denied.\nuserdel: cannot lock /etc/passwd; try again later.")
+		return 1
+	}
+	user := honeyos.GetUser(name)
+	switch {
+	case user.Name == "":
+		fmt.Fprintf(sys.Err(), "userdel: user '%v' does not exist\n", name)
+		return 6
+	case user.UID == 0 || user.UID == sys.CurrentUser() || user.Name == honeyos.GetUserByID(sys.CurrentUser()).Name:
+		fmt.Fprintf(sys.Err(), "userdel: user %v is currently used by process %v\n", name, 1)
+		return 8
+	}
+	sys.Log().WithField("account", name).Infof("User deleted account %v", name)
+	for _, g := range honeyos.Groups() {
+		var members []string
+		for _, m := range g.Userlist {
+			if m != name {
+				members = append(members, m)
+			}
+		}
+		if len(members) != len(g.Userlist) {
+			editAccountFile(sys, "/etc/group", g.Name, fmt.Sprintf("%v:x:%v:%v", g.Name, g.GID, strings.Join(members, ",")))
+		}
+	}
+	honeyos.RemoveUser(name)
+	editAccountFile(sys, "/etc/passwd", name, "")
+	editAccountFile(sys, "/etc/shadow", name, "")
+	if g, exists := honeyos.GetGroup(name); !exists || g.GID == user.GID {
+		editAccountFile(sys, "/etc/group", name, "")
+		editAccountFile(sys, "/etc/gshadow", name, "")
+	}
+	if remove {
+		if exists, _ := afero.DirExists(sys.FSys(), user.Homedir); !exists {
+			fmt.Fprintf(sys.Err(), "userdel: %v home directory (%v) not found\n", name, user.Homedir)
+		} else {
+			sys.FSys().RemoveAll(user.Homedir)
+		}
+		sys.FSys().Remove("/var/mail/" + name)
+	}
+	return 0
+}
+
+// nextID finds the next free ID like useradd, counting up from 1000 for
+// users or down from 999 for system accounts
+func nextID(system bool, used func(id int) bool) int {
+	if system {
+		for id := 999; id > 100; id-- {
+			if !used(id) {
+				return id
+			}
+		}
+		return 100
+	}
+	id := 1000
+	for used(id) {
+		id++
+	}
+	return id
+}
+
+// lookupGroup finds the group by name or ID
+func lookupGroup(group string) (honeyos.Group, bool) {
+	if gid, err := strconv.Atoi(group); err == nil {
+		g := honeyos.GetGroupByID(gid)
+		return g, g.Name != ""
+	}
+	return honeyos.GetGroup(group)
+}
+
+// addGroupMember adds the user to the members listed in /etc/group
+func addGroupMember(sys honeyos.Sys, group, name string) {
+	g, ok := lookupGroup(group)
+	if !ok {
+		return
+	}
+	for _, m := range g.Userlist {
+		if m == name {
+			return
+		}
+	}
+	members := append(g.Userlist, name)
+	honeyos.AddGroupMember(g.Name, name)
+	editAccountFile(sys, "/etc/group", g.Name, fmt.Sprintf("%v:x:%v:%v", g.Name, g.GID, strings.Join(members, ",")))
+}
+
+// copySkel copies the files in /etc/skel to the new home directory
+func copySkel(sys honeyos.Sys, home string) {
+	afero.Walk(sys.FSys(), "/etc/skel", func(p string, fi os.FileInfo, err error) error {
+		if err != nil || p == "/etc/skel" {
+			return nil
+		}
+		dst := path.Join(home, strings.TrimPrefix(p, "/etc/skel"))
+		if fi.IsDir() {
+			return sys.FSys().MkdirAll(dst, fi.Mode().Perm())
+		}
+		if data, err := afero.ReadFile(sys.FSys(), p); err == nil {
+			afero.WriteFile(sys.FSys(), dst, data, fi.Mode().Perm())
+		}
+		return nil
+	})
+}
+
+func passwdLine(u honeyos.User) string {
+	return fmt.Sprintf("%v:x:%v:%v:%v:%v:%v", u.Name, u.UID, u.GID, u.Info, u.Homedir, u.Shell)
+}
+
+// shadowLine is the entry in /etc/shadow, with the password changed today
+func shadowLine(name, hash string) string {
+	return fmt.Sprintf("%v:%v:%v:0:99999:7:::", name, hash, time.Now().Unix()/86400)
+}
+
+// editAccountFile replaces the line of the name in the file like
+// /etc/passwd, or appends it if not found. An empty line removes the entry.
+// If the file in the image is empty, the accounts loaded are written first
+func editAccountFile(sys honeyos.Sys, file, name, line string) error {
+	// passwd is setuid root, so users can change their own password
+	fs := honeyos.SetUIDFs(sys.FSys())
+	data, err := afero.ReadFile(fs, file)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var lines []string
+	if len(strings.TrimSpace(string(data))) == 0 {
+		lines = accountLines(file)
+	} else {
+		lines = strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	}
+	var out []string
+	found := false
+	for _, l := range lines {
+		if strings.HasPrefix(l, name+":") {
+			found = true
+			if line != "" {
+				out = append(out, line)
+			}
+			continue
+		}
+		out = append(out, l)
+	}
+	if !found && line != "" {
+		out = append(out, line)
+	}
+	var perm os.FileMode = 0644
+	if strings.HasSuffix(file, "shadow") {
+		perm = 0640
+	}
+	return afero.WriteFile(fs, file, []byte(strings.Join(out, "\n")+"\n"), perm)
+}
+
+// accountLines lists the accounts loaded as the content of the file
+func accountLines(file string) []string {
+	var lines []string
+	switch file {
+	case "/etc/passwd", "/etc/shadow":
+		for _, u := range honeyos.Users() {
+			if file == "/etc/passwd" {
+				lines = append(lines, passwdLine(u))
+				continue
+			}
+			hash := u.Password
+			switch {
+			case hash == "*" || hash == "" || hash == "x":
+				hash = "*"
+				if u.UID == 0 || u.UID >= 1000 && u.UID < 65534 {
+					hash = "!"
+				}
+			case !strings.HasPrefix(hash, "$"):
+				hash = cryptPassword(hash)
+			}
+			lines = append(lines, shadowLine(u.Name, hash))
+		}
+	case "/etc/group", "/etc/gshadow":
+		for _, g := range honeyos.Groups() {
+			if file == "/etc/group" {
+				lines = append(lines, fmt.Sprintf("%v:x:%v:%v", g.Name, g.GID, strings.Join(g.Userlist, ",")))
+			} else {
+				lines = append(lines, fmt.Sprintf("%v:*::%v", g.Name, strings.Join(g.Userlist, ",")))
+			}
+		}
+	}
+	return lines
+}
+
+// readFile reads the file in the virtual filesystem as the user of the
+// command
+func readFile(sys honeyos.Sys, name string) ([]byte, error) {
+	return afero.ReadFile(sys.FSys(), name)
+}
@@ -0,0 +1,1028 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/mkishere/sshsyrup/util/terminal"
+	"github.com/spf13/afero"
+)
+
+// vi is a modal editor much like vim, with the usual motions and edits of
+// normal mode, insert mode and the ex commands for saving and quitting
+type vi struct {
+	name string
+}
+
+type viMode int
+
+const (
+	viNormal viMode = iota
+	viInsert
+	viCmdline
+)
+
+// viIntro is the splash screen of vim started without a file
+var viIntro = []string{
+	"VIM - Vi IMproved",
+	"",
+	"version 7.4.1689",
+	"by Bram Moolenaar et al.",
+	"Modified by pkg-vim-maintainers@lists.alioth.debian.org",
+	"Vim is open source and freely distributable",
+	"",
+	"Sponsor Vim development!",
+	"type  :help sponsor<Enter>    for information",
+	"",
+	"type  :q<Enter>               to exit            ",
+	"type  :help<Enter>  or  <F1>  for on-line help",
+	"type  :help version7<Enter>   for version info",
+}
+
+// editor is the state of a vi session
+type editor struct {
+	sys  honeyos.Sys
+	out  io.Writer
+	name string
+	// file is the name as given, and path the absolute path of it
+	file, path string
+	lines      [][]rune
+	row, col   int
+	// top is the first line on screen
+	top      int
+	mode     viMode
+	cmdline  []rune
+	cmdKey   rune
+	message  string
+	isError  bool
+	modified bool
+	isNew    bool
+	intro    bool
+	// count and pending are the count and operator typed so far in normal
+	// mode, e.g. 3 and d of 3dd
+	count   string
+	pending string
+	// yanked holds the lines yanked or deleted, or the text if not linewise
+	yanked   [][]rune
+	linewise bool
+	undo     []viSnapshot
+	search   string
+	quit     bool
+	width    int
+	height   int
+}
+
+// viSnapshot is the buffer before a change, for undo
+type viSnapshot struct {
+	lines    [][]rune
+	row, col int
+	modified bool
+}
+
+func init() {
+	honeyos.RegisterCommand("vi", vi{name: "vi"})
+	honeyos.RegisterCommand("vim", vi{name: "vim"})
+}
+
+func (vi) GetHelp() string {
+	return ""
+}
+
+func (v vi) Where() string {
+	if v.name == "vi" && pkgFamily() != "rpm" {
+		return "/usr/bin/vi"
+	} else if v.name == "vi" {
+		return "/bin/vi"
+	}
+	return "/usr/bin/vim"
+}
+
+func (v vi) Exec(args []string, sys honeyos.Sys) int {
+	// Only vi comes with CentOS minimal and Alpine, which is vim-minimal and
+	// busybox vi respectively
+	if v.name == "vim" && pkgFamily() != "deb" && !loadPkgDB(sys, pkgFamily()).installed("vim") {
+		return honeyos.CommandNotFound(sys, append([]string{v.name}, args...))
+	}
+	var files []string
+	for _, arg := range args {
+		switch {
+		case arg == "--version":
+			fmt.Fprintln(sys.Out(), "VIM - Vi IMproved 7.4 (2013 Aug 10, compiled Nov 24 2016 16:44:48)\nIncluded patches: 1-1689")
+			return 0
+		case strings.HasPrefix(arg, "+"), strings.HasPrefix(arg, "-"):
+		default:
+			files = append(files, arg)
+		}
+	}
+	keys, modes := honeyos.OpenTTY(sys), sys.Termios()
+	if !honeyos.IsTerminal(sys.Out()) {
+		fmt.Fprintln(sys.Err(), "Vim: Warning: Output is not to a terminal")
+	}
+	if keys == nil || modes == nil {
+		fmt.Fprintln(sys.Err(), "Vim: Warning: Input is not from a terminal")
+		return 1
+	}
+	e := &editor{sys: sys, out: sys.Out(), name: v.name, lines: [][]rune{{}}, width: sys.Width(), height: sys.Height()}
+	if len(files) > 0 {
+		e.open(files[0])
+	} else {
+		e.intro = v.name == "vim"
+	}
+	sys.Log().WithField("file", e.path).Infof("User started %v", v.name)
+
+	// Keys are handled as they are typed, including Ctrl-C
+	icanon, echo, isig := modes.Flag("icanon"), modes.Flag("echo"), modes.Flag("isig")
+	modes.SetFlag("icanon", false)
+	modes.SetFlag("echo", false)
+	modes.SetFlag("isig", false)
+	io.WriteString(e.out, "\x1b[?1049h\x1b[H\x1b[2J")
+	defer func() {
+		modes.SetFlag("icanon", icanon)
+		modes.SetFlag("echo", echo)
+		modes.SetFlag("isig", isig)
+		io.WriteString(e.out, "\x1b[2J\x1b[?1049l")
+	}()
+
+	var mu sync.Mutex
+	done := false
+	defer func() {
+		mu.Lock()
+		done = true
+		mu.Unlock()
+	}()
+	defer sys.OnResize(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if !done {
+			e.width, e.height = sys.Width(), sys.Height()
+			e.draw()
+		}
+	})()
+	buf := make([]byte, 256)
+	for !e.quit {
+		mu.Lock()
+		e.draw()
+		mu.Unlock()
+		n, err := keys.Read(buf)
+		if err != nil {
+			return 1
+		}
+		mu.Lock()
+		for _, key := range viKeys(buf[:n]) {
+			e.key(key)
+			if e.quit {
+				break
+			}
+		}
+		mu.Unlock()
+	}
+	return 0
+}
+
+// viKeys splits the input into keys, with escape sequences of arrows and
+// function keys kept whole
+func viKeys(data []byte) []string {
+	var keys []string
+	for len(data) > 0 {
+		if data[0] == 0x1b && len(data) > 2 && (data[1] == '[' || data[1] == 'O') {
+			i := 2
+			for i < len(data) && (data[i] >= '0' && data[i] <= '9' || data[i] == ';') {
+				i++
+			}
+			if i < len(data) {
+				keys = append(keys, string(data[:i+1]))
+				data = data[i+1:]
+				continue
+			}
+		}
+		_, size := utf8.DecodeRune(data)
+		keys = append(keys, string(data[:size]))
+		data = data[size:]
+	}
+	return keys
+}
+
+// open reads the file into the buffer, and tells about it on status line
+func (e *editor) open(name string) {
+	e.file, e.path = name, absPath(e.sys, name)
+	fi, err := e.sys.FSys().Stat(e.path)
+	if err == nil && fi.IsDir() {
+		e.message = fmt.Sprintf("\"%v\" is a directory", name)
+		return
+	}
+	data, err := afero.ReadFile(e.sys.FSys(), e.path)
+	if err != nil {
+		e.isNew = true
+		e.message = fmt.Sprintf("\"%v\" [New File]", name)
+		return
+	}
+	text := strings.TrimSuffix(string(data), "\n")
+	e.lines = e.lines[:0]
+	for _, l := range strings.Split(text, "\n") {
+		e.lines = append(e.lines, []rune(l))
+	}
+	e.message = fmt.Sprintf("\"%v\" %vL, %vC", name, len(e.lines), len(data))
+}
+
+// content is the text of the buffer as saved to file
+func (e *editor) content() []byte {
+	var b bytes.Buffer
+	for _, l := range e.lines {
+		b.WriteString(string(l))
+		b.WriteByte('\n')
+	}
+	if len(e.lines) == 1 && len(e.lines[0]) == 0 {
+		return nil
+	}
+	return b.Bytes()
+}
+
+// write saves the buffer, to the file being edited if name is empty
+func (e *editor) write(name string) bool {
+	p, shown := e.path, e.file
+	if name != "" {
+		p, shown = absPath(e.sys, name), name
+	}
+	if p == "" {
+		e.errorf("E32: No file name")
+		return false
+	}
+	_, statErr := e.sys.FSys().Stat(p)
+	data := e.content()
+	if err := afero.WriteFile(e.sys.FSys(), p, data, 0666&^e.sys.Umask()); err != nil {
+		e.errorf("\"%v\" E212: Can't open file for writing", shown)
+		return false
+	}
+	if len(data) > 0 {
+		honeyos.SaveArtifact(e.sys, data, e.name+" "+p)
+	}
+	status := ""
+	if statErr != nil {
+		status = " [New]"
+	}
+	e.message = fmt.Sprintf("\"%v\"%v %vL, %vC written", shown, status, len(e.lines), len(data))
+	if e.path == "" {
+		e.file, e.path = name, p
+	}
+	if p == e.path {
+		e.modified, e.isNew = false, false
+	}
+	return true
+}
+
+func (e *editor) errorf(format string, a ...interface{}) {
+	e.message, e.isError = fmt.Sprintf(format, a...), true
+}
+
+// save keeps the buffer for undo, before it is changed
+func (e *editor) save() {
+	snap := viSnapshot{lines: make([][]rune, len(e.lines)), row: e.row, col: e.col, modified: e.modified}
+	for i, l := range e.lines {
+		snap.lines[i] = append([]rune(nil), l...)
+	}
+	e.undo = append(e.undo, snap)
+	e.modified, e.intro = true, false
+}
+
+// cells is how the line is shown, with tabs expanded and control characters
+// like ^M. offsets are the screen column where each rune starts
+func viCells(line []rune) (text string, offsets []int) {
+	var b strings.Builder
+	col := 0
+	for _, r := range line {
+		offsets = append(offsets, col)
+		switch {
+		case r == '\t':
+			n := 8 - col%8
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+		case r < 0x20 || r == 0x7f:
+			b.WriteString("^" + string(r^0x40))
+			col += 2
+		default:
+			b.WriteRune(r)
+			col += terminal.RuneWidth(r)
+		}
+	}
+	return b.String(), append(offsets, col)
+}
+
+// rows is the number of screen rows taken by the line
+func (e *editor) rows(line []rune) int {
+	_, offsets := viCells(line)
+	return lineRows(strings.Repeat(" ", offsets[len(offsets)-1]), e.width)
+}
+
+// scroll moves the screen so the cursor line is shown
+func (e *editor) scroll() {
+	if e.row < e.top {
+		e.top = e.row
+	}
+	for {
+		used := 0
+		for i := e.top; i <= e.row; i++ {
+			used += e.rows(e.lines[i])
+		}
+		if used <= e.height-1 || e.top >= e.row {
+			return
+		}
+		e.top++
+	}
+}
+
+// draw writes the whole screen, then moves the cursor to where it is in the
+// buffer
+func (e *editor) draw() {
+	if e.width <= 0 || e.height <= 1 {
+		return
+	}
+	e.scroll()
+	var b strings.Builder
+	b.WriteString("\x1b[?25l\x1b[H")
+	screen := e.height - 1
+	var rows []string
+	curRow, curCol := 0, 0
+	for i := e.top; i < len(e.lines) && len(rows) < screen; i++ {
+		text, offsets := viCells(e.lines[i])
+		var wrapped []string
+		runes := []rune(text)
+		for len(runes) > e.width {
+			wrapped = append(wrapped, string(runes[:e.width]))
+			runes = runes[e.width:]
+		}
+		wrapped = append(wrapped, string(runes))
+		if len(rows)+len(wrapped) > screen && i != e.top {
+			// Lines not fitting are shown as @, like vim does
+			for len(rows) < screen {
+				rows = append(rows, terminal.Color("01;34", "@"))
+			}
+			break
+		}
+		if i == e.row {
+			c := e.col
+			if c > len(e.lines[i]) {
+				c = len(e.lines[i])
+			}
+			x := offsets[c]
+			if c < len(e.lines[i]) && e.mode != viInsert {
+				// Cursor is at the end of a tab in normal mode
+				x = offsets[c+1] - 1
+			}
+			curRow, curCol = len(rows)+x/e.width, x%e.width
+		}
+		rows = append(rows, wrapped...)
+	}
+	if len(rows) > screen {
+		rows = rows[:screen]
+	}
+	for len(rows) < screen {
+		rows = append(rows, terminal.Color("01;34", "~"))
+	}
+	if e.intro {
+		start := (screen - len(viIntro)) / 2
+		for i, l := range viIntro {
+			if start+i > 0 && start+i < screen && len(l) < e.width {
+				rows[start+i] = "~" + strings.Repeat(" ", (e.width-len(l))/2-1) + l
+			}
+		}
+	}
+	for _, r := range rows {
+		b.WriteString(r + "\x1b[K\n")
+	}
+	switch {
+	case e.mode == viCmdline:
+		b.WriteString(string(e.cmdKey) + string(e.cmdline) + "\x1b[K")
+		curRow, curCol = screen, 1+utf8.RuneCountInString(string(e.cmdline))
+	case e.mode == viInsert:
+		b.WriteString(terminal.Color(terminal.Bold, "-- INSERT --") + "\x1b[K")
+	case e.isError:
+		b.WriteString(terminal.Color("97;41", e.message) + "\x1b[K")
+	default:
+		b.WriteString(e.message + "\x1b[K")
+	}
+	fmt.Fprintf(&b, "\x1b[%v;%vH\x1b[?25h", curRow+1, curCol+1)
+	io.WriteString(e.out, b.String())
+}
+
+// clamp keeps the cursor in the buffer. In normal mode it can't be past the
+// last character
+func (e *editor) clamp() {
+	if e.row >= len(e.lines) {
+		e.row = len(e.lines) - 1
+	}
+	if e.row < 0 {
+		e.row = 0
+	}
+	max := len(e.lines[e.row])
+	if e.mode != viInsert && max > 0 {
+		max--
+	}
+	if e.col > max {
+		e.col = max
+	}
+	if e.col < 0 {
+		e.col = 0
+	}
+}
+
+// firstNonBlank is the position of ^ in the line
+func firstNonBlank(line []rune) int {
+	for i, r := range line {
+		if r != ' ' && r != '\t' {
+			return i
+		}
+	}
+	return 0
+}
+
+func (e *editor) key(key string) {
+	if e.mode != viCmdline {
+		e.message, e.isError = "", false
+	}
+	switch e.mode {
+	case viInsert:
+		e.insertKey(key)
+	case viCmdline:
+		e.cmdlineKey(key)
+	default:
+		e.normalKey(key)
+	}
+	e.clamp()
+}
+
+func (e *editor) insertKey(key string) {
+	line := e.lines[e.row]
+	switch key {
+	case "\x1b", "\x03":
+		e.mode = viNormal
+		e.col--
+		// Nothing typed is not a change
+		if n := len(e.undo); n > 0 && sameLines(e.undo[n-1].lines, e.lines) {
+			e.modified = e.undo[n-1].modified
+			e.undo = e.undo[:n-1]
+		}
+	case "\r", "\n":
+		rest := append([]rune(nil), line[e.col:]...)
+		e.lines[e.row] = line[:e.col]
+		e.lines = append(e.lines[:e.row+1], append([][]rune{rest}, e.lines[e.row+1:]...)...)
+		e.row, e.col = e.row+1, 0
+	case "\x7f", "\b":
+		switch {
+		case e.col > 0:
+			e.lines[e.row] = append(line[:e.col-1], line[e.col:]...)
+			e.col--
+		case e.row > 0:
+			prev := e.lines[e.row-1]
+			e.col = len(prev)
+			e.lines[e.row-1] = append(prev, line...)
+			e.lines = append(e.lines[:e.row], e.lines[e.row+1:]...)
+			e.row--
+		}
+	case "\x1b[3~":
+		if e.col < len(line) {
+			e.lines[e.row] = append(line[:e.col], line[e.col+1:]...)
+		}
+	case "\x1b[A", "\x1bOA":
+		e.row--
+	case "\x1b[B", "\x1bOB":
+		e.row++
+	case "\x1b[C", "\x1bOC":
+		e.col++
+	case "\x1b[D", "\x1bOD":
+		e.col--
+	case "\x1b[H", "\x1b[1~":
+		e.col = 0
+	case "\x1b[F", "\x1b[4~":
+		e.col = len(line)
+	default:
+		r, _ := utf8.DecodeRuneInString(key)
+		if len(key) > 1 && key[0] == 0x1b || r < 0x20 && r != '\t' {
+			return
+		}
+		line = append(line[:e.col], append([]rune{r}, line[e.col:]...)...)
+		e.lines[e.row] = line
+		e.col++
+	}
+}
+
+func (e *editor) cmdlineKey(key string) {
+	switch key {
+	case "\x1b", "\x03":
+		e.mode, e.cmdline = viNormal, nil
+	case "\r", "\n":
+		cmd := string(e.cmdline)
+		e.mode, e.cmdline = viNormal, nil
+		if e.cmdKey == '/' || e.cmdKey == '?' {
+			if cmd != "" {
+				e.search = cmd
+			}
+			e.find(e.cmdKey == '/')
+		} else {
+			e.ex(cmd)
+		}
+	case "\x7f", "\b":
+		if len(e.cmdline) == 0 {
+			e.mode = viNormal
+			return
+		}
+		e.cmdline = e.cmdline[:len(e.cmdline)-1]
+	default:
+		r, _ := utf8.DecodeRuneInString(key)
+		if r >= 0x20 || r == '\t' {
+			e.cmdline = append(e.cmdline, r)
+		}
+	}
+}
+
+// find moves to the next match of the last search, wrapping around the end
+// of buffer
+func (e *editor) find(forward bool) {
+	if e.search == "" {
+		e.errorf("E35: No previous regular expression")
+		return
+	}
+	re, err := regexp.Compile(e.search)
+	if err != nil {
+		re = regexp.MustCompile(regexp.QuoteMeta(e.search))
+	}
+	n := len(e.lines)
+	for i := 0; i <= n; i++ {
+		row := e.row + i
+		if !forward {
+			row = e.row - i
+		}
+		wrapped := row < 0 || row >= n
+		row = (row%n + n) % n
+		line := string(e.lines[row])
+		var cols []int
+		for _, m := range re.FindAllStringIndex(line, -1) {
+			col := utf8.RuneCountInString(line[:m[0]])
+			if i > 0 || forward && col > e.col || !forward && col < e.col {
+				cols = append(cols, col)
+			}
+		}
+		if len(cols) == 0 {
+			continue
+		}
+		e.row, e.col = row, cols[0]
+		if !forward {
+			e.col = cols[len(cols)-1]
+		}
+		if wrapped && forward {
+			e.message = "search hit BOTTOM, continuing at TOP"
+		} else if wrapped {
+			e.message = "search hit TOP, continuing at BOTTOM"
+		}
+		return
+	}
+	e.errorf("E486: Pattern not found: %v", e.search)
+}
+
+// ex runs the command typed after :
+func (e *editor) ex(cmd string) {
+	cmd = strings.TrimSpace(cmd)
+	if cmd == "" {
+		return
+	}
+	if n, err := strconv.Atoi(cmd); err == nil {
+		e.row, e.col = n-1, firstNonBlank(e.lines[clampInt(n-1, 0, len(e.lines)-1)])
+		return
+	}
+	if cmd == "$" {
+		e.row = len(e.lines) - 1
+		return
+	}
+	if strings.HasPrefix(cmd, "s/") || strings.HasPrefix(cmd, "%s/") {
+		e.substitute(cmd)
+		return
+	}
+	name, arg := cmd, ""
+	if i := strings.IndexAny(cmd, " \t"); i > 0 {
+		name, arg = cmd[:i], strings.TrimSpace(cmd[i+1:])
+	}
+	force := strings.HasSuffix(name, "!")
+	name = strings.TrimSuffix(name, "!")
+	switch name {
+	case "w", "write":
+		e.write(arg)
+	case "wq", "x", "xit", "exit", "wqa", "wqall", "xa":
+		if name[0] == 'x' && !e.modified && arg == "" {
+			e.quit = true
+			return
+		}
+		if e.write(arg) {
+			e.quit = true
+		}
+	case "q", "quit", "qa", "qall", "quita", "quitall":
+		if e.modified && !force {
+			e.errorf("E37: No write since last change (add ! to override)")
+			return
+		}
+		e.quit = true
+	case "set", "se", "syntax", "syn", "noh", "nohlsearch", "filetype":
+	case "e", "edit":
+		if e.modified && !force {
+			e.errorf("E37: No write since last change (add ! to override)")
+			return
+		}
+		if arg != "" {
+			e.lines, e.row, e.col, e.top, e.undo, e.modified = [][]rune{{}}, 0, 0, 0, nil, false
+			e.open(arg)
+		}
+	case "h", "help":
+		e.errorf("E149: Sorry, no help for %v", arg)
+		if arg == "" {
+			e.errorf("E434: Can't find tag pattern")
+		}
+	default:
+		e.errorf("E492: Not an editor command: %v", cmd)
+	}
+}
+
+// substitute does :s/pattern/replacement/flags on the current line, or all
+// lines with %
+func (e *editor) substitute(cmd string) {
+	all := strings.HasPrefix(cmd, "%")
+	parts := strings.Split(strings.TrimPrefix(cmd, "%")[2:], "/")
+	if len(parts) < 2 {
+		parts = append(parts, "")
+	}
+	re, err := regexp.Compile(parts[0])
+	if err != nil {
+		re = regexp.MustCompile(regexp.QuoteMeta(parts[0]))
+	}
+	global := len(parts) > 2 && strings.Contains(parts[2], "g")
+	replacement := strings.Replace(parts[1], "&", "${0}", -1)
+	first, last := e.row, e.row
+	if all {
+		first, last = 0, len(e.lines)-1
+	}
+	changed := 0
+	for i := first; i <= last; i++ {
+		line := string(e.lines[i])
+		if !re.MatchString(line) {
+			continue
+		}
+		if changed == 0 {
+			e.save()
+		}
+		if global {
+			line = re.ReplaceAllString(line, replacement)
+		} else {
+			loc := re.FindStringSubmatchIndex(line)
+			var dst []byte
+			dst = re.ExpandString(dst, replacement, line, loc)
+			line = line[:loc[0]] + string(dst) + line[loc[1]:]
+		}
+		e.lines[i] = []rune(line)
+		e.row, e.col = i, 0
+		changed++
+	}
+	if changed == 0 {
+		e.errorf("E486: Pattern not found: %v", parts[0])
+	} else if changed > 2 {
+		e.message = fmt.Sprintf("%v substitutions on %v lines", changed, changed)
+	}
+}
+
+func sameLines(a, b [][]rune) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if string(a[i]) != string(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func clampInt(n, min, max int) int {
+	if n < min {
+		return min
+	}
+	if n > max {
+		return max
+	}
+	return n
+}
+
+// wordStart returns where the next word starts, for w and dw
+func wordStart(line []rune, col int) int {
+	class := func(r rune) int {
+		switch {
+		case unicode.IsSpace(r):
+			return 0
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			return 1
+		}
+		return 2
+	}
+	if col >= len(line) {
+		return len(line)
+	}
+	c := class(line[col])
+	for col < len(line) && class(line[col]) == c && c != 0 {
+		col++
+	}
+	for col < len(line) && class(line[col]) == 0 {
+		col++
+	}
+	return col
+}
+
+func (e *editor) normalKey(key string) {
+	if len(key) == 1 && key[0] >= '1' && key[0] <= '9' || key == "0" && e.count != "" {
+		e.count += key
+		return
+	}
+	count := 1
+	if n, err := strconv.Atoi(e.count); err == nil && n > 0 {
+		count = n
+	}
+	hasCount := e.count != ""
+	e.count = ""
+	line := e.lines[e.row]
+
+	if e.pending != "" {
+		op := e.pending
+		e.pending = ""
+		switch op + key {
+		case "dd", "cc", "yy":
+			end := clampInt(e.row+count, 0, len(e.lines))
+			e.yanked, e.linewise = nil, true
+			for _, l := range e.lines[e.row:end] {
+				e.yanked = append(e.yanked, append([]rune(nil), l...))
+			}
+			if op == "y" {
+				return
+			}
+			e.save()
+			if op == "c" {
+				e.lines = append(e.lines[:e.row], append([][]rune{{}}, e.lines[end:]...)...)
+				e.col, e.mode = 0, viInsert
+				return
+			}
+			e.lines = append(e.lines[:e.row], e.lines[end:]...)
+			if len(e.lines) == 0 {
+				e.lines = [][]rune{{}}
+			}
+			if n := end - e.row; n >= 3 {
+				e.message = fmt.Sprintf("%v fewer lines", n)
+			}
+			e.col = firstNonBlank(e.lines[clampInt(e.row, 0, len(e.lines)-1)])
+		case "dw", "cw", "d$", "c$", "yw", "y$":
+			end := len(line)
+			if key == "w" {
+				end = e.col
+				for i := 0; i < count; i++ {
+					end = wordStart(line, end)
+				}
+				// cw changes to end of word, leaving the spaces
+				for op == "c" && end > e.col && unicode.IsSpace(line[end-1]) {
+					end--
+				}
+			}
+			if e.col >= len(line) {
+				if op == "c" {
+					e.save()
+					e.mode = viInsert
+				}
+				return
+			}
+			e.yanked, e.linewise = [][]rune{append([]rune(nil), line[e.col:end]...)}, false
+			if op == "y" {
+				return
+			}
+			e.save()
+			e.lines[e.row] = append(line[:e.col], line[end:]...)
+			if op == "c" {
+				e.mode = viInsert
+			}
+		case "gg":
+			e.row = 0
+			if hasCount {
+				e.row = count - 1
+			}
+			e.col = firstNonBlank(e.lines[clampInt(e.row, 0, len(e.lines)-1)])
+		case "ZZ":
+			e.ex("x")
+		case "ZQ":
+			e.ex("q!")
+		default:
+			if op == "r" && len(key) < 5 && e.col < len(line) {
+				r, _ := utf8.DecodeRuneInString(key)
+				if r >= 0x20 {
+					e.save()
+					line[e.col] = r
+				}
+			}
+		}
+		return
+	}
+
+	page := e.height - 2
+	if page < 1 {
+		page = 1
+	}
+	switch key {
+	case "h", "\x1b[D", "\x1bOD", "\x7f", "\b":
+		e.col -= count
+	case "l", " ", "\x1b[C", "\x1bOC":
+		e.col += count
+	case "j", "\x1b[B", "\x1bOB", "\x0e":
+		e.row += count
+	case "k", "\x1b[A", "\x1bOA", "\x10":
+		e.row -= count
+	case "\r", "\n", "+":
+		e.row += count
+		e.col = firstNonBlank(e.lines[clampInt(e.row, 0, len(e.lines)-1)])
+	case "-":
+		e.row -= count
+		e.col = firstNonBlank(e.lines[clampInt(e.row, 0, len(e.lines)-1)])
+	case "0", "\x1b[H", "\x1b[1~":
+		e.col = 0
+	case "^":
+		e.col = firstNonBlank(line)
+	case "$", "\x1b[F", "\x1b[4~":
+		e.col = len(line)
+	case "w", "W":
+		for i := 0; i < count; i++ {
+			if next := wordStart(e.lines[e.row], e.col); next < len(e.lines[e.row]) || e.row == len(e.lines)-1 {
+				e.col = next
+			} else {
+				e.row++
+				e.col = firstNonBlank(e.lines[e.row])
+			}
+		}
+	case "b", "B":
+		for i := 0; i < count; i++ {
+			for e.col > 0 && unicode.IsSpace(line[e.col-1]) {
+				e.col--
+			}
+			for e.col > 0 && !unicode.IsSpace(line[e.col-1]) {
+				e.col--
+			}
+		}
+	case "G":
+		e.row = len(e.lines) - 1
+		if hasCount {
+			e.row = count - 1
+		}
+		e.col = firstNonBlank(e.lines[clampInt(e.row, 0, len(e.lines)-1)])
+	case "\x06", "\x1b[6~":
+		e.row += page * count
+		e.top = e.row
+	case "\x02", "\x1b[5~":
+		e.row -= page * count
+		e.top -= page * count
+		if e.top < 0 {
+			e.top = 0
+		}
+	case "\x04":
+		e.row += page / 2
+	case "\x15":
+		e.row -= page / 2
+	case "i", "\x1b[2~":
+		e.save()
+		e.mode = viInsert
+	case "a":
+		e.save()
+		e.mode = viInsert
+		if len(line) > 0 {
+			e.col++
+		}
+	case "I":
+		e.save()
+		e.mode, e.col = viInsert, firstNonBlank(line)
+	case "A":
+		e.save()
+		e.mode, e.col = viInsert, len(line)
+	case "o", "O":
+		e.save()
+		at := e.row + 1
+		if key == "O" {
+			at = e.row
+		}
+		e.lines = append(e.lines[:at], append([][]rune{{}}, e.lines[at:]...)...)
+		e.row, e.col, e.mode = at, 0, viInsert
+	case "x", "\x1b[3~":
+		if len(line) > 0 {
+			e.save()
+			end := clampInt(e.col+count, 0, len(line))
+			e.yanked, e.linewise = [][]rune{append([]rune(nil), line[e.col:end]...)}, false
+			e.lines[e.row] = append(line[:e.col], line[end:]...)
+		}
+	case "X":
+		if e.col > 0 {
+			e.save()
+			start := clampInt(e.col-count, 0, e.col)
+			e.lines[e.row] = append(line[:start], line[e.col:]...)
+			e.col = start
+		}
+	case "D", "C":
+		e.save()
+		e.lines[e.row] = line[:e.col]
+		if key == "C" {
+			e.mode = viInsert
+		}
+	case "s":
+		e.save()
+		if e.col < len(line) {
+			e.lines[e.row] = append(line[:e.col], line[e.col+1:]...)
+		}
+		e.mode = viInsert
+	case "S":
+		e.save()
+		e.lines[e.row], e.col, e.mode = nil, 0, viInsert
+	case "J":
+		if e.row < len(e.lines)-1 {
+			e.save()
+			next := []rune(strings.TrimLeft(string(e.lines[e.row+1]), " \t"))
+			e.col = len(line)
+			if len(line) > 0 && len(next) > 0 {
+				line = append(line, ' ')
+			}
+			e.lines[e.row] = append(line, next...)
+			e.lines = append(e.lines[:e.row+1], e.lines[e.row+2:]...)
+		}
+	case "Y":
+		e.yanked, e.linewise = [][]rune{append([]rune(nil), line...)}, true
+	case "p", "P":
+		if e.yanked == nil {
+			e.errorf("E353: Nothing in register \"")
+			return
+		}
+		e.save()
+		if e.linewise {
+			at := e.row + 1
+			if key == "P" {
+				at = e.row
+			}
+			var paste [][]rune
+			for i := 0; i < count; i++ {
+				for _, l := range e.yanked {
+					paste = append(paste, append([]rune(nil), l...))
+				}
+			}
+			e.lines = append(e.lines[:at], append(paste, e.lines[at:]...)...)
+			e.row, e.col = at, firstNonBlank(e.lines[at])
+			return
+		}
+		at := e.col
+		if key == "p" && len(line) > 0 {
+			at++
+		}
+		text := []rune(strings.Repeat(string(e.yanked[0]), count))
+		e.lines[e.row] = append(line[:at:at], append(text, line[at:]...)...)
+		e.col = at + len(text) - 1
+	case "u":
+		if len(e.undo) == 0 {
+			e.message = "Already at oldest change"
+			return
+		}
+		snap := e.undo[len(e.undo)-1]
+		e.undo = e.undo[:len(e.undo)-1]
+		e.lines, e.row, e.col, e.modified = snap.lines, snap.row, snap.col, snap.modified
+		e.message = "1 change; before #" + strconv.Itoa(len(e.undo)+1)
+	case "n", "N":
+		e.find(key == "n")
+	case ":", "/", "?":
+		e.mode, e.cmdKey, e.cmdline = viCmdline, rune(key[0]), nil
+	case "d", "c", "y", "g", "Z", "r":
+		e.pending = key
+		e.count = strconv.Itoa(count)
+		if !hasCount {
+			e.count = ""
+		}
+	case "\x07":
+		e.fileInfo()
+	case "\x0c":
+		io.WriteString(e.out, "\x1b[2J")
+	case "\x03":
+		e.message = "Type  :quit<Enter>  to exit Vim"
+	case "\x1b":
+		io.WriteString(e.out, "\a")
+	}
+}
+
+// fileInfo shows the name and position like Ctrl-G
+func (e *editor) fileInfo() {
+	name := e.file
+	if name == "" {
+		name = "[No Name]"
+	}
+	mod := ""
+	if e.modified {
+		mod = " [Modified]"
+	}
+	e.message = fmt.Sprintf("\"%v\"%v %v lines --%v%%--", name, mod, len(e.lines), (e.row+1)*100/len(e.lines))
+}
@@ -0,0 +1,254 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/pflag"
+)
+
+// vmstat reports the memory and activity of the virtual machine. The first
+// line is the averages since boot, the following ones what goes on between
+// the samples, mostly idle
+type vmstat struct{}
+
+func init() {
+	honeyos.RegisterCommand("vmstat", vmstat{})
+}
+
+func (vmstat) GetHelp() string {
+	return "\nUsage:\n vmstat [options] [delay [count]]\n\nOptions:\n" +
+		" -a, --active           active/inactive memory\n" +
+		" -f, --forks            number of forks since boot\n" +
+		" -m, --slabs            slabinfo\n" +
+		" -n, --one-header       do not redisplay header\n" +
+		" -s, --stats            event counter statistics\n" +
+		" -d, --disk             disk statistics\n" +
+		" -D, --disk-sum         summarize disk statistics\n" +
+		" -p, --partition <dev>  partition specific statistics\n" +
+		" -S, --unit <char>      define display unit\n" +
+		" -w, --wide             wide output\n" +
+		" -t, --timestamp        show timestamp\n\n" +
+		" -h, --help     display this help and exit\n" +
+		" -V, --version  output version information and exit\n\n" +
+		"For more details see vmstat(8).\n"
+}
+
+func (vmstat) Where() string {
+	return "/usr/bin/vmstat"
+}
+
+// vmstatDisks are the disks of the machine, from the devices mounted
+func vmstatDisks(mounts []honeyos.MountInfo) []string {
+	var disks []string
+	seen := map[string]bool{}
+	for _, m := range mounts {
+		if !strings.HasPrefix(m.Device, "/dev/") {
+			continue
+		}
+		name := strings.TrimPrefix(m.Device, "/dev/")
+		if strings.HasPrefix(name, "mapper/") {
+			name = "dm-0"
+		} else {
+			name = strings.TrimRight(name, "0123456789")
+		}
+		if !seen[name] {
+			seen[name] = true
+			disks = append(disks, name)
+		}
+	}
+	return disks
+}
+
+func (v vmstat) Exec(args []string, sys honeyos.Sys) int {
+	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	active := flag.BoolP("active", "a", false, "active/inactive memory")
+	forks := flag.BoolP("forks", "f", false, "number of forks since boot")
+	slabs := flag.BoolP("slabs", "m", false, "slabinfo")
+	oneHeader := flag.BoolP("one-header", "n", false, "do not redisplay header")
+	stats := flag.BoolP("stats", "s", false, "event counter statistics")
+	disk := flag.BoolP("disk", "d", false, "disk statistics")
+	diskSum := flag.BoolP("disk-sum", "D", false, "summarize disk statistics")
+	flag.StringP("partition", "p", "", "partition specific statistics")
+	unit := flag.StringP("unit", "S", "K", "define display unit")
+	wide := flag.BoolP("wide", "w", false, "wide output")
+	timestamp := flag.BoolP("timestamp", "t", false, "show timestamp")
+	help := flag.BoolP("help", "h", false, "display this help and exit")
+	version := flag.BoolP("version", "V", false, "output version information and exit")
+	if err := flag.Parse(args); err != nil {
+		msg := strings.Replace(err.Error(), "unknown shorthand flag: ", "invalid option -- ", 1)
+		if i := strings.Index(msg, " in -"); i >= 0 {
+			msg = msg[:i]
+		}
+		fmt.Fprintf(sys.Err(), "vmstat: %v\n%v", msg, v.GetHelp())
+		return 1
+	}
+	switch {
+	case *help:
+		fmt.Fprint(sys.Out(), v.GetHelp())
+		return 0
+	case *version:
+		fmt.Fprintln(sys.Out(), "vmstat from procps-ng 3.3.10")
+		return 0
+	case *slabs && !isRoot(sys):
+		fmt.Fprintln(sys.Err(), "vmstat: your kernel does not support slabinfo or your permissions are insufficient")
+		return 1
+	}
+	div := 1.0
+	switch *unit {
+	case "k":
+		div = 1000.0 / 1024
+	case "K":
+	case "m":
+		div = 1000000.0 / 1024
+	case "M":
+		div = 1024
+	default:
+		fmt.Fprintf(sys.Err(), "vmstat: -S requires k, K, m or M (default is KiB)\n")
+		return 1
+	}
+	delay, count := 0, 1
+	if operands := flag.Args(); len(operands) > 0 {
+		n, err := strconv.Atoi(operands[0])
+		if err != nil || n < 0 {
+			fmt.Fprintln(sys.Err(), "vmstat: failed to parse argument:", operands[0])
+			return 1
+		}
+		delay, count = n, -1
+		if len(operands) > 1 {
+			if count, err = strconv.Atoi(operands[1]); err != nil || count < 1 {
+				fmt.Fprintln(sys.Err(), "vmstat: failed to parse argument:", operands[1])
+				return 1
+			}
+		}
+		if delay == 0 {
+			count = 1
+		}
+	}
+	up := time.Since(honeyos.BootTime())
+	// Counters since boot grow with the uptime at the rate of an idle server
+	secs := int64(up.Seconds())
+	switch {
+	case *forks:
+		fmt.Fprintf(sys.Out(), "%13d forks\n", 1800+secs*7/10)
+		return 0
+	case *stats:
+		m := readMemInfo(sys)
+		kb := func(n int) int64 { return int64(float64(n) / div) }
+		ticks := secs * 100
+		user, system := ticks/150, ticks/400
+		lines := []struct {
+			n    int64
+			what string
+		}{
+			{kb(m.total), "total memory"}, {kb(m.total - m.free - m.buffers - m.cached), "used memory"},
+			{kb(m.used + m.cached/2), "active memory"}, {kb(m.cached/2 + m.buffers), "inactive memory"},
+			{kb(m.free), "free memory"}, {kb(m.buffers), "buffer memory"}, {kb(m.cached), "swap cache"},
+			{kb(m.swapTotal), "total swap"}, {kb(m.swapTotal - m.swapFree), "used swap"}, {kb(m.swapFree), "free swap"},
+		}
+		for _, l := range lines {
+			fmt.Fprintf(sys.Out(), "%13d %v %v\n", l.n, *unit, l.what)
+		}
+		for _, l := range []struct {
+			n    int64
+			what string
+		}{
+			{user, "non-nice user cpu ticks"}, {ticks / 9000, "nice user cpu ticks"}, {system, "system cpu ticks"},
+			{ticks - user - system - ticks/900, "idle cpu ticks"}, {ticks / 900, "IO-wait cpu ticks"}, {0, "IRQ cpu ticks"},
+			{ticks / 2000, "softirq cpu ticks"}, {ticks / 5000, "stolen cpu ticks"}, {secs * 3, "pages paged in"},
+			{secs * 9, "pages paged out"}, {0, "pages swapped in"}, {0, "pages swapped out"},
+			{secs * 41, "interrupts"}, {secs * 87, "CPU context switches"}, {honeyos.BootTime().Unix(), "boot time"},
+			{1800 + secs*7/10, "forks"},
+		} {
+			fmt.Fprintf(sys.Out(), "%13d %v\n", l.n, l.what)
+		}
+		return 0
+	case *disk || *diskSum:
+		disks := vmstatDisks(sys.Mounts())
+		if *diskSum {
+			fmt.Fprintf(sys.Out(), "%13d disks \n%13d partitions \n", len(disks), len(disks)+1)
+			for _, l := range []struct {
+				n    int64
+				what string
+			}{
+				{secs * 2 / 3, "total reads"}, {secs / 9, "merged reads"}, {secs * 6, "read sectors"},
+				{secs / 2, "milli reading"}, {secs * 2, "writes"}, {secs, "merged writes"},
+				{secs * 18, "written sectors"}, {secs * 3, "milli writing"}, {0, "inprogress IO"},
+				{secs * 2 / 5, "milli spent IO"},
+			} {
+				fmt.Fprintf(sys.Out(), "%13d %v\n", l.n, l.what)
+			}
+			return 0
+		}
+		fmt.Fprintln(sys.Out(), "disk- ------------reads------------ ------------writes----------- -----IO------")
+		fmt.Fprintln(sys.Out(), "       total merged sectors      ms  total merged sectors      ms    cur    sec")
+		for i, d := range disks {
+			s := secs / int64(i*4+1)
+			fmt.Fprintf(sys.Out(), "%-5v %6d %6d %7d %7d %6d %6d %7d %7d %6d %6d\n",
+				d, s*2/3, s/9, s*6, s/2, s*2, s, s*18, s*3, 0, s/2500)
+		}
+		return 0
+	}
+
+	var header1, header2, format string
+	if *wide {
+		header1 = "procs -----------------------memory---------------------- ---swap-- -----io---- -system-- --------cpu--------"
+		header2 = " r  b         swpd         free         buff        cache   si   so    bi    bo   in   cs  us  sy  id  wa  st"
+		if *active {
+			header2 = " r  b         swpd         free        inact       active   si   so    bi    bo   in   cs  us  sy  id  wa  st"
+		}
+		format = "%2d %2d %12d %12d %12d %12d %4d %4d %5d %5d %4d %4d %3d %3d %3d %3d %3d"
+	} else {
+		header1 = "procs -----------memory---------- ---swap-- -----io---- -system-- ------cpu-----"
+		header2 = " r  b   swpd   free   buff  cache   si   so    bi    bo   in   cs us sy id wa st"
+		if *active {
+			header2 = " r  b   swpd   free  inact active   si   so    bi    bo   in   cs us sy id wa st"
+		}
+		format = "%2d %2d %6d %6d %6d %6d %4d %4d %5d %5d %4d %4d %2d %2d %2d %2d %2d"
+	}
+	if *timestamp {
+		header1 += " -----timestamp-----"
+		header2 += fmt.Sprintf(" %19v", time.Now().Format("MST"))
+	}
+	height := sys.Height() - 3
+	if height <= 0 {
+		height = 21
+	}
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for i := 0; count < 0 || i < count; i++ {
+		if i == 0 || !*oneHeader && i%height == 0 {
+			fmt.Fprintln(sys.Out(), header1)
+			fmt.Fprintln(sys.Out(), header2)
+		}
+		m := readMemInfo(sys)
+		kb := func(n int) int64 { return int64(float64(n) / div) }
+		mem := []int64{kb(m.swapTotal - m.swapFree), kb(m.free), kb(m.buffers), kb(m.cached)}
+		if *active {
+			mem = []int64{kb(m.swapTotal - m.swapFree), kb(m.free), kb(m.cached/2 + m.buffers), kb(m.used + m.cached/2)}
+		}
+		// The first line is the averages since boot
+		bi, bo, in, cs, us, sy := 3, 9, 41, 87, 1, 0
+		if i > 0 {
+			bi, bo, in, cs = 0, r.Intn(6)*4, 40+r.Intn(60), 70+r.Intn(120)
+			us, sy = r.Intn(2), r.Intn(2)
+		}
+		row := fmt.Sprintf(format, 1, 0, mem[0], mem[1], mem[2], mem[3], 0, 0, bi, bo, in, cs, us, sy, 100-us-sy, 0, 0)
+		if *timestamp {
+			row += " " + time.Now().Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintln(sys.Out(), row)
+		if count >= 0 && i+1 >= count {
+			break
+		}
+		if !pkgSleep(sys, time.Duration(delay)*time.Second) {
+			return 130
+		}
+	}
+	return 0
+}
@@ -0,0 +1,252 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/mkishere/sshsyrup/util/terminal"
+	"github.com/spf13/pflag"
+)
+
+// watch runs the command repeatedly, showing its output full screen until
+// interrupted
+type watch struct{}
+
+func init() {
+	honeyos.RegisterCommand("watch", watch{})
+}
+
+func (watch) GetHelp() string {
+	return `
+Usage:
+ watch [options] command
+
+Options:
+  -b, --beep             beep if command has a non-zero exit
+  -c, --color            interpret ANSI color and style sequences
+  -d, --differences[=<permanent>]
+                         highlight changes between updates
+  -e, --errexit          exit if command has a non-zero exit
+  -g, --chgexit          exit when output from command changes
+  -n, --interval <secs>  seconds to wait between updates
+  -p, --precise          attempt run command in precise intervals
+  -t, --no-title         turn off header
+  -x, --exec             pass command to exec instead of "sh -c"
+
+ -h, --help     display this help and exit
+ -v, --version  output version information and exit
+
+For more details see watch(1).
+`
+}
+
+func (watch) Where() string {
+	return "/usr/bin/watch"
+}
+
+func (w watch) Exec(args []string, sys honeyos.Sys) int {
+	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	// Options end at the command, whose options are its own
+	flag.SetInterspersed(false)
+	beep := flag.BoolP("beep", "b", false, "")
+	flag.BoolP("color", "c", false, "")
+	diff := flag.StringP("differences", "d", "", "")
+	flag.Lookup("differences").NoOptDefVal = "changes"
+	errExit := flag.BoolP("errexit", "e", false, "")
+	chgExit := flag.BoolP("chgexit", "g", false, "")
+	interval := flag.StringP("interval", "n", "2", "")
+	precise := flag.BoolP("precise", "p", false, "")
+	noTitle := flag.BoolP("no-title", "t", false, "")
+	exec := flag.BoolP("exec", "x", false, "")
+	help := flag.BoolP("help", "h", false, "")
+	version := flag.BoolP("version", "v", false, "")
+	if err := flag.Parse(args); err != nil {
+		msg := strings.Replace(err.Error(), "unknown shorthand flag: ", "invalid option -- ", 1)
+		if i := strings.Index(msg, " in -"); i >= 0 {
+			msg = msg[:i]
+		}
+		fmt.Fprintf(sys.Err(), "watch: %v\n%v", msg, w.GetHelp())
+		return 1
+	}
+	switch {
+	case *help:
+		fmt.Fprint(sys.Out(), w.GetHelp())
+		return 0
+	case *version:
+		fmt.Fprintln(sys.Out(), "watch from procps-ng 3.3.10")
+		return 0
+	case flag.NArg() == 0:
+		fmt.Fprint(sys.Err(), w.GetHelp())
+		return 1
+	}
+	secs, err := strconv.ParseFloat(strings.Replace(*interval, ",", ".", 1), 64)
+	if err != nil {
+		fmt.Fprintf(sys.Err(), "watch: failed to parse argument: '%v'\n", *interval)
+		return 1
+	}
+	if secs < 0.1 {
+		secs = 0.1
+	}
+	argv := flag.Args()
+	cmdLine := strings.Join(argv, " ")
+	if !*exec {
+		argv = []string{"sh", "-c", cmdLine}
+	}
+	sys.Log().WithField("command", cmdLine).Infof("User watching %v", cmdLine)
+
+	// Ctrl-C is taken here so the screen is restored, stopping the command
+	// running through the context
+	intr := honeyos.Interrupt(sys)
+	ctx, cancel := context.WithCancel(sys.Context())
+	defer cancel()
+	go func() {
+		select {
+		case <-intr:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	out := sys.Out()
+	io.WriteString(out, "\x1b[?1049h")
+	defer io.WriteString(out, "\x1b[?1049l")
+	var last []string
+	// The title takes the first two lines
+	skip := 0
+	if !*noTitle {
+		skip = 2
+	}
+	start := time.Now()
+	for i := 0; ; i++ {
+		var buf bytes.Buffer
+		n, _ := honeyos.RunAs(sys, honeyos.Credential{UID: sys.CurrentUser(), Stdin: strings.NewReader(""), Stdout: &buf, Context: ctx}, argv)
+		if ctx.Err() != nil {
+			return 0
+		}
+		screen := w.screen(sys, buf.String(), secs, cmdLine, !*noTitle)
+		w.draw(out, screen, last, skip, *diff != "" && i > 0)
+		switch {
+		case *chgExit && i > 0 && strings.Join(screen[skip:], "\n") != strings.Join(last[skip:], "\n"):
+			return 0
+		case n != 0 && *errExit:
+			io.WriteString(out, "\a\x1b[0m\n"+terminal.Color(terminal.Reverse, "command exit with a non-zero status, press a key to exit"))
+			w.waitKey(sys, ctx)
+			return 8
+		case n != 0 && *beep:
+			io.WriteString(out, "\a")
+		}
+		if *diff != "permanent" || i == 0 {
+			last = screen
+		}
+		wait := time.Duration(secs * float64(time.Second))
+		if *precise {
+			// The runs keep to the interval from the start, regardless of
+			// the time the command takes
+			wait = time.Until(start.Add(time.Duration(i+1) * wait))
+		}
+		select {
+		case <-ctx.Done():
+			return 0
+		case <-time.After(wait):
+		}
+	}
+}
+
+// screen returns the lines shown for the output, beginning with the title
+// and cut to the terminal
+func (watch) screen(sys honeyos.Sys, output string, secs float64, cmdLine string, title bool) []string {
+	width, height := sys.Width(), sys.Height()
+	var lines []string
+	if title {
+		left := fmt.Sprintf("Every %.1fs: %v", secs, cmdLine)
+		right := fmt.Sprintf("%v: %v", sys.Hostname(), honeyos.Now(sys).Format("Mon Jan _2 15:04:05 2006"))
+		if pad := width - len(left) - len(right); pad > 0 {
+			left += strings.Repeat(" ", pad) + right
+		}
+		lines = append(lines, left, "")
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+		if len(lines) >= height {
+			break
+		}
+		var b strings.Builder
+		col := 0
+		for _, r := range line {
+			if r == '\t' {
+				b.WriteString(strings.Repeat(" ", 8-col%8))
+				col += 8 - col%8
+			} else {
+				b.WriteRune(r)
+				col++
+			}
+		}
+		lines = append(lines, b.String())
+	}
+	for i, line := range lines {
+		if r := []rune(line); len(r) > width {
+			lines[i] = string(r[:width])
+		}
+	}
+	return lines
+}
+
+// draw clears the screen and writes the lines, in reverse video where they
+// differ from the last lines after the skipped title if diff is set
+func (watch) draw(out io.Writer, lines, last []string, skip int, diff bool) {
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		if !diff || i < skip {
+			b.WriteString(line)
+			continue
+		}
+		var prev []rune
+		if i < len(last) {
+			prev = []rune(last[i])
+		}
+		for j, r := range []rune(line) {
+			if j >= len(prev) || prev[j] != r {
+				b.WriteString(terminal.Color(terminal.Reverse, string(r)))
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	io.WriteString(out, b.String())
+}
+
+// waitKey waits for a key pressed, or the watch stopped
+func (watch) waitKey(sys honeyos.Sys, ctx context.Context) {
+	keys, modes := honeyos.OpenTTY(sys), sys.Termios()
+	if keys == nil || modes == nil {
+		<-ctx.Done()
+		return
+	}
+	icanon, echo := modes.Flag("icanon"), modes.Flag("echo")
+	modes.SetFlag("icanon", false)
+	modes.SetFlag("echo", false)
+	defer func() {
+		modes.SetFlag("icanon", icanon)
+		modes.SetFlag("echo", echo)
+	}()
+	done := make(chan struct{})
+	go func() {
+		keys.Read(make([]byte, 16))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,166 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/pflag"
+)
+
+// wc counts the lines, words and bytes of the files
+type wc struct{}
+
+// wcCounts are the counts of a file, in the order wc prints them
+type wcCounts struct {
+	lines, words, chars, bytes, maxLine int
+}
+
+func init() {
+	honeyos.RegisterCommand("wc", wc{})
+}
+
+func (wc) GetHelp() string {
+	return "Usage: wc [OPTION]... [FILE]...\n  or:  wc [OPTION]... --files0-from=F\nTry 'wc --help' for more information.\n"
+}
+
+func (wc) Where() string {
+	return "/usr/bin/wc"
+}
+
+func (w wc) Exec(args []string, sys honeyos.Sys) int {
+	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	lines := flag.BoolP("lines", "l", false, "")
+	words := flag.BoolP("words", "w", false, "")
+	chars := flag.BoolP("chars", "m", false, "")
+	byteCount := flag.BoolP("bytes", "c", false, "")
+	maxLine := flag.BoolP("max-line-length", "L", false, "")
+	help := flag.Bool("help", false, "")
+	if err := flag.Parse(args); err != nil {
+		msg := strings.Replace(err.Error(), "unknown shorthand flag: ", "invalid option -- ", 1)
+		if i := strings.Index(msg, " in -"); i >= 0 {
+			msg = msg[:i]
+		}
+		fmt.Fprintf(sys.Err(), "wc: %v\nTry 'wc --help' for more information.\n", msg)
+		return 1
+	}
+	if *help {
+		fmt.Fprint(sys.Out(), w.GetHelp())
+		return 0
+	}
+	if !*lines && !*words && !*chars && !*byteCount && !*maxLine {
+		*lines, *words, *byteCount = true, true, true
+	}
+	files := flag.Args()
+	named := len(files) > 0
+	if !named {
+		files = []string{"-"}
+	}
+
+	var counts []wcCounts
+	var total wcCounts
+	ok := make([]bool, len(files))
+	status := 0
+	stdin := false
+	for i, name := range files {
+		var data []byte
+		if name == "-" {
+			stdin = true
+			data, _ = ioutil.ReadAll(sys.In())
+		} else if fi, err := sys.FSys().Stat(absPath(sys, name)); err == nil && fi.IsDir() {
+			fmt.Fprintf(sys.Err(), "wc: %v: Is a directory\n", name)
+			status = 1
+			ok[i] = true
+		} else if data, err = readFile(sys, absPath(sys, name)); err != nil {
+			fmt.Fprintf(sys.Err(), "wc: %v: No such file or directory\n", name)
+			status = 1
+			counts = append(counts, wcCounts{})
+			continue
+		}
+		ok[i] = true
+		c := wcCount(data)
+		counts = append(counts, c)
+		total.lines += c.lines
+		total.words += c.words
+		total.chars += c.chars
+		total.bytes += c.bytes
+		total.maxLine = intMax(total.maxLine, c.maxLine)
+	}
+
+	// Counts line up on the width of the total bytes, or 7 for pipes,
+	// unless a single count of a single file is printed
+	selected := 0
+	for _, on := range []bool{*lines, *words, *chars, *byteCount, *maxLine} {
+		if on {
+			selected++
+		}
+	}
+	width := len(strconv.Itoa(total.bytes))
+	if stdin && width < 7 {
+		width = 7
+	}
+	if selected == 1 && len(files) == 1 {
+		width = 1
+	}
+	print := func(c wcCounts, name string) {
+		var fields []string
+		for _, f := range []struct {
+			on bool
+			n  int
+		}{{*lines, c.lines}, {*words, c.words}, {*chars, c.chars}, {*byteCount, c.bytes}, {*maxLine, c.maxLine}} {
+			if f.on {
+				fields = append(fields, fmt.Sprintf("%*d", width, f.n))
+			}
+		}
+		line := strings.Join(fields, " ")
+		if name != "" {
+			line += " " + name
+		}
+		fmt.Fprintln(sys.Out(), line)
+	}
+	for i, name := range files {
+		if !ok[i] {
+			continue
+		}
+		if !named {
+			name = ""
+		}
+		print(counts[i], name)
+	}
+	if len(files) > 1 {
+		print(total, "total")
+	}
+	return status
+}
+
+// wcCount counts the data. Words are the runs of characters between
+// blanks
+func wcCount(data []byte) wcCounts {
+	c := wcCounts{bytes: len(data), lines: bytes.Count(data, []byte{'\n'}), chars: utf8.RuneCount(data)}
+	inWord, col := false, 0
+	for _, r := range string(data) {
+		switch {
+		case r == '\n':
+			c.maxLine = intMax(c.maxLine, col)
+			col = 0
+		case r == '\t':
+			col += 8 - col%8
+		default:
+			col++
+		}
+		if unicode.IsSpace(r) {
+			inWord = false
+		} else if !inWord {
+			inWord = true
+			c.words++
+		}
+	}
+	c.maxLine = intMax(c.maxLine, col)
+	return c
+}
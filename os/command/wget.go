@@ -1,23 +1,37 @@
 package command
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
 	urllib "net/url"
 	"path"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/mkishere/sshsyrup/os"
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/afero"
 	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
 )
 
 type wget struct{}
 
+// wget exit status, from the manual of wget
+const (
+	wgetErrGeneric = 1
+	wgetErrParse   = 2
+	wgetErrIO      = 3
+	wgetErrNetwork = 4
+	wgetErrServer  = 8
+)
+
+const wgetUserAgent = "Wget/1.20.3 (linux-gnu)"
+
 func init() {
 	os.RegisterCommand("wget", wget{})
 
@@ -27,84 +41,213 @@ func (wg wget) GetHelp() string {
 }
 
 func printTs() string {
-	return time.Now().Format("2006-01-02 03:04:05")
+	return time.Now().Format("2006-01-02 15:04:05")
 }
 
 func (wg wget) Exec(args []string, sys os.Sys) int {
 	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
-	out := flag.String("O", "", "write documents to FILE.")
-	quiet := flag.BoolP("quiet", "q", false, "quiet (no output).")
-	flag.SetOutput(sys.Out())
-	err := flag.Parse(args)
-	f := flag.Args()
-	if len(args) == 0 {
-		fmt.Fprintln(sys.Out(), "wget: missing URL\nUsage: wget [OPTION]... [URL]...\n\nTry `wget --help' for more options.")
-		return 1
-	}
-	url := strings.TrimSpace(f[0])
+	flag.SetOutput(ioutil.Discard)
+	out := flag.StringP("output-document", "O", "", "write documents to FILE")
+	prefix := flag.StringP("directory-prefix", "P", "", "save files to PREFIX/..")
+	quiet := flag.BoolP("quiet", "q", false, "quiet (no output)")
+	agent := flag.StringP("user-agent", "U", wgetUserAgent, "identify as AGENT instead of Wget/VERSION")
+	flag.Bool("no-check-certificate", false, "don't validate the server's certificate")
+	flag.BoolP("continue", "c", false, "resume getting a partially-downloaded file")
+	flag.BoolP("no-verbose", "n", false, "turn off verboseness, without being quiet")
+	flag.StringP("tries", "t", "", "set number of retries to NUMBER")
+	flag.StringP("timeout", "T", "", "set all timeout values to SECONDS")
+	flag.BoolP("background", "b", false, "go to background after startup")
+	if err := flag.Parse(args); err != nil {
+		fmt.Fprintf(sys.Err(), "wget: %v\nUsage: wget [OPTION]... [URL]...\n\nTry `wget --help' for more options.\n", err)
+		return wgetErrParse
+	}
+	if flag.NArg() == 0 {
+		fmt.Fprintln(sys.Err(), "wget: missing URL\nUsage: wget [OPTION]... [URL]...\n\nTry `wget --help' for more options.")
+		return wgetErrGeneric
+	}
+	logOut := sys.Err()
+	if *quiet {
+		logOut = ioutil.Discard
+	}
+	res := 0
+	for _, arg := range flag.Args() {
+		if n := wg.fetch(strings.TrimSpace(arg), *out, *prefix, *agent, logOut, sys); n != 0 {
+			res = n
+		}
+		if sys.Context().Err() != nil {
+			break
+		}
+	}
+	return res
+}
+
+// fetch downloads the url into the virtual filesystem, with a copy kept in
+// artifact directory. Messages are written to logOut
+func (wg wget) fetch(url, out, prefix, agent string, logOut io.Writer, sys os.Sys) int {
 	if !strings.Contains(url, "://") {
 		url = "http://" + url
 	}
 	urlobj, err := urllib.Parse(url)
-	if err != nil {
-		fmt.Fprintln(sys.Out(), "Malformed URL")
-		return 1
+	if err != nil || urlobj.Hostname() == "" {
+		fmt.Fprintf(logOut, "%v: Invalid URL %v: Invalid host name.\n", url, url)
+		return wgetErrGeneric
+	}
+	if urlobj.Scheme != "http" && urlobj.Scheme != "https" {
+		fmt.Fprintf(logOut, "%v: Unsupported scheme ‘%v’.\n", url, urlobj.Scheme)
+		return wgetErrGeneric
 	}
-	if !*quiet {
-		if urlobj.Scheme != "http" && urlobj.Scheme != "https" {
-			fmt.Fprintf(sys.Out(), "Resolving %v (%v)... failed: Name or service not known.\n", urlobj.Scheme, urlobj.Scheme)
-			fmt.Fprintf(sys.Out(), "wget: unable to resolve host address ‘%v’\n", urlobj.Scheme)
-			return 1
+	host := urlobj.Hostname()
+	port := urlobj.Port()
+	if port == "" {
+		port = "80"
+		if urlobj.Scheme == "https" {
+			port = "443"
 		}
-		fmt.Fprintf(sys.Out(), "--%v--  %v\n", printTs(), url)
 	}
-	ip, err := net.LookupIP(urlobj.Hostname())
-	if err != nil {
-		// handle error
-		fmt.Fprintln(sys.Err(), err)
+	fmt.Fprintf(logOut, "--%v--  %v\n", printTs(), url)
+	sys.Log().WithField("url", url).Infof("User downloading %v", url)
+
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		fmt.Fprintf(logOut, "Resolving %v (%v)... ", host, host)
+		if viper.GetBool("server.allowDownload") {
+			ips, err = net.LookupIP(host)
+		}
+		if len(ips) == 0 {
+			// Either the name does not resolve or downloads are disabled, in
+			// which case the honeypot looks like being offline
+			fmt.Fprintln(logOut, "failed: Temporary failure in name resolution.")
+			fmt.Fprintf(logOut, "wget: unable to resolve host address ‘%v’\n", host)
+			return wgetErrNetwork
+		}
+		var addrs []string
+		for _, ip := range ips {
+			addrs = append(addrs, ip.String())
+		}
+		fmt.Fprintln(logOut, strings.Join(addrs, ", "))
+	}
+	if host == ips[0].String() {
+		fmt.Fprintf(logOut, "Connecting to %v:%v... ", host, port)
+	} else {
+		fmt.Fprintf(logOut, "Connecting to %v (%v)|%v|:%v... ", host, host, ips[0], port)
 	}
-	if !*quiet {
-		fmt.Fprintf(sys.Out(), "Resolving %v (%v)... %v\n", urlobj.Hostname(), urlobj.Hostname(), ip)
+	// The honeypot host and its network are never reached from the session
+	for _, ip := range ips {
+		if netInternal(ip) {
+			sys.Log().WithField("url", url).Warnf("Refused download from internal address %v", ip)
+			fmt.Fprintln(logOut, "failed: Connection refused.")
+			return wgetErrNetwork
+		}
 	}
-	resp, err := http.Get(url)
+	if !viper.GetBool("server.allowDownload") {
+		fmt.Fprintln(logOut, "failed: Connection timed out.")
+		return wgetErrNetwork
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		// handle error
-		fmt.Fprintln(sys.Err(), err)
-		return 1
+		fmt.Fprintln(logOut, "failed: Invalid argument.")
+		return wgetErrNetwork
 	}
-	if !*quiet {
-		fmt.Fprintf(sys.Out(), "Connecting to %v (%v)|%v|:80... connected\n", urlobj.Hostname(), urlobj.Hostname(), ip[0])
-		mimeType := resp.Header.Get("Content-Type")
-		fmt.Fprintln(sys.Out(), "HTTP request sent, awaiting response... 200 OK")
-		fmt.Fprintf(sys.Out(), "Length: unspecified [%v]\n", mimeType[:strings.LastIndex(mimeType, ";")])
+	req.Header.Set("User-Agent", agent)
+	client := &http.Client{Timeout: 60 * time.Second, Transport: &http.Transport{DialContext: netDialExternal}}
+	resp, err := client.Do(req.WithContext(sys.Context()))
+	if err != nil {
+		fmt.Fprintln(logOut, "failed: Connection refused.")
+		return wgetErrNetwork
 	}
 	defer resp.Body.Close()
-	b, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		//handle
-		fmt.Fprintln(sys.Err(), err)
-		return 1
+	fmt.Fprintln(logOut, "connected.")
+	status := strings.TrimSpace(strings.TrimPrefix(resp.Status, fmt.Sprint(resp.StatusCode)))
+	fmt.Fprintf(logOut, "HTTP request sent, awaiting response... %v %v\n", resp.StatusCode, status)
+	if resp.StatusCode >= 400 {
+		fmt.Fprintf(logOut, "%v ERROR %v: %v.\n\n", printTs(), resp.StatusCode, status)
+		return wgetErrServer
 	}
-	if *out == "" {
-		*out = "index.html"
+	mimeType := resp.Header.Get("Content-Type")
+	if i := strings.Index(mimeType, ";"); i >= 0 {
+		mimeType = mimeType[:i]
 	}
-	if !*quiet {
-		fmt.Fprintf(sys.Out(), "Saving to: ‘%v’\n\n", *out)
-		fmt.Fprintf(sys.Out(), "[ <=>%v ] %v       --.-K/s   in 0.1s\n", strings.Repeat(" ", sys.Width()-38), format(len(b)))
+	switch {
+	case resp.ContentLength >= 1024:
+		fmt.Fprintf(logOut, "Length: %v (%v) [%v]\n", resp.ContentLength, wgetSize(resp.ContentLength), mimeType)
+	case resp.ContentLength >= 0:
+		fmt.Fprintf(logOut, "Length: %v [%v]\n", resp.ContentLength, mimeType)
+	default:
+		fmt.Fprintf(logOut, "Length: unspecified [%v]\n", mimeType)
 	}
-	af := afero.Afero{sys.FSys()}
 
-	p := *out
-	if !path.IsAbs(p) {
-		p = path.Join(sys.Getcwd(), p)
+	af := afero.Afero{Fs: sys.FSys()}
+	name := out
+	if name == "" {
+		name = path.Base(urlobj.Path)
+		if name == "/" || name == "." || name == "" {
+			name = "index.html"
+		}
+		if prefix != "" {
+			name = path.Join(prefix, name)
+		}
+		// wget does not overwrite existing files but adds number after them
+		base := name
+		for i := 1; ; i++ {
+			if exists, _ := af.Exists(absPath(sys, name)); !exists {
+				break
+			}
+			name = fmt.Sprintf("%v.%v", base, i)
+		}
+	}
+	if name != "-" {
+		fmt.Fprintf(logOut, "Saving to: ‘%v’\n\n", name)
+	}
+
+	var body bytes.Buffer
+	limit := viper.GetSizeInBytes("server.downloadFileSizeLimit")
+	bar := wgetBar{name: path.Base(name), total: resp.ContentLength, start: time.Now(), out: logOut, sys: sys,
+		animate: logOut != ioutil.Discard && os.IsTerminal(logOut)}
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := resp.Body.Read(buf)
+		body.Write(buf[:n])
+		bar.update(int64(body.Len()))
+		if limit > 0 && uint(body.Len()) > limit {
+			break
+		}
+		if err != nil {
+			if err != io.EOF {
+				if sys.Context().Err() != nil {
+					return wgetErrNetwork
+				}
+				fmt.Fprintf(logOut, "\n%v (%v) - Read error at byte %v (%v).", printTs(), bar.speed(), body.Len(), err)
+				return wgetErrNetwork
+			}
+			break
+		}
 	}
-	err = af.WriteFile(p, b, 0666)
+	bar.finish(int64(body.Len()))
+
+	sum, err := os.SaveArtifact(sys, body.Bytes(), url)
 	if err != nil {
-		fmt.Fprintln(sys.Err(), err)
-		return 1
+		sys.Log().WithError(err).Error("Cannot save downloaded file to artifact directory")
+	}
+	p := absPath(sys, name)
+	if name == "-" {
+		sys.Out().Write(body.Bytes())
+		p = "-"
+	} else if err := af.WriteFile(p, body.Bytes(), 0666&^sys.Umask()); err != nil {
+		fmt.Fprintf(logOut, "%v: Permission denied\n", name)
+		return wgetErrIO
 	}
-	if !*quiet {
-		fmt.Fprintf(sys.Out(), "%v (0.5 KB/s) - ‘%v’ saved[%v]\n", printTs(), *out, format(len(b)))
+	sys.Log().WithFields(log.Fields{
+		"url":    url,
+		"path":   p,
+		"sha256": sum,
+	}).Infof("Downloaded %v bytes from %v", body.Len(), url)
+	if name != "-" {
+		fmt.Fprintf(logOut, "%v (%v) - ‘%v’ saved [%v/%v]\n\n", printTs(), bar.speed(), name, body.Len(), body.Len())
+	} else {
+		fmt.Fprintf(logOut, "%v (%v) - written to stdout [%v/%v]\n\n", printTs(), bar.speed(), body.Len(), body.Len())
 	}
 	return 0
 }
@@ -113,19 +256,146 @@ func (wg wget) Where() string {
 	return "/usr/bin/wget"
 }
 
-// Idea from https://stackoverflow.com/a/31046325
-func format(n int) string {
-	in := strconv.Itoa(n)
-	out := make([]byte, len(in)+len(in)/3)
+// absPath returns the absolute path of p, relative to working directory
+func absPath(sys os.Sys, p string) string {
+	if !path.IsAbs(p) {
+		p = path.Join(sys.Getcwd(), p)
+	}
+	return p
+}
+
+// wgetBar is the progress bar of wget, like
+// index.html      100%[===================>]   1.23K  --.-KB/s    in 0s
+type wgetBar struct {
+	name     string
+	total    int64
+	start    time.Time
+	last     time.Time
+	received int64
+	out      io.Writer
+	sys      os.Sys
+	animate  bool
+}
 
-	for i, j, k := len(in)-1, len(out)-1, 0; ; i, j = i-1, j-1 {
-		out[j] = in[i]
-		if i == 0 {
-			return string(out)
+// update redraws the bar for the received bytes, at most 5 times a second
+func (b *wgetBar) update(received int64) {
+	b.received = received
+	if !b.animate || time.Since(b.last) < 200*time.Millisecond {
+		return
+	}
+	b.last = time.Now()
+	fmt.Fprint(b.out, "\r"+b.render(received, false))
+}
+
+func (b *wgetBar) finish(received int64) {
+	b.received = received
+	if b.animate {
+		fmt.Fprint(b.out, "\r")
+	}
+	fmt.Fprintf(b.out, "%v\n\n", b.render(received, true))
+}
+
+func (b *wgetBar) render(received int64, done bool) string {
+	width := b.sys.Width() - 1
+	if width < 40 {
+		width = 40
+	}
+	name := b.name
+	nameWidth := width / 4
+	if len(name) > nameWidth-1 {
+		name = name[:nameWidth-1]
+	}
+	// Room left for the bar after name, percentage and the statistics
+	barWidth := width - nameWidth - 4 - 2 - 31
+	if barWidth < 5 {
+		barWidth = 5
+	}
+	var percent, bar string
+	if b.total > 0 {
+		p := int(received * 100 / b.total)
+		n := barWidth * p / 100
+		arrow := ">"
+		if n == 0 {
+			arrow = ""
+		} else {
+			n--
 		}
-		if k++; k == 3 {
-			j, k = j-1, 0
-			out[j] = ','
+		percent = fmt.Sprintf("%3d%%", p)
+		bar = strings.Repeat("=", n) + arrow + strings.Repeat(" ", barWidth-n-len(arrow))
+	} else {
+		// Length unknown, a bouncing <=> is shown instead
+		pos := int(time.Since(b.start)/(100*time.Millisecond)) % (2 * (barWidth - 3))
+		if pos > barWidth-3 {
+			pos = 2*(barWidth-3) - pos
 		}
+		if done {
+			pos = 0
+		}
+		percent = "    "
+		bar = strings.Repeat(" ", pos) + "<=>" + strings.Repeat(" ", barWidth-3-pos)
+	}
+	eta := "in " + wgetDuration(time.Since(b.start))
+	if !done {
+		eta = "eta --:--"
+	}
+	return fmt.Sprintf("%-*s%v[%v] %7v  %-10v %-10v", nameWidth, name, percent, bar, wgetSize(received), b.speed(), eta)
+}
+
+// speed is the average download speed, e.g. 1.23MB/s
+func (b *wgetBar) speed() string {
+	elapsed := time.Since(b.start).Seconds()
+	if elapsed < 0.001 || b.received == 0 {
+		return "--.-KB/s"
+	}
+	rate := float64(b.received) / elapsed
+	unit := "B/s"
+	for _, u := range []string{"KB/s", "MB/s", "GB/s"} {
+		if rate < 1024 {
+			break
+		}
+		rate /= 1024
+		unit = u
+	}
+	switch {
+	case rate < 10:
+		return fmt.Sprintf("%.2f%v", rate, unit)
+	case rate < 100:
+		return fmt.Sprintf("%.1f%v", rate, unit)
+	}
+	return fmt.Sprintf("%.0f%v", rate, unit)
+}
+
+// wgetSize formats the size with units, like 1.23K and 45.6M
+func wgetSize(n int64) string {
+	v := float64(n)
+	unit := ""
+	for _, u := range []string{"K", "M", "G", "T"} {
+		if v < 1024 {
+			break
+		}
+		v /= 1024
+		unit = u
+	}
+	switch {
+	case unit == "":
+		return fmt.Sprint(n)
+	case v < 10:
+		return fmt.Sprintf("%.2f%v", v, unit)
+	case v < 100:
+		return fmt.Sprintf("%.1f%v", v, unit)
+	}
+	return fmt.Sprintf("%.0f%v", v, unit)
+}
+
+// wgetDuration formats the time taken, like 0s, 0.1s or 2m 3s
+func wgetDuration(d time.Duration) string {
+	switch {
+	case d < 100*time.Millisecond:
+		return "0s"
+	case d < 10*time.Second:
+		return fmt.Sprintf("%.1fs", d.Seconds())
+	case d < time.Minute:
+		return fmt.Sprintf("%.0fs", d.Seconds())
 	}
+	return fmt.Sprintf("%vm %vs", int(d.Minutes()), int(d.Seconds())%60)
 }
@@ -0,0 +1,212 @@
+package command
+
+import (
+	"fmt"
+	pathlib "path"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+)
+
+type which struct{}
+
+type whereis struct{}
+
+// whereisDirs are where whereis looks for binaries, whatever $PATH is
+const whereisDirs = "/bin:/usr/bin:/sbin:/usr/sbin:/usr/local/bin:/usr/local/sbin:/usr/games:/usr/local/games:/snap/bin"
+
+func init() {
+	honeyos.RegisterCommand("which", which{})
+	honeyos.RegisterCommand("whereis", whereis{})
+}
+
+func (which) GetHelp() string {
+	if honeyos.Distro() == "centos" {
+		return `Usage: /usr/bin/which [options] [--] COMMAND [...]
+Write the full path of COMMAND(s) to standard output.
+
+  --version, -[vV] Print version and exit successfully.
+  --help,          Print this help and exit successfully.
+  --skip-dot       Skip directories in PATH that start with a dot.
+  --skip-tilde     Skip directories in PATH that start with a tilde.
+  --show-dot       Don't expand a dot to current directory in output.
+  --show-tilde     Output a tilde for HOME directory for non-root.
+  --tty-only       Stop processing options on the right if not on tty.
+  --all, -a        Print all matches in PATH, not just the first
+  --read-alias, -i Read list of aliases from stdin.
+  --skip-alias     Ignore option --read-alias; don't read stdin.
+  --read-functions Read shell functions from stdin.
+  --skip-functions Ignore option --read-functions; don't read stdin.
+
+Recommended use is to write the output of (alias; declare -f) to standard
+input, so that which can show aliases and shell functions. See which(1) for
+examples.
+
+If the options --read-alias and/or --read-functions are specified then the
+output can be a combination of the last non-zero exit status of an alias or
+function, or 1 if no match was found.
+
+Report bugs to <which-bugs@gnu.org>.
+`
+	}
+	return "Usage: which [-a] args\n"
+}
+
+func (which) Where() string {
+	return "/usr/bin/which"
+}
+
+func (w which) Exec(args []string, sys honeyos.Sys) int {
+	gnu := honeyos.Distro() == "centos"
+	all := false
+	var names []string
+	for i, arg := range args {
+		if arg == "--" {
+			names = append(names, args[i+1:]...)
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			names = append(names, arg)
+			continue
+		}
+		switch {
+		case arg == "-a" || gnu && arg == "--all":
+			all = true
+		case gnu && (arg == "--help"):
+			fmt.Fprint(sys.Out(), w.GetHelp())
+			return 0
+		case gnu && (arg == "--version" || arg == "-v" || arg == "-V"):
+			fmt.Fprintln(sys.Out(), "GNU which v2.20, Copyright (C) 1999 - 2008 Carlo Wood.")
+			return 0
+		case gnu && (arg == "--skip-dot" || arg == "--skip-tilde" || arg == "--show-dot" || arg == "--show-tilde" ||
+			arg == "--tty-only" || arg == "--skip-alias" || arg == "--skip-functions" || arg == "-i" ||
+			arg == "--read-alias" || arg == "--read-functions"):
+		case gnu:
+			fmt.Fprintf(sys.Err(), "/usr/bin/which: invalid option -- '%v'\n%v", arg[1:2], w.GetHelp())
+			return 1
+		default:
+			fmt.Fprint(sys.Out(), w.GetHelp())
+			return 2
+		}
+	}
+	if len(names) == 0 {
+		if gnu {
+			fmt.Fprint(sys.Err(), w.GetHelp())
+		}
+		return 1
+	}
+	path := honeyos.Getenv(sys, "PATH")
+	status := 0
+	for _, name := range names {
+		var found []string
+		if strings.Contains(name, "/") {
+			// The path is given, which only has to be executable
+			if fi, err := sys.FSys().Stat(absPath(sys, name)); err == nil && fi.Mode().IsRegular() && fi.Mode()&0111 != 0 {
+				found = []string{name}
+			}
+		} else {
+			found = honeyos.LookPath(sys, path, name)
+		}
+		if len(found) == 0 {
+			if gnu {
+				fmt.Fprintf(sys.Err(), "/usr/bin/which: no %v in (%v)\n", name, path)
+			}
+			status = 1
+			continue
+		}
+		if !all {
+			found = found[:1]
+		}
+		for _, p := range found {
+			fmt.Fprintln(sys.Out(), p)
+		}
+	}
+	return status
+}
+
+func (whereis) GetHelp() string {
+	return `
+Usage:
+ whereis [options] [-BMS <dir>... -f] <name>
+
+Locate the binary, source, and manual-page files for a command.
+
+Options:
+ -b         search only for binaries
+ -B <dirs>  define binaries lookup path
+ -m         search only for manuals and infos
+ -M <dirs>  define man and info lookup path
+ -s         search only for sources
+ -S <dirs>  define sources lookup path
+ -f         terminate <dirs> argument list
+ -u         search for unusual entries
+ -l         output effective lookup paths
+
+ -h, --help     display this help and exit
+ -V, --version  output version information and exit
+
+For more details see whereis(1).
+`
+}
+
+func (whereis) Where() string {
+	return "/usr/bin/whereis"
+}
+
+func (w whereis) Exec(args []string, sys honeyos.Sys) int {
+	if honeyos.Distro() == "alpine" {
+		return honeyos.CommandNotFound(sys, append([]string{"whereis"}, args...))
+	}
+	bins, mans := true, true
+	var names []string
+	for _, arg := range args {
+		switch arg {
+		case "-h", "--help":
+			fmt.Fprint(sys.Out(), w.GetHelp())
+			return 0
+		case "-V", "--version":
+			fmt.Fprintln(sys.Out(), "whereis from util-linux 2.34")
+			return 0
+		case "-b":
+			bins, mans = true, false
+		case "-m":
+			bins, mans = false, true
+		case "-s":
+			bins, mans = false, false
+		case "-u", "-f":
+		case "-l":
+			for _, dir := range strings.Split(whereisDirs, ":") {
+				fmt.Fprintf(sys.Out(), "bin: %v\n", dir)
+			}
+			fmt.Fprintln(sys.Out(), "man: /usr/share/man\nman: /usr/local/man")
+			return 0
+		default:
+			if strings.HasPrefix(arg, "-") {
+				fmt.Fprintf(sys.Err(), "whereis: bad usage\nTry 'whereis --help' for more information.\n")
+				return 1
+			}
+			names = append(names, arg)
+		}
+	}
+	if len(names) == 0 {
+		fmt.Fprintf(sys.Err(), "whereis: not enough arguments\nTry 'whereis --help' for more information.\n")
+		return 1
+	}
+	for _, name := range names {
+		name = pathlib.Base(name)
+		found := honeyos.LookPath(sys, whereisDirs, name)
+		var paths []string
+		if bins {
+			paths = append(paths, found...)
+		}
+		if mans && len(found) > 0 {
+			section := "1"
+			if strings.HasSuffix(pathlib.Dir(found[0]), "sbin") {
+				section = "8"
+			}
+			paths = append(paths, fmt.Sprintf("/usr/share/man/man%v/%v.%v.gz", section, name, section))
+		}
+		fmt.Fprintln(sys.Out(), strings.TrimSpace(name+": "+strings.Join(paths, " ")))
+	}
+	return 0
+}
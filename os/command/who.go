@@ -0,0 +1,275 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/afero"
+	"github.com/spf13/pflag"
+)
+
+// who shows who is logged in from utmp
+type who struct{}
+
+// w shows who is logged in and what they are doing
+type w struct{}
+
+func init() {
+	honeyos.RegisterCommand("who", who{})
+	honeyos.RegisterCommand("w", w{})
+}
+
+// readUtmp reads the boots and sessions in the utmp file. The session of sys
+// is added to /var/run/utmp unless the file has changed since the login, as
+// cleaned by the attacker
+func readUtmp(sys honeyos.Sys, name string) ([]honeyos.UtmpRecord, error) {
+	data, err := afero.ReadFile(sys.FSys(), name)
+	if err != nil {
+		return nil, err
+	}
+	records := honeyos.ParseUtmp(data)
+	if name != "/var/run/utmp" {
+		return records, nil
+	}
+	fi, err := sys.FSys().Stat(name)
+	if cur, ok := honeyos.CurrentSession(sys); ok && err == nil && !fi.ModTime().After(cur.Time) {
+		records = append(records, cur)
+	}
+	return records, nil
+}
+
+func (who) GetHelp() string {
+	return "Usage: who [OPTION]... [ FILE | ARG1 ARG2 ]\n" +
+		"Print information about users who are currently logged in.\n\n" +
+		"  -a, --all         same as -b -d --login -p -r -t -T -u\n" +
+		"  -b, --boot        time of last system boot\n" +
+		"  -H, --heading     print line of column headings\n" +
+		"  -l, --login       print system login processes\n" +
+		"  -m                only hostname and user associated with stdin\n" +
+		"  -q, --count       all login names and number of users logged on\n" +
+		"  -r, --runlevel    print current runlevel\n" +
+		"  -s, --short       print only name, line, and time (default)\n" +
+		"  -T, -w, --mesg    add user's message status as +, - or ?\n" +
+		"  -u, --users       list users logged in\n" +
+		"      --help     display this help and exit\n" +
+		"      --version  output version information and exit\n\n" +
+		"If FILE is not specified, use /var/run/utmp.  /var/log/wtmp as FILE is common.\n" +
+		"If ARG1 ARG2 given, -m presumed: 'am i' or 'mom likes' are usual.\n"
+}
+
+func (who) Where() string {
+	return "/usr/bin/who"
+}
+
+func (wh who) Exec(args []string, sys honeyos.Sys) int {
+	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	all := flag.BoolP("all", "a", false, "same as -b -d --login -p -r -t -T -u")
+	boot := flag.BoolP("boot", "b", false, "time of last system boot")
+	heading := flag.BoolP("heading", "H", false, "print line of column headings")
+	login := flag.BoolP("login", "l", false, "print system login processes")
+	me := flag.BoolP("m", "m", false, "only hostname and user associated with stdin")
+	count := flag.BoolP("count", "q", false, "all login names and number of users logged on")
+	runlevel := flag.BoolP("runlevel", "r", false, "print current runlevel")
+	flag.BoolP("short", "s", false, "print only name, line, and time (default)")
+	mesg := flag.BoolP("mesg", "T", false, "add user's message status as +, - or ?")
+	flag.BoolP("writable", "w", false, "add user's message status as +, - or ?")
+	users := flag.BoolP("users", "u", false, "list users logged in")
+	help := flag.Bool("help", false, "display this help and exit")
+	version := flag.Bool("version", false, "output version information and exit")
+	if err := flag.Parse(args); err != nil {
+		msg := strings.Replace(err.Error(), "unknown shorthand flag: ", "invalid option -- ", 1)
+		if i := strings.Index(msg, " in -"); i >= 0 {
+			msg = msg[:i]
+		}
+		fmt.Fprintf(sys.Err(), "who: %v\nTry 'who --help' for more information.\n", msg)
+		return 1
+	}
+	switch {
+	case *help:
+		fmt.Fprint(sys.Out(), wh.GetHelp())
+		return 0
+	case *version:
+		fmt.Fprintln(sys.Out(), "who (GNU coreutils) 8.25")
+		return 0
+	}
+	name := "/var/run/utmp"
+	switch operands := flag.Args(); len(operands) {
+	case 0:
+	case 1:
+		name = absPath(sys, operands[0])
+	case 2:
+		*me = true
+	default:
+		fmt.Fprintf(sys.Err(), "who: extra operand '%v'\nTry 'who --help' for more information.\n", operands[2])
+		return 1
+	}
+	if *all || flag.Changed("writable") {
+		*mesg = true
+	}
+	if *all {
+		*boot, *login, *runlevel, *users = true, true, true, true
+	}
+	// Only the users are shown unless asked for others
+	showUsers := *users || !(*boot || *login || *runlevel)
+	// utmp that cannot be read is taken as empty
+	records, _ := readUtmp(sys, name)
+	cur, _ := honeyos.CurrentSession(sys)
+
+	if *count {
+		var names []string
+		for _, r := range records {
+			if r.Type == honeyos.UtmpUserProcess {
+				names = append(names, r.User)
+			}
+		}
+		fmt.Fprintf(sys.Out(), "%v\n# users=%v\n", strings.Join(names, " "), len(names))
+		return 0
+	}
+	line := func(user, state, tty string, t time.Time, idle, pid, comment string) {
+		s := fmt.Sprintf("%-8s", user)
+		if *mesg {
+			s += " " + state
+		}
+		s += fmt.Sprintf(" %-12s %-16s", tty, t.Format("2006-01-02 15:04"))
+		if *users {
+			s += fmt.Sprintf(" %-6s %10s", idle, pid)
+		}
+		if comment != "" {
+			s += " " + comment
+		}
+		fmt.Fprintln(sys.Out(), strings.TrimRight(s, " "))
+	}
+	if *heading {
+		s := fmt.Sprintf("%-8s", "NAME")
+		if *mesg {
+			s += "  "
+		}
+		s += fmt.Sprintf(" %-12s %-16s", "LINE", "TIME")
+		if *users {
+			s += fmt.Sprintf(" %-6s %10s", "IDLE", "PID")
+		}
+		fmt.Fprintln(sys.Out(), s+" COMMENT")
+	}
+	for _, r := range records {
+		switch {
+		case r.Type == honeyos.UtmpBootTime && *boot && !*me:
+			line("", " ", "system boot", r.Time, "", "", "")
+		case r.Type == honeyos.UtmpRunLevel && *runlevel && !*me && r.User == "runlevel":
+			line("", " ", fmt.Sprintf("run-level %c", r.PID%256), r.Time, "", "", "")
+		case r.Type == honeyos.UtmpLoginProcess && *login && !*me:
+			line("LOGIN", " ", r.Line, r.Time, "", fmt.Sprint(r.PID), "id="+r.ID)
+		case r.Type == honeyos.UtmpUserProcess && showUsers:
+			if *me && r.Line != cur.Line {
+				continue
+			}
+			idle := "old"
+			if d := time.Since(r.Time); d < time.Minute || r.Line == cur.Line {
+				idle = "  .  "
+			} else if d < 24*time.Hour {
+				idle = fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+			}
+			comment := ""
+			if r.Host != "" {
+				comment = "(" + r.Host + ")"
+			}
+			line(r.User, "+", r.Line, r.Time, idle, fmt.Sprint(r.PID), comment)
+		}
+	}
+	return 0
+}
+
+func (w) GetHelp() string {
+	return "\nUsage:\n w [options]\n\nOptions:\n" +
+		" -h, --no-header     do not print header\n" +
+		" -u, --no-current    ignore current process username\n" +
+		" -s, --short         short format\n" +
+		" -f, --from          show remote hostname field\n" +
+		" -o, --old-style     old style output\n" +
+		" -i, --ip-addr       display IP address instead of hostname (if possible)\n\n" +
+		"     --help     display this help and exit\n" +
+		" -V, --version  output version information and exit\n\n" +
+		"For more details see w(1).\n"
+}
+
+func (w) Where() string {
+	return "/usr/bin/w"
+}
+
+func (wc w) Exec(args []string, sys honeyos.Sys) int {
+	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	noHeader := flag.BoolP("no-header", "h", false, "do not print header")
+	flag.BoolP("no-current", "u", false, "ignore current process username")
+	short := flag.BoolP("short", "s", false, "short format")
+	from := flag.BoolP("from", "f", false, "show remote hostname field")
+	flag.BoolP("old-style", "o", false, "old style output")
+	flag.BoolP("ip-addr", "i", false, "display IP address instead of hostname (if possible)")
+	help := flag.Bool("help", false, "display this help and exit")
+	version := flag.BoolP("version", "V", false, "output version information and exit")
+	if err := flag.Parse(args); err != nil {
+		msg := strings.Replace(err.Error(), "unknown shorthand flag: ", "invalid option -- ", 1)
+		if i := strings.Index(msg, " in -"); i >= 0 {
+			msg = msg[:i]
+		}
+		fmt.Fprintf(sys.Err(), "w: %v\n%v", msg, wc.GetHelp())
+		return 1
+	}
+	switch {
+	case *help:
+		fmt.Fprint(sys.Out(), wc.GetHelp())
+		return 0
+	case *version:
+		fmt.Fprintln(sys.Out(), "w from procps-ng 3.3.10")
+		return 0
+	}
+	records, _ := readUtmp(sys, "/var/run/utmp")
+	var sessions []honeyos.UtmpRecord
+	for _, r := range records {
+		if r.Type == honeyos.UtmpUserProcess && (flag.NArg() == 0 || r.User == flag.Arg(0)) {
+			sessions = append(sessions, r)
+		}
+	}
+	cur, _ := honeyos.CurrentSession(sys)
+	// The FROM field is shown by default, -f hides it
+	showFrom := !*from
+	if !*noHeader {
+		fmt.Fprintln(sys.Out(), " "+uptimeLine(sys))
+		s := "USER     TTY     "
+		if showFrom {
+			s += " FROM            "
+		}
+		if *short {
+			s += "  IDLE WHAT"
+		} else {
+			s += "  LOGIN@   IDLE   JCPU   PCPU WHAT"
+		}
+		fmt.Fprintln(sys.Out(), s)
+	}
+	for _, r := range sessions {
+		login := r.Time.Format("15:04")
+		if d := time.Since(r.Time); d > 7*24*time.Hour {
+			login = r.Time.Format("02Jan06")
+		} else if r.Time.YearDay() != time.Now().YearDay() {
+			login = r.Time.Format("Mon15")
+		}
+		idle, what, jcpu, pcpu := "0.00s", "w", "0.02s", "0.00s"
+		if r.Line != cur.Line {
+			idle, what, jcpu, pcpu = fmt.Sprintf("%vm", int(time.Since(r.Time).Minutes())%60+1), "-bash", "0.01s", "0.01s"
+		}
+		s := fmt.Sprintf("%-8.8s %-8.8s", r.User, r.Line)
+		if showFrom {
+			s += fmt.Sprintf(" %-16.16s", r.Host)
+		}
+		if *short {
+			s += fmt.Sprintf(" %6s %v", idle, what)
+		} else {
+			s += fmt.Sprintf(" %-8s %6s %6s %6s %v", login, idle, jcpu, pcpu, what)
+		}
+		fmt.Fprintln(sys.Out(), s)
+	}
+	return 0
+}
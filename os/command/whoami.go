@@ -13,12 +13,34 @@ func init() {
 }
 
 func (whoami) GetHelp() string {
-	return ""
+	return `Usage: whoami [OPTION]...
+Print the user name associated with the current effective user ID.
+Same as id -un.
+
+      --help     display this help and exit
+      --version  output version information and exit
+`
 }
 
-func (whoami) Exec(args []string, sys os.Sys) int {
+func (w whoami) Exec(args []string, sys os.Sys) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "--help":
+			fmt.Fprint(sys.Out(), w.GetHelp())
+			return 0
+		case "--version":
+			fmt.Fprintln(sys.Out(), "whoami (GNU coreutils) 8.32")
+			return 0
+		}
+		fmt.Fprintf(sys.Err(), "whoami: extra operand '%v'\nTry 'whoami --help' for more information.\n", args[0])
+		return 1
+	}
 	id := sys.CurrentUser()
 	u := os.GetUserByID(id)
+	if u.Name == "" {
+		fmt.Fprintf(sys.Err(), "whoami: cannot find name for user ID %v\n", id)
+		return 1
+	}
 	fmt.Fprintln(sys.Out(), u.Name)
 	return 0
 }
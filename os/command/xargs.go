@@ -0,0 +1,358 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+)
+
+// xargs runs the command with the arguments read from input, so pipelines
+// like find | xargs grep work. Commands run with empty input like the
+// /dev/null of GNU xargs
+type xargs struct{}
+
+type xargsOptions struct {
+	// delim splits the input into items instead of blanks and quotes, NUL
+	// for -0
+	delim             string
+	maxArgs, maxLines int
+	replace           string
+	noEmpty, verbose  bool
+	file, eof         string
+}
+
+const xargsUsage = `Usage: xargs [OPTION]... COMMAND [INITIAL-ARGS]...
+Run COMMAND with arguments INITIAL-ARGS and more arguments read from input.
+
+Mandatory and optional arguments to long options are also
+mandatory or optional for the corresponding short option.
+  -0, --null                   items are separated by a null, not whitespace;
+                                 disables quote and backslash processing and
+                                 logical EOF processing
+  -a, --arg-file=FILE          read arguments from FILE, not standard input
+  -d, --delimiter=CHARACTER    items in input stream are separated by CHARACTER,
+                                 not by whitespace; disables quote and backslash
+                                 processing and logical EOF processing
+  -E END                       set logical EOF string; if END occurs as a line
+                                 of input, the rest of the input is ignored
+                                 (ignored if -0 or -d was specified)
+  -I R                         same as --replace=R
+  -i, --replace[=R]            replace R in INITIAL-ARGS with names read
+                                 from standard input; if R is unspecified,
+                                 assume {}
+  -L, --max-lines=MAX-LINES    use at most MAX-LINES non-blank input lines per
+                                 command line
+  -n, --max-args=MAX-ARGS      use at most MAX-ARGS arguments per command line
+  -P, --max-procs=MAX-PROCS    run at most MAX-PROCS processes at a time
+  -r, --no-run-if-empty        if there are no arguments, then do not run COMMAND;
+                                 if this option is not given, COMMAND will be
+                                 run at least once
+  -s, --max-chars=MAX-CHARS    limit length of command line to MAX-CHARS
+  -t, --verbose                print commands before executing them
+  -x, --exit                   exit if the size (see -s) is exceeded
+      --help                   display this help and exit
+      --version                output version information and exit
+
+Report bugs to: bug-findutils@gnu.org
+`
+
+func init() {
+	honeyos.RegisterCommand("xargs", xargs{})
+}
+
+func (xargs) GetHelp() string {
+	return xargsUsage
+}
+
+func (xargs) Where() string {
+	return "/usr/bin/xargs"
+}
+
+func (x xargs) Exec(args []string, sys honeyos.Sys) int {
+	var opt xargsOptions
+	long := map[string]string{"null": "0", "arg-file": "a", "delimiter": "d", "eof": "e", "replace": "i",
+		"max-lines": "L", "max-args": "n", "max-procs": "P", "no-run-if-empty": "r", "max-chars": "s",
+		"verbose": "t", "exit": "x"}
+	i := 0
+	for ; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			i++
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			break
+		}
+		if strings.HasPrefix(arg, "--") {
+			name, value := arg[2:], ""
+			hasValue := false
+			if eq := strings.IndexByte(name, '='); eq >= 0 {
+				name, value, hasValue = name[:eq], name[eq+1:], true
+			}
+			switch name {
+			case "help":
+				fmt.Fprint(sys.Out(), xargsUsage)
+				return 0
+			case "version":
+				fmt.Fprintln(sys.Out(), "xargs (GNU findutils) 4.7.0-git")
+				return 0
+			}
+			c, ok := long[name]
+			if !ok {
+				fmt.Fprintf(sys.Err(), "xargs: unrecognized option '%v'\nTry 'xargs --help' for more information.\n", arg)
+				return 1
+			}
+			if !hasValue && strings.Contains("adLnPs", c) {
+				if i+1 >= len(args) {
+					fmt.Fprintf(sys.Err(), "xargs: option '--%v' requires an argument\nTry 'xargs --help' for more information.\n", name)
+					return 1
+				}
+				i++
+				value = args[i]
+			}
+			if n := x.option(sys, &opt, c[0], value); n >= 0 {
+				return n
+			}
+			continue
+		}
+		for j := 1; j < len(arg); j++ {
+			c := arg[j]
+			value := arg[j+1:]
+			switch {
+			case strings.IndexByte("adEILnPs", c) >= 0:
+				if value == "" {
+					if i+1 >= len(args) {
+						fmt.Fprintf(sys.Err(), "xargs: option requires an argument -- '%c'\nTry 'xargs --help' for more information.\n", c)
+						return 1
+					}
+					i++
+					value = args[i]
+				}
+				j = len(arg)
+			case strings.IndexByte("iel", c) >= 0:
+				// Optional values are only taken when attached
+				j = len(arg)
+			case strings.IndexByte("0rtx", c) < 0:
+				fmt.Fprintf(sys.Err(), "xargs: invalid option -- '%c'\nTry 'xargs --help' for more information.\n", c)
+				return 1
+			}
+			if n := x.option(sys, &opt, c, value); n >= 0 {
+				return n
+			}
+		}
+	}
+	cmd := args[i:]
+	if len(cmd) == 0 {
+		cmd = []string{"echo"}
+	}
+
+	var in io.Reader = sys.In()
+	if opt.file != "" {
+		f, err := sys.FSys().Open(absPath(sys, opt.file))
+		if err != nil {
+			fmt.Fprintf(sys.Err(), "xargs: %v: No such file or directory\n", opt.file)
+			return 1
+		}
+		defer f.Close()
+		in = f
+	}
+	data, _ := ioutil.ReadAll(in)
+	lines, err := x.split(string(data), opt)
+	if err != "" {
+		fmt.Fprintf(sys.Err(), "xargs: %v\n", err)
+		return 1
+	}
+
+	// Group the items into the command lines to run
+	var batches [][]string
+	switch {
+	case opt.replace != "":
+		for _, line := range lines {
+			item := strings.Join(line, " ")
+			var c []string
+			for _, arg := range cmd {
+				c = append(c, strings.Replace(arg, opt.replace, item, -1))
+			}
+			batches = append(batches, c)
+		}
+	case opt.maxLines > 0:
+		for n := 0; n < len(lines); n += opt.maxLines {
+			c := append([]string{}, cmd...)
+			for _, line := range lines[n:intMin(n+opt.maxLines, len(lines))] {
+				c = append(c, line...)
+			}
+			batches = append(batches, c)
+		}
+	default:
+		var items []string
+		for _, line := range lines {
+			items = append(items, line...)
+		}
+		size := len(items)
+		if opt.maxArgs > 0 {
+			size = opt.maxArgs
+		}
+		for n := 0; n < len(items); n += size {
+			batches = append(batches, append(append([]string{}, cmd...), items[n:intMin(n+size, len(items))]...))
+		}
+	}
+	if len(batches) == 0 && !opt.noEmpty && opt.replace == "" {
+		batches = append(batches, cmd)
+	}
+
+	status := 0
+	for _, c := range batches {
+		if opt.verbose {
+			fmt.Fprintln(sys.Err(), strings.Join(c, " "))
+		}
+		n, found := honeyos.RunAs(sys, honeyos.Credential{UID: sys.CurrentUser(), Stdin: strings.NewReader("")}, c)
+		switch {
+		case !found:
+			fmt.Fprintf(sys.Err(), "xargs: %v: No such file or directory\n", c[0])
+			return 127
+		case n == 255:
+			fmt.Fprintf(sys.Err(), "xargs: %v: exited with status 255; aborting\n", c[0])
+			return 124
+		case n >= 128:
+			fmt.Fprintf(sys.Err(), "xargs: %v: terminated by signal %d\n", c[0], n-128)
+			return 125
+		case n != 0:
+			status = 123
+		}
+		if sys.Context().Err() != nil {
+			return 130
+		}
+	}
+	return status
+}
+
+// option sets the option of the letter. It returns the exit status if
+// xargs is done, -1 to go on
+func (xargs) option(sys honeyos.Sys, opt *xargsOptions, c byte, value string) int {
+	number := func() int {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			fmt.Fprintf(sys.Err(), "xargs: invalid number \"%v\" for -%c option\nTry 'xargs --help' for more information.\n", value, c)
+			return -1
+		}
+		return n
+	}
+	switch c {
+	case '0':
+		opt.delim = "\x00"
+	case 'a':
+		opt.file = value
+	case 'd':
+		switch value {
+		case `\n`:
+			value = "\n"
+		case `\t`:
+			value = "\t"
+		case `\0`:
+			value = "\x00"
+		}
+		if len(value) != 1 {
+			fmt.Fprintf(sys.Err(), "xargs: invalid input delimiter specification %v: the delimiter must be either a single character or an escape sequence starting with \\.\n", value)
+			return 1
+		}
+		opt.delim = value
+	case 'E', 'e':
+		opt.eof = value
+	case 'I', 'i':
+		if value == "" {
+			value = "{}"
+		}
+		opt.replace = value
+	case 'L', 'l':
+		if value == "" {
+			value = "1"
+		}
+		if opt.maxLines = number(); opt.maxLines < 0 {
+			return 1
+		}
+	case 'n':
+		if opt.maxArgs = number(); opt.maxArgs < 0 {
+			return 1
+		}
+	case 'P', 's':
+		if number() < 0 {
+			return 1
+		}
+	case 'r':
+		opt.noEmpty = true
+	case 't':
+		opt.verbose = true
+	}
+	return -1
+}
+
+// split splits the input into the items of each line. Items are separated
+// by blanks unless the delimiter is set, and quotes and backslashes escape
+// them. With -I the item is the whole line
+func (xargs) split(data string, opt xargsOptions) (lines [][]string, err string) {
+	if opt.delim != "" {
+		items := strings.Split(data, opt.delim)
+		if items[len(items)-1] == "" || opt.delim != "\x00" && items[len(items)-1] == "\n" {
+			items = items[:len(items)-1]
+		}
+		for _, item := range items {
+			lines = append(lines, []string{item})
+		}
+		return lines, ""
+	}
+	for _, line := range strings.Split(data, "\n") {
+		if opt.replace != "" {
+			line = strings.TrimLeft(line, " \t")
+		}
+		var items []string
+		var item strings.Builder
+		quote, started := byte(0), false
+		for i := 0; i < len(line); i++ {
+			c := line[i]
+			switch {
+			case quote != 0:
+				if c == quote {
+					quote = 0
+				} else {
+					item.WriteByte(c)
+				}
+			case c == '\'' || c == '"':
+				quote, started = c, true
+			case c == '\\' && i+1 < len(line):
+				i++
+				item.WriteByte(line[i])
+				started = true
+			case (c == ' ' || c == '\t') && opt.replace == "":
+				if started {
+					items = append(items, item.String())
+					item.Reset()
+					started = false
+				}
+			default:
+				item.WriteByte(c)
+				started = true
+			}
+		}
+		if quote != 0 {
+			name := "single"
+			if quote == '"' {
+				name = "double"
+			}
+			return nil, fmt.Sprintf("unmatched %v quote; by default quotes are special to xargs unless you use the -0 option", name)
+		}
+		if started {
+			items = append(items, item.String())
+		}
+		if opt.eof != "" && len(items) > 0 && items[0] == opt.eof {
+			break
+		}
+		if len(items) > 0 {
+			lines = append(lines, items)
+		}
+	}
+	return lines, ""
+}
@@ -0,0 +1,634 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+	"time"
+
+	honeyos "github.com/mkishere/sshsyrup/os"
+	"github.com/spf13/afero"
+	"github.com/spf13/pflag"
+)
+
+// yum is yum and dnf, which share repositories and differ in messages
+type yum struct {
+	name string
+}
+
+type rpm struct{}
+
+func init() {
+	honeyos.RegisterCommand("yum", yum{"yum"})
+	honeyos.RegisterCommand("dnf", yum{"dnf"})
+	honeyos.RegisterCommand("rpm", rpm{})
+}
+
+// yumMirrorCache is created when mirrors are first resolved, so later runs
+// load them from cache like yum does
+const yumMirrorCache = "/var/cache/yum/x86_64/7/timedhosts.txt"
+
+// epelPkgs are only available after epel-release is installed
+var epelPkgs = map[string]bool{
+	"masscan": true, "hydra": true, "john": true, "htop": true, "tor": true, "proxychains": true,
+	"nodejs": true, "npm": true, "golang": true, "nginx": true,
+}
+
+// yumKey is the GPG key which packages from the repository are signed with
+type yumKey struct {
+	id, created, user, fingerprint, pkg, file string
+}
+
+var yumKeys = map[string]yumKey{
+	"base": {"f4a80eb5", "53a7ff4b", "CentOS-7 Key (CentOS 7 Official Signing Key) <security@centos.org>",
+		"6341 ab27 53d7 8a78 a7c2 7bb1 4a6d 1ac0 f4a8 0eb5", "centos-release-7-9.2009.1.el7.centos.x86_64 (@CentOS)",
+		"/etc/pki/rpm-gpg/RPM-GPG-KEY-CentOS-7"},
+	"epel": {"352c64e5", "52ae6884", "Fedora EPEL (7) <epel@fedoraproject.org>",
+		"91e9 7d7c 4a5e 96f1 7f3e 888f 6a2f aea2 352c 64e5", "epel-release-7-11.noarch (@extras)",
+		"/etc/pki/rpm-gpg/RPM-GPG-KEY-EPEL-7"},
+}
+
+func (y yum) GetHelp() string {
+	return ""
+}
+
+func (y yum) Where() string {
+	return "/usr/bin/" + y.name
+}
+
+// yumRepo returns the repository the package is downloaded from
+func yumRepo(name string) string {
+	switch {
+	case epelPkgs[name]:
+		return "epel"
+	case name == "epel-release":
+		return "extras"
+	case strings.HasPrefix(name, "lib") || strings.HasSuffix(name, "-devel"):
+		return "updates"
+	}
+	return "base"
+}
+
+func (y yum) Exec(args []string, sys honeyos.Sys) int {
+	if pkgFamily() != "rpm" {
+		return honeyos.CommandNotFound(sys, append([]string{y.name}, args...))
+	}
+	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	yes := flag.BoolP("assumeyes", "y", false, "answer yes for all questions")
+	quiet := flag.BoolP("quiet", "q", false, "quiet operation")
+	flag.Bool("nogpgcheck", false, "disable gpg signature checking")
+	flag.StringSlice("enablerepo", nil, "enable one or more repositories")
+	flag.StringSlice("disablerepo", nil, "disable repositories by id or glob")
+	if err := flag.Parse(args); err != nil {
+		fmt.Fprintf(sys.Err(), "Usage: %v [options] COMMAND\n\n%v: error: %v\n", y.name, y.name, err)
+		return 1
+	}
+	out := sys.Out()
+	if *quiet {
+		out = ioutil.Discard
+	}
+	if y.name == "yum" {
+		fmt.Fprintln(out, "Loaded plugins: fastestmirror")
+	}
+	if flag.NArg() == 0 {
+		fmt.Fprintf(sys.Err(), "You need to give some command\nusage: %v [options] COMMAND\n", y.name)
+		return 1
+	}
+	sub, names := flag.Arg(0), flag.Args()[1:]
+	switch sub {
+	case "install", "remove", "erase", "update", "upgrade", "makecache", "clean":
+		if !isRoot(sys) {
+			if y.name == "yum" {
+				fmt.Fprintln(sys.Err(), "You need to be root to perform this command.")
+			} else {
+				fmt.Fprintln(sys.Err(), "Error: This command has to be run with superuser privileges (under the root user on most systems).")
+			}
+			return 1
+		}
+	}
+	db := loadPkgDB(sys, "rpm")
+	switch sub {
+	case "install", "reinstall":
+		logPkgRequest(sys, y.name, "install", names)
+		if !y.loadMirrors(out, sys) {
+			return 1
+		}
+		return y.install(names, *yes, db, out, sys)
+	case "remove", "erase":
+		logPkgRequest(sys, y.name, "remove", names)
+		return y.remove(names, *yes, db, out, sys)
+	case "update", "upgrade", "check-update":
+		logPkgRequest(sys, y.name, "update", names)
+		if !y.loadMirrors(out, sys) {
+			return 1
+		}
+		if y.name == "yum" {
+			fmt.Fprintln(out, "No packages marked for update")
+		} else {
+			fmt.Fprintln(out, "Dependencies resolved.\nNothing to do.\nComplete!")
+		}
+		return 0
+	case "makecache":
+		if !y.loadMirrors(out, sys) {
+			return 1
+		}
+		fmt.Fprintln(out, "Metadata Cache Created")
+		return 0
+	case "clean":
+		sys.FSys().Remove(yumMirrorCache)
+		fmt.Fprintln(out, "Cleaning repos: base extras updates\nCleaning up list of fastest mirrors")
+		return 0
+	case "list", "search":
+		if !y.loadMirrors(out, sys) {
+			return 1
+		}
+		return y.list(sub, names, db, out, sys)
+	}
+	if y.name == "yum" {
+		fmt.Fprintf(sys.Err(), "No such command: %v. Please use /usr/bin/yum --help\n", sub)
+	} else {
+		fmt.Fprintf(sys.Err(), "No such command: %v. Please use /usr/bin/dnf --help\nIt could be a DNF plugin command, try: \"dnf install 'dnf-command(%v)'\"\n", sub, sub)
+	}
+	return 1
+}
+
+// loadMirrors prints the mirrors chosen and repository metadata fetched.
+// Only the first run determines the mirrors and downloads the metadata
+func (y yum) loadMirrors(out io.Writer, sys honeyos.Sys) bool {
+	fs := sys.FSys()
+	cached, _ := afero.Exists(fs, yumMirrorCache)
+	if y.name == "dnf" {
+		if !cached {
+			for _, repo := range []struct{ name, size string }{
+				{"CentOS-7 - Base", "6.1 MB"},
+				{"CentOS-7 - Updates", " 18 MB"},
+				{"CentOS-7 - Extras", "249 kB"},
+			} {
+				if !pkgSleep(sys, 400*time.Millisecond) {
+					return false
+				}
+				fmt.Fprintf(out, "%-41v %9v | %v  00:00\n", repo.name, "2.9 MB/s", repo.size)
+			}
+		}
+		fmt.Fprintf(out, "Last metadata expiration check: 0:00:%02d ago on %v.\n", time.Now().Second()%60, time.Now().Format("Mon 02 Jan 2006 03:04:05 PM MST"))
+		y.cacheMirrors(sys)
+		return true
+	}
+	if cached {
+		fmt.Fprintln(out, "Loading mirror speeds from cached hostfile")
+	} else {
+		fmt.Fprintln(out, "Determining fastest mirrors")
+		if !pkgSleep(sys, 800*time.Millisecond) {
+			return false
+		}
+	}
+	fmt.Fprintln(out, " * base: mirror.centos.org\n * extras: mirror.centos.org\n * updates: mirror.centos.org")
+	if cached {
+		return true
+	}
+	for _, repo := range []struct{ name, size string }{
+		{"base", "3.6 kB"}, {"extras", "2.9 kB"}, {"updates", "2.9 kB"},
+		{"(1/4): base/7/x86_64/group_gz", "153 kB"},
+		{"(2/4): extras/7/x86_64/primary_db", "249 kB"},
+		{"(3/4): base/7/x86_64/primary_db", "6.1 MB"},
+		{"(4/4): updates/7/x86_64/primary_db", " 18 MB"},
+	} {
+		if !pkgSleep(sys, 200*time.Millisecond) {
+			return false
+		}
+		fmt.Fprintf(out, "%-57v| %v  00:00:00     \n", repo.name, repo.size)
+	}
+	y.cacheMirrors(sys)
+	return true
+}
+
+func (yum) cacheMirrors(sys honeyos.Sys) {
+	fs := sys.FSys()
+	fs.MkdirAll(path.Dir(yumMirrorCache), 0755)
+	afero.WriteFile(fs, yumMirrorCache, []byte("mirror.centos.org 0.0512 "+fmt.Sprint(time.Now().Unix())+"\n"), 0644)
+}
+
+// yumAvailable returns the package if it can be installed from the enabled
+// repositories
+func yumAvailable(name string, db *pkgDB) (pkgInfo, bool) {
+	if epelPkgs[name] && !db.installed("epel-release") {
+		return pkgInfo{}, false
+	}
+	return lookupPkg("rpm", name)
+}
+
+// yumSize formats the size in kB like yum, e.g. 139 k and 3.9 M
+func yumSize(kb int) string {
+	switch {
+	case kb < 1024:
+		return fmt.Sprintf("%v k", kb)
+	case kb < 10*1024:
+		return fmt.Sprintf("%.1f M", float64(kb)/1024)
+	}
+	return fmt.Sprintf("%.0f M", float64(kb)/1024)
+}
+
+func (y yum) install(names []string, yes bool, db *pkgDB, out io.Writer, sys honeyos.Sys) int {
+	var missing []string
+	var want []string
+	for _, name := range names {
+		p, ok := yumAvailable(name, db)
+		if !ok {
+			missing = append(missing, name)
+			continue
+		}
+		if db.installed(name) {
+			fmt.Fprintf(out, "Package %v-%v.x86_64 already installed and latest version\n", name, db.pkgs[name].version)
+			continue
+		}
+		want = append(want, p.name)
+	}
+	for _, name := range missing {
+		if y.name == "yum" {
+			fmt.Fprintf(out, "No package %v available.\n", name)
+		} else {
+			fmt.Fprintf(out, "No match for argument: %v\n", name)
+		}
+	}
+	if len(missing) > 0 && y.name == "dnf" {
+		fmt.Fprintln(sys.Err(), "Error: Unable to find a match:", strings.Join(missing, " "))
+		return 1
+	}
+	if len(want) == 0 {
+		if y.name == "yum" {
+			fmt.Fprintln(sys.Err(), "Error: Nothing to do")
+			return 1
+		}
+		fmt.Fprintln(out, "Dependencies resolved.\nNothing to do.\nComplete!")
+		return 0
+	}
+	pkgs, _ := resolvePkgs("rpm", want, db)
+	requested := map[string]bool{}
+	for _, name := range want {
+		requested[name] = true
+	}
+	var explicit, deps []pkgInfo
+	for _, p := range pkgs {
+		p.version = pkgVersion(p, "rpm")
+		if requested[p.name] {
+			explicit = append(explicit, p)
+		} else {
+			deps = append(deps, p)
+		}
+	}
+	if y.name == "yum" {
+		fmt.Fprintln(out, "Resolving Dependencies\n--> Running transaction check")
+		for _, p := range explicit {
+			fmt.Fprintf(out, "---> Package %v.x86_64 0:%v will be installed\n", p.name, p.version)
+			for _, d := range deps {
+				fmt.Fprintf(out, "--> Processing Dependency: %v.so.1()(64bit) for package: %v-%v.x86_64\n", d.name, p.name, p.version)
+			}
+		}
+		if len(deps) > 0 {
+			fmt.Fprintln(out, "--> Running transaction check")
+			for _, d := range deps {
+				fmt.Fprintf(out, "---> Package %v.x86_64 0:%v will be installed\n", d.name, d.version)
+			}
+		}
+		fmt.Fprint(out, "--> Finished Dependency Resolution\n\nDependencies Resolved\n\n")
+	} else {
+		fmt.Fprintln(out, "Dependencies resolved.")
+	}
+	total, size := 0, 0
+	for _, p := range pkgs {
+		total += p.size / 3
+		size += p.size
+	}
+	y.transaction(out, "Installing", explicit, deps)
+	count := fmt.Sprintf("%v Package", len(explicit))
+	if len(explicit) > 1 {
+		count += "s"
+	}
+	if len(deps) > 0 {
+		if y.name == "yum" {
+			count += fmt.Sprintf(" (+%v Dependent package", len(deps))
+			if len(deps) > 1 {
+				count += "s"
+			}
+			count += ")"
+		} else {
+			count = fmt.Sprintf("%v Packages", len(pkgs))
+		}
+	}
+	fmt.Fprintf(out, "Install  %v\n\nTotal download size: %v\nInstalled size: %v\n", count, yumSize(total), yumSize(size))
+	if !yes && !y.confirm(sys, out) {
+		return 1
+	}
+	fmt.Fprintln(out, "Downloading packages:")
+	start := time.Now()
+	for i, p := range pkgs {
+		if !pkgSleep(sys, time.Duration(50+p.size/30)*time.Millisecond) {
+			return 1
+		}
+		file := fmt.Sprintf("(%v/%v): %v-%v.x86_64.rpm", i+1, len(pkgs), p.name, pkgVersion(p, "rpm"))
+		fmt.Fprintf(out, "%-57v| %6vB  00:00:00     \n", file, yumSize(p.size/3))
+	}
+	elapsed := time.Since(start)
+	fmt.Fprintln(out, strings.Repeat("-", 80))
+	fmt.Fprintf(out, "%-48v%vB/s | %vB  00:00:%02d     \n", "Total", yumSize(int(float64(total)/elapsed.Seconds()+1)), yumSize(total), int(elapsed.Seconds()))
+	// Each repository asks to import its key on first install from it
+	for _, repo := range []string{"base", "epel"} {
+		key := yumKeys[repo]
+		p, ok := yumFromRepo(pkgs, repo)
+		if !ok || db.installed("gpg-pubkey-"+key.id) {
+			continue
+		}
+		fmt.Fprintf(out, "warning: /var/cache/yum/x86_64/7/%v/packages/%v-%v.x86_64.rpm: Header V3 RSA/SHA256 Signature, key ID %v: NOKEY\n", yumRepo(p.name), p.name, pkgVersion(p, "rpm"), key.id)
+		fmt.Fprintf(out, "Public key for %v-%v.x86_64.rpm is not installed\nRetrieving key from file://%v\n", p.name, pkgVersion(p, "rpm"), key.file)
+		fmt.Fprintf(out, "Importing GPG key 0x%v:\n Userid     : \"%v\"\n Fingerprint: %v\n Package    : %v\n From       : %v\n",
+			strings.ToUpper(key.id), key.user, key.fingerprint, key.pkg, key.file)
+		if !yes {
+			if !pkgConfirm(sys, "Is this ok [y/N]: ", false) {
+				fmt.Fprintf(sys.Err(), "\n\nPublic key for %v-%v.x86_64.rpm is not installed\n", p.name, pkgVersion(p, "rpm"))
+				return 1
+			}
+		}
+		db.pkgs["gpg-pubkey-"+key.id] = pkgInfo{name: "gpg-pubkey-" + key.id, version: key.created, desc: "gpg(" + key.user + ")"}
+	}
+	fmt.Fprintln(out, "Running transaction check\nRunning transaction test\nTransaction test succeeded\nRunning transaction")
+	order := append(append([]pkgInfo{}, deps...), explicit...)
+	for i, p := range order {
+		if !pkgSleep(sys, 80*time.Millisecond) {
+			return 1
+		}
+		db.install(sys, p)
+		fmt.Fprintf(out, "  %-11v: %-59v%v/%v \n", "Installing", p.name+"-"+p.version+".x86_64", i+1, len(order))
+	}
+	for i, p := range order {
+		fmt.Fprintf(out, "  %-11v: %-59v%v/%v \n", "Verifying", p.name+"-"+p.version+".x86_64", i+1, len(order))
+	}
+	db.save(sys)
+	fmt.Fprintln(out, "\nInstalled:")
+	y.summary(out, explicit)
+	if len(deps) > 0 {
+		fmt.Fprintln(out, "Dependency Installed:")
+		y.summary(out, deps)
+	}
+	fmt.Fprintln(out, "Complete!")
+	return 0
+}
+
+// yumFromRepo returns the first package signed by the key of the
+// repository. Packages from base, updates and extras share the CentOS key
+func yumFromRepo(pkgs []pkgInfo, repo string) (pkgInfo, bool) {
+	for _, p := range pkgs {
+		if r := yumRepo(p.name); r == repo || repo == "base" && r != "epel" {
+			return p, true
+		}
+	}
+	return pkgInfo{}, false
+}
+
+// confirm asks to proceed with the transaction
+func (y yum) confirm(sys honeyos.Sys, out io.Writer) bool {
+	question := "Is this ok [y/d/N]: "
+	if y.name == "dnf" {
+		question = "Is this ok [y/N]: "
+	}
+	if pkgConfirm(sys, question, false) {
+		return true
+	}
+	fmt.Fprintln(out, "Exiting on user command")
+	if y.name == "yum" {
+		fmt.Fprintf(out, "Your transaction was saved, rerun it with:\n yum load-transaction /tmp/yum_save_tx.%v.yumtx\n", time.Now().Format("2006-01-02.15-04.")+"Xk3q0a")
+	} else {
+		fmt.Fprintln(out, "Operation aborted.")
+	}
+	return false
+}
+
+// transaction prints the table of packages to be installed or removed
+func (y yum) transaction(out io.Writer, action string, pkgs, deps []pkgInfo) {
+	line := strings.Repeat("=", 80)
+	header := fmt.Sprintf(" %-16v %-12v %-25v %-14v %6v", "Package", "Arch", "Version", "Repository", "Size")
+	if y.name == "dnf" {
+		header = fmt.Sprintf(" %-16v %-12v %-25v %-14v %6v", "Package", "Architecture", "Version", "Repository", "Size")
+	}
+	fmt.Fprintf(out, "%v\n%v\n%v\n%v:\n", line, header, line, action)
+	row := func(p pkgInfo) {
+		repo, size := yumRepo(p.name), yumSize(p.size/3)
+		if action == "Removing" {
+			repo, size = "@"+repo, yumSize(p.size)
+		}
+		fmt.Fprintf(out, " %-16v %-12v %-25v %-14v %6v\n", p.name, "x86_64", p.version, repo, size)
+	}
+	for _, p := range pkgs {
+		row(p)
+	}
+	if len(deps) > 0 {
+		fmt.Fprintf(out, "%v for dependencies:\n", action)
+		for _, p := range deps {
+			row(p)
+		}
+	}
+	fmt.Fprintf(out, "\nTransaction Summary\n%v\n", line)
+}
+
+func (y yum) summary(out io.Writer, pkgs []pkgInfo) {
+	var names []string
+	for _, p := range pkgs {
+		if y.name == "yum" {
+			names = append(names, p.name+".x86_64 0:"+p.version)
+		} else {
+			names = append(names, p.name+"-"+p.version+".x86_64")
+		}
+	}
+	fmt.Fprintf(out, "  %v\n\n", strings.Join(names, "  "))
+}
+
+func (y yum) remove(names []string, yes bool, db *pkgDB, out io.Writer, sys honeyos.Sys) int {
+	var pkgs []pkgInfo
+	size := 0
+	for _, name := range names {
+		p, ok := db.pkgs[name]
+		if !ok {
+			if y.name == "yum" {
+				fmt.Fprintf(out, "No Match for argument: %v\n", name)
+			} else {
+				fmt.Fprintf(out, "No match for argument: %v\n", name)
+			}
+			continue
+		}
+		pkgs = append(pkgs, p)
+		size += p.size
+	}
+	if len(pkgs) == 0 {
+		if y.name == "yum" {
+			fmt.Fprintln(out, "No Packages marked for removal")
+		} else {
+			fmt.Fprintln(sys.Err(), "Error: No packages marked for removal.")
+			return 1
+		}
+		return 0
+	}
+	if y.name == "yum" {
+		fmt.Fprintln(out, "Resolving Dependencies\n--> Running transaction check")
+		for _, p := range pkgs {
+			fmt.Fprintf(out, "---> Package %v.x86_64 0:%v will be erased\n", p.name, p.version)
+		}
+		fmt.Fprint(out, "--> Finished Dependency Resolution\n\nDependencies Resolved\n\n")
+	} else {
+		fmt.Fprintln(out, "Dependencies resolved.")
+	}
+	y.transaction(out, "Removing", pkgs, nil)
+	count := fmt.Sprintf("%v Package", len(pkgs))
+	if len(pkgs) > 1 {
+		count += "s"
+	}
+	fmt.Fprintf(out, "Remove  %v\n\n", count)
+	if y.name == "yum" {
+		fmt.Fprintf(out, "Installed size: %v\n", yumSize(size))
+	} else {
+		fmt.Fprintf(out, "Freed space: %v\n", yumSize(size))
+	}
+	if !yes && !y.confirm(sys, out) {
+		return 1
+	}
+	fmt.Fprintln(out, "Downloading packages:\nRunning transaction check\nRunning transaction test\nTransaction test succeeded\nRunning transaction")
+	for i, p := range pkgs {
+		if !pkgSleep(sys, 80*time.Millisecond) {
+			return 1
+		}
+		db.remove(sys, p.name)
+		fmt.Fprintf(out, "  %-11v: %-59v%v/%v \n", "Erasing", p.name+"-"+p.version+".x86_64", i+1, len(pkgs))
+	}
+	for i, p := range pkgs {
+		fmt.Fprintf(out, "  %-11v: %-59v%v/%v \n", "Verifying", p.name+"-"+p.version+".x86_64", i+1, len(pkgs))
+	}
+	db.save(sys)
+	fmt.Fprintln(out, "\nRemoved:")
+	y.summary(out, pkgs)
+	fmt.Fprintln(out, "Complete!")
+	return 0
+}
+
+func (y yum) list(sub string, patterns []string, db *pkgDB, out io.Writer, sys honeyos.Sys) int {
+	if sub == "search" {
+		if len(patterns) == 0 {
+			fmt.Fprintln(sys.Err(), "Error: Need an item to match")
+			return 1
+		}
+		found := false
+		for _, p := range pkgCatalog {
+			if p.family != "" && p.family != "rpm" {
+				continue
+			}
+			if _, ok := yumAvailable(p.name, db); !ok {
+				continue
+			}
+			for _, pattern := range patterns {
+				if strings.Contains(p.name, pattern) || strings.Contains(strings.ToLower(p.desc), strings.ToLower(pattern)) {
+					if !found {
+						fmt.Fprintf(out, "%v N/S matched: %v %v\n", strings.Repeat("=", 30), strings.Join(patterns, ", "), strings.Repeat("=", 30))
+						found = true
+					}
+					fmt.Fprintf(out, "%v.x86_64 : %v\n", p.name, p.desc)
+					break
+				}
+			}
+		}
+		if !found {
+			fmt.Fprintf(out, "No matches found\n")
+			return 1
+		}
+		return 0
+	}
+	fmt.Fprintln(out, "Installed Packages")
+	for _, name := range db.names() {
+		if strings.HasPrefix(name, "gpg-pubkey-") {
+			continue
+		}
+		fmt.Fprintf(out, "%-40v %-26v %v\n", name+".x86_64", db.pkgs[name].version, "@base")
+	}
+	return 0
+}
+
+func (rpm) GetHelp() string {
+	return ""
+}
+
+func (rpm) Where() string {
+	return "/usr/bin/rpm"
+}
+
+// rpmName is the name of the package with version and architecture, as rpm
+// prints in queries
+func rpmName(p pkgInfo) string {
+	if strings.HasPrefix(p.name, "gpg-pubkey-") {
+		return p.name + "-" + p.version
+	}
+	return p.name + "-" + p.version + ".x86_64"
+}
+
+func (r rpm) Exec(args []string, sys honeyos.Sys) int {
+	if pkgFamily() != "rpm" {
+		return honeyos.CommandNotFound(sys, append([]string{"rpm"}, args...))
+	}
+	flag := pflag.NewFlagSet("arg", pflag.ContinueOnError)
+	flag.SetOutput(ioutil.Discard)
+	query := flag.BoolP("query", "q", false, "")
+	all := flag.BoolP("all", "a", false, "")
+	info := flag.BoolP("info", "i", false, "")
+	list := flag.BoolP("list", "l", false, "")
+	flag.BoolP("verbose", "v", false, "")
+	flag.BoolP("hash", "h", false, "")
+	upgrade := flag.BoolP("upgrade", "U", false, "")
+	flag.Bool("import", false, "")
+	if err := flag.Parse(args); err != nil {
+		fmt.Fprintf(sys.Err(), "rpm: %v\n", err)
+		return 1
+	}
+	db := loadPkgDB(sys, "rpm")
+	switch {
+	case *query && *all:
+		for _, name := range db.names() {
+			fmt.Fprintln(sys.Out(), rpmName(db.pkgs[name]))
+		}
+		return 0
+	case *query:
+		if flag.NArg() == 0 {
+			fmt.Fprintln(sys.Err(), "rpm: no arguments given for query")
+			return 1
+		}
+		res := 0
+		for _, name := range flag.Args() {
+			p, ok := db.pkgs[name]
+			switch {
+			case !ok:
+				fmt.Fprintf(sys.Out(), "package %v is not installed\n", name)
+				res = 1
+			case *info:
+				fmt.Fprintf(sys.Out(), "Name        : %v\nVersion     : %v\nArchitecture: x86_64\nSize        : %v\nSummary     : %v\n",
+					p.name, p.version, p.size*1024, p.desc)
+			case *list:
+				for _, bin := range p.bins {
+					fmt.Fprintln(sys.Out(), bin)
+				}
+				fmt.Fprintf(sys.Out(), "/usr/share/doc/%v-%v\n", p.name, strings.Split(p.version, "-")[0])
+			default:
+				fmt.Fprintln(sys.Out(), rpmName(p))
+			}
+		}
+		return res
+	case *info || *upgrade:
+		logPkgRequest(sys, "rpm", "install", flag.Args())
+		if flag.NArg() == 0 {
+			fmt.Fprintln(sys.Err(), "rpm: no packages given for install")
+			return 1
+		}
+		for _, arg := range flag.Args() {
+			fmt.Fprintf(sys.Err(), "error: open of %v failed: No such file or directory\n", arg)
+		}
+		return 1
+	}
+	fmt.Fprintln(sys.Out(), "RPM version 4.11.3\nCopyright (C) 1998-2002 - Red Hat, Inc.\nThis program may be freely redistributed under the terms of the GNU GPL\n\nUsage: rpm [-aKfgpqVcdLilsiv?] [-a|--all] [-f|--file] [-p|--package] [-i|--install] [-U|--upgrade] [-e|--erase]")
+	return 1
+}
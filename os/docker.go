@@ -0,0 +1,238 @@
+package os
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Container is a docker container on the machine
+type Container struct {
+	// ID is the 64 hex digits of the container, shown as the first 12
+	ID, Name, Image string
+	// Command is the entrypoint and command the container runs, as docker ps
+	// shows it
+	Command string
+	// Ports are the ports published, like 0.0.0.0:80->80/tcp
+	Ports            string
+	Created, Started time.Time
+	Running          bool
+	// ExitCode and Finished are set once the container exited
+	ExitCode int
+	Finished time.Time
+}
+
+// Image is a docker image pulled to the machine
+type Image struct {
+	Repository, Tag, ID string
+	// Command is the default command of the containers run from the image
+	Command string
+	Created time.Time
+	Size    int64
+}
+
+// Ref is the image as repository:tag
+func (img *Image) Ref() string {
+	return img.Repository + ":" + img.Tag
+}
+
+// dockerImages are the images known to the honeypot, by repository, as the
+// size in MB and the command of the latest tag. Others pulled get a made up
+// size and run a shell
+var dockerImages = map[string]struct {
+	size float64
+	cmd  string
+}{
+	"nginx":       {133, "/docker-entrypoint.sh nginx -g 'daemon off;'"},
+	"httpd":       {138, "httpd-foreground"},
+	"mysql":       {448, "docker-entrypoint.sh mysqld"},
+	"mariadb":     {401, "docker-entrypoint.sh mysqld"},
+	"postgres":    {314, "docker-entrypoint.sh postgres"},
+	"redis":       {105, "docker-entrypoint.sh redis-server"},
+	"mongo":       {493, "docker-entrypoint.sh mongod"},
+	"node":        {943, "docker-entrypoint.sh node"},
+	"python":      {886, "python3"},
+	"ubuntu":      {72.8, "bash"},
+	"debian":      {124, "bash"},
+	"centos":      {209, "/bin/bash"},
+	"alpine":      {5.61, "/bin/sh"},
+	"busybox":     {1.24, "sh"},
+	"hello-world": {0.0133, "/hello"},
+}
+
+// defaultContainers are the containers running since boot unless listed in
+// persona.docker.containers, as name, image and ports published
+var defaultContainers = []string{
+	"web nginx:1.21 0.0.0.0:80->80/tcp,0.0.0.0:443->443/tcp",
+	"db mysql:5.7 3306/tcp,33060/tcp",
+	"cache redis:6-alpine 6379/tcp",
+}
+
+// defaultImages are pulled besides those of the containers unless listed in
+// persona.docker.images
+var defaultImages = []string{"ubuntu:18.04", "alpine:latest"}
+
+// DockerID returns the ID of the image or container made from the seed, 64
+// hex digits like sha256 ones
+func DockerID(seed string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(seed)))
+}
+
+// NewImage returns the image of the reference like nginx or nginx:1.21 as
+// if pulled at the time
+func NewImage(ref string, t time.Time) *Image {
+	repo, tag := ref, "latest"
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		repo, tag = ref[:i], ref[i+1:]
+	}
+	id := DockerID("image " + repo + ":" + tag)
+	img := &Image{Repository: repo, Tag: tag, ID: id, Command: "/bin/sh"}
+	// Images are built a few weeks before pulled
+	img.Created = t.Add(-time.Duration(14*24+int(id[0])%30*24) * time.Hour)
+	if known, ok := dockerImages[repo[strings.LastIndex(repo, "/")+1:]]; ok {
+		img.Command, img.Size = known.cmd, int64(known.size*1e6)
+		// Tags like 6-alpine are the smaller variants
+		if strings.Contains(tag, "alpine") && img.Size > 32e6 {
+			img.Size /= 3
+		}
+	} else {
+		img.Size = int64(20+int(id[1])%200) * 1e6
+	}
+	return img
+}
+
+// dockerState is the containers and images of the session. It starts with
+// those of the config, and changes as the user runs and removes them
+type dockerState struct {
+	mu         sync.Mutex
+	containers []*Container
+	images     []*Image
+	loaded     bool
+}
+
+// configDocker returns the containers started at boot and the images they
+// came from, followed by the other images in the config
+func configDocker() ([]*Container, []*Image) {
+	list := defaultContainers
+	if viper.IsSet("persona.docker.containers") {
+		list = viper.GetStringSlice("persona.docker.containers")
+	}
+	var containers []*Container
+	var images []*Image
+	pulled := map[string]*Image{}
+	pull := func(ref string, t time.Time) *Image {
+		img := NewImage(ref, t)
+		if p, ok := pulled[img.Ref()]; ok {
+			return p
+		}
+		pulled[img.Ref()] = img
+		images = append(images, img)
+		return img
+	}
+	for i, entry := range list {
+		fields := strings.Fields(entry)
+		if len(fields) < 2 {
+			continue
+		}
+		created := BootTime().Add(-time.Duration(30+i*9) * 24 * time.Hour)
+		img := pull(fields[1], created.Add(-time.Minute))
+		c := &Container{
+			ID:   DockerID(viper.GetString("server.hostname") + " " + entry),
+			Name: fields[0], Image: fields[1], Command: img.Command,
+			Created: created, Started: BootTime().Add(40 * time.Second), Running: true,
+		}
+		if len(fields) > 2 && fields[2] != "-" {
+			c.Ports = strings.Replace(fields[2], ",", ", ", -1)
+		}
+		containers = append(containers, c)
+	}
+	refs := defaultImages
+	if viper.IsSet("persona.docker.images") {
+		refs = viper.GetStringSlice("persona.docker.images")
+	}
+	for _, ref := range refs {
+		pull(ref, BootTime().Add(-60*24*time.Hour))
+	}
+	return containers, images
+}
+
+// UpdateDocker runs f with the containers and images of the session, newest
+// last, for f to read or change them
+func UpdateDocker(sys Sys, f func(containers *[]*Container, images *[]*Image)) {
+	proc, ok := sys.(*process)
+	if !ok || proc.docker == nil {
+		containers, images := configDocker()
+		f(&containers, &images)
+		return
+	}
+	d := proc.docker
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.loaded {
+		d.containers, d.images = configDocker()
+		d.loaded = true
+	}
+	f(&d.containers, &d.images)
+}
+
+// dockerProxies returns the docker-proxy dockerd runs for each port published
+// by the running containers, with the socket it listens on. There are none
+// if dockerd is not running on the machine
+func (sys *System) dockerProxies() ([]ProcInfo, []SockInfo) {
+	var dockerd *ProcInfo
+	for _, p := range sys.procs.list() {
+		if p.Comm() == "dockerd" {
+			p := p
+			dockerd = &p
+		}
+	}
+	if dockerd == nil || sys.docker == nil {
+		return nil, nil
+	}
+	d := sys.docker
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.loaded {
+		d.containers, d.images = configDocker()
+		d.loaded = true
+	}
+	var procs []ProcInfo
+	var socks []SockInfo
+	for i, c := range d.containers {
+		if !c.Running {
+			continue
+		}
+		for _, port := range strings.Split(c.Ports, ", ") {
+			// Ports exposed but not published like 3306/tcp have no proxy
+			arrow := strings.Index(port, "->")
+			if arrow < 0 {
+				continue
+			}
+			host, hostPort := SplitAddr(port[:arrow])
+			target, proto := port[arrow+2:], "tcp"
+			if j := strings.IndexByte(target, '/'); j >= 0 {
+				target, proto = target[:j], target[j+1:]
+			}
+			p := ProcInfo{
+				PID: dockerd.PID + 20 + len(procs)*11, PPID: dockerd.PID, User: "root", TTY: "?", Stat: "Sl",
+				Start: c.Started, VSZ: 372104, RSS: 3864,
+				Cmd: fmt.Sprintf("/usr/bin/docker-proxy -proto %v -host-ip %v -host-port %v -container-ip 172.17.0.%v -container-port %v",
+					proto, host, hostPort, i+2, target),
+			}
+			s := SockInfo{Proto: proto, Local: port[:arrow], Remote: "0.0.0.0:*", PID: p.PID, FD: 4, Program: p.Cmd, User: "root"}
+			if strings.Contains(host, ":") {
+				s.Proto, s.Remote = proto+"6", ":::*"
+			}
+			if proto == "tcp" {
+				s.State = "LISTEN"
+			}
+			procs = append(procs, p)
+			socks = append(socks, s)
+		}
+	}
+	return procs, socks
+}
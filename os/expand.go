@@ -0,0 +1,381 @@
+package os
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// expandWord performs tilde and parameter expansion on the word, followed
+// by quote removal. If split is true, unquoted result of expansion will be
+// split into multiple fields just like bash does with $IFS, and fields with
+// wildcards are expanded to matching filenames
+func (sh *Shell) expandWord(word string, split bool) []string {
+	var (
+		fields                     []string
+		buf, pattern               bytes.Buffer
+		inField, hasGlob           bool
+		singleQuoted, doubleQuoted bool
+	)
+	// literal writes text that should not be treated as wildcards
+	literal := func(s string) {
+		buf.WriteString(s)
+		for _, r := range s {
+			if strings.ContainsRune(globChars, r) {
+				pattern.WriteByte('\\')
+			}
+			pattern.WriteRune(r)
+		}
+		inField = true
+	}
+	unquoted := func(s string) {
+		buf.WriteString(s)
+		pattern.WriteString(s)
+		hasGlob = hasGlob || strings.ContainsAny(s, "*?[")
+		inField = true
+	}
+	flush := func() {
+		if inField {
+			var matches []string
+			if split && hasGlob {
+				matches = sh.sys.glob(pattern.String())
+			}
+			if len(matches) > 0 {
+				fields = append(fields, matches...)
+			} else {
+				fields = append(fields, buf.String())
+			}
+		}
+		buf.Reset()
+		pattern.Reset()
+		inField, hasGlob = false, false
+	}
+	runes := []rune(sh.expandTilde(word))
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case singleQuoted:
+			if r == '\'' {
+				singleQuoted = false
+			} else {
+				literal(string(r))
+			}
+		case r == '\\' && i+1 < len(runes) && (!doubleQuoted || strings.ContainsRune(dquoteEscapes, runes[i+1])):
+			// Backslash newline is line continuation and removed entirely
+			if i++; runes[i] != '\n' {
+				literal(string(runes[i]))
+			}
+		case r == '$' && !doubleQuoted && i+1 < len(runes) && runes[i+1] == '\'':
+			end := matchANSIQuote(runes, i+1)
+			if end < 0 {
+				end = len(runes)
+			}
+			literal(decodeANSIQuote(string(runes[i+2 : end])))
+			i = end
+		case r == '\'' && !doubleQuoted:
+			singleQuoted = true
+			inField = true
+		case r == '"':
+			doubleQuoted = !doubleQuoted
+			inField = true
+		case r == '$' || r == '`':
+			value, end, ok := sh.expandDollar(runes, i)
+			if !ok {
+				literal(string(r))
+				continue
+			}
+			i = end
+			if doubleQuoted || !split {
+				literal(value)
+				continue
+			}
+			// Field splitting
+			if len(value) > 0 && strings.ContainsRune(" \t\n", rune(value[0])) {
+				flush()
+			}
+			for j, f := range strings.Fields(value) {
+				if j > 0 {
+					flush()
+				}
+				unquoted(f)
+			}
+			if len(value) > 0 && strings.ContainsRune(" \t\n", rune(value[len(value)-1])) {
+				flush()
+			}
+		case doubleQuoted:
+			literal(string(r))
+		default:
+			unquoted(string(r))
+		}
+	}
+	flush()
+	return fields
+}
+
+// expandDollar expands the parameter, command substitution or arithmetic
+// expansion starting at runes[i]. It returns the value and the index where
+// the expansion ends
+func (sh *Shell) expandDollar(runes []rune, i int) (value string, end int, ok bool) {
+	if end = matchSubst(runes, i); runes[i] == '`' && end > 0 {
+		return sh.substitute(backtickUnescaper.Replace(string(runes[i+1 : end]))), end, true
+	} else if end > 0 && runes[i+1] == '(' {
+		inner := runes[i+2 : end]
+		if len(inner) > 1 && inner[0] == '(' && inner[len(inner)-1] == ')' {
+			return sh.arithExpand(string(inner[1 : len(inner)-1])), end, true
+		}
+		return sh.substitute(string(inner)), end, true
+	} else if runes[i] == '$' {
+		if value, n, ok := sh.expandParam(runes[i+1:]); ok {
+			return value, i + n, true
+		}
+	}
+	return "", i, false
+}
+
+// expandHeredoc expands parameters and command substitutions in the body of
+// here-document. Quotes are not special there
+func (sh *Shell) expandHeredoc(body string) string {
+	var buf bytes.Buffer
+	runes := []rune(body)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\' && i+1 < len(runes) && strings.ContainsRune("$`\\\n", runes[i+1]):
+			i++
+			if runes[i] != '\n' {
+				buf.WriteRune(runes[i])
+			}
+		case r == '$' || r == '`':
+			value, end, ok := sh.expandDollar(runes, i)
+			if !ok {
+				buf.WriteRune(r)
+				continue
+			}
+			buf.WriteString(value)
+			i = end
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	return buf.String()
+}
+
+// backtickUnescaper removes the backslashes that only have special meaning
+// inside backticks
+var backtickUnescaper = strings.NewReplacer("\\$", "$", "\\`", "`", "\\\\", "\\")
+
+// substitute runs the command in a subshell and returns its output, with
+// trailing newlines removed
+func (sh *Shell) substitute(cmd string) string {
+	var buf bytes.Buffer
+	sub := sh.subshell(sh.sys, sh.name, sh.args)
+	sub.interactive, sub.lineNo = sh.interactive, sh.lineNo
+	proc := &process{System: sub.sys, in: strings.NewReader(""), out: &buf, err: sh.stderr, shell: sub}
+	sh.status = sub.execLine(cmd, proc)
+	return strings.TrimRight(buf.String(), "\r\n")
+}
+
+// dquoteEscapes are the characters that backslash escapes in double quotes,
+// before other characters the backslash is kept
+const dquoteEscapes = "$`\"\\\n"
+
+// removeQuotes strips the quotes and escapes from the word
+func removeQuotes(word string) string {
+	var buf bytes.Buffer
+	var quote rune
+	runes := []rune(word)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				buf.WriteRune(r)
+			}
+		case r == '\\' && i+1 < len(runes) && (quote == 0 || strings.ContainsRune(dquoteEscapes, runes[i+1])):
+			if i++; runes[i] != '\n' {
+				buf.WriteRune(runes[i])
+			}
+		case r == '$' && quote == 0 && i+1 < len(runes) && runes[i+1] == '\'':
+			end := matchANSIQuote(runes, i+1)
+			if end < 0 {
+				end = len(runes)
+			}
+			buf.WriteString(decodeANSIQuote(string(runes[i+2 : end])))
+			i = end
+		case r == '\'' && quote == 0:
+			quote = r
+		case r == '"':
+			if quote == 0 {
+				quote = r
+			} else {
+				quote = 0
+			}
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	return buf.String()
+}
+
+// decodeANSIQuote decodes the backslash escapes in $'...' quoting, such as
+// \n, \x41 and \101
+func decodeANSIQuote(s string) string {
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			buf.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch c := s[i]; c {
+		case 'a':
+			buf.WriteByte('\a')
+		case 'b':
+			buf.WriteByte('\b')
+		case 'e', 'E':
+			buf.WriteByte(0x1b)
+		case 'f':
+			buf.WriteByte('\f')
+		case 'n':
+			buf.WriteByte('\n')
+		case 'r':
+			buf.WriteByte('\r')
+		case 't':
+			buf.WriteByte('\t')
+		case 'v':
+			buf.WriteByte('\v')
+		case 'x', 'u', 'U', '0', '1', '2', '3', '4', '5', '6', '7':
+			// Hex, unicode and octal escapes
+			base, max := 16, 2
+			switch c {
+			case 'u':
+				max = 4
+			case 'U':
+				max = 8
+			}
+			start, digits := i+1, "0123456789abcdefABCDEF"
+			if c >= '0' && c <= '7' {
+				base, max, start, digits = 8, 3, i, "01234567"
+			}
+			end := start
+			for end < len(s) && end-start < max && strings.IndexByte(digits, s[end]) >= 0 {
+				end++
+			}
+			n, err := strconv.ParseUint(s[start:end], base, 32)
+			if err != nil {
+				buf.WriteString(s[i-1 : end])
+			} else if c == 'u' || c == 'U' {
+				buf.WriteRune(rune(n))
+			} else {
+				buf.WriteByte(byte(n))
+			}
+			i = end - 1
+		default:
+			// \\, \', \" and unknown escapes
+			if c != '\\' && c != '\'' && c != '"' && c != '?' {
+				buf.WriteByte('\\')
+			}
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String()
+}
+
+// expandString expands the word as a single string without field splitting,
+// e.g. for the value of variable assignment
+func (sh *Shell) expandString(word string) string {
+	return strings.Join(sh.expandWord(word, false), "")
+}
+
+// expandParam expands parameter at the beginning of runes, which is the text
+// following '$'. It returns the value and number of runes consumed
+func (sh *Shell) expandParam(runes []rune) (value string, n int, ok bool) {
+	if len(runes) == 0 {
+		return "", 0, false
+	}
+	switch r := runes[0]; {
+	case r == '{':
+		end := -1
+		for i, c := range runes {
+			if c == '}' {
+				end = i
+				break
+			}
+		}
+		if end < 0 {
+			return "", 0, false
+		}
+		return sh.getVar(string(runes[1:end])), end + 1, true
+	case strings.ContainsRune("?!@*$#-", r) || r >= '0' && r <= '9':
+		return sh.getVar(string(r)), 1, true
+	case r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z':
+		n = 1
+		for n < len(runes) && isName(string(runes[:n+1])) {
+			n++
+		}
+		return sh.getVar(string(runes[:n])), n, true
+	}
+	return "", 0, false
+}
+
+// getVar returns value of the variable, including special parameters
+// like $? and positional parameters
+func (sh *Shell) getVar(name string) string {
+	switch name {
+	case "0":
+		return sh.name
+	case "@", "*":
+		return strings.Join(sh.args, " ")
+	case "#":
+		return strconv.Itoa(len(sh.args))
+	case "?":
+		return strconv.Itoa(sh.status)
+	case "$":
+		return strconv.Itoa(sh.pid)
+	case "!":
+		if sh.lastJobPid == 0 {
+			return ""
+		}
+		return strconv.Itoa(sh.lastJobPid)
+	case "-":
+		if sh.interactive {
+			return "himBHs"
+		}
+		return "hB"
+	}
+	if len(name) > 1 && name[0] == '#' {
+		// ${#name} is length of the value
+		return strconv.Itoa(len([]rune(sh.getVar(name[1:]))))
+	}
+	if n, err := strconv.Atoi(name); err == nil && n > 0 {
+		if n <= len(sh.args) {
+			return sh.args[n-1]
+		}
+		return ""
+	}
+	return sh.sys.envVars[name]
+}
+
+func (sh *Shell) setVar(name, value string) {
+	sh.sys.envVars[name] = value
+}
+
+// expandTilde replaces leading ~ of the word with home directory of the user
+func (sh *Shell) expandTilde(word string) string {
+	if !strings.HasPrefix(word, "~") {
+		return word
+	}
+	name := word[1:]
+	rest := ""
+	if i := strings.IndexByte(name, '/'); i >= 0 {
+		name, rest = name[:i], name[i:]
+	}
+	if name == "" {
+		return sh.getVar("HOME") + rest
+	} else if u := GetUser(name); u.Name != "" {
+		return u.Homedir + rest
+	}
+	return word
+}
@@ -0,0 +1,154 @@
+package os
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func newTestShell() *Shell {
+	fs := afero.NewMemMapFs()
+	afero.WriteFile(fs, "/root/a.sh", []byte{}, 0755)
+	afero.WriteFile(fs, "/root/b.sh", []byte{}, 0755)
+	afero.WriteFile(fs, "/root/.hidden.sh", []byte{}, 0755)
+	afero.WriteFile(fs, "/var/log/syslog", []byte{}, 0644)
+	afero.WriteFile(fs, "/var/log/auth.log", []byte{}, 0644)
+	fs.MkdirAll("/var/log/apt", 0755)
+	return &Shell{
+		name:   "-bash",
+		args:   []string{"a", "b c"},
+		pid:    1234,
+		status: 127,
+		sys: &System{
+			fSys: fs,
+			cwd:  "/root",
+			envVars: map[string]string{
+				"HOME": "/root",
+				"PATH": "/usr/bin:/bin",
+				"CMD":  "ls  -l",
+			},
+		},
+	}
+}
+
+func TestExpandWord(t *testing.T) {
+	sh := newTestShell()
+	tests := []struct {
+		word   string
+		expect []string
+	}{
+		{`$HOME`, []string{"/root"}},
+		{`${HOME}/.ssh`, []string{"/root/.ssh"}},
+		{`'$HOME'`, []string{"$HOME"}},
+		{`"$PATH:/sbin"`, []string{"/usr/bin:/bin:/sbin"}},
+		{`\$?`, []string{"$?"}},
+		{`$?`, []string{"127"}},
+		{`$$:$0:$#`, []string{"1234:-bash:2"}},
+		{`$2`, []string{"b", "c"}},
+		{`"$2"`, []string{"b c"}},
+		{`${#HOME}`, []string{"5"}},
+		{`$!`, nil},
+		{`$CMD`, []string{"ls", "-l"}},
+		{`"$CMD"`, []string{"ls  -l"}},
+		{`$NOTHING`, nil},
+		{`""`, []string{""}},
+		{`~/x`, []string{"/root/x"}},
+		{`a$`, []string{"a$"}},
+		{`"a\nb"`, []string{`a\nb`}},
+		{`"a\"b\$c"`, []string{`a"b$c`}},
+		{`'a\b'`, []string{`a\b`}},
+		{`a\ b`, []string{"a b"}},
+		{`$'a\x41\101\tb\'c'`, []string{"aAA\tb'c"}},
+		{`"$'x'"`, []string{"$'x'"}},
+		{`$((1+2))`, []string{"3"}},
+		{`"$((1 + 2*3))"`, []string{"7"}},
+		{`x$(( $# * 10 ))`, []string{"x20"}},
+		{`$((${#HOME}-1))`, []string{"4"}},
+	}
+	for _, test := range tests {
+		if fields := sh.expandWord(test.word, true); !reflect.DeepEqual(fields, test.expect) {
+			t.Errorf("Expanding %v, expect %q, got %q", test.word, test.expect, fields)
+		}
+	}
+}
+
+func TestArith(t *testing.T) {
+	tests := []struct {
+		expr   string
+		expect int64
+		errMsg string
+	}{
+		{"", 0, ""},
+		{"2**10", 1024, ""},
+		{"7/2", 3, ""},
+		{"-7 % 3", -1, ""},
+		{"(1+2)*3", 9, ""},
+		{"1 < 2 && 3 > 2", 1, ""},
+		{"!5 || ~0 == -1", 1, ""},
+		{"1 << 4 | 1", 17, ""},
+		{"0x10 + 010 + 2#101", 29, ""},
+		{"1 ? 2 : 3", 2, ""},
+		{"HOME + N", 0, ""},
+		{"n = 5, n += 2, n * 2", 14, ""},
+		{"i++ + i", 1, ""},
+		{"0 && 1/0", 0, ""},
+		{"1/0", 0, `1/0: division by 0 (error token is "0")`},
+		{"1 +", 0, `1 +: syntax error: operand expected (error token is "+")`},
+		{"1 2", 0, `1 2: syntax error in expression (error token is "2")`},
+		{"(1", 0, "(1: missing `)' (error token is \"1\")"},
+		{"08", 0, `08: value too great for base (error token is "08")`},
+	}
+	for _, test := range tests {
+		sh := newTestShell()
+		n, err := sh.arith(test.expr)
+		errMsg := ""
+		if err != nil {
+			errMsg = err.Error()
+		}
+		if n != test.expect || errMsg != test.errMsg {
+			t.Errorf("Evaluating %q, expect %v %q, got %v %q", test.expr, test.expect, test.errMsg, n, errMsg)
+		}
+	}
+
+	// Assignments are kept in the variables, but not in the branch not taken
+	sh := newTestShell()
+	sh.arith("n = 5, n += 2, 0 && (m = 1)")
+	if n, m := sh.getVar("n"), sh.getVar("m"); n != "7" || m != "" {
+		t.Errorf("Expect n=7 and m unset, got %q, %q", n, m)
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	args, err := SplitArgs(`echo "a b" 'c  d' e\ f $'\x41'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := []string{"echo", "a b", "c  d", "e f", "A"}; !reflect.DeepEqual(args, expect) {
+		t.Errorf("Expect %q, got %q", expect, args)
+	}
+}
+
+func TestExpandGlob(t *testing.T) {
+	sh := newTestShell()
+	tests := []struct {
+		word   string
+		expect []string
+	}{
+		{`*.sh`, []string{"a.sh", "b.sh"}},
+		{`.*.sh`, []string{".hidden.sh"}},
+		{`/var/log/*`, []string{"/var/log/apt", "/var/log/auth.log", "/var/log/syslog"}},
+		{`/var/*/a*`, []string{"/var/log/apt", "/var/log/auth.log"}},
+		{`/var/log/*/`, []string{"/var/log/apt/"}},
+		{`[ab].sh`, []string{"a.sh", "b.sh"}},
+		{`?.sh`, []string{"a.sh", "b.sh"}},
+		{`"*.sh"`, []string{"*.sh"}},
+		{`\*.sh`, []string{"*.sh"}},
+		{`*.txt`, []string{"*.txt"}},
+	}
+	for _, test := range tests {
+		if fields := sh.expandWord(test.word, true); !reflect.DeepEqual(fields, test.expect) {
+			t.Errorf("Expanding %v, expect %q, got %q", test.word, test.expect, fields)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package os
+
+import "sync"
+
+// Chain is a chain of iptables rules
+type Chain struct {
+	Name string
+	// Policy is empty for the chains made by the user
+	Policy string
+	// Rules are as in iptables-save, like -p tcp -m tcp --dport 22 -j ACCEPT
+	Rules []string
+}
+
+// NftTable is a table of nftables, like ip filter
+type NftTable struct {
+	Family, Name string
+	Chains       []*NftChain
+}
+
+// NftChain is a chain of nftables. Base chains hook into the kernel with a
+// type, hook, priority and policy, the others are jumped to
+type NftChain struct {
+	Name               string
+	Type, Hook, Policy string
+	Priority           int
+	Rules              []NftRule
+}
+
+// NftRule is a rule of nftables, its statements as given and the handle
+// numbering it in the table
+type NftRule struct {
+	Handle int
+	Expr   string
+}
+
+// iptablesChains are the builtin chains of the iptables tables
+var iptablesChains = map[string][]string{
+	"filter":   {"INPUT", "FORWARD", "OUTPUT"},
+	"nat":      {"PREROUTING", "INPUT", "OUTPUT", "POSTROUTING"},
+	"mangle":   {"PREROUTING", "INPUT", "FORWARD", "OUTPUT", "POSTROUTING"},
+	"raw":      {"PREROUTING", "OUTPUT"},
+	"security": {"INPUT", "FORWARD", "OUTPUT"},
+}
+
+// firewallLoaders load the rules of the firewall frontends, like ufw and
+// firewalld do at boot
+var firewallLoaders []func(sys Sys, ipt map[string][]*Chain)
+
+// OnFirewallLoad calls f with the iptables tables of the session when they
+// are set up, for the frontends enabled to load their rules. It is meant to
+// be called by init of the commands
+func OnFirewallLoad(f func(sys Sys, ipt map[string][]*Chain)) {
+	firewallLoaders = append(firewallLoaders, f)
+}
+
+// firewall is the packet filter of the session as set up by iptables and nft.
+// It starts with the rules the frontends load, everything accepted if none
+type firewall struct {
+	mu  sync.Mutex
+	ipt map[string][]*Chain
+	nft []*NftTable
+}
+
+// newIPTables returns the iptables tables for IPv4 and IPv6 at boot, by name
+// like filter and ip6 filter
+func newIPTables() map[string][]*Chain {
+	tables := map[string][]*Chain{}
+	for name, chains := range iptablesChains {
+		for _, prefix := range []string{"", "ip6 "} {
+			for _, c := range chains {
+				tables[prefix+name] = append(tables[prefix+name], &Chain{Name: c, Policy: "ACCEPT"})
+			}
+		}
+	}
+	return tables
+}
+
+// loadIPTables returns the iptables tables at boot with the rules of the
+// firewall frontends loaded
+func loadIPTables(sys Sys) map[string][]*Chain {
+	tables := newIPTables()
+	for _, load := range firewallLoaders {
+		load(sys, tables)
+	}
+	return tables
+}
+
+// UpdateFirewall runs f with the iptables tables and the nftables ruleset of
+// the session, for f to read or change them
+func UpdateFirewall(sys Sys, f func(ipt map[string][]*Chain, nft *[]*NftTable)) {
+	proc, ok := sys.(*process)
+	if !ok || proc.firewall == nil {
+		var nft []*NftTable
+		f(loadIPTables(sys), &nft)
+		return
+	}
+	fw := proc.firewall
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	if fw.ipt == nil {
+		fw.ipt = loadIPTables(sys)
+	}
+	f(fw.ipt, &fw.nft)
+}
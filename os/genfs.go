@@ -0,0 +1,151 @@
+package os
+
+import (
+	"io/ioutil"
+	"os"
+	pathlib "path"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/afero/mem"
+)
+
+// generators make the content of the files which change with time or the
+// session, from what the file has on disk, at the moment they are read
+var generators = map[string]func(sys Sys, data []byte) []byte{
+	"/proc/mounts":  func(sys Sys, _ []byte) []byte { return []byte(MountsFile(sys.Mounts())) },
+	"/etc/mtab":     func(sys Sys, _ []byte) []byte { return []byte(MountsFile(sys.Mounts())) },
+	"/proc/uptime":  func(Sys, []byte) []byte { return []byte(UptimeFile()) },
+	"/proc/loadavg": func(sys Sys, _ []byte) []byte { return []byte(LoadAvgFile(sys)) },
+}
+
+// RegisterFile makes the file read as what gen returns, for files the kernel
+// makes up or which follow the state of the system. gen is given the content
+// on disk, and only called for files which exist
+func RegisterFile(name string, gen func(sys Sys, data []byte) []byte) {
+	generators[name] = gen
+}
+
+// genFs serves the files of generators as the session of sys sees them, or
+// as the system booted if there is no session, like for sftp. They have the
+// size of the content, so scp and tar send all of it
+type genFs struct {
+	afero.Fs
+	sys Sys
+}
+
+// genInfo is the file info of generated files, with the size of the content
+type genInfo struct {
+	os.FileInfo
+	size int64
+}
+
+func (fi genInfo) Size() int64 { return fi.size }
+
+func (fi genInfo) Nlink() int { return Nlink(fi.FileInfo) }
+
+func (fi genInfo) times() (atime, ctime time.Time) {
+	if t, ok := fi.FileInfo.(interface{ times() (time.Time, time.Time) }); ok {
+		return t.times()
+	}
+	return time.Time{}, time.Time{}
+}
+
+// genFile is the generated file opened, with the owner and mode on disk
+type genFile struct {
+	*mem.File
+	fi os.FileInfo
+}
+
+func (f genFile) Stat() (os.FileInfo, error) { return f.fi, nil }
+
+// genDir is a directory, listing the generated files with their size
+type genDir struct {
+	afero.File
+	fs   genFs
+	name string
+}
+
+func (d genDir) Readdir(n int) ([]os.FileInfo, error) {
+	list, err := d.File.Readdir(n)
+	for i, fi := range list {
+		list[i] = d.fs.info(pathlib.Join(d.name, fi.Name()), fi)
+	}
+	return list, err
+}
+
+func (g genFs) system() Sys {
+	if g.sys == nil {
+		return &System{procs: newProcTable()}
+	}
+	return g.sys
+}
+
+// generate returns the content of the file made by its generator
+func (g genFs) generate(name string, gen func(Sys, []byte) []byte) ([]byte, error) {
+	data, err := afero.ReadFile(g.Fs, name)
+	if err != nil {
+		return nil, err
+	}
+	return gen(g.system(), data), nil
+}
+
+// info returns the file info with the size of the generated content
+func (g genFs) info(name string, fi os.FileInfo) os.FileInfo {
+	name = pathlib.Clean(name)
+	gen, ok := generators[name]
+	if !ok || fi.IsDir() || fi.Mode()&os.ModeSymlink != 0 {
+		return fi
+	}
+	data, err := g.generate(name, gen)
+	if err != nil {
+		return fi
+	}
+	return genInfo{fi, int64(len(data))}
+}
+
+func (g genFs) Stat(name string) (os.FileInfo, error) {
+	fi, err := g.Fs.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	return g.info(name, fi), nil
+}
+
+func (g genFs) Open(name string) (afero.File, error) {
+	return g.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// OpenFile generates the files opened for reading. Those written are left
+// to the filesystem under
+func (g genFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if flag != os.O_RDONLY {
+		return g.Fs.OpenFile(name, flag, perm)
+	}
+	f, err := g.Fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return f, nil
+	}
+	name = pathlib.Clean(name)
+	if fi.IsDir() {
+		return genDir{f, g, name}, nil
+	}
+	gen, ok := generators[name]
+	if !ok {
+		return f, nil
+	}
+	data, err := ioutil.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	data = gen(g.system(), data)
+	file := mem.NewFileHandle(mem.CreateFile(name))
+	file.Write(data)
+	file.Seek(0, 0)
+	return genFile{file, genInfo{fi, int64(len(data))}}, nil
+}
@@ -0,0 +1,45 @@
+package os
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestGenFs(t *testing.T) {
+	persona := NewPersonaFs(afero.NewMemMapFs(), "test")
+	sys := &System{fSys: NewOwnerFs(persona), procs: newProcTable(), mounts: &mountTable{}}
+	Mount(&process{System: sys}, MountInfo{Device: "/dev/sdb1", Dir: "/mnt", Type: "ext4", Options: "rw"})
+	for _, fs := range []afero.Fs{persona, sys.FSys()} {
+		sizes := map[string]int64{}
+		list, err := afero.ReadDir(fs, "/proc")
+		if err != nil {
+			t.Fatalf("Listing /proc, got %v", err)
+		}
+		for _, fi := range list {
+			sizes[fi.Name()] = fi.Size()
+		}
+		for _, name := range []string{"/proc/mounts", "/etc/mtab", "/proc/loadavg", "/proc/version"} {
+			data, err := afero.ReadFile(fs, name)
+			if err != nil || len(data) == 0 {
+				t.Errorf("Reading %v, expect the content generated, got %q, %v", name, data, err)
+				continue
+			}
+			if fi, _ := fs.Stat(name); fi == nil || fi.Size() != int64(len(data)) {
+				t.Errorf("Stat of %v, expect size %v, got %v", name, len(data), fi)
+			}
+			if size, ok := sizes[strings.TrimPrefix(name, "/proc/")]; strings.HasPrefix(name, "/proc/") && (!ok || size != int64(len(data))) {
+				t.Errorf("Listing of %v, expect size %v, got %v", name, len(data), size)
+			}
+		}
+	}
+
+	// The session sees its own mounts, sftp those at boot
+	if data, _ := afero.ReadFile(sys.FSys(), "/proc/mounts"); !strings.Contains(string(data), "/dev/sdb1 /mnt ext4 rw 0 0\n") {
+		t.Errorf("Expect the mount of the session in /proc/mounts, got %q", data)
+	}
+	if data, _ := afero.ReadFile(persona, "/proc/mounts"); strings.Contains(string(data), "/dev/sdb1 /mnt") {
+		t.Errorf("Expect only the mounts at boot without session, got %q", data)
+	}
+}
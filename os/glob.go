@@ -0,0 +1,107 @@
+package os
+
+import (
+	pathlib "path"
+	"sort"
+	"strings"
+)
+
+const globChars = `*?[]\`
+
+// glob returns files in the virtual filesystem matching the pattern. Relative
+// patterns are resolved against current directory and the matches are
+// returned relative to it, as bash does
+func (sys *System) glob(pattern string) []string {
+	if !hasGlobMeta(pattern) {
+		return nil
+	}
+	comps := strings.Split(pattern, "/")
+	matches := []string{""}
+	if strings.HasPrefix(pattern, "/") {
+		matches = []string{"/"}
+		comps = comps[1:]
+	}
+	for i, comp := range comps {
+		var next []string
+		for _, dir := range matches {
+			switch {
+			case comp == "" && i == len(comps)-1:
+				// Trailing slash only matches directories
+				if fi, err := sys.fSys.Stat(sys.globPath(dir)); err == nil && fi.IsDir() {
+					next = append(next, dir+"/")
+				}
+			case comp == "":
+				next = append(next, dir)
+			case !hasGlobMeta(comp):
+				p := globJoin(dir, globUnescape(comp))
+				if _, err := sys.fSys.Stat(sys.globPath(p)); err == nil {
+					next = append(next, p)
+				}
+			default:
+				f, err := sys.fSys.Open(sys.globPath(dir))
+				if err != nil {
+					continue
+				}
+				names, err := f.Readdirnames(-1)
+				f.Close()
+				if err != nil {
+					continue
+				}
+				sort.Strings(names)
+				for _, name := range names {
+					// Hidden files need to be matched explicitly
+					if strings.HasPrefix(name, ".") && !strings.HasPrefix(comp, ".") {
+						continue
+					}
+					if matched, _ := pathlib.Match(comp, name); matched {
+						next = append(next, globJoin(dir, name))
+					}
+				}
+			}
+		}
+		matches = next
+	}
+	return matches
+}
+
+func (sys *System) globPath(p string) string {
+	if p == "" {
+		return sys.cwd
+	} else if !pathlib.IsAbs(p) {
+		return pathlib.Join(sys.cwd, p)
+	}
+	return p
+}
+
+func globJoin(dir, name string) string {
+	if dir == "" {
+		return name
+	} else if strings.HasSuffix(dir, "/") {
+		return dir + name
+	}
+	return dir + "/" + name
+}
+
+// hasGlobMeta checks if there are unescaped wildcards in the pattern
+func hasGlobMeta(pattern string) bool {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '*', '?', '[':
+			return true
+		}
+	}
+	return false
+}
+
+func globUnescape(s string) string {
+	var buf []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		buf = append(buf, s[i])
+	}
+	return string(buf)
+}
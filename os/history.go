@@ -0,0 +1,124 @@
+package os
+
+import (
+	"fmt"
+	pathlib "path"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// builtinHistory lists and edits the command history of the session. Clearing
+// and deleting entries are logged as warning since attackers do so to cover
+// their tracks, the log still has every command anyway
+func builtinHistory(sh *Shell, args []string, proc *process) int {
+	if sh.terminal == nil || !sh.interactive {
+		// History is disabled for scripts and bash -c
+		return 0
+	}
+	usage := func() int {
+		fmt.Fprintln(proc.Err(), "history: usage: history [-c] [-d offset] [n] or history -anrw [filename] or history -ps arg [arg...]")
+		return 2
+	}
+	args = args[1:]
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	if len(args) == 0 || !strings.HasPrefix(args[0], "-") {
+		hist := sh.terminal.History()
+		start := 0
+		if len(args) > 0 {
+			n, err := strconv.Atoi(args[0])
+			if err != nil || n < 0 {
+				sh.errorf(proc, "history: %v: numeric argument required", args[0])
+				return 1
+			}
+			if n < len(hist) {
+				start = len(hist) - n
+			}
+		}
+		for i := start; i < len(hist); i++ {
+			fmt.Fprintf(proc.Out(), "%5d  %v\n", i+1, hist[i])
+		}
+		return 0
+	}
+
+	file := sh.getVar("HISTFILE")
+	if file == "" {
+		file = sh.getVar("HOME") + "/.bash_history"
+	}
+	if len(args) > 1 {
+		file = args[1]
+	}
+	if !pathlib.IsAbs(file) {
+		file = pathlib.Join(sh.sys.Getcwd(), file)
+	}
+	switch opt := args[0]; opt {
+	case "-c":
+		sh.log.WithField("history", sh.terminal.History()).Warnf("User cleared shell history")
+		sh.terminal.ClearHistory()
+	case "-d":
+		if len(args) < 2 {
+			sh.errorf(proc, "history: -d: option requires an argument")
+			return usage()
+		}
+		hist := sh.terminal.History()
+		n, err := strconv.Atoi(args[1])
+		if n < 0 {
+			// Negative offset counts back from the end like bash 5
+			n += len(hist) + 1
+		}
+		if err != nil || n < 1 || n > len(hist) {
+			sh.errorf(proc, "history: %v: history position out of range", args[1])
+			return 1
+		}
+		sh.log.WithField("entry", hist[n-1]).Warnf("User deleted shell history entry %v", n)
+		sh.terminal.ClearHistory()
+		for i, line := range hist {
+			if i != n-1 {
+				sh.terminal.AddHistory(line)
+			}
+		}
+	case "-s":
+		// The history command itself is replaced by the arguments, if it
+		// was saved at all
+		if hist := sh.terminal.History(); sh.histAdded && len(hist) > 0 {
+			sh.terminal.ClearHistory()
+			for _, line := range hist[:len(hist)-1] {
+				sh.terminal.AddHistory(line)
+			}
+			sh.histAdded = false
+		}
+		if len(args) > 1 {
+			sh.terminal.AddHistory(strings.Join(args[1:], " "))
+		}
+	case "-p":
+		for _, arg := range args[1:] {
+			fmt.Fprintln(proc.Out(), arg)
+		}
+	case "-w", "-a":
+		content := strings.Join(sh.terminal.History(), "\n")
+		if content != "" {
+			content += "\n"
+		}
+		if err := afero.WriteFile(sh.sys.FSys(), file, []byte(content), 0600); err != nil {
+			sh.errorf(proc, "history: %v: cannot create: Permission denied", file)
+			return 1
+		}
+	case "-r", "-n":
+		data, err := afero.ReadFile(sh.sys.FSys(), file)
+		if err != nil {
+			return 1
+		}
+		for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+			if line != "" {
+				sh.terminal.AddHistory(line)
+			}
+		}
+	default:
+		sh.errorf(proc, "history: %v: invalid option", opt)
+		return usage()
+	}
+	return 0
+}
@@ -0,0 +1,279 @@
+package os
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// lastPid is the pid given to the most recently started process
+var lastPid = int32(1000 + rand.New(rand.NewSource(time.Now().UnixNano())).Intn(3000))
+
+// newPid allocates a pid for a new process
+func newPid() int {
+	return int(atomic.AddInt32(&lastPid, 1))
+}
+
+// job is a pipeline running in background
+type job struct {
+	id     int
+	pid    int
+	cmd    string
+	done   chan struct{}
+	status int
+	// signal is the signal that killed the job, or 0
+	signal int
+	cancel context.CancelFunc
+}
+
+func (j *job) finished() bool {
+	select {
+	case <-j.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// state returns job status the way bash shows it in job list
+func (j *job) state() string {
+	switch {
+	case !j.finished():
+		return "Running"
+	case j.signal != 0:
+		if desc, ok := signalDescs[j.signal]; ok {
+			return desc
+		}
+		return "SIG" + SignalName(j.signal)
+	case j.status == 0:
+		return "Done"
+	}
+	return "Exit " + strconv.Itoa(j.status)
+}
+
+// startJob runs the pipeline in background. Like bash it is run in a
+// subshell, and reads nothing from the terminal
+func (sh *Shell) startJob(pl *pipeline, parent *process) {
+	id := 1
+	if len(sh.jobs) > 0 {
+		id = sh.jobs[len(sh.jobs)-1].id + 1
+	}
+	j := &job{
+		id:   id,
+		pid:  newPid(),
+		cmd:  pl.String(),
+		done: make(chan struct{}),
+	}
+	sh.jobs = append(sh.jobs, j)
+	sh.lastJobPid = j.pid
+	sh.log.WithField("cmd", j.cmd).Infof("Job %v started in background", j.id)
+	if sh.interactive {
+		fmt.Fprintf(parent.Err(), "[%v] %v\n", j.id, j.pid)
+	}
+
+	sub := sh.subshell(sh.sys, sh.name, sh.args)
+	sub.interactive = sh.interactive
+	sub.lineNo = sh.lineNo
+	ctx, cancel := context.WithCancel(context.Background())
+	j.cancel = cancel
+	proc := &process{System: sub.sys, in: strings.NewReader(""), out: parent.out, err: parent.err, shell: sub, ctx: ctx,
+		pid: j.pid, background: true}
+	go func() {
+		defer cancel()
+		defer close(j.done)
+		defer func() {
+			if r := recover(); r != nil {
+				sh.log.Errorf("Recovered from panic in background job %v", r)
+				j.status = 1
+			}
+		}()
+		j.status = sub.execPipeline(pl, proc)
+	}()
+}
+
+// reportJobs prints the jobs finished since last checked and removes them
+// from job list, just like bash does before showing the prompt
+func (sh *Shell) reportJobs() {
+	for i := 0; i < len(sh.jobs); i++ {
+		if j := sh.jobs[i]; j.finished() {
+			fmt.Fprint(sh.stderr, sh.formatJob(j, i, false))
+			sh.removeJob(j)
+			i--
+		}
+	}
+}
+
+// formatJob formats the job like "[1]+  Running    sleep 10 &". i is the
+// position of the job in job list, to decide if it is current job
+func (sh *Shell) formatJob(j *job, i int, showPid bool) string {
+	mark := " "
+	if i == len(sh.jobs)-1 {
+		mark = "+"
+	} else if i == len(sh.jobs)-2 {
+		mark = "-"
+	}
+	cmd := j.cmd
+	if !j.finished() {
+		cmd += " &"
+	}
+	if showPid {
+		return fmt.Sprintf("[%v]%v %v %-24v%v\n", j.id, mark, j.pid, j.state(), cmd)
+	}
+	return fmt.Sprintf("[%v]%v  %-24v%v\n", j.id, mark, j.state(), cmd)
+}
+
+// hangup ends the session like the terminal closing does. The jobs are
+// killed by SIGHUP, while the processes ignoring it are left to init
+func (sh *Shell) hangup() {
+	for _, p := range sh.sys.procs.hangup() {
+		logger := sh.log.WithField("pid", p.PID).WithField("cmd", p.Cmd)
+		if kind := ProcessKind(p.Cmd); kind != "" {
+			logger.Warnf("User left %v process %v running after logout", kind, p.Comm())
+		} else {
+			logger.Infof("User left %v running after logout", p.Comm())
+		}
+	}
+	for _, j := range sh.jobs {
+		if !j.finished() {
+			j.signal = 1
+			j.cancel()
+		}
+	}
+}
+
+func (sh *Shell) removeJob(j *job) {
+	for i := range sh.jobs {
+		if sh.jobs[i] == j {
+			sh.jobs = append(sh.jobs[:i], sh.jobs[i+1:]...)
+			return
+		}
+	}
+}
+
+// findJob looks up job by job spec like %1, %+, %- or just the number.
+// Without the spec, current job is returned
+func (sh *Shell) findJob(spec string) *job {
+	if len(sh.jobs) == 0 {
+		return nil
+	}
+	spec = strings.TrimPrefix(spec, "%")
+	switch spec {
+	case "", "+", "%":
+		return sh.jobs[len(sh.jobs)-1]
+	case "-":
+		if len(sh.jobs) > 1 {
+			return sh.jobs[len(sh.jobs)-2]
+		}
+		return sh.jobs[0]
+	}
+	for _, j := range sh.jobs {
+		if strconv.Itoa(j.id) == spec || strings.HasPrefix(j.cmd, spec) {
+			return j
+		}
+	}
+	return nil
+}
+
+func builtinJobs(sh *Shell, args []string, proc *process) int {
+	showPid := len(args) > 1 && args[1] == "-l"
+	var finished []*job
+	for i, j := range sh.jobs {
+		fmt.Fprint(proc.Out(), sh.formatJob(j, i, showPid))
+		if j.finished() {
+			finished = append(finished, j)
+		}
+	}
+	for _, j := range finished {
+		sh.removeJob(j)
+	}
+	return 0
+}
+
+func builtinFg(sh *Shell, args []string, proc *process) int {
+	spec := ""
+	if len(args) > 1 {
+		spec = args[1]
+	}
+	j := sh.findJob(spec)
+	if j == nil {
+		if spec == "" {
+			spec = "current"
+		}
+		sh.errorf(proc, "%v: %v: no such job", args[0], spec)
+		return 1
+	}
+	fmt.Fprintln(proc.Out(), j.cmd)
+	// Interrupting foreground job kills it
+	select {
+	case <-j.done:
+	case <-proc.Context().Done():
+		j.cancel()
+		<-j.done
+	}
+	sh.removeJob(j)
+	return j.status
+}
+
+func builtinBg(sh *Shell, args []string, proc *process) int {
+	spec := ""
+	if len(args) > 1 {
+		spec = args[1]
+	}
+	j := sh.findJob(spec)
+	if j == nil {
+		if spec == "" {
+			spec = "current"
+		}
+		sh.errorf(proc, "%v: %v: no such job", args[0], spec)
+		return 1
+	}
+	// Jobs can't be stopped, so they are always running in background
+	sh.errorf(proc, "%v: job %v already in background", args[0], j.id)
+	return 0
+}
+
+func builtinWait(sh *Shell, args []string, proc *process) int {
+	status := 0
+	if len(args) == 1 {
+		for _, j := range sh.jobs {
+			select {
+			case <-j.done:
+			case <-proc.Context().Done():
+				return 130
+			}
+		}
+		return 0
+	}
+	for _, spec := range args[1:] {
+		var found *job
+		if strings.HasPrefix(spec, "%") {
+			found = sh.findJob(spec)
+		} else {
+			for _, j := range sh.jobs {
+				if strconv.Itoa(j.pid) == spec {
+					found = j
+				}
+			}
+		}
+		if found == nil && strings.HasPrefix(spec, "%") {
+			sh.errorf(proc, "%v: %v: no such job", args[0], spec)
+			status = 127
+			continue
+		} else if found == nil {
+			sh.errorf(proc, "%v: pid %v is not a child of this shell", args[0], spec)
+			status = 127
+			continue
+		}
+		select {
+		case <-found.done:
+			status = found.status
+		case <-proc.Context().Done():
+			return 130
+		}
+	}
+	return status
+}
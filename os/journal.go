@@ -0,0 +1,60 @@
+package os
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// journalHeaderSize is the size of the header of systemd journal files
+const journalHeaderSize = 240
+
+// MachineID is the ID of /etc/machine-id, made up by the host
+func MachineID() string {
+	return fmt.Sprintf("%x", md5.Sum([]byte(viper.GetString("server.hostname")+IPAddress())))
+}
+
+// BootID is the random ID the kernel gives the boot
+func BootID() string {
+	return fmt.Sprintf("%x", md5.Sum([]byte(viper.GetString("server.hostname")+IPAddress()+BootTime().String())))
+}
+
+// JournalPath is the journal systemd-journald keeps in /run, as the
+// journal is not made persistent by creating /var/log/journal
+func JournalPath() string {
+	return "/run/log/journal/" + MachineID() + "/system.journal"
+}
+
+// EncodeJournal writes the header of the journal file holding the entries
+// from head until tail. The entries themselves are made up when read
+func EncodeJournal(head, tail time.Time) []byte {
+	b := make([]byte, journalHeaderSize)
+	copy(b, "LPKSHHRH")
+	// Online, as journald has it open
+	b[16] = 1
+	id := func(s string) []byte {
+		out, _ := hex.DecodeString(s)
+		return out
+	}
+	copy(b[24:], id(fmt.Sprintf("%x", md5.Sum([]byte(MachineID()+head.String())))))
+	copy(b[40:], id(MachineID()))
+	copy(b[56:], id(BootID()))
+	binary.LittleEndian.PutUint64(b[88:], journalHeaderSize)
+	binary.LittleEndian.PutUint64(b[184:], uint64(head.UnixNano()/1000))
+	binary.LittleEndian.PutUint64(b[192:], uint64(tail.UnixNano()/1000))
+	return b
+}
+
+// ParseJournal reads the time of the first entry from the header of the
+// journal file, false if it is not one
+func ParseJournal(data []byte) (head time.Time, ok bool) {
+	if len(data) < journalHeaderSize || string(data[:8]) != "LPKSHHRH" {
+		return time.Time{}, false
+	}
+	usec := int64(binary.LittleEndian.Uint64(data[184:]))
+	return time.Unix(usec/1e6, usec%1e6*1000), true
+}
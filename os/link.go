@@ -0,0 +1,511 @@
+package os
+
+import (
+	"hash/fnv"
+	"os"
+	pathlib "path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mkishere/sshsyrup/virtualfs"
+	"github.com/spf13/afero"
+)
+
+// maxSymlinks is how many links are followed in a name before ELOOP, the
+// same as Linux
+const maxSymlinks = 40
+
+// fileLink is a symbolic link made by users, which the filesystems under
+// can't keep. An empty file holds its place in the directory
+type fileLink struct {
+	target string
+	mtime  time.Time
+}
+
+// symlinkInfo is the file info of a symbolic link, as lstat(2) gives
+type symlinkInfo struct {
+	name string
+	link fileLink
+	meta fileMeta
+}
+
+func (fi symlinkInfo) Name() string       { return fi.name }
+func (fi symlinkInfo) Size() int64        { return int64(len(fi.link.target)) }
+func (fi symlinkInfo) Mode() os.FileMode  { return os.ModeSymlink | 0777 }
+func (fi symlinkInfo) ModTime() time.Time { return fi.link.mtime }
+func (fi symlinkInfo) IsDir() bool        { return false }
+
+func (fi symlinkInfo) Sys() interface{} {
+	return fileOwner{fi.meta.uid, fi.meta.gid}
+}
+
+// linkedInfo is the file found by another name, through a symbolic link or
+// as one of its hard links
+type linkedInfo struct {
+	os.FileInfo
+	name, data string
+	nlink      int
+}
+
+func (fi linkedInfo) Name() string { return fi.name }
+
+func (fi linkedInfo) Nlink() int { return fi.nlink }
+
+func (fi linkedInfo) times() (atime, ctime time.Time) {
+	if t, ok := fi.FileInfo.(interface{ times() (time.Time, time.Time) }); ok {
+		return t.times()
+	}
+	return time.Time{}, time.Time{}
+}
+
+func (fi symlinkInfo) times() (atime, ctime time.Time) { return fi.meta.atime, fi.meta.ctime }
+
+// Nlink is the number of hard links of the file
+func Nlink(fi os.FileInfo) int {
+	if l, ok := fi.(interface{ Nlink() int }); ok {
+		return l.Nlink()
+	}
+	return 1
+}
+
+// Inode makes up the inode number of the file found at the path, which is
+// the same across listings and for all hard links of the file
+func Inode(name string, fi os.FileInfo) uint64 {
+	if l, ok := fi.(linkedInfo); ok {
+		name = l.data
+	}
+	name = pathlib.Clean(name)
+	if name == "/" {
+		return 2
+	}
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return h.Sum64()%4000000 + 12
+}
+
+// link returns the symbolic link at the path if there is one
+func (o *ownerFs) link(p string) (fileLink, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	l, ok := o.links[p]
+	return l, ok
+}
+
+// resolve follows the symbolic links in the name to the file it is of, and
+// the last one too if follow is set. Hard links made by users are resolved
+// to the file holding the content
+func (o *ownerFs) resolve(name string, follow bool) (string, error) {
+	name = pathlib.Clean(name)
+	o.mu.RLock()
+	none := len(o.links) == 0 && len(o.hardLinks) == 0
+	o.mu.RUnlock()
+	if none || !pathlib.IsAbs(name) {
+		return name, nil
+	}
+	hops := 0
+walk:
+	p := "/"
+	parts := strings.Split(strings.TrimPrefix(name, "/"), "/")
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+		next := pathlib.Join(p, part)
+		last := i == len(parts)-1
+		if l, ok := o.link(next); ok && (follow || !last) {
+			if hops++; hops > maxSymlinks {
+				return "", &os.PathError{Op: "stat", Path: name, Err: syscall.ELOOP}
+			}
+			target := l.target
+			if !pathlib.IsAbs(target) {
+				target = pathlib.Join(p, target)
+			}
+			name = pathlib.Join(append([]string{target}, parts[i+1:]...)...)
+			goto walk
+		}
+		p = next
+	}
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	if _, ok := o.links[p]; !ok {
+		if data, ok := o.hardLinks[p]; ok {
+			return data, nil
+		}
+	}
+	return p, nil
+}
+
+// resolveDir resolves the directory of the name, for calls acting on the
+// name itself like unlink(2)
+func (o *ownerFs) resolveDir(name string) (string, error) {
+	name = pathlib.Clean(name)
+	dir, err := o.resolve(pathlib.Dir(name), true)
+	if err != nil {
+		return "", err
+	}
+	return pathlib.Join(dir, pathlib.Base(name)), nil
+}
+
+// nlink counts the names of the file holding the content at p
+func (o *ownerFs) nlink(p string) int {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	n := 1
+	for _, data := range o.hardLinks {
+		if data == p {
+			n++
+		}
+	}
+	return n
+}
+
+// linked gives the info of the file resolved as p by name
+func (o *ownerFs) linked(name, p string, fi os.FileInfo) os.FileInfo {
+	n := o.nlink(p)
+	if n == 1 && pathlib.Clean(name) == p {
+		return fi
+	}
+	return linkedInfo{fi, pathlib.Base(pathlib.Clean(name)), p, n}
+}
+
+// entry is the info of the file listed in a directory, which for links is
+// the link or the file it links to
+func (o *ownerFs) entry(p string, fi os.FileInfo) os.FileInfo {
+	o.mu.RLock()
+	l, isLink := o.links[p]
+	data, isHard := o.hardLinks[p]
+	m := o.meta[p]
+	o.mu.RUnlock()
+	switch {
+	case isLink:
+		return symlinkInfo{pathlib.Base(p), l, m}
+	case isHard:
+		if dfi, err := o.Fs.Stat(data); err == nil {
+			return o.linked(p, data, o.info(data, dfi))
+		}
+	}
+	return o.linked(p, p, o.info(p, fi))
+}
+
+// Lstat is Stat without following the symbolic link at the end of the name
+func (o *ownerFs) Lstat(name string) (os.FileInfo, error) {
+	p, err := o.resolveDir(name)
+	if err != nil {
+		return nil, err
+	}
+	if o.hidden(p) {
+		return nil, notExist("lstat", name)
+	}
+	if l, ok := o.link(p); ok {
+		o.mu.RLock()
+		defer o.mu.RUnlock()
+		return symlinkInfo{pathlib.Base(p), l, o.meta[p]}, nil
+	}
+	return o.Stat(p)
+}
+
+// Readlink returns the target of the symbolic link. Links of the image
+// don't keep their targets
+func (o *ownerFs) Readlink(name string) (string, error) {
+	p, err := o.resolveDir(name)
+	if err != nil {
+		return "", err
+	}
+	if l, ok := o.link(p); ok {
+		return l.target, nil
+	}
+	if _, err := o.Stat(p); err != nil {
+		return "", &os.PathError{Op: "readlink", Path: name, Err: os.ErrNotExist}
+	}
+	return "", &os.PathError{Op: "readlink", Path: name, Err: syscall.EINVAL}
+}
+
+// placeholder makes the empty file standing for the link in its directory
+func (o *ownerFs) placeholder(op, p string) error {
+	if _, err := o.Lstat(p); err == nil {
+		return &os.LinkError{Op: op, Old: p, New: p, Err: os.ErrExist}
+	}
+	f, err := o.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	o.forget(p)
+	return f.Close()
+}
+
+// Symlink makes newname a symbolic link to oldname, which may not exist
+func (o *ownerFs) Symlink(oldname, newname string) error {
+	p, err := o.resolveDir(newname)
+	if err != nil {
+		return err
+	}
+	if err := o.placeholder("symlink", p); err != nil {
+		if le, ok := err.(*os.LinkError); ok {
+			le.Old, le.New = oldname, newname
+		}
+		return err
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.links[p] = fileLink{oldname, time.Now()}
+	return nil
+}
+
+// Link makes newname another name of the file oldname. A hard link to a
+// symbolic link is another link to the same target
+func (o *ownerFs) Link(oldname, newname string) error {
+	src, err := o.resolveDir(oldname)
+	if err != nil {
+		return err
+	}
+	fi, err := o.Lstat(src)
+	if err != nil {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: os.ErrNotExist}
+	}
+	if fi.IsDir() {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: syscall.EPERM}
+	}
+	if l, ok := o.link(src); ok {
+		return o.Symlink(l.target, newname)
+	}
+	if src, err = o.resolve(src, false); err != nil {
+		return err
+	}
+	dst, err := o.resolveDir(newname)
+	if err != nil {
+		return err
+	}
+	if err := o.placeholder("link", dst); err != nil {
+		if le, ok := err.(*os.LinkError); ok {
+			le.Old, le.New = oldname, newname
+		}
+		return err
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.hardLinks[dst] = src
+	m := o.meta[src]
+	m.ctime = time.Now()
+	o.meta[src] = m
+	return nil
+}
+
+// Lchown changes the owner of the symbolic link rather than its target
+func (o *ownerFs) Lchown(name string, uid, gid int) error {
+	p, err := o.resolveDir(name)
+	if err != nil {
+		return err
+	}
+	if _, ok := o.link(p); !ok {
+		return o.Chown(p, uid, gid)
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	m := o.meta[p]
+	m.uid, m.gid, m.hasOwner = uid, gid, true
+	o.meta[p] = m
+	return nil
+}
+
+// unlink drops the link at p if it is one, telling if it was. The content
+// of a file with other hard links moves to one of them
+func (o *ownerFs) unlink(p string) (bool, error) {
+	o.mu.Lock()
+	if _, ok := o.links[p]; ok {
+		delete(o.links, p)
+		o.mu.Unlock()
+		return true, o.dropPlaceholder(p)
+	}
+	if _, ok := o.hardLinks[p]; ok {
+		delete(o.hardLinks, p)
+		o.mu.Unlock()
+		return true, o.dropPlaceholder(p)
+	}
+	heir := ""
+	for alias, data := range o.hardLinks {
+		if data == p && (heir == "" || alias < heir) {
+			heir = alias
+		}
+	}
+	o.mu.Unlock()
+	if heir == "" {
+		return false, nil
+	}
+	// The content moves to the heir, which the other names link to
+	if err := o.dropPlaceholder(heir); err != nil {
+		return true, err
+	}
+	o.mu.Lock()
+	delete(o.hardLinks, heir)
+	o.mu.Unlock()
+	if err := o.Rename(p, heir); err != nil {
+		return true, err
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for alias, data := range o.hardLinks {
+		if data == p {
+			o.hardLinks[alias] = heir
+		}
+	}
+	return true, nil
+}
+
+func (o *ownerFs) dropPlaceholder(p string) error {
+	if err := o.Fs.Remove(p); err != nil && err != syscall.EPERM && !os.IsNotExist(err) {
+		return err
+	}
+	o.hide(p)
+	o.forget(p)
+	return nil
+}
+
+// moveLinks moves the links at or under oldname to newname, and the hard
+// links to files there
+func (o *ownerFs) moveLinks(oldname, newname string) {
+	under := func(p string) bool {
+		return p == oldname || strings.HasPrefix(p, oldname+"/")
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for p, l := range o.links {
+		if under(p) {
+			delete(o.links, p)
+			o.links[newname+p[len(oldname):]] = l
+		}
+	}
+	moved := map[string]string{}
+	for alias, data := range o.hardLinks {
+		if under(data) {
+			data = newname + data[len(oldname):]
+		}
+		if under(alias) {
+			delete(o.hardLinks, alias)
+			alias = newname + alias[len(oldname):]
+		}
+		moved[alias] = data
+	}
+	for alias, data := range moved {
+		o.hardLinks[alias] = data
+	}
+}
+
+// dropLinks forgets the links at or under the name removed
+func (o *ownerFs) dropLinks(name string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for p := range o.links {
+		if p == name || strings.HasPrefix(p, name+"/") {
+			delete(o.links, p)
+		}
+	}
+	for alias := range o.hardLinks {
+		if alias == name || strings.HasPrefix(alias, name+"/") {
+			delete(o.hardLinks, alias)
+		}
+	}
+}
+
+// linker is the filesystem which can make links
+type linker interface {
+	Lstat(name string) (os.FileInfo, error)
+	Readlink(name string) (string, error)
+	Symlink(oldname, newname string) error
+	Link(oldname, newname string) error
+	Lchown(name string, uid, gid int) error
+}
+
+func notSupported(op, name string) error {
+	return &os.PathError{Op: op, Path: name, Err: syscall.EOPNOTSUPP}
+}
+
+// Lstat returns the info of the file without following a symbolic link at
+// the end of its name
+func Lstat(fs afero.Fs, name string) (os.FileInfo, error) {
+	if l, ok := fs.(linker); ok {
+		return l.Lstat(name)
+	}
+	return fs.Stat(name)
+}
+
+// Readlink returns the target of the symbolic link
+func Readlink(fs afero.Fs, name string) (string, error) {
+	if l, ok := fs.(linker); ok {
+		return l.Readlink(name)
+	}
+	return "", notSupported("readlink", name)
+}
+
+// Symlink makes newname a symbolic link to oldname
+func Symlink(fs afero.Fs, oldname, newname string) error {
+	if l, ok := fs.(linker); ok {
+		return l.Symlink(oldname, newname)
+	}
+	return notSupported("symlink", newname)
+}
+
+// Link makes newname a hard link to oldname
+func Link(fs afero.Fs, oldname, newname string) error {
+	if l, ok := fs.(linker); ok {
+		return l.Link(oldname, newname)
+	}
+	return notSupported("link", newname)
+}
+
+// Lchown changes the owner of the symbolic link itself
+func Lchown(fs afero.Fs, name string, uid, gid int) error {
+	if l, ok := fs.(linker); ok {
+		return l.Lchown(name, uid, gid)
+	}
+	return Chown(fs, name, uid, gid)
+}
+
+func (u userFs) Lstat(name string) (os.FileInfo, error) {
+	fi, err := Lstat(u.Fs, name)
+	if err != nil {
+		return nil, err
+	}
+	return genFs{u.Fs, u.sys}.info(name, fi), nil
+}
+
+func (u userFs) Readlink(name string) (string, error) {
+	return Readlink(u.Fs, name)
+}
+
+func (u userFs) Symlink(oldname, newname string) error {
+	if !u.writableDir(pathlib.Clean(newname)) {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: os.ErrPermission}
+	}
+	if err := Symlink(u.Fs, oldname, newname); err != nil {
+		return err
+	}
+	return Lchown(u.Fs, newname, u.sys.CurrentUser(), u.sys.CurrentGroup())
+}
+
+// Link needs the file to be readable, as Linux protects hard links to files
+// of others
+func (u userFs) Link(oldname, newname string) error {
+	if !u.writableDir(pathlib.Clean(newname)) || !Access(u.sys, oldname, 4) {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: os.ErrPermission}
+	}
+	return Link(u.Fs, oldname, newname)
+}
+
+// Lchown of a symbolic link follows the rules of Chown for the link
+func (u userFs) Lchown(name string, uid, gid int) error {
+	fi, err := Lstat(u.Fs, name)
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSymlink == 0 {
+		return u.Chown(name, uid, gid)
+	}
+	if user := u.sys.CurrentUser(); user != 0 {
+		owner, group, _, _ := virtualfs.GetExtraInfo(fi)
+		if owner != user || uid != owner || gid != group && gid != u.sys.CurrentGroup() {
+			return &os.PathError{Op: "lchown", Path: name, Err: os.ErrPermission}
+		}
+	}
+	return Lchown(u.Fs, name, uid, gid)
+}
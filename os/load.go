@@ -0,0 +1,91 @@
+package os
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// loadTick is how often the kernel samples the run queue for load average
+const loadTick = 5 * time.Second
+
+// load is the load average of the machine, shared by the sessions so
+// uptime, top, w and /proc/loadavg agree
+var load = struct {
+	mu   sync.Mutex
+	last time.Time
+	avg  [3]float64
+}{last: time.Now().Add(-30 * time.Minute), avg: [3]float64{0.04, 0.05, 0.05}}
+
+// Uptime returns how long the machine has been up. It does not follow the
+// clock set by date, like the monotonic clock of the kernel
+func Uptime() time.Duration { return time.Since(BootTime()) }
+
+// LoadAvg returns the load average of the last 1, 5 and 15 minutes. It
+// decays towards the number of runnable processes like the kernel does:
+// an almost idle machine, plus the miners and other busy processes the
+// session starts
+func LoadAvg(sys Sys) (l1, l5, l15 float64) {
+	var busy []time.Time
+	for _, p := range sys.Processes() {
+		if ProcessKind(p.Cmd) == "miner" || strings.HasPrefix(p.Stat, "R") && !isLoadReader(p.Comm()) {
+			busy = append(busy, p.Start)
+		}
+	}
+	load.mu.Lock()
+	defer load.mu.Unlock()
+	now := time.Now()
+	ticks := int(now.Sub(load.last) / loadTick)
+	// Ticks from long ago no longer matter
+	if ticks > 360 {
+		load.last = load.last.Add(time.Duration(ticks-360) * loadTick)
+		ticks = 360
+	}
+	for i := 0; i < ticks; i++ {
+		load.last = load.last.Add(loadTick)
+		// Now and then a daemon wakes up
+		h := fnv.New32a()
+		h.Write([]byte(fmt.Sprint(load.last.Unix())))
+		n := 0.0
+		if h.Sum32()%20 == 0 {
+			n = 1
+		}
+		for _, start := range busy {
+			if start.Before(load.last) {
+				n++
+			}
+		}
+		for j, period := range []float64{60, 300, 900} {
+			e := math.Exp(-loadTick.Seconds() / period)
+			load.avg[j] = load.avg[j]*e + n*(1-e)
+		}
+	}
+	return load.avg[0], load.avg[1], load.avg[2]
+}
+
+// isLoadReader tells if the command is one reading the load, which is not
+// counted as it only runs for the moment it reads
+func isLoadReader(comm string) bool {
+	switch comm {
+	case "ps", "top", "htop", "uptime", "w", "cat":
+		return true
+	}
+	return false
+}
+
+// LoadAvgFile returns the content of /proc/loadavg
+func LoadAvgFile(sys Sys) string {
+	l1, l5, l15 := LoadAvg(sys)
+	return fmt.Sprintf("%.2f %.2f %.2f 1/%v %v\n", l1, l5, l15, len(sys.Processes()), atomic.LoadInt32(&lastPid))
+}
+
+// UptimeFile returns the content of /proc/uptime, the uptime and the time
+// the CPU has been idle
+func UptimeFile() string {
+	up := Uptime().Seconds()
+	return fmt.Sprintf("%.2f %.2f\n", up, up*0.97)
+}
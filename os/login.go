@@ -0,0 +1,136 @@
+package os
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+)
+
+// loginRecord is a session in the login history, like an entry in wtmp
+type loginRecord struct {
+	User string    `json:"user"`
+	Host string    `json:"host"`
+	TTY  string    `json:"tty"`
+	Time time.Time `json:"time"`
+	// pid is of the sshd of the session, for utmp
+	pid int
+}
+
+var loginHistoryLock sync.Mutex
+
+// loginHistory reads the sessions recorded in server.loginHistory, oldest
+// first
+func loginHistory() []loginRecord {
+	loginHistoryLock.Lock()
+	defer loginHistoryLock.Unlock()
+	f, err := os.Open(viper.GetString("server.loginHistory"))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var records []loginRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r loginRecord
+		if json.Unmarshal(scanner.Bytes(), &r) == nil {
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+// recordLogin appends the session to the login history
+func recordLogin(r loginRecord) error {
+	file := viper.GetString("server.loginHistory")
+	if file == "" {
+		return nil
+	}
+	loginHistoryLock.Lock()
+	defer loginHistoryLock.Unlock()
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(b, '\n'))
+	return err
+}
+
+// login does what sshd and bash do when user logs in: print /etc/motd and
+// the last login, then run the profile scripts
+func (sh *Shell) login() {
+	proc := sh.newProcess()
+	user := GetUserByID(sh.sys.CurrentUser()).Name
+	if motd, err := afero.ReadFile(sh.sys.FSys(), "/etc/motd"); err == nil {
+		proc.Out().Write(motd)
+	}
+	var last *loginRecord
+	history := loginHistory()
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].User == user {
+			last = &history[i]
+			break
+		}
+	}
+	if last != nil {
+		fmt.Fprintf(proc.Out(), "Last login: %v from %v\n", last.Time.Format("Mon Jan _2 15:04:05 2006"), last.Host)
+	}
+	host := sh.src
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	record := loginRecord{User: user, Host: host, TTY: "pts/0", Time: time.Now(), pid: sh.sshdPid}
+	sh.sys.login = &record
+	if err := recordLogin(record); err != nil {
+		sh.log.WithError(err).Error("Cannot record login history")
+	}
+	sh.runProfile(proc)
+}
+
+// runProfile runs the startup files of login shell
+func (sh *Shell) runProfile(proc *process) {
+	// Login shell reads /etc/profile, then the first of the user profiles
+	sh.runStartupFile("/etc/profile", proc)
+	home := sh.getVar("HOME")
+	profile := ""
+	for _, name := range []string{".bash_profile", ".bash_login", ".profile"} {
+		if script, found := sh.runStartupFile(home+"/"+name, proc); found {
+			profile = script
+			break
+		}
+	}
+	// Stock profile from /etc/skel sources .bashrc, but the image may not
+	// have the profile or its content
+	if !strings.Contains(profile, ".bashrc") {
+		sh.runStartupFile(home+"/.bashrc", proc)
+	}
+}
+
+// runStartupFile runs the script in current shell like source does. found
+// is false if the file does not exist
+func (sh *Shell) runStartupFile(p string, proc *process) (script string, found bool) {
+	script, err := sh.readScript(p)
+	if err != nil {
+		return "", false
+	}
+	if strings.TrimSpace(script) == "" {
+		return script, true
+	}
+	sh.log.WithField("path", p).Infof("Running startup file %v", p)
+	sh.sourcing = p
+	sh.runScript(script, proc)
+	sh.sourcing, sh.lineNo = "", 0
+	return script, true
+}
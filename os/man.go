@@ -0,0 +1,106 @@
+package os
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// manPages are the compressed manual pages shipped with the server, by
+	// the path they are installed at, e.g. /usr/share/man/man1/ls.1.gz
+	manPages = map[string]string{}
+	// manOnce makes the pages of the commands once, after all are registered
+	manOnce  sync.Once
+	manFiles map[string]string
+)
+
+// RegisterManPages installs the manual pages in dir, which is laid out in
+// sections like man1/ls.1.gz, under /usr/share/man
+func RegisterManPages(dir string) error {
+	sections, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, section := range sections {
+		if !section.IsDir() || !strings.HasPrefix(section.Name(), "man") {
+			continue
+		}
+		pages, err := ioutil.ReadDir(path.Join(dir, section.Name()))
+		if err != nil {
+			return err
+		}
+		for _, page := range pages {
+			content, err := ioutil.ReadFile(path.Join(dir, section.Name(), page.Name()))
+			if err != nil {
+				return err
+			}
+			manPages[path.Join("/usr/share/man", section.Name(), page.Name())] = string(content)
+		}
+	}
+	return nil
+}
+
+// manualFiles returns the manual pages to install. Commands without a page
+// shipped get one made from their help, like help2man does for many
+// packages
+func manualFiles() map[string]string {
+	manOnce.Do(func() {
+		manFiles = make(map[string]string, len(funcMap))
+		for p, content := range manPages {
+			manFiles[p] = content
+		}
+		shipped := map[string]bool{}
+		for p := range manPages {
+			name := strings.TrimSuffix(path.Base(p), ".gz")
+			shipped[strings.TrimSuffix(name, path.Ext(name))] = true
+		}
+		for name, cmd := range funcMap {
+			help := cmd.GetHelp()
+			if strings.Contains(name, "/") || shipped[name] || strings.TrimSpace(help) == "" {
+				continue
+			}
+			section, manual := "1", "User Commands"
+			if strings.HasSuffix(path.Dir(cmd.Where()), "sbin") {
+				section, manual = "8", "System Administration Utilities"
+			}
+			manFiles[fmt.Sprintf("/usr/share/man/man%v/%v.%v.gz", section, name, section)] =
+				compressPage(helpPage(name, section, manual, help))
+		}
+	})
+	return manFiles
+}
+
+// helpPage writes the page of the command from its help in roff
+func helpPage(name, section, manual, help string) string {
+	var b strings.Builder
+	date := BootTime().AddDate(0, -5, 0).Format("January 2006")
+	upper := strings.ToUpper(name)
+	fmt.Fprintf(&b, ".\\\" DO NOT MODIFY THIS FILE!  It was generated by help2man 1.47.3.\n")
+	fmt.Fprintf(&b, ".TH %v \"%v\" \"%v\" \"%v\" \"%v\"\n", upper, section, date, name, manual)
+	fmt.Fprintf(&b, ".SH NAME\n%v \\- manual page for %v\n", name, name)
+	fmt.Fprintf(&b, ".SH DESCRIPTION\n.nf\n")
+	for _, line := range strings.Split(strings.Trim(help, "\n"), "\n") {
+		line = strings.Replace(strings.Replace(line, "\\", "\\e", -1), "-", "\\-", -1)
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			line = "\\&" + line
+		}
+		b.WriteString(line + "\n")
+	}
+	b.WriteString(".fi\n")
+	return b.String()
+}
+
+func compressPage(page string) string {
+	var buf bytes.Buffer
+	w, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	w.ModTime = time.Time{}
+	w.Write([]byte(page))
+	w.Close()
+	return buf.String()
+}
@@ -0,0 +1,234 @@
+package os
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// MountInfo is the filesystem mounted on the machine, shown in df and mount
+type MountInfo struct {
+	Device, Dir, Type, Options string
+	// Size is the size in bytes, 0 for pseudo filesystems like proc
+	Size int64
+}
+
+// tmpfs is sized by the memory like the kernel and systemd do
+var (
+	shmSize = fmt.Sprintf("%vK", MemTotal/2)
+	runSize = fmt.Sprintf("%vK", MemTotal/10)
+	devSize = fmt.Sprintf("%vK", MemTotal/2-16400)
+)
+
+// mountTables are the filesystems mounted, by distribution, as device,
+// mount point, type, size and options. They can be changed with
+// persona.mounts in the config
+var mountTables = map[string][]string{
+	"ubuntu": {
+		"sysfs /sys sysfs 0 rw,nosuid,nodev,noexec,relatime",
+		"proc /proc proc 0 rw,nosuid,nodev,noexec,relatime",
+		"udev /dev devtmpfs " + devSize + " rw,nosuid,relatime,size=" + strings.ToLower(devSize) + ",nr_inodes=251105,mode=755",
+		"devpts /dev/pts devpts 0 rw,nosuid,noexec,relatime,gid=5,mode=620,ptmxmode=000",
+		"tmpfs /run tmpfs " + runSize + " rw,nosuid,noexec,relatime,size=" + strings.ToLower(runSize) + ",mode=755",
+		"/dev/sda1 / ext4 40G rw,relatime,errors=remount-ro,data=ordered",
+		"securityfs /sys/kernel/security securityfs 0 rw,nosuid,nodev,noexec,relatime",
+		"tmpfs /dev/shm tmpfs " + shmSize + " rw,nosuid,nodev",
+		"tmpfs /run/lock tmpfs 5M rw,nosuid,nodev,noexec,relatime,size=5120k",
+		"tmpfs /sys/fs/cgroup tmpfs " + shmSize + " ro,nosuid,nodev,noexec,mode=755",
+		"cgroup /sys/fs/cgroup/systemd cgroup 0 rw,nosuid,nodev,noexec,relatime,xattr,release_agent=/lib/systemd/systemd-cgroups-agent,name=systemd",
+		"tmpfs /run/user/0 tmpfs " + runSize + " rw,nosuid,nodev,relatime,size=" + strings.ToLower(runSize) + ",mode=700",
+	},
+	"debian": {
+		"sysfs /sys sysfs 0 rw,nosuid,nodev,noexec,relatime",
+		"proc /proc proc 0 rw,nosuid,nodev,noexec,relatime",
+		"udev /dev devtmpfs " + devSize + " rw,nosuid,relatime,size=" + strings.ToLower(devSize) + ",nr_inodes=251105,mode=755",
+		"devpts /dev/pts devpts 0 rw,nosuid,noexec,relatime,gid=5,mode=620,ptmxmode=000",
+		"tmpfs /run tmpfs " + runSize + " rw,nosuid,noexec,relatime,size=" + strings.ToLower(runSize) + ",mode=755",
+		"/dev/vda1 / ext4 20G rw,relatime,errors=remount-ro,data=ordered",
+		"tmpfs /dev/shm tmpfs " + shmSize + " rw,nosuid,nodev",
+		"tmpfs /run/lock tmpfs 5M rw,nosuid,nodev,noexec,relatime,size=5120k",
+		"tmpfs /sys/fs/cgroup tmpfs " + shmSize + " ro,nosuid,nodev,noexec,mode=755",
+	},
+	"centos": {
+		"sysfs /sys sysfs 0 rw,nosuid,nodev,noexec,relatime,seclabel",
+		"proc /proc proc 0 rw,nosuid,nodev,noexec,relatime",
+		"devtmpfs /dev devtmpfs " + devSize + " rw,nosuid,seclabel,size=" + strings.ToLower(devSize) + ",nr_inodes=251105,mode=755",
+		"securityfs /sys/kernel/security securityfs 0 rw,nosuid,nodev,noexec,relatime",
+		"tmpfs /dev/shm tmpfs " + shmSize + " rw,nosuid,nodev,seclabel",
+		"devpts /dev/pts devpts 0 rw,nosuid,noexec,relatime,seclabel,gid=5,mode=620,ptmxmode=000",
+		"tmpfs /run tmpfs " + shmSize + " rw,nosuid,nodev,seclabel,mode=755",
+		"tmpfs /sys/fs/cgroup tmpfs " + shmSize + " ro,nosuid,nodev,noexec,seclabel,mode=755",
+		"/dev/mapper/centos-root / xfs 50G rw,relatime,seclabel,attr2,inode64,noquota",
+		"/dev/sda1 /boot xfs 1014M rw,relatime,seclabel,attr2,inode64,noquota",
+		"tmpfs /run/user/0 tmpfs " + runSize + " rw,nosuid,nodev,relatime,seclabel,size=" + strings.ToLower(runSize) + ",mode=700",
+	},
+	"alpine": {
+		"/dev/vda3 / ext4 19G rw,relatime",
+		"devtmpfs /dev devtmpfs 10M rw,nosuid,noexec,relatime,size=10240k,nr_inodes=251105,mode=755",
+		"proc /proc proc 0 rw,nosuid,nodev,noexec,relatime",
+		"sysfs /sys sysfs 0 rw,nosuid,nodev,noexec,relatime",
+		"devpts /dev/pts devpts 0 rw,nosuid,noexec,relatime,gid=5,mode=620,ptmxmode=000",
+		"shm /dev/shm tmpfs " + shmSize + " rw,nosuid,nodev,noexec,relatime",
+		"tmpfs /run tmpfs " + runSize + " rw,nosuid,nodev,size=" + strings.ToLower(runSize) + ",nr_inodes=819200,mode=755",
+		"/dev/vda1 /boot ext4 92M rw,relatime",
+	},
+}
+
+// ParseSize parses the size like 40G or 512K, in powers of 1024
+func ParseSize(s string) int64 {
+	mult := int64(1)
+	if i := strings.IndexAny(s, "KMGTkmgt"); i > 0 {
+		unit := strings.IndexByte("KMGT", strings.ToUpper(s[i : i+1])[0])
+		mult = 1 << (10 * uint(unit+1))
+		s = s[:i]
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return int64(n * float64(mult))
+}
+
+// configMounts returns the filesystems mounted at boot, in the order they
+// were mounted
+func configMounts() []MountInfo {
+	list := viper.GetStringSlice("persona.mounts")
+	if len(list) == 0 {
+		if list = mountTables[Distro()]; list == nil {
+			list = mountTables["ubuntu"]
+		}
+	}
+	var mounts []MountInfo
+	for _, entry := range list {
+		fields := strings.Fields(entry)
+		if len(fields) < 3 {
+			continue
+		}
+		m := MountInfo{Device: fields[0], Dir: fields[1], Type: fields[2], Options: "rw,relatime"}
+		if len(fields) > 3 {
+			m.Size = ParseSize(fields[3])
+		}
+		if len(fields) > 4 {
+			m.Options = fields[4]
+		}
+		mounts = append(mounts, m)
+	}
+	return mounts
+}
+
+// mountTable is the filesystems mounted in the session. It starts with those
+// mounted at boot, and changes as the user mounts and unmounts
+type mountTable struct {
+	mu     sync.Mutex
+	mounts []MountInfo
+	loaded bool
+}
+
+// Mounts returns the filesystems mounted, in the order they were mounted
+func (sys *System) Mounts() []MountInfo {
+	if sys.mounts == nil {
+		return configMounts()
+	}
+	t := sys.mounts
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.loaded {
+		t.mounts, t.loaded = configMounts(), true
+	}
+	return append([]MountInfo(nil), t.mounts...)
+}
+
+// Mount adds the filesystem to the mount table of the session
+func Mount(sys Sys, m MountInfo) {
+	proc, ok := sys.(*process)
+	if !ok || proc.mounts == nil {
+		return
+	}
+	proc.Mounts()
+	proc.mounts.mu.Lock()
+	defer proc.mounts.mu.Unlock()
+	proc.mounts.mounts = append(proc.mounts.mounts, m)
+}
+
+// Unmount removes the filesystem last mounted on the mount point or from the
+// device given. It returns false if there is no such mount
+func Unmount(sys Sys, target string) bool {
+	proc, ok := sys.(*process)
+	if !ok || proc.mounts == nil {
+		return false
+	}
+	proc.Mounts()
+	t := proc.mounts
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := len(t.mounts) - 1; i >= 0; i-- {
+		if t.mounts[i].Dir == target || t.mounts[i].Device == target {
+			t.mounts = append(t.mounts[:i], t.mounts[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// MountsFile formats the mount table like /proc/mounts
+func MountsFile(mounts []MountInfo) string {
+	var b strings.Builder
+	for _, m := range mounts {
+		fmt.Fprintf(&b, "%v %v %v %v 0 0\n", m.Device, m.Dir, m.Type, m.Options)
+	}
+	return b.String()
+}
+
+// fstab is /etc/fstab listing the disks mounted at boot, by UUID except the
+// LVM volumes
+func fstab() string {
+	var b strings.Builder
+	switch Distro() {
+	case "centos":
+		b.WriteString("\n#\n# /etc/fstab\n# Created by anaconda on Tue Nov 10 08:42:17 2020\n#\n" +
+			"# Accessible filesystems, by reference, are maintained under '/dev/disk'\n" +
+			"# See man pages fstab(5), findfs(8), mount(8) and/or blkid(8) for more info\n#\n")
+	case "alpine":
+	default:
+		b.WriteString("# /etc/fstab: static file system information.\n#\n" +
+			"# Use 'blkid' to print the universally unique identifier for a\n" +
+			"# device; this may be used with UUID= as a more robust way to name devices\n" +
+			"# that works even if disks are added and removed. See fstab(5).\n#\n" +
+			"# <file system> <mount point>   <type>  <options>       <dump>  <pass>\n")
+	}
+	for _, m := range configMounts() {
+		if !strings.HasPrefix(m.Device, "/dev/") {
+			continue
+		}
+		dev, opts, pass := m.Device, "defaults", 2
+		if !strings.HasPrefix(dev, "/dev/mapper/") {
+			dev = "UUID=" + DiskUUID(dev)
+		}
+		if m.Dir == "/" {
+			pass = 1
+		}
+		switch {
+		case Distro() == "centos":
+			pass = 0
+		case m.Dir == "/" && strings.HasPrefix(m.Type, "ext") && Distro() != "alpine":
+			opts = "errors=remount-ro"
+		case Distro() == "alpine":
+			opts = "rw,relatime"
+		}
+		fmt.Fprintf(&b, "%-41v %-15v %-7v %-15v 0 %v\n", dev, m.Dir, m.Type, opts, pass)
+	}
+	return b.String()
+}
+
+// DiskUUID makes up the UUID of the filesystem on the device
+func DiskUUID(dev string) string {
+	h := fnv.New128a()
+	fmt.Fprintf(h, "%v%v", dev, IPAddress())
+	id := h.Sum(nil)
+	id[6], id[8] = id[6]&0x0f|0x40, id[8]&0x3f|0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", id[:4], id[4:6], id[6:8], id[8:10], id[10:16])
+}
@@ -0,0 +1,362 @@
+package os
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mkishere/sshsyrup/util/terminal"
+)
+
+// maxScrollback limits the output kept of the window while detached
+const maxScrollback = 64 << 10
+
+// Multiplexer is a session of terminal multiplexer like screen and tmux. Its
+// window is a shell of its own, which keeps running what is started in it
+// after the user detaches
+type Multiplexer struct {
+	// Tool is screen or tmux
+	Tool, Name string
+	// PID is the server process of the session
+	PID      int
+	Created  time.Time
+	Attached bool
+	// Cmd is the command the window runs instead of the shell
+	Cmd string
+
+	sh    *Shell
+	state *muxState
+	// cmd is Cmd until it is started, which ends the session once it exits
+	cmd string
+	// mu guards the keys sent to the window. busy is set while the window is
+	// running something, and the lines sent meanwhile wait in queue
+	mu      sync.Mutex
+	busy    bool
+	pending string
+	queue   []string
+	// detach is set when told to detach from inside the window
+	detach bool
+	output scrollback
+	ctx    context.Context
+	cancel context.CancelFunc
+	ended  sync.Once
+	done   chan struct{}
+}
+
+// muxState is the multiplexer sessions of the client
+type muxState struct {
+	mu   sync.Mutex
+	list []*Multiplexer
+}
+
+// scrollback keeps the latest output of the window, and shows it live to the
+// terminal while attached
+type scrollback struct {
+	mu   sync.Mutex
+	buf  []byte
+	live io.Writer
+}
+
+func (s *scrollback) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.live != nil {
+		s.live.Write(p)
+	}
+	s.buf = append(s.buf, p...)
+	if len(s.buf) > maxScrollback {
+		s.buf = s.buf[len(s.buf)-maxScrollback:]
+	}
+	return len(p), nil
+}
+
+func (s *scrollback) setLive(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.live = w
+}
+
+// Multiplexers returns the multiplexer sessions of the client, oldest first
+func Multiplexers(sys Sys) []*Multiplexer {
+	proc, ok := sys.(*process)
+	if !ok || proc.mux == nil {
+		return nil
+	}
+	proc.mux.mu.Lock()
+	defer proc.mux.mu.Unlock()
+	return append([]*Multiplexer(nil), proc.mux.list...)
+}
+
+// NewMultiplexer starts a session with the window running the shell, or cmd
+// if it is not empty. server is the command line of the server process as ps
+// shows it. It returns nil if the command is not run by the shell
+func NewMultiplexer(sys Sys, tool, name, server, cmd string) *Multiplexer {
+	proc, ok := sys.(*process)
+	if !ok || proc.shell == nil || proc.mux == nil {
+		return nil
+	}
+	m := &Multiplexer{
+		Tool:    tool,
+		Name:    name,
+		PID:     newPid(),
+		Created: time.Now(),
+		Cmd:     cmd,
+		state:   proc.mux,
+		cmd:     cmd,
+		done:    make(chan struct{}),
+	}
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	sh := proc.shell.subshell(proc.System, "bash", nil)
+	sh.log = proc.shell.log.WithField("multiplexer", tool+":"+name)
+	sh.interactive = true
+	sh.sys.envVars["TERM"], sh.sys.exports["TERM"] = "screen", true
+	user := GetUserByID(proc.userId).Name
+	proc.procs.add(ProcInfo{PID: m.PID, PPID: 1, User: user, TTY: "?", Stat: "Ss", Start: m.Created,
+		VSZ: 28932, RSS: 3040, Cmd: server})
+	// The command is the process of the window itself, there is no shell
+	sh.pid = m.PID
+	if cmd == "" {
+		sh.pid = newPid()
+		proc.procs.add(ProcInfo{PID: sh.pid, PPID: m.PID, User: user, TTY: proc.ttyName(), Stat: "Ss+",
+			Start: m.Created, VSZ: 21312, RSS: 5128, Cmd: "/bin/bash"})
+	}
+	m.sh = sh
+	proc.mux.mu.Lock()
+	proc.mux.list = append(proc.mux.list, m)
+	proc.mux.mu.Unlock()
+	sh.log.WithField("cmd", cmd).Infof("Multiplexer session %v started by %v", name, tool)
+	return m
+}
+
+// Setenv sets the environment variable of the window, like $STY of screen
+func (m *Multiplexer) Setenv(key, value string) {
+	m.sh.sys.envVars[key], m.sh.sys.exports[key] = value, true
+}
+
+// Output returns the output of the window kept while detached
+func (m *Multiplexer) Output() string {
+	m.output.mu.Lock()
+	defer m.output.mu.Unlock()
+	return string(m.output.buf)
+}
+
+// Finished tells if the session has ended
+func (m *Multiplexer) Finished() bool {
+	select {
+	case <-m.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Run starts the command of the session in background, for sessions created
+// detached. The session ends when it exits
+func (m *Multiplexer) Run() {
+	m.mu.Lock()
+	cmd := m.cmd
+	m.cmd = ""
+	if cmd == "" || m.busy {
+		m.mu.Unlock()
+		return
+	}
+	m.busy = true
+	m.mu.Unlock()
+	go func() {
+		defer m.end()
+		m.exec(cmd)
+	}()
+}
+
+// Send types the keys into the window. Each line is run by the shell of the
+// window in background, in the order sent
+func (m *Multiplexer) Send(keys string) {
+	m.mu.Lock()
+	m.pending += keys
+	for {
+		i := strings.IndexAny(m.pending, "\r\n")
+		if i < 0 {
+			break
+		}
+		m.queue = append(m.queue, m.pending[:i])
+		m.pending = m.pending[i+1:]
+	}
+	start := !m.busy && len(m.queue) > 0
+	if start {
+		m.busy = true
+	}
+	m.mu.Unlock()
+	if start {
+		go m.work()
+	}
+}
+
+// work runs the lines waiting in queue, until it is empty
+func (m *Multiplexer) work() {
+	for {
+		m.mu.Lock()
+		if len(m.queue) == 0 || m.Finished() {
+			m.busy = false
+			m.mu.Unlock()
+			return
+		}
+		line := m.queue[0]
+		m.queue = m.queue[1:]
+		m.mu.Unlock()
+		if strings.TrimSpace(line) != "" {
+			m.sh.log.WithField("cmd", line).Infof("User input command %v", line)
+		}
+		m.exec(line)
+		if m.sh.exited {
+			m.end()
+		}
+	}
+}
+
+// exec runs the line in the window while detached, writing to the
+// scrollback
+func (m *Multiplexer) exec(line string) {
+	defer func() {
+		if r := recover(); r != nil {
+			m.sh.log.Errorf("Recovered from panic in multiplexer window %v", r)
+		}
+	}()
+	proc := &process{System: m.sh.sys, in: strings.NewReader(""), out: &m.output, err: &m.output, shell: m.sh,
+		ctx: m.ctx, background: true}
+	m.sh.execLine(line, proc)
+}
+
+// release lets the lines sent while attached run
+func (m *Multiplexer) release() {
+	m.mu.Lock()
+	m.busy = false
+	m.mu.Unlock()
+	m.Send("")
+}
+
+// Attach connects the terminal to the window, until the user detaches with
+// prefix key followed by d, or the session ends. rows is the height of the
+// window, less than the terminal if a status line is drawn below it
+func (m *Multiplexer) Attach(sys Sys, prefix rune, rows int) (detached bool) {
+	proc, ok := sys.(*process)
+	if !ok || m.sh.terminal == nil || m.Finished() {
+		return false
+	}
+	m.setAttached(true)
+	defer m.setAttached(false)
+	term := m.sh.terminal
+	term.SetDetachKey(prefix)
+	defer term.SetDetachKey(0)
+	term.SetSize(proc.Width(), rows)
+	defer term.SetSize(proc.Width(), proc.Height())
+	m.sh.log.Infof("User attached to multiplexer session %v", m.Name)
+
+	m.mu.Lock()
+	busy := m.busy
+	m.busy = true
+	cmd := m.cmd
+	m.cmd = ""
+	m.detach = false
+	m.mu.Unlock()
+	if busy {
+		// The command started detached is still running, what is typed
+		// goes to it
+		return m.watch(proc)
+	}
+	defer m.release()
+	sh := m.sh
+	sh.stdin, sh.stdout, sh.stderr = proc.In(), proc.Out(), proc.Err()
+	sh.more = sh.readMore
+	if cmd != "" {
+		sh.ExecLine(cmd)
+		m.end()
+		return false
+	}
+	for !sh.exited && !m.Finished() {
+		line, err := sh.readCommand()
+		switch {
+		case err == terminal.ErrDetach:
+			return true
+		case err == terminal.ErrInterrupt:
+			sh.status = 130
+			continue
+		case err != nil:
+			fmt.Fprintln(proc.Err(), "exit")
+			m.end()
+			return false
+		}
+		sh.addHistory(line)
+		sh.ExecLine(line)
+		m.mu.Lock()
+		detach := m.detach
+		m.mu.Unlock()
+		if detach {
+			return true
+		}
+	}
+	m.end()
+	return false
+}
+
+// watch shows the output of the command running in the window, and logs
+// what is typed to it until detached
+func (m *Multiplexer) watch(proc *process) bool {
+	m.output.setLive(proc.Out())
+	defer m.output.setLive(nil)
+	term := m.sh.terminal
+	for !m.Finished() {
+		term.SetPrompt("")
+		line, err := term.ReadLine()
+		switch err {
+		case nil, terminal.ErrInterrupt:
+			if strings.TrimSpace(line) != "" {
+				m.sh.log.WithField("input", line).Info("User typed into multiplexer window")
+			}
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// Detach detaches the terminal from the window after the command typed in
+// it returns, for commands detaching the session they run in
+func (m *Multiplexer) Detach() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.detach = true
+}
+
+// Kill ends the session and what is running in its window
+func (m *Multiplexer) Kill() {
+	m.sh.log.Infof("Multiplexer session %v killed", m.Name)
+	m.end()
+}
+
+func (m *Multiplexer) setAttached(on bool) {
+	m.state.mu.Lock()
+	defer m.state.mu.Unlock()
+	m.Attached = on
+}
+
+// end removes the session and its processes once the window exits
+func (m *Multiplexer) end() {
+	m.ended.Do(func() {
+		m.cancel()
+		sys := m.sh.sys
+		sys.procs.remove(m.sh.pid)
+		sys.procs.remove(m.PID)
+		m.state.mu.Lock()
+		for i, other := range m.state.list {
+			if other == m {
+				m.state.list = append(m.state.list[:i], m.state.list[i+1:]...)
+				break
+			}
+		}
+		m.state.mu.Unlock()
+		close(m.done)
+	})
+}
@@ -0,0 +1,149 @@
+package os
+
+import (
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// SockInfo is an internet socket, as shown by netstat and ss
+type SockInfo struct {
+	// Proto is tcp, tcp6, udp or udp6
+	Proto string
+	// Local and Remote are the addresses like 0.0.0.0:22, with * as the
+	// port of listening sockets
+	Local, Remote string
+	// State is like LISTEN or ESTABLISHED, empty for udp sockets
+	State        string
+	RecvQ, SendQ int
+	// PID and FD are of the process owning the socket
+	PID, FD int
+	// Program is the command line of the process
+	Program string
+	User    string
+}
+
+// SplitAddr splits the address of the socket into host and port. Unlike
+// net.SplitHostPort IPv6 addresses are not in brackets, e.g. :::22
+func SplitAddr(addr string) (host, port string) {
+	i := strings.LastIndexByte(addr, ':')
+	if i < 0 {
+		return addr, ""
+	}
+	return addr[:i], addr[i+1:]
+}
+
+// listenSockets are the sockets of the daemons, by distribution. They can be
+// changed with persona.listen in the config
+var listenSockets = map[string][]string{
+	"ubuntu": {"tcp 0.0.0.0:22 sshd", "tcp6 :::22 sshd", "udp 0.0.0.0:68 dhclient"},
+	"centos": {"tcp 0.0.0.0:22 sshd", "tcp6 :::22 sshd", "udp 127.0.0.1:323 chronyd", "udp6 ::1:323 chronyd"},
+	"alpine": {"tcp 0.0.0.0:22 sshd", "tcp6 :::22 sshd"},
+}
+
+// sockTable is the sockets of the session, besides those of the daemons
+type sockTable struct {
+	mu    sync.Mutex
+	socks []SockInfo
+}
+
+func (t *sockTable) add(s SockInfo) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.socks = append(t.socks, s)
+}
+
+func (t *sockTable) remove(s SockInfo) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := range t.socks {
+		if t.socks[i] == s {
+			t.socks = append(t.socks[:i], t.socks[i+1:]...)
+			return
+		}
+	}
+}
+
+// OpenSocket adds the socket opened by the command to the socket table, as
+// owned by its process. close removes it once the command is done with it
+func OpenSocket(sys Sys, s SockInfo) (close func()) {
+	proc, ok := sys.(*process)
+	if !ok {
+		return func() {}
+	}
+	s.PID, s.FD, s.User = proc.pid, 3, GetUserByID(proc.userId).Name
+	for _, p := range proc.procs.list() {
+		if p.PID == proc.pid {
+			s.Program = p.Cmd
+		}
+	}
+	proc.socks.add(s)
+	return func() { proc.socks.remove(s) }
+}
+
+// Sockets returns the sockets listening and the connections established,
+// including the one of the client and the ports published by the containers
+func (sys *System) Sockets() []SockInfo {
+	list := viper.GetStringSlice("persona.listen")
+	if len(list) == 0 {
+		if list = listenSockets[Distro()]; list == nil {
+			list = listenSockets["ubuntu"]
+		}
+	}
+	procs := sys.Processes()
+	var socks []SockInfo
+	for i, entry := range list {
+		fields := strings.Fields(entry)
+		if len(fields) < 2 {
+			continue
+		}
+		s := SockInfo{Proto: fields[0], Local: fields[1], FD: 3 + i%2, User: "root"}
+		if strings.HasPrefix(s.Proto, "tcp") {
+			s.State = "LISTEN"
+		}
+		if strings.HasSuffix(s.Proto, "6") {
+			s.Remote = ":::*"
+		} else {
+			s.Remote = "0.0.0.0:*"
+		}
+		if len(fields) > 2 {
+			for _, p := range procs {
+				if p.Comm() == fields[2] {
+					s.PID, s.Program, s.User = p.PID, p.Cmd, p.User
+					break
+				}
+			}
+		}
+		socks = append(socks, s)
+	}
+	_, proxies := sys.dockerProxies()
+	socks = append(socks, proxies...)
+	sys.socks.mu.Lock()
+	defer sys.socks.mu.Unlock()
+	for _, s := range sys.socks.socks {
+		// Output of the command itself may be still on the way
+		if s.State == "ESTABLISHED" && rand.Intn(3) == 0 {
+			s.SendQ = 36 + rand.Intn(4)*16
+		}
+		socks = append(socks, s)
+	}
+	return socks
+}
+
+// addConnection adds the ssh connection of the client, owned by the sshd of
+// the session
+func (sys *System) addConnection(src string, pid int, cmd string) {
+	host, port, err := net.SplitHostPort(src)
+	if err != nil {
+		return
+	}
+	proto := "tcp"
+	if strings.Contains(host, ":") {
+		proto = "tcp6"
+	}
+	sys.socks.add(SockInfo{Proto: proto, Local: IPAddress() + ":22", Remote: host + ":" + port, State: "ESTABLISHED",
+		PID: pid, FD: 3, Program: cmd, User: "root"})
+}
@@ -0,0 +1,113 @@
+package os
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// ubuntuPackages maps commands not installed by default to the package
+// providing it in xenial, for the hint printed by command-not-found of Ubuntu
+var ubuntuPackages = map[string]string{
+	"aria2c":      "aria2",
+	"axel":        "axel",
+	"bzip2":       "bzip2",
+	"docker":      "docker.io",
+	"emacs":       "emacs24-nox",
+	"g++":         "g++",
+	"gcc":         "gcc",
+	"git":         "git",
+	"go":          "golang-go",
+	"htop":        "htop",
+	"hydra":       "hydra",
+	"iftop":       "iftop",
+	"john":        "john",
+	"lynx":        "lynx",
+	"make":        "make",
+	"masscan":     "masscan",
+	"mc":          "mc",
+	"mysql":       "mysql-client-core-5.7",
+	"ncat":        "nmap",
+	"nmap":        "nmap",
+	"node":        "nodejs-legacy",
+	"npm":         "npm",
+	"php":         "php7.0-cli",
+	"pip":         "python-pip",
+	"pip3":        "python3-pip",
+	"proxychains": "proxychains",
+	"psql":        "postgresql-client-common",
+	"redis-cli":   "redis-tools",
+	"ruby":        "ruby",
+	"socat":       "socat",
+	"sshpass":     "sshpass",
+	"tor":         "tor",
+	"traceroute":  "traceroute",
+	"unzip":       "unzip",
+	"w3m":         "w3m",
+	"whois":       "whois",
+	"zip":         "zip",
+	"zmap":        "zmap",
+}
+
+var unknownCmdLock sync.Mutex
+
+// commandNotFound prints the error for unknown command the way the distro
+// of persona does, and records the command in the unknown command list
+func (sh *Shell) commandNotFound(args []string, proc *process) int {
+	sh.log.WithField("args", args).Infof("Command %v not found", args[0])
+	sh.recordUnknownCommand(args)
+	switch {
+	case !sh.interactive:
+		// command_not_found_handle is only defined for interactive shell
+		sh.errorf(proc, "%v: command not found", args[0])
+	case Distro() == "alpine":
+		sh.errorf(proc, "%v: not found", args[0])
+	case Distro() == "ubuntu":
+		pkg, exists := ubuntuPackages[args[0]]
+		if !exists {
+			fmt.Fprintf(proc.Err(), "%v: command not found\n", args[0])
+			break
+		}
+		install := "apt install " + pkg
+		if sh.sys.CurrentUser() != 0 {
+			install = "sudo " + install
+		}
+		fmt.Fprintf(proc.Err(), "The program '%v' is currently not installed. You can install it by typing:\n%v\n", args[0], install)
+	default:
+		sh.errorf(proc, "%v: command not found", args[0])
+	}
+	return 127
+}
+
+// CommandNotFound prints the error as if the command does not exist, for
+// commands not found in the distribution the honeypot pretends to be. args
+// includes the command name
+func CommandNotFound(sys Sys, args []string) int {
+	if proc, ok := sys.(*process); ok && proc.shell != nil {
+		return proc.shell.commandNotFound(args, proc)
+	}
+	fmt.Fprintf(sys.Err(), "%v: command not found\n", args[0])
+	return 127
+}
+
+// recordUnknownCommand appends the command to the file set in
+// server.unknownCommandList, so commands attackers expect can be added later
+func (sh *Shell) recordUnknownCommand(args []string) {
+	file := viper.GetString("server.unknownCommandList")
+	if file == "" {
+		return
+	}
+	unknownCmdLock.Lock()
+	defer unknownCmdLock.Unlock()
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		sh.log.WithError(err).Errorf("Cannot open unknown command list %v", file)
+		return
+	}
+	defer f.Close()
+	fmt.Fprintf(f, "%v\t%v\t%v\n", time.Now().Format(time.RFC3339), GetUserByID(sh.sys.CurrentUser()).Name, strings.Join(args, " "))
+}
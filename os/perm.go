@@ -0,0 +1,785 @@
+package os
+
+import (
+	"errors"
+	"io"
+	"os"
+	pathlib "path"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/mkishere/sshsyrup/virtualfs"
+	"github.com/spf13/afero"
+)
+
+// fileMeta is the owner and mode of a file set by users, which the
+// filesystems under can't keep, with the access and change times
+type fileMeta struct {
+	uid, gid     int
+	mode         os.FileMode
+	hasOwner     bool
+	hasMode      bool
+	atime, ctime time.Time
+}
+
+// fileOwner is what Sys() of files with their owner changed returns
+type fileOwner struct {
+	uid, gid int
+}
+
+func (o fileOwner) UID() int { return o.uid }
+
+func (o fileOwner) GID() int { return o.gid }
+
+// ownedInfo is the file info with owner and mode kept by ownerFs
+type ownedInfo struct {
+	os.FileInfo
+	meta fileMeta
+}
+
+func (fi ownedInfo) Mode() os.FileMode {
+	if fi.meta.hasMode {
+		return fi.FileInfo.Mode()&^os.ModePerm&^(os.ModeSetuid|os.ModeSetgid|os.ModeSticky) | fi.meta.mode
+	}
+	return fi.FileInfo.Mode()
+}
+
+func (fi ownedInfo) Sys() interface{} {
+	if fi.meta.hasOwner {
+		return fileOwner{fi.meta.uid, fi.meta.gid}
+	}
+	return fi.FileInfo.Sys()
+}
+
+func (fi ownedInfo) times() (atime, ctime time.Time) { return fi.meta.atime, fi.meta.ctime }
+
+// FileTimes returns the access, modification and change times of the file.
+// Files not touched since the image was made have their change time at the
+// last modification
+func FileTimes(fi os.FileInfo) (atime, mtime, ctime time.Time) {
+	mtime, ctime = fi.ModTime(), fi.ModTime()
+	_, _, atime, _ = virtualfs.GetExtraInfo(fi)
+	if t, ok := fi.(interface{ times() (time.Time, time.Time) }); ok {
+		a, c := t.times()
+		if !a.IsZero() {
+			atime = a
+		}
+		if c.After(ctime) {
+			ctime = c
+		}
+	}
+	if atime.IsZero() {
+		atime = mtime
+	}
+	return atime, mtime, ctime
+}
+
+// ownerFs keeps the owner and mode of files, as files from the image are
+// read only and files saved by users are owned by the honeypot. Files of the
+// image removed are hidden, as they can't be deleted from the image
+type ownerFs struct {
+	afero.Fs
+	mu      sync.RWMutex
+	meta    map[string]fileMeta
+	removed map[string]bool
+	// links are the symbolic links made by users, and hardLinks the names
+	// made by ln for the files holding the content
+	links     map[string]fileLink
+	hardLinks map[string]string
+}
+
+// ownedFile is a file of ownerFs, which lists the directory with owners
+type ownedFile struct {
+	afero.File
+	fs   *ownerFs
+	name string
+}
+
+// NewOwnerFs returns the filesystem keeping owner and mode changes of base,
+// which is shared by all sessions
+func NewOwnerFs(base afero.Fs) afero.Fs {
+	// Directories saved on disk are made by the honeypot, so those with
+	// modes other than 755 are kept here
+	return &ownerFs{Fs: base, meta: map[string]fileMeta{
+		"/tmp":     {mode: os.ModeSticky | 0777, hasMode: true},
+		"/var/tmp": {mode: os.ModeSticky | 0777, hasMode: true},
+		"/root":    {mode: 0700, hasMode: true},
+	}, removed: map[string]bool{}, links: map[string]fileLink{}, hardLinks: map[string]string{}}
+}
+
+// hidden tells if the file or a directory above it has been removed
+func (o *ownerFs) hidden(name string) bool {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	if len(o.removed) == 0 {
+		return false
+	}
+	for p := pathlib.Clean(name); ; p = pathlib.Dir(p) {
+		if o.removed[p] {
+			return true
+		}
+		if p == "/" || p == "." {
+			return false
+		}
+	}
+}
+
+// hide removes the file from view if the filesystem under still has it,
+// which is the case for files of the image
+func (o *ownerFs) hide(name string) {
+	if _, err := o.Fs.Stat(name); err != nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.removed[pathlib.Clean(name)] = true
+}
+
+// unhide makes the name available for a new file. The files still in the
+// image under the directory removed stay hidden
+func (o *ownerFs) unhide(name string) {
+	name = pathlib.Clean(name)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if !o.removed[name] {
+		return
+	}
+	delete(o.removed, name)
+	if fi, err := o.Fs.Stat(name); err != nil || !fi.IsDir() {
+		return
+	}
+	if f, err := o.Fs.Open(name); err == nil {
+		names, _ := f.Readdirnames(-1)
+		f.Close()
+		for _, n := range names {
+			o.removed[pathlib.Join(name, n)] = true
+		}
+	}
+}
+
+func notExist(op, name string) error {
+	return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+}
+
+func (o *ownerFs) info(name string, fi os.FileInfo) os.FileInfo {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	if m, ok := o.meta[pathlib.Clean(name)]; ok {
+		return ownedInfo{fi, m}
+	}
+	return fi
+}
+
+func (o *ownerFs) Stat(name string) (os.FileInfo, error) {
+	p, err := o.resolve(name, true)
+	if err != nil {
+		return nil, err
+	}
+	if o.hidden(p) {
+		return nil, notExist("stat", name)
+	}
+	fi, err := o.Fs.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	return o.linked(name, p, o.info(p, fi)), nil
+}
+
+func (o *ownerFs) Open(name string) (afero.File, error) {
+	p, err := o.resolve(name, true)
+	if err != nil {
+		return nil, err
+	}
+	if o.hidden(p) {
+		return nil, notExist("open", name)
+	}
+	f, err := o.Fs.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	return ownedFile{f, o, p}, nil
+}
+
+func (o *ownerFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	name, err := o.resolve(name, true)
+	if err != nil {
+		return nil, err
+	}
+	if o.hidden(name) {
+		if flag&os.O_CREATE == 0 || o.hidden(pathlib.Dir(name)) {
+			return nil, notExist("open", name)
+		}
+		// The file is new, not the one in the image
+		o.unhide(name)
+		o.forget(name)
+		flag |= os.O_TRUNC
+	}
+	if flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+		o.keep(name)
+	}
+	f, err := o.Fs.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return ownedFile{f, o, pathlib.Clean(name)}, nil
+}
+
+// keep records the owner and mode of the file before it is written, which
+// are lost when the file from the image is copied to disk
+func (o *ownerFs) keep(name string) {
+	fi, err := o.Fs.Stat(name)
+	if err != nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	m := o.meta[pathlib.Clean(name)]
+	if !m.hasOwner {
+		m.uid, m.gid, _, _ = virtualfs.GetExtraInfo(fi)
+		m.hasOwner = true
+	}
+	if !m.hasMode {
+		m.mode, m.hasMode = fi.Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky), true
+	}
+	o.meta[pathlib.Clean(name)] = m
+}
+
+func (o *ownerFs) Create(name string) (afero.File, error) {
+	return o.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// Chmod keeps the mode, and still lets the honeypot read and write the file
+// saved
+func (o *ownerFs) Chmod(name string, mode os.FileMode) error {
+	if _, err := o.Stat(name); err != nil {
+		return err
+	}
+	name, _ = o.resolve(name, true)
+	o.Fs.Chmod(name, mode|0600)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	m := o.meta[pathlib.Clean(name)]
+	m.mode, m.hasMode = mode&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky), true
+	m.ctime = time.Now()
+	o.meta[pathlib.Clean(name)] = m
+	return nil
+}
+
+func (o *ownerFs) Chown(name string, uid, gid int) error {
+	if _, err := o.Stat(name); err != nil {
+		return err
+	}
+	name, _ = o.resolve(name, true)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	m := o.meta[pathlib.Clean(name)]
+	m.uid, m.gid, m.hasOwner = uid, gid, true
+	m.ctime = time.Now()
+	o.meta[pathlib.Clean(name)] = m
+	return nil
+}
+
+func (o *ownerFs) Chtimes(name string, atime, mtime time.Time) error {
+	p, err := o.resolve(name, true)
+	if err != nil {
+		return err
+	}
+	if err := o.Fs.Chtimes(p, atime, mtime); err != nil {
+		return err
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	m := o.meta[p]
+	m.atime, m.ctime = atime, time.Now()
+	o.meta[p] = m
+	return nil
+}
+
+func (o *ownerFs) Mkdir(name string, perm os.FileMode) error {
+	name, err := o.resolveDir(name)
+	if err != nil {
+		return err
+	}
+	if _, ok := o.link(name); ok {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrExist}
+	}
+	if o.hidden(name) {
+		if o.hidden(pathlib.Dir(name)) {
+			return notExist("mkdir", name)
+		}
+		// The directory of the image is shown again, but empty
+		o.unhide(name)
+		o.forget(name)
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		o.meta[pathlib.Clean(name)] = fileMeta{mode: perm & (os.ModePerm | os.ModeSticky), hasMode: true}
+		return nil
+	}
+	return o.Fs.Mkdir(name, perm)
+}
+
+func (o *ownerFs) MkdirAll(name string, perm os.FileMode) error {
+	if fi, err := o.Stat(name); err == nil {
+		if !fi.IsDir() {
+			return &os.PathError{Op: "mkdir", Path: name, Err: syscall.ENOTDIR}
+		}
+		return nil
+	}
+	if dir := pathlib.Dir(pathlib.Clean(name)); dir != pathlib.Clean(name) {
+		if err := o.MkdirAll(dir, perm); err != nil {
+			return err
+		}
+	}
+	return o.Mkdir(name, perm)
+}
+
+// Rename copies the files of the image, which can't be moved, and hides the
+// old ones
+func (o *ownerFs) Rename(oldname, newname string) error {
+	oldname, err := o.resolveDir(oldname)
+	if err != nil {
+		return err
+	}
+	if newname, err = o.resolveDir(newname); err != nil {
+		return err
+	}
+	if o.hidden(oldname) {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: os.ErrNotExist}
+	}
+	if o.hidden(newname) {
+		o.unhide(newname)
+	}
+	// A link replaced is gone, the file it linked to stays
+	o.dropLinks(newname)
+	err = o.Fs.Rename(oldname, newname)
+	if err == syscall.EPERM {
+		if err = o.copyTree(oldname, newname); err == nil {
+			o.hide(oldname)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	// The copy of the image is moved but the original is still there
+	o.hide(oldname)
+	o.mu.Lock()
+	for p, m := range o.meta {
+		if p == oldname || len(p) > len(oldname) && p[:len(oldname)+1] == oldname+"/" {
+			delete(o.meta, p)
+			o.meta[newname+p[len(oldname):]] = m
+		}
+	}
+	m := o.meta[newname]
+	m.ctime = time.Now()
+	o.meta[newname] = m
+	o.mu.Unlock()
+	o.moveLinks(oldname, newname)
+	return nil
+}
+
+// copyTree copies the file, or the directory with everything under it
+func (o *ownerFs) copyTree(src, dst string) error {
+	o.mu.RLock()
+	_, isLink := o.links[src]
+	_, isHard := o.hardLinks[src]
+	o.mu.RUnlock()
+	if isLink || isHard {
+		// The link itself is moved by moveLinks
+		f, err := o.Fs.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return err
+		}
+		return f.Close()
+	}
+	fi, err := o.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		in, err := o.Open(src)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := o.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+		_, err = io.Copy(out, in)
+		return err
+	}
+	if err := o.Mkdir(dst, fi.Mode().Perm()); err != nil && !os.IsExist(err) {
+		return err
+	}
+	f, err := o.Open(src)
+	if err != nil {
+		return err
+	}
+	names, _ := f.Readdirnames(-1)
+	f.Close()
+	for _, n := range names {
+		if err := o.copyTree(pathlib.Join(src, n), pathlib.Join(dst, n)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Remove hides the file of the image, which is only possible for empty
+// directories like rmdir(2)
+func (o *ownerFs) Remove(name string) error {
+	name, err := o.resolveDir(name)
+	if err != nil {
+		return err
+	}
+	if unlinked, err := o.unlink(name); unlinked {
+		return err
+	}
+	fi, err := o.Stat(name)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		f, err := o.Open(name)
+		if err != nil {
+			return err
+		}
+		names, _ := f.Readdirnames(-1)
+		f.Close()
+		if len(names) > 0 {
+			return &os.PathError{Op: "remove", Path: name, Err: syscall.ENOTEMPTY}
+		}
+	}
+	// Files only in the image are not found in the layer saved on disk
+	if err := o.Fs.Remove(name); err != nil && err != syscall.EPERM && !os.IsNotExist(err) {
+		return err
+	}
+	o.hide(name)
+	o.forget(name)
+	return nil
+}
+
+func (o *ownerFs) RemoveAll(name string) error {
+	name, err := o.resolveDir(name)
+	if err != nil {
+		return err
+	}
+	if unlinked, err := o.unlink(name); unlinked {
+		return err
+	}
+	if o.hidden(name) {
+		return nil
+	}
+	if err := o.Fs.RemoveAll(name); err != nil && err != syscall.EPERM && !os.IsNotExist(err) {
+		return err
+	}
+	o.hide(name)
+	o.forget(name)
+	o.dropLinks(name)
+	return nil
+}
+
+// forget drops what is kept of the file and those under it
+func (o *ownerFs) forget(name string) {
+	name = pathlib.Clean(name)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for p := range o.meta {
+		if p == name || len(p) > len(name) && p[:len(name)+1] == name+"/" {
+			delete(o.meta, p)
+		}
+	}
+}
+
+func (f ownedFile) Stat() (os.FileInfo, error) {
+	fi, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return f.fs.info(f.name, fi), nil
+}
+
+func (f ownedFile) Readdir(n int) ([]os.FileInfo, error) {
+	list, err := f.File.Readdir(n)
+	shown := list[:0]
+	for _, fi := range list {
+		if p := pathlib.Join(f.name, fi.Name()); !f.fs.hidden(p) {
+			shown = append(shown, f.fs.entry(p, fi))
+		}
+	}
+	return shown, err
+}
+
+func (f ownedFile) Readdirnames(n int) ([]string, error) {
+	list, err := f.File.Readdirnames(n)
+	shown := list[:0]
+	for _, name := range list {
+		if !f.fs.hidden(pathlib.Join(f.name, name)) {
+			shown = append(shown, name)
+		}
+	}
+	return shown, err
+}
+
+// chowner is the filesystem which can change the owner of files
+type chowner interface {
+	Chown(name string, uid, gid int) error
+}
+
+// Chown changes the owner of the file, if the filesystem keeps owners
+func Chown(fs afero.Fs, name string, uid, gid int) error {
+	if c, ok := fs.(chowner); ok {
+		return c.Chown(name, uid, gid)
+	}
+	return &os.PathError{Op: "chown", Path: name, Err: errors.New("operation not supported")}
+}
+
+// SetUIDFs returns the filesystem without permission checks, for commands
+// installed setuid root like passwd
+func SetUIDFs(fs afero.Fs) afero.Fs {
+	if u, ok := fs.(userFs); ok {
+		return u.Fs
+	}
+	return fs
+}
+
+// userFs checks the permission of the user on the files, like the kernel
+// does. Files created are owned by the user
+type userFs struct {
+	afero.Fs
+	sys *System
+}
+
+// permits tells if the mode allows the user want, which is some of 4, 2 and 1
+// for read, write and execute
+func (u userFs) permits(fi os.FileInfo, want os.FileMode) bool {
+	uid, gid, _, _ := virtualfs.GetExtraInfo(fi)
+	mode := fi.Mode().Perm()
+	switch {
+	case uid == u.sys.CurrentUser():
+		mode >>= 6
+	case gid == u.sys.CurrentGroup():
+		mode >>= 3
+	}
+	return mode&want == want
+}
+
+// allowed tells if the user can access the file as want. The directories
+// leading to it have to be searchable as well. Root can access everything
+func (u userFs) allowed(name string, fi os.FileInfo, want os.FileMode) bool {
+	if u.sys.CurrentUser() == 0 {
+		return true
+	}
+	for dir := pathlib.Dir(name); ; dir = pathlib.Dir(dir) {
+		if d, err := u.Fs.Stat(dir); err == nil && !u.permits(d, 1) {
+			return false
+		}
+		if dir == "/" || dir == "." {
+			break
+		}
+	}
+	return u.permits(fi, want)
+}
+
+// Access tells if the user running the command can access the file as want,
+// like access(2) does
+func Access(sys Sys, name string, want os.FileMode) bool {
+	u, ok := sys.FSys().(userFs)
+	if !ok {
+		return true
+	}
+	fi, err := u.Fs.Stat(name)
+	return err == nil && u.allowed(name, fi, want)
+}
+
+// writableDir tells if entries can be added to or removed from the directory
+// of the file
+func (u userFs) writableDir(name string) bool {
+	dir := pathlib.Dir(name)
+	fi, err := u.Fs.Stat(dir)
+	return err != nil || u.allowed(dir, fi, 3)
+}
+
+// removable tells if the file can be removed, which only its owner can do in
+// directories with sticky bit like /tmp
+func (u userFs) removable(name string) bool {
+	if !u.writableDir(name) {
+		return false
+	}
+	uid := u.sys.CurrentUser()
+	dir, err := u.Fs.Stat(pathlib.Dir(name))
+	if uid == 0 || err != nil || dir.Mode()&os.ModeSticky == 0 {
+		return true
+	}
+	fi, err := u.Fs.Stat(name)
+	if err != nil {
+		return true
+	}
+	owner, _, _, _ := virtualfs.GetExtraInfo(fi)
+	dirOwner, _, _, _ := virtualfs.GetExtraInfo(dir)
+	return owner == uid || dirOwner == uid
+}
+
+func (u userFs) own(name string) {
+	Chown(u.Fs, name, u.sys.CurrentUser(), u.sys.CurrentGroup())
+}
+
+func (u userFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	name = pathlib.Clean(name)
+	fi, err := u.Fs.Stat(name)
+	if err == nil {
+		want := os.FileMode(4)
+		switch flag & (os.O_WRONLY | os.O_RDWR) {
+		case os.O_WRONLY:
+			want = 2
+		case os.O_RDWR:
+			want = 6
+		}
+		if !u.allowed(name, fi, want) {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrPermission}
+		}
+		if flag == os.O_RDONLY {
+			// Directories are only merged from the layers by Open
+			return genFs{u.Fs, u.sys}.Open(name)
+		}
+		return u.Fs.OpenFile(name, flag, perm)
+	}
+	if flag&os.O_CREATE == 0 {
+		return u.Fs.OpenFile(name, flag, perm)
+	}
+	if !u.writableDir(name) {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrPermission}
+	}
+	f, err := u.Fs.OpenFile(name, flag, perm)
+	if err == nil && u.sys.CurrentUser() != 0 {
+		u.own(name)
+	}
+	return f, err
+}
+
+// Stat has the size of generated files as the session reads them
+func (u userFs) Stat(name string) (os.FileInfo, error) {
+	return genFs{u.Fs, u.sys}.Stat(name)
+}
+
+func (u userFs) Open(name string) (afero.File, error) {
+	return u.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (u userFs) Create(name string) (afero.File, error) {
+	return u.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+func (u userFs) Mkdir(name string, perm os.FileMode) error {
+	name = pathlib.Clean(name)
+	if !u.writableDir(name) {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrPermission}
+	}
+	if err := u.Fs.Mkdir(name, perm); err != nil {
+		return err
+	}
+	if u.sys.CurrentUser() != 0 {
+		u.own(name)
+	}
+	return nil
+}
+
+func (u userFs) MkdirAll(name string, perm os.FileMode) error {
+	name = pathlib.Clean(name)
+	if fi, err := u.Fs.Stat(name); err == nil {
+		if !fi.IsDir() {
+			return &os.PathError{Op: "mkdir", Path: name, Err: syscall.ENOTDIR}
+		}
+		return nil
+	}
+	if dir := pathlib.Dir(name); dir != name {
+		if err := u.MkdirAll(dir, perm); err != nil {
+			return err
+		}
+	}
+	return u.Mkdir(name, perm)
+}
+
+func (u userFs) Remove(name string) error {
+	if !u.removable(pathlib.Clean(name)) {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrPermission}
+	}
+	return u.Fs.Remove(name)
+}
+
+func (u userFs) RemoveAll(name string) error {
+	if !u.removable(pathlib.Clean(name)) {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrPermission}
+	}
+	return u.Fs.RemoveAll(name)
+}
+
+func (u userFs) Rename(oldname, newname string) error {
+	if !u.removable(pathlib.Clean(oldname)) || !u.writableDir(pathlib.Clean(newname)) {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: os.ErrPermission}
+	}
+	return u.Fs.Rename(oldname, newname)
+}
+
+// isOwner tells if the user owns the file, which is needed for changing its
+// mode and times
+func (u userFs) isOwner(name string) (bool, error) {
+	fi, err := u.Fs.Stat(name)
+	if err != nil {
+		return false, err
+	}
+	uid, _, _, _ := virtualfs.GetExtraInfo(fi)
+	return u.sys.CurrentUser() == 0 || uid == u.sys.CurrentUser(), nil
+}
+
+func (u userFs) Chmod(name string, mode os.FileMode) error {
+	if ok, err := u.isOwner(name); err != nil {
+		return err
+	} else if !ok {
+		return &os.PathError{Op: "chmod", Path: name, Err: os.ErrPermission}
+	}
+	return u.Fs.Chmod(name, mode)
+}
+
+func (u userFs) Chtimes(name string, atime, mtime time.Time) error {
+	if ok, err := u.isOwner(name); err != nil {
+		return err
+	} else if !ok {
+		return &os.PathError{Op: "chtimes", Path: name, Err: os.ErrPermission}
+	}
+	return u.Fs.Chtimes(name, atime, mtime)
+}
+
+// Chown is allowed for root. Others can only change the group of their files
+// to their own group
+func (u userFs) Chown(name string, uid, gid int) error {
+	if user := u.sys.CurrentUser(); user != 0 {
+		fi, err := u.Fs.Stat(name)
+		if err != nil {
+			return err
+		}
+		owner, group, _, _ := virtualfs.GetExtraInfo(fi)
+		if owner != user || uid != owner || gid != group && gid != u.sys.CurrentGroup() {
+			return &os.PathError{Op: "chown", Path: name, Err: os.ErrPermission}
+		}
+	}
+	return Chown(u.Fs, name, uid, gid)
+}
+
+// executable tells if the user can run the file. Even root needs one of the
+// execute bits
+func (sys *System) executable(name string, fi os.FileInfo) bool {
+	if fi.Mode()&0111 == 0 {
+		return false
+	}
+	u := userFs{sys.fSys, sys}
+	return u.allowed(name, fi, 1)
+}
+
+// searchable tells if the user can change to the directory
+func (sys *System) searchable(name string, fi os.FileInfo) bool {
+	u := userFs{sys.fSys, sys}
+	return u.allowed(name, fi, 1)
+}
@@ -0,0 +1,214 @@
+package os
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+func TestUserFs(t *testing.T) {
+	users[1000] = User{UID: 1000, GID: 1000, Name: "test"}
+	base := NewOwnerFs(afero.NewMemMapFs())
+	base.Chmod("/", os.ModeDir|0755)
+	base.MkdirAll("/etc", 0755)
+	afero.WriteFile(base, "/etc/shadow", []byte("root:*:17000:0:99999:7:::\n"), 0640)
+	afero.WriteFile(base, "/etc/passwd", []byte("root:x:0:0::/root:/bin/bash\n"), 0644)
+	base.MkdirAll("/tmp", 0777)
+	base.Chmod("/tmp", os.ModeSticky|0777)
+	base.MkdirAll("/root", 0700)
+	afero.WriteFile(base, "/root/a.sh", []byte("id\n"), 0755)
+
+	root := &System{fSys: base}
+	user := &System{fSys: base, userId: 1000}
+	if _, err := afero.ReadFile(user.FSys(), "/etc/shadow"); !os.IsPermission(err) {
+		t.Errorf("Reading /etc/shadow as user, expect permission denied, got %v", err)
+	}
+	if _, err := afero.ReadFile(user.FSys(), "/etc/passwd"); err != nil {
+		t.Errorf("Reading /etc/passwd as user, got %v", err)
+	}
+	if err := afero.WriteFile(user.FSys(), "/etc/passwd", nil, 0644); !os.IsPermission(err) {
+		t.Errorf("Writing /etc/passwd as user, expect permission denied, got %v", err)
+	}
+	if _, err := afero.ReadFile(user.FSys(), "/root/a.sh"); !os.IsPermission(err) {
+		t.Errorf("Reading file under /root as user, expect permission denied, got %v", err)
+	}
+	if err := user.Chdir("/root"); !os.IsPermission(err) {
+		t.Errorf("Changing to /root as user, expect permission denied, got %v", err)
+	}
+
+	// Files created are owned by the user, and only the owner can change them
+	if err := afero.WriteFile(user.FSys(), "/tmp/x", []byte("x"), 0644); err != nil {
+		t.Fatalf("Writing /tmp/x as user, got %v", err)
+	}
+	fi, _ := user.FSys().Stat("/tmp/x")
+	if uid := fi.Sys().(interface{ UID() int }).UID(); uid != 1000 {
+		t.Errorf("Owner of file created, expect 1000, got %v", uid)
+	}
+	if err := afero.WriteFile(root.FSys(), "/tmp/y", []byte("y"), 0644); err != nil {
+		t.Fatalf("Writing /tmp/y as root, got %v", err)
+	}
+	if err := user.FSys().Chmod("/tmp/y", 0777); !os.IsPermission(err) {
+		t.Errorf("Changing mode of file of root as user, expect permission denied, got %v", err)
+	}
+	if err := user.FSys().Remove("/tmp/y"); !os.IsPermission(err) {
+		t.Errorf("Removing file of root in /tmp as user, expect permission denied, got %v", err)
+	}
+	if err := Chown(user.FSys(), "/tmp/x", 0, 0); !os.IsPermission(err) {
+		t.Errorf("Giving file to root as user, expect permission denied, got %v", err)
+	}
+	if err := Chown(root.FSys(), "/tmp/y", 1000, 1000); err != nil {
+		t.Errorf("Giving file to user as root, got %v", err)
+	}
+	if err := user.FSys().Remove("/tmp/y"); err != nil {
+		t.Errorf("Removing file given to user, got %v", err)
+	}
+
+	fi, _ = user.FSys().Stat("/tmp/x")
+	if user.executable("/tmp/x", fi) || root.executable("/tmp/x", fi) {
+		t.Errorf("File without execute bits is executable")
+	}
+	user.FSys().Chmod("/tmp/x", 0744)
+	fi, _ = user.FSys().Stat("/tmp/x")
+	if !user.executable("/tmp/x", fi) {
+		t.Errorf("File with mode 744 is not executable by its owner")
+	}
+}
+
+func TestRemoveImageFile(t *testing.T) {
+	image := afero.NewMemMapFs()
+	image.MkdirAll("/etc/cron.d", 0755)
+	afero.WriteFile(image, "/etc/passwd", []byte("root:x:0:0::/root:/bin/bash\n"), 0644)
+	afero.WriteFile(image, "/etc/cron.d/job", []byte("* * * * * root id\n"), 0644)
+	fs := NewOwnerFs(afero.NewCopyOnWriteFs(afero.NewReadOnlyFs(image), afero.NewMemMapFs()))
+
+	if err := fs.Remove("/etc/passwd"); err != nil {
+		t.Fatalf("Removing file of the image, got %v", err)
+	}
+	if _, err := fs.Stat("/etc/passwd"); !os.IsNotExist(err) {
+		t.Errorf("Stat of file removed, expect not exist, got %v", err)
+	}
+	dir, _ := fs.Open("/etc")
+	names, _ := dir.Readdirnames(-1)
+	dir.Close()
+	if len(names) != 1 || names[0] != "cron.d" {
+		t.Errorf("Listing directory with file removed, expect [cron.d], got %v", names)
+	}
+	// A file created again is new, not the one in the image
+	afero.WriteFile(fs, "/etc/passwd", []byte("x"), 0644)
+	if b, _ := afero.ReadFile(fs, "/etc/passwd"); string(b) != "x" {
+		t.Errorf("Content of file created again, expect x, got %q", b)
+	}
+
+	if err := fs.Remove("/etc/cron.d"); err == nil {
+		t.Errorf("Removing directory not empty, expect error")
+	}
+	if err := fs.Rename("/etc/cron.d", "/etc/cron.daily"); err != nil {
+		t.Fatalf("Renaming directory of the image, got %v", err)
+	}
+	if b, _ := afero.ReadFile(fs, "/etc/cron.daily/job"); len(b) == 0 {
+		t.Errorf("File under directory renamed is empty")
+	}
+	if _, err := fs.Stat("/etc/cron.d/job"); !os.IsNotExist(err) {
+		t.Errorf("Stat of file under directory renamed, expect not exist, got %v", err)
+	}
+	if err := fs.RemoveAll("/etc/cron.daily"); err != nil {
+		t.Errorf("Removing directory copied from the image, got %v", err)
+	}
+	if err := fs.Mkdir("/etc/cron.d", 0700); err != nil {
+		t.Fatalf("Making directory removed again, got %v", err)
+	}
+	if fi, err := fs.Stat("/etc/cron.d/job"); err == nil {
+		t.Errorf("Directory made again should be empty, got %v", fi.Name())
+	}
+}
+
+func TestLinks(t *testing.T) {
+	image := afero.NewMemMapFs()
+	image.MkdirAll("/etc/init.d", 0755)
+	image.MkdirAll("/etc/rc2.d", 0755)
+	afero.WriteFile(image, "/etc/init.d/ssh", []byte("#!/bin/sh\n"), 0755)
+	fs := NewOwnerFs(afero.NewCopyOnWriteFs(afero.NewReadOnlyFs(image), afero.NewMemMapFs()))
+
+	if err := Symlink(fs, "../init.d/ssh", "/etc/rc2.d/S01ssh"); err != nil {
+		t.Fatalf("Making symbolic link, got %v", err)
+	}
+	if err := Symlink(fs, "/etc/init.d/ssh", "/etc/rc2.d/S01ssh"); !os.IsExist(err) {
+		t.Errorf("Making link over existing one, expect file exists, got %v", err)
+	}
+	if b, err := afero.ReadFile(fs, "/etc/rc2.d/S01ssh"); string(b) != "#!/bin/sh\n" {
+		t.Errorf("Reading through relative link, expect the target, got %q, %v", b, err)
+	}
+	if target, _ := Readlink(fs, "/etc/rc2.d/S01ssh"); target != "../init.d/ssh" {
+		t.Errorf("Target of link, expect ../init.d/ssh, got %v", target)
+	}
+	if fi, _ := Lstat(fs, "/etc/rc2.d/S01ssh"); fi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("Lstat of link, expect symbolic link, got %v", fi.Mode())
+	}
+	if fi, _ := fs.Stat("/etc/rc2.d/S01ssh"); fi.Mode()&os.ModeSymlink != 0 || fi.Name() != "S01ssh" {
+		t.Errorf("Stat of link, expect the target by the link name, got %v %v", fi.Name(), fi.Mode())
+	}
+	dir, _ := fs.Open("/etc/rc2.d")
+	list, _ := dir.Readdir(-1)
+	dir.Close()
+	if len(list) != 1 || list[0].Mode()&os.ModeSymlink == 0 {
+		t.Errorf("Listing directory with link, expect the link, got %v", list)
+	}
+
+	// Hard links share the content, which stays when the first name is gone
+	if err := Link(fs, "/etc/init.d/ssh", "/etc/ssh.bak"); err != nil {
+		t.Fatalf("Making hard link, got %v", err)
+	}
+	afero.WriteFile(fs, "/etc/ssh.bak", []byte("id\n"), 0755)
+	if b, _ := afero.ReadFile(fs, "/etc/init.d/ssh"); string(b) != "id\n" {
+		t.Errorf("Content written through hard link, expect id, got %q", b)
+	}
+	if fi, _ := fs.Stat("/etc/init.d/ssh"); Nlink(fi) != 2 {
+		t.Errorf("Links of file with hard link, expect 2, got %v", Nlink(fi))
+	}
+	fi, _ := fs.Stat("/etc/init.d/ssh")
+	bak, _ := fs.Stat("/etc/ssh.bak")
+	if Inode("/etc/init.d/ssh", fi) != Inode("/etc/ssh.bak", bak) {
+		t.Errorf("Inode of hard links, expect the same, got %v and %v", Inode("/etc/init.d/ssh", fi), Inode("/etc/ssh.bak", bak))
+	}
+	if err := fs.Remove("/etc/init.d/ssh"); err != nil {
+		t.Fatalf("Removing file with hard link, got %v", err)
+	}
+	if b, _ := afero.ReadFile(fs, "/etc/ssh.bak"); string(b) != "id\n" {
+		t.Errorf("Content of hard link left, expect id, got %q", b)
+	}
+	if _, err := fs.Stat("/etc/rc2.d/S01ssh"); !os.IsNotExist(err) {
+		t.Errorf("Stat of dangling link, expect not exist, got %v", err)
+	}
+	if err := fs.Remove("/etc/rc2.d/S01ssh"); err != nil {
+		t.Errorf("Removing dangling link, got %v", err)
+	}
+	if _, err := Lstat(fs, "/etc/rc2.d/S01ssh"); !os.IsNotExist(err) {
+		t.Errorf("Lstat of link removed, expect not exist, got %v", err)
+	}
+}
+
+func TestFileTimes(t *testing.T) {
+	image := afero.NewMemMapFs()
+	afero.WriteFile(image, "/etc/passwd", []byte("root:x:0:0::/root:/bin/bash\n"), 0644)
+	old := time.Date(2017, 12, 15, 13, 51, 58, 0, time.UTC)
+	image.Chtimes("/etc/passwd", old, old)
+	fs := NewOwnerFs(afero.NewCopyOnWriteFs(afero.NewReadOnlyFs(image), afero.NewMemMapFs()))
+
+	fi, _ := fs.Stat("/etc/passwd")
+	if atime, mtime, ctime := FileTimes(fi); !atime.Equal(old) || !mtime.Equal(old) || !ctime.Equal(old) {
+		t.Errorf("Times of file from image, expect %v, got %v %v %v", old, atime, mtime, ctime)
+	}
+	fs.Chmod("/etc/passwd", 0600)
+	fi, _ = fs.Stat("/etc/passwd")
+	if _, mtime, ctime := FileTimes(fi); !mtime.Equal(old) || !ctime.After(old) {
+		t.Errorf("Times after chmod, expect change time only to move, got %v %v", mtime, ctime)
+	}
+	access := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	fs.Chtimes("/etc/passwd", access, old)
+	fi, _ = fs.Stat("/etc/passwd")
+	if atime, _, _ := FileTimes(fi); !atime.Equal(access) {
+		t.Errorf("Access time set, expect %v, got %v", access, atime)
+	}
+}
@@ -0,0 +1,236 @@
+package os
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+)
+
+// release is how the distribution describes itself, and the kernel it ships
+type release struct {
+	id, name, version, versionID, codename, pretty, like string
+	homeURL, supportURL, bugURL                          string
+	kernel, kernelVersion, builder                       string
+}
+
+var releases = map[string]release{
+	"ubuntu": {
+		id: "ubuntu", name: "Ubuntu", version: "16.04.7 LTS (Xenial Xerus)", versionID: "16.04", codename: "xenial",
+		pretty: "Ubuntu 16.04.7 LTS", like: "debian", homeURL: "http://www.ubuntu.com/",
+		supportURL: "http://help.ubuntu.com/", bugURL: "http://bugs.launchpad.net/ubuntu/",
+		kernel: "4.4.0-210-generic", kernelVersion: "#242-Ubuntu SMP Fri Apr 16 09:57:56 UTC 2021",
+		builder: "(buildd@lgw01-amd64-009) (gcc version 5.4.0 20160609 (Ubuntu 5.4.0-6ubuntu1~16.04.12) )",
+	},
+	"debian": {
+		id: "debian", name: "Debian GNU/Linux", version: "9 (stretch)", versionID: "9", codename: "stretch",
+		pretty: "Debian GNU/Linux 9 (stretch)", homeURL: "https://www.debian.org/",
+		supportURL: "https://www.debian.org/support", bugURL: "https://bugs.debian.org/",
+		kernel: "4.9.0-19-amd64", kernelVersion: "#1 SMP Debian 4.9.320-2 (2022-06-30)",
+		builder: "(debian-kernel@lists.debian.org) (gcc version 6.3.0 20170516 (Debian 6.3.0-18+deb9u1) )",
+	},
+	"centos": {
+		id: "centos", name: "CentOS Linux", version: "7 (Core)", versionID: "7", codename: "Core",
+		pretty: "CentOS Linux 7 (Core)", like: "rhel fedora", homeURL: "https://www.centos.org/",
+		bugURL: "https://bugs.centos.org/",
+		kernel: "3.10.0-1160.el7.x86_64", kernelVersion: "#1 SMP Mon Oct 19 16:18:59 UTC 2020",
+		builder: "(mockbuild@kbuilder.bsys.centos.org) (gcc version 4.8.5 20150623 (Red Hat 4.8.5-44) (GCC) )",
+	},
+	"alpine": {
+		id: "alpine", name: "Alpine Linux", versionID: "3.15.4", pretty: "Alpine Linux v3.15",
+		homeURL: "https://alpinelinux.org/", bugURL: "https://bugs.alpinelinux.org/",
+		kernel: "5.15.32-0-virt", kernelVersion: "#1-Alpine SMP Mon, 28 Mar 2022 13:09:17 +0000",
+		builder: "(buildozer@build-3-15-x86_64) (gcc (Alpine 10.3.1_git20211027) 10.3.1 20211027, GNU ld (GNU Binutils) 2.37)",
+	},
+}
+
+// Distro returns the Linux distribution the honeypot pretends to be, e.g.
+// ubuntu, debian, centos or alpine
+func Distro() string {
+	return strings.ToLower(viper.GetString("persona.distro"))
+}
+
+func distroRelease() release {
+	if r, ok := releases[Distro()]; ok {
+		return r
+	}
+	return releases["ubuntu"]
+}
+
+// IPAddress returns the address of the network interface of the machine
+func IPAddress() string {
+	return viper.GetString("persona.address")
+}
+
+// KernelRelease returns the release of the kernel, like 4.4.0-210-generic.
+// It is the stock kernel of the distribution unless set in persona config
+func KernelRelease() string {
+	if k := viper.GetString("persona.kernel"); k != "" {
+		return k
+	}
+	return distroRelease().kernel
+}
+
+// KernelVersion returns the build of the kernel, as in uname -v
+func KernelVersion() string {
+	if v := viper.GetString("persona.kernelVersion"); v != "" {
+		return v
+	}
+	return distroRelease().kernelVersion
+}
+
+// Arch returns the machine hardware name, like x86_64
+func Arch() string {
+	return viper.GetString("persona.arch")
+}
+
+// DistroName returns the release of the distribution as lsb_release shows,
+// with its ID, description, release number and codename
+func DistroName() (id, description, releaseNo, codename string) {
+	r := distroRelease()
+	return strings.Fields(r.name)[0], r.pretty, r.versionID, r.codename
+}
+
+// personaFiles are the files describing the system, generated from persona
+// so they agree with uname and each other
+func personaFiles(hostname string) map[string]string {
+	r := distroRelease()
+	files := map[string]string{
+		"/etc/hostname": hostname + "\n",
+		"/proc/version": fmt.Sprintf("Linux version %v %v %v\n", KernelRelease(), r.builder, KernelVersion()),
+		"/etc/fstab":    fstab(),
+		"/proc/cmdline": bootCmdline() + "\n",
+		// Generated when read, see generators
+		"/proc/mounts":  "",
+		"/etc/mtab":     "",
+		"/proc/uptime":  "",
+		"/proc/loadavg": "",
+		"/proc/cpuinfo": "",
+		"/proc/modules": "",
+		"/var/log/wtmp": string(EncodeUtmp(wtmpHistory(time.Now()))),
+		"/var/run/utmp": string(EncodeUtmp(utmpBoot())),
+	}
+	for name, content := range manualFiles() {
+		files[name] = content
+	}
+	var osRelease []string
+	add := func(key, value string, quoted bool) {
+		if value == "" {
+			return
+		}
+		if quoted {
+			value = `"` + value + `"`
+		}
+		osRelease = append(osRelease, key+"="+value)
+	}
+	add("NAME", r.name, true)
+	add("VERSION", r.version, true)
+	add("ID", r.id, r.id == "centos")
+	add("ID_LIKE", r.like, r.id == "centos")
+	add("VERSION_ID", r.versionID, r.id != "alpine")
+	add("PRETTY_NAME", r.pretty, true)
+	switch r.id {
+	case "centos":
+		add("ANSI_COLOR", "0;31", true)
+		add("CPE_NAME", "cpe:/o:centos:centos:7", true)
+		add("HOME_URL", r.homeURL, true)
+		add("BUG_REPORT_URL", r.bugURL, true)
+		osRelease = append(osRelease, "", `CENTOS_MANTISBT_PROJECT="CentOS-7"`, `CENTOS_MANTISBT_PROJECT_VERSION="7"`,
+			`REDHAT_SUPPORT_PRODUCT="centos"`, `REDHAT_SUPPORT_PRODUCT_VERSION="7"`, "")
+		files["/etc/centos-release"] = "CentOS Linux release 7.9.2009 (Core)\n"
+		files["/etc/redhat-release"] = files["/etc/centos-release"]
+		files["/etc/system-release"] = files["/etc/centos-release"]
+		files["/etc/issue"] = "\\S\nKernel \\r on an \\m\n\n"
+	case "alpine":
+		add("HOME_URL", r.homeURL, true)
+		add("BUG_REPORT_URL", r.bugURL, true)
+		files["/etc/alpine-release"] = r.versionID + "\n"
+		files["/etc/issue"] = "Welcome to Alpine Linux 3.15\nKernel \\r on an \\m (\\l)\n\n"
+	case "debian":
+		add("VERSION_CODENAME", r.codename, false)
+		add("ID_LIKE", r.like, false)
+		add("HOME_URL", r.homeURL, true)
+		add("SUPPORT_URL", r.supportURL, true)
+		add("BUG_REPORT_URL", r.bugURL, true)
+		files["/etc/debian_version"] = "9.13\n"
+		files["/etc/issue"] = "Debian GNU/Linux 9 \\n \\l\n\n"
+		files["/etc/issue.net"] = "Debian GNU/Linux 9\n"
+	default:
+		add("HOME_URL", r.homeURL, true)
+		add("SUPPORT_URL", r.supportURL, true)
+		add("BUG_REPORT_URL", r.bugURL, true)
+		add("UBUNTU_CODENAME", r.codename, false)
+		files["/etc/lsb-release"] = fmt.Sprintf("DISTRIB_ID=%v\nDISTRIB_RELEASE=%v\nDISTRIB_CODENAME=%v\nDISTRIB_DESCRIPTION=\"%v\"\n",
+			r.name, r.versionID, r.codename, r.pretty)
+		files["/etc/debian_version"] = "stretch/sid\n"
+		files["/etc/issue"] = r.pretty + " \\n \\l\n\n"
+		files["/etc/issue.net"] = r.pretty + "\n"
+	}
+	files["/etc/os-release"] = strings.Join(osRelease, "\n") + "\n"
+	if tz := viper.GetString("persona.timezone"); tz != "" && r.id != "centos" && r.id != "alpine" {
+		files["/etc/timezone"] = tz + "\n"
+	}
+	if r.id != "alpine" {
+		files["/etc/machine-id"] = MachineID() + "\n"
+		files[JournalPath()] = string(EncodeJournal(BootTime(), time.Now()))
+	}
+	return files
+}
+
+// bootCmdline is the command line the kernel was booted with, by the boot
+// loader of the distribution
+func bootCmdline() string {
+	root := "/dev/sda1"
+	for _, m := range configMounts() {
+		if m.Dir == "/" {
+			root = m.Device
+		}
+	}
+	if !strings.HasPrefix(root, "/dev/mapper/") {
+		root = "UUID=" + DiskUUID(root)
+	}
+	switch Distro() {
+	case "centos":
+		return fmt.Sprintf("BOOT_IMAGE=/vmlinuz-%v root=%v ro crashkernel=auto rd.lvm.lv=centos/root rd.lvm.lv=centos/swap rhgb quiet LANG=en_US.UTF-8",
+			KernelRelease(), root)
+	case "alpine":
+		return fmt.Sprintf("BOOT_IMAGE=vmlinuz-virt root=%v modules=sd-mod,usb-storage,ext4 quiet rootfstype=ext4", root)
+	case "debian":
+		return fmt.Sprintf("BOOT_IMAGE=/boot/vmlinuz-%v root=%v ro quiet", KernelRelease(), root)
+	}
+	return fmt.Sprintf("BOOT_IMAGE=/boot/vmlinuz-%v root=%v ro console=tty1 console=ttyS0 net.ifnames=0", KernelRelease(), root)
+}
+
+// NewPersonaFs lays the files generated from persona over the image, which
+// may come from another distribution or have them emptied. Files changing
+// with time, like those under /proc, are made each time they are read
+func NewPersonaFs(base afero.Fs, hostname string) afero.Fs {
+	layer := afero.NewMemMapFs()
+	dirs := map[string]bool{}
+	for name, content := range personaFiles(hostname) {
+		layer.MkdirAll(path.Dir(name), 0755)
+		afero.WriteFile(layer, name, []byte(content), 0644)
+		for dir := path.Dir(name); !dirs[dir]; dir = path.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+	// The directories of the layer hide those of the image, so they take
+	// their mode and time. Those missing in the image were made at boot, as
+	// was the root, which has no entry in the image
+	for dir := range dirs {
+		mode, mtime := os.ModeDir|0755, BootTime()
+		if dir == "/proc" {
+			mode = os.ModeDir | 0555
+		}
+		if fi, err := base.Stat(dir); err == nil && fi.IsDir() && dir != "/" {
+			mode, mtime = fi.Mode(), fi.ModTime()
+		}
+		layer.Chmod(dir, mode)
+		layer.Chtimes(dir, mtime, mtime)
+	}
+	return genFs{Fs: afero.NewCopyOnWriteFs(base, layer)}
+}
@@ -0,0 +1,38 @@
+package os
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+func TestPersonaFsDirs(t *testing.T) {
+	base := afero.NewMemMapFs()
+	base.MkdirAll("/etc", 0750)
+	mtime := time.Date(2018, 1, 10, 2, 6, 0, 0, time.UTC)
+	base.Chtimes("/etc", mtime, mtime)
+	fs := NewPersonaFs(base, "test")
+
+	tests := []struct {
+		dir   string
+		mode  os.FileMode
+		mtime time.Time
+	}{
+		{"/", os.ModeDir | 0755, BootTime()},
+		{"/etc", os.ModeDir | 0750, mtime},
+		{"/proc", os.ModeDir | 0555, BootTime()},
+		{"/var/log", os.ModeDir | 0755, BootTime()},
+	}
+	for _, tt := range tests {
+		fi, err := fs.Stat(tt.dir)
+		if err != nil {
+			t.Errorf("Stat of %v, got %v", tt.dir, err)
+			continue
+		}
+		if fi.Mode() != tt.mode || !fi.ModTime().Equal(tt.mtime) {
+			t.Errorf("Stat of %v, expect %v at %v, got %v at %v", tt.dir, tt.mode, tt.mtime, fi.Mode(), fi.ModTime())
+		}
+	}
+}
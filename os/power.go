@@ -0,0 +1,78 @@
+package os
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Disconnect is sent on the termination channel of the shell when the
+// session is dropped without exit status, as the machine going down does
+const Disconnect = -1
+
+// shutdownHooks are called when the machine goes down, guarded by bootMu
+var shutdownHooks []func(up time.Time)
+
+// OnShutdown calls f when the machine goes down, with the time it is up
+// again, so the server can drop the other sessions and refuse clients while
+// it boots
+func OnShutdown(f func(up time.Time)) {
+	bootMu.Lock()
+	defer bootMu.Unlock()
+	shutdownHooks = append(shutdownHooks, f)
+}
+
+// Shutdown takes the machine down to reboot or power off, as action says.
+// The sessions are dropped and the processes left running are gone. The
+// machine boots again after server.bootWindow, so BootTime is in the future
+// until then, and all clients are refused meanwhile
+func Shutdown(sys Sys, action string) {
+	window := viper.GetDuration("server.bootWindow")
+	sys.Log().WithField("action", action).WithField("bootWindow", window.String()).Warnf("User took the machine down to %v", action)
+	orphans.mu.Lock()
+	orphans.procs = map[int]ProcInfo{}
+	orphans.mu.Unlock()
+
+	bootMu.Lock()
+	bootTime = time.Now().Add(window)
+	up, hooks := bootTime, shutdownHooks
+	bootMu.Unlock()
+	for _, f := range hooks {
+		f(up)
+	}
+
+	proc, ok := sys.(*process)
+	if !ok || proc.shell == nil {
+		return
+	}
+	root := proc.shell
+	for sh := proc.shell; sh != nil; sh = sh.parent {
+		sh.exited, root = true, sh
+	}
+	select {
+	case root.termSignal <- Disconnect:
+	default:
+	}
+}
+
+// Wall writes the message to the terminal of the session as broadcast from
+// the user of sys, like wall(1) and the warnings of shutdown
+func Wall(sys Sys, msg string) {
+	proc, ok := sys.(*process)
+	if !ok || proc.shell == nil {
+		return
+	}
+	root := proc.shell
+	for root.parent != nil {
+		root = root.parent
+	}
+	if root.stdout == nil || !IsTerminal(root.stdout) {
+		return
+	}
+	from := GetUserByID(proc.userId).Name + "@" + proc.Hostname()
+	if tty := proc.ttyName(); tty != "?" {
+		from += " on " + tty
+	}
+	fmt.Fprintf(root.stdout, "\a\nBroadcast message from %v (%v):\n\n%v\n\n", from, Now(sys).Format("Mon 2006-01-02 15:04:05 MST"), msg)
+}
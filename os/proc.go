@@ -0,0 +1,350 @@
+package os
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// bootTime is when the honeypot pretends to have booted, a few days before
+// the server started. Rebooting the machine moves it
+var (
+	bootMu   sync.Mutex
+	bootTime = time.Now().Add(-72*time.Hour - time.Duration(rand.Int63n(int64(24*time.Hour))))
+)
+
+// MemTotal is the memory of the machine in kB
+const MemTotal = 2041248
+
+// BootTime returns when the machine was booted, for uptime and start time of
+// the daemons
+func BootTime() time.Time {
+	bootMu.Lock()
+	defer bootMu.Unlock()
+	return bootTime
+}
+
+// ProcInfo is an entry of the process table, as shown by ps and top
+type ProcInfo struct {
+	PID, PPID int
+	User      string
+	// TTY is the controlling terminal like pts/0, or ? for daemons
+	TTY string
+	// Stat is the process state code, e.g. S, R+ or Ss
+	Stat  string
+	Start time.Time
+	// Time is the CPU time used
+	Time time.Duration
+	// CPU and Mem are the percentage of CPU and memory used
+	CPU, Mem float64
+	// VSZ and RSS are the virtual and resident memory size in kB
+	VSZ, RSS int
+	// Cmd is the command line, with kernel threads in brackets
+	Cmd string
+}
+
+// Comm returns the name of the command, like the comm field of ps
+func (p ProcInfo) Comm() string {
+	if strings.HasPrefix(p.Cmd, "[") {
+		return strings.Trim(p.Cmd, "[]")
+	}
+	fields := strings.Fields(p.Cmd)
+	if len(fields) == 0 {
+		return ""
+	}
+	name := fields[0]
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(name, "-"), ":")
+}
+
+// procTable is the processes seen in the session: the daemons of the
+// system and the commands run by the client
+type procTable struct {
+	mu    sync.Mutex
+	procs map[int]ProcInfo
+	// noHangup is the processes outliving the session, with the function
+	// stopping those detached from the shell
+	noHangup map[int]context.CancelFunc
+}
+
+// orphans are the processes left running by the sessions closed, like the
+// ones started with nohup. init adopts them, and the later sessions see them
+var orphans = &procTable{procs: map[int]ProcInfo{}}
+
+// daemon is a process running since boot
+type daemon struct {
+	pid, ppid int
+	user      string
+	stat      string
+	vsz, rss  int
+	cmd       string
+}
+
+// kthreads are kernel threads, children of kthreadd
+var kthreads = []string{"ksoftirqd/0", "kworker/0:0H", "rcu_sched", "rcu_bh", "migration/0", "watchdog/0",
+	"kdevtmpfs", "netns", "perf", "khungtaskd", "writeback", "ksmd", "khugepaged", "crypto", "kintegrityd",
+	"bioset", "kblockd", "ata_sff", "md", "devfreq_wq", "kswapd0", "vmstat", "fsnotify_mark", "kthrotld",
+	"acpi_thermal_pm", "ipv6_addrconf", "deferwq", "scsi_eh_0", "scsi_tmf_0", "kworker/u2:2", "jbd2/sda1-8",
+	"ext4-rsv-conver", "kauditd"}
+
+var daemons = map[string][]daemon{
+	"ubuntu": {
+		{1, 0, "root", "Ss", 119748, 5968, "/sbin/init"},
+		{245, 1, "root", "Ss", 35276, 3820, "/lib/systemd/systemd-journald"},
+		{290, 1, "root", "Ss", 44508, 4012, "/lib/systemd/systemd-udevd"},
+		{560, 1, "systemd-timesync", "Ssl", 100324, 2532, "/lib/systemd/systemd-timesyncd"},
+		{803, 1, "syslog", "Ssl", 256396, 3188, "/usr/sbin/rsyslogd -n"},
+		{811, 1, "root", "Ss", 29008, 2848, "/usr/sbin/cron -f"},
+		{815, 1, "root", "Ss", 28548, 3064, "/lib/systemd/systemd-logind"},
+		{818, 1, "messagebus", "Ss", 42900, 3788, "/usr/bin/dbus-daemon --system --address=systemd: --nofork --nopidfile --systemd-activation"},
+		{822, 1, "root", "Ssl", 275864, 6148, "/usr/lib/accountsservice/accounts-daemon"},
+		{860, 1, "root", "Ss", 16124, 860, "/sbin/dhclient -1 -v -pf /run/dhclient.eth0.pid -lf /var/lib/dhcp/dhclient.eth0.leases eth0"},
+		{944, 1, "root", "Ss", 65512, 6180, "/usr/sbin/sshd -D"},
+		{960, 1, "root", "Ss+", 15936, 1540, "/sbin/agetty --noclear tty1 linux"},
+		{981, 1, "root", "Ss", 19472, 236, "/usr/sbin/irqbalance --pid=/var/run/irqbalance.pid"},
+		{1012, 1, "root", "Ssl", 1232716, 41152, "/usr/bin/containerd"},
+		{1187, 1, "root", "Ssl", 1418460, 88236, "/usr/bin/dockerd -H fd:// --containerd=/run/containerd/containerd.sock"},
+	},
+	"centos": {
+		{1, 0, "root", "Ss", 125480, 3944, "/usr/lib/systemd/systemd --switched-root --system --deserialize 22"},
+		{339, 1, "root", "Ss", 39060, 6648, "/usr/lib/systemd/systemd-journald"},
+		{362, 1, "root", "Ss", 46488, 2468, "/usr/lib/systemd/systemd-udevd"},
+		{449, 1, "root", "S<sl", 55520, 1108, "/sbin/auditd"},
+		{472, 1, "polkitd", "Ssl", 612236, 13100, "/usr/lib/polkit-1/polkitd --no-debug"},
+		{473, 1, "root", "Ssl", 358356, 27748, "/usr/bin/python2 -Es /usr/sbin/firewalld --nofork --nopid"},
+		{474, 1, "dbus", "Ssl", 58116, 2312, "/usr/bin/dbus-daemon --system --address=systemd: --nofork --nopidfile --systemd-activation"},
+		{476, 1, "chrony", "S", 117808, 1760, "/usr/sbin/chronyd"},
+		{479, 1, "root", "Ss", 26376, 1744, "/usr/lib/systemd/systemd-logind"},
+		{481, 1, "root", "Ss", 126384, 1664, "/usr/sbin/crond -n"},
+		{489, 1, "root", "Ss+", 110208, 864, "/sbin/agetty --noclear tty1 linux"},
+		{523, 1, "root", "Ssl", 553160, 9620, "/usr/sbin/NetworkManager --no-daemon"},
+		{872, 1, "root", "Ssl", 574284, 17396, "/usr/bin/python2 -Es /usr/sbin/tuned -l -P"},
+		{874, 1, "root", "Ss", 112988, 4304, "/usr/sbin/sshd -D"},
+		{876, 1, "root", "Ssl", 216400, 4920, "/usr/sbin/rsyslogd -n"},
+		{1034, 1, "root", "Ssl", 1233228, 39712, "/usr/bin/containerd"},
+		{1206, 1, "root", "Ssl", 1420288, 86904, "/usr/bin/dockerd -H fd:// --containerd=/run/containerd/containerd.sock"},
+	},
+	"alpine": {
+		{1, 0, "root", "S", 1624, 4, "/sbin/init"},
+		{265, 1, "root", "S", 1624, 4, "/sbin/syslogd -t -n"},
+		{298, 1, "root", "S", 1624, 4, "/usr/sbin/crond -c /etc/crontabs"},
+		{331, 1, "root", "S", 4336, 4, "/usr/sbin/sshd"},
+		{350, 1, "root", "S", 1624, 4, "/sbin/getty 38400 tty1"},
+	},
+}
+
+func newProcTable() *procTable {
+	t := &procTable{procs: map[int]ProcInfo{}}
+	distro := Distro()
+	list, ok := daemons[distro]
+	if !ok {
+		list = daemons["ubuntu"]
+	}
+	boot := BootTime()
+	r := rand.New(rand.NewSource(boot.Unix()))
+	for _, d := range list {
+		p := ProcInfo{
+			PID: d.pid, PPID: d.ppid, User: d.user, TTY: "?", Stat: d.stat, Start: boot,
+			Time: time.Duration(r.Intn(30)) * time.Second, VSZ: d.vsz, RSS: d.rss, Cmd: d.cmd,
+		}
+		// getty is the only daemon on a terminal
+		if strings.HasSuffix(d.stat, "+") {
+			p.TTY = "tty1"
+		}
+		t.procs[d.pid] = p
+	}
+	if distro != "alpine" {
+		t.procs[2] = ProcInfo{PID: 2, User: "root", TTY: "?", Stat: "S", Start: boot, Cmd: "[kthreadd]"}
+		for i, name := range kthreads {
+			pid := 3 + i
+			if i > 25 {
+				pid = 100 + i*7
+			}
+			t.procs[pid] = ProcInfo{PID: pid, PPID: 2, User: "root", TTY: "?", Stat: "S", Start: boot, Cmd: "[" + name + "]"}
+		}
+	}
+	for _, p := range orphans.list() {
+		t.procs[p.PID] = p
+		t.keepOnHangup(p.PID, nil)
+	}
+	return t
+}
+
+func (t *procTable) add(p ProcInfo) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.procs[p.PID] = p
+}
+
+func (t *procTable) remove(pid int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.procs, pid)
+	delete(t.noHangup, pid)
+}
+
+// keepOnHangup marks the process to outlive the session. stop is the function
+// stopping it if it is detached from the shell, nil for the others
+func (t *procTable) keepOnHangup(pid int, stop context.CancelFunc) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.noHangup == nil {
+		t.noHangup = map[int]context.CancelFunc{}
+	}
+	if old, ok := t.noHangup[pid]; !ok || old == nil {
+		t.noHangup[pid] = stop
+	}
+}
+
+// ignoresHangup tells if the process outlives the session, and returns the
+// function stopping it if it is detached
+func (t *procTable) ignoresHangup(pid int) (stop context.CancelFunc, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stop, ok = t.noHangup[pid]
+	return
+}
+
+func (t *procTable) get(pid int) (ProcInfo, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.procs[pid]
+	return p, ok
+}
+
+// setStat changes the state shown by ps, like T for stopped process
+func (t *procTable) setStat(pid int, stat string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if p, ok := t.procs[pid]; ok {
+		p.Stat = stat
+		t.procs[pid] = p
+	}
+}
+
+// hangup adopts the processes outliving the session as orphans, and stops
+// those detached since nothing runs them anymore. The processes adopted are
+// returned
+func (t *procTable) hangup() []ProcInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var adopted []ProcInfo
+	for pid, stop := range t.noHangup {
+		if stop != nil {
+			stop()
+		}
+		p, ok := t.procs[pid]
+		if _, old := orphans.get(pid); !ok || old {
+			continue
+		}
+		p.PPID, p.TTY, p.Stat = 1, "?", strings.TrimSuffix(p.Stat, "+")
+		orphans.add(p)
+		adopted = append(adopted, p)
+	}
+	sort.Slice(adopted, func(i, j int) bool { return adopted[i].PID < adopted[j].PID })
+	return adopted
+}
+
+// list returns the processes ordered by pid
+func (t *procTable) list() []ProcInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	procs := make([]ProcInfo, 0, len(t.procs))
+	for _, p := range t.procs {
+		p.Mem = float64(p.RSS) * 100 / MemTotal
+		procs = append(procs, p)
+	}
+	sort.Slice(procs, func(i, j int) bool { return procs[i].PID < procs[j].PID })
+	return procs
+}
+
+// sshdPid is the pid of the sshd listening for connections, the parent of
+// the session processes
+func (t *procTable) sshdPid() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, p := range t.procs {
+		if strings.HasPrefix(p.Cmd, "/usr/sbin/sshd") {
+			return p.PID
+		}
+	}
+	return 1
+}
+
+// Processes returns the process table of the session, with the docker-proxy
+// of the ports published by the containers
+func (sys *System) Processes() []ProcInfo {
+	procs := sys.procs.list()
+	proxies, _ := sys.dockerProxies()
+	if len(proxies) == 0 {
+		return procs
+	}
+	for _, p := range proxies {
+		p.Mem = float64(p.RSS) * 100 / MemTotal
+		procs = append(procs, p)
+	}
+	sort.Slice(procs, func(i, j int) bool { return procs[i].PID < procs[j].PID })
+	return procs
+}
+
+// ttyName is the terminal the session is attached to
+func (sys *System) ttyName() string {
+	if sys.termios == nil {
+		return "?"
+	}
+	return "pts/0"
+}
+
+// startSession adds sshd and the login shell of the session to the process
+// table
+func (sh *Shell) startSession(cmd string) {
+	sys := sh.sys
+	user := GetUserByID(sys.userId).Name
+	term := sys.ttyName()
+	if term == "?" {
+		term = "notty"
+	}
+	sys.procs.add(ProcInfo{PID: sh.sshdPid, PPID: sys.procs.sshdPid(), User: "root", TTY: "?", Stat: "Ss",
+		Start: time.Now(), VSZ: 95368, RSS: 6780, Cmd: "sshd: " + user + "@" + term})
+	sys.addConnection(sh.src, sh.sshdPid, "sshd: "+user+"@"+term)
+	sys.procs.add(ProcInfo{PID: sh.pid, PPID: sh.sshdPid, User: user, TTY: sys.ttyName(), Stat: "Ss",
+		Start: time.Now(), VSZ: 21312, RSS: 5128, Cmd: cmd})
+}
+
+// startProcess adds the command run by the shell to the process table, and
+// returns the function removing it once the command exits
+func (sh *Shell) startProcess(pid int, args []string, proc *process) (exit func()) {
+	stat, tty, ppid := "S", sh.sys.ttyName(), sh.pid
+	if !proc.background && sh.sys.termios != nil {
+		stat += "+"
+	}
+	if proc.detached {
+		// setsid forks it off, so init adopts it
+		tty, ppid = "?", 1
+	}
+	if args[0] == "ps" || args[0] == "top" || args[0] == "htop" {
+		// It is the one running when the table is read
+		stat = "R" + stat[1:]
+	}
+	// Memory used by the command is made up but stays the same every time
+	size := 0
+	for _, c := range args[0] {
+		size = size*31 + int(c)
+	}
+	if size < 0 {
+		size = -size
+	}
+	sh.sys.procs.add(ProcInfo{
+		PID: pid, PPID: ppid, User: GetUserByID(proc.userId).Name, TTY: tty, Stat: stat,
+		Start: time.Now(), VSZ: 7000 + size%30000, RSS: 700 + size%3000, Cmd: strings.Join(args, " "),
+	})
+	if proc.noHangup {
+		sh.sys.procs.keepOnHangup(pid, nil)
+	}
+	return func() { sh.sys.procs.remove(pid) }
+}
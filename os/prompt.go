@@ -0,0 +1,86 @@
+package os
+
+import (
+	"bytes"
+	pathlib "path"
+	"strconv"
+	"strings"
+)
+
+// prompt renders $PS1 for the next command
+func (sh *Shell) prompt() string {
+	return sh.renderPrompt(sh.getVar("PS1"))
+}
+
+// renderPrompt expands the backslash escapes in prompt string the way bash
+// does, e.g. \u for username and \w for working directory
+func (sh *Shell) renderPrompt(ps string) string {
+	var buf bytes.Buffer
+	for i := 0; i < len(ps); i++ {
+		if ps[i] != '\\' || i+1 >= len(ps) {
+			buf.WriteByte(ps[i])
+			continue
+		}
+		i++
+		switch c := ps[i]; c {
+		case 'u':
+			buf.WriteString(GetUserByID(sh.sys.CurrentUser()).Name)
+		case 'h':
+			buf.WriteString(strings.SplitN(sh.sys.Hostname(), ".", 2)[0])
+		case 'H':
+			buf.WriteString(sh.sys.Hostname())
+		case 'w', 'W':
+			cwd := sh.sys.Getcwd()
+			home := sh.getVar("HOME")
+			switch {
+			case len(home) > 1 && (cwd == home || strings.HasPrefix(cwd, home+"/")):
+				if c == 'W' && cwd != home {
+					cwd = pathlib.Base(cwd)
+				} else {
+					cwd = "~" + cwd[len(home):]
+				}
+			case c == 'W':
+				cwd = pathlib.Base(cwd)
+			}
+			buf.WriteString(cwd)
+		case '$':
+			if sh.sys.CurrentUser() == 0 {
+				buf.WriteByte('#')
+			} else {
+				buf.WriteByte('$')
+			}
+		case 's':
+			buf.WriteString("bash")
+		case 'v':
+			buf.WriteString("4.4")
+		case 't':
+			buf.WriteString(Now(sh.sys).Format("15:04:05"))
+		case 'T':
+			buf.WriteString(Now(sh.sys).Format("03:04:05"))
+		case 'A':
+			buf.WriteString(Now(sh.sys).Format("15:04"))
+		case 'd':
+			buf.WriteString(Now(sh.sys).Format("Mon Jan 02"))
+		case 'n':
+			buf.WriteByte('\n')
+		case 'e':
+			buf.WriteByte(0x1b)
+		case 'a':
+			buf.WriteByte('\a')
+		case '[', ']':
+			// Markers of non-printing sequence, terminal handles it already
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			end := i
+			for end < len(ps) && end-i < 3 && ps[end] >= '0' && ps[end] <= '7' {
+				end++
+			}
+			n, _ := strconv.ParseUint(ps[i:end], 8, 8)
+			buf.WriteByte(byte(n))
+			i = end - 1
+		default:
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String()
+}
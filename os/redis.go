@@ -0,0 +1,39 @@
+package os
+
+import "sync"
+
+// RedisServer is the data of a Redis server redis-cli connects to. It is kept
+// for the session, so what one run of the client sets the next one sees
+type RedisServer struct {
+	// DBs are the strings set in each database, by key
+	DBs    map[int]map[string]string
+	Config map[string]string
+}
+
+// redisState is the Redis servers of the session, by address
+type redisState struct {
+	mu      sync.Mutex
+	servers map[string]*RedisServer
+}
+
+// UpdateRedis runs f with the server at the address, for f to read or change
+// it. The server is made by seed the first time it is reached
+func UpdateRedis(sys Sys, addr string, seed func() *RedisServer, f func(s *RedisServer)) {
+	proc, ok := sys.(*process)
+	if !ok || proc.redis == nil {
+		f(seed())
+		return
+	}
+	r := proc.redis
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.servers == nil {
+		r.servers = map[string]*RedisServer{}
+	}
+	s, ok := r.servers[addr]
+	if !ok {
+		s = seed()
+		r.servers[addr] = s
+	}
+	f(s)
+}
@@ -0,0 +1,211 @@
+package os
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	pathlib "path"
+	"strings"
+
+	"github.com/mkishere/sshsyrup/util/terminal"
+)
+
+// Credential is the user a command runs as, for commands like sudo and su
+type Credential struct {
+	UID int
+	// Login starts from the environment and home directory of the user,
+	// like su - does
+	Login bool
+	// Env is added to the environment of the command
+	Env map[string]string
+	// Clear starts the command with empty environment before adding Env,
+	// and Unset removes the variables from it, as env -i and -u do
+	Clear bool
+	Unset []string
+	// Hostname changes the host the command appears to run on, for commands
+	// like ssh landing on another host
+	Hostname string
+	// Dir is the working directory of the command, the current one if empty
+	Dir string
+	// Stdin, Stdout and Stderr replace the standard I/O of the command if
+	// set, for commands like awk reading the output of commands they run
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	// Context stops the command once done, for commands stopping what they
+	// run on their own like watch on Ctrl-C
+	Context context.Context
+	// NoHangup keeps the command running once the session closes, as nohup
+	// does. The command takes the pid of the caller like exec. Detach also
+	// takes it off the terminal and returns without waiting for it, as
+	// setsid does
+	NoHangup bool
+	Detach   bool
+}
+
+// RunAs runs the command as the user. Without command the shell is started,
+// reading commands from the terminal until exit. found is false if the
+// command does not exist, which includes shell builtins
+func RunAs(sys Sys, cred Credential, args []string) (n int, found bool) {
+	proc, ok := sys.(*process)
+	if !ok || proc.shell == nil {
+		return 127, false
+	}
+	name := "bash"
+	if cred.Login {
+		name = "-bash"
+	}
+	sh := proc.shell.subshell(proc.System, name, nil)
+	sh.pid = newPid()
+	u := GetUserByID(cred.UID)
+	sh.sys.userId = cred.UID
+	if cred.Hostname != "" {
+		host := cred.Hostname
+		sh.sys.hostName = &host
+	}
+	if cred.Login {
+		env := map[string]string{
+			"HOME":    u.Homedir,
+			"USER":    u.Name,
+			"LOGNAME": u.Name,
+			"SHELL":   u.Shell,
+			"PATH":    "/usr/local/bin:/usr/bin:/bin:/usr/local/games:/usr/games",
+		}
+		if cred.UID == 0 {
+			env["PATH"] = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+		}
+		for _, k := range []string{"TERM", "LANG", "PS1", "PS2"} {
+			if v, exists := sh.sys.envVars[k]; exists {
+				env[k] = v
+			}
+		}
+		sh.sys.envVars, sh.sys.exports = env, map[string]bool{}
+		for k := range env {
+			sh.sys.exports[k] = k != "PS1" && k != "PS2"
+		}
+		if sh.sys.Chdir(u.Homedir) != nil {
+			sh.sys.cwd = "/"
+		}
+		sh.sys.envVars["PWD"] = sh.sys.cwd
+	}
+	if cred.Dir != "" && sh.sys.Chdir(cred.Dir) == nil {
+		sh.sys.envVars["PWD"] = sh.sys.cwd
+	}
+	if cred.Clear {
+		sh.sys.envVars, sh.sys.exports = map[string]string{}, map[string]bool{}
+	}
+	for _, k := range cred.Unset {
+		delete(sh.sys.envVars, k)
+		delete(sh.sys.exports, k)
+	}
+	for k, v := range cred.Env {
+		sh.sys.envVars[k], sh.sys.exports[k] = v, true
+	}
+	child := proc.fork(sh)
+	if cred.Stdin != nil {
+		child.in = cred.Stdin
+	}
+	if cred.Stdout != nil {
+		child.out = cred.Stdout
+	}
+	if cred.Stderr != nil {
+		child.err = cred.Stderr
+	}
+	if cred.Context != nil {
+		child.ctx = cred.Context
+	}
+	child.noHangup = child.noHangup || cred.NoHangup || cred.Detach
+	if len(args) > 0 {
+		if !sh.commandExists(args[0]) {
+			return 127, false
+		}
+		if cred.Detach {
+			// The command is only stopped by signals, or dropped with the
+			// session closing
+			ctx, cancel := context.WithCancel(context.Background())
+			child.ctx, child.pid, child.background, child.detached = ctx, newPid(), true, true
+			if cred.Stdin == nil {
+				child.in = strings.NewReader("")
+			}
+			proc.procs.keepOnHangup(child.pid, cancel)
+			go func() {
+				defer cancel()
+				defer func() {
+					if r := recover(); r != nil {
+						sh.log.Errorf("Recovered from panic in detached process %v", r)
+					}
+				}()
+				sh.run(args, child)
+			}()
+			return 0, true
+		}
+		if cred.NoHangup {
+			child.pid, sh.pid = proc.pid, proc.shell.pid
+		}
+		return sh.run(args, child), true
+	}
+	defer proc.shell.startProcess(sh.pid, []string{name}, child)()
+	return sh.interact(child, cred.Login), true
+}
+
+// commandExists tells if the command can be run, which are the commands
+// simulated and executables found in $PATH
+func (sh *Shell) commandExists(name string) bool {
+	if _, builtin := builtins[name]; builtin {
+		// Unless there is also the executable like /bin/pwd
+		_, ok := funcMap[name]
+		return ok
+	}
+	if _, ok := funcMap[pathlib.Base(name)]; ok {
+		return true
+	}
+	if _, ok := fakeFuncList[pathlib.Base(name)]; ok {
+		return true
+	}
+	if strings.Contains(name, "/") {
+		if !pathlib.IsAbs(name) {
+			name = pathlib.Join(sh.sys.Getcwd(), name)
+		}
+		_, err := sh.sys.FSys().Stat(name)
+		return err == nil
+	}
+	return sh.lookPath(name) != ""
+}
+
+// interact runs the shell started by a command, reading commands from the
+// terminal until exit or Ctrl-D. The commands are read from the input if it
+// is not the terminal
+func (sh *Shell) interact(proc *process, login bool) int {
+	if login {
+		sh.runProfile(proc)
+	} else {
+		sh.runStartupFile(sh.getVar("HOME")+"/.bashrc", proc)
+	}
+	if sh.terminal == nil || !IsTerminal(proc.In()) {
+		script, _ := ioutil.ReadAll(proc.In())
+		return sh.runScript(string(script), proc)
+	}
+	sh.interactive = true
+	sh.stdin, sh.stdout, sh.stderr = proc.In(), proc.Out(), proc.Err()
+	sh.more = sh.readMore
+	for !sh.exited {
+		cmd, err := sh.readCommand()
+		switch {
+		case err == terminal.ErrInterrupt:
+			sh.status = 130
+			continue
+		case err != nil:
+			// Ctrl-D leaves the shell like exit does
+			if login {
+				fmt.Fprintln(proc.Err(), "logout")
+			} else {
+				fmt.Fprintln(proc.Err(), "exit")
+			}
+			return sh.status
+		}
+		sh.addHistory(cmd)
+		sh.ExecLine(cmd)
+	}
+	return sh.exitStatus
+}
@@ -0,0 +1,308 @@
+package os
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	pathlib "path"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+// maxScriptSize limits how much of a file is read for interpreting
+const maxScriptSize = 1 << 20
+
+// shellNames are interpreters in shebang that the scripts can be run with
+var shellNames = map[string]bool{
+	"ash":  true,
+	"bash": true,
+	"dash": true,
+	"sh":   true,
+}
+
+// shellCommand is the sh/bash executable, which runs the script in the
+// arguments with the interpreter of the shell it is invoked from
+type shellCommand struct {
+	path string
+}
+
+func init() {
+	RegisterCommand("sh", shellCommand{"/bin/sh"})
+	RegisterCommand("bash", shellCommand{"/bin/bash"})
+	RegisterCommand("dash", shellCommand{"/bin/dash"})
+}
+
+func (c shellCommand) GetHelp() string { return "" }
+
+func (c shellCommand) Where() string { return c.path }
+
+func (c shellCommand) Exec(args []string, sys Sys) int {
+	proc, ok := sys.(*process)
+	if !ok || proc.shell == nil {
+		return 0
+	}
+	// Options like -x does not change how the script runs here
+	cmdString := false
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && args[0] != "-" {
+		if strings.HasPrefix(args[0], "--") {
+			args = args[1:]
+			continue
+		}
+		cmdString = cmdString || strings.Contains(args[0], "c")
+		args = args[1:]
+	}
+	if cmdString {
+		if len(args) == 0 {
+			fmt.Fprintf(proc.Err(), "%v: -c: option requires an argument\n", pathlib.Base(c.path))
+			return 2
+		}
+		// bash -c 'command' name args..., where name becomes $0
+		name, posArgs := pathlib.Base(c.path), []string(nil)
+		if len(args) > 1 {
+			name, posArgs = args[1], args[2:]
+		}
+		proc.shell.log.WithField("cmd", args[0]).Infof("Command string executed by %v", pathlib.Base(c.path))
+		sub := proc.shell.subshell(proc.System, name, posArgs)
+		sub.pid, sub.commandString = proc.pid, true
+		return sub.runScript(args[0], proc)
+	}
+	if len(args) == 0 || args[0] == "-" {
+		// Script from stdin, e.g. curl http://x/a.sh | sh
+		script, _ := ioutil.ReadAll(proc.In())
+		sub := proc.shell.subshell(proc.System, pathlib.Base(c.path), nil)
+		sub.pid = proc.pid
+		return sub.runScript(string(script), proc)
+	}
+	script, err := proc.shell.readScript(args[0])
+	if err != nil {
+		fmt.Fprintf(proc.Err(), "%v: %v: No such file or directory\n", pathlib.Base(c.path), args[0])
+		return 127
+	}
+	proc.shell.log.WithField("path", args[0]).Infof("Script %v executed by %v", args[0], pathlib.Base(c.path))
+	sub := proc.shell.subshell(proc.System, args[0], args[1:])
+	sub.pid = proc.pid
+	return sub.runScript(script, proc)
+}
+
+// execFile runs the file as executable from the virtual filesystem. found is
+// false if the command does not point to a file
+func (sh *Shell) execFile(args []string, proc *process) (n int, found bool) {
+	name := args[0]
+	p := name
+	if !strings.Contains(name, "/") {
+		if p = sh.lookPath(name); p == "" {
+			return 127, false
+		}
+	} else if !pathlib.IsAbs(p) {
+		p = pathlib.Join(sh.sys.Getcwd(), p)
+	}
+	fi, err := sh.sys.FSys().Stat(p)
+	switch {
+	case err != nil:
+		sh.errorf(proc, "%v: No such file or directory", name)
+		return 127, true
+	case fi.IsDir():
+		sh.errorf(proc, "%v: Is a directory", name)
+		return 126, true
+	case !sh.sys.executable(p, fi):
+		sh.errorf(proc, "%v: Permission denied", name)
+		return 126, true
+	}
+	script, err := sh.readScript(p)
+	if err != nil {
+		sh.errorf(proc, "%v: Permission denied", name)
+		return 126, true
+	}
+	sh.log.WithFields(log.Fields{
+		"path": p,
+		"args": args[1:],
+	}).Infof("User executed file %v", p)
+
+	if strings.HasPrefix(script, "\x7fELF") {
+		if proc.noHangup {
+			// Binaries left running, like the miners started with nohup,
+			// seem to run until killed
+			<-proc.Context().Done()
+			return 130, true
+		}
+		// We can't run binaries, so pretend it crashed
+		fmt.Fprintln(proc.Err(), "Segmentation fault (core dumped)")
+		return 139, true
+	}
+	if strings.HasPrefix(script, "#!") {
+		shebang := script[2:]
+		if i := strings.IndexByte(shebang, '\n'); i >= 0 {
+			shebang = shebang[:i]
+		}
+		interp := strings.Fields(shebang)
+		if len(interp) > 1 && pathlib.Base(interp[0]) == "env" {
+			interp = interp[1:]
+		}
+		if len(interp) > 0 && !shellNames[pathlib.Base(interp[0])] {
+			n, err := sh.sys.exec(interp[0], append(append(interp[1:], p), args[1:]...), proc)
+			if err != nil {
+				sh.errorf(proc, "%v: %v: bad interpreter: No such file or directory", name, interp[0])
+				return 126, true
+			}
+			return n, true
+		}
+	}
+	sub := sh.subshell(proc.System, name, args[1:])
+	sub.pid = proc.pid
+	return sub.runScript(script, proc), true
+}
+
+// lookPath searches the directories in $PATH for the executable
+func (sh *Shell) lookPath(name string) string {
+	for _, dir := range strings.Split(sh.getVar("PATH"), ":") {
+		if dir == "" {
+			continue
+		}
+		p := pathlib.Join(dir, name)
+		fi, err := sh.sys.FSys().Stat(p)
+		// Empty files in the image are placeholders of binaries we don't simulate
+		if err == nil && fi.Mode().IsRegular() && fi.Mode()&0111 != 0 && fi.Size() > 0 {
+			return p
+		}
+	}
+	return ""
+}
+
+// executables finds what the name runs as in each directory of path, in
+// the order searched. They are the files in the image, the simulated
+// commands installed in the directory and the fake ones, which are in
+// /usr/bin
+func executables(fs afero.Fs, path, name string) []string {
+	var found []string
+	if name == "" || strings.Contains(name, "/") {
+		return nil
+	}
+	seen := map[string]bool{}
+	for _, dir := range strings.Split(path, ":") {
+		if dir == "" || seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		p := pathlib.Join(dir, name)
+		fi, err := fs.Stat(p)
+		_, simulated := funcMap[p]
+		_, fake := fakeFuncList[name]
+		switch {
+		case err == nil && fi.Mode().IsRegular() && fi.Mode()&0111 != 0 && fi.Size() > 0:
+		case simulated && funcMap[p].Where() == p:
+		case fake && dir == "/usr/bin":
+		default:
+			continue
+		}
+		found = append(found, p)
+	}
+	return found
+}
+
+// LookPath finds the executables of the name in the directories of path,
+// which is colon separated like $PATH, in the order the shell searches them
+func LookPath(sys Sys, path, name string) []string {
+	return executables(sys.FSys(), path, name)
+}
+
+func (sh *Shell) readScript(p string) (string, error) {
+	if !pathlib.IsAbs(p) {
+		p = pathlib.Join(sh.sys.Getcwd(), p)
+	}
+	f, err := sh.sys.FSys().Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	b, err := ioutil.ReadAll(&io.LimitedReader{R: f, N: maxScriptSize})
+	return string(b), err
+}
+
+// subshell creates a copy of the shell for running scripts, so that changes
+// to variables and working directory won't affect the parent. sys is the
+// system of the process starting the subshell
+func (sh *Shell) subshell(sys *System, name string, args []string) *Shell {
+	sys = sys.clone()
+	// Aliases are not expanded in non-interactive shell
+	sys.aliases = map[string]string{}
+	return &Shell{
+		log:        sh.log,
+		termSignal: sh.termSignal,
+		terminal:   sh.terminal,
+		tty:        sh.tty,
+		sys:        sys,
+		stdin:      sh.stdin,
+		stdout:     sh.stdout,
+		stderr:     sh.stderr,
+		name:       name,
+		args:       args,
+		pid:        sh.pid,
+		status:     sh.status,
+		parent:     sh,
+	}
+}
+
+// runScript executes the script line by line in the shell
+func (sh *Shell) runScript(script string, parent *process) int {
+	proc := parent.fork(sh)
+	lines := strings.Split(script, "\n")
+	i := 0
+	more := sh.more
+	sh.more = func() (string, error) {
+		if i+1 >= len(lines) {
+			return "", io.EOF
+		}
+		i++
+		sh.lineNo = i + 1
+		return strings.TrimSuffix(lines[i], "\r"), nil
+	}
+	defer func() {
+		sh.more = more
+	}()
+	n := 0
+	for ; i < len(lines) && !sh.exited && proc.Context().Err() == nil; i++ {
+		sh.lineNo = i + 1
+		line := strings.TrimSuffix(lines[i], "\r")
+		for continued(line) && i+1 < len(lines) {
+			i++
+			line = joinLines(line, strings.TrimSuffix(lines[i], "\r"))
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		sh.log.WithFields(log.Fields{
+			"script": sh.name,
+			"cmd":    line,
+		}).Infof("Script running command %v", line)
+		n = sh.execLine(line, proc)
+	}
+	if sh.exited {
+		return sh.exitStatus
+	}
+	return n
+}
+
+func builtinSource(sh *Shell, args []string, proc *process) int {
+	if len(args) < 2 {
+		sh.errorf(proc, "%v: filename argument required", args[0])
+		fmt.Fprintf(proc.Err(), "%v: usage: %v filename [arguments]\n", args[0], args[0])
+		return 2
+	}
+	script, err := sh.readScript(args[1])
+	if err != nil {
+		sh.errorf(proc, "%v: No such file or directory", args[1])
+		return 1
+	}
+	sh.log.WithField("path", args[1]).Infof("User sourced script %v", args[1])
+	lineNo, posArgs, sourcing := sh.lineNo, sh.args, sh.sourcing
+	if len(args) > 2 {
+		sh.args = args[2:]
+	}
+	sh.sourcing = args[1]
+	defer func() {
+		sh.lineNo, sh.args, sh.sourcing = lineNo, posArgs, sourcing
+	}()
+	return sh.runScript(script, proc)
+}
@@ -1,14 +1,19 @@
 package os
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"os"
+	pathlib "path"
 	"strings"
+	"sync"
 
-	"github.com/mattn/go-shellwords"
+	"github.com/mkishere/sshsyrup/util/terminal"
 	"github.com/mkishere/sshsyrup/util/termlogger"
 	log "github.com/sirupsen/logrus"
-	"golang.org/x/crypto/ssh/terminal"
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
 )
 
 type Shell struct {
@@ -17,14 +22,57 @@ type Shell struct {
 	terminal   *terminal.Terminal
 	sys        *System
 	DelayFunc  func()
+	stdin      io.Reader
+	stdout     io.Writer
+	stderr     io.Writer
+
+	// name is $0 and args are positional parameters of the shell
+	name string
+	args []string
+	pid  int
+	// sshdPid is the pid of sshd process serving the session
+	sshdPid     int
+	status      int
+	lastJobPid  int
+	interactive bool
+	lineNo      int
+	exited      bool
+	exitStatus  int
+	parent      *Shell
+	jobs        []*job
+	// more reads the next line of input, for commands spanning multiple
+	// lines like here-document
+	more func() (string, error)
+	tty  *tty
+	// commandString is set when running the command string of bash -c,
+	// where errors are not prefixed with line number
+	commandString bool
+	// sourcing is the file being run by source, for error messages
+	sourcing string
+	src      string
+	// histAdded tells if the line being run was saved to history, which
+	// history -s replaces like bash does
+	histAdded bool
 }
 
 func NewShell(sys *System, ipSrc string, log *log.Entry, termSignal chan<- int) *Shell {
+	if _, exists := sys.envVars["PS1"]; !exists {
+		sys.envVars["PS1"] = viper.GetString("server.prompt")
+	}
+	if _, exists := sys.envVars["PS2"]; !exists {
+		sys.envVars["PS2"] = "> "
+	}
 
+	sshdPid := newPid()
 	return &Shell{
-		log:        log,
-		termSignal: termSignal,
-		sys:        sys,
+		log:         log,
+		termSignal:  termSignal,
+		sys:         sys,
+		name:        "-bash",
+		sshdPid:     sshdPid,
+		pid:         newPid(),
+		interactive: true,
+		src:         ipSrc,
 	}
 }
 
@@ -33,28 +81,42 @@ func (sh *Shell) HandleRequest(hook termlogger.LogHook) {
 	tLog := termlogger.NewLogger(hook, sh.sys.In(), sh.sys.Out(), sh.sys.Err())
 	defer tLog.Close()
 
+	sh.stdout = ttyWriter{stdoutWrapper{tLog.Out()}}
+	sh.stderr = ttyWriter{stdoutWrapper{tLog.Err()}}
+	sh.tty = newTTY(tLog.In(), sh.stdout, sh.sys.termios)
+	sh.stdin = sh.tty
 	sh.terminal = terminal.NewTerminal(struct {
 		io.Reader
 		io.Writer
 	}{
-		tLog.In(),
+		sh.tty,
 		tLog.Out(),
-	}, "$ ")
+	}, sh.prompt())
+	sh.terminal.SetBracketedPasteMode(true)
+	// Line editor follows the window size, like running commands do
+	sh.terminal.SetSize(sh.sys.Width(), sh.sys.Height())
+	defer sh.sys.OnResize(func() {
+		sh.terminal.SetSize(sh.sys.Width(), sh.sys.Height())
+	})()
+	sh.more = sh.readMore
 	defer func() {
 		if r := recover(); r != nil {
 			sh.log.Errorf("Recovered from panic %v", r)
 			sh.termSignal <- 1
 		}
 	}()
-	shellParser := shellwords.NewParser()
-	shellParser.ParseBacktick = false
+	sh.startSession(sh.name)
+	defer sh.hangup()
+	sh.login()
+	if sh.exited {
+		return
+	}
 	for {
-		cmd, err := sh.terminal.ReadLine()
-		if len(strings.TrimSpace(cmd)) > 0 {
-			sh.log.WithField("cmd", cmd).Infof("User input command %v", cmd)
-		}
-		if sh.DelayFunc != nil {
-			sh.DelayFunc()
+		cmd, err := sh.readCommand()
+		if err == terminal.ErrInterrupt {
+			// Ctrl-C at prompt discards the line
+			sh.status = 130
+			continue
 		}
 		if err != nil {
 			if err.Error() == "EOF" {
@@ -65,66 +127,390 @@ func (sh *Shell) HandleRequest(hook termlogger.LogHook) {
 			sh.log.WithError(err).Error("Error when reading terminal")
 			break
 		}
-
-		pos := 0
-		for pos < len(cmd) {
-			cmdList, err := shellParser.Parse(cmd[pos:])
-			if err != nil {
-				sh.terminal.Write([]byte(cmd[pos:] + ": command not found"))
-				break
-			}
-			for i, cmdComp := range cmdList {
-				if strings.Contains(cmdComp, "=") {
-					envVar := strings.SplitN(cmdComp, "=", 1)
-					sh.sys.SetEnv(envVar[0], envVar[1])
-				} else {
-					sh.ExecCmd(strings.Join(cmdList[i:], " "), tLog)
-					break
-				}
-			}
-			if shellParser.Position == -1 {
-				break
-			}
-			pos += shellParser.Position + 1
+		sh.addHistory(cmd)
+		sh.ExecLine(cmd)
+		if sh.exited {
+			return
 		}
 	}
 }
 
-func (sh *Shell) SetSize(width, height int) error {
-	sh.sys.width = width
-	sh.sys.height = height
-	return sh.terminal.SetSize(width, height)
+// readMore reads the next line of the command from the terminal with $PS2
+func (sh *Shell) readMore() (string, error) {
+	sh.terminal.SetPrompt(sh.renderPrompt(sh.getVar("PS2")))
+	sh.terminal.SetEcho(sh.tty.echoing())
+	return sh.terminal.ReadLine()
 }
 
-func (sh *Shell) ExecCmd(cmd string, tLog termlogger.StdIOErr) {
-	cmd = strings.TrimSpace(cmd)
-	switch {
+// readCommand prompts and reads the command from the terminal
+func (sh *Shell) readCommand() (string, error) {
+	sh.reportJobs()
+	sh.terminal.SetPrompt(sh.prompt())
+	sh.terminal.SetEcho(sh.tty.echoing())
+	cmd, err := sh.terminal.ReadLine()
+	// Keep reading with $PS2 until the command is complete, so it is
+	// logged and executed as a whole
+	for err == nil && continued(cmd) {
+		var next string
+		if next, err = sh.more(); err == nil {
+			cmd = joinLines(cmd, next)
+		} else if err == io.EOF {
+			err = nil
+			break
+		}
+	}
+	if len(strings.TrimSpace(cmd)) > 0 {
+		sh.log.WithField("cmd", cmd).Infof("User input command %v", cmd)
+	}
+	if sh.DelayFunc != nil {
+		sh.DelayFunc()
+	}
+	return cmd, err
+}
+
+// HandleExec runs the command string of SSH exec request non-interactively,
+// the same way as bash -c does
+func (sh *Shell) HandleExec(cmd string) {
+	defer func() {
+		if r := recover(); r != nil {
+			sh.log.Errorf("Recovered from panic %v", r)
+			sh.termSignal <- 1
+		}
+	}()
+	sh.name, sh.interactive, sh.commandString = "bash", false, true
+	sh.stdin, sh.stdout, sh.stderr = sh.sys.In(), sh.sys.Out(), sh.sys.Err()
+	sh.startSession("bash -c " + cmd)
+	defer sh.hangup()
+	sh.termSignal <- sh.runScript(cmd, sh.newProcess())
+}
 
-	case strings.TrimSpace(cmd) == "":
-		//Do nothing
-	case cmd == "logout", cmd == "exit":
-		sh.log.Infof("User logged out")
-		sh.terminal.Write([]byte("logout\n"))
-		sh.terminal.SetPrompt("")
-		sh.termSignal <- 0
+// addHistory saves the command line to history, skipping lines starting
+// with space and duplicates like HISTCONTROL=ignoreboth
+func (sh *Shell) addHistory(line string) {
+	sh.histAdded = false
+	if strings.TrimSpace(line) == "" || strings.HasPrefix(line, " ") {
 		return
-	case strings.HasPrefix(cmd, "cd"):
-		args := strings.Split(cmd, " ")
-		if len(args) > 1 {
-			err := sh.sys.Chdir(args[1])
-			if err != nil {
-				sh.terminal.Write([]byte(fmt.Sprintf("-bash: cd: %v: No such file or directory\n", args[1])))
+	}
+	if h := sh.terminal.History(); len(h) > 0 && h[len(h)-1] == line {
+		return
+	}
+	sh.terminal.AddHistory(line)
+	sh.histAdded = true
+}
+
+// ExecLine parses the command line and runs the pipelines in it
+func (sh *Shell) ExecLine(line string) int {
+	return sh.execLine(line, sh.newProcess())
+}
+
+// newProcess returns a process attached to standard I/O of the shell
+func (sh *Shell) newProcess() *process {
+	return &process{System: sh.sys, in: sh.stdin, out: sh.stdout, err: sh.stderr, shell: sh}
+}
+
+func (sh *Shell) execLine(line string, proc *process) int {
+	tokens, err := lex(line)
+	var list []*pipeline
+	if err == nil {
+		list, err = parseTokens(sh.expandAliases(tokens))
+	}
+	if err != nil {
+		sh.errorf(proc, "%v", err)
+		sh.status = 2
+		return 2
+	}
+	for _, doc := range heredocs(list) {
+		if !sh.readHeredoc(doc, proc) {
+			// Interrupted while typing the here-document
+			sh.status = 130
+			return 130
+		}
+	}
+	n := 0
+	for _, pl := range list {
+		if sh.exited || proc.Context().Err() != nil {
+			break
+		}
+		// Status of the last pipeline executed decides if we continue on && and ||
+		if pl.cond == tokAnd && n != 0 || pl.cond == tokOr && n == 0 {
+			continue
+		}
+		switch {
+		case pl.background:
+			sh.startJob(pl, proc)
+			n = 0
+		case sh.tty != nil && proc.ctx == nil:
+			// Top level commands of interactive shell, e.g. not in sourced script
+			var interrupted bool
+			if n, interrupted = sh.foreground(pl, proc); interrupted {
+				// Rest of the command line is aborted as well
+				sh.status = n
+				return n
 			}
+		default:
+			n = sh.execPipeline(pl, proc)
+		}
+		sh.status = n
+	}
+	return n
+}
+
+// readHeredoc reads the lines following the command as body of the
+// here-document, until the delimiter is seen. It returns false if user
+// interrupts with Ctrl-C
+func (sh *Shell) readHeredoc(doc *redirect, proc *process) bool {
+	delim := strings.Join(sh.expandWord(doc.target, false), "")
+	var body bytes.Buffer
+	for {
+		line, err := "", io.EOF
+		if sh.more != nil {
+			line, err = sh.more()
+		}
+		if err == terminal.ErrInterrupt {
+			return false
+		}
+		if err != nil {
+			sh.errorf(proc, "warning: here-document at line %v delimited by end-of-file (wanted `%v')", sh.lineNo, delim)
+			break
 		}
-	case strings.HasPrefix(cmd, "export"):
+		if doc.op == "<<-" {
+			line = strings.TrimLeft(line, "\t")
+		}
+		if line == delim {
+			break
+		}
+		body.WriteString(line + "\n")
+	}
+	doc.body = body.String()
+	sh.log.WithFields(log.Fields{
+		"delimiter": delim,
+		"body":      doc.body,
+	}).Infof("Here-document received")
+	return true
+}
 
-	default:
-		args := strings.Split(cmd, " ")
-		n, err := sh.sys.exec(args[0], args[1:], tLog)
+// errorf prints error message prefixed like bash does, e.g. "-bash: " for
+// interactive shell, "bash: " for bash -c and "script.sh: line 3: " for
+// scripts
+func (sh *Shell) errorf(proc *process, format string, a ...interface{}) {
+	prefix := sh.name + ": "
+	if sh.sourcing != "" {
+		prefix += fmt.Sprintf("%v: line %v: ", sh.sourcing, sh.lineNo)
+	} else if !sh.interactive && !sh.commandString {
+		prefix = fmt.Sprintf("%v: line %v: ", sh.name, sh.lineNo)
+	}
+	fmt.Fprintf(proc.Err(), prefix+format+"\n", a...)
+}
+
+// execPipeline runs every command in the pipeline concurrently, with stdout
+// of each command connected to stdin of the next. Like bash, each command
+// of a multi-command pipeline runs in its own subshell. Exit status of the
+// pipeline is the one of the last command
+func (sh *Shell) execPipeline(pl *pipeline, parent *process) int {
+	if len(pl.cmds) == 1 {
+		return sh.execCommand(pl.cmds[0], parent)
+	}
+	var wg sync.WaitGroup
+	status := make([]int, len(pl.cmds))
+	in := parent.in
+	for i, cmd := range pl.cmds {
+		sub := sh.subshell(parent.System, sh.name, sh.args)
+		sub.interactive, sub.lineNo = sh.interactive, sh.lineNo
+		proc := parent.fork(sub)
+		proc.in = in
+		if i == len(pl.cmds)-1 {
+			// $! of the job is the last command of the pipeline
+			proc.pid = parent.pid
+		}
+		var pw *io.PipeWriter
+		if i < len(pl.cmds)-1 {
+			var pr *io.PipeReader
+			pr, pw = io.Pipe()
+			proc.out = pw
+			in = pr
+		}
+		wg.Add(1)
+		go func(i int, cmd *simpleCommand, proc *process, pw *io.PipeWriter) {
+			defer wg.Done()
+			status[i] = proc.shell.execCommand(cmd, proc)
+			if pw != nil {
+				pw.Close()
+			}
+			// Like SIGPIPE, let the command before us know nobody is reading anymore
+			if pr, ok := proc.in.(*io.PipeReader); ok {
+				pr.Close()
+			}
+		}(i, cmd, proc, pw)
+	}
+	wg.Wait()
+	return status[len(status)-1]
+}
+
+func (sh *Shell) execCommand(cmd *simpleCommand, proc *process) int {
+	if len(cmd.redirs) > 0 {
+		p := *proc
+		proc = &p
+		files, err := sh.applyRedirects(cmd.redirs, proc)
+		defer func() {
+			for _, f := range files {
+				f.Close()
+			}
+		}()
 		if err != nil {
-			sh.terminal.Write([]byte(fmt.Sprintf("%v: command not found\n", args[0])))
+			sh.errorf(proc, "%v", err)
+			return 1
+		}
+	}
+	var args []string
+	for _, arg := range cmd.args {
+		args = append(args, sh.expandWord(arg, true)...)
+	}
+	if len(args) > 0 && len(cmd.assigns) > 0 {
+		// Assignments before the command only go to its environment
+		p := *proc
+		p.System = proc.System.clone()
+		proc = &p
+	}
+	for _, assign := range cmd.assigns {
+		envVar := strings.SplitN(assign, "=", 2)
+		if value := sh.expandString(envVar[1]); len(args) > 0 {
+			proc.SetEnv(envVar[0], value)
 		} else {
-			sh.sys.envVars["?"] = string(n)
+			sh.setVar(envVar[0], value)
+		}
+	}
+	return sh.run(args, proc)
+}
+
+// run executes the command, which can be builtin, one of the simulated
+// commands or file in the virtual filesystem
+func (sh *Shell) run(args []string, proc *process) int {
+	if len(args) == 0 {
+		return 0
+	}
+	if builtin, ok := builtins[args[0]]; ok {
+		return builtin(sh, args, proc)
+	}
+	p := *proc
+	proc = &p
+	if proc.pid == 0 {
+		proc.pid = newPid()
+	}
+	defer sh.startProcess(proc.pid, args, proc)()
+	n, err := sh.sys.exec(args[0], args[1:], proc)
+	if err == nil {
+		return n
+	}
+	if n, found := sh.execFile(args, proc); found {
+		return n
+	}
+	return sh.commandNotFound(args, proc)
+}
+
+// applyRedirects opens the files in the redirection list and attaches them to
+// the standard I/O of the process. Opened files are returned so they can be
+// closed once the command is finished
+func (sh *Shell) applyRedirects(redirs []redirect, proc *process) (files []io.Closer, err error) {
+	for _, r := range redirs {
+		target := sh.expandString(r.target)
+		switch r.op {
+		case ">&", "<&":
+			// Only duplicating stdout/stderr like 2>&1 is supported
+			var w io.Writer
+			switch target {
+			case "1":
+				w = proc.out
+			case "2":
+				w = proc.err
+			default:
+				return files, fmt.Errorf("%v: ambiguous redirect", target)
+			}
+			if r.fd == 1 {
+				proc.out = w
+			} else if r.fd == 2 {
+				proc.err = w
+			}
+		case "<<", "<<-", "<<<":
+			if r.fd != 0 {
+				continue
+			}
+			doc := r.body
+			if r.op == "<<<" {
+				doc = target + "\n"
+			} else if !strings.ContainsAny(r.target, "'\"\\") {
+				// Body is expanded unless the delimiter is quoted
+				doc = sh.expandHeredoc(doc)
+			}
+			proc.in = strings.NewReader(doc)
+		case "<":
+			if r.fd != 0 {
+				continue
+			}
+			var f afero.File
+			switch target {
+			case "/dev/null", "/dev/zero":
+				proc.in = strings.NewReader("")
+			case "/dev/stdin", "/dev/tty":
+			default:
+				if f, err = sh.openRedirect(target, os.O_RDONLY); err != nil {
+					return
+				}
+				files = append(files, f)
+				proc.in = f
+			}
+		default:
+			var w io.Writer
+			switch target {
+			case "/dev/null", "/dev/zero":
+				w = termlogger.DummyWriter{}
+			case "/dev/stdout", "/dev/tty":
+				w = proc.out
+			case "/dev/stderr":
+				w = proc.err
+			default:
+				flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+				if strings.HasSuffix(r.op, ">>") {
+					flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+				}
+				f, openErr := sh.openRedirect(target, flag)
+				if openErr != nil {
+					return files, openErr
+				}
+				files = append(files, f)
+				w = f
+			}
+			switch {
+			case strings.HasPrefix(r.op, "&"):
+				proc.out, proc.err = w, w
+			case r.fd == 1:
+				proc.out = w
+			case r.fd == 2:
+				proc.err = w
+			}
+		}
+	}
+	return
+}
+
+// openRedirect opens the file to be redirected to/from in the virtual
+// filesystem, returning error message the way bash prints it
+func (sh *Shell) openRedirect(target string, flag int) (afero.File, error) {
+	p := target
+	if !pathlib.IsAbs(p) {
+		p = pathlib.Join(sh.sys.Getcwd(), p)
+	}
+	if fi, err := sh.sys.FSys().Stat(p); err == nil && fi.IsDir() {
+		return nil, fmt.Errorf("%v: Is a directory", target)
+	}
+	f, err := sh.sys.FSys().OpenFile(p, flag, 0666&^sh.sys.Umask())
+	if err != nil {
+		if os.IsPermission(err) {
+			return nil, fmt.Errorf("%v: Permission denied", target)
 		}
+		return nil, fmt.Errorf("%v: No such file or directory", target)
+	}
+	if flag != os.O_RDONLY {
+		sh.log.WithField("path", p).Infof("Command output redirected to %v", p)
 	}
+	return f, nil
 }
@@ -0,0 +1,312 @@
+package os
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// signalNames are the names of the signals of Linux by number, without
+// the SIG prefix
+var signalNames = []string{"", "HUP", "INT", "QUIT", "ILL", "TRAP", "ABRT", "BUS", "FPE", "KILL", "USR1", "SEGV",
+	"USR2", "PIPE", "ALRM", "TERM", "STKFLT", "CHLD", "CONT", "STOP", "TSTP", "TTIN", "TTOU", "URG", "XCPU",
+	"XFSZ", "VTALRM", "PROF", "WINCH", "IO", "PWR", "SYS"}
+
+// signalDescs are how bash reports the jobs killed by the signals
+var signalDescs = map[int]string{1: "Hangup", 2: "Interrupt", 3: "Quit", 6: "Aborted", 9: "Killed",
+	10: "User defined signal 1", 11: "Segmentation fault", 12: "User defined signal 2", 13: "Broken pipe",
+	14: "Alarm clock", 15: "Terminated"}
+
+// SignalName returns the name of the signal like KILL, or the number if it
+// has none
+func SignalName(sig int) string {
+	switch {
+	case sig > 0 && sig < len(signalNames):
+		return signalNames[sig]
+	case sig == 34:
+		return "RTMIN"
+	case sig > 34 && sig < 50:
+		return fmt.Sprintf("RTMIN+%v", sig-34)
+	case sig >= 50 && sig < 64:
+		return fmt.Sprintf("RTMAX-%v", 64-sig)
+	case sig == 64:
+		return "RTMAX"
+	}
+	return strconv.Itoa(sig)
+}
+
+// ParseSignal parses the signal given by number or name, with or without
+// the SIG prefix and in any case
+func ParseSignal(s string) (int, bool) {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n, n >= 0 && n <= 64
+	}
+	name := strings.TrimPrefix(strings.ToUpper(s), "SIG")
+	for sig := 1; sig <= 64; sig++ {
+		if SignalName(sig) == name {
+			return sig, true
+		}
+	}
+	return 0, false
+}
+
+// SignalList returns the signals as kill -l lists them, five to a line
+func SignalList() string {
+	var b strings.Builder
+	n := 0
+	for sig := 1; sig <= 64; sig++ {
+		if sig == 32 || sig == 33 {
+			continue
+		}
+		n++
+		fmt.Fprintf(&b, "%2d) SIG%v", sig, SignalName(sig))
+		if n%5 == 0 || sig == 64 {
+			b.WriteString("\n")
+		} else {
+			b.WriteString("\t")
+		}
+	}
+	return b.String()
+}
+
+// processKinds are the words in the command lines of the processes worth
+// noting when killed: the agents guarding the machine, and the miners of
+// other attackers
+var processKinds = map[string][]string{
+	"security": {"auditd", "rsyslogd", "syslogd", "syslog-ng", "journald", "fail2ban", "ossec", "wazuh", "falco",
+		"osqueryd", "aliyundun", "aliyun", "aegis", "ydservice", "yunjing", "qcloud", "bcm-agent", "cloudmonitor",
+		"clamd", "freshclam", "snort", "suricata", "sysdig", "falcon-sensor", "sophos", "cbagent", "tripwire",
+		"auditbeat", "filebeat", "splunkd", "nessus", "amazon-ssm-agent", "apparmor"},
+	"miner": {"xmrig", "xmr-stak", "minerd", "cpuminer", "ccminer", "kdevtmpfsi", "kinsing", "cryptonight",
+		"nanominer", "ethminer", "t-rex", "lolminer", "nbminer", "phoenixminer", "stratum+", "sysupdate",
+		"kthreaddi", "watchbog", "dbused", "monero", "xmr"},
+}
+
+// ProcessKind tells what the process of the command line is to those
+// killing it: security for the security and monitoring agents, miner for
+// the cryptocurrency miners, or empty for the others
+func ProcessKind(cmd string) string {
+	cmd = strings.ToLower(cmd)
+	for _, kind := range []string{"security", "miner"} {
+		for _, word := range processKinds[kind] {
+			if strings.Contains(cmd, word) {
+				return kind
+			}
+		}
+	}
+	return ""
+}
+
+// Kill sends the signal to the process of the pid. The process leaves the
+// process table unless it ignores the signal, and its job or shell stops.
+// Signal 0 only checks if the process can be signaled. The errors are
+// syscall.ESRCH and syscall.EPERM like kill(2)
+func Kill(sys Sys, pid, sig int) error {
+	proc, ok := sys.(*process)
+	if !ok {
+		return syscall.ESRCH
+	}
+	p, ok := proc.procs.get(pid)
+	if !ok {
+		// Job of shell constructs like loops has no process of its own
+		j := proc.shell.jobOf(pid)
+		if j == nil {
+			return syscall.ESRCH
+		}
+		p = ProcInfo{PID: pid, PPID: proc.shell.pid, User: GetUserByID(proc.userId).Name, Stat: "S", Cmd: j.cmd}
+	}
+	if proc.userId != 0 && p.User != GetUserByID(proc.userId).Name {
+		return syscall.EPERM
+	}
+	logger := sys.Log().WithField("pid", pid).WithField("signal", SignalName(sig)).WithField("target", p.Cmd)
+	if kind := ProcessKind(p.Cmd); kind != "" {
+		logger.Warnf("User killing %v process %v", kind, p.Comm())
+	} else {
+		logger.Infof("User sent SIG%v to %v", SignalName(sig), p.Comm())
+	}
+	switch {
+	case sig == 0:
+		return nil
+	case pid == 1 || pid == 2 || p.PPID == 2:
+		// init and the kernel threads cannot be killed
+		return nil
+	}
+	switch SignalName(sig) {
+	case "STOP", "TSTP", "TTIN", "TTOU":
+		proc.procs.setStat(pid, "T"+strings.TrimLeft(p.Stat, "RSDT"))
+		return nil
+	case "CONT":
+		proc.procs.setStat(pid, "S"+strings.TrimLeft(p.Stat, "RSDT"))
+		return nil
+	case "CHLD", "WINCH", "URG":
+		return nil
+	}
+	if stop, ok := proc.procs.ignoresHangup(pid); ok {
+		if sig == 1 {
+			return nil
+		}
+		if stop != nil {
+			stop()
+		}
+	}
+	if target := proc.shell.shellOf(pid); target != nil {
+		// Interactive bash ignores these, sshd does not
+		if target.interactive && target.pid == pid && (sig == 2 || sig == 3 || sig == 15) {
+			return nil
+		}
+		for sh := proc.shell; sh != target.parent; sh = sh.parent {
+			sh.exited, sh.exitStatus = true, 128+sig
+		}
+		if target.parent == nil {
+			target.log.Infof("User killed own session with SIG%v", SignalName(sig))
+			select {
+			case target.termSignal <- 128 + sig:
+			default:
+			}
+		}
+		return nil
+	}
+	if j := proc.shell.jobOf(pid); j != nil {
+		j.signal = sig
+		j.cancel()
+	}
+	proc.procs.remove(pid)
+	orphans.remove(pid)
+	return nil
+}
+
+// shellOf finds the shell of the pid among the shell and those starting
+// it. Subshells share the pid of their parent, so the outermost is the
+// process. The login shell is also found by the pid of its sshd
+func (sh *Shell) shellOf(pid int) *Shell {
+	var found *Shell
+	for ; sh != nil; sh = sh.parent {
+		if sh.pid == pid || sh.parent == nil && sh.sshdPid == pid {
+			found = sh
+		}
+	}
+	return found
+}
+
+// jobOf finds the running job of the pid in the shell and those starting it
+func (sh *Shell) jobOf(pid int) *job {
+	for ; sh != nil; sh = sh.parent {
+		for _, j := range sh.jobs {
+			if j.pid == pid && !j.finished() {
+				return j
+			}
+		}
+	}
+	return nil
+}
+
+func builtinKill(sh *Shell, args []string, proc *process) int {
+	sig := 15
+	targets := args[1:]
+	if len(targets) > 0 {
+		arg := targets[0]
+		switch {
+		case arg == "-l" || arg == "-L":
+			return listSignals(sh, args, targets[1:], proc)
+		case arg == "-s" || arg == "-n":
+			if len(targets) < 2 {
+				sh.errorf(proc, "%v: %v: option requires an argument", args[0], arg)
+				return 2
+			}
+			n, ok := ParseSignal(targets[1])
+			if !ok {
+				sh.errorf(proc, "%v: %v: invalid signal specification", args[0], targets[1])
+				return 1
+			}
+			sig, targets = n, targets[2:]
+		case arg == "--":
+			targets = targets[1:]
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			n, ok := ParseSignal(arg[1:])
+			if !ok {
+				sh.errorf(proc, "%v: %v: invalid signal specification", args[0], arg[1:])
+				return 1
+			}
+			sig, targets = n, targets[1:]
+		}
+	}
+	if len(targets) == 0 {
+		fmt.Fprintf(proc.Err(), "%v: usage: kill [-s sigspec | -n signum | -sigspec] pid | jobspec ... or kill -l [sigspec]\n", args[0])
+		return 2
+	}
+	status := 0
+	for _, target := range targets {
+		var pid int
+		if strings.HasPrefix(target, "%") {
+			j := sh.findJob(target)
+			if j == nil {
+				sh.errorf(proc, "%v: %v: no such job", args[0], target)
+				status = 1
+				continue
+			}
+			pid = j.pid
+		} else {
+			n, err := strconv.Atoi(target)
+			if err != nil {
+				sh.errorf(proc, "%v: %v: arguments must be process or job IDs", args[0], target)
+				status = 1
+				continue
+			}
+			pid = n
+		}
+		var err error
+		switch {
+		case pid == -1:
+			// Every process that can be signaled except init and the caller
+			err = syscall.ESRCH
+			for _, p := range proc.Processes() {
+				if p.PID != 1 && p.PID != sh.pid && Kill(proc, p.PID, sig) == nil {
+					err = nil
+				}
+			}
+		case pid < 0:
+			// Process group is the same as its leader here
+			err = Kill(proc, -pid, sig)
+		default:
+			err = Kill(proc, pid, sig)
+		}
+		switch err {
+		case syscall.ESRCH:
+			sh.errorf(proc, "%v: (%v) - No such process", args[0], target)
+			status = 1
+		case syscall.EPERM:
+			sh.errorf(proc, "%v: (%v) - Operation not permitted", args[0], target)
+			status = 1
+		}
+	}
+	return status
+}
+
+// listSignals prints the signal names for kill -l, or converts the
+// signals given between names and numbers
+func listSignals(sh *Shell, args, sigs []string, proc *process) int {
+	if len(sigs) == 0 {
+		fmt.Fprint(proc.Out(), SignalList())
+		return 0
+	}
+	status := 0
+	for _, s := range sigs {
+		if n, err := strconv.Atoi(s); err == nil {
+			// Exit status of killed command gives the signal too
+			if n > 128 {
+				n -= 128
+			}
+			if name := SignalName(n); n > 0 && n <= 64 && name != strconv.Itoa(n) {
+				fmt.Fprintln(proc.Out(), name)
+				continue
+			}
+		} else if n, ok := ParseSignal(s); ok {
+			fmt.Fprintln(proc.Out(), n)
+			continue
+		}
+		sh.errorf(proc, "%v: %v: invalid signal specification", args[0], s)
+		status = 1
+	}
+	return status
+}
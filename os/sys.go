@@ -2,11 +2,15 @@ package os
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	pathlib "path"
+	"sort"
+	"strings"
+	"syscall"
 
 	"github.com/mkishere/sshsyrup/util/termlogger"
 
@@ -42,15 +46,30 @@ type Command interface {
 
 // System provides what most of os/sys does in the honeyport
 type System struct {
-	userId        int
-	cwd           string
-	fSys          afero.Fs
-	sshChan       ssh.Channel
-	envVars       map[string]string
-	width, height int
-	log           *log.Entry
-	sessionLog    termlogger.LogHook
-	hostName      string
+	userId     int
+	cwd        string
+	fSys       afero.Fs
+	sshChan    ssh.Channel
+	envVars    map[string]string
+	exports    map[string]bool
+	aliases    map[string]string
+	umask      os.FileMode
+	window     *window
+	termios    *Termios
+	procs      *procTable
+	socks      *sockTable
+	mounts     *mountTable
+	firewall   *firewall
+	clock      *clock
+	docker     *dockerState
+	mux        *muxState
+	redis      *redisState
+	login      *loginRecord
+	log        *log.Entry
+	sessionLog termlogger.LogHook
+	// hostName is shared by the processes of the session, so renaming the
+	// host changes it for all of them
+	hostName *string
 }
 
 type Sys interface {
@@ -67,19 +86,90 @@ type Sys interface {
 	CurrentUser() int
 	CurrentGroup() int
 	Hostname() string
+	// Umask is the mask to be applied when creating files
+	Umask() os.FileMode
+	// Context is done when the command is interrupted, e.g. by Ctrl-C.
+	// Long running commands should return once it's done
+	Context() context.Context
+	// Termios is the settings of the terminal, nil if the session has no
+	// pty
+	Termios() *Termios
+	// OnResize calls f when the terminal window is resized, so the command
+	// can redraw the screen. Call cancel once the command no longer watches
+	OnResize(f func()) (cancel func())
+	// Log is the logger of the session, for recording what the command does
+	Log() *log.Entry
+	// Processes returns the process table, with the daemons of the system and
+	// the commands running in the session
+	Processes() []ProcInfo
+	// Sockets returns the internet sockets, listening or connected
+	Sockets() []SockInfo
+	// Mounts returns the filesystems mounted, in the order they were mounted
+	Mounts() []MountInfo
 }
 type stdoutWrapper struct {
 	io.Writer
 }
 
-type sysLogWrapper struct {
-	termlogger.StdIOErr
+// Getenv returns value of the environment variable of the command
+func Getenv(sys Sys, key string) string {
+	for _, env := range sys.Environ() {
+		if strings.HasPrefix(env, key+"=") {
+			return env[len(key)+1:]
+		}
+	}
+	return ""
+}
+
+// IsTerminal tells if the input or output is the terminal, rather than pipe
+// or file, like isatty(3) does
+func IsTerminal(f interface{}) bool {
+	if cw, ok := f.(ctxWriter); ok {
+		f = cw.Writer
+	}
+	switch f.(type) {
+	case ttyWriter, *cookedReader:
+		return true
+	}
+	return false
+}
+
+// process is what a single command invocation sees of the system. The
+// standard I/O can be attached to the terminal or the pipes around it
+type process struct {
 	*System
+	in       io.Reader
+	out, err io.Writer
+	shell    *Shell
+	ctx      context.Context
+	// pid is given to the command run with the process instead of a new
+	// one, so the command of background job gets the pid shown in $!
+	pid int
+	// background is set for the processes of background jobs
+	background bool
+	// noHangup is set for the processes outliving the session, and detached
+	// for those without the terminal, as nohup and setsid run
+	noHangup, detached bool
+}
+
+func (p *process) In() io.Reader  { return p.in }
+func (p *process) Out() io.Writer { return p.out }
+func (p *process) Err() io.Writer { return p.err }
+
+// Context is canceled when the user interrupts the command
+func (p *process) Context() context.Context {
+	if p.ctx == nil {
+		return context.Background()
+	}
+	return p.ctx
 }
 
-func (sys *sysLogWrapper) In() io.Reader  { return sys.StdIOErr.In() }
-func (sys *sysLogWrapper) Out() io.Writer { return stdoutWrapper{sys.StdIOErr.Out()} }
-func (sys *sysLogWrapper) Err() io.Writer { return stdoutWrapper{sys.StdIOErr.Err()} }
+// fork returns a copy of the process running in the shell
+func (p *process) fork(sh *Shell) *process {
+	proc := *p
+	proc.System, proc.shell, proc.pid = sh.sys, sh, 0
+	return &proc
+}
 
 // NewSystem initializer a system object containing current user context: ID,
 // home directory, terminal dimensions, etc.
@@ -87,22 +177,70 @@ func NewSystem(user, host string, fs afero.Fs, channel ssh.Channel, width, heigh
 	if _, exists := IsUserExist(user); !exists {
 		CreateUser(user, "password")
 	}
-	aferoFs := afero.Afero{fs}
-	if exists, _ := aferoFs.DirExists(usernameMapping[user].Homedir); !exists {
-		aferoFs.MkdirAll(usernameMapping[user].Homedir, 0755)
+	u := GetUser(user)
+	aferoFs := afero.Afero{Fs: fs}
+	if exists, _ := aferoFs.DirExists(u.Homedir); !exists {
+		aferoFs.MkdirAll(u.Homedir, 0755)
 	}
 
+	path := "/usr/local/bin:/usr/bin:/bin:/usr/local/games:/usr/games"
+	if u.UID == 0 {
+		path = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+	}
+	shell := u.Shell
+	if len(shell) == 0 {
+		shell = "/bin/bash"
+	}
+	envVars := map[string]string{
+		"HOME":    u.Homedir,
+		"USER":    user,
+		"LOGNAME": user,
+		"SHELL":   shell,
+		"PATH":    path,
+		"PWD":     u.Homedir,
+		"TERM":    "dumb",
+		"LANG":    "en_US.UTF-8",
+	}
+	exports := make(map[string]bool, len(envVars))
+	for k := range envVars {
+		exports[k] = true
+	}
 	return &System{
-		cwd:      usernameMapping[user].Homedir,
-		fSys:     aferoFs,
-		envVars:  map[string]string{},
+		cwd:      u.Homedir,
+		fSys:     fs,
+		envVars:  envVars,
+		exports:  exports,
+		aliases:  newAliases(),
+		umask:    0022,
 		sshChan:  channel,
-		width:    width,
-		height:   height,
+		window:   newWindow(width, height),
+		procs:    newProcTable(),
+		socks:    &sockTable{},
+		mounts:   &mountTable{},
+		firewall: &firewall{},
+		clock:    &clock{},
+		docker:   &dockerState{},
+		mux:      &muxState{},
+		redis:    &redisState{},
 		log:      log,
-		userId:   usernameMapping[user].UID,
-		hostName: host,
+		userId:   u.UID,
+		hostName: &host,
+	}
+}
+
+// clone returns a copy of the system with its own set of variables, like
+// what a forked process has
+func (sys *System) clone() *System {
+	c := *sys
+	c.envVars = make(map[string]string, len(sys.envVars))
+	for k, v := range sys.envVars {
+		c.envVars[k] = v
+	}
+	c.exports = make(map[string]bool, len(sys.exports))
+	for k, v := range sys.exports {
+		c.exports[k] = v
 	}
+	return &c
 }
 
 // Getcwd gets current working directory
@@ -121,7 +259,11 @@ func (sys *System) Chdir(path string) error {
 	} else if err != nil {
 		return err
 	}
+	if fi, err := sys.fSys.Stat(path); err == nil && !sys.searchable(path, fi) {
+		return os.ErrPermission
+	}
 	sys.cwd = path
+	sys.envVars["PWD"] = path
 	return nil
 }
 
@@ -133,7 +275,25 @@ func (sys *System) CurrentGroup() int {
 }
 
 func (sys *System) Hostname() string {
-	return sys.hostName
+	return *sys.hostName
+}
+
+// SetHostname renames the host for the rest of the session, like
+// sethostname(2) does. Only root can rename it
+func SetHostname(sys Sys, name string) error {
+	proc, ok := sys.(*process)
+	if !ok {
+		return syscall.EPERM
+	}
+	if proc.userId != 0 {
+		return syscall.EPERM
+	}
+	if name == "" || len(name) > 64 {
+		return syscall.EINVAL
+	}
+	proc.Log().WithField("old", *proc.hostName).WithField("new", name).Infof("User renamed host to %v", name)
+	*proc.hostName = name
+	return nil
 }
 
 // In returns a io.Reader that represent stdin
@@ -150,11 +310,41 @@ func (sys *System) Err() io.Writer {
 
 func (sys *System) IOStream() io.ReadWriter { return sys.sshChan }
 
-func (sys *System) FSys() afero.Fs { return sys.fSys }
+// FSys returns the filesystem as seen by the user, with permissions checked
+func (sys *System) FSys() afero.Fs { return userFs{sys.fSys, sys} }
+
+func (sys *System) Context() context.Context { return context.Background() }
+
+func (sys *System) Width() int {
+	width, _ := sys.window.size()
+	return width
+}
+
+func (sys *System) Height() int {
+	_, height := sys.window.size()
+	return height
+}
+
+// SetSize changes the size of terminal window, and notifies the running
+// commands watching it
+func (sys *System) SetSize(width, height int) {
+	sys.window.resize(width, height)
+}
 
-func (sys *System) Width() int { return sys.width }
+func (sys *System) OnResize(f func()) (cancel func()) {
+	return sys.window.watch(f)
+}
+
+func (sys *System) Termios() *Termios { return sys.termios }
 
-func (sys *System) Height() int { return sys.height }
+func (sys *System) Log() *log.Entry { return sys.log }
+
+// SetTerminalModes allocates the pty for the session, with the terminal
+// modes encoded in the pty-req
+func (sys *System) SetTerminalModes(modes string) {
+	sys.termios = newTermios(sys.window)
+	sys.termios.applyModes([]byte(modes))
+}
 
 // Write replace \n with \r\n before writing to the underlying io.Writer.
 // Copied from golang.org/x/crypto/ssh/terminal
@@ -186,24 +376,35 @@ func (sw stdoutWrapper) Write(buf []byte) (n int, err error) {
 	return n, nil
 }
 
+// Environ returns the exported variables in the form of key=value
 func (sys *System) Environ() (env []string) {
 	env = make([]string, 0, len(sys.envVars))
 	for k, v := range sys.envVars {
-		env = append(env, fmt.Sprintf("%v=%v", k, v))
+		if sys.exports[k] {
+			env = append(env, fmt.Sprintf("%v=%v", k, v))
+		}
 	}
+	sort.Strings(env)
 	return
 }
 
+// SetEnv sets and exports the environment variable
 func (sys *System) SetEnv(key, value string) error {
+	if !isName(key) {
+		return fmt.Errorf("`%v': not a valid identifier", key)
+	}
 	sys.envVars[key] = value
+	sys.exports[key] = true
 	return nil
 }
 
+func (sys *System) Umask() os.FileMode { return sys.umask }
+
 func (sys *System) Exec(path string, args []string) (int, error) {
-	return sys.exec(path, args, nil)
+	return sys.exec(path, args, sys)
 }
 
-func (sys *System) exec(path string, args []string, io termlogger.StdIOErr) (int, error) {
+func (sys *System) exec(path string, args []string, proc Sys) (int, error) {
 	cmd := pathlib.Base(path)
 	if execFunc, ok := funcMap[cmd]; ok {
 
@@ -214,30 +415,22 @@ func (sys *System) exec(path string, args []string, io termlogger.StdIOErr) (int
 					"args":  args,
 					"error": r,
 				}).Error("Command has crashed")
-				sys.Err().Write([]byte("Segmentation fault\n"))
+				proc.Err().Write([]byte("Segmentation fault\n"))
 			}
 		}()
-		var res int
-		// If logger is not nil, redirect IO to it
-		if io != nil {
-			loggedSys := &sysLogWrapper{io, sys}
-			res = execFunc.Exec(args, loggedSys)
-		} else {
-			res = execFunc.Exec(args, sys)
-		}
-		return res, nil
+		return execFunc.Exec(args, proc), nil
 	} else if output, inList := fakeFuncList[cmd]; inList {
 		// Print random error message
 		// Make use of golang map random nature :)
 		if len(output) == 0 {
-			return printRandomError(sys)
+			return printRandomError(proc)
 		}
 		// Read file and write output
 		content, err := ioutil.ReadFile(output)
 		if err != nil {
-			return printRandomError(sys)
+			return printRandomError(proc)
 		}
-		sys.Out().Write(content)
+		proc.Out().Write(content)
 		return 0, nil
 	}
 
@@ -266,7 +459,7 @@ func RegisterCommandOutput(cmd, pathToOutput string) {
 	fakeFuncList[cmd] = pathToOutput
 }
 
-func printRandomError(sys *System) (int, error) {
+func printRandomError(sys Sys) (int, error) {
 	for msg := range errMsgList {
 		sys.Err().Write([]byte(msg + "\n"))
 		break
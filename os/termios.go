@@ -0,0 +1,177 @@
+package os
+
+import (
+	"encoding/binary"
+	"sync"
+)
+
+// TermFlags are the settings of terminal in the order stty prints them,
+// grouped as control, input, output and local modes
+var TermFlags = [][]string{
+	{"parenb", "parodd", "cmspar", "cs8", "hupcl", "cstopb", "cread", "clocal", "crtscts"},
+	{"ignbrk", "brkint", "ignpar", "parmrk", "inpck", "istrip", "inlcr", "igncr", "icrnl", "ixon",
+		"ixoff", "iuclc", "ixany", "imaxbel", "iutf8"},
+	{"opost", "olcuc", "ocrnl", "onlcr", "onocr", "onlret", "ofill", "ofdel"},
+	{"isig", "icanon", "iexten", "echo", "echoe", "echok", "echonl", "noflsh", "xcase", "tostop",
+		"echoprt", "echoctl", "echoke", "flusho", "extproc"},
+}
+
+// TermChars are the special characters of terminal in the order stty
+// prints them
+var TermChars = []string{"intr", "quit", "erase", "kill", "eof", "eol", "eol2", "swtch", "start",
+	"stop", "susp", "rprnt", "werase", "lnext", "discard", "min", "time"}
+
+// saneFlags are the flags set after stty sane, which is also what a pty
+// from sshd starts with
+var saneFlags = []string{"cs8", "cread", "icrnl", "ixon", "iutf8", "opost", "onlcr", "isig",
+	"icanon", "iexten", "echo", "echoe", "echok", "echoctl", "echoke"}
+
+var saneChars = map[string]byte{
+	"intr": 3, "quit": 0x1c, "erase": 0x7f, "kill": 0x15, "eof": 4, "start": 0x11, "stop": 0x13,
+	"susp": 0x1a, "rprnt": 0x12, "werase": 0x17, "lnext": 0x16, "discard": 0x0f, "min": 1,
+}
+
+// ptyModes maps the opcodes of terminal modes in pty-req (RFC 4254) to the
+// name of the character or flag
+var ptyModes = map[byte]string{
+	1: "intr", 2: "quit", 3: "erase", 4: "kill", 5: "eof", 6: "eol", 7: "eol2", 8: "start",
+	9: "stop", 10: "susp", 12: "rprnt", 13: "werase", 14: "lnext", 18: "discard",
+	30: "ignpar", 31: "parmrk", 32: "inpck", 33: "istrip", 34: "inlcr", 35: "igncr", 36: "icrnl",
+	37: "iuclc", 38: "ixon", 39: "ixany", 40: "ixoff", 41: "imaxbel", 42: "iutf8",
+	50: "isig", 51: "icanon", 52: "xcase", 53: "echo", 54: "echoe", 55: "echok", 56: "echonl",
+	57: "noflsh", 58: "tostop", 59: "iexten", 60: "echoctl", 61: "echoke", 62: "pendin",
+	70: "opost", 71: "olcuc", 72: "onlcr", 73: "ocrnl", 74: "onocr", 75: "onlret",
+	91: "cs8", 92: "parenb", 93: "parodd",
+}
+
+// Termios is the settings of the terminal of session, like termios(3) of a
+// pty. It is shared by all commands in the session, so a command can turn
+// off echo when reading password
+type Termios struct {
+	mu             sync.Mutex
+	flags          map[string]bool
+	chars          map[string]byte
+	ispeed, ospeed int
+	window         *window
+}
+
+func newTermios(win *window) *Termios {
+	t := &Termios{ispeed: 38400, ospeed: 38400, window: win}
+	t.Sane()
+	return t
+}
+
+// Sane resets the flags and characters to sane values, like stty sane
+func (t *Termios) Sane() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.flags = map[string]bool{}
+	for _, f := range saneFlags {
+		t.flags[f] = true
+	}
+	t.chars = map[string]byte{}
+	for k, v := range saneChars {
+		t.chars[k] = v
+	}
+}
+
+// applyModes applies the encoded terminal modes from pty-req
+func (t *Termios) applyModes(modes []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for len(modes) > 0 && modes[0] != 0 && modes[0] < 160 {
+		if len(modes) < 5 {
+			return
+		}
+		op, value := modes[0], binary.BigEndian.Uint32(modes[1:5])
+		modes = modes[5:]
+		switch name, known := ptyModes[op]; {
+		case op == 128:
+			t.ispeed = int(value)
+		case op == 129:
+			t.ospeed = int(value)
+		case !known:
+		case op < 30:
+			// Characters, 255 is _POSIX_VDISABLE
+			if value == 255 {
+				value = 0
+			}
+			t.chars[name] = byte(value)
+		default:
+			t.flags[name] = value != 0
+		}
+	}
+}
+
+// Flag tells if the flag like echo or icanon is set
+func (t *Termios) Flag(name string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.flags[name]
+}
+
+// SetFlag turns the flag on or off. It returns false if the flag is unknown
+func (t *Termios) SetFlag(name string, on bool) bool {
+	for _, group := range TermFlags {
+		for _, f := range group {
+			if f == name {
+				t.mu.Lock()
+				t.flags[name] = on
+				t.mu.Unlock()
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Char returns the special character like intr, or 0 if it is undefined
+func (t *Termios) Char(name string) byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.chars[name]
+}
+
+// SetChar sets the special character. It returns false if name is unknown
+func (t *Termios) SetChar(name string, c byte) bool {
+	for _, n := range TermChars {
+		if n == name {
+			t.mu.Lock()
+			t.chars[name] = c
+			t.mu.Unlock()
+			return true
+		}
+	}
+	return false
+}
+
+// Speed returns the input and output baud rate
+func (t *Termios) Speed() (ispeed, ospeed int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.ispeed, t.ospeed
+}
+
+// SetSpeed sets the baud rate, 0 leaves it unchanged
+func (t *Termios) SetSpeed(ispeed, ospeed int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if ispeed > 0 {
+		t.ispeed = ispeed
+	}
+	if ospeed > 0 {
+		t.ospeed = ospeed
+	}
+}
+
+// Size returns the rows and columns of the terminal
+func (t *Termios) Size() (rows, cols int) {
+	cols, rows = t.window.size()
+	return rows, cols
+}
+
+// SetSize sets the window size of the terminal, which is seen by commands
+// running as if the client resized the window
+func (t *Termios) SetSize(rows, cols int) {
+	t.window.resize(cols, rows)
+}
@@ -0,0 +1,305 @@
+package os
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mkishere/sshsyrup/util/terminal"
+)
+
+const (
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyBackspace = 127
+)
+
+// tty sits between the client and the shell like terminal driver of a real
+// system. Input is read in background so Ctrl-C can be caught while a command
+// is running, even if the command does not read stdin
+type tty struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	queue []byte
+	eof   bool
+	// intr is called when Ctrl-C is received, nil if we're at prompt
+	intr func()
+	echo io.Writer
+	// modes is the terminal settings, nil for the defaults
+	modes *Termios
+}
+
+func newTTY(in io.Reader, echo io.Writer, modes *Termios) *tty {
+	t := &tty{echo: echo, modes: modes}
+	t.cond = sync.NewCond(&t.mu)
+	go t.readInput(in)
+	return t
+}
+
+func (t *tty) readInput(in io.Reader) {
+	buf := make([]byte, 1024)
+	for {
+		n, err := in.Read(buf)
+		t.mu.Lock()
+		data := buf[:n]
+		intrChar := byte(keyCtrlC)
+		if t.modes != nil {
+			intrChar = t.modes.Char("intr")
+			if !t.modes.Flag("isig") {
+				intrChar = 0
+			}
+		}
+		for i := len(data) - 1; i >= 0 && t.intr != nil && intrChar != 0; i-- {
+			if data[i] == intrChar {
+				// Like a real terminal, pending input is flushed on interrupt
+				t.echo.Write([]byte("^C"))
+				t.intr()
+				t.intr = nil
+				t.queue = t.queue[:0]
+				data = data[i+1:]
+				break
+			}
+		}
+		t.queue = append(t.queue, data...)
+		if err != nil {
+			t.eof = true
+		}
+		t.cond.Broadcast()
+		t.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Read returns the raw input, for the line editor at prompt
+func (t *tty) Read(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for len(t.queue) == 0 && !t.eof {
+		t.cond.Wait()
+	}
+	if len(t.queue) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, t.queue)
+	t.queue = t.queue[n:]
+	return n, nil
+}
+
+// echoing tells if input should be echoed
+func (t *tty) echoing() bool {
+	return t.modes == nil || t.modes.Flag("echo")
+}
+
+// canonical tells if input is processed line by line, i.e. not in raw mode
+func (t *tty) canonical() bool {
+	return t.modes == nil || t.modes.Flag("icanon")
+}
+
+// setInterrupt sets the function to be called on Ctrl-C
+func (t *tty) setInterrupt(intr func()) {
+	t.mu.Lock()
+	t.intr = intr
+	t.mu.Unlock()
+}
+
+// wakeup lets the blocking reads notice the command is interrupted
+func (t *tty) wakeup() {
+	t.mu.Lock()
+	t.cond.Broadcast()
+	t.mu.Unlock()
+}
+
+// cookedReader is stdin of a foreground command. Input is echoed and
+// returned line by line, with backspace and Ctrl-D handled like canonical
+// mode of a terminal
+type cookedReader struct {
+	t    *tty
+	ctx  context.Context
+	line []byte
+	out  []byte
+}
+
+func (r *cookedReader) Read(p []byte) (int, error) {
+	if len(r.out) > 0 {
+		n := copy(p, r.out)
+		r.out = r.out[n:]
+		return n, nil
+	}
+	t := r.t
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// In raw mode with min 0, read gives up after time tenths of a second,
+	// which is how top refreshes while waiting for keys
+	expired := false
+	if !t.canonical() && t.modes.Char("min") == 0 && t.modes.Char("time") > 0 {
+		timer := time.AfterFunc(time.Duration(t.modes.Char("time"))*100*time.Millisecond, func() {
+			t.mu.Lock()
+			expired = true
+			t.cond.Broadcast()
+			t.mu.Unlock()
+		})
+		defer timer.Stop()
+	}
+	for {
+		for len(t.queue) == 0 && !t.eof && r.ctx.Err() == nil && !expired {
+			t.cond.Wait()
+		}
+		if expired && len(t.queue) == 0 && r.ctx.Err() == nil {
+			return 0, nil
+		}
+		if r.ctx.Err() != nil || len(t.queue) == 0 {
+			return 0, io.EOF
+		}
+		if !t.canonical() {
+			// Raw mode, input is passed as is without waiting for a line
+			n := copy(p, t.queue)
+			if t.echoing() {
+				t.echo.Write(t.queue[:n])
+			}
+			t.queue = t.queue[n:]
+			return n, nil
+		}
+		echo := t.echoing()
+		b := t.queue[0]
+		t.queue = t.queue[1:]
+		switch b {
+		case '\r', '\n':
+			if echo || t.modes.Flag("echonl") {
+				t.echo.Write([]byte("\n"))
+			}
+			r.out = append(r.line, '\n')
+			r.line = nil
+		case keyCtrlD:
+			if len(r.line) == 0 {
+				return 0, io.EOF
+			}
+			r.out, r.line = r.line, nil
+		case keyBackspace, '\b':
+			if len(r.line) > 0 {
+				// Erase the whole character, which can be multibyte and wide
+				c, l := utf8.DecodeLastRune(r.line)
+				r.line = r.line[:len(r.line)-l]
+				if w := terminal.RuneWidth(c); echo {
+					t.echo.Write([]byte(strings.Repeat("\b", w) + strings.Repeat(" ", w) + strings.Repeat("\b", w)))
+				}
+			}
+			continue
+		default:
+			r.line = append(r.line, b)
+			if echo {
+				t.echo.Write([]byte{b})
+			}
+			continue
+		}
+		n := copy(p, r.out)
+		r.out = r.out[n:]
+		return n, nil
+	}
+}
+
+// OpenTTY returns the input of the controlling terminal of the command, like
+// opening /dev/tty, so a pager can read the keys even if stdin is a pipe. It
+// returns nil if the session has no terminal
+func OpenTTY(sys Sys) io.Reader {
+	proc, ok := sys.(*process)
+	if !ok || proc.shell == nil || proc.shell.tty == nil {
+		return nil
+	}
+	return &cookedReader{t: proc.shell.tty, ctx: proc.Context()}
+}
+
+// Interrupt returns the channel closed when Ctrl-C is pressed, for commands
+// handling the interrupt themselves like ping printing the statistics. The
+// command is not interrupted then, and should return soon after. It is nil
+// if the command is not run from the terminal
+func Interrupt(sys Sys) <-chan struct{} {
+	proc, ok := sys.(*process)
+	if !ok || proc.shell == nil || proc.shell.tty == nil || proc.background {
+		return nil
+	}
+	ch := make(chan struct{})
+	proc.shell.tty.setInterrupt(func() { close(ch) })
+	return ch
+}
+
+// ttyWriter is the output to terminal of interactive session, so that
+// commands can tell it from pipes and files
+type ttyWriter struct {
+	io.Writer
+}
+
+// writerFunc is the function writing as io.Writer
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+// Tap returns the writer writing with the function, which is the terminal as
+// long as w is, so that the command writing can't tell the difference
+func Tap(w io.Writer, write func(p []byte) (int, error)) io.Writer {
+	if IsTerminal(w) {
+		return ttyWriter{writerFunc(write)}
+	}
+	return writerFunc(write)
+}
+
+// ctxWriter drops the output once the command is interrupted, so commands
+// not honoring the context won't keep printing after the prompt is back
+type ctxWriter struct {
+	io.Writer
+	ctx context.Context
+}
+
+func (w ctxWriter) Write(p []byte) (int, error) {
+	if w.ctx.Err() != nil {
+		return 0, io.ErrClosedPipe
+	}
+	return w.Writer.Write(p)
+}
+
+// foreground runs the pipeline as foreground job, which can be interrupted
+// by pressing Ctrl-C. Exit status is 130 if it is interrupted
+func (sh *Shell) foreground(pl *pipeline, parent *process) (n int, interrupted bool) {
+	ctx, cancel := context.WithCancel(parent.Context())
+	defer cancel()
+	proc := &process{
+		System: sh.sys,
+		in:     &cookedReader{t: sh.tty, ctx: ctx},
+		out:    ctxWriter{parent.out, ctx},
+		err:    ctxWriter{parent.err, ctx},
+		shell:  sh,
+		ctx:    ctx,
+	}
+	sh.tty.setInterrupt(cancel)
+	defer sh.tty.setInterrupt(nil)
+
+	done := make(chan int, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				sh.log.Errorf("Recovered from panic %v", r)
+				done <- 1
+			}
+		}()
+		done <- sh.execPipeline(pl, proc)
+	}()
+	select {
+	case n := <-done:
+		return n, false
+	case <-ctx.Done():
+		sh.tty.wakeup()
+	}
+	// Give the command a moment to finish, but don't wait for commands that
+	// ignores the interrupt
+	select {
+	case <-done:
+	case <-time.After(100 * time.Millisecond):
+	}
+	sh.log.Info("User interrupted running command")
+	parent.out.Write([]byte("\n"))
+	return 130, true
+}
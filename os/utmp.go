@@ -0,0 +1,207 @@
+package os
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Types of utmp records, as in utmp.h
+const (
+	UtmpRunLevel     = 1
+	UtmpBootTime     = 2
+	UtmpLoginProcess = 6
+	UtmpUserProcess  = 7
+	UtmpDeadProcess  = 8
+)
+
+// utmpSize is the size of struct utmp on x86_64
+const utmpSize = 384
+
+// UtmpRecord is an entry of utmp and wtmp, the sessions and boots of the
+// machine
+type UtmpRecord struct {
+	Type int
+	PID  int
+	// Line is the terminal like pts/0, or ~ for boot and run level
+	Line, ID, User, Host string
+	Time                 time.Time
+}
+
+// ParseUtmp reads the records of utmp or wtmp, ignoring the incomplete one
+// at the end
+func ParseUtmp(data []byte) []UtmpRecord {
+	str := func(b []byte) string {
+		if i := bytes.IndexByte(b, 0); i >= 0 {
+			b = b[:i]
+		}
+		return string(b)
+	}
+	var records []UtmpRecord
+	for ; len(data) >= utmpSize; data = data[utmpSize:] {
+		b := data[:utmpSize]
+		records = append(records, UtmpRecord{
+			Type: int(binary.LittleEndian.Uint16(b[0:])),
+			PID:  int(int32(binary.LittleEndian.Uint32(b[4:]))),
+			Line: str(b[8:40]), ID: str(b[40:44]), User: str(b[44:76]), Host: str(b[76:332]),
+			Time: time.Unix(int64(int32(binary.LittleEndian.Uint32(b[340:]))), int64(binary.LittleEndian.Uint32(b[344:]))*1000),
+		})
+	}
+	return records
+}
+
+// EncodeUtmp writes the records in the format of utmp and wtmp
+func EncodeUtmp(records []UtmpRecord) []byte {
+	var buf bytes.Buffer
+	for _, r := range records {
+		b := make([]byte, utmpSize)
+		binary.LittleEndian.PutUint16(b[0:], uint16(r.Type))
+		binary.LittleEndian.PutUint32(b[4:], uint32(r.PID))
+		copy(b[8:40], r.Line)
+		copy(b[40:44], r.ID)
+		copy(b[44:76], r.User)
+		copy(b[76:332], r.Host)
+		binary.LittleEndian.PutUint32(b[340:], uint32(r.Time.Unix()))
+		binary.LittleEndian.PutUint32(b[344:], uint32(r.Time.Nanosecond()/1000))
+		if ip := net.ParseIP(r.Host).To4(); ip != nil {
+			copy(b[348:352], ip)
+		}
+		buf.Write(b)
+	}
+	return buf.Bytes()
+}
+
+// adminHosts are where the administrators log in from, one in the network
+// of the machine and one outside, made up by the address
+func adminHosts(r *rand.Rand) []string {
+	local := "192.168.1.10"
+	if ip := net.ParseIP(IPAddress()).To4(); ip != nil {
+		local = fmt.Sprintf("%v.%v.%v.%v", ip[0], ip[1], ip[2], 2+r.Intn(60))
+	}
+	first := []int{62, 80, 86, 94, 109, 176, 188, 213, 217}
+	return []string{local, fmt.Sprintf("%v.%v.%v.%v", first[r.Intn(len(first))], r.Intn(256), r.Intn(256), 1+r.Intn(254))}
+}
+
+// wtmpHistory makes up the history of the machine until now: the boot
+// before the last one, and root logging in every few days from the hosts of
+// the administrators, like the server is looked after
+func wtmpHistory(now time.Time) []UtmpRecord {
+	h := fnv.New64a()
+	h.Write([]byte(IPAddress() + KernelRelease()))
+	r := rand.New(rand.NewSource(int64(h.Sum64())))
+	hosts := adminHosts(r)
+	boot := BootTime()
+	prevBoot := boot.Add(-time.Duration(20*24+r.Intn(20*24)) * time.Hour)
+	var records []UtmpRecord
+	bootRecords := func(t time.Time) {
+		records = append(records,
+			UtmpRecord{Type: UtmpBootTime, Line: "~", User: "reboot", Host: KernelRelease(), Time: t},
+			UtmpRecord{Type: UtmpRunLevel, PID: 'N'<<8 | '5', Line: "~", User: "runlevel", Host: KernelRelease(), Time: t.Add(9 * time.Second)})
+	}
+	sessions := func(from, until time.Time) {
+		pid := 1200 + r.Intn(800)
+		for t := from.Add(time.Duration(2+r.Intn(30)) * time.Hour); ; {
+			end := t.Add(time.Duration(3+r.Intn(90))*time.Minute + time.Duration(r.Intn(60))*time.Second)
+			if end.After(until) {
+				return
+			}
+			line := "pts/0"
+			if r.Intn(5) == 0 {
+				line = "pts/1"
+			}
+			host := hosts[r.Intn(len(hosts))]
+			records = append(records,
+				UtmpRecord{Type: UtmpUserProcess, PID: pid, Line: line, ID: strings.TrimPrefix(line, "p"), User: "root", Host: host, Time: t},
+				UtmpRecord{Type: UtmpDeadProcess, PID: pid, Line: line, ID: strings.TrimPrefix(line, "p"), Time: end})
+			pid += 100 + r.Intn(3000)
+			t = end.Add(time.Duration(12+r.Intn(96)) * time.Hour)
+		}
+	}
+	bootRecords(prevBoot)
+	sessions(prevBoot, boot.Add(-time.Hour))
+	// The last boot followed the shutdown after an upgrade
+	records = append(records, UtmpRecord{Type: UtmpRunLevel, PID: '5'<<8 | '0', Line: "~", User: "shutdown", Host: KernelRelease(),
+		Time: boot.Add(-time.Duration(40+r.Intn(60)) * time.Second)})
+	bootRecords(boot)
+	sessions(boot, now)
+	return records
+}
+
+// utmpBoot is utmp right after the boot, with getty waiting on the console
+func utmpBoot() []UtmpRecord {
+	boot := BootTime()
+	return []UtmpRecord{
+		{Type: UtmpBootTime, Line: "~", User: "reboot", Host: KernelRelease(), Time: boot},
+		{Type: UtmpRunLevel, PID: 'N'<<8 | '5', Line: "~", User: "runlevel", Host: KernelRelease(), Time: boot.Add(9 * time.Second)},
+		{Type: UtmpLoginProcess, PID: 960, Line: "tty1", ID: "tty1", User: "LOGIN", Time: boot.Add(10 * time.Second)},
+	}
+}
+
+// Sessions returns the logins to the honeypot since the time as records of
+// wtmp, oldest first. Those ended are followed by the logout made up, and the
+// session of sys is still logged in
+func Sessions(sys Sys, since time.Time) []UtmpRecord {
+	var current *loginRecord
+	if proc, ok := sys.(*process); ok {
+		current = proc.login
+	}
+	var records []UtmpRecord
+	history := loginHistory()
+	found := false
+	for _, l := range history {
+		found = found || current != nil && l.Time.Equal(current.Time) && l.User == current.User
+	}
+	if current != nil && !found {
+		// Login history is not kept without server.loginHistory
+		history = append(history, *current)
+	}
+	for i, l := range history {
+		if l.Time.Before(since) {
+			continue
+		}
+		h := fnv.New32a()
+		fmt.Fprint(h, l.Time.UnixNano())
+		pid := 2000 + int(h.Sum32()%30000)
+		records = append(records, UtmpRecord{Type: UtmpUserProcess, PID: pid, Line: l.TTY, ID: strings.TrimPrefix(l.TTY, "p"),
+			User: l.User, Host: l.Host, Time: l.Time})
+		if current != nil && l.Time.Equal(current.Time) && l.User == current.User {
+			records[len(records)-1].PID = current.pid
+			continue
+		}
+		end := l.Time.Add(time.Duration(30+h.Sum32()%1800) * time.Second)
+		if end.After(time.Now()) {
+			end = time.Now()
+		}
+		// Sessions on the same terminal do not overlap
+		for _, next := range history[i+1:] {
+			if next.TTY == l.TTY && next.Time.Before(end) {
+				end = next.Time.Add(-time.Duration(1+h.Sum32()%20) * time.Second)
+				if end.Before(l.Time) {
+					end = l.Time
+				}
+				break
+			}
+		}
+		records = append(records, UtmpRecord{Type: UtmpDeadProcess, PID: pid, Line: l.TTY, ID: strings.TrimPrefix(l.TTY, "p"), Time: end})
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Time.Before(records[j].Time) })
+	return records
+}
+
+// CurrentSession returns the record of the session of sys in utmp, false if
+// it is not a login session
+func CurrentSession(sys Sys) (UtmpRecord, bool) {
+	proc, ok := sys.(*process)
+	if !ok || proc.login == nil {
+		return UtmpRecord{}, false
+	}
+	l := proc.login
+	return UtmpRecord{Type: UtmpUserProcess, PID: l.pid, Line: l.TTY, ID: strings.TrimPrefix(l.TTY, "p"), User: l.User,
+		Host: l.Host, Time: l.Time}, true
+}
@@ -0,0 +1,32 @@
+package os
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUtmp(t *testing.T) {
+	login := time.Unix(1791701189, 123000000)
+	records := []UtmpRecord{
+		{Type: UtmpBootTime, Line: "~", User: "reboot", Host: "4.4.0-210-generic", Time: login.Add(-time.Hour)},
+		{Type: UtmpUserProcess, PID: 1234, Line: "pts/0", ID: "ts/0", User: "root", Host: "192.168.1.10", Time: login},
+	}
+	data := EncodeUtmp(records)
+	if len(data) != 2*utmpSize {
+		t.Fatalf("Encoded %v bytes, expect %v", len(data), 2*utmpSize)
+	}
+	// The incomplete record left by truncating is ignored
+	got := ParseUtmp(append(data, 0, 0, 0))
+	if len(got) != len(records) {
+		t.Fatalf("Parsed %v records, expect %v", len(got), len(records))
+	}
+	for i := range records {
+		if !got[i].Time.Equal(records[i].Time) {
+			t.Errorf("Record %v is at %v, expect %v", i, got[i].Time, records[i].Time)
+		}
+		got[i].Time = records[i].Time
+		if got[i] != records[i] {
+			t.Errorf("Record %v is %+v, expect %+v", i, got[i], records[i])
+		}
+	}
+}
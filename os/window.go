@@ -0,0 +1,56 @@
+package os
+
+import "sync"
+
+// window is the size of the terminal window. It is shared by the session and
+// all processes in it, so every running command sees the same size when the
+// client resizes the window, like the winsize of a pty
+type window struct {
+	mu            sync.Mutex
+	width, height int
+	watchers      map[int]func()
+	nextID        int
+}
+
+func newWindow(width, height int) *window {
+	return &window{width: width, height: height, watchers: map[int]func(){}}
+}
+
+func (w *window) size() (width, height int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.width, w.height
+}
+
+// resize changes the size and calls the watchers, like SIGWINCH sent to the
+// processes on the terminal
+func (w *window) resize(width, height int) {
+	w.mu.Lock()
+	if w.width == width && w.height == height {
+		w.mu.Unlock()
+		return
+	}
+	w.width, w.height = width, height
+	watchers := make([]func(), 0, len(w.watchers))
+	for _, f := range w.watchers {
+		watchers = append(watchers, f)
+	}
+	w.mu.Unlock()
+	for _, f := range watchers {
+		f()
+	}
+}
+
+// watch calls f every time the window is resized, until cancel is called
+func (w *window) watch(f func()) (cancel func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	id := w.nextID
+	w.nextID++
+	w.watchers[id] = f
+	return func() {
+		w.mu.Lock()
+		delete(w.watchers, id)
+		w.mu.Unlock()
+	}
+}
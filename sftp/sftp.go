@@ -54,7 +54,7 @@ func (sftp *Sftp) GetRealPath(path string) string {
 
 func NewSftp(conn io.ReadWriter, vfs afero.Fs, user string, log *log.Entry, quitSig chan<- int) *Sftp {
 	u := honeyos.GetUser(user)
-	fs := afero.Afero{vfs}
+	fs := afero.Afero{Fs: vfs}
 	if exists, _ := fs.DirExists(u.Homedir); !exists {
 		fs.MkdirAll(u.Homedir, 0755)
 	}
@@ -12,6 +12,7 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/spf13/viper"
@@ -38,6 +39,7 @@ type SSHSession struct {
 	src           net.Addr
 	clientVersion string
 	sshChan       <-chan ssh.NewChannel
+	conn          *ssh.ServerConn
 	log           *log.Entry
 	sys           *os.System
 	term          string
@@ -58,8 +60,10 @@ type ptyRequest struct {
 	Modes   string
 }
 type winChgRequest struct {
-	Width  uint32
-	Height uint32
+	Width    uint32
+	Height   uint32
+	WidthPx  uint32
+	HeightPx uint32
 }
 
 type tunnelRequest struct {
@@ -76,6 +80,9 @@ type Server struct {
 
 var (
 	ipConnCnt *netconn.IPConnCount = netconn.NewIPConnCount()
+	// sessions are the termination channels of the sessions open, for
+	// dropping them when the machine goes down
+	sessions sync.Map
 )
 
 // NewSSHSession create new SSH connection based on existing socket connection
@@ -100,13 +107,18 @@ func NewSSHSession(nConn net.Conn, sshConfig *ssh.ServerConfig, vfs afero.Fs) (*
 		src:           conn.RemoteAddr(),
 		clientVersion: string(conn.ClientVersion()),
 		sshChan:       chans,
+		conn:          conn,
 		log:           logger,
 		fs:            vfs,
 	}, nil
 }
 
 func (s *SSHSession) handleNewSession(newChan ssh.NewChannel) {
-
+	// Clients logging in as the machine went down are dropped with the rest
+	if time.Now().Before(os.BootTime()) {
+		s.conn.Close()
+		return
+	}
 	channel, requests, err := newChan.Accept()
 	if err != nil {
 		s.log.WithError(err).Error("Could not accept channel")
@@ -115,6 +127,8 @@ func (s *SSHSession) handleNewSession(newChan ssh.NewChannel) {
 	var sh *os.Shell
 	go func(in <-chan *ssh.Request, channel ssh.Channel) {
 		quitSignal := make(chan int, 1)
+		sessions.Store(quitSignal, true)
+		defer sessions.Delete(quitSignal)
 		for {
 			select {
 			case req := <-in:
@@ -135,6 +149,8 @@ func (s *SSHSession) handleNewSession(newChan ssh.NewChannel) {
 						s.log.WithField("reqType", req.Type).Infof("User requesting pty(%v %vx%v)", ptyreq.Term, ptyreq.Width, ptyreq.Height)
 
 						s.sys = os.NewSystem(s.user, viper.GetString("server.hostname"), s.fs, channel, int(ptyreq.Width), int(ptyreq.Height), s.log)
+						s.sys.SetTerminalModes(ptyreq.Modes)
+						s.sys.SetEnv("TERM", ptyreq.Term)
 						s.term = ptyreq.Term
 						req.Reply(true, nil)
 					}
@@ -148,6 +164,9 @@ func (s *SSHSession) handleNewSession(newChan ssh.NewChannel) {
 							"envVarName":  envReq.Name,
 							"envVarValue": envReq.Value,
 						}).Infof("User sends envvar:%v=%v", envReq.Name, envReq.Value)
+						if s.sys != nil {
+							s.sys.SetEnv(envReq.Name, envReq.Value)
+						}
 						req.Reply(true, nil)
 					}
 				case "shell":
@@ -205,12 +224,14 @@ func (s *SSHSession) handleNewSession(newChan ssh.NewChannel) {
 					}
 				case "window-change":
 					s.log.WithField("reqType", req.Type).Info("User shell window size changed")
-					if sh != nil {
-						winChg := &winChgRequest{}
-						if err := ssh.Unmarshal(req.Payload, winChg); err != nil {
-							req.Reply(false, nil)
-						}
-						sh.SetSize(int(winChg.Width), int(winChg.Height))
+					winChg := &winChgRequest{}
+					if err := ssh.Unmarshal(req.Payload, winChg); err != nil {
+						req.Reply(false, nil)
+						break
+					}
+					// Commands running in the session are notified of the new size
+					if s.sys != nil {
+						s.sys.SetSize(int(winChg.Width), int(winChg.Height))
 					}
 				case "exec":
 					cmd := string(req.Payload[4:])
@@ -218,29 +239,29 @@ func (s *SSHSession) handleNewSession(newChan ssh.NewChannel) {
 						"reqType": req.Type,
 						"cmd":     cmd,
 					}).Info("User request remote exec")
-					args := strings.Split(cmd, " ")
-					var sys *os.System
-					if s.sys == nil {
-						sys = os.NewSystem(s.user, viper.GetString("server.hostname"), s.fs, channel, 80, 24, s.log)
-					} else {
-						sys = s.sys
-					}
-					if strings.HasPrefix(args[0], "scp") {
+					args, err := os.SplitArgs(cmd)
+					if err == nil && len(args) > 0 && strings.HasPrefix(args[0], "scp") {
 						scp := command.NewSCP(channel, s.fs, s.log.WithField("module", "scp"))
 						go scp.Main(args[1:], quitSignal)
 						req.Reply(true, nil)
 						continue
 					}
-					n, err := sys.Exec(args[0], args[1:])
-					if err != nil {
-						channel.Write([]byte(fmt.Sprintf("%v: command not found\r\n", cmd)))
+					if s.sys == nil {
+						s.sys = os.NewSystem(s.user, viper.GetString("server.hostname"), s.fs, channel, 80, 24, s.log)
 					}
-					quitSignal <- n
+					sh = os.NewShell(s.sys, s.src.String(), s.log.WithField("module", "shell"), quitSignal)
+					go sh.HandleExec(cmd)
 					req.Reply(true, nil)
 				default:
 					s.log.WithField("reqType", req.Type).Infof("Unknown channel request type %v", req.Type)
 				}
 			case ret := <-quitSignal:
+				if ret == os.Disconnect {
+					// The machine went down, so the connection just drops
+					s.log.Info("Dropping connection as the machine goes down")
+					s.conn.Close()
+					return
+				}
 				s.log.Info("User closing channel")
 				defer closeChannel(channel, ret)
 				return
@@ -289,6 +310,7 @@ func (s *SSHSession) handleNewConn() {
 					newChannel.Reject(ssh.ResourceShortage, "Cannot create new channel")
 				}
 				go ssh.DiscardRequests(req)
+				newChannel := newChannel
 				go func() {
 					s.log.WithFields(log.Fields{
 						"host": host,
@@ -358,7 +380,11 @@ func NewServer(configPath string, hostKey []byte) (s Server) {
 	if err != nil {
 		log.Error("Cannot create virtual filesystem")
 	}
-	vfs := afero.NewCopyOnWriteFs(zipfs, backupFS)
+	vfs := os.NewOwnerFs(afero.NewCopyOnWriteFs(os.NewPersonaFs(zipfs, viper.GetString("server.hostname")), backupFS))
+	// Most scripts expect a writable /tmp
+	if exists, _ := afero.DirExists(vfs, "/tmp"); !exists {
+		vfs.MkdirAll("/tmp", 0777)
+	}
 	err = os.LoadUsers(path.Join(configPath, viper.GetString("virtualfs.uidMappingFile")))
 	if err != nil {
 		log.Errorf("Cannot load user mapping file %v", path.Join(configPath, viper.GetString("virtualfs.uidMappingFile")))
@@ -445,16 +471,32 @@ func (sc Server) ListenAndServe() {
 		log.WithError(err).Fatal("Could not create listening socket")
 	}
 	defer listener.Close()
+	// The machine going down drops every session, and clients are refused
+	// until it is up again
+	os.OnShutdown(func(time.Time) {
+		sessions.Range(func(key, _ interface{}) bool {
+			select {
+			case key.(chan int) <- os.Disconnect:
+			default:
+			}
+			return true
+		})
+	})
 
 	for {
 		nConn, err := listener.Accept()
+		if err != nil {
+			log.WithError(err).Error("Failed to accept incoming connection")
+			continue
+		}
 		host, port, _ := net.SplitHostPort(nConn.RemoteAddr().String())
 		log.WithFields(log.Fields{
 			"srcIP": host,
 			"port":  port,
 		}).Info("Connection established")
-		if err != nil {
-			log.WithError(err).Error("Failed to accept incoming connection")
+		if time.Now().Before(os.BootTime()) {
+			log.WithField("srcIP", host).Info("Dropping connection as the machine is booting")
+			nConn.Close()
 			continue
 		}
 		cnt := ipConnCnt.Read(host)
@@ -0,0 +1,43 @@
+package terminal
+
+import "strings"
+
+// SGR parameters for common colors and attributes
+const (
+	Reset   = "0"
+	Bold    = "01"
+	Reverse = "07"
+	Red     = "31"
+	Green   = "32"
+	Yellow  = "33"
+	Blue    = "34"
+	Magenta = "35"
+	Cyan    = "36"
+)
+
+// colorTerms are prefixes of TERM known to support color, from the TERM
+// list of dircolors
+var colorTerms = []string{
+	"ansi", "color", "con", "cons25", "console", "cygwin", "dtterm", "eterm",
+	"gnome", "hurd", "jfbterm", "konsole", "kterm", "linux", "mach", "mlterm",
+	"putty", "rxvt", "screen", "st", "terminator", "tmux", "vt100", "xterm",
+}
+
+// Color wraps the text with SGR escape sequence, e.g. Color("01;34", "bin")
+// for bold blue. Text is returned as is if sgr is empty
+func Color(sgr, text string) string {
+	if sgr == "" {
+		return text
+	}
+	return "\x1b[" + sgr + "m" + text + "\x1b[0m"
+}
+
+// ColorTerm tells if the terminal type in TERM supports color
+func ColorTerm(term string) bool {
+	for _, t := range colorTerms {
+		if strings.HasPrefix(term, t) {
+			return true
+		}
+	}
+	return false
+}
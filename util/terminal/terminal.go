@@ -0,0 +1,792 @@
+// Package terminal is a line editor for the interactive shell, mimicking
+// what readline does in bash. It has the same interface as
+// golang.org/x/crypto/ssh/terminal, with history search added
+package terminal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlG     = 7
+	keyCtrlH     = 8
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = '\r'
+	keyNewline   = '\n'
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlR     = 18
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyCtrlY     = 25
+	keyEscape    = 27
+	keyBackspace = 127
+
+	// Keys with escape sequence are mapped to the surrogate range which is
+	// never a valid rune
+	keyUnknown = 0xd800 + iota
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyHome
+	keyEnd
+	keyAltLeft
+	keyAltRight
+	keyAltB
+	keyAltF
+	keyAltD
+	keyAltBackspace
+	keyDelete
+	keyInsert
+	keyPasteStart
+	keyPasteEnd
+)
+
+const (
+	maxLineLength = 4096
+	maxHistory    = 1000
+)
+
+// ErrInterrupt is returned by ReadLine when user presses Ctrl-C
+var ErrInterrupt = errors.New("interrupted")
+
+// ErrDetach is returned by ReadLine when user presses the detach key set
+// with SetDetachKey followed by d
+var ErrDetach = errors.New("detached")
+
+// Terminal reads and edits line of input from user
+type Terminal struct {
+	lock sync.Mutex
+	r    io.Reader
+	w    io.Writer
+
+	// promptHead is everything before the last line of prompt, which is not
+	// redrawn when the line changes
+	promptHead, prompt []rune
+	line               []rune
+	pos                int
+	width, height      int
+	// cursorRow is the row where cursor is, counting from the last line of
+	// prompt
+	cursorRow int
+	reading   bool
+	lastKey   rune
+	inBuf     []byte
+	outBuf    bytes.Buffer
+
+	history     []string
+	histIndex   int
+	histPending []rune
+	search      *searchState
+	lastSearch  []rune
+	killed      []rune
+	pasteActive bool
+	// overwrite is toggled by Insert key, typed text replaces what is under
+	// the cursor
+	overwrite bool
+	// bracketedPaste is whether the terminal is asked to mark pasted text
+	bracketedPaste bool
+	// noEcho hides what user types, when echo is turned off with stty
+	noEcho bool
+	// detachKey is the command key of terminal multiplexer, like Ctrl-A of
+	// screen, and prefixed is set after it is pressed
+	detachKey rune
+	prefixed  bool
+}
+
+// searchState is the state of reverse-i-search
+type searchState struct {
+	query    []rune
+	index    int
+	matchPos int
+	failed   bool
+	origLine []rune
+	origPos  int
+}
+
+// NewTerminal creates a line editor reading and echoing from rw
+func NewTerminal(rw io.ReadWriter, prompt string) *Terminal {
+	t := &Terminal{
+		r:         rw,
+		w:         rw,
+		width:     80,
+		height:    24,
+		histIndex: -1,
+	}
+	t.setPrompt(prompt)
+	return t
+}
+
+// SetPrompt sets the prompt to be used for next ReadLine
+func (t *Terminal) SetPrompt(prompt string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.setPrompt(prompt)
+}
+
+func (t *Terminal) setPrompt(prompt string) {
+	runes := []rune(prompt)
+	t.promptHead, t.prompt = nil, runes
+	for i := len(runes) - 1; i >= 0; i-- {
+		if runes[i] == '\n' {
+			t.promptHead, t.prompt = runes[:i+1], runes[i+1:]
+			break
+		}
+	}
+}
+
+// SetSize updates the terminal width and height
+func (t *Terminal) SetSize(width, height int) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if width <= 0 {
+		width = 80
+	}
+	t.width, t.height = width, height
+	if t.reading {
+		t.refresh()
+		t.flush()
+	}
+	return nil
+}
+
+// SetBracketedPasteMode asks the terminal to mark pasted text with escape
+// sequence while reading line, like readline does
+func (t *Terminal) SetBracketedPasteMode(on bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.bracketedPaste = on
+}
+
+// SetEcho sets if the line being typed is shown
+func (t *Terminal) SetEcho(on bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.noEcho = !on
+}
+
+// SetDetachKey makes ReadLine return ErrDetach when the key is pressed
+// followed by d, like Ctrl-A d in screen. Other keys after it are ignored,
+// except the key itself which is handled as usual. 0 turns it off
+func (t *Terminal) SetDetachKey(key rune) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.detachKey, t.prefixed = key, false
+}
+
+// History returns lines in history, oldest first
+func (t *Terminal) History() []string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return append([]string{}, t.history...)
+}
+
+// AddHistory appends the line to history
+func (t *Terminal) AddHistory(line string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.history = append(t.history, line)
+	if len(t.history) > maxHistory {
+		t.history = t.history[len(t.history)-maxHistory:]
+	}
+}
+
+// ClearHistory removes all lines in history
+func (t *Terminal) ClearHistory() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.history = nil
+}
+
+// Write writes the data to terminal with \n converted to \r\n. If ReadLine
+// is in progress the line being edited is redrawn after the data
+func (t *Terminal) Write(p []byte) (int, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.reading {
+		t.clearLine()
+	}
+	t.queue(p)
+	if t.reading {
+		t.queueRunes(t.promptHead)
+		t.refresh()
+	}
+	if err := t.flush(); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// ReadLine reads a line of input. It returns io.EOF if user presses Ctrl-D
+// on empty line, or ErrInterrupt if Ctrl-C is pressed
+func (t *Terminal) ReadLine() (string, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.line, t.pos, t.cursorRow = nil, 0, 0
+	t.histIndex, t.search = -1, nil
+	t.reading = true
+	defer func() {
+		t.reading = false
+	}()
+	if t.bracketedPaste {
+		t.queue([]byte("\x1b[?2004h"))
+	}
+	t.queueRunes(t.promptHead)
+	if t.noEcho {
+		t.queueRunes(t.prompt)
+	}
+	t.refresh()
+
+	readBuf := make([]byte, 256)
+	for {
+		for len(t.inBuf) > 0 {
+			key, rest := bytesToKey(t.inBuf, t.pasteActive)
+			if key == utf8.RuneError && len(rest) == len(t.inBuf) {
+				// Partial key sequence, wait for more input
+				break
+			}
+			t.inBuf = rest
+			line, done, err := t.handleKey(key)
+			t.lastKey = key
+			if done || err != nil {
+				if t.bracketedPaste {
+					t.queue([]byte("\x1b[?2004l"))
+				}
+				t.flush()
+				return line, err
+			}
+		}
+		if err := t.flush(); err != nil {
+			return "", err
+		}
+		t.lock.Unlock()
+		n, err := t.r.Read(readBuf)
+		t.lock.Lock()
+		t.inBuf = append(t.inBuf, readBuf[:n]...)
+		if err != nil && len(t.inBuf) == 0 {
+			return "", err
+		}
+	}
+}
+
+// handleKey processes the key. done is true once user finishes the line
+func (t *Terminal) handleKey(key rune) (line string, done bool, err error) {
+	if t.detachKey != 0 && !t.pasteActive {
+		switch {
+		case t.prefixed:
+			t.prefixed = false
+			if key == 'd' {
+				return "", true, ErrDetach
+			}
+			if key != t.detachKey {
+				return
+			}
+		case key == t.detachKey:
+			t.prefixed = true
+			return
+		}
+	}
+	if t.search != nil {
+		if !t.handleSearchKey(key) {
+			return
+		}
+	}
+	if t.pasteActive && key != keyEnter && key != keyNewline && key != keyPasteEnd {
+		if key >= ' ' && key < keyUnknown {
+			t.insert(key)
+		}
+		return
+	}
+	switch key {
+	case keyEnter, keyNewline:
+		// \r\n is a single enter
+		if key == keyNewline && t.lastKey == keyEnter {
+			return
+		}
+		t.moveCursor(len(t.line))
+		if !t.noEcho {
+			t.queue([]byte("\r\n"))
+		}
+		return string(t.line), true, nil
+	case keyCtrlC:
+		t.moveCursor(len(t.line))
+		t.queue([]byte("^C\r\n"))
+		return "", true, ErrInterrupt
+	case keyCtrlD:
+		if len(t.line) == 0 {
+			return "", true, io.EOF
+		}
+		if t.pos < len(t.line) {
+			t.line = append(t.line[:t.pos], t.line[t.pos+1:]...)
+			t.refresh()
+		}
+	case keyDelete:
+		if t.pos < len(t.line) {
+			t.line = append(t.line[:t.pos], t.line[t.pos+1:]...)
+			t.refresh()
+		}
+	case keyInsert:
+		t.overwrite = !t.overwrite
+	case keyCtrlA, keyHome:
+		t.moveCursor(0)
+	case keyCtrlE, keyEnd:
+		t.moveCursor(len(t.line))
+	case keyCtrlB, keyLeft:
+		if t.pos > 0 {
+			t.moveCursor(t.pos - 1)
+		}
+	case keyCtrlF, keyRight:
+		if t.pos < len(t.line) {
+			t.moveCursor(t.pos + 1)
+		}
+	case keyAltLeft, keyAltB:
+		t.moveCursor(t.wordLeft())
+	case keyAltRight, keyAltF:
+		t.moveCursor(t.wordRight())
+	case keyAltD:
+		t.kill(t.pos, t.wordRight())
+	case keyAltBackspace:
+		t.kill(t.wordLeft(), t.pos)
+	case keyBackspace, keyCtrlH:
+		if t.pos > 0 {
+			t.line = append(t.line[:t.pos-1], t.line[t.pos:]...)
+			t.pos--
+			t.refresh()
+		}
+	case keyCtrlK:
+		t.kill(t.pos, len(t.line))
+	case keyCtrlU:
+		t.kill(0, t.pos)
+	case keyCtrlW:
+		// Delete backward to the previous whitespace
+		start := t.pos
+		for start > 0 && t.line[start-1] == ' ' {
+			start--
+		}
+		for start > 0 && t.line[start-1] != ' ' {
+			start--
+		}
+		t.kill(start, t.pos)
+	case keyCtrlY:
+		for _, r := range t.killed {
+			t.insert(r)
+		}
+	case keyCtrlL:
+		t.queue([]byte("\x1b[H\x1b[2J"))
+		t.queueRunes(t.promptHead)
+		t.cursorRow = 0
+		t.refresh()
+	case keyCtrlP, keyUp:
+		if t.histIndex+1 >= len(t.history) {
+			return
+		}
+		if t.histIndex == -1 {
+			t.histPending = append([]rune{}, t.line...)
+		}
+		t.histIndex++
+		t.setLine([]rune(t.history[len(t.history)-1-t.histIndex]))
+	case keyCtrlN, keyDown:
+		switch t.histIndex {
+		case -1:
+		case 0:
+			t.histIndex--
+			t.setLine(t.histPending)
+		default:
+			t.histIndex--
+			t.setLine([]rune(t.history[len(t.history)-1-t.histIndex]))
+		}
+	case keyCtrlR:
+		t.search = &searchState{
+			index:    len(t.history),
+			origLine: append([]rune{}, t.line...),
+			origPos:  t.pos,
+		}
+		t.refresh()
+	case keyPasteStart:
+		t.pasteActive = true
+	case keyPasteEnd:
+		t.pasteActive = false
+	default:
+		if unicode.IsPrint(key) {
+			t.insert(key)
+		}
+	}
+	return
+}
+
+// handleSearchKey processes the key during reverse-i-search. It returns
+// true if the search ends and the key should be processed as usual
+func (t *Terminal) handleSearchKey(key rune) bool {
+	s := t.search
+	switch {
+	case key == keyCtrlR:
+		if len(s.query) == 0 {
+			s.query = append([]rune{}, t.lastSearch...)
+		}
+		t.searchHistory(s.index - 1)
+	case key == keyBackspace || key == keyCtrlH:
+		if len(s.query) > 0 {
+			s.query = s.query[:len(s.query)-1]
+			t.searchHistory(len(t.history) - 1)
+		}
+	case key == keyCtrlG:
+		t.search = nil
+		t.line, t.pos = s.origLine, s.origPos
+	case key == keyCtrlC:
+		t.search = nil
+		return true
+	case key != keyEscape && key < keyUnknown && unicode.IsPrint(key):
+		s.query = append(s.query, key)
+		t.searchHistory(s.index)
+	default:
+		// Other keys end the search, leaving the matched line for editing
+		t.search = nil
+		t.lastSearch = s.query
+		if key == keyEscape {
+			t.refresh()
+			return false
+		}
+		return true
+	}
+	t.refresh()
+	return false
+}
+
+// searchHistory finds the latest history entry containing the query,
+// starting from index going back
+func (t *Terminal) searchHistory(from int) {
+	s := t.search
+	if from >= len(t.history) {
+		from = len(t.history) - 1
+	}
+	query := string(s.query)
+	for i := from; i >= 0; i-- {
+		if pos := strings.Index(t.history[i], query); pos >= 0 {
+			s.index, s.failed = i, false
+			t.line = []rune(t.history[i])
+			t.pos = utf8.RuneCountInString(t.history[i][:pos])
+			return
+		}
+	}
+	s.failed = true
+}
+
+func (t *Terminal) insert(r rune) {
+	if t.overwrite && t.search == nil && t.pos < len(t.line) {
+		t.line[t.pos] = r
+		t.pos++
+		t.refresh()
+		return
+	}
+	if len(t.line) >= maxLineLength {
+		return
+	}
+	t.line = append(t.line, 0)
+	copy(t.line[t.pos+1:], t.line[t.pos:])
+	t.line[t.pos] = r
+	t.pos++
+	if t.search == nil && t.pos == len(t.line) && RuneWidth(r) == 1 && (visualLength(t.prompt)+runesWidth(t.line))%t.width != 0 {
+		// Typing at end of line only needs the key echoed
+		if !t.noEcho {
+			t.queueRunes([]rune{r})
+		}
+		return
+	}
+	t.refresh()
+}
+
+// kill removes text between start and end of the line, which can be yanked
+// back with Ctrl-Y
+func (t *Terminal) kill(start, end int) {
+	if start >= end {
+		return
+	}
+	t.killed = append([]rune{}, t.line[start:end]...)
+	t.line = append(t.line[:start], t.line[end:]...)
+	t.pos = start
+	t.refresh()
+}
+
+func (t *Terminal) setLine(line []rune) {
+	t.line = append([]rune{}, line...)
+	t.pos = len(t.line)
+	t.refresh()
+}
+
+func (t *Terminal) wordLeft() int {
+	pos := t.pos
+	for pos > 0 && !isWordChar(t.line[pos-1]) {
+		pos--
+	}
+	for pos > 0 && isWordChar(t.line[pos-1]) {
+		pos--
+	}
+	return pos
+}
+
+func (t *Terminal) wordRight() int {
+	pos := t.pos
+	for pos < len(t.line) && !isWordChar(t.line[pos]) {
+		pos++
+	}
+	for pos < len(t.line) && isWordChar(t.line[pos]) {
+		pos++
+	}
+	return pos
+}
+
+func isWordChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func (t *Terminal) moveCursor(pos int) {
+	if pos == t.pos && t.search == nil {
+		return
+	}
+	t.pos = pos
+	t.refresh()
+}
+
+// currentPrompt is the last line of prompt, or the search status in
+// reverse-i-search
+func (t *Terminal) currentPrompt() []rune {
+	if s := t.search; s != nil {
+		status := "reverse-i-search"
+		if s.failed {
+			status = "failed " + status
+		}
+		return []rune(fmt.Sprintf("(%v)`%v': ", status, string(s.query)))
+	}
+	return t.prompt
+}
+
+// clearLine erases the prompt and line from screen, leaving the cursor at
+// the start of the last line of prompt
+func (t *Terminal) clearLine() {
+	if t.cursorRow > 0 {
+		fmt.Fprintf(&t.outBuf, "\x1b[%dA", t.cursorRow)
+	}
+	t.queue([]byte("\r\x1b[J"))
+	t.cursorRow = 0
+}
+
+// refresh redraws the last line of prompt and the line, then moves the
+// cursor to where it should be
+func (t *Terminal) refresh() {
+	if t.noEcho {
+		return
+	}
+	prompt := t.currentPrompt()
+	t.clearLine()
+	t.queueRunes(prompt)
+	t.queueRunes(t.line)
+
+	promptLen := visualLength(prompt)
+	end := promptLen + runesWidth(t.line)
+	if end > 0 && end%t.width == 0 {
+		// Cursor stays at the last column after filling up a row, move it to
+		// next row explicitly
+		t.queue([]byte("\r\n"))
+	}
+	endRow := end / t.width
+	cur := promptLen + runesWidth(t.line[:t.pos])
+	row, col := cur/t.width, cur%t.width
+	if endRow > row {
+		fmt.Fprintf(&t.outBuf, "\x1b[%dA", endRow-row)
+	}
+	t.queue([]byte("\r"))
+	if col > 0 {
+		fmt.Fprintf(&t.outBuf, "\x1b[%dC", col)
+	}
+	t.cursorRow = row
+}
+
+func (t *Terminal) queue(p []byte) {
+	p = bytes.Replace(p, []byte("\r\n"), []byte("\n"), -1)
+	t.outBuf.Write(bytes.Replace(p, []byte("\n"), []byte("\r\n"), -1))
+}
+
+func (t *Terminal) queueRunes(r []rune) {
+	t.queue([]byte(string(r)))
+}
+
+func (t *Terminal) flush() error {
+	if t.outBuf.Len() == 0 {
+		return nil
+	}
+	_, err := t.w.Write(t.outBuf.Bytes())
+	t.outBuf.Reset()
+	return err
+}
+
+// visualLength returns the number of columns the text takes on screen,
+// skipping escape sequences
+func visualLength(runes []rune) int {
+	b := []byte(string(runes))
+	n := 0
+	for i := 0; i < len(b); {
+		if b[i] == keyEscape {
+			l := sequenceLength(b[i:])
+			if l < 0 {
+				break
+			}
+			i += l
+			continue
+		}
+		r, l := utf8.DecodeRune(b[i:])
+		i += l
+		n += RuneWidth(r)
+	}
+	return n
+}
+
+// sequenceLength returns the length of escape sequence at the beginning of
+// b, or -1 if it is incomplete
+func sequenceLength(b []byte) int {
+	if len(b) < 2 {
+		return -1
+	}
+	switch b[1] {
+	case '[':
+		// CSI: parameter bytes, intermediate bytes, then the final byte
+		i := 2
+		for i < len(b) && b[i] >= 0x30 && b[i] <= 0x3f {
+			i++
+		}
+		for i < len(b) && b[i] >= 0x20 && b[i] <= 0x2f {
+			i++
+		}
+		if i >= len(b) {
+			return -1
+		}
+		if b[i] >= 0x40 && b[i] <= 0x7e {
+			return i + 1
+		}
+		// Malformed sequence, drop what we have seen
+		return i
+	case ']', 'P', '^', '_':
+		// OSC, DCS, PM and APC are strings terminated by BEL or ST
+		for i := 2; i < len(b); i++ {
+			if b[i] == '\a' {
+				return i + 1
+			}
+			if b[i] == keyEscape && i+1 < len(b) && b[i+1] == '\\' {
+				return i + 2
+			}
+		}
+		if len(b) > maxLineLength {
+			return len(b)
+		}
+		return -1
+	case 'O':
+		if len(b) < 3 {
+			return -1
+		}
+		return 3
+	}
+	// ESC followed by other key is Alt/Meta + key
+	if !utf8.FullRune(b[1:]) {
+		return -1
+	}
+	_, l := utf8.DecodeRune(b[1:])
+	return 1 + l
+}
+
+// bytesToKey parses a key from the input. If the input is only a partial
+// key sequence, utf8.RuneError and the input itself is returned
+func bytesToKey(b []byte, pasteActive bool) (rune, []byte) {
+	if b[0] != keyEscape {
+		if !utf8.FullRune(b) {
+			return utf8.RuneError, b
+		}
+		r, l := utf8.DecodeRune(b)
+		return r, b[l:]
+	}
+	n := sequenceLength(b)
+	if n < 0 {
+		return utf8.RuneError, b
+	}
+	seq, rest := b[:n], b[n:]
+	switch {
+	case seq[1] == '[' && n > 2:
+		params, final := string(seq[2:n-1]), seq[n-1]
+		if pasteActive {
+			if params == "201" && final == '~' {
+				return keyPasteEnd, rest
+			}
+			return keyUnknown, rest
+		}
+		return csiKey(params, final), rest
+	case seq[1] == 'O' && !pasteActive:
+		return csiKey("", seq[2]), rest
+	}
+	if n == 2 && !pasteActive {
+		switch seq[1] {
+		case 'b':
+			return keyAltB, rest
+		case 'f':
+			return keyAltF, rest
+		case 'd':
+			return keyAltD, rest
+		case keyBackspace, keyCtrlH:
+			return keyAltBackspace, rest
+		}
+	}
+	// Replies from terminal and keys we don't handle are skipped
+	return keyUnknown, rest
+}
+
+func csiKey(params string, final byte) rune {
+	// Modifier parameter 3 is Alt, 5 is Ctrl
+	modified := strings.HasSuffix(params, ";3") || strings.HasSuffix(params, ";5")
+	switch final {
+	case 'A':
+		return keyUp
+	case 'B':
+		return keyDown
+	case 'C':
+		if modified {
+			return keyAltRight
+		}
+		return keyRight
+	case 'D':
+		if modified {
+			return keyAltLeft
+		}
+		return keyLeft
+	case 'H':
+		return keyHome
+	case 'F':
+		return keyEnd
+	case '~':
+		switch params {
+		case "1", "7":
+			return keyHome
+		case "2":
+			return keyInsert
+		case "3":
+			return keyDelete
+		case "4", "8":
+			return keyEnd
+		case "200":
+			return keyPasteStart
+		}
+	}
+	return keyUnknown
+}
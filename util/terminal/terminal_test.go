@@ -0,0 +1,98 @@
+package terminal
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestBytesToKey(t *testing.T) {
+	tests := []struct {
+		in   string
+		key  rune
+		rest string
+	}{
+		{"a", 'a', ""},
+		{"\x1b[A", keyUp, ""},
+		{"\x1bOD", keyLeft, ""},
+		{"\x1b[1;5C", keyAltRight, ""},
+		{"\x1b[1~x", keyHome, "x"},
+		{"\x1b[?1;2cls", keyUnknown, "ls"},
+		{"\x1b]11;rgb:0000/0000/0000\x1b\\ls", keyUnknown, "ls"},
+		{"\x1b[200~", keyPasteStart, ""},
+		{"\x1b[3~", keyDelete, ""},
+		{"\x1bb", keyAltB, ""},
+		{"\xe4\xbd\xa0", '你', ""},
+	}
+	for _, test := range tests {
+		key, rest := bytesToKey([]byte(test.in), false)
+		if key != test.key || string(rest) != test.rest {
+			t.Errorf("%q: expect %v %q, got %v %q", test.in, test.key, test.rest, key, rest)
+		}
+	}
+	for _, partial := range []string{"\x1b", "\x1b[", "\x1b[1;", "\x1b]0;title", "\xe4\xbd"} {
+		if key, rest := bytesToKey([]byte(partial), false); key != 0xfffd || len(rest) != len(partial) {
+			t.Errorf("%q: expect partial sequence, got %v %q", partial, key, rest)
+		}
+	}
+}
+
+func TestVisualLength(t *testing.T) {
+	prompt := "\x1b]0;root@host: ~\a\x1b[01;32mroot@host\x1b[00m:~# "
+	if n := visualLength([]rune(prompt)); n != 13 {
+		t.Errorf("Expect 13, got %v", n)
+	}
+}
+
+type fakeTerm struct {
+	io.Reader
+	out bytes.Buffer
+}
+
+func (f *fakeTerm) Write(p []byte) (int, error) { return f.out.Write(p) }
+
+func TestReadLine(t *testing.T) {
+	term := NewTerminal(&fakeTerm{Reader: bytes.NewBufferString(
+		"echo a\rls -l\recho \x1b[200~b\x1b[201~c\r\x12ec\x12\r\x12zz\x07x\r" +
+			"cat a b\x1bb\x1bb\x1b[2~x\x1b[2~\x1bf\x1b\x7fz\x01\x1b[3~\r")}, "$ ")
+	for _, expect := range []string{"echo a", "ls -l", "echo bc", "echo a", "x", "at x z"} {
+		line, err := term.ReadLine()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line != expect {
+			t.Errorf("Expect %q, got %q", expect, line)
+		}
+		term.AddHistory(line)
+	}
+	if _, err := term.ReadLine(); err != io.EOF {
+		t.Errorf("Expect EOF, got %v", err)
+	}
+}
+
+func TestStringWidth(t *testing.T) {
+	tests := map[string]int{
+		"abc":                  3,
+		"文件.txt":               8,
+		"файл":                 4,
+		"é":                   1,
+		"한글":                   4,
+		"\x1b[1;34mbin\x1b[0m": 3,
+	}
+	for s, expect := range tests {
+		if n := StringWidth(s); n != expect {
+			t.Errorf("%q: expect %v, got %v", s, expect, n)
+		}
+	}
+}
+
+func TestDetachKey(t *testing.T) {
+	term := NewTerminal(&fakeTerm{Reader: bytes.NewBufferString("ls\x01x\x01\x01a \rtop\x01d")}, "$ ")
+	term.SetDetachKey(keyCtrlA)
+	if line, err := term.ReadLine(); err != nil || line != "a ls" {
+		t.Errorf("Expect %q, got %q, %v", "a ls", line, err)
+	}
+	if _, err := term.ReadLine(); err != ErrDetach {
+		t.Errorf("Expect ErrDetach, got %v", err)
+	}
+}
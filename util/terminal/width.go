@@ -0,0 +1,62 @@
+package terminal
+
+import "unicode"
+
+// wideRanges are the East Asian wide and fullwidth characters, which take
+// two columns on terminal
+var wideRanges = [][2]rune{
+	{0x1100, 0x115f},
+	{0x231a, 0x231b},
+	{0x2e80, 0x303e},
+	{0x3041, 0x33ff},
+	{0x3400, 0x4dbf},
+	{0x4e00, 0x9fff},
+	{0xa000, 0xa4cf},
+	{0xa960, 0xa97f},
+	{0xac00, 0xd7a3},
+	{0xf900, 0xfaff},
+	{0xfe10, 0xfe19},
+	{0xfe30, 0xfe6f},
+	{0xff00, 0xff60},
+	{0xffe0, 0xffe6},
+	{0x1f300, 0x1f64f},
+	{0x1f900, 0x1f9ff},
+	{0x20000, 0x2fffd},
+	{0x30000, 0x3fffd},
+}
+
+// RuneWidth returns the number of columns the rune takes on terminal
+func RuneWidth(r rune) int {
+	switch {
+	case r == 0 || r < 0x20 || r == 0x7f:
+		return 0
+	case r < 0x300:
+		return 1
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) || r == 0x200b:
+		// Combining marks and zero width characters
+		return 0
+	}
+	for _, rg := range wideRanges {
+		if r < rg[0] {
+			break
+		}
+		if r <= rg[1] {
+			return 2
+		}
+	}
+	return 1
+}
+
+// StringWidth returns the number of columns the string takes on terminal.
+// Escape sequences like color codes take no space
+func StringWidth(s string) int {
+	return visualLength([]rune(s))
+}
+
+func runesWidth(runes []rune) int {
+	n := 0
+	for _, r := range runes {
+		n += RuneWidth(r)
+	}
+	return n
+}
@@ -22,6 +22,17 @@ type File struct {
 	dirOffset int
 }
 
+// newHandle returns a copy of the node for reading, so that each opened file
+// has its own offset and buffer and closing it won't affect the tree
+func (f *File) newHandle() *File {
+	return &File{
+		FileInfo: f.FileInfo,
+		zipFile:  f.zipFile,
+		children: f.children,
+		SymLink:  f.SymLink,
+	}
+}
+
 func (f *File) fillBuffer(offset int64) (err error) {
 	if f.reader == nil {
 		if f.reader, err = f.zipFile.Open(); err != nil {
@@ -63,7 +74,7 @@ func (f *File) Read(p []byte) (n int, err error) {
 	}
 	err = f.fillBuffer(f.offset + int64(len(p)))
 	n = copy(p, f.buf[f.offset:])
-	f.offset += int64(len(p))
+	f.offset += int64(n)
 	return
 }
 
@@ -129,6 +129,13 @@ func GetExtraInfo(fi os.FileInfo) (uid, gid int, aTime, mTime time.Time) {
 		gid = p.GID()
 		aTime = p.Atime()
 		mTime = p.Mtime()
+	case interface {
+		UID() int
+		GID() int
+	}:
+		// Owner changed by users
+		uid, gid = p.UID(), p.GID()
+		aTime, mTime = fi.ModTime(), fi.ModTime()
 	default:
 		uid = 0
 		gid = 0
@@ -32,7 +32,7 @@ func NewVirtualFS(zipFile string) (afero.Fs, error) {
 	if err != nil {
 		return nil, err
 	}
-	defer r.Close()
+	// The archive is kept open as files are read from it lazily
 	vfs := &VirtualFS{
 		root: &File{
 			FileInfo: rootInfo{},
@@ -137,7 +137,7 @@ func (t *VirtualFS) Open(path string) (afero.File, error) {
 	if err != nil {
 		return nil, err
 	}
-	return n, nil
+	return n.newHandle(), nil
 }
 
 func (t *VirtualFS) OpenFile(path string, flag int, mode os.FileMode) (afero.File, error) {
@@ -145,7 +145,7 @@ func (t *VirtualFS) OpenFile(path string, flag int, mode os.FileMode) (afero.Fil
 	if err != nil {
 		return nil, err
 	}
-	return node, nil
+	return node.newHandle(), nil
 }
 
 func (t *VirtualFS) Stat(path string) (os.FileInfo, error) {
@@ -1,7 +1,11 @@
 package virtualfs
 
 import (
+	"archive/zip"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
 	"testing"
 )
 
@@ -40,3 +44,69 @@ func TestFsStat(t *testing.T) {
 		t.Error(fi.Name())
 	}
 }
+
+func TestFileHandles(t *testing.T) {
+	vfs, err := NewVirtualFS("../filesystem.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 16)
+	// Opening the file again after closing it gets a fresh handle
+	for i := 0; i < 2; i++ {
+		f, err := vfs.Open("/bin/cat")
+		if err != nil {
+			t.Fatal(err)
+		}
+		other, err := vfs.Open("/bin/cat")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Reads at the end keep returning EOF without moving past it
+		for j := 0; j < 2; j++ {
+			if n, err := f.Read(buf); n != 0 || err != io.EOF {
+				t.Errorf("Read returned %v, %v", n, err)
+			}
+		}
+		f.Close()
+		// Closing one handle leaves the others open
+		if n, err := other.Read(buf); n != 0 || err != io.EOF {
+			t.Errorf("Read after closing the other handle returned %v, %v", n, err)
+		}
+		other.Close()
+	}
+}
+
+func TestFileContent(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "virtualfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmp.Name())
+	w := zip.NewWriter(tmp)
+	w.Create("etc/")
+	fw, err := w.Create("etc/motd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write([]byte("Welcome to Ubuntu\n"))
+	w.Close()
+	tmp.Close()
+
+	vfs, err := NewVirtualFS(tmp.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The contents are read from the archive after it has been loaded
+	f, err := vfs.Open("/etc/motd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "Welcome to Ubuntu\n" {
+		t.Errorf("%q", data)
+	}
+}